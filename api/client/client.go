@@ -4,13 +4,17 @@ package client
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/kelda/kelda/api"
 	"github.com/kelda/kelda/api/pb"
 	"github.com/kelda/kelda/connection"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/version"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -26,6 +30,15 @@ type Client interface {
 	// Close the grpc connection.
 	Close() error
 
+	// Query retrieves the rows of `table` that match `q` into `v`, a pointer to
+	// a slice of database structs.  The query is evaluated by the server.
+	Query(table db.TableType, q db.Query, v interface{}) error
+
+	// Count returns the number of rows of `table` that match `q`, with each
+	// combination of values of `fields`.  The rows are counted by the server,
+	// so only the counts are sent.
+	Count(table db.TableType, q db.Query, fields ...string) ([]db.Count, error)
+
 	// QueryMachines retrieves the machines tracked by the Quilt daemon.
 	QueryMachines() ([]db.Machine, error)
 
@@ -53,13 +66,105 @@ type Client interface {
 	// Only defined on the daemon.
 	QueryMinionCounters(string) ([]pb.Counter, error)
 
+	// QueryDecisions retrieves the reconciliation decisions recorded by the
+	// Quilt daemon, or by the minion with the given host if it's non-empty.
+	QueryDecisions(string) ([]pb.Decision, error)
+
+	// QueryJoinScores retrieves explanations of the most recent scored joins,
+	// from the Quilt daemon, or from the minion with the given host if it's
+	// non-empty.
+	QueryJoinScores(string) ([]pb.JoinScores, error)
+
+	// SetLogLevels sets the log levels of the given modules on the Quilt
+	// daemon, or on the minion with the given host if it's non-empty, and
+	// returns the resulting level of every module.  A level of "default"
+	// reverts the module to the level of the process.
+	SetLogLevels(host string, levels map[string]string) (map[string]string, error)
+
+	// Attach connects `stdin` and `stdout` to the container with `dockerID`
+	// until the container exits, or reading `stdin` fails.  Sizes received on
+	// `resize` are applied to the container's TTY.  The daemon forwards the
+	// request to the minion on `host`, and minions attach to their own
+	// containers if `host` is empty.
+	Attach(host, dockerID string, stdin io.Reader, stdout io.Writer,
+		resize <-chan api.TerminalSize) error
+
+	// MirrorEndpoint mirrors all of the traffic of whichever end of the
+	// connection from `from` to `to` runs on the same machine as the container
+	// with hostname `capture`, to the capture container, until `duration`
+	// elapses.  The daemon forwards the request to the minion on `host`, which
+	// must run the capture container, and one end of the connection.
+	MirrorEndpoint(host, from, to, capture string, duration time.Duration) error
+
+	// QueryEtcdSnapshot dumps the etcd keys through which the minions share the
+	// cluster's state.  The daemon forwards the request to the leader, and
+	// diffs the keys against its own view of the cluster.
+	QueryEtcdSnapshot() (pb.EtcdSnapshotReply, error)
+
+	// QueryConvergence computes whether the cluster implements the deployed
+	// blueprint, and if not, what's outstanding.  Only defined on the daemon.
+	QueryConvergence() (pb.ConvergenceReply, error)
+
+	// Simulate reports where the containers of `blueprint` would be placed on
+	// its machines, without deploying it.  Only defined on the daemon.
+	Simulate(blueprint string) (pb.SimulateReply, error)
+
+	// QueryCost estimates the hourly cost of the machines of `blueprint`, or of
+	// the deployed machines if `blueprint` is empty.  Only defined on the
+	// daemon.
+	QueryCost(blueprint string) (pb.CostReply, error)
+
+	// Reboot reboots the machine with `blueprintID` once its containers have
+	// been rescheduled elsewhere.  Only defined on the daemon.
+	Reboot(blueprintID string) error
+
+	// Reimage replaces the machine with `blueprintID` with a freshly booted
+	// one once its containers have been rescheduled elsewhere.  Only defined
+	// on the daemon.
+	Reimage(blueprintID string) error
+
+	// QueryResources lists the provider resources that were created for the
+	// namespace.  Only defined on the daemon.
+	QueryResources() (pb.ResourcesReply, error)
+
+	// SetCanary sets the fraction of the traffic to `loadBalancer` that is
+	// sent to its canary containers.  Only defined on the daemon.
+	SetCanary(loadBalancer string, fraction float64) error
+
+	// RestartContainers restarts the containers whose hostname matches the glob
+	// `hostname`, and whose label is `label`, and returns their hostnames.
+	// Either may be empty, but not both.  Minions ignore the selector, and
+	// instead restart their containers with `dockerIDs`.
+	RestartContainers(hostname, label string, dockerIDs []string) ([]string, error)
+
+	// StopContainers stops the containers selected in the same manner as
+	// RestartContainers, and returns their hostnames.  Only defined on the
+	// daemon.
+	StopContainers(hostname, label string) ([]string, error)
+
 	// QueryImages retrieves the image information tracked by the Quilt daemon.
 	QueryImages() ([]db.Image, error)
 
+	// QuerySettings retrieves the settings of each namespace tracked by the
+	// Quilt daemon.
+	QuerySettings() ([]db.Settings, error)
+
+	// UpdateSettings sets the given settings of `namespace`.  Settings that
+	// aren't given are left unchanged.  Only defined on the daemon.
+	UpdateSettings(namespace string, flags map[string]bool) error
+
 	// Deploy makes a request to the Quilt daemon to deploy the given deployment.
 	// Only defined on the daemon.
 	Deploy(deployment string) error
 
+	// DeployAndWait is like Deploy, except that it blocks until the deployment
+	// converges, or `timeout` elapses.  `progress` is called whenever the
+	// progress of the deployment changes.  If the deployment doesn't converge
+	// in time, the returned error is a ConvergenceError that lists what's
+	// outstanding.  Only defined on the daemon.
+	DeployAndWait(deployment string, timeout time.Duration,
+		progress func(pb.DeployReply)) error
+
 	// Version retrieves the Quilt version of the remote daemon.
 	Version() (string, error)
 }
@@ -72,6 +177,11 @@ type clientImpl struct {
 	cc       *grpc.ClientConn
 }
 
+// Proxy, if set, is the proxy through which clients connect to TCP addresses.
+// The daemon sets it so that it may reach minions that are only accessible through
+// a proxy.
+var Proxy connection.Dialer
+
 // New creates a new Quilt client connected to `lAddr`.
 func New(lAddr string, creds connection.Credentials) (Client, error) {
 	proto, addr, err := api.ParseListenAddress(lAddr)
@@ -79,7 +189,12 @@ func New(lAddr string, creds connection.Credentials) (Client, error) {
 		return nil, err
 	}
 
-	cc, err := connection.Client(proto, addr, creds.ClientOpts())
+	var proxy connection.Dialer
+	if proto == "tcp" {
+		proxy = Proxy
+	}
+
+	cc, err := connection.ProxiedClient(proxy, proto, addr, creds.ClientOpts())
 	if err != nil {
 		if err == context.DeadlineExceeded {
 			err = daemonTimeoutError{
@@ -91,17 +206,44 @@ func New(lAddr string, creds connection.Credentials) (Client, error) {
 	}
 
 	pbClient := pb.NewAPIClient(cc)
+	if err := negotiate(pbClient); err != nil {
+		cc.Close()
+		return nil, err
+	}
+
 	return clientImpl{
 		pbClient: pbClient,
 		cc:       cc,
 	}, nil
 }
 
+// negotiate exchanges API versions with the daemon, and returns an error if
+// either side can't communicate with the other.
+func negotiate(pbClient pb.APIClient) error {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := pbClient.Version(ctx, &pb.VersionRequest{
+		APIVersion:    version.APIVersion,
+		MinAPIVersion: version.MinAPIVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("negotiate API version: %s", grpc.ErrorDesc(err))
+	}
+	return version.CheckCompatible("daemon", reply.APIVersion, reply.MinAPIVersion)
+}
+
 // Writes the result into `v` a pointer to a slice of database structs.  For example
 // *[]db.Machine.
 func query(pbClient pb.APIClient, table db.TableType, v interface{}) error {
+	return queryWhere(pbClient, table, db.Query{}, v)
+}
+
+// queryWhere is like query, except only the rows matching `q` are returned.  The
+// query is evaluated by the server.
+func queryWhere(pbClient pb.APIClient, table db.TableType, q db.Query,
+	v interface{}) error {
+
 	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
-	reply, err := pbClient.Query(ctx, &pb.DBQuery{Table: string(table)})
+	reply, err := pbClient.Query(ctx, queryToPB(table, q))
 	if err != nil {
 		return err
 	}
@@ -110,11 +252,58 @@ func query(pbClient pb.APIClient, table db.TableType, v interface{}) error {
 	return json.Unmarshal(replyBytes, v)
 }
 
+func queryToPB(table db.TableType, q db.Query) *pb.DBQuery {
+	dbQuery := &pb.DBQuery{
+		Table:   string(table),
+		OrderBy: q.Order,
+		Limit:   int32(q.Max),
+	}
+	for _, f := range q.Filters {
+		dbQuery.Filters = append(dbQuery.Filters,
+			&pb.Filter{Field: f.Field, Op: f.Op, Value: f.Value})
+	}
+	return dbQuery
+}
+
 // Close the grpc connection.
 func (c clientImpl) Close() error {
 	return c.cc.Close()
 }
 
+// Query retrieves the rows of `table` that match `q` into `v`, a pointer to a
+// slice of database structs.
+func (c clientImpl) Query(table db.TableType, q db.Query, v interface{}) error {
+	return queryWhere(c.pbClient, table, q, v)
+}
+
+// Count returns the number of rows of `table` that match `q`, with each
+// combination of values of `fields`.
+func (c clientImpl) Count(table db.TableType, q db.Query, fields ...string) (
+	[]db.Count, error) {
+
+	dbQuery := queryToPB(table, q)
+	dbQuery.CountBy = fields
+
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.Query(ctx, dbQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers that predate counting ignore CountBy, and reply with the rows.
+	if reply.TableContents != "" {
+		return nil, errors.New("the server doesn't support counting rows; " +
+			"upgrade it")
+	}
+
+	var counts []db.Count
+	for _, count := range reply.Counts {
+		counts = append(counts, db.Count{
+			Values: count.Values, Count: int(count.Count)})
+	}
+	return counts, nil
+}
+
 // QueryMachines retrieves the machines tracked by the Quilt daemon.
 func (c clientImpl) QueryMachines() ([]db.Machine, error) {
 	var rows []db.Machine
@@ -158,6 +347,13 @@ func (c clientImpl) QueryImages() ([]db.Image, error) {
 	return rows, query(c.pbClient, db.ImageTable, &rows)
 }
 
+// QuerySettings retrieves the settings of each namespace tracked by the Quilt
+// daemon.
+func (c clientImpl) QuerySettings() ([]db.Settings, error) {
+	var rows []db.Settings
+	return rows, query(c.pbClient, db.SettingsTable, &rows)
+}
+
 // QueryCounters retrieves the debugging counters tracked with the Quilt daemon.
 func (c clientImpl) QueryCounters() ([]pb.Counter, error) {
 	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
@@ -181,6 +377,263 @@ func (c clientImpl) QueryMinionCounters(host string) ([]pb.Counter, error) {
 	return parseCountersReply(reply), nil
 }
 
+// QueryDecisions retrieves the reconciliation decisions recorded by the Quilt
+// daemon, or by the minion with the given host if it's non-empty.
+func (c clientImpl) QueryDecisions(host string) ([]pb.Decision, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.QueryDecisions(ctx, &pb.DecisionsRequest{Host: host})
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []pb.Decision
+	for _, d := range reply.Decisions {
+		decisions = append(decisions, *d)
+	}
+	return decisions, nil
+}
+
+// QueryJoinScores retrieves explanations of the most recent scored joins, from
+// the Quilt daemon, or from the minion with the given host if it's non-empty.
+func (c clientImpl) QueryJoinScores(host string) ([]pb.JoinScores, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.QueryJoinScores(ctx,
+		&pb.JoinScoresRequest{Host: host})
+	if err != nil {
+		return nil, err
+	}
+
+	var joins []pb.JoinScores
+	for _, j := range reply.Joins {
+		joins = append(joins, *j)
+	}
+	return joins, nil
+}
+
+// SetLogLevels sets the log levels of modules on the Quilt daemon, or on the
+// minion with the given host if it's non-empty.
+func (c clientImpl) SetLogLevels(host string, levels map[string]string) (
+	map[string]string, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.SetLogLevels(ctx,
+		&pb.LogLevelsRequest{Host: host, Levels: levels})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Levels, nil
+}
+
+// QueryEtcdSnapshot dumps the etcd keys through which the minions share the
+// cluster's state.
+func (c clientImpl) QueryEtcdSnapshot() (pb.EtcdSnapshotReply, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.QueryEtcdSnapshot(ctx, &pb.EtcdSnapshotRequest{})
+	if err != nil {
+		return pb.EtcdSnapshotReply{}, err
+	}
+	return *reply, nil
+}
+
+// QueryConvergence computes whether the cluster implements the deployed
+// blueprint.
+func (c clientImpl) QueryConvergence() (pb.ConvergenceReply, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.QueryConvergence(ctx, &pb.ConvergenceRequest{})
+	if err != nil {
+		return pb.ConvergenceReply{}, err
+	}
+	return *reply, nil
+}
+
+// Simulate reports where the containers of `blueprint` would be placed on its
+// machines, without deploying it.
+func (c clientImpl) Simulate(blueprint string) (pb.SimulateReply, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.Simulate(ctx,
+		&pb.SimulateRequest{Blueprint: blueprint})
+	if err != nil {
+		return pb.SimulateReply{}, err
+	}
+	return *reply, nil
+}
+
+// QueryCost estimates the hourly cost of the machines of `blueprint`, or of the
+// deployed machines if `blueprint` is empty.
+func (c clientImpl) QueryCost(blueprint string) (pb.CostReply, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.QueryCost(ctx,
+		&pb.CostRequest{Blueprint: blueprint})
+	if err != nil {
+		return pb.CostReply{}, err
+	}
+	return *reply, nil
+}
+
+// Reboot requests that the machine with `blueprintID` be rebooted.
+func (c clientImpl) Reboot(blueprintID string) error {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	_, err := c.pbClient.Reboot(ctx,
+		&pb.MachineActionRequest{BlueprintID: blueprintID})
+	return err
+}
+
+// Reimage requests that the machine with `blueprintID` be replaced.
+func (c clientImpl) Reimage(blueprintID string) error {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	_, err := c.pbClient.Reimage(ctx,
+		&pb.MachineActionRequest{BlueprintID: blueprintID})
+	return err
+}
+
+// QueryResources lists the provider resources of the namespace.
+func (c clientImpl) QueryResources() (pb.ResourcesReply, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.QueryResources(ctx, &pb.ResourcesRequest{})
+	if err != nil {
+		return pb.ResourcesReply{}, err
+	}
+	return *reply, nil
+}
+
+// SetCanary sets the canary fraction of `loadBalancer`.
+func (c clientImpl) SetCanary(loadBalancer string, fraction float64) error {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	_, err := c.pbClient.SetCanary(ctx, &pb.CanaryRequest{
+		LoadBalancer: loadBalancer,
+		Fraction:     fraction,
+	})
+	return err
+}
+
+// RestartContainers restarts the selected containers.
+func (c clientImpl) RestartContainers(hostname, label string, dockerIDs []string) (
+	[]string, error) {
+
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.RestartContainers(ctx, &pb.ContainersRequest{
+		Hostname:  hostname,
+		Label:     label,
+		DockerIDs: dockerIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Hostnames, nil
+}
+
+// StopContainers stops the selected containers.
+func (c clientImpl) StopContainers(hostname, label string) ([]string, error) {
+	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
+	reply, err := c.pbClient.StopContainers(ctx, &pb.ContainersRequest{
+		Hostname: hostname,
+		Label:    label,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Hostnames, nil
+}
+
+// MirrorEndpoint mirrors the traffic of an end of a connection to a capture
+// container.  It blocks until the mirror is removed.
+func (c clientImpl) MirrorEndpoint(host, from, to, capture string,
+	duration time.Duration) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		duration+requestTimeout)
+	defer cancel()
+
+	_, err := c.pbClient.MirrorEndpoint(ctx, &pb.MirrorRequest{
+		Host:            host,
+		From:            from,
+		To:              to,
+		Capture:         capture,
+		DurationSeconds: int64(duration / time.Second),
+	})
+	return err
+}
+
+// Attach attaches to the container with `dockerID`.
+func (c clientImpl) Attach(host, dockerID string, stdin io.Reader,
+	stdout io.Writer, resize <-chan api.TerminalSize) error {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.pbClient.Attach(ctx)
+	if err != nil {
+		return err
+	}
+
+	// gRPC streams don't support concurrent sends.
+	var sendLock sync.Mutex
+	send := func(req *pb.AttachRequest) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(req)
+	}
+
+	err = send(&pb.AttachRequest{Host: host, DockerID: dockerID})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case size := <-resize:
+				send(&pb.AttachRequest{Height: int32(size.Height),
+					Width: int32(size.Width)})
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// The stream is cancelled if reading stdin fails, in which case that
+	// error is returned.  If stdin simply ends, the container's output is
+	// still streamed until it exits.
+	stdinErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 && send(&pb.AttachRequest{Stdin: buf[:n]}) != nil {
+				return
+			}
+
+			if err == io.EOF {
+				sendLock.Lock()
+				stream.CloseSend()
+				sendLock.Unlock()
+				return
+			} else if err != nil {
+				stdinErr <- err
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			select {
+			case err := <-stdinErr:
+				return err
+			default:
+				return err
+			}
+		}
+
+		if _, err := stdout.Write(reply.Output); err != nil {
+			return err
+		}
+	}
+}
+
 func parseCountersReply(reply *pb.CountersReply) (counters []pb.Counter) {
 	for _, c := range reply.Counters {
 		counters = append(counters, *c)
@@ -190,19 +643,96 @@ func parseCountersReply(reply *pb.CountersReply) (counters []pb.Counter) {
 
 // Deploy makes a request to the Quilt daemon to deploy the given deployment.
 func (c clientImpl) Deploy(deployment string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	stream, err := c.pbClient.Deploy(ctx, &pb.DeployRequest{Deployment: deployment})
+	if err != nil {
+		return err
+	}
+
+	// The daemon replies once the blueprint is accepted.
+	_, err = stream.Recv()
+	return err
+}
+
+// A ConvergenceError is returned by DeployAndWait when the deployment doesn't
+// converge within its timeout.
+type ConvergenceError struct {
+	Timeout time.Duration
+
+	// The items that kept the deployment from converging.
+	Outstanding []*pb.OutstandingItem
+}
+
+func (err ConvergenceError) Error() string {
+	return fmt.Sprintf("deployment didn't converge within %s (%d item(s) "+
+		"outstanding)", err.Timeout, len(err.Outstanding))
+}
+
+// DeployAndWait deploys the given deployment, and waits for it to converge.
+func (c clientImpl) DeployAndWait(deployment string, timeout time.Duration,
+	progress func(pb.DeployReply)) error {
+
+	// The daemon enforces the timeout, so that it can report what's
+	// outstanding when it elapses.  The request's own deadline is a little
+	// later, in case the daemon doesn't respond.
+	ctx, cancel := context.WithTimeout(context.Background(),
+		timeout+requestTimeout)
+	defer cancel()
+
+	stream, err := c.pbClient.Deploy(ctx, &pb.DeployRequest{
+		Deployment:     deployment,
+		Wait:           true,
+		TimeoutSeconds: int64((timeout + time.Second - 1) / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("daemon stopped reporting progress before " +
+				"the deployment converged")
+		} else if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("deployment didn't converge within %s",
+					timeout)
+			}
+			return err
+		}
+
+		if reply.Stage == api.DeployTimedOut {
+			return ConvergenceError{timeout, reply.Outstanding}
+		}
+
+		progress(*reply)
+		if reply.Stage == api.DeployDone {
+			return nil
+		}
+	}
+}
+
+// UpdateSettings sets the given settings of `namespace`.
+func (c clientImpl) UpdateSettings(namespace string, flags map[string]bool) error {
 	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
-	_, err := c.pbClient.Deploy(ctx, &pb.DeployRequest{Deployment: deployment})
+	_, err := c.pbClient.UpdateSettings(ctx, &pb.UpdateSettingsRequest{
+		Namespace: namespace, Flags: flags})
 	return err
 }
 
 // Version retrieves the Quilt version of the remote daemon.
 func (c clientImpl) Version() (string, error) {
 	ctx, _ := context.WithTimeout(context.Background(), requestTimeout)
-	version, err := c.pbClient.Version(ctx, &pb.VersionRequest{})
+	reply, err := c.pbClient.Version(ctx, &pb.VersionRequest{
+		APIVersion:    version.APIVersion,
+		MinAPIVersion: version.MinAPIVersion,
+	})
 	if err != nil {
 		return "", err
 	}
-	return version.Version, nil
+	return reply.Version, nil
 }
 
 // daemonTimeoutError represents when we are unable to connect to the Quilt
@@ -1,32 +1,122 @@
 package client
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
+	"github.com/kelda/kelda/api"
 	"github.com/kelda/kelda/api/pb"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/version"
 )
 
 type mockAPIClient struct {
-	mockResponse string
-	mockError    error
+	mockResponse  string
+	mockCounts    []*pb.RowCount
+	mockError     error
+	deployReplies []pb.DeployReply
+	attachStream  *mockAttachClient
+	mirrorRequest *pb.MirrorRequest
+	versionReply  pb.VersionReply
+	etcdReply     pb.EtcdSnapshotReply
+
+	containersRequest *pb.ContainersRequest
 }
 
 func (c mockAPIClient) Query(ctx context.Context, in *pb.DBQuery,
 	opts ...grpc.CallOption) (*pb.QueryReply, error) {
 
-	return &pb.QueryReply{TableContents: c.mockResponse}, c.mockError
+	return &pb.QueryReply{TableContents: c.mockResponse, Counts: c.mockCounts},
+		c.mockError
 }
 
 func (c mockAPIClient) Deploy(ctx context.Context, in *pb.DeployRequest,
-	opts ...grpc.CallOption) (*pb.DeployReply, error) {
+	opts ...grpc.CallOption) (pb.API_DeployClient, error) {
+
+	return &mockDeployClient{replies: c.deployReplies}, c.mockError
+}
+
+type mockDeployClient struct {
+	replies []pb.DeployReply
+	grpc.ClientStream
+}
+
+func (s *mockDeployClient) Recv() (*pb.DeployReply, error) {
+	if len(s.replies) == 0 {
+		return nil, io.EOF
+	}
+
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return &reply, nil
+}
+
+func (c mockAPIClient) Attach(ctx context.Context, opts ...grpc.CallOption) (
+	pb.API_AttachClient, error) {
+
+	c.attachStream.ctx = ctx
+	return c.attachStream, c.mockError
+}
+
+// mockAttachClient records the requests sent on it, and echoes their stdin as
+// output.
+type mockAttachClient struct {
+	ctx      context.Context
+	requests []pb.AttachRequest
+	replies  chan *pb.AttachReply
+	grpc.ClientStream
+}
+
+func newMockAttachClient() *mockAttachClient {
+	return &mockAttachClient{replies: make(chan *pb.AttachReply, 16)}
+}
 
-	return &pb.DeployReply{}, nil
+func (s *mockAttachClient) Send(req *pb.AttachRequest) error {
+	s.requests = append(s.requests, *req)
+	if len(req.Stdin) > 0 {
+		output := append([]byte(nil), req.Stdin...)
+		s.replies <- &pb.AttachReply{Output: output}
+	}
+	return nil
+}
+
+func (s *mockAttachClient) CloseSend() error {
+	close(s.replies)
+	return nil
+}
+
+func (s *mockAttachClient) Recv() (*pb.AttachReply, error) {
+	select {
+	case reply, ok := <-s.replies:
+		if !ok {
+			return nil, io.EOF
+		}
+		return reply, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (c mockAPIClient) MirrorEndpoint(ctx context.Context, in *pb.MirrorRequest,
+	opts ...grpc.CallOption) (*pb.MirrorReply, error) {
+
+	*c.mirrorRequest = *in
+	return &pb.MirrorReply{}, c.mockError
+}
+
+func (c mockAPIClient) QueryEtcdSnapshot(ctx context.Context,
+	in *pb.EtcdSnapshotRequest, opts ...grpc.CallOption) (
+	*pb.EtcdSnapshotReply, error) {
+
+	return &c.etcdReply, c.mockError
 }
 
 func (c mockAPIClient) QueryCounters(ctx context.Context, in *pb.CountersRequest,
@@ -41,10 +131,97 @@ func (c mockAPIClient) QueryMinionCounters(ctx context.Context, in *pb.
 	return &pb.CountersReply{}, nil
 }
 
+func (c mockAPIClient) QueryDecisions(ctx context.Context, in *pb.DecisionsRequest,
+	opts ...grpc.CallOption) (*pb.DecisionsReply, error) {
+
+	return &pb.DecisionsReply{}, nil
+}
+
+func (c mockAPIClient) QueryJoinScores(ctx context.Context,
+	in *pb.JoinScoresRequest, opts ...grpc.CallOption) (
+	*pb.JoinScoresReply, error) {
+
+	return &pb.JoinScoresReply{}, nil
+}
+
+func (c mockAPIClient) SetLogLevels(ctx context.Context,
+	in *pb.LogLevelsRequest, opts ...grpc.CallOption) (
+	*pb.LogLevelsReply, error) {
+
+	return &pb.LogLevelsReply{}, nil
+}
+
+func (c mockAPIClient) QueryConvergence(ctx context.Context,
+	in *pb.ConvergenceRequest, opts ...grpc.CallOption) (
+	*pb.ConvergenceReply, error) {
+
+	return &pb.ConvergenceReply{}, nil
+}
+
+func (c mockAPIClient) Simulate(ctx context.Context, in *pb.SimulateRequest,
+	opts ...grpc.CallOption) (*pb.SimulateReply, error) {
+
+	return &pb.SimulateReply{}, nil
+}
+
+func (c mockAPIClient) QueryCost(ctx context.Context, in *pb.CostRequest,
+	opts ...grpc.CallOption) (*pb.CostReply, error) {
+
+	return &pb.CostReply{}, nil
+}
+
+func (c mockAPIClient) Reboot(ctx context.Context, in *pb.MachineActionRequest,
+	opts ...grpc.CallOption) (*pb.MachineActionReply, error) {
+
+	return &pb.MachineActionReply{}, nil
+}
+
+func (c mockAPIClient) Reimage(ctx context.Context, in *pb.MachineActionRequest,
+	opts ...grpc.CallOption) (*pb.MachineActionReply, error) {
+
+	return &pb.MachineActionReply{}, nil
+}
+
+func (c mockAPIClient) QueryResources(ctx context.Context,
+	in *pb.ResourcesRequest, opts ...grpc.CallOption) (*pb.ResourcesReply,
+	error) {
+
+	return &pb.ResourcesReply{}, nil
+}
+
+func (c mockAPIClient) SetCanary(ctx context.Context, in *pb.CanaryRequest,
+	opts ...grpc.CallOption) (*pb.CanaryReply, error) {
+
+	return &pb.CanaryReply{}, c.mockError
+}
+
+func (c mockAPIClient) RestartContainers(ctx context.Context,
+	in *pb.ContainersRequest, opts ...grpc.CallOption) (*pb.ContainersReply,
+	error) {
+
+	*c.containersRequest = *in
+	return &pb.ContainersReply{Hostnames: []string{"web"}}, c.mockError
+}
+
+func (c mockAPIClient) StopContainers(ctx context.Context,
+	in *pb.ContainersRequest, opts ...grpc.CallOption) (*pb.ContainersReply,
+	error) {
+
+	*c.containersRequest = *in
+	return &pb.ContainersReply{Hostnames: []string{"web"}}, c.mockError
+}
+
+func (c mockAPIClient) UpdateSettings(ctx context.Context,
+	in *pb.UpdateSettingsRequest, opts ...grpc.CallOption) (
+	*pb.UpdateSettingsReply, error) {
+
+	return &pb.UpdateSettingsReply{}, nil
+}
+
 func (c mockAPIClient) Version(ctx context.Context, in *pb.VersionRequest,
 	opts ...grpc.CallOption) (*pb.VersionReply, error) {
 
-	return &pb.VersionReply{}, nil
+	return &c.versionReply, c.mockError
 }
 
 func TestUnmarshalMachine(t *testing.T) {
@@ -109,6 +286,25 @@ func TestUnmarshalImage(t *testing.T) {
 	}, res)
 }
 
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	c := clientImpl{pbClient: mockAPIClient{mockCounts: []*pb.RowCount{
+		{Values: []string{"us-west-1", db.Connected}, Count: 3},
+	}}}
+	counts, err := c.Count(db.MachineTable, db.Query{}, "Region", "Status")
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Count{
+		{Values: []string{"us-west-1", db.Connected}, Count: 3},
+	}, counts)
+
+	// Servers that don't support counting reply with the rows instead.
+	c = clientImpl{pbClient: mockAPIClient{mockResponse: "[]"}}
+	_, err = c.Count(db.MachineTable, db.Query{}, "Status")
+	assert.EqualError(t, err, "the server doesn't support counting rows; "+
+		"upgrade it")
+}
+
 func TestUnmarshalError(t *testing.T) {
 	t.Parallel()
 
@@ -133,3 +329,160 @@ func TestGrpcError(t *testing.T) {
 	_, err := c.QueryMachines()
 	assert.EqualError(t, err, "timeout")
 }
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, negotiate(mockAPIClient{versionReply: pb.VersionReply{
+		APIVersion: version.APIVersion, MinAPIVersion: version.MinAPIVersion}}))
+
+	// Daemons that predate signed minion configs are refused.
+	err := negotiate(mockAPIClient{})
+	assert.EqualError(t, err, version.CheckCompatible("daemon", 0, 0).Error())
+
+	newer := version.APIVersion + 1
+	err = negotiate(mockAPIClient{versionReply: pb.VersionReply{
+		APIVersion: newer, MinAPIVersion: newer}})
+	assert.EqualError(t, err,
+		version.CheckCompatible("daemon", newer, newer).Error())
+
+	err = negotiate(mockAPIClient{mockError: errors.New("unknown method")})
+	assert.EqualError(t, err, "negotiate API version: unknown method")
+}
+
+func TestDeploy(t *testing.T) {
+	t.Parallel()
+
+	c := clientImpl{pbClient: mockAPIClient{
+		deployReplies: []pb.DeployReply{{Stage: api.DeployAccepted}},
+	}}
+	assert.NoError(t, c.Deploy("{}"))
+
+	c = clientImpl{pbClient: mockAPIClient{mockError: errors.New("invalid")}}
+	assert.EqualError(t, c.Deploy("{}"), "invalid")
+}
+
+func TestDeployAndWait(t *testing.T) {
+	t.Parallel()
+
+	replies := []pb.DeployReply{
+		{Stage: api.DeployAccepted},
+		{Stage: api.DeployBooting, MachinesTotal: 1},
+		{Stage: api.DeployDone, MachinesTotal: 1, MachinesBooted: 1,
+			MachinesConnected: 1},
+	}
+	c := clientImpl{pbClient: mockAPIClient{deployReplies: replies}}
+
+	var progress []pb.DeployReply
+	err := c.DeployAndWait("{}", time.Minute, func(reply pb.DeployReply) {
+		progress = append(progress, reply)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, replies, progress)
+
+	// The stream ends before the deployment converges.
+	c = clientImpl{pbClient: mockAPIClient{deployReplies: replies[:2]}}
+	err = c.DeployAndWait("{}", time.Minute, func(pb.DeployReply) {})
+	assert.EqualError(t, err, "daemon stopped reporting progress before "+
+		"the deployment converged")
+
+	// The daemon gives up, and reports what's outstanding.
+	outstanding := []*pb.OutstandingItem{
+		{Kind: "machine", ID: "1", Reason: "not connected"}}
+	c = clientImpl{pbClient: mockAPIClient{deployReplies: []pb.DeployReply{
+		replies[0], {Stage: api.DeployTimedOut, Outstanding: outstanding},
+	}}}
+	err = c.DeployAndWait("{}", time.Minute, func(pb.DeployReply) {})
+	assert.Equal(t, ConvergenceError{time.Minute, outstanding}, err)
+	assert.EqualError(t, err, "deployment didn't converge within 1m0s "+
+		"(1 item(s) outstanding)")
+}
+
+func TestAttach(t *testing.T) {
+	t.Parallel()
+
+	stream := newMockAttachClient()
+	c := clientImpl{pbClient: mockAPIClient{attachStream: stream}}
+
+	var stdout bytes.Buffer
+	err := c.Attach("host", "dockerID", strings.NewReader("input"), &stdout, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "input", stdout.String())
+	assert.Equal(t, "host", stream.requests[0].Host)
+	assert.Equal(t, "dockerID", stream.requests[0].DockerID)
+
+	// Failing to read stdin aborts the stream.
+	stream = newMockAttachClient()
+	c = clientImpl{pbClient: mockAPIClient{attachStream: stream}}
+	err = c.Attach("host", "dockerID", errReader{}, &stdout, nil)
+	assert.EqualError(t, err, "read error")
+
+	c = clientImpl{pbClient: mockAPIClient{attachStream: newMockAttachClient(),
+		mockError: errors.New("unavailable")}}
+	err = c.Attach("host", "dockerID", errReader{}, &stdout, nil)
+	assert.EqualError(t, err, "unavailable")
+}
+
+func TestMirrorEndpoint(t *testing.T) {
+	t.Parallel()
+
+	var req pb.MirrorRequest
+	c := clientImpl{pbClient: mockAPIClient{mirrorRequest: &req}}
+	err := c.MirrorEndpoint("host", "web", "db", "tcpdump", 90*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, pb.MirrorRequest{Host: "host", From: "web", To: "db",
+		Capture: "tcpdump", DurationSeconds: 90}, req)
+
+	c = clientImpl{pbClient: mockAPIClient{mirrorRequest: &req,
+		mockError: errors.New("unavailable")}}
+	err = c.MirrorEndpoint("host", "web", "db", "tcpdump", time.Minute)
+	assert.EqualError(t, err, "unavailable")
+}
+
+func TestQueryEtcdSnapshot(t *testing.T) {
+	t.Parallel()
+
+	reply := pb.EtcdSnapshotReply{
+		Keys: map[string]string{"/containers": "[]"},
+		Differences: []*pb.EtcdDifference{
+			{Kind: "minion", ID: "10.0.0.1", Reason: "reason"}},
+	}
+	c := clientImpl{pbClient: mockAPIClient{etcdReply: reply}}
+	res, err := c.QueryEtcdSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, reply, res)
+
+	c = clientImpl{pbClient: mockAPIClient{mockError: errors.New("err")}}
+	_, err = c.QueryEtcdSnapshot()
+	assert.EqualError(t, err, "err")
+}
+
+func TestContainersActions(t *testing.T) {
+	t.Parallel()
+
+	var req pb.ContainersRequest
+	c := clientImpl{pbClient: mockAPIClient{containersRequest: &req}}
+	hostnames, err := c.RestartContainers("web*", "", []string{"id"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web"}, hostnames)
+	assert.Equal(t, pb.ContainersRequest{Hostname: "web*",
+		DockerIDs: []string{"id"}}, req)
+
+	hostnames, err = c.StopContainers("", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web"}, hostnames)
+	assert.Equal(t, pb.ContainersRequest{Label: "web"}, req)
+
+	c = clientImpl{pbClient: mockAPIClient{containersRequest: &req,
+		mockError: errors.New("unavailable")}}
+	_, err = c.RestartContainers("web*", "", nil)
+	assert.EqualError(t, err, "unavailable")
+	_, err = c.StopContainers("web*", "")
+	assert.EqualError(t, err, "unavailable")
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
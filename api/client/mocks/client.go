@@ -2,9 +2,12 @@
 
 package mocks
 
+import api "github.com/kelda/kelda/api"
 import db "github.com/kelda/kelda/db"
+import io "io"
 import mock "github.com/stretchr/testify/mock"
 import pb "github.com/kelda/kelda/api/pb"
+import time "time"
 
 // Client is an autogenerated mock type for the Client type
 type Client struct {
@@ -39,6 +42,129 @@ func (_m *Client) Deploy(deployment string) error {
 	return r0
 }
 
+// DeployAndWait provides a mock function with given fields: deployment, timeout, progress
+func (_m *Client) DeployAndWait(deployment string, timeout time.Duration, progress func(pb.DeployReply)) error {
+	ret := _m.Called(deployment, timeout, progress)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, time.Duration, func(pb.DeployReply)) error); ok {
+		r0 = rf(deployment, timeout, progress)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// QuerySettings provides a mock function with given fields:
+func (_m *Client) QuerySettings() ([]db.Settings, error) {
+	ret := _m.Called()
+
+	var r0 []db.Settings
+	if rf, ok := ret.Get(0).(func() []db.Settings); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.Settings)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateSettings provides a mock function with given fields: namespace, flags
+func (_m *Client) UpdateSettings(namespace string, flags map[string]bool) error {
+	ret := _m.Called(namespace, flags)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, map[string]bool) error); ok {
+		r0 = rf(namespace, flags)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Reboot provides a mock function with given fields: blueprintID
+func (_m *Client) Reboot(blueprintID string) error {
+	ret := _m.Called(blueprintID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(blueprintID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Reimage provides a mock function with given fields: blueprintID
+func (_m *Client) Reimage(blueprintID string) error {
+	ret := _m.Called(blueprintID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(blueprintID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Query provides a mock function with given fields: table, q, v
+func (_m *Client) Query(table db.TableType, q db.Query, v interface{}) error {
+	ret := _m.Called(table, q, v)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(db.TableType, db.Query, interface{}) error); ok {
+		r0 = rf(table, q, v)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Count provides a mock function with given fields: table, q, fields
+func (_m *Client) Count(table db.TableType, q db.Query, fields ...string) ([]db.Count, error) {
+	_va := make([]interface{}, len(fields))
+	for _i := range fields {
+		_va[_i] = fields[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, table, q)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 []db.Count
+	if rf, ok := ret.Get(0).(func(db.TableType, db.Query, ...string) []db.Count); ok {
+		r0 = rf(table, q, fields...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.Count)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(db.TableType, db.Query, ...string) error); ok {
+		r1 = rf(table, q, fields...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // QueryBlueprints provides a mock function with given fields:
 func (_m *Client) QueryBlueprints() ([]db.Blueprint, error) {
 	ret := _m.Called()
@@ -223,6 +349,268 @@ func (_m *Client) QueryMachines() ([]db.Machine, error) {
 	return r0, r1
 }
 
+// QueryDecisions provides a mock function with given fields: _a0
+func (_m *Client) QueryDecisions(_a0 string) ([]pb.Decision, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []pb.Decision
+	if rf, ok := ret.Get(0).(func(string) []pb.Decision); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]pb.Decision)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryJoinScores provides a mock function with given fields: _a0
+func (_m *Client) QueryJoinScores(_a0 string) ([]pb.JoinScores, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []pb.JoinScores
+	if rf, ok := ret.Get(0).(func(string) []pb.JoinScores); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]pb.JoinScores)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetLogLevels provides a mock function with given fields: host, levels
+func (_m *Client) SetLogLevels(host string, levels map[string]string) (map[string]string, error) {
+	ret := _m.Called(host, levels)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func(string, map[string]string) map[string]string); ok {
+		r0 = rf(host, levels)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, map[string]string) error); ok {
+		r1 = rf(host, levels)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryConvergence provides a mock function with given fields:
+func (_m *Client) QueryConvergence() (pb.ConvergenceReply, error) {
+	ret := _m.Called()
+
+	var r0 pb.ConvergenceReply
+	if rf, ok := ret.Get(0).(func() pb.ConvergenceReply); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(pb.ConvergenceReply)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryEtcdSnapshot provides a mock function with given fields:
+func (_m *Client) QueryEtcdSnapshot() (pb.EtcdSnapshotReply, error) {
+	ret := _m.Called()
+
+	var r0 pb.EtcdSnapshotReply
+	if rf, ok := ret.Get(0).(func() pb.EtcdSnapshotReply); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(pb.EtcdSnapshotReply)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryResources provides a mock function with given fields:
+func (_m *Client) QueryResources() (pb.ResourcesReply, error) {
+	ret := _m.Called()
+
+	var r0 pb.ResourcesReply
+	if rf, ok := ret.Get(0).(func() pb.ResourcesReply); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(pb.ResourcesReply)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetCanary provides a mock function with given fields: loadBalancer, fraction
+func (_m *Client) SetCanary(loadBalancer string, fraction float64) error {
+	ret := _m.Called(loadBalancer, fraction)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, float64) error); ok {
+		r0 = rf(loadBalancer, fraction)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestartContainers provides a mock function with given fields: hostname, label, dockerIDs
+func (_m *Client) RestartContainers(hostname string, label string, dockerIDs []string) ([]string, error) {
+	ret := _m.Called(hostname, label, dockerIDs)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string, string, []string) []string); ok {
+		r0 = rf(hostname, label, dockerIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, []string) error); ok {
+		r1 = rf(hostname, label, dockerIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StopContainers provides a mock function with given fields: hostname, label
+func (_m *Client) StopContainers(hostname string, label string) ([]string, error) {
+	ret := _m.Called(hostname, label)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string, string) []string); ok {
+		r0 = rf(hostname, label)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(hostname, label)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Attach provides a mock function with given fields: host, dockerID, stdin, stdout, resize
+func (_m *Client) Attach(host string, dockerID string, stdin io.Reader, stdout io.Writer, resize <-chan api.TerminalSize) error {
+	ret := _m.Called(host, dockerID, stdin, stdout, resize)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, io.Reader, io.Writer, <-chan api.TerminalSize) error); ok {
+		r0 = rf(host, dockerID, stdin, stdout, resize)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MirrorEndpoint provides a mock function with given fields: host, from, to, capture, duration
+func (_m *Client) MirrorEndpoint(host string, from string, to string, capture string, duration time.Duration) error {
+	ret := _m.Called(host, from, to, capture, duration)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string, time.Duration) error); ok {
+		r0 = rf(host, from, to, capture, duration)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Simulate provides a mock function with given fields: blueprint
+func (_m *Client) Simulate(blueprint string) (pb.SimulateReply, error) {
+	ret := _m.Called(blueprint)
+
+	var r0 pb.SimulateReply
+	if rf, ok := ret.Get(0).(func(string) pb.SimulateReply); ok {
+		r0 = rf(blueprint)
+	} else {
+		r0 = ret.Get(0).(pb.SimulateReply)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(blueprint)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryCost provides a mock function with given fields: blueprint
+func (_m *Client) QueryCost(blueprint string) (pb.CostReply, error) {
+	ret := _m.Called(blueprint)
+
+	var r0 pb.CostReply
+	if rf, ok := ret.Get(0).(func(string) pb.CostReply); ok {
+		r0 = rf(blueprint)
+	} else {
+		r0 = ret.Get(0).(pb.CostReply)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(blueprint)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // QueryMinionCounters provides a mock function with given fields: _a0
 func (_m *Client) QueryMinionCounters(_a0 string) ([]pb.Counter, error) {
 	ret := _m.Called(_a0)
@@ -13,6 +13,39 @@ var DefaultSocket = "unix:///tmp/quilt.sock"
 // DefaultRemotePort is the port remote Quilt daemons (the minion) listen on by default.
 const DefaultRemotePort = 9000
 
+// DefaultSelfHostedPort is the port that daemons running inside the cluster, in
+// self-hosted namespaces, listen on.
+const DefaultSelfHostedPort = 9001
+
+// The stages of a deployment reported by the Deploy RPC, in order.
+const (
+	// DeployAccepted means the blueprint was accepted by the daemon.
+	DeployAccepted = "accepted"
+
+	// DeployBooting means some machines haven't booted yet.
+	DeployBooting = "booting"
+
+	// DeployConnecting means the machines are booted, but the daemon hasn't
+	// connected to all of them yet.
+	DeployConnecting = "connecting"
+
+	// DeployScheduling means the daemon is connected to every machine, but some
+	// containers haven't been scheduled yet.
+	DeployScheduling = "scheduling"
+
+	// DeployDone means the deployment has converged.
+	DeployDone = "done"
+
+	// DeployTimedOut means the deployment didn't converge before the timeout.
+	// The reply lists the outstanding items.
+	DeployTimedOut = "timed out"
+)
+
+// A TerminalSize is the size of a terminal, in characters.
+type TerminalSize struct {
+	Height, Width int
+}
+
 // ParseListenAddress validates and parses a socket address into the
 // protocol and address.
 func ParseListenAddress(lAddr string) (string, string, error) {
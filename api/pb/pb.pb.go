@@ -9,14 +9,51 @@ It is generated from these files:
 
 It has these top-level messages:
 	DBQuery
+	Filter
 	QueryReply
+	RowCount
 	DeployRequest
 	DeployReply
+	UpdateSettingsRequest
+	UpdateSettingsReply
 	VersionRequest
 	VersionReply
 	CountersRequest
 	MinionCountersRequest
 	CountersReply
+	DecisionsRequest
+	DecisionsReply
+	Decision
+	JoinScoresRequest
+	JoinScoresReply
+	JoinScores
+	LogLevelsRequest
+	LogLevelsReply
+	AttachRequest
+	AttachReply
+	MirrorRequest
+	MirrorReply
+	EtcdSnapshotRequest
+	EtcdSnapshotReply
+	EtcdDifference
+	ConvergenceRequest
+	ConvergenceReply
+	OutstandingItem
+	SimulateRequest
+	SimulateReply
+	SimulatedMachine
+	CostRequest
+	CostReply
+	MachineCost
+	MachineActionRequest
+	MachineActionReply
+	ResourcesRequest
+	ResourcesReply
+	Resource
+	CanaryRequest
+	CanaryReply
+	ContainersRequest
+	ContainersReply
 	Counter
 */
 package pb
@@ -42,7 +79,13 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type DBQuery struct {
-	Table string `protobuf:"bytes,1,opt,name=Table" json:"Table,omitempty"`
+	Table   string    `protobuf:"bytes,1,opt,name=Table" json:"Table,omitempty"`
+	Filters []*Filter `protobuf:"bytes,2,rep,name=Filters" json:"Filters,omitempty"`
+	OrderBy string    `protobuf:"bytes,3,opt,name=OrderBy" json:"OrderBy,omitempty"`
+	Limit   int32     `protobuf:"varint,4,opt,name=Limit" json:"Limit,omitempty"`
+	// If set, the reply counts the matching rows with each combination of
+	// values of these fields, rather than returning the rows.
+	CountBy []string `protobuf:"bytes,5,rep,name=CountBy" json:"CountBy,omitempty"`
 }
 
 func (m *DBQuery) Reset()                    { *m = DBQuery{} }
@@ -57,14 +100,75 @@ func (m *DBQuery) GetTable() string {
 	return ""
 }
 
+func (m *DBQuery) GetFilters() []*Filter {
+	if m != nil {
+		return m.Filters
+	}
+	return nil
+}
+
+func (m *DBQuery) GetOrderBy() string {
+	if m != nil {
+		return m.OrderBy
+	}
+	return ""
+}
+
+func (m *DBQuery) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *DBQuery) GetCountBy() []string {
+	if m != nil {
+		return m.CountBy
+	}
+	return nil
+}
+
+type Filter struct {
+	Field string `protobuf:"bytes,1,opt,name=Field" json:"Field,omitempty"`
+	Op    string `protobuf:"bytes,2,opt,name=Op" json:"Op,omitempty"`
+	Value string `protobuf:"bytes,3,opt,name=Value" json:"Value,omitempty"`
+}
+
+func (m *Filter) Reset()                    { *m = Filter{} }
+func (m *Filter) String() string            { return proto.CompactTextString(m) }
+func (*Filter) ProtoMessage()               {}
+func (*Filter) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Filter) GetField() string {
+	if m != nil {
+		return m.Field
+	}
+	return ""
+}
+
+func (m *Filter) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *Filter) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
 type QueryReply struct {
-	TableContents string `protobuf:"bytes,1,opt,name=TableContents" json:"TableContents,omitempty"`
+	TableContents string      `protobuf:"bytes,1,opt,name=TableContents" json:"TableContents,omitempty"`
+	Counts        []*RowCount `protobuf:"bytes,2,rep,name=Counts" json:"Counts,omitempty"`
 }
 
 func (m *QueryReply) Reset()                    { *m = QueryReply{} }
 func (m *QueryReply) String() string            { return proto.CompactTextString(m) }
 func (*QueryReply) ProtoMessage()               {}
-func (*QueryReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*QueryReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *QueryReply) GetTableContents() string {
 	if m != nil {
@@ -73,14 +177,50 @@ func (m *QueryReply) GetTableContents() string {
 	return ""
 }
 
+func (m *QueryReply) GetCounts() []*RowCount {
+	if m != nil {
+		return m.Counts
+	}
+	return nil
+}
+
+type RowCount struct {
+	Values []string `protobuf:"bytes,1,rep,name=Values" json:"Values,omitempty"`
+	Count  int32    `protobuf:"varint,2,opt,name=Count" json:"Count,omitempty"`
+}
+
+func (m *RowCount) Reset()                    { *m = RowCount{} }
+func (m *RowCount) String() string            { return proto.CompactTextString(m) }
+func (*RowCount) ProtoMessage()               {}
+func (*RowCount) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *RowCount) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+func (m *RowCount) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
 type DeployRequest struct {
 	Deployment string `protobuf:"bytes,1,opt,name=Deployment" json:"Deployment,omitempty"`
+	Wait       bool   `protobuf:"varint,2,opt,name=Wait" json:"Wait,omitempty"`
+	// How long the daemon waits for the deployment to converge before giving
+	// up and reporting what's outstanding.  Zero waits until the request is
+	// cancelled.
+	TimeoutSeconds int64 `protobuf:"varint,3,opt,name=TimeoutSeconds" json:"TimeoutSeconds,omitempty"`
 }
 
 func (m *DeployRequest) Reset()                    { *m = DeployRequest{} }
 func (m *DeployRequest) String() string            { return proto.CompactTextString(m) }
 func (*DeployRequest) ProtoMessage()               {}
-func (*DeployRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*DeployRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 func (m *DeployRequest) GetDeployment() string {
 	if m != nil {
@@ -89,30 +229,154 @@ func (m *DeployRequest) GetDeployment() string {
 	return ""
 }
 
+func (m *DeployRequest) GetWait() bool {
+	if m != nil {
+		return m.Wait
+	}
+	return false
+}
+
+func (m *DeployRequest) GetTimeoutSeconds() int64 {
+	if m != nil {
+		return m.TimeoutSeconds
+	}
+	return 0
+}
+
 type DeployReply struct {
+	Stage               string `protobuf:"bytes,1,opt,name=Stage" json:"Stage,omitempty"`
+	MachinesTotal       int32  `protobuf:"varint,2,opt,name=MachinesTotal" json:"MachinesTotal,omitempty"`
+	MachinesBooted      int32  `protobuf:"varint,3,opt,name=MachinesBooted" json:"MachinesBooted,omitempty"`
+	MachinesConnected   int32  `protobuf:"varint,4,opt,name=MachinesConnected" json:"MachinesConnected,omitempty"`
+	ContainersTotal     int32  `protobuf:"varint,5,opt,name=ContainersTotal" json:"ContainersTotal,omitempty"`
+	ContainersScheduled int32  `protobuf:"varint,6,opt,name=ContainersScheduled" json:"ContainersScheduled,omitempty"`
+	// The items that kept the deployment from converging.  Only set in the
+	// final reply of a deployment that timed out.
+	Outstanding []*OutstandingItem `protobuf:"bytes,7,rep,name=Outstanding" json:"Outstanding,omitempty"`
 }
 
 func (m *DeployReply) Reset()                    { *m = DeployReply{} }
 func (m *DeployReply) String() string            { return proto.CompactTextString(m) }
 func (*DeployReply) ProtoMessage()               {}
-func (*DeployReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*DeployReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *DeployReply) GetStage() string {
+	if m != nil {
+		return m.Stage
+	}
+	return ""
+}
+
+func (m *DeployReply) GetMachinesTotal() int32 {
+	if m != nil {
+		return m.MachinesTotal
+	}
+	return 0
+}
+
+func (m *DeployReply) GetMachinesBooted() int32 {
+	if m != nil {
+		return m.MachinesBooted
+	}
+	return 0
+}
+
+func (m *DeployReply) GetMachinesConnected() int32 {
+	if m != nil {
+		return m.MachinesConnected
+	}
+	return 0
+}
+
+func (m *DeployReply) GetContainersTotal() int32 {
+	if m != nil {
+		return m.ContainersTotal
+	}
+	return 0
+}
+
+func (m *DeployReply) GetContainersScheduled() int32 {
+	if m != nil {
+		return m.ContainersScheduled
+	}
+	return 0
+}
 
+func (m *DeployReply) GetOutstanding() []*OutstandingItem {
+	if m != nil {
+		return m.Outstanding
+	}
+	return nil
+}
+
+type UpdateSettingsRequest struct {
+	Namespace string          `protobuf:"bytes,1,opt,name=Namespace" json:"Namespace,omitempty"`
+	Flags     map[string]bool `protobuf:"bytes,2,rep,name=Flags" json:"Flags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (m *UpdateSettingsRequest) Reset()                    { *m = UpdateSettingsRequest{} }
+func (m *UpdateSettingsRequest) String() string            { return proto.CompactTextString(m) }
+func (*UpdateSettingsRequest) ProtoMessage()               {}
+func (*UpdateSettingsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *UpdateSettingsRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *UpdateSettingsRequest) GetFlags() map[string]bool {
+	if m != nil {
+		return m.Flags
+	}
+	return nil
+}
+
+type UpdateSettingsReply struct {
+}
+
+func (m *UpdateSettingsReply) Reset()                    { *m = UpdateSettingsReply{} }
+func (m *UpdateSettingsReply) String() string            { return proto.CompactTextString(m) }
+func (*UpdateSettingsReply) ProtoMessage()               {}
+func (*UpdateSettingsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+// The API versions that the sender speaks and can communicate with, so that
+// mismatched clients and daemons are refused with a clear error.
 type VersionRequest struct {
+	APIVersion    int32 `protobuf:"varint,1,opt,name=APIVersion" json:"APIVersion,omitempty"`
+	MinAPIVersion int32 `protobuf:"varint,2,opt,name=MinAPIVersion" json:"MinAPIVersion,omitempty"`
 }
 
 func (m *VersionRequest) Reset()                    { *m = VersionRequest{} }
 func (m *VersionRequest) String() string            { return proto.CompactTextString(m) }
 func (*VersionRequest) ProtoMessage()               {}
-func (*VersionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*VersionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *VersionRequest) GetAPIVersion() int32 {
+	if m != nil {
+		return m.APIVersion
+	}
+	return 0
+}
+
+func (m *VersionRequest) GetMinAPIVersion() int32 {
+	if m != nil {
+		return m.MinAPIVersion
+	}
+	return 0
+}
 
 type VersionReply struct {
-	Version string `protobuf:"bytes,1,opt,name=Version" json:"Version,omitempty"`
+	Version       string `protobuf:"bytes,1,opt,name=Version" json:"Version,omitempty"`
+	APIVersion    int32  `protobuf:"varint,2,opt,name=APIVersion" json:"APIVersion,omitempty"`
+	MinAPIVersion int32  `protobuf:"varint,3,opt,name=MinAPIVersion" json:"MinAPIVersion,omitempty"`
 }
 
 func (m *VersionReply) Reset()                    { *m = VersionReply{} }
 func (m *VersionReply) String() string            { return proto.CompactTextString(m) }
 func (*VersionReply) ProtoMessage()               {}
-func (*VersionReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*VersionReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *VersionReply) GetVersion() string {
 	if m != nil {
@@ -121,13 +385,27 @@ func (m *VersionReply) GetVersion() string {
 	return ""
 }
 
+func (m *VersionReply) GetAPIVersion() int32 {
+	if m != nil {
+		return m.APIVersion
+	}
+	return 0
+}
+
+func (m *VersionReply) GetMinAPIVersion() int32 {
+	if m != nil {
+		return m.MinAPIVersion
+	}
+	return 0
+}
+
 type CountersRequest struct {
 }
 
 func (m *CountersRequest) Reset()                    { *m = CountersRequest{} }
 func (m *CountersRequest) String() string            { return proto.CompactTextString(m) }
 func (*CountersRequest) ProtoMessage()               {}
-func (*CountersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*CountersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 type MinionCountersRequest struct {
 	Host string `protobuf:"bytes,1,opt,name=Host" json:"Host,omitempty"`
@@ -136,7 +414,7 @@ type MinionCountersRequest struct {
 func (m *MinionCountersRequest) Reset()                    { *m = MinionCountersRequest{} }
 func (m *MinionCountersRequest) String() string            { return proto.CompactTextString(m) }
 func (*MinionCountersRequest) ProtoMessage()               {}
-func (*MinionCountersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*MinionCountersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *MinionCountersRequest) GetHost() string {
 	if m != nil {
@@ -152,7 +430,7 @@ type CountersReply struct {
 func (m *CountersReply) Reset()                    { *m = CountersReply{} }
 func (m *CountersReply) String() string            { return proto.CompactTextString(m) }
 func (*CountersReply) ProtoMessage()               {}
-func (*CountersReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*CountersReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *CountersReply) GetCounters() []*Counter {
 	if m != nil {
@@ -161,126 +439,1269 @@ func (m *CountersReply) GetCounters() []*Counter {
 	return nil
 }
 
-type Counter struct {
-	Pkg       string `protobuf:"bytes,1,opt,name=Pkg" json:"Pkg,omitempty"`
-	Name      string `protobuf:"bytes,2,opt,name=Name" json:"Name,omitempty"`
-	Value     uint64 `protobuf:"varint,3,opt,name=Value" json:"Value,omitempty"`
-	PrevValue uint64 `protobuf:"varint,4,opt,name=PrevValue" json:"PrevValue,omitempty"`
+type DecisionsRequest struct {
+	Host string `protobuf:"bytes,1,opt,name=Host" json:"Host,omitempty"`
 }
 
-func (m *Counter) Reset()                    { *m = Counter{} }
-func (m *Counter) String() string            { return proto.CompactTextString(m) }
-func (*Counter) ProtoMessage()               {}
-func (*Counter) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (m *DecisionsRequest) Reset()                    { *m = DecisionsRequest{} }
+func (m *DecisionsRequest) String() string            { return proto.CompactTextString(m) }
+func (*DecisionsRequest) ProtoMessage()               {}
+func (*DecisionsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
-func (m *Counter) GetPkg() string {
+func (m *DecisionsRequest) GetHost() string {
 	if m != nil {
-		return m.Pkg
+		return m.Host
 	}
 	return ""
 }
 
-func (m *Counter) GetName() string {
+type DecisionsReply struct {
+	Decisions []*Decision `protobuf:"bytes,1,rep,name=decisions" json:"decisions,omitempty"`
+}
+
+func (m *DecisionsReply) Reset()                    { *m = DecisionsReply{} }
+func (m *DecisionsReply) String() string            { return proto.CompactTextString(m) }
+func (*DecisionsReply) ProtoMessage()               {}
+func (*DecisionsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *DecisionsReply) GetDecisions() []*Decision {
 	if m != nil {
-		return m.Name
+		return m.Decisions
 	}
-	return ""
+	return nil
 }
 
-func (m *Counter) GetValue() uint64 {
+type Decision struct {
+	Time      int64  `protobuf:"varint,1,opt,name=Time" json:"Time,omitempty"`
+	Module    string `protobuf:"bytes,2,opt,name=Module" json:"Module,omitempty"`
+	InputHash string `protobuf:"bytes,3,opt,name=InputHash" json:"InputHash,omitempty"`
+	Action    string `protobuf:"bytes,4,opt,name=Action" json:"Action,omitempty"`
+	Target    string `protobuf:"bytes,5,opt,name=Target" json:"Target,omitempty"`
+}
+
+func (m *Decision) Reset()                    { *m = Decision{} }
+func (m *Decision) String() string            { return proto.CompactTextString(m) }
+func (*Decision) ProtoMessage()               {}
+func (*Decision) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *Decision) GetTime() int64 {
 	if m != nil {
-		return m.Value
+		return m.Time
 	}
 	return 0
 }
 
-func (m *Counter) GetPrevValue() uint64 {
+func (m *Decision) GetModule() string {
 	if m != nil {
-		return m.PrevValue
+		return m.Module
+	}
+	return ""
+}
+
+func (m *Decision) GetInputHash() string {
+	if m != nil {
+		return m.InputHash
+	}
+	return ""
+}
+
+func (m *Decision) GetAction() string {
+	if m != nil {
+		return m.Action
+	}
+	return ""
+}
+
+func (m *Decision) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+type JoinScoresRequest struct {
+	Host string `protobuf:"bytes,1,opt,name=Host" json:"Host,omitempty"`
+}
+
+func (m *JoinScoresRequest) Reset()                    { *m = JoinScoresRequest{} }
+func (m *JoinScoresRequest) String() string            { return proto.CompactTextString(m) }
+func (*JoinScoresRequest) ProtoMessage()               {}
+func (*JoinScoresRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *JoinScoresRequest) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+type JoinScoresReply struct {
+	Joins []*JoinScores `protobuf:"bytes,1,rep,name=joins" json:"joins,omitempty"`
+}
+
+func (m *JoinScoresReply) Reset()                    { *m = JoinScoresReply{} }
+func (m *JoinScoresReply) String() string            { return proto.CompactTextString(m) }
+func (*JoinScoresReply) ProtoMessage()               {}
+func (*JoinScoresReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *JoinScoresReply) GetJoins() []*JoinScores {
+	if m != nil {
+		return m.Joins
+	}
+	return nil
+}
+
+type JoinScores struct {
+	Time        int64  `protobuf:"varint,1,opt,name=Time" json:"Time,omitempty"`
+	Module      string `protobuf:"bytes,2,opt,name=Module" json:"Module,omitempty"`
+	Name        string `protobuf:"bytes,3,opt,name=Name" json:"Name,omitempty"`
+	Explanation string `protobuf:"bytes,4,opt,name=Explanation" json:"Explanation,omitempty"`
+}
+
+func (m *JoinScores) Reset()                    { *m = JoinScores{} }
+func (m *JoinScores) String() string            { return proto.CompactTextString(m) }
+func (*JoinScores) ProtoMessage()               {}
+func (*JoinScores) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *JoinScores) GetTime() int64 {
+	if m != nil {
+		return m.Time
 	}
 	return 0
 }
 
-func init() {
-	proto.RegisterType((*DBQuery)(nil), "DBQuery")
-	proto.RegisterType((*QueryReply)(nil), "QueryReply")
-	proto.RegisterType((*DeployRequest)(nil), "DeployRequest")
-	proto.RegisterType((*DeployReply)(nil), "DeployReply")
-	proto.RegisterType((*VersionRequest)(nil), "VersionRequest")
-	proto.RegisterType((*VersionReply)(nil), "VersionReply")
-	proto.RegisterType((*CountersRequest)(nil), "CountersRequest")
-	proto.RegisterType((*MinionCountersRequest)(nil), "MinionCountersRequest")
-	proto.RegisterType((*CountersReply)(nil), "CountersReply")
-	proto.RegisterType((*Counter)(nil), "Counter")
+func (m *JoinScores) GetModule() string {
+	if m != nil {
+		return m.Module
+	}
+	return ""
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *JoinScores) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *JoinScores) GetExplanation() string {
+	if m != nil {
+		return m.Explanation
+	}
+	return ""
+}
 
-// Client API for API service
+type LogLevelsRequest struct {
+	Host string `protobuf:"bytes,1,opt,name=Host" json:"Host,omitempty"`
+	// Maps modules to a log level, or to "default" to revert them to the
+	// level of the process.
+	Levels map[string]string `protobuf:"bytes,2,rep,name=Levels" json:"Levels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
 
-type APIClient interface {
-	// Defined on both the daemon and minions.
-	Query(ctx context.Context, in *DBQuery, opts ...grpc.CallOption) (*QueryReply, error)
-	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionReply, error)
-	QueryCounters(ctx context.Context, in *CountersRequest, opts ...grpc.CallOption) (*CountersReply, error)
-	// Only defined on the daemon.
-	Deploy(ctx context.Context, in *DeployRequest, opts ...grpc.CallOption) (*DeployReply, error)
-	QueryMinionCounters(ctx context.Context, in *MinionCountersRequest, opts ...grpc.CallOption) (*CountersReply, error)
+func (m *LogLevelsRequest) Reset()                    { *m = LogLevelsRequest{} }
+func (m *LogLevelsRequest) String() string            { return proto.CompactTextString(m) }
+func (*LogLevelsRequest) ProtoMessage()               {}
+func (*LogLevelsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *LogLevelsRequest) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
 }
 
-type aPIClient struct {
-	cc *grpc.ClientConn
+func (m *LogLevelsRequest) GetLevels() map[string]string {
+	if m != nil {
+		return m.Levels
+	}
+	return nil
+}
+
+type LogLevelsReply struct {
+	Levels map[string]string `protobuf:"bytes,1,rep,name=Levels" json:"Levels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *LogLevelsReply) Reset()                    { *m = LogLevelsReply{} }
+func (m *LogLevelsReply) String() string            { return proto.CompactTextString(m) }
+func (*LogLevelsReply) ProtoMessage()               {}
+func (*LogLevelsReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *LogLevelsReply) GetLevels() map[string]string {
+	if m != nil {
+		return m.Levels
+	}
+	return nil
+}
+
+type AttachRequest struct {
+	// The container is identified by the first request of the stream.
+	Host     string `protobuf:"bytes,1,opt,name=Host" json:"Host,omitempty"`
+	DockerID string `protobuf:"bytes,2,opt,name=DockerID" json:"DockerID,omitempty"`
+	Stdin    []byte `protobuf:"bytes,3,opt,name=Stdin,proto3" json:"Stdin,omitempty"`
+	// The size of the client's terminal, if it changed.
+	Height int32 `protobuf:"varint,4,opt,name=Height" json:"Height,omitempty"`
+	Width  int32 `protobuf:"varint,5,opt,name=Width" json:"Width,omitempty"`
+}
+
+func (m *AttachRequest) Reset()                    { *m = AttachRequest{} }
+func (m *AttachRequest) String() string            { return proto.CompactTextString(m) }
+func (*AttachRequest) ProtoMessage()               {}
+func (*AttachRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *AttachRequest) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *AttachRequest) GetDockerID() string {
+	if m != nil {
+		return m.DockerID
+	}
+	return ""
+}
+
+func (m *AttachRequest) GetStdin() []byte {
+	if m != nil {
+		return m.Stdin
+	}
+	return nil
+}
+
+func (m *AttachRequest) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *AttachRequest) GetWidth() int32 {
+	if m != nil {
+		return m.Width
+	}
+	return 0
+}
+
+type AttachReply struct {
+	Output []byte `protobuf:"bytes,1,opt,name=Output,proto3" json:"Output,omitempty"`
+}
+
+func (m *AttachReply) Reset()                    { *m = AttachReply{} }
+func (m *AttachReply) String() string            { return proto.CompactTextString(m) }
+func (*AttachReply) ProtoMessage()               {}
+func (*AttachReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *AttachReply) GetOutput() []byte {
+	if m != nil {
+		return m.Output
+	}
+	return nil
+}
+
+type MirrorRequest struct {
+	Host string `protobuf:"bytes,1,opt,name=Host" json:"Host,omitempty"`
+	// The hostnames of the ends of the connection, and of the container that
+	// receives the traffic of the mirrored end.
+	From            string `protobuf:"bytes,2,opt,name=From" json:"From,omitempty"`
+	To              string `protobuf:"bytes,3,opt,name=To" json:"To,omitempty"`
+	Capture         string `protobuf:"bytes,4,opt,name=Capture" json:"Capture,omitempty"`
+	DurationSeconds int64  `protobuf:"varint,5,opt,name=DurationSeconds" json:"DurationSeconds,omitempty"`
+}
+
+func (m *MirrorRequest) Reset()                    { *m = MirrorRequest{} }
+func (m *MirrorRequest) String() string            { return proto.CompactTextString(m) }
+func (*MirrorRequest) ProtoMessage()               {}
+func (*MirrorRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *MirrorRequest) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *MirrorRequest) GetFrom() string {
+	if m != nil {
+		return m.From
+	}
+	return ""
+}
+
+func (m *MirrorRequest) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
+func (m *MirrorRequest) GetCapture() string {
+	if m != nil {
+		return m.Capture
+	}
+	return ""
+}
+
+func (m *MirrorRequest) GetDurationSeconds() int64 {
+	if m != nil {
+		return m.DurationSeconds
+	}
+	return 0
+}
+
+type MirrorReply struct {
+}
+
+func (m *MirrorReply) Reset()                    { *m = MirrorReply{} }
+func (m *MirrorReply) String() string            { return proto.CompactTextString(m) }
+func (*MirrorReply) ProtoMessage()               {}
+func (*MirrorReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+type EtcdSnapshotRequest struct {
+}
+
+func (m *EtcdSnapshotRequest) Reset()                    { *m = EtcdSnapshotRequest{} }
+func (m *EtcdSnapshotRequest) String() string            { return proto.CompactTextString(m) }
+func (*EtcdSnapshotRequest) ProtoMessage()               {}
+func (*EtcdSnapshotRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+type EtcdSnapshotReply struct {
+	// The values of the keys, by path.
+	Keys map[string]string `protobuf:"bytes,1,rep,name=Keys" json:"Keys,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Where the keys disagree with the daemon.  Only set by the daemon.
+	Differences []*EtcdDifference `protobuf:"bytes,2,rep,name=Differences" json:"Differences,omitempty"`
+}
+
+func (m *EtcdSnapshotReply) Reset()                    { *m = EtcdSnapshotReply{} }
+func (m *EtcdSnapshotReply) String() string            { return proto.CompactTextString(m) }
+func (*EtcdSnapshotReply) ProtoMessage()               {}
+func (*EtcdSnapshotReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *EtcdSnapshotReply) GetKeys() map[string]string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *EtcdSnapshotReply) GetDifferences() []*EtcdDifference {
+	if m != nil {
+		return m.Differences
+	}
+	return nil
+}
+
+type EtcdDifference struct {
+	Kind   string `protobuf:"bytes,1,opt,name=Kind" json:"Kind,omitempty"`
+	ID     string `protobuf:"bytes,2,opt,name=ID" json:"ID,omitempty"`
+	Reason string `protobuf:"bytes,3,opt,name=Reason" json:"Reason,omitempty"`
+}
+
+func (m *EtcdDifference) Reset()                    { *m = EtcdDifference{} }
+func (m *EtcdDifference) String() string            { return proto.CompactTextString(m) }
+func (*EtcdDifference) ProtoMessage()               {}
+func (*EtcdDifference) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *EtcdDifference) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *EtcdDifference) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *EtcdDifference) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type ConvergenceRequest struct {
+}
+
+func (m *ConvergenceRequest) Reset()                    { *m = ConvergenceRequest{} }
+func (m *ConvergenceRequest) String() string            { return proto.CompactTextString(m) }
+func (*ConvergenceRequest) ProtoMessage()               {}
+func (*ConvergenceRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+type ConvergenceReply struct {
+	Converged   bool               `protobuf:"varint,1,opt,name=Converged" json:"Converged,omitempty"`
+	Outstanding []*OutstandingItem `protobuf:"bytes,2,rep,name=Outstanding" json:"Outstanding,omitempty"`
+}
+
+func (m *ConvergenceReply) Reset()                    { *m = ConvergenceReply{} }
+func (m *ConvergenceReply) String() string            { return proto.CompactTextString(m) }
+func (*ConvergenceReply) ProtoMessage()               {}
+func (*ConvergenceReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *ConvergenceReply) GetConverged() bool {
+	if m != nil {
+		return m.Converged
+	}
+	return false
+}
+
+func (m *ConvergenceReply) GetOutstanding() []*OutstandingItem {
+	if m != nil {
+		return m.Outstanding
+	}
+	return nil
+}
+
+type OutstandingItem struct {
+	Kind   string `protobuf:"bytes,1,opt,name=Kind" json:"Kind,omitempty"`
+	ID     string `protobuf:"bytes,2,opt,name=ID" json:"ID,omitempty"`
+	Reason string `protobuf:"bytes,3,opt,name=Reason" json:"Reason,omitempty"`
+}
+
+func (m *OutstandingItem) Reset()                    { *m = OutstandingItem{} }
+func (m *OutstandingItem) String() string            { return proto.CompactTextString(m) }
+func (*OutstandingItem) ProtoMessage()               {}
+func (*OutstandingItem) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *OutstandingItem) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *OutstandingItem) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *OutstandingItem) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type SimulateRequest struct {
+	Blueprint string `protobuf:"bytes,1,opt,name=Blueprint" json:"Blueprint,omitempty"`
+}
+
+func (m *SimulateRequest) Reset()                    { *m = SimulateRequest{} }
+func (m *SimulateRequest) String() string            { return proto.CompactTextString(m) }
+func (*SimulateRequest) ProtoMessage()               {}
+func (*SimulateRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *SimulateRequest) GetBlueprint() string {
+	if m != nil {
+		return m.Blueprint
+	}
+	return ""
+}
+
+type SimulateReply struct {
+	// Whether every container could be placed.  Containers don't declare the
+	// CPU or RAM they need, so the machines' capacity isn't considered.
+	AllPlaced bool                `protobuf:"varint,1,opt,name=AllPlaced" json:"AllPlaced,omitempty"`
+	Machines  []*SimulatedMachine `protobuf:"bytes,2,rep,name=Machines" json:"Machines,omitempty"`
+	// The hostnames of the containers that couldn't be placed.
+	Unplaced []string `protobuf:"bytes,3,rep,name=Unplaced" json:"Unplaced,omitempty"`
+	// The number of worker machines that could be removed while every
+	// container could still be placed.
+	SpareMachines int32 `protobuf:"varint,4,opt,name=SpareMachines" json:"SpareMachines,omitempty"`
+}
+
+func (m *SimulateReply) Reset()                    { *m = SimulateReply{} }
+func (m *SimulateReply) String() string            { return proto.CompactTextString(m) }
+func (*SimulateReply) ProtoMessage()               {}
+func (*SimulateReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *SimulateReply) GetAllPlaced() bool {
+	if m != nil {
+		return m.AllPlaced
+	}
+	return false
+}
+
+func (m *SimulateReply) GetMachines() []*SimulatedMachine {
+	if m != nil {
+		return m.Machines
+	}
+	return nil
+}
+
+func (m *SimulateReply) GetUnplaced() []string {
+	if m != nil {
+		return m.Unplaced
+	}
+	return nil
+}
+
+func (m *SimulateReply) GetSpareMachines() int32 {
+	if m != nil {
+		return m.SpareMachines
+	}
+	return 0
+}
+
+type SimulatedMachine struct {
+	ID       string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	Provider string `protobuf:"bytes,2,opt,name=Provider" json:"Provider,omitempty"`
+	Region   string `protobuf:"bytes,3,opt,name=Region" json:"Region,omitempty"`
+	Size     string `protobuf:"bytes,4,opt,name=Size" json:"Size,omitempty"`
+	// The hostnames of the containers placed on the machine.
+	Containers []string `protobuf:"bytes,5,rep,name=Containers" json:"Containers,omitempty"`
+}
+
+func (m *SimulatedMachine) Reset()                    { *m = SimulatedMachine{} }
+func (m *SimulatedMachine) String() string            { return proto.CompactTextString(m) }
+func (*SimulatedMachine) ProtoMessage()               {}
+func (*SimulatedMachine) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *SimulatedMachine) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *SimulatedMachine) GetProvider() string {
+	if m != nil {
+		return m.Provider
+	}
+	return ""
+}
+
+func (m *SimulatedMachine) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+func (m *SimulatedMachine) GetSize() string {
+	if m != nil {
+		return m.Size
+	}
+	return ""
+}
+
+func (m *SimulatedMachine) GetContainers() []string {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+type CostRequest struct {
+	Blueprint string `protobuf:"bytes,1,opt,name=Blueprint" json:"Blueprint,omitempty"`
+}
+
+func (m *CostRequest) Reset()                    { *m = CostRequest{} }
+func (m *CostRequest) String() string            { return proto.CompactTextString(m) }
+func (*CostRequest) ProtoMessage()               {}
+func (*CostRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *CostRequest) GetBlueprint() string {
+	if m != nil {
+		return m.Blueprint
+	}
+	return ""
+}
+
+type CostReply struct {
+	Machines []*MachineCost `protobuf:"bytes,1,rep,name=Machines" json:"Machines,omitempty"`
+	// The estimated hourly cost in US dollars of the machines whose prices are
+	// known.
+	Total float64 `protobuf:"fixed64,2,opt,name=Total" json:"Total,omitempty"`
+}
+
+func (m *CostReply) Reset()                    { *m = CostReply{} }
+func (m *CostReply) String() string            { return proto.CompactTextString(m) }
+func (*CostReply) ProtoMessage()               {}
+func (*CostReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *CostReply) GetMachines() []*MachineCost {
+	if m != nil {
+		return m.Machines
+	}
+	return nil
+}
+
+func (m *CostReply) GetTotal() float64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+type MachineCost struct {
+	ID          string  `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	Provider    string  `protobuf:"bytes,2,opt,name=Provider" json:"Provider,omitempty"`
+	Region      string  `protobuf:"bytes,3,opt,name=Region" json:"Region,omitempty"`
+	Size        string  `protobuf:"bytes,4,opt,name=Size" json:"Size,omitempty"`
+	Preemptible bool    `protobuf:"varint,5,opt,name=Preemptible" json:"Preemptible,omitempty"`
+	Hourly      float64 `protobuf:"fixed64,6,opt,name=Hourly" json:"Hourly,omitempty"`
+	// Whether the price of the machine's size is unknown, in which case it's
+	// left out of the total.
+	Unknown bool `protobuf:"varint,7,opt,name=Unknown" json:"Unknown,omitempty"`
+}
+
+func (m *MachineCost) Reset()                    { *m = MachineCost{} }
+func (m *MachineCost) String() string            { return proto.CompactTextString(m) }
+func (*MachineCost) ProtoMessage()               {}
+func (*MachineCost) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *MachineCost) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *MachineCost) GetProvider() string {
+	if m != nil {
+		return m.Provider
+	}
+	return ""
+}
+
+func (m *MachineCost) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+func (m *MachineCost) GetSize() string {
+	if m != nil {
+		return m.Size
+	}
+	return ""
+}
+
+func (m *MachineCost) GetPreemptible() bool {
+	if m != nil {
+		return m.Preemptible
+	}
+	return false
+}
+
+func (m *MachineCost) GetHourly() float64 {
+	if m != nil {
+		return m.Hourly
+	}
+	return 0
+}
+
+func (m *MachineCost) GetUnknown() bool {
+	if m != nil {
+		return m.Unknown
+	}
+	return false
+}
+
+type MachineActionRequest struct {
+	BlueprintID string `protobuf:"bytes,1,opt,name=BlueprintID" json:"BlueprintID,omitempty"`
+}
+
+func (m *MachineActionRequest) Reset()                    { *m = MachineActionRequest{} }
+func (m *MachineActionRequest) String() string            { return proto.CompactTextString(m) }
+func (*MachineActionRequest) ProtoMessage()               {}
+func (*MachineActionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *MachineActionRequest) GetBlueprintID() string {
+	if m != nil {
+		return m.BlueprintID
+	}
+	return ""
+}
+
+type MachineActionReply struct {
+}
+
+func (m *MachineActionReply) Reset()                    { *m = MachineActionReply{} }
+func (m *MachineActionReply) String() string            { return proto.CompactTextString(m) }
+func (*MachineActionReply) ProtoMessage()               {}
+func (*MachineActionReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+type ResourcesRequest struct {
+}
+
+func (m *ResourcesRequest) Reset()                    { *m = ResourcesRequest{} }
+func (m *ResourcesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ResourcesRequest) ProtoMessage()               {}
+func (*ResourcesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+type ResourcesReply struct {
+	Resources []*Resource `protobuf:"bytes,1,rep,name=Resources" json:"Resources,omitempty"`
+	// The regions that couldn't be queried, and why.
+	Errors []string `protobuf:"bytes,2,rep,name=Errors" json:"Errors,omitempty"`
+}
+
+func (m *ResourcesReply) Reset()                    { *m = ResourcesReply{} }
+func (m *ResourcesReply) String() string            { return proto.CompactTextString(m) }
+func (*ResourcesReply) ProtoMessage()               {}
+func (*ResourcesReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *ResourcesReply) GetResources() []*Resource {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+func (m *ResourcesReply) GetErrors() []string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+type Resource struct {
+	Provider string            `protobuf:"bytes,1,opt,name=Provider" json:"Provider,omitempty"`
+	Region   string            `protobuf:"bytes,2,opt,name=Region" json:"Region,omitempty"`
+	Type     string            `protobuf:"bytes,3,opt,name=Type" json:"Type,omitempty"`
+	ID       string            `protobuf:"bytes,4,opt,name=ID" json:"ID,omitempty"`
+	Name     string            `protobuf:"bytes,5,opt,name=Name" json:"Name,omitempty"`
+	Tags     map[string]string `protobuf:"bytes,6,rep,name=Tags" json:"Tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The Unix time at which the resource was created, or 0 if the provider
+	// doesn't report it.
+	Created int64 `protobuf:"varint,7,opt,name=Created" json:"Created,omitempty"`
+}
+
+func (m *Resource) Reset()                    { *m = Resource{} }
+func (m *Resource) String() string            { return proto.CompactTextString(m) }
+func (*Resource) ProtoMessage()               {}
+func (*Resource) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *Resource) GetProvider() string {
+	if m != nil {
+		return m.Provider
+	}
+	return ""
+}
+
+func (m *Resource) GetRegion() string {
+	if m != nil {
+		return m.Region
+	}
+	return ""
+}
+
+func (m *Resource) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Resource) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *Resource) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Resource) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Resource) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+type CanaryRequest struct {
+	LoadBalancer string  `protobuf:"bytes,1,opt,name=LoadBalancer" json:"LoadBalancer,omitempty"`
+	Fraction     float64 `protobuf:"fixed64,2,opt,name=Fraction" json:"Fraction,omitempty"`
+}
+
+func (m *CanaryRequest) Reset()                    { *m = CanaryRequest{} }
+func (m *CanaryRequest) String() string            { return proto.CompactTextString(m) }
+func (*CanaryRequest) ProtoMessage()               {}
+func (*CanaryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *CanaryRequest) GetLoadBalancer() string {
+	if m != nil {
+		return m.LoadBalancer
+	}
+	return ""
+}
+
+func (m *CanaryRequest) GetFraction() float64 {
+	if m != nil {
+		return m.Fraction
+	}
+	return 0
+}
+
+type CanaryReply struct {
+}
+
+func (m *CanaryReply) Reset()                    { *m = CanaryReply{} }
+func (m *CanaryReply) String() string            { return proto.CompactTextString(m) }
+func (*CanaryReply) ProtoMessage()               {}
+func (*CanaryReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+type ContainersRequest struct {
+	Hostname  string   `protobuf:"bytes,1,opt,name=Hostname" json:"Hostname,omitempty"`
+	Label     string   `protobuf:"bytes,2,opt,name=Label" json:"Label,omitempty"`
+	DockerIDs []string `protobuf:"bytes,3,rep,name=DockerIDs" json:"DockerIDs,omitempty"`
+}
+
+func (m *ContainersRequest) Reset()                    { *m = ContainersRequest{} }
+func (m *ContainersRequest) String() string            { return proto.CompactTextString(m) }
+func (*ContainersRequest) ProtoMessage()               {}
+func (*ContainersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+func (m *ContainersRequest) GetHostname() string {
+	if m != nil {
+		return m.Hostname
+	}
+	return ""
+}
+
+func (m *ContainersRequest) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *ContainersRequest) GetDockerIDs() []string {
+	if m != nil {
+		return m.DockerIDs
+	}
+	return nil
+}
+
+type ContainersReply struct {
+	Hostnames []string `protobuf:"bytes,1,rep,name=Hostnames" json:"Hostnames,omitempty"`
+}
+
+func (m *ContainersReply) Reset()                    { *m = ContainersReply{} }
+func (m *ContainersReply) String() string            { return proto.CompactTextString(m) }
+func (*ContainersReply) ProtoMessage()               {}
+func (*ContainersReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *ContainersReply) GetHostnames() []string {
+	if m != nil {
+		return m.Hostnames
+	}
+	return nil
+}
+
+type Counter struct {
+	Pkg       string `protobuf:"bytes,1,opt,name=Pkg" json:"Pkg,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=Name" json:"Name,omitempty"`
+	Value     uint64 `protobuf:"varint,3,opt,name=Value" json:"Value,omitempty"`
+	PrevValue uint64 `protobuf:"varint,4,opt,name=PrevValue" json:"PrevValue,omitempty"`
+}
+
+func (m *Counter) Reset()                    { *m = Counter{} }
+func (m *Counter) String() string            { return proto.CompactTextString(m) }
+func (*Counter) ProtoMessage()               {}
+func (*Counter) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *Counter) GetPkg() string {
+	if m != nil {
+		return m.Pkg
+	}
+	return ""
+}
+
+func (m *Counter) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Counter) GetValue() uint64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *Counter) GetPrevValue() uint64 {
+	if m != nil {
+		return m.PrevValue
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*DBQuery)(nil), "DBQuery")
+	proto.RegisterType((*Filter)(nil), "Filter")
+	proto.RegisterType((*QueryReply)(nil), "QueryReply")
+	proto.RegisterType((*RowCount)(nil), "RowCount")
+	proto.RegisterType((*DeployRequest)(nil), "DeployRequest")
+	proto.RegisterType((*DeployReply)(nil), "DeployReply")
+	proto.RegisterType((*UpdateSettingsRequest)(nil), "UpdateSettingsRequest")
+	proto.RegisterType((*UpdateSettingsReply)(nil), "UpdateSettingsReply")
+	proto.RegisterType((*VersionRequest)(nil), "VersionRequest")
+	proto.RegisterType((*VersionReply)(nil), "VersionReply")
+	proto.RegisterType((*CountersRequest)(nil), "CountersRequest")
+	proto.RegisterType((*MinionCountersRequest)(nil), "MinionCountersRequest")
+	proto.RegisterType((*CountersReply)(nil), "CountersReply")
+	proto.RegisterType((*DecisionsRequest)(nil), "DecisionsRequest")
+	proto.RegisterType((*DecisionsReply)(nil), "DecisionsReply")
+	proto.RegisterType((*Decision)(nil), "Decision")
+	proto.RegisterType((*JoinScoresRequest)(nil), "JoinScoresRequest")
+	proto.RegisterType((*JoinScoresReply)(nil), "JoinScoresReply")
+	proto.RegisterType((*JoinScores)(nil), "JoinScores")
+	proto.RegisterType((*LogLevelsRequest)(nil), "LogLevelsRequest")
+	proto.RegisterType((*LogLevelsReply)(nil), "LogLevelsReply")
+	proto.RegisterType((*AttachRequest)(nil), "AttachRequest")
+	proto.RegisterType((*AttachReply)(nil), "AttachReply")
+	proto.RegisterType((*MirrorRequest)(nil), "MirrorRequest")
+	proto.RegisterType((*MirrorReply)(nil), "MirrorReply")
+	proto.RegisterType((*EtcdSnapshotRequest)(nil), "EtcdSnapshotRequest")
+	proto.RegisterType((*EtcdSnapshotReply)(nil), "EtcdSnapshotReply")
+	proto.RegisterType((*EtcdDifference)(nil), "EtcdDifference")
+	proto.RegisterType((*ConvergenceRequest)(nil), "ConvergenceRequest")
+	proto.RegisterType((*ConvergenceReply)(nil), "ConvergenceReply")
+	proto.RegisterType((*OutstandingItem)(nil), "OutstandingItem")
+	proto.RegisterType((*SimulateRequest)(nil), "SimulateRequest")
+	proto.RegisterType((*SimulateReply)(nil), "SimulateReply")
+	proto.RegisterType((*SimulatedMachine)(nil), "SimulatedMachine")
+	proto.RegisterType((*CostRequest)(nil), "CostRequest")
+	proto.RegisterType((*CostReply)(nil), "CostReply")
+	proto.RegisterType((*MachineCost)(nil), "MachineCost")
+	proto.RegisterType((*MachineActionRequest)(nil), "MachineActionRequest")
+	proto.RegisterType((*MachineActionReply)(nil), "MachineActionReply")
+	proto.RegisterType((*ResourcesRequest)(nil), "ResourcesRequest")
+	proto.RegisterType((*ResourcesReply)(nil), "ResourcesReply")
+	proto.RegisterType((*Resource)(nil), "Resource")
+	proto.RegisterType((*CanaryRequest)(nil), "CanaryRequest")
+	proto.RegisterType((*CanaryReply)(nil), "CanaryReply")
+	proto.RegisterType((*ContainersRequest)(nil), "ContainersRequest")
+	proto.RegisterType((*ContainersReply)(nil), "ContainersReply")
+	proto.RegisterType((*Counter)(nil), "Counter")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for API service
+
+type APIClient interface {
+	// Defined on both the daemon and minions.
+	Query(ctx context.Context, in *DBQuery, opts ...grpc.CallOption) (*QueryReply, error)
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionReply, error)
+	QueryCounters(ctx context.Context, in *CountersRequest, opts ...grpc.CallOption) (*CountersReply, error)
+	// Queries the local decisions, unless Host is set, in which case the daemon
+	// queries the minion on Host.
+	QueryDecisions(ctx context.Context, in *DecisionsRequest, opts ...grpc.CallOption) (*DecisionsReply, error)
+	// Explains the most recent scored joins, in the same manner as
+	// QueryDecisions.
+	QueryJoinScores(ctx context.Context, in *JoinScoresRequest, opts ...grpc.CallOption) (*JoinScoresReply, error)
+	// Sets the log levels of modules, and replies with the resulting level of
+	// every module.  Forwarded to the minion on Host in the same manner as
+	// QueryDecisions.
+	SetLogLevels(ctx context.Context, in *LogLevelsRequest, opts ...grpc.CallOption) (*LogLevelsReply, error)
+	// Attaches to a running container, streaming its output to the client, and
+	// the client's input and terminal size to it.  The daemon forwards the
+	// stream to the minion on Host.
+	Attach(ctx context.Context, opts ...grpc.CallOption) (API_AttachClient, error)
+	// Mirrors all of the traffic of the end of a connection that shares a
+	// machine with a capture container, not just the connection's, to the
+	// capture container until the duration elapses, or the request is
+	// cancelled.  The daemon forwards the request to the minion on Host, which
+	// must run the capture container.
+	MirrorEndpoint(ctx context.Context, in *MirrorRequest, opts ...grpc.CallOption) (*MirrorReply, error)
+	// Dumps the etcd keys through which the minions share the cluster's state.
+	// The daemon forwards the request to the leader, and diffs the keys against
+	// its own view of the cluster to pinpoint where the two disagree.
+	QueryEtcdSnapshot(ctx context.Context, in *EtcdSnapshotRequest, opts ...grpc.CallOption) (*EtcdSnapshotReply, error)
+	// Only defined on the daemon.
+	Deploy(ctx context.Context, in *DeployRequest, opts ...grpc.CallOption) (API_DeployClient, error)
+	UpdateSettings(ctx context.Context, in *UpdateSettingsRequest, opts ...grpc.CallOption) (*UpdateSettingsReply, error)
+	QueryMinionCounters(ctx context.Context, in *MinionCountersRequest, opts ...grpc.CallOption) (*CountersReply, error)
+	QueryConvergence(ctx context.Context, in *ConvergenceRequest, opts ...grpc.CallOption) (*ConvergenceReply, error)
+	// Simulates placing the containers of a blueprint on its machines, without
+	// deploying it.
+	Simulate(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (*SimulateReply, error)
+	// Estimates the hourly cost of the machines of a blueprint, or of the
+	// deployed machines if no blueprint is given.
+	QueryCost(ctx context.Context, in *CostRequest, opts ...grpc.CallOption) (*CostReply, error)
+	// Reboot or reimage the machine with the given blueprint ID once its
+	// containers have been rescheduled elsewhere.
+	Reboot(ctx context.Context, in *MachineActionRequest, opts ...grpc.CallOption) (*MachineActionReply, error)
+	Reimage(ctx context.Context, in *MachineActionRequest, opts ...grpc.CallOption) (*MachineActionReply, error)
+	// Lists the provider resources that were created for the namespace.
+	QueryResources(ctx context.Context, in *ResourcesRequest, opts ...grpc.CallOption) (*ResourcesReply, error)
+	// Sets the fraction of a load balancer's traffic that is sent to its
+	// canary containers, without redeploying the blueprint.
+	SetCanary(ctx context.Context, in *CanaryRequest, opts ...grpc.CallOption) (*CanaryReply, error)
+	// Restarts the containers whose hostname matches the glob Hostname, and
+	// whose label is Label.  The daemon forwards the Docker IDs of the selected
+	// containers to the minions running them, which restart them concurrently.
+	RestartContainers(ctx context.Context, in *ContainersRequest, opts ...grpc.CallOption) (*ContainersReply, error)
+	// Stops the selected containers by marking them stopped in the deployed
+	// blueprint, until it's next deployed.  Only defined on the daemon.
+	StopContainers(ctx context.Context, in *ContainersRequest, opts ...grpc.CallOption) (*ContainersReply, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) Query(ctx context.Context, in *DBQuery, opts ...grpc.CallOption) (*QueryReply, error) {
+	out := new(QueryReply)
+	err := grpc.Invoke(ctx, "/API/Query", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionReply, error) {
+	out := new(VersionReply)
+	err := grpc.Invoke(ctx, "/API/Version", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryCounters(ctx context.Context, in *CountersRequest, opts ...grpc.CallOption) (*CountersReply, error) {
+	out := new(CountersReply)
+	err := grpc.Invoke(ctx, "/API/QueryCounters", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryDecisions(ctx context.Context, in *DecisionsRequest, opts ...grpc.CallOption) (*DecisionsReply, error) {
+	out := new(DecisionsReply)
+	err := grpc.Invoke(ctx, "/API/QueryDecisions", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryJoinScores(ctx context.Context, in *JoinScoresRequest, opts ...grpc.CallOption) (*JoinScoresReply, error) {
+	out := new(JoinScoresReply)
+	err := grpc.Invoke(ctx, "/API/QueryJoinScores", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) SetLogLevels(ctx context.Context, in *LogLevelsRequest, opts ...grpc.CallOption) (*LogLevelsReply, error) {
+	out := new(LogLevelsReply)
+	err := grpc.Invoke(ctx, "/API/SetLogLevels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) Attach(ctx context.Context, opts ...grpc.CallOption) (API_AttachClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/API/Attach", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIAttachClient{stream}
+	return x, nil
+}
+
+type API_AttachClient interface {
+	Send(*AttachRequest) error
+	Recv() (*AttachReply, error)
+	grpc.ClientStream
+}
+
+type aPIAttachClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIAttachClient) Send(m *AttachRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *aPIAttachClient) Recv() (*AttachReply, error) {
+	m := new(AttachReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) MirrorEndpoint(ctx context.Context, in *MirrorRequest, opts ...grpc.CallOption) (*MirrorReply, error) {
+	out := new(MirrorReply)
+	err := grpc.Invoke(ctx, "/API/MirrorEndpoint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryEtcdSnapshot(ctx context.Context, in *EtcdSnapshotRequest, opts ...grpc.CallOption) (*EtcdSnapshotReply, error) {
+	out := new(EtcdSnapshotReply)
+	err := grpc.Invoke(ctx, "/API/QueryEtcdSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) Deploy(ctx context.Context, in *DeployRequest, opts ...grpc.CallOption) (API_DeployClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[1], c.cc, "/API/Deploy", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIDeployClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_DeployClient interface {
+	Recv() (*DeployReply, error)
+	grpc.ClientStream
+}
+
+type aPIDeployClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIDeployClient) Recv() (*DeployReply, error) {
+	m := new(DeployReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) UpdateSettings(ctx context.Context, in *UpdateSettingsRequest, opts ...grpc.CallOption) (*UpdateSettingsReply, error) {
+	out := new(UpdateSettingsReply)
+	err := grpc.Invoke(ctx, "/API/UpdateSettings", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryMinionCounters(ctx context.Context, in *MinionCountersRequest, opts ...grpc.CallOption) (*CountersReply, error) {
+	out := new(CountersReply)
+	err := grpc.Invoke(ctx, "/API/QueryMinionCounters", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryConvergence(ctx context.Context, in *ConvergenceRequest, opts ...grpc.CallOption) (*ConvergenceReply, error) {
+	out := new(ConvergenceReply)
+	err := grpc.Invoke(ctx, "/API/QueryConvergence", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) Simulate(ctx context.Context, in *SimulateRequest, opts ...grpc.CallOption) (*SimulateReply, error) {
+	out := new(SimulateReply)
+	err := grpc.Invoke(ctx, "/API/Simulate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) QueryCost(ctx context.Context, in *CostRequest, opts ...grpc.CallOption) (*CostReply, error) {
+	out := new(CostReply)
+	err := grpc.Invoke(ctx, "/API/QueryCost", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func NewAPIClient(cc *grpc.ClientConn) APIClient {
-	return &aPIClient{cc}
+func (c *aPIClient) Reboot(ctx context.Context, in *MachineActionRequest, opts ...grpc.CallOption) (*MachineActionReply, error) {
+	out := new(MachineActionReply)
+	err := grpc.Invoke(ctx, "/API/Reboot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (c *aPIClient) Query(ctx context.Context, in *DBQuery, opts ...grpc.CallOption) (*QueryReply, error) {
-	out := new(QueryReply)
-	err := grpc.Invoke(ctx, "/API/Query", in, out, c.cc, opts...)
+func (c *aPIClient) Reimage(ctx context.Context, in *MachineActionRequest, opts ...grpc.CallOption) (*MachineActionReply, error) {
+	out := new(MachineActionReply)
+	err := grpc.Invoke(ctx, "/API/Reimage", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionReply, error) {
-	out := new(VersionReply)
-	err := grpc.Invoke(ctx, "/API/Version", in, out, c.cc, opts...)
+func (c *aPIClient) QueryResources(ctx context.Context, in *ResourcesRequest, opts ...grpc.CallOption) (*ResourcesReply, error) {
+	out := new(ResourcesReply)
+	err := grpc.Invoke(ctx, "/API/QueryResources", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) QueryCounters(ctx context.Context, in *CountersRequest, opts ...grpc.CallOption) (*CountersReply, error) {
-	out := new(CountersReply)
-	err := grpc.Invoke(ctx, "/API/QueryCounters", in, out, c.cc, opts...)
+func (c *aPIClient) SetCanary(ctx context.Context, in *CanaryRequest, opts ...grpc.CallOption) (*CanaryReply, error) {
+	out := new(CanaryReply)
+	err := grpc.Invoke(ctx, "/API/SetCanary", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) Deploy(ctx context.Context, in *DeployRequest, opts ...grpc.CallOption) (*DeployReply, error) {
-	out := new(DeployReply)
-	err := grpc.Invoke(ctx, "/API/Deploy", in, out, c.cc, opts...)
+func (c *aPIClient) RestartContainers(ctx context.Context, in *ContainersRequest, opts ...grpc.CallOption) (*ContainersReply, error) {
+	out := new(ContainersReply)
+	err := grpc.Invoke(ctx, "/API/RestartContainers", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) QueryMinionCounters(ctx context.Context, in *MinionCountersRequest, opts ...grpc.CallOption) (*CountersReply, error) {
-	out := new(CountersReply)
-	err := grpc.Invoke(ctx, "/API/QueryMinionCounters", in, out, c.cc, opts...)
+func (c *aPIClient) StopContainers(ctx context.Context, in *ContainersRequest, opts ...grpc.CallOption) (*ContainersReply, error) {
+	out := new(ContainersReply)
+	err := grpc.Invoke(ctx, "/API/StopContainers", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -294,9 +1715,57 @@ type APIServer interface {
 	Query(context.Context, *DBQuery) (*QueryReply, error)
 	Version(context.Context, *VersionRequest) (*VersionReply, error)
 	QueryCounters(context.Context, *CountersRequest) (*CountersReply, error)
+	// Queries the local decisions, unless Host is set, in which case the daemon
+	// queries the minion on Host.
+	QueryDecisions(context.Context, *DecisionsRequest) (*DecisionsReply, error)
+	// Explains the most recent scored joins, in the same manner as
+	// QueryDecisions.
+	QueryJoinScores(context.Context, *JoinScoresRequest) (*JoinScoresReply, error)
+	// Sets the log levels of modules, and replies with the resulting level of
+	// every module.  Forwarded to the minion on Host in the same manner as
+	// QueryDecisions.
+	SetLogLevels(context.Context, *LogLevelsRequest) (*LogLevelsReply, error)
+	// Attaches to a running container, streaming its output to the client, and
+	// the client's input and terminal size to it.  The daemon forwards the
+	// stream to the minion on Host.
+	Attach(API_AttachServer) error
+	// Mirrors all of the traffic of the end of a connection that shares a
+	// machine with a capture container, not just the connection's, to the
+	// capture container until the duration elapses, or the request is
+	// cancelled.  The daemon forwards the request to the minion on Host, which
+	// must run the capture container.
+	MirrorEndpoint(context.Context, *MirrorRequest) (*MirrorReply, error)
+	// Dumps the etcd keys through which the minions share the cluster's state.
+	// The daemon forwards the request to the leader, and diffs the keys against
+	// its own view of the cluster to pinpoint where the two disagree.
+	QueryEtcdSnapshot(context.Context, *EtcdSnapshotRequest) (*EtcdSnapshotReply, error)
 	// Only defined on the daemon.
-	Deploy(context.Context, *DeployRequest) (*DeployReply, error)
+	Deploy(*DeployRequest, API_DeployServer) error
+	UpdateSettings(context.Context, *UpdateSettingsRequest) (*UpdateSettingsReply, error)
 	QueryMinionCounters(context.Context, *MinionCountersRequest) (*CountersReply, error)
+	QueryConvergence(context.Context, *ConvergenceRequest) (*ConvergenceReply, error)
+	// Simulates placing the containers of a blueprint on its machines, without
+	// deploying it.
+	Simulate(context.Context, *SimulateRequest) (*SimulateReply, error)
+	// Estimates the hourly cost of the machines of a blueprint, or of the
+	// deployed machines if no blueprint is given.
+	QueryCost(context.Context, *CostRequest) (*CostReply, error)
+	// Reboot or reimage the machine with the given blueprint ID once its
+	// containers have been rescheduled elsewhere.
+	Reboot(context.Context, *MachineActionRequest) (*MachineActionReply, error)
+	Reimage(context.Context, *MachineActionRequest) (*MachineActionReply, error)
+	// Lists the provider resources that were created for the namespace.
+	QueryResources(context.Context, *ResourcesRequest) (*ResourcesReply, error)
+	// Sets the fraction of a load balancer's traffic that is sent to its
+	// canary containers, without redeploying the blueprint.
+	SetCanary(context.Context, *CanaryRequest) (*CanaryReply, error)
+	// Restarts the containers whose hostname matches the glob Hostname, and
+	// whose label is Label.  The daemon forwards the Docker IDs of the selected
+	// containers to the minions running them, which restart them concurrently.
+	RestartContainers(context.Context, *ContainersRequest) (*ContainersReply, error)
+	// Stops the selected containers by marking them stopped in the deployed
+	// blueprint, until it's next deployed.  Only defined on the daemon.
+	StopContainers(context.Context, *ContainersRequest) (*ContainersReply, error)
 }
 
 func RegisterAPIServer(s *grpc.Server, srv APIServer) {
@@ -357,20 +1826,157 @@ func _API_QueryCounters_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_Deploy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeployRequest)
+func _API_QueryDecisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryDecisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/QueryDecisions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryDecisions(ctx, req.(*DecisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_QueryJoinScores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinScoresRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryJoinScores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/QueryJoinScores",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryJoinScores(ctx, req.(*JoinScoresRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_SetLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogLevelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).SetLogLevels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/SetLogLevels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).SetLogLevels(ctx, req.(*LogLevelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Attach_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(APIServer).Attach(&aPIAttachServer{stream})
+}
+
+type API_AttachServer interface {
+	Send(*AttachReply) error
+	Recv() (*AttachRequest, error)
+	grpc.ServerStream
+}
+
+type aPIAttachServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIAttachServer) Send(m *AttachReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *aPIAttachServer) Recv() (*AttachRequest, error) {
+	m := new(AttachRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _API_MirrorEndpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MirrorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).MirrorEndpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/MirrorEndpoint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).MirrorEndpoint(ctx, req.(*MirrorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_QueryEtcdSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EtcdSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryEtcdSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/QueryEtcdSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryEtcdSnapshot(ctx, req.(*EtcdSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Deploy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DeployRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).Deploy(m, &aPIDeployServer{stream})
+}
+
+type API_DeployServer interface {
+	Send(*DeployReply) error
+	grpc.ServerStream
+}
+
+type aPIDeployServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIDeployServer) Send(m *DeployReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_UpdateSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSettingsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).Deploy(ctx, in)
+		return srv.(APIServer).UpdateSettings(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/API/Deploy",
+		FullMethod: "/API/UpdateSettings",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).Deploy(ctx, req.(*DeployRequest))
+		return srv.(APIServer).UpdateSettings(ctx, req.(*UpdateSettingsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -393,6 +1999,168 @@ func _API_QueryMinionCounters_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _API_QueryConvergence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConvergenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryConvergence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/QueryConvergence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryConvergence(ctx, req.(*ConvergenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Simulate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimulateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).Simulate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/Simulate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).Simulate(ctx, req.(*SimulateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_QueryCost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryCost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/QueryCost",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryCost(ctx, req.(*CostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Reboot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MachineActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).Reboot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/Reboot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).Reboot(ctx, req.(*MachineActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Reimage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MachineActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).Reimage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/Reimage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).Reimage(ctx, req.(*MachineActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_QueryResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).QueryResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/QueryResources",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).QueryResources(ctx, req.(*ResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_SetCanary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).SetCanary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/SetCanary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).SetCanary(ctx, req.(*CanaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_RestartContainers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContainersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).RestartContainers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/RestartContainers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).RestartContainers(ctx, req.(*ContainersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_StopContainers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContainersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).StopContainers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/API/StopContainers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).StopContainers(ctx, req.(*ContainersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _API_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "API",
 	HandlerType: (*APIServer)(nil),
@@ -410,43 +2178,209 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			Handler:    _API_QueryCounters_Handler,
 		},
 		{
-			MethodName: "Deploy",
-			Handler:    _API_Deploy_Handler,
+			MethodName: "QueryDecisions",
+			Handler:    _API_QueryDecisions_Handler,
+		},
+		{
+			MethodName: "QueryJoinScores",
+			Handler:    _API_QueryJoinScores_Handler,
+		},
+		{
+			MethodName: "SetLogLevels",
+			Handler:    _API_SetLogLevels_Handler,
+		},
+		{
+			MethodName: "MirrorEndpoint",
+			Handler:    _API_MirrorEndpoint_Handler,
+		},
+		{
+			MethodName: "QueryEtcdSnapshot",
+			Handler:    _API_QueryEtcdSnapshot_Handler,
+		},
+		{
+			MethodName: "UpdateSettings",
+			Handler:    _API_UpdateSettings_Handler,
 		},
 		{
 			MethodName: "QueryMinionCounters",
 			Handler:    _API_QueryMinionCounters_Handler,
 		},
+		{
+			MethodName: "QueryConvergence",
+			Handler:    _API_QueryConvergence_Handler,
+		},
+		{
+			MethodName: "Simulate",
+			Handler:    _API_Simulate_Handler,
+		},
+		{
+			MethodName: "QueryCost",
+			Handler:    _API_QueryCost_Handler,
+		},
+		{
+			MethodName: "Reboot",
+			Handler:    _API_Reboot_Handler,
+		},
+		{
+			MethodName: "Reimage",
+			Handler:    _API_Reimage_Handler,
+		},
+		{
+			MethodName: "QueryResources",
+			Handler:    _API_QueryResources_Handler,
+		},
+		{
+			MethodName: "SetCanary",
+			Handler:    _API_SetCanary_Handler,
+		},
+		{
+			MethodName: "RestartContainers",
+			Handler:    _API_RestartContainers_Handler,
+		},
+		{
+			MethodName: "StopContainers",
+			Handler:    _API_StopContainers_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Attach",
+			Handler:       _API_Attach_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Deploy",
+			Handler:       _API_Deploy_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb/pb.proto",
 }
 
 func init() { proto.RegisterFile("pb/pb.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 364 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x52, 0x4b, 0x4b, 0xc3, 0x40,
-	0x10, 0x4e, 0x4c, 0x9f, 0x93, 0xa6, 0xad, 0xe3, 0x83, 0x10, 0x44, 0xc3, 0xe2, 0x21, 0x50, 0xd8,
-	0x42, 0x8b, 0x67, 0xd1, 0xf6, 0xa0, 0x07, 0xa5, 0x06, 0xe9, 0xbd, 0x29, 0x8b, 0x14, 0xd3, 0x6c,
-	0xcc, 0x43, 0xc8, 0xff, 0xf6, 0x07, 0x48, 0x76, 0x37, 0x6d, 0x53, 0xbd, 0xcd, 0x7c, 0xdf, 0x3c,
-	0x76, 0xbe, 0x6f, 0xc1, 0x8c, 0x83, 0x71, 0x1c, 0xd0, 0x38, 0xe1, 0x19, 0x27, 0x37, 0xd0, 0x9e,
-	0x3f, 0xbe, 0xe5, 0x2c, 0x29, 0xf0, 0x1c, 0x9a, 0xef, 0xab, 0x20, 0x64, 0xb6, 0xee, 0xea, 0x5e,
-	0xd7, 0x97, 0x09, 0x99, 0x00, 0x08, 0xda, 0x67, 0x71, 0x58, 0xe0, 0x2d, 0x58, 0x02, 0x9e, 0xf1,
-	0x28, 0x63, 0x51, 0x96, 0xaa, 0xda, 0x3a, 0x48, 0xc6, 0x60, 0xcd, 0x59, 0x1c, 0xf2, 0xc2, 0x67,
-	0x5f, 0x39, 0x4b, 0x33, 0xbc, 0x06, 0x90, 0xc0, 0x96, 0x45, 0x99, 0xea, 0x39, 0x40, 0x88, 0x05,
-	0x66, 0xd5, 0x10, 0x87, 0x05, 0x19, 0x42, 0x7f, 0xc9, 0x92, 0x74, 0xc3, 0x23, 0x35, 0x80, 0x78,
-	0xd0, 0xdb, 0x21, 0xe5, 0x3b, 0x6c, 0x68, 0xab, 0x5c, 0x4d, 0xab, 0x52, 0x72, 0x0a, 0x83, 0x19,
-	0xcf, 0xa3, 0x8c, 0x25, 0x69, 0xd5, 0x3c, 0x82, 0x8b, 0x97, 0x4d, 0xb4, 0xe1, 0xd1, 0x11, 0x81,
-	0x08, 0x8d, 0x27, 0x9e, 0x56, 0x0f, 0x12, 0x31, 0xb9, 0x03, 0x6b, 0x5f, 0x26, 0x4f, 0xee, 0xac,
-	0x15, 0x60, 0xeb, 0xae, 0xe1, 0x99, 0x93, 0x0e, 0x55, 0x15, 0xfe, 0x8e, 0x21, 0x6b, 0x68, 0x2b,
-	0x10, 0x87, 0x60, 0x2c, 0x3e, 0x3f, 0xd4, 0xd0, 0x32, 0x2c, 0xf7, 0xbc, 0xae, 0xb6, 0xcc, 0x3e,
-	0x91, 0x7b, 0xca, 0xb8, 0x54, 0x7b, 0xb9, 0x0a, 0x73, 0x66, 0x1b, 0xae, 0xee, 0x35, 0x7c, 0x99,
-	0xe0, 0x15, 0x74, 0x17, 0x09, 0xfb, 0x96, 0x4c, 0x43, 0x30, 0x7b, 0x60, 0xf2, 0xa3, 0x83, 0xf1,
-	0xb0, 0x78, 0x46, 0x17, 0x9a, 0xd2, 0xb2, 0x0e, 0x55, 0xe6, 0x39, 0x26, 0xdd, 0xbb, 0x44, 0x34,
-	0x1c, 0xed, 0xf4, 0xc1, 0x01, 0xad, 0x6b, 0xe9, 0x58, 0xf4, 0x50, 0x4a, 0xa2, 0xe1, 0x14, 0x2c,
-	0xd1, 0x5c, 0xdd, 0x8d, 0x43, 0x7a, 0xa4, 0x94, 0xd3, 0xa7, 0x35, 0x51, 0x88, 0x86, 0x1e, 0xb4,
-	0xa4, 0x65, 0xd8, 0xa7, 0x35, 0xb3, 0x9d, 0x1e, 0x3d, 0xf4, 0x52, 0xc3, 0x7b, 0x38, 0x13, 0xe3,
-	0xeb, 0x1e, 0xe0, 0x25, 0xfd, 0xd7, 0x94, 0xbf, 0xab, 0x82, 0x96, 0xf8, 0xaa, 0xd3, 0xdf, 0x00,
-	0x00, 0x00, 0xff, 0xff, 0x89, 0xf3, 0xd8, 0x96, 0xb9, 0x02, 0x00, 0x00,
+	// 1934 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb5, 0x58, 0x4b, 0x73, 0x1b, 0x45,
+	0x10, 0xf6, 0x5a, 0x96, 0x2d, 0xb7, 0x6c, 0x59, 0x1a, 0x3b, 0x94, 0x4b, 0x3c, 0x2a, 0xd9, 0x02,
+	0xe2, 0xe2, 0x31, 0x84, 0x04, 0x48, 0x02, 0x54, 0x41, 0x1c, 0x3b, 0x95, 0x10, 0x87, 0x98, 0x95,
+	0x13, 0xce, 0x6b, 0x69, 0x90, 0x17, 0x4b, 0xbb, 0xcb, 0x6a, 0x65, 0x30, 0x27, 0xaa, 0xe0, 0x04,
+	0xfc, 0x00, 0x4e, 0x9c, 0xb9, 0x51, 0x1c, 0xf9, 0x43, 0xfc, 0x00, 0x7e, 0x01, 0xdd, 0xf3, 0xda,
+	0xd9, 0xb5, 0x30, 0x49, 0x01, 0xb7, 0xed, 0x6f, 0x7a, 0xa6, 0x7b, 0x7a, 0xfa, 0xb9, 0xd0, 0x4c,
+	0x0f, 0xdf, 0x48, 0x0f, 0x79, 0x9a, 0x25, 0x79, 0xe2, 0xff, 0xe8, 0xc1, 0xd2, 0xce, 0xf6, 0x27,
+	0x53, 0x91, 0x9d, 0xb2, 0x0d, 0xa8, 0x1f, 0x84, 0x87, 0x23, 0xb1, 0xe9, 0x5d, 0xf4, 0xb6, 0x96,
+	0x03, 0x45, 0xb0, 0x4b, 0xb0, 0x74, 0x27, 0x1a, 0xe5, 0x22, 0x9b, 0x6c, 0xce, 0x5f, 0xac, 0x6d,
+	0x35, 0xaf, 0x2e, 0x71, 0x45, 0x07, 0x06, 0x67, 0x9b, 0xb0, 0xf4, 0x30, 0x1b, 0x88, 0x6c, 0xfb,
+	0x74, 0xb3, 0x26, 0xb7, 0x1a, 0x92, 0x8e, 0xdc, 0x8b, 0xc6, 0x51, 0xbe, 0xb9, 0x80, 0x78, 0x3d,
+	0x50, 0x04, 0xf1, 0xdf, 0x4e, 0xa6, 0x71, 0x8e, 0xfc, 0x75, 0x3c, 0x12, 0xf9, 0x35, 0xe9, 0xef,
+	0xc0, 0xa2, 0x3a, 0x94, 0x76, 0xde, 0x89, 0xc4, 0x68, 0x60, 0x94, 0x91, 0x04, 0x6b, 0xc1, 0xfc,
+	0xc3, 0x14, 0xf5, 0x20, 0x08, 0xbf, 0x88, 0xeb, 0x71, 0x38, 0x9a, 0x0a, 0x2d, 0x57, 0x11, 0xfe,
+	0x23, 0x00, 0x79, 0xa3, 0x40, 0xa4, 0xa3, 0x53, 0xf6, 0x22, 0xac, 0xca, 0x9b, 0xdc, 0x4e, 0xe2,
+	0x5c, 0xc4, 0xf9, 0x44, 0x9f, 0x58, 0x06, 0xf1, 0x9a, 0x8b, 0x52, 0x09, 0x73, 0xcb, 0x65, 0x1e,
+	0x24, 0x5f, 0x4a, 0x24, 0xd0, 0x0b, 0xfe, 0x0d, 0x68, 0x18, 0x8c, 0x3d, 0x03, 0x8b, 0x52, 0x16,
+	0x9d, 0x46, 0x37, 0xd0, 0x14, 0x29, 0x24, 0x19, 0xa4, 0x8e, 0x78, 0x61, 0x49, 0xf8, 0xc7, 0xb0,
+	0xba, 0x83, 0xba, 0x24, 0xa8, 0xd1, 0x17, 0xc8, 0x95, 0xb3, 0x17, 0x00, 0x14, 0x30, 0x46, 0xe1,
+	0x5a, 0x21, 0x07, 0x61, 0x0c, 0x16, 0x3e, 0x0d, 0x23, 0x75, 0x4a, 0x23, 0x90, 0xdf, 0xec, 0x65,
+	0x68, 0x1d, 0x44, 0x63, 0x91, 0x4c, 0xf3, 0x9e, 0xe8, 0x27, 0xf1, 0x60, 0x22, 0x2f, 0x5d, 0x0b,
+	0x2a, 0xa8, 0xff, 0xeb, 0x3c, 0x34, 0x8d, 0x34, 0xba, 0x3f, 0xaa, 0xd4, 0xcb, 0xc3, 0xa1, 0x7d,
+	0x56, 0x49, 0x90, 0x55, 0x1e, 0x84, 0xfd, 0xa3, 0x28, 0x16, 0x93, 0x83, 0x24, 0x0f, 0x47, 0x5a,
+	0xe1, 0x32, 0x48, 0x32, 0x0d, 0xb0, 0x9d, 0x24, 0xb9, 0x18, 0x48, 0x99, 0xf5, 0xa0, 0x82, 0xb2,
+	0xd7, 0xa0, 0x63, 0x10, 0xb4, 0x68, 0x2c, 0xfa, 0xc4, 0xaa, 0xde, 0xfc, 0xec, 0x02, 0xdb, 0x82,
+	0x35, 0xb2, 0x7b, 0x88, 0x68, 0xa6, 0xa5, 0xd7, 0x25, 0x6f, 0x15, 0x66, 0x57, 0x60, 0xbd, 0x80,
+	0x7a, 0xfd, 0x23, 0x31, 0x98, 0x8e, 0xf0, 0xe4, 0x45, 0xc9, 0x3d, 0x6b, 0x89, 0x5d, 0x85, 0xe6,
+	0xc3, 0x69, 0x3e, 0xc9, 0xc3, 0x78, 0x10, 0xc5, 0xc3, 0xcd, 0x25, 0xf9, 0x98, 0x6d, 0xee, 0x60,
+	0xf7, 0x72, 0x31, 0x0e, 0x5c, 0x26, 0xff, 0x17, 0x0f, 0x2e, 0x3c, 0x4a, 0x07, 0x61, 0x2e, 0x7a,
+	0x22, 0xcf, 0x11, 0x99, 0x98, 0x77, 0x7a, 0x0e, 0x96, 0x3f, 0x0e, 0xc7, 0x62, 0x92, 0x86, 0x7d,
+	0x63, 0xbf, 0x02, 0x60, 0xd7, 0xd1, 0x47, 0x47, 0xe1, 0xd0, 0xb8, 0xcc, 0x25, 0x3e, 0xf3, 0x10,
+	0x2e, 0x79, 0x76, 0xe3, 0x1c, 0x1d, 0x52, 0xf1, 0x77, 0x6f, 0x00, 0x14, 0x20, 0x6b, 0x43, 0xed,
+	0x58, 0x9c, 0xea, 0xe3, 0xe9, 0x93, 0x9e, 0xec, 0x44, 0xba, 0xb5, 0x7a, 0x7f, 0x45, 0xbc, 0x3b,
+	0x7f, 0xc3, 0xf3, 0x2f, 0xc0, 0x7a, 0x55, 0x08, 0xbe, 0xb1, 0xff, 0x18, 0x5a, 0x8f, 0xd1, 0x0c,
+	0x51, 0x12, 0x3b, 0x1e, 0x76, 0x6b, 0xff, 0x9e, 0x06, 0xe5, 0xd9, 0xf5, 0xc0, 0x41, 0xe4, 0xfb,
+	0x47, 0xb1, 0xc3, 0x62, 0xde, 0xdf, 0x05, 0xfd, 0x18, 0x56, 0xec, 0xb9, 0xe4, 0x4b, 0x18, 0xb9,
+	0xee, 0x91, 0x18, 0xb9, 0xe6, 0xbc, 0xb2, 0xbc, 0xf9, 0x7f, 0x96, 0x57, 0x9b, 0x25, 0xaf, 0x43,
+	0x9e, 0x81, 0x11, 0x83, 0xa4, 0xbe, 0x88, 0xff, 0x2a, 0x5c, 0x40, 0x1e, 0x5c, 0xac, 0x2c, 0x50,
+	0x8c, 0xdc, 0x4d, 0x26, 0x26, 0x7a, 0xe4, 0xb7, 0xff, 0x36, 0xac, 0x16, 0x6c, 0x2a, 0xf8, 0x1b,
+	0x7d, 0x0d, 0xc8, 0x48, 0x6d, 0x5e, 0x6d, 0x70, 0xcd, 0x11, 0xd8, 0x15, 0xff, 0x65, 0x68, 0xef,
+	0x88, 0x7e, 0x44, 0x2a, 0x9c, 0x7b, 0xfc, 0x4d, 0x68, 0x39, 0x7c, 0x74, 0xfe, 0x65, 0x58, 0x1e,
+	0x18, 0x44, 0x0b, 0x58, 0xe6, 0x86, 0x27, 0x28, 0xd6, 0xfc, 0xef, 0x3c, 0x68, 0x18, 0x9c, 0xce,
+	0xa6, 0xa0, 0x95, 0x67, 0xd7, 0x02, 0xf9, 0x4d, 0x19, 0xe5, 0x41, 0x42, 0x3e, 0xac, 0xd3, 0x9b,
+	0xa6, 0xc8, 0x05, 0xef, 0xc5, 0xe9, 0x34, 0xbf, 0x1b, 0x4e, 0x8e, 0x74, 0x9a, 0x2b, 0x00, 0xda,
+	0x75, 0xab, 0x9f, 0x93, 0x3d, 0x17, 0xd4, 0x2e, 0x45, 0x11, 0x7e, 0x10, 0x66, 0x43, 0x91, 0xcb,
+	0xc8, 0x42, 0x5c, 0x51, 0xfe, 0x65, 0xe8, 0x7c, 0x94, 0x44, 0x71, 0xaf, 0x9f, 0x64, 0xe2, 0xdc,
+	0xab, 0xbe, 0x05, 0x6b, 0x2e, 0x23, 0xdd, 0xf5, 0x12, 0xd4, 0x3f, 0x47, 0xc8, 0xdc, 0xb3, 0xc9,
+	0x1d, 0x06, 0xb5, 0x82, 0xfe, 0x02, 0x05, 0xf8, 0x54, 0xd7, 0x44, 0x5e, 0x0a, 0x2c, 0x7d, 0x43,
+	0xf9, 0xcd, 0x2e, 0x42, 0x73, 0xf7, 0xab, 0x74, 0x14, 0xc6, 0xa1, 0x73, 0x43, 0x17, 0xf2, 0x7f,
+	0xf2, 0xa0, 0xbd, 0x97, 0x0c, 0xf7, 0xc4, 0x89, 0x18, 0x9d, 0x77, 0x1d, 0xf6, 0x36, 0x2c, 0x2a,
+	0x26, 0x1d, 0xab, 0xcf, 0xf3, 0xea, 0x36, 0xae, 0x28, 0x15, 0xa7, 0x9a, 0xb9, 0x7b, 0x13, 0x9a,
+	0x0e, 0xfc, 0x4f, 0x91, 0xba, 0xec, 0x46, 0xea, 0x37, 0x1e, 0xb4, 0x1c, 0x19, 0x64, 0xc0, 0x6b,
+	0x56, 0x09, 0x65, 0xc1, 0x67, 0x79, 0x99, 0xe1, 0xbf, 0x56, 0xe1, 0x5b, 0x0f, 0x56, 0x6f, 0xe5,
+	0x39, 0xe6, 0xdf, 0xf3, 0x4c, 0xd3, 0x45, 0xc7, 0x4c, 0xfa, 0xc7, 0x22, 0xbb, 0xb7, 0xa3, 0x8f,
+	0xb0, 0xb4, 0xaa, 0x1d, 0x98, 0x23, 0xe5, 0xb3, 0xac, 0x04, 0x8a, 0xa0, 0x37, 0xbc, 0x2b, 0xa2,
+	0xe1, 0x91, 0x29, 0xeb, 0x9a, 0x22, 0xee, 0x4f, 0xa3, 0x41, 0x7e, 0xa4, 0xb3, 0xb9, 0x22, 0xfc,
+	0x97, 0xa0, 0x69, 0x94, 0x20, 0x23, 0xe0, 0x66, 0xcc, 0xbd, 0xe8, 0xbf, 0x52, 0x89, 0x95, 0x40,
+	0x53, 0xfe, 0x0f, 0x1e, 0x65, 0x88, 0x2c, 0x4b, 0xb2, 0xf3, 0x94, 0x45, 0xec, 0x4e, 0x96, 0x8c,
+	0xb5, 0xa2, 0xf2, 0x9b, 0x9a, 0x82, 0x83, 0x44, 0x3b, 0x0e, 0x7e, 0xc9, 0xf6, 0x22, 0x4c, 0xf3,
+	0x69, 0x26, 0xb4, 0xcb, 0x18, 0x92, 0x0a, 0xcf, 0xce, 0x34, 0x93, 0xae, 0x63, 0x6a, 0x68, 0x5d,
+	0xfa, 0x66, 0x15, 0xf6, 0x57, 0xa1, 0x69, 0x94, 0xa1, 0xfc, 0x8a, 0x69, 0x77, 0x37, 0xef, 0x0f,
+	0x7a, 0x71, 0x98, 0x4e, 0x8e, 0x92, 0xdc, 0xe4, 0xa6, 0xdf, 0x3c, 0xe8, 0x94, 0x71, 0xba, 0xe1,
+	0x15, 0x58, 0xb8, 0x2f, 0x4e, 0xcd, 0x23, 0x3f, 0xc7, 0xcf, 0x70, 0x70, 0x5a, 0x56, 0xaf, 0x2c,
+	0x39, 0xd9, 0x9b, 0x58, 0xb1, 0xa3, 0xcf, 0x3e, 0x13, 0x99, 0x88, 0xfb, 0xc2, 0xb8, 0xe8, 0x9a,
+	0xdc, 0x58, 0xe0, 0x81, 0xcb, 0xd3, 0xbd, 0x0e, 0xcb, 0xf6, 0x94, 0xa7, 0x72, 0x8a, 0x3d, 0x68,
+	0x95, 0xcf, 0x25, 0x9b, 0xde, 0x8f, 0x62, 0xd3, 0x69, 0xc9, 0x6f, 0xb2, 0xa9, 0x75, 0x07, 0xfc,
+	0xa2, 0x57, 0x0b, 0x44, 0x38, 0xd1, 0x79, 0x1b, 0xc3, 0x56, 0x51, 0xfe, 0x06, 0x30, 0xac, 0xc2,
+	0x27, 0x02, 0x93, 0x0b, 0xa9, 0xa8, 0xed, 0x32, 0x80, 0x76, 0x09, 0x25, 0xab, 0x60, 0x1e, 0x33,
+	0x98, 0x12, 0xd5, 0x08, 0x0a, 0xa0, 0x5a, 0xb6, 0xe7, 0x9f, 0xa4, 0x6c, 0x3f, 0x80, 0xb5, 0xca,
+	0xfa, 0xbf, 0xba, 0xca, 0x1b, 0xb0, 0xd6, 0x8b, 0xc6, 0xd3, 0x11, 0x16, 0x57, 0xa7, 0xfc, 0x6f,
+	0xa3, 0xdd, 0xd2, 0x2c, 0xb2, 0x5d, 0x5a, 0x01, 0xf8, 0x3f, 0xa3, 0xc7, 0x16, 0x3b, 0xf4, 0x1d,
+	0x6f, 0x8d, 0x46, 0xfb, 0x23, 0x6c, 0x0e, 0xec, 0x1d, 0x2d, 0xc0, 0x5e, 0x87, 0x86, 0xe9, 0x85,
+	0xf4, 0x05, 0x3b, 0xdc, 0xec, 0x1f, 0xe8, 0x95, 0xc0, 0xb2, 0x50, 0x5c, 0x3e, 0x8a, 0x53, 0x75,
+	0x56, 0x4d, 0x36, 0x99, 0x96, 0xa6, 0x6a, 0xda, 0x4b, 0xc3, 0x4c, 0xd8, 0xf3, 0x54, 0x20, 0x96,
+	0x41, 0xff, 0x7b, 0xcc, 0x8e, 0x55, 0x01, 0xda, 0x1c, 0x9e, 0x35, 0x07, 0x8a, 0xd9, 0xcf, 0x92,
+	0x93, 0x08, 0x1b, 0x76, 0x13, 0xfe, 0x86, 0x56, 0xa6, 0x1a, 0x46, 0xae, 0xa9, 0x86, 0xba, 0x7e,
+	0xf5, 0xa2, 0xaf, 0x4d, 0x78, 0xc9, 0x6f, 0x6a, 0x00, 0x8a, 0x7e, 0x4c, 0xf7, 0xf5, 0x0e, 0x82,
+	0x75, 0xbc, 0x79, 0x1b, 0x23, 0xf8, 0xc9, 0x4c, 0x7b, 0x9f, 0x9c, 0x65, 0xa2, 0xe3, 0x69, 0xcb,
+	0xb1, 0x9b, 0x8a, 0xa9, 0x15, 0xae, 0x01, 0xc9, 0x54, 0x98, 0x8c, 0x26, 0x18, 0xdb, 0xcc, 0x7a,
+	0x81, 0x22, 0xfc, 0xdf, 0x3d, 0x0c, 0xe6, 0x82, 0xff, 0x7f, 0xb3, 0x00, 0x96, 0xab, 0xfd, 0x4c,
+	0x88, 0x71, 0x9a, 0x47, 0x34, 0x45, 0xd5, 0xe5, 0xfb, 0xbb, 0x90, 0x4c, 0x9c, 0xc9, 0x34, 0x1b,
+	0x9d, 0xca, 0x0e, 0xd6, 0x0b, 0x34, 0x45, 0x19, 0xeb, 0x51, 0x7c, 0x1c, 0x27, 0x5f, 0xc6, 0xd8,
+	0xb0, 0xd2, 0x2e, 0x43, 0xe2, 0xcc, 0xb1, 0xa1, 0x55, 0x57, 0x85, 0xdd, 0x98, 0x0f, 0x65, 0x59,
+	0x6b, 0xd9, 0xcb, 0xb8, 0x10, 0x45, 0x66, 0x65, 0x27, 0x25, 0x32, 0x06, 0xed, 0x40, 0x4c, 0x50,
+	0x6a, 0xdf, 0x96, 0x7f, 0xff, 0x13, 0x68, 0x39, 0x98, 0xee, 0x6a, 0x2c, 0x62, 0xbb, 0x1a, 0x83,
+	0x04, 0xc5, 0x1a, 0x5d, 0x68, 0x97, 0xb2, 0xa4, 0x72, 0x68, 0x34, 0x8f, 0xa2, 0xfc, 0x3f, 0xb1,
+	0xdb, 0x31, 0x5c, 0x25, 0xfb, 0x7a, 0x7f, 0x6b, 0xdf, 0xf9, 0xaa, 0x7d, 0x0f, 0x4e, 0x53, 0xdb,
+	0x0e, 0xd0, 0xb7, 0x7e, 0xb7, 0x05, 0xfb, 0x6e, 0xa6, 0x65, 0xa8, 0x3b, 0x2d, 0xc3, 0x65, 0xdc,
+	0x47, 0x1d, 0xf9, 0xa2, 0x54, 0x7a, 0xdd, 0x2a, 0xcd, 0x0f, 0x6c, 0x0f, 0x2e, 0x19, 0x64, 0x91,
+	0xc8, 0x04, 0x05, 0x86, 0x34, 0x79, 0x2d, 0x30, 0x24, 0x65, 0xd6, 0x83, 0x27, 0xed, 0xcd, 0x4b,
+	0x99, 0xf5, 0x21, 0x36, 0x9f, 0xd8, 0x98, 0x64, 0x76, 0xca, 0xf3, 0x61, 0x65, 0x2f, 0x09, 0x07,
+	0xdb, 0x21, 0xf6, 0x2b, 0x7d, 0x7b, 0xf9, 0x12, 0x46, 0xc6, 0xb9, 0x93, 0x85, 0xaa, 0x85, 0x53,
+	0x5e, 0x6b, 0x69, 0x2a, 0x42, 0xe6, 0x40, 0x7a, 0xbb, 0x3e, 0x74, 0x8a, 0x78, 0x32, 0x32, 0x70,
+	0x3f, 0x15, 0xc6, 0x38, 0x1c, 0x9b, 0x01, 0xc5, 0xd2, 0x72, 0xfa, 0x0e, 0x0f, 0xc5, 0xc8, 0xa8,
+	0x2a, 0x09, 0x8a, 0x3c, 0x53, 0xdf, 0x27, 0x3a, 0xb1, 0x14, 0x00, 0x65, 0x41, 0x57, 0x88, 0xce,
+	0x6a, 0xe6, 0x48, 0x33, 0xee, 0x16, 0x00, 0x6a, 0xb5, 0xa4, 0x1b, 0x6a, 0x32, 0xd6, 0xfe, 0xf1,
+	0xd0, 0x18, 0x0b, 0x3f, 0xed, 0x13, 0xcd, 0x3b, 0x4f, 0x54, 0x9a, 0xd9, 0x17, 0xf4, 0xcc, 0x4e,
+	0x42, 0x30, 0x52, 0x4e, 0xd4, 0xca, 0x82, 0x5c, 0x29, 0x80, 0xab, 0x7f, 0x34, 0xa0, 0x86, 0x63,
+	0x02, 0xba, 0x7d, 0x5d, 0xfd, 0xab, 0x68, 0x70, 0xfd, 0xd7, 0xa2, 0xdb, 0xe4, 0xc5, 0xac, 0xef,
+	0xcf, 0xb1, 0x57, 0xed, 0x84, 0xc2, 0xd6, 0x78, 0x79, 0x26, 0xea, 0xae, 0x72, 0x77, 0x98, 0x41,
+	0xe6, 0x6b, 0xb0, 0x2a, 0x37, 0x9b, 0x99, 0x81, 0xb5, 0x79, 0x65, 0xca, 0xe8, 0xb6, 0x78, 0x69,
+	0xa0, 0xc0, 0x4d, 0xef, 0x40, 0x4b, 0x6e, 0xb2, 0x93, 0x00, 0xeb, 0xf0, 0xea, 0xf4, 0xd0, 0x5d,
+	0xe3, 0xe5, 0x41, 0x01, 0xf7, 0xdd, 0x84, 0x35, 0xb9, 0xcf, 0x6d, 0x90, 0xf9, 0x99, 0x66, 0xbc,
+	0xdb, 0xe6, 0x95, 0xbe, 0x1b, 0xb7, 0xbe, 0x05, 0x2b, 0x38, 0xef, 0xd9, 0x66, 0x11, 0x05, 0x56,
+	0xbb, 0x57, 0x14, 0x58, 0xee, 0x25, 0x71, 0xd7, 0x6b, 0x38, 0x1b, 0xc8, 0xc6, 0x8b, 0xb5, 0x78,
+	0xa9, 0x0d, 0xec, 0xae, 0x70, 0xa7, 0x23, 0xf3, 0xe7, 0xb6, 0xbc, 0x2b, 0x1e, 0x76, 0x2d, 0x2d,
+	0xd5, 0xf1, 0xec, 0xc6, 0x83, 0x14, 0x35, 0xc0, 0x3c, 0xc9, 0x4b, 0xfd, 0x18, 0xee, 0x72, 0x5b,
+	0xa2, 0x39, 0xf6, 0x01, 0x74, 0xe4, 0x85, 0xdc, 0xfe, 0x86, 0x6d, 0xf0, 0x19, 0x8d, 0x52, 0x97,
+	0x9d, 0x6d, 0x82, 0xf0, 0x80, 0x57, 0x60, 0x51, 0xfd, 0xa8, 0x40, 0x51, 0xa5, 0xff, 0x23, 0x28,
+	0xca, 0xf9, 0x83, 0xe1, 0xcf, 0xa1, 0x7a, 0x1f, 0x42, 0xab, 0x3c, 0xf8, 0xb2, 0x67, 0x66, 0x8f,
+	0xdb, 0xdd, 0x0d, 0x3e, 0x6b, 0x42, 0x26, 0x75, 0xd7, 0xa5, 0xba, 0xe5, 0x69, 0x12, 0x8f, 0x99,
+	0x39, 0x5e, 0xce, 0x78, 0xf8, 0xf7, 0xa1, 0xad, 0xbd, 0xc5, 0xb6, 0x36, 0x6c, 0x9d, 0x9f, 0x6d,
+	0x7f, 0xba, 0x1d, 0x5e, 0xed, 0x7e, 0x70, 0x37, 0x87, 0x86, 0xa9, 0xc5, 0xe8, 0x66, 0x95, 0x4e,
+	0x03, 0xa5, 0x95, 0x3a, 0x09, 0xe4, 0xc7, 0x1c, 0xac, 0xa5, 0x61, 0x94, 0xaf, 0x70, 0xa7, 0x76,
+	0x76, 0x81, 0xdb, 0xe2, 0x28, 0xfd, 0x11, 0x93, 0xe6, 0x61, 0x82, 0xb6, 0xbf, 0xc0, 0x67, 0xd5,
+	0x8a, 0xee, 0x3a, 0x9f, 0x51, 0x08, 0xe6, 0xd8, 0x75, 0x58, 0x0a, 0x44, 0x34, 0xa6, 0x9f, 0x41,
+	0x4f, 0xb7, 0xd1, 0x04, 0x40, 0x51, 0x06, 0x3a, 0xbc, 0x5a, 0x54, 0xd0, 0x1f, 0xcb, 0x35, 0x45,
+	0x86, 0xe6, 0x32, 0xbe, 0x89, 0xca, 0x68, 0xf8, 0xe2, 0xa5, 0x5c, 0x89, 0x2f, 0xee, 0xa6, 0xba,
+	0x39, 0xf6, 0x1e, 0x74, 0xf0, 0x80, 0x3c, 0xcc, 0xf2, 0x22, 0x1d, 0x61, 0xbc, 0x9c, 0x49, 0x80,
+	0xdd, 0x36, 0xaf, 0xe4, 0x2b, 0xdc, 0x7c, 0x03, 0x5a, 0xbd, 0x3c, 0x49, 0x9f, 0x7e, 0xe7, 0xe1,
+	0xa2, 0xfc, 0x2d, 0x7a, 0xed, 0x2f, 0x17, 0xec, 0x86, 0x4e, 0x25, 0x15, 0x00, 0x00,
 }
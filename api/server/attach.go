@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"io"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/connection/tls"
+)
+
+// Attach connects the client to a running container.  The daemon forwards the
+// stream to the minion on Host, which attaches to the container with Docker.
+func (s server) Attach(stream pb.API_AttachServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	if first.Host == "" && s.runningOnDaemon {
+		return errors.New("the host of the container is required")
+	} else if first.Host != "" && !s.runningOnDaemon {
+		return errDaemonOnlyRPC
+	}
+
+	// Minions only let the daemon attach to their containers.
+	if !s.runningOnDaemon {
+		if err := tls.VerifyDaemon(stream.Context()); err != nil {
+			return err
+		}
+	}
+
+	// The rest of the stream is relayed as the container's stdin, and the
+	// size of its TTY.
+	ctx := stream.Context()
+	stdin, stdinWriter := io.Pipe()
+	defer stdin.Close()
+
+	resize := make(chan api.TerminalSize)
+	go func() {
+		for req := first; ; {
+			if req.Height != 0 && req.Width != 0 {
+				size := api.TerminalSize{Height: int(req.Height),
+					Width: int(req.Width)}
+				select {
+				case resize <- size:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if len(req.Stdin) > 0 {
+				if _, err := stdinWriter.Write(req.Stdin); err != nil {
+					return
+				}
+			}
+
+			var err error
+			if req, err = stream.Recv(); err != nil {
+				stdinWriter.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	stdout := attachWriter{stream}
+	if first.Host == "" {
+		return attachLocal(ctx, first.DockerID, stdin, stdout, resize)
+	}
+
+	clnt, err := newClient(api.RemoteAddress(first.Host), s.clientCreds)
+	if err != nil {
+		return err
+	}
+	defer clnt.Close()
+
+	return clnt.Attach("", first.DockerID, stdin, stdout, resize)
+}
+
+// An attachWriter sends the output of a container to the client.
+type attachWriter struct {
+	stream pb.API_AttachServer
+}
+
+func (w attachWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&pb.AttachReply{Output: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
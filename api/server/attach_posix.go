@@ -0,0 +1,49 @@
+// +build !windows
+
+package server
+
+import (
+	"io"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/minion/docker"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Creates the Docker client with which minions attach to their containers.  The
+// client is only created when needed, as the daemon doesn't run Docker.  Mocked
+// out by the unit tests.
+var newDocker = func() docker.Client {
+	return docker.New("unix:///var/run/docker.sock")
+}
+
+// attachLocal attaches to the container with `id`, until it exits, or `ctx` is
+// cancelled.
+func attachLocal(ctx context.Context, id string, stdin io.Reader,
+	stdout io.Writer, resize <-chan api.TerminalSize) error {
+
+	dk := newDocker()
+	attachment, err := dk.Attach(id, stdin, stdout)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case size := <-resize:
+				err := dk.ResizeTTY(id, size.Height, size.Width)
+				if err != nil {
+					log.WithError(err).WithField("container", id).Debug(
+						"Failed to resize TTY")
+				}
+			case <-ctx.Done():
+				attachment.Close()
+				return
+			}
+		}
+	}()
+	return attachment.Wait()
+}
@@ -0,0 +1,138 @@
+// +build !windows
+
+package server
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/client"
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/minion/docker"
+)
+
+type mockAttachServer struct {
+	requests []pb.AttachRequest
+	output   bytes.Buffer
+
+	// If non-nil, Recv blocks until `wait` is closed before ending the stream.
+	wait chan struct{}
+
+	// The context of the stream, which defaults to that of the daemon.
+	ctx context.Context
+
+	grpc.ServerStream
+}
+
+func (s *mockAttachServer) Recv() (*pb.AttachRequest, error) {
+	if len(s.requests) == 0 {
+		if s.wait != nil {
+			<-s.wait
+		}
+		return nil, io.EOF
+	}
+
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return &req, nil
+}
+
+func (s *mockAttachServer) Send(reply *pb.AttachReply) error {
+	_, err := s.output.Write(reply.Output)
+	return err
+}
+
+func (s *mockAttachServer) Context() context.Context {
+	if s.ctx == nil {
+		return daemonContext()
+	}
+	return s.ctx
+}
+
+func TestAttachErrors(t *testing.T) {
+	stream := &mockAttachServer{requests: []pb.AttachRequest{{Host: "host"}}}
+	assert.Equal(t, errDaemonOnlyRPC, server{}.Attach(stream))
+
+	stream = &mockAttachServer{requests: []pb.AttachRequest{{DockerID: "id"}}}
+	assert.EqualError(t, server{runningOnDaemon: true}.Attach(stream),
+		"the host of the container is required")
+
+	// Minions only accept requests from the daemon.
+	stream = &mockAttachServer{requests: []pb.AttachRequest{{DockerID: "id"}},
+		ctx: context.Background()}
+	assert.EqualError(t, server{}.Attach(stream), "unknown peer")
+}
+
+func TestAttachLocal(t *testing.T) {
+	md, dk := docker.NewMock()
+	newDocker = func() docker.Client { return dk }
+
+	id, err := dk.Run(docker.RunOptions{Name: "name", TTY: true})
+	assert.NoError(t, err)
+
+	stream := &mockAttachServer{
+		requests: []pb.AttachRequest{
+			{DockerID: id, Height: 24, Width: 80},
+			{Stdin: []byte("input")},
+		},
+		wait: make(chan struct{}),
+	}
+
+	// Keep the stream open until the TTY is resized.
+	go func() {
+		defer close(stream.wait)
+		for {
+			md.Lock()
+			_, resized := md.TTYSizes[id]
+			md.Unlock()
+
+			if resized {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	assert.NoError(t, server{}.Attach(stream))
+	assert.Equal(t, "input", stream.output.String())
+	assert.Equal(t, [2]int{24, 80}, md.TTYSizes[id])
+
+	stream = &mockAttachServer{
+		requests: []pb.AttachRequest{{DockerID: "unknown"}}}
+	assert.Error(t, server{}.Attach(stream))
+}
+
+func TestAttachDaemon(t *testing.T) {
+	mc := new(mocks.Client)
+	mc.On("Attach", "", "dockerID", mock.Anything, mock.Anything,
+		mock.Anything).Return(nil)
+	mc.On("Close").Return(nil)
+
+	var dialed string
+	newClient = func(host string, _ connection.Credentials) (client.Client, error) {
+		dialed = host
+		return mc, nil
+	}
+
+	// The stream is held open so that the mock doesn't race with the stdin
+	// pipe being closed while inspecting its arguments.
+	stream := &mockAttachServer{
+		requests: []pb.AttachRequest{{Host: "9.9.9.9", DockerID: "dockerID"}},
+		wait:     make(chan struct{}),
+	}
+	defer close(stream.wait)
+
+	assert.NoError(t, server{runningOnDaemon: true}.Attach(stream))
+	assert.Equal(t, api.RemoteAddress("9.9.9.9"), dialed)
+	mc.AssertExpectations(t)
+}
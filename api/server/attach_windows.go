@@ -0,0 +1,16 @@
+package server
+
+import (
+	"errors"
+	"io"
+
+	"github.com/kelda/kelda/api"
+
+	"golang.org/x/net/context"
+)
+
+// Minions don't run on Windows, so containers are never attached to locally.
+func attachLocal(ctx context.Context, id string, stdin io.Reader,
+	stdout io.Writer, resize <-chan api.TerminalSize) error {
+	return errors.New("attaching is not supported on Windows")
+}
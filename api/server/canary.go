@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// SetCanary changes the canary fraction of a load balancer in the deployed
+// blueprint.  The foreman sends the modified blueprint to the minions as usual,
+// so the change takes effect without the blueprint being redeployed, and lasts
+// until it is.
+func (s server) SetCanary(ctx context.Context, req *pb.CanaryRequest) (
+	*pb.CanaryReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	if req.Fraction < 0 || req.Fraction > 1 {
+		return nil, errors.New("canary fraction must be between 0 and 1")
+	}
+
+	err := s.conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
+		bp, err := view.GetBlueprint()
+		if err != nil {
+			return errors.New("no blueprint is deployed")
+		}
+
+		// The blueprint may share its slices with readers, so the load
+		// balancers are copied rather than modified in place.
+		lbs := append([]blueprint.LoadBalancer(nil),
+			bp.LoadBalancers...)
+		found := false
+		for i, lb := range lbs {
+			if lb.Name == req.LoadBalancer {
+				lbs[i].CanaryFraction = req.Fraction
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no load balancer named %s", req.LoadBalancer)
+		}
+
+		bp.LoadBalancers = lbs
+		view.Commit(bp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CanaryReply{}, nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestSetCanary(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+
+	req := &pb.CanaryRequest{LoadBalancer: "web", Fraction: 0.25}
+	_, err := server{}.SetCanary(nil, req)
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	_, err = s.SetCanary(nil, req)
+	assert.EqualError(t, err, "no blueprint is deployed")
+
+	lbs := []blueprint.LoadBalancer{
+		{Name: "web", Hostnames: []string{"stable"},
+			CanaryHostnames: []string{"canary"}},
+		{Name: "db", Hostnames: []string{"db"}},
+	}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.LoadBalancers = lbs
+		view.Commit(bp)
+		return nil
+	})
+
+	_, err = s.SetCanary(nil, &pb.CanaryRequest{LoadBalancer: "web", Fraction: 2})
+	assert.EqualError(t, err, "canary fraction must be between 0 and 1")
+
+	_, err = s.SetCanary(nil, &pb.CanaryRequest{LoadBalancer: "missing"})
+	assert.EqualError(t, err, "no load balancer named missing")
+
+	_, err = s.SetCanary(nil, req)
+	assert.NoError(t, err)
+
+	bps := conn.SelectFromBlueprint(nil)
+	assert.Len(t, bps, 1)
+	bp := bps[0]
+	assert.Equal(t, 0.25, bp.LoadBalancers[0].CanaryFraction)
+	assert.Zero(t, bp.LoadBalancers[1].CanaryFraction)
+
+	// The original slice isn't modified.
+	assert.Zero(t, lbs[0].CanaryFraction)
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// RestartContainers restarts the selected containers.  The daemon looks up which
+// minion runs each of them, and forwards their Docker IDs to those minions
+// concurrently.  Minions restart the containers with the given Docker IDs.
+func (s server) RestartContainers(ctx context.Context, req *pb.ContainersRequest) (
+	*pb.ContainersReply, error) {
+
+	if !s.runningOnDaemon {
+		// Minions only let the daemon restart their containers.
+		if err := tls.VerifyDaemon(ctx); err != nil {
+			return nil, err
+		}
+
+		hostnames, err := restartLocal(s.conn, req.DockerIDs)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ContainersReply{Hostnames: hostnames}, nil
+	}
+
+	if err := checkSelector(req); err != nil {
+		return nil, err
+	}
+
+	leaderClient, err := newLeaderClient(s.conn.SelectFromMachine(nil),
+		s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+	defer leaderClient.Close()
+
+	rows, err := s.getClusterContainers(leaderClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The containers record the private IP of their minion, but the minions
+	// are dialed at their public IP.
+	publicIPs := map[string]string{}
+	for _, m := range s.conn.SelectFromMachine(nil) {
+		publicIPs[m.PrivateIP] = m.PublicIP
+	}
+
+	selected := false
+	hostIDs := map[string][]string{}
+	for _, dbc := range rows.([]db.Container) {
+		if !selects(req, dbc.Hostname, dbc.Label) {
+			continue
+		}
+
+		selected = true
+		if host := publicIPs[dbc.Minion]; host != "" && dbc.DockerID != "" {
+			hostIDs[host] = append(hostIDs[host], dbc.DockerID)
+		}
+	}
+
+	if !selected {
+		return nil, errNoneSelected(req)
+	} else if len(hostIDs) == 0 {
+		return nil, errors.New("none of the selected containers are running")
+	}
+
+	hostnames, err := restartRemote(hostIDs, s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ContainersReply{Hostnames: hostnames}, nil
+}
+
+// restartRemote restarts the containers in `hostIDs`, a map from the public IP of
+// each minion to the Docker IDs of the containers it should restart.  The minions
+// are contacted concurrently, and the failures of each reported together.
+func restartRemote(hostIDs map[string][]string, creds connection.Credentials) (
+	[]string, error) {
+
+	type result struct {
+		host      string
+		hostnames []string
+		err       error
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan result, len(hostIDs))
+	for host, ids := range hostIDs {
+		wg.Add(1)
+		go func(host string, ids []string) {
+			defer wg.Done()
+			var hostnames []string
+			clnt, err := newClient(api.RemoteAddress(host), creds)
+			if err == nil {
+				defer clnt.Close()
+				hostnames, err = clnt.RestartContainers("", "", ids)
+			}
+			results <- result{host, hostnames, err}
+		}(host, ids)
+	}
+
+	wg.Wait()
+	close(results)
+
+	var hostnames, failures []string
+	for res := range results {
+		if res.err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", res.host,
+				res.err))
+			continue
+		}
+		hostnames = append(hostnames, res.hostnames...)
+	}
+
+	if len(failures) != 0 {
+		sort.Strings(failures)
+		return nil, fmt.Errorf("failed to restart containers on %d minion(s): %s",
+			len(failures), strings.Join(failures, "; "))
+	}
+
+	sort.Strings(hostnames)
+	return hostnames, nil
+}
+
+// StopContainers marks the selected containers stopped in the deployed blueprint.
+// As with SetCanary, the change takes effect without the blueprint being
+// redeployed, and lasts until it is.
+func (s server) StopContainers(ctx context.Context, req *pb.ContainersRequest) (
+	*pb.ContainersReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	if err := checkSelector(req); err != nil {
+		return nil, err
+	}
+
+	var hostnames []string
+	err := s.conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
+		bp, err := view.GetBlueprint()
+		if err != nil {
+			return errors.New("no blueprint is deployed")
+		}
+
+		// The blueprint may share its slices with readers, so the
+		// containers are copied rather than modified in place.
+		containers := append([]blueprint.Container(nil), bp.Containers...)
+		for i, c := range containers {
+			if selects(req, c.Hostname, c.Label) {
+				containers[i].Stopped = true
+				hostnames = append(hostnames, c.Hostname)
+			}
+		}
+		if len(hostnames) == 0 {
+			return errNoneSelected(req)
+		}
+
+		bp.Containers = containers
+		view.Commit(bp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(hostnames)
+	return &pb.ContainersReply{Hostnames: hostnames}, nil
+}
+
+func checkSelector(req *pb.ContainersRequest) error {
+	if req.Hostname == "" && req.Label == "" {
+		return errors.New("a hostname pattern or label is required")
+	}
+
+	if _, err := path.Match(req.Hostname, ""); err != nil {
+		return fmt.Errorf("bad hostname pattern %q: %s", req.Hostname, err)
+	}
+	return nil
+}
+
+// selects returns whether the container with `hostname` and `label` matches the
+// selector of `req`.
+func selects(req *pb.ContainersRequest, hostname, label string) bool {
+	if req.Label != "" && req.Label != label {
+		return false
+	}
+
+	if req.Hostname == "" {
+		return true
+	}
+	match, _ := path.Match(req.Hostname, hostname)
+	return match
+}
+
+func errNoneSelected(req *pb.ContainersRequest) error {
+	var selector []string
+	if req.Hostname != "" {
+		selector = append(selector, fmt.Sprintf("hostname %q", req.Hostname))
+	}
+	if req.Label != "" {
+		selector = append(selector, fmt.Sprintf("label %q", req.Label))
+	}
+	return fmt.Errorf("no containers match %s", strings.Join(selector, " and "))
+}
@@ -0,0 +1,39 @@
+// +build !windows
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/kelda/kelda/db"
+)
+
+// restartLocal restarts the containers with `ids` that run on this minion, and
+// returns their hostnames.  The containers are removed, and the worker scheduler
+// recreates them from the minion's database as it would any other missing
+// container.
+func restartLocal(conn db.Conn, ids []string) ([]string, error) {
+	hostnames := map[string]string{}
+	for _, dbc := range conn.SelectFromContainer(nil) {
+		if dbc.DockerID != "" {
+			hostnames[dbc.DockerID] = dbc.Hostname
+		}
+	}
+
+	// Don't restart any of the containers unless all of them are ours.
+	for _, id := range ids {
+		if _, ok := hostnames[id]; !ok {
+			return nil, fmt.Errorf("no container %s on this minion", id)
+		}
+	}
+
+	dk := newDocker()
+	var restarted []string
+	for _, id := range ids {
+		if err := dk.RemoveID(id); err != nil {
+			return nil, fmt.Errorf("restart %s: %s", hostnames[id], err)
+		}
+		restarted = append(restarted, hostnames[id])
+	}
+	return restarted, nil
+}
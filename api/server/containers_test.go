@@ -0,0 +1,194 @@
+// +build !windows
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/client"
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+)
+
+func TestRestartContainersDaemon(t *testing.T) {
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		mc := new(mocks.Client)
+		mc.On("QueryContainers").Return([]db.Container{
+			{BlueprintID: "1", Hostname: "web-1", Label: "web",
+				Minion: "10.0.0.1"},
+			{BlueprintID: "2", Hostname: "web-2", Label: "web",
+				Minion: "10.0.0.2"},
+			{BlueprintID: "3", Hostname: "web-3", Label: "web",
+				Minion: "10.0.0.2"},
+			{BlueprintID: "4", Hostname: "db", Label: "db",
+				Minion: "10.0.0.2"},
+			{BlueprintID: "5", Hostname: "unscheduled", Label: "idle"},
+		}, nil)
+		mc.On("Close").Return(nil)
+		return mc, nil
+	}
+
+	restartErr := map[string]error{}
+	newClient = func(host string, _ connection.Credentials) (client.Client, error) {
+		var worker []db.Container
+		var ids []string
+		var hostnames []string
+		switch host {
+		case api.RemoteAddress("1.1.1.1"):
+			worker = []db.Container{{BlueprintID: "1", DockerID: "a"}}
+			ids, hostnames = []string{"a"}, []string{"web-1"}
+		case api.RemoteAddress("2.2.2.2"):
+			worker = []db.Container{{BlueprintID: "2", DockerID: "b"},
+				{BlueprintID: "3", DockerID: "c"},
+				{BlueprintID: "4", DockerID: "d"}}
+			ids, hostnames = []string{"b", "c"}, []string{"web-3", "web-2"}
+		default:
+			t.Fatalf("Unexpected call to getClient with host %s", host)
+		}
+
+		mc := new(mocks.Client)
+		mc.On("QueryContainers").Return(worker, nil)
+		mc.On("RestartContainers", "", "", ids).Return(hostnames,
+			restartErr[host])
+		mc.On("Close").Return(nil)
+		return mc, nil
+	}
+
+	conn := db.New()
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		for _, ips := range [][2]string{{"1.1.1.1", "10.0.0.1"},
+			{"2.2.2.2", "10.0.0.2"}} {
+			m := view.InsertMachine()
+			m.Role = db.Worker
+			m.PublicIP = ips[0]
+			m.PrivateIP = ips[1]
+			view.Commit(m)
+		}
+		return nil
+	})
+	s := server{conn: conn, runningOnDaemon: true}
+
+	_, err := s.RestartContainers(nil, &pb.ContainersRequest{})
+	assert.EqualError(t, err, "a hostname pattern or label is required")
+
+	_, err = s.RestartContainers(nil, &pb.ContainersRequest{Hostname: "[web"})
+	assert.EqualError(t, err, `bad hostname pattern "[web": `+
+		`syntax error in pattern`)
+
+	_, err = s.RestartContainers(nil, &pb.ContainersRequest{Hostname: "api*",
+		Label: "web"})
+	assert.EqualError(t, err, `no containers match hostname "api*" and `+
+		`label "web"`)
+
+	_, err = s.RestartContainers(nil, &pb.ContainersRequest{Label: "idle"})
+	assert.EqualError(t, err, "none of the selected containers are running")
+
+	reply, err := s.RestartContainers(nil, &pb.ContainersRequest{Hostname: "web-*"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-1", "web-2", "web-3"}, reply.Hostnames)
+
+	reply, err = s.RestartContainers(nil, &pb.ContainersRequest{Label: "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-1", "web-2", "web-3"}, reply.Hostnames)
+
+	restartErr[api.RemoteAddress("2.2.2.2")] = assert.AnError
+	_, err = s.RestartContainers(nil, &pb.ContainersRequest{Label: "web"})
+	assert.EqualError(t, err, "failed to restart containers on 1 minion(s): "+
+		"2.2.2.2: "+assert.AnError.Error())
+}
+
+func TestRestartContainersMinion(t *testing.T) {
+	md, dk := docker.NewMock()
+	newDocker = func() docker.Client { return dk }
+
+	webID, err := dk.Run(docker.RunOptions{Name: "web"})
+	assert.NoError(t, err)
+	dbID, err := dk.Run(docker.RunOptions{Name: "db"})
+	assert.NoError(t, err)
+
+	conn := db.New()
+	conn.Txn(db.ContainerTable).Run(func(view db.Database) error {
+		for _, c := range []db.Container{{Hostname: "web", DockerID: webID},
+			{Hostname: "db", DockerID: dbID}} {
+			dbc := view.InsertContainer()
+			dbc.Hostname = c.Hostname
+			dbc.DockerID = c.DockerID
+			view.Commit(dbc)
+		}
+		return nil
+	})
+	s := server{conn: conn}
+
+	// Minions only accept requests from the daemon.
+	_, err = s.RestartContainers(context.Background(), &pb.ContainersRequest{
+		DockerIDs: []string{webID}})
+	assert.EqualError(t, err, "unknown peer")
+
+	_, err = s.RestartContainers(daemonContext(), &pb.ContainersRequest{
+		DockerIDs: []string{webID, "other"}})
+	assert.EqualError(t, err, "no container other on this minion")
+
+	reply, err := s.RestartContainers(daemonContext(), &pb.ContainersRequest{
+		DockerIDs: []string{webID}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web"}, reply.Hostnames)
+
+	md.Lock()
+	_, webRunning := md.Containers[webID]
+	_, dbRunning := md.Containers[dbID]
+	md.Unlock()
+	assert.False(t, webRunning)
+	assert.True(t, dbRunning)
+}
+
+func TestStopContainers(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+
+	req := &pb.ContainersRequest{Hostname: "web-*"}
+	_, err := server{}.StopContainers(nil, req)
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	_, err = s.StopContainers(nil, req)
+	assert.EqualError(t, err, "no blueprint is deployed")
+
+	containers := []blueprint.Container{
+		{Hostname: "web-1", Label: "web"},
+		{Hostname: "web-2", Label: "web"},
+		{Hostname: "db", Label: "db"},
+	}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Containers = containers
+		view.Commit(bp)
+		return nil
+	})
+
+	_, err = s.StopContainers(nil, &pb.ContainersRequest{Label: "api"})
+	assert.EqualError(t, err, `no containers match label "api"`)
+
+	reply, err := s.StopContainers(nil, req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-1", "web-2"}, reply.Hostnames)
+
+	bps := conn.SelectFromBlueprint(nil)
+	assert.Len(t, bps, 1)
+	bp := bps[0]
+	assert.True(t, bp.Containers[0].Stopped)
+	assert.True(t, bp.Containers[1].Stopped)
+	assert.False(t, bp.Containers[2].Stopped)
+
+	// The deployed blueprint's containers aren't modified in place.
+	assert.False(t, containers[0].Stopped)
+}
@@ -0,0 +1,12 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/kelda/kelda/db"
+)
+
+// Minions don't run on Windows, so containers are never restarted locally.
+func restartLocal(conn db.Conn, ids []string) ([]string, error) {
+	return nil, errors.New("restarting containers is not supported on Windows")
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// The kinds of items that may prevent a deployment from converging.
+const (
+	machineItem    = "machine"
+	containerItem  = "container"
+	connectionItem = "connection"
+	clusterItem    = "cluster"
+)
+
+// The status Docker reports for running containers.
+const containerRunning = "running"
+
+// QueryConvergence computes whether the cluster implements the deployed
+// blueprint, and if not, what's outstanding.
+func (s server) QueryConvergence(ctx context.Context, _ *pb.ConvergenceRequest) (
+	*pb.ConvergenceReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	var bp db.Blueprint
+	var machines []db.Machine
+	err := s.conn.Txn(db.BlueprintTable, db.MachineTable).Run(
+		func(view db.Database) (err error) {
+			bp, err = view.GetBlueprint()
+			machines = view.SelectFromMachine(nil)
+			return err
+		})
+	if err != nil {
+		return nil, errors.New("no blueprint has been deployed")
+	}
+
+	outstanding := machineConvergence(machines)
+	outstanding = append(outstanding, s.clusterConvergence(bp.Blueprint,
+		machines)...)
+	return &pb.ConvergenceReply{
+		Converged:   len(outstanding) == 0,
+		Outstanding: outstanding,
+	}, nil
+}
+
+func machineConvergence(machines []db.Machine) []*pb.OutstandingItem {
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].BlueprintID < machines[j].BlueprintID
+	})
+
+	var outstanding []*pb.OutstandingItem
+	for _, m := range machines {
+		var reason string
+		switch {
+		case m.CloudID == "":
+			reason = "not booted"
+			switch m.Status {
+			case db.CredentialsMissing:
+				reason = fmt.Sprintf("not booted (%s)", m.Status)
+			case db.QuotaExceeded:
+				reason = fmt.Sprintf("not booted (%s: %s)", m.Status,
+					m.BootError)
+			}
+		case m.Status != db.Connected:
+			reason = "not connected"
+			if m.Status != "" {
+				reason = fmt.Sprintf("not connected (%s)", m.Status)
+			}
+		default:
+			continue
+		}
+		outstanding = append(outstanding, &pb.OutstandingItem{
+			Kind: machineItem, ID: m.BlueprintID, Reason: reason})
+	}
+	return outstanding
+}
+
+// clusterConvergence checks that the containers and connections in `bp` are
+// implemented by the cluster.
+func (s server) clusterConvergence(bp blueprint.Blueprint,
+	machines []db.Machine) []*pb.OutstandingItem {
+
+	if len(bp.Containers) == 0 && len(bp.Connections) == 0 {
+		return nil
+	}
+
+	unreachable := func(err error) []*pb.OutstandingItem {
+		return []*pb.OutstandingItem{{Kind: clusterItem,
+			Reason: fmt.Sprintf("failed to query the cluster: %s", err)}}
+	}
+
+	leaderClient, err := newLeaderClient(machines, s.clientCreds)
+	if err != nil {
+		return unreachable(err)
+	}
+	defer leaderClient.Close()
+
+	containers, err := s.getClusterContainers(leaderClient)
+	if err != nil {
+		return unreachable(err)
+	}
+
+	connections, err := leaderClient.QueryConnections()
+	if err != nil {
+		return unreachable(err)
+	}
+
+	return append(containerConvergence(bp.Containers,
+		containers.([]db.Container)),
+		connectionConvergence(bp.Connections, connections)...)
+}
+
+func containerConvergence(bpcs []blueprint.Container,
+	dbcs []db.Container) []*pb.OutstandingItem {
+
+	idToContainer := map[string]db.Container{}
+	for _, dbc := range dbcs {
+		idToContainer[dbc.BlueprintID] = dbc
+	}
+
+	var outstanding []*pb.OutstandingItem
+	for _, bpc := range bpcs {
+		dbc, ok := idToContainer[bpc.ID]
+
+		var reason string
+		switch {
+		case !ok:
+			reason = "unknown to the cluster"
+		case bpc.Stopped && dbc.Minion == "":
+			continue
+		case bpc.Stopped:
+			reason = "not stopped"
+		case dbc.Minion == "":
+			reason = "not scheduled"
+			if dbc.Status != "" {
+				reason = fmt.Sprintf("not scheduled (%s)", dbc.Status)
+			}
+		case dbc.Status == "":
+			reason = "not started"
+		case dbc.Status != containerRunning:
+			reason = dbc.Status
+		default:
+			continue
+		}
+		outstanding = append(outstanding, &pb.OutstandingItem{
+			Kind: containerItem, ID: bpc.ID, Reason: reason})
+	}
+	return outstanding
+}
+
+func connectionConvergence(bpConns []blueprint.Connection,
+	dbConns []db.Connection) []*pb.OutstandingItem {
+
+	programmed := map[blueprint.Connection]struct{}{}
+	for _, c := range dbConns {
+		programmed[blueprint.Connection{From: c.From, To: c.To,
+			MinPort: c.MinPort, MaxPort: c.MaxPort, MTLS: c.MTLS}] = struct{}{}
+	}
+
+	var outstanding []*pb.OutstandingItem
+	for _, c := range bpConns {
+		if _, ok := programmed[c]; ok {
+			continue
+		}
+
+		outstanding = append(outstanding, &pb.OutstandingItem{
+			Kind: connectionItem, ID: connectionID(c),
+			Reason: "not programmed"})
+	}
+	return outstanding
+}
+
+// connectionID identifies `c` by its endpoints and ports.
+func connectionID(c blueprint.Connection) string {
+	id := fmt.Sprintf("%s->%s:%d", c.From, c.To, c.MinPort)
+	if c.MinPort != c.MaxPort {
+		id = fmt.Sprintf("%s-%d", id, c.MaxPort)
+	}
+	return id
+}
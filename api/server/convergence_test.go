@@ -0,0 +1,117 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/api/client"
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestQueryConvergence(t *testing.T) {
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+
+	_, err := s.QueryConvergence(context.Background(), &pb.ConvergenceRequest{})
+	assert.EqualError(t, err, "no blueprint has been deployed")
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Blueprint = blueprint.Blueprint{
+			Containers: []blueprint.Container{
+				{ID: "running"}, {ID: "exited"}, {ID: "unscheduled"},
+				{ID: "missing"}, {ID: "stopped", Stopped: true},
+				{ID: "stopping", Stopped: true},
+			},
+			Connections: []blueprint.Connection{
+				{From: "a", To: "b", MinPort: 80, MaxPort: 80},
+				{From: "a", To: "c", MinPort: 80, MaxPort: 90},
+			},
+		}
+		view.Commit(bp)
+
+		for _, m := range []db.Machine{
+			{BlueprintID: "booting"},
+			{BlueprintID: "connecting", CloudID: "1", Status: db.Connecting},
+			{BlueprintID: "unbootable", Status: db.CredentialsMissing},
+			{BlueprintID: "overquota", Status: db.QuotaExceeded,
+				BootError: "needs 1 instances"},
+			{BlueprintID: "connected", CloudID: "2", Status: db.Connected},
+		} {
+			m.ID = view.InsertMachine().ID
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		mc := new(mocks.Client)
+		mc.On("QueryContainers").Return([]db.Container{
+			{BlueprintID: "running", Minion: "1", Status: "running"},
+			{BlueprintID: "exited", Minion: "1", Status: "exited"},
+			{BlueprintID: "unscheduled"},
+			{BlueprintID: "stopped", Stopped: true},
+			{BlueprintID: "stopping", Minion: "1", Status: "running",
+				Stopped: true},
+		}, nil)
+		mc.On("QueryConnections").Return([]db.Connection{
+			{From: "a", To: "b", MinPort: 80, MaxPort: 80},
+		}, nil)
+		mc.On("Close").Return(nil)
+		return mc, nil
+	}
+
+	reply, err := s.QueryConvergence(context.Background(),
+		&pb.ConvergenceRequest{})
+	assert.NoError(t, err)
+	assert.False(t, reply.Converged)
+	assert.Equal(t, []*pb.OutstandingItem{
+		{Kind: machineItem, ID: "booting", Reason: "not booted"},
+		{Kind: machineItem, ID: "connecting",
+			Reason: "not connected (connecting)"},
+		{Kind: machineItem, ID: "overquota",
+			Reason: "not booted (quota-exceeded: needs 1 instances)"},
+		{Kind: machineItem, ID: "unbootable",
+			Reason: "not booted (credentials-missing)"},
+		{Kind: containerItem, ID: "exited", Reason: "exited"},
+		{Kind: containerItem, ID: "unscheduled", Reason: "not scheduled"},
+		{Kind: containerItem, ID: "missing", Reason: "unknown to the cluster"},
+		{Kind: containerItem, ID: "stopping", Reason: "not stopped"},
+		{Kind: connectionItem, ID: "a->c:80-90", Reason: "not programmed"},
+	}, reply.Outstanding)
+
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		return nil, assert.AnError
+	}
+	reply, err = s.QueryConvergence(context.Background(),
+		&pb.ConvergenceRequest{})
+	assert.NoError(t, err)
+	assert.Contains(t, reply.Outstanding, &pb.OutstandingItem{Kind: clusterItem,
+		Reason: "failed to query the cluster: " + assert.AnError.Error()})
+}
+
+func TestQueryConvergenceConverged(t *testing.T) {
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		view.Commit(view.InsertBlueprint())
+		m := view.InsertMachine()
+		m.CloudID = "1"
+		m.Status = db.Connected
+		view.Commit(m)
+		return nil
+	})
+
+	reply, err := s.QueryConvergence(context.Background(),
+		&pb.ConvergenceRequest{})
+	assert.NoError(t, err)
+	assert.True(t, reply.Converged)
+	assert.Empty(t, reply.Outstanding)
+}
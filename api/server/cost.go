@@ -0,0 +1,74 @@
+package server
+
+import (
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/cloud/machine"
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// QueryCost estimates the hourly cost of the machines of the requested
+// blueprint, or of the deployed machines if no blueprint was given.  This way,
+// the cost of a blueprint can be checked both before and after it's deployed.
+func (s server) QueryCost(ctx context.Context, req *pb.CostRequest) (
+	*pb.CostReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	if req.Blueprint == "" {
+		return estimateCost(s.conn.SelectFromMachine(nil)), nil
+	}
+
+	bp, err := blueprint.FromJSON(req.Blueprint)
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []db.Machine
+	for _, bpm := range bp.Machines {
+		m := db.Machine{
+			BlueprintID: bpm.ID,
+			Provider:    db.ProviderName(bpm.Provider),
+			Region:      bpm.Region,
+			Size:        bpm.Size,
+			Preemptible: bpm.Preemptible,
+			MaxPrice:    bpm.MaxPrice,
+		}
+		if m.Preemptible && m.MaxPrice == 0 {
+			m.MaxPrice = bp.MaxPrice
+		}
+
+		// Machines that only specify their resources are priced at the
+		// size they would be booted with.  Machines whose provider comes
+		// from the daemon's defaults can't be priced.
+		_, err := db.ParseProvider(bpm.Provider)
+		if m.Size == "" && err == nil {
+			m.Size = machine.ChooseSize(m.Provider, bpm.RAM, bpm.CPU,
+				bpm.GPUs, bpm.GPUType, bpm.Arch)
+		}
+		machines = append(machines, m)
+	}
+	return estimateCost(machines), nil
+}
+
+func estimateCost(machines []db.Machine) *pb.CostReply {
+	reply := &pb.CostReply{}
+	for _, m := range machines {
+		price, ok := machine.HourlyPrice(m)
+		reply.Machines = append(reply.Machines, &pb.MachineCost{
+			ID:          m.BlueprintID,
+			Provider:    string(m.Provider),
+			Region:      m.Region,
+			Size:        m.Size,
+			Preemptible: m.Preemptible && !(m.OnDemand && m.OnDemandFallback),
+			Hourly:      price,
+			Unknown:     !ok,
+		})
+		reply.Total += price
+	}
+	return reply
+}
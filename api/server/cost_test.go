@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestQueryCost(t *testing.T) {
+	t.Parallel()
+
+	_, err := server{conn: db.New()}.QueryCost(nil, &pb.CostRequest{})
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+	_, err = s.QueryCost(nil, &pb.CostRequest{Blueprint: "malformed"})
+	assert.Error(t, err)
+
+	bp := blueprint.Blueprint{
+		MaxPrice: 0.01,
+		Machines: []blueprint.Machine{
+			{ID: "sized", Provider: "Amazon", Region: "us-east-1",
+				Size: "m4.large"},
+			{ID: "chosen", Provider: "Amazon", Region: "us-east-1",
+				RAM: blueprint.Range{Min: 8}, CPU: blueprint.Range{Min: 2}},
+			{ID: "spot", Provider: "Amazon", Region: "us-east-1",
+				Size: "m4.large", Preemptible: true},
+			{ID: "default", RAM: blueprint.Range{Min: 2}},
+		},
+	}
+	reply, err := s.QueryCost(nil, &pb.CostRequest{Blueprint: bp.String()})
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb.MachineCost{
+		{ID: "sized", Provider: "Amazon", Region: "us-east-1",
+			Size: "m4.large", Hourly: 0.12},
+		{ID: "chosen", Provider: "Amazon", Region: "us-east-1",
+			Size: "m4.large", Hourly: 0.12},
+		{ID: "spot", Provider: "Amazon", Region: "us-east-1",
+			Size: "m4.large", Preemptible: true, Hourly: 0.01},
+		{ID: "default", Unknown: true},
+	}, reply.Machines)
+	assert.InDelta(t, 0.25, reply.Total, 0.0001)
+
+	// Without a blueprint, the deployed machines are estimated.
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.BlueprintID = "deployed"
+		m.Provider = db.Amazon
+		m.Region = "us-east-1"
+		m.Size = "m4.large"
+		m.Preemptible = true
+		m.OnDemand = true
+		m.OnDemandFallback = true
+		view.Commit(m)
+		return nil
+	})
+	reply, err = s.QueryCost(nil, &pb.CostRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.CostReply{
+		Machines: []*pb.MachineCost{{ID: "deployed", Provider: "Amazon",
+			Region: "us-east-1", Size: "m4.large", Hourly: 0.12}},
+		Total: 0.12,
+	}, reply)
+}
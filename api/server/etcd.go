@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/etcd"
+
+	"golang.org/x/net/context"
+)
+
+// The kind of difference in the minion directory.
+const minionItem = "minion"
+
+// Allow mocking out for the unit tests.
+var etcdSnapshot = etcd.Snapshot
+
+// QueryEtcdSnapshot replies with the etcd keys through which the minions share
+// the cluster's state.  Minions read them from etcd, while the daemon forwards
+// the request to the leader, and diffs the keys against the deployed blueprint
+// and its machines.
+func (s server) QueryEtcdSnapshot(ctx context.Context, _ *pb.EtcdSnapshotRequest) (
+	*pb.EtcdSnapshotReply, error) {
+
+	if !s.runningOnDaemon {
+		keys, err := etcdSnapshot(s.conn)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.EtcdSnapshotReply{Keys: keys}, nil
+	}
+
+	var bp db.Blueprint
+	var machines []db.Machine
+	err := s.conn.Txn(db.BlueprintTable, db.MachineTable).Run(
+		func(view db.Database) (err error) {
+			bp, err = view.GetBlueprint()
+			machines = view.SelectFromMachine(nil)
+			return err
+		})
+	if err != nil {
+		return nil, errors.New("no blueprint has been deployed")
+	}
+
+	leaderClient, err := newLeaderClient(machines, s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+	defer leaderClient.Close()
+
+	reply, err := leaderClient.QueryEtcdSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	reply.Differences = diffEtcd(bp.Blueprint, machines, reply.Keys)
+	return &reply, nil
+}
+
+// diffEtcd returns where the etcd `keys` disagree with the blueprint and
+// machines of the daemon.
+func diffEtcd(bp blueprint.Blueprint, machines []db.Machine,
+	keys map[string]string) []*pb.EtcdDifference {
+
+	var diffs []*pb.EtcdDifference
+	var containers []db.Container
+	var connections []db.Connection
+	minions := map[string]db.Minion{}
+	for key, value := range keys {
+		var err error
+		switch {
+		case value == "":
+			continue
+		case strings.HasPrefix(key, etcd.ContainerDir+"/"):
+			var dbc db.Container
+			if err = json.Unmarshal([]byte(value), &dbc); err == nil {
+				containers = append(containers, dbc)
+			}
+		case key == etcd.ConnectionKey:
+			err = json.Unmarshal([]byte(value), &connections)
+		case strings.HasPrefix(key, etcd.MinionDir+"/"):
+			var m db.Minion
+			if err = json.Unmarshal([]byte(value), &m); err == nil {
+				minions[m.PrivateIP] = m
+			}
+		}
+
+		if err != nil {
+			diffs = append(diffs, &pb.EtcdDifference{Kind: clusterItem,
+				ID: key, Reason: fmt.Sprintf("unparseable: %s", err)})
+		}
+	}
+
+	diffs = append(diffs, diffEtcdMinions(machines, minions)...)
+	diffs = append(diffs, diffEtcdContainers(bp.Containers, containers,
+		minions)...)
+	diffs = append(diffs, diffEtcdConnections(bp, connections, containers)...)
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		if diffs[i].ID != diffs[j].ID {
+			return diffs[i].ID < diffs[j].ID
+		}
+		return diffs[i].Reason < diffs[j].Reason
+	})
+	return diffs
+}
+
+func diffEtcdMinions(machines []db.Machine,
+	minions map[string]db.Minion) []*pb.EtcdDifference {
+
+	var diffs []*pb.EtcdDifference
+	known := map[string]struct{}{}
+	for _, m := range machines {
+		if m.PrivateIP == "" {
+			continue
+		}
+		known[m.PrivateIP] = struct{}{}
+
+		minion, ok := minions[m.PrivateIP]
+		var reason string
+		switch {
+		case !ok && m.Status == db.Connected:
+			reason = "connected to the daemon, but not in etcd"
+		case ok && minion.Role != m.Role:
+			reason = fmt.Sprintf("%s in etcd, but %s in the daemon",
+				minion.Role, m.Role)
+		default:
+			continue
+		}
+		diffs = append(diffs, &pb.EtcdDifference{
+			Kind: minionItem, ID: m.PrivateIP, Reason: reason})
+	}
+
+	for ip := range minions {
+		if _, ok := known[ip]; !ok {
+			diffs = append(diffs, &pb.EtcdDifference{Kind: minionItem, ID: ip,
+				Reason: "in etcd, but not a machine of the daemon"})
+		}
+	}
+	return diffs
+}
+
+func diffEtcdContainers(bpcs []blueprint.Container, dbcs []db.Container,
+	minions map[string]db.Minion) []*pb.EtcdDifference {
+
+	var diffs []*pb.EtcdDifference
+	add := func(hostname, reason string) {
+		diffs = append(diffs, &pb.EtcdDifference{
+			Kind: containerItem, ID: hostname, Reason: reason})
+	}
+
+	idToContainer := map[string]db.Container{}
+	for _, dbc := range dbcs {
+		idToContainer[dbc.BlueprintID] = dbc
+	}
+
+	for _, bpc := range bpcs {
+		dbc, ok := idToContainer[bpc.ID]
+		if !ok {
+			add(bpc.Hostname, "in the blueprint, but not in etcd")
+			continue
+		}
+		delete(idToContainer, bpc.ID)
+
+		if bpc.Image.Name != dbc.Image {
+			add(bpc.Hostname, fmt.Sprintf(
+				"image %s in etcd, but %s in the blueprint",
+				dbc.Image, bpc.Image.Name))
+		}
+
+		if bpc.Stopped != dbc.Stopped {
+			add(bpc.Hostname, fmt.Sprintf(
+				"stopped is %t in etcd, but %t in the blueprint",
+				dbc.Stopped, bpc.Stopped))
+		}
+
+		if _, ok := minions[dbc.Minion]; dbc.Minion != "" && !ok {
+			add(bpc.Hostname, fmt.Sprintf(
+				"scheduled on %s, which isn't in etcd", dbc.Minion))
+		}
+	}
+
+	// Draining containers are kept after they're removed from the blueprint.
+	for _, dbc := range idToContainer {
+		if !dbc.Draining {
+			add(dbc.Hostname, "in etcd, but not in the blueprint")
+		}
+	}
+	return diffs
+}
+
+func diffEtcdConnections(bp blueprint.Blueprint, dbConns []db.Connection,
+	dbcs []db.Container) []*pb.EtcdDifference {
+
+	// The minions also allow connections to the members of load balancers.
+	expected := map[blueprint.Connection]struct{}{}
+	for _, c := range bp.Connections {
+		expected[c] = struct{}{}
+	}
+	for _, lb := range bp.LoadBalancers {
+		var members []string
+		members = append(members, lb.Hostnames...)
+		members = append(members, lb.CanaryHostnames...)
+		for _, c := range bp.Connections {
+			if c.To != lb.Name {
+				continue
+			}
+			for _, hostname := range members {
+				member := c
+				member.To = hostname
+				expected[member] = struct{}{}
+			}
+		}
+	}
+
+	draining := map[string]struct{}{}
+	for _, dbc := range dbcs {
+		if dbc.Draining {
+			draining[dbc.Hostname] = struct{}{}
+		}
+	}
+
+	programmed := map[blueprint.Connection]struct{}{}
+	for _, c := range dbConns {
+		programmed[blueprint.Connection{From: c.From, To: c.To,
+			MinPort: c.MinPort, MaxPort: c.MaxPort, MTLS: c.MTLS}] = struct{}{}
+	}
+
+	var diffs []*pb.EtcdDifference
+	for c := range expected {
+		if _, ok := programmed[c]; ok {
+			delete(programmed, c)
+			continue
+		}
+		diffs = append(diffs, &pb.EtcdDifference{Kind: connectionItem,
+			ID: connectionID(c), Reason: "in the blueprint, but not in etcd"})
+	}
+
+	for c := range programmed {
+		if _, ok := draining[c.To]; ok {
+			continue
+		}
+		diffs = append(diffs, &pb.EtcdDifference{Kind: connectionItem,
+			ID: connectionID(c), Reason: "in etcd, but not in the blueprint"})
+	}
+	return diffs
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kelda/kelda/api/client"
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestQueryEtcdSnapshotMinion(t *testing.T) {
+	s := server{conn: db.New()}
+
+	keys := map[string]string{"/connections": "[]"}
+	etcdSnapshot = func(db.Conn) (map[string]string, error) {
+		return keys, nil
+	}
+	reply, err := s.QueryEtcdSnapshot(context.Background(),
+		&pb.EtcdSnapshotRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.EtcdSnapshotReply{Keys: keys}, reply)
+
+	etcdSnapshot = func(db.Conn) (map[string]string, error) {
+		return nil, assert.AnError
+	}
+	_, err = s.QueryEtcdSnapshot(context.Background(), &pb.EtcdSnapshotRequest{})
+	assert.Equal(t, assert.AnError, err)
+}
+
+func TestQueryEtcdSnapshotDaemon(t *testing.T) {
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+
+	_, err := s.QueryEtcdSnapshot(context.Background(), &pb.EtcdSnapshotRequest{})
+	assert.EqualError(t, err, "no blueprint has been deployed")
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Blueprint = blueprint.Blueprint{
+			Containers: []blueprint.Container{
+				{ID: "1", Hostname: "web", Image: blueprint.Image{Name: "nginx"}},
+			},
+		}
+		view.Commit(bp)
+
+		m := view.InsertMachine()
+		m.PrivateIP = "10.0.0.1"
+		m.Role = db.Master
+		m.Status = db.Connected
+		view.Commit(m)
+		return nil
+	})
+
+	keys := map[string]string{
+		"/minion-containers/10.0.0.1/1": mustJSON(t,
+			db.Container{BlueprintID: "1", Minion: "10.0.0.1"}),
+		"/minions/10.0.0.1": mustJSON(t, db.Minion{PrivateIP: "10.0.0.1"}),
+	}
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		mc := new(mocks.Client)
+		mc.On("QueryEtcdSnapshot").Return(pb.EtcdSnapshotReply{Keys: keys}, nil)
+		mc.On("Close").Return(nil)
+		return mc, nil
+	}
+
+	reply, err := s.QueryEtcdSnapshot(context.Background(),
+		&pb.EtcdSnapshotRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, keys, reply.Keys)
+	assert.Equal(t, []*pb.EtcdDifference{
+		{Kind: containerItem, ID: "web",
+			Reason: "image  in etcd, but nginx in the blueprint"},
+		{Kind: minionItem, ID: "10.0.0.1",
+			Reason: " in etcd, but Master in the daemon"},
+	}, reply.Differences)
+
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		return nil, assert.AnError
+	}
+	_, err = s.QueryEtcdSnapshot(context.Background(), &pb.EtcdSnapshotRequest{})
+	assert.Equal(t, assert.AnError, err)
+}
+
+func TestDiffEtcd(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{ID: "1", Hostname: "same", Image: blueprint.Image{Name: "img"}},
+			{ID: "2", Hostname: "missing"},
+			{ID: "3", Hostname: "stopped", Stopped: true},
+			{ID: "4", Hostname: "orphaned"},
+		},
+		Connections: []blueprint.Connection{
+			{From: "a", To: "lb", MinPort: 80, MaxPort: 80},
+			{From: "a", To: "b", MinPort: 80, MaxPort: 90},
+		},
+		LoadBalancers: []blueprint.LoadBalancer{
+			{Name: "lb", Hostnames: []string{"same"}},
+		},
+	}
+
+	machines := []db.Machine{
+		{PrivateIP: "10.0.0.1", Role: db.Master, Status: db.Connected},
+		{PrivateIP: "10.0.0.2", Role: db.Worker, Status: db.Connected},
+		{PrivateIP: "10.0.0.3", Role: db.Worker, Status: db.Connecting},
+		{Role: db.Worker},
+	}
+
+	keys := map[string]string{
+		"/minion-containers/10.0.0.1/1": mustJSON(t, db.Container{
+			BlueprintID: "1", Hostname: "same", Image: "img",
+			Minion: "10.0.0.1"}),
+		"/minion-containers/10.0.0.1/3": mustJSON(t, db.Container{
+			BlueprintID: "3", Hostname: "stopped"}),
+		"/minion-containers/10.0.0.2/4": mustJSON(t, db.Container{
+			BlueprintID: "4", Hostname: "orphaned", Minion: "10.0.0.2"}),
+		"/minion-containers/10.0.0.1/5": mustJSON(t, db.Container{
+			BlueprintID: "5", Hostname: "extra"}),
+		"/minion-containers/10.0.0.1/6": mustJSON(t, db.Container{
+			BlueprintID: "6", Hostname: "draining", Draining: true}),
+		"/minion-containers/10.0.0.1/7": "{",
+		"/connections": mustJSON(t, []db.Connection{
+			{From: "a", To: "lb", MinPort: 80, MaxPort: 80},
+			{From: "a", To: "same", MinPort: 80, MaxPort: 80},
+			{From: "a", To: "draining", MinPort: 80, MaxPort: 80},
+			{From: "a", To: "c", MinPort: 80, MaxPort: 80},
+		}),
+		"/minions/10.0.0.1": mustJSON(t, db.Minion{PrivateIP: "10.0.0.1",
+			Role: db.Master}),
+		"/minions/10.0.0.4": mustJSON(t, db.Minion{PrivateIP: "10.0.0.4",
+			Role: db.Worker}),
+		"/minions/10.0.0.5": "",
+		"/minions/10.0.0.6": "{",
+	}
+
+	assert.Equal(t, []*pb.EtcdDifference{
+		{Kind: clusterItem, ID: "/minion-containers/10.0.0.1/7",
+			Reason: "unparseable: unexpected end of JSON input"},
+		{Kind: clusterItem, ID: "/minions/10.0.0.6",
+			Reason: "unparseable: unexpected end of JSON input"},
+		{Kind: connectionItem, ID: "a->b:80-90",
+			Reason: "in the blueprint, but not in etcd"},
+		{Kind: connectionItem, ID: "a->c:80",
+			Reason: "in etcd, but not in the blueprint"},
+		{Kind: containerItem, ID: "extra",
+			Reason: "in etcd, but not in the blueprint"},
+		{Kind: containerItem, ID: "missing",
+			Reason: "in the blueprint, but not in etcd"},
+		{Kind: containerItem, ID: "orphaned",
+			Reason: "scheduled on 10.0.0.2, which isn't in etcd"},
+		{Kind: containerItem, ID: "stopped",
+			Reason: "stopped is false in etcd, but true in the blueprint"},
+		{Kind: minionItem, ID: "10.0.0.2",
+			Reason: "connected to the daemon, but not in etcd"},
+		{Kind: minionItem, ID: "10.0.0.4",
+			Reason: "in etcd, but not a machine of the daemon"},
+	}, diffEtcd(bp, machines, keys))
+
+	assert.Empty(t, diffEtcd(blueprint.Blueprint{}, nil, nil))
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	js, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(js)
+}
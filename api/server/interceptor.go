@@ -0,0 +1,151 @@
+package server
+
+import (
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/counter"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The number of requests per second each client may make, and the number of
+// requests they may burst above that rate.
+var rateLimit, rateBurst = 50.0, 100.0
+
+// Buckets for idle clients are pruned once more than this many clients are
+// tracked, so that clients connecting from many addresses can't exhaust memory.
+const maxTrackedClients = 1024
+
+var c = counter.New("API Server")
+
+var now = time.Now
+
+// An interceptor is middleware that logs each request, converts panics in
+// handlers into errors, and limits the rate of requests from each client.
+type interceptor struct {
+	limiter *rateLimiter
+}
+
+func newInterceptor() interceptor {
+	return interceptor{newRateLimiter(rateLimit, rateBurst)}
+}
+
+func (i interceptor) unary(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	var resp interface{}
+	err := i.intercept(ctx, info.FullMethod, func() (err error) {
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (i interceptor) stream(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	return i.intercept(ss.Context(), info.FullMethod, func() error {
+		return handler(srv, ss)
+	})
+}
+
+func (i interceptor) intercept(ctx context.Context, method string,
+	handler func() error) (err error) {
+
+	client := clientIP(ctx)
+	if client == "" {
+		client = "local"
+	}
+
+	start := now()
+	defer func() {
+		if r := recover(); r != nil {
+			c.Inc("Panic")
+			log.WithFields(log.Fields{
+				"method": method,
+				"client": client,
+			}).Errorf("Panic in API handler: %v\n%s", r, debug.Stack())
+			err = status.Errorf(codes.Internal, "internal error: %v", r)
+		}
+		logRequest(method, client, now().Sub(start), err)
+	}()
+
+	if !i.limiter.allow(client) {
+		c.Inc("Rate Limited")
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	c.Inc(method)
+	return handler()
+}
+
+func logRequest(method, client string, duration time.Duration, err error) {
+	entry := log.WithFields(log.Fields{
+		"method":   method,
+		"client":   client,
+		"duration": duration,
+	})
+	if err != nil {
+		entry.WithError(err).Debug("API request failed")
+		return
+	}
+	entry.Debug("API request")
+}
+
+// A rateLimiter implements a token bucket for each client.
+type rateLimiter struct {
+	sync.Mutex
+	rate, burst float64
+	buckets     map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, buckets: map[string]*bucket{}}
+}
+
+// allow returns true if `client` may make a request, and consumes a token if so.
+func (rl *rateLimiter) allow(client string) bool {
+	rl.Lock()
+	defer rl.Unlock()
+
+	t := now()
+	b, ok := rl.buckets[client]
+	if !ok {
+		if len(rl.buckets) >= maxTrackedClients {
+			rl.prune(t)
+		}
+		b = &bucket{tokens: rl.burst, last: t}
+		rl.buckets[client] = b
+	}
+
+	b.tokens = math.Min(rl.burst, b.tokens+t.Sub(b.last).Seconds()*rl.rate)
+	b.last = t
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune forgets about the clients whose buckets would be full by `t`, as they're
+// indistinguishable from new clients.  The caller must hold the lock.
+func (rl *rateLimiter) prune(t time.Time) {
+	for client, b := range rl.buckets {
+		if b.tokens+t.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptor(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	rateLimit, rateBurst = 1, 2
+	defer func() { rateLimit, rateBurst = 50, 100 }()
+
+	intercept := newInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/API/Query"}
+	call := func(ctx context.Context, handler grpc.UnaryHandler) (
+		interface{}, error) {
+		return intercept.unary(ctx, "req", info, handler)
+	}
+
+	ok := func(_ context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	resp, err := call(context.Background(), ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "req", resp)
+
+	// Handler errors are passed through.
+	_, err = call(context.Background(),
+		func(context.Context, interface{}) (interface{}, error) {
+			return nil, errors.New("handler error")
+		})
+	assert.EqualError(t, err, "handler error")
+
+	// The local client has used its burst, but remote clients have their own
+	// limit.
+	_, err = call(context.Background(), ok)
+	assert.Equal(t, codes.ResourceExhausted, errorCode(err))
+
+	remote := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(8, 8, 8, 8)}})
+	_, err = call(remote, ok)
+	assert.NoError(t, err)
+
+	current = current.Add(time.Second)
+	_, err = call(context.Background(), ok)
+	assert.NoError(t, err)
+
+	// Panics are converted into errors.
+	current = current.Add(time.Second)
+	_, err = call(context.Background(),
+		func(context.Context, interface{}) (interface{}, error) {
+			panic("oops")
+		})
+	assert.Equal(t, codes.Internal, errorCode(err))
+	assert.Contains(t, err.Error(), "oops")
+}
+
+func TestStreamInterceptor(t *testing.T) {
+	intercept := newInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/API/Deploy"}
+	ss := &mockDeployServer{ctx: context.Background()}
+
+	err := intercept.stream(nil, ss, info,
+		func(interface{}, grpc.ServerStream) error {
+			panic("oops")
+		})
+	assert.Equal(t, codes.Internal, errorCode(err))
+
+	err = intercept.stream(nil, ss, info,
+		func(interface{}, grpc.ServerStream) error {
+			return nil
+		})
+	assert.NoError(t, err)
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	rl := newRateLimiter(1, 1)
+	assert.True(t, rl.allow("a"))
+	assert.False(t, rl.allow("a"))
+
+	rl.buckets["idle"] = &bucket{tokens: 1, last: current}
+	rl.prune(current)
+	assert.Len(t, rl.buckets, 1)
+	assert.Contains(t, rl.buckets, "a")
+
+	rl.prune(current.Add(time.Second))
+	assert.Empty(t, rl.buckets)
+}
+
+func errorCode(err error) codes.Code {
+	s, _ := status.FromError(err)
+	return s.Code()
+}
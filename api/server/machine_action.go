@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// Reboot requests that the machine be rebooted once it's drained of containers.
+func (s server) Reboot(ctx context.Context, req *pb.MachineActionRequest) (
+	*pb.MachineActionReply, error) {
+	return s.requestAction(req.BlueprintID, db.RebootAction)
+}
+
+// Reimage requests that the machine be replaced by a freshly booted one once
+// it's drained of containers.
+func (s server) Reimage(ctx context.Context, req *pb.MachineActionRequest) (
+	*pb.MachineActionReply, error) {
+	return s.requestAction(req.BlueprintID, db.ReimageAction)
+}
+
+// requestAction marks the machine so that the minion drains it, and the cloud
+// performs `action` once it has.
+func (s server) requestAction(blueprintID, action string) (
+	*pb.MachineActionReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	err := s.conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		var machines []db.Machine
+		err := view.SelectFromQuery(
+			db.Where("BlueprintID", db.Equal, blueprintID), &machines)
+		if err != nil {
+			return err
+		}
+
+		if len(machines) == 0 {
+			return fmt.Errorf("no machine with blueprint ID %s", blueprintID)
+		}
+
+		m := machines[0]
+		if m.CloudID == "" {
+			return fmt.Errorf("machine %s hasn't booted", blueprintID)
+		}
+		if m.Action != "" {
+			return fmt.Errorf("machine %s already has a pending %s",
+				blueprintID, m.Action)
+		}
+
+		m.Action = action
+		m.ActionRequested = time.Now()
+		view.Commit(m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MachineActionReply{}, nil
+}
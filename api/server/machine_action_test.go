@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/db"
+)
+
+func TestMachineActions(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.BlueprintID = "booted"
+		m.CloudID = "cloud"
+		view.Commit(m)
+
+		m = view.InsertMachine()
+		m.BlueprintID = "booting"
+		view.Commit(m)
+		return nil
+	})
+
+	_, err := server{}.Reboot(nil, &pb.MachineActionRequest{BlueprintID: "booted"})
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	_, err = s.Reboot(nil, &pb.MachineActionRequest{BlueprintID: "missing"})
+	assert.EqualError(t, err, "no machine with blueprint ID missing")
+
+	_, err = s.Reimage(nil, &pb.MachineActionRequest{BlueprintID: "booting"})
+	assert.EqualError(t, err, "machine booting hasn't booted")
+
+	_, err = s.Reboot(nil, &pb.MachineActionRequest{BlueprintID: "booted"})
+	assert.NoError(t, err)
+
+	machines := conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.BlueprintID == "booted"
+	})
+	assert.Len(t, machines, 1)
+	assert.Equal(t, db.RebootAction, machines[0].Action)
+	assert.False(t, machines[0].ActionRequested.IsZero())
+
+	_, err = s.Reimage(nil, &pb.MachineActionRequest{BlueprintID: "booted"})
+	assert.EqualError(t, err, "machine booted already has a pending reboot")
+}
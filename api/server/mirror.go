@@ -0,0 +1,76 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/connection/tls"
+
+	"golang.org/x/net/context"
+)
+
+// maxMirrorDuration bounds how long traffic is mirrored for, so that forgotten
+// mirrors don't burden the worker.
+const maxMirrorDuration = time.Hour
+
+// MirrorEndpoint mirrors the traffic of an end of a connection to a capture
+// container.  The daemon forwards the request to the minion on Host, which
+// mirrors the port of whichever end of the connection shares its machine with
+// the capture container.  Open vSwitch mirrors whole ports, so every packet that
+// the end sends or receives is mirrored, including those of its other
+// connections.
+func (s server) MirrorEndpoint(ctx context.Context, req *pb.MirrorRequest) (
+	*pb.MirrorReply, error) {
+
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if duration <= 0 || duration > maxMirrorDuration {
+		return nil, fmt.Errorf("the mirror duration must be between 1s and %s",
+			maxMirrorDuration)
+	}
+
+	if req.Host == "" && s.runningOnDaemon {
+		return nil, errors.New("the host of the capture container is required")
+	} else if req.Host != "" && !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	if req.Host == "" {
+		// Minions only let the daemon mirror their containers' traffic.
+		if err := tls.VerifyDaemon(ctx); err != nil {
+			return nil, err
+		}
+
+		err := mirrorLocal(ctx, s.conn, req.From, req.To, req.Capture, duration)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.MirrorReply{}, nil
+	}
+
+	clnt, err := newClient(api.RemoteAddress(req.Host), s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+	defer clnt.Close()
+
+	// Closing the client cancels the forwarded request, and so removes the
+	// mirror, if the request to the daemon is cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			clnt.Close()
+		case <-done:
+		}
+	}()
+
+	err = clnt.MirrorEndpoint("", req.From, req.To, req.Capture, duration)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MirrorReply{}, nil
+}
@@ -0,0 +1,224 @@
+// +build !windows
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/ipdef"
+	"github.com/kelda/kelda/minion/ovsdb"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Mirrors record when they expire, so that mirrors left behind by a minion that
+// crashed can be told apart from those that are still in use.
+const mirrorExpiresKey = "quilt-expires"
+
+// How often minions remove the mirrors that expired.
+const mirrorSweepInterval = time.Minute
+
+// Creates the client with which minions configure their mirrors.  Mocked out by
+// the unit tests.
+var newOvsdb = ovsdb.Open
+
+// mirrorLocal mirrors all of the traffic of the local end of the connection from
+// `from` to `to` to the container with hostname `capture` until `duration`
+// elapses, or `ctx` is cancelled.
+func mirrorLocal(ctx context.Context, conn db.Conn, from, to, capture string,
+	duration time.Duration) error {
+
+	mirrored, captureDbc, err := mirrorContainers(conn, from, to, capture)
+	if err != nil {
+		return err
+	}
+
+	odb, err := newOvsdb()
+	if err != nil {
+		return fmt.Errorf("ovsdb: %s", err)
+	}
+	defer odb.Disconnect()
+
+	// Each capture container receives the traffic of at most one mirror.
+	name := "quilt-mirror-" + capture
+	old, ok, err := findMirror(odb, name)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if !mirrorExpired(old, time.Now()) {
+			return fmt.Errorf("%s is already capturing a connection", capture)
+		}
+
+		if err := odb.DeleteMirror(old); err != nil {
+			return err
+		}
+	}
+
+	expires := time.Now().Add(duration)
+	err = odb.CreateMirror(ipdef.QuiltBridge, ovsdb.Mirror{
+		Name:   name,
+		Ports:  []string{ipdef.IFName(mirrored.EndpointID)},
+		Output: ipdef.IFName(captureDbc.EndpointID),
+		ExternalIDs: map[string]string{
+			mirrorExpiresKey: strconv.FormatInt(expires.Unix(), 10),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	logger := log.WithFields(log.Fields{
+		"container": mirrored.Hostname,
+		"capture":   capture,
+	})
+	logger.Info("Start mirroring traffic")
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(duration):
+	}
+
+	logger.Info("Stop mirroring traffic")
+	mirror, ok, err := findMirror(odb, name)
+	if err != nil || !ok {
+		return err
+	}
+	return odb.DeleteMirror(mirror)
+}
+
+// sweepMirrors periodically removes the mirrors that expired.  Mirrors are
+// normally removed by the request that created them, so these were left behind
+// by a minion that crashed while mirroring, and would otherwise keep burdening the
+// worker until their capture container is reused.
+func sweepMirrors() {
+	for range time.Tick(mirrorSweepInterval) {
+		if err := sweepMirrorsOnce(time.Now()); err != nil {
+			log.WithError(err).Warn("Failed to remove expired mirrors.")
+		}
+	}
+}
+
+func sweepMirrorsOnce(now time.Time) error {
+	odb, err := newOvsdb()
+	if err != nil {
+		return fmt.Errorf("ovsdb: %s", err)
+	}
+	defer odb.Disconnect()
+
+	mirrors, err := odb.ListMirrors()
+	if err != nil {
+		return err
+	}
+
+	for _, mirror := range mirrors {
+		if !mirrorExpired(mirror, now) {
+			continue
+		}
+
+		log.WithField("mirror", mirror.Name).Info("Remove expired mirror")
+		if err := odb.DeleteMirror(mirror); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mirrorExpired returns whether `mirror` was created by Quilt, and expired before
+// `now`.
+func mirrorExpired(mirror ovsdb.Mirror, now time.Time) bool {
+	expiresStr, ok := mirror.ExternalIDs[mirrorExpiresKey]
+	if !ok {
+		return false
+	}
+
+	expires, _ := strconv.ParseInt(expiresStr, 10, 64)
+	return !now.Before(time.Unix(expires, 0))
+}
+
+// mirrorContainers returns the end of the connection from `from` to `to` that
+// runs on this machine, and the container with hostname `capture`, which must also
+// run on this machine.
+func mirrorContainers(conn db.Conn, from, to, capture string) (
+	mirrored, captureDbc db.Container, err error) {
+
+	err = conn.Txn(db.ConnectionTable, db.ContainerTable,
+		db.MinionTable).Run(func(view db.Database) error {
+
+		var conns []db.Connection
+		err := view.SelectFromQuery(db.Where("From", db.Equal, from).
+			Where("To", db.Equal, to), &conns)
+		if err != nil {
+			return err
+		}
+
+		if len(conns) == 0 {
+			return fmt.Errorf("no connection from %s to %s", from, to)
+		}
+
+		var self []db.Minion
+		err = view.SelectFromQuery(db.Where("Self", db.Equal, true), &self)
+		if err != nil {
+			return err
+		}
+
+		if len(self) == 0 {
+			return errors.New("minion not yet initialized")
+		}
+
+		var running []db.Container
+		err = view.SelectFromQuery(
+			db.Where("Minion", db.Equal, self[0].PrivateIP).
+				Where("EndpointID", db.NotEqual, ""), &running)
+		if err != nil {
+			return err
+		}
+
+		local := map[string]db.Container{}
+		for _, dbc := range running {
+			local[dbc.Hostname] = dbc
+		}
+
+		var ok bool
+		if captureDbc, ok = local[capture]; !ok {
+			return fmt.Errorf("capture container %s isn't running on this "+
+				"machine", capture)
+		}
+
+		if from == capture || to == capture {
+			return errors.New("the capture container can't be an end of " +
+				"the connection")
+		}
+
+		if mirrored, ok = local[from]; ok {
+			return nil
+		}
+		if mirrored, ok = local[to]; ok {
+			return nil
+		}
+		return fmt.Errorf("neither %s nor %s is running on the same machine "+
+			"as %s", from, to, capture)
+	})
+	return mirrored, captureDbc, err
+}
+
+// findMirror returns the mirror called `name`, if there is one.
+func findMirror(odb ovsdb.Client, name string) (ovsdb.Mirror, bool, error) {
+	mirrors, err := odb.ListMirrors()
+	if err != nil {
+		return ovsdb.Mirror{}, false, err
+	}
+
+	for _, mirror := range mirrors {
+		if mirror.Name == name {
+			return mirror, true, nil
+		}
+	}
+	return ovsdb.Mirror{}, false, nil
+}
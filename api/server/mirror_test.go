@@ -0,0 +1,214 @@
+// +build !windows
+
+package server
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/client"
+	clientMocks "github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/ipdef"
+	"github.com/kelda/kelda/minion/ovsdb"
+	ovsdbMocks "github.com/kelda/kelda/minion/ovsdb/mocks"
+)
+
+func TestMirrorErrors(t *testing.T) {
+	ctx := context.Background()
+	_, err := server{}.MirrorEndpoint(ctx, &pb.MirrorRequest{})
+	assert.EqualError(t, err,
+		"the mirror duration must be between 1s and 1h0m0s")
+
+	_, err = server{}.MirrorEndpoint(ctx,
+		&pb.MirrorRequest{DurationSeconds: 2 * 60 * 60})
+	assert.EqualError(t, err,
+		"the mirror duration must be between 1s and 1h0m0s")
+
+	_, err = server{}.MirrorEndpoint(ctx,
+		&pb.MirrorRequest{Host: "host", DurationSeconds: 1})
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	_, err = server{runningOnDaemon: true}.MirrorEndpoint(ctx,
+		&pb.MirrorRequest{DurationSeconds: 1})
+	assert.EqualError(t, err, "the host of the capture container is required")
+
+	// Minions only accept requests from the daemon.
+	_, err = server{}.MirrorEndpoint(ctx, &pb.MirrorRequest{DurationSeconds: 1})
+	assert.EqualError(t, err, "unknown peer")
+}
+
+func TestMirrorContainers(t *testing.T) {
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		self := view.InsertMinion()
+		self.Self = true
+		self.PrivateIP = "10.0.0.1"
+		view.Commit(self)
+
+		for _, dbc := range []db.Container{
+			{Hostname: "web", Minion: "10.0.0.2", EndpointID: "web-ep"},
+			{Hostname: "db", Minion: "10.0.0.1", EndpointID: "db-ep"},
+			{Hostname: "tcpdump", Minion: "10.0.0.1", EndpointID: "tcpdump-ep"},
+			{Hostname: "remote", Minion: "10.0.0.2", EndpointID: "remote-ep"},
+		} {
+			inserted := view.InsertContainer()
+			dbc.ID = inserted.ID
+			view.Commit(dbc)
+		}
+
+		for _, c := range []db.Connection{
+			{From: "web", To: "db", MinPort: 5432, MaxPort: 5432},
+			{From: "web", To: "remote", MinPort: 80, MaxPort: 80},
+			{From: "web", To: "tcpdump", MinPort: 80, MaxPort: 80},
+		} {
+			inserted := view.InsertConnection()
+			c.ID = inserted.ID
+			view.Commit(c)
+		}
+		return nil
+	})
+
+	mirrored, capture, err := mirrorContainers(conn, "web", "db", "tcpdump")
+	assert.NoError(t, err)
+	assert.Equal(t, "db", mirrored.Hostname)
+	assert.Equal(t, "tcpdump", capture.Hostname)
+
+	_, _, err = mirrorContainers(conn, "db", "web", "tcpdump")
+	assert.EqualError(t, err, "no connection from db to web")
+
+	_, _, err = mirrorContainers(conn, "web", "db", "remote")
+	assert.EqualError(t, err, "capture container remote isn't running on this "+
+		"machine")
+
+	_, _, err = mirrorContainers(conn, "web", "remote", "tcpdump")
+	assert.EqualError(t, err, "neither web nor remote is running on the same "+
+		"machine as tcpdump")
+
+	_, _, err = mirrorContainers(conn, "web", "tcpdump", "tcpdump")
+	assert.EqualError(t, err, "the capture container can't be an end of the "+
+		"connection")
+}
+
+func TestMirrorLocal(t *testing.T) {
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		self := view.InsertMinion()
+		self.Self = true
+		self.PrivateIP = "10.0.0.1"
+		view.Commit(self)
+
+		for _, dbc := range []db.Container{
+			{Hostname: "web", Minion: "10.0.0.1", EndpointID: "web-ep"},
+			{Hostname: "tcpdump", Minion: "10.0.0.1", EndpointID: "tcpdump-ep"},
+		} {
+			inserted := view.InsertContainer()
+			dbc.ID = inserted.ID
+			view.Commit(dbc)
+		}
+
+		c := view.InsertConnection()
+		c.From = "web"
+		c.To = "db"
+		view.Commit(c)
+		return nil
+	})
+
+	odb := new(ovsdbMocks.Client)
+	newOvsdb = func() (ovsdb.Client, error) { return odb, nil }
+	odb.On("Disconnect").Return()
+
+	mirror := ovsdb.Mirror{Name: "quilt-mirror-tcpdump"}
+	expired := mirror
+	expired.ExternalIDs = map[string]string{mirrorExpiresKey: "1"}
+	active := mirror
+	active.ExternalIDs = map[string]string{mirrorExpiresKey: strconv.FormatInt(
+		time.Now().Add(time.Hour).Unix(), 10)}
+
+	// Captures that are already in use are refused.
+	odb.On("ListMirrors").Return([]ovsdb.Mirror{active}, nil).Once()
+	err := mirrorLocal(context.Background(), conn, "web", "db", "tcpdump",
+		time.Minute)
+	assert.EqualError(t, err, "tcpdump is already capturing a connection")
+
+	// Expired mirrors are replaced, and mirrors are removed once the request
+	// is cancelled.
+	odb.On("ListMirrors").Return([]ovsdb.Mirror{expired}, nil).Once()
+	odb.On("DeleteMirror", expired).Return(nil).Once()
+	odb.On("CreateMirror", ipdef.QuiltBridge, mock.Anything).Return(nil).Once()
+	odb.On("ListMirrors").Return([]ovsdb.Mirror{active}, nil).Once()
+	odb.On("DeleteMirror", active).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = mirrorLocal(ctx, conn, "web", "db", "tcpdump", time.Minute)
+	assert.NoError(t, err)
+	odb.AssertExpectations(t)
+
+	var created ovsdb.Mirror
+	for _, call := range odb.Calls {
+		if call.Method == "CreateMirror" {
+			created = call.Arguments.Get(1).(ovsdb.Mirror)
+		}
+	}
+	assert.Equal(t, "quilt-mirror-tcpdump", created.Name)
+	assert.Equal(t, []string{"web-ep"}, created.Ports)
+	assert.Equal(t, "tcpdump-ep", created.Output)
+	assert.Contains(t, created.ExternalIDs, mirrorExpiresKey)
+}
+
+func TestSweepMirrors(t *testing.T) {
+	odb := new(ovsdbMocks.Client)
+	newOvsdb = func() (ovsdb.Client, error) { return odb, nil }
+	odb.On("Disconnect").Return()
+
+	now := time.Unix(100, 0)
+	expired := ovsdb.Mirror{Name: "quilt-mirror-a",
+		ExternalIDs: map[string]string{mirrorExpiresKey: "100"}}
+	active := ovsdb.Mirror{Name: "quilt-mirror-b",
+		ExternalIDs: map[string]string{mirrorExpiresKey: "101"}}
+
+	// Mirrors that weren't created by Quilt are left alone.
+	other := ovsdb.Mirror{Name: "other"}
+
+	odb.On("ListMirrors").Return([]ovsdb.Mirror{expired, active, other},
+		nil).Once()
+	odb.On("DeleteMirror", expired).Return(nil).Once()
+	assert.NoError(t, sweepMirrorsOnce(now))
+	odb.AssertExpectations(t)
+
+	odb.On("ListMirrors").Return([]ovsdb.Mirror{expired}, nil).Once()
+	odb.On("DeleteMirror", expired).Return(assert.AnError).Once()
+	assert.Equal(t, assert.AnError, sweepMirrorsOnce(now))
+
+	odb.On("ListMirrors").Return(nil, assert.AnError).Once()
+	assert.Equal(t, assert.AnError, sweepMirrorsOnce(now))
+}
+
+func TestMirrorDaemon(t *testing.T) {
+	mc := new(clientMocks.Client)
+	mc.On("MirrorEndpoint", "", "web", "db", "tcpdump",
+		90*time.Second).Return(nil)
+	mc.On("Close").Return(nil)
+
+	var dialed string
+	newClient = func(host string, _ connection.Credentials) (client.Client, error) {
+		dialed = host
+		return mc, nil
+	}
+
+	_, err := server{runningOnDaemon: true}.MirrorEndpoint(
+		context.Background(), &pb.MirrorRequest{Host: "9.9.9.9", From: "web",
+			To: "db", Capture: "tcpdump", DurationSeconds: 90})
+	assert.NoError(t, err)
+	assert.Equal(t, api.RemoteAddress("9.9.9.9"), dialed)
+	mc.AssertExpectations(t)
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// Minions don't run on Windows, so traffic is never mirrored locally.
+func mirrorLocal(ctx context.Context, conn db.Conn, from, to, capture string,
+	duration time.Duration) error {
+	return errors.New("mirroring is not supported on Windows")
+}
+
+// Minions don't run on Windows, so there are never any mirrors to remove.
+func sweepMirrors() {}
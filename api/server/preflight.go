@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/util"
+)
+
+// Mirror is the registry from which machines without internet access pull
+// images.  If it's set, blueprints are only deployed if their images are in the
+// mirror, as the machines would fail to pull them otherwise.  Set by the daemon.
+var Mirror string
+
+var registryClient = &http.Client{Timeout: 10 * time.Second}
+
+// The manifest formats that the mirror may store images in.
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v1+prettyjws",
+}
+
+// checkMirror returns an error listing the images of `containers` that aren't in
+// `mirror`.  Images built from Dockerfiles are built within the cluster, so they
+// aren't checked.
+func checkMirror(mirror string, containers []blueprint.Container) error {
+	checked := map[string]struct{}{}
+	var missing []string
+	for _, c := range containers {
+		image := c.Image.Name
+		if _, ok := checked[image]; ok || c.Image.Dockerfile != "" {
+			continue
+		}
+		checked[image] = struct{}{}
+
+		exists, err := imageExists(util.MirrorImage(mirror, image))
+		if err != nil {
+			return fmt.Errorf("failed to check mirror for image %s: %s",
+				image, err)
+		}
+
+		if !exists {
+			missing = append(missing, image)
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("images missing from mirror %s: %s", mirror,
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// imageExists queries the registry hosting `image` for its manifest.  Like
+// Docker, it tries HTTPS before falling back to plain HTTP.
+func imageExists(image string) (bool, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false, err
+	}
+
+	ref := "latest"
+	if digested, ok := named.(reference.Digested); ok {
+		ref = digested.Digest().String()
+	} else if tagged, ok := named.(reference.Tagged); ok {
+		ref = tagged.Tag()
+	}
+
+	var lastErr error
+	for _, scheme := range []string{"https", "http"} {
+		url := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme,
+			reference.Domain(named), reference.Path(named), ref)
+		req, err := http.NewRequest("HEAD", url, nil)
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+
+		resp, err := registryClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return true, nil
+		case http.StatusNotFound:
+			return false, nil
+		default:
+			return false, fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+	}
+	return false, lastErr
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+)
+
+func TestCheckMirror(t *testing.T) {
+	var requests []string
+	mirror := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "HEAD", r.Method)
+			requests = append(requests, r.URL.Path)
+			switch r.URL.Path {
+			case "/v2/library/nginx/manifests/1.13",
+				"/v2/quay.io/coreos/etcd/manifests/latest":
+			case "/v2/library/private/manifests/latest":
+				w.WriteHeader(http.StatusUnauthorized)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer mirror.Close()
+	mirrorHost := strings.TrimPrefix(mirror.URL, "http://")
+
+	containers := func(images ...blueprint.Image) (res []blueprint.Container) {
+		for _, image := range images {
+			res = append(res, blueprint.Container{Image: image})
+		}
+		return res
+	}
+
+	assert.NoError(t, checkMirror(mirrorHost, containers(
+		blueprint.Image{Name: "nginx:1.13"},
+		blueprint.Image{Name: "nginx:1.13"},
+		blueprint.Image{Name: "quay.io/coreos/etcd"},
+		blueprint.Image{Name: "custom", Dockerfile: "FROM nginx"})))
+
+	// Duplicate images, and images built from Dockerfiles, aren't checked.
+	assert.Equal(t, []string{"/v2/library/nginx/manifests/1.13",
+		"/v2/quay.io/coreos/etcd/manifests/latest"}, requests)
+
+	err := checkMirror(mirrorHost, containers(
+		blueprint.Image{Name: "nginx:1.13"},
+		blueprint.Image{Name: "nginx:latest"},
+		blueprint.Image{Name: "redis"}))
+	assert.EqualError(t, err, "images missing from mirror "+mirrorHost+
+		": nginx:latest, redis")
+
+	err = checkMirror(mirrorHost, containers(blueprint.Image{Name: "private"}))
+	assert.EqualError(t, err, "failed to check mirror for image private: "+
+		"unexpected status: 401 Unauthorized")
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/cloud"
+
+	"golang.org/x/net/context"
+)
+
+// QueryResources lists the provider resources of the namespace, so that they may
+// be audited or cleaned up.
+func (s server) QueryResources(ctx context.Context, req *pb.ResourcesRequest) (
+	*pb.ResourcesReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	resources, errs := listResources()
+
+	reply := &pb.ResourcesReply{}
+	for _, res := range resources {
+		pbRes := &pb.Resource{
+			Provider: string(res.Provider),
+			Region:   res.Region,
+			Type:     res.Type,
+			ID:       res.ID,
+			Name:     res.Name,
+			Tags:     res.Tags,
+		}
+		if !res.Created.IsZero() {
+			pbRes.Created = res.Created.Unix()
+		}
+		reply.Resources = append(reply.Resources, pbRes)
+	}
+
+	for _, err := range errs {
+		reply.Errors = append(reply.Errors, err.Error())
+	}
+	return reply, nil
+}
+
+var listResources = cloud.Resources
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/cloud"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+)
+
+func TestQueryResources(t *testing.T) {
+	listResources = func() ([]cloud.Resource, []error) {
+		return []cloud.Resource{
+			{
+				Resource: resource.Resource{
+					Type:    resource.Instance,
+					ID:      "i-1",
+					Name:    "worker-1",
+					Tags:    map[string]string{"namespace": "ns"},
+					Created: time.Unix(1500000000, 0),
+				},
+				Provider: db.Amazon,
+				Region:   "us-west-1",
+			},
+			{
+				Resource: resource.Resource{Type: resource.SecurityGroup,
+					ID: "sg-1"},
+				Provider: db.Amazon,
+				Region:   "us-west-1",
+			},
+		}, []error{errors.New("Google-us-east1-b-ns: unauthorized")}
+	}
+
+	_, err := server{}.QueryResources(nil, &pb.ResourcesRequest{})
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	reply, err := server{runningOnDaemon: true}.QueryResources(nil,
+		&pb.ResourcesRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.ResourcesReply{
+		Resources: []*pb.Resource{
+			{
+				Provider: "Amazon",
+				Region:   "us-west-1",
+				Type:     resource.Instance,
+				ID:       "i-1",
+				Name:     "worker-1",
+				Tags:     map[string]string{"namespace": "ns"},
+				Created:  1500000000,
+			},
+			{
+				Provider: "Amazon",
+				Region:   "us-west-1",
+				Type:     resource.SecurityGroup,
+				ID:       "sg-1",
+			},
+		},
+		Errors: []string{"Google-us-east1-b-ns: unauthorized"},
+	}, reply)
+}
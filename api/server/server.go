@@ -4,22 +4,31 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/kelda/kelda/api"
 	"github.com/kelda/kelda/api/client"
 	"github.com/kelda/kelda/api/pb"
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/health"
+	"github.com/kelda/kelda/recorder"
+	"github.com/kelda/kelda/util"
 	"github.com/kelda/kelda/version"
 
 	"github.com/docker/distribution/reference"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -39,6 +48,13 @@ type server struct {
 	clientCreds connection.Credentials
 }
 
+// A Listener is an address that the API server listens on, along with the
+// credentials that clients connecting to the address must use.
+type Listener struct {
+	Address string
+	Creds   connection.Credentials
+}
+
 // Run starts a server that responds to connections from the CLI. It runs on both
 // the daemon and on the minion. The server provides various client-relevant
 // methods, such as starting deployments, and querying the state of the system.
@@ -46,35 +62,106 @@ type server struct {
 // the actual deployment.
 func Run(conn db.Conn, listenAddr string, runningOnDaemon bool,
 	creds connection.Credentials) error {
-	proto, addr, err := api.ParseListenAddress(listenAddr)
-	if err != nil {
-		return err
+	return RunListeners(conn, []Listener{{listenAddr, creds}}, runningOnDaemon,
+		creds)
+}
+
+// RunListeners is like Run, except that the server listens on each of
+// `listeners` simultaneously.  `clientCreds` are used when connecting to the
+// cluster.
+func RunListeners(conn db.Conn, listeners []Listener, runningOnDaemon bool,
+	clientCreds connection.Credentials) error {
+
+	type address struct{ proto, addr string }
+	var addrs []address
+	for _, l := range listeners {
+		proto, addr, err := api.ParseListenAddress(l.Address)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, address{proto, addr})
 	}
 
-	sock, s := connection.Server(proto, addr, creds.ServerOpts())
+	apiServer := server{conn, runningOnDaemon, clientCreds}
+	intercept := newInterceptor()
+	interceptOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(intercept.unary),
+		grpc.StreamInterceptor(intercept.stream),
+	}
 
-	// Cleanup the socket if we're interrupted.
-	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGHUP)
-	go func(c chan os.Signal) {
-		sig := <-c
-		log.Printf("Caught signal %s: shutting down.\n", sig)
-		sock.Close()
-		os.Exit(0)
-	}(sigc)
+	var socks []net.Listener
+	var servers []*grpc.Server
+	for i, l := range listeners {
+		sock, s := connection.Server(addrs[i].proto, addrs[i].addr,
+			append(l.Creds.ServerOpts(), interceptOpts...))
+		pb.RegisterAPIServer(s, apiServer)
+		health.RegisterServer(s)
+		socks = append(socks, sock)
+		servers = append(servers, s)
+	}
 
-	apiServer := server{conn, runningOnDaemon, creds}
-	pb.RegisterAPIServer(s, apiServer)
-	s.Serve(sock)
+	running.Lock()
+	running.servers = append(running.servers, servers...)
+	running.Unlock()
+
+	// The daemon shuts down gracefully on its own, and calls Stop when it
+	// does.  Otherwise, cleanup the sockets if we're interrupted.
+	if !runningOnDaemon {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, os.Interrupt, os.Kill, syscall.SIGTERM,
+			syscall.SIGHUP)
+		go func(c chan os.Signal) {
+			sig := <-c
+			log.Printf("Caught signal %s: shutting down.\n", sig)
+			for _, sock := range socks {
+				sock.Close()
+			}
+			os.Exit(0)
+		}(sigc)
+
+		// Minions remove the mirrors that outlived the requests that
+		// created them.
+		go sweepMirrors()
+	}
+
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(s *grpc.Server, sock net.Listener) {
+			defer wg.Done()
+			s.Serve(sock)
+		}(servers[i], socks[i])
+	}
+	wg.Wait()
 
 	return nil
 }
 
+// The servers started by RunListeners, so that Stop can close them.
+var running struct {
+	sync.Mutex
+	servers []*grpc.Server
+}
+
+// Stop closes the listeners of the servers started by RunListeners, and cancels
+// their in-progress requests.
+func Stop() {
+	running.Lock()
+	defer running.Unlock()
+
+	for _, s := range running.servers {
+		s.Stop()
+	}
+	running.servers = nil
+}
+
 // Query runs in two modes: daemon, or local. If in local mode, Query simply
 // returns the requested table from its local database. If in daemon mode,
 // Query proxies certain table requests (e.g. Container and Connection) to the
 // cluster. This is necessary because some tables are only used on the minions,
-// and aren't synced back to the daemon.
+// and aren't synced back to the daemon.  Queries that count rows by some of their
+// fields reply with the counts rather than the rows, so that summaries of large
+// tables don't require sending every row.
 func (s server) Query(cts context.Context, query *pb.DBQuery) (*pb.QueryReply, error) {
 	var rows interface{}
 	var err error
@@ -90,6 +177,15 @@ func (s server) Query(cts context.Context, query *pb.DBQuery) (*pb.QueryReply, e
 		return nil, err
 	}
 
+	if len(query.CountBy) > 0 {
+		return countQuery(QueryFromPB(query), rows, query.CountBy)
+	}
+
+	rows, err = applyQuery(QueryFromPB(query), rows)
+	if err != nil {
+		return nil, err
+	}
+
 	json, err := json.Marshal(rows)
 	if err != nil {
 		return nil, err
@@ -98,6 +194,46 @@ func (s server) Query(cts context.Context, query *pb.DBQuery) (*pb.QueryReply, e
 	return &pb.QueryReply{TableContents: string(json)}, nil
 }
 
+// QueryFromPB converts the filters of `query` into a db.Query.
+func QueryFromPB(query *pb.DBQuery) db.Query {
+	q := db.Query{Order: query.OrderBy, Max: int(query.Limit)}
+	for _, f := range query.Filters {
+		q.Filters = append(q.Filters, db.Filter{
+			Field: f.Field, Op: f.Op, Value: f.Value})
+	}
+	return q
+}
+
+// applyQuery evaluates `q` on `rows`, a slice of database rows.
+func applyQuery(q db.Query, rows interface{}) (interface{}, error) {
+	ptr := reflect.New(reflect.TypeOf(rows))
+	ptr.Elem().Set(reflect.ValueOf(rows))
+	if err := q.Apply(ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+// countQuery counts the rows of `rows`, a slice of database rows, that match `q`
+// by the values of `fields`.
+func countQuery(q db.Query, rows interface{}, fields []string) (
+	*pb.QueryReply, error) {
+
+	ptr := reflect.New(reflect.TypeOf(rows))
+	ptr.Elem().Set(reflect.ValueOf(rows))
+	counts, err := q.Count(ptr.Interface(), fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &pb.QueryReply{}
+	for _, count := range counts {
+		reply.Counts = append(reply.Counts, &pb.RowCount{
+			Values: count.Values, Count: int32(count.Count)})
+	}
+	return reply, nil
+}
+
 func (s server) queryLocal(table db.TableType) (interface{}, error) {
 	switch table {
 	case db.MachineTable:
@@ -114,6 +250,8 @@ func (s server) queryLocal(table db.TableType) (interface{}, error) {
 		return s.conn.SelectFromBlueprint(nil), nil
 	case db.ImageTable:
 		return s.conn.SelectFromImage(nil), nil
+	case db.SettingsTable:
+		return s.conn.SelectFromSettings(nil), nil
 	default:
 		return nil, fmt.Errorf("unrecognized table: %s", table)
 	}
@@ -123,7 +261,7 @@ func (s server) queryFromDaemon(table db.TableType) (
 	interface{}, error) {
 
 	switch table {
-	case db.MachineTable, db.BlueprintTable:
+	case db.MachineTable, db.BlueprintTable, db.SettingsTable:
 		return s.queryLocal(table)
 	}
 
@@ -176,23 +314,186 @@ func (s server) QueryCounters(ctx context.Context, in *pb.CountersRequest) (
 	return &pb.CountersReply{Counters: counter.Dump()}, nil
 }
 
-func (s server) Deploy(cts context.Context, deployReq *pb.DeployRequest) (
-	*pb.DeployReply, error) {
+func (s server) QueryDecisions(ctx context.Context, in *pb.DecisionsRequest) (
+	*pb.DecisionsReply, error) {
+	if in.Host == "" {
+		return &pb.DecisionsReply{Decisions: recorder.Dump()}, nil
+	}
 
 	if !s.runningOnDaemon {
 		return nil, errDaemonOnlyRPC
 	}
 
-	newBlueprint, err := blueprint.FromJSON(deployReq.Deployment)
+	clnt, err := newClient(api.RemoteAddress(in.Host), s.clientCreds)
 	if err != nil {
-		return &pb.DeployReply{}, err
+		return nil, err
 	}
 
-	for _, c := range newBlueprint.Containers {
-		if _, err := reference.ParseAnyReference(c.Image.Name); err != nil {
-			return &pb.DeployReply{}, fmt.Errorf("could not parse "+
-				"container image %s: %s", c.Image.Name, err.Error())
+	decisions, err := clnt.QueryDecisions("")
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &pb.DecisionsReply{}
+	for i := range decisions {
+		reply.Decisions = append(reply.Decisions, &decisions[i])
+	}
+	return reply, nil
+}
+
+func (s server) QueryJoinScores(ctx context.Context, in *pb.JoinScoresRequest) (
+	*pb.JoinScoresReply, error) {
+	if in.Host == "" {
+		return &pb.JoinScoresReply{Joins: recorder.DumpScores()}, nil
+	}
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	clnt, err := newClient(api.RemoteAddress(in.Host), s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+
+	joins, err := clnt.QueryJoinScores("")
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &pb.JoinScoresReply{}
+	for i := range joins {
+		reply.Joins = append(reply.Joins, &joins[i])
+	}
+	return reply, nil
+}
+
+func (s server) SetLogLevels(ctx context.Context, in *pb.LogLevelsRequest) (
+	*pb.LogLevelsReply, error) {
+	if in.Host == "" {
+		// Minions only let the daemon change their log levels.
+		if !s.runningOnDaemon {
+			if err := tls.VerifyDaemon(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := setLogLevels(in.Levels); err != nil {
+			return nil, err
+		}
+
+		reply := &pb.LogLevelsReply{Levels: map[string]string{}}
+		for module, level := range util.ModuleLogLevels() {
+			reply.Levels[module] = level.String()
 		}
+		return reply, nil
+	}
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	clnt, err := newClient(api.RemoteAddress(in.Host), s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+
+	levels, err := clnt.SetLogLevels("", in.Levels)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LogLevelsReply{Levels: levels}, nil
+}
+
+// setLogLevels sets the log level of each module in `levels`.  No levels are
+// changed if any of them are invalid.
+func setLogLevels(levels map[string]string) error {
+	known := map[string]bool{}
+	for _, module := range util.LogModules() {
+		known[module] = true
+	}
+
+	parsed := map[string]log.Level{}
+	for module, level := range levels {
+		if !known[module] {
+			return fmt.Errorf("unknown module: %s", module)
+		}
+
+		if level == "default" {
+			continue
+		}
+
+		var err error
+		if parsed[module], err = log.ParseLevel(level); err != nil {
+			return err
+		}
+	}
+
+	for module, level := range levels {
+		if level == "default" {
+			util.ResetModuleLogLevel(module)
+		} else {
+			util.SetModuleLogLevel(module, parsed[module])
+		}
+	}
+	return nil
+}
+
+func (s server) UpdateSettings(ctx context.Context,
+	in *pb.UpdateSettingsRequest) (*pb.UpdateSettingsReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	if in.Namespace == "" {
+		return nil, errors.New("namespace must be specified")
+	}
+
+	for name := range in.Flags {
+		if err := db.ValidateSetting(name); err != nil {
+			return nil, err
+		}
+	}
+
+	s.conn.Txn(db.SettingsTable).Run(func(view db.Database) error {
+		settings := view.GetSettings(in.Namespace)
+		if settings.ID == 0 {
+			settings = view.InsertSettings()
+			settings.Namespace = in.Namespace
+		}
+
+		// Rows may share the map with readers, so it's copied rather than
+		// modified in place.
+		flags := map[string]bool{}
+		for name, value := range settings.Flags {
+			flags[name] = value
+		}
+		for name, value := range in.Flags {
+			flags[name] = value
+		}
+		settings.Flags = flags
+		view.Commit(settings)
+		return nil
+	})
+	return &pb.UpdateSettingsReply{}, nil
+}
+
+// Deploy deploys the requested blueprint, and replies once it's accepted.  If the
+// request asks to wait, the server continues to stream the progress of the
+// deployment until it converges, or the client gives up.
+func (s server) Deploy(deployReq *pb.DeployRequest, stream pb.API_DeployServer) error {
+	if !s.runningOnDaemon {
+		return errDaemonOnlyRPC
+	}
+
+	newBlueprint, err := blueprint.FromJSON(deployReq.Deployment)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckBlueprint(newBlueprint); err != nil {
+		return err
 	}
 
 	err = s.conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
@@ -202,29 +503,196 @@ func (s server) Deploy(cts context.Context, deployReq *pb.DeployRequest) (
 		}
 
 		bp.Blueprint = newBlueprint
+		bp.ClientIP = clientIP(stream.Context())
+		bp.Commit = ""
 		view.Commit(bp)
 		return nil
 	})
 	if err != nil {
-		return &pb.DeployReply{}, err
+		return err
 	}
 
 	// XXX: Remove this error when the Vagrant provider is done.
 	for _, machine := range newBlueprint.Machines {
 		if machine.Provider == string(db.Vagrant) {
-			err = errors.New("The Vagrant provider is still in development." +
+			return errors.New("The Vagrant provider is still in development." +
 				" The blueprint will continue to run, but" +
 				" there may be some errors.")
-			return &pb.DeployReply{}, err
 		}
 	}
 
-	return &pb.DeployReply{}, nil
+	err = stream.Send(&pb.DeployReply{Stage: api.DeployAccepted})
+	if err != nil || !deployReq.Wait {
+		return err
+	}
+
+	trigger := s.conn.TriggerTick(deployPollInterval, db.MachineTable)
+	defer trigger.Stop()
+
+	var timeout <-chan time.Time
+	if deployReq.TimeoutSeconds > 0 {
+		timer := time.NewTimer(
+			time.Duration(deployReq.TimeoutSeconds) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var last pb.DeployReply
+	for {
+		progress := s.deployProgress(newBlueprint)
+		if !reflect.DeepEqual(progress, last) {
+			if err := stream.Send(&progress); err != nil {
+				return err
+			}
+			last = progress
+		}
+
+		if progress.Stage == api.DeployDone {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-timeout:
+			// Rather than failing, the daemon reports what kept the
+			// deployment from converging, so that clients such as CI
+			// pipelines needn't query it separately.
+			last.Stage = api.DeployTimedOut
+			last.Outstanding = s.outstanding(newBlueprint)
+			return stream.Send(&last)
+		case <-trigger.C:
+		}
+	}
+}
+
+// CheckBlueprint returns an error if `bp` can't be deployed, e.g. because it
+// refers to images that don't exist.
+func CheckBlueprint(bp blueprint.Blueprint) error {
+	for _, c := range bp.Containers {
+		if _, err := reference.ParseAnyReference(c.Image.Name); err != nil {
+			return fmt.Errorf("could not parse "+
+				"container image %s: %s", c.Image.Name, err.Error())
+		}
+	}
+
+	if Mirror != "" {
+		return checkMirror(Mirror, bp.Containers)
+	}
+	return nil
+}
+
+// outstanding returns the items that keep the cluster from implementing `bp`.
+func (s server) outstanding(bp blueprint.Blueprint) []*pb.OutstandingItem {
+	machines := s.conn.SelectFromMachine(nil)
+	return append(machineConvergence(machines),
+		s.clusterConvergence(bp, machines)...)
+}
+
+// How often, in seconds, the progress of a deployment is checked while waiting
+// for it to converge.  Container scheduling happens on the leader, so changes to
+// it don't trigger a check.
+const deployPollInterval = 5
+
+// deployProgress computes how far the cluster is from implementing `bp`.
+func (s server) deployProgress(bp blueprint.Blueprint) pb.DeployReply {
+	progress := pb.DeployReply{
+		MachinesTotal:   int32(len(bp.Machines)),
+		ContainersTotal: int32(len(bp.Containers)),
+	}
+
+	machines := s.conn.SelectFromMachine(nil)
+	for _, m := range machines {
+		if m.CloudID != "" {
+			progress.MachinesBooted++
+		}
+		if m.Status == db.Connected {
+			progress.MachinesConnected++
+		}
+	}
+
+	switch {
+	case progress.MachinesBooted < progress.MachinesTotal:
+		progress.Stage = api.DeployBooting
+		return progress
+	case progress.MachinesConnected < progress.MachinesTotal:
+		progress.Stage = api.DeployConnecting
+		return progress
+	}
+
+	progress.Stage = api.DeployScheduling
+	if progress.ContainersTotal > 0 {
+		containers, err := s.queryScheduledContainers(machines)
+		if err != nil {
+			log.WithError(err).Debug("Failed to query containers")
+			return progress
+		}
+
+		ids := map[string]struct{}{}
+		for _, c := range bp.Containers {
+			ids[c.ID] = struct{}{}
+		}
+		for _, c := range containers {
+			if _, ok := ids[c.BlueprintID]; ok {
+				progress.ContainersScheduled++
+			}
+		}
+	}
+
+	if progress.ContainersScheduled >= progress.ContainersTotal {
+		progress.Stage = api.DeployDone
+	}
+	return progress
+}
+
+// queryScheduledContainers returns the containers that the leader has assigned to
+// a minion.  Unlike the Container table query, the workers aren't consulted, as
+// only the leader knows about unscheduled containers.
+func (s server) queryScheduledContainers(machines []db.Machine) (
+	[]db.Container, error) {
+
+	leaderClient, err := newLeaderClient(machines, s.clientCreds)
+	if err != nil {
+		return nil, err
+	}
+	defer leaderClient.Close()
+
+	var scheduled []db.Container
+	err = leaderClient.Query(db.ContainerTable,
+		db.Where("Minion", db.NotEqual, ""), &scheduled)
+	return scheduled, err
 }
 
-func (s server) Version(_ context.Context, _ *pb.VersionRequest) (
+// clientIP returns the IP address of the client that made the request in `ctx`,
+// or the empty string if the client is on the same host.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	addr, ok := p.Addr.(*net.TCPAddr)
+	if !ok || addr.IP.IsLoopback() {
+		return ""
+	}
+	return addr.IP.String()
+}
+
+// Version reports the daemon's version, and refuses clients whose API version is
+// incompatible.
+func (s server) Version(_ context.Context, req *pb.VersionRequest) (
 	*pb.VersionReply, error) {
-	return &pb.VersionReply{Version: version.Version}, nil
+
+	err := version.CheckCompatible("client", req.APIVersion, req.MinAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.VersionReply{
+		Version:       version.Version,
+		APIVersion:    version.APIVersion,
+		MinAPIVersion: version.MinAPIVersion,
+	}, nil
 }
 
 func (s server) getClusterContainers(leaderClient client.Client) (interface{}, error) {
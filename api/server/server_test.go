@@ -1,12 +1,20 @@
 package server
 
 import (
+	cryptoTLS "crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"testing"
 	"time"
 
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 
 	"github.com/kelda/kelda/api"
 	"github.com/kelda/kelda/api/client"
@@ -14,8 +22,14 @@ import (
 	"github.com/kelda/kelda/api/pb"
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+	"github.com/kelda/kelda/version"
+
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func checkQuery(t *testing.T, s server, table db.TableType, exp string) {
@@ -62,12 +76,116 @@ func TestQueryMachinesDaemon(t *testing.T) {
 
 	exp := `[{"ID":1,"BlueprintID":"","Role":"Master","Provider":"Amazon",` +
 		`"Region":"","Size":"size","DiskSize":0,"SSHKeys":null,"FloatingIP":"",` +
-		`"Preemptible":false,"CloudID":"","PublicIP":"8.8.8.8",` +
-		`"PrivateIP":"9.9.9.9","Status":"connected"}]`
+		`"Preemptible":false,"MaxPrice":0,"Sysctls":null,"Hugepages":0,` +
+		`"Hooks":null,` +
+		`"Bootstrap":"","Hostname":"","Tags":null,"Labels":null,"VPC":"","Subnet":"","InstanceProfile":"","SecurityGroups":null,"AutoFloatingIP":false,"Zone":"","PlacementGroup":"","Image":"","VolumeType":"","IOPS":0,"DiskType":"","Volumes":null,"IPv6":false,` +
+		`"GPUs":0,"GPUType":"","Arch":"",` +
+		`"Host":"","SSHUser":"","SSHKeyPath":"","OnDemandFallback":false,` +
+		`"CloudID":"","PublicIP":"8.8.8.8",` +
+		`"PrivateIP":"9.9.9.9","PublicIPv6":"","Interrupted":false,"OnDemand":false,` +
+		`"Status":"connected","BootError":"",` +
+		`"ConfigGeneration":0,"AppliedGeneration":0,` +
+		`"ConfigError":"",` +
+		`"Action":"",` +
+		`"ActionRequested":"0001-01-01T00:00:00Z"}]`
 
 	checkQuery(t, server{conn, true, nil}, db.MachineTable, exp)
 }
 
+func TestQueryFilters(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, ip := range []string{"3", "1", "2"} {
+			m := view.InsertMachine()
+			m.Role = db.Worker
+			m.PrivateIP = ip
+			view.Commit(m)
+		}
+
+		m := view.InsertMachine()
+		m.Role = db.Master
+		m.PrivateIP = "0"
+		view.Commit(m)
+		return nil
+	})
+
+	s := server{conn: conn, runningOnDaemon: true}
+	reply, err := s.Query(context.Background(), &pb.DBQuery{
+		Table:   string(db.MachineTable),
+		Filters: []*pb.Filter{{Field: "Role", Op: "=", Value: "Worker"}},
+		OrderBy: "-PrivateIP",
+		Limit:   2,
+	})
+	assert.NoError(t, err)
+
+	var machines []db.Machine
+	assert.NoError(t, json.Unmarshal([]byte(reply.TableContents), &machines))
+	assert.Len(t, machines, 2)
+	assert.Equal(t, "3", machines[0].PrivateIP)
+	assert.Equal(t, "2", machines[1].PrivateIP)
+
+	_, err = s.Query(context.Background(), &pb.DBQuery{
+		Table:   string(db.MachineTable),
+		Filters: []*pb.Filter{{Field: "Bogus", Op: "=", Value: "Worker"}},
+	})
+	assert.EqualError(t, err, "unknown field: Bogus")
+}
+
+func TestQueryCount(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, status := range []string{db.Connected, db.Booting,
+			db.Connected} {
+			m := view.InsertMachine()
+			m.Role = db.Worker
+			m.Region = "us-west-1"
+			m.Status = status
+			view.Commit(m)
+		}
+
+		m := view.InsertMachine()
+		m.Role = db.Master
+		m.Region = "us-east-1"
+		m.Status = db.Connected
+		view.Commit(m)
+		return nil
+	})
+
+	s := server{conn: conn, runningOnDaemon: true}
+	reply, err := s.Query(context.Background(), &pb.DBQuery{
+		Table:   string(db.MachineTable),
+		CountBy: []string{"Region", "Status"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reply.TableContents)
+	assert.Equal(t, []*pb.RowCount{
+		{Values: []string{"us-east-1", db.Connected}, Count: 1},
+		{Values: []string{"us-west-1", db.Booting}, Count: 1},
+		{Values: []string{"us-west-1", db.Connected}, Count: 2},
+	}, reply.Counts)
+
+	reply, err = s.Query(context.Background(), &pb.DBQuery{
+		Table:   string(db.MachineTable),
+		Filters: []*pb.Filter{{Field: "Role", Op: "=", Value: "Worker"}},
+		CountBy: []string{"Status"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*pb.RowCount{
+		{Values: []string{db.Booting}, Count: 1},
+		{Values: []string{db.Connected}, Count: 2},
+	}, reply.Counts)
+
+	_, err = s.Query(context.Background(), &pb.DBQuery{
+		Table:   string(db.MachineTable),
+		CountBy: []string{"Bogus"},
+	})
+	assert.EqualError(t, err, "unknown field: Bogus")
+}
+
 func TestQueryContainersCluster(t *testing.T) {
 	t.Parallel()
 
@@ -143,8 +261,8 @@ func TestBadDeployment(t *testing.T) {
 
 	badDeployment := `{`
 
-	_, err := s.Deploy(context.Background(),
-		&pb.DeployRequest{Deployment: badDeployment})
+	err := s.Deploy(&pb.DeployRequest{Deployment: badDeployment},
+		&mockDeployServer{ctx: context.Background()})
 
 	assert.EqualError(t, err,
 		"unable to parse blueprint: unexpected end of JSON input")
@@ -178,8 +296,8 @@ func testInvalidImage(t *testing.T, s server, img, expErr string) {
                 "Env": {}
 	}]}`, img)
 
-	_, err := s.Deploy(context.Background(),
-		&pb.DeployRequest{Deployment: deployment})
+	err := s.Deploy(&pb.DeployRequest{Deployment: deployment},
+		&mockDeployServer{ctx: context.Background()})
 	assert.EqualError(t, err, expErr)
 }
 
@@ -197,10 +315,12 @@ func TestDeploy(t *testing.T) {
 		"Size":"m4.large"
 	}]}`
 
-	_, err := s.Deploy(context.Background(),
-		&pb.DeployRequest{Deployment: createMachineDeployment})
+	stream := &mockDeployServer{ctx: context.Background()}
+	err := s.Deploy(&pb.DeployRequest{Deployment: createMachineDeployment},
+		stream)
 
 	assert.NoError(t, err)
+	assert.Equal(t, []pb.DeployReply{{Stage: api.DeployAccepted}}, stream.sent)
 
 	var bp db.Blueprint
 	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
@@ -212,6 +332,169 @@ func TestDeploy(t *testing.T) {
 	exp, err := blueprint.FromJSON(createMachineDeployment)
 	assert.NoError(t, err)
 	assert.Equal(t, exp, bp.Blueprint)
+	assert.Empty(t, bp.ClientIP)
+}
+
+func TestDeployWait(t *testing.T) {
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		mc := new(mocks.Client)
+		mc.On("Query", db.ContainerTable, db.Where("Minion", db.NotEqual, ""),
+			mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(2).(*[]db.Container) = []db.Container{
+				{BlueprintID: "1", Minion: "worker"},
+			}
+		}).Return(nil)
+		mc.On("Close").Return(nil)
+		return mc, nil
+	}
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		view.Commit(view.InsertMachine())
+		return nil
+	})
+
+	// Each time progress is reported, the cluster converges a little more.
+	stream := &mockDeployServer{ctx: context.Background()}
+	stream.onSend = func(reply pb.DeployReply) {
+		conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			dbm := view.SelectFromMachine(nil)[0]
+			switch reply.Stage {
+			case api.DeployBooting:
+				dbm.CloudID = "cloud"
+			case api.DeployConnecting:
+				dbm.Status = db.Connected
+			}
+			view.Commit(dbm)
+			return nil
+		})
+	}
+
+	deployment := `{"Machines":[{"Provider":"Amazon","Role":"Worker"}],` +
+		`"Containers":[{"ID":"1","Image":{"Name":"image"}}]}`
+	err := s.Deploy(&pb.DeployRequest{Deployment: deployment, Wait: true}, stream)
+	assert.NoError(t, err)
+
+	progress := pb.DeployReply{MachinesTotal: 1, ContainersTotal: 1}
+	booting := progress
+	booting.Stage = api.DeployBooting
+	connecting := booting
+	connecting.Stage = api.DeployConnecting
+	connecting.MachinesBooted = 1
+	done := connecting
+	done.Stage = api.DeployDone
+	done.MachinesConnected = 1
+	done.ContainersScheduled = 1
+	assert.Equal(t, []pb.DeployReply{{Stage: api.DeployAccepted}, booting,
+		connecting, done}, stream.sent)
+
+	// The server stops waiting if the client gives up.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		dbm := view.SelectFromMachine(nil)[0]
+		dbm.Status = ""
+		view.Commit(dbm)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.Deploy(&pb.DeployRequest{Deployment: deployment, Wait: true},
+		&mockDeployServer{ctx: ctx})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestDeployWaitTimeout(t *testing.T) {
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		dbm := view.InsertMachine()
+		dbm.BlueprintID = "1"
+		dbm.CloudID = "cloud"
+		dbm.Status = db.Connecting
+		view.Commit(dbm)
+		return nil
+	})
+
+	// The machine never connects, so the daemon gives up and reports it.
+	stream := &mockDeployServer{ctx: context.Background()}
+	deployment := `{"Machines":[{"Provider":"Amazon","Role":"Worker"}]}`
+	err := s.Deploy(&pb.DeployRequest{Deployment: deployment, Wait: true,
+		TimeoutSeconds: 1}, stream)
+	assert.NoError(t, err)
+
+	connecting := pb.DeployReply{Stage: api.DeployConnecting, MachinesTotal: 1,
+		MachinesBooted: 1}
+	timedOut := connecting
+	timedOut.Stage = api.DeployTimedOut
+	timedOut.Outstanding = []*pb.OutstandingItem{{Kind: machineItem, ID: "1",
+		Reason: "not connected (connecting)"}}
+	assert.Equal(t, []pb.DeployReply{{Stage: api.DeployAccepted}, connecting,
+		timedOut}, stream.sent)
+}
+
+func TestDeployProgressScheduling(t *testing.T) {
+	newLeaderClient = func(_ []db.Machine, _ connection.Credentials) (
+		client.Client, error) {
+		return nil, errors.New("no leader")
+	}
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		dbm := view.InsertMachine()
+		dbm.CloudID = "cloud"
+		dbm.Status = db.Connected
+		view.Commit(dbm)
+		return nil
+	})
+
+	progress := s.deployProgress(blueprint.Blueprint{
+		Machines:   []blueprint.Machine{{}},
+		Containers: []blueprint.Container{{ID: "1"}},
+	})
+	assert.Equal(t, pb.DeployReply{
+		Stage:             api.DeployScheduling,
+		MachinesTotal:     1,
+		MachinesBooted:    1,
+		MachinesConnected: 1,
+		ContainersTotal:   1,
+	}, progress)
+}
+
+type mockDeployServer struct {
+	ctx    context.Context
+	sent   []pb.DeployReply
+	onSend func(pb.DeployReply)
+
+	grpc.ServerStream
+}
+
+func (s *mockDeployServer) Send(reply *pb.DeployReply) error {
+	s.sent = append(s.sent, *reply)
+	if s.onSend != nil {
+		s.onSend(*reply)
+	}
+	return nil
+}
+
+func (s *mockDeployServer) Context() context.Context {
+	return s.ctx
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, clientIP(context.Background()))
+
+	ctx := func(addr net.Addr) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	}
+	assert.Empty(t, clientIP(ctx(&net.UnixAddr{Name: "/tmp/quilt.sock"})))
+	assert.Empty(t, clientIP(ctx(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})))
+	assert.Equal(t, "8.8.8.8",
+		clientIP(ctx(&net.TCPAddr{IP: net.IPv4(8, 8, 8, 8), Port: 1234})))
 }
 
 func TestVagrantDeployment(t *testing.T) {
@@ -231,8 +514,8 @@ func TestVagrantDeployment(t *testing.T) {
 		" The blueprint will continue to run, but" +
 		" there may be some errors."
 
-	_, err := s.Deploy(context.Background(),
-		&pb.DeployRequest{Deployment: vagrantDeployment})
+	err := s.Deploy(&pb.DeployRequest{Deployment: vagrantDeployment},
+		&mockDeployServer{ctx: context.Background()})
 
 	assert.Error(t, err, vagrantErrMsg)
 
@@ -310,8 +593,99 @@ func TestDaemonOnlyEndpoints(t *testing.T) {
 	_, err := server{runningOnDaemon: false}.QueryMinionCounters(nil, nil)
 	assert.EqualError(t, err, errDaemonOnlyRPC.Error())
 
-	_, err = server{runningOnDaemon: false}.Deploy(nil, nil)
+	err = server{runningOnDaemon: false}.Deploy(nil, nil)
 	assert.EqualError(t, err, errDaemonOnlyRPC.Error())
+
+	_, err = server{runningOnDaemon: false}.QueryConvergence(nil, nil)
+	assert.EqualError(t, err, errDaemonOnlyRPC.Error())
+
+	_, err = server{runningOnDaemon: false}.QueryDecisions(nil,
+		&pb.DecisionsRequest{Host: "host"})
+	assert.EqualError(t, err, errDaemonOnlyRPC.Error())
+
+	// Local decisions are available on minions.
+	_, err = server{runningOnDaemon: false}.QueryDecisions(nil,
+		&pb.DecisionsRequest{})
+	assert.NoError(t, err)
+
+	_, err = server{runningOnDaemon: false}.QueryJoinScores(nil,
+		&pb.JoinScoresRequest{Host: "host"})
+	assert.EqualError(t, err, errDaemonOnlyRPC.Error())
+
+	_, err = server{runningOnDaemon: false}.QueryJoinScores(nil,
+		&pb.JoinScoresRequest{})
+	assert.NoError(t, err)
+
+	_, err = server{runningOnDaemon: false}.SetLogLevels(nil,
+		&pb.LogLevelsRequest{Host: "host"})
+	assert.EqualError(t, err, errDaemonOnlyRPC.Error())
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	s := server{}
+	reply, err := s.Version(nil, &pb.VersionRequest{
+		APIVersion:    version.APIVersion,
+		MinAPIVersion: version.MinAPIVersion,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, pb.VersionReply{
+		Version:       version.Version,
+		APIVersion:    version.APIVersion,
+		MinAPIVersion: version.MinAPIVersion,
+	}, *reply)
+
+	// Clients that require a newer API version are refused.
+	_, err = s.Version(nil, &pb.VersionRequest{
+		APIVersion:    version.APIVersion + 1,
+		MinAPIVersion: version.APIVersion + 1,
+	})
+	assert.Error(t, err)
+
+	// So are clients that predate version negotiation.
+	_, err = s.Version(nil, &pb.VersionRequest{})
+	assert.Error(t, err)
+}
+
+func TestSetLogLevels(t *testing.T) {
+	defer util.ResetModuleLogLevel("network")
+	defer util.ResetModuleLogLevel("cloud")
+
+	reply, err := server{}.SetLogLevels(daemonContext(), &pb.LogLevelsRequest{
+		Levels: map[string]string{"cloud": "debug", "network": "error"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "debug", reply.Levels["cloud"])
+	assert.Equal(t, "error", reply.Levels["network"])
+	assert.Equal(t, util.LogLevel().String(), reply.Levels["scheduler"])
+
+	reply, err = server{}.SetLogLevels(daemonContext(), &pb.LogLevelsRequest{
+		Levels: map[string]string{"network": "default"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "debug", reply.Levels["cloud"])
+	assert.Equal(t, util.LogLevel().String(), reply.Levels["network"])
+
+	// Invalid requests don't change any levels.
+	_, err = server{}.SetLogLevels(daemonContext(), &pb.LogLevelsRequest{
+		Levels: map[string]string{"cloud": "info", "ovs": "debug"}})
+	assert.EqualError(t, err, "unknown module: ovs")
+
+	_, err = server{}.SetLogLevels(daemonContext(), &pb.LogLevelsRequest{
+		Levels: map[string]string{"cloud": "info", "network": "loud"}})
+	assert.Error(t, err)
+	assert.Equal(t, log.DebugLevel, util.ModuleLogLevels()["cloud"])
+
+	// Minions only let the daemon change their log levels.
+	_, err = server{}.SetLogLevels(context.Background(), &pb.LogLevelsRequest{
+		Levels: map[string]string{"cloud": "info"}})
+	assert.EqualError(t, err, "unknown peer")
+	assert.Equal(t, log.DebugLevel, util.ModuleLogLevels()["cloud"])
+
+	// The daemon sets its own levels for any client.
+	reply, err = server{runningOnDaemon: true}.SetLogLevels(context.Background(),
+		&pb.LogLevelsRequest{Levels: map[string]string{"cloud": "info"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "info", reply.Levels["cloud"])
 }
 
 func TestQueryImagesCluster(t *testing.T) {
@@ -326,7 +700,8 @@ func TestQueryImagesCluster(t *testing.T) {
 		return nil
 	})
 
-	exp := `[{"ID":1,"Name":"foo","Dockerfile":"","DockerID":"","Status":""}]`
+	exp := `[{"ID":1,"Name":"foo","Dockerfile":"","DockerID":"","Status":"",` +
+		`"Minion":"","Registry":""}]`
 	checkQuery(t, server{conn, false, nil}, db.ImageTable, exp)
 }
 
@@ -341,6 +716,53 @@ func TestQueryImagesDaemon(t *testing.T) {
 		return mc, nil
 	}
 
-	exp := `[{"ID":0,"Name":"bar","Dockerfile":"","DockerID":"","Status":""}]`
+	exp := `[{"ID":0,"Name":"bar","Dockerfile":"","DockerID":"","Status":"",` +
+		`"Minion":"","Registry":""}]`
 	checkQuery(t, server{db.New(), true, nil}, db.ImageTable, exp)
 }
+
+func TestUpdateSettings(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	s := server{conn: conn, runningOnDaemon: true}
+
+	_, err := s.UpdateSettings(nil, &pb.UpdateSettingsRequest{
+		Flags: map[string]bool{db.MinimalACLs: true}})
+	assert.EqualError(t, err, "namespace must be specified")
+
+	_, err = s.UpdateSettings(nil, &pb.UpdateSettingsRequest{
+		Namespace: "ns", Flags: map[string]bool{"foo": true}})
+	assert.EqualError(t, err, "unknown setting: foo")
+	assert.Empty(t, conn.SelectFromSettings(nil))
+
+	_, err = s.UpdateSettings(nil, &pb.UpdateSettingsRequest{
+		Namespace: "ns", Flags: map[string]bool{db.MinimalACLs: true}})
+	assert.NoError(t, err)
+
+	settings := conn.SelectFromSettings(nil)
+	assert.Len(t, settings, 1)
+	assert.Equal(t, "ns", settings[0].Namespace)
+	assert.True(t, settings[0].Enabled(db.MinimalACLs))
+
+	_, err = s.UpdateSettings(nil, &pb.UpdateSettingsRequest{
+		Namespace: "ns", Flags: map[string]bool{db.MinimalACLs: false}})
+	assert.NoError(t, err)
+
+	settings = conn.SelectFromSettings(nil)
+	assert.Len(t, settings, 1)
+	assert.False(t, settings[0].Enabled(db.MinimalACLs))
+
+	exp := `[{"ID":1,"Namespace":"ns","Flags":{"minimal-acls":false}}]`
+	checkQuery(t, s, db.SettingsTable, exp)
+}
+
+// daemonContext returns the context of a request from a client that presented
+// the daemon's certificate.
+func daemonContext() context.Context {
+	daemon := &x509.Certificate{URIs: []*url.URL{tls.DaemonURI()}}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: cryptoTLS.ConnectionState{
+			PeerCertificates: []*x509.Certificate{daemon}}},
+	})
+}
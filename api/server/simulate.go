@@ -0,0 +1,37 @@
+package server
+
+import (
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/cloud/machine"
+	"github.com/kelda/kelda/db"
+
+	"golang.org/x/net/context"
+)
+
+// Simulate reports where the containers of the requested blueprint would be
+// placed on its machines, without deploying it.
+func (s server) Simulate(ctx context.Context, req *pb.SimulateRequest) (
+	*pb.SimulateReply, error) {
+
+	if !s.runningOnDaemon {
+		return nil, errDaemonOnlyRPC
+	}
+
+	bp, err := blueprint.FromJSON(req.Blueprint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Placement constraints may refer to sizes, so machines that only specify
+	// their resources are given the size they would be booted with.
+	for i, m := range bp.Machines {
+		if m.Size == "" {
+			bp.Machines[i].Size = machine.ChooseSize(
+				db.ProviderName(m.Provider), m.RAM, m.CPU, m.GPUs,
+				m.GPUType, m.Arch)
+		}
+	}
+
+	return simulate(bp)
+}
@@ -0,0 +1,34 @@
+// +build !windows
+
+package server
+
+import (
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/minion/scheduler"
+)
+
+func simulate(bp blueprint.Blueprint) (*pb.SimulateReply, error) {
+	sim := scheduler.Simulate(bp)
+
+	reply := &pb.SimulateReply{
+		AllPlaced:     sim.AllPlaced(),
+		Unplaced:      sim.Unplaced,
+		SpareMachines: int32(sim.SpareMachines),
+	}
+	for _, m := range bp.Machines {
+		hostnames, ok := sim.Placed[m.ID]
+		if !ok {
+			continue
+		}
+
+		reply.Machines = append(reply.Machines, &pb.SimulatedMachine{
+			ID:         m.ID,
+			Provider:   m.Provider,
+			Region:     m.Region,
+			Size:       m.Size,
+			Containers: hostnames,
+		})
+	}
+	return reply, nil
+}
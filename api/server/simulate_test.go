@@ -0,0 +1,44 @@
+// +build !windows
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+
+	bp := blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{ID: "master", Role: "Master", Provider: "Amazon"},
+			{ID: "worker", Role: "Worker", Provider: "Amazon",
+				RAM: blueprint.Range{Min: 2}},
+		},
+		Containers: []blueprint.Container{
+			{ID: "a", Hostname: "a", Image: blueprint.Image{Name: "nginx"}},
+		},
+	}
+
+	_, err := server{conn: db.New()}.Simulate(nil,
+		&pb.SimulateRequest{Blueprint: bp.String()})
+	assert.Equal(t, errDaemonOnlyRPC, err)
+
+	s := server{conn: db.New(), runningOnDaemon: true}
+	_, err = s.Simulate(nil, &pb.SimulateRequest{Blueprint: "malformed"})
+	assert.Error(t, err)
+
+	reply, err := s.Simulate(nil, &pb.SimulateRequest{Blueprint: bp.String()})
+	assert.NoError(t, err)
+	assert.Equal(t, &pb.SimulateReply{
+		AllPlaced: true,
+		Machines: []*pb.SimulatedMachine{{ID: "worker", Provider: "Amazon",
+			Size: "m3.medium", Containers: []string{"a"}}},
+	}, reply)
+}
@@ -0,0 +1,13 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+)
+
+// The scheduler depends on Linux networking, so it isn't built on Windows.
+func simulate(bp blueprint.Blueprint) (*pb.SimulateReply, error) {
+	return nil, errors.New("simulation is not supported on Windows")
+}
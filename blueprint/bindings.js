@@ -3,6 +3,7 @@ const crypto = require('crypto');
 const request = require('sync-request');
 const stringify = require('json-stable-stringify');
 const _ = require('underscore');
+const net = require('net');
 const path = require('path');
 const os = require('os');
 
@@ -29,10 +30,27 @@ class Deployment {
    *   add its IP address here.  These IP addresses must be in CIDR notation; e.g.,
    *   to allow access from 1.2.3.4, set adminACL to ["1.2.3.4/32"]. To allow access
    *   from all IP addresses, set adminACL to ["0.0.0.0/0"].
+   *   Entries may also be DNS names, which are periodically resolved so that
+   *   admins with dynamic IPs keep access, or "client", which refers to the IP
+   *   of the machine that ran `quilt run`.
+   * @param {number} [deploymentOpts.maxPrice] - The maximum hourly price, in US
+   *   dollars, bid for preemptible machines that don't set their own maxPrice.
+   * @param {boolean} [deploymentOpts.onDemandFallback=false] - Whether
+   *   preemptible machines are booted on-demand instead after preemptible
+   *   capacity repeatedly fails to boot, or is reclaimed right after booting.
+   * @param {boolean} [deploymentOpts.spreadMasters=false] - Whether Amazon
+   *   masters that don't set a zone or subnet are spread across the
+   *   availability zones of their region, so that losing a zone doesn't lose
+   *   the etcd quorum.
    */
   constructor(deploymentOpts = {}) {
     this.namespace = deploymentOpts.namespace || 'default-namespace';
     this.adminACL = getStringArray('adminACL', deploymentOpts.adminACL);
+    this.maxPrice = getMaxPrice(deploymentOpts.maxPrice);
+    this.onDemandFallback = getBoolean('onDemandFallback',
+      deploymentOpts.onDemandFallback);
+    this.spreadMasters = getBoolean('spreadMasters',
+      deploymentOpts.spreadMasters);
 
     checkExtraKeys(deploymentOpts, this);
 
@@ -64,6 +82,17 @@ class Infrastructure extends Deployment {
    *   add its IP address here.  These IP addresses must be in CIDR notation; e.g.,
    *   to allow access from 1.2.3.4, set adminACL to ["1.2.3.4/32"]. To allow access
    *   from all IP addresses, set adminACL to ["0.0.0.0/0"].
+   *   Entries may also be DNS names, which are periodically resolved so that
+   *   admins with dynamic IPs keep access, or "client", which refers to the IP
+   *   of the machine that ran `quilt run`.
+   * @param {number} [opts.maxPrice] - The maximum hourly price, in US dollars,
+   *   bid for preemptible machines that don't set their own maxPrice.
+   * @param {boolean} [opts.onDemandFallback=false] - Whether preemptible
+   *   machines are booted on-demand instead after preemptible capacity
+   *   repeatedly fails to boot, or is reclaimed right after booting.
+   * @param {boolean} [opts.spreadMasters=false] - Whether Amazon masters that
+   *   don't set a zone or subnet are spread across the availability zones of
+   *   their region.
    */
   constructor(masters, workers, opts = {}) {
     super(opts);
@@ -82,7 +111,10 @@ class Infrastructure extends Deployment {
 }
 
 /**
- * Gets the public key associated with a github username.
+ * Gets the public key associated with a github username.  The keys are fetched
+ * when the blueprint runs, so they aren't updated until it's redeployed.  To
+ * have the daemon fetch and refresh them instead, pass the username in the
+ * `githubKeys` option of {@link Machine}.
  * @param {string} user - The GitHub username.
  * @returns {string} The SSH key.
  */
@@ -261,6 +293,10 @@ Deployment.prototype.toQuiltRepresentation = function toQuiltRepresentation() {
     loadBalancers.push({
       name: lb.name,
       hostnames: lb.containers.map(c => c.hostname),
+      canaryHostnames: lb.canary.map(c => c.hostname),
+      canaryFraction: lb.canaryFraction,
+      drainPeriod: lb.drainPeriod,
+      edge: lb.edge,
     });
   });
 
@@ -279,6 +315,9 @@ Deployment.prototype.toQuiltRepresentation = function toQuiltRepresentation() {
 
     namespace: this.namespace,
     adminACL: this.adminACL,
+    maxPrice: this.maxPrice,
+    onDemandFallback: this.onDemandFallback,
+    spreadMasters: this.spreadMasters,
   };
   vet(quiltDeployment);
   return quiltDeployment;
@@ -367,15 +406,50 @@ Deployment.prototype.deploy = function deploy(list) {
  *
  * @param {string} name - The name of the load balancer.
  * @param {Container[]} containers - The containers behind the load balancer.
+ * @param {Object} [opts] - Optional arguments.
+ * @param {Container[]} [opts.canary] - Containers that receive a fraction of
+ *   the load balancer's traffic, such as a new version being tested, in
+ *   addition to `containers`.
+ * @param {number} [opts.canaryFraction=0] - The fraction of traffic, between 0
+ *   and 1, that is sent to the canary containers. It's rounded to the nearest
+ *   percent, and may be adjusted after the blueprint is deployed with
+ *   `quilt canary`.
+ * @param {number} [opts.drainPeriod=0] - The number of seconds that containers
+ *   removed from the load balancer keep running after they stop receiving new
+ *   connections, so that their established connections can finish.
+ * @param {string} [opts.edge] - A machine label, in the form 'key=value'
+ *   (e.g. 'edge=true'). The load balancer then accepts connections from
+ *   `publicInternet`, but only on the workers with the label, which proxy them
+ *   to the load balancer.
  */
-function LoadBalancer(name, containers) {
+function LoadBalancer(name, containers, opts = {}) {
   if (typeof name !== 'string') {
     throw new Error(`name must be a string; was ${stringify(name)}`);
   }
   this.name = uniqueHostname(name);
   this.containers = boxObjects(containers, Container);
+  this.canary = boxObjects(opts.canary || [], Container);
+
+  this.canaryFraction = getNumber('canaryFraction', opts.canaryFraction);
+  if (this.canaryFraction < 0 || this.canaryFraction > 1) {
+    throw new Error('canaryFraction must be between 0 and 1 ' +
+      `(was: ${stringify(this.canaryFraction)})`);
+  }
+
+  this.drainPeriod = getNumber('drainPeriod', opts.drainPeriod);
+  if (!Number.isInteger(this.drainPeriod) || this.drainPeriod < 0) {
+    throw new Error('drainPeriod must be a non-negative integer ' +
+      `(was: ${stringify(this.drainPeriod)})`);
+  }
+
+  this.edge = getString('edge', opts.edge);
+  if (this.edge !== '' && !/^[^=]+=[^=]*$/.test(this.edge)) {
+    throw new Error('edge must be a label in the form key=value ' +
+      `(was: ${stringify(this.edge)})`);
+  }
 
   this.allowedInboundConnections = [];
+  this.incomingPublic = [];
 }
 
 // Get the Quilt hostname that represents the entire load balancer.
@@ -392,12 +466,35 @@ LoadBalancer.prototype.deploy = function lbDeploy(deployment) {
  * allow direct connections to the containers behind the load balancer.
  *
  * @param {Container|Container[]} srcArg - The containers that can open
- *   connections to this load balancer.
+ *   connections to this load balancer, or `publicInternet` if the load
+ *   balancer has `edge` machines.
  * @param {int|Port|PortRange} portRange - The ports on which containers can
  *   open connections.
+ * @param {Object} [opts] - Optional arguments.
+ * @param {boolean} [opts.mtls=false] - Whether the connections are wrapped in
+ *   mutual TLS. Every container behind the load balancer must accept them.
  * @returns {void}
  */
-LoadBalancer.prototype.allowFrom = function lbAllowFrom(srcArg, portRange) {
+LoadBalancer.prototype.allowFrom =
+function lbAllowFrom(srcArg, portRange, opts = {}) {
+  if (srcArg === publicInternet) {
+    if (this.edge === '') {
+      throw new Error('only load balancers with edge machines can accept ' +
+        'connections from the public internet');
+    }
+    if (opts.mtls) {
+      throw new Error('connections from the public internet can\'t use mTLS');
+    }
+
+    const range = boxRange(portRange);
+    if (range.min !== range.max) {
+      throw new Error('public internet can only connect to single ports ' +
+              'and not to port ranges');
+    }
+    this.incomingPublic.push(range);
+    return;
+  }
+
   let src;
   try {
     src = boxObjects(srcArg, Container);
@@ -409,7 +506,7 @@ LoadBalancer.prototype.allowFrom = function lbAllowFrom(srcArg, portRange) {
 
   src.forEach((c) => {
     this.allowedInboundConnections.push(
-      new Connection(c, boxRange(portRange)));
+      new Connection(c, boxRange(portRange), opts.mtls));
   });
 };
 
@@ -420,7 +517,11 @@ LoadBalancer.prototype.allowFrom = function lbAllowFrom(srcArg, portRange) {
  * @implements {Connectable}
  */
 const publicInternet = {
-  allowFrom(srcArg, portRange) {
+  allowFrom(srcArg, portRange, opts = {}) {
+    if (opts.mtls) {
+      throw new Error('connections to the public internet can\'t use mTLS');
+    }
+
     let src;
     try {
       src = boxObjects(srcArg, Container);
@@ -437,12 +538,19 @@ const publicInternet = {
 };
 
 LoadBalancer.prototype.getQuiltConnections = function lbGetQuiltConnections() {
-  return this.allowedInboundConnections.map(conn => ({
-    from: conn.from.hostname,
-    to: this.name,
-    minPort: conn.minPort,
-    maxPort: conn.maxPort,
-  }));
+  const connections = this.allowedInboundConnections.map(conn =>
+    conn.toQuiltRepresentation(conn.from.hostname, this.name));
+
+  this.incomingPublic.forEach((rng) => {
+    connections.push({
+      from: publicInternetLabel,
+      to: this.name,
+      minPort: rng.min,
+      maxPort: rng.max,
+    });
+  });
+
+  return connections;
 };
 
 /**
@@ -578,6 +686,112 @@ function getNumber(argName, arg) {
   throw new Error(`${argName} must be a number (was: ${stringify(arg)})`);
 }
 
+function getMaxPrice(arg) {
+  const price = getNumber('maxPrice', arg);
+  if (price < 0) {
+    throw new Error(`maxPrice must not be negative (was: ${price})`);
+  }
+  return price;
+}
+
+const machineHookEvents = ['pre-boot', 'post-connect', 'pre-stop',
+  'disk-usage'];
+
+const bootstrapModes = ['user-data', 'ssh'];
+
+const diskTypes = ['pd-standard', 'pd-ssd', 'local-ssd'];
+
+// Containers are always attached to the Quilt network, and so can't join the
+// host network or join the Quilt network a second time.
+const reservedNetworks = ['host', 'quilt'];
+
+// The ARNs of Amazon IAM roles, e.g. arn:aws:iam::123456789012:role/uploader.
+const roleArnRegex = /^arn:aws[a-z-]*:iam::\d{12}:role\/.+$/;
+
+// The signals that containers may be sent to reload their files.
+const reloadSignals = ['SIGHUP', 'SIGUSR1', 'SIGUSR2'];
+
+/**
+ * @private
+ * @param {Object[]} arg - The machine hooks.
+ * @returns {Object[]} An empty array if `arg` is not defined, and otherwise
+ *   `arg`.  An error is thrown if any of the hooks are malformed.
+ */
+function getMachineHooks(arg) {
+  if (arg === undefined) {
+    return [];
+  }
+  if (!Array.isArray(arg)) {
+    throw new Error(`hooks must be an array (was: ${stringify(arg)})`);
+  }
+  return arg.map((hook) => {
+    if (!machineHookEvents.includes(hook.event)) {
+      throw new Error(`hook event must be one of ${machineHookEvents} ` +
+        `(was: ${stringify(hook.event)})`);
+    }
+
+    const command = getString('hook command', hook.command);
+    const url = getString('hook url', hook.url);
+    if ((command === '') === (url === '')) {
+      throw new Error('hook must have either a command or a url ' +
+        `(was: ${stringify(hook)})`);
+    }
+
+    const extras = Object.keys(hook).filter(key =>
+      !['event', 'command', 'url'].includes(key));
+    if (extras.length > 0) {
+      throw new Error(`Unrecognized keys passed to hook: ${extras}`);
+    }
+
+    const result = { event: hook.event };
+    if (command !== '') {
+      result.command = command;
+    } else {
+      result.url = url;
+    }
+    return result;
+  });
+}
+
+/**
+ * @private
+ * @param {Object[]} arg - The machine's block storage volumes.
+ * @returns {Object[]} An empty array if `arg` is not defined, and otherwise
+ *   `arg`.  An error is thrown if any of the volumes are malformed.
+ */
+function getMachineVolumes(arg) {
+  if (arg === undefined) {
+    return [];
+  }
+  if (!Array.isArray(arg)) {
+    throw new Error(`volumes must be an array (was: ${stringify(arg)})`);
+  }
+  return arg.map((volume) => {
+    const name = getString('volume name', volume.name);
+    if (name === '') {
+      throw new Error(`volume must have a name (was: ${stringify(volume)})`);
+    }
+
+    const size = getNumber('volume size', volume.size);
+    if (!Number.isInteger(size) || size <= 0) {
+      throw new Error('volume size must be a positive integer ' +
+        `(was: ${stringify(volume.size)})`);
+    }
+
+    const extras = Object.keys(volume).filter(key =>
+      !['name', 'size', 'persistent'].includes(key));
+    if (extras.length > 0) {
+      throw new Error(`Unrecognized keys passed to volume: ${extras}`);
+    }
+
+    return {
+      name,
+      size,
+      persistent: getBoolean('volume persistent', volume.persistent),
+    };
+  });
+}
+
 /**
  * Forces `arg` to be a string, even if it's undefined.
  * @private
@@ -703,7 +917,8 @@ function getBoolean(argName, arg) {
  * @param {Object.<string, string>} [optionalArgs] - Optional arguments that
  *   modify the machine.
  * @param {string} [optionalArgs.provider] - The cloud provider that the machine
- *   should be launched in. Accepted values are Amazon, DigitalOcean, Google,
+ *   should be launched in. Accepted values are Alibaba, Amazon, Azure,
+ *   DigitalOcean, Fake, Google, IBM, OpenStack, Scaleway, Static,
  *   and Vagrant. This argument is optional, but the provider attribute of the
  *   machine must be set before it is deployed.
  * @param {string} [optionalArgs.role] - The role the machine will run as
@@ -714,16 +929,120 @@ function getBoolean(argName, arg) {
  *   template to create a machine with the appropriate role, as in the example.
  * @param {string} [optionalArgs.region] - The region the machine will run-in
  *   (provider-specific; e.g., for Amazon, this could be 'us-west-2').
+ * @param {string} [optionalArgs.zone] - The availability zone, within the
+ *   region, that an Amazon machine runs in (e.g. 'us-west-2a'). Defaults to a
+ *   zone chosen by Amazon, or by `spreadMasters` for masters.
  * @param {string} [optionalArgs.size] - The instance type (provider-specific).
  * @param {Range|int} [optionalArgs.cpu] - The desired number of CPUs.
  * @param {Range|int} [optionalArgs.ram] - The desired amount of RAM in GiB.
+ * @param {int} [optionalArgs.gpus] - The minimum number of GPUs. Amazon
+ *   machines are given an instance type with at least this many GPUs (e.g.
+ *   p2 or p3), while Google attaches 1, 2, 4 or 8 GPUs to the machine.
+ * @param {string} [optionalArgs.gpuType] - The type of the GPUs, e.g.
+ *   'nvidia-tesla-k80' or 'nvidia-tesla-v100'. Requires `gpus`. Defaults to
+ *   any type on Amazon, and to 'nvidia-tesla-k80' on Google.
+ * @param {string} [optionalArgs.arch='amd64'] - The machine's CPU
+ *   architecture, 'amd64' or 'arm64'. ARM machines are given an ARM instance
+ *   type, such as Amazon's Graviton instances, and boot the ARM build of Quilt.
  * @param {int} [optionalArgs.diskSize] - The desired amount of disk space in GB.
+ * @param {string} [optionalArgs.volumeType] - The type of an Amazon machine's
+ *   EBS volume: 'gp2' (the default), 'gp3', 'io1', 'io2', 'st1' or 'sc1'.
+ * @param {int} [optionalArgs.iops] - The IOPS provisioned for an Amazon
+ *   machine's volume. Requires `volumeType`, and is required by 'io1' and
+ *   'io2' volumes.
+ * @param {string} [optionalArgs.diskType='pd-standard'] - The type of a
+ *   Google machine's disk: 'pd-standard', 'pd-ssd', or 'local-ssd'. Machines
+ *   with a local SSD boot from a standard persistent disk, and attach the
+ *   local SSD as scratch space that's lost when the machine stops.
+ * @param {Object[]} [optionalArgs.volumes] - Block storage volumes to attach
+ *   to a DigitalOcean machine when it boots, e.g.
+ *   {name: 'data', size: 100, persistent: true}. `size` is in gigabytes.
+ *   Volumes are deleted when the machine stops, unless they're `persistent`,
+ *   in which case they're kept and attached to the next machine in the
+ *   region that declares a volume of the same name.
+ * @param {boolean} [optionalArgs.ipv6=false] - Whether to give the machine a
+ *   public IPv6 address, in addition to its IPv4 addresses. Supported on
+ *   Amazon, for machines launched into a `subnet` with an IPv6 CIDR block,
+ *   and on DigitalOcean.
  * @param {string} [optionalArgs.floatingIp] - A reserved IP to associate with
- *   the machine.
+ *   the machine. On Amazon, `'auto'` allocates a new Elastic IP for the
+ *   machine, which is released when the machine stops.
  * @param {string[]} [optionalArgs.sshKeys] - Public keys to allow users to log
  *   in to the machine and containers running on it.
+ * @param {string[]} [optionalArgs.githubKeys] - GitHub usernames whose public
+ *   keys are allowed to log in, like `sshKeys`. Unlike {@link githubKeys},
+ *   the keys are fetched by the daemon rather than when the blueprint runs,
+ *   and the daemon periodically refreshes them, so keys that are added to or
+ *   removed from GitHub are applied to the running machines.
  * @param {boolean} [optionalArgs.preemptible=false] - Whether the machine
  *   should be preemptible. Only supported on the Amazon provider.
+ * @param {number} [optionalArgs.maxPrice] - The maximum hourly price, in US
+ *   dollars, to bid for a preemptible machine. Defaults to the deployment's
+ *   maxPrice, or to the provider's default if neither is set.
+ * @param {Object.<string, string>} [optionalArgs.sysctls] - Kernel parameters
+ *   to set when the machine boots (e.g. {'net.core.somaxconn': '1024'}).
+ * @param {int} [optionalArgs.hugepages] - The number of 2MB huge pages to
+ *   reserve when the machine boots.
+ * @param {Object[]} [optionalArgs.hooks] - Commands or webhooks run by the
+ *   daemon at points in the machine's lifecycle, e.g.
+ *   {event: 'pre-stop', command: 'deregister $QUILT_PUBLIC_IP'}. The event
+ *   must be 'pre-boot', 'post-connect', 'pre-stop', or 'disk-usage', and each
+ *   hook must have either a `command` or a `url`.
+ * @param {string} [optionalArgs.bootstrap] - How the boot script is delivered
+ *   to the machine: 'user-data', which is run by cloud-init, or 'ssh', for
+ *   images that limit the size of user-data or don't run cloud-init. With
+ *   'ssh', the user-data only grants the daemon SSH access, and the daemon runs
+ *   the boot script over SSH. Defaults to the provider's default, which is
+ *   'user-data' unless the daemon was started with `-ssh-bootstrap`.
+ * @param {string} [optionalArgs.hostname] - A pattern for the machine's
+ *   hostname, e.g. 'web-{role}-{index}-{region}'. {role}, {index} and {region}
+ *   are replaced by the machine's lowercase role, its position among the
+ *   blueprint's machines of that role (starting from 1), and its region.
+ *   Providers also name the instance after the hostname, except on
+ *   DigitalOcean, where droplet names identify the namespace.
+ * @param {Object.<string, string>} [optionalArgs.tags] - Key-value pairs that
+ *   the provider attaches to the instance when it boots, e.g. for cost
+ *   allocation (e.g. {'cost-center': 'research'}). Amazon applies them as tags,
+ *   Google as labels, and DigitalOcean as tags of the form 'key:value'. Keys
+ *   must start with a lowercase letter, and keys and values may only contain
+ *   lowercase letters, digits, '-' and '_'. Tags are only applied at boot.
+ * @param {Object.<string, string>} [optionalArgs.labels] - Key-value pairs
+ *   that describe the machine to the scheduler (e.g. {ssd: 'true'}), which
+ *   containers may require with `placeOn({label: 'ssd=true'})`. Unlike tags,
+ *   labels aren't passed to the provider, and changing them doesn't replace
+ *   the machine. Keys and values may contain letters, digits, '.', '_' and
+ *   '-', and keys may also contain '/'.
+ * @param {string} [optionalArgs.vpc] - The ID of an existing Amazon VPC to
+ *   launch the machine into, rather than the region's default VPC. Must be
+ *   given along with `subnet`.
+ * @param {string} [optionalArgs.subnet] - The ID of the subnet, within `vpc`,
+ *   to launch the machine into.
+ * @param {string} [optionalArgs.instanceProfile] - The name of the IAM
+ *   instance profile to attach to an Amazon machine. Containers on the
+ *   machine may use its role's credentials to access the AWS API.
+ * @param {string[]} [optionalArgs.securityGroups] - The IDs of existing
+ *   Amazon security groups (e.g. 'sg-0123456789abcdef0') to launch the
+ *   machine into, in addition to the group that Quilt manages, which can't
+ *   be left out. Quilt never changes their rules, so baseline firewall rules
+ *   maintained outside of Quilt are preserved.
+ * @param {string} [optionalArgs.placementGroup] - The name of the Amazon
+ *   placement group to launch the machine into, for low network latency
+ *   between the machines in the group. The group is created, with the cluster
+ *   strategy, if it doesn't exist.
+ * @param {string} [optionalArgs.image] - The image to boot the machine from in
+ *   place of the provider's default Ubuntu 16.04 image: an AMI ID on Amazon,
+ *   an image URL (e.g. 'projects/my-project/global/images/my-image') on
+ *   Google, or a snapshot ID on DigitalOcean. The image must be based on
+ *   Ubuntu 16.04. Images that were prebaked with Docker skip installing it
+ *   when the machine boots.
+ * @param {string} [optionalArgs.host] - The IP address of the pre-existing
+ *   host that a Static machine runs on. Required for, and only accepted by,
+ *   the Static provider.
+ * @param {string} [optionalArgs.sshUser='root'] - The user with which the
+ *   daemon logs in to a Static machine's host. It must have passwordless sudo.
+ * @param {string} [optionalArgs.sshKeyPath] - The path, on the daemon's
+ *   machine, of the private key with which the daemon logs in to a Static
+ *   machine's host. Defaults to the daemon's SSH key, ~/.quilt/ssh_key.
  */
 function Machine(optionalArgs) {
   this._refID = uniqueID();
@@ -731,13 +1050,71 @@ function Machine(optionalArgs) {
   this.provider = getString('provider', optionalArgs.provider);
   this.role = getString('role', optionalArgs.role);
   this.region = getString('region', optionalArgs.region);
+  this.zone = getString('zone', optionalArgs.zone);
   this.size = getString('size', optionalArgs.size);
   this.floatingIp = getString('floatingIp', optionalArgs.floatingIp);
   this.diskSize = getNumber('diskSize', optionalArgs.diskSize);
+  this.volumeType = getString('volumeType', optionalArgs.volumeType);
+  this.iops = getNumber('iops', optionalArgs.iops);
+  if (this.iops !== 0 && this.volumeType === '') {
+    throw new Error('iops requires volumeType');
+  }
+  this.diskType = getString('diskType', optionalArgs.diskType);
+  if (this.diskType !== '' && !diskTypes.includes(this.diskType)) {
+    throw new Error(`diskType must be one of ${diskTypes} ` +
+      `(was: ${stringify(this.diskType)})`);
+  }
+  this.volumes = getMachineVolumes(optionalArgs.volumes);
+  this.ipv6 = getBoolean('ipv6', optionalArgs.ipv6);
   this.sshKeys = getStringArray('sshKeys', optionalArgs.sshKeys);
+  this.githubKeys = getStringArray('githubKeys', optionalArgs.githubKeys);
   this.cpu = boxRange(optionalArgs.cpu);
   this.ram = boxRange(optionalArgs.ram);
+  this.gpus = getNumber('gpus', optionalArgs.gpus);
+  if (!Number.isInteger(this.gpus) || this.gpus < 0) {
+    throw new Error('gpus must be a non-negative integer ' +
+      `(was: ${stringify(this.gpus)})`);
+  }
+  this.gpuType = getString('gpuType', optionalArgs.gpuType);
+  if (this.gpuType !== '' && this.gpus === 0) {
+    throw new Error('gpuType requires gpus');
+  }
+  this.arch = getString('arch', optionalArgs.arch);
+  if (this.arch !== '' && this.arch !== 'amd64' && this.arch !== 'arm64') {
+    throw new Error('arch must be \'amd64\' or \'arm64\' ' +
+      `(was: ${stringify(this.arch)})`);
+  }
   this.preemptible = getBoolean('preemptible', optionalArgs.preemptible);
+  this.maxPrice = getMaxPrice(optionalArgs.maxPrice);
+  if (this.maxPrice !== 0 && !this.preemptible) {
+    throw new Error('maxPrice is only valid for preemptible machines');
+  }
+  this.sysctls = getStringMap('sysctls', optionalArgs.sysctls);
+  this.hugepages = getNumber('hugepages', optionalArgs.hugepages);
+  this.hooks = getMachineHooks(optionalArgs.hooks);
+  this.bootstrap = getString('bootstrap', optionalArgs.bootstrap);
+  if (this.bootstrap !== '' && !bootstrapModes.includes(this.bootstrap)) {
+    throw new Error(`bootstrap must be one of ${bootstrapModes} ` +
+      `(was: ${stringify(this.bootstrap)})`);
+  }
+  this.hostname = getString('hostname', optionalArgs.hostname);
+  this.tags = getStringMap('tags', optionalArgs.tags);
+  this.labels = getStringMap('labels', optionalArgs.labels);
+  this.vpc = getString('vpc', optionalArgs.vpc);
+  this.subnet = getString('subnet', optionalArgs.subnet);
+  if ((this.vpc === '') !== (this.subnet === '')) {
+    throw new Error('vpc and subnet must be specified together');
+  }
+  this.instanceProfile = getString('instanceProfile',
+    optionalArgs.instanceProfile);
+  this.securityGroups = getStringArray('securityGroups',
+    optionalArgs.securityGroups);
+  this.image = getString('image', optionalArgs.image);
+  this.placementGroup = getString('placementGroup',
+    optionalArgs.placementGroup);
+  this.host = getString('host', optionalArgs.host);
+  this.sshUser = getString('sshUser', optionalArgs.sshUser);
+  this.sshKeyPath = getString('sshKeyPath', optionalArgs.sshKeyPath);
 
   checkExtraKeys(optionalArgs, this);
 }
@@ -748,10 +1125,26 @@ Machine.prototype.deploy = function machineDeploy(deployment) {
 
 // Create a new machine with the same attributes.
 Machine.prototype.clone = function machineClone() {
-  // _.clone only creates a shallow copy, so we must clone sshKeys ourselves.
+  // _.clone only creates a shallow copy, so we must clone sshKeys,
+  // githubKeys, securityGroups, sysctls, hooks, tags, labels, and volumes
+  // ourselves.
   const keyClone = _.clone(this.sshKeys);
+  const githubKeyClone = _.clone(this.githubKeys);
+  const securityGroupClone = _.clone(this.securityGroups);
+  const sysctlClone = _.clone(this.sysctls);
+  const hookClone = this.hooks.map(hook => _.clone(hook));
+  const tagClone = _.clone(this.tags);
+  const labelClone = _.clone(this.labels);
+  const volumeClone = this.volumes.map(volume => _.clone(volume));
   const cloned = _.clone(this);
   cloned.sshKeys = keyClone;
+  cloned.githubKeys = githubKeyClone;
+  cloned.securityGroups = securityGroupClone;
+  cloned.sysctls = sysctlClone;
+  cloned.hooks = hookClone;
+  cloned.tags = tagClone;
+  cloned.labels = labelClone;
+  cloned.volumes = volumeClone;
   return new Machine(cloned);
 };
 
@@ -883,8 +1276,57 @@ Image.prototype.clone = function imageClone() {
  *   the path on the container where the text file should be installed, and
  *   the value is the contents of the text file. If the file content specified
  *   by this argument changes and the blueprint is re-run, Quilt will re-start
- *   the container using the new files.  Files are installed with permissions
- *   0644 and parent directories are automatically created.
+ *   the container using the new files, unless `reloadSignal` is set.  Files
+ *   are installed with permissions 0644 and parent directories are
+ *   automatically created.
+ * @param {string} [optionalArgs.reloadSignal] - The signal (SIGHUP, SIGUSR1,
+ *   or SIGUSR2) that makes the container reload its files.  If it's set,
+ *   changed files are copied into the running container, and the signal is
+ *   sent to its main process, instead of restarting it.  Files removed from
+ *   `filepathToContent` are left in place until the container restarts, and
+ *   changes to anything else, such as `env`, still restart the container.
+ * @param {string} [optionalArgs.priorityClass] - The scheduling priority of
+ *   the container (accepted values are low, normal, high, and critical).  When
+ *   the cluster is out of room, containers may be evicted to make room for
+ *   containers with a higher priority.
+ * @param {string[]} [optionalArgs.postStart] - A command to execute in the
+ *   container after it starts. If it fails, the container is restarted.
+ * @param {string[]} [optionalArgs.preStop] - A command to execute in the
+ *   container before it's stopped.
+ * @param {string[]} [optionalArgs.networks] - The names of Docker networks on
+ *   the machine, such as a macvlan network bridged to the provider's network,
+ *   that the container joins in addition to the Quilt network.  The networks
+ *   aren't considered when placing the container.  If it's placed on a
+ *   machine that lacks one of them, it fails to start there with a `failed`
+ *   status, and is moved to another machine.  Because Docker can't attach a
+ *   container to both the host network and another network, `host` isn't
+ *   accepted.
+ * @param {string[]} [optionalArgs.dns] - The IP addresses of DNS servers that
+ *   the container queries for names that Quilt doesn't resolve, such as legacy
+ *   internal names, after Quilt's own DNS server.
+ * @param {string[]} [optionalArgs.dnsSearch] - DNS search domains for the
+ *   container, in addition to Quilt's `q` domain.
+ * @param {Object.<string, string>} [optionalArgs.extraHosts] - Entries added to
+ *   the container's /etc/hosts, from hostname to IP address.
+ * @param {boolean} [optionalArgs.exposeMetadata] - If true, the container's
+ *   environment describes its machine and its own identity:
+ *   QUILT_PROVIDER, QUILT_REGION, QUILT_SIZE, QUILT_PUBLIC_IP,
+ *   QUILT_PRIVATE_IP, QUILT_HOSTNAME, QUILT_IP, and QUILT_BLUEPRINT_ID.  The
+ *   variables are set when the container starts.
+ * @param {string} [optionalArgs.cloudRole] - The ARN of an Amazon IAM role
+ *   (e.g. 'arn:aws:iam::123456789012:role/uploader') whose short-lived
+ *   credentials are issued to the container by its machine, so that it can
+ *   access services such as S3 without long-lived keys in its environment.
+ *   The credentials are served at the instance metadata API paths, and the
+ *   container's AWS_EC2_METADATA_SERVICE_ENDPOINT points Amazon's SDKs at
+ *   them.
+ * @param {boolean} [optionalArgs.tty] - If true, the container is allocated a
+ *   TTY, and its stdin is kept open, so that `quilt attach` can interact with
+ *   it.  Useful for REPL-style workloads.
+ * @param {boolean} [optionalArgs.stopped] - If true, the container is
+ *   intentionally stopped: it isn't run, but it keeps its hostname and IP
+ *   address, so that it resumes with the same identity once `stopped` is
+ *   removed.  Stopping a container doesn't change its ID.
  */
 function Container(hostnamePrefix, image, optionalArgs = {}) {
   // refID is used to distinguish deployments with multiple references to the
@@ -907,9 +1349,55 @@ function Container(hostnamePrefix, image, optionalArgs = {}) {
   this.env = getStringMap('env', optionalArgs.env);
   this.filepathToContent = getStringMap('filepathToContent',
     optionalArgs.filepathToContent);
+  this.priorityClass = getString('priorityClass', optionalArgs.priorityClass);
+  this.postStart = getStringArray('postStart', optionalArgs.postStart);
+  this.preStop = getStringArray('preStop', optionalArgs.preStop);
+  this.reloadSignal = getString('reloadSignal', optionalArgs.reloadSignal);
+  if (this.reloadSignal !== '' &&
+      !reloadSignals.includes(this.reloadSignal)) {
+    throw new Error(`reloadSignal must be one of ${reloadSignals} ` +
+      `(was: ${stringify(this.reloadSignal)})`);
+  }
+  this.networks = getStringArray('networks', optionalArgs.networks);
+  this.networks.forEach((network) => {
+    if (reservedNetworks.includes(network)) {
+      throw new Error(`networks must not include ${reservedNetworks} ` +
+        `(was: ${stringify(this.networks)})`);
+    }
+  });
+  this.dns = getStringArray('dns', optionalArgs.dns);
+  this.dns.forEach((ip) => {
+    if (!net.isIP(ip)) {
+      throw new Error('dns must be a list of IP addresses ' +
+        `(was: ${stringify(this.dns)})`);
+    }
+  });
+  this.dnsSearch = getStringArray('dnsSearch', optionalArgs.dnsSearch);
+  this.extraHosts = getStringMap('extraHosts', optionalArgs.extraHosts);
+  Object.keys(this.extraHosts).forEach((host) => {
+    if (!net.isIP(this.extraHosts[host])) {
+      throw new Error('extraHosts must map hostnames to IP addresses ' +
+        `(was: ${stringify(this.extraHosts)})`);
+    }
+  });
+  this.exposeMetadata = getBoolean('exposeMetadata',
+    optionalArgs.exposeMetadata);
+  this.cloudRole = getString('cloudRole', optionalArgs.cloudRole);
+  if (this.cloudRole !== '' && !roleArnRegex.test(this.cloudRole)) {
+    throw new Error('cloudRole must be the ARN of an IAM role ' +
+      `(was: ${stringify(this.cloudRole)})`);
+  }
+  this.tty = getBoolean('tty', optionalArgs.tty);
+  this.stopped = getBoolean('stopped', optionalArgs.stopped);
 
   // Don't allow callers to modify the arguments by reference.
   this.command = _.clone(this.command);
+  this.postStart = _.clone(this.postStart);
+  this.preStop = _.clone(this.preStop);
+  this.networks = _.clone(this.networks);
+  this.dns = _.clone(this.dns);
+  this.dnsSearch = _.clone(this.dnsSearch);
+  this.extraHosts = _.clone(this.extraHosts);
   this.env = _.clone(this.env);
   this.filepathToContent = _.clone(this.filepathToContent);
   this.image = this.image.clone();
@@ -971,22 +1459,48 @@ Container.prototype.getHostname = function containerGetHostname() {
 };
 
 Container.prototype.hash = function containerHash() {
+  // Containers that reload their files keep their ID when the files change, so
+  // that they're updated in place rather than replaced.
+  let filepathToContent = this.filepathToContent;
+  if (this.reloadSignal !== '') {
+    filepathToContent = undefined;
+  }
+
   return stringify({
     image: this.image,
     command: this.command,
     env: this.env,
-    filepathToContent: this.filepathToContent,
+    filepathToContent,
     hostname: this.hostname,
   });
 };
 
+/**
+ * Restricts the container to machines with the given attributes.
+ *
+ * @param {Object} machineAttrs - The attributes that the machine must have.
+ * @param {string} [machineAttrs.provider] - The machine's provider.
+ * @param {string} [machineAttrs.size] - The machine's size.
+ * @param {string} [machineAttrs.region] - The machine's region.
+ * @param {string} [machineAttrs.floatingIp] - The machine's floating IP.
+ * @param {string} [machineAttrs.label] - A label of the machine, in the form
+ *   'key=value' (e.g. 'ssd=true'). Call `placeOn` once for each label that the
+ *   machine must have.
+ */
 Container.prototype.placeOn = function containerPlaceOn(machineAttrs) {
+  const label = getString('label', machineAttrs.label);
+  if (label !== '' && !/^[^=]+=[^=]*$/.test(label)) {
+    throw new Error('label must be in the form key=value ' +
+      `(was: ${stringify(label)})`);
+  }
+
   this.placements.push({
     exclusive: false,
     provider: getString('provider', machineAttrs.provider),
     size: getString('size', machineAttrs.size),
     region: getString('region', machineAttrs.region),
     floatingIp: getString('floatingIp', machineAttrs.floatingIp),
+    label,
   });
 };
 
@@ -1008,8 +1522,11 @@ function containerGetPlacementsWithID() {
 };
 
 Container.prototype.allowFrom =
-function containerAllowFrom(srcArg, portRange) {
+function containerAllowFrom(srcArg, portRange, opts = {}) {
   if (srcArg === publicInternet) {
+    if (opts.mtls) {
+      throw new Error('connections from the public internet can\'t use mTLS');
+    }
     this.allowFromPublic(portRange);
     return;
   }
@@ -1025,7 +1542,7 @@ function containerAllowFrom(srcArg, portRange) {
 
   src.forEach((c) => {
     this.allowedInboundConnections.push(
-      new Connection(c, boxRange(portRange)));
+      new Connection(c, boxRange(portRange), opts.mtls));
   });
 };
 
@@ -1057,12 +1574,8 @@ function containerGetQuiltConnections() {
   const connections = [];
 
   this.allowedInboundConnections.forEach((conn) => {
-    connections.push({
-      from: conn.from.hostname,
-      to: this.hostname,
-      minPort: conn.minPort,
-      maxPort: conn.maxPort,
-    });
+    connections.push(
+      conn.toQuiltRepresentation(conn.from.hostname, this.hostname));
   });
 
   this.outgoingPublic.forEach((rng) => {
@@ -1095,6 +1608,19 @@ function containerToQuiltRepresentation() {
     env: this.env,
     filepathToContent: this.filepathToContent,
     hostname: this.hostname,
+    label: this.hostnamePrefix,
+    priorityClass: this.priorityClass,
+    postStart: this.postStart,
+    preStop: this.preStop,
+    reloadSignal: this.reloadSignal,
+    networks: this.networks,
+    dns: this.dns,
+    dnsSearch: this.dnsSearch,
+    extraHosts: this.extraHosts,
+    exposeMetadata: this.exposeMetadata,
+    cloudRole: this.cloudRole,
+    tty: this.tty,
+    stopped: this.stopped,
   };
 };
 
@@ -1142,9 +1668,15 @@ class Connectable {
    *
    * @param {Container} src - The container that can initiate connections.
    * @param {int|Port|PortRange} port - The ports to allow traffic on.
+   * @param {Object} [opts] - Optional arguments.
+   * @param {boolean} [opts.mtls=false] - Whether the connections are
+   *   transparently wrapped in mutual TLS by proxies that the minions run
+   *   alongside the containers.  The proxies authenticate each other with
+   *   certificates from the namespace's certificate authority, so the
+   *   containers needn't be configured.
    * @returns {void}
    */
-  allowFrom(src, port) { // eslint-disable-line
+  allowFrom(src, port, opts) { // eslint-disable-line
     throw new Error('not implemented');
   }
 }
@@ -1170,11 +1702,12 @@ function isConnectable(x) {
  *   Examples of connectable objects are Containers, LoadBalancers, publicInternet,
  *   and user-defined objects that implement allowFrom.
  * @param {int|Port|PortRange} port - The ports that traffic is allowed on.
+ * @param {Object} [opts] - Optional arguments, as accepted by allowFrom.
  * @returns {void}
  */
-function allow(src, dst, port) {
+function allow(src, dst, port, opts) {
   boxConnectable(dst).forEach((c) => {
-    c.allowFrom(src, port);
+    c.allowFrom(src, port, opts);
   });
 }
 
@@ -1184,13 +1717,37 @@ function allow(src, dst, port) {
  *
  * @param {string} from - The host from which connections are allowed.
  * @param {PortRange} ports - The port numbers which are allowed.
+ * @param {boolean} [mtls=false] - Whether the connections use mutual TLS.
  */
-function Connection(from, ports) {
+function Connection(from, ports, mtls = false) {
   this.minPort = ports.min;
   this.maxPort = ports.max;
   this.from = from;
+  this.mtls = mtls;
 }
 
+/**
+ * Converts the connection into the form required by the deployment engine.
+ * @private
+ *
+ * @param {string} from - The hostname that connections are allowed from.
+ * @param {string} to - The hostname that connections are allowed to.
+ * @returns {Object} The connection.
+ */
+Connection.prototype.toQuiltRepresentation =
+function connectionToQuiltRepresentation(from, to) {
+  const conn = {
+    from,
+    to,
+    minPort: this.minPort,
+    maxPort: this.maxPort,
+  };
+  if (this.mtls) {
+    conn.mtls = true;
+  }
+  return conn;
+};
+
 /**
  * Creates a Range object.
  * @constructor
@@ -163,6 +163,300 @@ describe('Bindings', () => {
         preemptible: true,
       }]);
     });
+    it('max price', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        preemptible: true,
+        maxPrice: 0.12,
+      }).asMaster());
+      checkMachines([{
+        role: 'Master',
+        provider: 'Amazon',
+        preemptible: true,
+        maxPrice: 0.12,
+      }]);
+    });
+    it('max price without preemptible', () => {
+      expect(() => new b.Machine({ provider: 'Amazon', maxPrice: 0.12 }))
+        .to.throw('maxPrice is only valid for preemptible machines');
+    });
+    it('negative max price', () => {
+      expect(() => new b.Machine({ preemptible: true, maxPrice: -1 }))
+        .to.throw('maxPrice must not be negative (was: -1)');
+    });
+    it('kernel tuning', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        sysctls: { 'net.core.somaxconn': '1024' },
+        hugepages: 512,
+      }).asMaster());
+      checkMachines([{
+        role: 'Master',
+        provider: 'Amazon',
+        sysctls: { 'net.core.somaxconn': '1024' },
+        hugepages: 512,
+      }]);
+    });
+    it('lifecycle hooks', () => {
+      const hooks = [
+        { event: 'pre-stop', command: 'deregister $QUILT_PUBLIC_IP' },
+        { event: 'post-connect', url: 'https://cmdb.example.com' },
+      ];
+      deployment.deploy(new b.Machine({ provider: 'Amazon', hooks })
+        .asWorker());
+      checkMachines([{ role: 'Worker', provider: 'Amazon', hooks }]);
+    });
+    it('bootstrap mode', () => {
+      deployment.deploy(new b.Machine({ provider: 'Amazon', bootstrap: 'ssh' })
+        .asWorker());
+      checkMachines([{ role: 'Worker', provider: 'Amazon', bootstrap: 'ssh' }]);
+      expect(() => new b.Machine({ bootstrap: 'telnet' }))
+        .to.throw('bootstrap must be one of user-data,ssh (was: "telnet")');
+    });
+    it('hostname pattern', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        hostname: '{role}-{index}-{region}',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        hostname: '{role}-{index}-{region}',
+      }]);
+    });
+    it('tags', () => {
+      const tags = { 'cost-center': 'research', team: 'infra' };
+      const machine = new b.Machine({ provider: 'Amazon', tags });
+      deployment.deploy(machine.asWorker());
+      checkMachines([{ role: 'Worker', provider: 'Amazon', tags }]);
+
+      const cloned = machine.clone();
+      cloned.tags.team = 'billing';
+      expect(machine.tags.team).to.equal('infra');
+    });
+    it('labels', () => {
+      const labels = { ssd: 'true', compliance: 'pci' };
+      const machine = new b.Machine({ provider: 'Amazon', labels });
+      deployment.deploy(machine.asWorker());
+      checkMachines([{ role: 'Worker', provider: 'Amazon', labels }]);
+
+      const cloned = machine.clone();
+      cloned.labels.ssd = 'false';
+      expect(machine.labels.ssd).to.equal('true');
+    });
+    it('github keys', () => {
+      const machine = new b.Machine({
+        provider: 'Amazon',
+        githubKeys: ['ejj'],
+      });
+      deployment.deploy(machine.asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        githubKeys: ['ejj'],
+      }]);
+
+      const cloned = machine.clone();
+      cloned.githubKeys.push('kklin');
+      expect(machine.githubKeys).to.eql(['ejj']);
+    });
+    it('vpc and subnet', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        vpc: 'vpc-1',
+        subnet: 'subnet-1',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        vpc: 'vpc-1',
+        subnet: 'subnet-1',
+      }]);
+      expect(() => new b.Machine({ subnet: 'subnet-1' }))
+        .to.throw('vpc and subnet must be specified together');
+    });
+    it('gpus', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Google',
+        gpus: 2,
+        gpuType: 'nvidia-tesla-v100',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Google',
+        gpus: 2,
+        gpuType: 'nvidia-tesla-v100',
+      }]);
+      expect(() => new b.Machine({ gpus: 1.5 }))
+        .to.throw('gpus must be a non-negative integer (was: 1.5)');
+      expect(() => new b.Machine({ gpuType: 'nvidia-tesla-k80' }))
+        .to.throw('gpuType requires gpus');
+    });
+    it('arch', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        arch: 'arm64',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        arch: 'arm64',
+      }]);
+      expect(() => new b.Machine({ arch: 'x86' }))
+        .to.throw('arch must be \'amd64\' or \'arm64\' (was: "x86")');
+    });
+    it('instance profile', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        instanceProfile: 'app-server',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        instanceProfile: 'app-server',
+      }]);
+    });
+    it('security groups', () => {
+      const machine = new b.Machine({
+        provider: 'Amazon',
+        securityGroups: ['sg-0123456789abcdef0'],
+      });
+      deployment.deploy(machine.asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        securityGroups: ['sg-0123456789abcdef0'],
+      }]);
+
+      // Clones don't share the list.
+      const clone = machine.clone();
+      clone.securityGroups.push('sg-1');
+      expect(machine.securityGroups).to.deep.equal(['sg-0123456789abcdef0']);
+
+      expect(() => new b.Machine({ securityGroups: 'sg-1' }))
+        .to.throw('securityGroups must be an array of strings (was: "sg-1")');
+    });
+    it('zone and placement group', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        region: 'us-west-2',
+        zone: 'us-west-2b',
+        placementGroup: 'pool',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        region: 'us-west-2',
+        zone: 'us-west-2b',
+        placementGroup: 'pool',
+      }]);
+    });
+    it('image', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        image: 'ami-0123456789abcdef0',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        image: 'ami-0123456789abcdef0',
+      }]);
+    });
+    it('volume type', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Amazon',
+        volumeType: 'io2',
+        iops: 4000,
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Amazon',
+        volumeType: 'io2',
+        iops: 4000,
+      }]);
+    });
+    it('iops without a volume type', () => {
+      expect(() => new b.Machine({ provider: 'Amazon', iops: 4000 }))
+        .to.throw('iops requires volumeType');
+    });
+    it('disk type', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Google',
+        diskType: 'pd-ssd',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Google',
+        diskType: 'pd-ssd',
+      }]);
+      expect(() => new b.Machine({ provider: 'Google', diskType: 'ssd' }))
+        .to.throw('diskType must be one of pd-standard,pd-ssd,local-ssd ' +
+          '(was: "ssd")');
+    });
+    it('ipv6', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'DigitalOcean',
+        ipv6: true,
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'DigitalOcean',
+        ipv6: true,
+      }]);
+      expect(() => new b.Machine({ provider: 'DigitalOcean', ipv6: 'yes' }))
+        .to.throw('ipv6 must be a boolean (was: "yes")');
+    });
+    it('block storage volumes', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'DigitalOcean',
+        volumes: [
+          { name: 'data', size: 100, persistent: true },
+          { name: 'scratch', size: 10 },
+        ],
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'DigitalOcean',
+        volumes: [
+          { name: 'data', size: 100, persistent: true },
+          { name: 'scratch', size: 10, persistent: false },
+        ],
+      }]);
+    });
+    it('malformed block storage volumes', () => {
+      expect(() => new b.Machine({ volumes: [{ size: 10 }] }))
+        .to.throw('volume must have a name (was: {"size":10})');
+      expect(() => new b.Machine({ volumes: [{ name: 'data', size: 0 }] }))
+        .to.throw('volume size must be a positive integer (was: 0)');
+      expect(() => new b.Machine({
+        volumes: [{ name: 'data', size: 10, type: 'ssd' }],
+      })).to.throw('Unrecognized keys passed to volume: type');
+    });
+    it('static host', () => {
+      deployment.deploy(new b.Machine({
+        provider: 'Static',
+        host: '10.0.0.5',
+        sshUser: 'ubuntu',
+        sshKeyPath: '/home/user/.ssh/lab',
+      }).asWorker());
+      checkMachines([{
+        role: 'Worker',
+        provider: 'Static',
+        host: '10.0.0.5',
+        sshUser: 'ubuntu',
+        sshKeyPath: '/home/user/.ssh/lab',
+      }]);
+    });
+    it('malformed lifecycle hooks', () => {
+      expect(() => new b.Machine({ hooks: [{ event: 'reboot', command: 'x' }] }))
+        .to.throw('hook event must be one of ' +
+          'pre-boot,post-connect,pre-stop,disk-usage (was: "reboot")');
+      expect(() => new b.Machine({ hooks: [{ event: 'pre-boot' }] }))
+        .to.throw('hook must have either a command or a url');
+      expect(() => new b.Machine({
+        hooks: [{ event: 'pre-boot', command: 'x', timeout: 5 }],
+      })).to.throw('Unrecognized keys passed to hook: timeout');
+    });
   });
 
   describe('Container', () => {
@@ -198,6 +492,95 @@ describe('Bindings', () => {
         filepathToContent: {},
       }]);
     });
+    it('lifecycle hooks', () => {
+      const container = new b.Container('host', 'image', {
+        postStart: ['warm', 'cache'],
+        preStop: ['deregister'],
+      });
+      container.deploy(deployment);
+      checkContainers([{
+        hostname: 'host',
+        postStart: ['warm', 'cache'],
+        preStop: ['deregister'],
+      }]);
+    });
+    it('exposeMetadata', () => {
+      const container = new b.Container('host', 'image', {
+        exposeMetadata: true,
+      });
+      container.deploy(deployment);
+      checkContainers([{ hostname: 'host', exposeMetadata: true }]);
+    });
+    it('cloudRole', () => {
+      const role = 'arn:aws:iam::123456789012:role/uploader';
+      const container = new b.Container('host', 'image', { cloudRole: role });
+      container.deploy(deployment);
+      checkContainers([{ hostname: 'host', cloudRole: role }]);
+      expect(() => new b.Container('host', 'image', { cloudRole: 'uploader' }))
+        .to.throw('cloudRole must be the ARN of an IAM role (was: "uploader")');
+    });
+    it('reload signal', () => {
+      const container = new b.Container('host', 'image', {
+        filepathToContent: { '/etc/app.conf': 'conf' },
+        reloadSignal: 'SIGHUP',
+      });
+      container.deploy(deployment);
+      checkContainers([{
+        hostname: 'host',
+        filepathToContent: { '/etc/app.conf': 'conf' },
+        reloadSignal: 'SIGHUP',
+      }]);
+      expect(() => new b.Container('host', 'image', { reloadSignal: 'SIGKILL' }))
+        .to.throw('reloadSignal must be one of SIGHUP,SIGUSR1,SIGUSR2 ' +
+          '(was: "SIGKILL")');
+    });
+    it('stopped', () => {
+      const stopped = new b.Container('host', 'image', { stopped: true });
+      stopped.deploy(deployment);
+      checkContainers([{ hostname: 'host', stopped: true }]);
+    });
+    it('tty', () => {
+      const container = new b.Container('host', 'image', { tty: true });
+      container.deploy(deployment);
+      checkContainers([{ hostname: 'host', tty: true }]);
+    });
+    it('networks', () => {
+      const container = new b.Container('host', 'image', {
+        networks: ['vpc'],
+      });
+      container.deploy(deployment);
+      checkContainers([{ hostname: 'host', networks: ['vpc'] }]);
+      expect(() => new b.Container('host', 'image', { networks: ['host'] }))
+        .to.throw('networks must not include host,quilt (was: ["host"])');
+    });
+    it('dns and hosts', () => {
+      const container = new b.Container('host', 'image', {
+        dns: ['192.168.1.53'],
+        dnsSearch: ['corp.internal'],
+        extraHosts: { legacy: '192.168.1.10' },
+      });
+      container.deploy(deployment);
+      checkContainers([{
+        hostname: 'host',
+        dns: ['192.168.1.53'],
+        dnsSearch: ['corp.internal'],
+        extraHosts: { legacy: '192.168.1.10' },
+      }]);
+      expect(() => new b.Container('host', 'image', { dns: ['ns1'] }))
+        .to.throw('dns must be a list of IP addresses (was: ["ns1"])');
+      expect(() => new b.Container('host', 'image', {
+        extraHosts: { legacy: 'legacy.corp' },
+      })).to.throw('extraHosts must map hostnames to IP addresses ' +
+        '(was: {"legacy":"legacy.corp"})');
+    });
+    it('replicas share a label', () => {
+      new b.Container('host', 'image').deploy(deployment);
+      new b.Container('host', 'image').deploy(deployment);
+      checkContainers([
+        { hostname: 'host', label: 'host' },
+        { hostname: 'host2', label: 'host' },
+      ]);
+    });
     it('command', () => {
       const container = new b.Container('host', 'image', {
         command: ['arg1', 'arg2'],
@@ -385,6 +768,23 @@ describe('Bindings', () => {
         floatingIp: 'xxx.xxx.xxx.xxx',
       }]);
     });
+    it('MachineRule label', () => {
+      target.placeOn({ label: 'ssd=true' });
+      target.placeOn({ label: 'compliance=pci' });
+      checkPlacements([{
+        targetContainerID: '293fc7ad8a799d3cf2619a3db7124b0459f395cb',
+        exclusive: false,
+        label: 'ssd=true',
+      }, {
+        targetContainerID: '293fc7ad8a799d3cf2619a3db7124b0459f395cb',
+        exclusive: false,
+        label: 'compliance=pci',
+      }]);
+    });
+    it('MachineRule malformed label', () => {
+      expect(() => target.placeOn({ label: 'ssd' })).to.throw(
+        'label must be in the form key=value (was: "ssd")');
+    });
   });
   describe('LoadBalancer', () => {
     it('basic', () => {
@@ -434,6 +834,67 @@ describe('Bindings', () => {
         },
       ]);
     });
+    it('canary', () => {
+      const lb = new b.LoadBalancer('web_tier',
+        [new b.Container('stable', 'nginx')], {
+          canary: new b.Container('canary', 'nginx:next'),
+          canaryFraction: 0.05,
+        });
+      lb.deploy(deployment);
+      checkLoadBalancers([{
+        name: 'web_tier',
+        hostnames: ['stable'],
+        canaryHostnames: ['canary'],
+        canaryFraction: 0.05,
+      }]);
+    });
+    it('drain period', () => {
+      const lb = new b.LoadBalancer('web_tier',
+        [new b.Container('web', 'nginx')], { drainPeriod: 30 });
+      lb.deploy(deployment);
+      checkLoadBalancers([{
+        name: 'web_tier',
+        hostnames: ['web'],
+        drainPeriod: 30,
+      }]);
+    });
+    it('drain period must be a non-negative integer', () => {
+      expect(() => new b.LoadBalancer('foo', [], { drainPeriod: -1 }))
+        .to.throw('drainPeriod must be a non-negative integer (was: -1)');
+      expect(() => new b.LoadBalancer('foo', [], { drainPeriod: 1.5 }))
+        .to.throw('drainPeriod must be a non-negative integer (was: 1.5)');
+    });
+    it('edge', () => {
+      const lb = new b.LoadBalancer('web_tier',
+        [new b.Container('web', 'nginx')], { edge: 'edge=true' });
+      lb.allowFrom(b.publicInternet, 80);
+      lb.deploy(deployment);
+      checkLoadBalancers([{
+        name: 'web_tier',
+        hostnames: ['web'],
+        edge: 'edge=true',
+      }]);
+      checkConnections([{
+        from: 'public',
+        to: 'web_tier',
+        minPort: 80,
+        maxPort: 80,
+      }]);
+    });
+    it('edge must be a label', () => {
+      expect(() => new b.LoadBalancer('foo', [], { edge: 'edge' }))
+        .to.throw('edge must be a label in the form key=value (was: "edge")');
+    });
+    it('public connections require edge machines', () => {
+      const lb = new b.LoadBalancer('foo', []);
+      expect(() => lb.allowFrom(b.publicInternet, 80)).to.throw(
+        'only load balancers with edge machines can accept connections ' +
+        'from the public internet');
+    });
+    it('canary fraction out of range', () => {
+      expect(() => new b.LoadBalancer('foo', [], { canaryFraction: 1.5 }))
+        .to.throw('canaryFraction must be between 0 and 1 (was: 1.5)');
+    });
     it('get LoadBalancer hostname', () => {
       const foo = new b.LoadBalancer('foo', []);
       expect(foo.hostname()).to.equal('foo.q');
@@ -478,6 +939,32 @@ describe('Bindings', () => {
         maxPort: 85,
       }]);
     });
+    it('mtls', () => {
+      bar.allowFrom(foo, 80, { mtls: true });
+      fooLoadBalancer.allowFrom(bar, 443, { mtls: true });
+      checkConnections([
+        {
+          from: 'foo',
+          to: 'bar',
+          minPort: 80,
+          maxPort: 80,
+          mtls: true,
+        },
+        {
+          from: 'bar',
+          to: 'fooLoadBalancer',
+          minPort: 443,
+          maxPort: 443,
+          mtls: true,
+        },
+      ]);
+    });
+    it('mtls with publicInternet', () => {
+      expect(() => foo.allowFrom(b.publicInternet, 80, { mtls: true })).to
+        .throw('connections from the public internet can\'t use mTLS');
+      expect(() => b.publicInternet.allowFrom(foo, 80, { mtls: true })).to
+        .throw('connections to the public internet can\'t use mTLS');
+    });
     it('connect to invalid port range', () => {
       expect(() => foo.allowFrom(bar, true)).to
         .throw('Input argument must be a number or a Range');
@@ -769,6 +1256,23 @@ describe('Bindings', () => {
     it('default admin ACL', () => {
       expect(deployment.toQuiltRepresentation().adminACL).to.eql([]);
     });
+    it('max price', () => {
+      deployment = new b.Deployment({ maxPrice: 0.5 });
+      expect(deployment.toQuiltRepresentation().maxPrice).to.equal(0.5);
+    });
+    it('on-demand fallback', () => {
+      expect(deployment.toQuiltRepresentation().onDemandFallback).to.equal(
+        false);
+      deployment = new b.Deployment({ onDemandFallback: true });
+      expect(deployment.toQuiltRepresentation().onDemandFallback).to.equal(
+        true);
+    });
+    it('spread masters', () => {
+      expect(deployment.toQuiltRepresentation().spreadMasters).to.equal(
+        false);
+      deployment = new b.Deployment({ spreadMasters: true });
+      expect(deployment.toQuiltRepresentation().spreadMasters).to.equal(true);
+    });
   });
   describe('githubKeys()', () => {});
   describe('baseInfrastructure()', () => {
@@ -19,6 +19,19 @@ type Blueprint struct {
 
 	AdminACL  []string `json:",omitempty"`
 	Namespace string   `json:",omitempty"`
+
+	// The maximum hourly price bid for preemptible machines that don't set
+	// their own.
+	MaxPrice float64 `json:",omitempty"`
+
+	// Whether preemptible machines are booted on-demand instead once
+	// preemptible capacity repeatedly fails to boot, or is reclaimed right
+	// after booting.
+	OnDemandFallback bool `json:",omitempty"`
+
+	// Whether Amazon masters that don't choose their own availability zone or
+	// subnet are spread across the zones of their region.
+	SpreadMasters bool `json:",omitempty"`
 }
 
 // A Placement constraint guides on what type of machine a container can be
@@ -33,6 +46,9 @@ type Placement struct {
 	Size       string `json:",omitempty"`
 	Region     string `json:",omitempty"`
 	FloatingIP string `json:",omitempty"`
+
+	// A machine label in the form "key=value", e.g. "ssd=true".
+	Label string `json:",omitempty"`
 }
 
 // An Image represents a Docker image that can be run. If the Dockerfile is non-empty,
@@ -50,12 +66,70 @@ type Container struct {
 	Env               map[string]string `json:",omitempty"`
 	FilepathToContent map[string]string `json:",omitempty"`
 	Hostname          string            `json:",omitempty"`
+	PriorityClass     string            `json:",omitempty"`
+
+	// The hostname shared by the replicas of the container, before it was made
+	// unique.
+	Label string `json:",omitempty"`
+
+	// Commands executed in the container after it starts, and before it's
+	// stopped.
+	PostStart []string `json:",omitempty"`
+	PreStop   []string `json:",omitempty"`
+
+	// The signal, e.g. SIGHUP, that makes the container reload its files.  If
+	// it's set, changed files are copied into the running container rather
+	// than restarting it.
+	ReloadSignal string `json:",omitempty"`
+
+	// The names of Docker networks that the container joins in addition to
+	// the Quilt network.
+	Networks []string `json:",omitempty"`
+
+	// The DNS servers that the container queries after Quilt's own, and its
+	// search domains in addition to Quilt's.  They let containers resolve
+	// names outside of Quilt, such as legacy internal names.
+	DNS       []string `json:",omitempty"`
+	DNSSearch []string `json:",omitempty"`
+
+	// Entries added to the container's /etc/hosts, from hostname to IP.
+	ExtraHosts map[string]string `json:",omitempty"`
+
+	// Whether the container's environment describes its machine and its own
+	// identity.
+	ExposeMetadata bool `json:",omitempty"`
+
+	// The ARN of the Amazon IAM role whose short-lived credentials the
+	// container's minion issues to it.
+	CloudRole string `json:",omitempty"`
+
+	// Whether the container is allocated a TTY, with its stdin kept open, so
+	// that it can be attached to.
+	TTY bool `json:",omitempty"`
+
+	// Whether the container is intentionally stopped.  Stopped containers
+	// aren't run, but keep their IP and hostname.
+	Stopped bool `json:",omitempty"`
 }
 
 // A LoadBalancer represents a load balanced group of containers.
 type LoadBalancer struct {
 	Name      string   `json:",omitempty"`
 	Hostnames []string `json:",omitempty"`
+
+	// The containers that receive CanaryFraction of the traffic, while the
+	// rest is sent to Hostnames.
+	CanaryHostnames []string `json:",omitempty"`
+	CanaryFraction  float64  `json:",omitempty"`
+
+	// The number of seconds that containers removed from the load balancer
+	// are kept running, so that their established connections can finish.
+	DrainPeriod int `json:",omitempty"`
+
+	// A machine label in the form "key=value".  Connections from the public
+	// internet to the load balancer are only accepted by the machines with the
+	// label, which proxy them to the load balancer.
+	Edge string `json:",omitempty"`
 }
 
 // A Connection allows the container with the `From` hostname to speak to the container
@@ -65,11 +139,26 @@ type Connection struct {
 	To      string `json:",omitempty"`
 	MinPort int    `json:",omitempty"`
 	MaxPort int    `json:",omitempty"`
+
+	// Whether the connection is wrapped in mutual TLS by proxies that run
+	// alongside the containers.
+	MTLS bool `json:",omitempty"`
 }
 
 // A ConnectionSlice allows for slices of Collections to be used in joins
 type ConnectionSlice []Connection
 
+// AutoFloatingIP is the FloatingIP of machines whose floating IP is allocated by
+// their provider when they boot, and released when they're stopped.
+const AutoFloatingIP = "auto"
+
+// The CPU architectures that machines may have.  Machines that don't specify
+// an architecture are AMD64.
+const (
+	AMD64 = "amd64"
+	ARM64 = "arm64"
+)
+
 // A Machine specifies the type of VM that should be booted.
 type Machine struct {
 	ID          string   `json:",omitempty"`
@@ -80,11 +169,156 @@ type Machine struct {
 	RAM         Range    `json:",omitempty"`
 	DiskSize    int      `json:",omitempty"`
 	Region      string   `json:",omitempty"`
+	Zone        string   `json:",omitempty"`
 	SSHKeys     []string `json:",omitempty"`
 	FloatingIP  string   `json:",omitempty"`
 	Preemptible bool     `json:",omitempty"`
+
+	// GitHub usernames whose public keys are granted SSH access, in addition
+	// to SSHKeys.  The daemon fetches the keys, and periodically refreshes them.
+	GithubKeys []string `json:",omitempty"`
+
+	// The minimum number of GPUs that the machine must have, and their type,
+	// e.g. "nvidia-tesla-k80".  An empty type accepts GPUs of any type.
+	GPUs    int    `json:",omitempty"`
+	GPUType string `json:",omitempty"`
+
+	// The machine's CPU architecture, AMD64 or ARM64, which restricts the sizes
+	// that may be chosen for it.
+	Arch string `json:",omitempty"`
+
+	// The maximum hourly price, in US dollars, bid for a preemptible machine.
+	// Zero selects the blueprint's MaxPrice, or the provider's default.
+	MaxPrice float64 `json:",omitempty"`
+
+	// Kernel tuning applied when the machine boots. Hugepages is the number
+	// of 2MB huge pages to reserve.
+	Sysctls   map[string]string `json:",omitempty"`
+	Hugepages int               `json:",omitempty"`
+
+	Hooks []MachineHook `json:",omitempty"`
+
+	// How the boot script is delivered to the machine.  Empty selects the
+	// provider's default.
+	Bootstrap string `json:",omitempty"`
+
+	// A pattern for the machine's hostname, which providers also use as the
+	// instance's name where they can.  The placeholders {role}, {index} and
+	// {region} are replaced by the machine's role, its position among the
+	// blueprint's machines of that role starting from 1, and its region.
+	Hostname string `json:",omitempty"`
+
+	// Key-value pairs that the provider attaches to the instance when it
+	// boots, e.g. for cost allocation.  Amazon applies them as tags, Google as
+	// labels, and DigitalOcean as tags of the form "key:value".
+	Tags map[string]string `json:",omitempty"`
+
+	// Key-value pairs that describe the machine to the scheduler, e.g.
+	// {"ssd": "true"}, which container placement rules may require.  Unlike
+	// tags, they aren't passed to the provider, and they're updated in place.
+	Labels map[string]string `json:",omitempty"`
+
+	// The existing Amazon VPC, and the subnet within it, that the machine is
+	// launched into.  Machines that don't specify them are launched into the
+	// region's default VPC.
+	VPC    string `json:",omitempty"`
+	Subnet string `json:",omitempty"`
+
+	// The name of the IAM instance profile attached to an Amazon machine, whose
+	// role's credentials are available to the machine's containers.
+	InstanceProfile string `json:",omitempty"`
+
+	// The IDs of existing Amazon security groups that the machine is launched
+	// into, in addition to the group that Quilt manages.  Quilt never changes
+	// their rules.  The managed group can't be left out, as Quilt finds its
+	// machines by their membership in it.
+	SecurityGroups []string `json:",omitempty"`
+
+	// The type of the EBS volume of an Amazon machine, e.g. "gp3" or "io2", and
+	// its provisioned IOPS.  The type defaults to "gp2", whose IOPS are fixed
+	// by the volume's size.
+	VolumeType string `json:",omitempty"`
+	IOPS       int    `json:",omitempty"`
+
+	// The type of a Google machine's disk: "pd-standard", "pd-ssd", or
+	// "local-ssd", which boots from a standard persistent disk and attaches a
+	// local SSD.  Empty selects "pd-standard".
+	DiskType string `json:",omitempty"`
+
+	// Block storage volumes attached to a DigitalOcean machine when it boots.
+	Volumes []MachineVolume `json:",omitempty"`
+
+	// Whether the machine is given a public IPv6 address, in addition to its
+	// IPv4 addresses.  Amazon machines must be launched into a subnet with an
+	// IPv6 CIDR block.
+	IPv6 bool `json:",omitempty"`
+
+	// The Amazon placement group that the machine is launched into.  Quilt
+	// creates the group, with the cluster strategy, if it doesn't exist.
+	PlacementGroup string `json:",omitempty"`
+
+	// The image that the machine boots from in place of the provider's default
+	// Ubuntu image: an AMI ID on Amazon, an image URL on Google, or a snapshot
+	// ID on DigitalOcean.
+	Image string `json:",omitempty"`
+
+	// The IP address of the pre-existing host that a Static machine runs on,
+	// and the user and private key file with which the daemon logs in to it
+	// over SSH.  The key defaults to the daemon's SSH key.
+	Host       string `json:",omitempty"`
+	SSHUser    string `json:",omitempty"`
+	SSHKeyPath string `json:",omitempty"`
+}
+
+// A MachineHook is run by the daemon when a machine reaches the lifecycle point
+// named by Event.  Exactly one of Command, a shell command, or URL, a webhook that
+// receives a POST describing the machine, must be set.
+type MachineHook struct {
+	Event   string
+	Command string `json:",omitempty"`
+	URL     string `json:",omitempty"`
 }
 
+// A MachineVolume is a block storage volume, of Size gigabytes, attached to a
+// machine.  Volumes are deleted when their machine stops, unless they're
+// Persistent, in which case they're kept and attached to the next machine that
+// declares a volume of the same Name.
+type MachineVolume struct {
+	Name       string
+	Size       int
+	Persistent bool `json:",omitempty"`
+}
+
+// The machine lifecycle events that hooks may be registered for.
+const (
+	// PreBoot hooks run before the machine is booted.  If a hook fails, the
+	// machine isn't booted until the hooks are retried.
+	PreBoot = "pre-boot"
+
+	// PostConnect hooks run when the daemon connects to the machine's minion.
+	PostConnect = "post-connect"
+
+	// PreStop hooks run before the machine is stopped.  The machine is stopped
+	// even if a hook fails.
+	PreStop = "pre-stop"
+
+	// DiskUsage hooks run when the usage of one of the machine's partitions
+	// rises past 80%, 90%, or 95%.
+	DiskUsage = "disk-usage"
+)
+
+// The ways a machine's boot script may be delivered.
+const (
+	// UserDataBootstrap passes the boot script to the provider as user-data,
+	// which is run by cloud-init.
+	UserDataBootstrap = "user-data"
+
+	// SSHBootstrap passes the provider a minimal script that only grants the
+	// daemon SSH access.  The daemon then runs the boot script over SSH.  This
+	// is for images that limit the size of user-data, or don't run cloud-init.
+	SSHBootstrap = "ssh"
+)
+
 // A Range defines a range of acceptable values for a Machine attribute
 type Range struct {
 	Min float64 `json:",omitempty"`
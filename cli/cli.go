@@ -10,7 +10,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// Note the `minion` command is in quiltctl_posix.go as it only runs on posix systems.
+// Note the `minion` and `tls-proxy` commands are in cli_posix.go as they only run on
+// posix systems.
 var commands = map[string]command.SubCommand{
 	"daemon":  command.NewDaemonCommand(),
 	"inspect": &inspect.Inspect{},
@@ -26,6 +27,23 @@ var commands = map[string]command.SubCommand{
 	"version":    command.NewVersionCommand(),
 	"debug-logs": command.NewDebugCommand(),
 	"counters":   &command.Counters{},
+	"decisions":  &command.Decisions{},
+	"etcd":       &command.Etcd{},
+	"log-level":  &command.LogLevel{},
+	"settings":   &command.Settings{},
+	"ready":      &command.Ready{},
+	"simulate":   &command.Simulate{},
+	"cost":       &command.Cost{},
+	"attach":     &command.Attach{},
+	"reboot":     command.NewRebootCommand(),
+	"reimage":    command.NewReimageCommand(),
+	"resources":  &command.Resources{},
+	"edge-proxy": &command.EdgeProxy{},
+	"mirror":     &command.Mirror{},
+	"canary":     &command.Canary{},
+
+	"restart":         command.NewRestartCommand(),
+	"stop-containers": command.NewStopContainersCommand(),
 }
 
 // Run parses and runs the cli subcommand given the command line arguments.
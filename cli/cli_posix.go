@@ -6,4 +6,5 @@ import "github.com/kelda/kelda/cli/command"
 
 func init() {
 	commands["minion"] = command.NewMinionCommand()
+	commands["tls-proxy"] = &command.TLSProxy{}
 }
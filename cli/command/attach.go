@@ -0,0 +1,124 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/util"
+)
+
+// Attach implements the `quilt attach` command.
+type Attach struct {
+	target string
+
+	connectionHelper
+}
+
+var attachCommands = "quilt attach [OPTIONS] ID"
+var attachExplanation = `Attach to the main process of a running container.
+
+The container's output is printed until it exits.  Input is only forwarded to
+containers whose blueprint sets the "tty" option, in which case the local
+terminal is put into raw mode.  Type Ctrl-P Ctrl-Q to detach from the container
+without stopping it.
+
+To attach to container 8879fd2dbcee:
+quilt attach 8879fd2dbcee`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Attach) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.Usage = func() {
+		util.PrintUsageString(attachCommands, attachExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the attach command.
+func (cmd *Attach) Parse(args []string) error {
+	if len(args) == 0 {
+		return errors.New("must specify a target container")
+	}
+
+	cmd.target = args[0]
+	return nil
+}
+
+// Run attaches to the container.
+func (cmd *Attach) Run() int {
+	host, cont, err := getContainer(cmd.client, cmd.target)
+	if err != nil {
+		log.WithError(err).Error("Failed to resolve target container")
+		return 1
+	}
+
+	if cont.DockerID == "" {
+		log.Error("Container not yet running")
+		return 1
+	}
+
+	resize := make(chan api.TerminalSize, 1)
+	restore, err := setupTerminal(resize)
+	if err != nil {
+		log.WithError(err).Error("Failed to set up terminal")
+		return 1
+	}
+
+	err = cmd.client.Attach(host, cont.DockerID, os.Stdin, os.Stdout, resize)
+	restore()
+	if err != nil {
+		log.WithError(err).Error("Failed to attach to container")
+		return 1
+	}
+	return 0
+}
+
+// setupTerminal puts stdin into raw mode if it's a terminal, and reports its
+// size on `resize` whenever it changes.  The returned function restores the
+// terminal to its original state.
+var setupTerminal = func(resize chan<- api.TerminalSize) (func(), error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return func() {}, nil
+	}
+
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	notifyResize(sig)
+	go func() {
+		for {
+			width, height, err := terminal.GetSize(fd)
+			if err != nil {
+				log.WithError(err).Warn("Error getting terminal window size")
+			} else {
+				select {
+				case resize <- api.TerminalSize{Height: height, Width: width}:
+				case <-done:
+					return
+				}
+			}
+
+			select {
+			case <-sig:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sig)
+		close(done)
+		terminal.Restore(fd, state)
+	}, nil
+}
@@ -0,0 +1,13 @@
+// +build !windows
+
+package command
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifyResize(sig chan os.Signal) {
+	signal.Notify(sig, syscall.SIGWINCH)
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/db"
+)
+
+func TestAttach(t *testing.T) {
+	var restored bool
+	setupTerminal = func(chan<- api.TerminalSize) (func(), error) {
+		return func() { restored = true }, nil
+	}
+
+	mc := new(mocks.Client)
+	mc.On("QueryMachines").Return([]db.Machine{
+		{BlueprintID: "m", PublicIP: "8.8.8.8", PrivateIP: "9.9.9.9"}}, nil)
+	mc.On("QueryContainers").Return([]db.Container{
+		{BlueprintID: "running", DockerID: "dockerID", Minion: "9.9.9.9"},
+		{BlueprintID: "pending"}}, nil)
+	mc.On("Attach", "8.8.8.8", "dockerID", os.Stdin, os.Stdout,
+		mock.Anything).Return(nil).Once()
+
+	cmd := &Attach{target: "running"}
+	cmd.client = mc
+	assert.Equal(t, 0, cmd.Run())
+	assert.True(t, restored)
+
+	mc.On("Attach", "8.8.8.8", "dockerID", os.Stdin, os.Stdout,
+		mock.Anything).Return(assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+
+	cmd.target = "pending"
+	assert.Equal(t, 1, cmd.Run())
+
+	cmd.target = "missing"
+	assert.Equal(t, 1, cmd.Run())
+
+	assert.EqualError(t, (&Attach{}).Parse(nil),
+		"must specify a target container")
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kelda/kelda/util"
+)
+
+// Canary implements the `quilt canary` command.
+type Canary struct {
+	loadBalancer string
+	fraction     float64
+
+	connectionHelper
+}
+
+var canaryCommands = "quilt canary [OPTIONS] LOAD_BALANCER FRACTION"
+var canaryExplanation = `Change the fraction of a load balancer's traffic that is
+sent to its canary containers.
+
+FRACTION must be between 0 and 1, and is rounded to the nearest percent.  The
+change takes effect without redeploying the blueprint, but is reverted to the
+blueprint's canaryFraction when the blueprint is next deployed.
+
+To send 5% of the traffic to the canaries of the load balancer web:
+quilt canary web 0.05`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Canary) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.Usage = func() {
+		util.PrintUsageString(canaryCommands, canaryExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the canary command.
+func (cmd *Canary) Parse(args []string) error {
+	if len(args) != 2 {
+		return errors.New("must specify a load balancer and a fraction")
+	}
+
+	fraction, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || fraction < 0 || fraction > 1 {
+		return fmt.Errorf("fraction must be a number between 0 and 1: %s",
+			args[1])
+	}
+
+	cmd.loadBalancer = args[0]
+	cmd.fraction = fraction
+	return nil
+}
+
+// Run sets the canary fraction of the load balancer.
+func (cmd *Canary) Run() int {
+	if err := cmd.client.SetCanary(cmd.loadBalancer, cmd.fraction); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set canary fraction: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+)
+
+func TestCanaryParse(t *testing.T) {
+	t.Parallel()
+
+	cmd := &Canary{}
+	assert.EqualError(t, cmd.Parse([]string{"web"}),
+		"must specify a load balancer and a fraction")
+	assert.EqualError(t, cmd.Parse([]string{"web", "half"}),
+		"fraction must be a number between 0 and 1: half")
+	assert.EqualError(t, cmd.Parse([]string{"web", "5"}),
+		"fraction must be a number between 0 and 1: 5")
+
+	assert.NoError(t, cmd.Parse([]string{"web", "0.05"}))
+	assert.Equal(t, "web", cmd.loadBalancer)
+	assert.Equal(t, 0.05, cmd.fraction)
+}
+
+func TestCanaryRun(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("SetCanary", "web", 0.05).Return(nil).Once()
+	mc.On("SetCanary", "missing", 0.05).Return(assert.AnError).Once()
+
+	cmd := &Canary{loadBalancer: "web", fraction: 0.05}
+	cmd.client = mc
+	assert.Zero(t, cmd.Run())
+
+	cmd.loadBalancer = "missing"
+	assert.NotZero(t, cmd.Run())
+	mc.AssertExpectations(t)
+}
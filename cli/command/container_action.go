@@ -0,0 +1,109 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kelda/kelda/util"
+)
+
+const (
+	restartAction = "restart"
+	stopAction    = "stop-containers"
+)
+
+// ContainerAction implements the `quilt restart` and `quilt stop-containers`
+// commands.
+type ContainerAction struct {
+	action   string
+	hostname string
+	label    string
+
+	connectionHelper
+}
+
+// NewRestartCommand creates a new ContainerAction command that restarts
+// containers.
+func NewRestartCommand() *ContainerAction {
+	return &ContainerAction{action: restartAction}
+}
+
+// NewStopContainersCommand creates a new ContainerAction command that stops
+// containers.
+func NewStopContainersCommand() *ContainerAction {
+	return &ContainerAction{action: stopAction}
+}
+
+var containerActionExplanations = map[string]string{
+	restartAction: `Restart the containers whose hostname matches a glob pattern,
+or that have a label.  If both are given, only containers matching both are
+restarted.  The minions running the containers restart them concurrently.
+
+To restart every container whose hostname starts with "web":
+quilt restart 'web*'
+
+To restart every container labeled "spark-worker":
+quilt restart -label spark-worker`,
+
+	stopAction: `Stop the containers whose hostname matches a glob pattern, or
+that have a label, in the same manner as ` + "`quilt restart`" + `.  The
+containers stay stopped until the blueprint is next deployed.
+
+To stop every container whose hostname starts with "web":
+quilt stop-containers 'web*'`,
+}
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *ContainerAction) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.StringVar(&cmd.label, "label", "", "the label of the containers")
+	flags.Usage = func() {
+		util.PrintUsageString(
+			fmt.Sprintf("quilt %s [OPTIONS] [HOSTNAME_PATTERN]", cmd.action),
+			containerActionExplanations[cmd.action], flags)
+	}
+}
+
+// Parse parses the command line arguments for the command.
+func (cmd *ContainerAction) Parse(args []string) error {
+	if len(args) > 1 {
+		return errors.New("must specify at most one hostname pattern")
+	}
+
+	if len(args) == 1 {
+		cmd.hostname = args[0]
+	}
+
+	if cmd.hostname == "" && cmd.label == "" {
+		return errors.New("must specify a hostname pattern or label")
+	}
+	return nil
+}
+
+// Run requests the action on the selected containers.
+func (cmd *ContainerAction) Run() int {
+	var hostnames []string
+	var err error
+	if cmd.action == restartAction {
+		hostnames, err = cmd.client.RestartContainers(cmd.hostname, cmd.label,
+			nil)
+	} else {
+		hostnames, err = cmd.client.StopContainers(cmd.hostname, cmd.label)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("Failed to %s", cmd.action)
+		return 1
+	}
+
+	verb := "Restarted"
+	if cmd.action == stopAction {
+		verb = "Stopped"
+	}
+	fmt.Printf("%s %d container(s): %s\n", verb, len(hostnames),
+		strings.Join(hostnames, ", "))
+	return 0
+}
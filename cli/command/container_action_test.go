@@ -0,0 +1,47 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+)
+
+func TestContainerActionParse(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewRestartCommand()
+	assert.EqualError(t, cmd.Parse(nil),
+		"must specify a hostname pattern or label")
+	assert.EqualError(t, cmd.Parse([]string{"web*", "db"}),
+		"must specify at most one hostname pattern")
+
+	assert.NoError(t, cmd.Parse([]string{"web*"}))
+	assert.Equal(t, "web*", cmd.hostname)
+
+	cmd = NewStopContainersCommand()
+	cmd.label = "web"
+	assert.NoError(t, cmd.Parse(nil))
+	assert.Empty(t, cmd.hostname)
+}
+
+func TestContainerActionRun(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("RestartContainers", "web*", "", []string(nil)).Return(
+		[]string{"web-1", "web-2"}, nil)
+	mc.On("StopContainers", "", "db").Return(nil, assert.AnError)
+
+	cmd := NewRestartCommand()
+	cmd.client = mc
+	cmd.hostname = "web*"
+	assert.Zero(t, cmd.Run())
+
+	cmd = NewStopContainersCommand()
+	cmd.client = mc
+	cmd.label = "db"
+	assert.NotZero(t, cmd.Run())
+	mc.AssertExpectations(t)
+}
@@ -0,0 +1,87 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/util"
+)
+
+// Cost implements the `quilt cost` command.
+type Cost struct {
+	blueprint string
+
+	connectionHelper
+}
+
+// The number of hours in an average month.
+const hoursPerMonth = 730
+
+var costCommands = "quilt cost [OPTIONS] [BLUEPRINT]"
+var costExplanation = `Estimate the hourly cost of a blueprint's machines.
+
+Without a blueprint, the cost of the deployed machines is estimated.  The
+estimates are based on the providers' on-demand prices, and preemptible machines
+are assumed to get a typical discount, capped by their maximum price.  Machines
+whose prices aren't known are left out of the total.`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Cost) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.StringVar(&cmd.blueprint, "blueprint", "",
+		"the blueprint whose cost to estimate")
+	flags.Usage = func() {
+		util.PrintUsageString(costCommands, costExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the cost command.
+func (cmd *Cost) Parse(args []string) error {
+	if cmd.blueprint == "" && len(args) > 0 {
+		cmd.blueprint = args[0]
+	}
+	return nil
+}
+
+// Run prints the estimated cost of the machines.
+func (cmd *Cost) Run() int {
+	var bp string
+	if cmd.blueprint != "" {
+		compiled, err := compile(cmd.blueprint)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		bp = compiled.String()
+	}
+
+	reply, err := cmd.client.QueryCost(bp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error estimating cost: %s\n", err)
+		return 1
+	}
+
+	printCost(os.Stdout, reply)
+	return 0
+}
+
+func printCost(out io.Writer, reply pb.CostReply) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\tPROVIDER\tREGION\tSIZE\tPREEMPTIBLE\tHOURLY")
+	for _, m := range reply.Machines {
+		hourly := fmt.Sprintf("$%.4f", m.Hourly)
+		if m.Unknown {
+			hourly = "unknown"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", util.ShortUUID(m.ID),
+			m.Provider, m.Region, m.Size, m.Preemptible, hourly)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nEstimated cost: $%.2f per hour, or $%.2f per month.\n",
+		reply.Total, reply.Total*hoursPerMonth)
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+)
+
+func TestCost(t *testing.T) {
+	compile = func(path string) (blueprint.Blueprint, error) {
+		return blueprint.Blueprint{Namespace: path}, nil
+	}
+	defer func() { compile = blueprint.FromFile }()
+
+	bp := blueprint.Blueprint{Namespace: "bp"}.String()
+	mock := new(mocks.Client)
+	mock.On("QueryCost", bp).Return(pb.CostReply{}, nil).Once()
+	cmd := &Cost{}
+	assert.NoError(t, cmd.Parse([]string{"bp"}))
+	cmd.client = mock
+	assert.Equal(t, 0, cmd.Run())
+
+	// Without a blueprint, the deployed machines are estimated.
+	mock.On("QueryCost", "").Return(pb.CostReply{}, nil).Once()
+	cmd = &Cost{}
+	cmd.client = mock
+	assert.Equal(t, 0, cmd.Run())
+
+	mock.On("QueryCost", "").Return(pb.CostReply{}, assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintCost(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printCost(&b, pb.CostReply{
+		Machines: []*pb.MachineCost{
+			{ID: "1", Provider: "Amazon", Region: "us-east-1",
+				Size: "m4.large", Preemptible: true, Hourly: 0.036},
+			{ID: "2", Provider: "Amazon", Size: "custom", Unknown: true},
+		},
+		Total: 0.036,
+	})
+	assert.Equal(t, `MACHINE  PROVIDER  REGION     SIZE      PREEMPTIBLE  HOURLY
+1        Amazon    us-east-1  m4.large  true         $0.0360
+2        Amazon               custom    false        unknown
+
+Estimated cost: $0.04 per hour, or $26.28 per month.
+`, b.String())
+}
@@ -6,20 +6,40 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/client"
 	"github.com/kelda/kelda/api/server"
+	"github.com/kelda/kelda/blueprint"
 	cliPath "github.com/kelda/kelda/cli/path"
 	"github.com/kelda/kelda/cloud"
+	"github.com/kelda/kelda/cloud/assets"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/foreman"
+	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls"
 	tlsIO "github.com/kelda/kelda/connection/tls/io"
 	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/engine"
+	"github.com/kelda/kelda/gitops"
+	"github.com/kelda/kelda/health"
+	"github.com/kelda/kelda/recorder"
+	"github.com/kelda/kelda/selfhost"
 	"github.com/kelda/kelda/util"
 	"github.com/kelda/kelda/version"
 
@@ -28,6 +48,19 @@ import (
 
 // Daemon contains the options for running the Quilt daemon.
 type Daemon struct {
+	flightRecorder bool
+	configPath     string
+	listeners      listenerFlags
+	sshBootstrap   providerFlags
+	assetAddress   string
+	mirror         string
+	minionProxy    string
+	healthAddress  string
+	endpoints      endpointFlags
+	skipTLSVerify  bool
+	gitops         gitops.Config
+	selfHosted     bool
+
 	*connectionFlags
 }
 
@@ -44,6 +77,50 @@ var daemonExplanation = "Start the quilt daemon, which listens for quilt API req
 // InstallFlags sets up parsing for command line flags
 func (dCmd *Daemon) InstallFlags(flags *flag.FlagSet) {
 	dCmd.connectionFlags.InstallFlags(flags)
+	flags.BoolVar(&dCmd.flightRecorder, "flight-recorder", recorder.Enabled,
+		"record reconciliation decisions for `quilt decisions`")
+	flags.StringVar(&dCmd.configPath, "config",
+		cliPath.DefaultDaemonConfigPath, "the `PATH` of the configuration "+
+			"file that supplies defaults for machines, such as their disk "+
+			"size, provider, and region. Changes apply on the next "+
+			"deploy, except admin keys, which apply immediately")
+	flags.Var(&dCmd.listeners, "listen", "an additional `ADDRESS[,TLS_DIR]` "+
+		"to listen on, whose clients must use the TLS credentials in TLS_DIR "+
+		"(defaults to the daemon's credentials). May be repeated")
+	flags.Var(&dCmd.sshBootstrap, "ssh-bootstrap", "bootstrap the machines "+
+		"of `PROVIDER` over SSH rather than with user-data, unless the "+
+		"blueprint selects otherwise. May be repeated")
+	flags.StringVar(&dCmd.assetAddress, "assets", "", "serve boot scripts "+
+		"and TLS credentials to booting machines on `HOST:PORT`, rather "+
+		"than passing them in user-data. Machines must be able to reach "+
+		"HOST, which defaults to the daemon's public IP")
+	flags.StringVar(&dCmd.mirror, "mirror", "", "the `REGISTRY` from which "+
+		"machines without internet access pull the Quilt and container "+
+		"images")
+	flags.StringVar(&dCmd.minionProxy, "minion-proxy", "", "connect to "+
+		"machines through the proxy at `URL`, which may be "+
+		"socks5://HOST:PORT, http://HOST:PORT, or ssh://USER@HOST for a "+
+		"jump host that accepts the Quilt SSH key")
+	flags.StringVar(&dCmd.healthAddress, "health", "", "serve the /healthz "+
+		"and /readyz endpoints over HTTP on `HOST:PORT`")
+	flags.Var(&dCmd.endpoints, "provider-endpoint", "connect to the API of "+
+		"a provider at `PROVIDER=URL` rather than its public API, e.g. to "+
+		"test against an emulator. May be repeated")
+	flags.BoolVar(&dCmd.skipTLSVerify, "provider-skip-tls-verify", false,
+		"don't verify the TLS certificates of overridden provider endpoints")
+	flags.StringVar(&dCmd.gitops.Repo, "gitops-repo", "", "deploy the "+
+		"blueprint in the git repository at `URL`, and redeploy it whenever "+
+		"the watched branch changes")
+	flags.StringVar(&dCmd.gitops.Branch, "gitops-branch", "master",
+		"the `BRANCH` of the GitOps repository to deploy")
+	flags.StringVar(&dCmd.gitops.Blueprint, "gitops-blueprint", "main.js",
+		"the `PATH` of the blueprint within the GitOps repository")
+	flags.DurationVar(&dCmd.gitops.Interval, "gitops-interval", time.Minute,
+		"how often to check the GitOps repository for new commits")
+	flags.BoolVar(&dCmd.selfHosted, "self-hosted", false, "run as the "+
+		"self-hosted daemon of a cluster, which redeploys the blueprint that "+
+		"the masters stored when it starts. Set by the masters, rather "+
+		"than by users")
 	flags.Usage = func() {
 		util.PrintUsageString(daemonCommands, daemonExplanation, flags)
 	}
@@ -51,6 +128,9 @@ func (dCmd *Daemon) InstallFlags(flags *flag.FlagSet) {
 
 // Parse parses the command line arguments for the daemon command.
 func (dCmd *Daemon) Parse(args []string) error {
+	if dCmd.gitops.Repo != "" && dCmd.gitops.Interval <= 0 {
+		return errors.New("the GitOps interval must be positive")
+	}
 	return nil
 }
 
@@ -67,6 +147,7 @@ func (dCmd *Daemon) AfterRun() error {
 // Run starts the daemon.
 func (dCmd *Daemon) Run() int {
 	log.WithField("version", version.Version).Info("Starting Quilt daemon")
+	recorder.Enabled = dCmd.flightRecorder
 
 	// If the TLS credentials do not exist, autogenerate credentials and write
 	// them to disk.
@@ -80,6 +161,12 @@ func (dCmd *Daemon) Run() int {
 		}
 	}
 
+	if err := upgradeTLS(cliPath.DefaultTLSDir); err != nil {
+		log.WithError(err).WithField("path", cliPath.DefaultTLSDir).Error(
+			"Failed to upgrade TLS credentials")
+		return 1
+	}
+
 	if _, err := util.Stat(cliPath.DefaultSSHKeyPath); os.IsNotExist(err) {
 		log.WithField("path", cliPath.DefaultSSHKeyPath).Info(
 			"Auto-generating Quilt SSH key")
@@ -102,9 +189,82 @@ func (dCmd *Daemon) Run() int {
 		return 1
 	}
 
+	listeners := []server.Listener{{Address: dCmd.host, Creds: creds}}
+	for _, l := range dCmd.listeners {
+		lCreds := creds
+		if l.tlsDir != "" {
+			lCreds, err = tlsIO.ReadCredentials(l.tlsDir)
+			if err != nil {
+				log.WithError(err).WithField("address", l.address).Error(
+					"Failed to parse TLS credentials for listener")
+				return 1
+			}
+		}
+		listeners = append(listeners, server.Listener{
+			Address: l.address, Creds: lCreds})
+	}
+
+	if dCmd.minionProxy != "" {
+		proxy, err := connection.NewProxyDialer(dCmd.minionProxy, sshKey)
+		if err != nil {
+			log.WithError(err).Error("Failed to parse minion proxy")
+			return 1
+		}
+		foreman.Proxy = proxy
+		client.Proxy = proxy
+	}
+
+	// Bundle the daemon's state, so that it can be moved into the cluster if
+	// a namespace is self-hosted.
+	bundle, err := selfhost.Bundle(map[string]string{
+		"tls":         cliPath.DefaultTLSDir,
+		"ssh_key":     cliPath.DefaultSSHKeyPath,
+		"daemon.json": dCmd.configPath,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to bundle the daemon's state, so " +
+			"namespaces can't be self-hosted")
+	}
+	foreman.SelfHostBundle = bundle
+	foreman.SelfHosted = dCmd.selfHosted
+
+	cfg.Mirror = dCmd.mirror
+	server.Mirror = dCmd.mirror
+	for _, p := range dCmd.sshBootstrap {
+		cloud.SSHBootstrapProviders[p] = struct{}{}
+	}
+	for p, endpointURL := range dCmd.endpoints {
+		log.WithFields(log.Fields{"provider": p, "url": endpointURL}).Info(
+			"Overriding provider endpoint")
+		endpoint.Overrides[p] = endpointURL
+	}
+	endpoint.SkipTLSVerify = dCmd.skipTLSVerify
+
+	// Signals are caught before starting any threads, so that the daemon
+	// always shuts down gracefully.
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
 	conn := db.New()
-	go engine.Run(conn, getPublicKey(sshKey))
-	go server.Run(conn, dCmd.host, true, creds)
+	if dCmd.selfHosted {
+		if err := restoreBlueprint(conn, selfhost.BlueprintPath); err != nil {
+			log.WithError(err).Error("Failed to restore the self-hosted " +
+				"blueprint")
+			return 1
+		}
+	}
+
+	go health.WatchDB(conn)
+	go health.NotifySystemd()
+	if dCmd.healthAddress != "" {
+		go func() {
+			err := health.Run(dCmd.healthAddress)
+			log.WithError(err).Error("Health server exited")
+		}()
+	}
+
+	go engine.Run(conn, getPublicKey(sshKey), dCmd.configPath)
+	go server.RunListeners(conn, listeners, true, creds)
 
 	ca, err := tlsIO.ReadCA(cliPath.DefaultTLSDir)
 	if err != nil {
@@ -113,11 +273,213 @@ func (dCmd *Daemon) Run() int {
 		return 1
 	}
 
+	if dCmd.assetAddress != "" {
+		go func() {
+			err := assets.Run(conn, ca, dCmd.assetAddress,
+				cliPath.DefaultRedeemedTokensPath)
+			log.WithError(err).Error("Asset server exited")
+		}()
+	}
+
+	if dCmd.gitops.Repo != "" {
+		go gitops.Run(conn, dCmd.gitops, cliPath.DefaultGitOpsDir)
+	}
+
 	go cloud.SyncCredentials(conn, sshKey, ca)
-	cloud.Run(conn, creds)
+	go cloud.SyncBootstrap(conn, sshKey)
+	go cloud.Run(conn, creds, ca)
+
+	sig := <-sigc
+	log.WithField("signal", sig).Info("Shutting down")
+	go func() {
+		<-sigc
+		log.Warn("Caught second signal: exiting immediately")
+		os.Exit(1)
+	}()
+
+	shutdown()
 	return 0
 }
 
+// restoreBlueprint deploys the blueprint stored at `path` by the masters, and
+// keeps its namespace self-hosted, so that the self-hosted daemon carries on
+// managing the cluster that it's running in.
+func restoreBlueprint(conn db.Conn, path string) error {
+	bpStr, err := util.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	bp, err := blueprint.FromJSON(bpStr)
+	if err != nil {
+		return fmt.Errorf("parse %s: %s", path, err)
+	}
+
+	conn.Txn(db.BlueprintTable, db.SettingsTable).Run(func(view db.Database) error {
+		dbBlueprint, err := view.GetBlueprint()
+		if err != nil {
+			dbBlueprint = view.InsertBlueprint()
+		}
+		dbBlueprint.Blueprint = bp
+		view.Commit(dbBlueprint)
+
+		settings := view.GetSettings(bp.Namespace)
+		if settings.ID == 0 {
+			settings = view.InsertSettings()
+			settings.Namespace = bp.Namespace
+		}
+
+		flags := map[string]bool{db.SelfHost: true}
+		for name, value := range settings.Flags {
+			if name != db.SelfHost {
+				flags[name] = value
+			}
+		}
+		settings.Flags = flags
+		view.Commit(settings)
+		return nil
+	})
+
+	log.WithField("namespace", bp.Namespace).Info("Restored self-hosted blueprint")
+	return nil
+}
+
+// How long the daemon waits for in-progress provider mutations to finish when
+// shutting down.
+const shutdownTimeout = time.Minute
+
+// shutdown stops accepting API requests, waits for the machines that are being
+// booted or stopped, and closes the connections to minions.
+func shutdown() {
+	start := time.Now()
+	server.Stop()
+	summary := cloud.Shutdown(shutdownTimeout)
+
+	logger := log.WithFields(log.Fields{
+		"duration":  time.Since(start).Round(time.Millisecond),
+		"in-flight": summary.InFlight,
+		"abandoned": summary.Abandoned,
+		"minions":   summary.Minions,
+	})
+	if summary.Abandoned > 0 {
+		logger.Warn("Shut down before all provider operations finished")
+	} else {
+		logger.Info("Shut down cleanly")
+	}
+}
+
+// A listenerFlag is an additional address for the daemon to listen on.  Clients
+// connecting to it must use the credentials in tlsDir, or the daemon's
+// credentials if tlsDir is empty.
+type listenerFlag struct {
+	address string
+	tlsDir  string
+}
+
+type listenerFlags []listenerFlag
+
+func (lf *listenerFlags) String() string {
+	var strs []string
+	for _, l := range *lf {
+		str := l.address
+		if l.tlsDir != "" {
+			str += "," + l.tlsDir
+		}
+		strs = append(strs, str)
+	}
+	return strings.Join(strs, " ")
+}
+
+func (lf *listenerFlags) Set(value string) error {
+	parts := strings.SplitN(value, ",", 2)
+	if _, _, err := api.ParseListenAddress(parts[0]); err != nil {
+		return err
+	}
+
+	l := listenerFlag{address: parts[0]}
+	if len(parts) == 2 {
+		l.tlsDir = parts[1]
+	}
+	*lf = append(*lf, l)
+	return nil
+}
+
+// providerFlags are the providers whose machines are bootstrapped over SSH by
+// default.
+type providerFlags []db.ProviderName
+
+func (pf *providerFlags) String() string {
+	var strs []string
+	for _, p := range *pf {
+		strs = append(strs, string(p))
+	}
+	return strings.Join(strs, " ")
+}
+
+func (pf *providerFlags) Set(value string) error {
+	p, err := db.ParseProvider(value)
+	if err != nil {
+		return err
+	}
+
+	if p == db.Vagrant || p == db.Fake {
+		return fmt.Errorf("%s does not support SSH bootstrapping",
+			strings.ToLower(string(p)))
+	}
+
+	if p == db.Static {
+		return errors.New("static hosts are always bootstrapped over SSH")
+	}
+	*pf = append(*pf, p)
+	return nil
+}
+
+// endpointFlags maps providers to the URL of their API.
+type endpointFlags map[db.ProviderName]string
+
+func (ef *endpointFlags) String() string {
+	var strs []string
+	for p, endpointURL := range *ef {
+		strs = append(strs, fmt.Sprintf("%s=%s", p, endpointURL))
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, " ")
+}
+
+func (ef *endpointFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return errors.New("provider endpoints must be of the form " +
+			"PROVIDER=URL")
+	}
+
+	p, err := db.ParseProvider(parts[0])
+	if err != nil {
+		return err
+	}
+
+	if p == db.Vagrant || p == db.Static || p == db.Fake {
+		return fmt.Errorf("%s does not have an API endpoint",
+			strings.ToLower(string(p)))
+	}
+
+	u, err := url.Parse(parts[1])
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint must be an http or https URL: %s",
+			parts[1])
+	}
+
+	if *ef == nil {
+		*ef = endpointFlags{}
+	}
+	(*ef)[p] = parts[1]
+	return nil
+}
+
 func parseSSHPrivateKey(path string) (ssh.Signer, error) {
 	keyStr, err := util.ReadFile(path)
 	if err != nil {
@@ -146,14 +508,40 @@ func setupTLS(outDir string) error {
 		return fmt.Errorf("failed to create CA: %s", err)
 	}
 
-	// Generate a signed certificate for use by the Daemon server, and client
-	// connections.
-	signed, err := rsa.NewSigned(ca)
+	return writeDaemonFiles(outDir, ca)
+}
+
+// upgradeTLS reissues the daemon's certificate in `dir` if it isn't marked as the
+// daemon's, as is the case for credentials generated by older versions.  Minions
+// only accept configs from clients that present the daemon's certificate.
+func upgradeTLS(dir string) error {
+	signed, err := tlsIO.ReadSigned(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read signed key pair: %s", err)
+	}
+
+	if tls.IsDaemon(signed.Cert()) {
+		return nil
+	}
+
+	ca, err := tlsIO.ReadCA(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read CA: %s", err)
+	}
+
+	log.WithField("path", dir).Info("Reissuing the daemon's TLS certificate")
+	return writeDaemonFiles(dir, ca)
+}
+
+// writeDaemonFiles writes `ca` to `dir`, along with a new certificate that it
+// signs for use by the Daemon server, and client connections.
+func writeDaemonFiles(dir string, ca rsa.KeyPair) error {
+	signed, err := rsa.NewSignedWithURIs(ca, []*url.URL{tls.DaemonURI()})
 	if err != nil {
 		return fmt.Errorf("failed to create signed key pair: %s", err)
 	}
 
-	for _, f := range tlsIO.DaemonFiles(outDir, ca, signed) {
+	for _, f := range tlsIO.DaemonFiles(dir, ca, signed) {
 		if err := util.WriteFile(f.Path, []byte(f.Content), f.Mode); err != nil {
 			return fmt.Errorf("failed to write file (%s): %s", f.Path, err)
 		}
@@ -1,12 +1,20 @@
 package command
 
 import (
+	"flag"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/connection/tls"
 	tlsIO "github.com/kelda/kelda/connection/tls/io"
+	"github.com/kelda/kelda/connection/tls/rsa"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/gitops"
 	"github.com/kelda/kelda/util"
 )
 
@@ -79,6 +87,43 @@ func TestSetupTLS(t *testing.T) {
 
 	_, err = tlsIO.ReadCredentials(tlsDir)
 	assert.NoError(t, err)
+
+	signed, err := tlsIO.ReadSigned(tlsDir)
+	assert.NoError(t, err)
+	assert.True(t, tls.IsDaemon(signed.Cert()))
+}
+
+func TestUpgradeTLS(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	tlsDir := "tls"
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	// Credentials generated by older versions don't mark the daemon's
+	// certificate, so it's reissued by the same CA.
+	old, err := rsa.NewSigned(ca)
+	assert.NoError(t, err)
+	for _, f := range tlsIO.DaemonFiles(tlsDir, ca, old) {
+		assert.NoError(t, util.WriteFile(f.Path, []byte(f.Content), f.Mode))
+	}
+
+	assert.NoError(t, upgradeTLS(tlsDir))
+	signed, err := tlsIO.ReadSigned(tlsDir)
+	assert.NoError(t, err)
+	assert.True(t, tls.IsDaemon(signed.Cert()))
+
+	parsedCA, err := tlsIO.ReadCA(tlsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, ca.CertString(), parsedCA.CertString())
+
+	// Certificates that are already marked are left alone.
+	assert.NoError(t, upgradeTLS(tlsDir))
+	upgraded, err := tlsIO.ReadSigned(tlsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, signed.CertString(), upgraded.CertString())
+
+	assert.Error(t, upgradeTLS("missing"))
 }
 
 // Test that the generated file can be parsed.
@@ -92,3 +137,127 @@ func TestSetupSSHKey(t *testing.T) {
 	_, err = parseSSHPrivateKey(keyPath)
 	assert.NoError(t, err)
 }
+
+func TestListenerFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	dCmd := NewDaemonCommand()
+	dCmd.InstallFlags(flags)
+
+	err := flags.Parse([]string{"-listen", "tcp://0.0.0.0:9000,/remote-tls",
+		"-listen", "unix:///tmp/other.sock"})
+	assert.NoError(t, err)
+	assert.Equal(t, listenerFlags{
+		{address: "tcp://0.0.0.0:9000", tlsDir: "/remote-tls"},
+		{address: "unix:///tmp/other.sock"},
+	}, dCmd.listeners)
+	assert.Equal(t, "tcp://0.0.0.0:9000,/remote-tls unix:///tmp/other.sock",
+		dCmd.listeners.String())
+
+	err = flags.Parse([]string{"-listen", "0.0.0.0:9000"})
+	assert.Error(t, err)
+}
+
+func TestGitOpsFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	dCmd := NewDaemonCommand()
+	dCmd.InstallFlags(flags)
+
+	assert.NoError(t, flags.Parse(nil))
+	assert.NoError(t, dCmd.Parse(nil))
+	assert.Equal(t, gitops.Config{Branch: "master", Blueprint: "main.js",
+		Interval: time.Minute}, dCmd.gitops)
+
+	err := flags.Parse([]string{"-gitops-repo", "https://git.test/infra",
+		"-gitops-branch", "prod", "-gitops-interval", "0s"})
+	assert.NoError(t, err)
+	assert.EqualError(t, dCmd.Parse(nil), "the GitOps interval must be positive")
+}
+
+func TestSSHBootstrapFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	dCmd := NewDaemonCommand()
+	dCmd.InstallFlags(flags)
+
+	err := flags.Parse([]string{"-ssh-bootstrap", "Amazon",
+		"-ssh-bootstrap", "Google"})
+	assert.NoError(t, err)
+	assert.Equal(t, providerFlags{db.Amazon, db.Google}, dCmd.sshBootstrap)
+	assert.Equal(t, "Amazon Google", dCmd.sshBootstrap.String())
+
+	assert.Error(t, flags.Parse([]string{"-ssh-bootstrap", "Vagrant"}))
+	assert.Error(t, flags.Parse([]string{"-ssh-bootstrap", "Static"}))
+	assert.Error(t, flags.Parse([]string{"-ssh-bootstrap", "Rackspace"}))
+}
+
+func TestEndpointFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	dCmd := NewDaemonCommand()
+	dCmd.InstallFlags(flags)
+
+	err := flags.Parse([]string{
+		"-provider-endpoint", "Amazon=http://localhost:4566",
+		"-provider-endpoint", "DigitalOcean=https://do.test/v2/",
+		"-provider-skip-tls-verify"})
+	assert.NoError(t, err)
+	assert.Equal(t, endpointFlags{
+		db.Amazon:       "http://localhost:4566",
+		db.DigitalOcean: "https://do.test/v2/",
+	}, dCmd.endpoints)
+	assert.Equal(t, "Amazon=http://localhost:4566 "+
+		"DigitalOcean=https://do.test/v2/", dCmd.endpoints.String())
+	assert.True(t, dCmd.skipTLSVerify)
+
+	assert.Error(t, flags.Parse([]string{"-provider-endpoint", "Amazon"}))
+	assert.Error(t, flags.Parse([]string{"-provider-endpoint",
+		"Vagrant=http://localhost"}))
+	assert.Error(t, flags.Parse([]string{"-provider-endpoint",
+		"Static=http://localhost"}))
+	assert.Error(t, flags.Parse([]string{"-provider-endpoint",
+		"Amazon=localhost:4566"}))
+}
+
+func TestRestoreBlueprint(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	conn := db.New()
+
+	err := restoreBlueprint(conn, "/blueprint.json")
+	assert.EqualError(t, err, "open /blueprint.json: file does not exist")
+
+	util.WriteFile("/blueprint.json", []byte("malformed"), 0600)
+	assert.Error(t, restoreBlueprint(conn, "/blueprint.json"))
+
+	bp := blueprint.Blueprint{Namespace: "ns", AdminACL: []string{"1.2.3.4/32"}}
+	util.WriteFile("/blueprint.json", []byte(bp.String()), 0600)
+	conn.Txn(db.SettingsTable).Run(func(view db.Database) error {
+		settings := view.InsertSettings()
+		settings.Namespace = "ns"
+		settings.Flags = map[string]bool{db.MinimalACLs: true}
+		view.Commit(settings)
+		return nil
+	})
+	assert.NoError(t, restoreBlueprint(conn, "/blueprint.json"))
+
+	conn.Txn(db.BlueprintTable, db.SettingsTable).Run(func(view db.Database) error {
+		dbBlueprint, err := view.GetBlueprint()
+		assert.NoError(t, err)
+		assert.Equal(t, bp.Namespace, dbBlueprint.Namespace)
+		assert.Equal(t, bp.AdminACL, dbBlueprint.AdminACL)
+
+		settings := view.GetSettings("ns")
+		assert.True(t, settings.Enabled(db.SelfHost))
+		assert.True(t, settings.Enabled(db.MinimalACLs))
+		return nil
+	})
+}
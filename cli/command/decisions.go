@@ -0,0 +1,125 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kelda/kelda/api/client"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/util"
+)
+
+var decisionsCommands = "quilt decisions [OPTIONS] TARGET"
+var decisionsExplanation = fmt.Sprintf(`Display the most recent decisions made while
+reconciling the cluster, such as booting and stopping machines, or placing
+containers.  Decisions made from the same inputs share an input hash.
+
+With -scores, display how the most recent joins paired their inputs instead,
+such as the database machines with the cloud machines.  This explains why, for
+example, a machine was replaced rather than reused.
+
+TARGET should be %q to retrieve the decisions of the daemon. To retrieve the
+decisions of a machine, use the machine's ID as TARGET.`, daemonTarget)
+
+// Decisions implements the `quilt decisions` command.
+type Decisions struct {
+	target string
+	scores bool
+
+	connectionHelper
+}
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Decisions) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.BoolVar(&cmd.scores, "scores", false,
+		"display the score matrices of the most recent joins")
+	flags.Usage = func() {
+		util.PrintUsageString(decisionsCommands, decisionsExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the decisions command.
+func (cmd *Decisions) Parse(args []string) error {
+	if len(args) == 0 {
+		return errors.New("must specify a target")
+	}
+	cmd.target = args[0]
+	return nil
+}
+
+// Run retrieves and prints the recorded decisions.
+func (cmd *Decisions) Run() int {
+	if err := cmd.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (cmd *Decisions) run() error {
+	host, err := decisionsHost(cmd.client, cmd.target)
+	if err != nil {
+		return fmt.Errorf("resolve machine: %s", err)
+	}
+
+	if cmd.scores {
+		joins, err := cmd.client.QueryJoinScores(host)
+		if err != nil {
+			return fmt.Errorf("error querying join scores: %s", err)
+		}
+
+		printJoinScores(os.Stdout, joins)
+		return nil
+	}
+
+	decisions, err := cmd.client.QueryDecisions(host)
+	if err != nil {
+		return fmt.Errorf("error querying decisions: %s", err)
+	}
+
+	printDecisions(os.Stdout, decisions)
+	return nil
+}
+
+// decisionsHost returns the host whose decisions should be queried, which is
+// empty for the daemon.
+func decisionsHost(c client.Client, tgt string) (string, error) {
+	if tgt == daemonTarget {
+		return "", nil
+	}
+
+	mach, err := getMachine(c, tgt)
+	if err != nil {
+		return "", err
+	}
+	return mach.PublicIP, nil
+}
+
+func printDecisions(out io.Writer, decisions []pb.Decision) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "TIME\tMODULE\tINPUTS\tACTION\tTARGET")
+	for _, d := range decisions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			time.Unix(0, d.Time).UTC().Format(time.RFC3339),
+			d.Module, d.InputHash, d.Action, d.Target)
+	}
+}
+
+func printJoinScores(out io.Writer, joins []pb.JoinScores) {
+	for i, j := range joins {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s %s (%s)\n", j.Module, j.Name,
+			time.Unix(0, j.Time).UTC().Format(time.RFC3339))
+		fmt.Fprint(out, j.Explanation)
+	}
+}
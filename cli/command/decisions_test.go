@@ -0,0 +1,92 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionsParse(t *testing.T) {
+	t.Parallel()
+
+	decisions := &Decisions{}
+	assert.Error(t, decisions.Parse(nil), "")
+
+	assert.NoError(t, decisions.Parse([]string{"host"}))
+	assert.Equal(t, "host", decisions.target)
+}
+
+func TestDecisionsQuery(t *testing.T) {
+	t.Parallel()
+
+	mock := new(mocks.Client)
+	mock.On("QueryDecisions", "").Once().Return(nil, nil)
+	decisions := &Decisions{target: daemonTarget}
+	decisions.client = mock
+	assert.Zero(t, decisions.Run())
+
+	mock.On("QueryMachines").Return(
+		[]db.Machine{{BlueprintID: "minion", PublicIP: "host"}}, nil)
+	mock.On("QueryDecisions", "host").Once().Return(nil, assert.AnError)
+	decisions = &Decisions{target: "minion"}
+	decisions.client = mock
+	assert.NotZero(t, decisions.Run())
+
+	mock.On("QueryJoinScores", "host").Once().Return(nil, nil)
+	decisions = &Decisions{target: "minion", scores: true}
+	decisions.client = mock
+	assert.Zero(t, decisions.Run())
+
+	decisions = &Decisions{target: "unknown"}
+	decisions.client = mock
+	assert.NotZero(t, decisions.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintDecisions(t *testing.T) {
+	t.Parallel()
+
+	decisions := []pb.Decision{{
+		Time:      time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC).UnixNano(),
+		Module:    "Cloud",
+		InputHash: "abc",
+		Action:    "stop",
+		Target:    "Machine-1",
+	}}
+
+	var b bytes.Buffer
+	printDecisions(&b, decisions)
+	assert.Equal(t, `TIME                  MODULE  INPUTS  ACTION  TARGET
+2017-01-02T03:04:05Z  Cloud   abc     stop    Machine-1
+`, b.String())
+}
+
+func TestPrintJoinScores(t *testing.T) {
+	t.Parallel()
+
+	joins := []pb.JoinScores{{
+		Time:        time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC).UnixNano(),
+		Module:      "Engine",
+		Name:        "machines",
+		Explanation: "L0: a\n",
+	}, {
+		Time:        time.Date(2017, 1, 2, 3, 4, 6, 0, time.UTC).UnixNano(),
+		Module:      "Scheduler",
+		Name:        "containers",
+		Explanation: "R0: b\n",
+	}}
+
+	var b bytes.Buffer
+	printJoinScores(&b, joins)
+	assert.Equal(t, `Engine machines (2017-01-02T03:04:05Z)
+L0: a
+
+Scheduler containers (2017-01-02T03:04:06Z)
+R0: b
+`, b.String())
+}
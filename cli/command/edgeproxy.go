@@ -0,0 +1,85 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kelda/kelda/minion/edgeproxy"
+	"github.com/kelda/kelda/util"
+)
+
+// EdgeProxy contains the options for running the proxy that carries public
+// traffic to a load balancer.
+type EdgeProxy struct {
+	ports portFlags
+	host  string
+}
+
+var edgeProxyCommands = "quilt edge-proxy [OPTIONS] LOAD_BALANCER"
+var edgeProxyExplanation = `Forward public connections to a load balancer.
+
+The minion runs the proxy on each of the load balancer's edge machines, and maps
+the load balancer's public ports to it.  Connections to each port are forwarded to
+the same port of LOAD_BALANCER.`
+
+// InstallFlags sets up parsing for command line flags.
+func (pCmd *EdgeProxy) InstallFlags(flags *flag.FlagSet) {
+	flags.Var(&pCmd.ports, "port", "accept connections on `PORT`")
+
+	flags.Usage = func() {
+		util.PrintUsageString(edgeProxyCommands, edgeProxyExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the edge-proxy command.
+func (pCmd *EdgeProxy) Parse(args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify a load balancer")
+	}
+
+	pCmd.host = args[0]
+	return nil
+}
+
+// BeforeRun makes any necessary post-parsing transformations.
+func (pCmd *EdgeProxy) BeforeRun() error {
+	return nil
+}
+
+// AfterRun performs any necessary post-run cleanup.
+func (pCmd *EdgeProxy) AfterRun() error {
+	return nil
+}
+
+// Run proxies the public connections until it fails.
+func (pCmd *EdgeProxy) Run() int {
+	if err := edgeproxy.Run(pCmd.host, pCmd.ports); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// portFlags are the ports on which the proxy accepts connections.
+type portFlags []int
+
+func (pf *portFlags) String() string {
+	var strs []string
+	for _, port := range *pf {
+		strs = append(strs, strconv.Itoa(port))
+	}
+	return strings.Join(strs, " ")
+}
+
+func (pf *portFlags) Set(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port: %q", value)
+	}
+	*pf = append(*pf, port)
+	return nil
+}
@@ -0,0 +1,31 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEdgeProxyFlags(t *testing.T) {
+	t.Parallel()
+
+	cmd := &EdgeProxy{}
+	err := parseHelper(cmd, []string{"-port", "80", "-port", "443", "web.q"})
+	assert.NoError(t, err)
+	assert.Equal(t, portFlags{80, 443}, cmd.ports)
+	assert.Equal(t, "80 443", cmd.ports.String())
+	assert.Equal(t, "web.q", cmd.host)
+
+	assert.Error(t, cmd.ports.Set("0"))
+	assert.Error(t, cmd.ports.Set("http"))
+
+	assert.EqualError(t, parseHelper(&EdgeProxy{}, []string{"-port", "80"}),
+		"must specify a load balancer")
+}
+
+func TestEdgeProxyNoPorts(t *testing.T) {
+	t.Parallel()
+
+	cmd := &EdgeProxy{host: "web.q"}
+	assert.Equal(t, 1, cmd.Run())
+}
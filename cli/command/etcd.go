@@ -0,0 +1,85 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/util"
+)
+
+// Etcd implements the `quilt etcd` command.
+type Etcd struct {
+	keys bool
+
+	connectionHelper
+}
+
+var etcdCommands = "quilt etcd [OPTIONS]"
+var etcdExplanation = `Compare the daemon's view of the cluster with the etcd keys through
+which the minions share the cluster's state: the containers, the connections,
+and the minion directory.  Each difference is printed, and the command fails if
+there are any.  With the -keys flag, the keys are also printed.
+
+This helps pinpoint where the daemon and the minions have fallen out of sync.`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Etcd) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.BoolVar(&cmd.keys, "keys", false, "print the values of the etcd keys")
+	flags.Usage = func() {
+		util.PrintUsageString(etcdCommands, etcdExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the etcd command.
+func (cmd *Etcd) Parse(args []string) error {
+	return nil
+}
+
+// Run prints the differences between the daemon and etcd.
+func (cmd *Etcd) Run() int {
+	reply, err := cmd.client.QueryEtcdSnapshot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error querying etcd: %s\n", err)
+		return 1
+	}
+
+	if cmd.keys {
+		printEtcdKeys(os.Stdout, reply.Keys)
+	}
+
+	if len(reply.Differences) == 0 {
+		fmt.Println("The daemon and etcd agree.")
+		return 0
+	}
+
+	printEtcdDifferences(os.Stdout, reply.Differences)
+	return 1
+}
+
+func printEtcdKeys(out io.Writer, keys map[string]string) {
+	var paths []string
+	for path := range keys {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fmt.Fprintf(out, "%s:\n%s\n\n", path, keys[path])
+	}
+}
+
+func printEtcdDifferences(out io.Writer, diffs []*pb.EtcdDifference) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "KIND\tID\tDIFFERENCE")
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Kind, d.ID, d.Reason)
+	}
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcd(t *testing.T) {
+	mock := new(mocks.Client)
+	mock.On("QueryEtcdSnapshot").Return(pb.EtcdSnapshotReply{}, nil).Once()
+	cmd := &Etcd{keys: true}
+	cmd.client = mock
+	assert.Equal(t, 0, cmd.Run())
+
+	mock.On("QueryEtcdSnapshot").Return(pb.EtcdSnapshotReply{
+		Differences: []*pb.EtcdDifference{
+			{Kind: "minion", ID: "10.0.0.1", Reason: "reason"}},
+	}, nil).Once()
+	assert.Equal(t, 1, cmd.Run())
+
+	mock.On("QueryEtcdSnapshot").Return(pb.EtcdSnapshotReply{}, assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintEtcd(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printEtcdKeys(&b, map[string]string{
+		"/minions/10.0.0.1": "{}",
+		"/containers":       "[]",
+	})
+	assert.Equal(t, "/containers:\n[]\n\n/minions/10.0.0.1:\n{}\n\n", b.String())
+
+	b.Reset()
+	printEtcdDifferences(&b, []*pb.EtcdDifference{
+		{Kind: "minion", ID: "10.0.0.1", Reason: "in etcd, but not a machine"},
+		{Kind: "container", ID: "web", Reason: "in the blueprint, but not in etcd"},
+	})
+	assert.Equal(t, `KIND       ID        DIFFERENCE
+minion     10.0.0.1  in etcd, but not a machine
+container  web       in the blueprint, but not in etcd
+`, b.String())
+}
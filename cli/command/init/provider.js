@@ -19,6 +19,13 @@ let providerConfig = { // eslint-disable-line prefer-const
     },
     requiresSsh: true,
   },
+  Azure: {
+    credsKeys: {
+      [consts.inputCredsPath]: 'Path to Azure service principal (created ' +
+        'with `az ad sp create-for-rbac --sdk-auth`)',
+    },
+    requiresSsh: true,
+  },
   Google: {
     credsKeys: {
       [consts.inputCredsPath]: 'Path to GCE service account key',
@@ -32,6 +39,36 @@ let providerConfig = { // eslint-disable-line prefer-const
     },
     requiresSsh: true,
   },
+  OpenStack: {
+    credsKeys: {
+      [consts.inputCredsPath]: 'Path to OpenStack configuration (see the ' +
+        'Cloud Provider docs for its format)',
+    },
+    requiresSsh: true,
+  },
+  Alibaba: {
+    credsTemplate: 'alibaba_creds_template',
+    credsKeys: {
+      key: 'Alibaba Cloud AccessKey ID',
+      secret: 'Alibaba Cloud AccessKey secret',
+    },
+    requiresSsh: true,
+  },
+  Scaleway: {
+    credsTemplate: 'scaleway_creds_template',
+    credsKeys: {
+      key: 'Scaleway API secret key',
+      project: 'Scaleway project ID',
+    },
+    requiresSsh: true,
+  },
+  IBM: {
+    credsTemplate: 'ibm_creds_template',
+    credsKeys: {
+      key: 'IBM Cloud API key',
+    },
+    requiresSsh: true,
+  },
   Vagrant: {
     requiresSsh: false,
   },
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kelda/kelda/util"
+)
+
+var logLevelCommands = "quilt log-level [OPTIONS] TARGET [MODULE=LEVEL ...]"
+var logLevelExplanation = fmt.Sprintf(`View or change the log levels of modules at
+runtime, such as to turn on debug logging for the cloud module without also
+enabling it for the network module.  With no MODULE=LEVEL arguments, the current
+levels are printed.
+
+LEVEL is a log level (debug, info, warn, error, fatal, or panic), or "default"
+to revert the module to the level that the process was started with.  Levels
+are reset when the process restarts.
+
+TARGET should be %q to change the log levels of the daemon. To change the log
+levels of a machine's minion, use the machine's ID as TARGET.
+
+Available modules: %s`, daemonTarget, strings.Join(util.LogModules(), ", "))
+
+// LogLevel implements the `quilt log-level` command.
+type LogLevel struct {
+	target string
+	levels map[string]string
+
+	connectionHelper
+}
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *LogLevel) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.Usage = func() {
+		util.PrintUsageString(logLevelCommands, logLevelExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the log-level command.
+func (cmd *LogLevel) Parse(args []string) error {
+	if len(args) == 0 {
+		return errors.New("must specify a target")
+	}
+	cmd.target = args[0]
+
+	cmd.levels = map[string]string{}
+	for _, arg := range args[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("malformed log level: %s", arg)
+		}
+		cmd.levels[parts[0]] = parts[1]
+	}
+	return nil
+}
+
+// Run changes and prints the log levels.
+func (cmd *LogLevel) Run() int {
+	if err := cmd.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (cmd *LogLevel) run() error {
+	host, err := decisionsHost(cmd.client, cmd.target)
+	if err != nil {
+		return fmt.Errorf("resolve machine: %s", err)
+	}
+
+	levels, err := cmd.client.SetLogLevels(host, cmd.levels)
+	if err != nil {
+		return fmt.Errorf("unable to set log levels: %s", err)
+	}
+
+	printLogLevels(os.Stdout, levels)
+	return nil
+}
+
+func printLogLevels(out io.Writer, levels map[string]string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	var modules []string
+	for module := range levels {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	fmt.Fprintln(w, "MODULE\tLEVEL")
+	for _, module := range modules {
+		fmt.Fprintf(w, "%s\t%s\n", module, levels[module])
+	}
+}
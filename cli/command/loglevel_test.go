@@ -0,0 +1,60 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevelParse(t *testing.T) {
+	t.Parallel()
+
+	cmd := &LogLevel{}
+	assert.EqualError(t, cmd.Parse(nil), "must specify a target")
+
+	assert.NoError(t, cmd.Parse([]string{"daemon"}))
+	assert.Equal(t, "daemon", cmd.target)
+	assert.Empty(t, cmd.levels)
+
+	assert.NoError(t, cmd.Parse([]string{"minion", "cloud=debug",
+		"network=default"}))
+	assert.Equal(t, "minion", cmd.target)
+	assert.Equal(t, map[string]string{"cloud": "debug", "network": "default"},
+		cmd.levels)
+
+	assert.EqualError(t, cmd.Parse([]string{"daemon", "cloud"}),
+		"malformed log level: cloud")
+}
+
+func TestLogLevelRun(t *testing.T) {
+	t.Parallel()
+
+	mock := new(mocks.Client)
+	mock.On("SetLogLevels", "", map[string]string{"cloud": "debug"}).
+		Once().Return(map[string]string{"cloud": "debug"}, nil)
+	cmd := &LogLevel{target: daemonTarget,
+		levels: map[string]string{"cloud": "debug"}}
+	cmd.client = mock
+	assert.Zero(t, cmd.Run())
+
+	mock.On("QueryMachines").Return(
+		[]db.Machine{{BlueprintID: "minion", PublicIP: "host"}}, nil)
+	mock.On("SetLogLevels", "host", map[string]string{}).
+		Once().Return(nil, assert.AnError)
+	cmd = &LogLevel{target: "minion", levels: map[string]string{}}
+	cmd.client = mock
+	assert.NotZero(t, cmd.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintLogLevels(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printLogLevels(&b, map[string]string{"scheduler": "info", "cloud": "debug"})
+	assert.Equal(t, "MODULE     LEVEL\ncloud      debug\nscheduler  info\n",
+		b.String())
+}
@@ -0,0 +1,90 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+// MachineAction implements the `quilt reboot` and `quilt reimage` commands.
+type MachineAction struct {
+	action string
+	target string
+
+	connectionHelper
+}
+
+// NewRebootCommand creates a new MachineAction command that reboots machines.
+func NewRebootCommand() *MachineAction {
+	return &MachineAction{action: db.RebootAction}
+}
+
+// NewReimageCommand creates a new MachineAction command that reimages machines.
+func NewReimageCommand() *MachineAction {
+	return &MachineAction{action: db.ReimageAction}
+}
+
+var machineActionExplanations = map[string]string{
+	db.RebootAction: `Reboot a machine with its cloud provider's API.
+
+The machine's containers are first rescheduled onto other machines.  If they
+haven't all been moved within five minutes, the machine is rebooted anyway.
+
+To reboot the machine with blueprint ID 8879fd2dbcee:
+quilt reboot 8879fd2dbcee`,
+
+	db.ReimageAction: `Replace a machine with a freshly booted one.
+
+The machine's containers are first rescheduled onto other machines.  If they
+haven't all been moved within five minutes, the machine is replaced anyway.
+
+To reimage the machine with blueprint ID 8879fd2dbcee:
+quilt reimage 8879fd2dbcee`,
+}
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *MachineAction) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.Usage = func() {
+		util.PrintUsageString(
+			fmt.Sprintf("quilt %s [OPTIONS] ID", cmd.action),
+			machineActionExplanations[cmd.action], flags)
+	}
+}
+
+// Parse parses the command line arguments for the command.
+func (cmd *MachineAction) Parse(args []string) error {
+	if len(args) == 0 {
+		return errors.New("must specify a target machine")
+	}
+
+	cmd.target = args[0]
+	return nil
+}
+
+// Run requests the action on the target machine.
+func (cmd *MachineAction) Run() int {
+	m, err := getMachine(cmd.client, cmd.target)
+	if err != nil {
+		log.WithError(err).Error("Failed to resolve target machine")
+		return 1
+	}
+
+	if cmd.action == db.RebootAction {
+		err = cmd.client.Reboot(m.BlueprintID)
+	} else {
+		err = cmd.client.Reimage(m.BlueprintID)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("Failed to %s machine", cmd.action)
+		return 1
+	}
+
+	fmt.Printf("Draining machine %s before the %s.\n", m.BlueprintID, cmd.action)
+	return 0
+}
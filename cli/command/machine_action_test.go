@@ -0,0 +1,43 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/db"
+)
+
+func TestMachineActionParse(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewRebootCommand()
+	assert.EqualError(t, cmd.Parse(nil), "must specify a target machine")
+	assert.NoError(t, cmd.Parse([]string{"id"}))
+	assert.Equal(t, "id", cmd.target)
+}
+
+func TestMachineActionRun(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("QueryMachines").Return([]db.Machine{
+		{BlueprintID: "abc"}, {BlueprintID: "def"}}, nil)
+	mc.On("Reboot", "abc").Return(nil)
+	mc.On("Reimage", "def").Return(assert.AnError)
+
+	cmd := NewRebootCommand()
+	cmd.client = mc
+	cmd.target = "a"
+	assert.Zero(t, cmd.Run())
+
+	cmd = NewReimageCommand()
+	cmd.client = mc
+	cmd.target = "d"
+	assert.NotZero(t, cmd.Run())
+
+	cmd.target = "missing"
+	assert.NotZero(t, cmd.Run())
+	mc.AssertExpectations(t)
+}
@@ -10,6 +10,9 @@ import (
 
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/minion/scheduler"
+	"github.com/kelda/kelda/recorder"
 	"github.com/kelda/kelda/util"
 	"github.com/kelda/kelda/version"
 
@@ -20,6 +23,9 @@ import (
 type Minion struct {
 	role                            string
 	inboundPubIntf, outboundPubIntf string
+	localityWeight                  float64
+	flightRecorder                  bool
+	mirror                          string
 
 	connectionFlags
 }
@@ -43,6 +49,13 @@ func (mCmd *Minion) InstallFlags(flags *flag.FlagSet) {
 		"the interface on which to allow inbound traffic")
 	flags.StringVar(&mCmd.outboundPubIntf, "outbound-pub-intf", "",
 		"the interface on which to allow outbound traffic")
+	flags.Float64Var(&mCmd.localityWeight, "locality-weight",
+		scheduler.LocalityWeight, "how strongly the scheduler prefers "+
+			"placing connected containers near each other (0 disables)")
+	flags.BoolVar(&mCmd.flightRecorder, "flight-recorder", recorder.Enabled,
+		"record reconciliation decisions for `quilt decisions`")
+	flags.StringVar(&mCmd.mirror, "mirror", "", "the `REGISTRY` to pull "+
+		"images from, for machines without internet access")
 
 	flags.Usage = func() {
 		util.PrintUsageString(minionCommands, minionExplanation, flags)
@@ -84,6 +97,9 @@ func (mCmd *Minion) run() error {
 		return errors.New("no or improper role specified")
 	}
 
+	scheduler.LocalityWeight = mCmd.localityWeight
+	recorder.Enabled = mCmd.flightRecorder
+	docker.Mirror = mCmd.mirror
 	minion.Run(role, mCmd.inboundPubIntf, mCmd.outboundPubIntf)
 	return nil
 }
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kelda/kelda/util"
+)
+
+// Mirror implements the `quilt mirror` command.
+type Mirror struct {
+	from     string
+	to       string
+	capture  string
+	duration time.Duration
+
+	connectionHelper
+}
+
+var mirrorCommands = "quilt mirror [OPTIONS] FROM TO CAPTURE"
+var mirrorExplanation = `Mirror the traffic of an end of a connection to a capture
+container.
+
+FROM and TO are the hostnames of the ends of a connection in the blueprint, and
+CAPTURE is the blueprint ID of a running container, such as one running tcpdump,
+that receives a copy of every packet sent or received by whichever end of the
+connection runs on the same machine, including the packets of its other
+connections.  The capture container must share a machine with at least one end
+of the connection.  Mirroring stops once the duration
+elapses, or the command is interrupted.
+
+To capture the traffic between web and db with container 8879fd2dbcee for five
+minutes:
+quilt mirror -duration 5m web db 8879fd2dbcee`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Mirror) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.DurationVar(&cmd.duration, "duration", time.Minute,
+		"how long to mirror traffic for, up to an hour")
+	flags.Usage = func() {
+		util.PrintUsageString(mirrorCommands, mirrorExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the mirror command.
+func (cmd *Mirror) Parse(args []string) error {
+	if len(args) != 3 {
+		return errors.New("must specify the ends of the connection, and a " +
+			"capture container")
+	}
+
+	cmd.from, cmd.to, cmd.capture = args[0], args[1], args[2]
+	return nil
+}
+
+// Run mirrors the connection until the duration elapses.
+func (cmd *Mirror) Run() int {
+	host, capture, err := getContainer(cmd.client, cmd.capture)
+	if err != nil {
+		log.WithError(err).Error("Failed to resolve capture container")
+		return 1
+	}
+
+	if capture.DockerID == "" {
+		log.Error("Capture container not yet running")
+		return 1
+	}
+
+	fmt.Printf("Mirroring traffic from %s to %s to %s for %s\n", cmd.from,
+		cmd.to, capture.Hostname, cmd.duration)
+	err = cmd.client.MirrorEndpoint(host, cmd.from, cmd.to, capture.Hostname,
+		cmd.duration)
+	if err != nil {
+		log.WithError(err).Error("Failed to mirror connection")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/db"
+)
+
+func TestMirrorFlags(t *testing.T) {
+	t.Parallel()
+
+	cmd := &Mirror{}
+	err := parseHelper(cmd, []string{"-duration", "5m", "web", "db", "capture"})
+	assert.NoError(t, err)
+	assert.Equal(t, "web", cmd.from)
+	assert.Equal(t, "db", cmd.to)
+	assert.Equal(t, "capture", cmd.capture)
+	assert.Equal(t, 5*time.Minute, cmd.duration)
+
+	cmd = &Mirror{}
+	err = parseHelper(cmd, []string{"web", "db"})
+	assert.EqualError(t, err, "must specify the ends of the connection, and a "+
+		"capture container")
+}
+
+func TestMirror(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("QueryMachines").Return([]db.Machine{
+		{BlueprintID: "m", PublicIP: "8.8.8.8", PrivateIP: "9.9.9.9"}}, nil)
+	mc.On("QueryContainers").Return([]db.Container{
+		{BlueprintID: "running", Hostname: "tcpdump", DockerID: "dockerID",
+			Minion: "9.9.9.9"},
+		{BlueprintID: "pending"}}, nil)
+	mc.On("MirrorEndpoint", "8.8.8.8", "web", "db", "tcpdump",
+		time.Minute).Return(nil).Once()
+
+	cmd := &Mirror{from: "web", to: "db", capture: "running",
+		duration: time.Minute}
+	cmd.client = mc
+	assert.Equal(t, 0, cmd.Run())
+
+	mc.On("MirrorEndpoint", "8.8.8.8", "web", "db", "tcpdump",
+		time.Minute).Return(assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+
+	cmd.capture = "pending"
+	assert.Equal(t, 1, cmd.Run())
+
+	cmd.capture = "missing"
+	assert.Equal(t, 1, cmd.Run())
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/util"
+)
+
+// Ready implements the `quilt ready` command.
+type Ready struct {
+	wait    bool
+	timeout time.Duration
+
+	connectionHelper
+}
+
+var readyCommands = "quilt ready [OPTIONS]"
+var readyExplanation = `Check whether the cluster implements the deployed blueprint.
+
+Exits successfully if every machine is connected, every container is running,
+and every connection is programmed.  Otherwise, the outstanding items are
+printed, and the command fails.  With the -wait flag, the command blocks until
+the cluster is ready, or the timeout elapses.`
+
+// The interval at which readiness is polled when waiting.
+var readyPollInterval = 5 * time.Second
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Ready) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.BoolVar(&cmd.wait, "wait", false, "wait for the cluster to be ready")
+	flags.DurationVar(&cmd.timeout, "timeout", 30*time.Minute,
+		"how long to wait for the cluster to be ready")
+	flags.Usage = func() {
+		util.PrintUsageString(readyCommands, readyExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the ready command.
+func (cmd *Ready) Parse(args []string) error {
+	return nil
+}
+
+// Run checks whether the cluster is ready.
+func (cmd *Ready) Run() int {
+	deadline := time.Now().Add(cmd.timeout)
+	for {
+		reply, err := cmd.client.QueryConvergence()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error querying convergence: %s\n", err)
+			return 1
+		}
+
+		if reply.Converged {
+			fmt.Println("The cluster is ready.")
+			return 0
+		}
+
+		if !cmd.wait || time.Now().Add(readyPollInterval).After(deadline) {
+			printOutstanding(os.Stdout, reply.Outstanding)
+			return 1
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
+
+func printOutstanding(out io.Writer, outstanding []*pb.OutstandingItem) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "KIND\tID\tREASON")
+	for _, item := range outstanding {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", item.Kind, item.ID, item.Reason)
+	}
+}
@@ -0,0 +1,51 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReady(t *testing.T) {
+	readyPollInterval = 0
+	defer func() { readyPollInterval = 5 * time.Second }()
+
+	notReady := pb.ConvergenceReply{Outstanding: []*pb.OutstandingItem{
+		{Kind: "machine", ID: "1", Reason: "not booted"}}}
+
+	mock := new(mocks.Client)
+	mock.On("QueryConvergence").Return(notReady, nil).Once()
+	cmd := &Ready{}
+	cmd.client = mock
+	assert.Equal(t, 1, cmd.Run())
+
+	// When waiting, the command polls until the cluster is ready.
+	mock.On("QueryConvergence").Return(notReady, nil).Twice()
+	mock.On("QueryConvergence").Return(
+		pb.ConvergenceReply{Converged: true}, nil).Once()
+	cmd = &Ready{wait: true, timeout: time.Minute}
+	cmd.client = mock
+	assert.Equal(t, 0, cmd.Run())
+
+	mock.On("QueryConvergence").Return(pb.ConvergenceReply{}, assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintOutstanding(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printOutstanding(&b, []*pb.OutstandingItem{
+		{Kind: "machine", ID: "abc", Reason: "not connected"},
+		{Kind: "container", ID: "def", Reason: "exited"},
+	})
+	assert.Equal(t, `KIND       ID   REASON
+machine    abc  not connected
+container  def  exited
+`, b.String())
+}
@@ -0,0 +1,98 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/util"
+)
+
+// Resources implements the `quilt resources` command.
+type Resources struct {
+	connectionHelper
+}
+
+var resourcesCommands = "quilt resources [OPTIONS]"
+var resourcesExplanation = `List the provider resources created for the namespace.
+
+The daemon queries every region of every provider for the instances, security
+groups, keys, volumes, and IP addresses that it created for the current
+namespace, along with their tags and creation times.  Resources are listed
+whether or not they're still in use, so the output may be used to audit the
+namespace, or to find resources that must be cleaned up by hand.  The command
+fails if some regions couldn't be queried.`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Resources) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.Usage = func() {
+		util.PrintUsageString(resourcesCommands, resourcesExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the resources command.
+func (cmd *Resources) Parse(args []string) error {
+	return nil
+}
+
+// Run lists the resources of the namespace.
+func (cmd *Resources) Run() int {
+	reply, err := cmd.client.QueryResources()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error querying resources: %s\n", err)
+		return 1
+	}
+
+	printResources(os.Stdout, reply.Resources)
+	for _, err := range reply.Errors {
+		fmt.Fprintf(os.Stderr, "error querying resources: %s\n", err)
+	}
+
+	if len(reply.Errors) != 0 {
+		return 1
+	}
+	return 0
+}
+
+func printResources(out io.Writer, resources []*pb.Resource) {
+	sort.Slice(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.ID < b.ID
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "PROVIDER\tREGION\tTYPE\tID\tNAME\tCREATED\tTAGS")
+	for _, res := range resources {
+		var created string
+		if res.Created != 0 {
+			created = time.Unix(res.Created, 0).UTC().Format(time.RFC3339)
+		}
+
+		var tags []string
+		for key, value := range res.Tags {
+			tags = append(tags, key+"="+value)
+		}
+		sort.Strings(tags)
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.Provider,
+			res.Region, res.Type, res.ID, res.Name, created,
+			strings.Join(tags, ", "))
+	}
+}
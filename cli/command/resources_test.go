@@ -0,0 +1,46 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+)
+
+func TestResources(t *testing.T) {
+	mock := new(mocks.Client)
+	mock.On("QueryResources").Return(pb.ResourcesReply{}, nil).Once()
+	cmd := &Resources{}
+	cmd.client = mock
+	assert.NoError(t, cmd.Parse(nil))
+	assert.Equal(t, 0, cmd.Run())
+
+	mock.On("QueryResources").Return(pb.ResourcesReply{
+		Errors: []string{"unauthorized"}}, nil).Once()
+	assert.Equal(t, 1, cmd.Run())
+
+	mock.On("QueryResources").Return(pb.ResourcesReply{}, assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintResources(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printResources(&b, []*pb.Resource{
+		{Provider: "Google", Region: "us-east1-b", Type: "network",
+			ID: "quilt-ns"},
+		{Provider: "Amazon", Region: "us-west-1", Type: "instance",
+			ID: "i-1", Name: "worker-1", Created: 1500000000,
+			Tags: map[string]string{"b": "2", "a": "1"}},
+	})
+	assert.Equal(t, `PROVIDER  REGION      TYPE      ID        NAME      `+
+		`CREATED               TAGS
+Amazon    us-west-1   instance  i-1       worker-1  2017-07-14T02:40:00Z  a=1, b=2
+Google    us-east1-b  network   quilt-ns                                  
+`, b.String())
+}
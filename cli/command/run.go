@@ -10,12 +10,15 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/pmezard/go-difflib/difflib"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/kelda/kelda/api"
 	"github.com/kelda/kelda/api/client"
+	"github.com/kelda/kelda/api/pb"
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/util"
 )
@@ -24,6 +27,8 @@ import (
 type Run struct {
 	blueprint string
 	force     bool
+	wait      bool
+	timeout   time.Duration
 
 	connectionHelper
 }
@@ -37,7 +42,12 @@ var runCommands = `quilt run [OPTIONS] BLUEPRINT`
 var runExplanation = `Compile a blueprint, and deploy the system it describes.
 
 Confirmation is required if deploying the blueprint would change an existing
-deployment. Confirmation can be skipped with the -f flag.`
+deployment. Confirmation can be skipped with the -f flag.
+
+With the -wait flag, the command reports the progress of the deployment, and
+exits once it converges, or fails if it doesn't converge within the timeout.  On
+failure, the machines, containers, and connections that didn't converge are
+listed.`
 
 // InstallFlags sets up parsing for command line flags.
 func (rCmd *Run) InstallFlags(flags *flag.FlagSet) {
@@ -45,6 +55,10 @@ func (rCmd *Run) InstallFlags(flags *flag.FlagSet) {
 
 	flags.StringVar(&rCmd.blueprint, "blueprint", "", "the blueprint to run")
 	flags.BoolVar(&rCmd.force, "f", false, "deploy without confirming changes")
+	flags.BoolVar(&rCmd.wait, "wait", false,
+		"wait for the deployment to converge")
+	flags.DurationVar(&rCmd.timeout, "timeout", 30*time.Minute,
+		"how long to wait for the deployment to converge")
 
 	flags.Usage = func() {
 		util.PrintUsageString(runCommands, runExplanation, flags)
@@ -106,6 +120,19 @@ func (rCmd *Run) Run() int {
 		}
 	}
 
+	if rCmd.wait {
+		err = rCmd.client.DeployAndWait(deployment, rCmd.timeout,
+			printProgress)
+		if err != nil {
+			log.WithError(err).Error("Deployment failed.")
+			if convErr, ok := err.(client.ConvergenceError); ok {
+				printOutstanding(os.Stdout, convErr.Outstanding)
+			}
+			return 1
+		}
+		return 0
+	}
+
 	err = rCmd.client.Deploy(deployment)
 	if err != nil {
 		log.WithError(err).Error("Error while starting run.")
@@ -118,6 +145,30 @@ func (rCmd *Run) Run() int {
 	return 0
 }
 
+func printProgress(progress pb.DeployReply) {
+	fmt.Println(formatProgress(progress))
+}
+
+func formatProgress(progress pb.DeployReply) string {
+	switch progress.Stage {
+	case api.DeployAccepted:
+		return "Blueprint accepted."
+	case api.DeployBooting:
+		return fmt.Sprintf("Booting machines: %d/%d booted.",
+			progress.MachinesBooted, progress.MachinesTotal)
+	case api.DeployConnecting:
+		return fmt.Sprintf("Connecting to machines: %d/%d connected.",
+			progress.MachinesConnected, progress.MachinesTotal)
+	case api.DeployScheduling:
+		return fmt.Sprintf("Scheduling containers: %d/%d scheduled.",
+			progress.ContainersScheduled, progress.ContainersTotal)
+	case api.DeployDone:
+		return "Deployment converged."
+	default:
+		return progress.Stage
+	}
+}
+
 func getCurrentDeployment(c client.Client) (blueprint.Blueprint, error) {
 	blueprints, err := c.QueryBlueprints()
 	if err != nil {
@@ -6,13 +6,17 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/api/client"
 	clientMock "github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/util"
@@ -227,6 +231,8 @@ func TestRunFlags(t *testing.T) {
 	checkRunParsing(t, []string{expBlueprint}, Run{blueprint: expBlueprint}, nil)
 	checkRunParsing(t, []string{"-f", expBlueprint},
 		Run{force: true, blueprint: expBlueprint}, nil)
+	checkRunParsing(t, []string{"-wait", "-timeout", "1m", expBlueprint},
+		Run{wait: true, timeout: time.Minute, blueprint: expBlueprint}, nil)
 	checkRunParsing(t, []string{}, Run{}, errors.New("no blueprint specified"))
 }
 
@@ -245,4 +251,53 @@ func checkRunParsing(t *testing.T, args []string, expFlags Run, expErr error) {
 	assert.Nil(t, err)
 	assert.Equal(t, expFlags.blueprint, runCmd.blueprint)
 	assert.Equal(t, expFlags.force, runCmd.force)
+	if expFlags.wait {
+		assert.True(t, runCmd.wait)
+		assert.Equal(t, expFlags.timeout, runCmd.timeout)
+	}
+}
+
+func TestRunWait(t *testing.T) {
+	compile = func(path string) (blueprint.Blueprint, error) {
+		return blueprint.Blueprint{}, nil
+	}
+
+	c := new(clientMock.Client)
+	c.On("QueryBlueprints").Return(nil, nil)
+	c.On("DeployAndWait", "{}", time.Minute, mock.Anything).Return(nil).Once()
+
+	runCmd := &Run{
+		connectionHelper: connectionHelper{client: c},
+		blueprint:        "test.js",
+		wait:             true,
+		timeout:          time.Minute,
+	}
+	assert.Equal(t, 0, runCmd.Run())
+	c.AssertNotCalled(t, "Deploy", mock.Anything)
+
+	c.On("DeployAndWait", "{}", time.Minute, mock.Anything).Return(
+		assert.AnError).Once()
+	assert.Equal(t, 1, runCmd.Run())
+
+	c.On("DeployAndWait", "{}", time.Minute, mock.Anything).Return(
+		client.ConvergenceError{Timeout: time.Minute}).Once()
+	assert.Equal(t, 1, runCmd.Run())
+}
+
+func TestFormatProgress(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "Blueprint accepted.",
+		formatProgress(pb.DeployReply{Stage: api.DeployAccepted}))
+	assert.Equal(t, "Booting machines: 1/3 booted.",
+		formatProgress(pb.DeployReply{Stage: api.DeployBooting,
+			MachinesBooted: 1, MachinesTotal: 3}))
+	assert.Equal(t, "Connecting to machines: 2/3 connected.",
+		formatProgress(pb.DeployReply{Stage: api.DeployConnecting,
+			MachinesConnected: 2, MachinesTotal: 3}))
+	assert.Equal(t, "Scheduling containers: 0/4 scheduled.",
+		formatProgress(pb.DeployReply{Stage: api.DeployScheduling,
+			ContainersTotal: 4}))
+	assert.Equal(t, "Deployment converged.",
+		formatProgress(pb.DeployReply{Stage: api.DeployDone}))
 }
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+// Settings contains the options for viewing and changing namespace settings.
+type Settings struct {
+	namespace string
+	flags     map[string]bool
+
+	connectionHelper
+}
+
+var settingsCommands = "quilt settings [OPTIONS] [NAME=VALUE ...]"
+var settingsExplanation = fmt.Sprintf(`View or change the settings of a namespace.
+
+Settings toggle the behavior of the daemon at runtime.  With no arguments, the
+current settings are printed.  Otherwise, each NAME is set to VALUE, which must
+be true or false.
+
+If no namespace is specified, the namespace that is currently tracked by the
+daemon is used.
+
+Available settings: %s`, strings.Join(db.SettingNames(), ", "))
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Settings) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.StringVar(&cmd.namespace, "namespace", "",
+		"the namespace whose settings to view or change")
+	flags.Usage = func() {
+		util.PrintUsageString(settingsCommands, settingsExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the settings command.
+func (cmd *Settings) Parse(args []string) error {
+	cmd.flags = map[string]bool{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("malformed setting: %s", arg)
+		}
+
+		if err := db.ValidateSetting(parts[0]); err != nil {
+			return err
+		}
+
+		value, err := strconv.ParseBool(parts[1])
+		if err != nil {
+			return fmt.Errorf("malformed value for %s: %s", parts[0], parts[1])
+		}
+		cmd.flags[parts[0]] = value
+	}
+	return nil
+}
+
+// Run prints or changes the settings.
+func (cmd *Settings) Run() int {
+	if err := cmd.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (cmd *Settings) run() error {
+	namespace := cmd.namespace
+	if namespace == "" {
+		bp, err := getCurrentDeployment(cmd.client)
+		if err != nil {
+			return fmt.Errorf("unable to get current namespace: %s", err)
+		}
+		namespace = bp.Namespace
+	}
+
+	if len(cmd.flags) > 0 {
+		return cmd.client.UpdateSettings(namespace, cmd.flags)
+	}
+
+	allSettings, err := cmd.client.QuerySettings()
+	if err != nil {
+		return fmt.Errorf("unable to query settings: %s", err)
+	}
+
+	settings := db.Settings{Namespace: namespace}
+	for _, s := range allSettings {
+		if s.Namespace == namespace {
+			settings = s
+		}
+	}
+	printSettings(os.Stdout, settings)
+	return nil
+}
+
+func printSettings(out io.Writer, settings db.Settings) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "SETTING\tVALUE")
+	for _, name := range db.SettingNames() {
+		fmt.Fprintf(w, "%s\t%t\n", name, settings.Enabled(name))
+	}
+}
@@ -0,0 +1,65 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsParse(t *testing.T) {
+	t.Parallel()
+
+	cmd := &Settings{}
+	assert.NoError(t, cmd.Parse(nil))
+	assert.Empty(t, cmd.flags)
+
+	assert.NoError(t, cmd.Parse([]string{"minimal-acls=true"}))
+	assert.Equal(t, map[string]bool{db.MinimalACLs: true}, cmd.flags)
+
+	assert.EqualError(t, cmd.Parse([]string{"minimal-acls"}),
+		"malformed setting: minimal-acls")
+	assert.EqualError(t, cmd.Parse([]string{"foo=true"}), "unknown setting: foo")
+	assert.EqualError(t, cmd.Parse([]string{"minimal-acls=maybe"}),
+		"malformed value for minimal-acls: maybe")
+}
+
+func TestSettingsRun(t *testing.T) {
+	t.Parallel()
+
+	mock := new(mocks.Client)
+	mock.On("QueryBlueprints").Return([]db.Blueprint{{
+		Blueprint: blueprint.Blueprint{Namespace: "ns"}}}, nil)
+	mock.On("UpdateSettings", "ns", map[string]bool{db.MinimalACLs: true}).
+		Once().Return(nil)
+
+	cmd := &Settings{flags: map[string]bool{db.MinimalACLs: true}}
+	cmd.client = mock
+	assert.Zero(t, cmd.Run())
+
+	mock.On("UpdateSettings", "other", map[string]bool{db.MinimalACLs: false}).
+		Once().Return(assert.AnError)
+	cmd = &Settings{namespace: "other",
+		flags: map[string]bool{db.MinimalACLs: false}}
+	cmd.client = mock
+	assert.NotZero(t, cmd.Run())
+
+	mock.On("QuerySettings").Once().Return(nil, nil)
+	cmd = &Settings{}
+	cmd.client = mock
+	assert.Zero(t, cmd.Run())
+	mock.AssertExpectations(t)
+}
+
+func TestPrintSettings(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printSettings(&b, db.Settings{Flags: map[string]bool{db.MinimalACLs: true}})
+	assert.Equal(t, "SETTING        VALUE\ndisk-gc        false\n"+
+		"host-firewall  false\nminimal-acls   true\nself-host      false\n",
+		b.String())
+}
@@ -69,7 +69,8 @@ func (pCmd *Show) run() (err error) {
 
 	clusterUp := false
 	for _, m := range machines {
-		if m.Status == db.Connected || m.Status == db.Reconnecting {
+		if m.Status == db.Connected || m.Status == db.NetworkDegraded ||
+			m.Status == db.Draining || m.Status == db.Reconnecting {
 			clusterUp = true
 		}
 	}
@@ -134,10 +135,24 @@ func writeMachines(fd io.Writer, machines []db.Machine) {
 
 		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
 			util.ShortUUID(m.BlueprintID), m.Role, m.Provider, m.Region,
-			m.Size, pubIP, m.Status)
+			m.Size, pubIP, machineStatus(m))
 	}
 }
 
+// machineStatus returns the status of `m`, noting whether its minion rejected or
+// has yet to apply its most recent configuration.
+func machineStatus(m db.Machine) string {
+	switch {
+	case m.Status == "":
+		return ""
+	case m.ConfigError != "":
+		return m.Status + " (config rejected)"
+	case m.AppliedGeneration < m.ConfigGeneration:
+		return m.Status + " (config stale)"
+	}
+	return m.Status
+}
+
 func writeContainers(fd io.Writer, containers []db.Container, machines []db.Machine,
 	connections []db.Connection, images []db.Image, truncate bool) {
 	w := tabwriter.NewWriter(fd, 0, 0, 4, ' ', 0)
@@ -186,6 +201,8 @@ func writeContainers(fd io.Writer, containers []db.Container, machines []db.Mach
 
 			var status string
 			switch {
+			case dbc.Stopped && dbc.Minion == "":
+				status = "stopped"
 			case dbc.Status != "":
 				status = dbc.Status
 			case dbc.Minion != "":
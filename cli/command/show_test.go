@@ -134,6 +134,22 @@ func TestMachineOutput(t *testing.T) {
 	assert.Equal(t, exp, result)
 }
 
+func TestMachineStatus(t *testing.T) {
+	t.Parallel()
+
+	m := db.Machine{Status: db.Connected, ConfigGeneration: 2,
+		AppliedGeneration: 2}
+	assert.Equal(t, "connected", machineStatus(m))
+
+	m.ConfigGeneration = 3
+	assert.Equal(t, "connected (config stale)", machineStatus(m))
+
+	m.ConfigError = "invalid blueprint signature"
+	assert.Equal(t, "connected (config rejected)", machineStatus(m))
+
+	assert.Equal(t, "", machineStatus(db.Machine{}))
+}
+
 func checkContainerOutput(t *testing.T, containers []db.Container,
 	machines []db.Machine, connections []db.Connection, images []db.Image,
 	truncate bool, exp string) {
@@ -261,6 +277,18 @@ ________________________________________________________________________________
 `
 	checkContainerOutput(t, containers, machines, connections, nil, false, expected)
 
+	// Stopped containers are reported as such once they're torn down.
+	containers = []db.Container{
+		{ID: 1, BlueprintID: "3", IP: "1.2.3.4", Image: "image1",
+			Hostname: "paused", Stopped: true},
+	}
+
+	expected = `CONTAINER____MACHINE____COMMAND____HOSTNAME____STATUS_____` +
+		`CREATED____PUBLIC_IP
+3_______________________image1_____paused______stopped_______________
+`
+	checkContainerOutput(t, containers, machines, connections, nil, true, expected)
+
 	// Test writing container that has multiple connections to the public
 	// internet.
 	containers = []db.Container{{
@@ -0,0 +1,92 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/util"
+)
+
+// Simulate implements the `quilt simulate` command.
+type Simulate struct {
+	blueprint string
+
+	connectionHelper
+}
+
+var simulateCommands = "quilt simulate [OPTIONS] BLUEPRINT"
+var simulateExplanation = `Check whether a blueprint's containers can be placed on its machines.
+
+The daemon places the containers on the blueprint's worker machines as the
+scheduler would in a new cluster, without booting anything, and prints the
+containers placed on each machine, and how many workers could be removed while
+every container could still be placed.  The command fails if some containers
+can't be placed.  Like the scheduler, it only considers the placement
+constraints and port conflicts of the containers, and not whether the machines
+have enough CPU or RAM to run them.`
+
+// InstallFlags sets up parsing for command line flags.
+func (cmd *Simulate) InstallFlags(flags *flag.FlagSet) {
+	cmd.connectionHelper.InstallFlags(flags)
+	flags.StringVar(&cmd.blueprint, "blueprint", "", "the blueprint to simulate")
+	flags.Usage = func() {
+		util.PrintUsageString(simulateCommands, simulateExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the simulate command.
+func (cmd *Simulate) Parse(args []string) error {
+	if cmd.blueprint == "" {
+		if len(args) == 0 {
+			return errors.New("no blueprint specified")
+		}
+		cmd.blueprint = args[0]
+	}
+	return nil
+}
+
+// Run simulates placing the blueprint's containers.
+func (cmd *Simulate) Run() int {
+	compiled, err := compile(cmd.blueprint)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	reply, err := cmd.client.Simulate(compiled.String())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error simulating blueprint: %s\n", err)
+		return 1
+	}
+
+	printSimulation(os.Stdout, reply)
+	if !reply.AllPlaced {
+		return 1
+	}
+	return 0
+}
+
+func printSimulation(out io.Writer, reply pb.SimulateReply) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINE\tPROVIDER\tREGION\tSIZE\tCONTAINERS")
+	for _, m := range reply.Machines {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", util.ShortUUID(m.ID),
+			m.Provider, m.Region, m.Size, strings.Join(m.Containers, ", "))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if reply.AllPlaced {
+		fmt.Fprintf(out, "Every container was placed, with %d worker "+
+			"machine(s) to spare.\n", reply.SpareMachines)
+	} else {
+		fmt.Fprintf(out, "Some containers couldn't be placed: %s\n",
+			strings.Join(reply.Unplaced, ", "))
+	}
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/api/client/mocks"
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/blueprint"
+)
+
+func TestSimulate(t *testing.T) {
+	compile = func(path string) (blueprint.Blueprint, error) {
+		return blueprint.Blueprint{Namespace: path}, nil
+	}
+	defer func() { compile = blueprint.FromFile }()
+
+	bp := blueprint.Blueprint{Namespace: "bp"}.String()
+	mock := new(mocks.Client)
+	mock.On("Simulate", bp).Return(pb.SimulateReply{AllPlaced: true}, nil).Once()
+	cmd := &Simulate{blueprint: "bp"}
+	cmd.client = mock
+	assert.Equal(t, 0, cmd.Run())
+
+	mock.On("Simulate", bp).Return(pb.SimulateReply{}, nil).Once()
+	assert.Equal(t, 1, cmd.Run())
+
+	mock.On("Simulate", bp).Return(pb.SimulateReply{}, assert.AnError)
+	assert.Equal(t, 1, cmd.Run())
+	mock.AssertExpectations(t)
+
+	assert.EqualError(t, (&Simulate{}).Parse(nil), "no blueprint specified")
+}
+
+func TestPrintSimulation(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	printSimulation(&b, pb.SimulateReply{
+		AllPlaced:     true,
+		SpareMachines: 1,
+		Machines: []*pb.SimulatedMachine{{ID: "1", Provider: "Amazon",
+			Region: "us-west-1", Size: "m3.medium",
+			Containers: []string{"a", "b"}}},
+	})
+	assert.Equal(t, `MACHINE  PROVIDER  REGION     SIZE       CONTAINERS
+1        Amazon    us-west-1  m3.medium  a, b
+
+Every container was placed, with 1 worker machine(s) to spare.
+`, b.String())
+
+	b.Reset()
+	printSimulation(&b, pb.SimulateReply{Unplaced: []string{"a"}})
+	assert.Equal(t, `MACHINE  PROVIDER  REGION  SIZE  CONTAINERS
+
+Some containers couldn't be placed: a
+`, b.String())
+}
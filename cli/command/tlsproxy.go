@@ -0,0 +1,102 @@
+// +build !windows
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	tlsIO "github.com/kelda/kelda/connection/tls/io"
+	"github.com/kelda/kelda/minion/tlsproxy"
+	"github.com/kelda/kelda/util"
+)
+
+// TLSProxy contains the options for running the proxy that wraps a container's
+// mTLS connections.
+type TLSProxy struct {
+	inbound  ruleFlags
+	outbound ruleFlags
+	tlsDir   string
+	cleanup  bool
+}
+
+var tlsProxyCommands = "quilt tls-proxy [OPTIONS]"
+var tlsProxyExplanation = `Wrap a container's connections in mutual TLS.
+
+The minion runs the proxy in the network namespace of each container at either end
+of an mTLS connection.  Connections from the inbound peers, and to the outbound
+peers, are redirected to the proxy, which authenticates the peer with the
+namespace's certificate authority.  Peers are of the form IP:PORT or
+IP:MINPORT-MAXPORT.`
+
+// InstallFlags sets up parsing for command line flags.
+func (pCmd *TLSProxy) InstallFlags(flags *flag.FlagSet) {
+	flags.Var(&pCmd.inbound, "inbound", "accept mTLS connections from `PEER`")
+	flags.Var(&pCmd.outbound, "outbound", "wrap the connections to `PEER`")
+	flags.StringVar(&pCmd.tlsDir, "tls-dir", tlsproxy.TLSDir,
+		"the directory containing the TLS credentials")
+	flags.BoolVar(&pCmd.cleanup, "cleanup", false,
+		"remove the redirects left by a proxy, and exit")
+
+	flags.Usage = func() {
+		util.PrintUsageString(tlsProxyCommands, tlsProxyExplanation, flags)
+	}
+}
+
+// Parse parses the command line arguments for the tls-proxy command.
+func (pCmd *TLSProxy) Parse(args []string) error {
+	return nil
+}
+
+// BeforeRun makes any necessary post-parsing transformations.
+func (pCmd *TLSProxy) BeforeRun() error {
+	return nil
+}
+
+// AfterRun performs any necessary post-run cleanup.
+func (pCmd *TLSProxy) AfterRun() error {
+	return nil
+}
+
+// Run proxies the container's connections until it fails.
+func (pCmd *TLSProxy) Run() int {
+	if err := pCmd.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (pCmd *TLSProxy) run() error {
+	if pCmd.cleanup {
+		return tlsproxy.Cleanup()
+	}
+
+	creds, err := tlsIO.ReadCredentials(pCmd.tlsDir)
+	if err != nil {
+		return fmt.Errorf("read credentials: %s", err)
+	}
+	return tlsproxy.Run(creds, pCmd.inbound, pCmd.outbound)
+}
+
+// ruleFlags are the peers of the proxy's container.
+type ruleFlags []tlsproxy.Rule
+
+func (rf *ruleFlags) String() string {
+	var strs []string
+	for _, r := range *rf {
+		strs = append(strs, r.String())
+	}
+	return strings.Join(strs, " ")
+}
+
+func (rf *ruleFlags) Set(value string) error {
+	r, err := tlsproxy.ParseRule(value)
+	if err != nil {
+		return err
+	}
+	*rf = append(*rf, r)
+	return nil
+}
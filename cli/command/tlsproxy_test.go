@@ -0,0 +1,37 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/minion/tlsproxy"
+	"github.com/kelda/kelda/util"
+)
+
+func TestTLSProxyFlags(t *testing.T) {
+	t.Parallel()
+
+	cmd := &TLSProxy{}
+	err := parseHelper(cmd, []string{"-inbound", "10.0.0.2:80",
+		"-outbound", "10.0.0.3:443", "-outbound", "10.0.0.4:8000-8080"})
+	assert.NoError(t, err)
+	assert.Equal(t, ruleFlags{{IP: "10.0.0.2", MinPort: 80, MaxPort: 80}},
+		cmd.inbound)
+	assert.Equal(t, ruleFlags{
+		{IP: "10.0.0.3", MinPort: 443, MaxPort: 443},
+		{IP: "10.0.0.4", MinPort: 8000, MaxPort: 8080},
+	}, cmd.outbound)
+	assert.Equal(t, "10.0.0.3:443 10.0.0.4:8000-8080", cmd.outbound.String())
+	assert.Equal(t, tlsproxy.TLSDir, cmd.tlsDir)
+
+	assert.Error(t, cmd.inbound.Set("10.0.0.2"))
+}
+
+func TestTLSProxyMissingCredentials(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	cmd := &TLSProxy{tlsDir: "/tls"}
+	assert.Equal(t, 1, cmd.Run())
+}
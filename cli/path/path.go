@@ -18,4 +18,17 @@ var (
 	// DefaultSSHKeyPath is the default filepath where the private SSH key used
 	// to access Quilt will be stored.
 	DefaultSSHKeyPath = filepath.Join(quiltHome, "ssh_key")
+
+	// DefaultDaemonConfigPath is the default location of the daemon's
+	// configuration file.
+	DefaultDaemonConfigPath = filepath.Join(quiltHome, "daemon.json")
+
+	// DefaultGitOpsDir is where the daemon checks out the repository whose
+	// blueprint it deploys.
+	DefaultGitOpsDir = filepath.Join(quiltHome, "gitops")
+
+	// DefaultRedeemedTokensPath is where the daemon's asset server stores the
+	// boot tokens that have been redeemed, so that they can't be replayed once
+	// the daemon restarts.
+	DefaultRedeemedTokensPath = filepath.Join(quiltHome, "redeemed_tokens.json")
 )
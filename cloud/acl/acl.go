@@ -1,12 +1,87 @@
 package acl
 
-// ACL represents allowed traffic to a machine.
+import (
+	"net"
+	"strings"
+)
+
+// ACL represents allowed traffic to a machine.  CidrIP may be an IPv4 or an IPv6
+// CIDR block.
 type ACL struct {
 	CidrIP  string
 	MinPort int
 	MaxPort int
 }
 
+// IPv6 returns whether the ACL allows traffic from an IPv6 CIDR block.
+func (acl ACL) IPv6() bool {
+	return strings.Contains(acl.CidrIP, ":")
+}
+
+// HostCIDR returns the CIDR block that contains only `ip`.
+func HostCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// Canonical returns `cidr` with the host bits of its address cleared, in the form
+// that firewalls list it, e.g. 10.0.0.0/8 for 10.0.0.1/8.  Comparing ACLs with
+// the listed rules would otherwise never match, and they would be recreated on
+// every run.  Strings that aren't CIDR blocks are returned unchanged.
+func Canonical(cidr string) string {
+	if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+		return ipnet.String()
+	}
+	return cidr
+}
+
+// The ports on which masters serve etcd.  Besides the masters themselves, the
+// etcd proxies of workers use both: the client port to proxy requests, and the
+// peer port to discover the members of the cluster.
+const (
+	EtcdClientPort = 2379
+	EtcdPeerPort   = 2380
+)
+
+// AllPorts allows traffic to every port, except those of etcd, from `cidr`.
+// Etcd is only exposed to the machines of the cluster.
+func AllPorts(cidr string) []ACL {
+	return ACL{CidrIP: cidr, MinPort: 1, MaxPort: 65535}.WithoutEtcd()
+}
+
+// WithoutEtcd returns the ACLs that allow the same traffic as `acl`, except to
+// the ports of etcd.
+func (acl ACL) WithoutEtcd() []ACL {
+	var acls []ACL
+	if acl.MinPort < EtcdClientPort {
+		below := acl
+		below.MaxPort = min(acl.MaxPort, EtcdClientPort-1)
+		acls = append(acls, below)
+	}
+	if acl.MaxPort > EtcdPeerPort {
+		above := acl
+		above.MinPort = max(acl.MinPort, EtcdPeerPort+1)
+		acls = append(acls, above)
+	}
+	return acls
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // Slice is an alias for []ACL to allow for joins
 type Slice []ACL
 
@@ -1,6 +1,7 @@
 package acl
 
 import (
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,3 +14,30 @@ func TestSlice(t *testing.T) {
 	assert.Equal(t, slice.Len(), 1)
 	assert.Equal(t, slice.Get(0), acl)
 }
+
+func TestWithoutEtcd(t *testing.T) {
+	assert.Equal(t, []ACL{{"1.2.3.4/32", 1, 2378}, {"1.2.3.4/32", 2381, 65535}},
+		AllPorts("1.2.3.4/32"))
+
+	assert.Equal(t, []ACL{{"cidr", 80, 80}}, ACL{"cidr", 80, 80}.WithoutEtcd())
+	assert.Equal(t, []ACL{{"cidr", 2000, 2378}},
+		ACL{"cidr", 2000, 2379}.WithoutEtcd())
+	assert.Equal(t, []ACL{{"cidr", 2381, 3000}},
+		ACL{"cidr", 2380, 3000}.WithoutEtcd())
+	assert.Empty(t, ACL{"cidr", 2379, 2380}.WithoutEtcd())
+}
+
+func TestIPv6(t *testing.T) {
+	assert.False(t, ACL{CidrIP: "1.2.3.4/32"}.IPv6())
+	assert.True(t, ACL{CidrIP: "2001:db8::/32"}.IPv6())
+
+	assert.Equal(t, "1.2.3.4/32", HostCIDR(net.ParseIP("1.2.3.4")))
+	assert.Equal(t, "2001:db8::1/128", HostCIDR(net.ParseIP("2001:db8::1")))
+}
+
+func TestCanonical(t *testing.T) {
+	assert.Equal(t, "10.0.0.0/8", Canonical("10.0.0.1/8"))
+	assert.Equal(t, "1.2.3.4/32", Canonical("1.2.3.4/32"))
+	assert.Equal(t, "2001:db8::/32", Canonical("2001:db8:0::1/32"))
+	assert.Equal(t, "local", Canonical("local"))
+}
@@ -0,0 +1,839 @@
+package alibaba
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/alibaba/client"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/wait"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/util"
+
+	"github.com/satori/go.uuid"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRegion is assigned to Machines without a specified region
+const DefaultRegion string = "us-west-1"
+
+// Regions supported by the Alibaba provider.
+var Regions = []string{
+	"ap-northeast-1",
+	"ap-south-1",
+	"ap-southeast-1",
+	"ap-southeast-2",
+	"cn-beijing",
+	"cn-hangzhou",
+	"cn-hongkong",
+	"cn-shanghai",
+	"eu-central-1",
+	"us-east-1",
+	"us-west-1",
+}
+
+var configPath = filepath.Join(".alibaba", "quilt.json")
+
+// The environment variables that override the AccessKey in the config file.
+const (
+	accessKeyIDEnv     = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	accessKeySecretEnv = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+)
+
+// The key of the tag whose value is the namespace of the instance.
+const namespaceTag = "quilt-namespace"
+
+// The Ubuntu 16.04 image that machines boot by default.  Public images have the
+// same ID in every region.
+const defaultImage = "ubuntu_16_04_64_20G_alibase_20180409.vhd"
+
+// The bandwidth, in Mbps, of the Elastic IPs that are allocated for machines.
+const eipBandwidth = "100"
+
+// config is the configuration in ~/.alibaba/quilt.json.
+type config struct {
+	client.Credentials
+
+	// The Ubuntu 16.04 image that machines boot.
+	Image string
+
+	// Maps regions to the VSwitch that machines in the region are attached
+	// to.  Regions that aren't listed use the VSwitch of their default VPC.
+	VSwitches map[string]string
+}
+
+func readConfig() (config, error) {
+	conf := config{Image: defaultImage}
+
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+	confStr, err := util.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(confStr), &conf); err != nil {
+			return conf, fmt.Errorf("parse %s: %s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return conf, err
+	}
+
+	creds.FromEnv(&conf.AccessKeyID, accessKeyIDEnv)
+	creds.FromEnv(&conf.AccessKeySecret, accessKeySecretEnv)
+
+	switch {
+	case conf.AccessKeyID == "" && conf.AccessKeySecret == "":
+		return conf, creds.MissingError{Provider: db.Alibaba, Sources: []string{
+			path, creds.EnvSource(accessKeyIDEnv, accessKeySecretEnv)}}
+	case conf.AccessKeyID == "" || conf.AccessKeySecret == "":
+		return conf, fmt.Errorf("%s must contain the accessKeyId and "+
+			"accessKeySecret of an AccessKey", path)
+	}
+	return conf, nil
+}
+
+// The Provider object represents a connection to an Alibaba Cloud region.
+// Instances are tagged with the namespace, and share a security group that's
+// named after the namespace.  Instances don't have a fixed public IP.  Instead,
+// each is associated with an Elastic IP, which is either one of the blueprint's
+// floating IPs, or one that's allocated for the instance and named after the
+// namespace.
+type Provider struct {
+	client.Client
+
+	namespace string
+	region    string
+	config    config
+}
+
+// New starts a new client session with the AccessKey in ~/.alibaba/quilt.json,
+// or in the environment.
+func New(namespace, region string) (*Provider, error) {
+	prvdr, err := newAlibaba(namespace, region)
+	if err != nil {
+		return prvdr, err
+	}
+
+	_, err = prvdr.List()
+	return prvdr, err
+}
+
+// Creation is broken out for unit testing.
+var newAlibaba = func(namespace, region string) (*Provider, error) {
+	// Replayed responses don't require valid credentials.
+	conf := config{Image: defaultImage}
+	if !cassette.Replaying() {
+		var err error
+		if conf, err = readConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Provider{
+		Client: client.New(conf.Credentials, region,
+			"alibaba-"+region),
+		namespace: namespace,
+		region:    region,
+		config:    conf,
+	}, nil
+}
+
+type instance struct {
+	InstanceID    string
+	InstanceName  string
+	InstanceType  string
+	Status        string
+	SpotStrategy  string
+	CreationTime  string
+	VpcAttributes struct {
+		PrivateIPAddress struct{ IPAddress []string }
+	}
+	Tags struct {
+		Tag []struct{ TagKey, TagValue string }
+	}
+}
+
+// The format of instance creation times, which omit the seconds.
+const creationTimeFormat = "2006-01-02T15:04Z"
+
+type eip struct {
+	AllocationID string
+	IPAddress    string
+	InstanceID   string
+	Name         string
+	Status       string
+}
+
+// List the instances in the namespace.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	instances, err := prvdr.namespaceInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	eips, err := prvdr.eips(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceEIPs := map[string]eip{}
+	for _, ip := range eips {
+		if ip.InstanceID != "" {
+			instanceEIPs[ip.InstanceID] = ip
+		}
+	}
+
+	var machines []db.Machine
+	for _, inst := range instances {
+		m := db.Machine{
+			CloudID: inst.InstanceID,
+			Size:    inst.InstanceType,
+			Preemptible: inst.SpotStrategy != "" &&
+				inst.SpotStrategy != "NoSpot",
+		}
+
+		if ips := inst.VpcAttributes.PrivateIPAddress.IPAddress; len(ips) > 0 {
+			m.PrivateIP = ips[0]
+		}
+
+		if ip, ok := instanceEIPs[inst.InstanceID]; ok {
+			m.PublicIP = ip.IPAddress
+			if ip.Name != prvdr.eipName() {
+				m.FloatingIP = ip.IPAddress
+			}
+		}
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+// namespaceInstances returns the instances tagged with the namespace.
+func (prvdr Provider) namespaceInstances() ([]instance, error) {
+	return prvdr.instances(map[string]string{
+		"Tag.1.Key":   namespaceTag,
+		"Tag.1.Value": prvdr.namespace,
+	})
+}
+
+// instances returns every page of the instances that match `params`.
+func (prvdr Provider) instances(params map[string]string) ([]instance, error) {
+	var instances []instance
+	for page := 1; ; page++ {
+		var resp struct {
+			TotalCount int
+			Instances  struct{ Instance []instance }
+		}
+
+		pageParams := map[string]string{
+			"PageSize":   "100",
+			"PageNumber": strconv.Itoa(page),
+		}
+		for key, value := range params {
+			pageParams[key] = value
+		}
+
+		err := prvdr.Do(client.ECS, "DescribeInstances", pageParams, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("list instances: %s", err)
+		}
+
+		instances = append(instances, resp.Instances.Instance...)
+		if len(resp.Instances.Instance) == 0 ||
+			len(instances) >= resp.TotalCount {
+			return instances, nil
+		}
+	}
+}
+
+// instance returns the instance with the given ID, or false if it doesn't exist.
+func (prvdr Provider) instance(id string) (instance, bool, error) {
+	instances, err := prvdr.instances(map[string]string{
+		"InstanceIds": fmt.Sprintf(`["%s"]`, id),
+	})
+	if err != nil || len(instances) == 0 {
+		return instance{}, false, err
+	}
+	return instances[0], true, nil
+}
+
+// eips returns every page of the Elastic IPs that match `params`.
+func (prvdr Provider) eips(params map[string]string) ([]eip, error) {
+	var eips []eip
+	for page := 1; ; page++ {
+		var resp struct {
+			TotalCount   int
+			EipAddresses struct{ EipAddress []eip }
+		}
+
+		pageParams := map[string]string{
+			"PageSize":   "100",
+			"PageNumber": strconv.Itoa(page),
+		}
+		for key, value := range params {
+			pageParams[key] = value
+		}
+
+		err := prvdr.Do(client.VPC, "DescribeEipAddresses", pageParams, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("list elastic IPs: %s", err)
+		}
+
+		eips = append(eips, resp.EipAddresses.EipAddress...)
+		if len(resp.EipAddresses.EipAddress) == 0 ||
+			len(eips) >= resp.TotalCount {
+			return eips, nil
+		}
+	}
+}
+
+// The resources that instances are booted with.
+type bootResources struct {
+	vSwitchID, securityGroupID string
+}
+
+// Boot creates each instance in a goroutine, and waits for them to start.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	vSwitchID, vpcID, err := prvdr.vSwitch()
+	if err != nil {
+		return err
+	}
+
+	groupID, err := prvdr.securityGroup(vpcID)
+	if err != nil {
+		return fmt.Errorf("create security group: %s", err)
+	}
+
+	res := bootResources{vSwitchID: vSwitchID, securityGroupID: groupID}
+	errChan := make(chan error, len(bootSet))
+	for _, m := range bootSet {
+		go func(m db.Machine) {
+			errChan <- prvdr.createInstance(m, res)
+		}(m)
+	}
+
+	for range bootSet {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// vSwitch returns the IDs of the VSwitch that instances are attached to, and of
+// its VPC.
+func (prvdr Provider) vSwitch() (string, string, error) {
+	params := map[string]string{"IsDefault": "true"}
+	if id, ok := prvdr.config.VSwitches[prvdr.region]; ok {
+		params = map[string]string{"VSwitchId": id}
+	}
+
+	var resp struct {
+		VSwitches struct {
+			VSwitch []struct{ VSwitchID, VpcID string }
+		}
+	}
+	if err := prvdr.Do(client.VPC, "DescribeVSwitches", params, &resp); err != nil {
+		return "", "", fmt.Errorf("find vswitch: %s", err)
+	}
+
+	if len(resp.VSwitches.VSwitch) == 0 {
+		return "", "", fmt.Errorf("no vswitch in %s. Configure one in ~/%s",
+			prvdr.region, configPath)
+	}
+	vSwitch := resp.VSwitches.VSwitch[0]
+	return vSwitch.VSwitchID, vSwitch.VpcID, nil
+}
+
+// createInstance creates an instance, associates it with an Elastic IP, and
+// starts it.  The instance is started last so that it can reach the internet
+// when it boots.  If any step fails, the instance is deleted.
+func (prvdr Provider) createInstance(m db.Machine, res bootResources) error {
+	params := map[string]string{
+		"ImageId":         prvdr.config.Image,
+		"InstanceType":    m.Size,
+		"InstanceName":    "quilt-" + uuid.NewV4().String(),
+		"SecurityGroupId": res.securityGroupID,
+		"VSwitchId":       res.vSwitchID,
+		"Tag.1.Key":       namespaceTag,
+		"Tag.1.Value":     prvdr.namespace,
+		"UserData": base64.StdEncoding.EncodeToString(
+			[]byte(cfg.UserData(m, ""))),
+	}
+
+	if m.DiskSize != 0 {
+		params["SystemDisk.Size"] = strconv.Itoa(m.DiskSize)
+	}
+
+	if m.Hostname != "" {
+		params["InstanceName"] = m.Hostname
+		params["HostName"] = m.Hostname
+	}
+
+	if m.Preemptible {
+		params["SpotStrategy"] = "SpotAsPriceGo"
+	}
+
+	var created struct{ InstanceID string }
+	err := prvdr.Do(client.ECS, "CreateInstance", params, &created)
+	if err != nil {
+		return fmt.Errorf("create instance: %s", err)
+	}
+
+	id := created.InstanceID
+	if err := prvdr.setupInstance(id); err != nil {
+		if cleanupErr := prvdr.deleteInstance(id); cleanupErr != nil {
+			log.WithError(cleanupErr).WithField("id", id).Warn(
+				"Failed to clean up instance that failed to boot")
+		}
+		return err
+	}
+	return nil
+}
+
+func (prvdr Provider) setupInstance(id string) error {
+	if err := prvdr.waitForStatus(id, "Stopped"); err != nil {
+		return err
+	}
+
+	if err := prvdr.allocateEIP(id); err != nil {
+		return err
+	}
+
+	err := prvdr.Do(client.ECS, "StartInstance",
+		map[string]string{"InstanceId": id}, nil)
+	if err != nil {
+		return fmt.Errorf("start instance %s: %s", id, err)
+	}
+	return prvdr.waitForStatus(id, "Running")
+}
+
+func (prvdr Provider) waitForStatus(id, status string) error {
+	err := wait.Wait(func() bool {
+		inst, ok, err := prvdr.instance(id)
+		return err == nil && ok && inst.Status == status
+	})
+	if err != nil {
+		return fmt.Errorf("wait for instance %s to be %s: %s", id,
+			strings.ToLower(status), err)
+	}
+	return nil
+}
+
+// Stop deletes each instance, and releases the Elastic IPs allocated for them.
+func (prvdr Provider) Stop(machines []db.Machine) error {
+	errChan := make(chan error, len(machines))
+	for _, m := range machines {
+		go func(m db.Machine) {
+			errChan <- prvdr.deleteInstance(m.CloudID)
+		}(m)
+	}
+
+	var err error
+	for range machines {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Reboot restarts each instance in place.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		err := prvdr.Do(client.ECS, "RebootInstance",
+			map[string]string{"InstanceId": m.CloudID}, nil)
+		if err != nil {
+			return fmt.Errorf("reboot instance %s: %s", m.CloudID, err)
+		}
+	}
+	return nil
+}
+
+func (prvdr Provider) deleteInstance(id string) error {
+	if err := prvdr.unassociateEIPs(id); err != nil {
+		return err
+	}
+
+	err := prvdr.Do(client.ECS, "DeleteInstance",
+		map[string]string{"InstanceId": id, "Force": "true"}, nil)
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("delete instance %s: %s", id, err)
+	}
+
+	return wait.Wait(func() bool {
+		_, ok, err := prvdr.instance(id)
+		return err == nil && !ok
+	})
+}
+
+func (prvdr Provider) eipName() string {
+	return "quilt-" + prvdr.namespace
+}
+
+// allocateEIP allocates an Elastic IP named after the namespace, and associates
+// it with the instance.
+func (prvdr Provider) allocateEIP(instanceID string) error {
+	var allocated struct{ AllocationID string }
+	err := prvdr.Do(client.VPC, "AllocateEipAddress", map[string]string{
+		"Bandwidth":          eipBandwidth,
+		"InternetChargeType": "PayByTraffic",
+		"Name":               prvdr.eipName(),
+	}, &allocated)
+	if err != nil {
+		return fmt.Errorf("allocate elastic IP: %s", err)
+	}
+
+	err = prvdr.associateEIP(eip{AllocationID: allocated.AllocationID}, instanceID)
+	if err != nil {
+		if releaseErr := prvdr.releaseEIP(allocated.AllocationID); releaseErr != nil {
+			log.WithError(releaseErr).Warn("Failed to release elastic IP")
+		}
+	}
+	return err
+}
+
+// associateEIP associates `ip` with the instance, and waits for it to be in use.
+func (prvdr Provider) associateEIP(ip eip, instanceID string) error {
+	err := prvdr.Do(client.VPC, "AssociateEipAddress", map[string]string{
+		"AllocationId": ip.AllocationID,
+		"InstanceId":   instanceID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("associate elastic IP %s with %s: %s",
+			ip.AllocationID, instanceID, err)
+	}
+	return prvdr.waitForEIP(ip.AllocationID, "InUse")
+}
+
+// unassociateEIPs unassociates the Elastic IPs of the instance, and releases
+// those that were allocated for it.
+func (prvdr Provider) unassociateEIPs(instanceID string) error {
+	eips, err := prvdr.eips(map[string]string{
+		"AssociatedInstanceType": "EcsInstance",
+		"AssociatedInstanceId":   instanceID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range eips {
+		err := prvdr.Do(client.VPC, "UnassociateEipAddress", map[string]string{
+			"AllocationId": ip.AllocationID,
+			"InstanceId":   instanceID,
+		}, nil)
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("unassociate elastic IP %s: %s",
+				ip.IPAddress, err)
+		}
+
+		if err := prvdr.waitForEIP(ip.AllocationID, "Available"); err != nil {
+			return err
+		}
+
+		if ip.Name == prvdr.eipName() {
+			if err := prvdr.releaseEIP(ip.AllocationID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (prvdr Provider) releaseEIP(allocationID string) error {
+	err := prvdr.Do(client.VPC, "ReleaseEipAddress",
+		map[string]string{"AllocationId": allocationID}, nil)
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("release elastic IP %s: %s", allocationID, err)
+	}
+	return nil
+}
+
+func (prvdr Provider) waitForEIP(allocationID, status string) error {
+	err := wait.Wait(func() bool {
+		eips, err := prvdr.eips(map[string]string{"AllocationId": allocationID})
+		return err == nil && len(eips) > 0 && eips[0].Status == status
+	})
+	if err != nil {
+		return fmt.Errorf("wait for elastic IP %s: %s", allocationID, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional features that Alibaba supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true, Preemptible: true,
+		ResizableDisks: true,
+	}
+}
+
+// UpdateFloatingIPs associates instances with the Elastic IPs listed as their
+// floating IP.  Instances without a floating IP are associated with a newly
+// allocated Elastic IP instead, so that they can still reach the internet.
+func (prvdr Provider) UpdateFloatingIPs(desired []db.Machine) error {
+	curr, err := prvdr.List()
+	if err != nil {
+		return fmt.Errorf("list machines: %s", err)
+	}
+
+	idKey := func(intf interface{}) interface{} {
+		return intf.(db.Machine).CloudID
+	}
+	pairs, _, unmatchedDesired := join.HashJoin(
+		db.MachineSlice(curr), db.MachineSlice(desired), idKey, idKey)
+
+	if len(unmatchedDesired) != 0 {
+		var unmatchedIDs []string
+		for _, m := range unmatchedDesired {
+			unmatchedIDs = append(unmatchedIDs, m.(db.Machine).CloudID)
+		}
+		return fmt.Errorf("no matching IDs: %s", strings.Join(unmatchedIDs, ", "))
+	}
+
+	for _, pair := range pairs {
+		curr := pair.L.(db.Machine)
+		desired := pair.R.(db.Machine)
+		if curr.FloatingIP == desired.FloatingIP {
+			continue
+		}
+
+		if err := prvdr.unassociateEIPs(curr.CloudID); err != nil {
+			return err
+		}
+
+		if desired.FloatingIP == "" {
+			err = prvdr.allocateEIP(curr.CloudID)
+		} else {
+			err = prvdr.associateFloatingIP(desired.FloatingIP, curr.CloudID)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (prvdr Provider) associateFloatingIP(ip, instanceID string) error {
+	eips, err := prvdr.eips(map[string]string{"EipAddress": ip})
+	if err != nil {
+		return err
+	} else if len(eips) == 0 {
+		return fmt.Errorf("no elastic IP %s in %s", ip, prvdr.region)
+	}
+	return prvdr.associateEIP(eips[0], instanceID)
+}
+
+// Resources lists the instances in the namespace, the Elastic IPs associated
+// with them or allocated for the namespace, and the namespace's security groups.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	instances, err := prvdr.namespaceInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	instanceIDs := map[string]bool{}
+	for _, inst := range instances {
+		instanceIDs[inst.InstanceID] = true
+
+		tags := map[string]string{}
+		for _, tag := range inst.Tags.Tag {
+			tags[tag.TagKey] = tag.TagValue
+		}
+
+		// The creation time is omitted if it can't be parsed.
+		created, _ := time.Parse(creationTimeFormat, inst.CreationTime)
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      inst.InstanceID,
+			Name:    inst.InstanceName,
+			Tags:    tags,
+			Created: created,
+		})
+	}
+
+	eips, err := prvdr.eips(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range eips {
+		if instanceIDs[ip.InstanceID] || ip.Name == prvdr.eipName() {
+			resources = append(resources, resource.Resource{
+				Type: resource.IP,
+				ID:   ip.AllocationID,
+				Name: ip.IPAddress,
+			})
+		}
+	}
+
+	var groups struct {
+		SecurityGroups struct {
+			SecurityGroup []struct{ SecurityGroupID string }
+		}
+	}
+	err = prvdr.Do(client.ECS, "DescribeSecurityGroups", map[string]string{
+		"SecurityGroupName": prvdr.groupName(),
+	}, &groups)
+	if err != nil {
+		return nil, fmt.Errorf("list security groups: %s", err)
+	}
+
+	for _, group := range groups.SecurityGroups.SecurityGroup {
+		resources = append(resources, resource.Resource{
+			Type: resource.SecurityGroup,
+			ID:   group.SecurityGroupID,
+			Name: prvdr.groupName(),
+		})
+	}
+	return resources, nil
+}
+
+func (prvdr Provider) groupName() string {
+	return "quilt-" + prvdr.namespace
+}
+
+// securityGroup returns the ID of the namespace's security group in the VPC,
+// creating it if it doesn't exist.  New groups allow all traffic between their
+// members.
+func (prvdr Provider) securityGroup(vpcID string) (string, error) {
+	var groups struct {
+		SecurityGroups struct {
+			SecurityGroup []struct{ SecurityGroupID string }
+		}
+	}
+	err := prvdr.Do(client.ECS, "DescribeSecurityGroups", map[string]string{
+		"VpcId":             vpcID,
+		"SecurityGroupName": prvdr.groupName(),
+	}, &groups)
+	if err != nil {
+		return "", fmt.Errorf("list security groups: %s", err)
+	}
+
+	if len(groups.SecurityGroups.SecurityGroup) > 0 {
+		return groups.SecurityGroups.SecurityGroup[0].SecurityGroupID, nil
+	}
+
+	var created struct{ SecurityGroupID string }
+	err = prvdr.Do(client.ECS, "CreateSecurityGroup", map[string]string{
+		"VpcId":             vpcID,
+		"SecurityGroupName": prvdr.groupName(),
+		"Description":       "Quilt namespace " + prvdr.namespace,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+
+	id := created.SecurityGroupID
+	err = prvdr.Do(client.ECS, "AuthorizeSecurityGroup", map[string]string{
+		"SecurityGroupId": id,
+		"IpProtocol":      "all",
+		"PortRange":       "-1/-1",
+		"SourceGroupId":   id,
+	}, nil)
+	return id, err
+}
+
+// A securityRule is the part of a security group rule that's set from an ACL.
+type securityRule struct {
+	IPProtocol   string
+	PortRange    string
+	SourceCidrIP string
+}
+
+// SetACLs adds and removes the rules of the namespace's security group, so that
+// it allows the given TCP, UDP, and ICMP traffic.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	_, vpcID, err := prvdr.vSwitch()
+	if err != nil {
+		return err
+	}
+
+	groupID, err := prvdr.securityGroup(vpcID)
+	if err != nil {
+		return err
+	}
+
+	var current struct {
+		Permissions struct{ Permission []securityRule }
+	}
+	err = prvdr.Do(client.ECS, "DescribeSecurityGroupAttribute",
+		map[string]string{"SecurityGroupId": groupID, "Direction": "ingress"},
+		&current)
+	if err != nil {
+		return fmt.Errorf("list security group rules: %s", err)
+	}
+
+	// Rules without a source CIDR, such as the rule that allows traffic
+	// between members of the group, aren't managed by ACLs.  Protocols are
+	// listed in upper case, but set in lower case.
+	var currRules []securityRule
+	for _, rule := range current.Permissions.Permission {
+		if rule.SourceCidrIP != "" {
+			rule.IPProtocol = strings.ToLower(rule.IPProtocol)
+			currRules = append(currRules, rule)
+		}
+	}
+
+	var desired []securityRule
+	for _, a := range acls {
+		portRange := fmt.Sprintf("%d/%d", a.MinPort, a.MaxPort)
+		desired = append(desired,
+			securityRule{"tcp", portRange, a.CidrIP},
+			securityRule{"udp", portRange, a.CidrIP},
+			securityRule{"icmp", "-1/-1", a.CidrIP})
+	}
+
+	_, toAdd, toRemove := join.HashJoin(securityRuleSlice(desired),
+		securityRuleSlice(currRules), nil, nil)
+
+	for _, intf := range toRemove {
+		err := prvdr.authorize("RevokeSecurityGroup", groupID,
+			intf.(securityRule))
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("revoke security group rule: %s", err)
+		}
+	}
+
+	for _, intf := range toAdd {
+		rule := intf.(securityRule)
+		log.WithField("rule", rule).Debug("Alibaba: Adding ACL")
+		if err := prvdr.authorize("AuthorizeSecurityGroup", groupID,
+			rule); err != nil {
+			return fmt.Errorf("authorize security group rule: %s", err)
+		}
+	}
+	return nil
+}
+
+// authorize calls `action`, which either authorizes or revokes `rule`.
+func (prvdr Provider) authorize(action, groupID string, rule securityRule) error {
+	return prvdr.Do(client.ECS, action, map[string]string{
+		"SecurityGroupId": groupID,
+		"IpProtocol":      rule.IPProtocol,
+		"PortRange":       rule.PortRange,
+		"SourceCidrIp":    rule.SourceCidrIP,
+	}, nil)
+}
+
+type securityRuleSlice []securityRule
+
+func (slc securityRuleSlice) Get(ii int) interface{} {
+	return slc[ii]
+}
+
+func (slc securityRuleSlice) Len() int {
+	return len(slc)
+}
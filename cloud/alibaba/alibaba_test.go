@@ -0,0 +1,485 @@
+package alibaba
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/alibaba/client"
+	"github.com/kelda/kelda/cloud/alibaba/client/mocks"
+	"github.com/kelda/kelda/cloud/cloudtest"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+// page returns `params` with the parameters that request the given page of a
+// Describe action.
+func page(number string, params map[string]string) map[string]string {
+	paged := map[string]string{"PageSize": "100", "PageNumber": number}
+	for key, value := range params {
+		paged[key] = value
+	}
+	return paged
+}
+
+var namespaceParams = map[string]string{"Tag.1.Key": namespaceTag,
+	"Tag.1.Value": "ns"}
+
+func instancesReply(total int, instances ...map[string]interface{}) func(
+	mock.Arguments) {
+	return cloudtest.Reply(map[string]interface{}{"TotalCount": total,
+		"Instances": map[string]interface{}{"Instance": instances}})
+}
+
+func eipsReply(eips ...map[string]string) func(mock.Arguments) {
+	return cloudtest.Reply(map[string]interface{}{"TotalCount": len(eips),
+		"EipAddresses": map[string]interface{}{"EipAddress": eips}})
+}
+
+func TestList(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	// The instances are listed across several pages.
+	mc.On("Do", client.ECS, "DescribeInstances", page("1", namespaceParams),
+		mock.Anything).Return(nil).Run(instancesReply(2,
+		map[string]interface{}{
+			"InstanceId":   "i-1",
+			"InstanceType": "ecs.n4.small",
+			"SpotStrategy": "NoSpot",
+			"VpcAttributes": map[string]interface{}{"PrivateIpAddress": map[string]interface{}{
+				"IpAddress": []string{"172.16.0.1"}}},
+		}))
+	mc.On("Do", client.ECS, "DescribeInstances", page("2", namespaceParams),
+		mock.Anything).Return(nil).Run(instancesReply(2,
+		map[string]interface{}{
+			"InstanceId":   "i-2",
+			"InstanceType": "ecs.n4.large",
+			"SpotStrategy": "SpotAsPriceGo",
+		}))
+
+	// The Elastic IP allocated for an instance is only its public IP, while
+	// one of the blueprint's is also its floating IP.
+	mc.On("Do", client.VPC, "DescribeEipAddresses", page("1", nil),
+		mock.Anything).Return(nil).Run(eipsReply(
+		map[string]string{"IpAddress": "8.8.8.8", "InstanceId": "i-1",
+			"Name": "quilt-ns"},
+		map[string]string{"IpAddress": "1.2.3.4", "InstanceId": "i-2"},
+		map[string]string{"IpAddress": "5.6.7.8"}))
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{
+		{CloudID: "i-1", Size: "ecs.n4.small", PrivateIP: "172.16.0.1",
+			PublicIP: "8.8.8.8"},
+		{CloudID: "i-2", Size: "ecs.n4.large", Preemptible: true,
+			PublicIP: "1.2.3.4", FloatingIP: "1.2.3.4"},
+	}, machines)
+}
+
+// expectBootResources sets up the VSwitch and the security group that instances
+// are booted in.
+func expectBootResources(mc *mocks.Client) {
+	mc.On("Do", client.VPC, "DescribeVSwitches",
+		map[string]string{"IsDefault": "true"}, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"VSwitches": map[string]interface{}{
+			"VSwitch": []map[string]string{
+				{"VSwitchId": "vsw", "VpcId": "vpc"}}}}))
+	mc.On("Do", client.ECS, "DescribeSecurityGroups", map[string]string{
+		"VpcId": "vpc", "SecurityGroupName": "quilt-ns"},
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"SecurityGroups": map[string]interface{}{"SecurityGroup": []map[string]string{
+			{"SecurityGroupId": "sg"}}}}))
+}
+
+func TestBoot(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns", config: config{Image: "ubuntu"}}
+	expectBootResources(mc)
+
+	var params map[string]string
+	mc.On("Do", client.ECS, "CreateInstance", mock.Anything,
+		mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		params = args.Get(2).(map[string]string)
+		cloudtest.Reply(map[string]string{"InstanceId": "i-1"})(args)
+	}).Once()
+
+	instanceParams := page("1", map[string]string{"InstanceIds": `["i-1"]`})
+	mc.On("Do", client.ECS, "DescribeInstances", instanceParams,
+		mock.Anything).Return(nil).Run(instancesReply(1, map[string]interface{}{
+		"InstanceId": "i-1", "Status": "Stopped"})).Once()
+
+	// Instances don't have a public IP, so the Elastic IP is associated before
+	// the instance starts, so that it can reach the internet when it boots.
+	mc.On("Do", client.VPC, "AllocateEipAddress", map[string]string{
+		"Bandwidth":          eipBandwidth,
+		"InternetChargeType": "PayByTraffic",
+		"Name":               "quilt-ns",
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"AllocationId": "eip-1"})).Once()
+	mc.On("Do", client.VPC, "AssociateEipAddress", map[string]string{
+		"AllocationId": "eip-1", "InstanceId": "i-1"}, nil).Return(nil).Once()
+	mc.On("Do", client.VPC, "DescribeEipAddresses",
+		page("1", map[string]string{"AllocationId": "eip-1"}),
+		mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+		"AllocationId": "eip-1", "Status": "InUse"}))
+	mc.On("Do", client.ECS, "StartInstance", map[string]string{
+		"InstanceId": "i-1"}, nil).Return(nil).Once()
+	mc.On("Do", client.ECS, "DescribeInstances", instanceParams,
+		mock.Anything).Return(nil).Run(instancesReply(1, map[string]interface{}{
+		"InstanceId": "i-1", "Status": "Running"}))
+
+	err := prvdr.Boot([]db.Machine{{Size: "ecs.n4.large", Preemptible: true,
+		Hostname: "worker-1", DiskSize: 40}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	var actions []string
+	for _, call := range mc.Calls {
+		switch action := call.Arguments.String(1); action {
+		case "AssociateEipAddress", "StartInstance":
+			actions = append(actions, action)
+		}
+	}
+	assert.Equal(t, []string{"AssociateEipAddress", "StartInstance"}, actions)
+
+	assert.NotEmpty(t, params["UserData"])
+	delete(params, "UserData")
+	assert.Equal(t, map[string]string{
+		"ImageId":         "ubuntu",
+		"InstanceType":    "ecs.n4.large",
+		"InstanceName":    "worker-1",
+		"HostName":        "worker-1",
+		"SecurityGroupId": "sg",
+		"VSwitchId":       "vsw",
+		"Tag.1.Key":       namespaceTag,
+		"Tag.1.Value":     "ns",
+		"SystemDisk.Size": "40",
+		"SpotStrategy":    "SpotAsPriceGo",
+	}, params)
+}
+
+func TestBootErrors(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+	expectBootResources(mc)
+
+	mc.On("Do", client.ECS, "CreateInstance", mock.Anything,
+		mock.Anything).Return(client.Error{
+		Code: "InvalidInstanceType.ValueNotSupported"}).Once()
+	err := prvdr.Boot([]db.Machine{{Size: "ecs.huge"}})
+	assert.EqualError(t, err, "create instance: "+
+		"InvalidInstanceType.ValueNotSupported: ")
+
+	// If the Elastic IP can't be associated, it's released, and the instance
+	// is deleted.
+	mc.On("Do", client.ECS, "CreateInstance", mock.Anything,
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"InstanceId": "i-1"})).Once()
+
+	instanceParams := page("1", map[string]string{"InstanceIds": `["i-1"]`})
+	mc.On("Do", client.ECS, "DescribeInstances", instanceParams,
+		mock.Anything).Return(nil).Run(instancesReply(1, map[string]interface{}{
+		"InstanceId": "i-1", "Status": "Stopped"})).Once()
+	mc.On("Do", client.VPC, "AllocateEipAddress", mock.Anything,
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"AllocationId": "eip-1"})).Once()
+	mc.On("Do", client.VPC, "AssociateEipAddress", mock.Anything, nil).Return(
+		errors.New("forbidden")).Once()
+	mc.On("Do", client.VPC, "ReleaseEipAddress", map[string]string{
+		"AllocationId": "eip-1"}, nil).Return(nil).Once()
+
+	mc.On("Do", client.VPC, "DescribeEipAddresses", page("1", map[string]string{
+		"AssociatedInstanceType": "EcsInstance",
+		"AssociatedInstanceId":   "i-1",
+	}), mock.Anything).Return(nil).Run(eipsReply())
+	mc.On("Do", client.ECS, "DeleteInstance", map[string]string{
+		"InstanceId": "i-1", "Force": "true"}, nil).Return(nil).Once()
+	mc.On("Do", client.ECS, "DescribeInstances", instanceParams,
+		mock.Anything).Return(nil).Run(instancesReply(0))
+
+	err = prvdr.Boot([]db.Machine{{Size: "ecs.n4.small"}})
+	assert.EqualError(t, err, "associate elastic IP eip-1 with i-1: forbidden")
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "Do", client.ECS, "StartInstance", mock.Anything,
+		mock.Anything)
+}
+
+func TestStop(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	// Both Elastic IPs are unassociated, but only the one allocated for the
+	// instance is released.  The other is one of the blueprint's floating IPs.
+	mc.On("Do", client.VPC, "DescribeEipAddresses", page("1", map[string]string{
+		"AssociatedInstanceType": "EcsInstance",
+		"AssociatedInstanceId":   "i-1",
+	}), mock.Anything).Return(nil).Run(eipsReply(
+		map[string]string{"AllocationId": "eip-1", "Name": "quilt-ns"},
+		map[string]string{"AllocationId": "eip-2"}))
+	for _, id := range []string{"eip-1", "eip-2"} {
+		mc.On("Do", client.VPC, "UnassociateEipAddress", map[string]string{
+			"AllocationId": id, "InstanceId": "i-1"}, nil).Return(nil).Once()
+		mc.On("Do", client.VPC, "DescribeEipAddresses",
+			page("1", map[string]string{"AllocationId": id}),
+			mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+			"AllocationId": id, "Status": "Available"}))
+	}
+	mc.On("Do", client.VPC, "ReleaseEipAddress", map[string]string{
+		"AllocationId": "eip-1"}, nil).Return(nil).Once()
+
+	mc.On("Do", client.ECS, "DeleteInstance", map[string]string{
+		"InstanceId": "i-1", "Force": "true"}, nil).Return(nil).Once()
+	mc.On("Do", client.ECS, "DescribeInstances",
+		page("1", map[string]string{"InstanceIds": `["i-1"]`}),
+		mock.Anything).Return(nil).Run(instancesReply(0))
+
+	assert.NoError(t, prvdr.Stop([]db.Machine{{CloudID: "i-1"}}))
+	mc.AssertExpectations(t)
+}
+
+func TestReboot(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	mc.On("Do", client.ECS, "RebootInstance", map[string]string{
+		"InstanceId": "i-1"}, nil).Return(nil).Once()
+	assert.NoError(t, prvdr.Reboot([]db.Machine{{CloudID: "i-1"}}))
+
+	mc.On("Do", client.ECS, "RebootInstance", map[string]string{
+		"InstanceId": "i-2"}, nil).Return(client.Error{
+		Code: "InvalidInstanceId.NotFound"})
+	err := prvdr.Reboot([]db.Machine{{CloudID: "i-2"}})
+	assert.EqualError(t, err,
+		"reboot instance i-2: InvalidInstanceId.NotFound: ")
+	mc.AssertExpectations(t)
+}
+
+func TestResources(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	mc.On("Do", client.ECS, "DescribeInstances", page("1", namespaceParams),
+		mock.Anything).Return(nil).Run(instancesReply(1, map[string]interface{}{
+		"InstanceId":   "i-1",
+		"InstanceName": "worker-1",
+		"CreationTime": "2017-10-13T17:00Z",
+		"Tags": map[string]interface{}{"Tag": []map[string]string{
+			{"TagKey": namespaceTag, "TagValue": "ns"}}},
+	}))
+
+	// Elastic IPs that were allocated for the namespace are reported even if
+	// they aren't associated, but unrelated Elastic IPs aren't.
+	mc.On("Do", client.VPC, "DescribeEipAddresses", page("1", nil),
+		mock.Anything).Return(nil).Run(eipsReply(
+		map[string]string{"AllocationId": "floating", "IpAddress": "1.2.3.4",
+			"InstanceId": "i-1"},
+		map[string]string{"AllocationId": "leaked", "IpAddress": "5.6.7.8",
+			"Name": "quilt-ns"},
+		map[string]string{"AllocationId": "unrelated", "IpAddress": "9.9.9.9"}))
+	mc.On("Do", client.ECS, "DescribeSecurityGroups", map[string]string{
+		"SecurityGroupName": "quilt-ns"}, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"SecurityGroups": map[string]interface{}{
+			"SecurityGroup": []map[string]string{{"SecurityGroupId": "sg"}}}}))
+
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{
+			Type:    resource.Instance,
+			ID:      "i-1",
+			Name:    "worker-1",
+			Tags:    map[string]string{namespaceTag: "ns"},
+			Created: time.Date(2017, 10, 13, 17, 0, 0, 0, time.UTC),
+		},
+		{Type: resource.IP, ID: "floating", Name: "1.2.3.4"},
+		{Type: resource.IP, ID: "leaked", Name: "5.6.7.8"},
+		{Type: resource.SecurityGroup, ID: "sg", Name: "quilt-ns"},
+	}, resources)
+}
+
+func TestUpdateFloatingIPs(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns", region: DefaultRegion}
+
+	mc.On("Do", client.ECS, "DescribeInstances", page("1", namespaceParams),
+		mock.Anything).Return(nil).Run(instancesReply(1, map[string]interface{}{
+		"InstanceId": "i-1"}))
+	mc.On("Do", client.VPC, "DescribeEipAddresses", page("1", nil),
+		mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+		"AllocationId": "eip-1", "IpAddress": "8.8.8.8", "InstanceId": "i-1",
+		"Name": "quilt-ns"}))
+
+	err := prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "no matching IDs: missing")
+
+	// The Elastic IP allocated for the instance is released, and replaced by
+	// the floating IP.
+	mc.On("Do", client.VPC, "DescribeEipAddresses", page("1", map[string]string{
+		"AssociatedInstanceType": "EcsInstance",
+		"AssociatedInstanceId":   "i-1",
+	}), mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+		"AllocationId": "eip-1", "Name": "quilt-ns"}))
+	mc.On("Do", client.VPC, "UnassociateEipAddress", map[string]string{
+		"AllocationId": "eip-1", "InstanceId": "i-1"}, nil).Return(nil)
+	mc.On("Do", client.VPC, "DescribeEipAddresses",
+		page("1", map[string]string{"AllocationId": "eip-1"}),
+		mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+		"AllocationId": "eip-1", "Status": "Available"}))
+	mc.On("Do", client.VPC, "ReleaseEipAddress", map[string]string{
+		"AllocationId": "eip-1"}, nil).Return(nil)
+
+	mc.On("Do", client.VPC, "DescribeEipAddresses",
+		page("1", map[string]string{"EipAddress": "1.2.3.4"}),
+		mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+		"AllocationId": "reserved", "IpAddress": "1.2.3.4"}))
+	mc.On("Do", client.VPC, "AssociateEipAddress", map[string]string{
+		"AllocationId": "reserved", "InstanceId": "i-1"}, nil).Return(nil).Once()
+	mc.On("Do", client.VPC, "DescribeEipAddresses",
+		page("1", map[string]string{"AllocationId": "reserved"}),
+		mock.Anything).Return(nil).Run(eipsReply(map[string]string{
+		"AllocationId": "reserved", "Status": "InUse"}))
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "i-1",
+		FloatingIP: "1.2.3.4"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	// Floating IPs must already be allocated in the region.
+	mc.On("Do", client.VPC, "DescribeEipAddresses",
+		page("1", map[string]string{"EipAddress": "5.6.7.8"}),
+		mock.Anything).Return(nil).Run(eipsReply())
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "i-1",
+		FloatingIP: "5.6.7.8"}})
+	assert.EqualError(t, err, "no elastic IP 5.6.7.8 in us-west-1")
+}
+
+func TestSetACLs(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+	expectBootResources(mc)
+
+	// Protocols are listed in upper case.  The rule that allows traffic
+	// within the group has no source CIDR, so it's left alone.
+	mc.On("Do", client.ECS, "DescribeSecurityGroupAttribute", map[string]string{
+		"SecurityGroupId": "sg", "Direction": "ingress"},
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"Permissions": map[string]interface{}{"Permission": []map[string]string{
+			{"IpProtocol": "ALL", "PortRange": "-1/-1"},
+			{"IpProtocol": "TCP", "PortRange": "80/80",
+				"SourceCidrIp": "1.2.3.4/32"},
+			{"IpProtocol": "ICMP", "PortRange": "-1/-1",
+				"SourceCidrIp": "1.2.3.4/32"},
+			{"IpProtocol": "TCP", "PortRange": "22/22",
+				"SourceCidrIp": "5.6.7.8/32"},
+		}}}))
+	mc.On("Do", client.ECS, "RevokeSecurityGroup", map[string]string{
+		"SecurityGroupId": "sg",
+		"IpProtocol":      "tcp",
+		"PortRange":       "22/22",
+		"SourceCidrIp":    "5.6.7.8/32",
+	}, nil).Return(nil).Once()
+	mc.On("Do", client.ECS, "AuthorizeSecurityGroup", map[string]string{
+		"SecurityGroupId": "sg",
+		"IpProtocol":      "udp",
+		"PortRange":       "80/80",
+		"SourceCidrIp":    "1.2.3.4/32",
+	}, nil).Return(nil).Once()
+
+	assert.NoError(t, prvdr.SetACLs([]acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80}}))
+	mc.AssertExpectations(t)
+}
+
+func TestCreateSecurityGroup(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	// New groups allow all traffic between their members.
+	mc.On("Do", client.ECS, "DescribeSecurityGroups", map[string]string{
+		"VpcId": "vpc", "SecurityGroupName": "quilt-ns"},
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{}))
+	mc.On("Do", client.ECS, "CreateSecurityGroup", map[string]string{
+		"VpcId":             "vpc",
+		"SecurityGroupName": "quilt-ns",
+		"Description":       "Quilt namespace ns",
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"SecurityGroupId": "sg"})).Once()
+	mc.On("Do", client.ECS, "AuthorizeSecurityGroup", map[string]string{
+		"SecurityGroupId": "sg",
+		"IpProtocol":      "all",
+		"PortRange":       "-1/-1",
+		"SourceGroupId":   "sg",
+	}, nil).Return(nil).Once()
+
+	id, err := prvdr.securityGroup("vpc")
+	assert.NoError(t, err)
+	assert.Equal(t, "sg", id)
+	mc.AssertExpectations(t)
+}
+
+func TestVSwitch(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns", region: "us-west-1",
+		config: config{VSwitches: map[string]string{"us-west-1": "configured"}}}
+
+	mc.On("Do", client.VPC, "DescribeVSwitches", map[string]string{
+		"VSwitchId": "configured"}, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"VSwitches": map[string]interface{}{
+			"VSwitch": []map[string]string{
+				{"VSwitchId": "configured", "VpcId": "vpc"}}}}))
+	id, vpc, err := prvdr.vSwitch()
+	assert.NoError(t, err)
+	assert.Equal(t, "configured", id)
+	assert.Equal(t, "vpc", vpc)
+
+	// Regions without a configured VSwitch use their default one, if they
+	// have one.
+	prvdr.region = "us-east-1"
+	mc.On("Do", client.VPC, "DescribeVSwitches", map[string]string{
+		"IsDefault": "true"}, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{}))
+	_, _, err = prvdr.vSwitch()
+	assert.EqualError(t, err, "no vswitch in us-east-1. Configure one in "+
+		"~/.alibaba/quilt.json")
+}
+
+func TestReadConfig(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+
+	util.WriteFile(path, []byte(`{"accessKeyId": "id",
+		"accessKeySecret": "secret"}`), 0600)
+	conf, err := readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "id", conf.AccessKeyID)
+	assert.Equal(t, "secret", conf.AccessKeySecret)
+	assert.Equal(t, defaultImage, conf.Image)
+
+	util.WriteFile(path, []byte(`{"accessKeyId": "id"}`), 0600)
+	_, err = readConfig()
+	assert.EqualError(t, err, path+" must contain the accessKeyId and "+
+		"accessKeySecret of an AccessKey")
+
+	// The environment takes precedence over the config file.
+	os.Setenv(accessKeySecretEnv, "envSecret")
+	defer os.Unsetenv(accessKeySecretEnv)
+	conf, err = readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "id", conf.AccessKeyID)
+	assert.Equal(t, "envSecret", conf.AccessKeySecret)
+
+	os.Unsetenv(accessKeySecretEnv)
+	util.AppFs = afero.NewMemMapFs()
+	_, err = readConfig()
+	assert.True(t, creds.IsMissing(err))
+}
@@ -0,0 +1,172 @@
+//go:generate mockery -name=Client
+
+// Package client is a minimal client of the Alibaba Cloud RPC APIs.  Each request
+// calls an action, such as "RunInstances", and is signed with the user's
+// AccessKey.
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+
+	"github.com/satori/go.uuid"
+)
+
+// The services that Quilt uses.  Instances and security groups are managed with
+// ECS, and Elastic IPs with VPC.
+const (
+	ECS = "ecs"
+	VPC = "vpc"
+)
+
+// The API version of each service.
+var versions = map[string]string{
+	ECS: "2014-05-26",
+	VPC: "2016-04-28",
+}
+
+// A Client for the Alibaba Cloud APIs.  Used for unit testing.
+type Client interface {
+	// Do calls `action` of `service` with `params`, and decodes the response
+	// into `result` if it's non-nil.  The RegionId parameter defaults to the
+	// client's region.
+	Do(service, action string, params map[string]string, result interface{}) error
+}
+
+// An Error is returned when the API rejects a request.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// IsNotFound returns whether `err` reports that a resource doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(Error)
+	return ok && (apiErr.StatusCode == http.StatusNotFound ||
+		strings.HasSuffix(apiErr.Code, ".NotFound"))
+}
+
+// Credentials are the AccessKey with which requests are signed.
+type Credentials struct {
+	AccessKeyID     string
+	AccessKeySecret string
+}
+
+var c = counter.New("Alibaba")
+
+var now = time.Now
+
+type client struct {
+	http   *http.Client
+	creds  Credentials
+	region string
+}
+
+// New creates a client of the services in `region`, whose requests are recorded
+// or replayed as `cassetteName`.
+func New(creds Credentials, region, cassetteName string) Client {
+	return &client{
+		http: cassette.Wrap(cassetteName, throttle.Wrap(db.Alibaba, region,
+			endpoint.Client(db.Alibaba, &http.Client{}))),
+		creds:  creds,
+		region: region,
+	}
+}
+
+func (client *client) Do(service, action string, params map[string]string,
+	result interface{}) error {
+
+	c.Inc(action)
+
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	if values.Get("RegionId") == "" {
+		values.Set("RegionId", client.region)
+	}
+	values.Set("Action", action)
+	values.Set("Format", "JSON")
+	values.Set("Version", versions[service])
+	values.Set("AccessKeyId", client.creds.AccessKeyID)
+	values.Set("SignatureMethod", "HMAC-SHA1")
+	values.Set("SignatureVersion", "1.0")
+	values.Set("SignatureNonce", uuid.NewV4().String())
+	values.Set("Timestamp", now().UTC().Format("2006-01-02T15:04:05Z"))
+	values.Set("Signature", sign("POST", values, client.creds.AccessKeySecret))
+
+	// The action is sent in the URL, and the other parameters in the body,
+	// so that replayed requests are matched by their action despite the
+	// nonce and timestamp.
+	values.Del("Action")
+	reqURL := baseURL(service) + "?Action=" + url.QueryEscape(action)
+	resp, err := client.http.Post(reqURL, "application/x-www-form-urlencoded",
+		strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(respBody, &apiErr) != nil || apiErr.Code == "" {
+			apiErr.Code = resp.Status
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+func baseURL(service string) string {
+	if base, ok := endpoint.Get(db.Alibaba); ok {
+		return base
+	}
+	return "https://" + service + ".aliyuncs.com/"
+}
+
+// sign computes the signature of a request with the given parameters, as
+// described in the "Request signature" section of the ECS API reference.
+func sign(method string, values url.Values, secret string) string {
+	// Encode sorts the parameters by their keys.  Its escaping only differs
+	// from RFC 3986 in that spaces become '+', and literal '+' are escaped.
+	canonical := strings.Replace(values.Encode(), "+", "%20", -1)
+	toSign := method + "&" + percentEncode("/") + "&" + percentEncode(canonical)
+
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(toSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode escapes `str` as specified by RFC 3986, which differs from
+// url.QueryEscape in that spaces are escaped as %20 rather than '+'.
+func percentEncode(str string) string {
+	return strings.Replace(url.QueryEscape(str), "+", "%20", -1)
+}
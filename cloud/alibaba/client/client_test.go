@@ -0,0 +1,83 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestDo(t *testing.T) {
+	now = func() time.Time { return time.Unix(0, 0) }
+	creds := Credentials{AccessKeyID: "id", AccessKeySecret: "secret"}
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, r.ParseForm())
+
+			// The signature covers every parameter, including the action
+			// in the URL.
+			params := url.Values{}
+			for key, value := range r.Form {
+				params[key] = value
+			}
+			signature := params.Get("Signature")
+			params.Del("Signature")
+			assert.Equal(t, sign("POST", params, "secret"), signature)
+
+			assert.Equal(t, "id", r.Form.Get("AccessKeyId"))
+			assert.Equal(t, "1970-01-01T00:00:00Z", r.Form.Get("Timestamp"))
+			assert.Equal(t, "2014-05-26", r.Form.Get("Version"))
+
+			switch r.URL.Query().Get("Action") {
+			case "DescribeInstances":
+				fmt.Fprintf(w, `{"RegionId": "%s"}`, r.Form.Get("RegionId"))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"Code": "InvalidInstanceId.NotFound",
+					"Message": "missing"}`)
+			}
+		}))
+	defer server.Close()
+
+	endpoint.Overrides[db.Alibaba] = server.URL
+	defer delete(endpoint.Overrides, db.Alibaba)
+
+	client := New(creds, "us-west-1", "")
+
+	var res struct{ RegionID string }
+	assert.NoError(t, client.Do(ECS, "DescribeInstances", nil, &res))
+	assert.Equal(t, "us-west-1", res.RegionID)
+
+	assert.NoError(t, client.Do(ECS, "DescribeInstances",
+		map[string]string{"RegionId": "us-east-1"}, &res))
+	assert.Equal(t, "us-east-1", res.RegionID)
+
+	err := client.Do(ECS, "DeleteInstance", nil, nil)
+	assert.EqualError(t, err, "InvalidInstanceId.NotFound: missing")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestSign(t *testing.T) {
+	// The example from the ECS API reference.
+	params := url.Values{
+		"AccessKeyId":      {"testid"},
+		"Action":           {"DescribeRegions"},
+		"Format":           {"XML"},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureNonce":   {"3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf"},
+		"SignatureVersion": {"1.0"},
+		"Timestamp":        {"2016-02-23T12:46:24Z"},
+		"Version":          {"2014-05-26"},
+	}
+	assert.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=",
+		sign("GET", params, "testsecret"))
+}
@@ -2,14 +2,22 @@ package amazon
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/cloud/amazon/client"
+	"github.com/kelda/kelda/cloud/capability"
 	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/machine"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/cloud/wait"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
@@ -27,6 +35,9 @@ type Provider struct {
 
 	namespace string
 	region    string
+
+	// The region's Ubuntu ARM64 image, once it has been looked up.
+	armAMI string
 }
 
 type awsMachine struct {
@@ -41,12 +52,22 @@ const (
 	// user specified region preference.
 	DefaultRegion = "us-west-1"
 
-	spotPrice = "0.5"
+	// The maximum hourly price bid for spot instances whose blueprint doesn't
+	// specify one.
+	defaultSpotPrice = "0.5"
 )
 
 // Regions is the list of supported AWS regions.
 var Regions = []string{"ap-southeast-2", "us-west-1", "us-west-2"}
 
+// Zones are the availability zones of each region, across which masters may be
+// spread.  Newer accounts don't have us-west-1a, so it's left out.
+var Zones = map[string][]string{
+	"ap-southeast-2": {"ap-southeast-2a", "ap-southeast-2b", "ap-southeast-2c"},
+	"us-west-1":      {"us-west-1b", "us-west-1c"},
+	"us-west-2":      {"us-west-2a", "us-west-2b", "us-west-2c"},
+}
+
 // Ubuntu 16.04, 64-bit hvm:ebs-ssd
 var amis = map[string]string{
 	"ap-southeast-2": "ami-943d3bf7",
@@ -54,11 +75,20 @@ var amis = map[string]string{
 	"us-west-2":      "ami-d206bdb2",
 }
 
+// ARM64 machines boot from the newest of Canonical's Ubuntu 16.04 ARM64 images in
+// their region, as they aren't available in every region.
+const (
+	canonicalOwner = "099720109477"
+	armAMIName     = "ubuntu/images/hvm-ssd/ubuntu-xenial-16.04-arm64-server-*"
+)
+
 var sleep = time.Sleep
 
 var timeout = 5 * time.Minute
 
-// New creates a new Amazon EC2 cluster.
+// New creates a new Amazon EC2 cluster.  Credentials are found by the AWS SDK:
+// in the environment, in ~/.aws/credentials, or in the role of the instance that
+// the daemon runs on.
 func New(namespace, region string) (*Provider, error) {
 	prvdr := newAmazon(namespace, region)
 	if _, err := prvdr.List(); err != nil {
@@ -76,10 +106,10 @@ func New(namespace, region string) (*Provider, error) {
 		// AWS probably failed to connect because no access credentials
 		// were found. AWS's error message is not very helpful, so try to
 		// point the user in the right direction.
-		return nil, fmt.Errorf("AWS failed to find access "+
-			"credentials. At least one method for finding access "+
-			"credentials must succeed, but they all failed: %s)",
-			credErr.Error())
+		log.WithError(credErr).Debug("AWS failed to find access credentials")
+		return nil, creds.MissingError{Provider: db.Amazon, Sources: []string{
+			creds.EnvSource("AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"),
+			"~/.aws/credentials", "the role of an EC2 instance"}}
 	}
 	return prvdr, nil
 }
@@ -97,10 +127,24 @@ func newAmazon(namespace, region string) *Provider {
 
 type bootReq struct {
 	groupID     string
+	subnet      string
 	cfg         string
 	size        string
 	diskSize    int
+	volumeType  string
+	iops        int
 	preemptible bool
+	maxPrice    float64
+	hostname    string
+	profile     string
+	groups      string
+	image       string
+	zone        string
+	group       string
+	ipv6        bool
+
+	// The JSON encoding of the machines' tags, as maps can't be compared.
+	tags string
 }
 
 // Boot creates instances in the `prvdr` configured according to the `bootSet`.
@@ -109,23 +153,65 @@ func (prvdr *Provider) Boot(bootSet []db.Machine) error {
 		return nil
 	}
 
-	groupID, _, err := prvdr.getCreateSecurityGroup()
-	if err != nil {
-		return err
-	}
-
+	// The security group of each VPC, by VPC ID.
+	groupIDs := map[string]string{}
+	placementGroups := map[string]bool{}
 	bootReqMap := make(map[bootReq]int64) // From boot request to an instance count.
 	for _, m := range bootSet {
+		groupID, ok := groupIDs[m.VPC]
+		if !ok {
+			var err error
+			groupID, err = prvdr.getCreateSecurityGroup(m.VPC)
+			if err != nil {
+				return err
+			}
+			groupIDs[m.VPC] = groupID
+		}
+
+		if m.PlacementGroup != "" && !placementGroups[m.PlacementGroup] {
+			if err := prvdr.getCreatePlacementGroup(
+				m.PlacementGroup); err != nil {
+				return fmt.Errorf("placement group %s: %s",
+					m.PlacementGroup, err)
+			}
+			placementGroups[m.PlacementGroup] = true
+		}
+
+		tags, err := json.Marshal(m.Tags)
+		if err != nil {
+			return err
+		}
+
+		image := m.Image
+		if image == "" && m.Arch == blueprint.ARM64 {
+			if image, err = prvdr.getARMImage(); err != nil {
+				return fmt.Errorf("ARM64 image: %s", err)
+			}
+		}
+
 		br := bootReq{
 			groupID:     groupID,
-			cfg:         cfg.Ubuntu(m, ""),
+			subnet:      m.Subnet,
+			cfg:         cfg.UserData(m, ""),
 			size:        m.Size,
 			diskSize:    m.DiskSize,
+			volumeType:  m.VolumeType,
+			iops:        m.IOPS,
 			preemptible: m.Preemptible,
+			maxPrice:    m.MaxPrice,
+			hostname:    m.Hostname,
+			profile:     m.InstanceProfile,
+			groups:      strings.Join(m.SecurityGroups, " "),
+			image:       image,
+			zone:        m.Zone,
+			group:       m.PlacementGroup,
+			ipv6:        m.IPv6,
+			tags:        string(tags),
 		}
 		bootReqMap[br] = bootReqMap[br] + 1
 	}
 
+	var err error
 	for br, count := range bootReqMap {
 		if br.preemptible {
 			err = prvdr.bootSpot(br, count)
@@ -141,15 +227,124 @@ func (prvdr *Provider) Boot(bootSet []db.Machine) error {
 	return nil
 }
 
+// subnetID returns the subnet to launch the instances into, or nil to launch
+// them into the default VPC.
+func (br bootReq) subnetID() *string {
+	if br.subnet == "" {
+		return nil
+	}
+	return aws.String(br.subnet)
+}
+
+// network returns the security groups, subnet and network interfaces to launch
+// the instances with.  Instances are only assigned IPv6 addresses if their network
+// interface requests them, in which case the security groups and subnet must be
+// set on the interface rather than on the request.
+func (br bootReq) network() (groups []*string, subnet *string,
+	interfaces []*ec2.InstanceNetworkInterfaceSpecification) {
+
+	groups = []*string{aws.String(br.groupID)}
+	for _, group := range strings.Fields(br.groups) {
+		if group != br.groupID {
+			groups = append(groups, aws.String(group))
+		}
+	}
+
+	if !br.ipv6 {
+		return groups, br.subnetID(), nil
+	}
+
+	return nil, nil, []*ec2.InstanceNetworkInterfaceSpecification{{
+		DeviceIndex:              aws.Int64(0),
+		Groups:                   groups,
+		SubnetId:                 br.subnetID(),
+		AssociatePublicIpAddress: aws.Bool(true),
+		Ipv6AddressCount:         aws.Int64(1),
+	}}
+}
+
+// instanceProfile returns the IAM instance profile to attach to the instances, or
+// nil if they don't have one.
+func (br bootReq) instanceProfile() *ec2.IamInstanceProfileSpecification {
+	if br.profile == "" {
+		return nil
+	}
+	return &ec2.IamInstanceProfileSpecification{Name: aws.String(br.profile)}
+}
+
+// placement returns the zone and placement group to launch the instances into,
+// or nil to let Amazon choose.
+func (br bootReq) placement() *ec2.Placement {
+	if br.zone == "" && br.group == "" {
+		return nil
+	}
+	return &ec2.Placement{
+		AvailabilityZone: optionalString(br.zone),
+		GroupName:        optionalString(br.group),
+	}
+}
+
+// spotPlacement is the same as placement, for spot requests.
+func (br bootReq) spotPlacement() *ec2.SpotPlacement {
+	if br.zone == "" && br.group == "" {
+		return nil
+	}
+	return &ec2.SpotPlacement{
+		AvailabilityZone: optionalString(br.zone),
+		GroupName:        optionalString(br.group),
+	}
+}
+
+// imageID returns the AMI that the instances boot from, which is the region's
+// Ubuntu image unless the blueprint chose its own.
+func (br bootReq) imageID(region string) *string {
+	if br.image == "" {
+		return aws.String(amis[region])
+	}
+	return aws.String(br.image)
+}
+
+// getARMImage returns the newest Ubuntu ARM64 image in the region.
+func (prvdr *Provider) getARMImage() (string, error) {
+	if prvdr.armAMI != "" {
+		return prvdr.armAMI, nil
+	}
+
+	images, err := prvdr.DescribeImages(canonicalOwner, armAMIName)
+	if err != nil {
+		return "", err
+	}
+
+	// The creation dates are in ISO 8601, so they sort chronologically.
+	var newest *ec2.Image
+	for _, image := range images {
+		if newest == nil || resolveString(image.CreationDate) >
+			resolveString(newest.CreationDate) {
+			newest = image
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no images in %s", prvdr.region)
+	}
+
+	prvdr.armAMI = resolveString(newest.ImageId)
+	return prvdr.armAMI, nil
+}
+
 func (prvdr *Provider) bootReserved(br bootReq, count int64) error {
 	cloudConfig64 := base64.StdEncoding.EncodeToString([]byte(br.cfg))
+	groups, subnet, interfaces := br.network()
 	resp, err := prvdr.RunInstances(&ec2.RunInstancesInput{
-		ImageId:          aws.String(amis[prvdr.region]),
-		InstanceType:     aws.String(br.size),
-		UserData:         &cloudConfig64,
-		SecurityGroupIds: []*string{aws.String(br.groupID)},
+		ImageId:            br.imageID(prvdr.region),
+		InstanceType:       aws.String(br.size),
+		UserData:           &cloudConfig64,
+		SecurityGroupIds:   groups,
+		SubnetId:           subnet,
+		NetworkInterfaces:  interfaces,
+		IamInstanceProfile: br.instanceProfile(),
+		Placement:          br.placement(),
 		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
-			blockDevice(br.diskSize)},
+			blockDevice(br.diskSize, br.volumeType, br.iops)},
 		MaxCount: &count,
 		MinCount: &count,
 	})
@@ -168,21 +363,32 @@ func (prvdr *Provider) bootReserved(br bootReq, count int64) error {
 			log.WithError(stopErr).WithField("ids", ids).
 				Error("Failed to cleanup failed boots")
 		}
+		return err
 	}
 
-	return err
+	return prvdr.tagInstances(ids, br)
 }
 
 func (prvdr *Provider) bootSpot(br bootReq, count int64) error {
+	price := defaultSpotPrice
+	if br.maxPrice > 0 {
+		price = strconv.FormatFloat(br.maxPrice, 'f', -1, 64)
+	}
+
 	cloudConfig64 := base64.StdEncoding.EncodeToString([]byte(br.cfg))
-	spots, err := prvdr.RequestSpotInstances(spotPrice, count,
+	groups, subnet, interfaces := br.network()
+	spots, err := prvdr.RequestSpotInstances(price, count,
 		&ec2.RequestSpotLaunchSpecification{
-			ImageId:          aws.String(amis[prvdr.region]),
-			InstanceType:     aws.String(br.size),
-			UserData:         &cloudConfig64,
-			SecurityGroupIds: []*string{aws.String(br.groupID)},
+			ImageId:            br.imageID(prvdr.region),
+			InstanceType:       aws.String(br.size),
+			UserData:           &cloudConfig64,
+			SecurityGroupIds:   groups,
+			SubnetId:           subnet,
+			NetworkInterfaces:  interfaces,
+			IamInstanceProfile: br.instanceProfile(),
+			Placement:          br.spotPlacement(),
 			BlockDeviceMappings: []*ec2.BlockDeviceMapping{
-				blockDevice(br.diskSize)}})
+				blockDevice(br.diskSize, br.volumeType, br.iops)}})
 	if err != nil {
 		return err
 	}
@@ -192,17 +398,139 @@ func (prvdr *Provider) bootSpot(br bootReq, count int64) error {
 		ids = append(ids, *request.SpotInstanceRequestId)
 	}
 
-	err = prvdr.wait(ids, true)
+	instIDs, err := prvdr.waitSpots(ids)
 	if err != nil {
 		if stopErr := prvdr.stopSpots(ids); stopErr != nil {
 			log.WithError(stopErr).WithField("ids", ids).
 				Error("Failed to cleanup failed boots")
 		}
+		return err
+	}
+	return prvdr.tagInstances(instIDs, br)
+}
+
+// waitSpots waits for the spot requests with the given IDs to be fulfilled, and
+// returns the IDs of their instances.  It gives up early if a request fails or
+// is closed, as it will never be fulfilled.
+func (prvdr *Provider) waitSpots(ids []string) ([]string, error) {
+	var instIDs, pending []string
+	var failed error
+	err := wait.Wait(func() bool {
+		spots, err := prvdr.DescribeSpotInstanceRequests(ids, nil)
+		if err != nil {
+			log.WithError(err).Warn("Failed to describe spot requests.")
+			return false
+		}
+
+		instIDs, pending = nil, nil
+		for _, spot := range spots {
+			id := resolveString(spot.SpotInstanceRequestId)
+			switch resolveString(spot.State) {
+			case ec2.SpotInstanceStateFailed, ec2.SpotInstanceStateCancelled,
+				ec2.SpotInstanceStateClosed:
+				failed = fmt.Errorf("spot request %s %s", id, spotStatus(spot))
+				return true
+			}
+
+			if spot.InstanceId == nil {
+				pending = append(pending,
+					fmt.Sprintf("%s (%s)", id, spotStatus(spot)))
+				continue
+			}
+			instIDs = append(instIDs, *spot.InstanceId)
+		}
+		return len(instIDs) == len(ids)
+	})
+
+	switch {
+	case failed != nil:
+		return nil, failed
+	case err != nil:
+		return nil, fmt.Errorf("spot requests weren't fulfilled: %s",
+			strings.Join(pending, ", "))
+	}
+	return instIDs, nil
+}
+
+// spotStatus describes why a spot request is in its current state, e.g.
+// "price-too-low: Your Spot request price of 0.01 is lower than ...".
+func spotStatus(spot *ec2.SpotInstanceRequest) string {
+	if spot.Status == nil {
+		return resolveString(spot.State)
+	}
+	return fmt.Sprintf("%s: %s", resolveString(spot.Status.Code),
+		resolveString(spot.Status.Message))
+}
+
+// tagInstances applies the machines' tags to the instances, along with a Name
+// tag holding their hostname, which the EC2 console displays as their name.
+// Their volumes are tagged with the namespace.
+func (prvdr *Provider) tagInstances(ids []string, br bootReq) error {
+	if err := prvdr.tagVolumes(ids); err != nil {
+		return err
+	}
+
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(br.tags), &tags); err != nil {
+		return err
+	}
+
+	if br.hostname != "" {
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		tags["Name"] = br.hostname
+	}
+
+	if len(tags) == 0 || len(ids) == 0 {
+		return nil
 	}
-	return err
+
+	if err := prvdr.CreateTags(ids, tags); err != nil {
+		return fmt.Errorf("tag instances %v: %s", ids, err)
+	}
+	return nil
 }
 
-// Stop shuts down `machines` in `prvdr`.
+// tagVolumes tags the volumes of the instances with `ids` with the namespace, so
+// that Resources finds them even if they outlive their instance.
+func (prvdr *Provider) tagVolumes(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	insts, err := prvdr.DescribeInstances([]*ec2.Filter{{
+		Name:   aws.String("instance-id"),
+		Values: aws.StringSlice(ids)}})
+	if err != nil {
+		return fmt.Errorf("list instances: %s", err)
+	}
+
+	var volumeIDs []string
+	for _, res := range insts.Reservations {
+		for _, inst := range res.Instances {
+			for _, bdm := range inst.BlockDeviceMappings {
+				if bdm.Ebs != nil && bdm.Ebs.VolumeId != nil {
+					volumeIDs = append(volumeIDs, *bdm.Ebs.VolumeId)
+				}
+			}
+		}
+	}
+
+	if len(volumeIDs) == 0 {
+		return nil
+	}
+
+	err = prvdr.CreateTags(volumeIDs,
+		map[string]string{namespaceTag: prvdr.namespace})
+	if err != nil {
+		return fmt.Errorf("tag volumes %v: %s", volumeIDs, err)
+	}
+	return nil
+}
+
+// Stop shuts down `machines` in `prvdr`, and then releases the Elastic IPs that
+// were allocated for them.
 func (prvdr *Provider) Stop(machines []db.Machine) error {
 	var spotIDs, instIDs []string
 	for _, m := range machines {
@@ -223,13 +551,18 @@ func (prvdr *Provider) Stop(machines []db.Machine) error {
 	}
 
 	switch {
-	case spotErr == nil:
-		return instErr
-	case instErr == nil:
-		return spotErr
-	default:
+	case spotErr != nil && instErr != nil:
 		return fmt.Errorf("reserved: %v, and spot: %v", instErr, spotErr)
+	case spotErr != nil:
+		return spotErr
+	case instErr != nil:
+		return instErr
 	}
+
+	// The IPs are only released once their instances are gone, so that a
+	// machine that fails to stop keeps its IP.  The IPs of machines that did
+	// stop despite an error are swept up by List.
+	return prvdr.releaseFloatingIPs(machines)
 }
 
 func (prvdr *Provider) stopSpots(ids []string) error {
@@ -271,6 +604,14 @@ func (prvdr *Provider) stopInstances(ids []string) error {
 	return prvdr.wait(ids, false)
 }
 
+// The status codes of spot requests whose instances are about to be interrupted
+// by Amazon.
+var interruptionCodes = map[string]bool{
+	"marked-for-stop":        true,
+	"marked-for-termination": true,
+	"marked-for-hibernation": true,
+}
+
 var trackedSpotStates = aws.StringSlice(
 	[]string{ec2.SpotInstanceStateActive, ec2.SpotInstanceStateOpen})
 
@@ -286,24 +627,66 @@ func (prvdr *Provider) listSpots() (machines []awsMachine, err error) {
 	}
 
 	for _, spot := range spots {
-		machines = append(machines, awsMachine{
-			spotID: resolveString(spot.SpotInstanceRequestId),
-		})
+		awsm := awsMachine{spotID: resolveString(spot.SpotInstanceRequestId)}
+
+		// Report the size of pending requests, so that the cloud matches
+		// them with the machines they were placed for rather than booting
+		// them again.
+		if spec := spot.LaunchSpecification; spec != nil {
+			awsm.machine.Size = resolveString(spec.InstanceType)
+			awsm.machine.Subnet = resolveString(spec.SubnetId)
+			awsm.machine.Image = resolveString(spec.ImageId)
+			if placement := spec.Placement; placement != nil {
+				awsm.machine.Zone = resolveString(
+					placement.AvailabilityZone)
+				awsm.machine.PlacementGroup = resolveString(
+					placement.GroupName)
+			}
+			if profile := spec.IamInstanceProfile; profile != nil {
+				awsm.machine.InstanceProfile = instanceProfileName(
+					resolveString(profile.Name),
+					resolveString(profile.Arn))
+			}
+			if len(spec.BlockDeviceMappings) != 0 &&
+				spec.BlockDeviceMappings[0].Ebs != nil {
+				ebs := spec.BlockDeviceMappings[0].Ebs
+				awsm.machine.DiskSize = int(aws.Int64Value(
+					ebs.VolumeSize))
+				awsm.machine.VolumeType = resolveString(ebs.VolumeType)
+				awsm.machine.IOPS = int(aws.Int64Value(ebs.Iops))
+			}
+		}
+
+		if spot.Status != nil &&
+			interruptionCodes[resolveString(spot.Status.Code)] {
+			awsm.machine.Interrupted = true
+		}
+
+		if resolveString(spot.State) == ec2.SpotInstanceStateOpen {
+			log.WithFields(log.Fields{
+				"id":     awsm.spotID,
+				"status": spotStatus(spot),
+			}).Debug("Spot request is pending")
+		}
+		machines = append(machines, awsm)
 	}
 	return machines, nil
 }
 
-func (prvdr *Provider) parseDiskSize(inst ec2.Instance) (int, error) {
+// parseVolume returns the root volume of `inst`, or nil if it has none.
+func (prvdr *Provider) parseVolume(inst ec2.Instance) (*ec2.Volume, error) {
 	if len(inst.BlockDeviceMappings) == 0 {
-		return 0, nil
+		return nil, nil
 	}
 
 	volumeID := *inst.BlockDeviceMappings[0].Ebs.VolumeId
-	volumes, err := prvdr.DescribeVolumes(volumeID)
+	volumes, err := prvdr.DescribeVolumes([]*ec2.Filter{{
+		Name:   aws.String("volume-id"),
+		Values: []*string{&volumeID}}})
 	if err != nil || len(volumes) == 0 {
-		return 0, err
+		return nil, err
 	}
-	return int(*volumes[0].Size), nil
+	return volumes[0], nil
 }
 
 // `listInstances` fetches and parses all machines in the namespace into a list
@@ -319,10 +702,19 @@ func (prvdr *Provider) listInstances() (instances []awsMachine, err error) {
 		return nil, err
 	}
 
-	addrs, err := prvdr.DescribeAddresses()
+	addrs, err := prvdr.DescribeAddresses(nil)
 	if err != nil {
 		return nil, err
 	}
+
+	// Failing to sweep shouldn't stop the machines from being listed.
+	tagged, err := prvdr.DescribeAddresses(prvdr.namespaceFilter())
+	if err != nil {
+		log.WithError(err).Warn("Failed to list tagged Elastic IPs")
+	} else {
+		prvdr.sweepFloatingIPs(tagged)
+	}
+
 	ipMap := map[string]*ec2.Address{}
 	for _, ip := range addrs {
 		if ip.InstanceId != nil {
@@ -332,16 +724,61 @@ func (prvdr *Provider) listInstances() (instances []awsMachine, err error) {
 
 	for _, res := range insts.Reservations {
 		for _, inst := range res.Instances {
-			diskSize, err := prvdr.parseDiskSize(*inst)
+			volume, err := prvdr.parseVolume(*inst)
 			if err != nil {
 				log.WithError(err).
 					Warn("Error retrieving Amazon machine " +
 						"disk information.")
 			}
 
+			var diskSize, iops int
+			var volumeType string
+			if volume != nil {
+				diskSize = int(aws.Int64Value(volume.Size))
+				volumeType = resolveString(volume.VolumeType)
+				iops = int(aws.Int64Value(volume.Iops))
+			}
+
 			var floatingIP string
+			var autoFloatingIP bool
 			if ip := ipMap[*inst.InstanceId]; ip != nil {
 				floatingIP = *ip.PublicIp
+				autoFloatingIP = ip.AllocationId != nil &&
+					parseTags(inst.Tags)[autoFloatingIPTag] ==
+						*ip.AllocationId
+			}
+
+			var zone, group string
+			if inst.Placement != nil {
+				zone = resolveString(inst.Placement.AvailabilityZone)
+				group = resolveString(inst.Placement.GroupName)
+			}
+
+			var profile string
+			if inst.IamInstanceProfile != nil {
+				profile = instanceProfileName("",
+					resolveString(inst.IamInstanceProfile.Arn))
+			}
+
+			// The namespace's own group is implied, so only the groups
+			// that were attached alongside it are reported.
+			var groups []string
+			for _, group := range inst.SecurityGroups {
+				if resolveString(group.GroupName) != prvdr.namespace {
+					groups = append(groups,
+						resolveString(group.GroupId))
+				}
+			}
+			sort.Strings(groups)
+
+			// Amazon's IPv6 addresses are public.
+			var ipv6 string
+			for _, intf := range inst.NetworkInterfaces {
+				if len(intf.Ipv6Addresses) != 0 {
+					ipv6 = resolveString(
+						intf.Ipv6Addresses[0].Ipv6Address)
+					break
+				}
 			}
 
 			instances = append(instances, awsMachine{
@@ -349,11 +786,23 @@ func (prvdr *Provider) listInstances() (instances []awsMachine, err error) {
 				spotID: resolveString(
 					inst.SpotInstanceRequestId),
 				machine: db.Machine{
-					PublicIP:   resolveString(inst.PublicIpAddress),
-					PrivateIP:  resolveString(inst.PrivateIpAddress),
-					FloatingIP: floatingIP,
-					Size:       resolveString(inst.InstanceType),
-					DiskSize:   diskSize,
+					PublicIP:        resolveString(inst.PublicIpAddress),
+					PrivateIP:       resolveString(inst.PrivateIpAddress),
+					PublicIPv6:      ipv6,
+					IPv6:            ipv6 != "",
+					FloatingIP:      floatingIP,
+					AutoFloatingIP:  autoFloatingIP,
+					Size:            resolveString(inst.InstanceType),
+					DiskSize:        diskSize,
+					VolumeType:      volumeType,
+					IOPS:            iops,
+					VPC:             resolveString(inst.VpcId),
+					Subnet:          resolveString(inst.SubnetId),
+					InstanceProfile: profile,
+					SecurityGroups:  groups,
+					Image:           resolveString(inst.ImageId),
+					Zone:            zone,
+					PlacementGroup:  group,
 				},
 			})
 		}
@@ -384,7 +833,9 @@ func (prvdr *Provider) List() (machines []db.Machine, err error) {
 		awsMachines = append(awsMachines, mIntf.(awsMachine))
 	}
 	for _, pair := range bootedSpots {
-		awsMachines = append(awsMachines, pair.R.(awsMachine))
+		awsm := pair.R.(awsMachine)
+		awsm.machine.Interrupted = pair.L.(awsMachine).machine.Interrupted
+		awsMachines = append(awsMachines, awsm)
 	}
 	for _, mIntf := range nonbootedSpots {
 		awsMachines = append(awsMachines, mIntf.(awsMachine))
@@ -397,14 +848,252 @@ func (prvdr *Provider) List() (machines []db.Machine, err error) {
 		if !cm.Preemptible {
 			cm.CloudID = awsm.instanceID
 		}
+
+		// Amazon's instance types come with a fixed set of GPUs.
+		cm.GPUs, cm.GPUType = machine.GPUCapacity(db.Amazon, cm.Size, 0, "")
 		machines = append(machines, cm)
 	}
 	return machines, nil
 }
 
-// UpdateFloatingIPs updates Elastic IPs <> EC2 instance associations.
+// Reboot reboots the instances of `machines` in place.
+func (prvdr *Provider) Reboot(machines []db.Machine) error {
+	var spotIDs, instIDs []string
+	for _, m := range machines {
+		if m.Preemptible {
+			spotIDs = append(spotIDs, m.CloudID)
+		} else {
+			instIDs = append(instIDs, m.CloudID)
+		}
+	}
+
+	// The CloudIDs of preemptible machines are their spot requests, which
+	// have to be resolved to their instances.
+	if len(spotIDs) != 0 {
+		ids, err := prvdr.spotInstances(spotIDs)
+		if err != nil {
+			return err
+		}
+		instIDs = append(instIDs, ids...)
+	}
+
+	if len(instIDs) == 0 {
+		return nil
+	}
+	return prvdr.RebootInstances(instIDs)
+}
+
+// spotInstances returns the IDs of the instances that fulfilled the spot
+// requests with the given IDs.  Requests that haven't been fulfilled are
+// skipped.
+func (prvdr *Provider) spotInstances(spotIDs []string) ([]string, error) {
+	spots, err := prvdr.DescribeSpotInstanceRequests(spotIDs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, spot := range spots {
+		if spot.InstanceId != nil {
+			ids = append(ids, *spot.InstanceId)
+		}
+	}
+	return ids, nil
+}
+
+// Resources lists the instances, spot requests, volumes, Elastic IPs, key pairs,
+// and security group of the namespace.  Instances are listed in every state, so
+// that stopped instances are included in audits.  Volumes are also listed once
+// they're detached from the namespace's instances, if Quilt tagged them with the
+// namespace.  Detached Elastic IPs that Quilt allocated are released, and only
+// listed if that fails.
+func (prvdr *Provider) Resources() ([]resource.Resource, error) {
+	var resources []resource.Resource
+
+	groups, err := prvdr.DescribeSecurityGroup(prvdr.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("list security groups: %s", err)
+	}
+	for _, group := range groups {
+		resources = append(resources, resource.Resource{
+			Type: resource.SecurityGroup,
+			ID:   resolveString(group.GroupId),
+			Tags: parseTags(group.Tags),
+		})
+	}
+
+	spots, err := prvdr.DescribeSpotInstanceRequests(nil, []*ec2.Filter{{
+		Name:   aws.String("launch.group-name"),
+		Values: []*string{aws.String(prvdr.namespace)}}})
+	if err != nil {
+		return nil, fmt.Errorf("list spot requests: %s", err)
+	}
+	for _, spot := range spots {
+		resources = append(resources, resource.Resource{
+			Type:    resource.SpotRequest,
+			ID:      resolveString(spot.SpotInstanceRequestId),
+			Tags:    parseTags(spot.Tags),
+			Created: aws.TimeValue(spot.CreateTime),
+		})
+	}
+
+	insts, err := prvdr.DescribeInstances([]*ec2.Filter{{
+		Name:   aws.String("instance.group-name"),
+		Values: []*string{aws.String(prvdr.namespace)}}})
+	if err != nil {
+		return nil, fmt.Errorf("list instances: %s", err)
+	}
+
+	instIDs := map[string]struct{}{}
+	keyPairs := map[string]struct{}{}
+	var volumeIDs []*string
+	for _, res := range insts.Reservations {
+		for _, inst := range res.Instances {
+			id := resolveString(inst.InstanceId)
+			instIDs[id] = struct{}{}
+			resources = append(resources, resource.Resource{
+				Type:    resource.Instance,
+				ID:      id,
+				Tags:    parseTags(inst.Tags),
+				Created: aws.TimeValue(inst.LaunchTime),
+			})
+
+			if inst.KeyName != nil {
+				keyPairs[*inst.KeyName] = struct{}{}
+			}
+
+			for _, bdm := range inst.BlockDeviceMappings {
+				if bdm.Ebs != nil && bdm.Ebs.VolumeId != nil {
+					volumeIDs = append(volumeIDs, bdm.Ebs.VolumeId)
+				}
+			}
+		}
+	}
+
+	// The volumes of the instances, and the volumes tagged with the namespace,
+	// which include those that outlived their instance.
+	volumeFilters := [][]*ec2.Filter{prvdr.namespaceFilter()}
+	if len(volumeIDs) > 0 {
+		volumeFilters = append(volumeFilters, []*ec2.Filter{{
+			Name: aws.String("volume-id"), Values: volumeIDs}})
+	}
+
+	volumeSet := map[string]struct{}{}
+	for _, filters := range volumeFilters {
+		volumes, err := prvdr.DescribeVolumes(filters)
+		if err != nil {
+			return nil, fmt.Errorf("list volumes: %s", err)
+		}
+
+		for _, vol := range volumes {
+			id := resolveString(vol.VolumeId)
+			if _, ok := volumeSet[id]; ok {
+				continue
+			}
+			volumeSet[id] = struct{}{}
+
+			resources = append(resources, resource.Resource{
+				Type:    resource.Volume,
+				ID:      id,
+				Tags:    parseTags(vol.Tags),
+				Created: aws.TimeValue(vol.CreateTime),
+			})
+		}
+	}
+
+	addrs, err := prvdr.DescribeAddresses(nil)
+	if err != nil {
+		return nil, fmt.Errorf("list addresses: %s", err)
+	}
+
+	tagged, err := prvdr.DescribeAddresses(prvdr.namespaceFilter())
+	if err != nil {
+		return nil, fmt.Errorf("list tagged addresses: %s", err)
+	}
+	released := prvdr.sweepFloatingIPs(tagged)
+
+	taggedIDs := map[string]struct{}{}
+	for _, addr := range tagged {
+		taggedIDs[resolveString(addr.AllocationId)] = struct{}{}
+	}
+
+	for _, addr := range addrs {
+		id := resolveString(addr.AllocationId)
+		if _, ok := released[id]; ok {
+			continue
+		}
+
+		_, ours := instIDs[resolveString(addr.InstanceId)]
+		_, isTagged := taggedIDs[id]
+		if ours || (isTagged && addr.AssociationId == nil) {
+			resources = append(resources, resource.Resource{
+				Type: resource.IP,
+				ID:   id,
+				Name: resolveString(addr.PublicIp),
+			})
+		}
+	}
+
+	// Quilt doesn't create key pairs, but reports those that the namespace's
+	// instances were launched with.
+	var keyNames []string
+	for name := range keyPairs {
+		keyNames = append(keyNames, name)
+	}
+	sort.Strings(keyNames)
+	for _, name := range keyNames {
+		resources = append(resources, resource.Resource{
+			Type: resource.KeyPair,
+			ID:   name,
+		})
+	}
+	return resources, nil
+}
+
+// namespaceFilter returns an EC2 filter that matches the resources that are
+// tagged with the namespace.
+func (prvdr *Provider) namespaceFilter() []*ec2.Filter {
+	return []*ec2.Filter{{
+		Name:   aws.String("tag:" + namespaceTag),
+		Values: []*string{aws.String(prvdr.namespace)}}}
+}
+
+func parseTags(tags []*ec2.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	parsed := map[string]string{}
+	for _, tag := range tags {
+		parsed[resolveString(tag.Key)] = resolveString(tag.Value)
+	}
+	return parsed
+}
+
+// Capabilities returns the optional features that Amazon supports.
+func (prvdr *Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true, Preemptible: true,
+		ResizableDisks: true, IPv6: true,
+	}
+}
+
+// The tag of the volumes and Elastic IPs that Quilt created for a namespace,
+// whose value is the namespace.  Unlike instances, they aren't in the
+// namespace's security group, so the tag identifies them once they're detached.
+// Detached Elastic IPs with the tag are released by List and Resources.
+const namespaceTag = "quilt-namespace"
+
+// The tag of instances whose Elastic IP was allocated by Quilt, whose value is
+// the IP's allocation ID.  The IP is released once it's no longer associated with
+// the instance.
+const autoFloatingIPTag = "quilt-auto-floating-ip"
+
+// UpdateFloatingIPs updates Elastic IPs <> EC2 instance associations.  Machines
+// whose floating IP is blueprint.AutoFloatingIP are associated with a newly
+// allocated Elastic IP.
 func (prvdr *Provider) UpdateFloatingIPs(machines []db.Machine) error {
-	addrs, err := prvdr.DescribeAddresses()
+	addrs, err := prvdr.DescribeAddresses(nil)
 	if err != nil {
 		return err
 	}
@@ -413,6 +1102,8 @@ func (prvdr *Provider) UpdateFloatingIPs(machines []db.Machine) error {
 	addresses := map[string]string{}
 	// Map EC2 Instance -> Elastic IP association.
 	associations := map[string]string{}
+	// Map EC2 Instance -> Elastic IP.
+	allocations := map[string]string{}
 	for _, addr := range addrs {
 		if addr.AllocationId != nil {
 			addresses[*addr.PublicIp] = *addr.AllocationId
@@ -420,6 +1111,8 @@ func (prvdr *Provider) UpdateFloatingIPs(machines []db.Machine) error {
 
 		if addr.InstanceId != nil && addr.AssociationId != nil {
 			associations[*addr.InstanceId] = *addr.AssociationId
+			allocations[*addr.InstanceId] = aws.StringValue(
+				addr.AllocationId)
 		}
 	}
 
@@ -432,7 +1125,15 @@ func (prvdr *Provider) UpdateFloatingIPs(machines []db.Machine) error {
 			}
 		}
 
-		if machine.FloatingIP == "" {
+		// The machine's current IP is released once it's replaced, if
+		// Quilt allocated it.
+		var release string
+		if machine.AutoFloatingIP {
+			release = allocations[id]
+		}
+
+		switch machine.FloatingIP {
+		case "":
 			associationID, ok := associations[id]
 			if !ok {
 				continue
@@ -442,18 +1143,130 @@ func (prvdr *Provider) UpdateFloatingIPs(machines []db.Machine) error {
 			if err != nil {
 				return err
 			}
-		} else {
+		case blueprint.AutoFloatingIP:
+			if err := prvdr.associateNewAddress(id); err != nil {
+				return err
+			}
+		default:
 			allocationID := addresses[machine.FloatingIP]
 			err := prvdr.AssociateAddress(id, allocationID)
 			if err != nil {
 				return err
 			}
 		}
+
+		if release != "" {
+			if err := prvdr.ReleaseAddress(release); err != nil {
+				return fmt.Errorf("release %s: %s", release, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// associateNewAddress allocates an Elastic IP, and associates it with the instance
+// `id`.  The instance is tagged with the IP's allocation ID, so that the IP is
+// released along with it.
+func (prvdr *Provider) associateNewAddress(id string) error {
+	allocationID, ip, err := prvdr.AllocateAddress()
+	if err != nil {
+		return fmt.Errorf("allocate address: %s", err)
+	}
+
+	err = prvdr.CreateTags([]string{id},
+		map[string]string{autoFloatingIPTag: allocationID})
+	if err == nil {
+		err = prvdr.AssociateAddress(id, allocationID)
+	}
+
+	if err != nil {
+		// The IP would otherwise be leaked, as it's only released along with
+		// the instance that it's associated with.
+		if releaseErr := prvdr.ReleaseAddress(allocationID); releaseErr != nil {
+			log.WithError(releaseErr).WithField("ip", ip).Warn(
+				"Failed to release Elastic IP")
+		}
+		return err
+	}
+
+	// The IP is tagged with the namespace once it's associated, so that it's
+	// still found if it outlives the instance.
+	err = prvdr.CreateTags([]string{allocationID},
+		map[string]string{namespaceTag: prvdr.namespace})
+	if err != nil {
+		log.WithError(err).WithField("ip", ip).Warn("Failed to tag Elastic IP")
+	}
+
+	log.WithFields(log.Fields{"instance": id, "ip": ip}).Info(
+		"Allocated Elastic IP")
+	return nil
+}
+
+// releaseFloatingIPs releases the Elastic IPs that Quilt allocated for
+// `machines`.
+func (prvdr *Provider) releaseFloatingIPs(machines []db.Machine) error {
+	ips := map[string]bool{}
+	for _, m := range machines {
+		if m.AutoFloatingIP {
+			ips[m.FloatingIP] = true
+		}
 	}
 
+	if len(ips) == 0 {
+		return nil
+	}
+
+	addrs, err := prvdr.DescribeAddresses(nil)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if addr.AllocationId == nil || !ips[aws.StringValue(addr.PublicIp)] {
+			continue
+		}
+
+		if addr.AssociationId != nil {
+			err := prvdr.DisassociateAddress(*addr.AssociationId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := prvdr.ReleaseAddress(*addr.AllocationId); err != nil {
+			return fmt.Errorf("release %s: %s", *addr.AllocationId, err)
+		}
+	}
 	return nil
 }
 
+// sweepFloatingIPs releases the Elastic IPs among `tagged`, which Quilt allocated
+// for the namespace, that are no longer associated with an instance.  Their
+// instances were terminated or reclaimed without Quilt stopping them, so they
+// would otherwise be leaked.  It returns the allocation IDs of the IPs that it
+// released.
+func (prvdr *Provider) sweepFloatingIPs(tagged []*ec2.Address) map[string]struct{} {
+	released := map[string]struct{}{}
+	for _, addr := range tagged {
+		if addr.AllocationId == nil || addr.AssociationId != nil ||
+			addr.InstanceId != nil {
+			continue
+		}
+
+		ip := resolveString(addr.PublicIp)
+		if err := prvdr.ReleaseAddress(*addr.AllocationId); err != nil {
+			log.WithError(err).WithField("ip", ip).Warn(
+				"Failed to release detached Elastic IP")
+			continue
+		}
+
+		log.WithField("ip", ip).Info("Released detached Elastic IP")
+		released[*addr.AllocationId] = struct{}{}
+	}
+	return released
+}
+
 func (prvdr Provider) getInstanceID(spotID string) (string, error) {
 	spots, err := prvdr.DescribeSpotInstanceRequests([]string{spotID}, nil)
 	if err != nil {
@@ -501,28 +1314,53 @@ func (prvdr *Provider) wait(ids []string, boot bool) error {
 	})
 }
 
-// SetACLs adds and removes acls in `prvdr` so that it conforms to `acls`.
+// SetACLs adds and removes acls in `prvdr` so that it conforms to `acls`.  The
+// namespace has a security group in each VPC that its machines run in, and
+// each is synced.  The existing groups that blueprints launch machines into are
+// left alone.
 func (prvdr *Provider) SetACLs(acls []acl.ACL) error {
-	groupID, ingress, err := prvdr.getCreateSecurityGroup()
+	groups, err := prvdr.DescribeSecurityGroup(prvdr.namespace)
 	if err != nil {
 		return err
 	}
 
+	if len(groups) == 0 {
+		groupID, err := prvdr.getCreateSecurityGroup("")
+		if err != nil {
+			return err
+		}
+		groups = []*ec2.SecurityGroup{{GroupId: aws.String(groupID)}}
+	}
+
+	for _, group := range groups {
+		err := prvdr.setGroupACLs(resolveString(group.GroupId),
+			group.IpPermissions, acls)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (prvdr *Provider) setGroupACLs(groupID string, ingress []*ec2.IpPermission,
+	acls []acl.ACL) error {
+
 	rangesToAdd, foundGroup, rulesToRemove := syncACLs(acls, groupID, ingress)
 
 	if len(rangesToAdd) != 0 {
 		logACLs(true, rangesToAdd)
-		err = prvdr.AuthorizeSecurityGroup(
-			prvdr.namespace, "", rangesToAdd)
+		err := prvdr.AuthorizeSecurityGroup(groupID, rangesToAdd)
 		if err != nil {
 			return err
 		}
 	}
 
 	if !foundGroup {
-		log.WithField("Group", prvdr.namespace).Debug("Amazon: Add group")
-		err = prvdr.AuthorizeSecurityGroup(
-			prvdr.namespace, prvdr.namespace, nil)
+		log.WithField("Group", groupID).Debug("Amazon: Add group")
+		err := prvdr.AuthorizeSecurityGroup(groupID, []*ec2.IpPermission{{
+			IpProtocol: aws.String("-1"),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{{
+				GroupId: aws.String(groupID)}}}})
 		if err != nil {
 			return err
 		}
@@ -530,7 +1368,7 @@ func (prvdr *Provider) SetACLs(acls []acl.ACL) error {
 
 	if len(rulesToRemove) != 0 {
 		logACLs(false, rulesToRemove)
-		err = prvdr.RevokeSecurityGroup(prvdr.namespace, rulesToRemove)
+		err := prvdr.RevokeSecurityGroup(groupID, rulesToRemove)
 		if err != nil {
 			return err
 		}
@@ -539,27 +1377,59 @@ func (prvdr *Provider) SetACLs(acls []acl.ACL) error {
 	return nil
 }
 
-func (prvdr *Provider) getCreateSecurityGroup() (
-	string, []*ec2.IpPermission, error) {
-
+// getCreateSecurityGroup returns the ID of the namespace's security group in the
+// VPC with ID `vpc`, or in the default VPC if it's empty.  The group is created
+// if it doesn't exist yet.
+func (prvdr *Provider) getCreateSecurityGroup(vpc string) (string, error) {
 	groups, err := prvdr.DescribeSecurityGroup(prvdr.namespace)
 	if err != nil {
-		return "", nil, err
-	} else if len(groups) > 1 {
-		err := errors.New("Multiple Security Groups with the same name: " +
+		return "", err
+	}
+
+	groupVPC := vpc
+	if groupVPC == "" {
+		groupVPC, err = prvdr.DescribeDefaultVPC()
+		if err != nil {
+			return "", fmt.Errorf("find default VPC: %s", err)
+		}
+	}
+
+	var ids []string
+	for _, group := range groups {
+		if resolveString(group.VpcId) == groupVPC {
+			ids = append(ids, resolveString(group.GroupId))
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return prvdr.CreateSecurityGroup(prvdr.namespace, "Quilt Group", vpc)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", errors.New("Multiple Security Groups with the same name: " +
 			prvdr.namespace)
-		return "", nil, err
-	} else if len(groups) == 1 {
-		return *groups[0].GroupId, groups[0].IpPermissions, nil
 	}
+}
 
-	id, err := prvdr.CreateSecurityGroup(prvdr.namespace, "Quilt Group")
-	return id, nil, err
+// getCreatePlacementGroup creates the placement group `name` if it doesn't
+// already exist.
+func (prvdr *Provider) getCreatePlacementGroup(name string) error {
+	groups, err := prvdr.DescribePlacementGroup(name)
+	if err != nil {
+		return err
+	}
+
+	if len(groups) != 0 {
+		return nil
+	}
+	return prvdr.CreatePlacementGroup(name)
 }
 
 // syncACLs returns the permissions that need to be removed and added in order
 // for the cloud ACLs to match the policy.
-// rangesToAdd is guaranteed to always have exactly one item in the IpRanges slice.
+// rangesToAdd is guaranteed to always have exactly one item in either the IpRanges
+// or the Ipv6Ranges slice.
 func syncACLs(desiredACLs []acl.ACL, desiredGroupID string,
 	current []*ec2.IpPermission) (rangesToAdd []*ec2.IpPermission, foundGroup bool,
 	toRemove []*ec2.IpPermission) {
@@ -576,6 +1446,16 @@ func syncACLs(desiredACLs []acl.ACL, desiredGroupID string,
 				},
 			})
 		}
+		for _, ipRange := range perm.Ipv6Ranges {
+			currRangeRules = append(currRangeRules, &ec2.IpPermission{
+				IpProtocol: perm.IpProtocol,
+				FromPort:   perm.FromPort,
+				ToPort:     perm.ToPort,
+				Ipv6Ranges: []*ec2.Ipv6Range{
+					ipRange,
+				},
+			})
+		}
 		for _, pair := range perm.UserIdGroupPairs {
 			if *pair.GroupId != desiredGroupID {
 				toRemove = append(toRemove, &ec2.IpPermission{
@@ -591,34 +1471,16 @@ func syncACLs(desiredACLs []acl.ACL, desiredGroupID string,
 
 	var desiredRangeRules []*ec2.IpPermission
 	for _, acl := range desiredACLs {
-		desiredRangeRules = append(desiredRangeRules, &ec2.IpPermission{
-			FromPort: aws.Int64(int64(acl.MinPort)),
-			ToPort:   aws.Int64(int64(acl.MaxPort)),
-			IpRanges: []*ec2.IpRange{
-				{
-					CidrIp: aws.String(acl.CidrIP),
-				},
-			},
-			IpProtocol: aws.String("tcp"),
-		}, &ec2.IpPermission{
-			FromPort: aws.Int64(int64(acl.MinPort)),
-			ToPort:   aws.Int64(int64(acl.MaxPort)),
-			IpRanges: []*ec2.IpRange{
-				{
-					CidrIp: aws.String(acl.CidrIP),
-				},
-			},
-			IpProtocol: aws.String("udp"),
-		}, &ec2.IpPermission{
-			FromPort: aws.Int64(-1),
-			ToPort:   aws.Int64(-1),
-			IpRanges: []*ec2.IpRange{
-				{
-					CidrIp: aws.String(acl.CidrIP),
-				},
-			},
-			IpProtocol: aws.String("icmp"),
-		})
+		// Amazon identifies ICMPv6 by its protocol number.
+		icmp := "icmp"
+		if acl.IPv6() {
+			icmp = "58"
+		}
+
+		desiredRangeRules = append(desiredRangeRules,
+			ipPermission("tcp", acl.MinPort, acl.MaxPort, acl),
+			ipPermission("udp", acl.MinPort, acl.MaxPort, acl),
+			ipPermission(icmp, -1, -1, acl))
 	}
 
 	_, toAdd, rangesToRemove := join.HashJoin(ipPermSlice(desiredRangeRules),
@@ -633,6 +1495,25 @@ func syncACLs(desiredACLs []acl.ACL, desiredGroupID string,
 	return rangesToAdd, foundGroup, toRemove
 }
 
+// ipPermission returns the permission that allows `protocol` traffic to the
+// ports between `minPort` and `maxPort` from the CIDR block of `acl`.
+func ipPermission(protocol string, minPort, maxPort int,
+	acl acl.ACL) *ec2.IpPermission {
+
+	perm := &ec2.IpPermission{
+		FromPort:   aws.Int64(int64(minPort)),
+		ToPort:     aws.Int64(int64(maxPort)),
+		IpProtocol: aws.String(protocol),
+	}
+
+	if acl.IPv6() {
+		perm.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: aws.String(acl.CidrIP)}}
+	} else {
+		perm.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(acl.CidrIP)}}
+	}
+	return perm
+}
+
 func logACLs(add bool, perms []*ec2.IpPermission) {
 	action := "Remove"
 	if add {
@@ -640,7 +1521,7 @@ func logACLs(add bool, perms []*ec2.IpPermission) {
 	}
 
 	for _, perm := range perms {
-		if len(perm.IpRanges) != 0 {
+		if cidrIP, ok := permCIDR(perm); ok {
 			// Each rule has three variants (TCP, UDP, and ICMP), but
 			// we only want to log once.
 			protocol := *perm.IpProtocol
@@ -648,7 +1529,6 @@ func logACLs(add bool, perms []*ec2.IpPermission) {
 				continue
 			}
 
-			cidrIP := *perm.IpRanges[0].CidrIp
 			ports := fmt.Sprintf("%d", *perm.FromPort)
 			if *perm.FromPort != *perm.ToPort {
 				ports += fmt.Sprintf("-%d", *perm.ToPort)
@@ -664,16 +1544,36 @@ func logACLs(add bool, perms []*ec2.IpPermission) {
 	}
 }
 
-// blockDevice returns the block device we use for our AWS machines.
-func blockDevice(diskSize int) *ec2.BlockDeviceMapping {
+// blockDevice returns the block device we use for our AWS machines.  Volumes are
+// gp2 unless another type is chosen, and only provisioned IOPS are requested.
+func blockDevice(diskSize int, volumeType string, iops int) *ec2.BlockDeviceMapping {
+	if volumeType == "" {
+		volumeType = ec2.VolumeTypeGp2
+	}
+
+	ebs := &ec2.EbsBlockDevice{
+		DeleteOnTermination: aws.Bool(true),
+		VolumeSize:          aws.Int64(int64(diskSize)),
+		VolumeType:          aws.String(volumeType),
+	}
+	if iops != 0 {
+		ebs.Iops = aws.Int64(int64(iops))
+	}
 	return &ec2.BlockDeviceMapping{
 		DeviceName: aws.String("/dev/sda1"),
-		Ebs: &ec2.EbsBlockDevice{
-			DeleteOnTermination: aws.Bool(true),
-			VolumeSize:          aws.Int64(int64(diskSize)),
-			VolumeType:          aws.String("gp2"),
-		},
+		Ebs:        ebs,
+	}
+}
+
+// instanceProfileName returns the name of an IAM instance profile.  Amazon
+// describes instances with only the ARN of their profile, which ends in the
+// profile's path and name, e.g.
+// "arn:aws:iam::123456789012:instance-profile/path/name".
+func instanceProfileName(name, arn string) string {
+	if name != "" || arn == "" {
+		return name
 	}
+	return arn[strings.LastIndex(arn, "/")+1:]
 }
 
 func resolveString(ptr *string) string {
@@ -683,6 +1583,15 @@ func resolveString(ptr *string) string {
 	return *ptr
 }
 
+// optionalString returns a pointer to `str`, or nil if it's empty, for parameters
+// that Amazon distinguishes from being omitted.
+func optionalString(str string) *string {
+	if str == "" {
+		return nil
+	}
+	return &str
+}
+
 type awsMachineSlice []awsMachine
 
 func (ams awsMachineSlice) Get(ii int) interface{} {
@@ -717,13 +1626,23 @@ func permToACLKey(permIntf interface{}) interface{} {
 		key.protocol = *perm.IpProtocol
 	}
 
-	if perm.IpRanges[0].CidrIp != nil {
-		key.ipRange = *perm.IpRanges[0].CidrIp
-	}
-
+	key.ipRange, _ = permCIDR(perm)
 	return key
 }
 
+// permCIDR returns the IPv4 or IPv6 CIDR block of the first range of `perm`, and
+// false if it has no ranges.
+func permCIDR(perm *ec2.IpPermission) (string, bool) {
+	switch {
+	case len(perm.IpRanges) != 0:
+		return resolveString(perm.IpRanges[0].CidrIp), true
+	case len(perm.Ipv6Ranges) != 0:
+		return resolveString(perm.Ipv6Ranges[0].CidrIpv6), true
+	default:
+		return "", false
+	}
+}
+
 type ipPermSlice []*ec2.IpPermission
 
 func (slc ipPermSlice) Get(ii int) interface{} {
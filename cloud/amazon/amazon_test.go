@@ -12,9 +12,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/cloud/amazon/client/mocks"
 	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/util"
 )
@@ -92,15 +94,25 @@ func TestList(t *testing.T) {
 				SpotInstanceRequestId: aws.String("spot3"),
 				State: aws.String(ec2.SpotInstanceStateOpen)}}, nil)
 
-	mc.On("DescribeAddresses").Return([]*ec2.Address{{
+	mc.On("DescribeAddresses", []*ec2.Filter(nil)).Return([]*ec2.Address{{
 		InstanceId: aws.String("inst2"),
 		PublicIp:   aws.String("xx.xxx.xxx.xxx"),
 	}, {
 		InstanceId: aws.String("inst3"),
 		PublicIp:   aws.String("8.8.8.8")}}, nil)
 
+	// Detached Elastic IPs that Quilt allocated for the namespace are released.
 	amazonProvider := newAmazon(testNamespace, DefaultRegion)
 	amazonProvider.Client = mc
+	mc.On("DescribeAddresses", amazonProvider.namespaceFilter()).Return(
+		[]*ec2.Address{{
+			AllocationId:  aws.String("attached"),
+			InstanceId:    aws.String("inst3"),
+			AssociationId: aws.String("assoc"),
+		}, {
+			AllocationId: aws.String("stale"),
+		}}, nil)
+	mc.On("ReleaseAddress", "stale").Return(nil).Once()
 
 	machines, err := amazonProvider.List()
 
@@ -131,6 +143,8 @@ func TestList(t *testing.T) {
 			Preemptible: true,
 		},
 	}, machines)
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "ReleaseAddress", "attached")
 }
 
 func TestNewACLs(t *testing.T) {
@@ -166,11 +180,10 @@ func TestNewACLs(t *testing.T) {
 					IpProtocol: aws.String("udp"),
 				},
 			},
-			GroupId: aws.String("")}}, nil)
+			GroupId: aws.String("groupId")}}, nil)
 
 	mc.On("RevokeSecurityGroup", mock.Anything, mock.Anything).Return(nil)
-	mc.On("AuthorizeSecurityGroup", mock.Anything, mock.Anything,
-		mock.Anything).Return(nil)
+	mc.On("AuthorizeSecurityGroup", mock.Anything, mock.Anything).Return(nil)
 	mc.On("DescribeInstances", mock.Anything).Return(
 		&ec2.DescribeInstancesOutput{}, nil,
 	)
@@ -193,12 +206,14 @@ func TestNewACLs(t *testing.T) {
 
 	assert.Nil(t, err)
 
-	mc.AssertCalled(t, "RevokeSecurityGroup", testNamespace, []*ec2.IpPermission{{
+	mc.AssertCalled(t, "RevokeSecurityGroup", "groupId", []*ec2.IpPermission{{
 		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("deleteMe")}},
 		IpProtocol: aws.String("-1")}})
 
-	mc.AssertCalled(t, "AuthorizeSecurityGroup", testNamespace, testNamespace,
-		mock.Anything)
+	mc.AssertCalled(t, "AuthorizeSecurityGroup", "groupId", []*ec2.IpPermission{{
+		IpProtocol: aws.String("-1"),
+		UserIdGroupPairs: []*ec2.UserIdGroupPair{{
+			GroupId: aws.String("groupId")}}}})
 
 	// Manually extract and compare the ingress rules for allowing traffic based
 	// on IP ranges so that we can sort them because HashJoin returns results
@@ -207,8 +222,8 @@ func TestNewACLs(t *testing.T) {
 	var foundCall bool
 	for _, call := range mc.Calls {
 		if call.Method == "AuthorizeSecurityGroup" {
-			arg := call.Arguments.Get(2).([]*ec2.IpPermission)
-			if len(arg) != 0 {
+			arg := call.Arguments.Get(1).([]*ec2.IpPermission)
+			if len(arg) != 0 && len(arg[0].IpRanges) != 0 {
 				perms = arg
 				foundCall = true
 			}
@@ -305,6 +320,7 @@ func TestBoot(t *testing.T) {
 	mc := new(mocks.Client)
 	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
 		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
 
 	mc.On("RequestSpotInstances", mock.Anything, mock.Anything,
 		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
@@ -333,7 +349,7 @@ func TestBoot(t *testing.T) {
 			},
 		}, nil,
 	)
-	mc.On("DescribeAddresses").Return(nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
 
 	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
 		[]*ec2.SpotInstanceRequest{{
@@ -377,7 +393,7 @@ func TestBoot(t *testing.T) {
 	assert.Nil(t, err)
 
 	cfg := cfg.Ubuntu(db.Machine{Role: db.Master}, "")
-	mc.AssertCalled(t, "RequestSpotInstances", spotPrice, int64(2),
+	mc.AssertCalled(t, "RequestSpotInstances", defaultSpotPrice, int64(2),
 		&ec2.RequestSpotLaunchSpecification{
 			ImageId:      aws.String(amis[DefaultRegion]),
 			InstanceType: aws.String("m4.large"),
@@ -385,7 +401,7 @@ func TestBoot(t *testing.T) {
 				[]byte(cfg))),
 			SecurityGroupIds: aws.StringSlice([]string{"groupId"}),
 			BlockDeviceMappings: []*ec2.BlockDeviceMapping{
-				blockDevice(32)}})
+				blockDevice(32, "", 0)}})
 	mc.AssertCalled(t, "RunInstances", &ec2.RunInstancesInput{
 		ImageId:      aws.String(amis[DefaultRegion]),
 		InstanceType: aws.String("m4.large"),
@@ -393,13 +409,535 @@ func TestBoot(t *testing.T) {
 			[]byte(cfg))),
 		SecurityGroupIds: aws.StringSlice([]string{"groupId"}),
 		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
-			blockDevice(32)},
+			blockDevice(32, "", 0)},
 		MaxCount: aws.Int64(2),
 		MinCount: aws.Int64(2),
 	})
 	mc.AssertExpectations(t)
 }
 
+func TestBootHostname(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("reserved1")}},
+	}, nil)
+	mc.On("RequestSpotInstances", mock.Anything, mock.Anything,
+		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
+		SpotInstanceRequestId: aws.String("spot1")}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		[]*ec2.SpotInstanceRequest{{
+			InstanceId:            aws.String("inst1"),
+			SpotInstanceRequestId: aws.String("spot1"),
+			State: aws.String(ec2.SpotInstanceStateActive)}}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("reserved1"),
+				InstanceType: aws.String("m4.large"),
+				State:        running,
+			}, {
+				InstanceId:            aws.String("inst1"),
+				SpotInstanceRequestId: aws.String("spot1"),
+				InstanceType:          aws.String("m4.large"),
+				State:                 running,
+			}}}}}, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+	mc.On("CreateTags", []string{"reserved1"}, map[string]string{
+		"Name": "worker-1", "team": "infra"}).Return(nil)
+	mc.On("CreateTags", []string{"inst1"}, map[string]string{
+		"Name": "worker-2"}).Return(nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	err := amazonProvider.Boot([]db.Machine{
+		{Role: db.Worker, Size: "m4.large", Hostname: "worker-1",
+			Tags: map[string]string{"team": "infra"}},
+		{Role: db.Worker, Size: "m4.large", Hostname: "worker-2",
+			Preemptible: true},
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+}
+
+func TestBootVPC(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}, {
+		GroupId: aws.String("sg-1"), VpcId: aws.String("vpc-1"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("CreateSecurityGroup", testNamespace, "Quilt Group", "vpc-2").Return(
+		"sg-2", nil)
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("m4.large"),
+				VpcId:        aws.String("vpc-1"),
+				SubnetId:     aws.String("subnet-1"),
+				State:        running,
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	groupID, err := amazonProvider.getCreateSecurityGroup("")
+	assert.NoError(t, err)
+	assert.Equal(t, "default-sg", groupID)
+
+	groupID, err = amazonProvider.getCreateSecurityGroup("vpc-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "sg-2", groupID)
+
+	err = amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		VPC: "vpc-1", Subnet: "subnet-1"}})
+	assert.NoError(t, err)
+	mc.AssertCalled(t, "RunInstances", mock.MatchedBy(
+		func(in *ec2.RunInstancesInput) bool {
+			return aws.StringValue(in.SubnetId) == "subnet-1" &&
+				aws.StringValueSlice(in.SecurityGroupIds)[0] == "sg-1"
+		}))
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, "vpc-1", machines[0].VPC)
+	assert.Equal(t, "subnet-1", machines[0].Subnet)
+}
+
+func TestBootIPv6(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("sg-1"), VpcId: aws.String("vpc-1"),
+	}}, nil)
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("m4.large"),
+				State:        running,
+				NetworkInterfaces: []*ec2.InstanceNetworkInterface{{
+					Ipv6Addresses: []*ec2.InstanceIpv6Address{{
+						Ipv6Address: aws.String(
+							"2001:db8::1")}}}},
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	// The security group and subnet are set on the network interface that
+	// requests the IPv6 address.
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		VPC: "vpc-1", Subnet: "subnet-1", IPv6: true}})
+	assert.NoError(t, err)
+	mc.AssertCalled(t, "RunInstances", mock.MatchedBy(
+		func(in *ec2.RunInstancesInput) bool {
+			intfs := in.NetworkInterfaces
+			return in.SecurityGroupIds == nil && in.SubnetId == nil &&
+				len(intfs) == 1 &&
+				aws.StringValue(intfs[0].SubnetId) == "subnet-1" &&
+				aws.StringValueSlice(intfs[0].Groups)[0] == "sg-1" &&
+				aws.BoolValue(intfs[0].AssociatePublicIpAddress) &&
+				aws.Int64Value(intfs[0].Ipv6AddressCount) == 1
+		}))
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.True(t, machines[0].IPv6)
+	assert.Equal(t, "2001:db8::1", machines[0].PublicIPv6)
+}
+
+func TestSyncIPv6ACLs(t *testing.T) {
+	t.Parallel()
+
+	current := []*ec2.IpPermission{
+		ipPermission("tcp", 22, 22, acl.ACL{CidrIP: "2001:db8::/32"}),
+		ipPermission("58", -1, -1, acl.ACL{CidrIP: "2001:db8::/32"}),
+		ipPermission("tcp", 22, 22, acl.ACL{CidrIP: "2001:db8:1::/48"}),
+	}
+	current[0].Ipv6Ranges = append(current[0].Ipv6Ranges,
+		&ec2.Ipv6Range{CidrIpv6: aws.String("2001:db8:2::/48")})
+
+	toAdd, _, toRemove := syncACLs([]acl.ACL{
+		{CidrIP: "2001:db8::/32", MinPort: 22, MaxPort: 22},
+	}, "sg", current)
+	assert.Equal(t, []*ec2.IpPermission{
+		ipPermission("udp", 22, 22, acl.ACL{CidrIP: "2001:db8::/32"}),
+	}, toAdd)
+
+	sort.Sort(ipPermSlice(toRemove))
+	assert.Equal(t, []*ec2.IpPermission{
+		ipPermission("tcp", 22, 22, acl.ACL{CidrIP: "2001:db8:1::/48"}),
+		ipPermission("tcp", 22, 22, acl.ACL{CidrIP: "2001:db8:2::/48"}),
+	}, toRemove)
+}
+
+func TestBootInstanceProfile(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("m4.large"),
+				IamInstanceProfile: &ec2.IamInstanceProfile{
+					Arn: aws.String("arn:aws:iam::123456789012:" +
+						"instance-profile/quilt/app")},
+				State: running,
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		InstanceProfile: "app"}})
+	assert.NoError(t, err)
+	mc.AssertCalled(t, "RunInstances", mock.MatchedBy(
+		func(in *ec2.RunInstancesInput) bool {
+			return in.IamInstanceProfile != nil &&
+				aws.StringValue(in.IamInstanceProfile.Name) == "app"
+		}))
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, "app", machines[0].InstanceProfile)
+}
+
+func TestBootSecurityGroups(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("m4.large"),
+				SecurityGroups: []*ec2.GroupIdentifier{{
+					GroupId:   aws.String("sg-2"),
+					GroupName: aws.String("baseline"),
+				}, {
+					GroupId:   aws.String("default-sg"),
+					GroupName: aws.String(testNamespace),
+				}, {
+					GroupId:   aws.String("sg-1"),
+					GroupName: aws.String("corp"),
+				}},
+				State: running,
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	// The machine is launched into the namespace's group, as well as the
+	// existing groups.
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		SecurityGroups: []string{"sg-1", "sg-2"}}})
+	assert.NoError(t, err)
+	mc.AssertCalled(t, "RunInstances", mock.MatchedBy(
+		func(in *ec2.RunInstancesInput) bool {
+			return assert.ObjectsAreEqual([]string{"default-sg", "sg-1",
+				"sg-2"}, aws.StringValueSlice(in.SecurityGroupIds))
+		}))
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, []string{"sg-1", "sg-2"}, machines[0].SecurityGroups)
+}
+
+func TestBootARM(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("DescribeImages", canonicalOwner, armAMIName).Return([]*ec2.Image{{
+		ImageId:      aws.String("ami-old"),
+		CreationDate: aws.String("2018-01-01T00:00:00.000Z"),
+	}, {
+		ImageId:      aws.String("ami-new"),
+		CreationDate: aws.String("2018-11-27T00:00:00.000Z"),
+	}}, nil).Once()
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("a1.large"),
+				State:        running,
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+	mc.On("CreateTags", mock.Anything, mock.Anything).Return(nil)
+
+	amazonProvider := newAmazon(testNamespace, "us-west-2")
+	amazonProvider.Client = mc
+
+	imageOf := func(size string) string {
+		for _, call := range mc.Calls {
+			if call.Method != "RunInstances" {
+				continue
+			}
+			in := call.Arguments.Get(0).(*ec2.RunInstancesInput)
+			if *in.InstanceType == size {
+				return *in.ImageId
+			}
+		}
+		return ""
+	}
+
+	// ARM machines boot from the newest Ubuntu ARM image, unless they choose
+	// their own.  The image is only looked up once.
+	err := amazonProvider.Boot([]db.Machine{
+		{Role: db.Worker, Size: "a1.large", Arch: blueprint.ARM64},
+		{Role: db.Worker, Size: "a1.medium", Arch: blueprint.ARM64},
+		{Role: db.Worker, Size: "a1.xlarge", Arch: blueprint.ARM64,
+			Image: "ami-custom"},
+		{Role: db.Worker, Size: "m4.large"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ami-new", imageOf("a1.large"))
+	assert.Equal(t, "ami-new", imageOf("a1.medium"))
+	assert.Equal(t, "ami-custom", imageOf("a1.xlarge"))
+	assert.Equal(t, amis["us-west-2"], imageOf("m4.large"))
+	mc.AssertNumberOfCalls(t, "DescribeImages", 1)
+
+	// Boots fail if the region has no ARM images.
+	amazonProvider = newAmazon(testNamespace, "us-west-2")
+	mc = new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("DescribeImages", canonicalOwner, armAMIName).Return(nil, nil)
+	amazonProvider.Client = mc
+
+	err = amazonProvider.Boot([]db.Machine{
+		{Role: db.Worker, Size: "a1.large", Arch: blueprint.ARM64}})
+	assert.EqualError(t, err, "ARM64 image: no images in us-west-2")
+	mc.AssertNotCalled(t, "RunInstances", mock.Anything)
+}
+
+func TestBootPlacement(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("DescribePlacementGroup", "pool").Return(nil, nil).Once()
+	mc.On("CreatePlacementGroup", "pool").Return(nil).Once()
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("c4.8xlarge"),
+				Placement: &ec2.Placement{
+					AvailabilityZone: aws.String("us-west-1b"),
+					GroupName:        aws.String("pool")},
+				State: running,
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "c4.8xlarge",
+		Zone: "us-west-1b", PlacementGroup: "pool"}})
+	assert.NoError(t, err)
+	mc.AssertCalled(t, "RunInstances", mock.MatchedBy(
+		func(in *ec2.RunInstancesInput) bool {
+			return in.Placement != nil &&
+				aws.StringValue(in.Placement.AvailabilityZone) ==
+					"us-west-1b" &&
+				aws.StringValue(in.Placement.GroupName) == "pool"
+		}))
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, "us-west-1b", machines[0].Zone)
+	assert.Equal(t, "pool", machines[0].PlacementGroup)
+
+	// Existing placement groups are used as they are.
+	mc.On("DescribePlacementGroup", "pool").Return([]*ec2.PlacementGroup{{
+		GroupName: aws.String("pool")}}, nil)
+	err = amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "c4.8xlarge",
+		PlacementGroup: "pool"}})
+	assert.NoError(t, err)
+	mc.AssertNumberOfCalls(t, "CreatePlacementGroup", 1)
+}
+
+func TestBootVolume(t *testing.T) {
+	t.Parallel()
+
+	running := &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}}, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("RunInstances", mock.Anything).Return(&ec2.Reservation{
+		Instances: []*ec2.Instance{{InstanceId: aws.String("inst")}},
+	}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("inst"),
+				InstanceType: aws.String("m4.large"),
+				BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{{
+					Ebs: &ec2.EbsInstanceBlockDevice{
+						VolumeId: aws.String("vol")}}},
+				State: running,
+			}}}}}, nil)
+	mc.On("DescribeVolumes", mock.Anything).Return([]*ec2.Volume{{
+		Size:       aws.Int64(64),
+		VolumeType: aws.String("io2"),
+		Iops:       aws.Int64(4000),
+	}}, nil)
+	mc.On("CreateTags", []string{"vol"},
+		map[string]string{namespaceTag: testNamespace}).Return(nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		DiskSize: 64, VolumeType: "io2", IOPS: 4000}})
+	assert.NoError(t, err)
+	mc.AssertCalled(t, "RunInstances", mock.MatchedBy(
+		func(in *ec2.RunInstancesInput) bool {
+			return assert.ObjectsAreEqual([]*ec2.BlockDeviceMapping{
+				blockDevice(64, "io2", 4000)}, in.BlockDeviceMappings)
+		}))
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, 64, machines[0].DiskSize)
+	assert.Equal(t, "io2", machines[0].VolumeType)
+	assert.Equal(t, 4000, machines[0].IOPS)
+
+	// Only provisioned IOPS are requested, and volumes default to gp2.
+	ebs := blockDevice(32, "", 0).Ebs
+	assert.Equal(t, "gp2", *ebs.VolumeType)
+	assert.Nil(t, ebs.Iops)
+	assert.Equal(t, int64(4000), *blockDevice(32, "io2", 4000).Ebs.Iops)
+}
+
+func TestInstanceProfileName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", instanceProfileName("", ""))
+	assert.Equal(t, "app", instanceProfileName("app", ""))
+	assert.Equal(t, "app", instanceProfileName("", "arn:aws:iam::123456789012:"+
+		"instance-profile/app"))
+	assert.Equal(t, "app", instanceProfileName("", "arn:aws:iam::123456789012:"+
+		"instance-profile/path/app"))
+}
+
+func TestSetACLsVPCs(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("default-sg"), VpcId: aws.String("vpc-default"),
+	}, {
+		GroupId: aws.String("sg-1"), VpcId: aws.String("vpc-1"),
+	}}, nil)
+	mc.On("AuthorizeSecurityGroup", mock.Anything, mock.Anything).Return(nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+	assert.NoError(t, amazonProvider.SetACLs(nil))
+
+	// Each VPC's security group allows traffic from its own members.
+	for _, groupID := range []string{"default-sg", "sg-1"} {
+		mc.AssertCalled(t, "AuthorizeSecurityGroup", groupID,
+			[]*ec2.IpPermission{{
+				IpProtocol: aws.String("-1"),
+				UserIdGroupPairs: []*ec2.UserIdGroupPair{{
+					GroupId: aws.String(groupID)}}}})
+	}
+
+	// The group in the default VPC is created if the namespace has none.
+	mc = new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return(nil, nil)
+	mc.On("DescribeDefaultVPC").Return("vpc-default", nil)
+	mc.On("CreateSecurityGroup", testNamespace, "Quilt Group", "").Return(
+		"default-sg", nil)
+	mc.On("AuthorizeSecurityGroup", "default-sg", mock.Anything).Return(nil)
+	amazonProvider.Client = mc
+	assert.NoError(t, amazonProvider.SetACLs(nil))
+	mc.AssertExpectations(t)
+}
+
 // This test attempts to boot a preemptible and non-preemptible instance,
 // but simulates a boot error where the machines never show up in `List`.
 // We should consider this a boot failure, and try to clean up by stopping
@@ -410,6 +948,7 @@ func TestBootUnsuccessful(t *testing.T) {
 	mc := new(mocks.Client)
 	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
 		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
 
 	mc.On("RequestSpotInstances", mock.Anything, mock.Anything,
 		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
@@ -433,7 +972,7 @@ func TestBootUnsuccessful(t *testing.T) {
 			},
 		}, nil,
 	)
-	mc.On("DescribeAddresses").Return(nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
 
 	mc.On("DescribeSpotInstanceRequests", mock.Anything,
 		mock.Anything).Return(nil, nil)
@@ -451,6 +990,141 @@ func TestBootUnsuccessful(t *testing.T) {
 	mc.AssertExpectations(t)
 }
 
+func TestBootSpotMaxPrice(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
+	mc.On("RequestSpotInstances", "0.12", int64(1),
+		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
+		SpotInstanceRequestId: aws.String("spot1")}}, nil)
+	mc.On("DescribeSpotInstanceRequests", []string{"spot1"}, mock.Anything).Return(
+		[]*ec2.SpotInstanceRequest{{
+			InstanceId:            aws.String("inst1"),
+			SpotInstanceRequestId: aws.String("spot1"),
+			State: aws.String(ec2.SpotInstanceStateActive)}}, nil)
+
+	// The volumes of the new instances are tagged with the namespace.
+	mc.On("DescribeInstances", []*ec2.Filter{{
+		Name:   aws.String("instance-id"),
+		Values: []*string{aws.String("inst1")}}}).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId: aws.String("inst1"),
+				BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{{
+					Ebs: &ec2.EbsInstanceBlockDevice{
+						VolumeId: aws.String("vol1")}}},
+			}}}}}, nil)
+	mc.On("CreateTags", []string{"vol1"},
+		map[string]string{namespaceTag: testNamespace}).Return(nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		Preemptible: true, MaxPrice: 0.12}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+}
+
+func TestBootSpotFailed(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
+	mc.On("RequestSpotInstances", mock.Anything, mock.Anything,
+		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
+		SpotInstanceRequestId: aws.String("spot1")}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		[]*ec2.SpotInstanceRequest{{
+			SpotInstanceRequestId: aws.String("spot1"),
+			State: aws.String(ec2.SpotInstanceStateClosed),
+			Status: &ec2.SpotInstanceStatus{
+				Code:    aws.String("bad-parameters"),
+				Message: aws.String("Invalid instance type"),
+			}}}, nil)
+	mc.On("CancelSpotInstanceRequests", []string{"spot1"}).Return(nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{}, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	// The request is closed, so the boot fails without waiting for it to be
+	// fulfilled.
+	err := amazonProvider.Boot([]db.Machine{{Role: db.Worker, Size: "m4.large",
+		Preemptible: true}})
+	assert.EqualError(t, err,
+		"spot request spot1 bad-parameters: Invalid instance type")
+	mc.AssertExpectations(t)
+}
+
+func TestListPendingSpot(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{}, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		[]*ec2.SpotInstanceRequest{{
+			SpotInstanceRequestId: aws.String("spot1"),
+			State: aws.String(ec2.SpotInstanceStateOpen),
+			Status: &ec2.SpotInstanceStatus{
+				Code: aws.String("price-too-low"),
+			},
+			LaunchSpecification: &ec2.LaunchSpecification{
+				InstanceType: aws.String("m4.large"),
+				BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+					blockDevice(32, "", 0)},
+			}}}, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	// Pending requests are listed with the size they were placed for, so that
+	// they're matched with their machines.
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{{CloudID: "spot1", Size: "m4.large",
+		DiskSize: 32, VolumeType: "gp2", Preemptible: true}}, machines)
+}
+
+func TestListInterruptedSpot(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:            aws.String("inst1"),
+				SpotInstanceRequestId: aws.String("spot1"),
+				InstanceType:          aws.String("m4.large"),
+			}}}}}, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		[]*ec2.SpotInstanceRequest{{
+			SpotInstanceRequestId: aws.String("spot1"),
+			InstanceId:            aws.String("inst1"),
+			State: aws.String(ec2.SpotInstanceStateActive),
+			Status: &ec2.SpotInstanceStatus{
+				Code: aws.String("marked-for-termination"),
+			}}}, nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{{CloudID: "spot1", Size: "m4.large",
+		Preemptible: true, Interrupted: true}}, machines)
+}
+
 func TestStop(t *testing.T) {
 	t.Parallel()
 
@@ -478,7 +1152,7 @@ func TestStop(t *testing.T) {
 	mc.On("DescribeInstances", mock.Anything).Return(
 		&ec2.DescribeInstancesOutput{}, nil,
 	)
-	mc.On("DescribeAddresses").Return(nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
 
 	amazonProvider := newAmazon(testNamespace, DefaultRegion)
 	amazonProvider.Client = mc
@@ -504,6 +1178,141 @@ func TestStop(t *testing.T) {
 	mc.AssertCalled(t, "TerminateInstances", []string{reservedIDs[0]})
 
 	mc.AssertCalled(t, "CancelSpotInstanceRequests", spotIDs)
+
+	// The Elastic IPs of machines that fail to stop aren't released.
+	mc = new(mocks.Client)
+	mc.On("TerminateInstances", mock.Anything).Return(assert.AnError)
+	amazonProvider.Client = mc
+	err = amazonProvider.Stop([]db.Machine{{CloudID: reservedIDs[0]}})
+	assert.Equal(t, assert.AnError, err)
+	mc.AssertNotCalled(t, "DescribeAddresses", mock.Anything)
+	mc.AssertNotCalled(t, "ReleaseAddress", mock.Anything)
+}
+
+func TestReboot(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	mc.On("DescribeSpotInstanceRequests", []string{"spot1", "spot2"},
+		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
+		SpotInstanceRequestId: aws.String("spot1"),
+		InstanceId:            aws.String("inst1"),
+	}, {
+		SpotInstanceRequestId: aws.String("spot2"),
+	}}, nil)
+	mc.On("RebootInstances", []string{"reserved1", "inst1"}).Return(nil)
+
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	err := amazonProvider.Reboot([]db.Machine{
+		{CloudID: "spot1", Preemptible: true},
+		{CloudID: "reserved1"},
+		{CloudID: "spot2", Preemptible: true},
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	mc = new(mocks.Client)
+	mc.On("RebootInstances", []string{"reserved1"}).Return(assert.AnError)
+	amazonProvider.Client = mc
+	err = amazonProvider.Reboot([]db.Machine{{CloudID: "reserved1"}})
+	assert.Equal(t, assert.AnError, err)
+}
+
+func TestResources(t *testing.T) {
+	t.Parallel()
+
+	launched := time.Unix(100, 0)
+	mc := new(mocks.Client)
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	mc.On("DescribeSecurityGroup", testNamespace).Return([]*ec2.SecurityGroup{{
+		GroupId: aws.String("sg"),
+	}}, nil)
+	mc.On("DescribeSpotInstanceRequests", []string(nil), mock.Anything).Return(
+		[]*ec2.SpotInstanceRequest{{
+			SpotInstanceRequestId: aws.String("spot1"),
+			CreateTime:            &launched,
+		}}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{
+			InstanceId: aws.String("inst1"),
+			LaunchTime: &launched,
+			KeyName:    aws.String("key"),
+			Tags: []*ec2.Tag{{
+				Key: aws.String("Name"), Value: aws.String("worker-1")}},
+			BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{{
+				Ebs: &ec2.EbsInstanceBlockDevice{
+					VolumeId: aws.String("vol1")}}},
+		}}}}}, nil)
+
+	// Tagged volumes are listed even once they're detached.
+	namespaceFilter := amazonProvider.namespaceFilter()
+	volumeTags := []*ec2.Tag{{Key: aws.String(namespaceTag),
+		Value: aws.String(testNamespace)}}
+	mc.On("DescribeVolumes", []*ec2.Filter{{Name: aws.String("volume-id"),
+		Values: []*string{aws.String("vol1")}}}).Return([]*ec2.Volume{{
+		VolumeId: aws.String("vol1"), CreateTime: &launched,
+		Tags: volumeTags}}, nil)
+	mc.On("DescribeVolumes", namespaceFilter).Return([]*ec2.Volume{{
+		VolumeId: aws.String("vol1"), CreateTime: &launched, Tags: volumeTags,
+	}, {
+		VolumeId: aws.String("orphan"), CreateTime: &launched,
+		Tags: volumeTags}}, nil)
+	mc.On("DescribeAddresses", []*ec2.Filter(nil)).Return([]*ec2.Address{{
+		AllocationId:  aws.String("eip1"),
+		PublicIp:      aws.String("8.8.8.8"),
+		InstanceId:    aws.String("inst1"),
+		AssociationId: aws.String("assoc1"),
+	}, {
+		AllocationId:  aws.String("other"),
+		InstanceId:    aws.String("other"),
+		AssociationId: aws.String("assoc2"),
+	}, {
+		AllocationId: aws.String("detached"),
+		PublicIp:     aws.String("9.9.9.9"),
+	}, {
+		AllocationId: aws.String("stale"),
+		PublicIp:     aws.String("7.7.7.7"),
+	}, {
+		AllocationId: aws.String("unrelated"),
+	}}, nil)
+	mc.On("DescribeAddresses", namespaceFilter).Return([]*ec2.Address{{
+		AllocationId:  aws.String("eip1"),
+		InstanceId:    aws.String("inst1"),
+		AssociationId: aws.String("assoc1"),
+	}, {
+		AllocationId: aws.String("detached"),
+	}, {
+		AllocationId: aws.String("stale"),
+	}}, nil)
+
+	// Detached IPs are released, and only listed if that fails.
+	mc.On("ReleaseAddress", "detached").Return(assert.AnError)
+	mc.On("ReleaseAddress", "stale").Return(nil)
+
+	resources, err := amazonProvider.Resources()
+	assert.NoError(t, err)
+	tags := map[string]string{namespaceTag: testNamespace}
+	assert.Equal(t, []resource.Resource{
+		{Type: resource.SecurityGroup, ID: "sg"},
+		{Type: resource.SpotRequest, ID: "spot1", Created: launched},
+		{Type: resource.Instance, ID: "inst1", Created: launched,
+			Tags: map[string]string{"Name": "worker-1"}},
+		{Type: resource.Volume, ID: "vol1", Created: launched, Tags: tags},
+		{Type: resource.Volume, ID: "orphan", Created: launched, Tags: tags},
+		{Type: resource.IP, ID: "eip1", Name: "8.8.8.8"},
+		{Type: resource.IP, ID: "detached", Name: "9.9.9.9"},
+		{Type: resource.KeyPair, ID: "key"},
+	}, resources)
+
+	mc = new(mocks.Client)
+	mc.On("DescribeSecurityGroup", testNamespace).Return(nil, assert.AnError)
+	amazonProvider.Client = mc
+	_, err = amazonProvider.Resources()
+	assert.EqualError(t, err, "list security groups: "+assert.AnError.Error())
 }
 
 func TestWaitBoot(t *testing.T) {
@@ -536,9 +1345,10 @@ func TestWaitBoot(t *testing.T) {
 		},
 	}
 	mc := new(mocks.Client)
-	mc.On("DescribeAddresses").Return(nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
 	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
 		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
 
 	mc.On("RequestSpotInstances", mock.Anything, mock.Anything,
 		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
@@ -611,6 +1421,7 @@ func TestWaitStop(t *testing.T) {
 	mc := new(mocks.Client)
 	mc.On("DescribeSecurityGroup", mock.Anything).Return([]*ec2.SecurityGroup{{
 		GroupId: aws.String("groupId")}}, nil)
+	mc.On("DescribeDefaultVPC").Return("", nil)
 
 	mc.On("RequestSpotInstances", mock.Anything, mock.Anything,
 		mock.Anything).Return([]*ec2.SpotInstanceRequest{{
@@ -629,7 +1440,7 @@ func TestWaitStop(t *testing.T) {
 		}, nil,
 	)
 
-	mc.On("DescribeAddresses").Return(nil, nil)
+	mc.On("DescribeAddresses", mock.Anything).Return(nil, nil)
 
 	describeRequests := mc.On("DescribeSpotInstanceRequests", mock.Anything,
 		mock.Anything)
@@ -713,7 +1524,7 @@ func TestUpdateFloatingIPs(t *testing.T) {
 		},
 	}
 
-	mockClient.On("DescribeAddresses").Return([]*ec2.Address{{
+	mockClient.On("DescribeAddresses", mock.Anything).Return([]*ec2.Address{{
 		// Quilt should assign x.x.x.x to sir-1.
 		AllocationId: aws.String("alloc-1"),
 		PublicIp:     aws.String("x.x.x.x"),
@@ -787,3 +1598,87 @@ func TestUpdateFloatingIPs(t *testing.T) {
 	err := amazonProvider.UpdateFloatingIPs(mockMachines)
 	assert.Nil(t, err)
 }
+
+func TestAutoFloatingIPs(t *testing.T) {
+	t.Parallel()
+
+	mc := new(mocks.Client)
+	amazonProvider := newAmazon(testNamespace, DefaultRegion)
+	amazonProvider.Client = mc
+
+	mc.On("DescribeAddresses", amazonProvider.namespaceFilter()).Return(nil, nil)
+	mc.On("DescribeAddresses", []*ec2.Filter(nil)).Return([]*ec2.Address{{
+		AllocationId:  aws.String("alloc-auto"),
+		PublicIp:      aws.String("1.1.1.1"),
+		AssociationId: aws.String("assoc-auto"),
+		InstanceId:    aws.String("i-2"),
+	}, {
+		AllocationId: aws.String("alloc-reserved"),
+		PublicIp:     aws.String("2.2.2.2"),
+	}}, nil)
+	mc.On("DescribeInstances", mock.Anything).Return(
+		&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:   aws.String("i-2"),
+				InstanceType: aws.String("m4.large"),
+				Tags: []*ec2.Tag{{
+					Key:   aws.String(autoFloatingIPTag),
+					Value: aws.String("alloc-auto"),
+				}},
+				State: &ec2.InstanceState{
+					Name: aws.String(ec2.InstanceStateNameRunning)},
+			}}}}}, nil)
+	mc.On("DescribeSpotInstanceRequests", mock.Anything, mock.Anything).Return(
+		nil, nil)
+
+	// Allocated IPs are recognized by the tag of their instance.
+	machines, err := amazonProvider.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, "1.1.1.1", machines[0].FloatingIP)
+	assert.True(t, machines[0].AutoFloatingIP)
+
+	// Machines that ask for an automatic IP are associated with a new one,
+	// which is tagged with the namespace.
+	mc.On("AllocateAddress").Return("alloc-new", "3.3.3.3", nil).Once()
+	mc.On("CreateTags", []string{"i-1"},
+		map[string]string{autoFloatingIPTag: "alloc-new"}).Return(nil).Once()
+	mc.On("AssociateAddress", "i-1", "alloc-new").Return(nil).Once()
+	mc.On("CreateTags", []string{"alloc-new"},
+		map[string]string{namespaceTag: testNamespace}).Return(nil).Once()
+	err = amazonProvider.UpdateFloatingIPs([]db.Machine{
+		{CloudID: "i-1", FloatingIP: blueprint.AutoFloatingIP}})
+	assert.NoError(t, err)
+
+	// New IPs that can't be associated are released.
+	mc.On("AllocateAddress").Return("alloc-failed", "4.4.4.4", nil).Once()
+	mc.On("CreateTags", []string{"i-1"},
+		map[string]string{autoFloatingIPTag: "alloc-failed"}).Return(
+		assert.AnError).Once()
+	mc.On("ReleaseAddress", "alloc-failed").Return(nil).Once()
+	err = amazonProvider.UpdateFloatingIPs([]db.Machine{
+		{CloudID: "i-1", FloatingIP: blueprint.AutoFloatingIP}})
+	assert.Error(t, err)
+
+	// Replacing an allocated IP releases it.
+	mc.On("AssociateAddress", "i-2", "alloc-reserved").Return(nil).Once()
+	mc.On("ReleaseAddress", "alloc-auto").Return(nil).Once()
+	err = amazonProvider.UpdateFloatingIPs([]db.Machine{
+		{CloudID: "i-2", FloatingIP: "2.2.2.2", AutoFloatingIP: true}})
+	assert.NoError(t, err)
+
+	mc.On("DisassociateAddress", "assoc-auto").Return(nil).Once()
+	mc.On("ReleaseAddress", "alloc-auto").Return(nil).Once()
+	err = amazonProvider.UpdateFloatingIPs([]db.Machine{
+		{CloudID: "i-2", AutoFloatingIP: true}})
+	assert.NoError(t, err)
+
+	// Stopping a machine releases its allocated IP.
+	mc.On("DisassociateAddress", "assoc-auto").Return(nil).Once()
+	mc.On("ReleaseAddress", "alloc-auto").Return(nil).Once()
+	err = amazonProvider.releaseFloatingIPs([]db.Machine{
+		{CloudID: "i-2", FloatingIP: "1.1.1.1", AutoFloatingIP: true},
+		{CloudID: "i-3", FloatingIP: "2.2.2.2"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+}
@@ -3,10 +3,19 @@
 package client
 
 import (
+	"net/http"
+	"sort"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
 	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
 )
 
 // A Client to an Amazon EC2 region.
@@ -14,6 +23,8 @@ type Client interface {
 	DescribeInstances([]*ec2.Filter) (*ec2.DescribeInstancesOutput, error)
 	RunInstances(*ec2.RunInstancesInput) (*ec2.Reservation, error)
 	TerminateInstances(ids []string) error
+	RebootInstances(ids []string) error
+	CreateTags(ids []string, tags map[string]string) error
 
 	DescribeSpotInstanceRequests(ids []string, filters []*ec2.Filter) (
 		[]*ec2.SpotInstanceRequest, error)
@@ -23,14 +34,22 @@ type Client interface {
 	CancelSpotInstanceRequests(ids []string) error
 
 	DescribeSecurityGroup(name string) ([]*ec2.SecurityGroup, error)
-	CreateSecurityGroup(name, description string) (string, error)
-	AuthorizeSecurityGroup(name, src string, ranges []*ec2.IpPermission) error
-	RevokeSecurityGroup(name string, ranges []*ec2.IpPermission) error
-	DescribeAddresses() ([]*ec2.Address, error)
+	CreateSecurityGroup(name, description, vpcID string) (string, error)
+	AuthorizeSecurityGroup(groupID string, ranges []*ec2.IpPermission) error
+	RevokeSecurityGroup(groupID string, ranges []*ec2.IpPermission) error
+	DescribeDefaultVPC() (string, error)
+	DescribeAddresses(filters []*ec2.Filter) ([]*ec2.Address, error)
+	AllocateAddress() (allocationID, publicIP string, err error)
+	ReleaseAddress(allocationID string) error
 	AssociateAddress(id, allocationID string) error
 	DisassociateAddress(associationID string) error
 
-	DescribeVolumes(id string) ([]*ec2.Volume, error)
+	DescribePlacementGroup(name string) ([]*ec2.PlacementGroup, error)
+	CreatePlacementGroup(name string) error
+
+	DescribeVolumes(filters []*ec2.Filter) ([]*ec2.Volume, error)
+
+	DescribeImages(owner, name string) ([]*ec2.Image, error)
 }
 
 type awsClient struct {
@@ -57,6 +76,34 @@ func (ac awsClient) TerminateInstances(ids []string) error {
 	return err
 }
 
+func (ac awsClient) RebootInstances(ids []string) error {
+	c.Inc("Reboot Instances")
+	_, err := ac.client.RebootInstances(&ec2.RebootInstancesInput{
+		InstanceIds: stringSlice(ids)})
+	return err
+}
+
+func (ac awsClient) CreateTags(ids []string, tags map[string]string) error {
+	c.Inc("Create Tags")
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var ec2Tags []*ec2.Tag
+	for _, key := range keys {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(key),
+			Value: aws.String(tags[key])})
+	}
+
+	_, err := ac.client.CreateTags(&ec2.CreateTagsInput{
+		Resources: stringSlice(ids),
+		Tags:      ec2Tags,
+	})
+	return err
+}
+
 func (ac awsClient) DescribeSpotInstanceRequests(ids []string, filters []*ec2.Filter) (
 	[]*ec2.SpotInstanceRequest, error) {
 	c.Inc("List Spots")
@@ -101,52 +148,89 @@ func (ac awsClient) DescribeSecurityGroup(name string) ([]*ec2.SecurityGroup, er
 	return resp.SecurityGroups, err
 }
 
-func (ac awsClient) CreateSecurityGroup(name, description string) (string, error) {
+// CreateSecurityGroup creates a security group in the VPC with ID `vpcID`, or in
+// the default VPC if it's empty.
+func (ac awsClient) CreateSecurityGroup(name, description, vpcID string) (
+	string, error) {
 	c.Inc("Create Security Group")
+
+	var vpcPtr *string
+	if vpcID != "" {
+		vpcPtr = &vpcID
+	}
+
 	csgResp, err := ac.client.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
 		GroupName:   &name,
-		Description: &description})
+		Description: &description,
+		VpcId:       vpcPtr})
 	if err != nil {
 		return "", err
 	}
 	return *csgResp.GroupId, err
 }
 
-func (ac awsClient) AuthorizeSecurityGroup(name, src string,
+func (ac awsClient) AuthorizeSecurityGroup(groupID string,
 	ranges []*ec2.IpPermission) error {
 	c.Inc("Authorize Security Group")
-
-	var srcPtr *string
-	if src != "" {
-		srcPtr = &src
-	}
-
 	_, err := ac.client.AuthorizeSecurityGroupIngress(
 		&ec2.AuthorizeSecurityGroupIngressInput{
-			GroupName:               &name,
-			SourceSecurityGroupName: srcPtr,
-			IpPermissions:           ranges})
+			GroupId:       &groupID,
+			IpPermissions: ranges})
 	return err
 }
 
-func (ac awsClient) RevokeSecurityGroup(name string, ranges []*ec2.IpPermission) error {
+func (ac awsClient) RevokeSecurityGroup(groupID string,
+	ranges []*ec2.IpPermission) error {
 	c.Inc("Revoke Security Group")
 	_, err := ac.client.RevokeSecurityGroupIngress(
 		&ec2.RevokeSecurityGroupIngressInput{
-			GroupName:     &name,
+			GroupId:       &groupID,
 			IpPermissions: ranges})
 	return err
 }
 
-func (ac awsClient) DescribeAddresses() ([]*ec2.Address, error) {
+// DescribeDefaultVPC returns the ID of the region's default VPC, or an empty
+// string if the account doesn't have one.
+func (ac awsClient) DescribeDefaultVPC() (string, error) {
+	c.Inc("List VPCs")
+	resp, err := ac.client.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("isDefault"),
+			Values: []*string{aws.String("true")}}}})
+	if err != nil || len(resp.Vpcs) == 0 {
+		return "", err
+	}
+	return aws.StringValue(resp.Vpcs[0].VpcId), nil
+}
+
+func (ac awsClient) DescribeAddresses(filters []*ec2.Filter) ([]*ec2.Address,
+	error) {
 	c.Inc("List Addresses")
-	resp, err := ac.client.DescribeAddresses(nil)
+	resp, err := ac.client.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: filters})
 	if err != nil {
 		return nil, err
 	}
 	return resp.Addresses, err
 }
 
+func (ac awsClient) AllocateAddress() (string, string, error) {
+	c.Inc("Allocate Address")
+	resp, err := ac.client.AllocateAddress(&ec2.AllocateAddressInput{
+		Domain: aws.String(ec2.DomainTypeVpc)})
+	if err != nil {
+		return "", "", err
+	}
+	return aws.StringValue(resp.AllocationId), aws.StringValue(resp.PublicIp), nil
+}
+
+func (ac awsClient) ReleaseAddress(allocationID string) error {
+	c.Inc("Release Address")
+	_, err := ac.client.ReleaseAddress(&ec2.ReleaseAddressInput{
+		AllocationId: &allocationID})
+	return err
+}
+
 func (ac awsClient) AssociateAddress(id, allocationID string) error {
 	c.Inc("Associate Address")
 	_, err := ac.client.AssociateAddress(&ec2.AssociateAddressInput{
@@ -162,23 +246,83 @@ func (ac awsClient) DisassociateAddress(associationID string) error {
 	return err
 }
 
-func (ac awsClient) DescribeVolumes(id string) ([]*ec2.Volume, error) {
+func (ac awsClient) DescribePlacementGroup(name string) (
+	[]*ec2.PlacementGroup, error) {
+	c.Inc("List Placement Groups")
+	resp, err := ac.client.DescribePlacementGroups(
+		&ec2.DescribePlacementGroupsInput{
+			Filters: []*ec2.Filter{{
+				Name:   aws.String("group-name"),
+				Values: []*string{&name}}}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.PlacementGroups, err
+}
+
+// CreatePlacementGroup creates a placement group with the cluster strategy, which
+// packs its instances close together for low network latency.
+func (ac awsClient) CreatePlacementGroup(name string) error {
+	c.Inc("Create Placement Group")
+	_, err := ac.client.CreatePlacementGroup(&ec2.CreatePlacementGroupInput{
+		GroupName: &name,
+		Strategy:  aws.String(ec2.PlacementStrategyCluster)})
+	return err
+}
+
+func (ac awsClient) DescribeVolumes(filters []*ec2.Filter) ([]*ec2.Volume, error) {
 	c.Inc("List Volumes")
 	resp, err := ac.client.DescribeVolumes(&ec2.DescribeVolumesInput{
-		Filters: []*ec2.Filter{{
-			Name:   aws.String("volume-id"),
-			Values: []*string{&id}}}})
+		Filters: filters})
 	if err != nil {
 		return nil, err
 	}
 	return resp.Volumes, err
 }
 
+// DescribeImages lists the available images owned by `owner` whose names match
+// the `name` pattern.
+func (ac awsClient) DescribeImages(owner, name string) ([]*ec2.Image, error) {
+	c.Inc("List Images")
+	resp, err := ac.client.DescribeImages(&ec2.DescribeImagesInput{
+		Owners: []*string{&owner},
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("name"),
+			Values: []*string{&name},
+		}, {
+			Name:   aws.String("state"),
+			Values: []*string{aws.String(ec2.ImageStateAvailable)}}}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Images, err
+}
+
 // New creates a new Client.
 func New(region string) Client {
 	c.Inc("New Client")
 	session := session.New()
 	session.Config.Region = &region
+
+	httpClient := session.Config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if url, ok := endpoint.Get(db.Amazon); ok {
+		session.Config.Endpoint = aws.String(strings.TrimSuffix(url, "/"))
+		httpClient = endpoint.Client(db.Amazon, httpClient)
+	}
+
+	// Throttled requests are retried by the HTTP client, so the SDK doesn't
+	// retry them on top of it.
+	session.Config.HTTPClient = cassette.Wrap("amazon-"+region,
+		throttle.Wrap(db.Amazon, region, httpClient))
+	session.Config.MaxRetries = aws.Int(0)
+	if cassette.Replaying() {
+		// Requests are still signed, but the signature isn't checked.
+		session.Config.Credentials = credentials.NewStaticCredentials(
+			"replay", "replay", "")
+	}
 	return awsClient{ec2.New(session)}
 }
 
@@ -38,16 +38,25 @@ func TestErrors(t *testing.T) {
 	_, err = ac.DescribeSecurityGroup("")
 	assert.EqualError(t, err, "test")
 
-	_, err = ac.CreateSecurityGroup("", "")
+	_, err = ac.CreateSecurityGroup("", "", "")
 	assert.EqualError(t, err, "test")
 
-	err = ac.AuthorizeSecurityGroup("name", "src", nil)
+	err = ac.AuthorizeSecurityGroup("id", nil)
 	assert.EqualError(t, err, "test")
 
 	err = ac.RevokeSecurityGroup("", nil)
 	assert.EqualError(t, err, "test")
 
-	_, err = ac.DescribeAddresses()
+	_, err = ac.DescribeDefaultVPC()
+	assert.EqualError(t, err, "test")
+
+	_, err = ac.DescribeAddresses(nil)
+	assert.EqualError(t, err, "test")
+
+	_, _, err = ac.AllocateAddress()
+	assert.EqualError(t, err, "test")
+
+	err = ac.ReleaseAddress("")
 	assert.EqualError(t, err, "test")
 
 	err = ac.AssociateAddress("", "")
@@ -56,6 +65,15 @@ func TestErrors(t *testing.T) {
 	err = ac.DisassociateAddress("")
 	assert.EqualError(t, err, "test")
 
-	_, err = ac.DescribeVolumes("")
+	_, err = ac.DescribePlacementGroup("")
+	assert.EqualError(t, err, "test")
+
+	err = ac.CreatePlacementGroup("")
+	assert.EqualError(t, err, "test")
+
+	_, err = ac.DescribeVolumes(nil)
+	assert.EqualError(t, err, "test")
+
+	_, err = ac.DescribeImages("", "")
 	assert.EqualError(t, err, "test")
 }
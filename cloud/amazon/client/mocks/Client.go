@@ -10,6 +10,34 @@ type Client struct {
 	mock.Mock
 }
 
+// AllocateAddress provides a mock function with given fields:
+func (_m *Client) AllocateAddress() (string, string, error) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func() string); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func() error); ok {
+		r2 = rf()
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // AssociateAddress provides a mock function with given fields: id, allocationID
 func (_m *Client) AssociateAddress(id string, allocationID string) error {
 	ret := _m.Called(id, allocationID)
@@ -24,13 +52,13 @@ func (_m *Client) AssociateAddress(id string, allocationID string) error {
 	return r0
 }
 
-// AuthorizeSecurityGroup provides a mock function with given fields: name, src, ranges
-func (_m *Client) AuthorizeSecurityGroup(name string, src string, ranges []*ec2.IpPermission) error {
-	ret := _m.Called(name, src, ranges)
+// AuthorizeSecurityGroup provides a mock function with given fields: groupID, ranges
+func (_m *Client) AuthorizeSecurityGroup(groupID string, ranges []*ec2.IpPermission) error {
+	ret := _m.Called(groupID, ranges)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, []*ec2.IpPermission) error); ok {
-		r0 = rf(name, src, ranges)
+	if rf, ok := ret.Get(0).(func(string, []*ec2.IpPermission) error); ok {
+		r0 = rf(groupID, ranges)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -52,20 +80,34 @@ func (_m *Client) CancelSpotInstanceRequests(ids []string) error {
 	return r0
 }
 
-// CreateSecurityGroup provides a mock function with given fields: name, description
-func (_m *Client) CreateSecurityGroup(name string, description string) (string, error) {
-	ret := _m.Called(name, description)
+// CreatePlacementGroup provides a mock function with given fields: name
+func (_m *Client) CreatePlacementGroup(name string) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateSecurityGroup provides a mock function with given fields: name, description, vpcID
+func (_m *Client) CreateSecurityGroup(name string, description string, vpcID string) (string, error) {
+	ret := _m.Called(name, description, vpcID)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string, string) string); ok {
-		r0 = rf(name, description)
+	if rf, ok := ret.Get(0).(func(string, string, string) string); ok {
+		r0 = rf(name, description, vpcID)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(name, description)
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(name, description, vpcID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -73,13 +115,34 @@ func (_m *Client) CreateSecurityGroup(name string, description string) (string,
 	return r0, r1
 }
 
-// DescribeAddresses provides a mock function with given fields:
-func (_m *Client) DescribeAddresses() ([]*ec2.Address, error) {
+// DescribeDefaultVPC provides a mock function with given fields:
+func (_m *Client) DescribeDefaultVPC() (string, error) {
 	ret := _m.Called()
 
-	var r0 []*ec2.Address
-	if rf, ok := ret.Get(0).(func() []*ec2.Address); ok {
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
 		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DescribeAddresses provides a mock function with given fields: filters
+func (_m *Client) DescribeAddresses(filters []*ec2.Filter) ([]*ec2.Address, error) {
+	ret := _m.Called(filters)
+
+	var r0 []*ec2.Address
+	if rf, ok := ret.Get(0).(func([]*ec2.Filter) []*ec2.Address); ok {
+		r0 = rf(filters)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*ec2.Address)
@@ -87,8 +150,31 @@ func (_m *Client) DescribeAddresses() ([]*ec2.Address, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func([]*ec2.Filter) error); ok {
+		r1 = rf(filters)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DescribeImages provides a mock function with given fields: owner, name
+func (_m *Client) DescribeImages(owner string, name string) ([]*ec2.Image, error) {
+	ret := _m.Called(owner, name)
+
+	var r0 []*ec2.Image
+	if rf, ok := ret.Get(0).(func(string, string) []*ec2.Image); ok {
+		r0 = rf(owner, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*ec2.Image)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(owner, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -119,6 +205,29 @@ func (_m *Client) DescribeInstances(_a0 []*ec2.Filter) (*ec2.DescribeInstancesOu
 	return r0, r1
 }
 
+// DescribePlacementGroup provides a mock function with given fields: name
+func (_m *Client) DescribePlacementGroup(name string) ([]*ec2.PlacementGroup, error) {
+	ret := _m.Called(name)
+
+	var r0 []*ec2.PlacementGroup
+	if rf, ok := ret.Get(0).(func(string) []*ec2.PlacementGroup); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*ec2.PlacementGroup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DescribeSecurityGroup provides a mock function with given fields: name
 func (_m *Client) DescribeSecurityGroup(name string) ([]*ec2.SecurityGroup, error) {
 	ret := _m.Called(name)
@@ -165,13 +274,13 @@ func (_m *Client) DescribeSpotInstanceRequests(ids []string, filters []*ec2.Filt
 	return r0, r1
 }
 
-// DescribeVolumes provides a mock function with given fields: id
-func (_m *Client) DescribeVolumes(id string) ([]*ec2.Volume, error) {
-	ret := _m.Called(id)
+// DescribeVolumes provides a mock function with given fields: filters
+func (_m *Client) DescribeVolumes(filters []*ec2.Filter) ([]*ec2.Volume, error) {
+	ret := _m.Called(filters)
 
 	var r0 []*ec2.Volume
-	if rf, ok := ret.Get(0).(func(string) []*ec2.Volume); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func([]*ec2.Filter) []*ec2.Volume); ok {
+		r0 = rf(filters)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*ec2.Volume)
@@ -179,8 +288,8 @@ func (_m *Client) DescribeVolumes(id string) ([]*ec2.Volume, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func([]*ec2.Filter) error); ok {
+		r1 = rf(filters)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -202,6 +311,20 @@ func (_m *Client) DisassociateAddress(associationID string) error {
 	return r0
 }
 
+// ReleaseAddress provides a mock function with given fields: allocationID
+func (_m *Client) ReleaseAddress(allocationID string) error {
+	ret := _m.Called(allocationID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(allocationID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RequestSpotInstances provides a mock function with given fields: spotPrice, count, launchSpec
 func (_m *Client) RequestSpotInstances(spotPrice string, count int64, launchSpec *ec2.RequestSpotLaunchSpecification) ([]*ec2.SpotInstanceRequest, error) {
 	ret := _m.Called(spotPrice, count, launchSpec)
@@ -225,13 +348,13 @@ func (_m *Client) RequestSpotInstances(spotPrice string, count int64, launchSpec
 	return r0, r1
 }
 
-// RevokeSecurityGroup provides a mock function with given fields: name, ranges
-func (_m *Client) RevokeSecurityGroup(name string, ranges []*ec2.IpPermission) error {
-	ret := _m.Called(name, ranges)
+// RevokeSecurityGroup provides a mock function with given fields: groupID, ranges
+func (_m *Client) RevokeSecurityGroup(groupID string, ranges []*ec2.IpPermission) error {
+	ret := _m.Called(groupID, ranges)
 
 	var r0 error
 	if rf, ok := ret.Get(0).(func(string, []*ec2.IpPermission) error); ok {
-		r0 = rf(name, ranges)
+		r0 = rf(groupID, ranges)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -262,6 +385,34 @@ func (_m *Client) RunInstances(_a0 *ec2.RunInstancesInput) (*ec2.Reservation, er
 	return r0, r1
 }
 
+// RebootInstances provides a mock function with given fields: ids
+func (_m *Client) RebootInstances(ids []string) error {
+	ret := _m.Called(ids)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]string) error); ok {
+		r0 = rf(ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateTags provides a mock function with given fields: ids, tags
+func (_m *Client) CreateTags(ids []string, tags map[string]string) error {
+	ret := _m.Called(ids, tags)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]string, map[string]string) error); ok {
+		r0 = rf(ids, tags)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // TerminateInstances provides a mock function with given fields: ids
 func (_m *Client) TerminateInstances(ids []string) error {
 	ret := _m.Called(ids)
@@ -0,0 +1,179 @@
+// Package assets implements the daemon's asset server, from which booting
+// machines fetch their boot script and TLS credentials.  This keeps the user-data
+// passed to providers small, regardless of the size of the boot script.
+//
+// Each machine's user-data contains a one-time token, which is redeemed for the
+// machine's boot script.  The tokens are derived from the daemon's CA, so they
+// survive restarts of the daemon, and are bound to the blueprint ID of the machine
+// they were issued to.  The credentials in the boot script are signed for the
+// private IP and cloud ID of that machine, so the server only responds once the
+// daemon has learned them from the machine's provider, and only to requests from
+// one of the machine's addresses.  A token that leaks from a machine's user-data
+// therefore can't be redeemed for the credentials of another machine.
+package assets
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kelda/kelda/cloud/cfg"
+	quiltTLS "github.com/kelda/kelda/connection/tls"
+	tlsIO "github.com/kelda/kelda/connection/tls/io"
+	"github.com/kelda/kelda/connection/tls/rsa"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var c = counter.New("Assets")
+
+var errUnknownMachine = errors.New("unknown machine")
+
+var myIP = util.MyIP
+
+type server struct {
+	conn db.Conn
+	ca   rsa.KeyPair
+}
+
+// Run serves boot assets on `address`, and makes subsequently booted machines
+// fetch their boot script from it.  Its TLS certificate is signed by `ca`.  The
+// tokens that have been redeemed are stored at `redeemedPath`.
+func Run(conn db.Conn, ca rsa.KeyPair, address, redeemedPath string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if host, err = myIP(); err != nil {
+			return fmt.Errorf("get public IP: %s", err)
+		}
+	}
+
+	signed, err := rsa.NewSigned(ca)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.X509KeyPair([]byte(signed.CertString()),
+		[]byte(signed.PrivateKeyString()))
+	if err != nil {
+		return err
+	}
+
+	pin, err := publicKeyPin(signed)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://%s", net.JoinHostPort(host, port))
+	cfg.EnableAssets(url, pin, tokenKey(ca), redeemedPath)
+	log.WithField("url", url).Info("Serving boot assets")
+
+	mux := http.NewServeMux()
+	mux.Handle("/boot.sh", server{conn, ca})
+	httpServer := http.Server{
+		Addr:      address,
+		Handler:   mux,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	return httpServer.ListenAndServeTLS("", "")
+}
+
+// publicKeyPin returns the base64 encoded SHA-256 hash of the public key of
+// `keyPair`, in the format expected by curl's --pinnedpubkey.
+func publicKeyPin(keyPair rsa.KeyPair) (string, error) {
+	block, _ := pem.Decode([]byte(keyPair.CertString()))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// tokenKey returns the key that authenticates boot tokens.  It's derived from the
+// CA, so that it's the same each time the daemon starts.
+func tokenKey(ca rsa.KeyPair) []byte {
+	key := sha256.Sum256([]byte("quilt boot token " + ca.PrivateKeyString()))
+	return key[:]
+}
+
+func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	var script string
+	err := cfg.Redeem(token, func(tok cfg.Token) (err error) {
+		script, err = s.bootScript(tok, host)
+		return err
+	})
+
+	switch err {
+	case nil:
+		c.Inc("Serve Boot Script")
+		log.WithField("host", host).Info("Served boot script")
+		fmt.Fprint(w, script)
+	case cfg.ErrInvalidToken:
+		c.Inc("Invalid Token")
+		log.WithField("host", host).Warn("Boot script request with invalid token")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errUnknownMachine:
+		// The machine will retry once the daemon has learned its IP.
+		c.Inc("Unknown Machine")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		log.WithError(err).WithField("host", host).Error(
+			"Failed to generate boot script")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// bootScript returns the boot script for the booting machine that `tok` was
+// issued to, whose request came from `host`.  The request must come from the
+// machine's public or floating IP, or from its private IP if the daemon is in the
+// machine's network.  The script installs TLS credentials signed for the
+// machine's private IP and cloud ID before running the usual boot script.
+// Machines that are already connected don't need a boot script, so their
+// credentials aren't handed out.
+func (s server) bootScript(tok cfg.Token, host string) (string, error) {
+	machines := s.conn.SelectFromMachine(func(dbm db.Machine) bool {
+		return tok.BlueprintID != "" && dbm.BlueprintID == tok.BlueprintID &&
+			dbm.Role == tok.Role && dbm.CloudID != "" &&
+			dbm.PrivateIP != "" && dbm.Status != db.Connected &&
+			(dbm.PublicIP == host || dbm.FloatingIP == host ||
+				dbm.PrivateIP == host)
+	})
+
+	if len(machines) != 1 {
+		return "", errUnknownMachine
+	}
+	m := machines[0]
+
+	signed, err := rsa.NewSignedWithURIs(s.ca,
+		[]*url.URL{quiltTLS.MachineURI(m.CloudID)}, net.ParseIP(m.PrivateIP))
+	if err != nil {
+		return "", err
+	}
+
+	script := "#!/bin/bash\n\n"
+	script += fmt.Sprintf("mkdir -p %s\n", tlsIO.MinionTLSDir)
+	for _, f := range tlsIO.MinionFiles(tlsIO.MinionTLSDir, s.ca, signed) {
+		script += fmt.Sprintf("install -m %o /dev/null %s\n", f.Mode, f.Path)
+		script += fmt.Sprintf("cat <<'EOF' > %s\n%sEOF\n", f.Path, f.Content)
+	}
+	return script + "\n" + cfg.Ubuntu(m, ""), nil
+}
@@ -0,0 +1,141 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/cfg"
+	tlsIO "github.com/kelda/kelda/connection/tls/io"
+	"github.com/kelda/kelda/connection/tls/rsa"
+	"github.com/kelda/kelda/db"
+)
+
+var tokenRegex = regexp.MustCompile(`Bearer ([0-9a-zA-Z.]+)`)
+
+func TestServeBootScript(t *testing.T) {
+	defer cfg.EnableAssets("", "", nil, "")
+
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	conn := db.New()
+	s := server{conn, ca}
+
+	cfg.EnableAssets("https://8.8.8.8:9001", "pin", tokenKey(ca), "")
+	token := tokenRegex.FindStringSubmatch(cfg.UserData(
+		db.Machine{Role: db.Worker, BlueprintID: "worker"}, ""))[1]
+
+	get := func(token, host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/boot.sh", nil)
+		req.RemoteAddr = host + ":5678"
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("bad", "1.2.3.4").Code)
+
+	// The daemon hasn't learned the machine's IPs yet.
+	assert.Equal(t, http.StatusServiceUnavailable, get(token, "1.2.3.4").Code)
+
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		for _, id := range []string{"worker", "other"} {
+			m := view.InsertMachine()
+			m.BlueprintID = id
+			m.Role = db.Worker
+			m.PublicIP = "1.2.3.4"
+			m.PrivateIP = "10.0.0.1"
+			m.CloudID = id
+			m.SSHKeys = []string{"ssh-rsa key"}
+			if id == "other" {
+				m.PublicIP = "5.6.7.8"
+				m.PrivateIP = "10.0.0.2"
+			}
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	// Requests from addresses other than the machine's are refused, even if
+	// they come from another booting machine.
+	assert.Equal(t, http.StatusServiceUnavailable, get(token, "9.9.9.9").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(token, "5.6.7.8").Code)
+
+	// The daemon restarts, and the token it issued before is still valid.
+	cfg.EnableAssets("https://8.8.8.8:9001", "pin", tokenKey(ca), "")
+
+	w := get(token, "1.2.3.4")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "mkdir -p "+tlsIO.MinionTLSDir)
+	assert.Contains(t, w.Body.String(), ca.CertString())
+	assert.Contains(t, w.Body.String(), "--role \"Worker\"")
+	assert.Contains(t, w.Body.String(), "ssh-rsa key")
+
+	// Tokens may only be used once.
+	assert.Equal(t, http.StatusUnauthorized, get(token, "1.2.3.4").Code)
+
+	// Tokens are only redeemed for machines of the role they were issued for.
+	masterToken := tokenRegex.FindStringSubmatch(cfg.UserData(
+		db.Machine{Role: db.Master, BlueprintID: "worker"}, ""))[1]
+	assert.Equal(t, http.StatusServiceUnavailable,
+		get(masterToken, "1.2.3.4").Code)
+
+	// Connected machines don't need their boot script.
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		for _, m := range view.SelectFromMachine(nil) {
+			m.Status = db.Connected
+			view.Commit(m)
+		}
+		return nil
+	})
+	token = tokenRegex.FindStringSubmatch(cfg.UserData(
+		db.Machine{Role: db.Worker, BlueprintID: "worker"}, ""))[1]
+	assert.Equal(t, http.StatusServiceUnavailable, get(token, "1.2.3.4").Code)
+}
+
+func TestBootScriptAddresses(t *testing.T) {
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	conn := db.New()
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.BlueprintID = "worker"
+		m.Role = db.Worker
+		m.PublicIP = "1.1.1.1"
+		m.FloatingIP = "2.2.2.2"
+		m.PrivateIP = "10.0.0.1"
+		m.CloudID = "id"
+		view.Commit(m)
+		return nil
+	})
+	s := server{conn, ca}
+
+	// Requests may come from any of the machine's addresses.
+	tok := cfg.Token{Role: db.Worker, BlueprintID: "worker"}
+	for _, host := range []string{"1.1.1.1", "2.2.2.2", "10.0.0.1"} {
+		_, err = s.bootScript(tok, host)
+		assert.NoError(t, err, host)
+	}
+
+	_, err = s.bootScript(tok, "5.5.5.5")
+	assert.Equal(t, errUnknownMachine, err)
+
+	// Tokens without a blueprint ID aren't bound to a machine.
+	_, err = s.bootScript(cfg.Token{Role: db.Worker}, "1.1.1.1")
+	assert.Equal(t, errUnknownMachine, err)
+}
+
+func TestPublicKeyPin(t *testing.T) {
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	pin, err := publicKeyPin(ca)
+	assert.NoError(t, err)
+	assert.Len(t, pin, 44)
+}
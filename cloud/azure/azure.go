@@ -0,0 +1,702 @@
+package azure
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/azure/client"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cfg"
+	cloudResource "github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/wait"
+	"github.com/kelda/kelda/db"
+
+	"github.com/satori/go.uuid"
+	"golang.org/x/crypto/ssh"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRegion is assigned to Machines without a specified region
+const DefaultRegion string = "westus2"
+
+// Regions supported by the Azure API
+var Regions = []string{"centralus", "eastus", "eastus2", "northeurope",
+	"southeastasia", "westeurope", "westus", "westus2"}
+
+// The versions of the Resource Manager APIs used by the provider.
+const (
+	resourcesVersion = "2017-05-10"
+	networkVersion   = "2018-02-01"
+	computeVersion   = "2017-12-01"
+)
+
+// The network that connects the machines.  It mustn't overlap with the Quilt
+// subnet, which is routed by the minions.
+const (
+	ipv4Range  = "192.168.0.0/16"
+	subnetName = "quilt"
+)
+
+// The user that Azure creates on each machine.  Azure requires it to have an SSH
+// key, so it's given a key that's thrown away.  The boot script creates the
+// users that actually log in to the machine.
+const adminUser = "quilt-admin"
+
+// The Provider object represents a connection to Azure.  Each namespace and
+// region is a resource group containing the machines, and the network and
+// security group that they share.
+type Provider struct {
+	client.Client
+
+	namespace string
+	region    string
+	group     string // The path of the resource group.
+	adminKey  string
+}
+
+// New starts a new client session with the service principal in
+// ~/.azure/quilt.json.
+func New(namespace, region string) (*Provider, error) {
+	prvdr, err := newAzure(namespace, region)
+	if err != nil {
+		return prvdr, err
+	}
+
+	_, err = prvdr.List()
+	return prvdr, err
+}
+
+// Creation is broken out for unit testing.
+var newAzure = func(namespace, region string) (*Provider, error) {
+	apiClient, err := client.New("azure-" + region)
+	if err != nil {
+		return nil, err
+	}
+
+	adminKey, err := newAdminKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate admin key: %s", err)
+	}
+
+	return &Provider{
+		Client:    apiClient,
+		namespace: namespace,
+		region:    region,
+		group:     "/resourceGroups/" + groupName(namespace, region),
+		adminKey:  adminKey,
+	}, nil
+}
+
+// groupName returns the name of the resource group of `namespace` in `region`.
+// Resource group names may only contain alphanumerics, underscores, hyphens,
+// periods and parentheses, so other characters are replaced with hyphens.
+func groupName(namespace, region string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9',
+			strings.ContainsRune("_-.()", r):
+			return r
+		default:
+			return '-'
+		}
+	}, namespace+"-"+region)
+}
+
+func newAdminKey() (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(ssh.MarshalAuthorizedKey(pub)), nil
+}
+
+// The path of the resource of type `typ` called `name`, e.g.
+// "Microsoft.Compute/virtualMachines".
+func (prvdr Provider) path(typ, name string) string {
+	return fmt.Sprintf("%s/providers/%s/%s", prvdr.group, typ, name)
+}
+
+func (prvdr Provider) vmPath(name string) string {
+	return prvdr.path("Microsoft.Compute/virtualMachines", name)
+}
+
+func (prvdr Provider) diskPath(name string) string {
+	return prvdr.path("Microsoft.Compute/disks", name)
+}
+
+func (prvdr Provider) nicPath(name string) string {
+	return prvdr.path("Microsoft.Network/networkInterfaces", name)
+}
+
+func (prvdr Provider) publicIPPath(name string) string {
+	return prvdr.path("Microsoft.Network/publicIPAddresses", name)
+}
+
+func (prvdr Provider) nsgPath() string {
+	return prvdr.path("Microsoft.Network/networkSecurityGroups", "quilt")
+}
+
+func (prvdr Provider) vnetPath() string {
+	return prvdr.path("Microsoft.Network/virtualNetworks", "quilt")
+}
+
+// The subset of the API's resources that the provider reads.  The JSON decoder
+// matches field names case insensitively, so only the fields whose names differ
+// from the API's are tagged.
+type resource struct {
+	ID         string
+	Properties struct {
+		ProvisioningState string
+	}
+}
+
+type virtualMachine struct {
+	Name       string
+	Properties struct {
+		HardwareProfile struct {
+			VMSize string
+		}
+		StorageProfile struct {
+			OSDisk struct {
+				DiskSizeGB int
+			}
+		}
+		NetworkProfile struct {
+			NetworkInterfaces []subResource
+		}
+	}
+}
+
+type networkInterface struct {
+	ID         string
+	Properties struct {
+		IPConfigurations []struct {
+			Properties struct {
+				PrivateIPAddress string
+				PublicIPAddress  subResource
+			}
+		}
+	}
+}
+
+type publicIPAddress struct {
+	ID         string
+	Properties struct {
+		IPAddress string
+	}
+}
+
+type subResource struct {
+	ID string `json:"id"`
+}
+
+// List the machines in the namespace's resource group.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	vms, err := prvdr.Client.List(prvdr.group+
+		"/providers/Microsoft.Compute/virtualMachines", computeVersion)
+	if client.IsNotFound(err) {
+		// The resource group is created when the first machine boots.
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("list virtual machines: %s", err)
+	}
+
+	nics := map[string]networkInterface{}
+	if err := prvdr.listInto("Microsoft.Network/networkInterfaces",
+		func(raw json.RawMessage) error {
+			var nic networkInterface
+			err := json.Unmarshal(raw, &nic)
+			nics[strings.ToLower(nic.ID)] = nic
+			return err
+		}); err != nil {
+		return nil, fmt.Errorf("list network interfaces: %s", err)
+	}
+
+	publicIPs := map[string]string{}
+	if err := prvdr.listInto("Microsoft.Network/publicIPAddresses",
+		func(raw json.RawMessage) error {
+			var ip publicIPAddress
+			err := json.Unmarshal(raw, &ip)
+			publicIPs[strings.ToLower(ip.ID)] = ip.Properties.IPAddress
+			return err
+		}); err != nil {
+		return nil, fmt.Errorf("list public IPs: %s", err)
+	}
+
+	var machines []db.Machine
+	for _, raw := range vms {
+		var vm virtualMachine
+		if err := json.Unmarshal(raw, &vm); err != nil {
+			return nil, fmt.Errorf("parse virtual machine: %s", err)
+		}
+
+		m := db.Machine{
+			CloudID:  vm.Name,
+			Size:     vm.Properties.HardwareProfile.VMSize,
+			DiskSize: vm.Properties.StorageProfile.OSDisk.DiskSizeGB,
+		}
+
+		// Resource IDs are case insensitive, and the API doesn't always
+		// capitalize them consistently.
+		for _, nicRef := range vm.Properties.NetworkProfile.NetworkInterfaces {
+			nic := nics[strings.ToLower(nicRef.ID)]
+			for _, ipCfg := range nic.Properties.IPConfigurations {
+				m.PrivateIP = ipCfg.Properties.PrivateIPAddress
+				m.PublicIP = publicIPs[strings.ToLower(
+					ipCfg.Properties.PublicIPAddress.ID)]
+			}
+		}
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+// listInto calls `parse` on each resource of type `typ` in the resource group.
+func (prvdr Provider) listInto(typ string, parse func(json.RawMessage) error) error {
+	values, err := prvdr.Client.List(prvdr.group+"/providers/"+typ, networkVersion)
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range values {
+		if err := parse(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Boot creates the network if it doesn't exist, and then boots every machine in
+// a goroutine, and waits for the machines to come up.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	for _, m := range bootSet {
+		if m.Preemptible {
+			return errors.New("preemptible instances are not yet implemented")
+		}
+	}
+
+	subnetID, err := prvdr.setupNetwork()
+	if err != nil {
+		return fmt.Errorf("setup network: %s", err)
+	}
+
+	errChan := make(chan error, len(bootSet))
+	for _, m := range bootSet {
+		go func(m db.Machine) {
+			errChan <- prvdr.createMachine(m, subnetID)
+		}(m)
+	}
+
+	for range bootSet {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// setupNetwork creates the resource group, the security group that enforces the
+// ACLs, and the virtual network, and returns the ID of the subnet in which the
+// machines boot.
+func (prvdr Provider) setupNetwork() (string, error) {
+	group := map[string]interface{}{"location": prvdr.region}
+	if err := prvdr.Put(prvdr.group, resourcesVersion, group, nil); err != nil {
+		return "", fmt.Errorf("create resource group: %s", err)
+	}
+
+	nsgID, err := prvdr.getOrCreate(prvdr.nsgPath(), map[string]interface{}{
+		"location": prvdr.region,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create security group: %s", err)
+	}
+
+	vnetID, err := prvdr.getOrCreate(prvdr.vnetPath(), map[string]interface{}{
+		"location": prvdr.region,
+		"properties": map[string]interface{}{
+			"addressSpace": map[string]interface{}{
+				"addressPrefixes": []string{ipv4Range},
+			},
+			"subnets": []interface{}{map[string]interface{}{
+				"name": subnetName,
+				"properties": map[string]interface{}{
+					"addressPrefix":        ipv4Range,
+					"networkSecurityGroup": subResource{nsgID},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("create virtual network: %s", err)
+	}
+	return vnetID + "/subnets/" + subnetName, nil
+}
+
+// getOrCreate returns the ID of the network resource at `path`, creating it
+// with `body` if it doesn't exist.
+func (prvdr Provider) getOrCreate(path string, body interface{}) (string, error) {
+	var res resource
+	err := prvdr.Get(path, networkVersion, &res)
+	if err == nil {
+		return res.ID, nil
+	} else if !client.IsNotFound(err) {
+		return "", err
+	}
+
+	if err := prvdr.Put(path, networkVersion, body, &res); err != nil {
+		return "", err
+	}
+	return res.ID, prvdr.waitProvisioned(path, networkVersion)
+}
+
+// createMachine creates a virtual machine, and the public IP and network
+// interface that connect it, and waits for it to be provisioned.  If creation
+// fails, the resources that were created are deleted.
+func (prvdr Provider) createMachine(m db.Machine, subnetID string) error {
+	name := "quilt-" + uuid.NewV4().String()
+	if m.Hostname != "" {
+		name = m.Hostname
+	}
+	if err := prvdr.createResources(name, m, subnetID); err != nil {
+		if cleanupErr := prvdr.deleteMachine(name); cleanupErr != nil {
+			log.WithError(cleanupErr).WithField("name", name).Warn(
+				"Failed to clean up machine that failed to boot")
+		}
+		return err
+	}
+	return nil
+}
+
+func (prvdr Provider) createResources(name string, m db.Machine,
+	subnetID string) error {
+
+	var publicIP resource
+	err := prvdr.Put(prvdr.publicIPPath(name), networkVersion,
+		map[string]interface{}{
+			"location": prvdr.region,
+			"properties": map[string]interface{}{
+				"publicIPAllocationMethod": "Static",
+			},
+		}, &publicIP)
+	if err != nil {
+		return fmt.Errorf("create public IP: %s", err)
+	}
+
+	var nic resource
+	err = prvdr.Put(prvdr.nicPath(name), networkVersion, map[string]interface{}{
+		"location": prvdr.region,
+		"properties": map[string]interface{}{
+			"ipConfigurations": []interface{}{map[string]interface{}{
+				"name": "quilt",
+				"properties": map[string]interface{}{
+					"subnet":                    subResource{subnetID},
+					"publicIPAddress":           subResource{publicIP.ID},
+					"privateIPAllocationMethod": "Dynamic",
+				},
+			}},
+		},
+	}, &nic)
+	if err != nil {
+		return fmt.Errorf("create network interface: %s", err)
+	}
+
+	osDisk := map[string]interface{}{
+		"name":         name,
+		"createOption": "FromImage",
+		"managedDisk": map[string]interface{}{
+			"storageAccountType": "Standard_LRS",
+		},
+	}
+	if m.DiskSize != 0 {
+		osDisk["diskSizeGB"] = m.DiskSize
+	}
+
+	userData := base64.StdEncoding.EncodeToString([]byte(cfg.UserData(m, "")))
+	err = prvdr.Put(prvdr.vmPath(name), computeVersion, map[string]interface{}{
+		"location": prvdr.region,
+		"properties": map[string]interface{}{
+			"hardwareProfile": map[string]interface{}{"vmSize": m.Size},
+			"storageProfile": map[string]interface{}{
+				"imageReference": map[string]interface{}{
+					"publisher": "Canonical",
+					"offer":     "UbuntuServer",
+					"sku":       "16.04-LTS",
+					"version":   "latest",
+				},
+				"osDisk": osDisk,
+			},
+			"osProfile": map[string]interface{}{
+				"computerName":  name,
+				"adminUsername": adminUser,
+				"customData":    userData,
+				"linuxConfiguration": map[string]interface{}{
+					"disablePasswordAuthentication": true,
+					"ssh": map[string]interface{}{
+						"publicKeys": []interface{}{
+							map[string]interface{}{
+								"path": fmt.Sprintf(
+									"/home/%s/.ssh/authorized_keys",
+									adminUser),
+								"keyData": prvdr.adminKey,
+							},
+						},
+					},
+				},
+			},
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []subResource{{nic.ID}},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("create virtual machine: %s", err)
+	}
+	return prvdr.waitProvisioned(prvdr.vmPath(name), computeVersion)
+}
+
+// waitProvisioned waits for the resource at `path` to finish provisioning.
+func (prvdr Provider) waitProvisioned(path, apiVersion string) error {
+	var state string
+	err := wait.Wait(func() bool {
+		var res resource
+		if err := prvdr.Get(path, apiVersion, &res); err != nil {
+			return false
+		}
+		state = res.Properties.ProvisioningState
+		return state == "Succeeded" || state == "Failed"
+	})
+	if err == nil && state == "Failed" {
+		err = fmt.Errorf("provisioning %s failed", path)
+	}
+	return err
+}
+
+// Stop deletes each machine, along with its disk and network resources.
+func (prvdr Provider) Stop(machines []db.Machine) error {
+	errChan := make(chan error, len(machines))
+	for _, m := range machines {
+		go func(m db.Machine) {
+			errChan <- prvdr.deleteMachine(m.CloudID)
+		}(m)
+	}
+
+	var err error
+	for range machines {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// deleteMachine deletes the resources of the machine called `name`.  Resources
+// can't be deleted while they're in use, so they're deleted in order, starting
+// with the virtual machine.
+func (prvdr Provider) deleteMachine(name string) error {
+	for _, res := range []struct{ path, apiVersion string }{
+		{prvdr.vmPath(name), computeVersion},
+		{prvdr.diskPath(name), computeVersion},
+		{prvdr.nicPath(name), networkVersion},
+		{prvdr.publicIPPath(name), networkVersion},
+	} {
+		if err := prvdr.deleteAndWait(res.path, res.apiVersion); err != nil {
+			return fmt.Errorf("delete %s: %s", res.path, err)
+		}
+	}
+	return nil
+}
+
+func (prvdr Provider) deleteAndWait(path, apiVersion string) error {
+	err := prvdr.Delete(path, apiVersion)
+	if err != nil && !client.IsNotFound(err) {
+		return err
+	}
+
+	return wait.Wait(func() bool {
+		return client.IsNotFound(prvdr.Get(path, apiVersion, nil))
+	})
+}
+
+// Reboot restarts the virtual machines of `machines`.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		err := prvdr.Post(prvdr.vmPath(m.CloudID)+"/restart", computeVersion)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The types of resources that the provider creates in resource groups, and the
+// resource types they're reported as.
+var resourceTypes = []struct {
+	typ, apiVersion, reportAs string
+}{
+	{"Microsoft.Network/virtualNetworks", networkVersion,
+		cloudResource.Network},
+	{"Microsoft.Network/networkSecurityGroups", networkVersion,
+		cloudResource.SecurityGroup},
+	{"Microsoft.Compute/virtualMachines", computeVersion,
+		cloudResource.Instance},
+	{"Microsoft.Compute/disks", computeVersion, cloudResource.Volume},
+	{"Microsoft.Network/networkInterfaces", networkVersion,
+		cloudResource.Other},
+	{"Microsoft.Network/publicIPAddresses", networkVersion, cloudResource.IP},
+}
+
+// Resources lists the resources in the namespace's resource group.  Only disks
+// report when they were created.
+func (prvdr Provider) Resources() ([]cloudResource.Resource, error) {
+	var resources []cloudResource.Resource
+	for _, rt := range resourceTypes {
+		values, err := prvdr.Client.List(prvdr.group+"/providers/"+rt.typ,
+			rt.apiVersion)
+		if client.IsNotFound(err) {
+			// The resource group is created when the first machine boots.
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("list %s: %s", rt.typ, err)
+		}
+
+		for _, raw := range values {
+			var res struct {
+				Name       string
+				Tags       map[string]string
+				Properties struct {
+					TimeCreated time.Time
+					IPAddress   string
+				}
+			}
+			if err := json.Unmarshal(raw, &res); err != nil {
+				return nil, fmt.Errorf("parse %s: %s", rt.typ, err)
+			}
+
+			resources = append(resources, cloudResource.Resource{
+				Type:    rt.reportAs,
+				ID:      res.Name,
+				Name:    res.Properties.IPAddress,
+				Tags:    res.Tags,
+				Created: res.Properties.TimeCreated,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// Capabilities returns the optional features that Azure supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		ResizableDisks: true,
+	}
+}
+
+// UpdateFloatingIPs is not supported in Azure.
+func (prvdr Provider) UpdateFloatingIPs([]db.Machine) error {
+	return errors.New("azure provider does not support floating IPs")
+}
+
+type securityRule struct {
+	Name       string                 `json:"name"`
+	Properties securityRuleProperties `json:"properties"`
+}
+
+type securityRuleProperties struct {
+	Protocol                 string `json:"protocol"`
+	SourcePortRange          string `json:"sourcePortRange"`
+	DestinationPortRange     string `json:"destinationPortRange"`
+	SourceAddressPrefix      string `json:"sourceAddressPrefix"`
+	DestinationAddressPrefix string `json:"destinationAddressPrefix"`
+	Access                   string `json:"access"`
+	Priority                 int    `json:"priority"`
+	Direction                string `json:"direction"`
+}
+
+// SetACLs replaces the rules of the security group attached to the subnet, so
+// that it only allows the given inbound traffic from outside of the virtual
+// network.  Traffic within the network is allowed by Azure's default rules.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	if _, err := prvdr.setupNetwork(); err != nil {
+		return fmt.Errorf("setup network: %s", err)
+	}
+
+	var nsg struct {
+		Properties struct {
+			SecurityRules []securityRule
+		}
+	}
+	if err := prvdr.Get(prvdr.nsgPath(), networkVersion, &nsg); err != nil {
+		return fmt.Errorf("get security group: %s", err)
+	}
+
+	rules := securityRules(acls)
+	curr := nsg.Properties.SecurityRules
+	if (len(rules) == 0 && len(curr) == 0) || reflect.DeepEqual(rules, curr) {
+		return nil
+	}
+
+	log.WithField("ACLs", acls).Debug("Azure: Setting ACLs")
+	err := prvdr.Put(prvdr.nsgPath(), networkVersion, map[string]interface{}{
+		"location": prvdr.region,
+		"properties": map[string]interface{}{
+			"securityRules": rules,
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("update security group: %s", err)
+	}
+	return prvdr.waitProvisioned(prvdr.nsgPath(), networkVersion)
+}
+
+// securityRules converts `acls` to security rules.  Each rule needs a unique
+// priority, so the ACLs are sorted to give them a consistent one.
+func securityRules(acls []acl.ACL) []securityRule {
+	acls = append([]acl.ACL{}, acls...)
+	sort.Slice(acls, func(i, j int) bool {
+		if acls[i].CidrIP != acls[j].CidrIP {
+			return acls[i].CidrIP < acls[j].CidrIP
+		}
+		if acls[i].MinPort != acls[j].MinPort {
+			return acls[i].MinPort < acls[j].MinPort
+		}
+		return acls[i].MaxPort < acls[j].MaxPort
+	})
+
+	var rules []securityRule
+	for i, a := range acls {
+		ports := fmt.Sprintf("%d-%d", a.MinPort, a.MaxPort)
+		if a.MinPort == a.MaxPort {
+			ports = fmt.Sprintf("%d", a.MinPort)
+		}
+
+		rules = append(rules, securityRule{
+			Name: fmt.Sprintf("quilt-%d", i),
+			Properties: securityRuleProperties{
+				Protocol:                 "*",
+				SourcePortRange:          "*",
+				DestinationPortRange:     ports,
+				SourceAddressPrefix:      a.CidrIP,
+				DestinationAddressPrefix: "*",
+				Access:                   "Allow",
+				Priority:                 100 + i,
+				Direction:                "Inbound",
+			},
+		})
+	}
+	return rules
+}
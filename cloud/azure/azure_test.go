@@ -0,0 +1,331 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/azure/client"
+	cloudResource "github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+)
+
+// fakeClient emulates the Resource Manager API by storing the body of each Put,
+// and marking it as provisioned.
+type fakeClient struct {
+	sync.Mutex
+	resources map[string]map[string]interface{}
+	ips       int
+
+	failPut string
+	posts   []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{resources: map[string]map[string]interface{}{}}
+}
+
+var errNotFound = client.Error{StatusCode: http.StatusNotFound, Code: "NotFound"}
+
+func (fc *fakeClient) Get(path, apiVersion string, result interface{}) error {
+	fc.Lock()
+	defer fc.Unlock()
+
+	res, ok := fc.resources[strings.ToLower(path)]
+	if !ok {
+		return errNotFound
+	}
+	return decode(res, result)
+}
+
+func (fc *fakeClient) List(path, apiVersion string) ([]json.RawMessage, error) {
+	fc.Lock()
+	defer fc.Unlock()
+
+	group := strings.ToLower(strings.SplitN(path, "/providers/", 2)[0])
+	if _, ok := fc.resources[group]; !ok {
+		return nil, errNotFound
+	}
+
+	var values []json.RawMessage
+	prefix := strings.ToLower(path) + "/"
+	for resPath, res := range fc.resources {
+		if strings.HasPrefix(resPath, prefix) &&
+			!strings.Contains(strings.TrimPrefix(resPath, prefix), "/") {
+			var raw json.RawMessage
+			decode(res, &raw)
+			values = append(values, raw)
+		}
+	}
+	return values, nil
+}
+
+func (fc *fakeClient) Put(path, apiVersion string, body, result interface{}) error {
+	fc.Lock()
+	defer fc.Unlock()
+
+	if fc.failPut != "" && strings.Contains(path, fc.failPut) {
+		return errors.New("put failed")
+	}
+
+	res := map[string]interface{}{}
+	decode(body, &res)
+	res["id"] = "/subscriptions/sub" + path
+	res["name"] = path[strings.LastIndex(path, "/")+1:]
+
+	props, _ := res["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+		res["properties"] = props
+	}
+	props["provisioningState"] = "Succeeded"
+
+	fc.ips++
+	switch {
+	case strings.Contains(path, "publicIPAddresses"):
+		props["ipAddress"] = fmt.Sprintf("8.8.8.%d", fc.ips)
+	case strings.Contains(path, "networkInterfaces"):
+		ipCfg := props["ipConfigurations"].([]interface{})[0]
+		ipProps := ipCfg.(map[string]interface{})["properties"]
+		ipProps.(map[string]interface{})["privateIPAddress"] =
+			fmt.Sprintf("192.168.0.%d", fc.ips)
+	}
+
+	fc.resources[strings.ToLower(path)] = res
+	return decode(res, result)
+}
+
+func (fc *fakeClient) Delete(path, apiVersion string) error {
+	fc.Lock()
+	defer fc.Unlock()
+
+	if _, ok := fc.resources[strings.ToLower(path)]; !ok {
+		return errNotFound
+	}
+	delete(fc.resources, strings.ToLower(path))
+	return nil
+}
+
+func (fc *fakeClient) Post(path, apiVersion string) error {
+	fc.Lock()
+	defer fc.Unlock()
+
+	fc.posts = append(fc.posts, path)
+	return nil
+}
+
+func (fc *fakeClient) paths(typ string) (paths []string) {
+	fc.Lock()
+	defer fc.Unlock()
+	for path := range fc.resources {
+		if strings.Contains(path, strings.ToLower(typ)) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func decode(in, out interface{}) error {
+	if out == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func newTestProvider() (Provider, *fakeClient) {
+	fc := newFakeClient()
+	return Provider{
+		Client:    fc,
+		namespace: "ns",
+		region:    DefaultRegion,
+		group:     "/resourceGroups/" + groupName("ns", DefaultRegion),
+		adminKey:  "ssh-rsa key",
+	}, fc
+}
+
+func TestBootListStop(t *testing.T) {
+	prvdr, fc := newTestProvider()
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+
+	err = prvdr.Boot([]db.Machine{
+		{Size: "Standard_B1s", DiskSize: 32},
+		{Size: "Standard_B2s"},
+	})
+	assert.NoError(t, err)
+
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 2)
+
+	sizes := map[string]int{}
+	for _, m := range machines {
+		assert.True(t, strings.HasPrefix(m.CloudID, "quilt-"))
+		assert.True(t, strings.HasPrefix(m.PublicIP, "8.8.8."))
+		assert.True(t, strings.HasPrefix(m.PrivateIP, "192.168.0."))
+		sizes[m.Size] = m.DiskSize
+	}
+	assert.Equal(t, map[string]int{"Standard_B1s": 32, "Standard_B2s": 0}, sizes)
+
+	var vm struct {
+		Properties struct {
+			OSProfile struct {
+				AdminUsername string
+				CustomData    string
+			}
+		}
+	}
+	assert.NoError(t, prvdr.Get(prvdr.vmPath(machines[0].CloudID),
+		computeVersion, &vm))
+	assert.Equal(t, adminUser, vm.Properties.OSProfile.AdminUsername)
+	assert.NotEmpty(t, vm.Properties.OSProfile.CustomData)
+
+	assert.NoError(t, prvdr.Stop(machines[:1]))
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+
+	assert.NoError(t, prvdr.Stop(machines))
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+
+	// Only the shared network resources remain.
+	assert.Empty(t, fc.paths("publicIPAddresses"))
+	assert.Empty(t, fc.paths("networkInterfaces"))
+	assert.Len(t, fc.paths("virtualNetworks"), 1)
+	assert.Len(t, fc.paths("networkSecurityGroups"), 1)
+}
+
+func TestBootHostname(t *testing.T) {
+	prvdr, _ := newTestProvider()
+	assert.NoError(t, prvdr.Boot([]db.Machine{
+		{Size: "Standard_B1s", Hostname: "worker-1"}}))
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, "worker-1", machines[0].CloudID)
+
+	var vm struct {
+		Properties struct {
+			OSProfile struct{ ComputerName string }
+		}
+	}
+	assert.NoError(t, prvdr.Get(prvdr.vmPath("worker-1"), computeVersion, &vm))
+	assert.Equal(t, "worker-1", vm.Properties.OSProfile.ComputerName)
+}
+
+func TestBootErrors(t *testing.T) {
+	prvdr, fc := newTestProvider()
+
+	err := prvdr.Boot([]db.Machine{{Preemptible: true}})
+	assert.EqualError(t, err, "preemptible instances are not yet implemented")
+
+	// The resources of a machine that fails to boot are cleaned up.
+	fc.failPut = "virtualMachines"
+	err = prvdr.Boot([]db.Machine{{Size: "Standard_B1s"}})
+	assert.EqualError(t, err, "create virtual machine: put failed")
+	assert.Empty(t, fc.paths("publicIPAddresses"))
+	assert.Empty(t, fc.paths("networkInterfaces"))
+}
+
+func TestSetACLs(t *testing.T) {
+	prvdr, _ := newTestProvider()
+
+	getRules := func() []securityRule {
+		var nsg struct {
+			Properties struct {
+				SecurityRules []securityRule
+			}
+		}
+		assert.NoError(t, prvdr.Get(prvdr.nsgPath(), networkVersion, &nsg))
+		return nsg.Properties.SecurityRules
+	}
+
+	acls := []acl.ACL{
+		{CidrIP: "8.8.8.8/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "1.2.3.4/32", MinPort: 1, MaxPort: 65535},
+	}
+	assert.NoError(t, prvdr.SetACLs(acls))
+
+	rules := getRules()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "1.2.3.4/32", rules[0].Properties.SourceAddressPrefix)
+	assert.Equal(t, "1-65535", rules[0].Properties.DestinationPortRange)
+	assert.Equal(t, 100, rules[0].Properties.Priority)
+	assert.Equal(t, "8.8.8.8/32", rules[1].Properties.SourceAddressPrefix)
+	assert.Equal(t, "80", rules[1].Properties.DestinationPortRange)
+	assert.Equal(t, 101, rules[1].Properties.Priority)
+
+	assert.NoError(t, prvdr.SetACLs(acls[:1]))
+	rules = getRules()
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "8.8.8.8/32", rules[0].Properties.SourceAddressPrefix)
+
+	assert.NoError(t, prvdr.SetACLs(nil))
+	assert.Empty(t, getRules())
+}
+
+func TestReboot(t *testing.T) {
+	prvdr, fc := newTestProvider()
+	assert.NoError(t, prvdr.Reboot([]db.Machine{{CloudID: "quilt-1"}}))
+	assert.Equal(t, []string{prvdr.vmPath("quilt-1") + "/restart"}, fc.posts)
+}
+
+func TestResources(t *testing.T) {
+	prvdr, fc := newTestProvider()
+
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Empty(t, resources)
+
+	assert.NoError(t, prvdr.Boot([]db.Machine{
+		{Size: "Standard_B1s", Hostname: "worker-1"}}))
+	fc.resources[strings.ToLower(prvdr.diskPath("worker-1"))] =
+		map[string]interface{}{"name": "worker-1", "properties": map[string]string{
+			"timeCreated": "2017-10-13T17:00:00Z"}}
+
+	resources, err = prvdr.Resources()
+	assert.NoError(t, err)
+
+	types := map[string]string{}
+	for _, res := range resources {
+		types[res.Type] = res.ID
+		switch res.Type {
+		case cloudResource.Volume:
+			assert.Equal(t, time.Date(2017, 10, 13, 17, 0, 0, 0, time.UTC),
+				res.Created)
+		case cloudResource.IP:
+			assert.True(t, strings.HasPrefix(res.Name, "8.8.8."))
+		}
+	}
+	assert.Equal(t, map[string]string{
+		cloudResource.Network:       "quilt",
+		cloudResource.SecurityGroup: "quilt",
+		cloudResource.Instance:      "worker-1",
+		cloudResource.Volume:        "worker-1",
+		cloudResource.Other:         "worker-1",
+		cloudResource.IP:            "worker-1",
+	}, types)
+}
+
+func TestGroupName(t *testing.T) {
+	assert.Equal(t, "my-name_space.1-westus2",
+		groupName("my name_space.1", "westus2"))
+}
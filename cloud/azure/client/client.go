@@ -0,0 +1,309 @@
+// Package client is a minimal client of the Azure Resource Manager API.  The
+// Azure SDK for Go is large, and Quilt only needs to manage a handful of
+// resources, so requests are made directly to the REST API.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+
+	"golang.org/x/oauth2"
+)
+
+// A Client for the Azure Resource Manager API.  Paths are relative to the
+// subscription, e.g. "/resourceGroups/quilt".  Used for unit testing.
+type Client interface {
+	// Get decodes the resource at `path` into `result`.
+	Get(path, apiVersion string, result interface{}) error
+
+	// List returns the resources in the collection at `path`.
+	List(path, apiVersion string) ([]json.RawMessage, error)
+
+	// Put creates or replaces the resource at `path`, and decodes the
+	// response into `result` if it's non-nil.  Resources are usually
+	// provisioned asynchronously after Put returns.
+	Put(path, apiVersion string, body, result interface{}) error
+
+	// Delete starts deleting the resource at `path`.
+	Delete(path, apiVersion string) error
+
+	// Post starts the action at `path`, such as restarting a virtual machine.
+	Post(path, apiVersion string) error
+}
+
+// An Error is returned by the API when a request fails.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// IsNotFound returns whether `err` reports that a resource doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+const (
+	managementURL = "https://management.azure.com/"
+	loginURL      = "https://login.microsoftonline.com/"
+)
+
+// The service principal with which Quilt authenticates, in the format printed by
+// `az ad sp create-for-rbac --sdk-auth`.
+type credentials struct {
+	ClientID       string `json:"clientId"`
+	ClientSecret   string `json:"clientSecret"`
+	SubscriptionID string `json:"subscriptionId"`
+	TenantID       string `json:"tenantId"`
+}
+
+var credentialsPath = filepath.Join(".azure", "quilt.json")
+
+// The environment variables, as used by the Azure SDKs, that override the
+// service principal in the credentials file.
+const (
+	clientIDEnv       = "AZURE_CLIENT_ID"
+	clientSecretEnv   = "AZURE_CLIENT_SECRET"
+	subscriptionIDEnv = "AZURE_SUBSCRIPTION_ID"
+	tenantIDEnv       = "AZURE_TENANT_ID"
+)
+
+var c = counter.New("Azure")
+
+type client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// New creates a client with the credentials in ~/.azure/quilt.json, or in the
+// environment, whose requests are recorded or replayed as `cassetteName`.
+func New(cassetteName string) (Client, error) {
+	// Replayed responses don't require valid credentials.
+	creds := credentials{SubscriptionID: "replay"}
+	if !cassette.Replaying() {
+		var err error
+		creds, err = readCredentials(
+			filepath.Join(os.Getenv("HOME"), credentialsPath))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	base := managementURL
+	if endpointURL, ok := endpoint.Get(db.Azure); ok {
+		base = endpointURL
+	}
+
+	// Azure's rate limits apply to the whole subscription.
+	httpClient := throttle.Wrap(db.Azure, "",
+		endpoint.Client(db.Azure, &http.Client{}))
+	if !cassette.Replaying() {
+		src := tokenSource{creds: creds, http: httpClient}
+		httpClient = &http.Client{Transport: &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, src),
+			Base:   httpClient.Transport,
+		}}
+	}
+
+	return &client{
+		http:    cassette.Wrap(cassetteName, httpClient),
+		baseURL: base + "subscriptions/" + creds.SubscriptionID,
+	}, nil
+}
+
+func readCredentials(path string) (credentials, error) {
+	var sp credentials
+	credsStr, err := util.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(credsStr), &sp); err != nil {
+			return sp, fmt.Errorf("parse %s: %s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return sp, err
+	}
+
+	creds.FromEnv(&sp.ClientID, clientIDEnv)
+	creds.FromEnv(&sp.ClientSecret, clientSecretEnv)
+	creds.FromEnv(&sp.SubscriptionID, subscriptionIDEnv)
+	creds.FromEnv(&sp.TenantID, tenantIDEnv)
+
+	switch {
+	case sp == credentials{}:
+		return sp, creds.MissingError{Provider: db.Azure, Sources: []string{
+			path, creds.EnvSource(clientIDEnv, clientSecretEnv,
+				subscriptionIDEnv, tenantIDEnv)}}
+	case sp.ClientID == "" || sp.ClientSecret == "" ||
+		sp.SubscriptionID == "" || sp.TenantID == "":
+		return sp, fmt.Errorf("%s must contain the clientId, clientSecret, "+
+			"subscriptionId, and tenantId of a service principal", path)
+	}
+	return sp, nil
+}
+
+func (client *client) Get(path, apiVersion string, result interface{}) error {
+	c.Inc("Get")
+	return client.do("GET", client.url(path, apiVersion), nil, result)
+}
+
+func (client *client) List(path, apiVersion string) ([]json.RawMessage, error) {
+	c.Inc("List")
+
+	var values []json.RawMessage
+	reqURL := client.url(path, apiVersion)
+	for reqURL != "" {
+		var page struct {
+			Value    []json.RawMessage `json:"value"`
+			NextLink string            `json:"nextLink"`
+		}
+		if err := client.do("GET", reqURL, nil, &page); err != nil {
+			return nil, err
+		}
+		values = append(values, page.Value...)
+		reqURL = page.NextLink
+	}
+	return values, nil
+}
+
+func (client *client) Put(path, apiVersion string, body,
+	result interface{}) error {
+	c.Inc("Put")
+	return client.do("PUT", client.url(path, apiVersion), body, result)
+}
+
+func (client *client) Delete(path, apiVersion string) error {
+	c.Inc("Delete")
+	return client.do("DELETE", client.url(path, apiVersion), nil, nil)
+}
+
+func (client *client) Post(path, apiVersion string) error {
+	c.Inc("Post")
+	return client.do("POST", client.url(path, apiVersion), nil, nil)
+}
+
+func (client *client) url(path, apiVersion string) string {
+	return client.baseURL + path + "?api-version=" + apiVersion
+}
+
+func (client *client) do(method, reqURL string, body, result interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, reqURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		if apiErr.Error.Code == "" {
+			apiErr.Error.Code = resp.Status
+		}
+		return Error{
+			StatusCode: resp.StatusCode,
+			Code:       apiErr.Error.Code,
+			Message:    apiErr.Error.Message,
+		}
+	}
+
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// tokenSource fetches access tokens for the Resource Manager API with the
+// client credentials grant.
+type tokenSource struct {
+	creds credentials
+	http  *http.Client
+}
+
+func (src tokenSource) Token() (*oauth2.Token, error) {
+	c.Inc("Token")
+	resp, err := src.http.PostForm(loginURL+src.creds.TenantID+"/oauth2/token",
+		url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {src.creds.ClientID},
+			"client_secret": {src.creds.ClientSecret},
+			"resource":      {managementURL},
+		})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresOn   string `json:"expires_on"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("decode token: %s", err)
+	}
+
+	if token.AccessToken == "" {
+		if token.Description == "" {
+			token.Description = resp.Status
+		}
+		return nil, errors.New(strings.TrimSpace(token.Description))
+	}
+
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token expiry: %s", token.ExpiresOn)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Unix(expiresOn, 0),
+	}, nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/util"
+)
+
+func TestRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "2017-01-01", r.URL.Query().Get("api-version"))
+
+			switch r.URL.Path {
+			case "/subscriptions/sub/resourceGroups/rg":
+				if r.Method == "PUT" {
+					body, _ := ioutil.ReadAll(r.Body)
+					w.Write(body)
+					return
+				}
+				fmt.Fprint(w, `{"name": "rg"}`)
+			case "/subscriptions/sub/vm/restart":
+				assert.Equal(t, "POST", r.Method)
+				w.WriteHeader(http.StatusAccepted)
+			case "/subscriptions/sub/page1":
+				fmt.Fprintf(w, `{"value": [1, 2], "nextLink": "%s"}`,
+					"http://"+r.Host+"/subscriptions/sub/page2"+
+						"?api-version=2017-01-01")
+			case "/subscriptions/sub/page2":
+				fmt.Fprint(w, `{"value": [3]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": "ResourceNotFound", `+
+					`"message": "missing"}}`)
+			}
+		}))
+	defer server.Close()
+
+	client := &client{http: server.Client(),
+		baseURL: server.URL + "/subscriptions/sub"}
+
+	var res struct{ Name string }
+	assert.NoError(t, client.Get("/resourceGroups/rg", "2017-01-01", &res))
+	assert.Equal(t, "rg", res.Name)
+
+	res.Name = ""
+	assert.NoError(t, client.Put("/resourceGroups/rg", "2017-01-01",
+		map[string]string{"name": "put"}, &res))
+	assert.Equal(t, "put", res.Name)
+
+	values, err := client.List("/page1", "2017-01-01")
+	assert.NoError(t, err)
+	assert.Len(t, values, 3)
+	assert.Equal(t, "3", string(values[2]))
+
+	assert.NoError(t, client.Post("/vm/restart", "2017-01-01"))
+
+	err = client.Delete("/missing", "2017-01-01")
+	assert.EqualError(t, err, "ResourceNotFound: missing")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestReadCredentials(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	_, err := readCredentials("creds")
+	assert.EqualError(t, err, "no Azure credentials found in creds, or the "+
+		"AZURE_CLIENT_ID, AZURE_CLIENT_SECRET, AZURE_SUBSCRIPTION_ID and "+
+		"AZURE_TENANT_ID environment variables")
+
+	util.WriteFile("creds", []byte(`{"clientId": "id"}`), 0600)
+	_, err = readCredentials("creds")
+	assert.EqualError(t, err, "creds must contain the clientId, clientSecret, "+
+		"subscriptionId, and tenantId of a service principal")
+
+	util.WriteFile("creds", []byte(`{"clientId": "id", "clientSecret": "secret",
+		"subscriptionId": "sub", "tenantId": "tenant"}`), 0600)
+	creds, err := readCredentials("creds")
+	assert.NoError(t, err)
+	assert.Equal(t, credentials{ClientID: "id", ClientSecret: "secret",
+		SubscriptionID: "sub", TenantID: "tenant"}, creds)
+
+	// The environment takes precedence over the credentials file.
+	os.Setenv(subscriptionIDEnv, "envSub")
+	defer os.Unsetenv(subscriptionIDEnv)
+	creds, err = readCredentials("creds")
+	assert.NoError(t, err)
+	assert.Equal(t, "envSub", creds.SubscriptionID)
+	assert.Equal(t, "id", creds.ClientID)
+}
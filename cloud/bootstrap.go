@@ -0,0 +1,153 @@
+package cloud
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+)
+
+var bootstrapCounter = counter.New("Cloud Bootstrap")
+
+// SSHBootstrapProviders are the providers whose machines are bootstrapped over
+// SSH unless their blueprint selects otherwise.  It's set by the daemon.
+var SSHBootstrapProviders = map[db.ProviderName]struct{}{}
+
+// The boot script is uploaded to bootScriptPath, and bootMarkerPath is created
+// once it has completed, so that it isn't rerun if the daemon restarts.
+const (
+	bootScriptPath = "/tmp/quilt-boot.sh"
+	bootMarkerPath = "/var/lib/quilt-bootstrapped"
+)
+
+var bootstrapCommand = fmt.Sprintf("if [ ! -e %[2]s ]; then "+
+	"cat > %[1]s && sudo bash %[1]s < /dev/null && sudo touch %[2]s; fi",
+	bootScriptPath, bootMarkerPath)
+
+// bootstrapMode returns how the boot script is delivered to `m`.  Machines whose
+// boot script is too large for their provider's user-data are bootstrapped over
+// SSH, even if the blueprint requests user-data, as they would fail to boot
+// otherwise.
+func bootstrapMode(m db.Machine) string {
+	if !cfg.UserDataFits(m) {
+		return blueprint.SSHBootstrap
+	}
+	if m.Bootstrap != "" {
+		return m.Bootstrap
+	}
+	if _, ok := SSHBootstrapProviders[m.Provider]; ok {
+		return blueprint.SSHBootstrap
+	}
+	return blueprint.UserDataBootstrap
+}
+
+// SyncBootstrap runs the boot script over SSH on the machines that are
+// bootstrapped over SSH rather than with user-data.  Like SyncCredentials, it
+// authenticates with the given ssh key, which the machines' user-data grants
+// access to.
+func SyncBootstrap(conn db.Conn, sshKey ssh.Signer) {
+	bootstrapped := map[string]struct{}{}
+	for range conn.TriggerTick(30, db.MachineTable).C {
+		machines := conn.SelectFromMachine(nil)
+		syncBootstrapOnce(sshKey, machines, bootstrapped)
+	}
+}
+
+// syncBootstrapOnce bootstraps the machines that haven't been bootstrapped yet in
+// parallel, and adds the successful ones to `bootstrapped`.  Machines that no
+// longer exist are removed from `bootstrapped`, so it doesn't grow forever.
+func syncBootstrapOnce(sshKey ssh.Signer, machines []db.Machine,
+	bootstrapped map[string]struct{}) {
+
+	cloudIDs := map[string]struct{}{}
+	for _, m := range machines {
+		cloudIDs[m.CloudID] = struct{}{}
+	}
+	for id := range bootstrapped {
+		if _, ok := cloudIDs[id]; !ok {
+			delete(bootstrapped, id)
+		}
+	}
+
+	// The machines are picked before any are bootstrapped, as the goroutines
+	// write to `bootstrapped`.
+	var toBootstrap []db.Machine
+	for _, m := range machines {
+		_, done := bootstrapped[m.CloudID]
+		if !done && m.PublicIP != "" &&
+			bootstrapMode(m) == blueprint.SSHBootstrap {
+			toBootstrap = append(toBootstrap, m)
+		}
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, m := range toBootstrap {
+		wg.Add(1)
+		go func(m db.Machine) {
+			defer wg.Done()
+			if bootstrap(m, sshKey) {
+				mutex.Lock()
+				bootstrapped[m.CloudID] = struct{}{}
+				mutex.Unlock()
+			}
+		}(m)
+	}
+	wg.Wait()
+}
+
+// bootstrap runs the boot script on `m`.  Returns whether it was successful.
+func bootstrap(m db.Machine, sshKey ssh.Signer) bool {
+	bootstrapCounter.Inc("Bootstrap")
+
+	// Bootstrapping is retried, so machines that are still booting aren't
+	// treated as failures.
+	output, err := runSSH(m.PublicIP, sshKey, bootstrapCommand, cfg.Ubuntu(m, ""))
+	if err != nil {
+		bootstrapCounter.Inc("Bootstrap Failure")
+		log.WithError(err).WithFields(log.Fields{
+			"host":   m.PublicIP,
+			"output": output,
+		}).Debug("Failed to bootstrap machine. Retrying.")
+		return false
+	}
+
+	log.WithField("host", m.PublicIP).Info("Bootstrapped machine over SSH")
+	return true
+}
+
+// runSSHImpl runs `cmd` on `host` with `stdin` as its input, and returns its
+// combined output.
+func runSSHImpl(host string, sshKey ssh.Signer, cmd, stdin string) (
+	string, error) {
+
+	client, err := dialSSH(host, sshKey)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("session: %s", err)
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdin = strings.NewReader(stdin)
+	session.Stdout = &output
+	session.Stderr = &output
+	err = session.Run(cmd)
+	return output.String(), err
+}
+
+// Saved in a variable to allow mocking during unit testing.
+var runSSH = runSSHImpl
@@ -0,0 +1,82 @@
+package cloud
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestSyncBootstrap(t *testing.T) {
+	SSHBootstrapProviders = map[db.ProviderName]struct{}{db.Google: {}}
+	defer func() { SSHBootstrapProviders = map[db.ProviderName]struct{}{} }()
+
+	var mutex sync.Mutex
+	var ran []string
+	failHosts := map[string]bool{"4.4.4.4": true}
+	runSSH = func(host string, _ ssh.Signer, cmd, stdin string) (string, error) {
+		assert.Equal(t, bootstrapCommand, cmd)
+		assert.True(t, strings.HasPrefix(stdin, "#!/bin/bash"))
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		ran = append(ran, host)
+		if failHosts[host] {
+			return "connection refused", errors.New("dial")
+		}
+		return "", nil
+	}
+
+	machines := []db.Machine{
+		{CloudID: "1", PublicIP: "1.1.1.1", Provider: db.Amazon,
+			Bootstrap: blueprint.SSHBootstrap},
+		{CloudID: "2", PublicIP: "2.2.2.2", Provider: db.Amazon},
+		{CloudID: "3", PublicIP: "3.3.3.3", Provider: db.Google},
+		{CloudID: "4", PublicIP: "4.4.4.4", Provider: db.Google},
+		{CloudID: "5", PublicIP: "5.5.5.5", Provider: db.Google,
+			Bootstrap: blueprint.UserDataBootstrap},
+		{CloudID: "6", Provider: db.Amazon, Bootstrap: blueprint.SSHBootstrap},
+	}
+
+	bootstrapped := map[string]struct{}{}
+	syncBootstrapOnce(nil, machines, bootstrapped)
+	sort.Strings(ran)
+	assert.Equal(t, []string{"1.1.1.1", "3.3.3.3", "4.4.4.4"}, ran)
+	assert.Equal(t, map[string]struct{}{"1": {}, "3": {}}, bootstrapped)
+
+	// Only the machine that failed is retried.
+	ran = nil
+	delete(failHosts, "4.4.4.4")
+	syncBootstrapOnce(nil, machines, bootstrapped)
+	assert.Equal(t, []string{"4.4.4.4"}, ran)
+	assert.Len(t, bootstrapped, 3)
+
+	// Machines that are gone are forgotten.
+	ran = nil
+	syncBootstrapOnce(nil, machines[2:], bootstrapped)
+	assert.Empty(t, ran)
+	assert.Equal(t, map[string]struct{}{"3": {}, "4": {}}, bootstrapped)
+}
+
+func TestBootstrapMode(t *testing.T) {
+	SSHBootstrapProviders = map[db.ProviderName]struct{}{db.Google: {}}
+	defer func() { SSHBootstrapProviders = map[db.ProviderName]struct{}{} }()
+
+	assert.Equal(t, blueprint.UserDataBootstrap,
+		bootstrapMode(db.Machine{Provider: db.Amazon}))
+	assert.Equal(t, blueprint.SSHBootstrap,
+		bootstrapMode(db.Machine{Provider: db.Amazon,
+			Bootstrap: blueprint.SSHBootstrap}))
+	assert.Equal(t, blueprint.SSHBootstrap,
+		bootstrapMode(db.Machine{Provider: db.Google}))
+	assert.Equal(t, blueprint.UserDataBootstrap,
+		bootstrapMode(db.Machine{Provider: db.Google,
+			Bootstrap: blueprint.UserDataBootstrap}))
+}
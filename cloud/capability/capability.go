@@ -0,0 +1,19 @@
+package capability
+
+// Capabilities are the optional features that a cloud provider supports.  The
+// cloud plans around the features that a provider lacks, rather than asking the
+// provider for something that it can only refuse.
+type Capabilities struct {
+	// Whether machines may be assigned floating IPs after they boot.
+	FloatingIPs bool
+
+	// Whether machines may be booted as preemptible machines.
+	Preemptible bool
+
+	// Whether the disk size of machines may be chosen when they boot, rather
+	// than being fixed by their size.
+	ResizableDisks bool
+
+	// Whether machines are given IPv6 addresses.
+	IPv6 bool
+}
@@ -0,0 +1,275 @@
+// Package cassette records the HTTP interactions between Quilt and the cloud
+// providers, and replays them.  Replaying a cassette allows the provider logic to
+// be exercised against realistic responses without live credentials, so that
+// provider-specific bugs can be reproduced.
+//
+// Cassettes are selected at runtime with the QUILT_CASSETTE environment variable,
+// which is of the form "record:DIR" or "replay:DIR".  Each provider client has its
+// own cassette within DIR.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvVar is the environment variable that selects the cassette mode.
+const EnvVar = "QUILT_CASSETTE"
+
+// The cassette modes.
+const (
+	record = "record"
+	replay = "replay"
+)
+
+// An Interaction is a request made to a provider, and its response.
+type Interaction struct {
+	Request  Request
+	Response Response
+}
+
+// A Request is the part of an HTTP request that identifies it.  Headers aren't
+// recorded, as they contain credentials.
+type Request struct {
+	Method string
+	URL    string
+	Body   string `json:",omitempty"`
+}
+
+// A Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int
+	Header     http.Header `json:",omitempty"`
+	Body       string      `json:",omitempty"`
+}
+
+var c = counter.New("Cassette")
+
+// Allow mocking out the environment for the unit tests.
+var getenv = os.Getenv
+
+// Replaying returns true if provider interactions are replayed from cassettes,
+// in which case provider clients shouldn't require credentials.
+func Replaying() bool {
+	mode, _ := parseEnv()
+	return mode == replay
+}
+
+// Wrap returns a copy of `client` whose requests are recorded to, or replayed
+// from, the cassette called `name`.  If cassettes aren't enabled, `client` is
+// returned unchanged.
+func Wrap(name string, client *http.Client) *http.Client {
+	mode, dir := parseEnv()
+	path := filepath.Join(dir, name+".json")
+
+	wrapped := *client
+	switch mode {
+	case record:
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		wrapped.Transport = &recorder{path: path, base: base}
+	case replay:
+		rp, err := newReplayer(path)
+		if err != nil {
+			log.WithError(err).WithField("path", path).Error(
+				"Failed to load cassette")
+		}
+		wrapped.Transport = rp
+	default:
+		return client
+	}
+
+	log.WithFields(log.Fields{"mode": mode, "path": path}).Info(
+		"Using provider cassette")
+	return &wrapped
+}
+
+func parseEnv() (mode, dir string) {
+	env := getenv(EnvVar)
+	if env == "" {
+		return "", ""
+	}
+
+	parts := strings.SplitN(env, ":", 2)
+	if len(parts) != 2 || (parts[0] != record && parts[0] != replay) {
+		log.WithField(EnvVar, env).Warn("Malformed cassette mode. " +
+			"Expected record:DIR or replay:DIR.")
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// A recorder performs requests with `base`, and appends them to the cassette at
+// `path`.
+type recorder struct {
+	path string
+	base http.RoundTripper
+
+	sync.Mutex
+	interactions []Interaction
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	c.Inc("Record")
+	r.interactions = append(r.interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   reqBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       respBody,
+		},
+	})
+
+	// The cassette is rewritten after each interaction so that it's complete
+	// even if the daemon is killed.
+	cassetteJSON, err := json.MarshalIndent(r.interactions, "", "    ")
+	if err == nil {
+		err = util.WriteFile(r.path, cassetteJSON, 0600)
+	}
+	if err != nil {
+		log.WithError(err).WithField("path", r.path).Warn(
+			"Failed to write cassette")
+	}
+	return resp, nil
+}
+
+// A replayer responds to requests with the responses in a cassette.  Requests
+// are matched to the first unused interaction with the same method, URL, and
+// body, or failing that, with the same method and URL.  Once every matching
+// interaction has been used, the last one is replayed indefinitely, so that
+// polling the provider reaches a steady state.
+type replayer struct {
+	sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func newReplayer(path string) (*replayer, error) {
+	rp := &replayer{}
+
+	cassetteJSON, err := util.ReadFile(path)
+	if err != nil {
+		return rp, err
+	}
+
+	if err := json.Unmarshal([]byte(cassetteJSON), &rp.interactions); err != nil {
+		return rp, err
+	}
+	rp.used = make([]bool, len(rp.interactions))
+	return rp, nil
+}
+
+func (rp *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rp.Lock()
+	defer rp.Unlock()
+
+	sameURL := func(i int) bool {
+		recorded := rp.interactions[i].Request
+		return recorded.Method == req.Method && recorded.URL == req.URL.String()
+	}
+	sameBody := func(i int) bool {
+		return sameURL(i) && rp.interactions[i].Request.Body == body
+	}
+
+	match := -1
+	for _, matches := range []func(int) bool{sameBody, sameURL} {
+		for i := range rp.interactions {
+			if !rp.used[i] && matches(i) {
+				match = i
+				break
+			}
+		}
+		if match >= 0 {
+			break
+		}
+	}
+
+	if match < 0 {
+		for i := range rp.interactions {
+			if sameURL(i) {
+				match = i
+			}
+		}
+	}
+
+	if match < 0 {
+		c.Inc("Replay Miss")
+		return nil, fmt.Errorf("no recorded response for %s %s",
+			req.Method, req.URL)
+	}
+
+	c.Inc("Replay")
+	rp.used[match] = true
+	recorded := rp.interactions[match].Response
+	return &http.Response{
+		Status: fmt.Sprintf("%d %s", recorded.StatusCode,
+			http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header,
+		Body:          ioutil.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readBody reads the contents of `body`, and replaces it with an equivalent
+// reader so that it may be read again.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil {
+		return "", nil
+	}
+
+	contents, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+
+	*body = ioutil.NopCloser(bytes.NewReader(contents))
+	return string(contents), nil
+}
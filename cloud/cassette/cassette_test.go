@@ -0,0 +1,89 @@
+package cassette
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/util"
+)
+
+func TestRecordReplay(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	defer func() { getenv = os.Getenv }()
+
+	var count int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			count++
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Count", fmt.Sprint(count))
+			fmt.Fprintf(w, "%s %s %s %d", r.Method, r.URL.Path, body, count)
+		}))
+
+	getenv = func(string) string { return "record:/cassettes" }
+	client := Wrap("test", &http.Client{})
+	assert.Equal(t, "GET /list  1", get(t, client, server.URL+"/list"))
+	assert.Equal(t, "GET /list  2", get(t, client, server.URL+"/list"))
+	assert.Equal(t, "POST /boot a 3", post(t, client, server.URL+"/boot", "a"))
+	assert.Equal(t, "POST /boot b 4", post(t, client, server.URL+"/boot", "b"))
+
+	cassetteJSON, err := util.ReadFile("/cassettes/test.json")
+	assert.NoError(t, err)
+	assert.Contains(t, cassetteJSON, `"X-Count"`)
+
+	// Replaying doesn't contact the provider.
+	server.Close()
+	getenv = func(string) string { return "replay:/cassettes" }
+	assert.True(t, Replaying())
+	client = Wrap("test", &http.Client{})
+
+	// Requests with matching bodies are preferred, and the final response is
+	// repeated once the recorded responses are exhausted.
+	assert.Equal(t, "POST /boot b 4", post(t, client, server.URL+"/boot", "b"))
+	assert.Equal(t, "POST /boot a 3", post(t, client, server.URL+"/boot", "c"))
+	assert.Equal(t, "GET /list  1", get(t, client, server.URL+"/list"))
+	assert.Equal(t, "GET /list  2", get(t, client, server.URL+"/list"))
+	assert.Equal(t, "GET /list  2", get(t, client, server.URL+"/list"))
+
+	_, err = client.Get(server.URL + "/unknown")
+	assert.Error(t, err)
+}
+
+func TestWrapDisabled(t *testing.T) {
+	defer func() { getenv = os.Getenv }()
+
+	client := &http.Client{}
+	for _, env := range []string{"", "bad", "rewind:/dir"} {
+		getenv = func(string) string { return env }
+		assert.True(t, client == Wrap("test", client))
+		assert.False(t, Replaying())
+	}
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	assert.NoError(t, err)
+	return readResponse(t, resp)
+}
+
+func post(t *testing.T, client *http.Client, url, body string) string {
+	resp, err := client.Post(url, "text/plain", strings.NewReader(body))
+	assert.NoError(t, err)
+	return readResponse(t, resp)
+}
+
+func readResponse(t *testing.T, resp *http.Response) string {
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
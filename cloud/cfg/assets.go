@@ -0,0 +1,221 @@
+package cfg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// How long a machine may take to fetch its boot script after it's booted.
+const tokenTTL = time.Hour
+
+// ErrInvalidToken is returned when redeeming a token that wasn't issued by this
+// daemon's asset server, has expired, or has already been redeemed.
+var ErrInvalidToken = errors.New("invalid token")
+
+// The daemon's asset server, from which machines fetch their boot script.  If
+// the URL is empty, the boot script is passed in the user-data instead.
+//
+// Tokens are authenticated with an HMAC keyed by the daemon's CA, rather than
+// being remembered, so the tokens in the user-data of machines that are still
+// booting remain valid if the daemon restarts.  Redeemed tokens are remembered
+// until they expire, in memory and in the file at `redeemedPath`, so that each
+// may only be used once, even across restarts.
+var assets struct {
+	sync.Mutex
+	url, pin     string
+	key          []byte
+	redeemedPath string
+	redeemed     map[string]time.Time
+}
+
+// Allow mocking out for the unit tests.
+var now = time.Now
+
+// A Token identifies the machine that a boot token was issued to.
+type Token struct {
+	Role        db.Role
+	BlueprintID string
+}
+
+// EnableAssets makes the user-data of subsequently booted machines fetch their
+// boot script from the asset server at `url`, rather than contain it.  `pin` is
+// the base64 encoded SHA-256 hash of the server's public key, which machines
+// use to authenticate the server.  `key` authenticates the tokens that machines
+// present, and must be the same each time the daemon starts.  The tokens that
+// have been redeemed are stored at `redeemedPath`, if it isn't empty.
+func EnableAssets(url, pin string, key []byte, redeemedPath string) {
+	assets.Lock()
+	defer assets.Unlock()
+	assets.url = url
+	assets.pin = pin
+	assets.key = key
+	assets.redeemedPath = redeemedPath
+	assets.redeemed = readRedeemed(redeemedPath)
+}
+
+// Redeem calls `fn` with the machine that `token` was issued to.  If `fn`
+// succeeds, the token is consumed so that it can't be used again.
+func Redeem(token string, fn func(Token) error) error {
+	assets.Lock()
+	defer assets.Unlock()
+
+	parsed, expires, ok := parseToken(token)
+	if !ok || now().After(expires) {
+		return ErrInvalidToken
+	}
+
+	if _, ok := assets.redeemed[token]; ok {
+		return ErrInvalidToken
+	}
+
+	if err := fn(parsed); err != nil {
+		return err
+	}
+
+	for redeemed, expiry := range assets.redeemed {
+		if now().After(expiry) {
+			delete(assets.redeemed, redeemed)
+		}
+	}
+	assets.redeemed[token] = expires
+	writeRedeemed()
+	return nil
+}
+
+// readRedeemed returns the redeemed tokens stored at `path`, keyed by token, with
+// their expiry times.  A missing or corrupt file is treated as empty.
+func readRedeemed(path string) map[string]time.Time {
+	redeemed := map[string]time.Time{}
+	if path == "" {
+		return redeemed
+	}
+
+	contents, err := util.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).WithField("path", path).Warn(
+				"Failed to read redeemed boot tokens")
+		}
+		return redeemed
+	}
+
+	if err := json.Unmarshal([]byte(contents), &redeemed); err != nil {
+		log.WithError(err).WithField("path", path).Warn(
+			"Failed to parse redeemed boot tokens")
+	}
+	return redeemed
+}
+
+// writeRedeemed stores the redeemed tokens at the redeemed path.  The caller must
+// hold the assets lock.
+func writeRedeemed() {
+	if assets.redeemedPath == "" {
+		return
+	}
+
+	contents, err := json.Marshal(assets.redeemed)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := util.WriteFile(assets.redeemedPath, contents, 0600); err != nil {
+		log.WithError(err).WithField("path", assets.redeemedPath).Warn(
+			"Failed to store redeemed boot tokens")
+	}
+}
+
+// fetchUserData returns user-data that fetches the boot script for `m` from the
+// asset server, or false if the asset server isn't enabled.
+func fetchUserData(m db.Machine) (string, bool) {
+	assets.Lock()
+	defer assets.Unlock()
+
+	if assets.url == "" {
+		return "", false
+	}
+
+	t := template.Must(template.New("fetch").Parse(fetchTemplate))
+
+	var userData bytes.Buffer
+	err := t.Execute(&userData, struct {
+		URL, Pin, Token string
+	}{
+		URL:   assets.url,
+		Pin:   assets.pin,
+		Token: issueToken(m.Role, m.BlueprintID),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return userData.String(), true
+}
+
+// issueToken returns a new token that may be redeemed for the boot script of the
+// machine with `role` and `blueprintID`.  A token is a random nonce, its expiry
+// time, the role, and the hex encoded blueprint ID, followed by their HMAC.  The
+// caller must hold the assets lock.
+func issueToken(role db.Role, blueprintID string) string {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+
+	payload := fmt.Sprintf("%x.%d.%s.%x", nonce, now().Add(tokenTTL).Unix(), role,
+		blueprintID)
+	return payload + "." + tokenMAC(payload)
+}
+
+// parseToken returns the machine that `token` was issued to and its expiry, or
+// false if it wasn't issued with the current key.  The caller must hold the
+// assets lock.
+func parseToken(token string) (Token, time.Time, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]),
+		[]byte(tokenMAC(token[:i]))) {
+		return Token{}, time.Time{}, false
+	}
+
+	fields := strings.Split(token[:i], ".")
+	if len(fields) != 4 {
+		return Token{}, time.Time{}, false
+	}
+
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return Token{}, time.Time{}, false
+	}
+
+	role, err := db.ParseRole(fields[2])
+	if err != nil {
+		return Token{}, time.Time{}, false
+	}
+
+	blueprintID, err := hex.DecodeString(fields[3])
+	if err != nil {
+		return Token{}, time.Time{}, false
+	}
+	return Token{role, string(blueprintID)}, time.Unix(expires, 0), true
+}
+
+func tokenMAC(payload string) string {
+	mac := hmac.New(sha256.New, assets.key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package cfg
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+var tokenRegex = regexp.MustCompile(`Bearer ([0-9a-zA-Z.]+)`)
+
+func TestAssets(t *testing.T) {
+	defer EnableAssets("", "", nil, "")
+	cfgTemplate = "boot script"
+
+	// Without the asset server, tokens aren't issued.
+	EnableAssets("", "", nil, "")
+	m := db.Machine{Provider: db.Amazon, Role: db.Worker, BlueprintID: "bp"}
+	assert.Equal(t, "boot script", UserData(m, ""))
+
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "")
+	userData := UserData(m, "")
+	assert.Contains(t, userData, `--pinnedpubkey "sha256//pin"`)
+	assert.Contains(t, userData, `"https://8.8.8.8:9001/boot.sh"`)
+	assert.True(t, UserDataFits(m))
+
+	token := tokenRegex.FindStringSubmatch(userData)[1]
+
+	// Failed redemptions don't consume the token.
+	err := Redeem(token, func(Token) error { return errors.New("retry") })
+	assert.EqualError(t, err, "retry")
+
+	var redeemed Token
+	assert.NoError(t, Redeem(token, func(tok Token) error {
+		redeemed = tok
+		return nil
+	}))
+	assert.Equal(t, Token{db.Worker, "bp"}, redeemed)
+
+	noop := func(Token) error { return nil }
+	assert.Equal(t, ErrInvalidToken, Redeem(token, noop))
+	assert.Equal(t, ErrInvalidToken, Redeem("unknown", noop))
+
+	// Machines bootstrapped over SSH don't fetch their boot script.
+	m.Bootstrap = blueprint.SSHBootstrap
+	assert.NotContains(t, UserData(m, ""), "Bearer")
+}
+
+func TestTokenRestart(t *testing.T) {
+	defer EnableAssets("", "", nil, "")
+	noop := func(Token) error { return nil }
+
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "")
+	assets.Lock()
+	token := issueToken(db.Master, "bp")
+	assets.Unlock()
+
+	// Tokens remain valid when the daemon restarts with the same key.
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "")
+	var redeemed Token
+	assert.NoError(t, Redeem(token, func(tok Token) error {
+		redeemed = tok
+		return nil
+	}))
+	assert.Equal(t, Token{db.Master, "bp"}, redeemed)
+
+	// Tokens issued with other keys, or that were tampered with, are rejected.
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("other"), "")
+	assert.Equal(t, ErrInvalidToken, Redeem(token, noop))
+
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "")
+	assets.Lock()
+	token = issueToken(db.Worker, "bp")
+	assets.Unlock()
+	forged := regexp.MustCompile(`\.Worker\.`).ReplaceAllString(token, ".Master.")
+	assert.Equal(t, ErrInvalidToken, Redeem(forged, noop))
+
+	// Nor can a token be moved to another machine.
+	forged = regexp.MustCompile(`\.6270\.`).ReplaceAllString(token, ".6271.")
+	assert.NotEqual(t, token, forged)
+	assert.Equal(t, ErrInvalidToken, Redeem(forged, noop))
+}
+
+func TestRedeemedRestart(t *testing.T) {
+	defer EnableAssets("", "", nil, "")
+	util.AppFs = afero.NewMemMapFs()
+	noop := func(Token) error { return nil }
+
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "/redeemed")
+	assets.Lock()
+	token := issueToken(db.Worker, "bp")
+	assets.Unlock()
+	assert.NoError(t, Redeem(token, noop))
+
+	// Redeemed tokens can't be replayed once the daemon restarts.
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "/redeemed")
+	assert.Equal(t, ErrInvalidToken, Redeem(token, noop))
+
+	// Corrupt files don't stop new tokens from being redeemed.
+	assert.NoError(t, util.WriteFile("/redeemed", []byte("corrupt"), 0600))
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "/redeemed")
+	assets.Lock()
+	token = issueToken(db.Worker, "bp")
+	assets.Unlock()
+	assert.NoError(t, Redeem(token, noop))
+}
+
+func TestTokenExpiry(t *testing.T) {
+	defer EnableAssets("", "", nil, "")
+	defer func() { now = time.Now }()
+
+	start := time.Now()
+	now = func() time.Time { return start }
+	EnableAssets("https://8.8.8.8:9001", "pin", []byte("key"), "")
+
+	assets.Lock()
+	token := issueToken(db.Worker, "bp")
+	assets.Unlock()
+	assert.NoError(t, Redeem(token, func(Token) error { return nil }))
+
+	now = func() time.Time { return start.Add(2 * tokenTTL) }
+	assert.Equal(t, ErrInvalidToken, Redeem(token,
+		func(Token) error { return nil }))
+
+	// Expired tokens are forgotten when other tokens are redeemed.
+	assets.Lock()
+	token = issueToken(db.Worker, "bp")
+	assets.Unlock()
+	assert.NoError(t, Redeem(token, func(Token) error { return nil }))
+	assets.Lock()
+	assert.Len(t, assets.redeemed, 1)
+	assets.Unlock()
+}
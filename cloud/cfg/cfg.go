@@ -2,15 +2,22 @@ package cfg
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/kelda/kelda/blueprint"
 	tlsIO "github.com/kelda/kelda/connection/tls/io"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/recorder"
+	"github.com/kelda/kelda/util"
 	"github.com/kelda/kelda/version"
-
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -20,12 +27,24 @@ const (
 // Allow mocking out for the unit tests.
 var ver = version.Version
 
+// Mirror is the registry from which machines pull images, if they don't have
+// internet access.  When set, the boot script doesn't access the internet, so the
+// machines' image must be configured with a package mirror that carries Docker.
+// Set by the daemon.
+var Mirror string
+
 // Ubuntu generates a cloud config file for the Ubuntu operating system with the
 // corresponding `version`.
 func Ubuntu(m db.Machine, inboundPublic string) string {
 	t := template.Must(template.New("cloudConfig").Parse(cfgTemplate))
 
-	img := fmt.Sprintf("%s:%s", quiltImage, ver)
+	// ARM machines run the ARM build of the Quilt image.
+	arch := blueprint.AMD64
+	if m.Arch == blueprint.ARM64 {
+		arch = blueprint.ARM64
+	}
+	img := util.MirrorImage(Mirror, util.ArchImage(
+		fmt.Sprintf("%s:%s", quiltImage, ver), arch))
 
 	// Mount the TLSDir as a read-only host volume. This is necessary for
 	// the minion container to access the TLS certificates copied by
@@ -39,12 +58,24 @@ func Ubuntu(m db.Machine, inboundPublic string) string {
 		LogLevel   string
 		MinionOpts string
 		DockerOpts string
+		Sysctls    string
+		Mirror     string
+		Hostname   string
+		Arch       string
+
+		CustomImage bool
 	}{
 		QuiltImage: img,
 		SSHKeys:    strings.Join(m.SSHKeys, "\n"),
-		LogLevel:   log.GetLevel().String(),
+		LogLevel:   util.LogLevel().String(),
 		MinionOpts: minionOptions(m.Role, inboundPublic),
 		DockerOpts: dockerOpts,
+		Sysctls:    sysctlConf(m),
+		Mirror:     Mirror,
+		Hostname:   m.Hostname,
+		Arch:       arch,
+
+		CustomImage: m.Image != "",
 	})
 	if err != nil {
 		panic(err)
@@ -53,11 +84,171 @@ func Ubuntu(m db.Machine, inboundPublic string) string {
 	return cloudConfigBytes.String()
 }
 
+// The maximum size of the user-data accepted by each provider whose user-data is
+// run by cloud-init.  Other providers run their user-data directly, so it can't
+// be compressed, and their limits are large enough for the boot script.
+var userDataLimits = map[db.ProviderName]int{
+	db.Amazon:       16 * 1024,
+	db.Azure:        64*1024 - 1,
+	db.DigitalOcean: 64 * 1024,
+	db.OpenStack:    65535 / 4 * 3,     // The limit applies once base64 encoded.
+	db.Alibaba:      16 * 1024 / 4 * 3, // Also limited once base64 encoded.
+	db.IBM:          64 * 1024,
+}
+
+// The providers whose user-data may be compressed.  Their APIs base64 encode the
+// user-data, so it may hold arbitrary bytes.  The other providers send it as a
+// JSON string, which can't carry the gzipped bytes intact, so their boot scripts
+// that exceed the limit are bootstrapped over SSH instead.
+var binaryUserData = map[db.ProviderName]bool{
+	db.Amazon:    true,
+	db.Azure:     true,
+	db.OpenStack: true,
+	db.Alibaba:   true,
+}
+
+// The boundary between the parts of multipart user-data.  It's fixed so that the
+// size of the encoded user-data is deterministic.
+const mimeBoundary = "==QuiltUserData=="
+
+// UserData generates the user-data that providers pass to `m` when it boots.
+// For machines bootstrapped over SSH, this only grants the Quilt SSH keys access,
+// and the boot script generated by Ubuntu is run later by the daemon.  If the
+// asset server is enabled, the user-data fetches the boot script from it.
+// Otherwise, boot scripts that exceed the user-data limit of a provider that
+// accepts binary user-data are encoded as gzipped multipart MIME, which
+// cloud-init expands.
+func UserData(m db.Machine, inboundPublic string) string {
+	if m.Bootstrap == blueprint.SSHBootstrap {
+		return sshBootstrap(m)
+	}
+
+	if userData, ok := fetchUserData(m); ok {
+		return userData
+	}
+
+	userData, _ := encode(m.Provider, Ubuntu(m, inboundPublic))
+	return userData
+}
+
+// UserDataFits returns whether the boot script for `m` fits within its
+// provider's user-data limit, after being encoded.  Machines whose boot script
+// doesn't fit must be bootstrapped over SSH.
+func UserDataFits(m db.Machine) bool {
+	assets.Lock()
+	fetched := assets.url != ""
+	assets.Unlock()
+	if fetched {
+		return true
+	}
+
+	_, ok := encode(m.Provider, Ubuntu(m, ""))
+	return ok
+}
+
+// encode compresses `script` if it exceeds the user-data limit of `provider`,
+// and the provider accepts binary user-data.  Returns false if it doesn't fit.
+func encode(provider db.ProviderName, script string) (string, bool) {
+	limit, ok := userDataLimits[provider]
+	if !ok || len(script) <= limit {
+		return script, true
+	}
+
+	if !binaryUserData[provider] {
+		return script, false
+	}
+
+	compressed, err := gzipMultipart(script)
+	if err != nil {
+		panic(err)
+	}
+	return compressed, len(compressed) <= limit
+}
+
+// gzipMultipart wraps `script` in a multipart MIME message, and gzips it.
+// cloud-init detects the compression and decompresses it before running the
+// parts.
+func gzipMultipart(script string) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+
+	mw := multipart.NewWriter(gz)
+	if err := mw.SetBoundary(mimeBoundary); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(gz, "Content-Type: multipart/mixed; boundary=%q\r\n"+
+		"MIME-Version: 1.0\r\n\r\n", mimeBoundary)
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {`text/x-shellscript; charset="us-ascii"`},
+		"Content-Disposition": {`attachment; filename="quilt-boot.sh"`},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.WriteString(part, script); err != nil {
+		return "", err
+	}
+
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func sshBootstrap(m db.Machine) string {
+	t := template.Must(template.New("sshBootstrap").Parse(sshBootstrapTemplate))
+
+	var userData bytes.Buffer
+	err := t.Execute(&userData, struct{ SSHKeys string }{
+		SSHKeys: strings.Join(m.SSHKeys, "\n"),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return userData.String()
+}
+
 func minionOptions(role db.Role, inboundPublic string) string {
 	options := fmt.Sprintf("--role %q", role)
 
 	if inboundPublic != "" {
 		options += fmt.Sprintf(" --inbound-pub-intf %q", inboundPublic)
 	}
+
+	if Mirror != "" {
+		options += fmt.Sprintf(" --mirror %q", Mirror)
+	}
+
+	// Machines record their decisions if the daemon records its own.
+	if recorder.Enabled {
+		options += " --flight-recorder"
+	}
 	return options
 }
+
+// sysctlConf generates the contents of a sysctl.d configuration file that
+// applies the kernel settings requested for `m`. The lines are joined with
+// a tab so that they line up with the heredoc in the boot script.
+func sysctlConf(m db.Machine) string {
+	sysctls := map[string]string{}
+	for key, val := range m.Sysctls {
+		sysctls[key] = val
+	}
+
+	if m.Hugepages != 0 {
+		sysctls["vm.nr_hugepages"] = strconv.Itoa(m.Hugepages)
+	}
+
+	var lines []string
+	for key, val := range sysctls {
+		lines = append(lines, fmt.Sprintf("%s = %s", key, val))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n\t")
+}
@@ -1,18 +1,105 @@
 package cfg
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
 	"testing"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/recorder"
+	"github.com/kelda/kelda/util"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 )
 
+// The boot script template, before it's mocked out by the tests.
+var realCfgTemplate = cfgTemplate
+
+func TestMirror(t *testing.T) {
+	defer func() { Mirror = "" }()
+	cfgTemplate = realCfgTemplate
+	ver = "1.2.3"
+
+	res := Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.Contains(t, res, "quilt/quilt:1.2.3")
+	assert.Contains(t, res, "download.docker.com")
+	assert.NotContains(t, res, "--mirror")
+
+	Mirror = "mirror:5000"
+	res = Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.Contains(t, res, "mirror:5000/quilt/quilt:1.2.3")
+	assert.Contains(t, res, `--mirror "mirror:5000"`)
+	assert.Contains(t, res, "--insecure-registry mirror:5000 ")
+	assert.NotContains(t, res, "download.docker.com")
+	assert.NotContains(t, res, "add-apt-repository")
+}
+
+func TestFlightRecorder(t *testing.T) {
+	defer func() { recorder.Enabled = false }()
+	cfgTemplate = realCfgTemplate
+
+	res := Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.NotContains(t, res, "--flight-recorder")
+
+	recorder.Enabled = true
+	res = Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.Contains(t, res, "--flight-recorder")
+}
+
+func TestHostname(t *testing.T) {
+	cfgTemplate = realCfgTemplate
+
+	res := Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.NotContains(t, res, "hostname")
+
+	res = Ubuntu(db.Machine{Role: db.Worker, Hostname: "worker-1"}, "")
+	assert.Contains(t, res, "hostnamectl set-hostname worker-1\n")
+	assert.Contains(t, res, "\nconfigure_hostname\n")
+}
+
+func TestArch(t *testing.T) {
+	defer func() { Mirror = "" }()
+	cfgTemplate = realCfgTemplate
+	ver = "1.2.3"
+
+	res := Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.NotContains(t, res, "-arm64")
+	assert.Contains(t, res, "deb [arch=amd64]")
+
+	res = Ubuntu(db.Machine{Role: db.Worker, Arch: blueprint.ARM64}, "")
+	assert.Contains(t, res, "quilt/quilt:1.2.3-arm64")
+	assert.Contains(t, res, "deb [arch=arm64]")
+
+	Mirror = "mirror:5000"
+	res = Ubuntu(db.Machine{Role: db.Worker, Arch: blueprint.ARM64}, "")
+	assert.Contains(t, res, "mirror:5000/quilt/quilt:1.2.3-arm64")
+}
+
+func TestCustomImage(t *testing.T) {
+	cfgTemplate = realCfgTemplate
+
+	res := Ubuntu(db.Machine{Role: db.Worker}, "")
+	assert.NotContains(t, res, "/etc/quilt/prebaked")
+	assert.Contains(t, res, "\ninstall_docker\n")
+
+	res = Ubuntu(db.Machine{Role: db.Worker, Image: "ami-1234"}, "")
+	assert.Contains(t, res, "if [ -e /etc/quilt/prebaked ] ; then\n")
+	assert.Contains(t, res, "\n\tinstall_docker\nfi\n")
+}
+
 func TestCloudConfig(t *testing.T) {
 	cfgTemplate = "({{.QuiltImage}}) ({{.SSHKeys}}) " +
 		"({{.MinionOpts}}) ({{.LogLevel}}) ({{.DockerOpts}})"
 
-	log.SetLevel(log.InfoLevel)
+	util.SetLogLevel(log.InfoLevel)
 	ver = "master"
 	res := Ubuntu(db.Machine{
 		SSHKeys: []string{"a", "b"},
@@ -24,7 +111,7 @@ func TestCloudConfig(t *testing.T) {
 		t.Errorf("res: %s\nexp: %s", res, exp)
 	}
 
-	log.SetLevel(log.DebugLevel)
+	util.SetLogLevel(log.DebugLevel)
 	ver = "1.2.3"
 	res = Ubuntu(db.Machine{
 		SSHKeys: []string{"a", "b"},
@@ -37,3 +124,130 @@ func TestCloudConfig(t *testing.T) {
 		t.Errorf("res: %s\nexp: %s", res, exp)
 	}
 }
+
+func TestUserData(t *testing.T) {
+	cfgTemplate = "({{.SSHKeys}}) ({{.MinionOpts}})"
+	sshBootstrapTemplate = "ssh ({{.SSHKeys}})"
+
+	m := db.Machine{SSHKeys: []string{"a", "b"}, Role: db.Worker}
+	assert.Equal(t, "(a\nb) (--role \"Worker\")", UserData(m, ""))
+
+	m.Bootstrap = blueprint.UserDataBootstrap
+	assert.Equal(t, "(a\nb) (--role \"Worker\")", UserData(m, ""))
+
+	m.Bootstrap = blueprint.SSHBootstrap
+	assert.Equal(t, "ssh (a\nb)", UserData(m, ""))
+}
+
+func TestEncode(t *testing.T) {
+	defer func(limits map[db.ProviderName]int) {
+		userDataLimits = limits
+	}(userDataLimits)
+	userDataLimits = map[db.ProviderName]int{db.Amazon: 256}
+
+	// Scripts within the limit, or for providers without limits, are unchanged.
+	small := "#!/bin/bash\necho hello\n"
+	userData, ok := encode(db.Amazon, small)
+	assert.True(t, ok)
+	assert.Equal(t, small, userData)
+
+	large := "#!/bin/bash\n" + strings.Repeat("echo hello\n", 100)
+	userData, ok = encode(db.Google, large)
+	assert.True(t, ok)
+	assert.Equal(t, large, userData)
+
+	userData, ok = encode(db.Amazon, large)
+	assert.True(t, ok)
+	assert.True(t, len(userData) <= 256)
+
+	gz, err := gzip.NewReader(strings.NewReader(userData))
+	assert.NoError(t, err)
+	msg, err := mail.ReadMessage(gz)
+	assert.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, `text/x-shellscript; charset="us-ascii"`,
+		part.Header.Get("Content-Type"))
+	script, err := ioutil.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(script))
+
+	// Scripts that don't compress enough don't fit.
+	random := make([]byte, 512)
+	rand.Read(random)
+	_, ok = encode(db.Amazon, string(random))
+	assert.False(t, ok)
+}
+
+func TestEncodeJSON(t *testing.T) {
+	defer func(limits map[db.ProviderName]int) {
+		userDataLimits = limits
+	}(userDataLimits)
+	userDataLimits = map[db.ProviderName]int{db.DigitalOcean: 256}
+
+	// DigitalOcean sends the user-data as a JSON string, which would corrupt
+	// gzipped bytes, so large scripts are left as is and don't fit.
+	large := "#!/bin/bash\n" + strings.Repeat("echo hello\n", 100)
+	userData, ok := encode(db.DigitalOcean, large)
+	assert.False(t, ok)
+	assert.Equal(t, large, userData)
+
+	js, err := json.Marshal(struct{ UserData string }{userData})
+	assert.NoError(t, err)
+	var decoded struct{ UserData string }
+	assert.NoError(t, json.Unmarshal(js, &decoded))
+	assert.Equal(t, userData, decoded.UserData)
+}
+
+func TestEncodeIBM(t *testing.T) {
+	// IBM also sends the user-data as a JSON string, so it's held to its real
+	// limit without being compressed.
+	large := "#!/bin/bash\n" + strings.Repeat("echo hello\n", 7000)
+	assert.True(t, len(large) > userDataLimits[db.IBM])
+	userData, ok := encode(db.IBM, large)
+	assert.False(t, ok)
+	assert.Equal(t, large, userData)
+
+	small := "#!/bin/bash\necho hello\n"
+	userData, ok = encode(db.IBM, small)
+	assert.True(t, ok)
+
+	js, err := json.Marshal(map[string]string{"user_data": userData})
+	assert.NoError(t, err)
+	var decoded map[string]string
+	assert.NoError(t, json.Unmarshal(js, &decoded))
+	assert.Equal(t, small, decoded["user_data"])
+}
+
+func TestUserDataFits(t *testing.T) {
+	defer func(limits map[db.ProviderName]int) {
+		userDataLimits = limits
+	}(userDataLimits)
+	userDataLimits = map[db.ProviderName]int{db.Amazon: 8}
+
+	cfgTemplate = "{{.SSHKeys}}"
+	assert.True(t, UserDataFits(db.Machine{Provider: db.Amazon}))
+	assert.True(t, UserDataFits(db.Machine{Provider: db.Google,
+		SSHKeys: []string{"a long ssh key"}}))
+	assert.False(t, UserDataFits(db.Machine{Provider: db.Amazon,
+		SSHKeys: []string{"a long ssh key"}}))
+}
+
+func TestSysctlConf(t *testing.T) {
+	assert.Equal(t, "", sysctlConf(db.Machine{}))
+
+	res := sysctlConf(db.Machine{
+		Sysctls: map[string]string{
+			"net.core.somaxconn": "1024",
+			"vm.swappiness":      "1",
+		},
+		Hugepages: 512,
+	})
+	exp := "net.core.somaxconn = 1024\n\tvm.nr_hugepages = 512\n\tvm.swappiness = 1"
+	assert.Equal(t, exp, res)
+}
@@ -30,6 +30,24 @@ initialize_ovs() {
 	EOF
 }
 
+configure_kernel() {
+	cat <<- 'EOF' > /etc/sysctl.d/60-quilt.conf
+	{{.Sysctls}}
+	EOF
+	sysctl --system
+}
+
+{{- if .Hostname}}
+
+# cloud-init is told to preserve the hostname, as it otherwise resets it to the
+# provider's default when the machine reboots.
+configure_hostname() {
+	hostnamectl set-hostname {{.Hostname}}
+	echo "127.0.1.1 {{.Hostname}}" >> /etc/hosts
+	echo "preserve_hostname: true" > /etc/cloud/cloud.cfg.d/99-quilt-hostname.cfg
+}
+{{- end}}
+
 initialize_docker() {
 	mkdir -p /etc/systemd/system/docker.service.d
 
@@ -41,7 +59,7 @@ initialize_docker() {
 	# The below empty ExecStart deletes the official one installed by docker daemon.
 	ExecStart=
 	ExecStart=/usr/bin/dockerd --ip-forward=false --bridge=none \
-	--insecure-registry 10.0.0.0/8 --insecure-registry 172.16.0.0/12 --insecure-registry 192.168.0.0/16 \
+	--insecure-registry 10.0.0.0/8 --insecure-registry 172.16.0.0/12 --insecure-registry 192.168.0.0/16{{if .Mirror}} --insecure-registry {{.Mirror}}{{end}} \
 	-H unix:///var/run/docker.sock
 
 
@@ -66,7 +84,9 @@ initialize_minion() {
 	-v /var/run/docker.sock:/var/run/docker.sock \
 	-v /etc/ssl/certs/ca-certificates.crt:/etc/ssl/certs/ca-certificates.crt \
 	-v /home/quilt/.ssh:/home/quilt/.ssh:rw \
-	-v /run/docker:/run/docker:rw {{.DockerOpts}} {{.QuiltImage}} \
+	-v /run/docker:/run/docker:rw \
+	-v /var/lib/quilt:/var/lib/quilt:rw \
+	-v /:/hostfs:ro {{.DockerOpts}} {{.QuiltImage}} \
 	quilt -l {{.LogLevel}} minion {{.MinionOpts}}
 	Restart=on-failure
 
@@ -76,6 +96,12 @@ initialize_minion() {
 }
 
 install_docker() {
+{{- if .Mirror}}
+	# The machine doesn't have internet access, so Docker is installed from the
+	# package mirror configured in the machine's image.
+	apt-get update
+	apt-get install docker-ce=17.06.0~ce-0~ubuntu -y
+{{- else}}
 	# The expected key is documented by Docker here:
 	# https://docs.docker.com/engine/installation/linux/docker-ce/ubuntu/#install-using-the-repository
 	curl -fsSL https://download.docker.com/linux/ubuntu/gpg | apt-key add -
@@ -87,30 +113,14 @@ install_docker() {
 	    exit 1
 	fi
 
-	add-apt-repository "deb [arch=amd64] https://download.docker.com/linux/ubuntu $(lsb_release -cs) stable"
+	add-apt-repository "deb [arch={{.Arch}}] https://download.docker.com/linux/ubuntu $(lsb_release -cs) stable"
 	apt-get update
 	apt-get install docker-ce=17.06.0~ce-0~ubuntu -y
+{{- end}}
 	systemctl stop docker.service
 }
 
-setup_user() {
-	user=$1
-	ssh_keys=$2
-	sudo groupadd $user
-	sudo useradd $user -s /bin/bash -g $user
-	sudo usermod -aG sudo $user
-
-	user_dir=/home/$user
-
-	# Create dirs and files with correct users and permissions
-	install -d -o $user -m 744 $user_dir
-	install -d -o $user -m 700 $user_dir/.ssh
-	install -o $user -m 600 /dev/null $user_dir/.ssh/authorized_keys
-	printf "$ssh_keys" >> $user_dir/.ssh/authorized_keys
-	printf "$user ALL = (ALL) NOPASSWD: ALL\n" >> /etc/sudoers
-}
-
-echo -n "Start Boot Script: " >> /var/log/bootscript.log
+` + setupUser + `echo -n "Start Boot Script: " >> /var/log/bootscript.log
 date >> /var/log/bootscript.log
 
 export DEBIAN_FRONTEND=noninteractive
@@ -121,7 +131,21 @@ setup_user quilt "$ssh_keys"
 sudo mkdir /run/docker/plugins
 sudo chmod -R /run/docker/plugins 0755
 
+{{- if .CustomImage}}
+# Images prebaked for Quilt already have Docker installed, and mark themselves
+# so that the boot script doesn't install it again.
+if [ -e /etc/quilt/prebaked ] ; then
+	echo "Prebaked image, skipping Docker installation" >> /var/log/bootscript.log
+else
+	install_docker
+fi
+{{- else}}
 install_docker
+{{- end}}
+configure_kernel
+{{- if .Hostname}}
+configure_hostname
+{{- end}}
 initialize_ovs
 initialize_docker
 initialize_minion
@@ -141,3 +165,52 @@ systemctl restart {docker,ovs,minion}.service
 echo -n "Completed Boot Script: " >> /var/log/bootscript.log
 date >> /var/log/bootscript.log
     `
+
+// sshBootstrapTemplate is passed to the provider in place of the boot script for
+// machines that are bootstrapped over SSH.  It only grants access to the Quilt
+// SSH keys, so it's small enough for any provider's user-data limit.
+var sshBootstrapTemplate = `#!/bin/bash
+
+` + setupUser + `ssh_keys="{{.SSHKeys}}"
+setup_user quilt "$ssh_keys"
+`
+
+// fetchTemplate is passed to the provider in place of the boot script when the
+// daemon's asset server is enabled.  The server is authenticated by pinning its
+// public key, as its certificate isn't signed by a CA that the machine trusts.
+// The token identifies the machine, and the server checks that the request comes
+// from one of the machine's addresses.  Fetching is retried, because the server
+// refuses to serve the boot script until the daemon has learned the machine's IPs
+// from the provider.
+var fetchTemplate = `#!/bin/bash
+
+umask 077
+until curl -fsS --insecure --pinnedpubkey "sha256//{{.Pin}}" \
+	-H "Authorization: Bearer {{.Token}}" \
+	-o /tmp/quilt-boot.sh "{{.URL}}/boot.sh"; do
+	sleep 5
+done
+umask 022
+bash /tmp/quilt-boot.sh
+`
+
+// setupUser defines a bash function that creates a user with passwordless sudo,
+// and the given authorized SSH keys.
+const setupUser = `setup_user() {
+	user=$1
+	ssh_keys=$2
+	sudo groupadd $user
+	sudo useradd $user -s /bin/bash -g $user
+	sudo usermod -aG sudo $user
+
+	user_dir=/home/$user
+
+	# Create dirs and files with correct users and permissions
+	install -d -o $user -m 744 $user_dir
+	install -d -o $user -m 700 $user_dir/.ssh
+	install -o $user -m 600 /dev/null $user_dir/.ssh/authorized_keys
+	printf "$ssh_keys" >> $user_dir/.ssh/authorized_keys
+	printf "$user ALL = (ALL) NOPASSWD: ALL\n" >> /etc/sudoers
+}
+
+`
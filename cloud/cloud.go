@@ -3,19 +3,37 @@ package cloud
 import (
 	"errors"
 	"fmt"
+	"net"
 	"time"
 
+	"github.com/kelda/kelda/api"
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/alibaba"
 	"github.com/kelda/kelda/cloud/amazon"
+	"github.com/kelda/kelda/cloud/azure"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
 	"github.com/kelda/kelda/cloud/digitalocean"
+	"github.com/kelda/kelda/cloud/fake"
 	"github.com/kelda/kelda/cloud/foreman"
 	"github.com/kelda/kelda/cloud/google"
+	"github.com/kelda/kelda/cloud/hooks"
+	"github.com/kelda/kelda/cloud/ibm"
+	"github.com/kelda/kelda/cloud/openstack"
+	"github.com/kelda/kelda/cloud/quota"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/scaleway"
+	"github.com/kelda/kelda/cloud/static"
 	"github.com/kelda/kelda/cloud/vagrant"
 	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/health"
 	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/recorder"
 	"github.com/kelda/kelda/util"
 	log "github.com/sirupsen/logrus"
 )
@@ -30,10 +48,24 @@ type provider interface {
 	SetACLs([]acl.ACL) error
 
 	UpdateFloatingIPs([]db.Machine) error
+
+	Reboot([]db.Machine) error
+
+	Resources() ([]resource.Resource, error)
+
+	Capabilities() capability.Capabilities
+}
+
+// A quotaProvider is a provider that can report its account's quotas, so that
+// machines that would exceed them aren't booted.
+type quotaProvider interface {
+	Quotas() ([]quota.Quota, error)
 }
 
 var c = counter.New("Cloud")
 
+var rec = recorder.New("Cloud")
+
 type cloud struct {
 	conn db.Conn
 
@@ -41,22 +73,66 @@ type cloud struct {
 	providerName db.ProviderName
 	region       string
 	provider     provider
+
+	// The database machine of each cloud machine, keyed by CloudID.  The engine
+	// removes a machine's database row before the machine is stopped, so this is
+	// how its pre-stop hooks are found.
+	dbMachines map[string]db.Machine
+
+	// The most recent successful resolution of each DNS name in the admin ACL,
+	// so that a failed lookup doesn't lock admins out.
+	resolvedACLs map[string][]string
+
+	// The number of consecutive times that each preemptible machine, keyed by
+	// BlueprintID, failed to boot or was reclaimed before its minion
+	// connected.  They're keyed by BlueprintID because the database rows of
+	// reclaimed machines are replaced.
+	preemptibleFailures map[string]int
 }
 
+// The number of consecutive preemptible failures after which machines with
+// OnDemandFallback are booted on-demand instead.
+const maxPreemptibleFailures = 3
+
+// Admin ACL entries that refer to the IP of the machine running the daemon, and
+// of the API client that deployed the blueprint.
+const (
+	localACL  = "local"
+	clientACL = "client"
+)
+
+// The name of the thread that runs the foreman, as reported to the health
+// checks.
+const foremanThread = "foreman"
+
 var myIP = util.MyIP
+var lookupHost = net.LookupHost
 var sleep = time.Sleep
+var runHooks = hooks.RunAll
 
 // Run continually checks 'conn' for cloud changes and recreates the cloud as
-// needed.
-func Run(conn db.Conn, creds connection.Credentials) {
+// needed.  It returns once the daemon shuts down.  Blueprints sent to the
+// minions are signed by `ca`.
+func Run(conn db.Conn, creds connection.Credentials, ca rsa.KeyPair) {
 	foreman.Credentials = creds
+	foreman.CA = &ca
 
 	go updateMachineStatuses(conn)
 
 	var ns string
 	foreman.Init(conn)
+	health.Start(foremanThread, 5*time.Minute)
 	stop := make(chan struct{})
-	for range conn.TriggerTick(60, db.BlueprintTable, db.MachineTable).C {
+	trigger := conn.TriggerTick(60, db.BlueprintTable, db.MachineTable)
+	for {
+		select {
+		case <-trigger.C:
+		case <-shuttingDown:
+			foremanClosed <- foreman.Close()
+			return
+		}
+		health.Beat(foremanThread)
+
 		newns, _ := conn.GetBlueprintNamespace()
 		if newns == ns {
 			foreman.RunOnce(conn)
@@ -77,10 +153,15 @@ func Run(conn db.Conn, creds connection.Credentials) {
 }
 
 func makeClouds(conn db.Conn, ns string, stop chan struct{}) {
+	var active []cloud
+	missing := map[db.ProviderName]struct{}{}
 	for _, p := range db.AllProviders {
 		for _, r := range validRegions(p) {
 			cld, err := newCloud(conn, p, r, ns)
 			if err != nil {
+				if creds.IsMissing(err) {
+					missing[p] = struct{}{}
+				}
 				log.WithFields(log.Fields{
 					"error":  err,
 					"region": cld.String(),
@@ -88,8 +169,11 @@ func makeClouds(conn db.Conn, ns string, stop chan struct{}) {
 				continue
 			}
 			go cld.run(stop)
+			active = append(active, cld)
 		}
 	}
+	setActiveClouds(active)
+	setMissingCredentials(missing)
 }
 
 func newCloud(conn db.Conn, pName db.ProviderName, region, ns string) (cloud, error) {
@@ -98,11 +182,20 @@ func newCloud(conn db.Conn, pName db.ProviderName, region, ns string) (cloud, er
 		namespace:    ns,
 		region:       region,
 		providerName: pName,
+		dbMachines:   map[string]db.Machine{},
+		resolvedACLs: map[string][]string{},
+
+		preemptibleFailures: map[string]int{},
 	}
 
 	var err error
 	cld.provider, err = newProvider(pName, ns, region)
 	if err != nil {
+		// Missing credentials are reported in the status of the provider's
+		// machines, so they're kept distinguishable.
+		if creds.IsMissing(err) {
+			return cld, err
+		}
 		return cld, fmt.Errorf("failed to connect: %s", err)
 	}
 	return cld, nil
@@ -111,7 +204,8 @@ func newCloud(conn db.Conn, pName db.ProviderName, region, ns string) (cloud, er
 func (cld cloud) run(stop <-chan struct{}) {
 	log.Debugf("Start Cloud %s", cld)
 
-	trigger := cld.conn.TriggerTick(60, db.BlueprintTable, db.MachineTable)
+	trigger := cld.conn.TriggerTick(60, db.BlueprintTable, db.MachineTable,
+		db.SettingsTable)
 	defer trigger.Stop()
 
 	for {
@@ -124,6 +218,7 @@ func (cld cloud) run(stop <-chan struct{}) {
 		select {
 		case <-stop:
 			log.Debugf("Stop Cloud %s", cld)
+			health.Forget(cld.healthName())
 			return
 		default:
 		}
@@ -155,9 +250,16 @@ func (cld cloud) runOnce() {
 			return
 		}
 
+		// Once the self-hosted daemon manages the namespace, this daemon
+		// only keeps its view of the machines up to date.
+		if steppedDown() {
+			return
+		}
+
 		if len(jr.boot) == 0 &&
 			len(jr.terminate) == 0 &&
-			len(jr.updateIPs) == 0 {
+			len(jr.updateIPs) == 0 &&
+			len(jr.reboot) == 0 {
 			// ACLs must be processed after Quilt learns about what machines
 			// are in the cloud.  If we didn't, inter-machine ACLs could get
 			// removed when the Quilt controller restarts, even if there are
@@ -166,10 +268,19 @@ func (cld cloud) runOnce() {
 			return
 		}
 
+		count := len(jr.boot) + len(jr.terminate) + len(jr.updateIPs) +
+			len(jr.reboot)
+		if !startMutation(count) {
+			return
+		}
+
 		cld.boot(jr.boot)
+		cld.preStop(jr.terminate)
 		cld.updateCloud(jr.terminate, provider.Stop, "stop")
 		cld.updateCloud(jr.updateIPs, provider.UpdateFloatingIPs,
 			"update floating IPs")
+		cld.updateCloud(jr.reboot, provider.Reboot, "reboot")
+		finishMutation(count)
 	}
 }
 
@@ -177,26 +288,106 @@ func (cld cloud) boot(machines []db.Machine) {
 	// As a defensive measure, we only copy over the fields that the underlying
 	// provider should care about instead of passing `machines` to updateCloud
 	// directly.
-	var cloudMachines []db.Machine
+	caps := cld.provider.Capabilities()
+
+	// The hooks of every machine run together, so that slow hooks hold up the
+	// join loop for at most the deadline shared by them all.
+	hookErrs := runHooks(blueprint.PreBoot, machines)
+
+	var cloudMachines, booted []db.Machine
+	for i, m := range machines {
+		if err := hookErrs[i]; err != nil {
+			log.WithError(err).WithField("machine", m).Warn(
+				"Pre-boot hooks failed. Will retry before booting.")
+			continue
+		}
+
+		cloudMachine := db.Machine{
+			Size:            m.Size,
+			DiskSize:        m.DiskSize,
+			VolumeType:      m.VolumeType,
+			IOPS:            m.IOPS,
+			DiskType:        m.DiskType,
+			Volumes:         m.Volumes,
+			Preemptible:     bootPreemptible(m),
+			MaxPrice:        m.MaxPrice,
+			Sysctls:         m.Sysctls,
+			Hugepages:       m.Hugepages,
+			Hostname:        m.Hostname,
+			Tags:            m.Tags,
+			VPC:             m.VPC,
+			Subnet:          m.Subnet,
+			InstanceProfile: m.InstanceProfile,
+			SecurityGroups:  m.SecurityGroups,
+			Image:           m.Image,
+			Zone:            m.Zone,
+			PlacementGroup:  m.PlacementGroup,
+			GPUs:            m.GPUs,
+			GPUType:         m.GPUType,
+			Arch:            m.Arch,
+			Host:            m.Host,
+			SSHUser:         m.SSHUser,
+			SSHKeyPath:      m.SSHKeyPath,
+			SSHKeys:         m.SSHKeys,
+			Role:            m.Role,
+			Provider:        m.Provider,
+			Region:          m.Region,
+		}
+		if !caps.ResizableDisks {
+			// The disk size is fixed by the machine's size.
+			cloudMachine.DiskSize = 0
+		}
+		if bootstrapMode(m) == blueprint.SSHBootstrap {
+			cloudMachine.Bootstrap = blueprint.SSHBootstrap
+			if !cfg.UserDataFits(m) {
+				log.WithField("machine", m).Warn("Boot script exceeds " +
+					"the provider's user-data limit. Bootstrapping over SSH.")
+			}
+		}
+		cloudMachines = append(cloudMachines, cloudMachine)
+		booted = append(booted, m)
+	}
+
+	if err := cld.updateCloud(cloudMachines, provider.Boot, "boot"); err != nil {
+		for _, m := range booted {
+			if bootPreemptible(m) {
+				cld.preemptibleFailures[m.BlueprintID]++
+			}
+		}
+	}
+}
+
+// bootPreemptible returns whether `dbm` should be booted as a preemptible
+// machine, rather than as the on-demand machine it fell back to.
+func bootPreemptible(dbm db.Machine) bool {
+	return dbm.Preemptible && !(dbm.OnDemand && dbm.OnDemandFallback)
+}
+
+// preStop runs the pre-stop hooks of the machines about to be stopped.  Stopping
+// isn't blocked on failed hooks, as that would leak machines.
+func (cld cloud) preStop(machines []db.Machine) {
+	var dbms []db.Machine
 	for _, m := range machines {
-		cloudMachines = append(cloudMachines, db.Machine{
-			Size:        m.Size,
-			DiskSize:    m.DiskSize,
-			Preemptible: m.Preemptible,
-			SSHKeys:     m.SSHKeys,
-			Role:        m.Role,
-			Provider:    m.Provider,
-			Region:      m.Region,
-		})
+		if dbm, ok := cld.dbMachines[m.CloudID]; ok {
+			dbms = append(dbms, dbm)
+		}
+	}
+
+	for i, err := range runHooks(blueprint.PreStop, dbms) {
+		if err != nil {
+			log.WithError(err).WithField("machine", dbms[i]).Warn(
+				"Pre-stop hooks failed. Stopping anyway.")
+		}
 	}
-	cld.updateCloud(cloudMachines, provider.Boot, "boot")
 }
 
 type machineAction func(provider, []db.Machine) error
 
-func (cld cloud) updateCloud(machines []db.Machine, fn machineAction, action string) {
+func (cld cloud) updateCloud(machines []db.Machine, fn machineAction,
+	action string) error {
+
 	if len(machines) == 0 {
-		return
+		return nil
 	}
 
 	logFields := log.Fields{
@@ -206,12 +397,14 @@ func (cld cloud) updateCloud(machines []db.Machine, fn machineAction, action str
 	}
 
 	c.Inc(action)
-	if err := fn(cld.provider, machines); err != nil {
+	err := fn(cld.provider, machines)
+	if err != nil {
 		logFields["error"] = err
 		log.WithFields(logFields).Errorf("Failed to update machines.")
 	} else {
 		log.WithFields(logFields).Infof("Updated machines.")
 	}
+	return err
 }
 
 type joinResult struct {
@@ -220,6 +413,7 @@ type joinResult struct {
 	boot      []db.Machine
 	terminate []db.Machine
 	updateIPs []db.Machine
+	reboot    []db.Machine
 }
 
 func (cld cloud) join() (joinResult, error) {
@@ -231,8 +425,10 @@ func (cld cloud) join() (joinResult, error) {
 		return res, err
 	}
 
-	err = cld.conn.Txn(db.BlueprintTable,
-		db.MachineTable).Run(func(view db.Database) error {
+	budget := cld.quotaBudget()
+
+	err = cld.conn.Txn(db.BlueprintTable, db.MachineTable,
+		db.SettingsTable).Run(func(view db.Database) error {
 		bp, err := view.GetBlueprint()
 		if err != nil {
 			log.WithError(err).Error("Failed to get blueprint")
@@ -251,16 +447,85 @@ func (cld cloud) join() (joinResult, error) {
 
 		cloudMachines = getMachineRoles(cloudMachines)
 
-		dbResult := syncDB(cloudMachines, machines)
-		res.boot = dbResult.boot
+		dbResult := syncDB(cld.String(), cloudMachines, machines)
 		res.terminate = dbResult.stop
-		res.updateIPs = dbResult.updateIPs
+
+		caps := cld.provider.Capabilities()
+		if caps.FloatingIPs {
+			res.updateIPs = dbResult.updateIPs
+		} else if len(dbResult.updateIPs) > 0 {
+			log.WithField("cloud", cld.String()).Warn("The provider " +
+				"doesn't support floating IPs. Ignoring them.")
+		}
+
+		rj := rec.Join(cloudMachines, machines)
+		for _, dbm := range dbResult.boot {
+			// The policy engine replaces interrupted machines, so once
+			// they've been reclaimed, their rows are removed rather than
+			// booted again.
+			if dbm.Interrupted {
+				rj.Decide("remove", dbm)
+				view.Remove(dbm)
+				continue
+			}
+
+			if bootPreemptible(dbm) && !caps.Preemptible {
+				if !dbm.OnDemandFallback {
+					log.WithField("machine", dbm).Error("The provider " +
+						"doesn't support preemptible machines. " +
+						"Not booting it.")
+					rj.Decide("skip unsupported preemptible", dbm)
+					continue
+				}
+
+				c.Inc("On-Demand Fallback")
+				log.WithField("machine", dbm).Warn("The provider doesn't " +
+					"support preemptible machines. Booting an " +
+					"on-demand machine instead.")
+				rj.Decide("fall back to on-demand", dbm)
+				dbm.OnDemand = true
+			} else if dbm.Preemptible && dbm.OnDemandFallback &&
+				!dbm.OnDemand && cld.preemptibleFailures[dbm.BlueprintID] >=
+				maxPreemptibleFailures {
+				c.Inc("On-Demand Fallback")
+				log.WithField("machine", dbm).Warn("Preemptible capacity " +
+					"is unavailable. Booting an on-demand machine instead.")
+				rj.Decide("fall back to on-demand", dbm)
+				dbm.OnDemand = true
+			}
+
+			if err := budget.Reserve(dbm); err != nil {
+				rj.Decide("skip over quota", dbm)
+				if dbm.Status != db.QuotaExceeded ||
+					dbm.BootError != err.Error() {
+					log.WithError(err).WithField("machine", dbm).Warn(
+						"Not booting machine that would exceed a quota.")
+					dbm.Status = db.QuotaExceeded
+					dbm.BootError = err.Error()
+					view.Commit(dbm)
+				}
+				continue
+			}
+			rj.Decide("boot", dbm)
+			res.boot = append(res.boot, dbm)
+		}
+		for _, m := range res.terminate {
+			rj.Decide("stop", m)
+		}
 
 		for _, dbm := range res.boot {
 			dbm.Status = db.Booting
+			dbm.BootError = ""
 			view.Commit(dbm)
 		}
 
+		dbMachines := map[string]db.Machine{}
+		for _, m := range res.terminate {
+			if dbm, ok := cld.dbMachines[m.CloudID]; ok {
+				dbMachines[m.CloudID] = dbm
+			}
+		}
+
 		for _, pair := range dbResult.pairs {
 			dbm := pair.L.(db.Machine)
 			m := pair.R.(db.Machine)
@@ -274,16 +539,68 @@ func (cld cloud) join() (joinResult, error) {
 				// machine and a cloud machine, so the status is not
 				// applicable.
 				dbm.Status = ""
+				dbm.BootError = ""
 			}
 			dbm.PublicIP = m.PublicIP
 			dbm.PrivateIP = m.PrivateIP
+			dbm.PublicIPv6 = m.PublicIPv6
+
+			// Machines whose floating IP is allocated automatically take
+			// whichever IP the provider allocated for them.
+			if dbm.AutoFloatingIP {
+				dbm.FloatingIP = ""
+				if m.AutoFloatingIP {
+					dbm.FloatingIP = m.FloatingIP
+				}
+			}
+
+			connected := isConnected(dbm.PublicIP)
+			if !dbm.Interrupted &&
+				(m.Interrupted || isInterrupted(dbm.PublicIP)) {
+				c.Inc("Interrupted")
+				log.WithField("machine", dbm).Info("Machine is about to " +
+					"be reclaimed by its provider. Draining it.")
+				dbm.Interrupted = true
+
+				// Machines reclaimed before they were of any use count
+				// as a failure to get preemptible capacity.
+				if dbm.Preemptible && !connected {
+					cld.preemptibleFailures[dbm.BlueprintID]++
+				}
+			} else if connected && !dbm.Interrupted {
+				delete(cld.preemptibleFailures, dbm.BlueprintID)
+			}
+
+			// Perform the requested action once the machine is drained.
+			// Reimaging stops the machine, after which a replacement is
+			// booted for its database row.
+			if dbm.Action != "" && dbm.CloudID == m.CloudID && drained(dbm) {
+				switch dbm.Action {
+				case db.RebootAction:
+					res.reboot = append(res.reboot, m)
+				case db.ReimageAction:
+					res.terminate = append(res.terminate, m)
+				}
+				rj.Decide(dbm.Action, m)
+				dbm.Action = ""
+				dbm.ActionRequested = time.Time{}
+			}
 
 			view.Commit(dbm)
+			dbMachines[m.CloudID] = dbm
+		}
+
+		for id := range cld.dbMachines {
+			delete(cld.dbMachines, id)
+		}
+		for id, dbm := range dbMachines {
+			cld.dbMachines[id] = dbm
 		}
 
 		// Regions with no machines in them should have their ACLs cleared.
 		if len(machines) > 0 {
-			for acl := range cld.getACLs(bp) {
+			settings := view.GetSettings(bp.Namespace)
+			for acl := range cld.getACLs(bp, settings) {
 				res.acls = append(res.acls, acl)
 			}
 		}
@@ -293,17 +610,79 @@ func (cld cloud) join() (joinResult, error) {
 	return res, err
 }
 
-func (cld cloud) getACLs(bp db.Blueprint) map[acl.ACL]struct{} {
+// quotaBudget returns the budget within which the cloud's machines are booted.
+// The quotas are only fetched when there are machines waiting to be booted, and
+// if they can't be, machines are booted unchecked.
+func (cld cloud) quotaBudget() quota.Budget {
+	qp, ok := cld.provider.(quotaProvider)
+	if !ok {
+		return quota.Budget{}
+	}
+
+	unbooted := cld.conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.Provider == cld.providerName && m.Region == cld.region &&
+			m.CloudID == ""
+	})
+	if len(unbooted) == 0 {
+		return quota.Budget{}
+	}
+
+	quotas, err := qp.Quotas()
+	if err != nil {
+		log.WithError(err).WithField("region", cld.String()).Warn(
+			"Failed to get quotas. Booting machines without checking them.")
+		return quota.Budget{}
+	}
+	return quota.NewBudget(cld.providerName, quotas)
+}
+
+// How long the containers of a machine are drained before its requested action
+// is performed regardless.  This way, minions that never report being drained,
+// such as those of older versions, don't block the action forever.
+const drainTimeout = 5 * time.Minute
+
+var isDrained = foreman.IsDrained
+var steppedDown = foreman.SteppedDown
+var isInterrupted = foreman.IsInterrupted
+
+// drained returns whether the requested action of `dbm` may be performed.  The
+// containers of machines that aren't connected can't be drained, so their action
+// is performed right away.
+func drained(dbm db.Machine) bool {
+	return !isConnected(dbm.PublicIP) || isDrained(dbm.PublicIP) ||
+		time.Since(dbm.ActionRequested) > drainTimeout
+}
+
+// The ports that admins need access to when minimal ACLs are enabled: SSH, the
+// API server, the self-hosted daemon, and the minion server.
+var adminPorts = []int{22, api.DefaultRemotePort, api.DefaultSelfHostedPort,
+	9999}
+
+func (cld cloud) getACLs(bp db.Blueprint, settings db.Settings) map[acl.ACL]struct{} {
 	aclSet := map[acl.ACL]struct{}{}
 
 	// Always allow traffic from the Quilt controller, so we append local.
-	for _, cidr := range append(bp.AdminACL, "local") {
-		acl := acl.ACL{
-			CidrIP:  cidr,
-			MinPort: 1,
-			MaxPort: 65535,
+	for _, cidr := range append(bp.AdminACL, localACL) {
+		if cidr == clientACL {
+			cidr = localACL
+			if ip := net.ParseIP(bp.ClientIP); ip != nil {
+				cidr = acl.HostCIDR(ip)
+			}
+		}
+
+		// Even without minimal ACLs, etcd is only exposed to the machines
+		// of the cluster.
+		if !settings.Enabled(db.MinimalACLs) {
+			for _, acl := range acl.AllPorts(cidr) {
+				aclSet[acl] = struct{}{}
+			}
+			continue
+		}
+
+		for _, port := range adminPorts {
+			aclSet[acl.ACL{CidrIP: cidr, MinPort: port, MaxPort: port}] =
+				struct{}{}
 		}
-		aclSet[acl] = struct{}{}
 	}
 
 	for _, conn := range bp.Connections {
@@ -321,23 +700,77 @@ func (cld cloud) getACLs(bp db.Blueprint) map[acl.ACL]struct{} {
 }
 
 func (cld cloud) syncACLs(unresolvedACLs []acl.ACL) {
+	// IPv6 ACLs are dropped for providers whose machines don't have IPv6
+	// addresses, as their firewalls may not accept them.
+	ipv6 := cld.provider.Capabilities().IPv6
+
 	var acls []acl.ACL
-	for _, acl := range unresolvedACLs {
-		if acl.CidrIP == "local" {
-			ip, err := myIP()
-			if err != nil {
-				log.WithError(err).Error("Failed to retrive local IP.")
-				return
+	aclSet := map[acl.ACL]struct{}{}
+	for _, unresolved := range unresolvedACLs {
+		cidrs, err := cld.resolveACL(unresolved.CidrIP)
+		if err != nil {
+			log.WithError(err).Error("Failed to resolve admin ACL.")
+			return
+		}
+
+		for _, cidr := range cidrs {
+			resolved := unresolved
+			resolved.CidrIP = cidr
+			if resolved.IPv6() && !ipv6 {
+				continue
+			}
+
+			if _, ok := aclSet[resolved]; !ok {
+				aclSet[resolved] = struct{}{}
+				acls = append(acls, resolved)
 			}
-			acl.CidrIP = ip + "/32"
 		}
-		acls = append(acls, acl)
 	}
 
 	c.Inc("SetACLs")
 	if err := cld.provider.SetACLs(acls); err != nil {
 		log.WithError(err).Warnf("Could not update ACLs in %s.", cld)
 	}
+	foreman.SetACLs(cld.providerName, cld.region, acls)
+}
+
+// resolveACL converts an admin ACL entry into CIDR blocks.  Entries may be CIDR
+// blocks, IP addresses, the local IP, or DNS names, which are resolved each time
+// the ACLs are synced so that admins with dynamic IPs keep access.
+func (cld cloud) resolveACL(entry string) ([]string, error) {
+	if entry == localACL {
+		ip, err := myIP()
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve local IP: %s", err)
+		}
+		return []string{ip + "/32"}, nil
+	}
+
+	if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+		return []string{ipnet.String()}, nil
+	}
+
+	if ip := net.ParseIP(entry); ip != nil {
+		return []string{acl.HostCIDR(ip)}, nil
+	}
+
+	var cidrs []string
+	addrs, err := lookupHost(entry)
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			cidrs = append(cidrs, acl.HostCIDR(ip))
+		}
+	}
+
+	if err != nil || len(cidrs) == 0 {
+		c.Inc("ACL Lookup Failure")
+		log.WithError(err).WithField("name", entry).Warn(
+			"Failed to resolve admin ACL. Using the previous resolution.")
+		return cld.resolvedACLs[entry], nil
+	}
+
+	cld.resolvedACLs[entry] = cidrs
+	return cidrs, nil
 }
 
 type syncDBResult struct {
@@ -347,49 +780,94 @@ type syncDBResult struct {
 	updateIPs []db.Machine
 }
 
-func syncDB(cms []db.Machine, dbms []db.Machine) syncDBResult {
+// syncDB pairs the cloud machines with the database machines.  `name` identifies
+// the cloud in the recorded join scores.
+func syncDB(name string, cms []db.Machine, dbms []db.Machine) syncDBResult {
 	ret := syncDBResult{}
 
-	pair1, dbmis, cmis := join.Join(dbms, cms, func(l, r interface{}) int {
-		dbm := l.(db.Machine)
-		m := r.(db.Machine)
-
-		if dbm.CloudID == m.CloudID && dbm.Provider == m.Provider &&
-			dbm.Preemptible == m.Preemptible &&
-			dbm.Region == m.Region && dbm.Size == m.Size &&
-			(m.DiskSize == 0 || dbm.DiskSize == m.DiskSize) &&
-			(m.Role == db.None || dbm.Role == m.Role) {
-			return 0
-		}
-
-		return -1
-	})
-
-	pair2, dbmis, cmis := join.Join(dbmis, cmis, func(l, r interface{}) int {
-		dbm := l.(db.Machine)
-		m := r.(db.Machine)
+	pair1, dbmis, cmis := rec.ScoredJoin(name+" by ID", dbms, cms,
+		func(l, r interface{}) int {
+			dbm := l.(db.Machine)
+			m := r.(db.Machine)
+
+			// Providers report the subnet of machines in the default VPC
+			// too, so it's only compared if the blueprint chose one.  The
+			// same goes for instance profiles and security groups attached
+			// outside of Quilt, for the images of machines booted from the
+			// default, for the zones of machines that let the provider
+			// choose, for the volume types, IOPS and disk types of machines
+			// with the default volume, and for the IPv6 addresses that
+			// subnets assign by default.
+			if dbm.CloudID == m.CloudID && dbm.Provider == m.Provider &&
+				dbm.Host == m.Host &&
+				(dbm.Subnet == "" || dbm.Subnet == m.Subnet) &&
+				(dbm.InstanceProfile == "" ||
+					dbm.InstanceProfile == m.InstanceProfile) &&
+				(len(dbm.SecurityGroups) == 0 || util.StrSliceEqual(
+					dbm.SecurityGroups, m.SecurityGroups)) &&
+				(dbm.Image == "" || dbm.Image == m.Image) &&
+				(dbm.Zone == "" || dbm.Zone == m.Zone) &&
+				dbm.PlacementGroup == m.PlacementGroup &&
+				(dbm.VolumeType == "" || dbm.VolumeType == m.VolumeType) &&
+				(dbm.IOPS == 0 || dbm.IOPS == m.IOPS) &&
+				(dbm.DiskType == "" || dbm.DiskType == m.DiskType) &&
+				db.VolumesEqual(dbm.Volumes, m.Volumes) &&
+				(!dbm.IPv6 || m.IPv6) &&
+				dbm.GPUs == m.GPUs && dbm.GPUType == m.GPUType &&
+				bootPreemptible(dbm) == m.Preemptible &&
+				dbm.Region == m.Region && dbm.Size == m.Size &&
+				(m.DiskSize == 0 || dbm.DiskSize == m.DiskSize) &&
+				(m.Role == db.None || dbm.Role == m.Role) {
+				return 0
+			}
 
-		if dbm.Provider != m.Provider ||
-			dbm.Region != m.Region ||
-			dbm.Size != m.Size ||
-			dbm.Preemptible != m.Preemptible ||
-			(m.DiskSize != 0 && dbm.DiskSize != m.DiskSize) ||
-			(m.Role != db.None && dbm.Role != m.Role) {
 			return -1
-		}
+		})
 
-		score := 10
-		if dbm.Role != db.None && m.Role != db.None && dbm.Role == m.Role {
-			score -= 4
-		}
-		if dbm.PublicIP == m.PublicIP && dbm.PrivateIP == m.PrivateIP {
-			score -= 2
-		}
-		if dbm.FloatingIP == m.FloatingIP {
-			score--
-		}
-		return score
-	})
+	pair2, dbmis, cmis := rec.ScoredJoin(name+" by attributes", dbmis, cmis,
+		func(l, r interface{}) int {
+			dbm := l.(db.Machine)
+			m := r.(db.Machine)
+
+			// Interrupted machines only keep the cloud machine that
+			// they're already paired with.
+			if dbm.Interrupted ||
+				dbm.Provider != m.Provider ||
+				dbm.Region != m.Region ||
+				dbm.Host != m.Host ||
+				(dbm.Subnet != "" && dbm.Subnet != m.Subnet) ||
+				(dbm.InstanceProfile != "" &&
+					dbm.InstanceProfile != m.InstanceProfile) ||
+				(len(dbm.SecurityGroups) != 0 && !util.StrSliceEqual(
+					dbm.SecurityGroups, m.SecurityGroups)) ||
+				(dbm.Image != "" && dbm.Image != m.Image) ||
+				(dbm.Zone != "" && dbm.Zone != m.Zone) ||
+				dbm.PlacementGroup != m.PlacementGroup ||
+				(dbm.VolumeType != "" && dbm.VolumeType != m.VolumeType) ||
+				(dbm.IOPS != 0 && dbm.IOPS != m.IOPS) ||
+				(dbm.DiskType != "" && dbm.DiskType != m.DiskType) ||
+				!db.VolumesEqual(dbm.Volumes, m.Volumes) ||
+				(dbm.IPv6 && !m.IPv6) ||
+				dbm.GPUs != m.GPUs || dbm.GPUType != m.GPUType ||
+				dbm.Size != m.Size ||
+				bootPreemptible(dbm) != m.Preemptible ||
+				(m.DiskSize != 0 && dbm.DiskSize != m.DiskSize) ||
+				(m.Role != db.None && dbm.Role != m.Role) {
+				return -1
+			}
+
+			score := 10
+			if dbm.Role != db.None && m.Role != db.None && dbm.Role == m.Role {
+				score -= 4
+			}
+			if dbm.PublicIP == m.PublicIP && dbm.PrivateIP == m.PrivateIP {
+				score -= 2
+			}
+			if dbm.FloatingIP == m.FloatingIP {
+				score--
+			}
+			return score
+		})
 
 	for _, cm := range cmis {
 		ret.stop = append(ret.stop, cm.(db.Machine))
@@ -404,9 +882,16 @@ func syncDB(cms []db.Machine, dbms []db.Machine) syncDBResult {
 		dbm := pair.L.(db.Machine)
 		m := pair.R.(db.Machine)
 
-		if dbm.CloudID == m.CloudID && dbm.FloatingIP != m.FloatingIP {
-			m.FloatingIP = dbm.FloatingIP
-			ret.updateIPs = append(ret.updateIPs, m)
+		if dbm.CloudID == m.CloudID {
+			switch {
+			case dbm.AutoFloatingIP && (m.FloatingIP == "" ||
+				!m.AutoFloatingIP):
+				m.FloatingIP = blueprint.AutoFloatingIP
+				ret.updateIPs = append(ret.updateIPs, m)
+			case !dbm.AutoFloatingIP && dbm.FloatingIP != m.FloatingIP:
+				m.FloatingIP = dbm.FloatingIP
+				ret.updateIPs = append(ret.updateIPs, m)
+			}
 		}
 
 		ret.pairs = append(ret.pairs, pair)
@@ -419,6 +904,7 @@ func (cld cloud) get() ([]db.Machine, error) {
 	c.Inc("List")
 
 	machines, err := cld.provider.List()
+	health.Report(cld.healthName(), err)
 	if err != nil {
 		return nil, fmt.Errorf("list %s: %s", cld, err)
 	}
@@ -444,12 +930,26 @@ func newProviderImpl(p db.ProviderName, namespace, region string) (provider, err
 	switch p {
 	case db.Amazon:
 		return amazon.New(namespace, region)
+	case db.Azure:
+		return azure.New(namespace, region)
 	case db.Google:
 		return google.New(namespace, region)
 	case db.DigitalOcean:
 		return digitalocean.New(namespace, region)
+	case db.OpenStack:
+		return openstack.New(namespace, region)
+	case db.Alibaba:
+		return alibaba.New(namespace, region)
+	case db.Scaleway:
+		return scaleway.New(namespace, region)
+	case db.IBM:
+		return ibm.New(namespace, region)
 	case db.Vagrant:
 		return vagrant.New(namespace)
+	case db.Static:
+		return static.New(namespace)
+	case db.Fake:
+		return fake.New(namespace, region)
 	default:
 		panic("Unimplemented")
 	}
@@ -459,12 +959,26 @@ func validRegionsImpl(p db.ProviderName) []string {
 	switch p {
 	case db.Amazon:
 		return amazon.Regions
+	case db.Azure:
+		return azure.Regions
 	case db.Google:
 		return google.Zones
 	case db.DigitalOcean:
 		return digitalocean.Regions
+	case db.OpenStack:
+		return openstack.Regions()
+	case db.Alibaba:
+		return alibaba.Regions
+	case db.Scaleway:
+		return scaleway.Regions
+	case db.IBM:
+		return ibm.Regions
 	case db.Vagrant:
 		return []string{""} // Vagrant has no regions
+	case db.Static:
+		return []string{""}
+	case db.Fake:
+		return fake.Regions
 	default:
 		panic("Unimplemented")
 	}
@@ -474,6 +988,13 @@ func (cld cloud) String() string {
 	return fmt.Sprintf("%s-%s-%s", cld.providerName, cld.region, cld.namespace)
 }
 
+// healthName is the name of the health check for the connection to the
+// provider.  It includes the namespace so that a cloud that's stopping doesn't
+// forget the check of its replacement.
+func (cld cloud) healthName() string {
+	return "provider " + cld.String()
+}
+
 // Stored in variables so they may be mocked out
 var newProvider = newProviderImpl
 var validRegions = validRegionsImpl
@@ -3,12 +3,19 @@ package cloud
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/foreman"
+	"github.com/kelda/kelda/cloud/hooks"
+	"github.com/kelda/kelda/cloud/quota"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
 	"github.com/stretchr/testify/assert"
@@ -26,13 +33,20 @@ type fakeProvider struct {
 	roles        map[string]db.Role
 	idCounter    int
 	cloudConfig  string
+	caps         capability.Capabilities
 
 	bootRequests []db.Machine
 	stopRequests []string
 	updatedIPs   []db.Machine
+	rebooted     []string
 	aclRequests  []acl.ACL
 
-	listError error
+	resources      []resource.Resource
+	quotas         []quota.Quota
+	listError      error
+	bootError      error
+	resourcesError error
+	quotasError    error
 }
 
 func fakeValidRegions(p db.ProviderName) []string {
@@ -44,6 +58,7 @@ func (p *fakeProvider) clearLogs() {
 	p.stopRequests = nil
 	p.aclRequests = nil
 	p.updatedIPs = nil
+	p.rebooted = nil
 }
 
 func (p *fakeProvider) List() ([]db.Machine, error) {
@@ -59,6 +74,11 @@ func (p *fakeProvider) List() ([]db.Machine, error) {
 }
 
 func (p *fakeProvider) Boot(bootSet []db.Machine) error {
+	if p.bootError != nil {
+		p.bootRequests = append(p.bootRequests, bootSet...)
+		return p.bootError
+	}
+
 	for _, toBoot := range bootSet {
 		// Record the boot request before we mutate it with implementation
 		// details of our fakeProvider.
@@ -106,6 +126,34 @@ func (p *fakeProvider) UpdateFloatingIPs(machines []db.Machine) error {
 	return nil
 }
 
+func (p *fakeProvider) Reboot(machines []db.Machine) error {
+	for _, machine := range machines {
+		p.rebooted = append(p.rebooted, machine.CloudID)
+	}
+	return nil
+}
+
+func (p *fakeProvider) Resources() ([]resource.Resource, error) {
+	return p.resources, p.resourcesError
+}
+
+// Quotas returns the configured quotas, with the machines booted by the fake
+// counted against the instance quota.
+func (p *fakeProvider) Quotas() ([]quota.Quota, error) {
+	var quotas []quota.Quota
+	for _, q := range p.quotas {
+		if q.Resource == quota.Instances {
+			q.Usage += len(p.machines)
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, p.quotasError
+}
+
+func (p *fakeProvider) Capabilities() capability.Capabilities {
+	return p.caps
+}
+
 func newTestCloud(provider db.ProviderName, region, namespace string) *cloud {
 	sleep = func(t time.Duration) {}
 	mock()
@@ -128,7 +176,7 @@ func TestPanicBadProvider(t *testing.T) {
 func TestSyncDB(t *testing.T) {
 	checkSyncDB := func(cloudMachines []db.Machine,
 		databaseMachines []db.Machine, expected syncDBResult) syncDBResult {
-		dbRes := syncDB(cloudMachines, databaseMachines)
+		dbRes := syncDB("cloud", cloudMachines, databaseMachines)
 
 		assert.Equal(t, expected.boot, dbRes.boot, "boot")
 		assert.Equal(t, expected.stop, dbRes.stop, "stop")
@@ -213,6 +261,25 @@ func TestSyncDB(t *testing.T) {
 		updateIPs: []db.Machine{cmWithIP},
 	})
 
+	// Test allocate Floating IP
+	dbAutoIP := db.Machine{Provider: FakeAmazon, CloudID: "id",
+		AutoFloatingIP: true}
+	cmAutoIP := db.Machine{Provider: FakeAmazon, CloudID: "id",
+		FloatingIP: "ip", AutoFloatingIP: true}
+	cmAutoRequest := db.Machine{Provider: FakeAmazon, CloudID: "id",
+		FloatingIP: blueprint.AutoFloatingIP}
+	checkSyncDB([]db.Machine{cmNoIP}, []db.Machine{dbAutoIP}, syncDBResult{
+		updateIPs: []db.Machine{cmAutoRequest},
+	})
+
+	// Test keep allocated Floating IP
+	checkSyncDB([]db.Machine{cmAutoIP}, []db.Machine{dbAutoIP}, syncDBResult{})
+
+	// Test replace reserved Floating IP with an allocated one
+	checkSyncDB([]db.Machine{cmWithIP}, []db.Machine{dbAutoIP}, syncDBResult{
+		updateIPs: []db.Machine{cmAutoRequest},
+	})
+
 	// Test bad disk size
 	checkSyncDB([]db.Machine{{DiskSize: 3}},
 		[]db.Machine{{DiskSize: 4}},
@@ -363,6 +430,166 @@ func TestSyncDB(t *testing.T) {
 			},
 		})
 
+	// Static machines only match the machine on their host.
+	dbStatic := db.Machine{Provider: db.Static, Size: "static", Host: "10.0.0.1"}
+	cmStatic := db.Machine{Provider: db.Static, Size: "static", Host: "10.0.0.2",
+		CloudID: "10.0.0.2"}
+	checkSyncDB([]db.Machine{cmStatic}, []db.Machine{dbStatic}, syncDBResult{
+		boot: []db.Machine{dbStatic},
+		stop: []db.Machine{cmStatic},
+	})
+
+	cmStatic.Host, cmStatic.CloudID = "10.0.0.1", "10.0.0.1"
+	checkSyncDB([]db.Machine{cmStatic}, []db.Machine{dbStatic}, syncDBResult{})
+
+	// Machines that choose a subnet only match machines in it, but machines
+	// that don't match machines in any subnet.
+	dbSubnet := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		Subnet: "subnet-1"}
+	cmSubnet := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		Subnet: "subnet-2"}
+	checkSyncDB([]db.Machine{cmSubnet}, []db.Machine{dbSubnet}, syncDBResult{
+		boot: []db.Machine{dbSubnet},
+		stop: []db.Machine{cmSubnet},
+	})
+
+	dbSubnet.Subnet = ""
+	checkSyncDB([]db.Machine{cmSubnet}, []db.Machine{dbSubnet}, syncDBResult{})
+
+	// Instance profiles are matched the same way.
+	dbProfile := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		InstanceProfile: "app"}
+	cmProfile := db.Machine{Provider: FakeAmazon, Size: "m4.large"}
+	checkSyncDB([]db.Machine{cmProfile}, []db.Machine{dbProfile}, syncDBResult{
+		boot: []db.Machine{dbProfile},
+		stop: []db.Machine{cmProfile},
+	})
+
+	cmProfile.InstanceProfile = "app"
+	checkSyncDB([]db.Machine{cmProfile}, []db.Machine{dbProfile}, syncDBResult{})
+
+	dbProfile.InstanceProfile = ""
+	checkSyncDB([]db.Machine{cmProfile}, []db.Machine{dbProfile}, syncDBResult{})
+
+	// Security groups are matched the same way.
+	dbGroups := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		SecurityGroups: []string{"sg-1"}}
+	cmGroups := db.Machine{Provider: FakeAmazon, Size: "m4.large"}
+	checkSyncDB([]db.Machine{cmGroups}, []db.Machine{dbGroups}, syncDBResult{
+		boot: []db.Machine{dbGroups},
+		stop: []db.Machine{cmGroups},
+	})
+
+	cmGroups.SecurityGroups = []string{"sg-1"}
+	checkSyncDB([]db.Machine{cmGroups}, []db.Machine{dbGroups}, syncDBResult{})
+
+	dbGroups.SecurityGroups = nil
+	checkSyncDB([]db.Machine{cmGroups}, []db.Machine{dbGroups}, syncDBResult{})
+
+	// Machines without the requested GPUs are replaced.
+	dbGPU := db.Machine{Provider: FakeAmazon, Size: "n1-standard-1", GPUs: 2,
+		GPUType: "nvidia-tesla-k80"}
+	cmGPU := db.Machine{Provider: FakeAmazon, Size: "n1-standard-1"}
+	checkSyncDB([]db.Machine{cmGPU}, []db.Machine{dbGPU}, syncDBResult{
+		boot: []db.Machine{dbGPU},
+		stop: []db.Machine{cmGPU},
+	})
+
+	cmGPU.GPUs, cmGPU.GPUType = 2, "nvidia-tesla-k80"
+	checkSyncDB([]db.Machine{cmGPU}, []db.Machine{dbGPU}, syncDBResult{})
+
+	// Machines booted from the wrong image are replaced, but machines that
+	// use the default image accept whichever image the provider reports.
+	dbImage := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		Image: "ami-prebaked"}
+	cmImage := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		Image: "ami-ubuntu"}
+	checkSyncDB([]db.Machine{cmImage}, []db.Machine{dbImage}, syncDBResult{
+		boot: []db.Machine{dbImage},
+		stop: []db.Machine{cmImage},
+	})
+
+	cmImage.Image = "ami-prebaked"
+	checkSyncDB([]db.Machine{cmImage}, []db.Machine{dbImage}, syncDBResult{})
+
+	dbImage.Image = ""
+	checkSyncDB([]db.Machine{cmImage}, []db.Machine{dbImage}, syncDBResult{})
+
+	// The same goes for zones, while placement groups must match exactly.
+	dbZone := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		Zone: "us-west-2a", PlacementGroup: "pool"}
+	cmZone := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		Zone: "us-west-2b", PlacementGroup: "pool"}
+	checkSyncDB([]db.Machine{cmZone}, []db.Machine{dbZone}, syncDBResult{
+		boot: []db.Machine{dbZone},
+		stop: []db.Machine{cmZone},
+	})
+
+	cmZone.Zone = "us-west-2a"
+	checkSyncDB([]db.Machine{cmZone}, []db.Machine{dbZone}, syncDBResult{})
+
+	dbZone.Zone = ""
+	checkSyncDB([]db.Machine{cmZone}, []db.Machine{dbZone}, syncDBResult{})
+
+	dbZone.PlacementGroup = ""
+	checkSyncDB([]db.Machine{cmZone}, []db.Machine{dbZone}, syncDBResult{
+		boot: []db.Machine{dbZone},
+		stop: []db.Machine{cmZone},
+	})
+
+	// Machines with the default volume accept whichever volume the provider
+	// reports.
+	dbVolume := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		VolumeType: "io2", IOPS: 4000}
+	cmVolume := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		VolumeType: "gp2", IOPS: 100}
+	checkSyncDB([]db.Machine{cmVolume}, []db.Machine{dbVolume}, syncDBResult{
+		boot: []db.Machine{dbVolume},
+		stop: []db.Machine{cmVolume},
+	})
+
+	cmVolume.VolumeType, cmVolume.IOPS = "io2", 4000
+	checkSyncDB([]db.Machine{cmVolume}, []db.Machine{dbVolume}, syncDBResult{})
+
+	dbVolume.VolumeType, dbVolume.IOPS = "", 0
+	checkSyncDB([]db.Machine{cmVolume}, []db.Machine{dbVolume}, syncDBResult{})
+
+	// Machines must have exactly the block storage volumes they declare.
+	data := []blueprint.MachineVolume{{Name: "data", Size: 100}}
+	dbVolume.Volumes = data
+	checkSyncDB([]db.Machine{cmVolume}, []db.Machine{dbVolume}, syncDBResult{
+		boot: []db.Machine{dbVolume},
+		stop: []db.Machine{cmVolume},
+	})
+
+	cmVolume.Volumes = data
+	checkSyncDB([]db.Machine{cmVolume}, []db.Machine{dbVolume}, syncDBResult{})
+
+	// The same goes for machines with the default disk type.
+	dbDisk := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		DiskType: "pd-ssd"}
+	cmDisk := db.Machine{Provider: FakeAmazon, Size: "m4.large",
+		DiskType: "local-ssd"}
+	checkSyncDB([]db.Machine{cmDisk}, []db.Machine{dbDisk}, syncDBResult{
+		boot: []db.Machine{dbDisk},
+		stop: []db.Machine{cmDisk},
+	})
+
+	dbDisk.DiskType = ""
+	checkSyncDB([]db.Machine{cmDisk}, []db.Machine{dbDisk}, syncDBResult{})
+
+	// Machines that request IPv6 must have it, but machines that don't may
+	// still be assigned it by their subnet.
+	dbIPv6 := db.Machine{Provider: FakeAmazon, Size: "m4.large", IPv6: true}
+	cmIPv6 := db.Machine{Provider: FakeAmazon, Size: "m4.large"}
+	checkSyncDB([]db.Machine{cmIPv6}, []db.Machine{dbIPv6}, syncDBResult{
+		boot: []db.Machine{dbIPv6},
+		stop: []db.Machine{cmIPv6},
+	})
+
+	dbIPv6.IPv6 = false
+	cmIPv6.IPv6 = true
+	checkSyncDB([]db.Machine{cmIPv6}, []db.Machine{dbIPv6}, syncDBResult{})
 }
 
 func TestCloudRunOnce(t *testing.T) {
@@ -568,6 +795,398 @@ func TestCloudRunOnce(t *testing.T) {
 	})
 }
 
+func TestMachineHooks(t *testing.T) {
+	var ran []string
+	hookErr := errors.New("hook failed")
+	runHooks = func(event string, machines []db.Machine) []error {
+		var errs []error
+		for _, m := range machines {
+			ran = append(ran, event+" "+m.BlueprintID)
+			errs = append(errs, hookErr)
+		}
+		return errs
+	}
+	defer func() { runHooks = hooks.RunAll }()
+
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.BlueprintID = "a"
+		m.Role = db.Master
+		m.Provider = FakeAmazon
+		m.Region = testRegion
+		m.Size = "m4.large"
+		view.Commit(m)
+		return nil
+	})
+
+	// Machines aren't booted until their pre-boot hooks succeed.
+	cld.runOnce()
+	providerInst := cld.provider.(*fakeProvider)
+	assert.Empty(t, providerInst.bootRequests)
+	assert.Equal(t, []string{"pre-boot a", "pre-boot a"}, ran)
+
+	hookErr = nil
+	ran = nil
+	cld.runOnce()
+	assert.Len(t, providerInst.bootRequests, 1)
+	assert.Equal(t, []string{"pre-boot a"}, ran)
+
+	// Pre-stop hooks run after the database row is removed, and don't block
+	// stopping the machine.
+	hookErr = errors.New("hook failed")
+	ran = nil
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		view.Remove(view.SelectFromMachine(nil)[0])
+		return nil
+	})
+	cld.runOnce()
+	assert.Len(t, providerInst.stopRequests, 1)
+	assert.Equal(t, []string{"pre-stop a"}, ran)
+}
+
+func TestMachineActions(t *testing.T) {
+	connected := true
+	isConnected = func(string) bool { return connected }
+	drainedMachines := map[string]bool{}
+	isDrained = func(ip string) bool { return drainedMachines[ip] }
+	defer func() {
+		isConnected = foreman.IsConnected
+		isDrained = foreman.IsDrained
+	}()
+
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.Role = db.Master
+		m.Provider = FakeAmazon
+		m.Region = testRegion
+		m.Size = "m4.large"
+		view.Commit(m)
+		return nil
+	})
+	cld.runOnce()
+
+	providerInst := cld.provider.(*fakeProvider)
+	setAction := func(action string, requested time.Time) {
+		cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			m := view.SelectFromMachine(nil)[0]
+			m.Action = action
+			m.ActionRequested = requested
+			view.Commit(m)
+			return nil
+		})
+	}
+	getMachine := func() db.Machine {
+		return cld.conn.SelectFromMachine(nil)[0]
+	}
+
+	// The machine isn't rebooted until its containers are drained.
+	setAction(db.RebootAction, time.Now())
+	cld.runOnce()
+	assert.Empty(t, providerInst.rebooted)
+	assert.Equal(t, db.RebootAction, getMachine().Action)
+
+	drainedMachines[getMachine().PublicIP] = true
+	cld.runOnce()
+	assert.Equal(t, []string{getMachine().CloudID}, providerInst.rebooted)
+	assert.Empty(t, getMachine().Action)
+	assert.Empty(t, providerInst.stopRequests)
+
+	// Machines that haven't drained in time are rebooted anyway.
+	providerInst.clearLogs()
+	drainedMachines = map[string]bool{}
+	setAction(db.RebootAction, time.Now().Add(-time.Hour))
+	cld.runOnce()
+	assert.Len(t, providerInst.rebooted, 1)
+
+	// Reimaging replaces the machine, without waiting for disconnected
+	// machines to drain.
+	providerInst.clearLogs()
+	connected = false
+	oldID := getMachine().CloudID
+	setAction(db.ReimageAction, time.Now())
+	cld.runOnce()
+	assert.Equal(t, []string{oldID}, providerInst.stopRequests)
+	assert.Len(t, providerInst.bootRequests, 1)
+	assert.Empty(t, providerInst.rebooted)
+
+	cld.runOnce()
+	assert.NotEqual(t, oldID, getMachine().CloudID)
+	assert.Empty(t, getMachine().Action)
+}
+
+func TestSteppedDown(t *testing.T) {
+	steppedDown = func() bool { return true }
+	defer func() { steppedDown = foreman.SteppedDown }()
+
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.Role = db.Master
+		m.Provider = FakeAmazon
+		m.Region = testRegion
+		m.Size = "m4.large"
+		view.Commit(m)
+		return nil
+	})
+
+	// The self-hosted daemon boots the machines, rather than this one.
+	cld.runOnce()
+	assert.Empty(t, cld.provider.(*fakeProvider).bootRequests)
+}
+
+func TestMachineInterruption(t *testing.T) {
+	interrupted := map[string]bool{}
+	isInterrupted = func(ip string) bool { return interrupted[ip] }
+	defer func() { isInterrupted = foreman.IsInterrupted }()
+
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for i := 0; i < 2; i++ {
+			m := view.InsertMachine()
+			m.Role = db.Master
+			m.Provider = FakeAmazon
+			m.Region = testRegion
+			m.Size = "m4.large"
+			view.Commit(m)
+		}
+		return nil
+	})
+	cld.runOnce()
+	cld.runOnce()
+
+	providerInst := cld.provider.(*fakeProvider)
+	providerInst.clearLogs()
+	machines := cld.conn.SelectFromMachine(nil)
+	assert.Len(t, machines, 2)
+
+	// Machines are marked as interrupted when either their provider or their
+	// minion announces it.
+	announced := providerInst.machines[machines[0].CloudID]
+	announced.Interrupted = true
+	providerInst.machines[announced.CloudID] = announced
+	interrupted[machines[1].PublicIP] = true
+	cld.runOnce()
+
+	machines = cld.conn.SelectFromMachine(nil)
+	assert.Len(t, machines, 2)
+	for _, m := range machines {
+		assert.True(t, m.Interrupted)
+	}
+	assert.Empty(t, providerInst.bootRequests)
+	assert.Empty(t, providerInst.stopRequests)
+
+	// Once reclaimed, interrupted machines are removed rather than booted
+	// again.
+	delete(providerInst.machines, announced.CloudID)
+	cld.runOnce()
+	assert.Empty(t, providerInst.bootRequests)
+	machines = cld.conn.SelectFromMachine(nil)
+	assert.Len(t, machines, 1)
+	assert.NotEqual(t, announced.CloudID, machines[0].CloudID)
+}
+
+func TestOnDemandFallback(t *testing.T) {
+	connected := false
+	isConnected = func(string) bool { return connected }
+	defer func() { isConnected = foreman.IsConnected }()
+
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	insertWorker := func(blueprintID string, fallback bool) {
+		cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			m := view.InsertMachine()
+			m.BlueprintID = blueprintID
+			m.Role = db.Worker
+			m.Provider = FakeAmazon
+			m.Region = testRegion
+			m.Size = "m4.large"
+			m.Preemptible = true
+			m.OnDemandFallback = fallback
+			view.Commit(m)
+			return nil
+		})
+	}
+	insertWorker("fallback", true)
+
+	// After repeatedly failing to boot a preemptible machine, an on-demand
+	// machine is booted instead.
+	providerInst := cld.provider.(*fakeProvider)
+	providerInst.bootError = errors.New("no capacity")
+	cld.runOnce()
+	cld.runOnce()
+
+	var preemptible []bool
+	for _, m := range providerInst.bootRequests {
+		preemptible = append(preemptible, m.Preemptible)
+	}
+	assert.Equal(t, []bool{true, true, true, false}, preemptible)
+	assert.True(t, cld.conn.SelectFromMachine(nil)[0].OnDemand)
+
+	providerInst.bootError = nil
+	providerInst.clearLogs()
+	cld.runOnce()
+	assert.Len(t, providerInst.bootRequests, 1)
+	assert.False(t, providerInst.bootRequests[0].Preemptible)
+
+	// The on-demand machine is kept.
+	providerInst.clearLogs()
+	cld.runOnce()
+	assert.Empty(t, providerInst.bootRequests)
+	assert.Empty(t, providerInst.stopRequests)
+
+	// Machines reclaimed before their minion connects count as failures, and
+	// the count is reset once a machine connects.
+	insertWorker("reclaimed", false)
+	cld.runOnce()
+	reclaimed := cld.conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.BlueprintID == "reclaimed"
+	})[0]
+
+	announced := providerInst.machines[reclaimed.CloudID]
+	announced.Interrupted = true
+	providerInst.machines[announced.CloudID] = announced
+	cld.runOnce()
+	assert.Equal(t, 1, cld.preemptibleFailures["reclaimed"])
+
+	insertWorker("reclaimed", false)
+	cld.runOnce()
+	connected = true
+	cld.runOnce()
+	assert.Zero(t, cld.preemptibleFailures["reclaimed"])
+
+	// Without OnDemandFallback, preemptible machines are retried
+	// indefinitely.
+	providerInst.bootError = errors.New("no capacity")
+	providerInst.clearLogs()
+	insertWorker("retried", false)
+	for i := 0; i < 3; i++ {
+		cld.runOnce()
+	}
+	assert.Len(t, providerInst.bootRequests, 6)
+	for _, m := range providerInst.bootRequests {
+		assert.True(t, m.Preemptible)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	providerInst := cld.provider.(*fakeProvider)
+	providerInst.caps = capability.Capabilities{}
+
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, id := range []string{"fallback", "preemptible", "floating"} {
+			m := view.InsertMachine()
+			m.BlueprintID = id
+			m.Role = db.Worker
+			m.Provider = FakeAmazon
+			m.Region = testRegion
+			m.Size = "m4.large"
+			m.DiskSize = 32
+			m.Preemptible = id != "floating"
+			m.OnDemandFallback = id == "fallback"
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	// Preemptible machines fall back to on-demand machines if they may, and
+	// otherwise aren't booted.  Disk sizes aren't requested.
+	cld.runOnce()
+	assert.Len(t, providerInst.bootRequests, 2)
+	for _, m := range providerInst.bootRequests {
+		assert.False(t, m.Preemptible)
+		assert.Zero(t, m.DiskSize)
+	}
+
+	machines := cld.conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.BlueprintID != "preemptible"
+	})
+	for _, m := range machines {
+		assert.NotEmpty(t, m.CloudID, m.BlueprintID)
+	}
+	assert.Empty(t, cld.conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.BlueprintID == "preemptible" && m.CloudID != ""
+	}))
+
+	// Floating IPs aren't assigned.
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.SelectFromMachine(func(m db.Machine) bool {
+			return m.BlueprintID == "floating"
+		})[0]
+		m.FloatingIP = "8.8.8.8"
+		view.Commit(m)
+		return nil
+	})
+	providerInst.clearLogs()
+	cld.runOnce()
+	assert.Empty(t, providerInst.updatedIPs)
+	assert.Empty(t, providerInst.bootRequests)
+}
+
+func TestQuotas(t *testing.T) {
+	cld := newTestCloud(FakeAmazon, testRegion, "ns")
+	setNamespace(cld.conn, "ns")
+	providerInst := cld.provider.(*fakeProvider)
+	providerInst.quotas = []quota.Quota{
+		{Resource: quota.Instances, Limit: 2, Usage: 1}}
+
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, id := range []string{"1", "2"} {
+			m := view.InsertMachine()
+			m.BlueprintID = id
+			m.Role = db.Worker
+			m.Provider = FakeAmazon
+			m.Region = testRegion
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	// Only one machine fits within the quota.  The other says why it isn't
+	// booted.
+	cld.runOnce()
+	assert.Len(t, providerInst.bootRequests, 1)
+	overQuota := cld.conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.CloudID == ""
+	})
+	assert.Len(t, overQuota, 1)
+	assert.Equal(t, db.QuotaExceeded, overQuota[0].Status)
+	assert.Equal(t, "needs 1 instances, but only 0 of the FakeAmazon quota "+
+		"of 2 remain. Stop other machines, or ask FakeAmazon to raise "+
+		"the quota", overQuota[0].BootError)
+
+	// Once the quota is raised, the machine is booted.
+	providerInst.clearLogs()
+	providerInst.quotas[0].Limit = 3
+	cld.runOnce()
+	assert.Len(t, providerInst.bootRequests, 1)
+	assert.Empty(t, cld.conn.SelectFromMachine(func(m db.Machine) bool {
+		return m.CloudID == "" || m.BootError != ""
+	}))
+
+	// Machines are booted unchecked if the quotas can't be fetched.
+	providerInst.clearLogs()
+	providerInst.quotasError = assert.AnError
+	cld.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.BlueprintID = "3"
+		m.Role = db.Worker
+		m.Provider = FakeAmazon
+		m.Region = testRegion
+		view.Commit(m)
+		return nil
+	})
+	cld.runOnce()
+	assert.Len(t, providerInst.bootRequests, 1)
+}
+
 func TestACLs(t *testing.T) {
 	myIP = func() (string, error) {
 		return "5.6.7.8", nil
@@ -585,23 +1204,78 @@ func TestACLs(t *testing.T) {
 	}
 	actual := clst.provider.(*fakeProvider).aclRequests
 	assert.Equal(t, exp, actual)
+
+	// DNS names are resolved, and the previous resolution is used if the lookup
+	// fails.  Duplicate ACLs are removed, as are IPv6 ACLs for providers without
+	// IPv6.  CIDR blocks are canonicalized so that they match the provider's.
+	lookupHost = func(name string) ([]string, error) {
+		return []string{"1.2.3.4", "fe80::1", "5.6.7.8"}, nil
+	}
+	defer func() { lookupHost = net.LookupHost }()
+
+	unresolved := []acl.ACL{
+		{CidrIP: "admin.example.com", MinPort: 80, MaxPort: 80},
+		{CidrIP: "local", MinPort: 80, MaxPort: 80},
+		{CidrIP: "9.9.9.9", MinPort: 80, MaxPort: 80},
+		{CidrIP: "10.0.0.0/8", MinPort: 80, MaxPort: 80},
+		{CidrIP: "10.0.0.1/8", MinPort: 80, MaxPort: 80},
+	}
+	exp = []acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "5.6.7.8/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "9.9.9.9/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "10.0.0.0/8", MinPort: 80, MaxPort: 80},
+	}
+	clst.syncACLs(unresolved)
+	assert.Equal(t, exp, clst.provider.(*fakeProvider).aclRequests)
+
+	lookupHost = func(name string) ([]string, error) {
+		return nil, assert.AnError
+	}
+	clst.syncACLs(unresolved)
+	assert.Equal(t, exp, clst.provider.(*fakeProvider).aclRequests)
+
+	// Names that have never resolved are skipped.
+	clst.syncACLs([]acl.ACL{
+		{CidrIP: "unknown.example.com", MinPort: 80, MaxPort: 80},
+		{CidrIP: "local", MinPort: 80, MaxPort: 80},
+	})
+	assert.Equal(t, []acl.ACL{{CidrIP: "5.6.7.8/32", MinPort: 80, MaxPort: 80}},
+		clst.provider.(*fakeProvider).aclRequests)
+
+	clst.provider.(*fakeProvider).caps.IPv6 = true
+	lookupHost = func(name string) ([]string, error) {
+		return []string{"1.2.3.4", "2001:db8::1"}, nil
+	}
+	clst.syncACLs([]acl.ACL{
+		{CidrIP: "admin.example.com", MinPort: 80, MaxPort: 80},
+		{CidrIP: "2001:db8::2", MinPort: 22, MaxPort: 22},
+		{CidrIP: "2001:db8:1::1/48", MinPort: 22, MaxPort: 22},
+	})
+	assert.Equal(t, []acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "2001:db8::1/128", MinPort: 80, MaxPort: 80},
+		{CidrIP: "2001:db8::2/128", MinPort: 22, MaxPort: 22},
+		{CidrIP: "2001:db8:1::/48", MinPort: 22, MaxPort: 22},
+	}, clst.provider.(*fakeProvider).aclRequests)
 }
 
 func TestGetACLs(t *testing.T) {
 	cld := newTestCloud(FakeAmazon, testRegion, "ns")
 
 	exp := map[acl.ACL]struct{}{
-		{CidrIP: "local", MinPort: 1, MaxPort: 65535}: {},
+		{CidrIP: "local", MinPort: 1, MaxPort: 2378}:     {},
+		{CidrIP: "local", MinPort: 2381, MaxPort: 65535}: {},
 	}
 
 	// Empty blueprint should have "local" added to it.
-	acls := cld.getACLs(db.Blueprint{})
+	acls := cld.getACLs(db.Blueprint{}, db.Settings{})
 	assert.Equal(t, exp, acls)
 
 	// A blueprint with local, shouldn't have it added a second time.
 	acls = cld.getACLs(db.Blueprint{
 		Blueprint: blueprint.Blueprint{AdminACL: []string{"local"}},
-	})
+	}, db.Settings{})
 	assert.Equal(t, exp, acls)
 
 	// Connections that aren't to or from public, shouldn't affect the acls.
@@ -614,7 +1288,7 @@ func TestGetACLs(t *testing.T) {
 				MaxPort: 6,
 			}},
 		},
-	})
+	}, db.Settings{})
 	assert.Equal(t, exp, acls)
 
 	// Connections from public create an ACL.
@@ -627,9 +1301,53 @@ func TestGetACLs(t *testing.T) {
 				MaxPort: 2,
 			}},
 		},
-	})
+	}, db.Settings{})
 	exp[acl.ACL{CidrIP: "0.0.0.0/0", MinPort: 1, MaxPort: 2}] = struct{}{}
 	assert.Equal(t, exp, acls)
+
+	// Minimal ACLs only open the ports needed by Quilt to admins.
+	acls = cld.getACLs(db.Blueprint{
+		Blueprint: blueprint.Blueprint{AdminACL: []string{"1.2.3.4/32"}},
+	}, db.Settings{Flags: map[string]bool{db.MinimalACLs: true}})
+	assert.Equal(t, map[acl.ACL]struct{}{
+		{CidrIP: "1.2.3.4/32", MinPort: 22, MaxPort: 22}:     {},
+		{CidrIP: "1.2.3.4/32", MinPort: 9000, MaxPort: 9000}: {},
+		{CidrIP: "1.2.3.4/32", MinPort: 9001, MaxPort: 9001}: {},
+		{CidrIP: "1.2.3.4/32", MinPort: 9999, MaxPort: 9999}: {},
+		{CidrIP: "local", MinPort: 22, MaxPort: 22}:          {},
+		{CidrIP: "local", MinPort: 9000, MaxPort: 9000}:      {},
+		{CidrIP: "local", MinPort: 9001, MaxPort: 9001}:      {},
+		{CidrIP: "local", MinPort: 9999, MaxPort: 9999}:      {},
+	}, acls)
+
+	// The client entry refers to the IP of the client that deployed the
+	// blueprint, or the local IP if the client is on the same host.
+	bp := db.Blueprint{
+		Blueprint: blueprint.Blueprint{AdminACL: []string{"client"}},
+	}
+	acls = cld.getACLs(bp, db.Settings{})
+	assert.Equal(t, map[acl.ACL]struct{}{
+		{CidrIP: "local", MinPort: 1, MaxPort: 2378}:     {},
+		{CidrIP: "local", MinPort: 2381, MaxPort: 65535}: {},
+	}, acls)
+
+	bp.ClientIP = "8.8.8.8"
+	acls = cld.getACLs(bp, db.Settings{})
+	assert.Equal(t, map[acl.ACL]struct{}{
+		{CidrIP: "8.8.8.8/32", MinPort: 1, MaxPort: 2378}:     {},
+		{CidrIP: "8.8.8.8/32", MinPort: 2381, MaxPort: 65535}: {},
+		{CidrIP: "local", MinPort: 1, MaxPort: 2378}:          {},
+		{CidrIP: "local", MinPort: 2381, MaxPort: 65535}:      {},
+	}, acls)
+
+	bp.ClientIP = "2001:db8::1"
+	acls = cld.getACLs(bp, db.Settings{})
+	assert.Equal(t, map[acl.ACL]struct{}{
+		{CidrIP: "2001:db8::1/128", MinPort: 1, MaxPort: 2378}:     {},
+		{CidrIP: "2001:db8::1/128", MinPort: 2381, MaxPort: 65535}: {},
+		{CidrIP: "local", MinPort: 1, MaxPort: 2378}:               {},
+		{CidrIP: "local", MinPort: 2381, MaxPort: 65535}:           {},
+	}, acls)
 }
 
 func TestMakeClouds(t *testing.T) {
@@ -650,6 +1368,27 @@ func TestMakeClouds(t *testing.T) {
 	close(stop)
 }
 
+func TestMakeCloudsMissingCredentials(t *testing.T) {
+	mockNewProvider := newProvider
+	defer func() { newProvider = mockNewProvider }()
+	newProvider = func(p db.ProviderName, namespace,
+		region string) (provider, error) {
+		if p == FakeVagrant {
+			return nil, creds.MissingError{Provider: p}
+		}
+		return mockNewProvider(p, namespace, region)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	makeClouds(db.New(), "ns", stop)
+	assert.True(t, credentialsMissing(FakeVagrant))
+	assert.False(t, credentialsMissing(FakeAmazon))
+
+	_, err := newCloud(db.New(), FakeVagrant, testRegion, "ns")
+	assert.True(t, creds.IsMissing(err))
+}
+
 func TestGetError(t *testing.T) {
 	t.Parallel()
 
@@ -684,6 +1423,8 @@ func mock() {
 			namespace:    namespace,
 			machines:     make(map[string]db.Machine),
 			roles:        make(map[string]db.Role),
+			caps: capability.Capabilities{FloatingIPs: true,
+				Preemptible: true, ResizableDisks: true},
 		}
 		ret.clearLogs()
 
@@ -0,0 +1,45 @@
+// Package cloudtest provides helpers for testing the cloud providers whose API
+// clients decode JSON responses into a result argument, against mocks of those
+// clients.
+package cloudtest
+
+import (
+	"encoding/json"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// Reply returns a function for mock.Call.Run that responds to the mocked call
+// with `resp`.  The response is decoded into the last argument of the call, as
+// the API would, unless the argument is nil.
+func Reply(resp interface{}) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		result := args.Get(len(args) - 1)
+		if result == nil {
+			return
+		}
+
+		raw, err := json.Marshal(resp)
+		if err != nil {
+			panic(err)
+		}
+		if err := json.Unmarshal(raw, result); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Body decodes the request body `body` into a generic JSON value, so that tests
+// may inspect the fields that providers send regardless of their Go types.
+func Body(body interface{}) map[string]interface{} {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		panic(err)
+	}
+	return decoded
+}
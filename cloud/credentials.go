@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"time"
 
@@ -13,6 +14,8 @@ import (
 	"github.com/spf13/afero/sftpfs"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/kelda/kelda/cloud/foreman"
+	"github.com/kelda/kelda/connection/tls"
 	tlsIO "github.com/kelda/kelda/connection/tls/io"
 	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/counter"
@@ -33,7 +36,7 @@ var credentialsCounter = counter.New("Cloud Credentials")
 // This will not cause any interruption to connections as long as the same
 // certificate authority is used by the daemon.
 func SyncCredentials(conn db.Conn, sshKey ssh.Signer, ca rsa.KeyPair) {
-	credentialedMachines := map[string]struct{}{}
+	credentialedMachines := map[string]string{}
 	for range conn.TriggerTick(30, db.MachineTable).C {
 		machines := conn.SelectFromMachine(nil)
 		syncCredentialsOnce(sshKey, ca, machines, credentialedMachines)
@@ -41,17 +44,25 @@ func SyncCredentials(conn db.Conn, sshKey ssh.Signer, ca rsa.KeyPair) {
 }
 
 func syncCredentialsOnce(sshKey ssh.Signer, ca rsa.KeyPair,
-	machines []db.Machine, credentialedMachines map[string]struct{}) {
+	machines []db.Machine, credentialedMachines map[string]string) {
 	credentialsCounter.Inc("Install to cluster")
 	for _, m := range machines {
-		_, hasCreds := credentialedMachines[m.PublicIP]
-		if hasCreds || m.PublicIP == "" {
+		// Credentials are reinstalled if the IP is reassigned to another
+		// machine, as they're bound to the machine's cloud ID.
+		cloudID, hasCreds := credentialedMachines[m.PublicIP]
+		if (hasCreds && cloudID == m.CloudID) || m.PublicIP == "" ||
+			m.CloudID == "" {
+			continue
+		}
+
+		// Fake machines have no SSH server, and their minions don't use TLS.
+		if m.Provider == db.Fake {
 			continue
 		}
 
 		credentialsCounter.Inc("Install " + m.PublicIP)
 		if generateAndInstallCerts(m, sshKey, ca) {
-			credentialedMachines[m.PublicIP] = struct{}{}
+			credentialedMachines[m.PublicIP] = m.CloudID
 		}
 	}
 }
@@ -71,8 +82,11 @@ func generateAndInstallCerts(machine db.Machine, sshKey ssh.Signer,
 	defer fs.Close()
 
 	// Generate new certificates signed by the CA for use by the minion for all
-	// communication.
-	signed, err := rsa.NewSigned(ca, net.ParseIP(machine.PrivateIP))
+	// communication.  They identify the machine by its cloud ID, so that the
+	// foreman can tell the minion apart from whoever later gets its IP.
+	signed, err := rsa.NewSignedWithURIs(ca,
+		[]*url.URL{tls.MachineURI(machine.CloudID)},
+		net.ParseIP(machine.PrivateIP))
 	if err != nil {
 		log.WithError(err).WithField("host", machine.PublicIP).
 			Error("Failed to generate certs. Retrying.")
@@ -135,20 +149,9 @@ func (fs sftpFsImpl) Close() error {
 
 // getSftpFsImpl gets an SFTP connection to `host` authenticated by `sshKey`.
 func getSftpFsImpl(host string, sshKey ssh.Signer) (sftpFs, error) {
-	sshConfig := &ssh.ClientConfig{
-		User:    "quilt",
-		Auth:    []ssh.AuthMethod{ssh.PublicKeys(sshKey)},
-		Timeout: 5 * time.Second,
-		// XXX: We have to ignore the host key because we don't keep track of
-		// the host keys of machines. Once we do, this should use strict host
-		// key checking. For now, this means that a machine could theoretically
-		// man in the middle as the target machine and obtain signed
-		// certificates.
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", host), sshConfig)
+	sshClient, err := dialSSH(host, sshKey)
 	if err != nil {
-		return nil, fmt.Errorf("dial: %s", err)
+		return nil, err
 	}
 
 	sftpClient, err := sftp.NewClient(sshClient)
@@ -159,5 +162,11 @@ func getSftpFsImpl(host string, sshKey ssh.Signer) (sftpFs, error) {
 	return sftpFsImpl{sftpfs.New(sftpClient), sftpClient}, nil
 }
 
+// dialSSH opens an SSH connection to the quilt user on `host`, authenticated by
+// `sshKey`.
+func dialSSH(host string, sshKey ssh.Signer) (*ssh.Client, error) {
+	return foreman.DialSSH(host, "quilt", sshKey, 5*time.Second)
+}
+
 // Saved in a variable to allow injecting a memory filesystem during unit testing.
 var getSftpFs = getSftpFsImpl
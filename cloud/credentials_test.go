@@ -3,6 +3,8 @@ package cloud
 import (
 	"crypto/rand"
 	goRSA "crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"path/filepath"
 	"testing"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/kelda/kelda/connection/tls"
 	tlsIO "github.com/kelda/kelda/connection/tls/io"
 	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/db"
@@ -36,16 +39,23 @@ func TestSyncCredentials(t *testing.T) {
 	ca, err := rsa.NewCertificateAuthority()
 	assert.NoError(t, err)
 
-	credentialedMachines := map[string]struct{}{}
-	syncCredentialsOnce(expSigner, ca,
-		[]db.Machine{{PublicIP: expHost, PrivateIP: "9.9.9.9"}},
-		credentialedMachines)
-	assert.Len(t, credentialedMachines, 1)
+	credentialedMachines := map[string]string{}
+	syncCredentialsOnce(expSigner, ca, []db.Machine{
+		{PublicIP: expHost, PrivateIP: "9.9.9.9", CloudID: "id"},
+	}, credentialedMachines)
+	assert.Equal(t, map[string]string{expHost: "id"}, credentialedMachines)
 
 	aferoFs := afero.Afero{Fs: mockFs}
 	certBytes, err := aferoFs.ReadFile(filepath.Join(tlsIO.MinionTLSDir, "quilt.crt"))
 	assert.NoError(t, err)
-	assert.NotEmpty(t, certBytes)
+
+	// The certificate is bound to the machine's cloud ID.
+	block, _ := pem.Decode(certBytes)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+	cloudID, ok := tls.MachineID(cert)
+	assert.True(t, ok)
+	assert.Equal(t, "id", cloudID)
 
 	keyBytes, err := aferoFs.ReadFile(filepath.Join(tlsIO.MinionTLSDir, "quilt.key"))
 	assert.NoError(t, err)
@@ -62,17 +72,17 @@ func TestSyncCredentialsSkip(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test that we skip machines that have not booted yet.
-	credentialedMachines := map[string]struct{}{}
+	credentialedMachines := map[string]string{}
 	syncCredentialsOnce(nil, ca,
 		[]db.Machine{{Role: db.Worker}}, credentialedMachines)
 	assert.Empty(t, credentialedMachines, 0)
 
 	// Test that we skip machines that have already been setup.
-	credentialedMachines = map[string]struct{}{
-		"8.8.8.8": {},
+	credentialedMachines = map[string]string{
+		"8.8.8.8": "id",
 	}
 	syncCredentialsOnce(nil, ca, []db.Machine{
-		{Role: db.Worker, PublicIP: "8.8.8.8"},
+		{Role: db.Worker, PublicIP: "8.8.8.8", CloudID: "id"},
 	}, credentialedMachines)
 	assert.Len(t, credentialedMachines, 1)
 
@@ -80,9 +90,27 @@ func TestSyncCredentialsSkip(t *testing.T) {
 	getSftpFs = func(host string, _ ssh.Signer) (sftpFs, error) {
 		return nil, assert.AnError
 	}
-	credentialedMachines = map[string]struct{}{}
+	credentialedMachines = map[string]string{}
 	syncCredentialsOnce(nil, ca, []db.Machine{
-		{Role: db.Worker, PublicIP: "8.8.8.8"},
+		{Role: db.Worker, PublicIP: "8.8.8.8", CloudID: "id"},
+	}, credentialedMachines)
+	assert.Empty(t, credentialedMachines)
+
+	// Test that we retry machines whose IP was reassigned to another machine.
+	credentialedMachines = map[string]string{"8.8.8.8": "old"}
+	syncCredentialsOnce(nil, ca, []db.Machine{
+		{Role: db.Worker, PublicIP: "8.8.8.8", CloudID: "new"},
+	}, credentialedMachines)
+	assert.Equal(t, map[string]string{"8.8.8.8": "old"}, credentialedMachines)
+
+	// Test that we skip Fake machines, which have no SSH server.
+	getSftpFs = func(host string, _ ssh.Signer) (sftpFs, error) {
+		t.Errorf("unexpected SFTP connection to %s", host)
+		return nil, assert.AnError
+	}
+	credentialedMachines = map[string]string{}
+	syncCredentialsOnce(nil, ca, []db.Machine{{Role: db.Worker,
+		Provider: db.Fake, PublicIP: "198.18.0.2", CloudID: "fake-1"},
 	}, credentialedMachines)
 	assert.Empty(t, credentialedMachines)
 }
@@ -0,0 +1,51 @@
+// Package creds helps the cloud providers find their credentials.  Each
+// provider first looks in its configuration file in the home directory, and then
+// in the environment variables that the provider's own tools use.  Some
+// providers may also use the role of the instance that the daemon runs on.
+package creds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kelda/kelda/db"
+)
+
+// A MissingError reports that a provider's credentials weren't found in any of
+// the places that it looked.
+type MissingError struct {
+	Provider db.ProviderName
+	Sources  []string
+}
+
+func (err MissingError) Error() string {
+	return fmt.Sprintf("no %s credentials found in %s", err.Provider,
+		strings.Join(err.Sources, ", or "))
+}
+
+// IsMissing returns whether `err` is a MissingError.
+func IsMissing(err error) bool {
+	_, ok := err.(MissingError)
+	return ok
+}
+
+// FromEnv sets `dst` to the first of the environment variables `names` that's
+// set.  If none are, `dst` is left alone.
+func FromEnv(dst *string, names ...string) {
+	for _, name := range names {
+		if val := os.Getenv(name); val != "" {
+			*dst = val
+			return
+		}
+	}
+}
+
+// EnvSource describes the environment variables `names` for a MissingError.
+func EnvSource(names ...string) string {
+	if len(names) == 1 {
+		return fmt.Sprintf("the %s environment variable", names[0])
+	}
+	return fmt.Sprintf("the %s and %s environment variables",
+		strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
@@ -0,0 +1,33 @@
+package creds
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingError(t *testing.T) {
+	err := MissingError{Provider: db.Amazon, Sources: []string{
+		"~/.aws/credentials", EnvSource("AWS_ACCESS_KEY_ID"),
+		EnvSource("A", "B", "C")}}
+	assert.EqualError(t, err, "no Amazon credentials found in "+
+		"~/.aws/credentials, or the AWS_ACCESS_KEY_ID environment variable, "+
+		"or the A, B and C environment variables")
+	assert.True(t, IsMissing(err))
+	assert.False(t, IsMissing(errors.New("other")))
+}
+
+func TestFromEnv(t *testing.T) {
+	os.Setenv("CREDS_TEST_SECOND", "second")
+	defer os.Unsetenv("CREDS_TEST_SECOND")
+
+	val := "file"
+	FromEnv(&val, "CREDS_TEST_UNSET")
+	assert.Equal(t, "file", val)
+
+	FromEnv(&val, "CREDS_TEST_FIRST", "CREDS_TEST_SECOND")
+	assert.Equal(t, "second", val)
+}
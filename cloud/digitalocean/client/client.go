@@ -5,9 +5,12 @@ package client
 import (
 	"context"
 	"net/http"
+	"net/url"
 
 	"github.com/digitalocean/godo"
+	"github.com/kelda/kelda/cloud/endpoint"
 	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
 )
 
 // A Client for DigitalOcean's API. Used for unit testing.
@@ -16,16 +19,29 @@ type Client interface {
 	DeleteDroplet(int) (*godo.Response, error)
 	GetDroplet(int) (*godo.Droplet, *godo.Response, error)
 	ListDroplets(*godo.ListOptions) ([]godo.Droplet, *godo.Response, error)
+	RebootDroplet(int) (*godo.Action, *godo.Response, error)
 
 	ListFloatingIPs(*godo.ListOptions) ([]godo.FloatingIP, *godo.Response, error)
 	AssignFloatingIP(string, int) (*godo.Action, *godo.Response, error)
 	UnassignFloatingIP(string) (*godo.Action, *godo.Response, error)
+
+	CreateVolume(*godo.VolumeCreateRequest) (*godo.Volume, *godo.Response, error)
+	DeleteVolume(string) (*godo.Response, error)
+	GetVolume(string) (*godo.Volume, *godo.Response, error)
+	ListVolumes(*godo.ListVolumeParams) ([]godo.Volume, *godo.Response, error)
+	AttachVolume(string, int) (*godo.Action, *godo.Response, error)
+
+	GetAccount() (*godo.Account, *godo.Response, error)
 }
 
 type client struct {
 	droplets          godo.DropletsService
+	dropletActions    godo.DropletActionsService
 	floatingIPs       godo.FloatingIPsService
 	floatingIPActions godo.FloatingIPActionsService
+	storage           godo.StorageService
+	storageActions    godo.StorageActionsService
+	account           godo.AccountService
 }
 
 var c = counter.New("Digital Ocean")
@@ -52,6 +68,11 @@ func (client client) ListDroplets(opt *godo.ListOptions) ([]godo.Droplet,
 	return client.droplets.List(context.Background(), opt)
 }
 
+func (client client) RebootDroplet(id int) (*godo.Action, *godo.Response, error) {
+	c.Inc("Reboot Droplet")
+	return client.dropletActions.Reboot(context.Background(), id)
+}
+
 func (client client) ListFloatingIPs(opt *godo.ListOptions) ([]godo.FloatingIP,
 	*godo.Response, error) {
 	c.Inc("List Floating IPs")
@@ -70,12 +91,54 @@ func (client client) UnassignFloatingIP(ip string) (*godo.Action, *godo.Response
 	return client.floatingIPActions.Unassign(context.Background(), ip)
 }
 
+func (client client) CreateVolume(req *godo.VolumeCreateRequest) (*godo.Volume,
+	*godo.Response, error) {
+	c.Inc("Create Volume")
+	return client.storage.CreateVolume(context.Background(), req)
+}
+
+func (client client) DeleteVolume(id string) (*godo.Response, error) {
+	c.Inc("Delete Volume")
+	return client.storage.DeleteVolume(context.Background(), id)
+}
+
+func (client client) GetVolume(id string) (*godo.Volume, *godo.Response, error) {
+	c.Inc("Get Volume")
+	return client.storage.GetVolume(context.Background(), id)
+}
+
+func (client client) ListVolumes(params *godo.ListVolumeParams) ([]godo.Volume,
+	*godo.Response, error) {
+	c.Inc("List Volumes")
+	return client.storage.ListVolumes(context.Background(), params)
+}
+
+func (client client) AttachVolume(id string, dropletID int) (*godo.Action,
+	*godo.Response, error) {
+	c.Inc("Attach Volume")
+	return client.storageActions.Attach(context.Background(), id, dropletID)
+}
+
+func (client client) GetAccount() (*godo.Account, *godo.Response, error) {
+	c.Inc("Get Account")
+	return client.account.Get(context.Background())
+}
+
 // New creates a new DigitalOcean client.
 func New(oauthClient *http.Client) Client {
 	api := godo.NewClient(oauthClient)
+	if endpointURL, ok := endpoint.Get(db.DigitalOcean); ok {
+		if baseURL, err := url.Parse(endpointURL); err == nil {
+			api.BaseURL = baseURL
+		}
+	}
 	return client{
 		droplets:          api.Droplets,
+		dropletActions:    api.DropletActions,
 		floatingIPs:       api.FloatingIPs,
 		floatingIPActions: api.FloatingIPActions,
+		storage:           api.Storage,
+		storageActions:    api.StorageActions,
+		account:           api.Account,
 	}
 }
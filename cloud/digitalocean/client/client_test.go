@@ -31,6 +31,10 @@ func TestError(t *testing.T) {
 	_, _, err = c.ListDroplets(&godo.ListOptions{})
 	assert.EqualError(t, err, "Get https://api.digitalocean.com/v2/droplets: test")
 
+	_, _, err = c.RebootDroplet(3)
+	assert.EqualError(t, err,
+		"Post https://api.digitalocean.com/v2/droplets/3/actions: test")
+
 	_, _, err = c.ListFloatingIPs(&godo.ListOptions{})
 	assert.EqualError(t, err,
 		"Get https://api.digitalocean.com/v2/floating_ips: test")
@@ -42,4 +46,20 @@ func TestError(t *testing.T) {
 	_, _, err = c.UnassignFloatingIP("a")
 	assert.EqualError(t, err,
 		"Post https://api.digitalocean.com/v2/floating_ips/a/actions: test")
+
+	_, _, err = c.CreateVolume(&godo.VolumeCreateRequest{})
+	assert.EqualError(t, err, "Post https://api.digitalocean.com/v2/volumes: test")
+
+	_, err = c.DeleteVolume("a")
+	assert.EqualError(t, err, "Delete https://api.digitalocean.com/v2/volumes/a: test")
+
+	_, _, err = c.GetVolume("a")
+	assert.EqualError(t, err, "Get https://api.digitalocean.com/v2/volumes/a: test")
+
+	_, _, err = c.ListVolumes(&godo.ListVolumeParams{})
+	assert.EqualError(t, err, "Get https://api.digitalocean.com/v2/volumes: test")
+
+	_, _, err = c.AttachVolume("a", 3)
+	assert.EqualError(t, err,
+		"Post https://api.digitalocean.com/v2/volumes/a/actions: test")
 }
@@ -42,6 +42,38 @@ func (_m *Client) AssignFloatingIP(_a0 string, _a1 int) (*godo.Action, *godo.Res
 	return r0, r1, r2
 }
 
+// AttachVolume provides a mock function with given fields: _a0, _a1
+func (_m *Client) AttachVolume(_a0 string, _a1 int) (*godo.Action, *godo.Response, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *godo.Action
+	if rf, ok := ret.Get(0).(func(string, int) *godo.Action); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*godo.Action)
+		}
+	}
+
+	var r1 *godo.Response
+	if rf, ok := ret.Get(1).(func(string, int) *godo.Response); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*godo.Response)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, int) error); ok {
+		r2 = rf(_a0, _a1)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // CreateDroplet provides a mock function with given fields: _a0
 func (_m *Client) CreateDroplet(_a0 *godo.DropletCreateRequest) (*godo.Droplet, *godo.Response, error) {
 	ret := _m.Called(_a0)
@@ -74,6 +106,38 @@ func (_m *Client) CreateDroplet(_a0 *godo.DropletCreateRequest) (*godo.Droplet,
 	return r0, r1, r2
 }
 
+// CreateVolume provides a mock function with given fields: _a0
+func (_m *Client) CreateVolume(_a0 *godo.VolumeCreateRequest) (*godo.Volume, *godo.Response, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *godo.Volume
+	if rf, ok := ret.Get(0).(func(*godo.VolumeCreateRequest) *godo.Volume); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*godo.Volume)
+		}
+	}
+
+	var r1 *godo.Response
+	if rf, ok := ret.Get(1).(func(*godo.VolumeCreateRequest) *godo.Response); ok {
+		r1 = rf(_a0)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*godo.Response)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(*godo.VolumeCreateRequest) error); ok {
+		r2 = rf(_a0)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // DeleteDroplet provides a mock function with given fields: _a0
 func (_m *Client) DeleteDroplet(_a0 int) (*godo.Response, error) {
 	ret := _m.Called(_a0)
@@ -97,6 +161,61 @@ func (_m *Client) DeleteDroplet(_a0 int) (*godo.Response, error) {
 	return r0, r1
 }
 
+// DeleteVolume provides a mock function with given fields: _a0
+func (_m *Client) DeleteVolume(_a0 string) (*godo.Response, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *godo.Response
+	if rf, ok := ret.Get(0).(func(string) *godo.Response); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*godo.Response)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccount provides a mock function with given fields:
+func (_m *Client) GetAccount() (*godo.Account, *godo.Response, error) {
+	ret := _m.Called()
+
+	var r0 *godo.Account
+	if rf, ok := ret.Get(0).(func() *godo.Account); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*godo.Account)
+		}
+	}
+
+	var r1 *godo.Response
+	if rf, ok := ret.Get(1).(func() *godo.Response); ok {
+		r1 = rf()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*godo.Response)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func() error); ok {
+		r2 = rf()
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetDroplet provides a mock function with given fields: _a0
 func (_m *Client) GetDroplet(_a0 int) (*godo.Droplet, *godo.Response, error) {
 	ret := _m.Called(_a0)
@@ -129,6 +248,38 @@ func (_m *Client) GetDroplet(_a0 int) (*godo.Droplet, *godo.Response, error) {
 	return r0, r1, r2
 }
 
+// GetVolume provides a mock function with given fields: _a0
+func (_m *Client) GetVolume(_a0 string) (*godo.Volume, *godo.Response, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *godo.Volume
+	if rf, ok := ret.Get(0).(func(string) *godo.Volume); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*godo.Volume)
+		}
+	}
+
+	var r1 *godo.Response
+	if rf, ok := ret.Get(1).(func(string) *godo.Response); ok {
+		r1 = rf(_a0)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*godo.Response)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string) error); ok {
+		r2 = rf(_a0)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // ListDroplets provides a mock function with given fields: _a0
 func (_m *Client) ListDroplets(_a0 *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
 	ret := _m.Called(_a0)
@@ -193,6 +344,70 @@ func (_m *Client) ListFloatingIPs(_a0 *godo.ListOptions) ([]godo.FloatingIP, *go
 	return r0, r1, r2
 }
 
+// ListVolumes provides a mock function with given fields: _a0
+func (_m *Client) ListVolumes(_a0 *godo.ListVolumeParams) ([]godo.Volume, *godo.Response, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []godo.Volume
+	if rf, ok := ret.Get(0).(func(*godo.ListVolumeParams) []godo.Volume); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]godo.Volume)
+		}
+	}
+
+	var r1 *godo.Response
+	if rf, ok := ret.Get(1).(func(*godo.ListVolumeParams) *godo.Response); ok {
+		r1 = rf(_a0)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*godo.Response)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(*godo.ListVolumeParams) error); ok {
+		r2 = rf(_a0)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RebootDroplet provides a mock function with given fields: _a0
+func (_m *Client) RebootDroplet(_a0 int) (*godo.Action, *godo.Response, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *godo.Action
+	if rf, ok := ret.Get(0).(func(int) *godo.Action); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*godo.Action)
+		}
+	}
+
+	var r1 *godo.Response
+	if rf, ok := ret.Get(1).(func(int) *godo.Response); ok {
+		r1 = rf(_a0)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*godo.Response)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(int) error); ok {
+		r2 = rf(_a0)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // UnassignFloatingIP provides a mock function with given fields: _a0
 func (_m *Client) UnassignFloatingIP(_a0 string) (*godo.Action, *godo.Response, error) {
 	ret := _m.Called(_a0)
@@ -3,22 +3,34 @@ package digitalocean
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/digitalocean/godo"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cassette"
 	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
 	"github.com/kelda/kelda/cloud/digitalocean/client"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/quota"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/throttle"
 	"github.com/kelda/kelda/cloud/wait"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
 	"github.com/kelda/kelda/util"
 
+	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 
 	log "github.com/sirupsen/logrus"
@@ -34,6 +46,9 @@ var c = counter.New("Digital Ocean")
 
 var apiKeyPath = ".digitalocean/key"
 
+// The environment variable that overrides the API key in apiKeyPath.
+const apiKeyEnv = "DIGITALOCEAN_ACCESS_TOKEN"
+
 // 16.04.1 x64 created at 2017-02-03.
 var imageID = 22601368
 
@@ -45,7 +60,8 @@ type Provider struct {
 	region    string
 }
 
-// New starts a new client session with the API key provided in ~/.digitalocean/key.
+// New starts a new client session with the API key provided in ~/.digitalocean/key,
+// or in the environment.
 func New(namespace, region string) (*Provider, error) {
 	prvdr, err := newDigitalOcean(namespace, region)
 	if err != nil {
@@ -59,15 +75,22 @@ func New(namespace, region string) (*Provider, error) {
 // Creation is broken out for unit testing.
 var newDigitalOcean = func(namespace, region string) (*Provider, error) {
 	namespace = strings.ToLower(strings.Replace(namespace, "_", "-", -1))
-	keyFile := filepath.Join(os.Getenv("HOME"), apiKeyPath)
-	key, err := util.ReadFile(keyFile)
-	if err != nil {
-		return nil, err
+
+	// Replayed responses don't require a valid API key.
+	key := "replay"
+	if !cassette.Replaying() {
+		var err error
+		if key, err = readAPIKey(); err != nil {
+			return nil, err
+		}
 	}
-	key = strings.TrimSpace(key)
 
+	ctx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient,
+		throttle.Wrap(db.DigitalOcean, region,
+			endpoint.Client(db.DigitalOcean, http.DefaultClient)))
 	tc := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: key})
-	oauthClient := oauth2.NewClient(oauth2.NoContext, tc)
+	oauthClient := cassette.Wrap("digitalocean-"+region,
+		oauth2.NewClient(ctx, tc))
 
 	prvdr := &Provider{
 		namespace: namespace,
@@ -77,6 +100,25 @@ var newDigitalOcean = func(namespace, region string) (*Provider, error) {
 	return prvdr, nil
 }
 
+func readAPIKey() (string, error) {
+	var key string
+	creds.FromEnv(&key, apiKeyEnv)
+	if key != "" {
+		return key, nil
+	}
+
+	keyFile := filepath.Join(os.Getenv("HOME"), apiKeyPath)
+	keyStr, err := util.ReadFile(keyFile)
+	switch {
+	case os.IsNotExist(err):
+		return "", creds.MissingError{Provider: db.DigitalOcean,
+			Sources: []string{keyFile, creds.EnvSource(apiKeyEnv)}}
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimSpace(keyStr), nil
+}
+
 // List will fetch all droplets that have the same name as the cluster namespace.
 func (prvdr Provider) List() (machines []db.Machine, err error) {
 	floatingIPs, err := prvdr.getFloatingIPs()
@@ -84,6 +126,62 @@ func (prvdr Provider) List() (machines []db.Machine, err error) {
 		return nil, err
 	}
 
+	droplets, err := prvdr.droplets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range droplets {
+		pubIP, err := d.PublicIPv4()
+		if err != nil {
+			return nil, fmt.Errorf("get public IP: %s", err)
+		}
+
+		privIP, err := d.PrivateIPv4()
+		if err != nil {
+			return nil, fmt.Errorf("get private IP: %s", err)
+		}
+
+		pubIPv6, err := d.PublicIPv6()
+		if err != nil {
+			return nil, fmt.Errorf("get public IPv6: %s", err)
+		}
+
+		machine := db.Machine{
+			CloudID:     strconv.Itoa(d.ID),
+			PublicIP:    pubIP,
+			PrivateIP:   privIP,
+			PublicIPv6:  pubIPv6,
+			IPv6:        pubIPv6 != "",
+			FloatingIP:  floatingIPs[d.ID],
+			Size:        d.SizeSlug,
+			Preemptible: false,
+		}
+		if d.Image != nil {
+			machine.Image = strconv.Itoa(d.Image.ID)
+		}
+
+		machine.Volumes, err = prvdr.dropletVolumes(d)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, machine)
+	}
+	return machines, nil
+}
+
+// droplets fetches the droplets of the namespace in the region.
+func (prvdr Provider) droplets() ([]godo.Droplet, error) {
+	return prvdr.listDroplets(func(d godo.Droplet) bool {
+		return d.Name == prvdr.namespace && d.Region.Slug == prvdr.region
+	})
+}
+
+// listDroplets returns the droplets of the account for which `keep` is true.
+func (prvdr Provider) listDroplets(keep func(godo.Droplet) bool) (
+	[]godo.Droplet, error) {
+
+	var res []godo.Droplet
 	dropletListOpt := &godo.ListOptions{} // Keep track of the page we're on.
 	// DigitalOcean's API has a paginated list of droplets.
 	for {
@@ -93,38 +191,117 @@ func (prvdr Provider) List() (machines []db.Machine, err error) {
 		}
 
 		for _, d := range droplets {
-			if d.Name != prvdr.namespace || d.Region.Slug != prvdr.region {
-				continue
+			if keep(d) {
+				res = append(res, d)
 			}
+		}
 
-			pubIP, err := d.PublicIPv4()
-			if err != nil {
-				return nil, fmt.Errorf("get public IP: %s", err)
-			}
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			break
+		}
 
-			privIP, err := d.PrivateIPv4()
-			if err != nil {
-				return nil, fmt.Errorf("get private IP: %s", err)
-			}
+		dropletListOpt.Page++
+	}
+	return res, nil
+}
+
+// dropletVolumes returns the volumes that Quilt attached to the droplet `d`,
+// sorted by name.
+func (prvdr Provider) dropletVolumes(d godo.Droplet) (
+	[]blueprint.MachineVolume, error) {
+	var volumes []blueprint.MachineVolume
+	for _, id := range d.VolumeIDs {
+		vol, _, err := prvdr.GetVolume(id)
+		if err != nil {
+			return nil, fmt.Errorf("get volume: %s", err)
+		}
+
+		if v, ok := prvdr.parseVolume(*vol, d.ID); ok {
+			volumes = append(volumes, v)
+		}
+	}
+
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes, nil
+}
+
+// volumeName returns the name of the DigitalOcean volume that backs `v` when
+// it's attached to the droplet `dropletID`.  Volume names are unique within a
+// region, so they're prefixed with the namespace.  Persistent volumes outlive
+// their droplets, and so aren't named after them.
+func (prvdr Provider) volumeName(v blueprint.MachineVolume, dropletID int) string {
+	if v.Persistent {
+		return prvdr.namespace + "-" + v.Name
+	}
+	return fmt.Sprintf("%s-%s-%d", prvdr.namespace, v.Name, dropletID)
+}
 
-			machine := db.Machine{
-				CloudID:     strconv.Itoa(d.ID),
-				PublicIP:    pubIP,
-				PrivateIP:   privIP,
-				FloatingIP:  floatingIPs[d.ID],
-				Size:        d.SizeSlug,
-				Preemptible: false,
+// parseVolume returns the blueprint volume that `vol`, attached to the droplet
+// `dropletID`, backs.  The volume's description holds the blueprint volume's
+// name.  It returns false if the volume wasn't created by Quilt.
+func (prvdr Provider) parseVolume(vol godo.Volume, dropletID int) (
+	blueprint.MachineVolume, bool) {
+	v := blueprint.MachineVolume{
+		Name: vol.Description,
+		Size: int(vol.SizeGigaBytes),
+	}
+	if vol.Name == prvdr.volumeName(v, dropletID) {
+		return v, true
+	}
+
+	v.Persistent = true
+	return v, vol.Name == prvdr.volumeName(v, dropletID)
+}
+
+// Resources lists the droplets of the namespace, and their volumes and floating
+// IPs.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	floatingIPs, err := prvdr.getFloatingIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	droplets, err := prvdr.droplets()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	for _, d := range droplets {
+		var tags map[string]string
+		for _, tag := range d.Tags {
+			if tags == nil {
+				tags = map[string]string{}
 			}
-			machines = append(machines, machine)
+			tags[tag] = ""
 		}
 
-		if resp.Links == nil || resp.Links.IsLastPage() {
-			break
+		created, _ := time.Parse(time.RFC3339, d.Created)
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      strconv.Itoa(d.ID),
+			Name:    d.Name,
+			Tags:    tags,
+			Created: created,
+		})
+
+		for _, id := range d.VolumeIDs {
+			resources = append(resources, resource.Resource{
+				Type: resource.Volume,
+				ID:   id,
+			})
 		}
 
-		dropletListOpt.Page++
+		if ip, ok := floatingIPs[d.ID]; ok {
+			resources = append(resources, resource.Resource{
+				Type: resource.IP,
+				ID:   ip,
+			})
+		}
 	}
-	return machines, nil
+	return resources, nil
 }
 
 func (prvdr Provider) getFloatingIPs() (map[int]string, error) {
@@ -174,16 +351,34 @@ func (prvdr Provider) Boot(bootSet []db.Machine) error {
 	return err
 }
 
-// Creates a new machine, and waits for the machine to become active.
+// Creates a new machine, waits for the machine to become active, and attaches its
+// volumes.
 func (prvdr Provider) createAndAttach(m db.Machine) error {
-	cloudConfig := cfg.Ubuntu(m, "")
+	image := imageID
+	if m.Image != "" {
+		var err error
+		if image, err = strconv.Atoi(m.Image); err != nil {
+			return fmt.Errorf("bad snapshot ID %q", m.Image)
+		}
+	}
+
+	// Existing persistent volumes are checked before the droplet is created,
+	// so that a volume that can't be attached doesn't leave a droplet behind.
+	persistent, err := prvdr.persistentVolumes(m.Volumes)
+	if err != nil {
+		return err
+	}
+
+	cloudConfig := cfg.UserData(m, "")
 	createReq := &godo.DropletCreateRequest{
 		Name:              prvdr.namespace,
 		Region:            prvdr.region,
 		Size:              m.Size,
-		Image:             godo.DropletCreateImage{ID: imageID},
+		Image:             godo.DropletCreateImage{ID: image},
 		PrivateNetworking: true,
+		IPv6:              m.IPv6,
 		UserData:          cloudConfig,
+		Tags:              dropletTags(m.Tags),
 	}
 
 	d, _, err := prvdr.CreateDroplet(createReq)
@@ -195,7 +390,128 @@ func (prvdr Provider) createAndAttach(m db.Machine) error {
 		d, _, err := prvdr.GetDroplet(d.ID)
 		return err == nil && d.Status == "active"
 	}
-	return wait.Wait(pred)
+	if err := wait.Wait(pred); err != nil {
+		return err
+	}
+
+	for _, v := range m.Volumes {
+		if err := prvdr.attachVolume(v, persistent[v.Name], d.ID); err != nil {
+			return fmt.Errorf("attach volume %s: %s", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// persistentVolumes returns the existing DigitalOcean volumes that back the
+// persistent `volumes`, by name.  It returns an error if one of them can't be
+// attached.
+func (prvdr Provider) persistentVolumes(volumes []blueprint.MachineVolume) (
+	map[string]string, error) {
+	ids := map[string]string{}
+	for _, v := range volumes {
+		if !v.Persistent {
+			continue
+		}
+
+		vols, _, err := prvdr.ListVolumes(&godo.ListVolumeParams{
+			Region: prvdr.region,
+			Name:   prvdr.volumeName(v, 0),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list volumes: %s", err)
+		}
+
+		if len(vols) == 0 {
+			continue
+		}
+
+		vol := vols[0]
+		switch {
+		case len(vol.DropletIDs) != 0:
+			return nil, fmt.Errorf("volume %s is attached to droplet %d",
+				v.Name, vol.DropletIDs[0])
+		case int(vol.SizeGigaBytes) != v.Size:
+			return nil, fmt.Errorf("volume %s is %dGB, not %dGB", v.Name,
+				vol.SizeGigaBytes, v.Size)
+		}
+		ids[v.Name] = vol.ID
+	}
+	return ids, nil
+}
+
+// attachVolume attaches the volume `id`, which backs `v`, to the droplet
+// `dropletID`, and waits for it to be attached.  The volume is created if `id`
+// is empty.
+func (prvdr Provider) attachVolume(v blueprint.MachineVolume, id string,
+	dropletID int) error {
+	if id == "" {
+		vol, _, err := prvdr.CreateVolume(&godo.VolumeCreateRequest{
+			Region:        prvdr.region,
+			Name:          prvdr.volumeName(v, dropletID),
+			Description:   v.Name,
+			SizeGigaBytes: int64(v.Size),
+		})
+		if err != nil {
+			return fmt.Errorf("create: %s", err)
+		}
+		id = vol.ID
+	}
+
+	if _, _, err := prvdr.AttachVolume(id, dropletID); err != nil {
+		return err
+	}
+
+	return wait.Wait(func() bool {
+		vol, _, err := prvdr.GetVolume(id)
+		if err != nil {
+			return false
+		}
+		for _, attached := range vol.DropletIDs {
+			if attached == dropletID {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// dropletTags converts `tags` into DigitalOcean's tags, which are plain names, by
+// joining each key and value with a colon.
+func dropletTags(tags map[string]string) []string {
+	var names []string
+	for key, val := range tags {
+		if val == "" {
+			names = append(names, key)
+		} else {
+			names = append(names, key+":"+val)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Quotas returns the droplet limit of the account.  The limit applies to the
+// droplets of all regions and namespaces, so they're all counted against it.
+func (prvdr Provider) Quotas() ([]quota.Quota, error) {
+	account, _, err := prvdr.GetAccount()
+	if err != nil {
+		return nil, fmt.Errorf("get account: %s", err)
+	}
+
+	droplets, err := prvdr.listDroplets(func(godo.Droplet) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	return []quota.Quota{{Resource: quota.Instances,
+		Limit: account.DropletLimit, Usage: len(droplets)}}, nil
+}
+
+// Capabilities returns the optional features that DigitalOcean supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true, IPv6: true,
+	}
 }
 
 // UpdateFloatingIPs updates Droplet to Floating IP associations.
@@ -257,7 +573,8 @@ func (prvdr Provider) syncFloatingIPs(curr, targets []db.Machine) error {
 	return nil
 }
 
-// Stop stops each machine and deletes their attached volumes.
+// Stop stops each machine, and deletes the volumes that Quilt attached to it,
+// unless they're persistent.
 func (prvdr Provider) Stop(machines []db.Machine) error {
 	errChan := make(chan error, len(machines))
 	for _, m := range machines {
@@ -281,6 +598,25 @@ func (prvdr Provider) deleteAndWait(ids string) error {
 		return err
 	}
 
+	d, _, err := prvdr.GetDroplet(id)
+	if err != nil {
+		return err
+	}
+
+	var volumeIDs []string
+	if d != nil {
+		for _, volumeID := range d.VolumeIDs {
+			vol, _, err := prvdr.GetVolume(volumeID)
+			if err != nil {
+				return fmt.Errorf("get volume: %s", err)
+			}
+
+			if v, ok := prvdr.parseVolume(*vol, id); ok && !v.Persistent {
+				volumeIDs = append(volumeIDs, volumeID)
+			}
+		}
+	}
+
 	_, err = prvdr.DeleteDroplet(id)
 	if err != nil {
 		return err
@@ -290,7 +626,32 @@ func (prvdr Provider) deleteAndWait(ids string) error {
 		d, _, err := prvdr.GetDroplet(id)
 		return err != nil || d == nil
 	}
-	return wait.Wait(pred)
+	if err := wait.Wait(pred); err != nil {
+		return err
+	}
+
+	// Destroying the droplet detached its volumes, so they can be deleted.
+	for _, volumeID := range volumeIDs {
+		if _, err := prvdr.DeleteVolume(volumeID); err != nil {
+			return fmt.Errorf("delete volume: %s", err)
+		}
+	}
+	return nil
+}
+
+// Reboot power cycles the droplets of `machines`.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		id, err := strconv.Atoi(m.CloudID)
+		if err != nil {
+			return err
+		}
+
+		if _, _, err := prvdr.RebootDroplet(id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // SetACLs is not supported in DigitalOcean.
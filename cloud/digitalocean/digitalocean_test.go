@@ -15,8 +15,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/cloud/digitalocean/client/mocks"
+	"github.com/kelda/kelda/cloud/quota"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/util"
 )
@@ -43,6 +46,18 @@ var network = &godo.Networks{
 	},
 }
 
+var ipv6Network = &godo.Networks{
+	V4: network.V4,
+	V6: []godo.NetworkV6{
+		{
+			IPAddress: "publicIPv6",
+			Netmask:   64,
+			Gateway:   "::1",
+			Type:      "public",
+		},
+	},
+}
+
 var sfo = &godo.Region{
 	Slug: DefaultRegion,
 }
@@ -82,7 +97,7 @@ func TestList(t *testing.T) {
 		{
 			ID:        125,
 			Name:      testNamespace,
-			Networks:  network,
+			Networks:  ipv6Network,
 			SizeSlug:  "size",
 			VolumeIDs: []string{"foo"},
 			Region:    sfo,
@@ -144,6 +159,8 @@ func TestList(t *testing.T) {
 			CloudID:     "125",
 			PublicIP:    "publicIP",
 			PrivateIP:   "privateIP",
+			PublicIPv6:  "publicIPv6",
+			IPv6:        true,
 			FloatingIP:  "floatingIP",
 			Size:        "size",
 			Preemptible: false,
@@ -237,6 +254,127 @@ func TestBoot(t *testing.T) {
 	assert.EqualError(t, err, errMsg)
 }
 
+func TestBootIPv6(t *testing.T) {
+	mc := new(mocks.Client)
+	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
+	assert.Nil(t, err)
+	doPrvdr.Client = mc
+
+	util.Sleep = func(t time.Duration) {}
+
+	mc.On("CreateDroplet", mock.MatchedBy(func(req *godo.DropletCreateRequest) bool {
+		return req.IPv6
+	})).Return(&godo.Droplet{ID: 123}, nil, nil).Once()
+	mc.On("GetDroplet", 123).Return(
+		&godo.Droplet{Status: "active"}, nil, nil).Once()
+
+	assert.NoError(t, doPrvdr.Boot([]db.Machine{{Size: "size", IPv6: true}}))
+	mc.AssertExpectations(t)
+}
+
+func TestBootVolumes(t *testing.T) {
+	mc := new(mocks.Client)
+	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
+	assert.Nil(t, err)
+	doPrvdr.Client = mc
+
+	util.Sleep = func(t time.Duration) {}
+
+	m := db.Machine{
+		Size: "size",
+		Volumes: []blueprint.MachineVolume{
+			{Name: "data", Size: 100, Persistent: true},
+			{Name: "scratch", Size: 10},
+		},
+	}
+
+	// The persistent volume exists, so only the other volume is created.
+	mc.On("ListVolumes", &godo.ListVolumeParams{
+		Region: DefaultRegion,
+		Name:   "namespace-data",
+	}).Return([]godo.Volume{{ID: "data", SizeGigaBytes: 100}}, nil, nil).Once()
+	mc.On("CreateDroplet", mock.Anything).Return(
+		&godo.Droplet{ID: 123}, nil, nil).Once()
+	mc.On("GetDroplet", 123).Return(
+		&godo.Droplet{Status: "active"}, nil, nil).Once()
+	mc.On("CreateVolume", &godo.VolumeCreateRequest{
+		Region:        DefaultRegion,
+		Name:          "namespace-scratch-123",
+		Description:   "scratch",
+		SizeGigaBytes: 10,
+	}).Return(&godo.Volume{ID: "scratch"}, nil, nil).Once()
+	mc.On("AttachVolume", "data", 123).Return(nil, nil, nil).Once()
+	mc.On("AttachVolume", "scratch", 123).Return(nil, nil, nil).Once()
+	mc.On("GetVolume", "data").Return(
+		&godo.Volume{DropletIDs: []int{123}}, nil, nil).Once()
+	mc.On("GetVolume", "scratch").Return(
+		&godo.Volume{DropletIDs: []int{123}}, nil, nil).Once()
+
+	assert.NoError(t, doPrvdr.Boot([]db.Machine{m}))
+	mc.AssertExpectations(t)
+
+	// Persistent volumes that are in use, or have changed size, aren't attached,
+	// and the droplet isn't created.
+	mc.On("ListVolumes", mock.Anything).Return([]godo.Volume{
+		{ID: "data", SizeGigaBytes: 100, DropletIDs: []int{124}},
+	}, nil, nil).Once()
+	assert.EqualError(t, doPrvdr.Boot([]db.Machine{m}),
+		"volume data is attached to droplet 124")
+
+	mc.On("ListVolumes", mock.Anything).Return([]godo.Volume{
+		{ID: "data", SizeGigaBytes: 50},
+	}, nil, nil).Once()
+	assert.EqualError(t, doPrvdr.Boot([]db.Machine{m}),
+		"volume data is 50GB, not 100GB")
+	mc.AssertNumberOfCalls(t, "CreateDroplet", 1)
+}
+
+func TestListVolumes(t *testing.T) {
+	mc := new(mocks.Client)
+	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
+	assert.Nil(t, err)
+	doPrvdr.Client = mc
+
+	mc.On("ListFloatingIPs", mock.Anything).Return(nil, &godo.Response{}, nil)
+	mc.On("ListDroplets", mock.Anything).Return([]godo.Droplet{{
+		ID:        123,
+		Name:      testNamespace,
+		Networks:  network,
+		Region:    sfo,
+		VolumeIDs: []string{"scratch", "other", "data"},
+	}}, &godo.Response{}, nil)
+	mc.On("GetVolume", "scratch").Return(&godo.Volume{
+		Name:          "namespace-scratch-123",
+		Description:   "scratch",
+		SizeGigaBytes: 10,
+	}, nil, nil)
+	mc.On("GetVolume", "other").Return(&godo.Volume{
+		Name:          "other",
+		SizeGigaBytes: 20,
+	}, nil, nil)
+	mc.On("GetVolume", "data").Return(&godo.Volume{
+		Name:          "namespace-data",
+		Description:   "data",
+		SizeGigaBytes: 100,
+	}, nil, nil)
+
+	machines, err := doPrvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, []blueprint.MachineVolume{
+		{Name: "data", Size: 100, Persistent: true},
+		{Name: "scratch", Size: 10},
+	}, machines[0].Volumes)
+}
+
+func TestDropletTags(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, dropletTags(nil))
+	assert.Equal(t, []string{"cost-center:research", "team"},
+		dropletTags(map[string]string{"team": "", "cost-center": "research"}))
+}
+
 func TestBootPreemptible(t *testing.T) {
 	t.Parallel()
 
@@ -273,6 +411,12 @@ func TestStop(t *testing.T) {
 		VolumeIDs: []string{"abc"},
 	}, nil, nil).Once()
 
+	mc.On("GetVolume", "abc").Return(&godo.Volume{
+		ID:          "abc",
+		Name:        "namespace-data-123",
+		Description: "data",
+	}, nil, nil).Once()
+
 	mc.On("GetDroplet", 123).Return(nil, nil, nil).Once()
 
 	mc.On("DeleteDroplet", 123).Return(nil, nil).Once()
@@ -283,8 +427,30 @@ func TestStop(t *testing.T) {
 
 	// Make sure machines are stopped.
 	mc.AssertNumberOfCalls(t, "GetDroplet", 2)
+	mc.AssertNumberOfCalls(t, "DeleteVolume", 1)
 	assert.Nil(t, err)
 
+	// Persistent volumes, and volumes attached outside of Quilt, are kept.
+	mc.On("GetDroplet", 123).Return(&godo.Droplet{
+		Status:    "active",
+		VolumeIDs: []string{"persistent", "other"},
+	}, nil, nil).Once()
+	mc.On("GetVolume", "persistent").Return(&godo.Volume{
+		ID:          "persistent",
+		Name:        "namespace-data",
+		Description: "data",
+	}, nil, nil).Once()
+	mc.On("GetVolume", "other").Return(&godo.Volume{
+		ID:   "other",
+		Name: "other",
+	}, nil, nil).Once()
+	mc.On("GetDroplet", 123).Return(nil, nil, nil).Once()
+	mc.On("DeleteDroplet", 123).Return(nil, nil).Once()
+
+	err = doPrvdr.Stop(stopSet)
+	assert.NoError(t, err)
+	mc.AssertNumberOfCalls(t, "DeleteVolume", 1)
+
 	// Error strconv.
 	badDoubleStopSet := []db.Machine{
 		{
@@ -310,12 +476,91 @@ func TestStop(t *testing.T) {
 		Status:    "active",
 		VolumeIDs: []string{"abc"},
 	}, nil, nil).Once()
+	mc.On("GetVolume", "abc").Return(&godo.Volume{ID: "abc"}, nil, nil).Once()
 
 	mc.On("DeleteDroplet", 123).Return(nil, errMock).Once()
 	err = doPrvdr.Stop(stopSet)
 	assert.EqualError(t, err, errMsg)
 }
 
+func TestReboot(t *testing.T) {
+	mc := new(mocks.Client)
+	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
+	assert.Nil(t, err)
+	doPrvdr.Client = mc
+
+	mc.On("RebootDroplet", 123).Return(nil, nil, nil).Once()
+	assert.NoError(t, doPrvdr.Reboot([]db.Machine{{CloudID: "123"}}))
+	mc.AssertExpectations(t)
+
+	assert.Error(t, doPrvdr.Reboot([]db.Machine{{CloudID: "123a"}}))
+
+	mc.On("RebootDroplet", 123).Return(nil, nil, errMock).Once()
+	assert.EqualError(t, doPrvdr.Reboot([]db.Machine{{CloudID: "123"}}), errMsg)
+}
+
+func TestResources(t *testing.T) {
+	mc := new(mocks.Client)
+	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
+	assert.Nil(t, err)
+	doPrvdr.Client = mc
+
+	resp := &godo.Response{Links: &godo.Links{}}
+	mc.On("ListFloatingIPs", mock.Anything).Return([]godo.FloatingIP{
+		{Droplet: &godo.Droplet{ID: 123}, IP: "floatingIP"}}, resp, nil)
+	mc.On("ListDroplets", mock.Anything).Return([]godo.Droplet{{
+		ID:        123,
+		Name:      testNamespace,
+		Region:    sfo,
+		Tags:      []string{"tag"},
+		Created:   "2017-10-13T17:00:00Z",
+		VolumeIDs: []string{"vol"},
+	}, {
+		ID:     124,
+		Name:   "other",
+		Region: sfo,
+	}}, resp, nil)
+
+	resources, err := doPrvdr.Resources()
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{Type: resource.Instance, ID: "123", Name: testNamespace,
+			Tags:    map[string]string{"tag": ""},
+			Created: time.Date(2017, 10, 13, 17, 0, 0, 0, time.UTC)},
+		{Type: resource.Volume, ID: "vol"},
+		{Type: resource.IP, ID: "floatingIP"},
+	}, resources)
+}
+
+func TestQuotas(t *testing.T) {
+	mc := new(mocks.Client)
+	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
+	assert.Nil(t, err)
+	doPrvdr.Client = mc
+
+	// Droplets of other namespaces and regions count against the limit.
+	resp := &godo.Response{Links: &godo.Links{}}
+	mc.On("GetAccount").Return(&godo.Account{DropletLimit: 10}, nil, nil).Once()
+	mc.On("ListDroplets", mock.Anything).Return([]godo.Droplet{
+		{ID: 123, Name: testNamespace, Region: sfo},
+		{ID: 124, Name: "other", Region: &godo.Region{Slug: "nyc1"}},
+	}, resp, nil).Once()
+
+	quotas, err := doPrvdr.Quotas()
+	assert.NoError(t, err)
+	assert.Equal(t, []quota.Quota{
+		{Resource: quota.Instances, Limit: 10, Usage: 2}}, quotas)
+
+	mc.On("GetAccount").Return(nil, nil, errMock).Once()
+	_, err = doPrvdr.Quotas()
+	assert.EqualError(t, err, "get account: "+errMsg)
+
+	mc.On("GetAccount").Return(&godo.Account{DropletLimit: 10}, nil, nil).Once()
+	mc.On("ListDroplets", mock.Anything).Return(nil, nil, errMock).Once()
+	_, err = doPrvdr.Quotas()
+	assert.EqualError(t, err, "list droplets: "+errMsg)
+}
+
 func TestSetACLs(t *testing.T) {
 	doPrvdr, err := newDigitalOcean(testNamespace, DefaultRegion)
 	assert.Nil(t, err)
@@ -479,3 +724,26 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, client, outClient)
 	assert.EqualError(t, err, errMsg)
 }
+
+func TestReadAPIKey(t *testing.T) {
+	fs := util.AppFs
+	defer func() { util.AppFs = fs }()
+
+	util.AppFs = afero.NewMemMapFs()
+	keyFile := filepath.Join(os.Getenv("HOME"), apiKeyPath)
+	_, err := readAPIKey()
+	assert.EqualError(t, err, "no DigitalOcean credentials found in "+keyFile+
+		", or the DIGITALOCEAN_ACCESS_TOKEN environment variable")
+
+	util.WriteFile(keyFile, []byte("fileKey\n"), 0600)
+	key, err := readAPIKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "fileKey", key)
+
+	// The environment takes precedence over the key file.
+	os.Setenv(apiKeyEnv, "envKey")
+	defer os.Unsetenv(apiKeyEnv)
+	key, err = readAPIKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "envKey", key)
+}
@@ -0,0 +1,50 @@
+// Package endpoint overrides the API endpoints of the cloud providers, so that
+// Quilt can be tested against emulated clouds such as LocalStack.
+package endpoint
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"github.com/kelda/kelda/db"
+)
+
+// Overrides maps providers to the base URL of their API.  Providers that aren't
+// in the map use their public API.  It's set by the daemon.
+var Overrides = map[db.ProviderName]string{}
+
+// SkipTLSVerify disables verification of the certificates presented by
+// overridden endpoints, which emulators often sign themselves.  It's set by the
+// daemon.
+var SkipTLSVerify bool
+
+// Get returns the overridden API endpoint of `provider`, with a trailing slash,
+// or false if it isn't overridden.
+func Get(provider db.ProviderName) (string, bool) {
+	url, ok := Overrides[provider]
+	if !ok {
+		return "", false
+	}
+
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+	return url, true
+}
+
+// Client returns the HTTP client with which to connect to the API of `provider`.
+// It's `base`, unless the provider's endpoint is overridden and SkipTLSVerify is
+// set, in which case it's a copy of `base` that doesn't verify certificates.
+func Client(provider db.ProviderName, base *http.Client) *http.Client {
+	if _, ok := Overrides[provider]; !ok || !SkipTLSVerify {
+		return base
+	}
+
+	client := *base
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	return &client
+}
@@ -0,0 +1,43 @@
+package endpoint
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+)
+
+func TestEndpoint(t *testing.T) {
+	Overrides = map[db.ProviderName]string{
+		db.Google:       "http://localhost:8080/compute/v1/projects",
+		db.DigitalOcean: "https://do.test/v2/",
+	}
+	defer func() {
+		Overrides = map[db.ProviderName]string{}
+		SkipTLSVerify = false
+	}()
+
+	_, ok := Get(db.Amazon)
+	assert.False(t, ok)
+
+	url, ok := Get(db.Google)
+	assert.True(t, ok)
+	assert.Equal(t, "http://localhost:8080/compute/v1/projects/", url)
+
+	url, ok = Get(db.DigitalOcean)
+	assert.True(t, ok)
+	assert.Equal(t, "https://do.test/v2/", url)
+
+	base := &http.Client{}
+	assert.True(t, base == Client(db.Google, base))
+
+	SkipTLSVerify = true
+	assert.True(t, base == Client(db.Amazon, base))
+
+	client := Client(db.Google, base)
+	assert.False(t, base == client)
+	assert.True(t, client.Transport.(*http.Transport).TLSClientConfig.
+		InsecureSkipVerify)
+}
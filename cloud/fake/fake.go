@@ -0,0 +1,374 @@
+// Package fake implements a cloud provider whose machines exist only in the
+// daemon's memory.  Each machine runs a minion stub that the foreman configures
+// directly, so deployments can exercise the cloud's join and the foreman without
+// credentials.  API calls may be slowed down, or fail at random, to test how the
+// cloud copes with unreliable providers.
+package fake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/foreman"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/pb"
+	"github.com/kelda/kelda/util"
+)
+
+// DefaultRegion is assigned to Machines without a specified region.
+const DefaultRegion string = "local-1"
+
+// Regions supported by the Fake provider.
+var Regions = []string{"local-1", "local-2"}
+
+// The path, relative to the home directory, of the optional configuration.
+var configPath = filepath.Join(".quilt", "fake.json")
+
+// ErrInjected is returned by API calls that were chosen to fail.
+var ErrInjected = errors.New("injected failure")
+
+// config is the configuration in ~/.quilt/fake.json.  Durations are parsed by
+// time.ParseDuration.
+type config struct {
+	// How long each API call takes.
+	Latency string
+
+	// How long machines take to boot or reboot before their minion responds.
+	BootTime string
+
+	// The probability, between 0 and 1, that each API call fails.
+	FailureRate float64
+}
+
+// The Provider object tracks the machines of a namespace and region.  Machines
+// are kept in a package-level store, so they outlive the Provider.
+type Provider struct {
+	namespace string
+	region    string
+
+	latency, bootTime time.Duration
+	failureRate       float64
+}
+
+// A machine booted by the provider.
+type machine struct {
+	db.Machine
+	created time.Time
+	minion  *minion
+}
+
+// A minion stub.  It reports the role that the machine was booted with, once the
+// machine has finished booting, and accepts whatever configuration it's sent.
+type minion struct {
+	sync.Mutex
+	config  pb.MinionConfig
+	readyAt time.Time
+}
+
+// The machines of each namespace and region, keyed by CloudID, and the
+// namespace's ACLs.
+var store = struct {
+	sync.Mutex
+	machines map[[2]string]map[string]*machine
+	acls     map[[2]string][]acl.ACL
+	nextID   int
+}{
+	machines: map[[2]string]map[string]*machine{},
+	acls:     map[[2]string][]acl.ACL{},
+}
+
+var c = counter.New("Fake")
+
+// Allow mocking out for the unit tests.
+var sleep = time.Sleep
+var random = rand.Float64
+var now = time.Now
+
+// New creates a Provider for the namespace's machines in `region`.
+func New(namespace, region string) (*Provider, error) {
+	conf, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	prvdr := &Provider{
+		namespace:   namespace,
+		region:      region,
+		failureRate: conf.FailureRate,
+	}
+
+	if conf.Latency != "" {
+		if prvdr.latency, err = time.ParseDuration(conf.Latency); err != nil {
+			return nil, fmt.Errorf("parse latency: %s", err)
+		}
+	}
+
+	if conf.BootTime != "" {
+		if prvdr.bootTime, err = time.ParseDuration(conf.BootTime); err != nil {
+			return nil, fmt.Errorf("parse boot time: %s", err)
+		}
+	}
+	return prvdr, nil
+}
+
+// readConfig reads the configuration, which is optional.
+func readConfig() (config, error) {
+	var conf config
+
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+	confStr, err := util.ReadFile(path)
+	if os.IsNotExist(err) {
+		return conf, nil
+	} else if err != nil {
+		return conf, err
+	}
+
+	if err := json.Unmarshal([]byte(confStr), &conf); err != nil {
+		return conf, fmt.Errorf("parse %s: %s", path, err)
+	}
+
+	if conf.FailureRate < 0 || conf.FailureRate > 1 {
+		return conf, fmt.Errorf("%s: failureRate must be between 0 and 1", path)
+	}
+	return conf, nil
+}
+
+// call simulates an API call named `name`, returning ErrInjected if it was
+// chosen to fail.
+func (prvdr Provider) call(name string) error {
+	c.Inc(name)
+	sleep(prvdr.latency)
+	if random() < prvdr.failureRate {
+		c.Inc(name + " Failure")
+		return ErrInjected
+	}
+	return nil
+}
+
+func (prvdr Provider) key() [2]string {
+	return [2]string{prvdr.namespace, prvdr.region}
+}
+
+// List the machines of the namespace and region.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	if err := prvdr.call("List"); err != nil {
+		return nil, err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	var machines []db.Machine
+	for _, m := range store.machines[prvdr.key()] {
+		machines = append(machines, db.Machine{
+			CloudID:     m.CloudID,
+			PublicIP:    m.PublicIP,
+			PrivateIP:   m.PrivateIP,
+			FloatingIP:  m.FloatingIP,
+			Size:        m.Size,
+			DiskSize:    m.DiskSize,
+			Preemptible: m.Preemptible,
+		})
+	}
+
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].CloudID < machines[j].CloudID
+	})
+	return machines, nil
+}
+
+// Boot creates the machines, and registers their minions with the foreman.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	if err := prvdr.call("Boot"); err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	machines := store.machines[prvdr.key()]
+	if machines == nil {
+		machines = map[string]*machine{}
+		store.machines[prvdr.key()] = machines
+	}
+
+	for _, m := range bootSet {
+		store.nextID++
+		id := store.nextID
+
+		m.CloudID = fmt.Sprintf("fake-%d", id)
+		m.PublicIP = fmt.Sprintf("198.18.%d.%d", id/250, id%250+1)
+		m.PrivateIP = fmt.Sprintf("10.%d.%d.%d", id/62500, id/250%250, id%250+1)
+
+		lm := &minion{
+			config:  pb.MinionConfig{Role: db.RoleToPB(m.Role)},
+			readyAt: now().Add(prvdr.bootTime),
+		}
+		machines[m.CloudID] = &machine{Machine: m, created: now(), minion: lm}
+		foreman.RegisterLocalMinion(m.PublicIP, lm)
+	}
+	return nil
+}
+
+// Stop deletes the machines, and unregisters their minions.
+func (prvdr Provider) Stop(toStop []db.Machine) error {
+	if err := prvdr.call("Stop"); err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	machines := store.machines[prvdr.key()]
+	for _, m := range toStop {
+		fm, ok := machines[m.CloudID]
+		if !ok {
+			return fmt.Errorf("no machine %s", m.CloudID)
+		}
+
+		foreman.RegisterLocalMinion(fm.PublicIP, nil)
+		delete(machines, m.CloudID)
+	}
+	return nil
+}
+
+// Reboot makes the minions of the machines unresponsive until they've booted
+// again.
+func (prvdr Provider) Reboot(toReboot []db.Machine) error {
+	if err := prvdr.call("Reboot"); err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	machines := store.machines[prvdr.key()]
+	for _, m := range toReboot {
+		fm, ok := machines[m.CloudID]
+		if !ok {
+			return fmt.Errorf("no machine %s", m.CloudID)
+		}
+
+		fm.minion.Lock()
+		fm.minion.readyAt = now().Add(prvdr.bootTime)
+		fm.minion.Unlock()
+	}
+	return nil
+}
+
+// SetACLs records the namespace's ACLs, which may be retrieved with ACLs.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	if err := prvdr.call("Set ACLs"); err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	store.acls[prvdr.key()] = append([]acl.ACL(nil), acls...)
+	return nil
+}
+
+// ACLs returns the ACLs most recently set for the namespace's machines in
+// `region`.
+func ACLs(namespace, region string) []acl.ACL {
+	store.Lock()
+	defer store.Unlock()
+
+	return append([]acl.ACL(nil), store.acls[[2]string{namespace, region}]...)
+}
+
+// Capabilities returns the optional features that the Fake provider supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true, Preemptible: true,
+		ResizableDisks: true,
+	}
+}
+
+// UpdateFloatingIPs assigns each machine its floating IP.  Any IP may be used as
+// a floating IP.
+func (prvdr Provider) UpdateFloatingIPs(desired []db.Machine) error {
+	if err := prvdr.call("Update Floating IPs"); err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	machines := store.machines[prvdr.key()]
+	for _, m := range desired {
+		fm, ok := machines[m.CloudID]
+		if !ok {
+			return fmt.Errorf("no machine %s", m.CloudID)
+		}
+		fm.FloatingIP = m.FloatingIP
+	}
+	return nil
+}
+
+// Resources lists the namespace's machines in the region.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	if err := prvdr.call("Resources"); err != nil {
+		return nil, err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	var resources []resource.Resource
+	for _, m := range store.machines[prvdr.key()] {
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      m.CloudID,
+			Name:    m.PublicIP,
+			Tags:    map[string]string{"namespace": prvdr.namespace},
+			Created: m.created,
+		})
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].ID < resources[j].ID
+	})
+	return resources, nil
+}
+
+// GetMinionConfig returns the minion's configuration, or an error if its machine
+// is still booting.
+func (lm *minion) GetMinionConfig() (pb.MinionConfig, error) {
+	lm.Lock()
+	defer lm.Unlock()
+
+	if now().Before(lm.readyAt) {
+		return pb.MinionConfig{}, errors.New("machine is booting")
+	}
+	return lm.config, nil
+}
+
+// SetMinionConfig stores the configuration.  Like real minions, the stub keeps
+// the role that it booted with, and reports that it's drained as soon as it's
+// told to drain, as it never runs any containers.
+func (lm *minion) SetMinionConfig(cfg pb.MinionConfig) error {
+	lm.Lock()
+	defer lm.Unlock()
+
+	if now().Before(lm.readyAt) {
+		return errors.New("machine is booting")
+	}
+
+	cfg.Role = lm.config.Role
+	cfg.Drained = cfg.Drain
+	lm.config = cfg
+	return nil
+}
@@ -0,0 +1,225 @@
+package fake
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/pb"
+	"github.com/kelda/kelda/util"
+)
+
+func TestBootListStop(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	prvdr, err := New("ns", "local-1")
+	assert.NoError(t, err)
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+
+	err = prvdr.Boot([]db.Machine{
+		{Role: db.Master, Size: "fake-1x1", DiskSize: 32},
+		{Role: db.Worker, Size: "fake-2x4", Preemptible: true},
+	})
+	assert.NoError(t, err)
+
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 2)
+	assert.Equal(t, "fake-1x1", machines[0].Size)
+	assert.Equal(t, 32, machines[0].DiskSize)
+	assert.True(t, machines[1].Preemptible)
+
+	for _, m := range machines {
+		assert.NotEmpty(t, m.CloudID)
+		assert.NotEmpty(t, m.PublicIP)
+		assert.NotEmpty(t, m.PrivateIP)
+	}
+	assert.NotEqual(t, machines[0].PublicIP, machines[1].PublicIP)
+
+	// Machines are shared by all providers for the namespace and region.
+	other, err := New("ns", "local-1")
+	assert.NoError(t, err)
+	otherMachines, err := other.List()
+	assert.NoError(t, err)
+	assert.Equal(t, machines, otherMachines)
+
+	other, err = New("ns", "local-2")
+	assert.NoError(t, err)
+	otherMachines, err = other.List()
+	assert.NoError(t, err)
+	assert.Empty(t, otherMachines)
+
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, resource.Instance, resources[0].Type)
+	assert.Equal(t, machines[0].CloudID, resources[0].ID)
+	assert.Equal(t, map[string]string{"namespace": "ns"}, resources[0].Tags)
+
+	assert.NoError(t, prvdr.Stop(machines[:1]))
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+
+	err = prvdr.Stop([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "no machine missing")
+
+	assert.NoError(t, prvdr.Stop(machines))
+}
+
+func TestMinion(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	prvdr, err := New("minion", "local-1")
+	assert.NoError(t, err)
+	prvdr.bootTime = time.Minute
+
+	assert.NoError(t, prvdr.Boot([]db.Machine{{Role: db.Worker}}))
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	lm := store.machines[prvdr.key()][machines[0].CloudID].minion
+
+	// The minion doesn't respond until the machine has booted.
+	_, err = lm.GetMinionConfig()
+	assert.EqualError(t, err, "machine is booting")
+	assert.EqualError(t, lm.SetMinionConfig(pb.MinionConfig{}),
+		"machine is booting")
+
+	current = current.Add(time.Minute)
+	cfg, err := lm.GetMinionConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, pb.MinionConfig{Role: pb.MinionConfig_WORKER}, cfg)
+
+	// The role can't be changed, and draining completes immediately.
+	err = lm.SetMinionConfig(pb.MinionConfig{Role: pb.MinionConfig_MASTER,
+		Blueprint: "blueprint", Drain: true})
+	assert.NoError(t, err)
+	cfg, err = lm.GetMinionConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, pb.MinionConfig{Role: pb.MinionConfig_WORKER,
+		Blueprint: "blueprint", Drain: true, Drained: true}, cfg)
+
+	// Rebooting makes the minion unresponsive again.
+	assert.NoError(t, prvdr.Reboot(machines))
+	_, err = lm.GetMinionConfig()
+	assert.EqualError(t, err, "machine is booting")
+
+	current = current.Add(time.Minute)
+	_, err = lm.GetMinionConfig()
+	assert.NoError(t, err)
+
+	err = prvdr.Reboot([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "no machine missing")
+
+	assert.NoError(t, prvdr.Stop(machines))
+}
+
+func TestUpdateFloatingIPs(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	prvdr, err := New("fip", "local-1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, prvdr.Boot([]db.Machine{{Role: db.Worker}}))
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+
+	machines[0].FloatingIP = "8.8.8.8"
+	assert.NoError(t, prvdr.UpdateFloatingIPs(machines))
+
+	updated, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, "8.8.8.8", updated[0].FloatingIP)
+	assert.Equal(t, machines[0].PublicIP, updated[0].PublicIP)
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "no machine missing")
+
+	assert.NoError(t, prvdr.Stop(machines))
+}
+
+func TestSetACLs(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	prvdr, err := New("acls", "local-1")
+	assert.NoError(t, err)
+
+	acls := []acl.ACL{{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80}}
+	assert.NoError(t, prvdr.SetACLs(acls))
+	assert.Equal(t, acls, ACLs("acls", "local-1"))
+	assert.Empty(t, ACLs("acls", "local-2"))
+}
+
+func TestInjectedFailures(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	var slept time.Duration
+	sleep = func(d time.Duration) { slept += d }
+	defer func() { sleep = time.Sleep }()
+
+	prvdr, err := New("failures", "local-1")
+	assert.NoError(t, err)
+	prvdr.latency = time.Second
+	prvdr.failureRate = 0.5
+
+	random = func() float64 { return 0.4 }
+	defer func() { random = rand.Float64 }()
+
+	_, err = prvdr.List()
+	assert.Equal(t, ErrInjected, err)
+	assert.Equal(t, ErrInjected, prvdr.Boot([]db.Machine{{Role: db.Worker}}))
+	assert.Equal(t, ErrInjected, prvdr.SetACLs(nil))
+	assert.Equal(t, 3*time.Second, slept)
+
+	random = func() float64 { return 0.5 }
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+}
+
+func TestReadConfig(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+
+	// The configuration is optional.
+	prvdr, err := New("ns", "local-1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), prvdr.latency)
+	assert.Equal(t, time.Duration(0), prvdr.bootTime)
+	assert.Equal(t, 0.0, prvdr.failureRate)
+
+	util.WriteFile(path, []byte(`{"latency": "2s", "bootTime": "1m",
+		"failureRate": 0.25}`), 0600)
+	prvdr, err = New("ns", "local-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, prvdr.latency)
+	assert.Equal(t, time.Minute, prvdr.bootTime)
+	assert.Equal(t, 0.25, prvdr.failureRate)
+
+	util.WriteFile(path, []byte(`{"failureRate": 2}`), 0600)
+	_, err = New("ns", "local-1")
+	assert.EqualError(t, err, path+": failureRate must be between 0 and 1")
+
+	util.WriteFile(path, []byte(`{"latency": "soon"}`), 0600)
+	_, err = New("ns", "local-1")
+	assert.EqualError(t, err, `parse latency: time: invalid duration "soon"`)
+
+	util.WriteFile(path, []byte(`not json`), 0600)
+	_, err = New("ns", "local-1")
+	assert.Error(t, err)
+}
@@ -1,7 +1,10 @@
 package foreman
 
 import (
+	"errors"
+	"net/url"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -9,10 +12,17 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/hooks"
 	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/etcd"
 	"github.com/kelda/kelda/minion/pb"
+	"github.com/kelda/kelda/version"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -22,6 +32,28 @@ var minions map[string]*minion
 // Credentials that the foreman should use to connect to its minions.
 var Credentials connection.Credentials
 
+// Proxy, if set, is the proxy through which the foreman connects to its minions.
+var Proxy connection.Dialer
+
+// CA is the daemon's certificate authority.  It signs the configs sent to the
+// minions, which reject configs that aren't signed by it, and the etcd passwords
+// of the minions are derived from it.
+var CA *rsa.KeyPair
+
+// SelfHostBundle is the daemon's bundled state (see the selfhost package), which
+// is sent to the masters of self-hosted namespaces.  Set by the daemon.
+var SelfHostBundle []byte
+
+// SelfHosted is whether this daemon is the one that the masters of a self-hosted
+// namespace run.  Other daemons step down once it's running.  Set by the daemon.
+var SelfHosted bool
+
+// Whether this daemon has handed the namespace over to the self-hosted daemon.
+var steppedDown = struct {
+	sync.Mutex
+	value bool
+}{}
+
 // ConnectionTrigger sends messages when a change to the connection status of a
 // minion occurs.
 // The sends are non-blocking, so if there is already a notification in the
@@ -49,20 +81,58 @@ type minion struct {
 	machine db.Machine
 	config  pb.MinionConfig
 
+	// The config most recently sent to the minion, without its generation.
+	// The generation is incremented whenever the config changes.  Signing is
+	// relatively slow, so the signature of the config is only recomputed when
+	// its generation changes.
+	desired    pb.MinionConfig
+	generation int64
+	signature  []byte
+
+	// The error with which the minion rejected the most recent config, if it
+	// did.
+	pushErr string
+
+	// Maps partitions to the highest disk usage threshold that they've been
+	// alerted for.
+	diskAlerts map[string]int
+
+	// Maps the hostnames of the minion's containers to the certificates issued
+	// to their TLS proxies.
+	containerCerts map[string]containerCert
+
 	mark bool /* Mark and sweep garbage collection. */
 }
 
+// A containerCert is a certificate issued to the TLS proxy of a container, and
+// the public key that it was issued for.
+type containerCert struct {
+	key, cert string
+}
+
 var c = counter.New("Foreman")
 
+// Disk-usage hooks run when the usage of a partition rises past one of these
+// percentages.
+var diskUsageThresholds = []int{80, 90, 95}
+
+// The ACLs most recently applied by each cloud, keyed by provider and region, so
+// that they may be mirrored by the firewalls of the cloud's minions.
+var cloudACLs = struct {
+	sync.Mutex
+	acls map[cloudKey][]acl.ACL
+}{acls: map[cloudKey][]acl.ACL{}}
+
+type cloudKey struct {
+	provider db.ProviderName
+	region   string
+}
+
 // Init the first time the foreman operates on a new namespace.  It queries the currently
 // running VMs for their previously assigned roles, and writes them to the database.
 func Init(conn db.Conn) {
 	c.Inc("Initialize")
-
-	for _, m := range minions {
-		m.client.Close()
-	}
-	minions = map[string]*minion{}
+	Close()
 
 	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
 		machines := view.SelectFromMachine(func(m db.Machine) bool {
@@ -75,14 +145,25 @@ func Init(conn db.Conn) {
 	})
 }
 
+// Close closes the connections to all minions, and returns how many there were.
+func Close() int {
+	count := len(minions)
+	for _, m := range minions {
+		m.client.Close()
+	}
+	minions = map[string]*minion{}
+	return count
+}
+
 // RunOnce should be called regularly to allow the foreman to update minion cfg.
 func RunOnce(conn db.Conn) {
 	c.Inc("Run")
 
 	var blueprint string
+	var diskGC, hostFirewall, selfHost, disableSelfHost bool
 	var machines []db.Machine
-	conn.Txn(db.BlueprintTable,
-		db.MachineTable).Run(func(view db.Database) error {
+	conn.Txn(db.BlueprintTable, db.MachineTable,
+		db.SettingsTable).Run(func(view db.Database) error {
 
 		machines = view.SelectFromMachine(func(m db.Machine) bool {
 			return m.PublicIP != "" && m.PrivateIP != ""
@@ -90,6 +171,11 @@ func RunOnce(conn db.Conn) {
 
 		bp, _ := view.GetBlueprint()
 		blueprint = bp.Blueprint.String()
+		settings := view.GetSettings(bp.Namespace)
+		diskGC = settings.Enabled(db.DiskGC)
+		hostFirewall = settings.Enabled(db.HostFirewall)
+		selfHost = settings.Enabled(db.SelfHost)
+		disableSelfHost = settings.Disabled(db.SelfHost)
 
 		return nil
 	})
@@ -97,13 +183,33 @@ func RunOnce(conn db.Conn) {
 	updateMinionMap(machines)
 	forEachMinion(updateConfig)
 
-	var etcdIPs []string
+	// Once the masters run the self-hosted daemon, this daemon stops sending
+	// configs, so that the two don't both manage the namespace.  It only
+	// takes over again if self-hosting is explicitly turned off, in which case
+	// the masters are told to stop the self-hosted daemon.
+	setSteppedDown(!SelfHosted && !disableSelfHost && selfHostRunning())
+	if SteppedDown() {
+		return
+	}
+
+	var etcdIPs, workerIPs []string
 	for _, m := range minions {
-		if m.config.Role == pb.MinionConfig_MASTER && m.machine.PrivateIP != "" {
+		if m.machine.PrivateIP == "" {
+			continue
+		}
+
+		switch m.config.Role {
+		case pb.MinionConfig_MASTER:
 			etcdIPs = append(etcdIPs, m.machine.PrivateIP)
+		case pb.MinionConfig_WORKER:
+			workerIPs = append(workerIPs, m.machine.PrivateIP)
 		}
 	}
 
+	// Sort the IPs so that the configs only change when they do.
+	sort.Strings(etcdIPs)
+	sort.Strings(workerIPs)
+
 	// Assign all of the minions their new configs
 	forEachMinion(func(m *minion) {
 		if !m.connected {
@@ -113,25 +219,272 @@ func RunOnce(conn db.Conn) {
 		newConfig := pb.MinionConfig{
 			FloatingIP:     m.machine.FloatingIP,
 			PrivateIP:      m.machine.PrivateIP,
-			Blueprint:      blueprint,
+			PublicIP:       m.machine.PublicIP,
 			Provider:       string(m.machine.Provider),
 			Size:           m.machine.Size,
 			Region:         m.machine.Region,
+			Labels:         m.machine.Labels,
 			EtcdMembers:    etcdIPs,
 			AuthorizedKeys: m.machine.SSHKeys,
+			DiskGC:         diskGC,
+			Drain:          m.machine.Action != "" || m.machine.Interrupted,
+			EtcdPasswords:  etcdPasswords(m, workerIPs),
+			ContainerCerts: containerCerts(m),
+			APIVersion:     version.APIVersion,
+			MinAPIVersion:  version.MinAPIVersion,
+		}
+
+		// Only masters use the blueprint.  Workers learn about their
+		// containers from the masters, so they aren't sent the blueprint, which
+		// contains the environment of every container in the deployment.
+		if m.config.Role == pb.MinionConfig_MASTER {
+			newConfig.Blueprint = blueprint
+		}
+
+		// The bundle contains the daemon's private keys, so it's only sent to
+		// the masters, which run the daemon if the namespace is self-hosted.
+		if m.config.Role == pb.MinionConfig_MASTER && selfHost {
+			newConfig.SelfHost = SelfHostBundle
 		}
 
-		if reflect.DeepEqual(newConfig, m.config) {
+		// The self-hosted daemon's state is only removed from the masters
+		// when self-hosting is turned off, rather than whenever a config
+		// lacks it.
+		if m.config.Role == pb.MinionConfig_MASTER && disableSelfHost {
+			newConfig.DisableSelfHost = true
+		}
+
+		if hostFirewall {
+			newConfig.ACLs = minionACLs(m.machine, m.config.Role, machines)
+		}
+
+		if !reflect.DeepEqual(newConfig, m.desired) {
+			// The minion may have applied configs from before the daemon
+			// restarted, so the generation continues from the one it reports.
+			m.desired = newConfig
+			m.generation = maxGeneration(m.generation, m.config.Generation) + 1
+			m.signature = nil
+			notifyConnectionChange()
+		}
+		newConfig.Generation = m.generation
+
+		// Minions reject configs whose generation isn't higher than that of
+		// the config they last applied.
+		if m.config.Generation >= m.generation {
 			return
 		}
 
+		if m.signature == nil {
+			signature, err := signConfig(newConfig)
+			if err != nil {
+				log.WithError(err).Error("Failed to sign minion config.")
+				return
+			}
+			m.signature = signature
+		}
+		newConfig.Signature = m.signature
+
+		var pushErr string
 		if err := m.client.setMinion(newConfig); err != nil {
 			log.WithError(err).Error("Failed to set minion config.")
-			return
+			pushErr = err.Error()
+		}
+
+		if pushErr != m.pushErr {
+			m.pushErr = pushErr
+			notifyConnectionChange()
 		}
 	})
 }
 
+// selfHostRunning returns whether any master reports running the self-hosted
+// daemon.
+func selfHostRunning() bool {
+	for _, m := range minions {
+		if m.connected && m.config.Role == pb.MinionConfig_MASTER &&
+			m.config.SelfHosted {
+			return true
+		}
+	}
+	return false
+}
+
+// SteppedDown returns whether this daemon has handed the management of its
+// namespace over to the self-hosted daemon.
+func SteppedDown() bool {
+	steppedDown.Lock()
+	defer steppedDown.Unlock()
+	return steppedDown.value
+}
+
+func setSteppedDown(value bool) {
+	steppedDown.Lock()
+	defer steppedDown.Unlock()
+
+	if value == steppedDown.value {
+		return
+	}
+
+	if value {
+		c.Inc("Step Down")
+		log.Info("The self-hosted daemon is running, so this daemon will " +
+			"no longer manage the namespace.")
+	} else {
+		c.Inc("Take Over")
+		log.Info("Resuming management of the namespace.")
+	}
+	steppedDown.value = value
+}
+
+func maxGeneration(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// signConfig returns the signature of `cfg` by the CA.
+func signConfig(cfg pb.MinionConfig) ([]byte, error) {
+	if CA == nil {
+		return nil, errors.New("no certificate authority")
+	}
+
+	signed, err := cfg.SignedBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Inc("Sign")
+	return CA.Sign(signed)
+}
+
+// etcdPasswords returns the etcd passwords that should be sent to `m`.  Masters
+// are sent every password, so that they may create the users, while each worker
+// is only sent the password of its own user.  The passwords are derived from the
+// CA, so they're the same after the daemon restarts.
+func etcdPasswords(m *minion, workerIPs []string) map[string]string {
+	if CA == nil {
+		return nil
+	}
+
+	var users []string
+	switch m.config.Role {
+	case pb.MinionConfig_MASTER:
+		users = []string{etcd.RootUser}
+		for _, ip := range workerIPs {
+			users = append(users, etcd.WorkerUser(ip))
+		}
+	case pb.MinionConfig_WORKER:
+		if m.machine.PrivateIP == "" {
+			return nil
+		}
+		users = []string{etcd.WorkerUser(m.machine.PrivateIP)}
+	default:
+		return nil
+	}
+
+	passwords := map[string]string{}
+	for _, user := range users {
+		passwords[user] = CA.DeriveSecret("etcd-" + user)
+	}
+	return passwords
+}
+
+// containerCerts returns the certificates that should be sent to `m` for the TLS
+// proxies of its containers, which identify the proxies by the hostname of their
+// container.  The minion generates the proxies' keys and only reports the public
+// halves, so that neither its own key nor the proxies' keys leave the machine.
+// Certificates have random serial numbers, so they're only issued when a proxy's
+// key changes, lest the config change every time.
+func containerCerts(m *minion) map[string]string {
+	if CA == nil || len(m.config.ContainerKeys) == 0 {
+		m.containerCerts = nil
+		return nil
+	}
+
+	issued := map[string]containerCert{}
+	certs := map[string]string{}
+	for hostname, key := range m.config.ContainerKeys {
+		cc, ok := m.containerCerts[hostname]
+		if !ok || cc.key != key {
+			cert, err := rsa.SignPublicKey(*CA, key,
+				[]*url.URL{tls.ContainerURI(hostname)})
+			if err != nil {
+				log.WithError(err).WithField("hostname", hostname).Warn(
+					"Failed to issue container certificate.")
+				continue
+			}
+
+			c.Inc("Issue Container Cert")
+			cc = containerCert{key: key, cert: cert}
+		}
+		issued[hostname] = cc
+		certs[hostname] = cc.cert
+	}
+	m.containerCerts = issued
+	return certs
+}
+
+// SetACLs records the ACLs that the cloud of `provider` in `region` applied, so
+// that the host firewalls of its machines may enforce them as well.
+func SetACLs(provider db.ProviderName, region string, acls []acl.ACL) {
+	cloudACLs.Lock()
+	defer cloudACLs.Unlock()
+	cloudACLs.acls[cloudKey{provider, region}] = acls
+}
+
+// minionACLs returns the ACLs that the firewall of `m`, whose minion has `role`,
+// should enforce: those of its cloud, and access for the other machines in the
+// cluster.  Only masters serve etcd, so they expose its ports to the cluster
+// alone, while workers don't expose them at all.  If the cloud hasn't applied its
+// ACLs yet, nothing is returned so that the minion doesn't lock out the daemon.
+func minionACLs(m db.Machine, role pb.MinionConfig_Role,
+	machines []db.Machine) []*pb.ACL {
+
+	cloudACLs.Lock()
+	cloudACLsOfM := cloudACLs.acls[cloudKey{m.Provider, m.Region}]
+	cloudACLs.Unlock()
+
+	if len(cloudACLsOfM) == 0 {
+		return nil
+	}
+
+	master := role == pb.MinionConfig_MASTER
+	var acls []acl.ACL
+	for _, cloudACL := range cloudACLsOfM {
+		if master {
+			acls = append(acls, cloudACL.WithoutEtcd()...)
+		} else {
+			acls = append(acls, cloudACL)
+		}
+	}
+
+	for _, machine := range machines {
+		cidr := machine.PrivateIP + "/32"
+		if master {
+			acls = append(acls, acl.ACL{CidrIP: cidr, MinPort: 1,
+				MaxPort: 65535})
+		} else {
+			acls = append(acls, acl.AllPorts(cidr)...)
+		}
+	}
+
+	var result []*pb.ACL
+	for _, acl := range acls {
+		result = append(result, &pb.ACL{
+			CidrIP:  acl.CidrIP,
+			MinPort: int32(acl.MinPort),
+			MaxPort: int32(acl.MaxPort),
+		})
+	}
+
+	// Sort the ACLs so that the config only changes when they do.
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
 // GetMachineRole uses the minion map to find the associated minion with
 // the IP, according to the foreman's last update cycle.
 func GetMachineRole(pubIP string) db.Role {
@@ -147,11 +500,32 @@ func IsConnected(pubIP string) bool {
 	return ok && min.connected
 }
 
+// ConfigStatus returns the generation of the most recent config sent to the
+// minion at pubIP, the generation of the most recent config that the minion
+// reported applying, and the error with which it rejected the most recent config,
+// if any.  The final return value is false if the foreman isn't connected to the
+// minion.
+func ConfigStatus(pubIP string) (generation, applied int64, pushErr string, ok bool) {
+	min, ok := minions[pubIP]
+	if !ok || !min.connected {
+		return 0, 0, "", false
+	}
+	return min.generation, min.config.Generation, min.pushErr, true
+}
+
 func updateMinionMap(machines []db.Machine) {
 	for _, m := range machines {
 		min, ok := minions[m.PublicIP]
+		if ok && min.machine.CloudID != m.CloudID {
+			// The IP was reassigned to another machine, whose identity the
+			// existing client doesn't verify.
+			min.client.Close()
+			delete(minions, m.PublicIP)
+			ok = false
+		}
+
 		if !ok {
-			client, err := newClient(m.PublicIP)
+			client, err := newClient(m.PublicIP, m.CloudID)
 			if err != nil {
 				continue
 			}
@@ -185,7 +559,31 @@ func forEachMinion(do func(minion *minion)) {
 	wg.Wait()
 }
 
+// IsNetworkDegraded returns whether the minion at pubIP reported that its network
+// is degraded.
+func IsNetworkDegraded(pubIP string) bool {
+	min, ok := minions[pubIP]
+	return ok && min.connected && min.config.NetworkDegraded
+}
+
+// IsInterrupted returns whether the minion at pubIP reported that its machine is
+// about to be reclaimed by its provider.
+func IsInterrupted(pubIP string) bool {
+	min, ok := minions[pubIP]
+	return ok && min.connected && min.config.Interrupted
+}
+
+// IsDrained returns whether the minion at pubIP reported that it was told to
+// drain, and no containers remain scheduled on it.
+func IsDrained(pubIP string) bool {
+	min, ok := minions[pubIP]
+	return ok && min.connected && min.config.Drained
+}
+
 func updateConfig(m *minion) {
+	wasDegraded := m.config.NetworkDegraded
+	wasApplied := m.config.Generation
+
 	var err error
 	m.config, err = m.client.getMinion()
 	if err != nil {
@@ -196,7 +594,22 @@ func updateConfig(m *minion) {
 		}
 	}
 
+	if m.config.NetworkDegraded != wasDegraded {
+		notifyConnectionChange()
+		if m.config.NetworkDegraded {
+			c.Inc("Minion Network Degraded")
+		}
+	}
+
+	if m.config.Generation != wasApplied {
+		notifyConnectionChange()
+	}
+
 	connected := err == nil
+	if connected {
+		checkDiskUsage(m)
+	}
+
 	if connected == m.connected {
 		return
 	}
@@ -206,11 +619,62 @@ func updateConfig(m *minion) {
 	if m.connected {
 		c.Inc("Minion Connected")
 		log.WithField("machine", m.machine).Debug("New connection")
+
+		// Init holds a transaction while updating configs, so the hooks are
+		// run in the background.
+		go func(machine db.Machine) {
+			err := runHooks(blueprint.PostConnect, machine)
+			if err != nil {
+				log.WithError(err).WithField("machine", machine).Warn(
+					"Post-connect hooks failed.")
+			}
+		}(m.machine)
 	} else {
 		c.Inc("Minion Disconnected")
 	}
 }
 
+// checkDiskUsage runs the disk-usage hooks of the minion's machine when the usage
+// of one of its partitions rises past a threshold.  Each threshold is only
+// alerted once, until the partition's usage falls back below it.
+func checkDiskUsage(m *minion) {
+	alerts := map[string]int{}
+	for partition, usage := range m.config.DiskUsage {
+		threshold := diskUsageThreshold(int(usage))
+		alerts[partition] = threshold
+		if threshold <= m.diskAlerts[partition] {
+			continue
+		}
+
+		c.Inc("Minion Disk Usage")
+		log.WithFields(log.Fields{
+			"machine":   m.machine,
+			"partition": partition,
+		}).Warnf("Disk is %d%% full.", usage)
+
+		go func(machine db.Machine, partition string, usage int) {
+			err := runDiskHooks(machine, partition, usage)
+			if err != nil {
+				log.WithError(err).WithField("machine", machine).Warn(
+					"Disk-usage hooks failed.")
+			}
+		}(m.machine, partition, int(usage))
+	}
+	m.diskAlerts = alerts
+}
+
+// diskUsageThreshold returns the highest threshold that `usage` has reached, or
+// 0 if it hasn't reached any.
+func diskUsageThreshold(usage int) int {
+	var result int
+	for _, threshold := range diskUsageThresholds {
+		if usage >= threshold {
+			result = threshold
+		}
+	}
+	return result
+}
+
 func notifyConnectionChange() {
 	select {
 	case ConnectionTrigger <- struct{}{}:
@@ -218,9 +682,64 @@ func notifyConnectionChange() {
 	}
 }
 
-func newClientImpl(ip string) (client, error) {
+// A LocalMinion is a minion that runs within the daemon, such as those of the
+// Fake provider's machines, and so is configured without a network connection.
+type LocalMinion interface {
+	GetMinionConfig() (pb.MinionConfig, error)
+	SetMinionConfig(pb.MinionConfig) error
+}
+
+var localMinions = struct {
+	sync.Mutex
+	minions map[string]LocalMinion
+}{minions: map[string]LocalMinion{}}
+
+// RegisterLocalMinion makes the foreman configure the minion at the public IP
+// `ip` by calling `lm`, rather than connecting to it.  A nil `lm` unregisters
+// the minion.
+func RegisterLocalMinion(ip string, lm LocalMinion) {
+	localMinions.Lock()
+	defer localMinions.Unlock()
+
+	if lm == nil {
+		delete(localMinions.minions, ip)
+	} else {
+		localMinions.minions[ip] = lm
+	}
+}
+
+type localClient struct {
+	LocalMinion
+}
+
+func (cl localClient) getMinion() (pb.MinionConfig, error) {
+	return cl.GetMinionConfig()
+}
+
+func (cl localClient) setMinion(cfg pb.MinionConfig) error {
+	return cl.SetMinionConfig(cfg)
+}
+
+func (cl localClient) Close() {}
+
+// newClientImpl connects to the minion at `ip`.  If the credentials support it,
+// the minion must prove that it's running on the machine with the given cloud
+// ID, rather than on a stranger's machine that was assigned a recycled IP.
+func newClientImpl(ip, cloudID string) (client, error) {
+	localMinions.Lock()
+	lm, ok := localMinions.minions[ip]
+	localMinions.Unlock()
+	if ok {
+		return localClient{lm}, nil
+	}
+
 	c.Inc("New Minion Client")
-	cc, err := connection.Client("tcp", ip+":9999", Credentials.ClientOpts())
+	opts := Credentials.ClientOpts()
+	if creds, ok := Credentials.(connection.MachineCredentials); ok {
+		opts = creds.MachineClientOpts(cloudID)
+	}
+
+	cc, err := connection.ProxiedClient(Proxy, "tcp", ip+":9999", opts)
 	if err != nil {
 		c.Inc("New Minion Client Error")
 		return nil, err
@@ -231,6 +750,8 @@ func newClientImpl(ip string) (client, error) {
 
 // Storing in a variable allows us to mock it out for unit tests
 var newClient = newClientImpl
+var runHooks = hooks.Run
+var runDiskHooks = hooks.RunDiskUsage
 
 func (cl clientImpl) getMinion() (pb.MinionConfig, error) {
 	c.Inc("Get Minion")
@@ -241,6 +762,13 @@ func (cl clientImpl) getMinion() (pb.MinionConfig, error) {
 		return pb.MinionConfig{}, err
 	}
 
+	// Incompatible minions are treated as disconnected, so that they're never
+	// sent a config that they might misinterpret.
+	err = version.CheckCompatible("minion", cfg.APIVersion, cfg.MinAPIVersion)
+	if err != nil {
+		c.Inc("Incompatible Minion")
+		return pb.MinionConfig{}, err
+	}
 	return *cfg, nil
 }
 
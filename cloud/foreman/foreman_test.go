@@ -2,14 +2,25 @@ package foreman
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/hooks"
+	"github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/etcd"
 	"github.com/kelda/kelda/minion/pb"
 )
 
+// The certificate authority of the daemon in the tests.  It's only
+// generated once, as generating keys is slow.
+var testCA *rsa.KeyPair
+
 type clients struct {
 	clients  map[string]*fakeClient
 	newCalls int
@@ -169,6 +180,39 @@ func TestBootEtcdRoleConflict(t *testing.T) {
 	assert.Equal(t, []string{"m1-priv"}, clients.clients["w1-pub"].mc.EtcdMembers)
 }
 
+func TestRecycledIP(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_WORKER,
+	})
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.PublicIP = "1.1.1.1"
+		m.PrivateIP = "10.0.0.1"
+		m.CloudID = "old"
+		view.Commit(m)
+		return nil
+	})
+	RunOnce(conn)
+	assert.Equal(t, 1, clients.newCalls)
+	assert.Equal(t, "old", clients.clients["1.1.1.1"].cloudID)
+
+	// The client is replaced when the IP is reassigned to another machine, so
+	// that the new client verifies the identity of the new machine.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.SelectFromMachine(nil)[0]
+		m.CloudID = "new"
+		view.Commit(m)
+		return nil
+	})
+	RunOnce(conn)
+	assert.Equal(t, 2, clients.newCalls)
+	assert.Equal(t, "new", clients.clients["1.1.1.1"].cloudID)
+
+	RunOnce(conn)
+	assert.Equal(t, 2, clients.newCalls)
+}
+
 func TestGetMachineRole(t *testing.T) {
 	workerMinion := minion{
 		config: pb.MinionConfig{
@@ -214,6 +258,276 @@ func TestConnectionTrigger(t *testing.T) {
 	}
 }
 
+func TestPostConnectHooks(t *testing.T) {
+	ran := make(chan string, 10)
+	runHooks = func(event string, m db.Machine) error {
+		ran <- event + " " + m.PublicIP
+		return errors.New("hook failed")
+	}
+	defer func() { runHooks = hooks.Run }()
+
+	fc := &fakeClient{}
+	min := &minion{client: fc, machine: db.Machine{PublicIP: "1.2.3.4"}}
+
+	updateConfig(min)
+	assert.Equal(t, "post-connect 1.2.3.4", <-ran)
+
+	// The hooks only run when the connection is established.
+	updateConfig(min)
+	fc.getMinionError = true
+	updateConfig(min)
+	assert.Empty(t, ran)
+
+	fc.getMinionError = false
+	updateConfig(min)
+	assert.Equal(t, "post-connect 1.2.3.4", <-ran)
+}
+
+func TestDiskUsageHooks(t *testing.T) {
+	ran := make(chan string, 10)
+	runDiskHooks = func(m db.Machine, partition string, usage int) error {
+		ran <- fmt.Sprintf("%s %s %d", m.PublicIP, partition, usage)
+		return nil
+	}
+	defer func() { runDiskHooks = hooks.RunDiskUsage }()
+
+	fc := &fakeClient{}
+	min := &minion{client: fc, machine: db.Machine{PublicIP: "1.2.3.4"}}
+
+	fc.mc.DiskUsage = map[string]int32{"root": 50, "docker": 82}
+	updateConfig(min)
+	assert.Equal(t, "1.2.3.4 docker 82", <-ran)
+
+	// Each threshold is only alerted once.
+	fc.mc.DiskUsage = map[string]int32{"root": 50, "docker": 85}
+	updateConfig(min)
+	assert.Empty(t, ran)
+
+	fc.mc.DiskUsage = map[string]int32{"root": 50, "docker": 96}
+	updateConfig(min)
+	assert.Equal(t, "1.2.3.4 docker 96", <-ran)
+
+	// Disconnecting doesn't forget the alerts.
+	fc.getMinionError = true
+	updateConfig(min)
+	fc.getMinionError = false
+	updateConfig(min)
+	assert.Empty(t, ran)
+
+	// Once the usage falls below a threshold, it's alerted again.
+	fc.mc.DiskUsage = map[string]int32{"root": 50, "docker": 70}
+	updateConfig(min)
+	fc.mc.DiskUsage = map[string]int32{"root": 50, "docker": 81}
+	updateConfig(min)
+	assert.Equal(t, "1.2.3.4 docker 81", <-ran)
+	assert.Empty(t, ran)
+}
+
+func TestDiskGCSetting(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_MASTER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Namespace = "ns"
+		view.Commit(bp)
+
+		m := view.InsertMachine()
+		m.PublicIP = "1.1.1.1"
+		m.PrivateIP = "1.1.1.1."
+		m.CloudID = "ID"
+		view.Commit(m)
+		return nil
+	})
+
+	RunOnce(conn)
+	RunOnce(conn)
+	assert.False(t, clients.clients["1.1.1.1"].mc.DiskGC)
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		settings := view.InsertSettings()
+		settings.Namespace = "ns"
+		settings.Flags = map[string]bool{db.DiskGC: true}
+		view.Commit(settings)
+		return nil
+	})
+
+	RunOnce(conn)
+	assert.True(t, clients.clients["1.1.1.1"].mc.DiskGC)
+}
+
+func TestDrain(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.PublicIP = "1.1.1.1"
+		m.PrivateIP = "1.1.1.1."
+		m.CloudID = "ID"
+		view.Commit(m)
+		return nil
+	})
+
+	RunOnce(conn)
+	RunOnce(conn)
+	assert.False(t, clients.clients["1.1.1.1"].mc.Drain)
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.SelectFromMachine(nil)[0]
+		m.Action = db.RebootAction
+		view.Commit(m)
+		return nil
+	})
+
+	RunOnce(conn)
+	assert.True(t, clients.clients["1.1.1.1"].mc.Drain)
+
+	// Interrupted machines are drained too.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.SelectFromMachine(nil)[0]
+		m.Action = ""
+		m.Interrupted = true
+		view.Commit(m)
+		return nil
+	})
+
+	RunOnce(conn)
+	assert.True(t, clients.clients["1.1.1.1"].mc.Drain)
+}
+
+func TestConfigGeneration(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.PublicIP = "1.1.1.1"
+		m.PrivateIP = "1.1.1.1."
+		m.CloudID = "ID"
+		view.Commit(m)
+		return nil
+	})
+
+	_, _, _, ok := ConfigStatus("1.1.1.1")
+	assert.False(t, ok)
+
+	// The minion acknowledges the config once it's polled again.
+	RunOnce(conn)
+	assert.Equal(t, int64(1), clients.clients["1.1.1.1"].mc.Generation)
+	gen, applied, _, ok := ConfigStatus("1.1.1.1")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), gen)
+	assert.Equal(t, int64(0), applied)
+
+	RunOnce(conn)
+	gen, applied, pushErr, _ := ConfigStatus("1.1.1.1")
+	assert.Equal(t, int64(1), gen)
+	assert.Equal(t, int64(1), applied)
+	assert.Empty(t, pushErr)
+
+	// The generation only changes with the config.
+	RunOnce(conn)
+	gen, _, _, _ = ConfigStatus("1.1.1.1")
+	assert.Equal(t, int64(1), gen)
+
+	setAction := func(action string) {
+		conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			m := view.SelectFromMachine(nil)[0]
+			m.Action = action
+			view.Commit(m)
+			return nil
+		})
+	}
+
+	clients.clients["1.1.1.1"].setMinionError = true
+	setAction(db.RebootAction)
+	RunOnce(conn)
+	gen, applied, pushErr, _ = ConfigStatus("1.1.1.1")
+	assert.Equal(t, int64(2), gen)
+	assert.Equal(t, int64(1), applied)
+	assert.Equal(t, "mock error", pushErr)
+
+	clients.clients["1.1.1.1"].setMinionError = false
+	RunOnce(conn)
+	RunOnce(conn)
+	gen, applied, pushErr, _ = ConfigStatus("1.1.1.1")
+	assert.Equal(t, int64(2), gen)
+	assert.Equal(t, int64(2), applied)
+	assert.Empty(t, pushErr)
+
+	// After the daemon restarts, the generation continues from the one the
+	// minion reports.
+	minions["1.1.1.1"].desired = pb.MinionConfig{}
+	minions["1.1.1.1"].generation = 0
+	setAction("")
+	RunOnce(conn)
+	gen, _, _, _ = ConfigStatus("1.1.1.1")
+	assert.Equal(t, int64(3), gen)
+}
+
+func TestHostFirewall(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_MASTER,
+		"2.2.2.2": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Namespace = "ns"
+		view.Commit(bp)
+
+		settings := view.InsertSettings()
+		settings.Namespace = "ns"
+		settings.Flags = map[string]bool{db.HostFirewall: true}
+		view.Commit(settings)
+
+		for _, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+			m := view.InsertMachine()
+			m.Provider = db.Vagrant
+			m.PublicIP = ip
+			m.PrivateIP = "10.0.0." + ip[:1]
+			m.CloudID = ip
+			view.Commit(m)
+		}
+		return nil
+	})
+	defer delete(cloudACLs.acls, cloudKey{db.Vagrant, ""})
+
+	// Until the cloud applies its ACLs, the minions don't filter traffic.
+	RunOnce(conn)
+	RunOnce(conn)
+	assert.Empty(t, clients.clients["1.1.1.1"].mc.ACLs)
+
+	// Masters expose etcd to the other machines of the cluster, but not to
+	// the cloud's ACLs, while workers don't expose it at all.
+	SetACLs(db.Vagrant, "", []acl.ACL{{CidrIP: "5.6.7.8/32", MinPort: 22,
+		MaxPort: 22}, {CidrIP: "0.0.0.0/0", MinPort: 2000, MaxPort: 3000}})
+	RunOnce(conn)
+	exp := []*pb.ACL{
+		{CidrIP: "0.0.0.0/0", MinPort: 2000, MaxPort: 2378},
+		{CidrIP: "0.0.0.0/0", MinPort: 2381, MaxPort: 3000},
+		{CidrIP: "10.0.0.1/32", MinPort: 1, MaxPort: 65535},
+		{CidrIP: "10.0.0.2/32", MinPort: 1, MaxPort: 65535},
+		{CidrIP: "5.6.7.8/32", MinPort: 22, MaxPort: 22},
+	}
+	assert.Equal(t, exp, clients.clients["1.1.1.1"].mc.ACLs)
+	assert.Equal(t, []*pb.ACL{
+		{CidrIP: "0.0.0.0/0", MinPort: 2000, MaxPort: 3000},
+		{CidrIP: "10.0.0.1/32", MinPort: 1, MaxPort: 2378},
+		{CidrIP: "10.0.0.1/32", MinPort: 2381, MaxPort: 65535},
+		{CidrIP: "10.0.0.2/32", MinPort: 1, MaxPort: 2378},
+		{CidrIP: "10.0.0.2/32", MinPort: 2381, MaxPort: 65535},
+		{CidrIP: "5.6.7.8/32", MinPort: 22, MaxPort: 22},
+	}, clients.clients["2.2.2.2"].mc.ACLs)
+
+	// The ACLs of other clouds don't apply.
+	SetACLs(db.Amazon, "us-west-1", []acl.ACL{{CidrIP: "0.0.0.0/0",
+		MinPort: 80, MaxPort: 80}})
+	defer delete(cloudACLs.acls, cloudKey{db.Amazon, "us-west-1"})
+	RunOnce(conn)
+	assert.Equal(t, exp, clients.clients["1.1.1.1"].mc.ACLs)
+}
+
 func fired(c chan struct{}) bool {
 	select {
 	case <-c:
@@ -223,6 +537,299 @@ func fired(c chan struct{}) bool {
 	}
 }
 
+func TestBlueprintDistribution(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_MASTER,
+		"2.2.2.2": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Namespace = "ns"
+		view.Commit(bp)
+
+		for _, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+			m := view.InsertMachine()
+			m.PublicIP = ip
+			m.PrivateIP = ip
+			m.CloudID = ip
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	RunOnce(conn)
+	RunOnce(conn)
+
+	// Only the master is sent the blueprint.  Both configs are signed.
+	master := clients.clients["1.1.1.1"].mc
+	assert.NotEmpty(t, master.Blueprint)
+	checkSigned(t, master)
+
+	worker := clients.clients["2.2.2.2"].mc
+	assert.Empty(t, worker.Blueprint)
+	checkSigned(t, worker)
+
+	// Configs that the minions already applied aren't sent again.
+	sets := clients.clients["1.1.1.1"].setCalls
+	RunOnce(conn)
+	assert.Equal(t, sets, clients.clients["1.1.1.1"].setCalls)
+
+	// Without a certificate authority, the minions aren't sent configs that
+	// they would reject.
+	CA = nil
+	conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
+		bp, _ := view.GetBlueprint()
+		bp.Blueprint.Namespace = "changed"
+		view.Commit(bp)
+		return nil
+	})
+	RunOnce(conn)
+	assert.Equal(t, master, clients.clients["1.1.1.1"].mc)
+}
+
+// checkSigned checks that `cfg` is signed by the CA.
+func checkSigned(t *testing.T, cfg pb.MinionConfig) {
+	signed, err := cfg.SignedBytes()
+	assert.NoError(t, err)
+	assert.NoError(t, rsa.Verify(CA.CertString(), signed, cfg.Signature))
+}
+
+func TestSelfHostDistribution(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_MASTER,
+		"2.2.2.2": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Namespace = "ns"
+		view.Commit(bp)
+
+		for _, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+			m := view.InsertMachine()
+			m.PublicIP = ip
+			m.PrivateIP = ip
+			m.CloudID = ip
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	SelfHostBundle = []byte("bundle")
+	defer func() { SelfHostBundle = nil }()
+
+	// The bundle isn't sent unless the namespace is self-hosted.
+	RunOnce(conn)
+	RunOnce(conn)
+	assert.Empty(t, clients.clients["1.1.1.1"].mc.SelfHost)
+
+	conn.Txn(db.SettingsTable).Run(func(view db.Database) error {
+		settings := view.InsertSettings()
+		settings.Namespace = "ns"
+		settings.Flags = map[string]bool{db.SelfHost: true}
+		view.Commit(settings)
+		return nil
+	})
+	RunOnce(conn)
+
+	// Only the master is sent the bundle.
+	master := clients.clients["1.1.1.1"].mc
+	assert.Equal(t, []byte("bundle"), master.SelfHost)
+	checkSigned(t, master)
+
+	assert.Empty(t, clients.clients["2.2.2.2"].mc.SelfHost)
+}
+
+func TestSelfHostHandoff(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_MASTER,
+		"2.2.2.2": pb.MinionConfig_WORKER,
+	})
+	defer setSteppedDown(false)
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Namespace = "ns"
+		view.Commit(bp)
+
+		for _, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+			m := view.InsertMachine()
+			m.PublicIP = ip
+			m.PrivateIP = ip
+			m.CloudID = ip
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	// Self-hosting that's merely not enabled doesn't remove the self-hosted
+	// daemon's state.
+	RunOnce(conn)
+	RunOnce(conn)
+	assert.False(t, SteppedDown())
+	assert.False(t, clients.clients["1.1.1.1"].mc.DisableSelfHost)
+
+	// Once the self-hosted daemon runs, this daemon stops sending configs,
+	// even if it doesn't know that the namespace is self-hosted.
+	master := clients.clients["1.1.1.1"]
+	master.selfHosted = true
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		m := view.SelectFromMachine(nil)[0]
+		m.Labels = map[string]string{"changed": "true"}
+		view.Commit(m)
+		return nil
+	})
+	setCalls := master.setCalls
+	RunOnce(conn)
+	assert.True(t, SteppedDown())
+	assert.Equal(t, setCalls, master.setCalls)
+
+	// The self-hosted daemon itself keeps managing the namespace.
+	SelfHosted = true
+	RunOnce(conn)
+	SelfHosted = false
+	assert.False(t, SteppedDown())
+
+	// Turning self-hosting off takes the namespace back, and tells the
+	// masters to stop the self-hosted daemon.
+	conn.Txn(db.SettingsTable).Run(func(view db.Database) error {
+		settings := view.InsertSettings()
+		settings.Namespace = "ns"
+		settings.Flags = map[string]bool{db.SelfHost: false}
+		view.Commit(settings)
+		return nil
+	})
+	RunOnce(conn)
+	assert.False(t, SteppedDown())
+	assert.True(t, master.mc.DisableSelfHost)
+	checkSigned(t, master.mc)
+	assert.False(t, clients.clients["2.2.2.2"].mc.DisableSelfHost)
+}
+
+func TestEtcdPasswords(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"1.1.1.1": pb.MinionConfig_MASTER,
+		"2.2.2.2": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+			m := view.InsertMachine()
+			m.PublicIP = ip
+			m.PrivateIP = ip
+			m.CloudID = ip
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	RunOnce(conn)
+	RunOnce(conn)
+
+	rootPassword := CA.DeriveSecret("etcd-root")
+	workerUser := etcd.WorkerUser("2.2.2.2")
+	workerPassword := CA.DeriveSecret("etcd-" + workerUser)
+	assert.NotEqual(t, rootPassword, workerPassword)
+
+	// Workers aren't sent the root password.
+	assert.Equal(t, map[string]string{
+		etcd.RootUser: rootPassword,
+		workerUser:    workerPassword,
+	}, clients.clients["1.1.1.1"].mc.EtcdPasswords)
+	assert.Equal(t, map[string]string{workerUser: workerPassword},
+		clients.clients["2.2.2.2"].mc.EtcdPasswords)
+
+	assert.Nil(t, etcdPasswords(&minion{}, nil))
+}
+
+func TestContainerCerts(t *testing.T) {
+	conn, clients := startTest(t, map[string]pb.MinionConfig_Role{
+		"2.2.2.2": pb.MinionConfig_WORKER,
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.PublicIP = "2.2.2.2"
+		m.PrivateIP = "2.2.2.2"
+		m.CloudID = "2.2.2.2"
+		view.Commit(m)
+		return nil
+	})
+
+	RunOnce(conn)
+	fc := clients.clients["2.2.2.2"]
+	assert.Empty(t, fc.mc.ContainerCerts)
+
+	webKey, webPubKey := newContainerKey(t)
+	fc.containerKeys = map[string]string{"web": webPubKey, "bad": "garbage"}
+	RunOnce(conn)
+
+	// The certificate matches the key that the minion kept, and identifies the
+	// container.
+	assert.Len(t, fc.mc.ContainerCerts, 1)
+	webCert := fc.mc.ContainerCerts["web"]
+	keyPair, err := rsa.New(webCert, webKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []*url.URL{tls.ContainerURI("web")}, keyPair.Cert().URIs)
+	_, err = tls.New(CA.CertString(), webCert, webKey)
+	assert.NoError(t, err)
+
+	// Certificates aren't reissued, so the config doesn't change.
+	generation := fc.mc.Generation
+	RunOnce(conn)
+	assert.Equal(t, generation, fc.mc.Generation)
+	assert.Equal(t, webCert, fc.mc.ContainerCerts["web"])
+
+	// Unless the key changes.
+	_, newPubKey := newContainerKey(t)
+	fc.containerKeys = map[string]string{"web": newPubKey}
+	RunOnce(conn)
+	assert.NotEqual(t, webCert, fc.mc.ContainerCerts["web"])
+
+	fc.containerKeys = nil
+	RunOnce(conn)
+	assert.Empty(t, fc.mc.ContainerCerts)
+}
+
+func newContainerKey(t *testing.T) (string, string) {
+	key, err := rsa.NewPrivateKey()
+	assert.NoError(t, err)
+
+	pubKey, err := rsa.PublicKeyString(key)
+	assert.NoError(t, err)
+	return key, pubKey
+}
+
+type localMinion struct {
+	mc pb.MinionConfig
+}
+
+func (lm *localMinion) GetMinionConfig() (pb.MinionConfig, error) {
+	return lm.mc, nil
+}
+
+func (lm *localMinion) SetMinionConfig(mc pb.MinionConfig) error {
+	lm.mc = mc
+	return nil
+}
+
+func TestLocalMinion(t *testing.T) {
+	lm := &localMinion{mc: pb.MinionConfig{Role: pb.MinionConfig_WORKER}}
+	RegisterLocalMinion("1.2.3.4", lm)
+
+	// Local minions are configured directly, rather than over the network.
+	client, err := newClientImpl("1.2.3.4", "id")
+	assert.NoError(t, err)
+
+	mc, err := client.getMinion()
+	assert.NoError(t, err)
+	assert.Equal(t, pb.MinionConfig_WORKER, mc.Role)
+
+	assert.NoError(t, client.setMinion(pb.MinionConfig{PrivateIP: "10.0.0.1"}))
+	assert.Equal(t, "10.0.0.1", lm.mc.PrivateIP)
+	client.Close()
+
+	RegisterLocalMinion("1.2.3.4", nil)
+	assert.Empty(t, localMinions.minions)
+}
+
 func TestIsConnected(t *testing.T) {
 	minions = map[string]*minion{}
 	assert.False(t, IsConnected("host"))
@@ -234,11 +841,60 @@ func TestIsConnected(t *testing.T) {
 	assert.True(t, IsConnected("host"))
 }
 
+func TestIsNetworkDegraded(t *testing.T) {
+	minions = map[string]*minion{}
+	assert.False(t, IsNetworkDegraded("host"))
+
+	minions["host"] = &minion{connected: true}
+	assert.False(t, IsNetworkDegraded("host"))
+
+	minions["host"].config.NetworkDegraded = true
+	assert.True(t, IsNetworkDegraded("host"))
+
+	minions["host"].connected = false
+	assert.False(t, IsNetworkDegraded("host"))
+}
+
+func TestIsInterrupted(t *testing.T) {
+	minions = map[string]*minion{}
+	assert.False(t, IsInterrupted("host"))
+
+	minions["host"] = &minion{connected: true}
+	assert.False(t, IsInterrupted("host"))
+
+	minions["host"].config.Interrupted = true
+	assert.True(t, IsInterrupted("host"))
+
+	minions["host"].connected = false
+	assert.False(t, IsInterrupted("host"))
+}
+
+func TestIsDrained(t *testing.T) {
+	minions = map[string]*minion{}
+	assert.False(t, IsDrained("host"))
+
+	minions["host"] = &minion{connected: true}
+	assert.False(t, IsDrained("host"))
+
+	minions["host"].config.Drained = true
+	assert.True(t, IsDrained("host"))
+
+	minions["host"].connected = false
+	assert.False(t, IsDrained("host"))
+}
+
 func startTest(t *testing.T, roles map[string]pb.MinionConfig_Role) (db.Conn, *clients) {
 	conn := db.New()
 	minions = map[string]*minion{}
+
+	if testCA == nil {
+		ca, err := rsa.NewCertificateAuthority()
+		assert.NoError(t, err)
+		testCA = &ca
+	}
+	CA = testCA
 	clients := &clients{make(map[string]*fakeClient), 0}
-	newClient = func(ip string) (client, error) {
+	newClient = func(ip, cloudID string) (client, error) {
 		if fc, ok := clients.clients[ip]; ok {
 			return fc, nil
 		}
@@ -250,6 +906,7 @@ func startTest(t *testing.T, roles map[string]pb.MinionConfig_Role) (db.Conn, *c
 		fc := &fakeClient{
 			clients: clients,
 			ip:      ip,
+			cloudID: cloudID,
 			role:    role,
 		}
 		clients.clients[ip] = fc
@@ -262,13 +919,26 @@ func startTest(t *testing.T, roles map[string]pb.MinionConfig_Role) (db.Conn, *c
 type fakeClient struct {
 	clients *clients
 	ip      string
+	cloudID string
 	role    pb.MinionConfig_Role
 	mc      pb.MinionConfig
 
+	// The public keys that the minion reports for its containers' TLS proxies.
+	containerKeys map[string]string
+
+	// Whether the minion reports running the self-hosted daemon.
+	selfHosted bool
+
 	getMinionError bool
+	setMinionError bool
+	setCalls       int
 }
 
 func (fc *fakeClient) setMinion(mc pb.MinionConfig) error {
+	fc.setCalls++
+	if fc.setMinionError {
+		return errors.New("mock error")
+	}
 	fc.mc = mc
 	return nil
 }
@@ -280,6 +950,8 @@ func (fc *fakeClient) getMinion() (pb.MinionConfig, error) {
 
 	mc := fc.mc
 	mc.Role = fc.role
+	mc.ContainerKeys = fc.containerKeys
+	mc.SelfHosted = fc.selfHosted
 	return mc, nil
 }
 
@@ -0,0 +1,48 @@
+package foreman
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DialSSH opens an SSH connection to `user` on `host`, authenticated by
+// `sshKey`.  Like the connections to the minions, it goes through Proxy, if
+// there is one.
+func DialSSH(host, user string, sshKey ssh.Signer, timeout time.Duration) (
+	*ssh.Client, error) {
+
+	sshConfig := &ssh.ClientConfig{
+		User:    user,
+		Auth:    []ssh.AuthMethod{ssh.PublicKeys(sshKey)},
+		Timeout: timeout,
+		// XXX: We have to ignore the host key because we don't keep track of
+		// the host keys of machines. Once we do, this should use strict host
+		// key checking. For now, this means that a machine could theoretically
+		// man in the middle as the target machine and obtain signed
+		// certificates.
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	addr := net.JoinHostPort(host, "22")
+	if Proxy == nil {
+		sshClient, err := ssh.Dial("tcp", addr, sshConfig)
+		if err != nil {
+			return nil, fmt.Errorf("dial: %s", err)
+		}
+		return sshClient, nil
+	}
+
+	conn, err := Proxy.DialTimeout("tcp", addr, sshConfig.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %s", err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh: %s", err)
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
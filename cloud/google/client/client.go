@@ -6,14 +6,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
 	compute "google.golang.org/api/compute/v1"
 
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
 	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/util"
 )
 
@@ -24,6 +32,7 @@ type Client interface {
 	InsertInstance(zone string, instance *compute.Instance) (
 		*compute.Operation, error)
 	DeleteInstance(zone, operation string) (*compute.Operation, error)
+	ResetInstance(zone, instance string) (*compute.Operation, error)
 	AddAccessConfig(zone, instance, networkInterface string,
 		accessConfig *compute.AccessConfig) (*compute.Operation, error)
 	DeleteAccessConfig(zone, instance, accessConfig,
@@ -39,6 +48,7 @@ type Client interface {
 	ListNetworks() (*compute.NetworkList, error)
 	InsertNetwork(network *compute.Network) (
 		*compute.Operation, error)
+	GetRegion(region string) (*compute.Region, error)
 }
 
 type client struct {
@@ -48,14 +58,34 @@ type client struct {
 
 var c = counter.New("Google")
 
-// New creates a new Google client.
+// The environment variable that names a service account key file, which takes
+// precedence over ~/.gce/quilt.json.
+const credentialsEnv = "GOOGLE_APPLICATION_CREDENTIALS"
+
+// Allow mocking out the metadata server for the unit tests.
+var onGCE = metadata.OnGCE
+var gceProjectID = metadata.ProjectID
+
+// New creates a new Google client with the service account key in
+// ~/.gce/quilt.json, or in the file named by GOOGLE_APPLICATION_CREDENTIALS.
+// If there's no key, but the daemon runs on Google Compute Engine, the client
+// uses the service account of the daemon's instance.
 func New() (Client, error) {
 	c.Inc("New Client")
 
 	configPath := filepath.Join(os.Getenv("HOME"), ".gce", "quilt.json")
+	creds.FromEnv(&configPath, credentialsEnv)
 	configStr, err := util.ReadFile(configPath)
-	if err != nil {
+	switch {
+	case err == nil:
+	case !os.IsNotExist(err):
 		return nil, err
+	case !cassette.Replaying() && onGCE():
+		return newInstanceClient()
+	default:
+		return nil, creds.MissingError{Provider: db.Google, Sources: []string{
+			configPath, creds.EnvSource(credentialsEnv),
+			"the service account of a Google Compute Engine instance"}}
 	}
 
 	service, err := newComputeService(configStr)
@@ -71,14 +101,56 @@ func New() (Client, error) {
 	return &client{gce: service, projID: projID}, nil
 }
 
+// newInstanceClient creates a client that authenticates as the service account
+// of the instance that the daemon runs on, within the instance's project.
+func newInstanceClient() (Client, error) {
+	projID, err := gceProjectID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project ID: %s", err)
+	}
+
+	service, err := newComputeService("")
+	if err != nil {
+		return nil, err
+	}
+	return &client{gce: service, projID: projID}, nil
+}
+
+// newComputeService creates a service that authenticates with the service
+// account key `configStr`, or as the instance's service account if it's empty.
 func newComputeService(configStr string) (*compute.Service, error) {
-	jwtConfig, err := google.JWTConfigFromJSON(
-		[]byte(configStr), compute.ComputeScope)
+	// Google's rate limits apply to the whole project.
+	httpClient := throttle.Wrap(db.Google, "",
+		endpoint.Client(db.Google, &http.Client{}))
+
+	// Replayed responses don't require a valid service account.
+	if !cassette.Replaying() {
+		// The token is fetched with the same client, so that emulators
+		// may serve it as well.
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+			httpClient)
+
+		src := google.ComputeTokenSource("")
+		if configStr != "" {
+			jwtConfig, err := google.JWTConfigFromJSON(
+				[]byte(configStr), compute.ComputeScope)
+			if err != nil {
+				return nil, err
+			}
+			src = jwtConfig.TokenSource(ctx)
+		}
+		httpClient = oauth2.NewClient(ctx, src)
+	}
+
+	service, err := compute.New(cassette.Wrap("google", httpClient))
 	if err != nil {
 		return nil, err
 	}
 
-	return compute.New(jwtConfig.Client(context.Background()))
+	if url, ok := endpoint.Get(db.Google); ok {
+		service.BasePath = url
+	}
+	return service, nil
 }
 
 const projectIDKey = "project_id"
@@ -123,6 +195,12 @@ func (ci *client) DeleteInstance(zone, instance string) (*compute.Operation,
 	return ci.gce.Instances.Delete(ci.projID, zone, instance).Do()
 }
 
+func (ci *client) ResetInstance(zone, instance string) (*compute.Operation,
+	error) {
+	c.Inc("Reset Instance")
+	return ci.gce.Instances.Reset(ci.projID, zone, instance).Do()
+}
+
 func (ci *client) AddAccessConfig(zone, instance, networkInterface string,
 	accessConfig *compute.AccessConfig) (*compute.Operation, error) {
 	c.Inc("Add Access Config")
@@ -182,3 +260,8 @@ func (ci *client) InsertNetwork(network *compute.Network) (
 	c.Inc("Insert Network")
 	return ci.gce.Networks.Insert(ci.projID, network).Do()
 }
+
+func (ci *client) GetRegion(region string) (*compute.Region, error) {
+	c.Inc("Get Region")
+	return ci.gce.Regions.Get(ci.projID, region).Do()
+}
@@ -3,11 +3,17 @@ package client
 import (
 	"errors"
 	"net/http"
+	"os"
 	"testing"
 
+	"cloud.google.com/go/compute/metadata"
 	compute "google.golang.org/api/compute/v1"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/util"
 )
 
 type rtErr struct{}
@@ -83,3 +89,35 @@ func TestGetProjectID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "myid", id)
 }
+
+func TestNewCredentials(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	defer func() {
+		onGCE = metadata.OnGCE
+		gceProjectID = metadata.ProjectID
+	}()
+
+	onGCE = func() bool { return false }
+	_, err := New()
+	assert.True(t, creds.IsMissing(err))
+
+	// Off of Google, a key file named in the environment is used.
+	os.Setenv(credentialsEnv, "key.json")
+	defer os.Unsetenv(credentialsEnv)
+	util.WriteFile("key.json", []byte(`{"project_id": "pid"}`), 0600)
+	_, err = New()
+	assert.EqualError(t, err, "google: read JWT from JSON credentials: "+
+		`'type' field is "" (expected "service_account")`)
+
+	// Without a key, the instance's service account is used on Google.
+	os.Unsetenv(credentialsEnv)
+	onGCE = func() bool { return true }
+	gceProjectID = func() (string, error) { return "instancePID", nil }
+	gce, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "instancePID", gce.(*client).projID)
+
+	gceProjectID = func() (string, error) { return "", errors.New("err") }
+	_, err = New()
+	assert.EqualError(t, err, "failed to get project ID: err")
+}
@@ -102,6 +102,29 @@ func (_m *Client) DeleteInstance(zone string, operation string) (*compute.Operat
 	return r0, r1
 }
 
+// ResetInstance provides a mock function with given fields: zone, instance
+func (_m *Client) ResetInstance(zone string, instance string) (*compute.Operation, error) {
+	ret := _m.Called(zone, instance)
+
+	var r0 *compute.Operation
+	if rf, ok := ret.Get(0).(func(string, string) *compute.Operation); ok {
+		r0 = rf(zone, instance)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*compute.Operation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(zone, instance)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetGlobalOperation provides a mock function with given fields: operation
 func (_m *Client) GetGlobalOperation(operation string) (*compute.Operation, error) {
 	ret := _m.Called(operation)
@@ -286,6 +309,29 @@ func (_m *Client) ListInstances(zone string, filter string) (*compute.InstanceLi
 	return r0, r1
 }
 
+// GetRegion provides a mock function with given fields: region
+func (_m *Client) GetRegion(region string) (*compute.Region, error) {
+	ret := _m.Called(region)
+
+	var r0 *compute.Region
+	if rf, ok := ret.Get(0).(func(string) *compute.Region); ok {
+		r0 = rf(region)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*compute.Region)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(region)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListNetworks provides a mock function with given fields:
 func (_m *Client) ListNetworks() (*compute.NetworkList, error) {
 	ret := _m.Called()
@@ -10,8 +10,11 @@ import (
 	"time"
 
 	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
 	"github.com/kelda/kelda/cloud/cfg"
 	"github.com/kelda/kelda/cloud/google/client"
+	"github.com/kelda/kelda/cloud/quota"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/cloud/wait"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
@@ -35,6 +38,17 @@ const ephemeralIPName = "External NAT"
 // floatingIPName is a constant for what we label NATs with floating IPs in GCE.
 const floatingIPName = "Floating IP"
 
+// imageMetadataKey is the instance metadata key that records the image that a
+// machine was booted from, if it wasn't the default.
+const imageMetadataKey = "quilt-image"
+
+// diskTypeMetadataKey is the instance metadata key that records the disk type
+// that a machine chose, if it chose one.
+const diskTypeMetadataKey = "quilt-disk-type"
+
+// localSSD is the disk type of machines that attach a local SSD.
+const localSSD = "local-ssd"
+
 const computeBaseURL string = "https://www.googleapis.com/compute/v1/projects"
 
 // The Provider objects represents a connection to GCE.
@@ -124,12 +138,39 @@ func (prvdr *Provider) List() ([]db.Machine, error) {
 			floatingIP = accessConfig.NatIP
 		}
 
+		var gpus int
+		var gpuType string
+		for _, accel := range instance.GuestAccelerators {
+			gpus += int(accel.AcceleratorCount)
+			gpuType = path.Base(accel.AcceleratorType)
+		}
+
+		var image, diskType string
+		if instance.Metadata != nil {
+			for _, item := range instance.Metadata.Items {
+				if item.Value == nil {
+					continue
+				}
+
+				switch item.Key {
+				case imageMetadataKey:
+					image = *item.Value
+				case diskTypeMetadataKey:
+					diskType = *item.Value
+				}
+			}
+		}
+
 		machines = append(machines, db.Machine{
 			CloudID:    instance.Name,
 			PublicIP:   accessConfig.NatIP,
 			FloatingIP: floatingIP,
 			PrivateIP:  iface.NetworkIP,
 			Size:       mtype,
+			GPUs:       gpus,
+			GPUType:    gpuType,
+			Image:      image,
+			DiskType:   diskType,
 		})
 	}
 	return machines, nil
@@ -144,8 +185,13 @@ func (prvdr *Provider) Boot(bootSet []db.Machine) error {
 			return errors.New("preemptible instances are not yet implemented")
 		}
 
+		// Instance names must be unique within the project's zone, so
+		// hostnames shared across namespaces fail to boot.
 		name := "quilt-" + uuid.NewV4().String()
-		_, err := prvdr.instanceNew(name, m.Size, cfg.Ubuntu(m, ""))
+		if m.Hostname != "" {
+			name = m.Hostname
+		}
+		_, err := prvdr.instanceNew(name, m, cfg.UserData(m, ""))
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error": err,
@@ -180,6 +226,120 @@ func (prvdr *Provider) Stop(machines []db.Machine) error {
 	return prvdr.wait(names, false)
 }
 
+// Reboot resets the instances of `machines`, and waits for the resets to finish.
+func (prvdr *Provider) Reboot(machines []db.Machine) error {
+	var ops []*compute.Operation
+	for _, m := range machines {
+		op, err := prvdr.ResetInstance(prvdr.zone, m.CloudID)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, op)
+	}
+	return prvdr.operationWait(ops...)
+}
+
+// Resources lists the instances and disks of the namespace in the zone, and the
+// namespace's network and firewalls.  The network and firewalls are global, so
+// they're reported by every zone.
+func (prvdr *Provider) Resources() ([]resource.Resource, error) {
+	var resources []resource.Resource
+
+	networks, err := prvdr.ListNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("list networks: %s", err)
+	}
+	for _, nw := range networks.Items {
+		if nw.Name == prvdr.networkName {
+			resources = append(resources, resource.Resource{
+				Type:    resource.Network,
+				ID:      nw.Name,
+				Created: parseTimestamp(nw.CreationTimestamp),
+			})
+		}
+	}
+
+	firewalls, err := prvdr.ListFirewalls()
+	if err != nil {
+		return nil, fmt.Errorf("list firewalls: %s", err)
+	}
+	for _, fw := range firewalls.Items {
+		if path.Base(fw.Network) == prvdr.networkName {
+			resources = append(resources, resource.Resource{
+				Type:    resource.SecurityGroup,
+				ID:      fw.Name,
+				Created: parseTimestamp(fw.CreationTimestamp),
+			})
+		}
+	}
+
+	instances, err := prvdr.ListInstances(prvdr.zone,
+		fmt.Sprintf("description eq %s", prvdr.ns))
+	if err != nil {
+		return nil, fmt.Errorf("list instances: %s", err)
+	}
+	for _, inst := range instances.Items {
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      inst.Name,
+			Tags:    inst.Labels,
+			Created: parseTimestamp(inst.CreationTimestamp),
+		})
+
+		for _, disk := range inst.Disks {
+			// Local SSDs aren't resources of their own.
+			if disk.Source == "" {
+				continue
+			}
+
+			resources = append(resources, resource.Resource{
+				Type: resource.Volume,
+				ID:   path.Base(disk.Source),
+			})
+		}
+	}
+	return resources, nil
+}
+
+// The region quota metrics that limit booting machines.
+var quotaMetrics = map[string]quota.Resource{
+	"INSTANCES":        quota.Instances,
+	"CPUS":             quota.CPUs,
+	"IN_USE_ADDRESSES": quota.IPs,
+}
+
+// Quotas returns the project's quotas in the region of the zone.
+func (prvdr *Provider) Quotas() ([]quota.Quota, error) {
+	region, err := prvdr.GetRegion(zoneRegion(prvdr.zone))
+	if err != nil {
+		return nil, fmt.Errorf("get region: %s", err)
+	}
+
+	var quotas []quota.Quota
+	for _, q := range region.Quotas {
+		if res, ok := quotaMetrics[q.Metric]; ok {
+			quotas = append(quotas, quota.Quota{Resource: res,
+				Limit: int(q.Limit), Usage: int(q.Usage)})
+		}
+	}
+	return quotas, nil
+}
+
+// zoneRegion returns the region of `zone`, such as us-east1 for us-east1-b.
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i >= 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// parseTimestamp parses the RFC 3339 timestamps reported by GCE.  Malformed
+// timestamps are treated as unknown.
+func parseTimestamp(timestamp string) time.Time {
+	t, _ := time.Parse(time.RFC3339, timestamp)
+	return t
+}
+
 // Get() and operationWait() don't always present the same results, so
 // Boot() and Stop() must have a special wait to stay in sync with Get().
 func (prvdr *Provider) wait(ids []string, shouldLive bool) error {
@@ -225,24 +385,34 @@ func (prvdr *Provider) operationWait(ops ...*compute.Operation) (err error) {
 	}, 30*time.Second, 3*time.Minute)
 }
 
-// Create new GCE instance.
+// Create new GCE instance for `m`.
 //
 // Does not check if the operation succeeds.
-func (prvdr *Provider) instanceNew(name string, size string,
-	cloudConfig string) (*compute.Operation, error) {
+func (prvdr *Provider) instanceNew(name string, m db.Machine, cloudConfig string) (
+	*compute.Operation, error) {
+	image := prvdr.imgURL
+	if m.Image != "" {
+		image = m.Image
+	}
+
+	bootDisk := &compute.AttachedDiskInitializeParams{SourceImage: image}
+	if m.DiskType != "" && m.DiskType != localSSD {
+		bootDisk.DiskType = fmt.Sprintf("zones/%s/diskTypes/%s", prvdr.zone,
+			m.DiskType)
+	}
+
 	instance := &compute.Instance{
 		Name:        name,
 		Description: prvdr.ns,
+		Labels:      m.Tags,
 		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s",
 			prvdr.zone,
-			size),
+			m.Size),
 		Disks: []*compute.AttachedDisk{
 			{
-				Boot:       true,
-				AutoDelete: true,
-				InitializeParams: &compute.AttachedDiskInitializeParams{
-					SourceImage: prvdr.imgURL,
-				},
+				Boot:             true,
+				AutoDelete:       true,
+				InitializeParams: bootDisk,
 			},
 		},
 		NetworkInterfaces: []*compute.NetworkInterface{
@@ -262,6 +432,16 @@ func (prvdr *Provider) instanceNew(name string, size string,
 					Key:   "startup-script",
 					Value: &cloudConfig,
 				},
+				// The boot disk doesn't record its image in the
+				// instance, so List reads it from here instead.
+				{
+					Key:   imageMetadataKey,
+					Value: &m.Image,
+				},
+				{
+					Key:   diskTypeMetadataKey,
+					Value: &m.DiskType,
+				},
 			},
 		},
 		Tags: &compute.Tags{
@@ -271,6 +451,33 @@ func (prvdr *Provider) instanceNew(name string, size string,
 		},
 	}
 
+	// Instances can't boot from local SSDs, so they boot from a standard
+	// persistent disk, and attach the local SSD as scratch space.
+	if m.DiskType == localSSD {
+		instance.Disks = append(instance.Disks, &compute.AttachedDisk{
+			Type:       "SCRATCH",
+			Interface:  "NVME",
+			AutoDelete: true,
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				DiskType: fmt.Sprintf("zones/%s/diskTypes/%s", prvdr.zone,
+					localSSD),
+			},
+		})
+	}
+
+	// Instances with GPUs can't be live migrated, so they're stopped for host
+	// maintenance instead.
+	if m.GPUs > 0 {
+		instance.GuestAccelerators = []*compute.AcceleratorConfig{{
+			AcceleratorCount: int64(m.GPUs),
+			AcceleratorType: fmt.Sprintf("zones/%s/acceleratorTypes/%s",
+				prvdr.zone, m.GPUType),
+		}}
+		instance.Scheduling = &compute.Scheduling{
+			OnHostMaintenance: "TERMINATE",
+		}
+	}
+
 	return prvdr.InsertInstance(prvdr.zone, instance)
 }
 
@@ -425,6 +632,13 @@ func (prvdr *Provider) SetACLs(acls []acl.ACL) error {
 	return nil
 }
 
+// Capabilities returns the optional features that Google supports.
+func (prvdr *Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true,
+	}
+}
+
 // UpdateFloatingIPs updates IPs of machines by recreating their network interfaces.
 func (prvdr *Provider) UpdateFloatingIPs(machines []db.Machine) error {
 	for _, m := range machines {
@@ -3,10 +3,14 @@ package google
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/cloud/google/client/mocks"
+	"github.com/kelda/kelda/cloud/quota"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 
 	compute "google.golang.org/api/compute/v1"
@@ -29,6 +33,8 @@ func (s *GoogleTestSuite) SetupTest() {
 }
 
 func (s *GoogleTestSuite) TestList() {
+	image := "projects/project/global/images/prebaked"
+	diskType := "pd-ssd"
 	s.gce.On("ListInstances", "zone-1",
 		"description eq namespace").Return(&compute.InstanceList{
 		Items: []*compute.Instance{
@@ -45,6 +51,20 @@ func (s *GoogleTestSuite) TestList() {
 						NetworkIP: "y.y.y.y",
 					},
 				},
+				GuestAccelerators: []*compute.AcceleratorConfig{{
+					AcceleratorCount: 2,
+					AcceleratorType: "projects/project/zones/zone-1/" +
+						"acceleratorTypes/nvidia-tesla-k80",
+				}},
+				Metadata: &compute.Metadata{
+					Items: []*compute.MetadataItems{{
+						Key:   "quilt-image",
+						Value: &image,
+					}, {
+						Key:   "quilt-disk-type",
+						Value: &diskType,
+					}},
+				},
 			},
 		},
 	}, nil)
@@ -57,9 +77,161 @@ func (s *GoogleTestSuite) TestList() {
 		PublicIP:  "x.x.x.x",
 		PrivateIP: "y.y.y.y",
 		Size:      "type-1",
+		GPUs:      2,
+		GPUType:   "nvidia-tesla-k80",
+		Image:     image,
+		DiskType:  diskType,
 	})
 }
 
+func (s *GoogleTestSuite) TestInstanceNewLabels() {
+	s.gce.On("InsertInstance", "zone-1", mock.MatchedBy(
+		func(inst *compute.Instance) bool {
+			return inst.Name == "worker-1" && inst.Labels["team"] == "infra"
+		})).Return(&compute.Operation{}, nil)
+
+	_, err := s.instanceNew("worker-1", db.Machine{Size: "n1-standard-1",
+		Tags: map[string]string{"team": "infra"}}, "cloud-config")
+	s.NoError(err)
+	s.gce.AssertExpectations(s.T())
+}
+
+func (s *GoogleTestSuite) TestInstanceNewGPUs() {
+	s.gce.On("InsertInstance", "zone-1", mock.MatchedBy(
+		func(inst *compute.Instance) bool {
+			return len(inst.GuestAccelerators) == 1 &&
+				inst.GuestAccelerators[0].AcceleratorCount == 2 &&
+				inst.GuestAccelerators[0].AcceleratorType ==
+					"zones/zone-1/acceleratorTypes/nvidia-tesla-k80" &&
+				inst.Scheduling.OnHostMaintenance == "TERMINATE"
+		})).Return(&compute.Operation{}, nil)
+
+	_, err := s.instanceNew("worker-1", db.Machine{Size: "n1-standard-1",
+		GPUs: 2, GPUType: "nvidia-tesla-k80"}, "cloud-config")
+	s.NoError(err)
+	s.gce.AssertExpectations(s.T())
+}
+
+func (s *GoogleTestSuite) TestInstanceNewImage() {
+	s.imgURL = "default-image"
+	image := "projects/project/global/images/prebaked"
+	s.gce.On("InsertInstance", "zone-1", mock.MatchedBy(
+		func(inst *compute.Instance) bool {
+			return inst.Name == "worker-1" &&
+				inst.Disks[0].InitializeParams.SourceImage == image
+		})).Return(&compute.Operation{}, nil)
+	s.gce.On("InsertInstance", "zone-1", mock.MatchedBy(
+		func(inst *compute.Instance) bool {
+			return inst.Name == "worker-2" &&
+				inst.Disks[0].InitializeParams.SourceImage == "default-image"
+		})).Return(&compute.Operation{}, nil)
+
+	_, err := s.instanceNew("worker-1", db.Machine{Size: "n1-standard-1",
+		Image: image}, "cloud-config")
+	s.NoError(err)
+	_, err = s.instanceNew("worker-2", db.Machine{Size: "n1-standard-1"},
+		"cloud-config")
+	s.NoError(err)
+	s.gce.AssertExpectations(s.T())
+}
+
+func (s *GoogleTestSuite) TestInstanceNewDiskType() {
+	s.gce.On("InsertInstance", "zone-1", mock.MatchedBy(
+		func(inst *compute.Instance) bool {
+			return inst.Name == "worker-1" && len(inst.Disks) == 1 &&
+				inst.Disks[0].InitializeParams.DiskType ==
+					"zones/zone-1/diskTypes/pd-ssd"
+		})).Return(&compute.Operation{}, nil)
+	s.gce.On("InsertInstance", "zone-1", mock.MatchedBy(
+		func(inst *compute.Instance) bool {
+			return inst.Name == "worker-2" && len(inst.Disks) == 2 &&
+				inst.Disks[0].Boot &&
+				inst.Disks[0].InitializeParams.DiskType == "" &&
+				inst.Disks[1].Type == "SCRATCH" &&
+				inst.Disks[1].InitializeParams.DiskType ==
+					"zones/zone-1/diskTypes/local-ssd"
+		})).Return(&compute.Operation{}, nil)
+
+	_, err := s.instanceNew("worker-1", db.Machine{Size: "n1-standard-1",
+		DiskType: "pd-ssd"}, "cloud-config")
+	s.NoError(err)
+	_, err = s.instanceNew("worker-2", db.Machine{Size: "n1-standard-1",
+		DiskType: "local-ssd"}, "cloud-config")
+	s.NoError(err)
+	s.gce.AssertExpectations(s.T())
+}
+
+func (s *GoogleTestSuite) TestReboot() {
+	op := &compute.Operation{Name: "op", Zone: "zones/zone-1"}
+	s.gce.On("ResetInstance", "zone-1", "name-1").Return(op, nil)
+	s.gce.On("GetZoneOperation", "zone-1", "op").Return(
+		&compute.Operation{Status: "DONE"}, nil)
+	s.NoError(s.Reboot([]db.Machine{{CloudID: "name-1"}}))
+	s.gce.AssertExpectations(s.T())
+
+	s.gce.On("ResetInstance", "zone-1", "name-2").Return(nil, errors.New("err"))
+	s.EqualError(s.Reboot([]db.Machine{{CloudID: "name-2"}}), "err")
+}
+
+func (s *GoogleTestSuite) TestResources() {
+	s.networkName = "namespace"
+	s.gce.On("ListNetworks").Return(&compute.NetworkList{
+		Items: []*compute.Network{{Name: "namespace",
+			CreationTimestamp: "2017-10-13T17:00:00Z"},
+			{Name: "other"}},
+	}, nil)
+	s.gce.On("ListFirewalls").Return(&compute.FirewallList{
+		Items: []*compute.Firewall{
+			{Name: "fw", Network: "global/networks/namespace"},
+			{Name: "other", Network: "global/networks/other"},
+		},
+	}, nil)
+	s.gce.On("ListInstances", "zone-1", "description eq namespace").Return(
+		&compute.InstanceList{Items: []*compute.Instance{{
+			Name:   "name-1",
+			Labels: map[string]string{"key": "value"},
+			Disks: []*compute.AttachedDisk{
+				{Source: "zones/zone-1/disks/name-1"},
+				{Type: "SCRATCH"}},
+		}}}, nil)
+
+	resources, err := s.Resources()
+	s.NoError(err)
+	s.Equal([]resource.Resource{
+		{Type: resource.Network, ID: "namespace",
+			Created: time.Date(2017, 10, 13, 17, 0, 0, 0, time.UTC)},
+		{Type: resource.SecurityGroup, ID: "fw"},
+		{Type: resource.Instance, ID: "name-1",
+			Tags: map[string]string{"key": "value"}},
+		{Type: resource.Volume, ID: "name-1"},
+	}, resources)
+}
+
+func (s *GoogleTestSuite) TestQuotas() {
+	s.gce.On("GetRegion", "zone").Return(&compute.Region{
+		Quotas: []*compute.Quota{
+			{Metric: "INSTANCES", Limit: 24, Usage: 3},
+			{Metric: "CPUS", Limit: 24, Usage: 6},
+			{Metric: "IN_USE_ADDRESSES", Limit: 8, Usage: 3},
+			{Metric: "SSD_TOTAL_GB", Limit: 2048},
+		},
+	}, nil).Once()
+
+	quotas, err := s.Quotas()
+	s.NoError(err)
+	s.Equal([]quota.Quota{
+		{Resource: quota.Instances, Limit: 24, Usage: 3},
+		{Resource: quota.CPUs, Limit: 24, Usage: 6},
+		{Resource: quota.IPs, Limit: 8, Usage: 3},
+	}, quotas)
+
+	s.gce.On("GetRegion", "zone").Return(nil, errors.New("err")).Once()
+	_, err = s.Quotas()
+	s.EqualError(err, "get region: err")
+
+	s.Equal("us-east1", zoneRegion("us-east1-b"))
+}
+
 func (s *GoogleTestSuite) TestListFirewalls() {
 	s.networkName = "network"
 	s.intFW = "intFW"
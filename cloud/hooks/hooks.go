@@ -0,0 +1,180 @@
+// Package hooks runs the lifecycle hooks that blueprints register on machines.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+)
+
+// Hooks are killed if they take longer than this to run.
+const timeout = 30 * time.Second
+
+// The hooks run by RunAll are killed if they haven't all finished by this
+// deadline, so that a slow hook can't hold up every other machine.
+var allTimeout = time.Minute
+
+var c = counter.New("Hooks")
+
+// The description of the machine given to hooks.  Commands receive it as
+// environment variables, e.g. QUILT_PUBLIC_IP, and webhooks as JSON.
+type payload struct {
+	Event     string
+	ID        string
+	Role      string
+	Provider  string
+	Region    string
+	Size      string
+	CloudID   string
+	PublicIP  string
+	PrivateIP string
+
+	// Only set for disk-usage hooks.
+	Partition string `json:",omitempty"`
+	DiskUsage int    `json:",omitempty"`
+}
+
+// Run runs the hooks of `m` that are registered for `event`.  Every hook is run,
+// even if an earlier one fails, and an error is returned if any failed.
+func Run(event string, m db.Machine) error {
+	return run(context.Background(), newPayload(event, m), m.Hooks)
+}
+
+// RunAll runs the hooks of each of `machines` that are registered for `event`.
+// The hooks of different machines run concurrently, and are killed if they
+// haven't finished within a shared deadline.  The returned errors correspond to
+// the machines at the same index, and are nil for those whose hooks succeeded.
+func RunAll(event string, machines []db.Machine) []error {
+	ctx, cancel := context.WithTimeout(context.Background(), allTimeout)
+	defer cancel()
+
+	errs := make([]error, len(machines))
+	var wg sync.WaitGroup
+	for i, m := range machines {
+		wg.Add(1)
+		go func(i int, m db.Machine) {
+			defer wg.Done()
+			errs[i] = run(ctx, newPayload(event, m), m.Hooks)
+		}(i, m)
+	}
+	wg.Wait()
+	return errs
+}
+
+// RunDiskUsage runs the disk-usage hooks of `m`, which are told that `partition`
+// is `usage` percent full.
+func RunDiskUsage(m db.Machine, partition string, usage int) error {
+	p := newPayload(blueprint.DiskUsage, m)
+	p.Partition = partition
+	p.DiskUsage = usage
+	return run(context.Background(), p, m.Hooks)
+}
+
+func newPayload(event string, m db.Machine) payload {
+	return payload{
+		Event:     event,
+		ID:        m.BlueprintID,
+		Role:      string(m.Role),
+		Provider:  string(m.Provider),
+		Region:    m.Region,
+		Size:      m.Size,
+		CloudID:   m.CloudID,
+		PublicIP:  m.PublicIP,
+		PrivateIP: m.PrivateIP,
+	}
+}
+
+func run(ctx context.Context, p payload, hooks []blueprint.MachineHook) error {
+	event := p.Event
+
+	var failed []string
+	for _, hook := range hooks {
+		if hook.Event != event {
+			continue
+		}
+
+		c.Inc(event)
+		var err error
+		if hook.Command != "" {
+			err = runCommand(ctx, hook.Command, p)
+		} else {
+			err = postWebhook(ctx, hook.URL, p)
+		}
+
+		if err != nil {
+			c.Inc(event + " Error")
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s hooks failed: %s", event, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func runCommand(ctx context.Context, command string, p payload) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"QUILT_EVENT="+p.Event,
+		"QUILT_MACHINE_ID="+p.ID,
+		"QUILT_ROLE="+p.Role,
+		"QUILT_PROVIDER="+p.Provider,
+		"QUILT_REGION="+p.Region,
+		"QUILT_SIZE="+p.Size,
+		"QUILT_CLOUD_ID="+p.CloudID,
+		"QUILT_PUBLIC_IP="+p.PublicIP,
+		"QUILT_PRIVATE_IP="+p.PrivateIP)
+	if p.Partition != "" {
+		cmd.Env = append(cmd.Env, "QUILT_PARTITION="+p.Partition,
+			"QUILT_DISK_USAGE="+strconv.Itoa(p.DiskUsage))
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%q: %s (%s)", command, err,
+			strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func postWebhook(ctx context.Context, url string, p payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
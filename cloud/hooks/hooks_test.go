@@ -0,0 +1,94 @@
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestRunCommand(t *testing.T) {
+	m := db.Machine{PublicIP: "1.2.3.4", Hooks: []blueprint.MachineHook{
+		{Event: blueprint.PreBoot,
+			Command: `test "$QUILT_PUBLIC_IP" = 1.2.3.4`},
+		{Event: blueprint.PreStop, Command: "echo oops; exit 1"},
+	}}
+
+	assert.NoError(t, Run(blueprint.PreBoot, m))
+	assert.NoError(t, Run(blueprint.PostConnect, m))
+	assert.EqualError(t, Run(blueprint.PreStop, m), `pre-stop hooks failed: `+
+		`"echo oops; exit 1": exit status 1 (oops)`)
+}
+
+func TestRunWebhook(t *testing.T) {
+	var received []payload
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var p payload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+			received = append(received, p)
+			if p.Event == blueprint.PreStop {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+	defer server.Close()
+
+	m := db.Machine{BlueprintID: "id", Role: db.Worker, Hooks: []blueprint.MachineHook{
+		{Event: blueprint.PostConnect, URL: server.URL},
+		{Event: blueprint.PreStop, URL: server.URL},
+	}}
+
+	assert.NoError(t, Run(blueprint.PostConnect, m))
+	assert.Equal(t, []payload{{Event: blueprint.PostConnect, ID: "id",
+		Role: "Worker"}}, received)
+
+	assert.Error(t, Run(blueprint.PreStop, m))
+	assert.Len(t, received, 2)
+}
+
+func TestRunDiskUsage(t *testing.T) {
+	m := db.Machine{PublicIP: "1.2.3.4", Hooks: []blueprint.MachineHook{
+		{Event: blueprint.DiskUsage, Command: `test "$QUILT_PARTITION" = docker ` +
+			`-a "$QUILT_DISK_USAGE" = 91`},
+		{Event: blueprint.PostConnect, Command: "exit 1"},
+	}}
+
+	assert.NoError(t, RunDiskUsage(m, "docker", 91))
+	assert.Error(t, RunDiskUsage(m, "docker", 80))
+}
+
+func TestRunAll(t *testing.T) {
+	hook := func(command string) []blueprint.MachineHook {
+		return []blueprint.MachineHook{
+			{Event: blueprint.PreBoot, Command: command}}
+	}
+	machines := []db.Machine{
+		{Hooks: hook("sleep 1")},
+		{Hooks: hook("sleep 1; exit 1")},
+		{},
+	}
+
+	// The machines' hooks run concurrently.
+	start := time.Now()
+	errs := RunAll(blueprint.PreBoot, machines)
+	assert.True(t, time.Since(start) < 2*time.Second)
+	assert.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.NoError(t, errs[2])
+
+	// Hooks that are still running at the deadline are killed.
+	allTimeout = 100 * time.Millisecond
+	defer func() { allTimeout = time.Minute }()
+
+	start = time.Now()
+	errs = RunAll(blueprint.PreBoot, []db.Machine{{Hooks: hook("exec sleep 10")}})
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Error(t, errs[0])
+}
@@ -0,0 +1,236 @@
+//go:generate mockery -name=Client
+
+// Package client is a minimal client of the IBM Cloud VPC API.  Requests are
+// scoped to a region, and authenticated with IAM tokens obtained for the user's
+// API key.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+)
+
+// The version of the VPC API that requests are made against.
+const apiVersion = "2019-10-08"
+
+// A Client for the IBM Cloud VPC API.  Used for unit testing.
+type Client interface {
+	// Do sends a `method` request to `path`, which is relative to the VPC API
+	// of the client's region, and decodes the response into `result` if it's
+	// non-nil.  `body` is sent as JSON.
+	Do(method, path string, body, result interface{}) error
+
+	// AttachTags attaches the user tags `tags` to the resources with the
+	// given CRNs.
+	AttachTags(crns, tags []string) error
+}
+
+// An Error is returned when the API rejects a request.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// IsNotFound returns whether `err` reports that a resource doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Credentials are the API key for which IAM tokens are requested.
+type Credentials struct {
+	APIKey string
+}
+
+var c = counter.New("IBM")
+
+type client struct {
+	http   *http.Client
+	creds  Credentials
+	region string
+
+	// The IAM token, which is refreshed shortly before it expires.
+	tokenLock sync.Mutex
+	token     string
+	expires   time.Time
+}
+
+// New creates a client of the API in `region`, whose requests are recorded or
+// replayed as `cassetteName`.
+func New(creds Credentials, region, cassetteName string) Client {
+	return &client{
+		http: cassette.Wrap(cassetteName, throttle.Wrap(db.IBM, region,
+			endpoint.Client(db.IBM, &http.Client{}))),
+		creds:  creds,
+		region: region,
+	}
+}
+
+func (client *client) Do(method, path string, body, result interface{}) error {
+	c.Inc(method)
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	reqURL := vpcURL(client.region) + "v1" + path + sep +
+		"version=" + apiVersion + "&generation=2"
+	return client.send(method, reqURL, body, result)
+}
+
+func (client *client) AttachTags(crns, tags []string) error {
+	c.Inc("Attach Tags")
+
+	var resources []map[string]string
+	for _, crn := range crns {
+		resources = append(resources, map[string]string{"resource_id": crn})
+	}
+
+	return client.send("POST", tagsURL()+"v3/tags/attach",
+		map[string]interface{}{"resources": resources, "tag_names": tags}, nil)
+}
+
+func (client *client) send(method, reqURL string, body,
+	result interface{}) error {
+
+	token, err := client.getToken()
+	if err != nil {
+		return fmt.Errorf("get IAM token: %s", err)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, reqURL, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return client.do(req, result)
+}
+
+func (client *client) do(req *http.Request, result interface{}) error {
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := Error{StatusCode: resp.StatusCode, Code: resp.Status}
+		var errResp struct {
+			Errors []struct{ Code, Message string }
+
+			// The IAM API reports errors differently.
+			ErrorCode    string `json:"errorCode"`
+			ErrorMessage string `json:"errorMessage"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil {
+			if len(errResp.Errors) > 0 {
+				apiErr.Code = errResp.Errors[0].Code
+				apiErr.Message = errResp.Errors[0].Message
+			} else if errResp.ErrorCode != "" {
+				apiErr.Code = errResp.ErrorCode
+				apiErr.Message = errResp.ErrorMessage
+			}
+		}
+		return apiErr
+	}
+
+	if result != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+// getToken returns an IAM token for the client's API key, requesting a new one
+// if the current one is about to expire.
+func (client *client) getToken() (string, error) {
+	client.tokenLock.Lock()
+	defer client.tokenLock.Unlock()
+
+	if client.token != "" && time.Now().Add(time.Minute).Before(client.expires) {
+		return client.token, nil
+	}
+
+	c.Inc("Get Token")
+	form := url.Values{
+		"grant_type": {"urn:ibm:params:oauth:grant-type:apikey"},
+		"apikey":     {client.creds.APIKey},
+	}
+	req, err := http.NewRequest("POST", iamURL()+"identity/token",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		Expiration  int64
+	}
+	if err := client.do(req, &resp); err != nil {
+		return "", err
+	}
+
+	client.token = resp.AccessToken
+	client.expires = time.Unix(resp.Expiration, 0)
+	return client.token, nil
+}
+
+// The endpoint override, if set, serves every API, as their paths don't overlap.
+
+func vpcURL(region string) string {
+	if base, ok := endpoint.Get(db.IBM); ok {
+		return base
+	}
+	return "https://" + region + ".iaas.cloud.ibm.com/"
+}
+
+func iamURL() string {
+	if base, ok := endpoint.Get(db.IBM); ok {
+		return base
+	}
+	return "https://iam.cloud.ibm.com/"
+}
+
+func tagsURL() string {
+	if base, ok := endpoint.Get(db.IBM); ok {
+		return base
+	}
+	return "https://tags.global-search-tagging.cloud.ibm.com/"
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestDo(t *testing.T) {
+	var tokens int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/identity/token" {
+				tokens++
+				assert.NoError(t, r.ParseForm())
+				assert.Equal(t, "key", r.PostForm.Get("apikey"))
+				fmt.Fprintf(w, `{"access_token": "token", "expiration": %d}`,
+					time.Now().Add(time.Hour).Unix())
+				return
+			}
+
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			switch r.Method + " " + r.URL.Path {
+			case "POST /v1/instances":
+				assert.Equal(t, apiVersion, r.URL.Query().Get("version"))
+				assert.Equal(t, "2", r.URL.Query().Get("generation"))
+				var body map[string]string
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				fmt.Fprintf(w, `{"name": %q}`, body["name"])
+			case "GET /v1/instances":
+				assert.Equal(t, "quilt-ns", r.URL.Query().Get("vpc.name"))
+				fmt.Fprint(w, `{"instances": []}`)
+			case "POST /v3/tags/attach":
+				var body struct {
+					Resources []struct {
+						ResourceID string `json:"resource_id"`
+					}
+					TagNames []string `json:"tag_names"`
+				}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.Equal(t, "crn", body.Resources[0].ResourceID)
+				assert.Equal(t, []string{"tag"}, body.TagNames)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"errors": [{"code": "not_found",
+					"message": "Instance not found"}]}`)
+			}
+		}))
+	defer server.Close()
+
+	endpoint.Overrides[db.IBM] = server.URL
+	defer delete(endpoint.Overrides, db.IBM)
+
+	client := New(Credentials{APIKey: "key"}, "us-south", "")
+
+	var res struct{ Name string }
+	err := client.Do("POST", "/instances", map[string]string{"name": "name"},
+		&res)
+	assert.NoError(t, err)
+	assert.Equal(t, "name", res.Name)
+
+	err = client.Do("GET", "/instances?vpc.name=quilt-ns", nil, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.AttachTags([]string{"crn"}, []string{"tag"}))
+
+	err = client.Do("DELETE", "/instances/missing", nil, nil)
+	assert.EqualError(t, err, "not_found: Instance not found")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+
+	// The token is reused until it's about to expire.
+	assert.Equal(t, 1, tokens)
+}
+
+func TestTokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errorCode": "BXNIM0415E",
+				"errorMessage": "Provided API key could not be found"}`)
+		}))
+	defer server.Close()
+
+	endpoint.Overrides[db.IBM] = server.URL
+	defer delete(endpoint.Overrides, db.IBM)
+
+	err := New(Credentials{APIKey: "bad"}, "us-south", "").Do("GET",
+		"/instances", nil, nil)
+	assert.EqualError(t, err, "get IAM token: BXNIM0415E: "+
+		"Provided API key could not be found")
+}
@@ -0,0 +1,980 @@
+package ibm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/ibm/client"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/wait"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/util"
+
+	"github.com/satori/go.uuid"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRegion is assigned to Machines without a specified region.
+const DefaultRegion string = "us-south"
+
+// Regions supported by the IBM provider.  Machines are booted in the first zone
+// of their region.
+var Regions = []string{
+	"au-syd",
+	"eu-de",
+	"eu-gb",
+	"jp-tok",
+	"us-east",
+	"us-south",
+}
+
+var configPath = filepath.Join(".ibmcloud", "quilt.json")
+
+// The environment variables, in order of precedence, that override the API key
+// in the config file.
+var apiKeyEnvs = []string{"IBMCLOUD_API_KEY", "IC_API_KEY"}
+
+// The key of the tag whose value is the namespace of the instance.
+const namespaceTag = "quilt-namespace"
+
+// The operating system of the public images that machines boot by default.
+const defaultOS = "ubuntu-16-04-amd64"
+
+// The prefix of the names of floating IPs that are allocated for machines, as
+// opposed to those reserved for use as a blueprint's floating IPs.
+const allocatedIPPrefix = "quilt-fip-"
+
+// The number of resources requested in each page of a list.
+const perPage = 100
+
+// config is the configuration in ~/.ibmcloud/quilt.json.
+type config struct {
+	client.Credentials
+
+	// The ID of the image that machines boot.  If it's empty, the newest
+	// public Ubuntu 16.04 image of the region is used.
+	Image string
+}
+
+func readConfig() (config, error) {
+	var conf config
+
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+	confStr, err := util.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(confStr), &conf); err != nil {
+			return conf, fmt.Errorf("parse %s: %s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return conf, err
+	}
+
+	creds.FromEnv(&conf.APIKey, apiKeyEnvs...)
+	if conf.APIKey == "" {
+		var sources []string
+		for _, env := range apiKeyEnvs {
+			sources = append(sources, creds.EnvSource(env))
+		}
+		return conf, creds.MissingError{Provider: db.IBM,
+			Sources: append([]string{path}, sources...)}
+	}
+	return conf, nil
+}
+
+// The Provider object represents a connection to an IBM Cloud VPC region.  Each
+// namespace has its own VPC, which contains the namespace's instances, a subnet
+// in each zone that they're booted in, and a security group that implements the
+// blueprint's ACLs.  Instances are also tagged with the namespace.
+type Provider struct {
+	client.Client
+
+	namespace string
+	region    string
+	config    config
+}
+
+// New starts a new client session with the API key in ~/.ibmcloud/quilt.json,
+// or in the environment.
+func New(namespace, region string) (*Provider, error) {
+	prvdr, err := newIBM(namespace, region)
+	if err != nil {
+		return prvdr, err
+	}
+
+	_, err = prvdr.List()
+	return prvdr, err
+}
+
+// Creation is broken out for unit testing.
+var newIBM = func(namespace, region string) (*Provider, error) {
+	// Replayed responses don't require valid credentials.
+	var conf config
+	if !cassette.Replaying() {
+		var err error
+		if conf, err = readConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Provider{
+		Client:    client.New(conf.Credentials, region, "ibm-"+region),
+		namespace: namespace,
+		region:    region,
+		config:    conf,
+	}, nil
+}
+
+type reference struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type instance struct {
+	ID      string
+	CRN     string
+	Name    string
+	Status  string
+	Profile reference
+	Zone    reference
+	NIC     struct {
+		ID        string
+		PrivateIP string `json:"primary_ipv4_address"`
+	} `json:"primary_network_interface"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type floatingIP struct {
+	ID      string
+	Name    string
+	Address string
+	Zone    reference
+
+	// The network interface that the floating IP is bound to, if any.
+	Target *reference
+}
+
+func (ip floatingIP) allocated() bool {
+	return strings.HasPrefix(ip.Name, allocatedIPPrefix)
+}
+
+// List the instances in the namespace's VPC.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	instances, err := prvdr.instances()
+	if err != nil {
+		return nil, err
+	}
+
+	nicToIP, err := prvdr.boundIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []db.Machine
+	for _, inst := range instances {
+		m := db.Machine{
+			CloudID:   inst.ID,
+			Size:      inst.Profile.Name,
+			PrivateIP: inst.NIC.PrivateIP,
+		}
+
+		if ip, ok := nicToIP[inst.NIC.ID]; ok {
+			m.PublicIP = ip.Address
+			if !ip.allocated() {
+				m.FloatingIP = ip.Address
+			}
+		}
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+// The name of the namespace's VPC and security group.  Names must be lowercase.
+func (prvdr Provider) networkName() string {
+	return "quilt-" + strings.ToLower(prvdr.namespace)
+}
+
+func (prvdr Provider) zone() string {
+	return prvdr.region + "-1"
+}
+
+// list decodes every resource listed at `path` under the key `key` into `result`,
+// following the pages of the results.
+func (prvdr Provider) list(path, key string, result interface{}) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	var items []json.RawMessage
+	start := ""
+	for {
+		pagePath := fmt.Sprintf("%s%slimit=%d", path, sep, perPage)
+		if start != "" {
+			pagePath += "&start=" + url.QueryEscape(start)
+		}
+
+		var resp map[string]json.RawMessage
+		if err := prvdr.Do("GET", pagePath, nil, &resp); err != nil {
+			return fmt.Errorf("list %s: %s", key, err)
+		}
+
+		var page []json.RawMessage
+		if err := json.Unmarshal(resp[key], &page); err != nil {
+			return fmt.Errorf("list %s: %s", key, err)
+		}
+		items = append(items, page...)
+
+		var next struct{ Href string }
+		if raw, ok := resp["next"]; ok {
+			json.Unmarshal(raw, &next)
+		}
+
+		nextURL, err := url.Parse(next.Href)
+		if err != nil || next.Href == "" {
+			break
+		}
+
+		start = nextURL.Query().Get("start")
+		if start == "" {
+			break
+		}
+	}
+
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// instances returns the instances in the namespace's VPC.
+func (prvdr Provider) instances() ([]instance, error) {
+	var instances []instance
+	err := prvdr.list("/instances?vpc.name="+url.QueryEscape(prvdr.networkName()),
+		"instances", &instances)
+	return instances, err
+}
+
+// instance returns the instance with the given ID, or false if it doesn't exist.
+func (prvdr Provider) instance(id string) (instance, bool, error) {
+	var inst instance
+	err := prvdr.Do("GET", "/instances/"+id, nil, &inst)
+	if client.IsNotFound(err) {
+		return instance{}, false, nil
+	} else if err != nil {
+		return instance{}, false, fmt.Errorf("get instance %s: %s", id, err)
+	}
+	return inst, true, nil
+}
+
+func (prvdr Provider) floatingIPs() ([]floatingIP, error) {
+	var ips []floatingIP
+	err := prvdr.list("/floating_ips", "floating_ips", &ips)
+	return ips, err
+}
+
+// boundIPs returns the floating IPs that are bound to network interfaces, keyed
+// by the interfaces' ID.
+func (prvdr Provider) boundIPs() (map[string]floatingIP, error) {
+	ips, err := prvdr.floatingIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	nicToIP := map[string]floatingIP{}
+	for _, ip := range ips {
+		if ip.Target != nil {
+			nicToIP[ip.Target.ID] = ip
+		}
+	}
+	return nicToIP, nil
+}
+
+// The network resources that instances are booted with.
+type network struct {
+	vpcID, subnetID, groupID string
+}
+
+// Boot creates each instance in a goroutine, and waits for them to start.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	for _, m := range bootSet {
+		if m.Preemptible {
+			return errors.New("preemptible instances are not yet implemented")
+		}
+	}
+
+	net, err := prvdr.network()
+	if err != nil {
+		return err
+	}
+
+	imageID, err := prvdr.image()
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error, len(bootSet))
+	for _, m := range bootSet {
+		go func(m db.Machine) {
+			errChan <- prvdr.createInstance(m, net, imageID)
+		}(m)
+	}
+
+	for range bootSet {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// network returns the namespace's VPC, its subnet in the zone that instances are
+// booted in, and its security group, creating any that don't exist.
+func (prvdr Provider) network() (network, error) {
+	vpcID, err := prvdr.vpc()
+	if err != nil {
+		return network{}, err
+	}
+
+	subnetID, err := prvdr.subnet(vpcID)
+	if err != nil {
+		return network{}, err
+	}
+
+	groupID, err := prvdr.securityGroup(vpcID)
+	if err != nil {
+		return network{}, err
+	}
+	return network{vpcID: vpcID, subnetID: subnetID, groupID: groupID}, nil
+}
+
+type vpc struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// namespaceVPC returns the namespace's VPC, or false if it doesn't exist.
+func (prvdr Provider) namespaceVPC() (vpc, bool, error) {
+	var vpcs []vpc
+	if err := prvdr.list("/vpcs", "vpcs", &vpcs); err != nil {
+		return vpc{}, false, err
+	}
+
+	for _, v := range vpcs {
+		if v.Name == prvdr.networkName() {
+			return v, true, nil
+		}
+	}
+	return vpc{}, false, nil
+}
+
+func (prvdr Provider) vpc() (string, error) {
+	v, ok, err := prvdr.namespaceVPC()
+	if err != nil || ok {
+		return v.ID, err
+	}
+
+	err = prvdr.Do("POST", "/vpcs", map[string]string{
+		"name":                      prvdr.networkName(),
+		"address_prefix_management": "auto",
+	}, &v)
+	if err != nil {
+		return "", fmt.Errorf("create VPC: %s", err)
+	}
+	return v.ID, nil
+}
+
+type subnet struct {
+	ID        string
+	Name      string
+	Zone      reference
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (prvdr Provider) subnets(vpcID string) ([]subnet, error) {
+	var subnets []subnet
+	err := prvdr.list("/subnets?vpc.id="+url.QueryEscape(vpcID), "subnets",
+		&subnets)
+	return subnets, err
+}
+
+func (prvdr Provider) subnet(vpcID string) (string, error) {
+	subnets, err := prvdr.subnets(vpcID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range subnets {
+		if s.Zone.Name == prvdr.zone() {
+			return s.ID, nil
+		}
+	}
+
+	var s subnet
+	err = prvdr.Do("POST", "/subnets", map[string]interface{}{
+		"name":                     prvdr.networkName() + "-" + prvdr.zone(),
+		"vpc":                      reference{ID: vpcID},
+		"zone":                     reference{Name: prvdr.zone()},
+		"total_ipv4_address_count": 256,
+	}, &s)
+	if err != nil {
+		return "", fmt.Errorf("create subnet: %s", err)
+	}
+	return s.ID, nil
+}
+
+type securityGroup struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (prvdr Provider) securityGroups(vpcID string) ([]securityGroup, error) {
+	var groups []securityGroup
+	err := prvdr.list("/security_groups?vpc.id="+url.QueryEscape(vpcID),
+		"security_groups", &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var named []securityGroup
+	for _, group := range groups {
+		if group.Name == prvdr.networkName() {
+			named = append(named, group)
+		}
+	}
+	return named, nil
+}
+
+// securityGroup returns the ID of the namespace's security group, creating it if
+// it doesn't exist.  New groups allow all traffic between their instances, and
+// all outbound traffic.
+func (prvdr Provider) securityGroup(vpcID string) (string, error) {
+	groups, err := prvdr.securityGroups(vpcID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(groups) > 0 {
+		return groups[0].ID, nil
+	}
+
+	var group securityGroup
+	err = prvdr.Do("POST", "/security_groups", map[string]interface{}{
+		"name": prvdr.networkName(),
+		"vpc":  reference{ID: vpcID},
+		"rules": []securityRule{
+			{Direction: "outbound", IPVersion: "ipv4", Protocol: "all"},
+		},
+	}, &group)
+	if err != nil {
+		return "", fmt.Errorf("create security group: %s", err)
+	}
+
+	// Rules that refer to the group itself can only be added once it exists.
+	err = prvdr.Do("POST", "/security_groups/"+group.ID+"/rules", securityRule{
+		Direction: "inbound",
+		IPVersion: "ipv4",
+		Protocol:  "all",
+		Remote:    &remote{ID: group.ID},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("create security group rule: %s", err)
+	}
+	return group.ID, nil
+}
+
+// image returns the ID of the image that instances boot.
+func (prvdr Provider) image() (string, error) {
+	if prvdr.config.Image != "" {
+		return prvdr.config.Image, nil
+	}
+
+	var images []struct {
+		ID              string
+		Status          string
+		OperatingSystem reference `json:"operating_system"`
+		CreatedAt       time.Time `json:"created_at"`
+	}
+	if err := prvdr.list("/images?visibility=public", "images",
+		&images); err != nil {
+		return "", err
+	}
+
+	var id string
+	var newest time.Time
+	for _, img := range images {
+		if img.Status == "available" && img.OperatingSystem.Name == defaultOS &&
+			!img.CreatedAt.Before(newest) {
+			id, newest = img.ID, img.CreatedAt
+		}
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("no %s image in %s. Configure one in ~/%s",
+			defaultOS, prvdr.region, configPath)
+	}
+	return id, nil
+}
+
+// createInstance creates an instance, tags it with the namespace, and allocates a
+// floating IP for it once it's running.  If the instance fails to boot, it's
+// deleted.
+func (prvdr Provider) createInstance(m db.Machine, net network,
+	imageID string) error {
+
+	name := "quilt-" + uuid.NewV4().String()
+	if m.Hostname != "" {
+		name = m.Hostname
+	}
+
+	req := map[string]interface{}{
+		"name":    name,
+		"profile": reference{Name: m.Size},
+		"image":   reference{ID: imageID},
+		"zone":    reference{Name: prvdr.zone()},
+		"vpc":     reference{ID: net.vpcID},
+		"primary_network_interface": map[string]interface{}{
+			"name":            "eth0",
+			"subnet":          reference{ID: net.subnetID},
+			"security_groups": []reference{{ID: net.groupID}},
+		},
+		"user_data": cfg.UserData(m, ""),
+	}
+
+	if m.DiskSize != 0 {
+		req["boot_volume_attachment"] = map[string]interface{}{
+			"delete_volume_on_instance_delete": true,
+			"volume": map[string]interface{}{
+				"capacity": m.DiskSize,
+				"profile":  reference{Name: "general-purpose"},
+			},
+		}
+	}
+
+	var inst instance
+	if err := prvdr.Do("POST", "/instances", req, &inst); err != nil {
+		return fmt.Errorf("create instance: %s", err)
+	}
+
+	if err := prvdr.setupInstance(inst); err != nil {
+		if cleanupErr := prvdr.deleteInstance(inst.ID); cleanupErr != nil {
+			log.WithError(cleanupErr).WithField("id", inst.ID).Warn(
+				"Failed to clean up instance that failed to boot")
+		}
+		return err
+	}
+	return nil
+}
+
+func (prvdr Provider) setupInstance(inst instance) error {
+	// The tag isn't relied on to list the namespace's instances, so instances
+	// that fail to be tagged are still usable.
+	err := prvdr.AttachTags([]string{inst.CRN},
+		[]string{namespaceTag + ":" + prvdr.namespace})
+	if err != nil {
+		log.WithError(err).WithField("id", inst.ID).Warn(
+			"Failed to tag instance with its namespace")
+	}
+
+	err = wait.Wait(func() bool {
+		inst, ok, err := prvdr.instance(inst.ID)
+		return err == nil && ok && inst.Status == "running"
+	})
+	if err != nil {
+		return fmt.Errorf("wait for instance %s to run: %s", inst.ID, err)
+	}
+
+	_, err = prvdr.allocateIP(inst.NIC.ID)
+	return err
+}
+
+// allocateIP allocates a floating IP, and binds it to the network interface with
+// ID `nicID`.
+func (prvdr Provider) allocateIP(nicID string) (floatingIP, error) {
+	var ip floatingIP
+	err := prvdr.Do("POST", "/floating_ips", map[string]interface{}{
+		"name":   allocatedIPPrefix + uuid.NewV4().String(),
+		"target": reference{ID: nicID},
+	}, &ip)
+	if err != nil {
+		return floatingIP{}, fmt.Errorf("allocate floating IP: %s", err)
+	}
+	return ip, nil
+}
+
+func (prvdr Provider) releaseIP(id string) error {
+	err := prvdr.Do("DELETE", "/floating_ips/"+id, nil, nil)
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("release floating IP %s: %s", id, err)
+	}
+	return nil
+}
+
+func nicIPPath(inst instance, ipID string) string {
+	return fmt.Sprintf("/instances/%s/network_interfaces/%s/floating_ips/%s",
+		inst.ID, inst.NIC.ID, ipID)
+}
+
+// Stop deletes each instance, and releases the floating IPs allocated for them.
+func (prvdr Provider) Stop(machines []db.Machine) error {
+	errChan := make(chan error, len(machines))
+	for _, m := range machines {
+		go func(m db.Machine) {
+			errChan <- prvdr.deleteInstance(m.CloudID)
+		}(m)
+	}
+
+	var err error
+	for range machines {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Reboot restarts each instance in place.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		err := prvdr.Do("POST", "/instances/"+m.CloudID+"/actions",
+			map[string]string{"type": "reboot"}, nil)
+		if err != nil {
+			return fmt.Errorf("reboot instance %s: %s", m.CloudID, err)
+		}
+	}
+	return nil
+}
+
+// deleteInstance releases the floating IP allocated for the instance, and deletes
+// the instance.
+func (prvdr Provider) deleteInstance(id string) error {
+	inst, ok, err := prvdr.instance(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	nicToIP, err := prvdr.boundIPs()
+	if err != nil {
+		return err
+	}
+
+	// Floating IPs are unbound when the instance is deleted, so allocated
+	// ones are released first, to avoid losing track of them.
+	if ip, ok := nicToIP[inst.NIC.ID]; ok && ip.allocated() {
+		if err := prvdr.releaseIP(ip.ID); err != nil {
+			return err
+		}
+	}
+
+	err = prvdr.Do("DELETE", "/instances/"+id, nil, nil)
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("delete instance %s: %s", id, err)
+	}
+
+	err = wait.Wait(func() bool {
+		_, ok, err := prvdr.instance(id)
+		return err == nil && !ok
+	})
+	if err != nil {
+		return fmt.Errorf("wait for instance %s to be deleted: %s", id, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional features that IBM supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true, ResizableDisks: true,
+	}
+}
+
+// UpdateFloatingIPs binds instances to the floating IPs listed as their floating
+// IP.  Instances without a floating IP are bound to a newly allocated floating IP
+// instead, so that they can still reach the internet.
+func (prvdr Provider) UpdateFloatingIPs(desired []db.Machine) error {
+	curr, err := prvdr.List()
+	if err != nil {
+		return fmt.Errorf("list machines: %s", err)
+	}
+
+	idKey := func(intf interface{}) interface{} {
+		return intf.(db.Machine).CloudID
+	}
+	pairs, _, unmatchedDesired := join.HashJoin(
+		db.MachineSlice(curr), db.MachineSlice(desired), idKey, idKey)
+
+	if len(unmatchedDesired) != 0 {
+		var unmatchedIDs []string
+		for _, m := range unmatchedDesired {
+			unmatchedIDs = append(unmatchedIDs, m.(db.Machine).CloudID)
+		}
+		return fmt.Errorf("no matching IDs: %s", strings.Join(unmatchedIDs, ", "))
+	}
+
+	for _, pair := range pairs {
+		curr := pair.L.(db.Machine)
+		desired := pair.R.(db.Machine)
+		if curr.FloatingIP == desired.FloatingIP {
+			continue
+		}
+
+		if err := prvdr.updateFloatingIP(curr.CloudID,
+			desired.FloatingIP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (prvdr Provider) updateFloatingIP(instanceID, address string) error {
+	inst, ok, err := prvdr.instance(instanceID)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no instance %s", instanceID)
+	}
+
+	ips, err := prvdr.floatingIPs()
+	if err != nil {
+		return err
+	}
+
+	var desired *floatingIP
+	for i, ip := range ips {
+		if address != "" && ip.Address == address {
+			desired = &ips[i]
+		}
+	}
+
+	if address != "" && desired == nil {
+		return fmt.Errorf("no floating IP %s in %s", address, prvdr.region)
+	}
+
+	// A network interface can only have one floating IP, so the current one is
+	// unbound, and released if it was allocated for the instance.
+	for _, ip := range ips {
+		if ip.Target == nil || ip.Target.ID != inst.NIC.ID {
+			continue
+		}
+
+		if ip.allocated() {
+			err = prvdr.releaseIP(ip.ID)
+		} else {
+			err = prvdr.Do("DELETE", nicIPPath(inst, ip.ID), nil, nil)
+		}
+
+		if err != nil {
+			return fmt.Errorf("unbind floating IP %s: %s", ip.Address, err)
+		}
+	}
+
+	if desired == nil {
+		_, err := prvdr.allocateIP(inst.NIC.ID)
+		return err
+	}
+
+	if err := prvdr.Do("PUT", nicIPPath(inst, desired.ID), nil, nil); err != nil {
+		return fmt.Errorf("bind floating IP %s: %s", address, err)
+	}
+	return nil
+}
+
+// Resources lists the namespace's instances, the floating IPs bound to them, and
+// its VPC, subnets, and security group.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	instances, err := prvdr.instances()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	nics := map[string]bool{}
+	for _, inst := range instances {
+		nics[inst.NIC.ID] = true
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      inst.ID,
+			Name:    inst.Name,
+			Tags:    map[string]string{namespaceTag: prvdr.namespace},
+			Created: inst.CreatedAt,
+		})
+	}
+
+	ips, err := prvdr.floatingIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		if ip.Target != nil && nics[ip.Target.ID] {
+			resources = append(resources, resource.Resource{
+				Type: resource.IP,
+				ID:   ip.ID,
+				Name: ip.Address,
+			})
+		}
+	}
+
+	v, ok, err := prvdr.namespaceVPC()
+	if err != nil || !ok {
+		return resources, err
+	}
+
+	subnets, err := prvdr.subnets(v.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range subnets {
+		resources = append(resources, resource.Resource{
+			Type:    resource.Network,
+			ID:      s.ID,
+			Name:    s.Name,
+			Created: s.CreatedAt,
+		})
+	}
+
+	groups, err := prvdr.securityGroups(v.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		resources = append(resources, resource.Resource{
+			Type:    resource.SecurityGroup,
+			ID:      group.ID,
+			Name:    group.Name,
+			Created: group.CreatedAt,
+		})
+	}
+
+	return append(resources, resource.Resource{
+		Type:    resource.Network,
+		ID:      v.ID,
+		Name:    v.Name,
+		Created: v.CreatedAt,
+	}), nil
+}
+
+type remote struct {
+	ID        string `json:"id,omitempty"`
+	CIDRBlock string `json:"cidr_block,omitempty"`
+}
+
+// A securityRule of a security group.  Rules without ports apply to every port.
+type securityRule struct {
+	ID        string  `json:"id,omitempty"`
+	Direction string  `json:"direction"`
+	IPVersion string  `json:"ip_version"`
+	Protocol  string  `json:"protocol"`
+	PortMin   int     `json:"port_min,omitempty"`
+	PortMax   int     `json:"port_max,omitempty"`
+	Remote    *remote `json:"remote,omitempty"`
+}
+
+// SetACLs adds and removes the inbound rules of the namespace's security group
+// that allow traffic from CIDR blocks, so that it allows the given TCP, UDP, and
+// ICMP traffic.  The rule that allows traffic between the namespace's instances
+// refers to the group itself, so it's left untouched.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	vpcID, err := prvdr.vpc()
+	if err != nil {
+		return err
+	}
+
+	groupID, err := prvdr.securityGroup(vpcID)
+	if err != nil {
+		return err
+	}
+
+	rulesPath := "/security_groups/" + groupID + "/rules"
+	var resp struct{ Rules []securityRule }
+	if err := prvdr.Do("GET", rulesPath, nil, &resp); err != nil {
+		return fmt.Errorf("list security group rules: %s", err)
+	}
+
+	var currRules []securityRule
+	for _, rule := range resp.Rules {
+		if rule.Direction == "inbound" && rule.Remote != nil &&
+			rule.Remote.CIDRBlock != "" {
+			currRules = append(currRules, rule)
+		}
+	}
+
+	var desired []securityRule
+	for _, a := range acls {
+		ipVersion := "ipv4"
+		if a.IPv6() {
+			ipVersion = "ipv6"
+		}
+
+		for _, protocol := range []string{"tcp", "udp"} {
+			desired = append(desired, securityRule{
+				Direction: "inbound",
+				IPVersion: ipVersion,
+				Protocol:  protocol,
+				PortMin:   a.MinPort,
+				PortMax:   a.MaxPort,
+				Remote:    &remote{CIDRBlock: a.CidrIP},
+			})
+		}
+
+		desired = append(desired, securityRule{
+			Direction: "inbound",
+			IPVersion: ipVersion,
+			Protocol:  "icmp",
+			Remote:    &remote{CIDRBlock: a.CidrIP},
+		})
+	}
+
+	ruleKey := func(intf interface{}) interface{} {
+		rule := intf.(securityRule)
+		return [5]interface{}{rule.IPVersion, rule.Protocol,
+			rule.Remote.CIDRBlock, rule.PortMin, rule.PortMax}
+	}
+	_, toAdd, toRemove := join.HashJoin(securityRuleSlice(desired),
+		securityRuleSlice(currRules), ruleKey, ruleKey)
+
+	for _, intf := range toRemove {
+		rule := intf.(securityRule)
+		err := prvdr.Do("DELETE", rulesPath+"/"+rule.ID, nil, nil)
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("delete security group rule: %s", err)
+		}
+	}
+
+	for _, intf := range toAdd {
+		rule := intf.(securityRule)
+		log.WithField("rule", rule).Debug("IBM: Adding ACL")
+		if err := prvdr.Do("POST", rulesPath, rule, nil); err != nil {
+			return fmt.Errorf("create security group rule: %s", err)
+		}
+	}
+	return nil
+}
+
+type securityRuleSlice []securityRule
+
+func (slc securityRuleSlice) Get(ii int) interface{} {
+	return slc[ii]
+}
+
+func (slc securityRuleSlice) Len() int {
+	return len(slc)
+}
@@ -0,0 +1,495 @@
+package ibm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/cloudtest"
+	"github.com/kelda/kelda/cloud/ibm/client"
+	"github.com/kelda/kelda/cloud/ibm/client/mocks"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+const (
+	instancesPath = "/instances?vpc.name=quilt-ns&limit=100"
+	ipsPath       = "/floating_ips?limit=100"
+	vpcsPath      = "/vpcs?limit=100"
+	subnetsPath   = "/subnets?vpc.id=vpc&limit=100"
+	groupsPath    = "/security_groups?vpc.id=vpc&limit=100"
+)
+
+var notFound = client.Error{StatusCode: 404, Code: "not_found"}
+
+// The namespace is upper case, to check that the names of the VPC and security
+// group are lower case, as IBM requires.
+func newTestProvider() (Provider, *mocks.Client) {
+	mc := new(mocks.Client)
+	return Provider{
+		Client:    mc,
+		namespace: "NS",
+		region:    DefaultRegion,
+		config:    config{Image: "ubuntu"},
+	}, mc
+}
+
+func listReply(key string, items ...interface{}) func(mock.Arguments) {
+	if items == nil {
+		items = []interface{}{}
+	}
+	return cloudtest.Reply(map[string]interface{}{key: items})
+}
+
+func inst(id, nic string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "crn": "crn:" + id,
+		"status": "running", "primary_network_interface": map[string]string{
+			"id": nic, "primary_ipv4_address": "10.240.0.4"}}
+}
+
+func fip(id, name, address, nic string) map[string]interface{} {
+	ip := map[string]interface{}{"id": id, "name": name, "address": address}
+	if nic != "" {
+		ip["target"] = map[string]string{"id": nic}
+	}
+	return ip
+}
+
+// expectNetwork sets up the namespace's existing VPC, subnet, and security group.
+func expectNetwork(mc *mocks.Client) {
+	mc.On("Do", "GET", vpcsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("vpcs", map[string]string{"id": "vpc", "name": "quilt-ns"}))
+	mc.On("Do", "GET", subnetsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("subnets", map[string]interface{}{"id": "subnet",
+			"zone": map[string]string{"name": "us-south-1"}}))
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("security_groups", map[string]string{"id": "sg",
+			"name": "quilt-ns"}))
+}
+
+func TestList(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	// Results are followed across pages.
+	mc.On("Do", "GET", instancesPath, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{
+			"instances": []interface{}{map[string]interface{}{"id": "1",
+				"profile": map[string]string{"name": "cx2-2x4"},
+				"primary_network_interface": map[string]string{
+					"id": "nic-1", "primary_ipv4_address": "10.240.0.4"}}},
+			"next": map[string]string{"href": "https://us-south." +
+				"iaas.cloud.ibm.com/v1/instances?start=page2&limit=100"},
+		}))
+	mc.On("Do", "GET", instancesPath+"&start=page2", nil, mock.Anything).Return(
+		nil).Run(listReply("instances", inst("2", "nic-2"), inst("3", "nic-3")))
+
+	// Floating IPs are only a machine's floating IP if they weren't allocated
+	// for it.
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(listReply(
+		"floating_ips",
+		fip("fip-1", "quilt-fip-1", "169.61.0.1", "nic-1"),
+		fip("reserved", "reserved", "1.2.3.4", "nic-2"),
+		fip("unbound", "quilt-fip-2", "169.61.0.2", "")))
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{
+		{CloudID: "1", Size: "cx2-2x4", PrivateIP: "10.240.0.4",
+			PublicIP: "169.61.0.1"},
+		{CloudID: "2", PrivateIP: "10.240.0.4", PublicIP: "1.2.3.4",
+			FloatingIP: "1.2.3.4"},
+		{CloudID: "3", PrivateIP: "10.240.0.4"},
+	}, machines)
+}
+
+func TestBoot(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	// The VPC of another namespace is ignored, as is the subnet in another
+	// zone.
+	mc.On("Do", "GET", vpcsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("vpcs", map[string]string{"id": "other", "name": "quilt-ns2"}))
+	mc.On("Do", "POST", "/vpcs", map[string]string{
+		"name":                      "quilt-ns",
+		"address_prefix_management": "auto",
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"id": "vpc"})).Once()
+	mc.On("Do", "GET", subnetsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("subnets", map[string]interface{}{"id": "other",
+			"zone": map[string]string{"name": "us-south-2"}}))
+	mc.On("Do", "POST", "/subnets", map[string]interface{}{
+		"name":                     "quilt-ns-us-south-1",
+		"vpc":                      reference{ID: "vpc"},
+		"zone":                     reference{Name: "us-south-1"},
+		"total_ipv4_address_count": 256,
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"id": "subnet"})).Once()
+
+	// New security groups allow traffic from their own instances.
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("security_groups"))
+	mc.On("Do", "POST", "/security_groups", map[string]interface{}{
+		"name": "quilt-ns",
+		"vpc":  reference{ID: "vpc"},
+		"rules": []securityRule{{Direction: "outbound", IPVersion: "ipv4",
+			Protocol: "all"}},
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]string{
+		"id": "sg"})).Once()
+	mc.On("Do", "POST", "/security_groups/sg/rules", securityRule{
+		Direction: "inbound", IPVersion: "ipv4", Protocol: "all",
+		Remote: &remote{ID: "sg"}}, nil).Return(nil).Once()
+
+	var req map[string]interface{}
+	mc.On("Do", "POST", "/instances", mock.Anything, mock.Anything).Return(
+		nil).Run(func(args mock.Arguments) {
+		req = cloudtest.Body(args.Get(2))
+		cloudtest.Reply(inst("i-1", "nic-1"))(args)
+	}).Once()
+	mc.On("AttachTags", []string{"crn:i-1"},
+		[]string{"quilt-namespace:NS"}).Return(nil).Once()
+	mc.On("Do", "GET", "/instances/i-1", nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(inst("i-1", "nic-1")))
+
+	var ipReq map[string]interface{}
+	mc.On("Do", "POST", "/floating_ips", mock.Anything, mock.Anything).Return(
+		nil).Run(func(args mock.Arguments) {
+		ipReq = cloudtest.Body(args.Get(2))
+	}).Once()
+
+	err := prvdr.Boot([]db.Machine{{Size: "cx2-2x4", Hostname: "worker-1",
+		DiskSize: 40}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	assert.NotEmpty(t, req["user_data"])
+	delete(req, "user_data")
+	assert.Equal(t, map[string]interface{}{
+		"name":    "worker-1",
+		"profile": map[string]interface{}{"name": "cx2-2x4"},
+		"image":   map[string]interface{}{"id": "ubuntu"},
+		"zone":    map[string]interface{}{"name": "us-south-1"},
+		"vpc":     map[string]interface{}{"id": "vpc"},
+		"primary_network_interface": map[string]interface{}{
+			"name":   "eth0",
+			"subnet": map[string]interface{}{"id": "subnet"},
+			"security_groups": []interface{}{
+				map[string]interface{}{"id": "sg"}},
+		},
+		"boot_volume_attachment": map[string]interface{}{
+			"delete_volume_on_instance_delete": true,
+			"volume": map[string]interface{}{
+				"capacity": float64(40),
+				"profile":  map[string]interface{}{"name": "general-purpose"},
+			},
+		},
+	}, req)
+
+	// The floating IP is named so that it's known to have been allocated.
+	assert.True(t, strings.HasPrefix(ipReq["name"].(string), allocatedIPPrefix))
+	assert.Equal(t, map[string]interface{}{"id": "nic-1"}, ipReq["target"])
+
+	err = prvdr.Boot([]db.Machine{{Size: "cx2-2x4", Preemptible: true}})
+	assert.EqualError(t, err, "preemptible instances are not yet implemented")
+}
+
+func TestBootErrors(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	expectNetwork(mc)
+
+	mc.On("Do", "POST", "/instances", mock.Anything, mock.Anything).Return(
+		client.Error{StatusCode: 400, Code: "bad_field",
+			Message: "unknown profile"}).Once()
+	err := prvdr.Boot([]db.Machine{{Size: "huge"}})
+	assert.EqualError(t, err, "create instance: bad_field: unknown profile")
+
+	// Instances that can't be tagged are still used, but instances without a
+	// floating IP are deleted.
+	mc.On("Do", "POST", "/instances", mock.Anything, mock.Anything).Return(
+		nil).Run(cloudtest.Reply(inst("i-1", "nic-1"))).Once()
+	mc.On("AttachTags", mock.Anything, mock.Anything).Return(
+		errors.New("tagging unavailable")).Once()
+	mc.On("Do", "GET", "/instances/i-1", nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(inst("i-1", "nic-1"))).Twice()
+	mc.On("Do", "POST", "/floating_ips", mock.Anything, mock.Anything).Return(
+		client.Error{StatusCode: 400, Code: "quota_exceeded"}).Once()
+
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("floating_ips"))
+	mc.On("Do", "DELETE", "/instances/i-1", nil, nil).Return(nil).Once()
+	mc.On("Do", "GET", "/instances/i-1", nil, mock.Anything).Return(notFound)
+
+	err = prvdr.Boot([]db.Machine{{Size: "cx2-2x4"}})
+	assert.EqualError(t, err, "allocate floating IP: quota_exceeded: ")
+	mc.AssertExpectations(t)
+}
+
+func TestStop(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	// Allocated floating IPs are released before the instance is deleted, but
+	// the blueprint's floating IPs are kept.
+	mc.On("Do", "GET", "/instances/i-1", nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(inst("i-1", "nic-1"))).Once()
+	mc.On("Do", "GET", "/instances/i-2", nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(inst("i-2", "nic-2"))).Once()
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(listReply(
+		"floating_ips",
+		fip("fip-1", "quilt-fip-1", "169.61.0.1", "nic-1"),
+		fip("reserved", "reserved", "1.2.3.4", "nic-2")))
+	mc.On("Do", "DELETE", "/floating_ips/fip-1", nil, nil).Return(nil).Once()
+	for _, id := range []string{"i-1", "i-2"} {
+		mc.On("Do", "DELETE", "/instances/"+id, nil, nil).Return(nil).Once()
+		mc.On("Do", "GET", "/instances/"+id, nil, mock.Anything).Return(
+			notFound)
+	}
+
+	// Instances that no longer exist are already stopped.
+	mc.On("Do", "GET", "/instances/i-3", nil, mock.Anything).Return(notFound)
+
+	err := prvdr.Stop([]db.Machine{{CloudID: "i-1"}, {CloudID: "i-2"},
+		{CloudID: "i-3"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "Do", "DELETE", "/floating_ips/reserved", nil, nil)
+}
+
+func TestReboot(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	mc.On("Do", "POST", "/instances/i-1/actions",
+		map[string]string{"type": "reboot"}, nil).Return(nil).Once()
+	assert.NoError(t, prvdr.Reboot([]db.Machine{{CloudID: "i-1"}}))
+
+	mc.On("Do", "POST", "/instances/i-2/actions",
+		map[string]string{"type": "reboot"}, nil).Return(notFound)
+	err := prvdr.Reboot([]db.Machine{{CloudID: "i-2"}})
+	assert.EqualError(t, err, "reboot instance i-2: not_found: ")
+	mc.AssertExpectations(t)
+}
+
+func TestResources(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	// Without a VPC, there are no other resources.
+	mc.On("Do", "GET", instancesPath, nil, mock.Anything).Return(nil).Run(
+		listReply("instances")).Once()
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("floating_ips")).Once()
+	mc.On("Do", "GET", vpcsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("vpcs")).Once()
+
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Empty(t, resources)
+
+	created := time.Date(2019, 10, 13, 16, 0, 0, 0, time.UTC)
+	worker := inst("i-1", "nic-1")
+	worker["name"] = "worker-1"
+	worker["created_at"] = created.Add(time.Hour)
+	mc.On("Do", "GET", instancesPath, nil, mock.Anything).Return(nil).Run(
+		listReply("instances", worker))
+
+	// Floating IPs that aren't bound to the namespace's instances aren't
+	// reported.
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(listReply(
+		"floating_ips",
+		fip("fip-1", "quilt-fip-1", "169.61.0.1", "nic-1"),
+		fip("reserved", "reserved", "1.2.3.4", "")))
+	mc.On("Do", "GET", vpcsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("vpcs", map[string]interface{}{"id": "vpc",
+			"name": "quilt-ns", "created_at": created}))
+	mc.On("Do", "GET", subnetsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("subnets", map[string]string{"id": "subnet",
+			"name": "quilt-ns-us-south-1"}))
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("security_groups",
+			map[string]string{"id": "sg", "name": "quilt-ns"},
+			map[string]string{"id": "default", "name": "default-group"}))
+
+	resources, err = prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{
+			Type:    resource.Instance,
+			ID:      "i-1",
+			Name:    "worker-1",
+			Tags:    map[string]string{"quilt-namespace": "NS"},
+			Created: created.Add(time.Hour),
+		},
+		{Type: resource.IP, ID: "fip-1", Name: "169.61.0.1"},
+		{Type: resource.Network, ID: "subnet", Name: "quilt-ns-us-south-1"},
+		{Type: resource.SecurityGroup, ID: "sg", Name: "quilt-ns"},
+		{Type: resource.Network, ID: "vpc", Name: "quilt-ns",
+			Created: created},
+	}, resources)
+}
+
+func TestImage(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	id, err := prvdr.image()
+	assert.NoError(t, err)
+	assert.Equal(t, "ubuntu", id)
+
+	// The newest available image of the default operating system is used.
+	prvdr.config.Image = ""
+	imagesPath := "/images?visibility=public&limit=100"
+	mc.On("Do", "GET", imagesPath, nil, mock.Anything).Return(nil).Run(
+		listReply("images",
+			image("old", defaultOS, "available", "2017-01-01T00:00:00Z"),
+			image("new", defaultOS, "available", "2018-01-01T00:00:00Z"),
+			image("pending", defaultOS, "pending", "2019-01-01T00:00:00Z"),
+			image("other", "centos-7-amd64", "available",
+				"2019-01-01T00:00:00Z"))).Once()
+	id, err = prvdr.image()
+	assert.NoError(t, err)
+	assert.Equal(t, "new", id)
+
+	mc.On("Do", "GET", imagesPath, nil, mock.Anything).Return(nil).Run(
+		listReply("images"))
+	_, err = prvdr.image()
+	assert.EqualError(t, err, "no ubuntu-16-04-amd64 image in us-south. "+
+		"Configure one in ~/.ibmcloud/quilt.json")
+}
+
+func image(id, os, status, created string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               id,
+		"status":           status,
+		"operating_system": map[string]string{"name": os},
+		"created_at":       created,
+	}
+}
+
+func TestUpdateFloatingIPs(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	nicPath := "/instances/i-1/network_interfaces/nic-1/floating_ips/"
+
+	mc.On("Do", "GET", instancesPath, nil, mock.Anything).Return(nil).Run(
+		listReply("instances", inst("i-1", "nic-1")))
+	mc.On("Do", "GET", "/instances/i-1", nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(inst("i-1", "nic-1")))
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(listReply(
+		"floating_ips",
+		fip("fip-1", "quilt-fip-1", "169.61.0.1", "nic-1"),
+		fip("reserved", "reserved", "1.2.3.4", ""))).Once()
+
+	err := prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "no matching IDs: missing")
+
+	// The allocated floating IP is released, since an interface can only have
+	// one, and replaced by the blueprint's.
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(listReply(
+		"floating_ips",
+		fip("fip-1", "quilt-fip-1", "169.61.0.1", "nic-1"),
+		fip("reserved", "reserved", "1.2.3.4", ""))).Twice()
+	mc.On("Do", "DELETE", "/floating_ips/fip-1", nil, nil).Return(nil).Once()
+	mc.On("Do", "PUT", nicPath+"reserved", nil, nil).Return(nil).Once()
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "i-1",
+		FloatingIP: "1.2.3.4"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	// Removing the floating IP unbinds it, but keeps it, and allocates a new
+	// one for the instance.
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(listReply(
+		"floating_ips", fip("reserved", "reserved", "1.2.3.4", "nic-1")))
+	mc.On("Do", "DELETE", nicPath+"reserved", nil, nil).Return(nil).Once()
+	mc.On("Do", "POST", "/floating_ips", mock.Anything, mock.Anything).Return(
+		nil).Once()
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "i-1"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "Do", "DELETE", "/floating_ips/reserved", nil, nil)
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "i-1",
+		FloatingIP: "5.6.7.8"}})
+	assert.EqualError(t, err, "no floating IP 5.6.7.8 in us-south")
+}
+
+func TestSetACLs(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	rulesPath := "/security_groups/sg/rules"
+	mc.On("Do", "GET", vpcsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("vpcs", map[string]string{"id": "vpc", "name": "quilt-ns"}))
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		listReply("security_groups", map[string]string{"id": "sg",
+			"name": "quilt-ns"}))
+
+	// The rule that refers to the group itself isn't managed by ACLs.
+	mc.On("Do", "GET", rulesPath, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"rules": []securityRule{
+			{ID: "self", Direction: "inbound", IPVersion: "ipv4",
+				Protocol: "all", Remote: &remote{ID: "sg"}},
+			{ID: "outbound", Direction: "outbound", IPVersion: "ipv4",
+				Protocol: "all"},
+			{ID: "current", Direction: "inbound", IPVersion: "ipv4",
+				Protocol: "tcp", PortMin: 80, PortMax: 80,
+				Remote: &remote{CIDRBlock: "1.2.3.4/32"}},
+			{ID: "stale", Direction: "inbound", IPVersion: "ipv4",
+				Protocol: "tcp", PortMin: 22, PortMax: 22,
+				Remote: &remote{CIDRBlock: "5.6.7.8/32"}},
+		}}))
+	mc.On("Do", "DELETE", rulesPath+"/stale", nil, nil).Return(nil).Once()
+
+	var added []string
+	mc.On("Do", "POST", rulesPath, mock.Anything, nil).Return(nil).Run(
+		func(args mock.Arguments) {
+			rule := args.Get(2).(securityRule)
+			added = append(added, strings.Join([]string{rule.IPVersion,
+				rule.Protocol, rule.Remote.CIDRBlock}, " "))
+		})
+
+	err := prvdr.SetACLs([]acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "2001:db8::/32", MinPort: 443, MaxPort: 443},
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	sort.Strings(added)
+	assert.Equal(t, []string{
+		"ipv4 icmp 1.2.3.4/32",
+		"ipv4 udp 1.2.3.4/32",
+		"ipv6 icmp 2001:db8::/32",
+		"ipv6 tcp 2001:db8::/32",
+		"ipv6 udp 2001:db8::/32",
+	}, added)
+}
+
+func TestReadConfig(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+
+	util.WriteFile(path, []byte(`{"apiKey": "key", "image": "image"}`), 0600)
+	conf, err := readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "key", conf.APIKey)
+	assert.Equal(t, "image", conf.Image)
+
+	// The environment takes precedence over the config file.
+	os.Setenv("IC_API_KEY", "envKey")
+	defer os.Unsetenv("IC_API_KEY")
+	conf, err = readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "envKey", conf.APIKey)
+	assert.Equal(t, "image", conf.Image)
+
+	os.Unsetenv("IC_API_KEY")
+	util.WriteFile(path, []byte(`{}`), 0600)
+	_, err = readConfig()
+	assert.EqualError(t, err, "no IBM credentials found in "+path+", or the "+
+		"IBMCLOUD_API_KEY environment variable, or the IC_API_KEY "+
+		"environment variable")
+}
@@ -0,0 +1,14 @@
+package machine
+
+// alibabaDescriptions enumerates the general purpose ECS instance types, with
+// their hourly price in us-west-1.
+var alibabaDescriptions = []Description{
+	{Size: "ecs.n4.small", CPU: 1, RAM: 2, Price: 0.032},
+	{Size: "ecs.n4.large", CPU: 2, RAM: 4, Price: 0.064},
+	{Size: "ecs.n4.xlarge", CPU: 4, RAM: 8, Price: 0.128},
+	{Size: "ecs.n4.2xlarge", CPU: 8, RAM: 16, Price: 0.256},
+	{Size: "ecs.n4.4xlarge", CPU: 16, RAM: 32, Price: 0.512},
+	{Size: "ecs.sn2ne.large", CPU: 2, RAM: 8, Price: 0.099},
+	{Size: "ecs.sn2ne.xlarge", CPU: 4, RAM: 16, Price: 0.198},
+	{Size: "ecs.sn2ne.2xlarge", CPU: 8, RAM: 32, Price: 0.396},
+}
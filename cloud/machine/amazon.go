@@ -4,6 +4,8 @@ package machine
 //
 // T2 instances are not supported for Spot requests:
 // https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/using-spot-limits.html
+//
+// The Graviton instances (a1 and m6g) are ARM64.
 var amazonDescriptions = []Description{
 	{Size: "m4.large", CPU: 2, RAM: 8, Disk: "ebsonly", Region: "us-east-1", Price: 0.12},
 	{Size: "m4.xlarge", CPU: 4, RAM: 16, Disk: "ebsonly", Region: "us-east-1", Price: 0.239},
@@ -24,8 +26,14 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "us-east-1", Price: 0.42},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "us-east-1", Price: 0.84},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "us-east-1", Price: 1.68},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "us-east-1", Price: 0.65},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "us-east-1", Price: 2.6},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "us-east-1", Price: 0.65, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "us-east-1", Price: 2.6, GPUs: 4, GPUType: "nvidia-grid-k520"},
+	{Size: "p2.xlarge", CPU: 4, RAM: 61, Disk: "ebsonly", Region: "us-east-1", Price: 0.9, GPUs: 1, GPUType: "nvidia-tesla-k80"},
+	{Size: "p2.8xlarge", CPU: 32, RAM: 488, Disk: "ebsonly", Region: "us-east-1", Price: 7.2, GPUs: 8, GPUType: "nvidia-tesla-k80"},
+	{Size: "p2.16xlarge", CPU: 64, RAM: 732, Disk: "ebsonly", Region: "us-east-1", Price: 14.4, GPUs: 16, GPUType: "nvidia-tesla-k80"},
+	{Size: "p3.2xlarge", CPU: 8, RAM: 61, Disk: "ebsonly", Region: "us-east-1", Price: 3.06, GPUs: 1, GPUType: "nvidia-tesla-v100"},
+	{Size: "p3.8xlarge", CPU: 32, RAM: 244, Disk: "ebsonly", Region: "us-east-1", Price: 12.24, GPUs: 4, GPUType: "nvidia-tesla-v100"},
+	{Size: "p3.16xlarge", CPU: 64, RAM: 488, Disk: "ebsonly", Region: "us-east-1", Price: 24.48, GPUs: 8, GPUType: "nvidia-tesla-v100"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "us-east-1", Price: 0.166},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "us-east-1", Price: 0.333},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "us-east-1", Price: 0.665},
@@ -39,6 +47,16 @@ var amazonDescriptions = []Description{
 	{Size: "d2.2xlarge", CPU: 8, RAM: 61, Disk: "6 x 2000 HDD", Region: "us-east-1", Price: 1.38},
 	{Size: "d2.4xlarge", CPU: 16, RAM: 122, Disk: "12 x 2000 HDD", Region: "us-east-1", Price: 2.76},
 	{Size: "d2.8xlarge", CPU: 36, RAM: 244, Disk: "24 x 2000 HDD", Region: "us-east-1", Price: 5.52},
+	{Size: "a1.medium", CPU: 1, RAM: 2, Disk: "ebsonly", Region: "us-east-1", Price: 0.0255, Arch: "arm64"},
+	{Size: "a1.large", CPU: 2, RAM: 4, Disk: "ebsonly", Region: "us-east-1", Price: 0.051, Arch: "arm64"},
+	{Size: "a1.xlarge", CPU: 4, RAM: 8, Disk: "ebsonly", Region: "us-east-1", Price: 0.102, Arch: "arm64"},
+	{Size: "a1.2xlarge", CPU: 8, RAM: 16, Disk: "ebsonly", Region: "us-east-1", Price: 0.204, Arch: "arm64"},
+	{Size: "a1.4xlarge", CPU: 16, RAM: 32, Disk: "ebsonly", Region: "us-east-1", Price: 0.408, Arch: "arm64"},
+	{Size: "m6g.medium", CPU: 1, RAM: 4, Disk: "ebsonly", Region: "us-east-1", Price: 0.0385, Arch: "arm64"},
+	{Size: "m6g.large", CPU: 2, RAM: 8, Disk: "ebsonly", Region: "us-east-1", Price: 0.077, Arch: "arm64"},
+	{Size: "m6g.xlarge", CPU: 4, RAM: 16, Disk: "ebsonly", Region: "us-east-1", Price: 0.154, Arch: "arm64"},
+	{Size: "m6g.2xlarge", CPU: 8, RAM: 32, Disk: "ebsonly", Region: "us-east-1", Price: 0.308, Arch: "arm64"},
+	{Size: "m6g.4xlarge", CPU: 16, RAM: 64, Disk: "ebsonly", Region: "us-east-1", Price: 0.616, Arch: "arm64"},
 	{Size: "m4.large", CPU: 2, RAM: 8, Disk: "ebsonly", Region: "us-west-2", Price: 0.12},
 	{Size: "m4.xlarge", CPU: 4, RAM: 16, Disk: "ebsonly", Region: "us-west-2", Price: 0.239},
 	{Size: "m4.2xlarge", CPU: 8, RAM: 32, Disk: "ebsonly", Region: "us-west-2", Price: 0.479},
@@ -58,8 +76,14 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "us-west-2", Price: 0.42},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "us-west-2", Price: 0.84},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "us-west-2", Price: 1.68},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "us-west-2", Price: 0.65},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "us-west-2", Price: 2.6},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "us-west-2", Price: 0.65, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "us-west-2", Price: 2.6, GPUs: 4, GPUType: "nvidia-grid-k520"},
+	{Size: "p2.xlarge", CPU: 4, RAM: 61, Disk: "ebsonly", Region: "us-west-2", Price: 0.9, GPUs: 1, GPUType: "nvidia-tesla-k80"},
+	{Size: "p2.8xlarge", CPU: 32, RAM: 488, Disk: "ebsonly", Region: "us-west-2", Price: 7.2, GPUs: 8, GPUType: "nvidia-tesla-k80"},
+	{Size: "p2.16xlarge", CPU: 64, RAM: 732, Disk: "ebsonly", Region: "us-west-2", Price: 14.4, GPUs: 16, GPUType: "nvidia-tesla-k80"},
+	{Size: "p3.2xlarge", CPU: 8, RAM: 61, Disk: "ebsonly", Region: "us-west-2", Price: 3.06, GPUs: 1, GPUType: "nvidia-tesla-v100"},
+	{Size: "p3.8xlarge", CPU: 32, RAM: 244, Disk: "ebsonly", Region: "us-west-2", Price: 12.24, GPUs: 4, GPUType: "nvidia-tesla-v100"},
+	{Size: "p3.16xlarge", CPU: 64, RAM: 488, Disk: "ebsonly", Region: "us-west-2", Price: 24.48, GPUs: 8, GPUType: "nvidia-tesla-v100"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "us-west-2", Price: 0.166},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "us-west-2", Price: 0.333},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "us-west-2", Price: 0.665},
@@ -73,6 +97,16 @@ var amazonDescriptions = []Description{
 	{Size: "d2.2xlarge", CPU: 8, RAM: 61, Disk: "6 x 2000 HDD", Region: "us-west-2", Price: 1.38},
 	{Size: "d2.4xlarge", CPU: 16, RAM: 122, Disk: "12 x 2000 HDD", Region: "us-west-2", Price: 2.76},
 	{Size: "d2.8xlarge", CPU: 36, RAM: 244, Disk: "24 x 2000 HDD", Region: "us-west-2", Price: 5.52},
+	{Size: "a1.medium", CPU: 1, RAM: 2, Disk: "ebsonly", Region: "us-west-2", Price: 0.0255, Arch: "arm64"},
+	{Size: "a1.large", CPU: 2, RAM: 4, Disk: "ebsonly", Region: "us-west-2", Price: 0.051, Arch: "arm64"},
+	{Size: "a1.xlarge", CPU: 4, RAM: 8, Disk: "ebsonly", Region: "us-west-2", Price: 0.102, Arch: "arm64"},
+	{Size: "a1.2xlarge", CPU: 8, RAM: 16, Disk: "ebsonly", Region: "us-west-2", Price: 0.204, Arch: "arm64"},
+	{Size: "a1.4xlarge", CPU: 16, RAM: 32, Disk: "ebsonly", Region: "us-west-2", Price: 0.408, Arch: "arm64"},
+	{Size: "m6g.medium", CPU: 1, RAM: 4, Disk: "ebsonly", Region: "us-west-2", Price: 0.0385, Arch: "arm64"},
+	{Size: "m6g.large", CPU: 2, RAM: 8, Disk: "ebsonly", Region: "us-west-2", Price: 0.077, Arch: "arm64"},
+	{Size: "m6g.xlarge", CPU: 4, RAM: 16, Disk: "ebsonly", Region: "us-west-2", Price: 0.154, Arch: "arm64"},
+	{Size: "m6g.2xlarge", CPU: 8, RAM: 32, Disk: "ebsonly", Region: "us-west-2", Price: 0.308, Arch: "arm64"},
+	{Size: "m6g.4xlarge", CPU: 16, RAM: 64, Disk: "ebsonly", Region: "us-west-2", Price: 0.616, Arch: "arm64"},
 	{Size: "m4.large", CPU: 2, RAM: 8, Disk: "ebsonly", Region: "us-west-1", Price: 0.14},
 	{Size: "m4.xlarge", CPU: 4, RAM: 16, Disk: "ebsonly", Region: "us-west-1", Price: 0.279},
 	{Size: "m4.2xlarge", CPU: 8, RAM: 32, Disk: "ebsonly", Region: "us-west-1", Price: 0.559},
@@ -92,8 +126,8 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "us-west-1", Price: 0.478},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "us-west-1", Price: 0.956},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "us-west-1", Price: 1.912},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "us-west-1", Price: 0.702},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "us-west-1", Price: 2.808},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "us-west-1", Price: 0.702, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "us-west-1", Price: 2.808, GPUs: 4, GPUType: "nvidia-grid-k520"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "us-west-1", Price: 0.185},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "us-west-1", Price: 0.371},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "us-west-1", Price: 0.741},
@@ -122,8 +156,14 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "eu-west-1", Price: 0.478},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "eu-west-1", Price: 0.956},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "eu-west-1", Price: 1.912},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "eu-west-1", Price: 0.702},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "eu-west-1", Price: 2.808},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "eu-west-1", Price: 0.702, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "eu-west-1", Price: 2.808, GPUs: 4, GPUType: "nvidia-grid-k520"},
+	{Size: "p2.xlarge", CPU: 4, RAM: 61, Disk: "ebsonly", Region: "eu-west-1", Price: 0.972, GPUs: 1, GPUType: "nvidia-tesla-k80"},
+	{Size: "p2.8xlarge", CPU: 32, RAM: 488, Disk: "ebsonly", Region: "eu-west-1", Price: 7.776, GPUs: 8, GPUType: "nvidia-tesla-k80"},
+	{Size: "p2.16xlarge", CPU: 64, RAM: 732, Disk: "ebsonly", Region: "eu-west-1", Price: 15.552, GPUs: 16, GPUType: "nvidia-tesla-k80"},
+	{Size: "p3.2xlarge", CPU: 8, RAM: 61, Disk: "ebsonly", Region: "eu-west-1", Price: 3.305, GPUs: 1, GPUType: "nvidia-tesla-v100"},
+	{Size: "p3.8xlarge", CPU: 32, RAM: 244, Disk: "ebsonly", Region: "eu-west-1", Price: 13.22, GPUs: 4, GPUType: "nvidia-tesla-v100"},
+	{Size: "p3.16xlarge", CPU: 64, RAM: 488, Disk: "ebsonly", Region: "eu-west-1", Price: 26.44, GPUs: 8, GPUType: "nvidia-tesla-v100"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "eu-west-1", Price: 0.185},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "eu-west-1", Price: 0.371},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "eu-west-1", Price: 0.741},
@@ -156,8 +196,8 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "eu-central-1", Price: 0.516},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "eu-central-1", Price: 1.032},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "eu-central-1", Price: 2.064},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "eu-central-1", Price: 0.772},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "eu-central-1", Price: 3.088},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "eu-central-1", Price: 0.772, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "eu-central-1", Price: 3.088, GPUs: 4, GPUType: "nvidia-grid-k520"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "eu-central-1", Price: 0.2},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "eu-central-1", Price: 0.4},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "eu-central-1", Price: 0.8},
@@ -190,8 +230,8 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "ap-southeast-1", Price: 0.529},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "ap-southeast-1", Price: 1.058},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "ap-southeast-1", Price: 2.117},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "ap-southeast-1", Price: 1},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "ap-southeast-1", Price: 4},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "ap-southeast-1", Price: 1, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "ap-southeast-1", Price: 4, GPUs: 4, GPUType: "nvidia-grid-k520"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "ap-southeast-1", Price: 0.2},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "ap-southeast-1", Price: 0.399},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "ap-southeast-1", Price: 0.798},
@@ -224,8 +264,8 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "ap-northeast-1", Price: 0.511},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "ap-northeast-1", Price: 1.021},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "ap-northeast-1", Price: 2.043},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "ap-northeast-1", Price: 0.898},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "ap-northeast-1", Price: 3.592},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "ap-northeast-1", Price: 0.898, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "ap-northeast-1", Price: 3.592, GPUs: 4, GPUType: "nvidia-grid-k520"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "ap-northeast-1", Price: 0.2},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "ap-northeast-1", Price: 0.399},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "ap-northeast-1", Price: 0.798},
@@ -258,8 +298,8 @@ var amazonDescriptions = []Description{
 	{Size: "c3.2xlarge", CPU: 8, RAM: 15, Disk: "2 x 80 SSD", Region: "ap-southeast-2", Price: 0.529},
 	{Size: "c3.4xlarge", CPU: 16, RAM: 30, Disk: "2 x 160 SSD", Region: "ap-southeast-2", Price: 1.058},
 	{Size: "c3.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 320 SSD", Region: "ap-southeast-2", Price: 2.117},
-	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "ap-southeast-2", Price: 0.898},
-	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "ap-southeast-2", Price: 3.592},
+	{Size: "g2.2xlarge", CPU: 8, RAM: 15, Disk: "60 SSD", Region: "ap-southeast-2", Price: 0.898, GPUs: 1, GPUType: "nvidia-grid-k520"},
+	{Size: "g2.8xlarge", CPU: 32, RAM: 60, Disk: "2 x 120 SSD", Region: "ap-southeast-2", Price: 3.592, GPUs: 4, GPUType: "nvidia-grid-k520"},
 	{Size: "r3.large", CPU: 2, RAM: 15, Disk: "1 x 32 SSD", Region: "ap-southeast-2", Price: 0.2},
 	{Size: "r3.xlarge", CPU: 4, RAM: 30.5, Disk: "1 x 80 SSD", Region: "ap-southeast-2", Price: 0.399},
 	{Size: "r3.2xlarge", CPU: 8, RAM: 61, Disk: "1 x 160 SSD", Region: "ap-southeast-2", Price: 0.798},
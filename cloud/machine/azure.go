@@ -0,0 +1,30 @@
+package machine
+
+// azureDescriptions enumerates Azure Linux virtual machine sizes, priced in West
+// US 2.
+var azureDescriptions = []Description{
+	{Size: "Standard_B1s", CPU: 1, RAM: 1, Price: 0.012},
+	{Size: "Standard_B1ms", CPU: 1, RAM: 2, Price: 0.024},
+	{Size: "Standard_B2s", CPU: 2, RAM: 4, Price: 0.048},
+	{Size: "Standard_B2ms", CPU: 2, RAM: 8, Price: 0.096},
+	{Size: "Standard_B4ms", CPU: 4, RAM: 16, Price: 0.192},
+	{Size: "Standard_B8ms", CPU: 8, RAM: 32, Price: 0.384},
+	{Size: "Standard_D1_v2", CPU: 1, RAM: 3.5, Price: 0.073},
+	{Size: "Standard_D2_v2", CPU: 2, RAM: 7, Price: 0.146},
+	{Size: "Standard_D3_v2", CPU: 4, RAM: 14, Price: 0.293},
+	{Size: "Standard_D4_v2", CPU: 8, RAM: 28, Price: 0.585},
+	{Size: "Standard_D5_v2", CPU: 16, RAM: 56, Price: 1.170},
+	{Size: "Standard_D2s_v3", CPU: 2, RAM: 8, Price: 0.096},
+	{Size: "Standard_D4s_v3", CPU: 4, RAM: 16, Price: 0.192},
+	{Size: "Standard_D8s_v3", CPU: 8, RAM: 32, Price: 0.384},
+	{Size: "Standard_D16s_v3", CPU: 16, RAM: 64, Price: 0.768},
+	{Size: "Standard_D32s_v3", CPU: 32, RAM: 128, Price: 1.536},
+	{Size: "Standard_E2s_v3", CPU: 2, RAM: 16, Price: 0.126},
+	{Size: "Standard_E4s_v3", CPU: 4, RAM: 32, Price: 0.252},
+	{Size: "Standard_E8s_v3", CPU: 8, RAM: 64, Price: 0.504},
+	{Size: "Standard_E16s_v3", CPU: 16, RAM: 128, Price: 1.008},
+	{Size: "Standard_F2s_v2", CPU: 2, RAM: 4, Price: 0.085},
+	{Size: "Standard_F4s_v2", CPU: 4, RAM: 8, Price: 0.169},
+	{Size: "Standard_F8s_v2", CPU: 8, RAM: 16, Price: 0.338},
+	{Size: "Standard_F16s_v2", CPU: 16, RAM: 32, Price: 0.677},
+}
@@ -0,0 +1,11 @@
+package machine
+
+// fakeDescriptions enumerates the sizes of Fake machines.  They cost nothing, so
+// their nominal prices only serve to prefer the smallest size that fits.
+var fakeDescriptions = []Description{
+	{Size: "fake-1x1", CPU: 1, RAM: 1, Price: 0.01},
+	{Size: "fake-2x4", CPU: 2, RAM: 4, Price: 0.02},
+	{Size: "fake-4x8", CPU: 4, RAM: 8, Price: 0.04},
+	{Size: "fake-8x16", CPU: 8, RAM: 16, Price: 0.08},
+	{Size: "fake-16x64", CPU: 16, RAM: 64, Price: 0.16},
+}
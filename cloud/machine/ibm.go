@@ -0,0 +1,18 @@
+package machine
+
+// ibmDescriptions enumerates the balanced, compute, and memory VPC profiles, with
+// their hourly price in US dollars.
+var ibmDescriptions = []Description{
+	{Size: "cx2-2x4", CPU: 2, RAM: 4, Price: 0.08},
+	{Size: "bx2-2x8", CPU: 2, RAM: 8, Price: 0.10},
+	{Size: "mx2-2x16", CPU: 2, RAM: 16, Price: 0.13},
+	{Size: "cx2-4x8", CPU: 4, RAM: 8, Price: 0.16},
+	{Size: "bx2-4x16", CPU: 4, RAM: 16, Price: 0.19},
+	{Size: "mx2-4x32", CPU: 4, RAM: 32, Price: 0.26},
+	{Size: "cx2-8x16", CPU: 8, RAM: 16, Price: 0.33},
+	{Size: "bx2-8x32", CPU: 8, RAM: 32, Price: 0.38},
+	{Size: "mx2-8x64", CPU: 8, RAM: 64, Price: 0.52},
+	{Size: "cx2-16x32", CPU: 16, RAM: 32, Price: 0.65},
+	{Size: "bx2-16x64", CPU: 16, RAM: 64, Price: 0.77},
+	{Size: "mx2-16x128", CPU: 16, RAM: 128, Price: 1.04},
+}
@@ -15,30 +15,172 @@ type Description struct {
 	CPU    int
 	Disk   string
 	Region string
+
+	// The number and type of the GPUs that come with the VM type.
+	GPUs    int
+	GPUType string
+
+	// The CPU architecture of the VM type.  Empty for AMD64.
+	Arch string
 }
 
+// The GPUs that Google can attach to its machine types, and how many of them may
+// be attached to a single machine.
+var googleGPUTypes = []string{"nvidia-tesla-k80", "nvidia-tesla-p100",
+	"nvidia-tesla-v100"}
+var googleGPUCounts = []int{1, 2, 4, 8}
+
+// The GPU type that Google machines that request GPUs are given by default.
+const defaultGoogleGPUType = "nvidia-tesla-k80"
+
 // ChooseSize returns an acceptable machine size for the given provider that fits the
-// provided ram, cpu, and price constraints.
-func ChooseSize(provider db.ProviderName, ram, cpu blueprint.Range) string {
+// provided ram, cpu, and price constraints, and has at least `gpus` GPUs of type
+// `gpuType`.  An empty `gpuType` accepts GPUs of any type.  Only sizes of the
+// CPU architecture `arch` are chosen, and an empty `arch` selects AMD64.
+func ChooseSize(provider db.ProviderName, ram, cpu blueprint.Range, gpus int,
+	gpuType, arch string) string {
+
+	if arch == blueprint.AMD64 {
+		arch = ""
+	}
+
 	switch provider {
 	case db.Amazon:
-		return chooseBestSize(amazonDescriptions, ram, cpu)
+		return chooseBestSize(amazonDescriptions, ram, cpu, gpus, gpuType, arch)
+	case db.Azure:
+		return chooseBestSize(azureDescriptions, ram, cpu, gpus, gpuType, arch)
 	case db.DigitalOcean:
-		return chooseBestSize(digitalOceanDescriptions, ram, cpu)
+		return chooseBestSize(digitalOceanDescriptions, ram, cpu, gpus, gpuType,
+			arch)
 	case db.Google:
-		return chooseBestSize(googleDescriptions, ram, cpu)
+		// Google attaches GPUs to machines of any type, so the size is chosen
+		// without them.
+		if gpus > 0 && !googleGPUsAvailable(gpus, gpuType) {
+			return ""
+		}
+		return chooseBestSize(googleDescriptions, ram, cpu, 0, "", arch)
+	case db.OpenStack:
+		return chooseBestSize(openStackDescriptions, ram, cpu, gpus, gpuType,
+			arch)
+	case db.Alibaba:
+		return chooseBestSize(alibabaDescriptions, ram, cpu, gpus, gpuType, arch)
+	case db.Scaleway:
+		return chooseBestSize(scalewayDescriptions, ram, cpu, gpus, gpuType, arch)
+	case db.IBM:
+		return chooseBestSize(ibmDescriptions, ram, cpu, gpus, gpuType, arch)
 	case db.Vagrant:
+		if gpus > 0 || arch != "" {
+			return ""
+		}
 		return vagrantSize(ram, cpu)
+	case db.Static:
+		// Hosts have whatever hardware they have, so there's nothing to
+		// choose between.
+		return "static"
+	case db.Fake:
+		return chooseBestSize(fakeDescriptions, ram, cpu, gpus, gpuType, arch)
 	default:
 		panic(fmt.Sprintf("Unknown Cloud Provider: %s", provider))
 	}
 }
 
-func chooseBestSize(descriptions []Description, ram, cpu blueprint.Range) string {
+// GPUCapacity returns the number and type of the GPUs of a `provider` machine of
+// `size`, for which `gpus` GPUs of type `gpuType` were requested.  Amazon's sizes
+// come with a fixed set of GPUs, while Google attaches the requested GPUs to
+// machines of any size.  Zero is returned if the provider can't supply the GPUs.
+func GPUCapacity(provider db.ProviderName, size string, gpus int,
+	gpuType string) (int, string) {
+
+	switch provider {
+	case db.Amazon:
+		for _, d := range amazonDescriptions {
+			if d.Size == size {
+				return d.GPUs, d.GPUType
+			}
+		}
+	case db.Google:
+		if gpuType == "" {
+			gpuType = defaultGoogleGPUType
+		}
+		if gpus > 0 && googleGPUsAvailable(gpus, gpuType) {
+			return gpus, gpuType
+		}
+	}
+	return 0, ""
+}
+
+// Arch returns the CPU architecture of a `provider` machine of `size`.  Sizes that
+// aren't known are assumed to be AMD64.
+func Arch(provider db.ProviderName, size string) string {
+	for _, d := range descriptions(provider) {
+		if d.Size == size && d.Arch != "" {
+			return d.Arch
+		}
+	}
+	return blueprint.AMD64
+}
+
+// CPUs returns the number of vCPUs of a `provider` machine of `size`, or zero if
+// the size isn't known.
+func CPUs(provider db.ProviderName, size string) int {
+	for _, d := range descriptions(provider) {
+		if d.Size == size {
+			return d.CPU
+		}
+	}
+	return 0
+}
+
+// descriptions returns the VM types offered by `provider`.  Vagrant and static
+// machines don't have any.
+func descriptions(provider db.ProviderName) []Description {
+	switch provider {
+	case db.Amazon:
+		return amazonDescriptions
+	case db.Azure:
+		return azureDescriptions
+	case db.DigitalOcean:
+		return digitalOceanDescriptions
+	case db.Google:
+		return googleDescriptions
+	case db.OpenStack:
+		return openStackDescriptions
+	case db.Alibaba:
+		return alibabaDescriptions
+	case db.Scaleway:
+		return scalewayDescriptions
+	case db.IBM:
+		return ibmDescriptions
+	case db.Fake:
+		return fakeDescriptions
+	default:
+		return nil
+	}
+}
+
+func googleGPUsAvailable(gpus int, gpuType string) bool {
+	countOK := false
+	for _, count := range googleGPUCounts {
+		countOK = countOK || count == gpus
+	}
+
+	typeOK := gpuType == ""
+	for _, t := range googleGPUTypes {
+		typeOK = typeOK || t == gpuType
+	}
+	return countOK && typeOK
+}
+
+func chooseBestSize(descriptions []Description, ram, cpu blueprint.Range, gpus int,
+	gpuType, arch string) string {
+
 	var best Description
 	for _, d := range descriptions {
-		if ram.Accepts(d.RAM) &&
+		if d.Arch == arch &&
+			ram.Accepts(d.RAM) &&
 			cpu.Accepts(float64(d.CPU)) &&
+			d.GPUs >= gpus &&
+			(gpus == 0 || gpuType == "" || d.GPUType == gpuType) &&
 			(best.Size == "" || d.Price < best.Price) {
 			best = d
 		}
@@ -3,13 +3,16 @@ package machine
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
 )
 
 func TestConstraints(t *testing.T) {
 	checkConstraint := func(descriptions []Description, ram blueprint.Range,
 		cpu blueprint.Range, exp string) {
-		resSize := chooseBestSize(descriptions, ram, cpu)
+		resSize := chooseBestSize(descriptions, ram, cpu, 0, "", "")
 		if resSize != exp {
 			t.Errorf("bad size picked. Expected %s, got %s", exp, resSize)
 		}
@@ -53,3 +56,89 @@ func TestConstraints(t *testing.T) {
 	checkConstraint(testDescriptions, blueprint.Range{Min: 3},
 		blueprint.Range{}, "size4")
 }
+
+func TestGPUs(t *testing.T) {
+	descriptions := []Description{
+		{Size: "cpu", Price: 1, RAM: 8, CPU: 4},
+		{Size: "k80", Price: 2, RAM: 8, CPU: 4, GPUs: 1, GPUType: "k80"},
+		{Size: "k80x4", Price: 6, RAM: 8, CPU: 4, GPUs: 4, GPUType: "k80"},
+		{Size: "v100", Price: 4, RAM: 8, CPU: 4, GPUs: 1, GPUType: "v100"},
+	}
+	check := func(gpus int, gpuType, exp string) {
+		assert.Equal(t, exp, chooseBestSize(descriptions, blueprint.Range{},
+			blueprint.Range{}, gpus, gpuType, ""))
+	}
+
+	check(0, "", "cpu")
+	check(1, "", "k80")
+	check(2, "", "k80x4")
+	check(1, "v100", "v100")
+	check(2, "v100", "")
+
+	// Google attaches GPUs to machines of any size.
+	assert.Equal(t, "f1-micro", ChooseSize(db.Google, blueprint.Range{},
+		blueprint.Range{Min: 1, Max: 1}, 0, "", ""))
+	assert.Equal(t, "f1-micro", ChooseSize(db.Google, blueprint.Range{},
+		blueprint.Range{Min: 1, Max: 1}, 2, "nvidia-tesla-v100", ""))
+	assert.Empty(t, ChooseSize(db.Google, blueprint.Range{}, blueprint.Range{},
+		3, "", ""))
+	assert.Empty(t, ChooseSize(db.Google, blueprint.Range{}, blueprint.Range{},
+		1, "nvidia-grid-k520", ""))
+
+	assert.Equal(t, "p3.2xlarge", ChooseSize(db.Amazon, blueprint.Range{},
+		blueprint.Range{}, 1, "nvidia-tesla-v100", ""))
+	assert.Empty(t, ChooseSize(db.DigitalOcean, blueprint.Range{},
+		blueprint.Range{}, 1, "", ""))
+}
+
+func TestArch(t *testing.T) {
+	descriptions := []Description{
+		{Size: "x86", Price: 2, RAM: 4, CPU: 2},
+		{Size: "arm", Price: 1, RAM: 4, CPU: 2, Arch: blueprint.ARM64},
+	}
+	assert.Equal(t, "x86", chooseBestSize(descriptions, blueprint.Range{},
+		blueprint.Range{}, 0, "", ""))
+	assert.Equal(t, "arm", chooseBestSize(descriptions, blueprint.Range{},
+		blueprint.Range{}, 0, "", blueprint.ARM64))
+
+	size := ChooseSize(db.Amazon, blueprint.Range{Min: 2}, blueprint.Range{Min: 1},
+		0, "", blueprint.ARM64)
+	assert.Equal(t, "a1.medium", size)
+	assert.Equal(t, blueprint.ARM64, Arch(db.Amazon, size))
+
+	size = ChooseSize(db.Amazon, blueprint.Range{Min: 2}, blueprint.Range{Min: 1},
+		0, "", blueprint.AMD64)
+	assert.Equal(t, blueprint.AMD64, Arch(db.Amazon, size))
+
+	assert.Empty(t, ChooseSize(db.DigitalOcean, blueprint.Range{},
+		blueprint.Range{}, 0, "", blueprint.ARM64))
+	assert.Empty(t, ChooseSize(db.Vagrant, blueprint.Range{}, blueprint.Range{},
+		0, "", blueprint.ARM64))
+	assert.Equal(t, blueprint.AMD64, Arch(db.Google, "n1-standard-1"))
+}
+
+func TestGPUCapacity(t *testing.T) {
+	gpus, gpuType := GPUCapacity(db.Amazon, "p2.8xlarge", 2, "")
+	assert.Equal(t, 8, gpus)
+	assert.Equal(t, "nvidia-tesla-k80", gpuType)
+
+	gpus, _ = GPUCapacity(db.Amazon, "m4.large", 1, "")
+	assert.Zero(t, gpus)
+
+	gpus, gpuType = GPUCapacity(db.Google, "n1-standard-1", 2, "")
+	assert.Equal(t, 2, gpus)
+	assert.Equal(t, defaultGoogleGPUType, gpuType)
+
+	gpus, _ = GPUCapacity(db.Google, "n1-standard-1", 0, "")
+	assert.Zero(t, gpus)
+
+	gpus, _ = GPUCapacity(db.DigitalOcean, "2gb", 1, "")
+	assert.Zero(t, gpus)
+}
+
+func TestCPUs(t *testing.T) {
+	assert.Equal(t, 2, CPUs(db.Google, "n1-standard-2"))
+	assert.Equal(t, 4, CPUs(db.Fake, "fake-4x8"))
+	assert.Zero(t, CPUs(db.Google, "unknown"))
+	assert.Zero(t, CPUs(db.Vagrant, "1,1"))
+}
@@ -0,0 +1,12 @@
+package machine
+
+// openStackDescriptions enumerates the default OpenStack flavors.  Flavors are
+// configured by each deployment, and usually aren't billed, so the prices only
+// reflect their relative cost.
+var openStackDescriptions = []Description{
+	{Size: "m1.tiny", CPU: 1, RAM: 0.5, Price: 0.5},
+	{Size: "m1.small", CPU: 1, RAM: 2, Price: 2},
+	{Size: "m1.medium", CPU: 2, RAM: 4, Price: 4},
+	{Size: "m1.large", CPU: 4, RAM: 8, Price: 8},
+	{Size: "m1.xlarge", CPU: 8, RAM: 16, Price: 16},
+}
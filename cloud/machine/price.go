@@ -0,0 +1,61 @@
+package machine
+
+import (
+	"github.com/kelda/kelda/db"
+)
+
+// The fraction by which preemptible machines are estimated to be cheaper than
+// on-demand ones.  Their prices fluctuate with demand, so these are typical
+// discounts rather than quotes.  Providers without preemptible machines boot
+// on-demand machines instead.
+var preemptibleDiscounts = map[db.ProviderName]float64{
+	db.Amazon:  0.7,
+	db.Alibaba: 0.7,
+}
+
+// HourlyPrice estimates the hourly price of `m` in US dollars.  The price of the
+// machine's region is preferred, but the prices of sizes that are only known in
+// other regions are used as an approximation.  Vagrant, static and fake machines
+// are free.  False is returned if the price of the machine's size is unknown.
+func HourlyPrice(m db.Machine) (float64, bool) {
+	switch m.Provider {
+	case db.Vagrant, db.Static, db.Fake:
+		return 0, true
+	}
+
+	price, ok := onDemandPrice(m.Provider, m.Region, m.Size)
+	if !ok {
+		return 0, false
+	}
+
+	discount, ok := preemptibleDiscounts[m.Provider]
+	if !m.Preemptible || (m.OnDemand && m.OnDemandFallback) || !ok {
+		return price, true
+	}
+
+	price *= 1 - discount
+	// Preemptible machines are never charged more than their bid.
+	if m.MaxPrice > 0 && m.MaxPrice < price {
+		price = m.MaxPrice
+	}
+	return price, true
+}
+
+func onDemandPrice(provider db.ProviderName, region, size string) (float64, bool) {
+	var price float64
+	var found bool
+	for _, d := range descriptions(provider) {
+		if d.Size != size {
+			continue
+		}
+
+		if d.Region == region {
+			return d.Price, true
+		}
+
+		if !found {
+			price, found = d.Price, true
+		}
+	}
+	return price, found
+}
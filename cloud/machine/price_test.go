@@ -0,0 +1,46 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHourlyPrice(t *testing.T) {
+	t.Parallel()
+
+	check := func(m db.Machine, exp float64) {
+		price, ok := HourlyPrice(m)
+		assert.True(t, ok, "%v", m)
+		assert.InDelta(t, exp, price, 0.0001, "%v", m)
+	}
+
+	m := db.Machine{Provider: db.Amazon, Region: "us-east-1", Size: "m4.large"}
+	check(m, 0.12)
+
+	// Sizes not known in the region are priced as in other regions.
+	m.Region = "unknown"
+	check(m, 0.12)
+
+	m.Preemptible = true
+	check(m, 0.036)
+
+	m.MaxPrice = 0.02
+	check(m, 0.02)
+
+	// Fallen back to on-demand.
+	m.OnDemand = true
+	m.OnDemandFallback = true
+	check(m, 0.12)
+
+	// DigitalOcean doesn't have preemptible machines.
+	check(db.Machine{Provider: db.DigitalOcean, Region: "sfo2", Size: "512mb",
+		Preemptible: true}, 0.00744)
+
+	check(db.Machine{Provider: db.Vagrant, Size: "1,1"}, 0)
+	check(db.Machine{Provider: db.Fake, Size: "fake-1x1"}, 0)
+
+	_, ok := HourlyPrice(db.Machine{Provider: db.Amazon, Size: "unknown"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,14 @@
+package machine
+
+// scalewayDescriptions enumerates the development and general purpose instance
+// types, with their hourly price in euros.
+var scalewayDescriptions = []Description{
+	{Size: "DEV1-S", CPU: 2, RAM: 2, Price: 0.01},
+	{Size: "DEV1-M", CPU: 3, RAM: 4, Price: 0.02},
+	{Size: "DEV1-L", CPU: 4, RAM: 8, Price: 0.04},
+	{Size: "DEV1-XL", CPU: 4, RAM: 12, Price: 0.06},
+	{Size: "GP1-XS", CPU: 4, RAM: 16, Price: 0.08},
+	{Size: "GP1-S", CPU: 8, RAM: 32, Price: 0.16},
+	{Size: "GP1-M", CPU: 16, RAM: 64, Price: 0.33},
+	{Size: "GP1-L", CPU: 32, RAM: 128, Price: 0.67},
+}
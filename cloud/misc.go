@@ -3,10 +3,16 @@ package cloud
 import (
 	"fmt"
 
+	"github.com/kelda/kelda/cloud/alibaba"
 	"github.com/kelda/kelda/cloud/amazon"
+	"github.com/kelda/kelda/cloud/azure"
 	"github.com/kelda/kelda/cloud/digitalocean"
+	"github.com/kelda/kelda/cloud/fake"
 	"github.com/kelda/kelda/cloud/google"
+	"github.com/kelda/kelda/cloud/ibm"
 	"github.com/kelda/kelda/cloud/machine"
+	"github.com/kelda/kelda/cloud/openstack"
+	"github.com/kelda/kelda/cloud/scaleway"
 	"github.com/kelda/kelda/db"
 )
 
@@ -21,11 +27,23 @@ func DefaultRegion(m db.Machine) db.Machine {
 	switch m.Provider {
 	case db.Amazon:
 		m.Region = amazon.DefaultRegion
+	case db.Azure:
+		m.Region = azure.DefaultRegion
 	case db.DigitalOcean:
 		m.Region = digitalocean.DefaultRegion
 	case db.Google:
 		m.Region = google.DefaultRegion
-	case db.Vagrant:
+	case db.OpenStack:
+		m.Region = openstack.DefaultRegion
+	case db.Alibaba:
+		m.Region = alibaba.DefaultRegion
+	case db.Scaleway:
+		m.Region = scaleway.DefaultRegion
+	case db.IBM:
+		m.Region = ibm.DefaultRegion
+	case db.Fake:
+		m.Region = fake.DefaultRegion
+	case db.Vagrant, db.Static:
 	default:
 		panic(fmt.Sprintf("Unknown Cloud Provider: %s", m.Provider))
 	}
@@ -33,6 +51,22 @@ func DefaultRegion(m db.Machine) db.Machine {
 	return m
 }
 
+// Zones returns the availability zones of `region` that machines of the given
+// provider may be placed in, or nil if the provider doesn't have zones.
+func Zones(p db.ProviderName, region string) []string {
+	if p == db.Amazon {
+		return amazon.Zones[region]
+	}
+	return nil
+}
+
 // ChooseSize returns an acceptable machine size for the given provider that fits the
-// provided ram, cpu, and price constraints.
+// provided ram, cpu, GPU, and price constraints.
 var ChooseSize = machine.ChooseSize
+
+// Arch returns the CPU architecture of a machine of the given provider and size.
+var Arch = machine.Arch
+
+// GPUCapacity returns the number and type of GPUs that a machine of the given
+// provider and size has when the given GPUs are requested.
+var GPUCapacity = machine.GPUCapacity
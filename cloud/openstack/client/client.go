@@ -0,0 +1,269 @@
+//go:generate mockery -name=Client
+
+// Package client is a minimal client of the OpenStack APIs.  It authenticates
+// with Keystone, and makes requests to the endpoints of the other services in
+// the catalog that Keystone returns.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+)
+
+// The types of the services that Quilt uses, as they're named in the catalog.
+const (
+	Compute = "compute"
+	Network = "network"
+	Image   = "image"
+)
+
+// A Client for the OpenStack APIs.  Paths are relative to the endpoint of
+// `service`, e.g. "/servers" for the compute service.  Used for unit testing.
+type Client interface {
+	// Get decodes the resource at `path` into `result`.
+	Get(service, path string, result interface{}) error
+
+	// Post sends `body` to `path`, and decodes the response into `result` if
+	// it's non-nil.
+	Post(service, path string, body, result interface{}) error
+
+	// Delete deletes the resource at `path`.
+	Delete(service, path string) error
+}
+
+// An Error is returned when the API rejects a request.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("%d: %s", err.StatusCode, err.Message)
+}
+
+// IsNotFound returns whether `err` reports that a resource doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Credentials identify the user and project with which Quilt authenticates.
+type Credentials struct {
+	AuthURL     string
+	Username    string
+	Password    string
+	ProjectName string
+	DomainName  string
+}
+
+var c = counter.New("OpenStack")
+
+// Tokens are renewed this long before they expire.
+const tokenSlack = 5 * time.Minute
+
+type client struct {
+	http   *http.Client
+	creds  Credentials
+	region string
+
+	sync.Mutex
+	token     string
+	expiry    time.Time
+	endpoints map[string]string
+}
+
+// New creates a client of the services in `region`, whose requests are recorded
+// or replayed as `cassetteName`.
+func New(creds Credentials, region, cassetteName string) Client {
+	if authURL, ok := endpoint.Get(db.OpenStack); ok {
+		creds.AuthURL = authURL
+	}
+
+	return &client{
+		http: cassette.Wrap(cassetteName, throttle.Wrap(db.OpenStack, region,
+			endpoint.Client(db.OpenStack, &http.Client{}))),
+		creds:  creds,
+		region: region,
+	}
+}
+
+func (client *client) Get(service, path string, result interface{}) error {
+	c.Inc("Get " + service)
+	return client.do(service, "GET", path, nil, result)
+}
+
+func (client *client) Post(service, path string, body, result interface{}) error {
+	c.Inc("Post " + service)
+	return client.do(service, "POST", path, body, result)
+}
+
+func (client *client) Delete(service, path string) error {
+	c.Inc("Delete " + service)
+	return client.do(service, "DELETE", path, nil, nil)
+}
+
+func (client *client) do(service, method, path string, body,
+	result interface{}) error {
+
+	token, endpoints, err := client.authenticate()
+	if err != nil {
+		return fmt.Errorf("authenticate: %s", err)
+	}
+
+	url, ok := endpoints[service]
+	if !ok {
+		return fmt.Errorf("no %s endpoint in region %s", service, client.region)
+	}
+
+	_, err = client.request(method, url+path, token, body, result)
+	return err
+}
+
+// request sends a JSON request, and decodes the response into `result`.  The
+// response is returned so that callers may read its headers.
+func (client *client) request(method, url, token string, body,
+	result interface{}) (*http.Response, error) {
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("X-Auth-Token", token)
+	}
+
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, Error{StatusCode: resp.StatusCode,
+			Message: errorMessage(respBody, resp.Status)}
+	}
+
+	if result != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// errorMessage extracts the message of an error response.  Each service wraps
+// it differently, e.g. {"itemNotFound": {"message": ...}} or
+// {"NeutronError": {"message": ...}}, so the first message found is used.
+func errorMessage(body []byte, status string) string {
+	var wrapped map[string]json.RawMessage
+	if json.Unmarshal(body, &wrapped) == nil {
+		for _, raw := range wrapped {
+			var msg struct{ Message string }
+			if json.Unmarshal(raw, &msg) == nil && msg.Message != "" {
+				return msg.Message
+			}
+		}
+	}
+	return status
+}
+
+// authenticate returns a token, and the endpoints of the services in the
+// client's region, requesting a new token from Keystone if the current one is
+// about to expire.
+func (client *client) authenticate() (string, map[string]string, error) {
+	client.Lock()
+	defer client.Unlock()
+
+	if client.token != "" && time.Now().Add(tokenSlack).Before(client.expiry) {
+		return client.token, client.endpoints, nil
+	}
+
+	c.Inc("Authenticate")
+	domain := map[string]string{"name": client.creds.DomainName}
+	body := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"password"},
+				"password": map[string]interface{}{
+					"user": map[string]interface{}{
+						"name":     client.creds.Username,
+						"password": client.creds.Password,
+						"domain":   domain,
+					},
+				},
+			},
+			"scope": map[string]interface{}{
+				"project": map[string]interface{}{
+					"name":   client.creds.ProjectName,
+					"domain": domain,
+				},
+			},
+		},
+	}
+
+	var result struct {
+		Token struct {
+			ExpiresAt time.Time `json:"expires_at"`
+			Catalog   []struct {
+				Type      string
+				Endpoints []struct {
+					Interface string
+					Region    string
+					URL       string
+				}
+			}
+		}
+	}
+	authURL := strings.TrimSuffix(client.creds.AuthURL, "/") + "/auth/tokens"
+	resp, err := client.request("POST", authURL, "", body, &result)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", nil, errors.New("no token in response")
+	}
+
+	endpoints := map[string]string{}
+	for _, service := range result.Token.Catalog {
+		for _, ep := range service.Endpoints {
+			if ep.Interface == "public" && ep.Region == client.region {
+				endpoints[service.Type] = strings.TrimSuffix(ep.URL, "/")
+			}
+		}
+	}
+
+	client.token = token
+	client.expiry = result.Token.ExpiresAt
+	client.endpoints = endpoints
+	return token, endpoints, nil
+}
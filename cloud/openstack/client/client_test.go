@@ -0,0 +1,86 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequests(t *testing.T) {
+	var auths int
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v3/auth/tokens" {
+				auths++
+				var body struct{ Auth struct{ Scope interface{} } }
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.NotNil(t, body.Auth.Scope)
+
+				w.Header().Set("X-Subject-Token", "token")
+				fmt.Fprintf(w, `{"token": {"expires_at": "%s", "catalog": [
+					{"type": "compute", "endpoints": [
+						{"interface": "public", "region": "RegionOne",
+						 "url": "%s/compute/"},
+						{"interface": "public", "region": "RegionTwo",
+						 "url": "%s/other"}]}]}}`,
+					time.Now().Add(time.Hour).Format(time.RFC3339),
+					server.URL, server.URL)
+				return
+			}
+
+			assert.Equal(t, "token", r.Header.Get("X-Auth-Token"))
+			switch r.URL.Path {
+			case "/compute/servers/1":
+				if r.Method == "DELETE" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				fmt.Fprint(w, `{"server": {"id": "1"}}`)
+			case "/compute/servers":
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				json.NewEncoder(w).Encode(body)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"itemNotFound": {"message": "missing"}}`)
+			}
+		}))
+	defer server.Close()
+
+	client := New(Credentials{AuthURL: server.URL + "/v3/"}, "RegionOne", "")
+
+	var res struct{ Server struct{ ID string } }
+	assert.NoError(t, client.Get(Compute, "/servers/1", &res))
+	assert.Equal(t, "1", res.Server.ID)
+
+	res.Server.ID = ""
+	assert.NoError(t, client.Post(Compute, "/servers",
+		map[string]interface{}{"server": map[string]string{"id": "2"}}, &res))
+	assert.Equal(t, "2", res.Server.ID)
+
+	assert.NoError(t, client.Delete(Compute, "/servers/1"))
+
+	err := client.Get(Compute, "/servers/2", &res)
+	assert.EqualError(t, err, "404: missing")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+
+	err = client.Get(Network, "/v2.0/ports", nil)
+	assert.EqualError(t, err, "no network endpoint in region RegionOne")
+
+	// The token is reused until it's about to expire.
+	assert.Equal(t, 1, auths)
+}
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "bad", errorMessage(
+		[]byte(`{"NeutronError": {"message": "bad"}}`), "400 Bad Request"))
+	assert.Equal(t, "400 Bad Request",
+		errorMessage([]byte(`not json`), "400 Bad Request"))
+}
@@ -0,0 +1,742 @@
+package openstack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/openstack/client"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/wait"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/util"
+
+	"github.com/satori/go.uuid"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRegion is assigned to Machines without a specified region
+const DefaultRegion string = "RegionOne"
+
+var configPath = filepath.Join(".openstack", "quilt.json")
+
+// The environment variables, as set by an OpenStack RC file, that override the
+// credentials in the config file.
+const (
+	authURLEnv     = "OS_AUTH_URL"
+	usernameEnv    = "OS_USERNAME"
+	passwordEnv    = "OS_PASSWORD"
+	projectNameEnv = "OS_PROJECT_NAME"
+	domainNameEnv  = "OS_USER_DOMAIN_NAME"
+)
+
+// The metadata key whose value is the namespace of the server.
+const namespaceKey = "quilt-namespace"
+
+// config is the deployment-specific configuration in ~/.openstack/quilt.json.
+type config struct {
+	client.Credentials
+
+	// The regions in which machines may boot.
+	Regions []string
+
+	// The name of the Ubuntu 16.04 image that machines boot.
+	Image string
+
+	// The name of the network that machines are attached to.  It may be
+	// omitted if the project only has one network.
+	Network string
+
+	// The name of the external network from which machines are assigned a
+	// floating IP as their public IP.  If it's omitted, machines are only
+	// reachable at their fixed IP.
+	ExternalNetwork string
+}
+
+func readConfig() (config, error) {
+	conf := config{
+		Credentials: client.Credentials{DomainName: "Default"},
+		Image:       "ubuntu-16.04",
+	}
+
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+	confStr, err := util.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(confStr), &conf); err != nil {
+			return conf, fmt.Errorf("parse %s: %s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return conf, err
+	}
+
+	creds.FromEnv(&conf.AuthURL, authURLEnv)
+	creds.FromEnv(&conf.Username, usernameEnv)
+	creds.FromEnv(&conf.Password, passwordEnv)
+	creds.FromEnv(&conf.ProjectName, projectNameEnv)
+	creds.FromEnv(&conf.DomainName, domainNameEnv)
+
+	switch {
+	case conf.AuthURL == "" && conf.Username == "" && conf.ProjectName == "":
+		return conf, creds.MissingError{Provider: db.OpenStack, Sources: []string{
+			path, creds.EnvSource(authURLEnv, usernameEnv, passwordEnv,
+				projectNameEnv)}}
+	case conf.AuthURL == "" || conf.Username == "" || conf.ProjectName == "":
+		return conf, fmt.Errorf("%s must contain the authURL, username, and "+
+			"projectName of the OpenStack user", path)
+	}
+	return conf, nil
+}
+
+// Regions returns the regions listed in ~/.openstack/quilt.json.  Regions are
+// specific to each OpenStack deployment, so they must be configured, unless the
+// deployment only has the default region.
+func Regions() []string {
+	conf, err := readConfig()
+	if err != nil || len(conf.Regions) == 0 {
+		return []string{DefaultRegion}
+	}
+	return conf.Regions
+}
+
+// The Provider object represents a connection to an OpenStack region.  Servers
+// are tagged with the namespace in their metadata, and share a security group
+// that's named after the namespace.
+type Provider struct {
+	client.Client
+
+	namespace string
+	region    string
+	config    config
+}
+
+// New starts a new client session with the credentials in
+// ~/.openstack/quilt.json, or in the environment.
+func New(namespace, region string) (*Provider, error) {
+	prvdr, err := newOpenStack(namespace, region)
+	if err != nil {
+		return prvdr, err
+	}
+
+	_, err = prvdr.List()
+	return prvdr, err
+}
+
+// Creation is broken out for unit testing.
+var newOpenStack = func(namespace, region string) (*Provider, error) {
+	// Replayed responses don't require valid credentials.
+	conf := config{Credentials: client.Credentials{AuthURL: "http://replay"}}
+	if !cassette.Replaying() {
+		var err error
+		if conf, err = readConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Provider{
+		Client:    client.New(conf.Credentials, region, "openstack-"+region),
+		namespace: namespace,
+		region:    region,
+		config:    conf,
+	}, nil
+}
+
+type server struct {
+	ID        string
+	Name      string
+	Status    string
+	Created   time.Time
+	Metadata  map[string]string
+	Flavor    struct{ ID string }
+	Addresses map[string][]struct {
+		Addr string
+		Type string `json:"OS-EXT-IPS:type"`
+	}
+	Volumes []struct{ ID string } `json:"os-extended-volumes:volumes_attached"`
+}
+
+// servers returns the servers in the namespace.
+func (prvdr Provider) servers() ([]server, error) {
+	var servers struct{ Servers []server }
+	err := prvdr.Get(client.Compute, "/servers/detail", &servers)
+	if err != nil {
+		return nil, fmt.Errorf("list servers: %s", err)
+	}
+
+	var inNamespace []server
+	for _, s := range servers.Servers {
+		if s.Metadata[namespaceKey] == prvdr.namespace {
+			inNamespace = append(inNamespace, s)
+		}
+	}
+	return inNamespace, nil
+}
+
+// List the servers in the namespace.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	servers, err := prvdr.servers()
+	if err != nil {
+		return nil, err
+	}
+
+	flavorIDs, err := prvdr.flavorIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	flavorNames := map[string]string{}
+	for name, id := range flavorIDs {
+		flavorNames[id] = name
+	}
+
+	var machines []db.Machine
+	for _, s := range servers {
+		m := db.Machine{CloudID: s.ID, Size: flavorNames[s.Flavor.ID]}
+		for _, addrs := range s.Addresses {
+			for _, addr := range addrs {
+				if addr.Type == "floating" {
+					m.PublicIP = addr.Addr
+				} else {
+					m.PrivateIP = addr.Addr
+				}
+			}
+		}
+
+		if prvdr.config.ExternalNetwork == "" {
+			m.PublicIP = m.PrivateIP
+		}
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+// flavorIDs maps the names of flavors to their IDs.
+func (prvdr Provider) flavorIDs() (map[string]string, error) {
+	var flavors struct {
+		Flavors []struct{ ID, Name string }
+	}
+	if err := prvdr.Get(client.Compute, "/flavors", &flavors); err != nil {
+		return nil, fmt.Errorf("list flavors: %s", err)
+	}
+
+	ids := map[string]string{}
+	for _, f := range flavors.Flavors {
+		ids[f.Name] = f.ID
+	}
+	return ids, nil
+}
+
+// findID returns the ID of the resource called `name` in the collection at
+// `path`, e.g. "/v2.0/networks", whose response is keyed by `collection`.
+func (prvdr Provider) findID(service, path, collection, name string) (string,
+	error) {
+
+	var resp map[string]json.RawMessage
+	err := prvdr.Get(service, path+"?name="+url.QueryEscape(name), &resp)
+	if err != nil {
+		return "", fmt.Errorf("find %s: %s", name, err)
+	}
+
+	var resources []struct{ ID string }
+	if err := json.Unmarshal(resp[collection], &resources); err != nil ||
+		len(resources) == 0 {
+		return "", fmt.Errorf("no %s called %s", collection, name)
+	}
+	return resources[0].ID, nil
+}
+
+// The resources that servers are booted with.
+type bootResources struct {
+	imageID, networkID, externalNetworkID string
+	flavorIDs                             map[string]string
+}
+
+// Boot creates each server in a goroutine, and waits for them to become active.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	for _, m := range bootSet {
+		if m.Preemptible {
+			return errors.New("preemptible instances are not yet implemented")
+		}
+	}
+
+	if _, err := prvdr.securityGroup(); err != nil {
+		return fmt.Errorf("create security group: %s", err)
+	}
+
+	res, err := prvdr.bootResources()
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error, len(bootSet))
+	for _, m := range bootSet {
+		go func(m db.Machine) {
+			errChan <- prvdr.createServer(m, res)
+		}(m)
+	}
+
+	for range bootSet {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (prvdr Provider) bootResources() (res bootResources, err error) {
+	res.imageID, err = prvdr.findID(client.Image, "/v2/images", "images",
+		prvdr.config.Image)
+	if err != nil {
+		return res, err
+	}
+
+	if prvdr.config.Network != "" {
+		res.networkID, err = prvdr.findID(client.Network, "/v2.0/networks",
+			"networks", prvdr.config.Network)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if prvdr.config.ExternalNetwork != "" {
+		res.externalNetworkID, err = prvdr.findID(client.Network,
+			"/v2.0/networks", "networks", prvdr.config.ExternalNetwork)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	res.flavorIDs, err = prvdr.flavorIDs()
+	return res, err
+}
+
+// createServer creates a server, waits for it to become active, and assigns it a
+// floating IP.  If any step fails, the server is deleted.
+func (prvdr Provider) createServer(m db.Machine, res bootResources) error {
+	flavorID, ok := res.flavorIDs[m.Size]
+	if !ok {
+		return fmt.Errorf("no flavor called %s", m.Size)
+	}
+
+	name := "quilt-" + uuid.NewV4().String()
+	if m.Hostname != "" {
+		name = m.Hostname
+	}
+
+	body := map[string]interface{}{
+		"name":            name,
+		"flavorRef":       flavorID,
+		"imageRef":        res.imageID,
+		"metadata":        map[string]string{namespaceKey: prvdr.namespace},
+		"security_groups": []map[string]string{{"name": prvdr.groupName()}},
+		"user_data": base64.StdEncoding.EncodeToString(
+			[]byte(cfg.UserData(m, ""))),
+	}
+
+	if res.networkID != "" {
+		body["networks"] = []map[string]string{{"uuid": res.networkID}}
+	}
+
+	// The root disk's size is fixed by the flavor, so machines with a
+	// different disk size boot from a volume instead.
+	if m.DiskSize != 0 {
+		body["imageRef"] = ""
+		body["block_device_mapping_v2"] = []map[string]interface{}{{
+			"boot_index":            0,
+			"uuid":                  res.imageID,
+			"source_type":           "image",
+			"destination_type":      "volume",
+			"volume_size":           m.DiskSize,
+			"delete_on_termination": true,
+		}}
+	}
+
+	var created struct{ Server struct{ ID string } }
+	err := prvdr.Post(client.Compute, "/servers",
+		map[string]interface{}{"server": body}, &created)
+	if err != nil {
+		return fmt.Errorf("create server: %s", err)
+	}
+
+	id := created.Server.ID
+	if err := prvdr.setupServer(id, res); err != nil {
+		if cleanupErr := prvdr.deleteServer(id); cleanupErr != nil {
+			log.WithError(cleanupErr).WithField("id", id).Warn(
+				"Failed to clean up server that failed to boot")
+		}
+		return err
+	}
+	return nil
+}
+
+func (prvdr Provider) setupServer(id string, res bootResources) error {
+	var status string
+	err := wait.Wait(func() bool {
+		var s struct{ Server server }
+		if err := prvdr.Get(client.Compute, "/servers/"+id, &s); err != nil {
+			return false
+		}
+		status = s.Server.Status
+		return status == "ACTIVE" || status == "ERROR"
+	})
+	if err == nil && status == "ERROR" {
+		err = errors.New("server failed to boot")
+	}
+	if err != nil {
+		return fmt.Errorf("wait for server %s: %s", id, err)
+	}
+
+	if res.externalNetworkID == "" {
+		return nil
+	}
+
+	ports, err := prvdr.serverPorts(id)
+	if err != nil {
+		return err
+	} else if len(ports) == 0 {
+		return fmt.Errorf("server %s has no ports", id)
+	}
+
+	err = prvdr.Post(client.Network, "/v2.0/floatingips", map[string]interface{}{
+		"floatingip": map[string]string{
+			"floating_network_id": res.externalNetworkID,
+			"port_id":             ports[0],
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("create floating IP: %s", err)
+	}
+	return nil
+}
+
+// serverPorts returns the IDs of the network ports of the server.
+func (prvdr Provider) serverPorts(serverID string) ([]string, error) {
+	var ports struct{ Ports []struct{ ID string } }
+	err := prvdr.Get(client.Network, "/v2.0/ports?device_id="+serverID, &ports)
+	if err != nil {
+		return nil, fmt.Errorf("list ports: %s", err)
+	}
+
+	var ids []string
+	for _, port := range ports.Ports {
+		ids = append(ids, port.ID)
+	}
+	return ids, nil
+}
+
+// Stop deletes each server, along with its floating IPs.
+func (prvdr Provider) Stop(machines []db.Machine) error {
+	errChan := make(chan error, len(machines))
+	for _, m := range machines {
+		go func(m db.Machine) {
+			errChan <- prvdr.deleteServer(m.CloudID)
+		}(m)
+	}
+
+	var err error
+	for range machines {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (prvdr Provider) deleteServer(id string) error {
+	// Floating IPs outlive their servers, so they're released first.
+	ports, err := prvdr.serverPorts(id)
+	if err != nil {
+		return err
+	}
+
+	for _, port := range ports {
+		var ips struct{ FloatingIPs []struct{ ID string } }
+		err := prvdr.Get(client.Network, "/v2.0/floatingips?port_id="+port, &ips)
+		if err != nil {
+			return fmt.Errorf("list floating IPs: %s", err)
+		}
+
+		for _, ip := range ips.FloatingIPs {
+			err := prvdr.Delete(client.Network, "/v2.0/floatingips/"+ip.ID)
+			if err != nil && !client.IsNotFound(err) {
+				return fmt.Errorf("delete floating IP: %s", err)
+			}
+		}
+	}
+
+	err = prvdr.Delete(client.Compute, "/servers/"+id)
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("delete server %s: %s", id, err)
+	}
+
+	return wait.Wait(func() bool {
+		return client.IsNotFound(prvdr.Get(client.Compute, "/servers/"+id, nil))
+	})
+}
+
+// Resources lists the servers in the namespace, along with their volumes and
+// floating IPs, and the namespace's security group.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	servers, err := prvdr.servers()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	for _, s := range servers {
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      s.ID,
+			Name:    s.Name,
+			Tags:    s.Metadata,
+			Created: s.Created,
+		})
+
+		for _, vol := range s.Volumes {
+			resources = append(resources, resource.Resource{
+				Type: resource.Volume,
+				ID:   vol.ID,
+			})
+		}
+
+		ports, err := prvdr.serverPorts(s.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, port := range ports {
+			var ips struct {
+				FloatingIPs []struct {
+					ID      string
+					Address string `json:"floating_ip_address"`
+				}
+			}
+			err := prvdr.Get(client.Network, "/v2.0/floatingips?port_id="+port,
+				&ips)
+			if err != nil {
+				return nil, fmt.Errorf("list floating IPs: %s", err)
+			}
+
+			for _, ip := range ips.FloatingIPs {
+				resources = append(resources, resource.Resource{
+					Type: resource.IP,
+					ID:   ip.ID,
+					Name: ip.Address,
+				})
+			}
+		}
+	}
+
+	var groups struct {
+		SecurityGroups []struct{ ID, Name string } `json:"security_groups"`
+	}
+	err = prvdr.Get(client.Network, "/v2.0/security-groups?name="+
+		url.QueryEscape(prvdr.groupName()), &groups)
+	if err != nil {
+		return nil, fmt.Errorf("list security groups: %s", err)
+	}
+
+	for _, group := range groups.SecurityGroups {
+		resources = append(resources, resource.Resource{
+			Type: resource.SecurityGroup,
+			ID:   group.ID,
+			Name: group.Name,
+		})
+	}
+	return resources, nil
+}
+
+// Reboot soft reboots each server.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		body := map[string]interface{}{"reboot": map[string]string{"type": "SOFT"}}
+		err := prvdr.Post(client.Compute, "/servers/"+m.CloudID+"/action", body,
+			nil)
+		if err != nil {
+			return fmt.Errorf("reboot server %s: %s", m.CloudID, err)
+		}
+	}
+	return nil
+}
+
+// Capabilities returns the optional features that OpenStack supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		ResizableDisks: true,
+	}
+}
+
+// UpdateFloatingIPs is not supported in OpenStack.  Machines are assigned a
+// floating IP from the external network when they boot instead.
+func (prvdr Provider) UpdateFloatingIPs([]db.Machine) error {
+	return errors.New("openstack provider does not support floating IPs")
+}
+
+func (prvdr Provider) groupName() string {
+	return "quilt-" + prvdr.namespace
+}
+
+// securityGroup returns the ID of the namespace's security group, creating it
+// if it doesn't exist.  New groups allow all traffic between their members.
+func (prvdr Provider) securityGroup() (string, error) {
+	var groups struct {
+		SecurityGroups []struct{ ID string } `json:"security_groups"`
+	}
+	err := prvdr.Get(client.Network, "/v2.0/security-groups?name="+
+		url.QueryEscape(prvdr.groupName()), &groups)
+	if err != nil {
+		return "", fmt.Errorf("list security groups: %s", err)
+	}
+
+	if len(groups.SecurityGroups) > 0 {
+		return groups.SecurityGroups[0].ID, nil
+	}
+
+	var created struct {
+		SecurityGroup struct{ ID string } `json:"security_group"`
+	}
+	err = prvdr.Post(client.Network, "/v2.0/security-groups",
+		map[string]interface{}{"security_group": map[string]string{
+			"name":        prvdr.groupName(),
+			"description": "Quilt namespace " + prvdr.namespace,
+		}}, &created)
+	if err != nil {
+		return "", err
+	}
+
+	id := created.SecurityGroup.ID
+	err = prvdr.Post(client.Network, "/v2.0/security-group-rules",
+		map[string]interface{}{"security_group_rule": map[string]string{
+			"security_group_id": id,
+			"direction":         "ingress",
+			"ethertype":         "IPv4",
+			"remote_group_id":   id,
+		}}, nil)
+	return id, err
+}
+
+// A securityRule is the part of a security group rule that's set from an ACL.
+type securityRule struct {
+	Protocol string `json:"protocol"`
+	MinPort  int    `json:"port_range_min"`
+	MaxPort  int    `json:"port_range_max"`
+	CidrIP   string `json:"remote_ip_prefix"`
+}
+
+// SetACLs adds and removes the rules of the namespace's security group, so that
+// it allows the given TCP, UDP and ICMP traffic.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	groupID, err := prvdr.securityGroup()
+	if err != nil {
+		return err
+	}
+
+	var current struct {
+		SecurityGroupRules []struct {
+			ID        string
+			Direction string
+			securityRule
+		} `json:"security_group_rules"`
+	}
+	err = prvdr.Get(client.Network,
+		"/v2.0/security-group-rules?security_group_id="+groupID, &current)
+	if err != nil {
+		return fmt.Errorf("list security group rules: %s", err)
+	}
+
+	// Rules without a remote IP prefix, such as the rule that allows traffic
+	// between members of the group, aren't managed by ACLs.
+	currRules := map[securityRule]string{}
+	for _, rule := range current.SecurityGroupRules {
+		if rule.Direction == "ingress" && rule.CidrIP != "" {
+			currRules[rule.securityRule] = rule.ID
+		}
+	}
+
+	var currKeys, desired []securityRule
+	for rule := range currRules {
+		currKeys = append(currKeys, rule)
+	}
+	for _, a := range acls {
+		for _, protocol := range []string{"tcp", "udp"} {
+			desired = append(desired, securityRule{
+				Protocol: protocol,
+				MinPort:  a.MinPort,
+				MaxPort:  a.MaxPort,
+				CidrIP:   a.CidrIP,
+			})
+		}
+
+		// ICMP rules don't have ports, so they allow every ICMP type.
+		icmp := "icmp"
+		if a.IPv6() {
+			icmp = "ipv6-icmp"
+		}
+		desired = append(desired, securityRule{Protocol: icmp, CidrIP: a.CidrIP})
+	}
+
+	_, toAdd, toRemove := join.HashJoin(securityRuleSlice(desired),
+		securityRuleSlice(currKeys), nil, nil)
+
+	for _, intf := range toRemove {
+		id := currRules[intf.(securityRule)]
+		err := prvdr.Delete(client.Network, "/v2.0/security-group-rules/"+id)
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("delete security group rule: %s", err)
+		}
+	}
+
+	for _, intf := range toAdd {
+		rule := intf.(securityRule)
+		log.WithField("rule", rule).Debug("OpenStack: Adding ACL")
+
+		ethertype := "IPv4"
+		if (acl.ACL{CidrIP: rule.CidrIP}).IPv6() {
+			ethertype = "IPv6"
+		}
+
+		body := map[string]interface{}{
+			"security_group_id": groupID,
+			"direction":         "ingress",
+			"ethertype":         ethertype,
+			"protocol":          rule.Protocol,
+			"remote_ip_prefix":  rule.CidrIP,
+		}
+
+		// For ICMP, the ports are the ICMP type and code, which are left
+		// unset so that every type is allowed.
+		if rule.MinPort != 0 || rule.MaxPort != 0 {
+			body["port_range_min"] = rule.MinPort
+			body["port_range_max"] = rule.MaxPort
+		}
+
+		err := prvdr.Post(client.Network, "/v2.0/security-group-rules",
+			map[string]interface{}{"security_group_rule": body}, nil)
+		if err != nil {
+			return fmt.Errorf("add security group rule: %s", err)
+		}
+	}
+	return nil
+}
+
+type securityRuleSlice []securityRule
+
+func (slc securityRuleSlice) Get(ii int) interface{} {
+	return slc[ii]
+}
+
+func (slc securityRuleSlice) Len() int {
+	return len(slc)
+}
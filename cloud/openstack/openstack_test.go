@@ -0,0 +1,414 @@
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/cloudtest"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/openstack/client"
+	"github.com/kelda/kelda/cloud/openstack/client/mocks"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+var errNotFound = client.Error{StatusCode: http.StatusNotFound}
+
+var flavors = map[string]interface{}{"flavors": []map[string]string{
+	{"id": "f1", "name": "m1.small"},
+	{"id": "f2", "name": "m1.large"},
+}}
+
+func TestList(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns",
+		config: config{ExternalNetwork: "public"}}
+
+	mc.On("Get", client.Compute, "/flavors", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(flavors))
+	mc.On("Get", client.Compute, "/servers/detail", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"servers": []interface{}{
+			map[string]interface{}{
+				"id":       "a",
+				"metadata": map[string]string{namespaceKey: "ns"},
+				"flavor":   map[string]string{"id": "f2"},
+				"addresses": map[string]interface{}{"net": []interface{}{
+					map[string]string{"addr": "10.0.0.1",
+						"OS-EXT-IPS:type": "fixed"},
+					map[string]string{"addr": "8.8.8.8",
+						"OS-EXT-IPS:type": "floating"},
+				}},
+			},
+			map[string]interface{}{
+				"id":       "other",
+				"metadata": map[string]string{namespaceKey: "other"},
+			},
+		}}))
+
+	// Servers of other namespaces are ignored, and flavors are reported by
+	// name.
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{{CloudID: "a", Size: "m1.large",
+		PrivateIP: "10.0.0.1", PublicIP: "8.8.8.8"}}, machines)
+
+	// Without an external network, servers are only reachable at their fixed
+	// IP.
+	prvdr.config.ExternalNetwork = ""
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", machines[0].PublicIP)
+
+	mc = new(mocks.Client)
+	prvdr.Client = mc
+	mc.On("Get", client.Compute, "/servers/detail", mock.Anything).Return(
+		errors.New("timeout"))
+	_, err = prvdr.List()
+	assert.EqualError(t, err, "list servers: timeout")
+}
+
+func TestBoot(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns", config: config{
+		Image: "ubuntu", Network: "private", ExternalNetwork: "public"}}
+
+	// The namespace's security group doesn't exist yet, so it's created along
+	// with the rule that allows traffic between its members.
+	mc.On("Get", client.Network, "/v2.0/security-groups?name=quilt-ns",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(
+		map[string]interface{}{"security_groups": []interface{}{}}))
+	mc.On("Post", client.Network, "/v2.0/security-groups", mock.Anything,
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"security_group": map[string]string{"id": "sg"}}))
+	mc.On("Post", client.Network, "/v2.0/security-group-rules",
+		map[string]interface{}{"security_group_rule": map[string]string{
+			"security_group_id": "sg",
+			"direction":         "ingress",
+			"ethertype":         "IPv4",
+			"remote_group_id":   "sg",
+		}}, nil).Return(nil).Once()
+
+	mc.On("Get", client.Image, "/v2/images?name=ubuntu", mock.Anything).Return(
+		nil).Run(cloudtest.Reply(map[string]interface{}{
+		"images": []map[string]string{{"id": "image"}}}))
+	mc.On("Get", client.Network, "/v2.0/networks?name=private",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"networks": []map[string]string{{"id": "private-id"}}}))
+	mc.On("Get", client.Network, "/v2.0/networks?name=public",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"networks": []map[string]string{{"id": "public-id"}}}))
+	mc.On("Get", client.Compute, "/flavors", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(flavors))
+
+	var server map[string]interface{}
+	mc.On("Post", client.Compute, "/servers", mock.Anything,
+		mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		server = cloudtest.Body(args.Get(2))["server"].(map[string]interface{})
+		cloudtest.Reply(map[string]interface{}{
+			"server": map[string]string{"id": "a"}})(args)
+	}).Once()
+	mc.On("Get", client.Compute, "/servers/a", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{
+			"server": map[string]string{"status": "ACTIVE"}}))
+
+	// Once the server is active, its port is assigned a floating IP from the
+	// external network.
+	mc.On("Get", client.Network, "/v2.0/ports?device_id=a",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"ports": []map[string]string{{"id": "port"}}}))
+	mc.On("Post", client.Network, "/v2.0/floatingips", map[string]interface{}{
+		"floatingip": map[string]string{
+			"floating_network_id": "public-id",
+			"port_id":             "port",
+		}}, nil).Return(nil).Once()
+
+	err := prvdr.Boot([]db.Machine{{Size: "m1.large", Hostname: "worker-1",
+		DiskSize: 64}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	assert.Equal(t, "worker-1", server["name"])
+	assert.Equal(t, "f2", server["flavorRef"])
+	assert.Equal(t, map[string]interface{}{namespaceKey: "ns"},
+		server["metadata"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "quilt-ns"}},
+		server["security_groups"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"uuid": "private-id"}},
+		server["networks"])
+
+	// The flavor fixes the size of the root disk, so the server boots from a
+	// volume of the requested size instead of the image.
+	assert.Equal(t, "", server["imageRef"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"boot_index":            float64(0),
+		"uuid":                  "image",
+		"source_type":           "image",
+		"destination_type":      "volume",
+		"volume_size":           float64(64),
+		"delete_on_termination": true,
+	}}, server["block_device_mapping_v2"])
+}
+
+func TestBootErrors(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns", config: config{Image: "ubuntu"}}
+
+	err := prvdr.Boot([]db.Machine{{Preemptible: true}})
+	assert.EqualError(t, err, "preemptible instances are not yet implemented")
+
+	mc.On("Get", client.Network, "/v2.0/security-groups?name=quilt-ns",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"security_groups": []map[string]string{{"id": "sg"}}}))
+	mc.On("Get", client.Image, "/v2/images?name=ubuntu", mock.Anything).Return(
+		nil).Run(cloudtest.Reply(map[string]interface{}{
+		"images": []map[string]string{{"id": "image"}}}))
+	mc.On("Get", client.Compute, "/flavors", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(flavors))
+
+	err = prvdr.Boot([]db.Machine{{Size: "m1.huge"}})
+	assert.EqualError(t, err, "no flavor called m1.huge")
+	mc.AssertNotCalled(t, "Post", client.Compute, "/servers", mock.Anything,
+		mock.Anything)
+
+	// Servers that fail to boot are deleted.
+	mc.On("Post", client.Compute, "/servers", mock.Anything,
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"server": map[string]string{"id": "a"}}))
+	mc.On("Get", client.Compute, "/servers/a", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{
+			"server": map[string]string{"status": "ERROR"}})).Once()
+	mc.On("Get", client.Network, "/v2.0/ports?device_id=a",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"ports": []interface{}{}}))
+	mc.On("Delete", client.Compute, "/servers/a").Return(nil).Once()
+	mc.On("Get", client.Compute, "/servers/a", nil).Return(errNotFound)
+
+	err = prvdr.Boot([]db.Machine{{Size: "m1.small"}})
+	assert.EqualError(t, err, "wait for server a: server failed to boot")
+	mc.AssertExpectations(t)
+}
+
+func TestStop(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	// Floating IPs outlive their servers, so they're released first.  Those
+	// that were already released are ignored.
+	mc.On("Get", client.Network, "/v2.0/ports?device_id=a",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"ports": []map[string]string{{"id": "p1"}, {"id": "p2"}}}))
+	mc.On("Get", client.Network, "/v2.0/floatingips?port_id=p1",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"floatingips": []map[string]string{{"id": "ip1"}}}))
+	mc.On("Get", client.Network, "/v2.0/floatingips?port_id=p2",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"floatingips": []map[string]string{{"id": "ip2"}}}))
+	mc.On("Delete", client.Network, "/v2.0/floatingips/ip1").Return(nil).Once()
+	mc.On("Delete", client.Network, "/v2.0/floatingips/ip2").Return(
+		errNotFound).Once()
+	mc.On("Delete", client.Compute, "/servers/a").Return(nil).Once()
+	mc.On("Get", client.Compute, "/servers/a", nil).Return(errNotFound)
+
+	assert.NoError(t, prvdr.Stop([]db.Machine{{CloudID: "a"}}))
+	mc.AssertExpectations(t)
+
+	mc.On("Get", client.Network, "/v2.0/ports?device_id=b",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"ports": []interface{}{}}))
+	mc.On("Delete", client.Compute, "/servers/b").Return(errors.New("conflict"))
+	err := prvdr.Stop([]db.Machine{{CloudID: "b"}})
+	assert.EqualError(t, err, "delete server b: conflict")
+}
+
+func TestReboot(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	mc.On("Post", client.Compute, "/servers/a/action", map[string]interface{}{
+		"reboot": map[string]string{"type": "SOFT"}}, nil).Return(nil).Once()
+	assert.NoError(t, prvdr.Reboot([]db.Machine{{CloudID: "a"}}))
+
+	mc.On("Post", client.Compute, "/servers/missing/action", mock.Anything,
+		nil).Return(errNotFound)
+	err := prvdr.Reboot([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "reboot server missing: 404: ")
+	mc.AssertExpectations(t)
+}
+
+func TestResources(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	mc.On("Get", client.Compute, "/servers/detail", mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"servers": []interface{}{
+			map[string]interface{}{
+				"id":       "a",
+				"name":     "worker-1",
+				"created":  "2017-10-13T17:00:00Z",
+				"metadata": map[string]string{namespaceKey: "ns"},
+				"os-extended-volumes:volumes_attached": []map[string]string{
+					{"id": "vol"}},
+			},
+			map[string]interface{}{
+				"id":       "other",
+				"metadata": map[string]string{namespaceKey: "other"},
+			},
+		}}))
+	mc.On("Get", client.Network, "/v2.0/ports?device_id=a",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"ports": []map[string]string{{"id": "port"}}}))
+	mc.On("Get", client.Network, "/v2.0/floatingips?port_id=port",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"floatingips": []map[string]string{
+			{"id": "ip", "floating_ip_address": "8.8.8.8"}}}))
+	mc.On("Get", client.Network, "/v2.0/security-groups?name=quilt-ns",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"security_groups": []map[string]string{
+			{"id": "sg", "name": "quilt-ns"}}}))
+
+	// Volumes attached to the servers are listed, as they may outlive them.
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{
+			Type:    resource.Instance,
+			ID:      "a",
+			Name:    "worker-1",
+			Tags:    map[string]string{namespaceKey: "ns"},
+			Created: time.Date(2017, 10, 13, 17, 0, 0, 0, time.UTC),
+		},
+		{Type: resource.Volume, ID: "vol"},
+		{Type: resource.IP, ID: "ip", Name: "8.8.8.8"},
+		{Type: resource.SecurityGroup, ID: "sg", Name: "quilt-ns"},
+	}, resources)
+}
+
+func TestSetACLs(t *testing.T) {
+	mc := new(mocks.Client)
+	prvdr := Provider{Client: mc, namespace: "ns"}
+
+	mc.On("Get", client.Network, "/v2.0/security-groups?name=quilt-ns",
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"security_groups": []map[string]string{{"id": "sg"}}}))
+
+	// The rule that allows traffic within the group has no remote IP prefix,
+	// so it's left alone.  The ICMP rule, whose ports are unset, is current.
+	mc.On("Get", client.Network, "/v2.0/security-group-rules?"+
+		"security_group_id=sg", mock.Anything).Return(nil).Run(cloudtest.Reply(
+		map[string]interface{}{"security_group_rules": []interface{}{
+			map[string]interface{}{"id": "group", "direction": "ingress",
+				"remote_group_id": "sg"},
+			map[string]interface{}{"id": "icmp", "direction": "ingress",
+				"protocol": "icmp", "remote_ip_prefix": "1.2.3.4/32"},
+			map[string]interface{}{"id": "tcp", "direction": "ingress",
+				"protocol": "tcp", "remote_ip_prefix": "1.2.3.4/32",
+				"port_range_min": 80, "port_range_max": 80},
+			map[string]interface{}{"id": "stale", "direction": "ingress",
+				"protocol": "tcp", "remote_ip_prefix": "5.6.7.8/32",
+				"port_range_min": 22, "port_range_max": 22},
+		}}))
+	mc.On("Delete", client.Network, "/v2.0/security-group-rules/stale").Return(
+		nil).Once()
+
+	var added []map[string]interface{}
+	mc.On("Post", client.Network, "/v2.0/security-group-rules", mock.Anything,
+		nil).Return(nil).Run(func(args mock.Arguments) {
+		body := cloudtest.Body(args.Get(2))
+		added = append(added,
+			body["security_group_rule"].(map[string]interface{}))
+	})
+
+	assert.NoError(t, prvdr.SetACLs([]acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "2001:db8::/32", MinPort: 22, MaxPort: 22},
+	}))
+	mc.AssertExpectations(t)
+
+	rule := func(ethertype, protocol, cidr string) map[string]interface{} {
+		return map[string]interface{}{
+			"security_group_id": "sg",
+			"direction":         "ingress",
+			"ethertype":         ethertype,
+			"protocol":          protocol,
+			"remote_ip_prefix":  cidr,
+		}
+	}
+	withPorts := func(r map[string]interface{}, min, max int) map[string]interface{} {
+		r["port_range_min"] = float64(min)
+		r["port_range_max"] = float64(max)
+		return r
+	}
+	assert.Equal(t, sorted([]map[string]interface{}{
+		withPorts(rule("IPv4", "udp", "1.2.3.4/32"), 80, 80),
+		withPorts(rule("IPv6", "tcp", "2001:db8::/32"), 22, 22),
+		withPorts(rule("IPv6", "udp", "2001:db8::/32"), 22, 22),
+		rule("IPv6", "ipv6-icmp", "2001:db8::/32"),
+	}), sorted(added))
+}
+
+// sorted returns the rules printed as strings in sorted order, as SetACLs adds
+// them in no particular order.
+func sorted(rules []map[string]interface{}) []string {
+	var strs []string
+	for _, rule := range rules {
+		strs = append(strs, fmt.Sprint(rule))
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+func TestUpdateFloatingIPs(t *testing.T) {
+	err := Provider{}.UpdateFloatingIPs([]db.Machine{{FloatingIP: "8.8.8.8"}})
+	assert.EqualError(t, err, "openstack provider does not support floating IPs")
+}
+
+func TestRegions(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+
+	assert.Equal(t, []string{DefaultRegion}, Regions())
+
+	util.WriteFile(path, []byte(`{"authURL": "http://keystone:5000/v3",
+		"username": "user", "projectName": "project",
+		"regions": ["east", "west"]}`), 0600)
+	assert.Equal(t, []string{"east", "west"}, Regions())
+
+	conf, err := readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "Default", conf.DomainName)
+	assert.Equal(t, "ubuntu-16.04", conf.Image)
+
+	util.WriteFile(path, []byte(`{"username": "user"}`), 0600)
+	_, err = readConfig()
+	assert.EqualError(t, err, path+" must contain the authURL, username, and "+
+		"projectName of the OpenStack user")
+
+	// The environment takes precedence over the config file.
+	os.Setenv(authURLEnv, "http://env:5000/v3")
+	os.Setenv(projectNameEnv, "envProject")
+	defer os.Unsetenv(authURLEnv)
+	defer os.Unsetenv(projectNameEnv)
+	conf, err = readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "http://env:5000/v3", conf.AuthURL)
+	assert.Equal(t, "user", conf.Username)
+	assert.Equal(t, "envProject", conf.ProjectName)
+
+	os.Unsetenv(authURLEnv)
+	os.Unsetenv(projectNameEnv)
+	util.AppFs = afero.NewMemMapFs()
+	_, err = readConfig()
+	assert.True(t, creds.IsMissing(err))
+}
@@ -0,0 +1,102 @@
+// Package quota checks machines against the limits that cloud providers place on
+// their accounts before they're booted.  Booting past a limit fails with errors
+// that are often opaque, and would be retried forever, so machines that don't
+// fit are held back with an explanation instead.
+package quota
+
+import (
+	"fmt"
+
+	"github.com/kelda/kelda/cloud/machine"
+	"github.com/kelda/kelda/db"
+)
+
+// A Resource is something whose use a provider limits.
+type Resource string
+
+const (
+	// Instances is the number of machines.
+	Instances Resource = "instances"
+
+	// CPUs is the number of vCPUs of all machines.
+	CPUs Resource = "vCPUs"
+
+	// IPs is the number of public IP addresses.
+	IPs Resource = "IP addresses"
+)
+
+// The resources in the order that they're checked, so that the same quota is
+// always reported for a machine that exceeds several.
+var resources = []Resource{Instances, CPUs, IPs}
+
+// A Quota is a provider's limit on a Resource, and how much of it is in use.
+type Quota struct {
+	Resource Resource
+	Limit    int
+	Usage    int
+}
+
+// An ExceededError reports that booting a machine needs more of a Resource than
+// is left in its Quota.
+type ExceededError struct {
+	Provider db.ProviderName
+	Quota    Quota
+	Needed   int
+}
+
+func (err ExceededError) Error() string {
+	remaining := err.Quota.Limit - err.Quota.Usage
+	if remaining < 0 {
+		remaining = 0
+	}
+	return fmt.Sprintf("needs %d %s, but only %d of the %s quota of %d "+
+		"remain. Stop other machines, or ask %s to raise the quota",
+		err.Needed, err.Quota.Resource, remaining, err.Provider,
+		err.Quota.Limit, err.Provider)
+}
+
+// A Budget tracks how much of each Quota remains as machines are booted.  The
+// zero Budget has no quotas, and so fits every machine.
+type Budget struct {
+	provider db.ProviderName
+	quotas   map[Resource]*Quota
+}
+
+// NewBudget creates a Budget for booting `provider` machines within `quotas`.
+func NewBudget(provider db.ProviderName, quotas []Quota) Budget {
+	b := Budget{provider: provider, quotas: map[Resource]*Quota{}}
+	for _, q := range quotas {
+		q := q
+		b.quotas[q.Resource] = &q
+	}
+	return b
+}
+
+// Reserve deducts the resources needed to boot `m` from the budget.  If any of
+// them is exhausted, nothing is deducted, and an ExceededError is returned.
+func (b Budget) Reserve(m db.Machine) error {
+	needs := Needs(m)
+	for _, res := range resources {
+		q, ok := b.quotas[res]
+		if n := needs[res]; ok && q.Usage+n > q.Limit {
+			return ExceededError{Provider: b.provider, Quota: *q, Needed: n}
+		}
+	}
+
+	for res, n := range needs {
+		if q, ok := b.quotas[res]; ok {
+			q.Usage += n
+		}
+	}
+	return nil
+}
+
+// Needs returns how much of each Resource booting `m` uses.  The vCPUs of sizes
+// that aren't known aren't counted.
+func Needs(m db.Machine) map[Resource]int {
+	needs := map[Resource]int{Instances: 1, IPs: 1}
+	if cpus := machine.CPUs(m.Provider, m.Size); cpus > 0 {
+		needs[CPUs] = cpus
+	}
+	return needs
+}
@@ -0,0 +1,44 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserve(t *testing.T) {
+	t.Parallel()
+
+	m := db.Machine{Provider: db.Fake, Size: "fake-2x4"}
+	b := NewBudget(db.Fake, []Quota{
+		{Resource: Instances, Limit: 3, Usage: 0},
+		{Resource: CPUs, Limit: 5, Usage: 1},
+	})
+	assert.NoError(t, b.Reserve(m))
+	assert.NoError(t, b.Reserve(m))
+
+	err := b.Reserve(m)
+	assert.Equal(t, ExceededError{Provider: db.Fake,
+		Quota:  Quota{Resource: CPUs, Limit: 5, Usage: 5},
+		Needed: 2}, err)
+	assert.EqualError(t, err, "needs 2 vCPUs, but only 0 of the Fake quota "+
+		"of 5 remain. Stop other machines, or ask Fake to raise the quota")
+
+	// The failed reservation didn't use up the last instance.
+	assert.NoError(t, b.Reserve(db.Machine{Provider: db.Fake, Size: "unknown"}))
+	assert.Equal(t, ExceededError{Provider: db.Fake,
+		Quota:  Quota{Resource: Instances, Limit: 3, Usage: 3},
+		Needed: 1}, b.Reserve(m))
+
+	assert.NoError(t, Budget{}.Reserve(m))
+}
+
+func TestNeeds(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, map[Resource]int{Instances: 1, IPs: 1, CPUs: 4},
+		Needs(db.Machine{Provider: db.Fake, Size: "fake-4x8"}))
+	assert.Equal(t, map[Resource]int{Instances: 1, IPs: 1},
+		Needs(db.Machine{Provider: db.Fake, Size: "unknown"}))
+}
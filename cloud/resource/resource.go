@@ -0,0 +1,36 @@
+package resource
+
+import "time"
+
+// Resource is an object that a cloud provider created on behalf of a namespace,
+// such as an instance, a firewall, or an IP address.
+type Resource struct {
+	// One of the resource types below.
+	Type string
+
+	// The provider's identifier for the resource.
+	ID string
+
+	// A more recognizable name for the resource, such as its IP address, if
+	// its ID isn't one.
+	Name string
+
+	// The tags or labels attached to the resource, if any.
+	Tags map[string]string
+
+	// When the resource was created, or the zero time if the provider doesn't
+	// report it.
+	Created time.Time
+}
+
+// The types of resources that providers report.
+const (
+	Instance      = "instance"
+	SpotRequest   = "spot-request"
+	SecurityGroup = "security-group"
+	KeyPair       = "key-pair"
+	Volume        = "volume"
+	IP            = "ip"
+	Network       = "network"
+	Other         = "other"
+)
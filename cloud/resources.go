@@ -0,0 +1,91 @@
+package cloud
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+)
+
+// The clouds of the current namespace, which are queried for their resources.
+var clouds struct {
+	sync.Mutex
+	active []cloud
+}
+
+// A Resource is a provider resource attributable to the namespace, along with
+// the region it was found in.
+type Resource struct {
+	resource.Resource
+
+	Provider db.ProviderName
+	Region   string
+}
+
+// setActiveClouds records the clouds of a new namespace.
+func setActiveClouds(active []cloud) {
+	clouds.Lock()
+	defer clouds.Unlock()
+	clouds.active = active
+}
+
+// Resources queries every region of every provider for the resources that it
+// created for the current namespace.  Resources that aren't specific to a region,
+// such as Google networks, are only reported once.  Regions that couldn't be
+// queried are reported in the returned errors, alongside the resources of the
+// other regions.
+func Resources() ([]Resource, []error) {
+	clouds.Lock()
+	active := clouds.active
+	clouds.Unlock()
+
+	type result struct {
+		cld       cloud
+		resources []resource.Resource
+		err       error
+	}
+
+	results := make([]result, len(active))
+	var wg sync.WaitGroup
+	for i, cld := range active {
+		wg.Add(1)
+		go func(i int, cld cloud) {
+			defer wg.Done()
+			c.Inc("List Resources")
+			resources, err := cld.provider.Resources()
+			results[i] = result{cld, resources, err}
+		}(i, cld)
+	}
+	wg.Wait()
+
+	type key struct {
+		provider db.ProviderName
+		typ, id  string
+	}
+	seen := map[key]bool{}
+
+	var resources []Resource
+	var errs []error
+	for _, res := range results {
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", res.cld, res.err))
+			continue
+		}
+
+		for _, r := range res.resources {
+			k := key{res.cld.providerName, r.Type, r.ID}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+
+			resources = append(resources, Resource{
+				Resource: r,
+				Provider: res.cld.providerName,
+				Region:   res.cld.region,
+			})
+		}
+	}
+	return resources, errs
+}
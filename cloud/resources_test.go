@@ -0,0 +1,55 @@
+package cloud
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+)
+
+func TestResources(t *testing.T) {
+	defer setActiveClouds(nil)
+
+	resources, errs := Resources()
+	assert.Empty(t, resources)
+	assert.Empty(t, errs)
+
+	network := resource.Resource{Type: resource.Network, ID: "quilt"}
+	instance := resource.Resource{Type: resource.Instance, ID: "i-1"}
+
+	west := newTestCloud(FakeAmazon, "us-west-1", "ns")
+	west.provider.(*fakeProvider).resources = []resource.Resource{
+		network, instance}
+
+	// Resources that aren't specific to a region are only reported once.
+	east := newTestCloud(FakeAmazon, "us-east-1", "ns")
+	east.provider.(*fakeProvider).resources = []resource.Resource{network}
+
+	broken := newTestCloud(FakeVagrant, testRegion, "ns")
+	broken.provider.(*fakeProvider).resourcesError = errors.New("err")
+
+	setActiveClouds([]cloud{*west, *east, *broken})
+	resources, errs = Resources()
+	assert.Equal(t, []Resource{
+		{Resource: network, Provider: FakeAmazon, Region: "us-west-1"},
+		{Resource: instance, Provider: FakeAmazon, Region: "us-west-1"},
+	}, resources)
+	assert.Equal(t, []error{errors.New("FakeVagrant-Fake region-ns: err")},
+		errs)
+}
+
+func TestMakeCloudsActive(t *testing.T) {
+	defer setActiveClouds(nil)
+	mock()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	makeClouds(db.New(), "ns", stop)
+
+	clouds.Lock()
+	defer clouds.Unlock()
+	assert.Len(t, clouds.active, 2)
+}
@@ -0,0 +1,131 @@
+//go:generate mockery -name=Client
+
+// Package client is a minimal client of the Scaleway Instance API.  Requests are
+// scoped to a zone, and authenticated with the user's secret key.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/cloud/throttle"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+)
+
+// A Client for the Scaleway Instance API.  Used for unit testing.
+type Client interface {
+	// Do sends a `method` request to `path`, which is relative to the API of
+	// the client's zone, and decodes the response into `result` if it's
+	// non-nil.  `body` is sent as JSON, unless it's a string, in which case
+	// it's sent as plain text.
+	Do(method, path string, body, result interface{}) error
+}
+
+// An Error is returned when the API rejects a request.
+type Error struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Type, err.Message)
+}
+
+// IsNotFound returns whether `err` reports that a resource doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Credentials are the secret key with which requests are authenticated.
+type Credentials struct {
+	SecretKey string
+}
+
+var c = counter.New("Scaleway")
+
+type client struct {
+	http  *http.Client
+	creds Credentials
+	zone  string
+}
+
+// New creates a client of the API in `zone`, whose requests are recorded or
+// replayed as `cassetteName`.
+func New(creds Credentials, zone, cassetteName string) Client {
+	return &client{
+		http: cassette.Wrap(cassetteName, throttle.Wrap(db.Scaleway, zone,
+			endpoint.Client(db.Scaleway, &http.Client{}))),
+		creds: creds,
+		zone:  zone,
+	}
+}
+
+func (client *client) Do(method, path string, body, result interface{}) error {
+	c.Inc(method)
+
+	var reqBody io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reqBody = strings.NewReader(b)
+		contentType = "text/plain"
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, baseURL()+"zones/"+client.zone+path,
+		reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", client.creds.SecretKey)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(respBody, &apiErr) != nil || apiErr.Type == "" {
+			apiErr.Type = resp.Status
+		}
+		return apiErr
+	}
+
+	if result != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, result)
+	}
+	return nil
+}
+
+func baseURL() string {
+	if base, ok := endpoint.Get(db.Scaleway); ok {
+		return base
+	}
+	return "https://api.scaleway.com/instance/v1/"
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/endpoint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "secret", r.Header.Get("X-Auth-Token"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+
+			switch r.Method + " " + r.URL.Path {
+			case "POST /zones/fr-par-1/servers":
+				assert.Equal(t, "application/json",
+					r.Header.Get("Content-Type"))
+				fmt.Fprintf(w, `{"server": %s}`, body)
+			case "PATCH /zones/fr-par-1/servers/id/user_data/cloud-init":
+				assert.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+				assert.Equal(t, "#cloud-config", string(body))
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"type": "not_found",
+					"message": "resource is not found"}`)
+			}
+		}))
+	defer server.Close()
+
+	endpoint.Overrides[db.Scaleway] = server.URL
+	defer delete(endpoint.Overrides, db.Scaleway)
+
+	client := New(Credentials{SecretKey: "secret"}, "fr-par-1", "")
+
+	var res struct{ Server struct{ Name string } }
+	err := client.Do("POST", "/servers", map[string]string{"name": "name"}, &res)
+	assert.NoError(t, err)
+	assert.Equal(t, "name", res.Server.Name)
+
+	err = client.Do("PATCH", "/servers/id/user_data/cloud-init",
+		"#cloud-config", nil)
+	assert.NoError(t, err)
+
+	err = client.Do("DELETE", "/servers/missing", nil, nil)
+	assert.EqualError(t, err, "not_found: resource is not found")
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+}
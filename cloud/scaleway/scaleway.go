@@ -0,0 +1,890 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cassette"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/scaleway/client"
+	"github.com/kelda/kelda/cloud/wait"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/util"
+
+	"github.com/satori/go.uuid"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRegion is assigned to Machines without a specified region.  Scaleway
+// regions are its availability zones.
+const DefaultRegion string = "fr-par-1"
+
+// Regions supported by the Scaleway provider.
+var Regions = []string{
+	"fr-par-1",
+	"fr-par-2",
+	"nl-ams-1",
+	"pl-waw-1",
+}
+
+var configPath = filepath.Join(".scaleway", "quilt.json")
+
+// The environment variables that override the credentials in the config file.
+const (
+	secretKeyEnv = "SCW_SECRET_KEY"
+	projectIDEnv = "SCW_DEFAULT_PROJECT_ID"
+)
+
+// The name of the public Ubuntu 16.04 image that machines boot by default.
+// Images have a different ID in each zone, so it's looked up by name.
+const defaultImageName = "Ubuntu Xenial"
+
+// The number of resources requested in each page of a list.
+const perPage = 100
+
+// config is the configuration in ~/.scaleway/quilt.json.
+type config struct {
+	client.Credentials
+
+	// The project that resources are created in.
+	ProjectID string
+
+	// The ID of the image that machines boot.  If it's empty, the public
+	// Ubuntu 16.04 image of the zone is used.
+	Image string
+}
+
+func readConfig() (config, error) {
+	var conf config
+
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+	confStr, err := util.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(confStr), &conf); err != nil {
+			return conf, fmt.Errorf("parse %s: %s", path, err)
+		}
+	case !os.IsNotExist(err):
+		return conf, err
+	}
+
+	creds.FromEnv(&conf.SecretKey, secretKeyEnv)
+	creds.FromEnv(&conf.ProjectID, projectIDEnv)
+
+	switch {
+	case conf.SecretKey == "" && conf.ProjectID == "":
+		return conf, creds.MissingError{Provider: db.Scaleway, Sources: []string{
+			path, creds.EnvSource(secretKeyEnv, projectIDEnv)}}
+	case conf.SecretKey == "" || conf.ProjectID == "":
+		return conf, fmt.Errorf("%s must contain the secretKey of an API key, "+
+			"and a projectId", path)
+	}
+	return conf, nil
+}
+
+// The Provider object represents a connection to a Scaleway zone.  Servers are
+// tagged with the namespace, and share a security group that's named after the
+// namespace.  Each server's public IP is a flexible IP, which is either one of the
+// blueprint's floating IPs, or one that's allocated for the server and tagged
+// with the namespace.
+type Provider struct {
+	client.Client
+
+	namespace string
+	zone      string
+	config    config
+}
+
+// New starts a new client session with the API key in ~/.scaleway/quilt.json,
+// or in the environment.
+func New(namespace, zone string) (*Provider, error) {
+	prvdr, err := newScaleway(namespace, zone)
+	if err != nil {
+		return prvdr, err
+	}
+
+	_, err = prvdr.List()
+	return prvdr, err
+}
+
+// Creation is broken out for unit testing.
+var newScaleway = func(namespace, zone string) (*Provider, error) {
+	// Replayed responses don't require valid credentials.
+	var conf config
+	if !cassette.Replaying() {
+		var err error
+		if conf, err = readConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Provider{
+		Client:    client.New(conf.Credentials, zone, "scaleway-"+zone),
+		namespace: namespace,
+		zone:      zone,
+		config:    conf,
+	}, nil
+}
+
+type server struct {
+	ID             string
+	Name           string
+	CommercialType string `json:"commercial_type"`
+	State          string
+	PrivateIP      string                        `json:"private_ip"`
+	PublicIP       *struct{ ID, Address string } `json:"public_ip"`
+	Tags           []string
+	CreationDate   time.Time `json:"creation_date"`
+	Volumes        map[string]struct{ ID string }
+}
+
+type flexibleIP struct {
+	ID      string
+	Address string
+	Server  *struct{ ID string }
+	Tags    []string
+}
+
+// List the servers in the namespace.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	servers, err := prvdr.namespaceServers()
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := prvdr.namespaceIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []db.Machine
+	for _, srv := range servers {
+		m := db.Machine{
+			CloudID:   srv.ID,
+			Size:      srv.CommercialType,
+			PrivateIP: srv.PrivateIP,
+		}
+
+		if srv.PublicIP != nil {
+			m.PublicIP = srv.PublicIP.Address
+			if _, ok := ips[srv.PublicIP.ID]; !ok {
+				m.FloatingIP = srv.PublicIP.Address
+			}
+		}
+		machines = append(machines, m)
+	}
+	return machines, nil
+}
+
+func (prvdr Provider) namespaceTag() string {
+	return "quilt-namespace=" + prvdr.namespace
+}
+
+// namespaceServers returns the servers tagged with the namespace.
+func (prvdr Provider) namespaceServers() ([]server, error) {
+	var servers []server
+	for page := 1; ; page++ {
+		var resp struct{ Servers []server }
+		path := fmt.Sprintf("/servers?tags=%s&per_page=%d&page=%d",
+			url.QueryEscape(prvdr.namespaceTag()), perPage, page)
+		if err := prvdr.Do("GET", path, nil, &resp); err != nil {
+			return nil, fmt.Errorf("list servers: %s", err)
+		}
+
+		servers = append(servers, resp.Servers...)
+		if len(resp.Servers) < perPage {
+			return servers, nil
+		}
+	}
+}
+
+// server returns the server with the given ID, or false if it doesn't exist.
+func (prvdr Provider) server(id string) (server, bool, error) {
+	var resp struct{ Server server }
+	err := prvdr.Do("GET", "/servers/"+id, nil, &resp)
+	if client.IsNotFound(err) {
+		return server{}, false, nil
+	} else if err != nil {
+		return server{}, false, fmt.Errorf("get server %s: %s", id, err)
+	}
+	return resp.Server, true, nil
+}
+
+// ips returns the flexible IPs of the project.  If `tag` is non-empty, only the
+// IPs with that tag are returned.
+func (prvdr Provider) ips(tag string) ([]flexibleIP, error) {
+	var ips []flexibleIP
+	for page := 1; ; page++ {
+		var resp struct{ IPs []flexibleIP }
+		path := fmt.Sprintf("/ips?project=%s&per_page=%d&page=%d",
+			url.QueryEscape(prvdr.config.ProjectID), perPage, page)
+		if tag != "" {
+			path += "&tags=" + url.QueryEscape(tag)
+		}
+
+		if err := prvdr.Do("GET", path, nil, &resp); err != nil {
+			return nil, fmt.Errorf("list flexible IPs: %s", err)
+		}
+
+		ips = append(ips, resp.IPs...)
+		if len(resp.IPs) < perPage {
+			return ips, nil
+		}
+	}
+}
+
+// namespaceIPs returns the flexible IPs that were allocated for the namespace,
+// keyed by their ID.
+func (prvdr Provider) namespaceIPs() (map[string]flexibleIP, error) {
+	ips, err := prvdr.ips(prvdr.namespaceTag())
+	if err != nil {
+		return nil, err
+	}
+
+	idToIP := map[string]flexibleIP{}
+	for _, ip := range ips {
+		idToIP[ip.ID] = ip
+	}
+	return idToIP, nil
+}
+
+// The resources that servers are booted with.
+type bootResources struct {
+	imageID, securityGroupID string
+}
+
+// Boot creates each server in a goroutine, and waits for them to start.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	for _, m := range bootSet {
+		if m.Preemptible {
+			return errors.New("preemptible instances are not yet implemented")
+		}
+	}
+
+	imageID, err := prvdr.image()
+	if err != nil {
+		return err
+	}
+
+	groupID, err := prvdr.securityGroup()
+	if err != nil {
+		return fmt.Errorf("create security group: %s", err)
+	}
+
+	res := bootResources{imageID: imageID, securityGroupID: groupID}
+	errChan := make(chan error, len(bootSet))
+	for _, m := range bootSet {
+		go func(m db.Machine) {
+			errChan <- prvdr.createServer(m, res)
+		}(m)
+	}
+
+	for range bootSet {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// image returns the ID of the image that servers boot.
+func (prvdr Provider) image() (string, error) {
+	if prvdr.config.Image != "" {
+		return prvdr.config.Image, nil
+	}
+
+	var resp struct {
+		Images []struct {
+			ID           string
+			Name         string
+			CreationDate time.Time `json:"creation_date"`
+		}
+	}
+	path := fmt.Sprintf("/images?public=true&arch=x86_64&name=%s&per_page=%d",
+		url.QueryEscape(defaultImageName), perPage)
+	if err := prvdr.Do("GET", path, nil, &resp); err != nil {
+		return "", fmt.Errorf("list images: %s", err)
+	}
+
+	// The name filter matches substrings, and old versions of the image are
+	// kept, so the newest image with exactly the right name is used.
+	var id string
+	var newest time.Time
+	for _, img := range resp.Images {
+		if img.Name == defaultImageName && !img.CreationDate.Before(newest) {
+			id, newest = img.ID, img.CreationDate
+		}
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("no %s image in %s. Configure one in ~/%s",
+			defaultImageName, prvdr.zone, configPath)
+	}
+	return id, nil
+}
+
+// createServer allocates a flexible IP, creates a server with it, and starts the
+// server.  If any step fails, the server and IP are deleted.
+func (prvdr Provider) createServer(m db.Machine, res bootResources) error {
+	ip, err := prvdr.allocateIP()
+	if err != nil {
+		return err
+	}
+
+	name := "quilt-" + uuid.NewV4().String()
+	if m.Hostname != "" {
+		name = m.Hostname
+	}
+
+	req := map[string]interface{}{
+		"name":                name,
+		"commercial_type":     m.Size,
+		"image":               res.imageID,
+		"project":             prvdr.config.ProjectID,
+		"tags":                []string{prvdr.namespaceTag()},
+		"security_group":      res.securityGroupID,
+		"dynamic_ip_required": false,
+		"public_ip":           ip.ID,
+	}
+
+	if m.DiskSize != 0 {
+		req["volumes"] = map[string]interface{}{
+			"0": map[string]interface{}{
+				"size":        int64(m.DiskSize) * 1000 * 1000 * 1000,
+				"volume_type": "l_ssd",
+			},
+		}
+	}
+
+	var created struct{ Server server }
+	if err := prvdr.Do("POST", "/servers", req, &created); err != nil {
+		if releaseErr := prvdr.releaseIP(ip.ID); releaseErr != nil {
+			log.WithError(releaseErr).Warn("Failed to release flexible IP")
+		}
+		return fmt.Errorf("create server: %s", err)
+	}
+
+	id := created.Server.ID
+	if err := prvdr.setupServer(id, m); err != nil {
+		if cleanupErr := prvdr.deleteServer(id); cleanupErr != nil {
+			log.WithError(cleanupErr).WithField("id", id).Warn(
+				"Failed to clean up server that failed to boot")
+		}
+		return err
+	}
+	return nil
+}
+
+func (prvdr Provider) setupServer(id string, m db.Machine) error {
+	err := prvdr.Do("PATCH", "/servers/"+id+"/user_data/cloud-init",
+		cfg.UserData(m, ""), nil)
+	if err != nil {
+		return fmt.Errorf("set user data of server %s: %s", id, err)
+	}
+
+	if err := prvdr.action(id, "poweron"); err != nil {
+		return err
+	}
+	return prvdr.waitForState(id, "running")
+}
+
+// action performs `action`, such as "poweron" or "reboot", on the server.
+func (prvdr Provider) action(id, action string) error {
+	err := prvdr.Do("POST", "/servers/"+id+"/action",
+		map[string]string{"action": action}, nil)
+	if err != nil {
+		return fmt.Errorf("%s server %s: %s", action, id, err)
+	}
+	return nil
+}
+
+func (prvdr Provider) waitForState(id, state string) error {
+	err := wait.Wait(func() bool {
+		srv, ok, err := prvdr.server(id)
+		return err == nil && ok && srv.State == state
+	})
+	if err != nil {
+		return fmt.Errorf("wait for server %s to be %s: %s", id, state, err)
+	}
+	return nil
+}
+
+// Stop deletes each server, and releases the flexible IPs allocated for them.
+func (prvdr Provider) Stop(machines []db.Machine) error {
+	errChan := make(chan error, len(machines))
+	for _, m := range machines {
+		go func(m db.Machine) {
+			errChan <- prvdr.deleteServer(m.CloudID)
+		}(m)
+	}
+
+	var err error
+	for range machines {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Reboot restarts each server in place.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		if err := prvdr.action(m.CloudID, "reboot"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteServer deletes the server along with its volumes, and releases its
+// flexible IP if it was allocated for the server.
+func (prvdr Provider) deleteServer(id string) error {
+	srv, ok, err := prvdr.server(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	// Servers that were never started can't be terminated, so they, and
+	// their volumes, are deleted directly.
+	if srv.State == "stopped" {
+		err := prvdr.Do("DELETE", "/servers/"+id, nil, nil)
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("delete server %s: %s", id, err)
+		}
+
+		for _, volume := range srv.Volumes {
+			err := prvdr.Do("DELETE", "/volumes/"+volume.ID, nil, nil)
+			if err != nil && !client.IsNotFound(err) {
+				return fmt.Errorf("delete volume %s: %s", volume.ID, err)
+			}
+		}
+	} else if err := prvdr.action(id, "terminate"); err != nil {
+		return err
+	}
+
+	err = wait.Wait(func() bool {
+		_, ok, err := prvdr.server(id)
+		return err == nil && !ok
+	})
+	if err != nil {
+		return fmt.Errorf("wait for server %s to be deleted: %s", id, err)
+	}
+
+	if srv.PublicIP == nil {
+		return nil
+	}
+
+	ips, err := prvdr.namespaceIPs()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ips[srv.PublicIP.ID]; ok {
+		return prvdr.releaseIP(srv.PublicIP.ID)
+	}
+	return nil
+}
+
+// allocateIP allocates a flexible IP that's tagged with the namespace.
+func (prvdr Provider) allocateIP() (flexibleIP, error) {
+	var allocated struct{ IP flexibleIP }
+	err := prvdr.Do("POST", "/ips", map[string]interface{}{
+		"project": prvdr.config.ProjectID,
+		"tags":    []string{prvdr.namespaceTag()},
+	}, &allocated)
+	if err != nil {
+		return flexibleIP{}, fmt.Errorf("allocate flexible IP: %s", err)
+	}
+	return allocated.IP, nil
+}
+
+// attachIP attaches the flexible IP with `ipID` to the server with `serverID`, or
+// detaches it if `serverID` is nil.
+func (prvdr Provider) attachIP(ipID string, serverID *string) error {
+	err := prvdr.Do("PATCH", "/ips/"+ipID,
+		map[string]*string{"server": serverID}, nil)
+	if err != nil {
+		return fmt.Errorf("update flexible IP %s: %s", ipID, err)
+	}
+	return nil
+}
+
+func (prvdr Provider) releaseIP(id string) error {
+	err := prvdr.Do("DELETE", "/ips/"+id, nil, nil)
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("release flexible IP %s: %s", id, err)
+	}
+	return nil
+}
+
+// Capabilities returns the optional features that Scaleway supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{
+		FloatingIPs: true, ResizableDisks: true,
+	}
+}
+
+// UpdateFloatingIPs attaches servers to the flexible IPs listed as their floating
+// IP.  Servers without a floating IP are attached to a newly allocated flexible IP
+// instead, so that they can still reach the internet.
+func (prvdr Provider) UpdateFloatingIPs(desired []db.Machine) error {
+	curr, err := prvdr.List()
+	if err != nil {
+		return fmt.Errorf("list machines: %s", err)
+	}
+
+	idKey := func(intf interface{}) interface{} {
+		return intf.(db.Machine).CloudID
+	}
+	pairs, _, unmatchedDesired := join.HashJoin(
+		db.MachineSlice(curr), db.MachineSlice(desired), idKey, idKey)
+
+	if len(unmatchedDesired) != 0 {
+		var unmatchedIDs []string
+		for _, m := range unmatchedDesired {
+			unmatchedIDs = append(unmatchedIDs, m.(db.Machine).CloudID)
+		}
+		return fmt.Errorf("no matching IDs: %s", strings.Join(unmatchedIDs, ", "))
+	}
+
+	for _, pair := range pairs {
+		curr := pair.L.(db.Machine)
+		desired := pair.R.(db.Machine)
+		if curr.FloatingIP == desired.FloatingIP {
+			continue
+		}
+
+		if err := prvdr.updateFloatingIP(curr.CloudID,
+			desired.FloatingIP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (prvdr Provider) updateFloatingIP(serverID, floatingIP string) error {
+	var ip flexibleIP
+	if floatingIP == "" {
+		var err error
+		if ip, err = prvdr.allocateIP(); err != nil {
+			return err
+		}
+	} else {
+		ips, err := prvdr.ips("")
+		if err != nil {
+			return err
+		}
+
+		for _, candidate := range ips {
+			if candidate.Address == floatingIP {
+				ip = candidate
+			}
+		}
+
+		if ip.ID == "" {
+			return fmt.Errorf("no flexible IP %s in %s", floatingIP,
+				prvdr.zone)
+		}
+	}
+
+	srv, ok, err := prvdr.server(serverID)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no server %s", serverID)
+	}
+
+	// The server's current IP is detached, and released if it was allocated
+	// for the server.
+	if srv.PublicIP != nil {
+		if err := prvdr.attachIP(srv.PublicIP.ID, nil); err != nil {
+			return err
+		}
+
+		nsIPs, err := prvdr.namespaceIPs()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := nsIPs[srv.PublicIP.ID]; ok {
+			if err := prvdr.releaseIP(srv.PublicIP.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return prvdr.attachIP(ip.ID, &serverID)
+}
+
+// Resources lists the servers in the namespace, the flexible IPs attached to them
+// or allocated for the namespace, and the namespace's security groups.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	servers, err := prvdr.namespaceServers()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	serverIDs := map[string]bool{}
+	for _, srv := range servers {
+		serverIDs[srv.ID] = true
+
+		tags := map[string]string{}
+		for _, tag := range srv.Tags {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) == 2 {
+				tags[kv[0]] = kv[1]
+			} else {
+				tags[tag] = ""
+			}
+		}
+
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      srv.ID,
+			Name:    srv.Name,
+			Tags:    tags,
+			Created: srv.CreationDate,
+		})
+	}
+
+	ips, err := prvdr.ips("")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		attached := ip.Server != nil && serverIDs[ip.Server.ID]
+		if attached || hasTag(ip.Tags, prvdr.namespaceTag()) {
+			resources = append(resources, resource.Resource{
+				Type: resource.IP,
+				ID:   ip.ID,
+				Name: ip.Address,
+			})
+		}
+	}
+
+	groups, err := prvdr.securityGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range groups {
+		resources = append(resources, resource.Resource{
+			Type: resource.SecurityGroup,
+			ID:   id,
+			Name: prvdr.groupName(),
+		})
+	}
+	return resources, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (prvdr Provider) groupName() string {
+	return "quilt-" + prvdr.namespace
+}
+
+// securityGroups returns the IDs of the security groups named after the namespace.
+func (prvdr Provider) securityGroups() ([]string, error) {
+	var resp struct {
+		SecurityGroups []struct{ ID, Name string } `json:"security_groups"`
+	}
+	path := fmt.Sprintf("/security_groups?project=%s&name=%s",
+		url.QueryEscape(prvdr.config.ProjectID),
+		url.QueryEscape(prvdr.groupName()))
+	if err := prvdr.Do("GET", path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("list security groups: %s", err)
+	}
+
+	// The name filter matches substrings, so other namespaces' groups may be
+	// listed too.
+	var ids []string
+	for _, group := range resp.SecurityGroups {
+		if group.Name == prvdr.groupName() {
+			ids = append(ids, group.ID)
+		}
+	}
+	return ids, nil
+}
+
+// securityGroup returns the ID of the namespace's security group, creating it if
+// it doesn't exist.  New groups drop inbound traffic unless it's allowed by a
+// rule.
+func (prvdr Provider) securityGroup() (string, error) {
+	ids, err := prvdr.securityGroups()
+	if err != nil {
+		return "", err
+	}
+
+	if len(ids) > 0 {
+		return ids[0], nil
+	}
+
+	var created struct {
+		SecurityGroup struct{ ID string } `json:"security_group"`
+	}
+	err = prvdr.Do("POST", "/security_groups", map[string]interface{}{
+		"name":                    prvdr.groupName(),
+		"project":                 prvdr.config.ProjectID,
+		"description":             "Quilt namespace " + prvdr.namespace,
+		"stateful":                true,
+		"inbound_default_policy":  "drop",
+		"outbound_default_policy": "accept",
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+	return created.SecurityGroup.ID, nil
+}
+
+// A securityRule is an inbound rule of a security group that accepts traffic.
+// Rules without ports apply to every port.
+type securityRule struct {
+	ID           string `json:"id,omitempty"`
+	Protocol     string `json:"protocol"`
+	Direction    string `json:"direction"`
+	Action       string `json:"action"`
+	IPRange      string `json:"ip_range"`
+	DestPortFrom int    `json:"dest_port_from,omitempty"`
+	DestPortTo   int    `json:"dest_port_to,omitempty"`
+	Editable     bool   `json:"editable,omitempty"`
+}
+
+// SetACLs adds and removes the rules of the namespace's security group, so that
+// it allows the given TCP, UDP, and ICMP traffic.  Scaleway's rules can't refer to
+// security groups, so the traffic between the servers of the namespace is allowed
+// by rules for each of their IPs.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	groupID, err := prvdr.securityGroup()
+	if err != nil {
+		return err
+	}
+
+	servers, err := prvdr.namespaceServers()
+	if err != nil {
+		return err
+	}
+
+	rulesPath := "/security_groups/" + groupID + "/rules"
+	var currRules []securityRule
+	for page := 1; ; page++ {
+		var resp struct{ Rules []securityRule }
+		path := fmt.Sprintf("%s?per_page=%d&page=%d", rulesPath, perPage, page)
+		if err := prvdr.Do("GET", path, nil, &resp); err != nil {
+			return fmt.Errorf("list security group rules: %s", err)
+		}
+
+		// The default rules of the group aren't editable, and so aren't
+		// managed by ACLs.
+		for _, rule := range resp.Rules {
+			if rule.Editable && rule.Direction == "inbound" &&
+				rule.Action == "accept" {
+				currRules = append(currRules, rule)
+			}
+		}
+
+		if len(resp.Rules) < perPage {
+			break
+		}
+	}
+
+	var desired []securityRule
+	for _, a := range acls {
+		desired = append(desired,
+			newRule("TCP", a.CidrIP, a.MinPort, a.MaxPort),
+			newRule("UDP", a.CidrIP, a.MinPort, a.MaxPort),
+			newRule("ICMP", a.CidrIP, 0, 0))
+	}
+
+	for _, srv := range servers {
+		if srv.PrivateIP != "" {
+			desired = append(desired, newRule("ANY", srv.PrivateIP+"/32", 0, 0))
+		}
+		if srv.PublicIP != nil {
+			desired = append(desired,
+				newRule("ANY", srv.PublicIP.Address+"/32", 0, 0))
+		}
+	}
+
+	// Rules for a single port may be listed without an end port.
+	ruleKey := func(intf interface{}) interface{} {
+		rule := intf.(securityRule)
+		if rule.DestPortTo == 0 {
+			rule.DestPortTo = rule.DestPortFrom
+		}
+		return [4]interface{}{rule.Protocol, rule.IPRange, rule.DestPortFrom,
+			rule.DestPortTo}
+	}
+	_, toAdd, toRemove := join.HashJoin(securityRuleSlice(desired),
+		securityRuleSlice(currRules), ruleKey, ruleKey)
+
+	for _, intf := range toRemove {
+		rule := intf.(securityRule)
+		err := prvdr.Do("DELETE", rulesPath+"/"+rule.ID, nil, nil)
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("delete security group rule: %s", err)
+		}
+	}
+
+	seen := map[interface{}]bool{}
+	for _, intf := range toAdd {
+		// Servers may have the same IP as an ACL, or each other.
+		if key := ruleKey(intf); !seen[key] {
+			seen[key] = true
+		} else {
+			continue
+		}
+
+		rule := intf.(securityRule)
+		log.WithField("rule", rule).Debug("Scaleway: Adding ACL")
+		if err := prvdr.Do("POST", rulesPath, rule, nil); err != nil {
+			return fmt.Errorf("create security group rule: %s", err)
+		}
+	}
+	return nil
+}
+
+func newRule(protocol, ipRange string, minPort, maxPort int) securityRule {
+	rule := securityRule{
+		Protocol:     protocol,
+		Direction:    "inbound",
+		Action:       "accept",
+		IPRange:      ipRange,
+		DestPortFrom: minPort,
+	}
+
+	if maxPort != minPort {
+		rule.DestPortTo = maxPort
+	}
+	return rule
+}
+
+type securityRuleSlice []securityRule
+
+func (slc securityRuleSlice) Get(ii int) interface{} {
+	return slc[ii]
+}
+
+func (slc securityRuleSlice) Len() int {
+	return len(slc)
+}
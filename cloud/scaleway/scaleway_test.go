@@ -0,0 +1,443 @@
+package scaleway
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/cloudtest"
+	"github.com/kelda/kelda/cloud/creds"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/cloud/scaleway/client"
+	"github.com/kelda/kelda/cloud/scaleway/client/mocks"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+const (
+	serversPath = "/servers?tags=quilt-namespace%3Dns&per_page=100&page=1"
+	ipsPath     = "/ips?project=project&per_page=100&page=1"
+	nsIPsPath   = ipsPath + "&tags=quilt-namespace%3Dns"
+	groupsPath  = "/security_groups?project=project&name=quilt-ns"
+)
+
+var notFound = client.Error{StatusCode: 404, Type: "not_found"}
+
+func newTestProvider() (Provider, *mocks.Client) {
+	mc := new(mocks.Client)
+	return Provider{
+		Client:    mc,
+		namespace: "ns",
+		zone:      DefaultRegion,
+		config:    config{ProjectID: "project", Image: "ubuntu"},
+	}, mc
+}
+
+func serversReply(servers ...map[string]interface{}) func(mock.Arguments) {
+	return cloudtest.Reply(map[string]interface{}{"servers": servers})
+}
+
+func ipsReply(ips ...map[string]interface{}) func(mock.Arguments) {
+	return cloudtest.Reply(map[string]interface{}{"ips": ips})
+}
+
+func serverReply(srv map[string]interface{}) func(mock.Arguments) {
+	return cloudtest.Reply(map[string]interface{}{"server": srv})
+}
+
+func TestList(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	mc.On("Do", "GET", serversPath, nil, mock.Anything).Return(nil).Run(
+		serversReply(
+			map[string]interface{}{"id": "srv-1", "commercial_type": "DEV1-S",
+				"private_ip": "10.1.0.1", "public_ip": map[string]string{
+					"id": "ip-1", "address": "51.15.0.1"}},
+			map[string]interface{}{"id": "srv-2", "commercial_type": "DEV1-M",
+				"private_ip": "10.1.0.2", "public_ip": map[string]string{
+					"id": "reserved", "address": "1.2.3.4"}},
+			map[string]interface{}{"id": "srv-3", "commercial_type": "DEV1-L"}))
+
+	// A flexible IP is only a floating IP if it wasn't allocated for the
+	// server it's attached to.
+	mc.On("Do", "GET", nsIPsPath, nil, mock.Anything).Return(nil).Run(ipsReply(
+		map[string]interface{}{"id": "ip-1", "address": "51.15.0.1"}))
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{
+		{CloudID: "srv-1", Size: "DEV1-S", PrivateIP: "10.1.0.1",
+			PublicIP: "51.15.0.1"},
+		{CloudID: "srv-2", Size: "DEV1-M", PrivateIP: "10.1.0.2",
+			PublicIP: "1.2.3.4", FloatingIP: "1.2.3.4"},
+		{CloudID: "srv-3", Size: "DEV1-L"},
+	}, machines)
+
+	prvdr, mc = newTestProvider()
+	mc.On("Do", "GET", serversPath, nil, mock.Anything).Return(
+		errors.New("unauthorized"))
+	_, err = prvdr.List()
+	assert.EqualError(t, err, "list servers: unauthorized")
+}
+
+func TestBoot(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	// The name filter matches substrings, so the group of namespace "ns2" is
+	// listed, but a group is still created for "ns".
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"security_groups": []map[string]string{
+			{"id": "sg-other", "name": "quilt-ns2"}}}))
+	mc.On("Do", "POST", "/security_groups", map[string]interface{}{
+		"name":                    "quilt-ns",
+		"project":                 "project",
+		"description":             "Quilt namespace ns",
+		"stateful":                true,
+		"inbound_default_policy":  "drop",
+		"outbound_default_policy": "accept",
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"security_group": map[string]string{"id": "sg"}})).Once()
+
+	mc.On("Do", "POST", "/ips", map[string]interface{}{
+		"project": "project",
+		"tags":    []string{"quilt-namespace=ns"},
+	}, mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"ip": map[string]string{"id": "ip-1", "address": "51.15.0.1"}})).Once()
+
+	var req map[string]interface{}
+	mc.On("Do", "POST", "/servers", mock.Anything, mock.Anything).Return(
+		nil).Run(func(args mock.Arguments) {
+		req = cloudtest.Body(args.Get(2))
+		serverReply(map[string]interface{}{"id": "srv-1"})(args)
+	}).Once()
+	var userData interface{}
+	mc.On("Do", "PATCH", "/servers/srv-1/user_data/cloud-init",
+		mock.Anything, nil).Return(nil).Run(func(args mock.Arguments) {
+		userData = args.Get(2)
+	}).Once()
+	mc.On("Do", "POST", "/servers/srv-1/action",
+		map[string]string{"action": "poweron"}, nil).Return(nil).Once()
+	mc.On("Do", "GET", "/servers/srv-1", nil, mock.Anything).Return(nil).Run(
+		serverReply(map[string]interface{}{"id": "srv-1", "state": "running"}))
+
+	err := prvdr.Boot([]db.Machine{{Size: "DEV1-S", Hostname: "worker-1",
+		DiskSize: 40}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	// User data is sent as plain text.
+	assert.IsType(t, "", userData)
+	assert.NotEmpty(t, userData)
+
+	// The server boots with the allocated flexible IP, rather than a dynamic
+	// one, and its disk size is given in bytes.
+	assert.Equal(t, map[string]interface{}{
+		"name":                "worker-1",
+		"commercial_type":     "DEV1-S",
+		"image":               "ubuntu",
+		"project":             "project",
+		"tags":                []interface{}{"quilt-namespace=ns"},
+		"security_group":      "sg",
+		"dynamic_ip_required": false,
+		"public_ip":           "ip-1",
+		"volumes": map[string]interface{}{"0": map[string]interface{}{
+			"size": float64(40000000000), "volume_type": "l_ssd"}},
+	}, req)
+
+	err = prvdr.Boot([]db.Machine{{Size: "DEV1-S", Preemptible: true}})
+	assert.EqualError(t, err, "preemptible instances are not yet implemented")
+}
+
+func TestBootErrors(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"security_groups": []map[string]string{
+			{"id": "sg", "name": "quilt-ns"}}}))
+	mc.On("Do", "POST", "/ips", mock.Anything, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"ip": map[string]string{
+			"id": "ip-1"}}))
+
+	// If the server can't be created, its flexible IP is released.
+	mc.On("Do", "POST", "/servers", mock.Anything, mock.Anything).Return(
+		client.Error{StatusCode: 400, Type: "invalid_arguments",
+			Message: "unknown commercial type"}).Once()
+	mc.On("Do", "DELETE", "/ips/ip-1", nil, nil).Return(nil).Once()
+
+	err := prvdr.Boot([]db.Machine{{Size: "HUGE"}})
+	assert.EqualError(t, err, "create server: "+
+		"invalid_arguments: unknown commercial type")
+	mc.AssertExpectations(t)
+
+	// If it can't be set up, it's deleted along with its volumes, since it was
+	// never started.
+	mc.On("Do", "POST", "/servers", mock.Anything, mock.Anything).Return(
+		nil).Run(serverReply(map[string]interface{}{"id": "srv-1"})).Once()
+	mc.On("Do", "PATCH", "/servers/srv-1/user_data/cloud-init", mock.Anything,
+		nil).Return(errors.New("too large")).Once()
+	mc.On("Do", "GET", "/servers/srv-1", nil, mock.Anything).Return(nil).Run(
+		serverReply(map[string]interface{}{
+			"id":        "srv-1",
+			"state":     "stopped",
+			"public_ip": map[string]string{"id": "ip-1"},
+			"volumes":   map[string]interface{}{"0": map[string]string{"id": "vol-1"}},
+		})).Once()
+	mc.On("Do", "DELETE", "/servers/srv-1", nil, nil).Return(nil).Once()
+	mc.On("Do", "DELETE", "/volumes/vol-1", nil, nil).Return(nil).Once()
+	mc.On("Do", "GET", "/servers/srv-1", nil, mock.Anything).Return(notFound)
+	mc.On("Do", "GET", nsIPsPath, nil, mock.Anything).Return(nil).Run(ipsReply(
+		map[string]interface{}{"id": "ip-1"}))
+	mc.On("Do", "DELETE", "/ips/ip-1", nil, nil).Return(nil).Once()
+
+	err = prvdr.Boot([]db.Machine{{Size: "DEV1-S"}})
+	assert.EqualError(t, err, "set user data of server srv-1: too large")
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "Do", "POST", "/servers/srv-1/action", mock.Anything,
+		mock.Anything)
+}
+
+func TestStop(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	// Running servers are terminated, which deletes their volumes.  The
+	// server's flexible IP is one of the blueprint's floating IPs, so it's
+	// kept.
+	mc.On("Do", "GET", "/servers/srv-1", nil, mock.Anything).Return(nil).Run(
+		serverReply(map[string]interface{}{"id": "srv-1", "state": "running",
+			"public_ip": map[string]string{"id": "reserved"}})).Once()
+	mc.On("Do", "POST", "/servers/srv-1/action",
+		map[string]string{"action": "terminate"}, nil).Return(nil).Once()
+	mc.On("Do", "GET", "/servers/srv-1", nil, mock.Anything).Return(notFound)
+	mc.On("Do", "GET", nsIPsPath, nil, mock.Anything).Return(nil).Run(ipsReply(
+		map[string]interface{}{"id": "ip-1"}))
+
+	// Servers that no longer exist are already stopped.
+	mc.On("Do", "GET", "/servers/srv-2", nil, mock.Anything).Return(notFound)
+
+	err := prvdr.Stop([]db.Machine{{CloudID: "srv-1"}, {CloudID: "srv-2"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+	mc.AssertNotCalled(t, "Do", "DELETE", mock.Anything, mock.Anything,
+		mock.Anything)
+}
+
+func TestReboot(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	mc.On("Do", "POST", "/servers/srv-1/action",
+		map[string]string{"action": "reboot"}, nil).Return(nil).Once()
+	assert.NoError(t, prvdr.Reboot([]db.Machine{{CloudID: "srv-1"}}))
+
+	mc.On("Do", "POST", "/servers/srv-2/action",
+		map[string]string{"action": "reboot"}, nil).Return(notFound)
+	err := prvdr.Reboot([]db.Machine{{CloudID: "srv-2"}})
+	assert.EqualError(t, err, "reboot server srv-2: not_found: ")
+	mc.AssertExpectations(t)
+}
+
+func TestResources(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	mc.On("Do", "GET", serversPath, nil, mock.Anything).Return(nil).Run(
+		serversReply(map[string]interface{}{
+			"id":            "srv-1",
+			"name":          "worker-1",
+			"tags":          []string{"quilt-namespace=ns", "pinned"},
+			"creation_date": "2017-10-13T17:00:00Z",
+		}))
+
+	// Flexible IPs that were allocated for the namespace are reported even if
+	// they aren't attached, but unrelated flexible IPs aren't.
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(ipsReply(
+		map[string]interface{}{"id": "reserved", "address": "1.2.3.4",
+			"server": map[string]string{"id": "srv-1"}},
+		map[string]interface{}{"id": "leaked", "address": "5.6.7.8",
+			"tags": []string{"quilt-namespace=ns"}},
+		map[string]interface{}{"id": "other", "address": "9.9.9.9",
+			"server": map[string]string{"id": "srv-other"}}))
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"security_groups": []map[string]string{
+			{"id": "sg", "name": "quilt-ns"},
+			{"id": "sg-other", "name": "quilt-ns2"}}}))
+
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{
+		{
+			Type:    resource.Instance,
+			ID:      "srv-1",
+			Name:    "worker-1",
+			Tags:    map[string]string{"quilt-namespace": "ns", "pinned": ""},
+			Created: time.Date(2017, 10, 13, 17, 0, 0, 0, time.UTC),
+		},
+		{Type: resource.IP, ID: "reserved", Name: "1.2.3.4"},
+		{Type: resource.IP, ID: "leaked", Name: "5.6.7.8"},
+		{Type: resource.SecurityGroup, ID: "sg", Name: "quilt-ns"},
+	}, resources)
+}
+
+func TestImage(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	id, err := prvdr.image()
+	assert.NoError(t, err)
+	assert.Equal(t, "ubuntu", id)
+
+	// The newest image with exactly the default name is used.
+	prvdr.config.Image = ""
+	mc.On("Do", "GET", "/images?public=true&arch=x86_64&name=Ubuntu+Xenial"+
+		"&per_page=100", nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"images": []map[string]string{
+			{"id": "old", "name": defaultImageName,
+				"creation_date": "2017-01-01T00:00:00Z"},
+			{"id": "new", "name": defaultImageName,
+				"creation_date": "2018-01-01T00:00:00Z"},
+			{"id": "other", "name": defaultImageName + " GPU",
+				"creation_date": "2019-01-01T00:00:00Z"},
+		}})).Once()
+	id, err = prvdr.image()
+	assert.NoError(t, err)
+	assert.Equal(t, "new", id)
+
+	mc.On("Do", "GET", mock.Anything, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{}))
+	_, err = prvdr.image()
+	assert.EqualError(t, err, "no Ubuntu Xenial image in fr-par-1. "+
+		"Configure one in ~/.scaleway/quilt.json")
+}
+
+func TestUpdateFloatingIPs(t *testing.T) {
+	prvdr, mc := newTestProvider()
+
+	srv := map[string]interface{}{"id": "srv-1", "public_ip": map[string]string{
+		"id": "ip-1", "address": "51.15.0.1"}}
+	mc.On("Do", "GET", serversPath, nil, mock.Anything).Return(nil).Run(
+		serversReply(srv))
+	mc.On("Do", "GET", "/servers/srv-1", nil, mock.Anything).Return(nil).Run(
+		serverReply(srv))
+	mc.On("Do", "GET", nsIPsPath, nil, mock.Anything).Return(nil).Run(ipsReply(
+		map[string]interface{}{"id": "ip-1", "address": "51.15.0.1"}))
+	mc.On("Do", "GET", ipsPath, nil, mock.Anything).Return(nil).Run(ipsReply(
+		map[string]interface{}{"id": "ip-1", "address": "51.15.0.1"},
+		map[string]interface{}{"id": "reserved", "address": "1.2.3.4"}))
+
+	err := prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "missing"}})
+	assert.EqualError(t, err, "no matching IDs: missing")
+
+	// The allocated flexible IP is detached and released, and replaced by the
+	// floating IP.
+	serverID := "srv-1"
+	mc.On("Do", "PATCH", "/ips/ip-1", map[string]*string{"server": nil},
+		nil).Return(nil).Once()
+	mc.On("Do", "DELETE", "/ips/ip-1", nil, nil).Return(nil).Once()
+	mc.On("Do", "PATCH", "/ips/reserved", map[string]*string{
+		"server": &serverID}, nil).Return(nil).Once()
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "srv-1",
+		FloatingIP: "1.2.3.4"}})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	var actions []string
+	for _, call := range mc.Calls {
+		if method := call.Arguments.String(0); method != "GET" {
+			actions = append(actions, method+" "+call.Arguments.String(1))
+		}
+	}
+	assert.Equal(t, []string{"PATCH /ips/ip-1", "DELETE /ips/ip-1",
+		"PATCH /ips/reserved"}, actions)
+
+	err = prvdr.UpdateFloatingIPs([]db.Machine{{CloudID: "srv-1",
+		FloatingIP: "5.6.7.8"}})
+	assert.EqualError(t, err, "no flexible IP 5.6.7.8 in fr-par-1")
+}
+
+func TestSetACLs(t *testing.T) {
+	prvdr, mc := newTestProvider()
+	rulesPath := "/security_groups/sg/rules"
+
+	mc.On("Do", "GET", groupsPath, nil, mock.Anything).Return(nil).Run(
+		cloudtest.Reply(map[string]interface{}{"security_groups": []map[string]string{
+			{"id": "sg", "name": "quilt-ns"}}}))
+	mc.On("Do", "GET", serversPath, nil, mock.Anything).Return(nil).Run(
+		serversReply(map[string]interface{}{"id": "srv-1",
+			"private_ip": "10.1.0.1",
+			"public_ip":  map[string]string{"address": "51.15.0.1"}}))
+
+	// The default rule isn't editable, so it's left alone.  Rules for a
+	// single port may be listed with an end port.
+	mc.On("Do", "GET", rulesPath+"?per_page=100&page=1", nil,
+		mock.Anything).Return(nil).Run(cloudtest.Reply(map[string]interface{}{
+		"rules": []securityRule{
+			{ID: "default", Protocol: "TCP", Direction: "inbound",
+				Action: "accept", IPRange: "0.0.0.0/0", DestPortFrom: 25},
+			{ID: "current", Protocol: "TCP", Direction: "inbound",
+				Action: "accept", IPRange: "1.2.3.4/32", DestPortFrom: 80,
+				DestPortTo: 80, Editable: true},
+			{ID: "stale", Protocol: "TCP", Direction: "inbound",
+				Action: "accept", IPRange: "5.6.7.8/32", DestPortFrom: 22,
+				Editable: true},
+		}}))
+	mc.On("Do", "DELETE", rulesPath+"/stale", nil, nil).Return(nil).Once()
+
+	var added []string
+	mc.On("Do", "POST", rulesPath, mock.Anything, nil).Return(nil).Run(
+		func(args mock.Arguments) {
+			rule := args.Get(2).(securityRule)
+			added = append(added, rule.IPRange+" "+rule.Protocol+" "+
+				rule.Direction+" "+rule.Action)
+		})
+
+	// The duplicate ACL only results in one set of rules.
+	err := prvdr.SetACLs([]acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80},
+		{CidrIP: "1.2.3.4/32", MinPort: 80, MaxPort: 80},
+	})
+	assert.NoError(t, err)
+	mc.AssertExpectations(t)
+
+	sort.Strings(added)
+	assert.Equal(t, []string{
+		"1.2.3.4/32 ICMP inbound accept",
+		"1.2.3.4/32 UDP inbound accept",
+		"10.1.0.1/32 ANY inbound accept",
+		"51.15.0.1/32 ANY inbound accept",
+	}, added)
+}
+
+func TestReadConfig(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	path := filepath.Join(os.Getenv("HOME"), configPath)
+
+	util.WriteFile(path, []byte(`{"secretKey": "secret",
+		"projectId": "project"}`), 0600)
+	conf, err := readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", conf.SecretKey)
+	assert.Equal(t, "project", conf.ProjectID)
+	assert.Empty(t, conf.Image)
+
+	util.WriteFile(path, []byte(`{"secretKey": "secret"}`), 0600)
+	_, err = readConfig()
+	assert.EqualError(t, err, path+" must contain the secretKey of an API key, "+
+		"and a projectId")
+
+	// The environment takes precedence over the config file.
+	os.Setenv(projectIDEnv, "envProject")
+	defer os.Unsetenv(projectIDEnv)
+	conf, err = readConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", conf.SecretKey)
+	assert.Equal(t, "envProject", conf.ProjectID)
+
+	os.Unsetenv(projectIDEnv)
+	util.AppFs = afero.NewMemMapFs()
+	_, err = readConfig()
+	assert.True(t, creds.IsMissing(err))
+}
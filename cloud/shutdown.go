@@ -0,0 +1,92 @@
+package cloud
+
+import (
+	"sync"
+	"time"
+)
+
+// The machines that are being booted, stopped, or updated by providers.  Once
+// the daemon begins shutting down, no new mutations start, and the daemon waits
+// for those in progress so that machines aren't abandoned mid-boot.
+var mutations struct {
+	sync.Mutex
+	wg       sync.WaitGroup
+	count    int
+	stopping bool
+}
+
+// Closed when the daemon begins shutting down, which makes Run close the
+// foreman's connections and send how many there were on foremanClosed.
+var shuttingDown = make(chan struct{})
+var foremanClosed = make(chan int, 1)
+
+// A ShutdownSummary describes what the clouds were doing when the daemon shut
+// down.
+type ShutdownSummary struct {
+	// The number of machines whose provider mutations were in progress.
+	InFlight int
+
+	// The number of machines whose provider mutations didn't finish before
+	// the timeout.
+	Abandoned int
+
+	// The number of minion connections that the foreman closed.
+	Minions int
+}
+
+// startMutation records that `count` machines are about to be mutated.  Returns
+// false if the daemon is shutting down, in which case the mutation shouldn't
+// start.
+func startMutation(count int) bool {
+	mutations.Lock()
+	defer mutations.Unlock()
+
+	if mutations.stopping {
+		return false
+	}
+	mutations.count += count
+	mutations.wg.Add(1)
+	return true
+}
+
+// finishMutation records that a mutation of `count` machines has finished.
+func finishMutation(count int) {
+	mutations.Lock()
+	defer mutations.Unlock()
+	mutations.count -= count
+	mutations.wg.Done()
+}
+
+// Shutdown prevents new provider mutations, and waits up to `timeout` for those
+// in progress to finish and for the foreman to close its minion connections.
+func Shutdown(timeout time.Duration) ShutdownSummary {
+	expired := make(chan struct{})
+	time.AfterFunc(timeout, func() { close(expired) })
+
+	mutations.Lock()
+	mutations.stopping = true
+	summary := ShutdownSummary{InFlight: mutations.count}
+	mutations.Unlock()
+	close(shuttingDown)
+
+	finished := make(chan struct{})
+	go func() {
+		mutations.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-expired:
+	}
+
+	mutations.Lock()
+	summary.Abandoned = mutations.count
+	mutations.Unlock()
+
+	select {
+	case summary.Minions = <-foremanClosed:
+	case <-expired:
+	}
+	return summary
+}
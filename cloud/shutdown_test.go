@@ -0,0 +1,49 @@
+package cloud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	defer resetShutdown()
+
+	assert.True(t, startMutation(2))
+	assert.True(t, startMutation(1))
+	finishMutation(1)
+
+	summaryChan := make(chan ShutdownSummary)
+	go func() { summaryChan <- Shutdown(time.Minute) }()
+
+	// New mutations don't start once the daemon is shutting down.
+	<-shuttingDown
+	assert.False(t, startMutation(1))
+
+	// Shutdown waits for the mutations in progress, and for the foreman.
+	foremanClosed <- 3
+	select {
+	case <-summaryChan:
+		t.Fatal("Shutdown returned before the mutation finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	finishMutation(2)
+	assert.Equal(t, ShutdownSummary{InFlight: 2, Minions: 3}, <-summaryChan)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	defer resetShutdown()
+
+	assert.True(t, startMutation(4))
+	assert.Equal(t, ShutdownSummary{InFlight: 4, Abandoned: 4},
+		Shutdown(10*time.Millisecond))
+	finishMutation(4)
+}
+
+func resetShutdown() {
+	mutations.stopping = false
+	shuttingDown = make(chan struct{})
+	foremanClosed = make(chan int, 1)
+}
@@ -0,0 +1,356 @@
+package static
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	cliPath "github.com/kelda/kelda/cli/path"
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
+	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/foreman"
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultSSHUser is the user that the daemon logs in to hosts as, if the
+// blueprint doesn't specify one.
+const DefaultSSHUser = "root"
+
+// The directory, relative to the home directory, where the hosts that were
+// bootstrapped for each namespace are recorded.
+var stateDir = filepath.Join(".quilt", "static")
+
+// The boot script is uploaded to bootScriptPath and run with sudo.  It creates
+// the same marker as machines that the cloud bootstraps over SSH, so that the
+// cloud doesn't rerun it.
+const (
+	bootScriptPath = "/tmp/quilt-boot.sh"
+	bootMarkerPath = "/var/lib/quilt-bootstrapped"
+)
+
+var bootstrapCommand = fmt.Sprintf("if [ ! -e %[2]s ]; then "+
+	"cat > %[1]s && sudo bash %[1]s < /dev/null && sudo touch %[2]s; fi",
+	bootScriptPath, bootMarkerPath)
+
+// teardownCommand stops the minion and removes the containers that it started,
+// along with the boot marker so that the host may be bootstrapped again.
+// Docker and the OVS kernel modules are left installed.
+const teardownCommand = "sudo systemctl disable --now minion.service ovs.service; " +
+	"sudo docker rm -f minion; " +
+	"sudo docker ps -aq --filter network=quilt | xargs -r sudo docker rm -f; " +
+	"sudo rm -rf /etc/systemd/system/minion.service " +
+	"/etc/systemd/system/ovs.service /var/lib/quilt " + bootMarkerPath
+
+// The reboot is delayed so that the SSH session exits cleanly.
+const rebootCommand = "sudo nohup sh -c 'sleep 1 && reboot' > /dev/null 2>&1 &"
+
+// The Provider object manages pre-existing hosts over SSH.  There's no API that
+// lists the hosts, so the hosts that were bootstrapped are recorded in a state
+// file on the daemon's machine.
+type Provider struct {
+	namespace string
+}
+
+// A host that the minion was bootstrapped on, as recorded in the state file.
+type host struct {
+	Host       string
+	SSHUser    string
+	SSHKeyPath string
+	Size       string
+	Booted     time.Time
+}
+
+// Guards reads and modifications of the state files.
+var stateLock sync.Mutex
+
+var c = counter.New("Static")
+
+// New creates a new static provider.
+func New(namespace string) (*Provider, error) {
+	prvdr := Provider{namespace}
+	_, err := prvdr.hosts()
+	return &prvdr, err
+}
+
+// List reports the hosts that were bootstrapped for the namespace.
+func (prvdr Provider) List() ([]db.Machine, error) {
+	hosts, err := prvdr.hosts()
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []db.Machine
+	for _, h := range hosts {
+		machines = append(machines, db.Machine{
+			CloudID:    h.Host,
+			PublicIP:   h.Host,
+			PrivateIP:  h.Host,
+			Size:       h.Size,
+			Host:       h.Host,
+			SSHUser:    h.SSHUser,
+			SSHKeyPath: h.SSHKeyPath,
+		})
+	}
+	return machines, nil
+}
+
+// Boot runs the minion's boot script on each host over SSH, and records the
+// hosts that were bootstrapped.
+func (prvdr Provider) Boot(bootSet []db.Machine) error {
+	for _, m := range bootSet {
+		if m.Preemptible {
+			return errors.New("static hosts can't be preemptible")
+		}
+		if net.ParseIP(m.Host) == nil {
+			return fmt.Errorf("static machine has invalid host %q", m.Host)
+		}
+	}
+
+	errChan := make(chan error, len(bootSet))
+	for _, m := range bootSet {
+		go func(m db.Machine) {
+			errChan <- prvdr.bootHost(m)
+		}(m)
+	}
+
+	var err error
+	for range bootSet {
+		if e := <-errChan; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (prvdr Provider) bootHost(m db.Machine) error {
+	h := host{
+		Host:       m.Host,
+		SSHUser:    m.SSHUser,
+		SSHKeyPath: m.SSHKeyPath,
+		Size:       m.Size,
+		Booted:     now(),
+	}
+
+	c.Inc("Boot")
+	output, err := runSSH(h, bootstrapCommand, cfg.Ubuntu(m, ""))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"host":   m.Host,
+			"output": output,
+		}).Debug("Boot script failed")
+		return fmt.Errorf("bootstrap %s: %s", m.Host, err)
+	}
+
+	return prvdr.updateHosts(func(hosts map[string]host) {
+		hosts[h.Host] = h
+	})
+}
+
+// Stop tears down the minion on each host, and forgets the host.
+func (prvdr Provider) Stop(machines []db.Machine) error {
+	hosts, err := prvdr.hosts()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range machines {
+		h, ok := hosts[m.CloudID]
+		if !ok {
+			continue
+		}
+
+		c.Inc("Stop")
+		output, err := runSSH(h, teardownCommand, "")
+		if err != nil {
+			log.WithFields(log.Fields{
+				"host":   h.Host,
+				"output": output,
+			}).Debug("Teardown failed")
+			return fmt.Errorf("tear down %s: %s", h.Host, err)
+		}
+
+		err = prvdr.updateHosts(func(hosts map[string]host) {
+			delete(hosts, h.Host)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reboot reboots each host.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	hosts, err := prvdr.hosts()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range machines {
+		h, ok := hosts[m.CloudID]
+		if !ok {
+			return fmt.Errorf("unknown host %s", m.CloudID)
+		}
+
+		c.Inc("Reboot")
+		if _, err := runSSH(h, rebootCommand, ""); err != nil {
+			return fmt.Errorf("reboot %s: %s", h.Host, err)
+		}
+	}
+	return nil
+}
+
+// Resources lists the hosts that were bootstrapped for the namespace, along with
+// when they were bootstrapped.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	hosts, err := prvdr.hosts()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	for _, h := range hosts {
+		resources = append(resources, resource.Resource{
+			Type:    resource.Instance,
+			ID:      h.Host,
+			Created: h.Booted,
+		})
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].ID < resources[j].ID
+	})
+	return resources, nil
+}
+
+// SetACLs is a noop, as hosts don't have a firewall that the provider controls.
+// The host-firewall setting enforces the ACLs on the hosts instead.
+func (prvdr Provider) SetACLs(acls []acl.ACL) error {
+	return nil
+}
+
+// Capabilities returns the optional features that static hosts supports.
+func (prvdr Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{}
+}
+
+// UpdateFloatingIPs is not supported.
+func (prvdr Provider) UpdateFloatingIPs([]db.Machine) error {
+	return errors.New("static provider does not support floating IPs")
+}
+
+func (prvdr Provider) statePath() string {
+	return filepath.Join(os.Getenv("HOME"), stateDir, prvdr.namespace+".json")
+}
+
+// hosts reads the state file, keyed by the hosts' addresses.
+func (prvdr Provider) hosts() (map[string]host, error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	return prvdr.readHosts()
+}
+
+func (prvdr Provider) readHosts() (map[string]host, error) {
+	hosts := map[string]host{}
+	path := prvdr.statePath()
+	if exists, err := util.FileExists(path); err != nil || !exists {
+		return hosts, err
+	}
+
+	state, err := util.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(state), &hosts); err != nil {
+		return nil, fmt.Errorf("parse %s: %s", path, err)
+	}
+	return hosts, nil
+}
+
+// updateHosts applies `update` to the hosts in the state file.
+func (prvdr Provider) updateHosts(update func(map[string]host)) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	hosts, err := prvdr.readHosts()
+	if err != nil {
+		return err
+	}
+	update(hosts)
+
+	state, err := json.MarshalIndent(hosts, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	path := prvdr.statePath()
+	if err := util.AppFs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return util.WriteFile(path, state, 0600)
+}
+
+// runSSHImpl runs `cmd` on the host with `stdin` as its input, and returns its
+// combined output.
+func runSSHImpl(h host, cmd, stdin string) (string, error) {
+	keyPath := h.SSHKeyPath
+	if keyPath == "" {
+		keyPath = cliPath.DefaultSSHKeyPath
+	}
+
+	key, err := util.ReadFile(keyPath)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	if err != nil {
+		return "", fmt.Errorf("parse %s: %s", keyPath, err)
+	}
+
+	user := h.SSHUser
+	if user == "" {
+		user = DefaultSSHUser
+	}
+
+	// Hosts are reached through the daemon's proxy, if it has one, just like
+	// the machines of the other providers.
+	client, err := foreman.DialSSH(h.Host, user, signer, 10*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("session: %s", err)
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdin = strings.NewReader(stdin)
+	session.Stdout = &output
+	session.Stderr = &output
+	err = session.Run(cmd)
+	return output.String(), err
+}
+
+// Allow mocking out for the unit tests.
+var runSSH = runSSHImpl
+var now = time.Now
@@ -0,0 +1,145 @@
+package static
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/resource"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+type sshCall struct {
+	host, user, cmd string
+}
+
+// mockSSH records the commands run over SSH, and fails those run on `broken`.
+func mockSSH(broken string) *[]sshCall {
+	util.AppFs = afero.NewMemMapFs()
+	now = func() time.Time { return time.Unix(1500000000, 0).UTC() }
+
+	var calls []sshCall
+	runSSH = func(h host, cmd, stdin string) (string, error) {
+		calls = append(calls, sshCall{h.Host, h.SSHUser, cmd})
+		if h.Host == broken {
+			return "output", errors.New("unreachable")
+		}
+		return "", nil
+	}
+	return &calls
+}
+
+func TestBootListStop(t *testing.T) {
+	calls := mockSSH("")
+
+	prvdr, err := New("ns")
+	assert.NoError(t, err)
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+
+	err = prvdr.Boot([]db.Machine{
+		{Host: "10.0.0.1", SSHUser: "ubuntu", SSHKeyPath: "/key", Size: "static",
+			Role: db.Master}})
+	assert.NoError(t, err)
+	assert.Len(t, *calls, 1)
+	assert.Equal(t, "ubuntu", (*calls)[0].user)
+	assert.Equal(t, bootstrapCommand, (*calls)[0].cmd)
+
+	// The hosts are recorded, so they're listed by new providers.
+	prvdr, err = New("ns")
+	assert.NoError(t, err)
+
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []db.Machine{{
+		CloudID:    "10.0.0.1",
+		PublicIP:   "10.0.0.1",
+		PrivateIP:  "10.0.0.1",
+		Size:       "static",
+		Host:       "10.0.0.1",
+		SSHUser:    "ubuntu",
+		SSHKeyPath: "/key",
+	}}, machines)
+
+	// Other namespaces have their own hosts.
+	other, err := New("other")
+	assert.NoError(t, err)
+	otherMachines, err := other.List()
+	assert.NoError(t, err)
+	assert.Empty(t, otherMachines)
+
+	resources, err := prvdr.Resources()
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Resource{{Type: resource.Instance,
+		ID: "10.0.0.1", Created: time.Unix(1500000000, 0).UTC()}}, resources)
+
+	assert.NoError(t, prvdr.Reboot(machines))
+	assert.Equal(t, rebootCommand, (*calls)[1].cmd)
+
+	assert.NoError(t, prvdr.Stop(machines))
+	assert.Equal(t, teardownCommand, (*calls)[2].cmd)
+
+	machines, err = prvdr.List()
+	assert.NoError(t, err)
+	assert.Empty(t, machines)
+}
+
+func TestBootErrors(t *testing.T) {
+	calls := mockSSH("10.0.0.2")
+	prvdr := Provider{"ns"}
+
+	err := prvdr.Boot([]db.Machine{{Host: "lab-1"}})
+	assert.EqualError(t, err, `static machine has invalid host "lab-1"`)
+
+	err = prvdr.Boot([]db.Machine{{Host: "10.0.0.1", Preemptible: true}})
+	assert.EqualError(t, err, "static hosts can't be preemptible")
+	assert.Empty(t, *calls)
+
+	// Hosts that failed to bootstrap aren't recorded.
+	err = prvdr.Boot([]db.Machine{{Host: "10.0.0.1"}, {Host: "10.0.0.2"}})
+	assert.EqualError(t, err, "bootstrap 10.0.0.2: unreachable")
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, "10.0.0.1", machines[0].Host)
+
+	err = prvdr.Reboot([]db.Machine{{CloudID: "10.0.0.3"}})
+	assert.EqualError(t, err, "unknown host 10.0.0.3")
+
+	assert.Error(t, prvdr.UpdateFloatingIPs(nil))
+}
+
+func TestStopError(t *testing.T) {
+	mockSSH("10.0.0.1")
+	prvdr := Provider{"ns"}
+	assert.NoError(t, prvdr.updateHosts(func(hosts map[string]host) {
+		hosts["10.0.0.1"] = host{Host: "10.0.0.1"}
+	}))
+
+	// Hosts that couldn't be torn down are kept, so that it's retried.
+	err := prvdr.Stop([]db.Machine{{CloudID: "10.0.0.1"}})
+	assert.EqualError(t, err, "tear down 10.0.0.1: unreachable")
+
+	machines, err := prvdr.List()
+	assert.NoError(t, err)
+	assert.Len(t, machines, 1)
+}
+
+func TestCorruptState(t *testing.T) {
+	mockSSH("")
+	prvdr := Provider{"ns"}
+	assert.NoError(t, util.AppFs.MkdirAll("/", 0700))
+	assert.NoError(t, util.WriteFile(prvdr.statePath(), []byte("{"), 0600))
+
+	_, err := New("ns")
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "parse "))
+}
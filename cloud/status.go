@@ -1,11 +1,33 @@
 package cloud
 
 import (
+	"sync"
+
 	"github.com/kelda/kelda/cloud/foreman"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/util"
 )
 
+// The providers whose credentials weren't found when the clouds of the current
+// namespace were created.
+var missingCredentials struct {
+	sync.Mutex
+	providers map[db.ProviderName]struct{}
+}
+
+func setMissingCredentials(providers map[db.ProviderName]struct{}) {
+	missingCredentials.Lock()
+	defer missingCredentials.Unlock()
+	missingCredentials.providers = providers
+}
+
+func credentialsMissing(p db.ProviderName) bool {
+	missingCredentials.Lock()
+	defer missingCredentials.Unlock()
+	_, ok := missingCredentials.providers[p]
+	return ok
+}
+
 func updateMachineStatuses(conn db.Conn) {
 	dbTrig := conn.TriggerTick(30, db.MachineTable).C
 	for range util.JoinNotifiers(dbTrig, foreman.ConnectionTrigger) {
@@ -21,11 +43,31 @@ func updateMachineStatusesOnce(conn db.Conn) {
 			// code responsible for changing it from db.Booting to
 			// db.Connecting and it must run to achieve that.
 			if dbm.PublicIP == "" {
+				if newStatus := unbootedStatus(dbm); newStatus != dbm.Status {
+					dbm.Status = newStatus
+					view.Commit(dbm)
+				}
 				continue
 			}
 
+			changed := false
 			if newStatus, ok := status(dbm); ok && newStatus != dbm.Status {
 				dbm.Status = newStatus
+				changed = true
+			}
+
+			// Disconnected machines keep the config status they last had.
+			gen, applied, pushErr, ok := configStatus(dbm.PublicIP)
+			if ok && (gen != dbm.ConfigGeneration ||
+				applied != dbm.AppliedGeneration ||
+				pushErr != dbm.ConfigError) {
+				dbm.ConfigGeneration = gen
+				dbm.AppliedGeneration = applied
+				dbm.ConfigError = pushErr
+				changed = true
+			}
+
+			if changed {
 				view.Commit(dbm)
 			}
 		}
@@ -33,18 +75,40 @@ func updateMachineStatusesOnce(conn db.Conn) {
 	})
 }
 
+// unbootedStatus returns the status of a machine without a public IP.  Machines
+// that can't be booted because their provider's credentials are missing say so,
+// while the statuses of the others are left to the clouds that boot them.
+func unbootedStatus(m db.Machine) string {
+	missing := m.CloudID == "" && credentialsMissing(m.Provider)
+	switch {
+	case missing && m.Status == "":
+		return db.CredentialsMissing
+	case !missing && m.Status == db.CredentialsMissing:
+		return ""
+	default:
+		return m.Status
+	}
+}
+
 // status returns a status string for the given machine. If no string could be
 // determined, the second return value is false.
 func status(m db.Machine) (string, bool) {
 	// "Connected" takes priority over other statuses.
 	connected := m.PublicIP != "" && isConnected(m.PublicIP)
 	if connected {
+		if isNetworkDegraded(m.PublicIP) {
+			return db.NetworkDegraded, true
+		}
+		if m.Action != "" || m.Interrupted {
+			return db.Draining, true
+		}
 		return db.Connected, true
 	}
 
 	// If we had previously connected, and we are not currently connected, show
 	// that we are attempting to reconnect.
-	if m.Status == db.Connected || m.Status == db.Reconnecting {
+	if m.Status == db.Connected || m.Status == db.NetworkDegraded ||
+		m.Status == db.Draining || m.Status == db.Reconnecting {
 		return db.Reconnecting, true
 	}
 
@@ -58,3 +122,5 @@ func status(m db.Machine) (string, bool) {
 }
 
 var isConnected = foreman.IsConnected
+var isNetworkDegraded = foreman.IsNetworkDegraded
+var configStatus = foreman.ConfigStatus
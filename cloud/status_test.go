@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/kelda/kelda/cloud/foreman"
 	"github.com/kelda/kelda/db"
 )
 
@@ -13,12 +14,15 @@ func TestUpdateMachineStatuses(t *testing.T) {
 		switch host {
 		case "connect-fail":
 			return false
-		case "connect-succeed":
+		case "connect-succeed", "connect-degraded":
 			return true
 		default:
 			panic("unrecognized host")
 		}
 	}
+	isNetworkDegraded = func(host string) bool {
+		return host == "connect-degraded"
+	}
 
 	conn := db.New()
 	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
@@ -68,9 +72,47 @@ func TestUpdateMachineStatuses(t *testing.T) {
 		m.PublicIP = "connect-fail"
 		view.Commit(m)
 
+		// A connected machine whose network becomes degraded.
+		m = view.InsertMachine()
+		m.BlueprintID = "8"
+		m.Status = db.Connected
+		m.PublicIP = "connect-degraded"
+		view.Commit(m)
+
+		// A connected machine that's about to be rebooted.
+		m = view.InsertMachine()
+		m.BlueprintID = "9"
+		m.Status = db.Connected
+		m.PublicIP = "connect-succeed"
+		m.Action = db.RebootAction
+		view.Commit(m)
+
+		// A connected machine that's about to be reclaimed by its provider.
+		m = view.InsertMachine()
+		m.BlueprintID = "10"
+		m.Status = db.Connected
+		m.PublicIP = "connect-succeed"
+		m.Interrupted = true
+		view.Commit(m)
+
+		// An unbooted machine whose provider's credentials are missing.
+		m = view.InsertMachine()
+		m.BlueprintID = "11"
+		m.Provider = db.Google
+		view.Commit(m)
+
+		// A machine whose provider's credentials were found after all.
+		m = view.InsertMachine()
+		m.BlueprintID = "12"
+		m.Provider = db.Amazon
+		m.Status = db.CredentialsMissing
+		view.Commit(m)
+
 		return nil
 	})
 
+	setMissingCredentials(map[db.ProviderName]struct{}{db.Google: {}})
+	defer setMissingCredentials(nil)
 	updateMachineStatusesOnce(conn)
 
 	actual := conn.SelectFromMachine(nil)
@@ -78,7 +120,7 @@ func TestUpdateMachineStatuses(t *testing.T) {
 		actual[i].ID = 0
 		actual[i].PublicIP = ""
 	}
-	assert.Len(t, actual, 7)
+	assert.Len(t, actual, 12)
 	assert.Contains(t, actual, db.Machine{BlueprintID: "1"})
 	assert.Contains(t, actual, db.Machine{BlueprintID: "2", Status: db.Booting})
 	assert.Contains(t, actual, db.Machine{BlueprintID: "3", Status: db.Connecting})
@@ -86,4 +128,61 @@ func TestUpdateMachineStatuses(t *testing.T) {
 	assert.Contains(t, actual, db.Machine{BlueprintID: "5", Status: db.Connected})
 	assert.Contains(t, actual, db.Machine{BlueprintID: "6", Status: db.Reconnecting})
 	assert.Contains(t, actual, db.Machine{BlueprintID: "7", Status: db.Reconnecting})
+	assert.Contains(t, actual, db.Machine{BlueprintID: "8",
+		Status: db.NetworkDegraded})
+	assert.Contains(t, actual, db.Machine{BlueprintID: "9",
+		Status: db.Draining, Action: db.RebootAction})
+	assert.Contains(t, actual, db.Machine{BlueprintID: "10",
+		Status: db.Draining, Interrupted: true})
+	assert.Contains(t, actual, db.Machine{BlueprintID: "11",
+		Provider: db.Google, Status: db.CredentialsMissing})
+	assert.Contains(t, actual, db.Machine{BlueprintID: "12",
+		Provider: db.Amazon})
+}
+
+func TestUpdateConfigStatuses(t *testing.T) {
+	isConnected = func(host string) bool { return host != "disconnected" }
+	isNetworkDegraded = func(string) bool { return false }
+	defer func() { configStatus = foreman.ConfigStatus }()
+	configStatus = func(host string) (int64, int64, string, bool) {
+		switch host {
+		case "stale":
+			return 2, 1, "", true
+		case "rejected":
+			return 2, 1, "invalid blueprint signature", true
+		}
+		return 0, 0, "", false
+	}
+
+	conn := db.New()
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		for _, ip := range []string{"stale", "rejected", "disconnected"} {
+			m := view.InsertMachine()
+			m.BlueprintID = ip
+			m.PublicIP = ip
+			m.Status = db.Connected
+			m.ConfigGeneration = 1
+			m.AppliedGeneration = 1
+			view.Commit(m)
+		}
+		return nil
+	})
+
+	updateMachineStatusesOnce(conn)
+
+	actual := map[string]db.Machine{}
+	for _, m := range conn.SelectFromMachine(nil) {
+		actual[m.BlueprintID] = m
+	}
+
+	assert.Equal(t, int64(2), actual["stale"].ConfigGeneration)
+	assert.Equal(t, int64(1), actual["stale"].AppliedGeneration)
+	assert.Empty(t, actual["stale"].ConfigError)
+
+	assert.Equal(t, "invalid blueprint signature", actual["rejected"].ConfigError)
+
+	// Disconnected machines keep their last known config status.
+	assert.Equal(t, db.Reconnecting, actual["disconnected"].Status)
+	assert.Equal(t, int64(1), actual["disconnected"].ConfigGeneration)
+	assert.Equal(t, int64(1), actual["disconnected"].AppliedGeneration)
 }
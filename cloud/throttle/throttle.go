@@ -0,0 +1,230 @@
+// Package throttle limits the rate of the requests that Quilt makes to the APIs
+// of the cloud providers, and retries the requests that the providers reject
+// because of their rate limits or fail with server errors.  Otherwise, large
+// clusters exceed the rate limits, and the rejected requests abort the whole
+// cloud run.
+//
+// Requests are throttled by a token bucket per provider and region, which the
+// clients of a provider share.
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// A limit is the rate, in requests per second, at which a bucket refills, and the
+// number of requests that may be made in a burst.
+type limit struct {
+	rate  float64
+	burst float64
+}
+
+var defaultLimit = limit{rate: 10, burst: 20}
+
+// The limits of the providers whose APIs allow fewer requests than the default.
+var limits = map[db.ProviderName]limit{
+	// DigitalOcean allows 5,000 requests per hour, and 250 per minute.
+	db.DigitalOcean: {rate: 1.3, burst: 60},
+}
+
+// The number of times that a request is retried, and the bounds of the delay
+// between attempts, which doubles after each one.
+var (
+	maxRetries = 5
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+var c = counter.New("Throttle")
+
+// Allow mocking out time for the unit tests.
+var now = time.Now
+var sleep = sleepCtx
+
+// sleepCtx waits for `d`, or until `ctx` is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type bucketKey struct {
+	provider db.ProviderName
+	region   string
+}
+
+var buckets = struct {
+	sync.Mutex
+	buckets map[bucketKey]*bucket
+}{buckets: map[bucketKey]*bucket{}}
+
+// Wrap returns a copy of `client` whose requests to the API of `provider` in
+// `region` are throttled and retried.  Providers whose rate limits aren't
+// regional share a bucket by passing an empty region.
+func Wrap(provider db.ProviderName, region string, client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	wrapped := *client
+	wrapped.Transport = &transport{
+		bucket: getBucket(provider, region),
+		base:   base,
+	}
+	return &wrapped
+}
+
+func getBucket(provider db.ProviderName, region string) *bucket {
+	buckets.Lock()
+	defer buckets.Unlock()
+
+	key := bucketKey{provider, region}
+	if b, ok := buckets.buckets[key]; ok {
+		return b
+	}
+
+	l, ok := limits[provider]
+	if !ok {
+		l = defaultLimit
+	}
+	b := &bucket{limit: l, tokens: l.burst, last: now()}
+	buckets.buckets[key] = b
+	return b
+}
+
+// A bucket holds the tokens that requests must take before they're sent.
+type bucket struct {
+	sync.Mutex
+	limit
+
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token from the bucket, and returns how long the caller must wait
+// for it.  Tokens are taken even if the bucket is empty, so that callers are
+// served in the order that they arrived.
+func (b *bucket) take() time.Duration {
+	b.Lock()
+	defer b.Unlock()
+
+	t := now()
+	b.tokens = math.Min(b.burst, b.tokens+t.Sub(b.last).Seconds()*b.rate)
+	b.last = t
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type transport struct {
+	bucket *bucket
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is read up front, so that it may be sent again on retries.
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if wait := t.bucket.take(); wait > 0 {
+			c.Inc("Throttled")
+			if err := sleep(req.Context(), wait); err != nil {
+				return nil, err
+			}
+		}
+
+		attemptReq := *req
+		if body != nil {
+			attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.base.RoundTrip(&attemptReq)
+		if err != nil || attempt == maxRetries || !shouldRetry(req, resp) {
+			return resp, err
+		}
+
+		delay := backoff(attempt, resp)
+		resp.Body.Close()
+
+		c.Inc("Retry")
+		log.WithFields(log.Fields{
+			"method": req.Method,
+			"host":   req.URL.Host,
+			"status": resp.StatusCode,
+			"delay":  delay,
+		}).Debug("Retrying cloud provider request")
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry returns whether `req` should be sent again after receiving `resp`.
+// Requests that were rejected because of the rate limit, or while the API was
+// unavailable, weren't performed, so they're always retried.  EC2 reports
+// RequestLimitExceeded as unavailable.  Other server errors
+// may have happened after the request was performed, so only requests that are
+// safe to repeat are retried.
+func shouldRetry(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+
+	if resp.StatusCode < 500 {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before the retry following `attempt`.  The
+// provider's Retry-After header is respected, if it's given in seconds.
+func backoff(attempt int, resp *http.Response) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+
+	delay := minBackoff << uint(attempt)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	return delay
+}
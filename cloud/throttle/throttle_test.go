@@ -0,0 +1,122 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+)
+
+func TestBucket(t *testing.T) {
+	clock := time.Unix(0, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	b := &bucket{limit: limit{rate: 2, burst: 2}, tokens: 2, last: clock}
+	assert.Equal(t, time.Duration(0), b.take())
+	assert.Equal(t, time.Duration(0), b.take())
+
+	// Once the burst is used up, callers wait for the bucket to refill, in the
+	// order that they arrived.
+	assert.Equal(t, 500*time.Millisecond, b.take())
+	assert.Equal(t, time.Second, b.take())
+
+	// The bucket never holds more than the burst.
+	clock = clock.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.take())
+	assert.Equal(t, time.Duration(0), b.take())
+	assert.Equal(t, 500*time.Millisecond, b.take())
+}
+
+func TestGetBucket(t *testing.T) {
+	amazon := getBucket(db.Amazon, "us-west-1")
+	assert.True(t, amazon == getBucket(db.Amazon, "us-west-1"))
+	assert.False(t, amazon == getBucket(db.Amazon, "us-west-2"))
+	assert.Equal(t, defaultLimit, amazon.limit)
+	assert.Equal(t, limits[db.DigitalOcean], getBucket(db.DigitalOcean, "sfo1").limit)
+}
+
+func TestRetry(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	defer func() { sleep = sleepCtx }()
+
+	var statuses []int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+
+			status := statuses[0]
+			statuses = statuses[1:]
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "7")
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, status)
+		}))
+	defer server.Close()
+
+	client := Wrap(db.Fake, "retry", &http.Client{})
+	post := func() *http.Response {
+		resp, err := client.Post(server.URL, "text/plain",
+			strings.NewReader("body"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Throttled and unavailable requests are retried with the same body,
+	// respecting Retry-After.
+	statuses = []int{429, 503, 503, 200}
+	assert.Equal(t, 200, post().StatusCode)
+	assert.Equal(t, []string{"body", "body", "body", "body"}, bodies)
+	assert.Equal(t, []time.Duration{7 * time.Second, time.Second,
+		2 * time.Second}, []time.Duration{slept[0], slept[1], slept[2]})
+
+	// Other server errors are only retried for requests that are safe to
+	// repeat.
+	statuses = []int{500}
+	assert.Equal(t, 500, post().StatusCode)
+
+	statuses = []int{500, 200}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// Client errors aren't retried.
+	statuses = []int{404}
+	assert.Equal(t, 404, post().StatusCode)
+
+	// Requests that are never accepted give up after the maximum retries.
+	statuses = nil
+	for i := 0; i <= maxRetries; i++ {
+		statuses = append(statuses, 503)
+	}
+	assert.Equal(t, 503, post().StatusCode)
+	assert.Empty(t, statuses)
+}
+
+func TestBackoff(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	assert.Equal(t, minBackoff, backoff(0, resp))
+	assert.Equal(t, 4*minBackoff, backoff(2, resp))
+	assert.Equal(t, maxBackoff, backoff(10, resp))
+	assert.Equal(t, maxBackoff, backoff(100, resp))
+
+	resp.Header.Set("Retry-After", "Wed, 21 Oct 2015 07:28:00 GMT")
+	assert.Equal(t, minBackoff, backoff(0, resp))
+}
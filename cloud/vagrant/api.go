@@ -98,6 +98,16 @@ func up(id string) error {
 	return nil
 }
 
+func reload(id string) error {
+	c.Inc("Reload")
+	_, stderr, err := shell(id, `vagrant --machine-readable reload`)
+	if err != nil {
+		log.Errorf("Failed to reload Vagrant machine: %s", string(stderr))
+		return errors.New("unable to reload machine")
+	}
+	return nil
+}
+
 func destroy(id string) error {
 	c.Inc("Destroy")
 	_, stderr, err := shell(id,
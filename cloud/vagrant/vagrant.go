@@ -4,8 +4,11 @@ import (
 	"errors"
 	"sync"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/cloud/capability"
 	"github.com/kelda/kelda/cloud/cfg"
+	"github.com/kelda/kelda/cloud/resource"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
 	"github.com/satori/go.uuid"
@@ -33,6 +36,9 @@ func (prvdr Provider) Boot(bootSet []db.Machine) error {
 			return errors.New(
 				"vagrant does not support preemptible instances")
 		}
+		if m.Bootstrap == blueprint.SSHBootstrap {
+			return errors.New("vagrant does not support SSH bootstrapping")
+		}
 	}
 
 	// If any of the boot.Machine() calls fail, errChan will contain exactly one
@@ -121,11 +127,44 @@ func (prvdr Provider) Stop(machines []db.Machine) error {
 	return nil
 }
 
+// Reboot restarts `machines` in place.
+func (prvdr Provider) Reboot(machines []db.Machine) error {
+	for _, m := range machines {
+		if err := reload(m.CloudID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resources lists the Vagrant machines.  They're the only resources Vagrant
+// creates.
+func (prvdr Provider) Resources() ([]resource.Resource, error) {
+	ids, err := list()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []resource.Resource
+	for _, id := range ids {
+		resources = append(resources, resource.Resource{
+			Type: resource.Instance,
+			ID:   id,
+		})
+	}
+	return resources, nil
+}
+
 // SetACLs is a noop for vagrant.
 func (prvdr Provider) SetACLs(acls []acl.ACL) error {
 	return nil
 }
 
+// Capabilities returns the optional features that Vagrant supports.
+func (prvdr *Provider) Capabilities() capability.Capabilities {
+	return capability.Capabilities{}
+}
+
 // UpdateFloatingIPs is not supported.
 func (prvdr *Provider) UpdateFloatingIPs([]db.Machine) error {
 	return errors.New("vagrant provider does not support floating IPs")
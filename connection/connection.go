@@ -22,10 +22,32 @@ type Credentials interface {
 	ServerOpts() []grpc.ServerOption
 }
 
+// MachineCredentials are Credentials that can also verify that a server is
+// running on a particular machine.
+type MachineCredentials interface {
+	Credentials
+
+	// MachineClientOpts returns the `DialOption`s necessary to setup the
+	// credentials when obtaining a grpc client connection to the machine with
+	// the given cloud ID.
+	MachineClientOpts(cloudID string) []grpc.DialOption
+}
+
 // Client creates a grpc client connected to `addr`.
 func Client(proto, addr string, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	return ProxiedClient(nil, proto, addr, opts)
+}
+
+// ProxiedClient creates a grpc client connected to `addr` through `proxy`.  If
+// `proxy` is nil, it connects directly.
+func ProxiedClient(proxy Dialer, proto, addr string, opts []grpc.DialOption) (
+	*grpc.ClientConn, error) {
+
 	dialer := func(dialAddr string, t time.Duration) (net.Conn, error) {
-		return net.DialTimeout(proto, dialAddr, t)
+		if proxy == nil {
+			return net.DialTimeout(proto, dialAddr, t)
+		}
+		return proxy.DialTimeout(proto, dialAddr, t)
 	}
 	return grpc.Dial(addr, append(opts, grpc.WithDialer(dialer),
 		grpc.WithBlock(), grpc.WithTimeout(connectTimeout))...)
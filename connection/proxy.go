@@ -0,0 +1,263 @@
+package connection
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// A Dialer opens connections through a proxy.
+type Dialer interface {
+	// DialTimeout connects to `addr`, failing if the connection isn't
+	// established within `timeout`.
+	DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error)
+}
+
+// NewProxyDialer returns a Dialer that connects through the proxy at `proxyURL`.
+// The scheme of the URL selects the kind of proxy:
+//
+//	socks5://[USER:PASSWORD@]HOST:PORT is a SOCKS5 proxy.
+//	http://[USER:PASSWORD@]HOST:PORT is an HTTP proxy that supports CONNECT.
+//	ssh://USER@HOST[:PORT] is a jump host, authenticated with `sshKey`.
+func NewProxyDialer(proxyURL string, sshKey ssh.Signer) (Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %s has no host", proxyURL)
+	}
+
+	switch u.Scheme {
+	case "socks5":
+		// Checks that the URL is valid.  The SOCKS dialer is recreated for
+		// each connection, with the connection's timeout.
+		if _, err := proxy.FromURL(u, proxy.Direct); err != nil {
+			return nil, err
+		}
+		return socksProxy{u}, nil
+	case "http":
+		return httpProxy{u}, nil
+	case "ssh":
+		if u.User == nil {
+			return nil, fmt.Errorf("jump host %s has no user", proxyURL)
+		}
+
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "22")
+		}
+		return &jumpHost{host: host, config: ssh.ClientConfig{
+			User: u.User.Username(),
+			Auth: []ssh.AuthMethod{ssh.PublicKeys(sshKey)},
+			// XXX: As with the connections to machines, the jump host's key
+			// isn't verified.  The connections made through it are
+			// authenticated with TLS, so this is only a risk to the
+			// availability of the cluster.
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+}
+
+// A socksProxy connects through a SOCKS5 proxy.
+type socksProxy struct {
+	url *url.URL
+}
+
+func (p socksProxy) DialTimeout(network, addr string, timeout time.Duration) (
+	net.Conn, error) {
+
+	dialer, err := proxy.FromURL(p.url, &net.Dialer{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	// The timeout of the forward dialer only covers connecting to the proxy,
+	// so the SOCKS handshake is bounded separately.
+	return withTimeout(timeout, func() (net.Conn, error) {
+		return dialer.Dial(network, addr)
+	})
+}
+
+// An httpProxy tunnels connections through an HTTP proxy with CONNECT requests.
+type httpProxy struct {
+	url *url.URL
+}
+
+func (p httpProxy) DialTimeout(network, addr string, timeout time.Duration) (
+	net.Conn, error) {
+
+	conn, err := net.DialTimeout("tcp", p.url.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CONNECT request must complete within the timeout as well.
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if p.url.User != nil {
+		password, _ := p.url.User.Password()
+		auth := p.url.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization",
+			"Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection to %s: %s",
+			addr, resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	// The reader may have buffered data sent after the response.
+	return bufferedConn{conn, reader}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// A jumpHost forwards connections through an SSH server.  The SSH connection is
+// shared by all forwarded connections, and reopened if it fails.
+type jumpHost struct {
+	host   string
+	config ssh.ClientConfig
+
+	// The lock only protects `client`.  Dials happen without it, so that a jump
+	// host that hangs doesn't block the connections that are already open.
+	sync.Mutex
+	client *ssh.Client
+}
+
+func (j *jumpHost) DialTimeout(network, addr string, timeout time.Duration) (
+	net.Conn, error) {
+
+	j.Lock()
+	client := j.client
+	j.Unlock()
+
+	if client != nil {
+		conn, err := withTimeout(timeout, func() (net.Conn, error) {
+			return client.Dial(network, addr)
+		})
+		if err == nil {
+			return conn, nil
+		}
+
+		// The SSH connection may have been lost, so retry with a new one.
+		j.reset(client)
+	}
+
+	client, err := j.connect(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial jump host: %s", err)
+	}
+	return withTimeout(timeout, func() (net.Conn, error) {
+		return client.Dial(network, addr)
+	})
+}
+
+// connect opens a new SSH connection to the jump host, unless another dial opened
+// one in the meantime.
+func (j *jumpHost) connect(timeout time.Duration) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("tcp", j.host, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlike ssh.Dial's timeout, the deadline also bounds the SSH handshake.
+	conn.SetDeadline(time.Now().Add(timeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, j.host, &j.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	j.Lock()
+	defer j.Unlock()
+	if j.client != nil {
+		client.Close()
+		return j.client, nil
+	}
+	j.client = client
+	return client, nil
+}
+
+// reset closes `client`, if it's still the jump host's connection.
+func (j *jumpHost) reset(client *ssh.Client) {
+	j.Lock()
+	defer j.Unlock()
+	if j.client == client {
+		j.client.Close()
+		j.client = nil
+	}
+}
+
+// withTimeout calls `dial`, giving up if it doesn't return within `timeout`.
+// Connections that are opened after giving up are closed.
+func withTimeout(timeout time.Duration, dial func() (net.Conn, error)) (
+	net.Conn, error) {
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		conn, err := dial()
+		results <- result{conn, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-results:
+		return res.conn, res.err
+	case <-timer.C:
+		go func() {
+			if res := <-results; res.conn != nil {
+				res.conn.Close()
+			}
+		}()
+		return nil, fmt.Errorf("dial timed out after %s", timeout)
+	}
+}
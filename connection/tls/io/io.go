@@ -50,6 +50,12 @@ func ReadCredentials(dir string) (tls.TLS, error) {
 	return tls.New(caCert, signedCert, signedKey)
 }
 
+// ReadCACert reads the PEM-encoded certificate of the certificate authority
+// contained within the directory.
+func ReadCACert(dir string) (string, error) {
+	return util.ReadFile(caCertPath(dir))
+}
+
 // ReadCA reads the certificate authority contained with the directory.
 func ReadCA(dir string) (rsa.KeyPair, error) {
 	caCert, err := util.ReadFile(caCertPath(dir))
@@ -65,14 +71,37 @@ func ReadCA(dir string) (rsa.KeyPair, error) {
 	return rsa.New(caCert, caKey)
 }
 
+// ReadSigned reads the signed key pair contained within the directory.
+func ReadSigned(dir string) (rsa.KeyPair, error) {
+	cert, err := util.ReadFile(signedCertPath(dir))
+	if err != nil {
+		return rsa.KeyPair{}, fmt.Errorf("read cert: %s", err)
+	}
+
+	key, err := util.ReadFile(signedKeyPath(dir))
+	if err != nil {
+		return rsa.KeyPair{}, fmt.Errorf("read key: %s", err)
+	}
+
+	return rsa.New(cert, key)
+}
+
 // MinionFiles defines how files should be written to disk for installation on
 // minions.
 func MinionFiles(dir string, ca, signed rsa.KeyPair) []File {
+	return CredentialFiles(dir, ca.CertString(), signed.CertString(),
+		signed.PrivateKeyString())
+}
+
+// CredentialFiles defines how the PEM-encoded certificate of the certificate
+// authority, and a certificate and key that it signed, should be written to disk
+// in `dir`, such as to pass them to a container.  They're laid out like the
+// minion's, so they can be read with ReadCredentials.
+func CredentialFiles(dir, caCert, cert, key string) []File {
 	return []File{
-		{Path: caCertPath(dir), Content: ca.CertString(), Mode: 0644},
-		{Path: signedCertPath(dir), Content: signed.CertString(), Mode: 0644},
-		{Path: signedKeyPath(dir), Content: signed.PrivateKeyString(),
-			Mode: 0600},
+		{Path: caCertPath(dir), Content: caCert, Mode: 0644},
+		{Path: signedCertPath(dir), Content: cert, Mode: 0644},
+		{Path: signedKeyPath(dir), Content: key, Mode: 0600},
 	}
 }
 
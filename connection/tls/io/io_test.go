@@ -29,6 +29,10 @@ func TestWriteAndReadCA(t *testing.T) {
 
 	assert.Equal(t, ca.CertString(), parsedCA.CertString())
 	assert.Equal(t, ca.PrivateKeyString(), parsedCA.PrivateKeyString())
+
+	caCert, err := ReadCACert(testDir)
+	assert.NoError(t, err)
+	assert.Equal(t, ca.CertString(), caCert)
 }
 
 func TestWriteAndReadMinionCerts(t *testing.T) {
@@ -50,6 +54,33 @@ func TestWriteAndReadMinionCerts(t *testing.T) {
 
 	_, err = ReadCredentials(testDir)
 	assert.NoError(t, err)
+
+	parsedSigned, err := ReadSigned(testDir)
+	assert.NoError(t, err)
+	assert.Equal(t, signed.CertString(), parsedSigned.CertString())
+	assert.Equal(t, signed.PrivateKeyString(), parsedSigned.PrivateKeyString())
+}
+
+func TestCredentialFiles(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	signed, err := rsa.NewSigned(ca)
+	assert.NoError(t, err)
+
+	files := CredentialFiles("/tls", ca.CertString(), signed.CertString(),
+		signed.PrivateKeyString())
+	assert.Equal(t, MinionFiles("/tls", ca, signed), files)
+
+	util.Mkdir("/tls", 0755)
+	for _, f := range files {
+		util.WriteFile(f.Path, []byte(f.Content), f.Mode)
+	}
+
+	_, err = ReadCredentials("/tls")
+	assert.NoError(t, err)
 }
 
 func TestReadDaemonCerts(t *testing.T) {
@@ -2,14 +2,18 @@ package rsa
 
 import (
 	"crypto"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"math/big"
 	"net"
+	"net/url"
 	"time"
 )
 
@@ -41,6 +45,48 @@ func (keyPair KeyPair) CertString() string {
 	}))
 }
 
+// Cert returns the certificate.
+func (keyPair KeyPair) Cert() *x509.Certificate {
+	return keyPair.cert
+}
+
+// Sign returns the signature of `data` by the private key.
+func (keyPair KeyPair) Sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, keyPair.key, crypto.SHA256, digest[:])
+}
+
+// DeriveSecret returns a secret, such as a password, derived from the private key
+// and `name`.  The secret is the same each time it's derived from the same key
+// and name, so it needn't be stored, but can't be derived without the key.
+func (keyPair KeyPair) DeriveSecret(name string) string {
+	mac := hmac.New(sha256.New, x509.MarshalPKCS1PrivateKey(keyPair.key))
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns an error unless `signature` is a signature of `data` by the
+// private key of the PEM-encoded certificate `certStr`.
+func Verify(certStr string, data, signature []byte) error {
+	certDER, err := getDER(certStr)
+	if err != nil {
+		return fmt.Errorf("read cert: %s", err)
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return fmt.Errorf("parse cert: %s", err)
+	}
+
+	pubKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("cert doesn't have an RSA public key")
+	}
+
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, digest[:], signature)
+}
+
 // New loads the KeyPair defined by the given PEM-encoded cert and key.
 func New(certStr, keyStr string) (KeyPair, error) {
 	keyDER, err := getDER(keyStr)
@@ -106,29 +152,104 @@ func NewCertificateAuthority() (KeyPair, error) {
 
 // NewSigned generates a KeyPair signed by `signer`.
 func NewSigned(signer KeyPair, ips ...net.IP) (KeyPair, error) {
+	return NewSignedWithURIs(signer, nil, ips...)
+}
+
+// NewSignedWithURIs generates a KeyPair signed by `signer` whose certificate
+// also lists `uris` as subject alternative names, such as to identify the
+// machine that it was issued to.
+func NewSignedWithURIs(signer KeyPair, uris []*url.URL, ips ...net.IP) (
+	KeyPair, error) {
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		return KeyPair{}, fmt.Errorf("create key: %s", err)
 	}
 
+	cert, err := signKey(signer, key.Public(), uris, ips)
+	return KeyPair{key, cert}, err
+}
+
+// NewPrivateKey generates a private key, and returns it PEM-encoded.  Its holder
+// may share the PublicKeyString of the key with a signer, so that the private key
+// itself needn't be sent anywhere.
+func NewPrivateKey() (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+	return KeyPair{key: key}.PrivateKeyString(), nil
+}
+
+// PublicKeyString returns the PEM-encoded public key of the PEM-encoded private
+// key `keyStr`.
+func PublicKeyString(keyStr string) (string, error) {
+	keyDER, err := getDER(keyStr)
+	if err != nil {
+		return "", fmt.Errorf("read key: %s", err)
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(keyDER)
+	if err != nil {
+		return "", fmt.Errorf("parse key: %s", err)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: pubDER,
+	})), nil
+}
+
+// SignPublicKey returns a PEM-encoded certificate for the PEM-encoded public key
+// `pubKeyStr`, signed by `signer`, whose subject alternative names are `uris`.
+func SignPublicKey(signer KeyPair, pubKeyStr string, uris []*url.URL) (
+	string, error) {
+	pubDER, err := getDER(pubKeyStr)
+	if err != nil {
+		return "", fmt.Errorf("read key: %s", err)
+	}
+
+	pubKey, err := x509.ParsePKIXPublicKey(pubDER)
+	if err != nil {
+		return "", fmt.Errorf("parse key: %s", err)
+	}
+
+	if _, ok := pubKey.(*rsa.PublicKey); !ok {
+		return "", errors.New("not an RSA public key")
+	}
+
+	cert, err := signKey(signer, pubKey, uris, nil)
+	if err != nil {
+		return "", err
+	}
+	return KeyPair{cert: cert}.CertString(), nil
+}
+
+// signKey issues a certificate for `pubKey` signed by `signer`.
+func signKey(signer KeyPair, pubKey crypto.PublicKey, uris []*url.URL,
+	ips []net.IP) (*x509.Certificate, error) {
+
 	template, err := certTemplate()
 	if err != nil {
-		return KeyPair{}, fmt.Errorf("create template: %s", err)
+		return nil, fmt.Errorf("create template: %s", err)
 	}
 	template.ExtKeyUsage = []x509.ExtKeyUsage{
 		x509.ExtKeyUsageClientAuth,
 		x509.ExtKeyUsageServerAuth,
 	}
 	template.IPAddresses = ips
+	template.URIs = uris
 
 	certBytes, err := x509.CreateCertificate(rand.Reader, &template,
-		signer.cert, key.Public(), signer.key)
+		signer.cert, pubKey, signer.key)
 	if err != nil {
-		return KeyPair{}, fmt.Errorf("create cert: %s", err)
+		return nil, fmt.Errorf("create cert: %s", err)
 	}
 
-	cert, err := x509.ParseCertificate(certBytes)
-	return KeyPair{key, cert}, err
+	return x509.ParseCertificate(certBytes)
 }
 
 func certTemplate() (x509.Certificate, error) {
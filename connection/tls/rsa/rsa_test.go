@@ -2,6 +2,7 @@ package rsa
 
 import (
 	"crypto/x509"
+	"net/url"
 	"testing"
 
 	"github.com/kelda/kelda/connection/tls"
@@ -47,6 +48,60 @@ func TestGeneratedVerifies(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSignVerify(t *testing.T) {
+	ca, signed, err := newCAAndSigned()
+	assert.NoError(t, err)
+
+	sig, err := ca.Sign([]byte("data"))
+	assert.NoError(t, err)
+	assert.NoError(t, Verify(ca.CertString(), []byte("data"), sig))
+
+	assert.Error(t, Verify(ca.CertString(), []byte("forged"), sig))
+	assert.Error(t, Verify(signed.CertString(), []byte("data"), sig))
+	assert.Error(t, Verify("garbage", []byte("data"), sig))
+}
+
+func TestDeriveSecret(t *testing.T) {
+	ca, signed, err := newCAAndSigned()
+	assert.NoError(t, err)
+
+	secret := ca.DeriveSecret("name")
+	assert.Len(t, secret, 64)
+	assert.Equal(t, secret, ca.DeriveSecret("name"))
+	assert.NotEqual(t, secret, ca.DeriveSecret("other"))
+	assert.NotEqual(t, secret, signed.DeriveSecret("name"))
+}
+
+func TestSignPublicKey(t *testing.T) {
+	ca, signed, err := newCAAndSigned()
+	assert.NoError(t, err)
+
+	key, err := NewPrivateKey()
+	assert.NoError(t, err)
+
+	pubKey, err := PublicKeyString(key)
+	assert.NoError(t, err)
+
+	uri := tls.ContainerURI("web")
+	cert, err := SignPublicKey(ca, pubKey, []*url.URL{uri})
+	assert.NoError(t, err)
+
+	// The certificate is usable with the private key that was never shared.
+	_, err = tls.New(ca.CertString(), cert, key)
+	assert.NoError(t, err)
+
+	keyPair, err := New(cert, key)
+	assert.NoError(t, err)
+	assert.Equal(t, []*url.URL{uri}, keyPair.cert.URIs)
+
+	// It doesn't match other keys.
+	_, err = tls.New(ca.CertString(), cert, signed.PrivateKeyString())
+	assert.Error(t, err)
+
+	_, err = SignPublicKey(ca, "garbage", nil)
+	assert.Error(t, err)
+}
+
 func newCAAndSigned() (KeyPair, KeyPair, error) {
 	ca, err := NewCertificateAuthority()
 	if err != nil {
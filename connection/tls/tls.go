@@ -5,10 +5,13 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 // TLS satisfies the connection.Credentials interface for configuring grpc
@@ -26,32 +29,65 @@ type TLS struct {
 
 // ServerOpts gets the grpc options for creating a server.
 func (tlsAuth TLS) ServerOpts() []grpc.ServerOption {
-	return []grpc.ServerOption{grpc.Creds(
-		credentials.NewTLS(&tls.Config{
-			Certificates: []tls.Certificate{tlsAuth.keyPair},
-			ClientCAs:    tlsAuth.caPool,
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-		}),
-	)}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsAuth.ServerConfig()))}
+}
+
+// ServerConfig gets the TLS configuration for accepting connections.  Only
+// clients with a certificate signed by the CA are accepted.
+func (tlsAuth TLS) ServerConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{tlsAuth.keyPair},
+		ClientCAs:    tlsAuth.caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
 }
 
 // ClientOpts gets the grpc options for connecting as a client.
 func (tlsAuth TLS) ClientOpts() []grpc.DialOption {
+	return tlsAuth.clientOpts(tlsAuth.verifySignedByCA)
+}
+
+// ClientConfig gets the TLS configuration for connecting as a client to servers
+// with a certificate signed by the CA.
+func (tlsAuth TLS) ClientConfig() *tls.Config {
+	return tlsAuth.clientConfig(tlsAuth.verifySignedByCA)
+}
+
+// MachineClientOpts gets the grpc options for connecting as a client to the
+// machine with the given cloud ID.  In addition to being signed by the CA, the
+// server's certificate must have been issued to that machine, so that a
+// stranger who is assigned the machine's IP after it's recycled by the cloud
+// provider can't pose as it.
+func (tlsAuth TLS) MachineClientOpts(cloudID string) []grpc.DialOption {
+	return tlsAuth.clientOpts(func(rawCerts [][]byte,
+		_ [][]*x509.Certificate) error {
+		return tlsAuth.verifyMachine(cloudID, rawCerts)
+	})
+}
+
+// verifyFunc verifies the certificates presented by a peer.  It's the type of
+// tls.Config's VerifyPeerCertificate.
+type verifyFunc func(rawCerts [][]byte, chains [][]*x509.Certificate) error
+
+func (tlsAuth TLS) clientOpts(verify verifyFunc) []grpc.DialOption {
 	return []grpc.DialOption{grpc.WithTransportCredentials(
-		credentials.NewTLS(&tls.Config{
-			Certificates: []tls.Certificate{tlsAuth.keyPair},
-
-			// We use a custom VerifyPeerCertificate that only checks whether
-			// the certificate is signed by the expected CA, and ignores
-			// the server's hostname. This greatly simplifies the certificate
-			// generation logic because it doesn't need to account for IP
-			// address changes. This is safe to do because the client only
-			// trusts a single CA, and we have complete control over what
-			// certificates the CA signs.
-			InsecureSkipVerify:    true,
-			VerifyPeerCertificate: tlsAuth.verifySignedByCA,
-		}),
-	)}
+		credentials.NewTLS(tlsAuth.clientConfig(verify)))}
+}
+
+func (tlsAuth TLS) clientConfig(verify verifyFunc) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{tlsAuth.keyPair},
+
+		// We use a custom VerifyPeerCertificate that only checks whether
+		// the certificate is signed by the expected CA, and ignores
+		// the server's hostname. This greatly simplifies the certificate
+		// generation logic because it doesn't need to account for IP
+		// address changes. This is safe to do because the client only
+		// trusts a single CA, and we have complete control over what
+		// certificates the CA signs.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verify,
+	}
 }
 
 // verifySignedByCA verifies that at least one certificate is signed by the
@@ -83,6 +119,100 @@ func (tlsAuth TLS) verifySignedByCA(rawCertsSlice [][]byte,
 		strings.Join(verifyErrors, ", "))
 }
 
+// verifyMachine verifies that the peer's certificate is signed by the expected
+// CA, and was issued to the machine with the given cloud ID.  Unlike
+// verifySignedByCA, only the first certificate is considered, as it's the one
+// whose private key the peer proved that it holds.
+func (tlsAuth TLS) verifyMachine(cloudID string, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer didn't present a certificate")
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("parse peer certificate: %s", err)
+	}
+
+	_, err = cert.Verify(x509.VerifyOptions{Roots: tlsAuth.caPool})
+	if err != nil {
+		return fmt.Errorf("failed to verify peer certificate: %s", err)
+	}
+
+	if id, ok := MachineID(cert); !ok || id != cloudID {
+		return fmt.Errorf("peer certificate was issued to machine %q, "+
+			"rather than %q", id, cloudID)
+	}
+	return nil
+}
+
+// MachineURI returns the URI that identifies the machine with the given cloud ID
+// in the subject alternative names of the machine's certificate.
+func MachineURI(cloudID string) *url.URL {
+	return &url.URL{Scheme: uriScheme, Host: machineURIHost, Path: "/" + cloudID}
+}
+
+// MachineID returns the cloud ID of the machine that `cert` was issued to, or
+// false if it wasn't issued to a machine.
+func MachineID(cert *x509.Certificate) (string, bool) {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == uriScheme && uri.Host == machineURIHost {
+			return strings.TrimPrefix(uri.Path, "/"), true
+		}
+	}
+	return "", false
+}
+
+// DaemonURI returns the URI that identifies the daemon in the subject alternative
+// names of its certificate.
+func DaemonURI() *url.URL {
+	return &url.URL{Scheme: uriScheme, Host: daemonURIHost}
+}
+
+// IsDaemon returns whether `cert` was issued to the daemon.
+func IsDaemon(cert *x509.Certificate) bool {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == uriScheme && uri.Host == daemonURIHost {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyDaemon returns an error unless the client of the gRPC request with
+// context `ctx` presented the daemon's certificate.  Machines hold certificates
+// signed by the same CA, so servers that only the daemon may use, such as the
+// minion's, must check the client's identity in addition to its signature.
+func VerifyDaemon(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return errors.New("unknown peer")
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return errors.New("peer didn't present a certificate")
+	}
+
+	if !IsDaemon(tlsInfo.State.PeerCertificates[0]) {
+		return errors.New("peer certificate wasn't issued to the daemon")
+	}
+	return nil
+}
+
+// ContainerURI returns the URI that identifies the container with `hostname` in
+// the subject alternative names of the certificate of its TLS proxy.
+func ContainerURI(hostname string) *url.URL {
+	return &url.URL{Scheme: uriScheme, Host: containerURIHost,
+		Path: "/" + hostname}
+}
+
+const (
+	uriScheme        = "quilt"
+	machineURIHost   = "machine"
+	daemonURIHost    = "daemon"
+	containerURIHost = "container"
+)
+
 // New creates a TLS instance from the given CA and signed certificate and key.
 func New(ca, cert, key string) (TLS, error) {
 	keyPair, err := tls.X509KeyPair([]byte(cert), []byte(key))
@@ -1,12 +1,18 @@
 package tls
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/pem"
+	"net/url"
 	"testing"
 
 	"github.com/kelda/kelda/connection/tls/rsa"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 const (
@@ -126,9 +132,86 @@ func TestVerifySignedByCA(t *testing.T) {
 		"x509: certificate signed by unknown authority")
 }
 
+func TestVerifyMachine(t *testing.T) {
+	t.Parallel()
+
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	client, err := rsa.NewSigned(ca)
+	assert.NoError(t, err)
+
+	tlsCred, err := New(ca.CertString(), client.CertString(),
+		client.PrivateKeyString())
+	assert.NoError(t, err)
+
+	machine, err := rsa.NewSignedWithURIs(ca, []*url.URL{MachineURI("id")})
+	assert.NoError(t, err)
+	assert.NoError(t, tryVerifyMachine(tlsCred, "id", machine.CertString()))
+
+	// Certificates issued to other machines are rejected, even though they're
+	// signed by the CA.
+	err = tryVerifyMachine(tlsCred, "other", machine.CertString())
+	assert.EqualError(t, err, `peer certificate was issued to machine "id", `+
+		`rather than "other"`)
+
+	err = tryVerifyMachine(tlsCred, "id", client.CertString())
+	assert.EqualError(t, err, `peer certificate was issued to machine "", `+
+		`rather than "id"`)
+
+	otherCA, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	otherMachine, err := rsa.NewSignedWithURIs(otherCA,
+		[]*url.URL{MachineURI("id")})
+	assert.NoError(t, err)
+
+	err = tryVerifyMachine(tlsCred, "id", otherMachine.CertString())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(),
+		"x509: certificate signed by unknown authority")
+}
+
+func TestVerifyDaemon(t *testing.T) {
+	t.Parallel()
+
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	daemon, err := rsa.NewSignedWithURIs(ca, []*url.URL{DaemonURI()})
+	assert.NoError(t, err)
+	assert.True(t, IsDaemon(daemon.Cert()))
+	assert.NoError(t, VerifyDaemon(peerContext(daemon.Cert())))
+
+	machine, err := rsa.NewSignedWithURIs(ca, []*url.URL{MachineURI("id")})
+	assert.NoError(t, err)
+	assert.False(t, IsDaemon(machine.Cert()))
+	assert.EqualError(t, VerifyDaemon(peerContext(machine.Cert())),
+		"peer certificate wasn't issued to the daemon")
+
+	assert.EqualError(t, VerifyDaemon(peerContext()),
+		"peer didn't present a certificate")
+	assert.EqualError(t, VerifyDaemon(context.Background()), "unknown peer")
+}
+
+func peerContext(certs ...*x509.Certificate) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{PeerCertificates: certs},
+		},
+	})
+}
+
 // tryVerify attempts to verify the given PEM-encoded certificate against
 // the TLS credentials.
 func tryVerify(tlsCred TLS, cert string) error {
 	der, _ := pem.Decode([]byte(cert))
 	return tlsCred.verifySignedByCA([][]byte{der.Bytes}, nil)
 }
+
+// tryVerifyMachine attempts to verify that the given PEM-encoded certificate was
+// issued to the machine with the given cloud ID.
+func tryVerifyMachine(tlsCred TLS, cloudID, cert string) error {
+	der, _ := pem.Decode([]byte(cert))
+	return tlsCred.verifyMachine(cloudID, [][]byte{der.Bytes})
+}
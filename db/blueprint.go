@@ -12,6 +12,14 @@ type Blueprint struct {
 	ID int
 
 	blueprint.Blueprint `rowStringer:"omit"`
+
+	// The IP address of the API client that deployed the blueprint, if it
+	// connected from another host.
+	ClientIP string
+
+	// The git commit that the blueprint was compiled from, if it was deployed
+	// by the daemon's GitOps watcher rather than by a client.
+	Commit string
 }
 
 // InsertBlueprint creates a new Blueprint and interts it into 'db'.
@@ -0,0 +1,33 @@
+package db
+
+import "time"
+
+// A Clock schedules the ticks of TriggerTick.  Tests may substitute a clock that
+// they control, such as the one in the dbtest package, so that loops driven by
+// TriggerTick run deterministically.
+type Clock interface {
+	// Every calls `tick` once every `d` until the returned function is called.
+	Every(d time.Duration, tick func()) (stop func())
+}
+
+type realClock struct{}
+
+func (realClock) Every(d time.Duration, tick func()) func() {
+	ticker := time.NewTicker(d)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				tick()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
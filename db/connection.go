@@ -1,7 +1,11 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // A Connection allows two hostnames to speak to each other on the port
@@ -13,6 +17,15 @@ type Connection struct {
 	To      string
 	MinPort int
 	MaxPort int
+
+	// Whether the connection is wrapped in mutual TLS by proxies that the
+	// workers run alongside the containers at either end.
+	MTLS bool `json:",omitempty"`
+
+	// The private IPs of the workers that have yet to install the network
+	// rules that implement the connection.  Set by the leader from the
+	// workers' reports, and not shared with the other minions.
+	Pending []string `json:",omitempty"`
 }
 
 // InsertConnection creates a new connection row and inserts it into the database.
@@ -55,9 +68,40 @@ func (c Connection) String() string {
 		port += fmt.Sprintf("-%d", c.MaxPort)
 	}
 
+	if c.MTLS {
+		port += " mTLS"
+	}
+
 	return fmt.Sprintf("Connection-%d{%s->%s:%s}", c.ID, c.From, c.To, port)
 }
 
+// Key identifies the connection by its endpoints and ports, which, unlike its ID,
+// are the same in every minion's database.
+func (c Connection) Key() string {
+	key := fmt.Sprintf("%s->%s:%d-%d", c.From, c.To, c.MinPort, c.MaxPort)
+	if c.MTLS {
+		key += "/mTLS"
+	}
+	return key
+}
+
+// ConnectionKeys returns the sorted keys (see Key) of `conns`.
+func ConnectionKeys(conns []Connection) []string {
+	var keys []string
+	for _, c := range conns {
+		keys = append(keys, c.Key())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HashConnectionKeys returns a short hash of the sorted connection `keys`, which
+// identifies the set of connections without listing every one.
+func HashConnectionKeys(keys []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
 func (c Connection) less(r row) bool {
 	o := r.(Connection)
 
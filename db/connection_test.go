@@ -30,7 +30,12 @@ func TestConnection(t *testing.T) {
 
 	connection.MaxPort = 3
 	assert.Equal(t, "Connection-1{foo->:0-3}", connection.String())
+	connection.MTLS = true
+	assert.Equal(t, "Connection-1{foo->:0-3 mTLS}", connection.String())
+	assert.Equal(t, "foo->:0-3/mTLS", connection.Key())
 	connection.MaxPort = 0
+	connection.MTLS = false
+	assert.Equal(t, "foo->:0-0", connection.Key())
 
 	assert.Equal(t, connection, connections.Get(0))
 
@@ -40,3 +45,22 @@ func TestConnection(t *testing.T) {
 	assert.True(t, connection.less(Connection{From: "foo", MinPort: 100}))
 	assert.True(t, connection.less(Connection{From: "foo", ID: id + 1}))
 }
+
+func TestConnectionKeys(t *testing.T) {
+	t.Parallel()
+
+	conns := []Connection{
+		{From: "web", To: "db", MinPort: 5432, MaxPort: 5432},
+		{From: "app", To: "web", MinPort: 80, MaxPort: 80},
+	}
+	keys := ConnectionKeys(conns)
+	assert.Equal(t, []string{"app->web:80-80", "web->db:5432-5432"}, keys)
+
+	// The hash doesn't depend on the order of the connections, but does on
+	// their keys.
+	hash := HashConnectionKeys(keys)
+	assert.Len(t, hash, 16)
+	assert.Equal(t, hash, HashConnectionKeys(ConnectionKeys(
+		[]Connection{conns[1], conns[0]})))
+	assert.NotEqual(t, hash, HashConnectionKeys(keys[:1]))
+}
@@ -58,22 +58,51 @@ const (
 	// Amazon implements Amazon EC2.
 	Amazon ProviderName = "Amazon"
 
+	// Azure implements Microsoft Azure Virtual Machines.
+	Azure ProviderName = "Azure"
+
 	// Google implements Google Cloud Engine.
 	Google ProviderName = "Google"
 
 	// DigitalOcean implements Digital Ocean Droplets.
 	DigitalOcean ProviderName = "DigitalOcean"
 
+	// OpenStack implements OpenStack Compute servers.
+	OpenStack ProviderName = "OpenStack"
+
+	// Alibaba implements Alibaba Cloud ECS instances.
+	Alibaba ProviderName = "Alibaba"
+
+	// Scaleway implements Scaleway Instances.
+	Scaleway ProviderName = "Scaleway"
+
+	// IBM implements IBM Cloud VPC instances.
+	IBM ProviderName = "IBM"
+
 	// Vagrant implements local virtual machines.
 	Vagrant ProviderName = "Vagrant"
+
+	// Static implements pre-existing hosts, such as bare-metal servers, that
+	// are bootstrapped over SSH.
+	Static ProviderName = "Static"
+
+	// Fake implements machines that exist only in the daemon's memory.
+	Fake ProviderName = "Fake"
 )
 
 // AllProviders lists all of the providers that Quilt supports.
 var AllProviders = []ProviderName{
 	Amazon,
+	Azure,
 	Google,
 	DigitalOcean,
+	OpenStack,
+	Alibaba,
+	Scaleway,
+	IBM,
 	Vagrant,
+	Static,
+	Fake,
 }
 
 // ParseProvider returns the ProviderName represented by 'name' or an error.
@@ -100,3 +129,28 @@ func ParseRole(role string) (Role, error) {
 		return None, errors.New("unknown role")
 	}
 }
+
+// PriorityClasses maps the names of the priority classes that containers may
+// request to their relative priority.  When the cluster is out of room, the
+// scheduler may evict containers to make room for ones with a higher priority.
+var PriorityClasses = map[string]int{
+	"low":      -10,
+	"normal":   0,
+	"high":     10,
+	"critical": 20,
+}
+
+// ParsePriorityClass returns the priority represented by the priority class
+// 'class', or an error.  Containers without a priority class have "normal"
+// priority.
+func ParsePriorityClass(class string) (int, error) {
+	if class == "" {
+		return PriorityClasses["normal"], nil
+	}
+
+	priority, ok := PriorityClasses[class]
+	if !ok {
+		return 0, fmt.Errorf("unknown priority class: %s", class)
+	}
+	return priority, nil
+}
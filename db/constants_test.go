@@ -13,7 +13,8 @@ func TestParseProvider(t *testing.T) {
 	_, err := ParseProvider("not_a_provider")
 	assert.Error(t, err)
 	expErr := errors.New("provider not_a_provider not supported (supported " +
-		"providers: [Amazon Google DigitalOcean Vagrant])")
+		"providers: [Amazon Azure Google DigitalOcean OpenStack Alibaba " +
+		"Scaleway IBM Vagrant Static Fake])")
 	assert.Equal(t, expErr, err)
 
 	// Verify that the correct provider is returned for all supported providers.
@@ -23,3 +24,22 @@ func TestParseProvider(t *testing.T) {
 		assert.Equal(t, provider, actualProvider)
 	}
 }
+
+func TestParsePriorityClass(t *testing.T) {
+	t.Parallel()
+
+	priority, err := ParsePriorityClass("")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, priority)
+
+	priority, err = ParsePriorityClass("high")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, priority)
+
+	low, _ := ParsePriorityClass("low")
+	critical, _ := ParsePriorityClass("critical")
+	assert.True(t, low < priority && priority < critical)
+
+	_, err = ParsePriorityClass("urgent")
+	assert.EqualError(t, err, "unknown priority class: urgent")
+}
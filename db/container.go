@@ -24,6 +24,20 @@ type Container struct {
 	Env               map[string]string `json:",omitempty"`
 	FilepathToContent map[string]string `json:",omitempty"`
 	Hostname          string            `json:",omitempty"`
+	Label             string            `json:",omitempty"`
+	Priority          int               `json:",omitempty"`
+	PostStart         []string          `json:",omitempty"`
+	PreStop           []string          `json:",omitempty"`
+	ReloadSignal      string            `json:",omitempty"`
+	Networks          []string          `json:",omitempty"`
+	DNS               []string          `json:",omitempty"`
+	DNSSearch         []string          `json:",omitempty"`
+	ExtraHosts        map[string]string `json:",omitempty"`
+	ExposeMetadata    bool              `json:",omitempty"`
+	CloudRole         string            `json:",omitempty"`
+	TTY               bool              `json:",omitempty"`
+	Stopped           bool              `json:",omitempty"`
+	Draining          bool              `json:",omitempty"`
 	Created           time.Time         `json:","`
 
 	Image      string `json:",omitempty"`
@@ -101,6 +115,22 @@ func (c Container) String() string {
 		tags = append(tags, fmt.Sprintf("Env: %s", c.Env))
 	}
 
+	if len(c.Networks) > 0 {
+		tags = append(tags, fmt.Sprintf("Networks: %s", c.Networks))
+	}
+
+	if len(c.DNS) > 0 {
+		tags = append(tags, fmt.Sprintf("DNS: %s", c.DNS))
+	}
+
+	if c.Draining {
+		tags = append(tags, "Draining")
+	}
+
+	if c.Priority != 0 {
+		tags = append(tags, fmt.Sprintf("Priority: %d", c.Priority))
+	}
+
 	if len(c.Status) > 0 {
 		tags = append(tags, fmt.Sprintf("Status: %s", c.Status))
 	}
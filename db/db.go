@@ -17,6 +17,9 @@ import (
 type Database struct {
 	tables  map[TableType]*table
 	idAlloc *idCounter
+
+	// The changes made by the transaction, if they're being recorded.
+	changes *[]Change
 }
 
 // A Trigger sends notifications when anything in their corresponding table changes.
@@ -33,12 +36,15 @@ type row interface {
 
 // A Conn is a database handle on which Transactions may be created.
 type Conn struct {
-	db Database
+	db        Database
+	clock     Clock
+	recorders *recorders
 }
 
 // A Transaction is a database handle on which transactions may be executed.
 type Transaction struct {
-	db Database
+	db        Database
+	recorders *recorders
 }
 
 // An idCounter is a wrapper around the global DB id providing concurrency safe use
@@ -55,12 +61,18 @@ var selectC = counter.New("Database Select")
 
 // New creates a connection to a brand new database.
 func New() Conn {
-	db := Database{make(map[TableType]*table), &idCounter{}}
+	return NewWithClock(realClock{})
+}
+
+// NewWithClock creates a connection to a brand new database whose TriggerTicks
+// use `clock`.
+func NewWithClock(clock Clock) Conn {
+	db := Database{tables: make(map[TableType]*table), idAlloc: &idCounter{}}
 	for _, t := range AllTables {
 		db.tables[t] = newTable()
 	}
 
-	cn := Conn{db: db}
+	cn := Conn{db: db, clock: clock, recorders: &recorders{}}
 	cn.runLogger()
 	return cn
 }
@@ -69,12 +81,18 @@ func New() Conn {
 // restricted access to only the given tables.
 func (cn Conn) Txn(tables ...TableType) Transaction {
 	// The Transaction has the same database data, just a subset of the tables.
-	db := Database{make(map[TableType]*table), cn.db.idAlloc}
+	db := Database{tables: make(map[TableType]*table), idAlloc: cn.db.idAlloc}
 	for _, t := range tables {
 		db.tables[t] = cn.db.accessTable(t)
 	}
 
-	return Transaction{db: db}
+	cn.recorders.Lock()
+	if len(cn.recorders.fns) > 0 {
+		db.changes = &[]Change{}
+	}
+	cn.recorders.Unlock()
+
+	return Transaction{db: db, recorders: cn.recorders}
 }
 
 // Run executes database transactions.  It takes a closure, 'do', which is operates
@@ -87,6 +105,9 @@ func (tr Transaction) Run(do func(db Database) error) error {
 	defer tr.unlockTables()
 
 	err := do(tr.db)
+	tr.db.checkIntegrity()
+	tr.notifyRecorders()
+
 	var alertTables []*table
 	for _, table := range tr.db.tables {
 		if table.shouldAlert {
@@ -123,23 +144,19 @@ func (cn Conn) Trigger(tt ...TableType) Trigger {
 func (cn Conn) TriggerTick(seconds int, tt ...TableType) Trigger {
 	trigger := cn.Trigger(tt...)
 
-	go func() {
-		ticker := time.NewTicker(time.Duration(seconds) * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case trigger.C <- struct{}{}:
-				c.Inc("Trigger")
-			default:
-			}
-
-			select {
-			case <-ticker.C:
-			case <-trigger.stop:
-				return
-			}
+	tick := func() {
+		select {
+		case trigger.C <- struct{}{}:
+			c.Inc("Trigger")
+		default:
 		}
+	}
+
+	tick()
+	stop := cn.clock.Every(time.Duration(seconds)*time.Second, tick)
+	go func() {
+		<-trigger.stop
+		stop()
 	}()
 
 	return trigger
@@ -183,6 +200,7 @@ func (db Database) insert(r row) {
 	table := db.accessTable(getTableType(r))
 	table.shouldAlert = true
 	table.rows[r.getID()] = r
+	db.record(Inserted, r)
 }
 
 // Commit updates the database with the data contained in row.
@@ -199,6 +217,7 @@ func (db Database) Commit(r row) {
 	if table.shouldAlert || !reflect.DeepEqual(r, old) {
 		table.rows[rid] = r
 		table.shouldAlert = true
+		db.record(Committed, r)
 	}
 }
 
@@ -208,6 +227,7 @@ func (db Database) Remove(r row) {
 	table := db.accessTable(getTableType(r))
 	delete(table.rows, r.getID())
 	table.shouldAlert = true
+	db.record(Removed, r)
 }
 
 func (db Database) nextID() int {
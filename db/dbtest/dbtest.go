@@ -0,0 +1,125 @@
+// Package dbtest provides a database harness for deterministic tests of the loops
+// that drive Quilt, such as those in the cloud providers and the scheduler.  The
+// harness's triggers tick in virtual time that only advances when the test says
+// so, and its transactions are recorded so that they may be replayed.
+package dbtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/db"
+)
+
+// A Harness is a database whose TriggerTicks are driven by a virtual Clock, and
+// which records the changes made by every transaction.
+type Harness struct {
+	db.Conn
+	Clock *Clock
+
+	mu   sync.Mutex
+	txns [][]db.Change
+}
+
+// New creates a Harness with a brand new database.  Its clock starts at the Unix
+// epoch.
+func New() *Harness {
+	h := &Harness{Clock: NewClock(time.Unix(0, 0))}
+	h.Conn = db.NewWithClock(h.Clock)
+	h.Conn.Record(func(changes []db.Change) {
+		h.mu.Lock()
+		h.txns = append(h.txns, changes)
+		h.mu.Unlock()
+	})
+	return h
+}
+
+// Advance moves the harness's clock forward by `d`, firing any tickers that come
+// due.
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+// Transactions returns the changes made by each recorded transaction, in the order
+// the transactions ran.
+func (h *Harness) Transactions() [][]db.Change {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([][]db.Change{}, h.txns...)
+}
+
+// Replay applies every recorded transaction to `conn`, leaving it in the same
+// state as the harness's database.
+func (h *Harness) Replay(conn db.Conn) {
+	for _, changes := range h.Transactions() {
+		conn.Replay(changes)
+	}
+}
+
+// A Clock is a db.Clock whose time only moves when it's advanced.  Ticks are
+// delivered synchronously by Advance, so their effects are visible as soon as it
+// returns.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+type ticker struct {
+	tick    func()
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+// NewClock creates a Clock whose time starts at `now`.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Every calls `tick` every `d` of virtual time, until the returned function is
+// called.
+func (c *Clock) Every(d time.Duration, tick func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &ticker{tick: tick, period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		t.stopped = true
+	}
+}
+
+// Advance moves the clock forward by `d`, and calls the ticks that come due before
+// returning.  Like time.Ticker, which drops ticks when its receiver falls behind,
+// each ticker fires at most once per call, even if `d` spans several periods.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []func()
+	for _, t := range c.tickers {
+		if t.stopped || t.next.After(c.now) {
+			continue
+		}
+
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.period)
+		}
+		due = append(due, t.tick)
+	}
+	c.mu.Unlock()
+
+	// The ticks are called without the lock, so they may use the clock.
+	for _, tick := range due {
+		tick()
+	}
+}
@@ -0,0 +1,93 @@
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerTick(t *testing.T) {
+	t.Parallel()
+
+	h := New()
+	trig := h.TriggerTick(30, db.MachineTable)
+	defer trig.Stop()
+
+	// TriggerTick always fires when it's created.
+	assert.True(t, fired(trig))
+
+	h.Advance(29 * time.Second)
+	assert.False(t, fired(trig))
+
+	h.Advance(time.Second)
+	assert.True(t, fired(trig))
+	assert.Equal(t, time.Unix(30, 0), h.Clock.Now())
+
+	// Advancing by several periods at once only ticks once.
+	h.Advance(90 * time.Second)
+	assert.True(t, fired(trig))
+	assert.False(t, fired(trig))
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	h := New()
+	var id int
+	h.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMachine()
+		m.Role = db.Master
+		view.Commit(m)
+		id = m.ID
+
+		m = view.InsertMachine()
+		m.Role = db.Worker
+		view.Commit(m)
+
+		view.InsertEtcd()
+		return nil
+	})
+
+	h.Txn(db.MachineTable).Run(func(view db.Database) error {
+		for _, m := range view.SelectFromMachine(nil) {
+			if m.ID == id {
+				view.Remove(m)
+			} else {
+				m.PublicIP = "8.8.8.8"
+				view.Commit(m)
+			}
+		}
+		return nil
+	})
+
+	// Transactions that don't change anything aren't recorded.
+	h.Txn(db.MachineTable).Run(func(view db.Database) error {
+		view.SelectFromMachine(nil)
+		return nil
+	})
+	assert.Len(t, h.Transactions(), 2)
+
+	conn := db.New()
+	h.Replay(conn)
+	assert.Equal(t, h.SelectFromMachine(nil), conn.SelectFromMachine(nil))
+	assert.Equal(t, h.SelectFromEtcd(nil), conn.SelectFromEtcd(nil))
+
+	// New rows don't reuse the IDs of replayed rows.
+	conn.Txn(db.MachineTable).Run(func(view db.Database) error {
+		assert.True(t, view.InsertMachine().ID > id)
+		return nil
+	})
+}
+
+// fired reports whether `trig` has fired.  The harness's clock delivers ticks
+// before Advance returns, so there's no need to wait for them.
+func fired(trig db.Trigger) bool {
+	select {
+	case <-trig.C:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,7 +1,7 @@
 package db
 
 // An Image row represents a Docker image that should be built by the Quilt
-// masters.
+// workers, and pushed to the leader's registry.
 type Image struct {
 	ID int
 
@@ -16,6 +16,14 @@ type Image struct {
 
 	// The build status of the image.
 	Status string
+
+	// The private IP of the worker that the leader chose to build the image.
+	Minion string
+
+	// The private IP of the leader whose registry the image is pushed to.
+	// Each master runs its own registry, so the image is rebuilt when the
+	// leader changes.
+	Registry string
 }
 
 const (
@@ -82,3 +90,17 @@ func (slc ImageSlice) Get(ii int) interface{} {
 func (slc ImageSlice) Len() int {
 	return len(slc)
 }
+
+// Less implements less than for sort.Interface.  Images are ordered by name and
+// Dockerfile rather than ID, as the IDs differ between minions.
+func (slc ImageSlice) Less(i, j int) bool {
+	if slc[i].Name != slc[j].Name {
+		return slc[i].Name < slc[j].Name
+	}
+	return slc[i].Dockerfile < slc[j].Dockerfile
+}
+
+// Swap implements swapping for sort.Interface.
+func (slc ImageSlice) Swap(i, j int) {
+	slc[i], slc[j] = slc[j], slc[i]
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/counter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// An invariant is a relationship between tables that should always hold.  A
+// violation doesn't prevent the transaction from committing, as the modules that
+// populate the tables will eventually reconcile them.  Instead, violations are
+// logged and counted so that reconciliation bugs don't go unnoticed.
+type invariant struct {
+	name   string
+	tables []TableType
+
+	// Returns a description of each violation of the invariant.
+	check func(db Database) []string
+}
+
+var invariants = []invariant{
+	{
+		name:   "Container Minion",
+		tables: []TableType{ContainerTable, MinionTable},
+		check:  checkContainerMinions,
+	},
+	{
+		name: "Connection Hostname",
+		tables: []TableType{ConnectionTable, ContainerTable,
+			LoadBalancerTable, MinionTable},
+		check: checkConnectionHostnames,
+	},
+}
+
+var integrityC = counter.New("Database Integrity")
+
+// The violations that have already been logged, so that persistent violations
+// don't flood the logs.
+var reported = struct {
+	sync.Mutex
+	violations map[string]struct{}
+}{violations: map[string]struct{}{}}
+
+// checkIntegrity checks the invariants that involve tables modified by the
+// transaction, and for which the transaction has access to every table.
+func (db Database) checkIntegrity() {
+	for _, inv := range invariants {
+		if !db.shouldCheck(inv) {
+			continue
+		}
+
+		violations := inv.check(db)
+		for range violations {
+			integrityC.Inc(inv.name)
+		}
+		reportViolations(inv.name, violations)
+	}
+}
+
+func (db Database) shouldCheck(inv invariant) bool {
+	var changed bool
+	for _, tt := range inv.tables {
+		table, ok := db.tables[tt]
+		if !ok {
+			return false
+		}
+		changed = changed || table.shouldAlert
+	}
+	return changed
+}
+
+func reportViolations(name string, violations []string) {
+	reported.Lock()
+	defer reported.Unlock()
+
+	current := map[string]struct{}{}
+	for _, violation := range violations {
+		key := name + ": " + violation
+		current[key] = struct{}{}
+		if _, ok := reported.violations[key]; !ok {
+			log.WithField("invariant", name).Warn(
+				"Database integrity violation: " + violation)
+		}
+	}
+
+	for key := range reported.violations {
+		if _, ok := current[key]; !ok && strings.HasPrefix(key, name+": ") {
+			delete(reported.violations, key)
+		}
+	}
+	for key := range current {
+		reported.violations[key] = struct{}{}
+	}
+}
+
+// Containers must be assigned to minions that exist.
+func checkContainerMinions(db Database) []string {
+	minions := map[string]struct{}{}
+	for _, m := range db.SelectFromMinion(nil) {
+		minions[m.PrivateIP] = struct{}{}
+	}
+
+	var violations []string
+	for _, dbc := range db.SelectFromContainer(func(dbc Container) bool {
+		return dbc.Minion != ""
+	}) {
+		if _, ok := minions[dbc.Minion]; !ok {
+			violations = append(violations, fmt.Sprintf(
+				"container %s is assigned to unknown minion %s",
+				dbc.BlueprintID, dbc.Minion))
+		}
+	}
+	return violations
+}
+
+// Connections must be between the hostnames of containers or load balancers, or
+// the public internet.  Workers only know about their own containers, so the
+// invariant is only checked on masters.
+func checkConnectionHostnames(db Database) []string {
+	if len(db.SelectFromMinion(func(m Minion) bool {
+		return m.Self && m.Role == Worker
+	})) > 0 {
+		return nil
+	}
+
+	hostnames := map[string]struct{}{blueprint.PublicInternetLabel: {}}
+	for _, dbc := range db.SelectFromContainer(nil) {
+		hostnames[dbc.Hostname] = struct{}{}
+	}
+	for _, lb := range db.SelectFromLoadBalancer(nil) {
+		hostnames[lb.Name] = struct{}{}
+	}
+
+	var violations []string
+	for _, conn := range db.SelectFromConnection(nil) {
+		for _, hostname := range []string{conn.From, conn.To} {
+			if _, ok := hostnames[hostname]; !ok {
+				violations = append(violations, fmt.Sprintf(
+					"connection %s->%s references unknown hostname %s",
+					conn.From, conn.To, hostname))
+			}
+		}
+	}
+	return violations
+}
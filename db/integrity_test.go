@@ -0,0 +1,101 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckContainerMinions(t *testing.T) {
+	t.Parallel()
+
+	conn := New()
+	conn.Txn(AllTables...).Run(func(view Database) error {
+		m := view.InsertMinion()
+		m.PrivateIP = "1"
+		view.Commit(m)
+
+		dbc := view.InsertContainer()
+		dbc.BlueprintID = "placed"
+		dbc.Minion = "1"
+		view.Commit(dbc)
+
+		dbc = view.InsertContainer()
+		dbc.BlueprintID = "unplaced"
+		view.Commit(dbc)
+
+		assert.Empty(t, checkContainerMinions(view))
+
+		dbc = view.InsertContainer()
+		dbc.BlueprintID = "orphan"
+		dbc.Minion = "2"
+		view.Commit(dbc)
+
+		assert.Equal(t, []string{
+			"container orphan is assigned to unknown minion 2"},
+			checkContainerMinions(view))
+		return nil
+	})
+}
+
+func TestCheckConnectionHostnames(t *testing.T) {
+	t.Parallel()
+
+	conn := New()
+	conn.Txn(AllTables...).Run(func(view Database) error {
+		dbc := view.InsertContainer()
+		dbc.Hostname = "red"
+		view.Commit(dbc)
+
+		lb := view.InsertLoadBalancer()
+		lb.Name = "blue"
+		view.Commit(lb)
+
+		c := view.InsertConnection()
+		c.From = "red"
+		c.To = "blue"
+		view.Commit(c)
+
+		c = view.InsertConnection()
+		c.From = "public"
+		c.To = "red"
+		view.Commit(c)
+
+		assert.Empty(t, checkConnectionHostnames(view))
+
+		c = view.InsertConnection()
+		c.From = "red"
+		c.To = "yellow"
+		view.Commit(c)
+
+		exp := []string{"connection red->yellow references unknown hostname yellow"}
+		assert.Equal(t, exp, checkConnectionHostnames(view))
+
+		// Workers don't know about every container.
+		m := view.InsertMinion()
+		m.Self = true
+		m.Role = Worker
+		view.Commit(m)
+		assert.Empty(t, checkConnectionHostnames(view))
+		return nil
+	})
+}
+
+func TestShouldCheck(t *testing.T) {
+	t.Parallel()
+
+	conn := New()
+	inv := invariant{tables: []TableType{ContainerTable, MinionTable}}
+	conn.Txn(ContainerTable).Run(func(view Database) error {
+		view.InsertContainer()
+		assert.False(t, view.shouldCheck(inv))
+		return nil
+	})
+
+	conn.Txn(ContainerTable, MinionTable).Run(func(view Database) error {
+		assert.False(t, view.shouldCheck(inv))
+		view.InsertMinion()
+		assert.True(t, view.shouldCheck(inv))
+		return nil
+	})
+}
@@ -1,5 +1,7 @@
 package db
 
+import "time"
+
 // A LoadBalancer row is created for each load balancer specified by the policy.
 type LoadBalancer struct {
 	ID int
@@ -7,6 +9,18 @@ type LoadBalancer struct {
 	Name      string
 	IP        string
 	Hostnames []string
+
+	// The containers that receive CanaryFraction of the traffic, while the
+	// rest is sent to Hostnames.
+	CanaryHostnames []string
+	CanaryFraction  float64
+
+	// Containers removed from the load balancer stop receiving new
+	// connections, but are kept running for DrainPeriod seconds so that their
+	// established connections can finish.  Draining maps the hostname of each
+	// such container to the time its drain ends.
+	DrainPeriod int
+	Draining    map[string]time.Time `rowStringer:"omit"`
 }
 
 // LoadBalancerSlice is an alias for []LoadBalancer to allow for joins
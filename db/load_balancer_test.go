@@ -28,7 +28,8 @@ func TestLoadBalancer(t *testing.T) {
 	assert.Equal(t, "foo", loadBalancer.Name)
 	assert.Equal(t, id, loadBalancer.getID())
 
-	assert.Equal(t, "LoadBalancer-1{Name=foo, Hostnames=[]}", loadBalancer.String())
+	assert.Equal(t, "LoadBalancer-1{Name=foo, Hostnames=[], CanaryHostnames=[]}",
+		loadBalancer.String())
 
 	assert.Equal(t, loadBalancer, loadBalancers.Get(0))
 
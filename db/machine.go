@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/kelda/kelda/blueprint"
 )
 
 // Machine represents a physical or virtual machine operated by a cloud provider on
@@ -21,17 +24,114 @@ type Machine struct {
 	SSHKeys     []string `rowStringer:"omit"`
 	FloatingIP  string
 	Preemptible bool
+	MaxPrice    float64
+	Sysctls     map[string]string `rowStringer:"omit"`
+	Hugepages   int
+	Hooks       []blueprint.MachineHook `rowStringer:"omit"`
+	Bootstrap   string
+	Hostname    string
+	Tags        map[string]string `rowStringer:"omit"`
+	Labels      map[string]string `rowStringer:"omit"`
+	VPC         string
+	Subnet      string
+
+	InstanceProfile string
+
+	// The sorted IDs of the existing Amazon security groups that the machine is
+	// in, besides the group that Quilt manages.
+	SecurityGroups []string `rowStringer:"omit"`
+
+	// Whether the machine's floating IP is allocated by its provider, rather
+	// than chosen by the blueprint.  Once it has been allocated, FloatingIP
+	// holds the address.
+	AutoFloatingIP bool
+
+	// The availability zone of the machine within its region, and the Amazon
+	// placement group that it's launched into.
+	Zone           string
+	PlacementGroup string
+
+	// The image that the machine boots from, if not the provider's default.
+	Image string
+
+	// The type of the machine's volume, and its provisioned IOPS, if not the
+	// provider's defaults.
+	VolumeType string
+	IOPS       int
+	DiskType   string
+
+	// The block storage volumes attached to the machine, sorted by name.
+	Volumes []blueprint.MachineVolume `rowStringer:"omit"`
+
+	// Whether the machine has a public IPv6 address.
+	IPv6 bool
+
+	// The number and type of the machine's GPUs.
+	GPUs    int
+	GPUType string
+
+	// The CPU architecture of the machine's size.  Empty for AMD64.
+	Arch string
+
+	// The address of the pre-existing host that the Static provider manages,
+	// and the credentials with which it logs in to the host over SSH.
+	Host       string
+	SSHUser    string
+	SSHKeyPath string `rowStringer:"omit"`
+
+	// Whether the cloud may boot the preemptible machine on-demand instead if
+	// preemptible capacity is unavailable.
+	OnDemandFallback bool
 
 	/* Populated by the cloud provider. */
-	CloudID   string //Cloud Provider ID
-	PublicIP  string
-	PrivateIP string
+	CloudID    string //Cloud Provider ID
+	PublicIP   string
+	PrivateIP  string
+	PublicIPv6 string
+
+	// Whether the machine is about to be reclaimed by its provider, as
+	// announced by the provider or the machine's minion.  Interrupted machines
+	// are drained, and replaced by the policy engine.
+	Interrupted bool
+
+	// Whether the preemptible machine was booted on-demand instead, because
+	// preemptible capacity was repeatedly unavailable.  It only applies while
+	// OnDemandFallback is set.
+	OnDemand bool
 
 	/* Populated by the cluster. */
 	Status string
+
+	// Why the machine isn't being booted, if its status is QuotaExceeded.
+	BootError string
+
+	// The generation of the most recent MinionConfig sent to the machine's
+	// minion, and of the most recent one that the minion acknowledged applying.
+	// The minion runs a stale configuration while they differ.
+	ConfigGeneration  int64
+	AppliedGeneration int64
+
+	// The error with which the minion rejected the most recent MinionConfig, if
+	// it did.
+	ConfigError string
+
+	/* Populated by the API server. */
+	// An administrative action, either RebootAction or ReimageAction, that was
+	// requested for the machine.  It's performed once the machine's containers
+	// have been drained, and then cleared.
+	Action          string
+	ActionRequested time.Time `rowStringer:"omit"`
 }
 
 const (
+	// CredentialsMissing represents that the machine can't be booted, because
+	// the credentials of its provider weren't found.
+	CredentialsMissing = "credentials-missing"
+
+	// QuotaExceeded represents that the machine isn't booted, because it would
+	// exceed one of its provider's quotas.
+	QuotaExceeded = "quota-exceeded"
+
 	// Booting represents that the machine is being booted by a cloud provider.
 	Booting = "booting"
 
@@ -46,6 +146,26 @@ const (
 	// Connected represents that we are currently connected to the machine's
 	// minion.
 	Connected = "connected"
+
+	// NetworkDegraded represents that we are connected to the machine's minion,
+	// but it failed to repair its OpenVSwitch, so its containers are being
+	// drained.
+	NetworkDegraded = "network-degraded"
+
+	// Draining represents that we are connected to the machine's minion, and its
+	// containers are being drained so that a requested action can be performed,
+	// or because the machine is about to be reclaimed by its provider.
+	Draining = "draining"
+)
+
+// The administrative actions that may be requested for a machine.
+const (
+	// RebootAction reboots the machine in place with its provider's API.
+	RebootAction = "reboot"
+
+	// ReimageAction replaces the machine with a new one booted from a fresh
+	// image.
+	ReimageAction = "reimage"
 )
 
 // InsertMachine creates a new Machine and inserts it into 'db'.
@@ -91,8 +211,15 @@ func (m Machine) String() string {
 		tags = append(tags, string(m.Role))
 	}
 
-	machineAttrs := []string{string(m.Provider), m.Region, m.Size}
-	if m.Preemptible {
+	region := m.Region
+	if m.Zone != "" {
+		region = m.Zone
+	}
+
+	machineAttrs := []string{string(m.Provider), region, m.Size}
+	if m.Preemptible && m.OnDemand && m.OnDemandFallback {
+		machineAttrs = append(machineAttrs, "on-demand fallback")
+	} else if m.Preemptible {
 		machineAttrs = append(machineAttrs, "preemptible")
 	}
 	tags = append(tags, strings.Join(machineAttrs, " "))
@@ -109,6 +236,10 @@ func (m Machine) String() string {
 		tags = append(tags, "PrivateIP="+m.PrivateIP)
 	}
 
+	if m.PublicIPv6 != "" {
+		tags = append(tags, "PublicIPv6="+m.PublicIPv6)
+	}
+
 	if m.FloatingIP != "" {
 		tags = append(tags, fmt.Sprintf("FloatingIP=%s", m.FloatingIP))
 	}
@@ -117,10 +248,34 @@ func (m Machine) String() string {
 		tags = append(tags, fmt.Sprintf("Disk=%dGB", m.DiskSize))
 	}
 
+	if m.DiskType != "" {
+		tags = append(tags, fmt.Sprintf("Disk=%s", m.DiskType))
+	}
+
+	if m.VolumeType != "" {
+		tags = append(tags, fmt.Sprintf("Volume=%s", m.VolumeType))
+	}
+
+	if m.IOPS != 0 {
+		tags = append(tags, fmt.Sprintf("IOPS=%d", m.IOPS))
+	}
+
+	if m.Hugepages != 0 {
+		tags = append(tags, fmt.Sprintf("Hugepages=%d", m.Hugepages))
+	}
+
+	if m.GPUs != 0 {
+		tags = append(tags, fmt.Sprintf("GPUs=%d %s", m.GPUs, m.GPUType))
+	}
+
 	if m.Status != "" {
 		tags = append(tags, m.Status)
 	}
 
+	if m.Action != "" {
+		tags = append(tags, "Action="+m.Action)
+	}
+
 	return fmt.Sprintf("Machine-%d{%s}", m.ID, strings.Join(tags, ", "))
 }
 
@@ -154,6 +309,19 @@ func SortMachines(machines []Machine) []Machine {
 	return machines
 }
 
+// VolumesEqual returns whether the sorted machine volumes `x` and `y` are equal.
+func VolumesEqual(x, y []blueprint.MachineVolume) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // MachineSlice is an alias for []Machine to allow for joins
 type MachineSlice []Machine
 
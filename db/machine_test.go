@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/kelda/kelda/blueprint"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -121,17 +122,66 @@ func TestMachineString(t *testing.T) {
 		Size:        "m4.large",
 		PublicIP:    "1.2.3.4",
 		PrivateIP:   "5.6.7.8",
+		PublicIPv6:  "2001:db8::1",
 		FloatingIP:  "8.9.3.2",
 		DiskSize:    56,
-		Status:      Connected,
+		Status:      Draining,
+		Action:      RebootAction,
 	}
 	got = m.String()
 	exp = "Machine-1{1, Worker, Amazon us-west-1 m4.large preemptible, " +
-		"CloudID1234, PublicIP=1.2.3.4, PrivateIP=5.6.7.8, FloatingIP=8.9.3.2," +
-		" Disk=56GB, connected}"
+		"CloudID1234, PublicIP=1.2.3.4, PrivateIP=5.6.7.8, " +
+		"PublicIPv6=2001:db8::1, FloatingIP=8.9.3.2, Disk=56GB, draining, " +
+		"Action=reboot}"
 	if got != exp {
 		t.Errorf("\nGot: %s\nExp: %s", got, exp)
 	}
+
+	m = Machine{
+		ID:               1,
+		Role:             Worker,
+		Preemptible:      true,
+		OnDemandFallback: true,
+		OnDemand:         true,
+		Provider:         "Amazon",
+		Region:           "us-west-1",
+		Size:             "m4.large",
+	}
+	got = m.String()
+	exp = "Machine-1{Worker, Amazon us-west-1 m4.large on-demand fallback}"
+	if got != exp {
+		t.Errorf("\nGot: %s\nExp: %s", got, exp)
+	}
+
+	m = Machine{
+		ID:       1,
+		Role:     Master,
+		Provider: "Amazon",
+		Region:   "us-west-2",
+		Zone:     "us-west-2b",
+		Size:     "m4.large",
+	}
+	got = m.String()
+	exp = "Machine-1{Master, Amazon us-west-2b m4.large}"
+	if got != exp {
+		t.Errorf("\nGot: %s\nExp: %s", got, exp)
+	}
+}
+
+func TestVolumesEqual(t *testing.T) {
+	data := blueprint.MachineVolume{Name: "data", Size: 100}
+	scratch := blueprint.MachineVolume{Name: "scratch", Size: 10}
+
+	assert.True(t, VolumesEqual(nil, []blueprint.MachineVolume{}))
+	assert.True(t, VolumesEqual([]blueprint.MachineVolume{data, scratch},
+		[]blueprint.MachineVolume{data, scratch}))
+	assert.False(t, VolumesEqual([]blueprint.MachineVolume{data},
+		[]blueprint.MachineVolume{data, scratch}))
+
+	persistent := data
+	persistent.Persistent = true
+	assert.False(t, VolumesEqual([]blueprint.MachineVolume{data},
+		[]blueprint.MachineVolume{persistent}))
 }
 
 func SelectMachineCheck(db Database, do func(Machine) bool, expected []Machine) error {
@@ -1,5 +1,7 @@
 package db
 
+import "github.com/kelda/kelda/cloud/acl"
+
 // The Minion table is instantiated on the minions with one row.  That row contains the
 // configuration that minion needs to operate, including its ID, Role, and IP address
 type Minion struct {
@@ -17,6 +19,83 @@ type Minion struct {
 	Region      string
 	FloatingIP  string
 	HostSubnets []string
+
+	// The labels of the minion's machine, which placement rules may require.
+	Labels map[string]string `json:",omitempty" rowStringer:"omit"`
+
+	// BlueprintIDs of the containers that repeatedly failed to boot on this
+	// minion, and thus should be scheduled elsewhere.
+	FailedContainers []string `json:",omitempty"`
+
+	// IPs of the containers on this minion that failed their most recent
+	// health check.
+	UnhealthyContainers []string `json:",omitempty"`
+
+	// The hash (see HashConnectionKeys) of the connections whose network rules
+	// are installed on this minion.  Only the hash is shared, as the
+	// connections themselves would grow every minion's row with the size of
+	// the deployment.
+	ProgrammedConnections string `json:",omitempty" rowStringer:"omit"`
+
+	// Maps the images that this minion built for the leader, identified by
+	// their build keys, to the Docker IDs of the built images.
+	BuiltImages map[string]string `json:",omitempty" rowStringer:"omit"`
+
+	// Set when OpenVSwitch is broken on this minion and couldn't be repaired.
+	NetworkDegraded bool `json:",omitempty"`
+
+	// Set by the daemon when the minion's machine is about to be rebooted or
+	// reimaged, so that its containers are scheduled elsewhere.
+	Drain bool `json:",omitempty"`
+
+	// Set when the provider announces that this minion's machine is about to
+	// be reclaimed, as happens to preemptible machines, so that its containers
+	// are scheduled elsewhere before it's terminated.
+	Interrupted bool `json:",omitempty"`
+
+	// Maps the partitions of this minion's machine, e.g. "root" and "docker",
+	// to the percentage of their space that's used.  It's reported to the
+	// daemon rather than shared with the other minions, as it changes often.
+	DiskUsage map[string]int `json:"-"`
+
+	// Whether unused images and stopped containers should be removed when a
+	// partition is nearly full.  Set by the daemon.
+	DiskGC bool `json:"-"`
+
+	// The traffic that this minion's machine should accept on its public
+	// interface.  If empty, the traffic isn't filtered.  Set by the daemon.
+	ACLs []acl.ACL `json:"-"`
+
+	// The public IP of this minion's machine, which is exposed to the
+	// containers that request their machine's metadata.  Set by the daemon.
+	PublicIP string `json:"-"`
+
+	// Maps etcd users to their passwords.  Set by the daemon.
+	EtcdPasswords map[string]string `json:"-" rowStringer:"omit"`
+
+	// The bundled state of the daemon, if the namespace is self-hosted.  Only
+	// set on masters, which run the daemon with it.  Set by the daemon.
+	SelfHost []byte `json:"-" rowStringer:"omit"`
+
+	// Whether the daemon instructed this master to stop running the
+	// self-hosted daemon and to delete its state.  Set by the daemon.
+	DisableSelfHost bool `json:"-" rowStringer:"omit"`
+
+	// Whether this master is running the self-hosted daemon.
+	SelfHosted bool `json:"-" rowStringer:"omit"`
+
+	// The generation of the last config that the daemon sent this minion,
+	// which is reported back so that the daemon knows the config was applied.
+	ConfigGeneration int64 `json:"-" rowStringer:"omit"`
+
+	// Maps the hostnames of this minion's containers that run a TLS proxy to
+	// the PEM-encoded private keys of their proxies.  Only the public keys are
+	// reported to the daemon, which issues the certificates.
+	ContainerKeys map[string]string `json:"-" rowStringer:"omit"`
+
+	// Maps the hostnames in ContainerKeys to the certificates that the daemon
+	// issued for their keys.  Set by the daemon.
+	ContainerCerts map[string]string `json:"-" rowStringer:"omit"`
 }
 
 // InsertMinion creates a new Minion and inserts it into 'db'.
@@ -20,6 +20,9 @@ type Placement struct {
 	Size       string
 	Region     string
 	FloatingIP string
+
+	// A machine label in the form "key=value" that the machine must have.
+	Label string
 }
 
 // PlacementSlice is an alias for []Placement to allow for joins
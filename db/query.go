@@ -0,0 +1,349 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The operators supported by Query.Where.
+const (
+	Equal          = "="
+	NotEqual       = "!="
+	Less           = "<"
+	LessOrEqual    = "<="
+	Greater        = ">"
+	GreaterOrEqual = ">="
+
+	// Has matches rows whose slice or map field contains the value (as a key,
+	// in the case of maps).
+	Has = "has"
+)
+
+// A Query selects, orders, and limits database rows, e.g. with SelectFromQuery.
+// Unlike the predicate closures passed to the other SelectFrom functions, a Query
+// is plain data, so it may be serialized and evaluated remotely.  Queries are
+// built by chaining calls, e.g.
+// db.Where("Role", db.Equal, db.Worker).OrderBy("PrivateIP").Limit(3).
+type Query struct {
+	Filters []Filter `json:",omitempty"`
+
+	// The field to sort by.  If prefixed with "-", the order is descending.
+	Order string `json:",omitempty"`
+
+	// The maximum number of rows to return.  Zero means no limit.
+	Max int `json:",omitempty"`
+}
+
+// A Filter matches the rows whose `Field` compares to `Value` according to `Op`.
+type Filter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// Where returns a Query that matches the rows whose `field` compares to `value`
+// according to `op`.
+func Where(field, op string, value interface{}) Query {
+	return Query{}.Where(field, op, value)
+}
+
+// Where adds a filter to `q`.  Rows must match every filter.  Equality is
+// evaluated on the string representation of the field, so `value` may be of any
+// type that prints the same way.
+func (q Query) Where(field, op string, value interface{}) Query {
+	q.Filters = append(q.Filters[:len(q.Filters):len(q.Filters)],
+		Filter{Field: field, Op: op, Value: fmt.Sprint(value)})
+	return q
+}
+
+// OrderBy sorts the results of `q` by `field`.  Prefix `field` with "-" to sort in
+// descending order.
+func (q Query) OrderBy(field string) Query {
+	q.Order = field
+	return q
+}
+
+// Limit restricts `q` to return at most `n` rows.
+func (q Query) Limit(n int) Query {
+	q.Max = n
+	return q
+}
+
+// SelectFromQuery replaces the contents of `rows`, a pointer to a slice of
+// database rows such as *[]Machine, with the rows of their table that match `q`.
+// Rows are ordered by ID unless `q` orders them otherwise.
+func (db Database) SelectFromQuery(q Query, rows interface{}) error {
+	table, err := queryTable(rows)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	tableRows := db.selectRows(table)
+	for id := range tableRows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	slice := reflect.ValueOf(rows).Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, len(ids))
+	for _, id := range ids {
+		result = reflect.Append(result, reflect.ValueOf(tableRows[id]))
+	}
+	slice.Set(result)
+	return q.Apply(rows)
+}
+
+// SelectFromQuery gets the rows that match `q`, as Database.SelectFromQuery does.
+func (cn Conn) SelectFromQuery(q Query, rows interface{}) error {
+	table, err := queryTable(rows)
+	if err != nil {
+		return err
+	}
+
+	return cn.Txn(table).Run(func(view Database) error {
+		return view.SelectFromQuery(q, rows)
+	})
+}
+
+// queryTable returns the table whose rows are elements of `rows`.
+func queryTable(rows interface{}) (TableType, error) {
+	if err := checkRows(rows); err != nil {
+		return "", err
+	}
+
+	rowType := reflect.TypeOf(rows).Elem().Elem()
+	for _, table := range AllTables {
+		if TableType(rowType.String()) == table {
+			return table, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a database row", rowType)
+}
+
+func checkRows(rows interface{}) error {
+	ptr := reflect.ValueOf(rows)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice ||
+		ptr.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.New("query rows must be a pointer to a slice of structs")
+	}
+	return nil
+}
+
+// Apply evaluates `q` on `rows`, a pointer to a slice of structs such as
+// *[]Machine, and replaces its contents with the result.
+func (q Query) Apply(rows interface{}) error {
+	if err := checkRows(rows); err != nil {
+		return err
+	}
+
+	slice := reflect.ValueOf(rows).Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		match, err := q.match(slice.Index(i))
+		if err != nil {
+			return err
+		}
+
+		if match {
+			result = reflect.Append(result, slice.Index(i))
+		}
+	}
+
+	if q.Order != "" {
+		field := strings.TrimPrefix(q.Order, "-")
+		desc := field != q.Order
+		if _, ok := slice.Type().Elem().FieldByName(field); !ok {
+			return fmt.Errorf("unknown field: %s", field)
+		}
+
+		sort.SliceStable(result.Interface(), func(i, j int) bool {
+			a := result.Index(i).FieldByName(field)
+			b := result.Index(j).FieldByName(field)
+			if desc {
+				return less(b, a)
+			}
+			return less(a, b)
+		})
+	}
+
+	if q.Max > 0 && result.Len() > q.Max {
+		result = result.Slice(0, q.Max)
+	}
+
+	slice.Set(result)
+	return nil
+}
+
+// A Count is the number of rows whose fields have the given values, in the order
+// of the fields they were counted by.
+type Count struct {
+	Values []string
+	Count  int
+}
+
+// Count evaluates `q` on `rows`, like Apply, and returns the number of resulting
+// rows with each combination of values of `fields`, e.g. the number of machines
+// with each status in each region.  Values are compared by their string
+// representation, and the counts are sorted by their values.
+func (q Query) Count(rows interface{}, fields ...string) ([]Count, error) {
+	if err := q.Apply(rows); err != nil {
+		return nil, err
+	}
+
+	slice := reflect.ValueOf(rows).Elem()
+	for _, field := range fields {
+		if _, ok := slice.Type().Elem().FieldByName(field); !ok {
+			return nil, fmt.Errorf("unknown field: %s", field)
+		}
+	}
+
+	var counts []Count
+	index := map[string]int{}
+	for i := 0; i < slice.Len(); i++ {
+		var values []string
+		for _, field := range fields {
+			values = append(values, fmt.Sprint(
+				slice.Index(i).FieldByName(field).Interface()))
+		}
+
+		// The values are joined with a separator that they're unlikely
+		// to contain.
+		key := strings.Join(values, "\x00")
+		if j, ok := index[key]; ok {
+			counts[j].Count++
+			continue
+		}
+		index[key] = len(counts)
+		counts = append(counts, Count{Values: values, Count: 1})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i].Values, counts[j].Values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return counts, nil
+}
+
+func (q Query) match(row reflect.Value) (bool, error) {
+	for _, filter := range q.Filters {
+		field := row.FieldByName(filter.Field)
+		if !field.IsValid() {
+			return false, fmt.Errorf("unknown field: %s", filter.Field)
+		}
+
+		match, err := filter.match(field)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (filter Filter) match(field reflect.Value) (bool, error) {
+	switch filter.Op {
+	case Equal:
+		return fmt.Sprint(field.Interface()) == filter.Value, nil
+	case NotEqual:
+		return fmt.Sprint(field.Interface()) != filter.Value, nil
+	case Has:
+		return has(field, filter)
+	case Less, LessOrEqual, Greater, GreaterOrEqual:
+	default:
+		return false, fmt.Errorf("unknown operator: %s", filter.Op)
+	}
+
+	var cmp int
+	switch {
+	case isNumber(field):
+		value, err := strconv.ParseFloat(filter.Value, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s is not a number: %s",
+				filter.Field, filter.Value)
+		}
+
+		switch x := toFloat(field); {
+		case x < value:
+			cmp = -1
+		case x > value:
+			cmp = 1
+		}
+	case field.Kind() == reflect.String:
+		cmp = strings.Compare(field.String(), filter.Value)
+	default:
+		return false, fmt.Errorf("%s is not ordered", filter.Field)
+	}
+
+	switch filter.Op {
+	case Less:
+		return cmp < 0, nil
+	case LessOrEqual:
+		return cmp <= 0, nil
+	case Greater:
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func has(field reflect.Value, filter Filter) (bool, error) {
+	switch field.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if fmt.Sprint(field.Index(i).Interface()) == filter.Value {
+				return true, nil
+			}
+		}
+	case reflect.Map:
+		for _, key := range field.MapKeys() {
+			if fmt.Sprint(key.Interface()) == filter.Value {
+				return true, nil
+			}
+		}
+	default:
+		return false, fmt.Errorf("%s is not a slice or map", filter.Field)
+	}
+	return false, nil
+}
+
+func less(a, b reflect.Value) bool {
+	switch {
+	case isNumber(a):
+		return toFloat(a) < toFloat(b)
+	case a.Kind() == reflect.String:
+		return a.String() < b.String()
+	default:
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	}
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
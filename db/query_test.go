@@ -0,0 +1,150 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	machines := []Machine{
+		{ID: 1, Role: Worker, DiskSize: 32, SSHKeys: []string{"a"}},
+		{ID: 2, Role: Master, DiskSize: 16},
+		{ID: 3, Role: Worker, DiskSize: 8, SSHKeys: []string{"a", "b"}},
+		{ID: 4, Role: Worker, DiskSize: 64},
+	}
+
+	ids := func(q Query) []int {
+		rows := append([]Machine{}, machines...)
+		assert.NoError(t, q.Apply(&rows))
+
+		var res []int
+		for _, row := range rows {
+			res = append(res, row.ID)
+		}
+		return res
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4}, ids(Query{}))
+	assert.Equal(t, []int{1, 3, 4}, ids(Where("Role", Equal, Worker)))
+	assert.Equal(t, []int{2}, ids(Where("Role", NotEqual, Worker)))
+	assert.Equal(t, []int{1, 3}, ids(Where("SSHKeys", Has, "a")))
+	assert.Equal(t, []int{3}, ids(Where("SSHKeys", Has, "b")))
+	assert.Equal(t, []int{1, 4}, ids(Where("DiskSize", Greater, 16)))
+	assert.Equal(t, []int{1, 2, 4}, ids(Where("DiskSize", GreaterOrEqual, 16)))
+	assert.Equal(t, []int{3}, ids(Where("DiskSize", Less, 16)))
+	assert.Equal(t, []int{2, 3}, ids(Where("DiskSize", LessOrEqual, 16)))
+	assert.Equal(t, []int{1, 3}, ids(Where("Role", Equal, Worker).
+		Where("DiskSize", LessOrEqual, 32)))
+	assert.Equal(t, []int{3, 2, 1, 4}, ids(Query{}.OrderBy("DiskSize")))
+	assert.Equal(t, []int{4, 1}, ids(Query{}.OrderBy("-DiskSize").Limit(2)))
+	assert.Equal(t, []int{1, 3, 4, 2}, ids(Query{}.OrderBy("-Role")))
+
+	// Building a query doesn't modify the query it was built from.
+	base := Where("Role", Equal, Worker)
+	base.Where("DiskSize", Less, 16)
+	assert.Len(t, base.Filters, 1)
+
+	rows := append([]Machine{}, machines...)
+	assert.EqualError(t, Where("Bogus", Equal, 1).Apply(&rows),
+		"unknown field: Bogus")
+	assert.EqualError(t, Where("Role", "~", 1).Apply(&rows),
+		"unknown operator: ~")
+	assert.EqualError(t, Where("DiskSize", Less, "big").Apply(&rows),
+		"DiskSize is not a number: big")
+	assert.EqualError(t, Where("SSHKeys", Less, "a").Apply(&rows),
+		"SSHKeys is not ordered")
+	assert.EqualError(t, Where("Role", Has, "a").Apply(&rows),
+		"Role is not a slice or map")
+	assert.EqualError(t, Query{}.OrderBy("Bogus").Apply(&rows),
+		"unknown field: Bogus")
+	assert.EqualError(t, Query{}.Apply(rows),
+		"query rows must be a pointer to a slice of structs")
+	assert.Len(t, rows, 4)
+}
+
+func TestSelectFromQuery(t *testing.T) {
+	t.Parallel()
+
+	conn := New()
+	conn.Txn(AllTables...).Run(func(view Database) error {
+		for _, size := range []int{32, 16, 8} {
+			m := view.InsertMachine()
+			m.DiskSize = size
+			view.Commit(m)
+		}
+		view.InsertContainer()
+		return nil
+	})
+
+	// Rows are ordered by ID unless the query orders them otherwise.
+	var machines []Machine
+	assert.NoError(t, conn.SelectFromQuery(Query{}, &machines))
+	assert.Equal(t, []int{32, 16, 8}, diskSizes(machines))
+
+	assert.NoError(t, conn.SelectFromQuery(Where("DiskSize", Less, 32).
+		OrderBy("DiskSize"), &machines))
+	assert.Equal(t, []int{8, 16}, diskSizes(machines))
+
+	conn.Txn(MachineTable).Run(func(view Database) error {
+		assert.NoError(t, view.SelectFromQuery(Query{}.Limit(1), &machines))
+		assert.Equal(t, []int{32}, diskSizes(machines))
+		return nil
+	})
+
+	var containers []Container
+	assert.NoError(t, conn.SelectFromQuery(Query{}, &containers))
+	assert.Len(t, containers, 1)
+
+	var notRows []Filter
+	assert.EqualError(t, conn.SelectFromQuery(Query{}, &notRows),
+		"db.Filter is not a database row")
+	assert.EqualError(t, conn.SelectFromQuery(Query{}, machines),
+		"query rows must be a pointer to a slice of structs")
+}
+
+func diskSizes(machines []Machine) (sizes []int) {
+	for _, m := range machines {
+		sizes = append(sizes, m.DiskSize)
+	}
+	return sizes
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	machines := []Machine{
+		{Role: Worker, Region: "us-west-1", Status: Connected},
+		{Role: Master, Region: "us-west-1", Status: Connected},
+		{Role: Worker, Region: "us-east-1", Status: Booting},
+		{Role: Worker, Region: "us-west-1", Status: Booting},
+		{Role: Worker, Region: "us-west-1", Status: Connected},
+	}
+
+	count := func(q Query, fields ...string) []Count {
+		rows := append([]Machine{}, machines...)
+		counts, err := q.Count(&rows, fields...)
+		assert.NoError(t, err)
+		return counts
+	}
+
+	assert.Equal(t, []Count{
+		{Values: []string{"us-east-1", Booting}, Count: 1},
+		{Values: []string{"us-west-1", Booting}, Count: 1},
+		{Values: []string{"us-west-1", Connected}, Count: 3},
+	}, count(Query{}, "Region", "Status"))
+	assert.Equal(t, []Count{
+		{Values: []string{Booting}, Count: 2},
+		{Values: []string{Connected}, Count: 2},
+	}, count(Where("Role", Equal, Worker), "Status"))
+	assert.Equal(t, []Count{{Values: nil, Count: 5}}, count(Query{}))
+	assert.Nil(t, count(Where("Region", Equal, "eu-west-1"), "Status"))
+
+	rows := append([]Machine{}, machines...)
+	_, err := Query{}.Count(&rows, "Bogus")
+	assert.EqualError(t, err, "unknown field: Bogus")
+	_, err = Where("Bogus", Equal, 1).Count(&rows, "Status")
+	assert.EqualError(t, err, "unknown field: Bogus")
+}
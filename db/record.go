@@ -0,0 +1,87 @@
+package db
+
+import "sync"
+
+// The operations that may be performed on a row.
+const (
+	Inserted  = "insert"
+	Committed = "commit"
+	Removed   = "remove"
+)
+
+// A Change is a single modification made to a row by a transaction.
+type Change struct {
+	Op  string
+	Row interface{}
+}
+
+type recorders struct {
+	sync.Mutex
+	fns []func([]Change)
+}
+
+// Record registers `fn` to be called with the changes made by each transaction that
+// modifies the database.  `fn` is called before the transaction releases its
+// tables, so it sees transactions in the order they ran, and must not start
+// transactions of its own.
+func (cn Conn) Record(fn func([]Change)) {
+	cn.recorders.Lock()
+	defer cn.recorders.Unlock()
+	cn.recorders.fns = append(cn.recorders.fns, fn)
+}
+
+// Replay applies `changes`, as passed to a Record callback, to the database.
+// Inserted rows keep their IDs, so the changes of subsequent transactions apply
+// to the right rows.
+func (cn Conn) Replay(changes []Change) {
+	var tables []TableType
+	for _, change := range changes {
+		tables = append(tables, getTableType(change.Row.(row)))
+	}
+
+	cn.Txn(tables...).Run(func(view Database) error {
+		for _, change := range changes {
+			r := change.Row.(row)
+			switch change.Op {
+			case Inserted:
+				view.reserveID(r.getID())
+				view.insert(r)
+			case Committed:
+				view.Commit(r)
+			case Removed:
+				view.Remove(r)
+			}
+		}
+		return nil
+	})
+}
+
+func (db Database) record(op string, r row) {
+	if db.changes != nil {
+		*db.changes = append(*db.changes, Change{Op: op, Row: r})
+	}
+}
+
+// reserveID ensures that `id` is never allocated by nextID.
+func (db Database) reserveID(id int) {
+	db.idAlloc.Lock()
+	defer db.idAlloc.Unlock()
+
+	if id > db.idAlloc.curID {
+		db.idAlloc.curID = id
+	}
+}
+
+func (tr Transaction) notifyRecorders() {
+	if tr.db.changes == nil || len(*tr.db.changes) == 0 {
+		return
+	}
+
+	tr.recorders.Lock()
+	fns := tr.recorders.fns
+	tr.recorders.Unlock()
+
+	for _, fn := range fns {
+		fn(*tr.db.changes)
+	}
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Settings holds the runtime toggles for the behaviors of a namespace, so that
+// they may be changed without restarting the daemon.
+type Settings struct {
+	ID int
+
+	Namespace string
+
+	// Maps setting names to their values.  Settings that aren't in the map take
+	// their default value.
+	Flags map[string]bool
+}
+
+// The names of the available settings.
+const (
+	// MinimalACLs restricts the admin ACLs to the ports needed by Quilt, rather
+	// than opening every port but etcd's.
+	MinimalACLs = "minimal-acls"
+
+	// DiskGC makes minions remove unused images and stopped containers when
+	// their disks are nearly full, before Docker runs out of space.
+	DiskGC = "disk-gc"
+
+	// HostFirewall makes minions enforce the ACLs with iptables, for providers
+	// whose firewalls are missing or weak, such as Vagrant.
+	HostFirewall = "host-firewall"
+
+	// SelfHost makes the masters run the daemon in a container on the etcd
+	// leader, so that the cluster is managed from within itself.
+	SelfHost = "self-host"
+)
+
+// SettingDefaults maps each available setting to its default value.
+var SettingDefaults = map[string]bool{
+	MinimalACLs:  false,
+	DiskGC:       false,
+	HostFirewall: false,
+	SelfHost:     false,
+}
+
+// ValidateSetting returns an error if `name` isn't an available setting.
+func ValidateSetting(name string) error {
+	if _, ok := SettingDefaults[name]; !ok {
+		return fmt.Errorf("unknown setting: %s", name)
+	}
+	return nil
+}
+
+// SettingNames returns the sorted names of the available settings.
+func SettingNames() []string {
+	var names []string
+	for name := range SettingDefaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Enabled returns the value of the setting `name`.
+func (s Settings) Enabled(name string) bool {
+	if value, ok := s.Flags[name]; ok {
+		return value
+	}
+	return SettingDefaults[name]
+}
+
+// Disabled returns whether the setting `name` was explicitly turned off, rather
+// than left at its default.
+func (s Settings) Disabled(name string) bool {
+	value, ok := s.Flags[name]
+	return ok && !value
+}
+
+// InsertSettings creates a new settings row and inserts it into the database.
+func (db Database) InsertSettings() Settings {
+	result := Settings{ID: db.nextID()}
+	db.insert(result)
+	return result
+}
+
+// SelectFromSettings gets all settings in the database that satisfy 'check'.
+func (db Database) SelectFromSettings(check func(Settings) bool) []Settings {
+	var result []Settings
+	for _, row := range db.selectRows(SettingsTable) {
+		if check == nil || check(row.(Settings)) {
+			result = append(result, row.(Settings))
+		}
+	}
+	return result
+}
+
+// SelectFromSettings gets all settings in the database connection that satisfy
+// 'check'.
+func (conn Conn) SelectFromSettings(check func(Settings) bool) []Settings {
+	var result []Settings
+	conn.Txn(SettingsTable).Run(func(view Database) error {
+		result = view.SelectFromSettings(check)
+		return nil
+	})
+	return result
+}
+
+// GetSettings returns the settings of `namespace`.  If the namespace has no
+// settings row, the defaults are returned.
+func (db Database) GetSettings(namespace string) Settings {
+	settings := db.SelectFromSettings(func(s Settings) bool {
+		return s.Namespace == namespace
+	})
+	if len(settings) == 0 {
+		return Settings{Namespace: namespace}
+	}
+	return settings[0]
+}
+
+func (s Settings) getID() int {
+	return s.ID
+}
+
+func (s Settings) String() string {
+	return defaultString(s)
+}
+
+func (s Settings) less(r row) bool {
+	return s.ID < r.(Settings).ID
+}
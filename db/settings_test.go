@@ -0,0 +1,48 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettings(t *testing.T) {
+	conn := New()
+
+	conn.Txn(SettingsTable).Run(func(view Database) error {
+		settings := view.GetSettings("ns")
+		assert.Equal(t, "ns", settings.Namespace)
+		assert.False(t, settings.Enabled(MinimalACLs))
+
+		settings = view.InsertSettings()
+		settings.Namespace = "ns"
+		settings.Flags = map[string]bool{MinimalACLs: true}
+		view.Commit(settings)
+		return nil
+	})
+
+	settings := conn.SelectFromSettings(nil)
+	assert.Len(t, settings, 1)
+	assert.True(t, settings[0].Enabled(MinimalACLs))
+	assert.Equal(t, "Settings-1{Namespace=ns, Flags=map[minimal-acls:true]}",
+		settings[0].String())
+
+	conn.Txn(SettingsTable).Run(func(view Database) error {
+		assert.True(t, view.GetSettings("ns").Enabled(MinimalACLs))
+		assert.False(t, view.GetSettings("other").Enabled(MinimalACLs))
+		return nil
+	})
+
+	// Only settings that are turned off are disabled, not those left at
+	// their default.
+	assert.False(t, Settings{}.Disabled(SelfHost))
+	assert.True(t, Settings{Flags: map[string]bool{SelfHost: false}}.Disabled(
+		SelfHost))
+	assert.False(t, Settings{Flags: map[string]bool{SelfHost: true}}.Disabled(
+		SelfHost))
+
+	assert.NoError(t, ValidateSetting(MinimalACLs))
+	assert.EqualError(t, ValidateSetting("foo"), "unknown setting: foo")
+	assert.Equal(t, []string{DiskGC, HostFirewall, MinimalACLs, SelfHost},
+		SettingNames())
+}
@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults are the values given to the machine attributes that blueprints leave
+// unset.  They're read from the daemon's configuration file, so that an
+// organization can share them rather than repeating them in every blueprint.
+type Defaults struct {
+	// The disk size, in gigabytes, of machines.
+	DiskSize int `json:",omitempty"`
+
+	// The provider of machines.
+	Provider string `json:",omitempty"`
+
+	// The region of machines, by provider.  Providers that aren't listed use
+	// their own default region.
+	Regions map[string]string `json:",omitempty"`
+
+	// Public keys that are granted SSH access to every machine, in addition to
+	// the keys in the blueprint and the daemon's own key.
+	AdminSSHKeys []string `json:",omitempty"`
+}
+
+// builtinDefaults are used when the configuration file doesn't exist, and for the
+// attributes it doesn't set.
+var builtinDefaults = Defaults{DiskSize: 32}
+
+// The configuration file is reloaded while the daemon runs, but the disk size,
+// provider, and regions decide which machines are booted, so a change to them
+// would replace running machines, masters included.  They're therefore held
+// fixed until the next deploy, while admin keys, which are installed on the
+// running machines, take effect as soon as they're loaded.
+var defaults = struct {
+	sync.Mutex
+
+	// The defaults most recently loaded from the configuration file.
+	loaded Defaults
+
+	// The defaults that were loaded when `blueprint` was deployed.
+	deployed  Defaults
+	blueprint *blueprint.Blueprint
+}{loaded: builtinDefaults, deployed: builtinDefaults}
+
+// How often the configuration file is checked for changes.
+var reloadInterval = 10 * time.Second
+
+// getDefaults returns the defaults for the currently deployed blueprint.
+func getDefaults() Defaults {
+	defaults.Lock()
+	defer defaults.Unlock()
+
+	d := defaults.deployed
+	d.AdminSSHKeys = defaults.loaded.AdminSSHKeys
+	return d
+}
+
+func loadedDefaults() Defaults {
+	defaults.Lock()
+	defer defaults.Unlock()
+	return defaults.loaded
+}
+
+func setDefaults(d Defaults) {
+	defaults.Lock()
+	defaults.loaded = d
+	defaults.Unlock()
+}
+
+// deployDefaults applies the loaded defaults to machines if `bp` differs from the
+// blueprint that was deployed when they were last applied.
+func deployDefaults(bp blueprint.Blueprint) {
+	defaults.Lock()
+	defer defaults.Unlock()
+
+	if defaults.blueprint != nil && reflect.DeepEqual(*defaults.blueprint, bp) {
+		return
+	}
+
+	if !reflect.DeepEqual(defaults.deployed, defaults.loaded) {
+		c.Inc("Deploy Defaults")
+	}
+	defaults.blueprint = &bp
+	defaults.deployed = defaults.loaded
+}
+
+// watchDefaults loads the defaults from the configuration file at `path`, and
+// then reloads them whenever the file changes.  The returned channel receives a
+// value after each reload that changed the defaults.
+func watchDefaults(path string) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	loadDefaults(path)
+
+	go func() {
+		for range time.Tick(reloadInterval) {
+			if loadDefaults(path) {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changed
+}
+
+// loadDefaults reads the defaults in the configuration file at `path`, and returns
+// whether they differ from the loaded defaults.  A missing or invalid file leaves
+// the last good defaults in place.
+func loadDefaults(path string) bool {
+	d, err := readDefaults(path)
+	if os.IsNotExist(err) {
+		// The file may be missing only while it's rewritten, so it's not
+		// taken as a request to restore the built-in defaults.
+		return false
+	} else if err != nil {
+		log.WithError(err).WithField("path", path).Error(
+			"Failed to load daemon configuration")
+		return false
+	}
+
+	if d.DiskSize == 0 {
+		d.DiskSize = builtinDefaults.DiskSize
+	}
+	if reflect.DeepEqual(d, loadedDefaults()) {
+		return false
+	}
+
+	c.Inc("Reload Defaults")
+	log.WithField("path", path).Info("Loaded daemon configuration")
+	setDefaults(d)
+	return true
+}
+
+func readDefaults(path string) (Defaults, error) {
+	contents, err := util.ReadFile(path)
+	if err != nil {
+		return Defaults{}, err
+	}
+
+	var d Defaults
+	if err := json.Unmarshal([]byte(contents), &d); err != nil {
+		return Defaults{}, fmt.Errorf("parse: %s", err)
+	}
+
+	if err := checkDefaults(d); err != nil {
+		return Defaults{}, err
+	}
+	return d, nil
+}
+
+func checkDefaults(d Defaults) error {
+	if d.DiskSize < 0 {
+		return errors.New("disk size must not be negative")
+	}
+
+	if d.Provider != "" {
+		if _, err := db.ParseProvider(d.Provider); err != nil {
+			return err
+		}
+	}
+
+	for provider := range d.Regions {
+		if _, err := db.ParseProvider(provider); err != nil {
+			return fmt.Errorf("region: %s", err)
+		}
+	}
+	return nil
+}
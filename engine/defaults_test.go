@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+	resetDefaults()
+	defer resetDefaults()
+
+	path := "/quilt/daemon.json"
+
+	// Without a configuration file, the built-in defaults are used.
+	assert.False(t, loadDefaults(path))
+	assert.Equal(t, builtinDefaults, loadedDefaults())
+
+	util.WriteFile(path, []byte(`{"diskSize": 64, "provider": "Amazon", `+
+		`"regions": {"Amazon": "us-east-1"}, "adminSSHKeys": ["key"]}`), 0644)
+	assert.True(t, loadDefaults(path))
+	assert.Equal(t, Defaults{
+		DiskSize:     64,
+		Provider:     "Amazon",
+		Regions:      map[string]string{"Amazon": "us-east-1"},
+		AdminSSHKeys: []string{"key"},
+	}, loadedDefaults())
+
+	// Reloading an unchanged file doesn't change the defaults.
+	assert.False(t, loadDefaults(path))
+
+	// Invalid files leave the current defaults in place.
+	for _, contents := range []string{
+		`{"diskSize": `,
+		`{"diskSize": -1}`,
+		`{"provider": "Amazin"}`,
+		`{"regions": {"amazon": "us-east-1"}}`,
+	} {
+		util.WriteFile(path, []byte(contents), 0644)
+		assert.False(t, loadDefaults(path), contents)
+		assert.Equal(t, 64, loadedDefaults().DiskSize)
+	}
+
+	// Attributes that the file doesn't set take their built-in default.
+	util.WriteFile(path, []byte(`{"provider": "Google"}`), 0644)
+	assert.True(t, loadDefaults(path))
+	assert.Equal(t, Defaults{DiskSize: 32, Provider: "Google"}, loadedDefaults())
+
+	// Deleting the file keeps the last good defaults.
+	util.AppFs.Remove(path)
+	assert.False(t, loadDefaults(path))
+	assert.Equal(t, Defaults{DiskSize: 32, Provider: "Google"}, loadedDefaults())
+}
+
+func TestDeployDefaults(t *testing.T) {
+	resetDefaults()
+	defer resetDefaults()
+
+	bp := blueprint.Blueprint{Namespace: "ns"}
+	deployDefaults(bp)
+	assert.Equal(t, builtinDefaults, getDefaults())
+
+	// Loading new defaults changes only the admin keys of the deployed
+	// blueprint, so that running machines aren't replaced.
+	setDefaults(Defaults{DiskSize: 64, Provider: "Amazon",
+		AdminSSHKeys: []string{"org"}})
+	deployDefaults(bp)
+	assert.Equal(t, Defaults{DiskSize: 32, AdminSSHKeys: []string{"org"}},
+		getDefaults())
+
+	// The rest apply once a new blueprint is deployed.
+	bp.Namespace = "other"
+	deployDefaults(bp)
+	assert.Equal(t, Defaults{DiskSize: 64, Provider: "Amazon",
+		AdminSSHKeys: []string{"org"}}, getDefaults())
+}
+
+func TestMachineDefaults(t *testing.T) {
+	resetDefaults()
+	defer resetDefaults()
+	setDefaults(Defaults{
+		DiskSize:     64,
+		Provider:     "Amazon",
+		Regions:      map[string]string{"Amazon": "us-east-1"},
+		AdminSSHKeys: []string{"org"},
+	})
+	deployDefaults(blueprint.Blueprint{})
+
+	machines := toDBMachine([]blueprint.Machine{
+		{Role: "Master", Size: "m4.large"},
+		{Role: "Worker", Size: "m4.large", DiskSize: 16, Region: "us-west-1",
+			SSHKeys: []string{"user"}},
+		{Role: "Worker", Provider: "Google", Size: "n1-standard-1"},
+	}, 0, false, "admin")
+	assert.Len(t, machines, 3)
+
+	assert.Equal(t, db.Amazon, machines[0].Provider)
+	assert.Equal(t, 64, machines[0].DiskSize)
+	assert.Equal(t, "us-east-1", machines[0].Region)
+	assert.Equal(t, []string{"org", "admin"}, machines[0].SSHKeys)
+
+	// The blueprint's own values take precedence.
+	assert.Equal(t, 16, machines[1].DiskSize)
+	assert.Equal(t, "us-west-1", machines[1].Region)
+	assert.Equal(t, []string{"user", "org", "admin"}, machines[1].SSHKeys)
+
+	// Providers without a default region use their own.
+	assert.Equal(t, db.Google, machines[2].Provider)
+	assert.Equal(t, "us-east1-b", machines[2].Region)
+}
+
+func resetDefaults() {
+	defaults.Lock()
+	defaults.loaded = builtinDefaults
+	defaults.deployed = builtinDefaults
+	defaults.blueprint = nil
+	defaults.Unlock()
+}
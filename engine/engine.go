@@ -1,24 +1,45 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/cloud"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/recorder"
 	"github.com/kelda/kelda/util"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var myIP = util.MyIP
-var defaultDiskSize = 32
 
 var c = counter.New("Engine")
 
-// Run updates the database in response to changes in the blueprint table.
-func Run(conn db.Conn, adminKey string) {
-	for range conn.TriggerTick(30, db.BlueprintTable, db.MachineTable).C {
+var rec = recorder.New("Engine")
+
+// Run updates the database in response to changes in the blueprint table, in
+// the defaults in the configuration file at `configPath`, and in the public keys
+// of the GitHub users that the blueprint grants SSH access.
+func Run(conn db.Conn, adminKey, configPath string) {
+	reload := watchDefaults(configPath)
+	githubKeysChanged := watchGithubKeys()
+	trigg := conn.TriggerTick(30, db.BlueprintTable, db.MachineTable)
+	for {
+		select {
+		case <-trigg.C:
+		case <-reload:
+		case <-githubKeysChanged:
+		}
+
 		conn.Txn(db.BlueprintTable, db.MachineTable).Run(
 			func(view db.Database) error {
 				return updateTxn(view, adminKey)
@@ -34,6 +55,7 @@ func updateTxn(view db.Database, adminKey string) error {
 		return err
 	}
 
+	deployDefaults(bp.Blueprint)
 	machineTxn(view, bp.Blueprint, adminKey)
 	return nil
 }
@@ -43,10 +65,19 @@ func updateTxn(view db.Database, adminKey string) error {
 // Specifically, it sets the role of the db.Machine, the size (which may depend
 // on RAM and CPU constraints), and the provider.
 // Additionally, it skips machines with invalid roles, sizes or providers.
-func toDBMachine(machines []blueprint.Machine, adminKey string) []db.Machine {
+// Preemptible machines that don't set a max price bid `maxPrice`, and may fall
+// back to on-demand machines if `onDemandFallback` is set.
+func toDBMachine(machines []blueprint.Machine, maxPrice float64,
+	onDemandFallback bool, adminKey string) []db.Machine {
+
+	defaults := getDefaults()
 
 	var hasMaster, hasWorker bool
 	var dbMachines []db.Machine
+	roleCounts := map[db.Role]int{}
+	hosts := map[string]bool{}
+	hostnames := map[string]bool{}
+	persistentVolumes := map[string]bool{}
 	for _, blueprintm := range machines {
 		var m db.Machine
 
@@ -57,10 +88,20 @@ func toDBMachine(machines []blueprint.Machine, adminKey string) []db.Machine {
 		}
 		m.Role = role
 
+		// Machines are indexed by their position in the blueprint, so that
+		// an invalid machine doesn't change the hostnames of the others.
+		roleCounts[role]++
+		index := roleCounts[role]
+
 		hasMaster = hasMaster || role == db.Master
 		hasWorker = hasWorker || role == db.Worker
 
-		p, err := db.ParseProvider(blueprintm.Provider)
+		provider := blueprintm.Provider
+		if provider == "" {
+			provider = defaults.Provider
+		}
+
+		p, err := db.ParseProvider(provider)
 		if err != nil {
 			log.WithError(err).Error("Error parsing provider.")
 			continue
@@ -68,29 +109,280 @@ func toDBMachine(machines []blueprint.Machine, adminKey string) []db.Machine {
 		m.Provider = p
 		m.Size = blueprintm.Size
 		m.Preemptible = blueprintm.Preemptible
+		if m.Preemptible {
+			m.MaxPrice = blueprintm.MaxPrice
+			if m.MaxPrice == 0 {
+				m.MaxPrice = maxPrice
+			}
+			m.OnDemandFallback = onDemandFallback
+		}
 
 		if m.Size == "" {
-			m.Size = cloud.ChooseSize(p, blueprintm.RAM, blueprintm.CPU)
+			m.Size = cloud.ChooseSize(p, blueprintm.RAM, blueprintm.CPU,
+				blueprintm.GPUs, blueprintm.GPUType, blueprintm.Arch)
 			if m.Size == "" {
 				log.Errorf("No valid size for %v, skipping.", m)
 				continue
 			}
 		}
 
+		// The architecture follows from the size, so explicit sizes must
+		// agree with the requested architecture.  Static hosts have whatever
+		// architecture the blueprint says they do.
+		arch := cloud.Arch(p, m.Size)
+		if p == db.Static && blueprintm.Arch != "" {
+			arch = blueprintm.Arch
+		}
+		if blueprintm.Arch != "" && blueprintm.Arch != arch {
+			log.Errorf("%v isn't %s, skipping.", m, blueprintm.Arch)
+			continue
+		}
+		if arch != blueprint.AMD64 {
+			m.Arch = arch
+		}
+
+		// Record the GPUs that the machine actually has, which may be more
+		// than were requested.
+		m.GPUs, m.GPUType = cloud.GPUCapacity(p, m.Size, blueprintm.GPUs,
+			blueprintm.GPUType)
+		if m.GPUs < blueprintm.GPUs || (blueprintm.GPUs > 0 &&
+			blueprintm.GPUType != "" && m.GPUType != blueprintm.GPUType) {
+			log.Errorf("%v doesn't have the requested GPUs, skipping.", m)
+			continue
+		}
+
 		m.DiskSize = blueprintm.DiskSize
 		if m.DiskSize == 0 {
-			m.DiskSize = defaultDiskSize
+			m.DiskSize = defaults.DiskSize
 		}
 
-		m.SSHKeys = blueprintm.SSHKeys
+		if err := checkGithubUsers(blueprintm.GithubKeys); err != nil {
+			log.WithError(err).Errorf("Invalid GitHub keys for %v, skipping.",
+				m)
+			continue
+		}
+		m.SSHKeys = append(blueprintm.SSHKeys,
+			githubKeys(blueprintm.GithubKeys)...)
+		m.SSHKeys = append(m.SSHKeys, defaults.AdminSSHKeys...)
 		if adminKey != "" {
 			m.SSHKeys = append(m.SSHKeys, adminKey)
 		}
 
+		if err := checkSysctls(blueprintm.Sysctls); err != nil {
+			log.WithError(err).Errorf("Invalid sysctls for %v, skipping.", m)
+			continue
+		}
+		m.Sysctls = blueprintm.Sysctls
+		m.Hugepages = blueprintm.Hugepages
+
+		if err := checkHooks(blueprintm.Hooks); err != nil {
+			log.WithError(err).Errorf("Invalid hooks for %v, skipping.", m)
+			continue
+		}
+		m.Hooks = blueprintm.Hooks
+
+		switch blueprintm.Bootstrap {
+		case "", blueprint.UserDataBootstrap, blueprint.SSHBootstrap:
+		default:
+			log.Errorf("Unknown bootstrap mode %q for %v, skipping.",
+				blueprintm.Bootstrap, m)
+			continue
+		}
+		m.Bootstrap = blueprintm.Bootstrap
+
+		if err := checkTags(blueprintm.Tags); err != nil {
+			log.WithError(err).Errorf("Invalid tags for %v, skipping.", m)
+			continue
+		}
+		m.Tags = blueprintm.Tags
+
+		if err := checkLabels(blueprintm.Labels); err != nil {
+			log.WithError(err).Errorf("Invalid labels for %v, skipping.", m)
+			continue
+		}
+		m.Labels = blueprintm.Labels
+
+		if (blueprintm.VPC == "") != (blueprintm.Subnet == "") {
+			log.Errorf("The VPC and subnet of %v must be specified "+
+				"together, skipping.", m)
+			continue
+		} else if blueprintm.VPC != "" && p != db.Amazon {
+			log.Errorf("Only Amazon machines may specify a VPC, "+
+				"skipping %v.", m)
+			continue
+		}
+		m.VPC = blueprintm.VPC
+		m.Subnet = blueprintm.Subnet
+
+		if blueprintm.InstanceProfile != "" && p != db.Amazon {
+			log.Errorf("Only Amazon machines may specify an instance "+
+				"profile, skipping %v.", m)
+			continue
+		}
+		m.InstanceProfile = blueprintm.InstanceProfile
+
+		if len(blueprintm.SecurityGroups) != 0 && p != db.Amazon {
+			log.Errorf("Only Amazon machines may specify security "+
+				"groups, skipping %v.", m)
+			continue
+		}
+		m.SecurityGroups, err = checkSecurityGroups(blueprintm.SecurityGroups)
+		if err != nil {
+			log.WithError(err).Errorf("Invalid security groups for %v, "+
+				"skipping.", m)
+			continue
+		}
+
+		if blueprintm.Image != "" {
+			switch p {
+			case db.Amazon, db.Google:
+			case db.DigitalOcean:
+				if _, err := strconv.Atoi(blueprintm.Image); err != nil {
+					log.Errorf("DigitalOcean images must be snapshot "+
+						"IDs, skipping %v.", m)
+					continue
+				}
+			default:
+				log.Errorf("Only Amazon, Google and DigitalOcean machines "+
+					"may specify an image, skipping %v.", m)
+				continue
+			}
+		}
+		m.Image = blueprintm.Image
+
 		m.BlueprintID = blueprintm.ID
 		m.Region = blueprintm.Region
-		m.FloatingIP = blueprintm.FloatingIP
-		dbMachines = append(dbMachines, cloud.DefaultRegion(m))
+		if m.Region == "" {
+			m.Region = defaults.Regions[string(p)]
+		}
+		if blueprintm.FloatingIP == blueprint.AutoFloatingIP {
+			if p != db.Amazon {
+				log.Errorf("Only Amazon machines may have their "+
+					"floating IP allocated automatically, skipping %v.",
+					m)
+				continue
+			}
+			m.AutoFloatingIP = true
+		} else {
+			m.FloatingIP = blueprintm.FloatingIP
+		}
+		m = cloud.DefaultRegion(m)
+
+		if blueprintm.Zone != "" {
+			if err := checkZone(blueprintm, m); err != nil {
+				log.WithError(err).Errorf("Invalid zone for %v, skipping.",
+					m)
+				continue
+			}
+			m.Zone = blueprintm.Zone
+		}
+
+		if err := checkVolume(blueprintm, p); err != nil {
+			log.WithError(err).Errorf("Invalid volume for %v, skipping.", m)
+			continue
+		}
+		m.VolumeType = blueprintm.VolumeType
+		m.IOPS = blueprintm.IOPS
+
+		if blueprintm.DiskType != "" {
+			if p != db.Google {
+				log.Errorf("Only Google machines may specify a disk "+
+					"type, skipping %v.", m)
+				continue
+			} else if !diskTypes[blueprintm.DiskType] {
+				log.Errorf("Unknown disk type %q for %v, skipping.",
+					blueprintm.DiskType, m)
+				continue
+			}
+		}
+		m.DiskType = blueprintm.DiskType
+
+		if err := checkVolumes(blueprintm.Volumes, p); err != nil {
+			log.WithError(err).Errorf("Invalid volumes for %v, skipping.", m)
+			continue
+		}
+
+		// Persistent volumes are identified by their name within their
+		// region, so only one machine may attach each.
+		var duplicate bool
+		for _, v := range blueprintm.Volumes {
+			key := m.Region + "/" + v.Name
+			if v.Persistent && persistentVolumes[key] {
+				log.Errorf("Duplicate persistent volume %s, skipping %v.",
+					v.Name, m)
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		for _, v := range blueprintm.Volumes {
+			if v.Persistent {
+				persistentVolumes[m.Region+"/"+v.Name] = true
+			}
+		}
+		m.Volumes = sortedVolumes(blueprintm.Volumes)
+
+		// Amazon only assigns IPv6 addresses in subnets with an IPv6 CIDR
+		// block, which the default VPC's subnets lack.
+		if blueprintm.IPv6 {
+			switch {
+			case p == db.Amazon && blueprintm.Subnet == "":
+				log.Errorf("Amazon machines with IPv6 must specify a "+
+					"subnet, skipping %v.", m)
+				continue
+			case p != db.Amazon && p != db.DigitalOcean:
+				log.Errorf("Only Amazon and DigitalOcean machines may "+
+					"have IPv6 addresses, skipping %v.", m)
+				continue
+			}
+		}
+		m.IPv6 = blueprintm.IPv6
+
+		if blueprintm.PlacementGroup != "" && p != db.Amazon {
+			log.Errorf("Only Amazon machines may specify a placement "+
+				"group, skipping %v.", m)
+			continue
+		}
+		m.PlacementGroup = blueprintm.PlacementGroup
+
+		if blueprintm.Hostname != "" {
+			m.Hostname, err = expandHostname(blueprintm.Hostname, m, index)
+			if err != nil {
+				log.WithError(err).Errorf("Invalid hostname for %v, skipping.",
+					m)
+				continue
+			}
+
+			if hostnames[m.Hostname] {
+				log.Errorf("Duplicate hostname %s, skipping.", m.Hostname)
+				continue
+			}
+			hostnames[m.Hostname] = true
+		}
+
+		if p == db.Static {
+			if net.ParseIP(blueprintm.Host) == nil {
+				log.Errorf("Invalid host %q for %v, skipping.",
+					blueprintm.Host, m)
+				continue
+			}
+
+			if hosts[blueprintm.Host] {
+				log.Errorf("Duplicate host %s, skipping.", blueprintm.Host)
+				continue
+			}
+			hosts[blueprintm.Host] = true
+		} else if blueprintm.Host != "" {
+			log.Errorf("Only static machines may specify a host, "+
+				"skipping %v.", m)
+			continue
+		}
+		m.Host = blueprintm.Host
+		m.SSHUser = blueprintm.SSHUser
+		m.SSHKeyPath = blueprintm.SSHKeyPath
+		dbMachines = append(dbMachines, m)
 	}
 
 	if hasMaster && !hasWorker {
@@ -106,9 +398,17 @@ func toDBMachine(machines []blueprint.Machine, adminKey string) []db.Machine {
 
 func machineTxn(view db.Database, bp blueprint.Blueprint, adminKey string) {
 	// XXX: How best to deal with machines that don't specify enough information?
-	blueprintMachines := toDBMachine(bp.Machines, adminKey)
+	blueprintMachines := toDBMachine(bp.Machines, bp.MaxPrice,
+		bp.OnDemandFallback, adminKey)
+	if bp.SpreadMasters {
+		spreadMasters(blueprintMachines)
+	}
 
-	dbMachines := view.SelectFromMachine(nil)
+	// Interrupted machines are left out of the join, so that replacements are
+	// booted while they drain.  The cloud removes them once they're reclaimed.
+	dbMachines := view.SelectFromMachine(func(m db.Machine) bool {
+		return !m.Interrupted
+	})
 
 	scoreFun := func(left, right interface{}) int {
 		blueprintMachine := left.(db.Machine)
@@ -122,12 +422,41 @@ func machineTxn(view db.Database, bp blueprint.Blueprint, adminKey string) {
 			return -1
 		case dbMachine.Region != blueprintMachine.Region:
 			return -1
+		case dbMachine.Zone != blueprintMachine.Zone ||
+			dbMachine.PlacementGroup != blueprintMachine.PlacementGroup:
+			return -1
+		case dbMachine.VolumeType != blueprintMachine.VolumeType ||
+			dbMachine.IOPS != blueprintMachine.IOPS ||
+			dbMachine.DiskType != blueprintMachine.DiskType:
+			return -1
+		case !db.VolumesEqual(dbMachine.Volumes, blueprintMachine.Volumes):
+			return -1
+		case dbMachine.IPv6 != blueprintMachine.IPv6:
+			return -1
+		case dbMachine.Host != blueprintMachine.Host:
+			return -1
+		case dbMachine.VPC != blueprintMachine.VPC ||
+			dbMachine.Subnet != blueprintMachine.Subnet:
+			return -1
+		case dbMachine.InstanceProfile != blueprintMachine.InstanceProfile:
+			return -1
+		case !util.StrSliceEqual(dbMachine.SecurityGroups,
+			blueprintMachine.SecurityGroups):
+			return -1
+		case dbMachine.Image != blueprintMachine.Image:
+			return -1
+		case dbMachine.GPUs != blueprintMachine.GPUs ||
+			dbMachine.GPUType != blueprintMachine.GPUType:
+			return -1
 		case dbMachine.Preemptible != blueprintMachine.Preemptible:
 			return -1
 		case dbMachine.Size != "" && blueprintMachine.Size != dbMachine.Size:
 			return -1
 		case dbMachine.FloatingIP != "" &&
-			dbMachine.FloatingIP != blueprintMachine.FloatingIP:
+			dbMachine.FloatingIP != blueprintMachine.FloatingIP &&
+			!(dbMachine.AutoFloatingIP && blueprintMachine.AutoFloatingIP):
+			// Machines keep the IPs allocated for them while the blueprint
+			// still asks for one.
 			return -1
 		case dbMachine.Role != db.None && dbMachine.Role != blueprintMachine.Role:
 			return -1
@@ -142,16 +471,19 @@ func machineTxn(view db.Database, bp blueprint.Blueprint, adminKey string) {
 		}
 	}
 
-	pairs, bootList, terminateList := join.Join(blueprintMachines, dbMachines,
-		scoreFun)
+	pairs, bootList, terminateList := rec.ScoredJoin("machines",
+		blueprintMachines, dbMachines, scoreFun)
 
+	rj := rec.Join(blueprintMachines, dbMachines)
 	for _, toTerminate := range terminateList {
 		toTerminate := toTerminate.(db.Machine)
+		rj.Decide("stop", toTerminate)
 		view.Remove(toTerminate)
 	}
 
 	for _, bootSet := range bootList {
 		bootSet := bootSet.(db.Machine)
+		rj.Decide("boot", bootSet)
 
 		pairs = append(pairs, join.Pair{L: bootSet, R: view.InsertMachine()})
 	}
@@ -166,9 +498,277 @@ func machineTxn(view db.Database, bp blueprint.Blueprint, adminKey string) {
 		dbMachine.DiskSize = blueprintMachine.DiskSize
 		dbMachine.Provider = blueprintMachine.Provider
 		dbMachine.Region = blueprintMachine.Region
+		dbMachine.Zone = blueprintMachine.Zone
+		dbMachine.PlacementGroup = blueprintMachine.PlacementGroup
+		dbMachine.VolumeType = blueprintMachine.VolumeType
+		dbMachine.IOPS = blueprintMachine.IOPS
+		dbMachine.DiskType = blueprintMachine.DiskType
+		dbMachine.Volumes = blueprintMachine.Volumes
+		dbMachine.IPv6 = blueprintMachine.IPv6
 		dbMachine.SSHKeys = blueprintMachine.SSHKeys
-		dbMachine.FloatingIP = blueprintMachine.FloatingIP
+		if !dbMachine.AutoFloatingIP || !blueprintMachine.AutoFloatingIP {
+			dbMachine.FloatingIP = blueprintMachine.FloatingIP
+		}
+		dbMachine.AutoFloatingIP = blueprintMachine.AutoFloatingIP
 		dbMachine.Preemptible = blueprintMachine.Preemptible
+		dbMachine.MaxPrice = blueprintMachine.MaxPrice
+		dbMachine.OnDemandFallback = blueprintMachine.OnDemandFallback
+		dbMachine.Sysctls = blueprintMachine.Sysctls
+		dbMachine.Hugepages = blueprintMachine.Hugepages
+		dbMachine.Hooks = blueprintMachine.Hooks
+		dbMachine.Bootstrap = blueprintMachine.Bootstrap
+		dbMachine.Hostname = blueprintMachine.Hostname
+		dbMachine.Tags = blueprintMachine.Tags
+		dbMachine.Labels = blueprintMachine.Labels
+		dbMachine.VPC = blueprintMachine.VPC
+		dbMachine.Subnet = blueprintMachine.Subnet
+		dbMachine.InstanceProfile = blueprintMachine.InstanceProfile
+		dbMachine.SecurityGroups = blueprintMachine.SecurityGroups
+		dbMachine.Image = blueprintMachine.Image
+		dbMachine.GPUs = blueprintMachine.GPUs
+		dbMachine.GPUType = blueprintMachine.GPUType
+		dbMachine.Arch = blueprintMachine.Arch
+		dbMachine.Host = blueprintMachine.Host
+		dbMachine.SSHUser = blueprintMachine.SSHUser
+		dbMachine.SSHKeyPath = blueprintMachine.SSHKeyPath
 		view.Commit(dbMachine)
 	}
 }
+
+// checkZone returns an error if `bpm` can't be placed in the availability zone
+// that it chose.  Only Amazon machines choose zones, which are named after their
+// region followed by a letter, e.g. us-west-2a.
+func checkZone(bpm blueprint.Machine, m db.Machine) error {
+	switch {
+	case m.Provider != db.Amazon:
+		return errors.New("only Amazon machines may specify a zone")
+	case bpm.Subnet != "":
+		return errors.New("the zone of a machine in a subnet is the " +
+			"subnet's zone")
+	case len(bpm.Zone) != len(m.Region)+1 ||
+		!strings.HasPrefix(bpm.Zone, m.Region) ||
+		bpm.Zone[len(m.Region)] < 'a' || bpm.Zone[len(m.Region)] > 'z':
+		return fmt.Errorf("%q isn't a zone of region %s", bpm.Zone, m.Region)
+	}
+	return nil
+}
+
+// The EBS volume types that Amazon machines may choose, and whether they accept
+// provisioned IOPS.
+var volumeTypes = map[string]bool{
+	"gp2": false, "gp3": true, "io1": true, "io2": true, "st1": false,
+	"sc1": false,
+}
+
+// The disk types that Google machines may choose.
+var diskTypes = map[string]bool{
+	"pd-standard": true, "pd-ssd": true, "local-ssd": true,
+}
+
+// checkVolume returns an error if `bpm` chose a volume type or IOPS that its
+// provider `p` can't provide.  The io1 and io2 types require IOPS, which gp3
+// volumes may optionally raise above their baseline.
+func checkVolume(bpm blueprint.Machine, p db.ProviderName) error {
+	if bpm.VolumeType == "" {
+		if bpm.IOPS != 0 {
+			return errors.New("IOPS require a volume type")
+		}
+		return nil
+	}
+
+	provisioned, ok := volumeTypes[bpm.VolumeType]
+	switch {
+	case p != db.Amazon:
+		return errors.New("only Amazon machines may specify a volume type")
+	case !ok:
+		return fmt.Errorf("unknown volume type %q", bpm.VolumeType)
+	case bpm.IOPS < 0:
+		return errors.New("IOPS must not be negative")
+	case !provisioned && bpm.IOPS != 0:
+		return fmt.Errorf("%s volumes don't accept IOPS", bpm.VolumeType)
+	case bpm.IOPS == 0 && (bpm.VolumeType == "io1" || bpm.VolumeType == "io2"):
+		return fmt.Errorf("%s volumes require IOPS", bpm.VolumeType)
+	}
+	return nil
+}
+
+// Volume names are restricted to the names that DigitalOcean accepts, less the
+// namespace and droplet ID that it prefixes and suffixes them with.
+var volumeNameRegex = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// DigitalOcean volumes are between 1GB and 16TB.
+const maxVolumeSize = 16 * 1024
+
+// checkVolumes returns an error if the block storage `volumes` can't be attached
+// to a machine of provider `p`.
+func checkVolumes(volumes []blueprint.MachineVolume, p db.ProviderName) error {
+	if len(volumes) > 0 && p != db.DigitalOcean {
+		return errors.New("only DigitalOcean machines may attach volumes")
+	}
+
+	names := map[string]bool{}
+	for _, v := range volumes {
+		switch {
+		case !volumeNameRegex.MatchString(v.Name):
+			return fmt.Errorf("malformed volume name: %q", v.Name)
+		case names[v.Name]:
+			return fmt.Errorf("duplicate volume name: %q", v.Name)
+		case v.Size < 1 || v.Size > maxVolumeSize:
+			return fmt.Errorf("volume %s must be between 1 and %d GB",
+				v.Name, maxVolumeSize)
+		}
+		names[v.Name] = true
+	}
+	return nil
+}
+
+// sortedVolumes returns a copy of `volumes` sorted by name, so that machines can
+// be compared regardless of the order in which their volumes were declared.
+func sortedVolumes(volumes []blueprint.MachineVolume) []blueprint.MachineVolume {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	sorted := append([]blueprint.MachineVolume{}, volumes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// spreadMasters places the Amazon masters that don't choose their own zone or
+// subnet in the zones of their region, in turn.  Masters are assigned zones in
+// the order of the blueprint, so that each keeps its zone across deployments.
+func spreadMasters(machines []db.Machine) {
+	counts := map[string]int{}
+	for i, m := range machines {
+		if m.Role != db.Master || m.Provider != db.Amazon || m.Zone != "" ||
+			m.Subnet != "" {
+			continue
+		}
+
+		zones := cloud.Zones(m.Provider, m.Region)
+		if len(zones) == 0 {
+			continue
+		}
+		machines[i].Zone = zones[counts[m.Region]%len(zones)]
+		counts[m.Region]++
+	}
+}
+
+var sysctlKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-]+([./][a-zA-Z0-9_\-]+)*$`)
+
+// checkSysctls verifies that the sysctls can be safely written into the
+// machine's boot script.
+func checkSysctls(sysctls map[string]string) error {
+	for key, val := range sysctls {
+		if !sysctlKeyRegex.MatchString(key) {
+			return fmt.Errorf("malformed sysctl key: %q", key)
+		}
+		if strings.ContainsAny(val, "\n\r") {
+			return fmt.Errorf("sysctl %s has a multi-line value", key)
+		}
+	}
+	return nil
+}
+
+// Tags are restricted to the keys and values that every provider accepts, which
+// are those of Google's labels.
+var tagKeyRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+var tagValueRegex = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+
+// checkTags verifies that the tags can be applied by every provider.
+func checkTags(tags map[string]string) error {
+	for key, val := range tags {
+		if !tagKeyRegex.MatchString(key) {
+			return fmt.Errorf("malformed tag key: %q", key)
+		}
+		if !tagValueRegex.MatchString(val) {
+			return fmt.Errorf("tag %s has a malformed value: %q", key, val)
+		}
+	}
+	return nil
+}
+
+// Labels may not contain "=", which separates the key from the value in placement
+// rules, so that each rule is unambiguous.
+var labelKeyRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+var labelValueRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{0,63}$`)
+
+// checkLabels verifies that the labels can be referenced by placement rules.
+func checkLabels(labels map[string]string) error {
+	for key, val := range labels {
+		if !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("malformed label key: %q", key)
+		}
+		if !labelValueRegex.MatchString(val) {
+			return fmt.Errorf("label %s has a malformed value: %q", key, val)
+		}
+	}
+	return nil
+}
+
+// Security group IDs are "sg-" followed by 8 hex digits, or 17 for groups created
+// since Amazon lengthened its resource IDs.
+var securityGroupRegex = regexp.MustCompile(`^sg-[0-9a-f]{8}([0-9a-f]{9})?$`)
+
+// checkSecurityGroups returns the sorted IDs of the security groups in `groups`,
+// or an error if any of them are malformed.
+func checkSecurityGroups(groups []string) ([]string, error) {
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	set := map[string]struct{}{}
+	for _, group := range groups {
+		if !securityGroupRegex.MatchString(group) {
+			return nil, fmt.Errorf("malformed security group ID: %s", group)
+		}
+		set[group] = struct{}{}
+	}
+
+	var sorted []string
+	for group := range set {
+		sorted = append(sorted, group)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// Hostnames are restricted to the names that every provider accepts for its
+// instances: a lowercase DNS label that starts with a letter.
+var hostnameRegex = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// expandHostname replaces the placeholders in the hostname `pattern` of `m`, the
+// `index`th machine of its role, and verifies that the result is a valid hostname.
+func expandHostname(pattern string, m db.Machine, index int) (string, error) {
+	hostname := strings.NewReplacer(
+		"{role}", strings.ToLower(string(m.Role)),
+		"{index}", strconv.Itoa(index),
+		"{region}", strings.ToLower(m.Region),
+	).Replace(pattern)
+
+	if !hostnameRegex.MatchString(hostname) {
+		return "", fmt.Errorf("malformed hostname: %q", hostname)
+	}
+	return hostname, nil
+}
+
+// checkHooks verifies that each hook is for a known event, and is either a
+// command or a webhook.
+func checkHooks(hooks []blueprint.MachineHook) error {
+	for _, hook := range hooks {
+		switch hook.Event {
+		case blueprint.PreBoot, blueprint.PostConnect, blueprint.PreStop,
+			blueprint.DiskUsage:
+		default:
+			return fmt.Errorf("unknown hook event: %q", hook.Event)
+		}
+
+		if (hook.Command == "") == (hook.URL == "") {
+			return fmt.Errorf("%s hook must have either a command or a URL",
+				hook.Event)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/kelda/kelda/blueprint"
@@ -261,6 +262,779 @@ func TestSort(t *testing.T) {
 	})
 }
 
+func TestSysctls(t *testing.T) {
+	conn := db.New()
+
+	sysctls := map[string]string{"net.core.somaxconn": "1024"}
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Sysctls: sysctls, Hugepages: 128},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Sysctls: map[string]string{"vm.x; rm -rf /": "1"}},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, sysctls, workers[0].Sysctls)
+	assert.Equal(t, 128, workers[0].Hugepages)
+
+	assert.NoError(t, checkSysctls(map[string]string{
+		"net/ipv4/ip_forward":          "1",
+		"net.ipv4.ip_local_port_range": "1024 65000",
+	}))
+	assert.Error(t, checkSysctls(map[string]string{"vm.swappiness": "1\n2"}))
+	assert.Error(t, checkSysctls(map[string]string{"": "1"}))
+}
+
+func TestVPC(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				VPC: "vpc-1", Subnet: "subnet-1"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Subnet: "subnet-1"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				VPC: "vpc-1", Subnet: "subnet-1"},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "vpc-1", workers[0].VPC)
+	assert.Equal(t, "subnet-1", workers[0].Subnet)
+
+	// Moving the machine to another subnet replaces it.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				VPC: "vpc-1", Subnet: "subnet-2"},
+		},
+	}, "")
+
+	_, newWorkers := selectMachines(conn)
+	assert.Len(t, newWorkers, 1)
+	assert.Equal(t, "subnet-2", newWorkers[0].Subnet)
+	assert.NotEqual(t, workers[0].ID, newWorkers[0].ID)
+}
+
+func TestInstanceProfile(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				InstanceProfile: "app"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				InstanceProfile: "app"},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "app", workers[0].InstanceProfile)
+
+	// Changing the instance profile replaces the machine.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				InstanceProfile: "web"},
+		},
+	}, "")
+
+	_, newWorkers := selectMachines(conn)
+	assert.Len(t, newWorkers, 1)
+	assert.Equal(t, "web", newWorkers[0].InstanceProfile)
+	assert.NotEqual(t, workers[0].ID, newWorkers[0].ID)
+}
+
+func TestSecurityGroups(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				SecurityGroups: []string{"sg-0123456789abcdef0",
+					"sg-01234567", "sg-01234567"}},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				SecurityGroups: []string{"corp"}},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				SecurityGroups: []string{"sg-01234567"}},
+		},
+	}, "")
+
+	_, workers := selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, []string{"sg-01234567", "sg-0123456789abcdef0"},
+		workers[0].SecurityGroups)
+
+	// Changing the security groups replaces the machine.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				SecurityGroups: []string{"sg-01234567"}},
+		},
+	}, "")
+
+	_, newWorkers := selectMachines(conn)
+	assert.Len(t, newWorkers, 1)
+	assert.Equal(t, []string{"sg-01234567"}, newWorkers[0].SecurityGroups)
+	assert.NotEqual(t, workers[0].ID, newWorkers[0].ID)
+}
+
+func TestAutoFloatingIP(t *testing.T) {
+	conn := db.New()
+
+	bp := blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				FloatingIP: blueprint.AutoFloatingIP},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				FloatingIP: blueprint.AutoFloatingIP},
+		},
+	}
+	updateBlueprint(t, conn, bp, "")
+
+	_, workers := selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.True(t, workers[0].AutoFloatingIP)
+	assert.Empty(t, workers[0].FloatingIP)
+
+	// The IP allocated by the cloud provider is kept across updates.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		workers[0].FloatingIP = "1.2.3.4"
+		view.Commit(workers[0])
+		return nil
+	})
+	updateBlueprint(t, conn, bp, "")
+
+	_, newWorkers := selectMachines(conn)
+	assert.Len(t, newWorkers, 1)
+	assert.Equal(t, workers[0].ID, newWorkers[0].ID)
+	assert.Equal(t, "1.2.3.4", newWorkers[0].FloatingIP)
+	assert.True(t, newWorkers[0].AutoFloatingIP)
+}
+
+func TestArch(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Role: "Worker", RAM: blueprint.Range{Min: 2},
+				Arch: blueprint.ARM64},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Arch: blueprint.ARM64},
+			{Provider: "DigitalOcean", Role: "Worker", Arch: blueprint.ARM64},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Empty(t, masters[0].Arch)
+
+	// Only the machine whose size could be chosen for ARM is booted.
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "a1.medium", workers[0].Size)
+	assert.Equal(t, blueprint.ARM64, workers[0].Arch)
+
+	// Sizes imply their architecture.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m6g.large", Role: "Worker"},
+		},
+	}, "")
+
+	_, workers = selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, blueprint.ARM64, workers[0].Arch)
+}
+
+func TestImage(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Image: "ami-1"},
+			{Provider: "DigitalOcean", Size: "2gb", Role: "Worker",
+				Image: "snapshot"},
+			{Provider: "Vagrant", Size: "2gb", Role: "Worker",
+				Image: "box"},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "ami-1", workers[0].Image)
+
+	// Changing the image replaces the machine.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Image: "ami-2"},
+		},
+	}, "")
+
+	_, newWorkers := selectMachines(conn)
+	assert.Len(t, newWorkers, 1)
+	assert.Equal(t, "ami-2", newWorkers[0].Image)
+	assert.NotEqual(t, workers[0].ID, newWorkers[0].ID)
+}
+
+func TestZones(t *testing.T) {
+	conn := db.New()
+
+	master := blueprint.Machine{Provider: "Amazon", Region: "us-west-2",
+		Size: "m4.large", Role: "Master"}
+	pinned := master
+	pinned.Zone = "us-west-2c"
+	worker := blueprint.Machine{Provider: "Amazon", Region: "us-west-2",
+		Size: "c4.8xlarge", Role: "Worker", Zone: "us-west-2a",
+		PlacementGroup: "pool"}
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{master, pinned, master, master, worker,
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Zone: "us-west-2a"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				Zone: "us-east1-b"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				PlacementGroup: "pool"},
+		},
+		SpreadMasters: true,
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	var zones []string
+	for _, m := range masters {
+		zones = append(zones, m.Zone)
+	}
+	sort.Strings(zones)
+	assert.Equal(t, []string{"us-west-2a", "us-west-2b", "us-west-2c",
+		"us-west-2c"}, zones)
+
+	// Workers aren't spread, and zones must belong to the machine's region.
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "us-west-2a", workers[0].Zone)
+	assert.Equal(t, "pool", workers[0].PlacementGroup)
+
+	// Without spreading, the masters that didn't choose a zone are replaced.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{master, pinned, worker},
+	}, "")
+
+	newMasters, _ := selectMachines(conn)
+	assert.Len(t, newMasters, 2)
+	for _, m := range newMasters {
+		if m.Zone == "" {
+			for _, old := range masters {
+				assert.NotEqual(t, old.ID, m.ID)
+			}
+		} else {
+			assert.Equal(t, "us-west-2c", m.Zone)
+		}
+	}
+}
+
+func TestCheckZone(t *testing.T) {
+	m := db.Machine{Provider: db.Amazon, Region: "us-west-2"}
+	assert.NoError(t, checkZone(blueprint.Machine{Zone: "us-west-2a"}, m))
+	assert.EqualError(t, checkZone(blueprint.Machine{Zone: "us-west-1a"}, m),
+		`"us-west-1a" isn't a zone of region us-west-2`)
+	assert.EqualError(t, checkZone(blueprint.Machine{Zone: "us-west-2"}, m),
+		`"us-west-2" isn't a zone of region us-west-2`)
+	assert.EqualError(t, checkZone(blueprint.Machine{Zone: "us-west-2a",
+		Subnet: "subnet-1"}, m),
+		"the zone of a machine in a subnet is the subnet's zone")
+
+	m.Provider = db.Google
+	assert.EqualError(t, checkZone(blueprint.Machine{Zone: "us-west-2a"}, m),
+		"only Amazon machines may specify a zone")
+}
+
+func TestCheckVolume(t *testing.T) {
+	check := func(volumeType string, iops int, p db.ProviderName) error {
+		return checkVolume(blueprint.Machine{VolumeType: volumeType,
+			IOPS: iops}, p)
+	}
+
+	assert.NoError(t, check("", 0, db.Google))
+	assert.NoError(t, check("gp3", 0, db.Amazon))
+	assert.NoError(t, check("gp3", 6000, db.Amazon))
+	assert.NoError(t, check("io2", 4000, db.Amazon))
+
+	assert.EqualError(t, check("", 4000, db.Amazon),
+		"IOPS require a volume type")
+	assert.EqualError(t, check("gp3", 0, db.Google),
+		"only Amazon machines may specify a volume type")
+	assert.EqualError(t, check("ssd", 0, db.Amazon), `unknown volume type "ssd"`)
+	assert.EqualError(t, check("gp3", -1, db.Amazon),
+		"IOPS must not be negative")
+	assert.EqualError(t, check("st1", 500, db.Amazon),
+		"st1 volumes don't accept IOPS")
+	assert.EqualError(t, check("io1", 0, db.Amazon), "io1 volumes require IOPS")
+}
+
+func TestCheckVolumes(t *testing.T) {
+	volume := func(name string, size int) blueprint.MachineVolume {
+		return blueprint.MachineVolume{Name: name, Size: size}
+	}
+
+	assert.NoError(t, checkVolumes(nil, db.Google))
+	assert.NoError(t, checkVolumes([]blueprint.MachineVolume{
+		volume("data", 100), volume("scratch-1", 1)}, db.DigitalOcean))
+
+	assert.EqualError(t, checkVolumes([]blueprint.MachineVolume{
+		volume("data", 100)}, db.Amazon),
+		"only DigitalOcean machines may attach volumes")
+	assert.EqualError(t, checkVolumes([]blueprint.MachineVolume{
+		volume("Data", 100)}, db.DigitalOcean), `malformed volume name: "Data"`)
+	assert.EqualError(t, checkVolumes([]blueprint.MachineVolume{
+		volume("data", 100), volume("data", 10)}, db.DigitalOcean),
+		`duplicate volume name: "data"`)
+	assert.EqualError(t, checkVolumes([]blueprint.MachineVolume{
+		volume("data", 0)}, db.DigitalOcean),
+		"volume data must be between 1 and 16384 GB")
+}
+
+func TestDiskType(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Google", Size: "n1-standard-1", Role: "Master"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				DiskType: "local-ssd"},
+			{Provider: "Google", Size: "n1-standard-2", Role: "Worker",
+				DiskType: "ssd"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				DiskType: "pd-ssd"},
+		},
+	}, "")
+
+	_, workers := selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "local-ssd", workers[0].DiskType)
+
+	// Changing a machine's disk type replaces it.
+	id := workers[0].ID
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Google", Size: "n1-standard-1", Role: "Master"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				DiskType: "pd-ssd"},
+		},
+	}, "")
+
+	_, workers = selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.NotEqual(t, id, workers[0].ID)
+	assert.Equal(t, "pd-ssd", workers[0].DiskType)
+}
+
+func TestIPv6(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				VPC: "vpc-1", Subnet: "subnet-1", IPv6: true},
+
+			// Amazon machines must choose a subnet, and Google machines
+			// can't have IPv6 addresses.
+			{Provider: "Amazon", Size: "m4.xlarge", Role: "Worker",
+				IPv6: true},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				IPv6: true},
+		},
+	}, "")
+
+	_, workers := selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.True(t, workers[0].IPv6)
+
+	// Disabling IPv6 replaces the machine.
+	id := workers[0].ID
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				VPC: "vpc-1", Subnet: "subnet-1"},
+		},
+	}, "")
+
+	_, workers = selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.NotEqual(t, id, workers[0].ID)
+	assert.False(t, workers[0].IPv6)
+}
+
+func TestVolumes(t *testing.T) {
+	conn := db.New()
+
+	data := blueprint.MachineVolume{Name: "data", Size: 100, Persistent: true}
+	scratch := blueprint.MachineVolume{Name: "scratch", Size: 10}
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "DigitalOcean", Size: "512mb", Role: "Master"},
+			{Provider: "DigitalOcean", Size: "512mb", Role: "Worker",
+				Volumes: []blueprint.MachineVolume{scratch, data}},
+
+			// Only one machine may attach each persistent volume.
+			{Provider: "DigitalOcean", Size: "1gb", Role: "Worker",
+				Volumes: []blueprint.MachineVolume{data}},
+		},
+	}, "")
+
+	_, workers := selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, []blueprint.MachineVolume{data, scratch}, workers[0].Volumes)
+
+	// Changing a machine's volumes replaces it.
+	id := workers[0].ID
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "DigitalOcean", Size: "512mb", Role: "Master"},
+			{Provider: "DigitalOcean", Size: "512mb", Role: "Worker",
+				Volumes: []blueprint.MachineVolume{data}},
+		},
+	}, "")
+
+	_, workers = selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.NotEqual(t, id, workers[0].ID)
+	assert.Equal(t, []blueprint.MachineVolume{data}, workers[0].Volumes)
+}
+
+func TestGPUs(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Role: "Worker", GPUs: 2,
+				GPUType: "nvidia-tesla-v100"},
+			{Provider: "Google", Size: "n1-standard-1", Role: "Worker",
+				GPUs: 2},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker", GPUs: 1},
+			{Provider: "DigitalOcean", Role: "Worker", GPUs: 1},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Zero(t, masters[0].GPUs)
+
+	// Amazon machines record the GPUs of the chosen size, while Google machines
+	// have the requested GPUs attached.
+	assert.Len(t, workers, 2)
+	byProvider := map[db.ProviderName]db.Machine{}
+	for _, m := range workers {
+		byProvider[m.Provider] = m
+	}
+	assert.Equal(t, "p3.8xlarge", byProvider[db.Amazon].Size)
+	assert.Equal(t, 4, byProvider[db.Amazon].GPUs)
+	assert.Equal(t, "nvidia-tesla-v100", byProvider[db.Amazon].GPUType)
+	assert.Equal(t, "n1-standard-1", byProvider[db.Google].Size)
+	assert.Equal(t, 2, byProvider[db.Google].GPUs)
+	assert.Equal(t, "nvidia-tesla-k80", byProvider[db.Google].GPUType)
+}
+
+func TestTags(t *testing.T) {
+	conn := db.New()
+
+	tags := map[string]string{"cost-center": "research", "team": ""}
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Tags: tags},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Tags: map[string]string{"Team": "infra"}},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, tags, workers[0].Tags)
+
+	assert.NoError(t, checkTags(nil))
+	assert.Error(t, checkTags(map[string]string{"1team": "infra"}))
+	assert.Error(t, checkTags(map[string]string{"team": "Infra"}))
+	assert.Error(t, checkTags(map[string]string{"team": "a:b"}))
+}
+
+func TestLabels(t *testing.T) {
+	conn := db.New()
+
+	labels := map[string]string{"ssd": "true", "example.com/tier": "gold"}
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Labels: labels},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Labels: map[string]string{"ssd": "a=b"}},
+		},
+	}, "")
+
+	_, workers := selectMachines(conn)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, labels, workers[0].Labels)
+
+	// Changing a machine's labels updates it in place.
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Labels: map[string]string{"ssd": "false"}},
+		},
+	}, "")
+
+	_, updated := selectMachines(conn)
+	assert.Len(t, updated, 1)
+	assert.Equal(t, workers[0].ID, updated[0].ID)
+	assert.Equal(t, map[string]string{"ssd": "false"}, updated[0].Labels)
+
+	assert.NoError(t, checkLabels(nil))
+	assert.Error(t, checkLabels(map[string]string{"": "true"}))
+	assert.Error(t, checkLabels(map[string]string{"a=b": "true"}))
+	assert.Error(t, checkLabels(map[string]string{"ssd": "a b"}))
+}
+
+func TestMachineHooks(t *testing.T) {
+	conn := db.New()
+
+	hooks := []blueprint.MachineHook{
+		{Event: blueprint.PreStop, Command: "deregister $QUILT_PUBLIC_IP"},
+		{Event: blueprint.PostConnect, URL: "https://cmdb.example.com"},
+		{Event: blueprint.DiskUsage, Command: "page-oncall"},
+	}
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Hooks: hooks},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Hooks: []blueprint.MachineHook{{Event: "reboot",
+					Command: "true"}}},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, hooks, workers[0].Hooks)
+
+	assert.NoError(t, checkHooks(hooks))
+	assert.EqualError(t, checkHooks([]blueprint.MachineHook{
+		{Event: blueprint.PreBoot}}),
+		"pre-boot hook must have either a command or a URL")
+	assert.EqualError(t, checkHooks([]blueprint.MachineHook{
+		{Event: blueprint.PreBoot, Command: "true", URL: "url"}}),
+		"pre-boot hook must have either a command or a URL")
+}
+
+func TestMachineBootstrap(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master",
+				Bootstrap: blueprint.UserDataBootstrap},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Bootstrap: blueprint.SSHBootstrap},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Bootstrap: "telnet"},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, blueprint.UserDataBootstrap, masters[0].Bootstrap)
+	assert.Equal(t, blueprint.SSHBootstrap, workers[0].Bootstrap)
+}
+
+func TestMaxPrice(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		MaxPrice: 0.5,
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Preemptible: true, MaxPrice: 0.12},
+			{Provider: "Amazon", Size: "m4.xlarge", Role: "Worker",
+				Preemptible: true},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 2)
+
+	// Only preemptible machines bid, at the blueprint's price by default.
+	assert.Zero(t, masters[0].MaxPrice)
+	prices := map[string]float64{}
+	for _, w := range workers {
+		prices[w.Size] = w.MaxPrice
+	}
+	assert.Equal(t, map[string]float64{"m4.large": 0.12, "m4.xlarge": 0.5},
+		prices)
+}
+
+func TestOnDemandFallback(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		OnDemandFallback: true,
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Preemptible: true},
+		},
+	}, "")
+
+	// Only preemptible machines fall back to on-demand machines.
+	masters, workers := selectMachines(conn)
+	assert.False(t, masters[0].OnDemandFallback)
+	assert.True(t, workers[0].OnDemandFallback)
+}
+
+func TestInterruptedMachines(t *testing.T) {
+	conn := db.New()
+
+	bp := blueprint.Blueprint{Machines: []blueprint.Machine{
+		{Provider: "Amazon", Size: "m4.large", Role: "Master"},
+		{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+			Preemptible: true},
+	}}
+	updateBlueprint(t, conn, bp, "")
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, m := range view.SelectFromMachine(nil) {
+			if m.Role == db.Worker {
+				m.CloudID = "interrupted"
+				m.Interrupted = true
+				view.Commit(m)
+			}
+		}
+		return nil
+	})
+
+	// A replacement is booted for the interrupted worker, which is left for
+	// the cloud to remove once it's reclaimed.
+	updateBlueprint(t, conn, bp, "")
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Len(t, workers, 2)
+	for _, w := range workers {
+		assert.Equal(t, w.CloudID == "interrupted", w.Interrupted)
+	}
+}
+
+func TestHostnames(t *testing.T) {
+	conn := db.New()
+
+	pattern := "{role}-{index}-{region}"
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Amazon", Size: "m4.large", Role: "Master",
+				Hostname: pattern},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Hostname: pattern},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Region: "us-east-1", Hostname: pattern},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Hostname: "Bad_Name"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Hostname: "worker-1-us-west-1"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker"},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Equal(t, "master-1-us-west-1", masters[0].Hostname)
+
+	var hostnames []string
+	for _, m := range workers {
+		hostnames = append(hostnames, m.Hostname)
+	}
+	sort.Strings(hostnames)
+	assert.Equal(t, []string{"", "worker-1-us-west-1", "worker-2-us-east-1"},
+		hostnames)
+
+	_, err := expandHostname("{index}-{role}", db.Machine{Role: db.Worker}, 1)
+	assert.EqualError(t, err, `malformed hostname: "1-worker"`)
+}
+
+func TestStaticHosts(t *testing.T) {
+	conn := db.New()
+
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Static", Role: "Master", Host: "10.0.0.1",
+				SSHUser: "ubuntu", SSHKeyPath: "/key"},
+			{Provider: "Static", Role: "Worker", Host: "10.0.0.2"},
+			{Provider: "Static", Role: "Worker", Host: "10.0.0.2"},
+			{Provider: "Static", Role: "Worker", Host: "lab-1"},
+			{Provider: "Static", Role: "Worker"},
+			{Provider: "Amazon", Size: "m4.large", Role: "Worker",
+				Host: "10.0.0.3"},
+		},
+	}, "")
+
+	masters, workers := selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Equal(t, "10.0.0.1", masters[0].Host)
+	assert.Equal(t, "ubuntu", masters[0].SSHUser)
+	assert.Equal(t, "/key", masters[0].SSHKeyPath)
+	assert.Equal(t, "static", masters[0].Size)
+
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "10.0.0.2", workers[0].Host)
+
+	// Machines are pinned to their hosts, so changing a machine's host replaces
+	// it.
+	masterID, workerID := masters[0].ID, workers[0].ID
+	updateBlueprint(t, conn, blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{Provider: "Static", Role: "Master", Host: "10.0.0.1",
+				SSHUser: "ubuntu", SSHKeyPath: "/key"},
+			{Provider: "Static", Role: "Worker", Host: "10.0.0.4"},
+		},
+	}, "")
+
+	masters, workers = selectMachines(conn)
+	assert.Len(t, masters, 1)
+	assert.Equal(t, masterID, masters[0].ID)
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "10.0.0.4", workers[0].Host)
+	assert.NotEqual(t, workerID, workers[0].ID)
+}
+
 func selectMachines(conn db.Conn) (masters, workers []db.Machine) {
 	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		masters = view.SelectFromMachine(func(m db.Machine) bool {
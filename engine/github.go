@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The public keys of the GitHub users that blueprints grant SSH access, by
+// username.  Users whose keys haven't been fetched yet map to nil.
+var githubKeyCache = struct {
+	sync.Mutex
+	keys map[string][]string
+}{keys: map[string][]string{}}
+
+// Receives a value when a user is added to the cache, so that their keys are
+// fetched without waiting for the next refresh.
+var githubUserAdded = make(chan struct{}, 1)
+
+// How often the keys of every cached user are fetched again.
+var githubRefreshInterval = 5 * time.Minute
+
+var githubClient = &http.Client{Timeout: 10 * time.Second}
+
+// GitHub usernames are at most 39 alphanumeric characters and single hyphens,
+// which can't begin or end the name.
+var githubUserRegex = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+
+// checkGithubUsers verifies that `users` are valid GitHub usernames.
+func checkGithubUsers(users []string) error {
+	for _, user := range users {
+		if len(user) > 39 || !githubUserRegex.MatchString(user) {
+			return fmt.Errorf("malformed GitHub username: %q", user)
+		}
+	}
+	return nil
+}
+
+// githubKeys returns the cached public keys of `users`.  Users that aren't in the
+// cache are added to it, and their keys are returned once they've been fetched.
+func githubKeys(users []string) []string {
+	githubKeyCache.Lock()
+	defer githubKeyCache.Unlock()
+
+	var keys []string
+	for _, user := range users {
+		userKeys, ok := githubKeyCache.keys[user]
+		if !ok {
+			githubKeyCache.keys[user] = nil
+			select {
+			case githubUserAdded <- struct{}{}:
+			default:
+			}
+		}
+		keys = append(keys, userKeys...)
+	}
+	return keys
+}
+
+// watchGithubKeys fetches the keys of the users in the cache whenever a user is
+// added, and refreshes them every `githubRefreshInterval`.  The returned channel
+// receives a value after each fetch that changed the cached keys.
+func watchGithubKeys() <-chan struct{} {
+	changed := make(chan struct{}, 1)
+
+	go func() {
+		tick := time.Tick(githubRefreshInterval)
+		for {
+			select {
+			case <-tick:
+			case <-githubUserAdded:
+			}
+
+			if updateGithubKeys() {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changed
+}
+
+// updateGithubKeys fetches the keys of every user in the cache, and returns
+// whether any of them changed.  Users whose keys can't be fetched keep their
+// cached keys.
+func updateGithubKeys() bool {
+	githubKeyCache.Lock()
+	var users []string
+	for user := range githubKeyCache.keys {
+		users = append(users, user)
+	}
+	githubKeyCache.Unlock()
+
+	fetched := map[string][]string{}
+	for _, user := range users {
+		keys, err := fetchGithubKeys(user)
+		if err != nil {
+			log.WithError(err).WithField("user", user).Warn(
+				"Failed to fetch GitHub keys")
+			continue
+		}
+		fetched[user] = keys
+	}
+
+	githubKeyCache.Lock()
+	defer githubKeyCache.Unlock()
+
+	changed := false
+	for user, keys := range fetched {
+		if !reflect.DeepEqual(githubKeyCache.keys[user], keys) {
+			githubKeyCache.keys[user] = keys
+			changed = true
+		}
+	}
+
+	if changed {
+		c.Inc("Update GitHub Keys")
+	}
+	return changed
+}
+
+var fetchGithubKeys = fetchGithubKeysImpl
+
+func fetchGithubKeysImpl(user string) ([]string, error) {
+	resp, err := githubClient.Get(fmt.Sprintf("https://github.com/%s.keys", user))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, key := range strings.Split(string(body), "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+)
+
+func TestGithubKeys(t *testing.T) {
+	defer func() {
+		fetchGithubKeys = fetchGithubKeysImpl
+		githubKeyCache.keys = map[string][]string{}
+	}()
+
+	remote := map[string][]string{"alice": {"a1", "a2"}}
+	fetchGithubKeys = func(user string) ([]string, error) {
+		keys, ok := remote[user]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return keys, nil
+	}
+
+	// Users' keys are only returned once they've been fetched.
+	bpms := []blueprint.Machine{
+		{Role: "Master", Provider: "Amazon", Size: "m4.large"},
+		{Role: "Worker", Provider: "Amazon", Size: "m4.large",
+			SSHKeys: []string{"user"}, GithubKeys: []string{"alice", "bob"}},
+	}
+	machines := toDBMachine(bpms, 0, false, "admin")
+	assert.Equal(t, []string{"user", "admin"}, machines[1].SSHKeys)
+	assert.Len(t, githubUserAdded, 1)
+	<-githubUserAdded
+
+	assert.True(t, updateGithubKeys())
+	machines = toDBMachine(bpms, 0, false, "admin")
+	assert.Equal(t, []string{"user", "a1", "a2", "admin"}, machines[1].SSHKeys)
+
+	// Refreshing unchanged keys doesn't trigger an update, and users whose keys
+	// can't be fetched keep their cached keys.
+	assert.False(t, updateGithubKeys())
+	delete(remote, "alice")
+	assert.False(t, updateGithubKeys())
+	assert.Equal(t, []string{"a1", "a2"}, githubKeys([]string{"alice"}))
+
+	remote["alice"] = []string{"a3"}
+	remote["bob"] = []string{}
+	assert.True(t, updateGithubKeys())
+	assert.Equal(t, []string{"a3"}, githubKeys([]string{"bob", "alice"}))
+	assert.Len(t, githubUserAdded, 0)
+
+	// Machines with malformed usernames are skipped.
+	bpms[1].GithubKeys = []string{"../alice"}
+	assert.Len(t, toDBMachine(bpms, 0, false, "admin"), 1)
+}
+
+func TestCheckGithubUsers(t *testing.T) {
+	assert.NoError(t, checkGithubUsers([]string{"ejj", "kklin", "a-b-c", "A1"}))
+
+	for _, user := range []string{"", "-a", "a-", "a--b", "a/b", "a.b",
+		"a234567890123456789012345678901234567890"} {
+		assert.Error(t, checkGithubUsers([]string{user}), user)
+	}
+}
@@ -9,7 +9,7 @@ const baseMachine = new Machine({
   provider: 'Amazon',
   size: 'm4.large',
   region: 'us-west-2',
-  // sshKeys: githubKeys("GITHUB_USERNAME")
+  // githubKeys: ['GITHUB_USERNAME'],
 });
 const workerMachine = baseMachine.clone();
 workerMachine.floatingIp = floatingIp;
@@ -0,0 +1,189 @@
+// Package gitops deploys the blueprint in a git repository, and redeploys it
+// whenever a new commit is pushed to the branch that it watches.  Commits whose
+// blueprint fails to compile, or that the API server would reject, aren't
+// deployed, so the cluster keeps running the last blueprint that was.
+package gitops
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/api/server"
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config describes the blueprint that's deployed.
+type Config struct {
+	// The URL of the repository, in any form that `git clone` accepts.
+	Repo string
+
+	// The branch whose head is deployed.
+	Branch string
+
+	// The path of the blueprint, relative to the root of the repository.
+	Blueprint string
+
+	// How often the branch is checked for new commits.
+	Interval time.Duration
+}
+
+var c = counter.New("GitOps")
+
+// compile is mocked out by the unit tests, which don't require Node.js.
+var compile = blueprint.FromFile
+
+type watcher struct {
+	conn db.Conn
+	cfg  Config
+
+	// The directory in which the repository is checked out.  It's kept
+	// between polls, so that only new commits are fetched.
+	dir string
+
+	// The last commit that was deployed, or that failed to deploy.  Failed
+	// commits aren't retried, as they'll fail again until a fix is pushed.
+	attempted string
+}
+
+// Run checks out the repository described by `cfg` in `dir`, and deploys its
+// blueprint whenever the watched branch changes.
+func Run(conn db.Conn, cfg Config, dir string) {
+	w := watcher{conn: conn, cfg: cfg, dir: dir}
+	for {
+		if err := w.poll(); err != nil {
+			c.Inc("Poll Error")
+			log.WithError(err).WithField("repo", cfg.Repo).Warn(
+				"Failed to deploy blueprint from git")
+		}
+		time.Sleep(cfg.Interval)
+	}
+}
+
+func (w *watcher) poll() error {
+	commit, err := w.fetch()
+	if err != nil {
+		return err
+	}
+
+	if commit == w.attempted {
+		return nil
+	}
+
+	c.Inc("New Commit")
+	if err := w.checkout(commit); err != nil {
+		return err
+	}
+
+	// Failures past this point are due to the commit itself, rather than to
+	// the network, so they aren't retried.
+	w.attempted = commit
+
+	bp, err := compile(filepath.Join(w.dir, w.cfg.Blueprint))
+	if err != nil {
+		c.Inc("Invalid Commit")
+		return fmt.Errorf("compile %s at %s: %s", w.cfg.Blueprint,
+			shortHash(commit), err)
+	}
+
+	if err := server.CheckBlueprint(bp); err != nil {
+		c.Inc("Invalid Commit")
+		return fmt.Errorf("blueprint at %s is invalid: %s",
+			shortHash(commit), err)
+	}
+
+	logger := log.WithField("commit", shortHash(commit))
+	if deploy(w.conn, bp, commit) {
+		c.Inc("Deploy")
+		logger.Info("Deployed blueprint from git")
+	} else {
+		logger.Debug("Blueprint unchanged by new commit")
+	}
+	return nil
+}
+
+// fetch clones the repository if it hasn't been already, and returns the hash of
+// the head of the watched branch.
+func (w *watcher) fetch() (string, error) {
+	if _, err := os.Stat(filepath.Join(w.dir, ".git")); os.IsNotExist(err) {
+		_, err := git("", "clone", "--no-checkout", "--single-branch",
+			"--branch", w.cfg.Branch, w.cfg.Repo, w.dir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := git(w.dir, "fetch", "origin", w.cfg.Branch); err != nil {
+		return "", err
+	}
+	return git(w.dir, "rev-parse", "FETCH_HEAD")
+}
+
+// checkout checks out `commit`, and installs the Node.js modules its blueprint
+// depends on, if any.
+func (w *watcher) checkout(commit string) error {
+	if _, err := git(w.dir, "checkout", "--force", "--detach", commit); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(w.dir, "package.json")); err != nil {
+		return nil
+	}
+
+	cmd := exec.Command("npm", "install")
+	cmd.Dir = w.dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("npm install: %s (%s)", err,
+			strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// deploy replaces the deployed blueprint with `bp`, and records that it was
+// compiled from `commit`.  It returns false if the blueprint didn't change.
+func deploy(conn db.Conn, bp blueprint.Blueprint, commit string) bool {
+	changed := true
+	conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
+		dbBp, err := view.GetBlueprint()
+		if err != nil {
+			dbBp = view.InsertBlueprint()
+		} else {
+			changed = dbBp.Blueprint.String() != bp.String()
+		}
+
+		// The daemon itself deployed the blueprint, rather than a client
+		// on another host.
+		dbBp.Blueprint = bp
+		dbBp.ClientIP = ""
+		dbBp.Commit = commit
+		view.Commit(dbBp)
+		return nil
+	})
+	return changed
+}
+
+// git runs git with `args` in `dir`, and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s (%s)", args[0], err,
+			strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func shortHash(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,112 @@
+package gitops
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestPoll(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	tmp, err := ioutil.TempDir("", "gitops")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	// The blueprints in the test repository are already compiled.
+	compile = func(path string) (blueprint.Blueprint, error) {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return blueprint.Blueprint{}, err
+		}
+		return blueprint.FromJSON(string(contents))
+	}
+	defer func() { compile = blueprint.FromFile }()
+
+	repo := filepath.Join(tmp, "repo")
+	_, err = git("", "init", "-q", repo)
+	require.NoError(t, err)
+	_, err = git(repo, "checkout", "-q", "-b", "prod")
+	require.NoError(t, err)
+
+	push := func(contents string) string {
+		path := filepath.Join(repo, "main.js")
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+		_, err := git(repo, "add", "main.js")
+		require.NoError(t, err)
+		_, err = git(repo, "-c", "user.name=test", "-c", "user.email=test@test",
+			"commit", "-q", "--allow-empty", "-m", "update")
+		require.NoError(t, err)
+		hash, err := git(repo, "rev-parse", "HEAD")
+		require.NoError(t, err)
+		return hash
+	}
+
+	conn := db.New()
+	w := watcher{conn: conn, dir: filepath.Join(tmp, "checkout"), cfg: Config{
+		Repo: repo, Branch: "prod", Blueprint: "main.js"}}
+	deployed := func() db.Blueprint {
+		var bp db.Blueprint
+		conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
+			bp, _ = view.GetBlueprint()
+			return nil
+		})
+		return bp
+	}
+
+	first := push(`{"Namespace": "prod"}`)
+	assert.NoError(t, w.poll())
+	assert.Equal(t, "prod", deployed().Namespace)
+	assert.Equal(t, first, deployed().Commit)
+
+	// A commit that doesn't compile isn't deployed, and isn't retried.
+	push(`{"Namespace":`)
+	assert.Error(t, w.poll())
+	assert.Equal(t, first, deployed().Commit)
+	assert.NoError(t, w.poll())
+
+	// Neither are blueprints that the API server would reject.
+	push(`{"Namespace": "prod",
+	       "Containers": [{"Image": {"Name": "hasCapital"}}]}`)
+	assert.Error(t, w.poll())
+	assert.Equal(t, first, deployed().Commit)
+
+	// Commits that don't change the blueprint are still recorded.
+	fixed := push(`{"Namespace": "prod"}`)
+	assert.NoError(t, w.poll())
+	assert.Equal(t, fixed, deployed().Commit)
+
+	push(`{"Namespace": "staging"}`)
+	assert.NoError(t, w.poll())
+	assert.Equal(t, "staging", deployed().Namespace)
+}
+
+func TestDeploy(t *testing.T) {
+	conn := db.New()
+	conn.Txn(db.BlueprintTable).Run(func(view db.Database) error {
+		bp := view.InsertBlueprint()
+		bp.Namespace = "ns"
+		bp.ClientIP = "8.8.8.8"
+		view.Commit(bp)
+		return nil
+	})
+
+	assert.False(t, deploy(conn, blueprint.Blueprint{Namespace: "ns"}, "a"))
+	assert.True(t, deploy(conn, blueprint.Blueprint{Namespace: "other"}, "b"))
+
+	bps := conn.SelectFromBlueprint(nil)
+	assert.Len(t, bps, 1)
+	assert.Equal(t, "other", bps[0].Namespace)
+	assert.Equal(t, "b", bps[0].Commit)
+	assert.Empty(t, bps[0].ClientIP)
+}
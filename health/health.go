@@ -0,0 +1,157 @@
+// Package health tracks whether the daemon is working, so that it can be
+// supervised by systemd or Kubernetes and restarted if it wedges.
+//
+// The daemon is live as long as its long-running threads keep making progress.
+// It's ready once each thread has made progress at least once, and it can reach
+// the cloud providers that it manages machines in.
+package health
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+)
+
+var c = counter.New("Health")
+
+// A Check is the status of a single thread or dependency.
+type Check struct {
+	Name    string
+	Healthy bool
+	Message string
+}
+
+type thread struct {
+	timeout  time.Duration
+	started  time.Time
+	lastBeat time.Time
+}
+
+var state = struct {
+	sync.Mutex
+	threads      map[string]thread
+	dependencies map[string]error
+}{
+	threads:      map[string]thread{},
+	dependencies: map[string]error{},
+}
+
+var now = time.Now
+
+// Start begins tracking the thread `name`, which must call Beat at least once
+// every `timeout` for the daemon to be live.
+func Start(name string, timeout time.Duration) {
+	state.Lock()
+	defer state.Unlock()
+	state.threads[name] = thread{timeout: timeout, started: now()}
+}
+
+// Beat records that the thread `name` has made progress.
+func Beat(name string) {
+	state.Lock()
+	defer state.Unlock()
+
+	t, ok := state.threads[name]
+	if !ok {
+		panic(fmt.Sprintf("heartbeat from unknown thread %s", name))
+	}
+	t.lastBeat = now()
+	state.threads[name] = t
+}
+
+// Report records the result of the most recent attempt to reach the dependency
+// `name`.  The daemon isn't ready while the attempt is failing.
+func Report(name string, err error) {
+	state.Lock()
+	defer state.Unlock()
+	state.dependencies[name] = err
+}
+
+// Forget stops tracking the dependency `name`.
+func Forget(name string) {
+	state.Lock()
+	defer state.Unlock()
+	delete(state.dependencies, name)
+}
+
+// Live returns the status of each thread, and whether they're all healthy.
+func Live() ([]Check, bool) {
+	state.Lock()
+	defer state.Unlock()
+	return checkThreads(false)
+}
+
+// Ready returns the status of each thread and dependency, and whether they're
+// all healthy.
+func Ready() ([]Check, bool) {
+	state.Lock()
+	defer state.Unlock()
+
+	checks, healthy := checkThreads(true)
+	for name, err := range state.dependencies {
+		check := Check{Name: name, Healthy: err == nil, Message: "ok"}
+		if err != nil {
+			check.Message = err.Error()
+			healthy = false
+		}
+		checks = append(checks, check)
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Name < checks[j].Name
+	})
+	return checks, healthy
+}
+
+// checkThreads returns the status of each thread.  If `requireBeat` is true,
+// threads that haven't beat since they started are unhealthy.  The caller must
+// hold the state lock.
+func checkThreads(requireBeat bool) ([]Check, bool) {
+	healthy := true
+	var checks []Check
+	for name, t := range state.threads {
+		check := Check{Name: name, Healthy: true, Message: "ok"}
+		last := t.lastBeat
+		if last.IsZero() {
+			last = t.started
+			if requireBeat {
+				check.Healthy = false
+				check.Message = "starting"
+			}
+		}
+
+		if since := now().Sub(last); since > t.timeout {
+			check.Healthy = false
+			check.Message = fmt.Sprintf("no progress in %s",
+				since.Truncate(time.Second))
+		}
+
+		healthy = healthy && check.Healthy
+		checks = append(checks, check)
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Name < checks[j].Name
+	})
+	return checks, healthy
+}
+
+// The name of the thread run by WatchDB.
+const dbThread = "db"
+
+// WatchDB beats while the database's triggers fire and its tables can be
+// locked, so that a deadlocked transaction makes the daemon unhealthy.
+func WatchDB(conn db.Conn) {
+	Start(dbThread, 2*time.Minute)
+	for range conn.TriggerTick(30, db.AllTables...).C {
+		conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			return nil
+		})
+		c.Inc("DB Heartbeat")
+		Beat(dbThread)
+	}
+}
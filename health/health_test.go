@@ -0,0 +1,121 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kelda/kelda/health/pb"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestHealth(t *testing.T) {
+	defer reset()
+
+	mockTime := time.Unix(0, 0)
+	now = func() time.Time { return mockTime }
+	defer func() { now = time.Now }()
+
+	// Threads that are starting are live, but not ready.
+	Start("foreman", time.Minute)
+	Start("db", time.Minute)
+	checks, live := Live()
+	assert.True(t, live)
+	assert.Equal(t, []Check{
+		{Name: "db", Healthy: true, Message: "ok"},
+		{Name: "foreman", Healthy: true, Message: "ok"},
+	}, checks)
+
+	checks, ready := Ready()
+	assert.False(t, ready)
+	assert.Equal(t, []Check{
+		{Name: "db", Healthy: false, Message: "starting"},
+		{Name: "foreman", Healthy: false, Message: "starting"},
+	}, checks)
+
+	mockTime = mockTime.Add(30 * time.Second)
+	Beat("foreman")
+	Beat("db")
+	_, ready = Ready()
+	assert.True(t, ready)
+
+	// A failing dependency makes the daemon unready, but not dead.
+	Report("provider Amazon-us-west-1-ns", errors.New("unauthorized"))
+	_, live = Live()
+	assert.True(t, live)
+	checks, ready = Ready()
+	assert.False(t, ready)
+	assert.Contains(t, checks, Check{Name: "provider Amazon-us-west-1-ns",
+		Healthy: false, Message: "unauthorized"})
+
+	Report("provider Amazon-us-west-1-ns", nil)
+	_, ready = Ready()
+	assert.True(t, ready)
+
+	Report("provider Google-us-east1-b-ns", errors.New("timeout"))
+	Forget("provider Google-us-east1-b-ns")
+	_, ready = Ready()
+	assert.True(t, ready)
+
+	// A thread that stops beating is unhealthy.
+	mockTime = mockTime.Add(45 * time.Second)
+	Beat("db")
+	mockTime = mockTime.Add(45 * time.Second)
+	checks, live = Live()
+	assert.False(t, live)
+	assert.Equal(t, []Check{
+		{Name: "db", Healthy: true, Message: "ok"},
+		{Name: "foreman", Healthy: false, Message: "no progress in 1m30s"},
+	}, checks)
+
+	assert.Panics(t, func() { Beat("unknown") })
+}
+
+func TestHandler(t *testing.T) {
+	defer reset()
+
+	Start("foreman", time.Minute)
+
+	recorder := httptest.NewRecorder()
+	handler(Live)(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "[+]foreman ok\nok\n", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handler(Ready)(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "[-]foreman starting\nfailed\n", recorder.Body.String())
+}
+
+func TestServer(t *testing.T) {
+	defer reset()
+
+	Start("foreman", time.Minute)
+	ctx := context.Background()
+
+	for _, service := range []string{"", "liveness"} {
+		resp, err := server{}.Check(ctx, &pb.HealthCheckRequest{
+			Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, pb.HealthCheckResponse_SERVING, resp.Status)
+	}
+
+	resp, err := server{}.Check(ctx, &pb.HealthCheckRequest{
+		Service: "readiness"})
+	assert.NoError(t, err)
+	assert.Equal(t, pb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	_, err = server{}.Check(ctx, &pb.HealthCheckRequest{Service: "API"})
+	assert.Equal(t, codes.NotFound, grpc.Code(err))
+}
+
+func reset() {
+	state.threads = map[string]thread{}
+	state.dependencies = map[string]error{}
+}
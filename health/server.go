@@ -0,0 +1,79 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kelda/kelda/health/pb"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Run serves the /healthz and /readyz endpoints over HTTP on `address`.  Each
+// responds with the status of its checks, and fails with 503 if any are
+// unhealthy.
+func Run(address string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handler(Live))
+	mux.HandleFunc("/readyz", handler(Ready))
+	return http.ListenAndServe(address, mux)
+}
+
+func handler(check func() ([]Check, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks, healthy := check()
+		if !healthy {
+			c.Inc("Unhealthy Probe")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		for _, check := range checks {
+			mark := "+"
+			if !check.Healthy {
+				mark = "-"
+			}
+			fmt.Fprintf(w, "[%s]%s %s\n", mark, check.Name, check.Message)
+		}
+
+		if healthy {
+			fmt.Fprintln(w, "ok")
+		} else {
+			fmt.Fprintln(w, "failed")
+		}
+	}
+}
+
+// RegisterServer registers the gRPC health service on `s`.  The empty service
+// name and "liveness" report whether the daemon is live, and "readiness"
+// whether it's ready.
+func RegisterServer(s *grpc.Server) {
+	pb.RegisterHealthServer(s, server{})
+}
+
+type server struct{}
+
+func (server) Check(ctx context.Context, req *pb.HealthCheckRequest) (
+	*pb.HealthCheckResponse, error) {
+
+	var healthy bool
+	switch req.Service {
+	case "", "liveness":
+		_, healthy = Live()
+	case "readiness":
+		_, healthy = Ready()
+	default:
+		return nil, status.Errorf(codes.NotFound, "unknown service: %s",
+			req.Service)
+	}
+
+	resp := &pb.HealthCheckResponse{
+		Status: pb.HealthCheckResponse_SERVING,
+	}
+	if !healthy {
+		resp.Status = pb.HealthCheckResponse_NOT_SERVING
+	}
+	return resp, nil
+}
@@ -0,0 +1,60 @@
+package health
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NotifySystemd implements systemd's notify protocol when the daemon is run as a
+// service with Type=notify.  It notifies systemd once the daemon is ready, and
+// if WatchdogSec is set, pings the watchdog for as long as the daemon is live,
+// so that systemd restarts the daemon if it wedges.  It does nothing when the
+// daemon isn't run by systemd.
+func NotifySystemd() {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	interval := 5 * time.Second
+	watchdog := false
+	if usec, err := strconv.Atoi(os.Getenv("WATCHDOG_USEC")); err == nil {
+		// Ping at twice the rate systemd requires, as recommended by
+		// sd_watchdog_enabled(3).
+		interval = time.Duration(usec) * time.Microsecond / 2
+		watchdog = true
+	}
+
+	notifiedReady := false
+	for range time.Tick(interval) {
+		if _, ready := Ready(); ready && !notifiedReady {
+			notifiedReady = notify(socket, "READY=1")
+		}
+
+		if _, live := Live(); watchdog && live {
+			notify(socket, "WATCHDOG=1")
+		}
+	}
+}
+
+// notify sends `state` to systemd's notification socket.  Returns whether it
+// was successful.
+func notify(socket, state string) bool {
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		log.WithError(err).Warn("Failed to connect to systemd")
+		return false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.WithError(err).WithField("state", state).Warn(
+			"Failed to notify systemd")
+		return false
+	}
+	return true
+}
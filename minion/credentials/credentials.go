@@ -0,0 +1,255 @@
+// Package credentials issues short-lived cloud credentials to the containers on
+// the minion's machine, so that they can access cloud services such as S3
+// without long-lived keys in their environment.  Each container is issued the
+// credentials of the cloud role that its blueprint assigned it, on behalf of its
+// hostname.
+//
+// The credentials are served on the gateway, at the paths of the cloud's
+// instance metadata API, so that the cloud's SDKs find them once they're pointed
+// at the gateway.  Containers are identified by the source IP of their requests.
+// The minion's OpenFlow rules only forward traffic from its own containers to
+// the gateway, and a container can't complete a TCP handshake from an address
+// that isn't its own, so the address can't be forged.
+//
+// The minion's own credentials, which may assume the role of any container, are
+// those of the machine's instance profile.  The network package drops the
+// containers' traffic to the real instance metadata service while any container
+// has a cloud role, so that containers can't obtain them.
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/ipdef"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Port is the port on the gateway on which credentials are served.
+const Port = 8182
+
+// Endpoint is the URL of the instance metadata API that containers are pointed
+// at to retrieve their credentials.
+var Endpoint = fmt.Sprintf("http://%s:%d", ipdef.GatewayIP, Port)
+
+// EndpointEnv is the environment variable with which Amazon's SDKs are pointed
+// at Endpoint, in place of the instance metadata API.
+const EndpointEnv = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+
+// The lifetime of the credentials issued to containers.  They're reissued once
+// they're within refreshWindow of expiring, which is the window in which
+// Amazon's SDKs retrieve new ones.
+const lifetime = time.Hour
+const refreshWindow = 5 * time.Minute
+
+// The paths of the instance metadata API that Amazon's SDKs retrieve credentials
+// from.  They first request a session token, which guards the API against
+// requests forwarded from outside of the instance.
+const tokenPath = "/latest/api/token"
+const credentialsPath = "/latest/meta-data/iam/security-credentials/"
+
+var c = counter.New("Credentials")
+
+// A credential is a temporary set of keys issued by the cloud, as serialized by
+// Amazon's instance metadata API.
+type credential struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// An issuer issues a credential for the cloud role `role`, on behalf of the
+// container `hostname`.
+type issuer func(role, hostname string) (credential, error)
+
+type server struct {
+	conn     db.Conn
+	provider string
+	issue    issuer
+
+	lock  sync.Mutex
+	cache map[string]credential
+}
+
+// Run serves credentials to the containers on the minion's machine, if it's a
+// worker.  Credentials are only issued on Amazon.
+func Run(conn db.Conn) {
+	for range conn.TriggerTick(30, db.MinionTable).C {
+		self := conn.MinionSelf()
+		if self.Role == db.Worker && self.Provider != "" {
+			break
+		}
+	}
+
+	self := conn.MinionSelf()
+	srv := &server{conn: conn, provider: self.Provider,
+		cache: map[string]credential{}}
+	if db.ProviderName(self.Provider) == db.Amazon {
+		srv.issue = assumeRole(self.Region)
+	}
+
+	// The gateway may not have its address yet, so failures are retried.
+	addr := fmt.Sprintf("%s:%d", ipdef.GatewayIP, Port)
+	for {
+		err := http.ListenAndServe(addr, srv)
+		log.WithError(err).Warn("Failed to serve container credentials.")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Inc("Request")
+
+	// Containers are identified by their address rather than by session
+	// token, so any token is accepted.
+	if r.Method == http.MethodPut && r.URL.Path == tokenPath {
+		ttl := r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds")
+		w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", ttl)
+		fmt.Fprint(w, "quilt")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, credentialsPath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	dbc, ok := srv.container(r.RemoteAddr)
+	if !ok || dbc.CloudRole == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Amazon role ARNs end with the role's name.
+	name := path.Base(dbc.CloudRole)
+	switch strings.TrimPrefix(r.URL.Path, credentialsPath) {
+	case "":
+		fmt.Fprint(w, name)
+	case name:
+		cred, err := srv.credential(dbc)
+		if err != nil {
+			c.Inc("Issue Error")
+			log.WithError(err).WithField("container", dbc.Hostname).Warn(
+				"Failed to issue credentials.")
+			http.Error(w, "failed to issue credentials",
+				http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Code        string
+			Type        string
+			LastUpdated time.Time
+			credential
+		}{"Success", "AWS-HMAC", time.Now().UTC(), cred})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// container returns the container whose IP is the host of `remoteAddr`.
+func (srv *server) container(remoteAddr string) (db.Container, bool) {
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return db.Container{}, false
+	}
+
+	dbcs := srv.conn.SelectFromContainer(func(dbc db.Container) bool {
+		return dbc.IP == ip
+	})
+	if len(dbcs) != 1 {
+		return db.Container{}, false
+	}
+	return dbcs[0], true
+}
+
+// credential returns the credential of `dbc`, which is only issued anew once the
+// cached one is about to expire.
+func (srv *server) credential(dbc db.Container) (credential, error) {
+	if srv.issue == nil {
+		return credential{}, fmt.Errorf("credentials aren't supported on %s",
+			srv.provider)
+	}
+
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	key := dbc.CloudRole + " " + dbc.Hostname
+	if cred, ok := srv.cache[key]; ok &&
+		time.Until(cred.Expiration) > refreshWindow {
+		return cred, nil
+	}
+
+	c.Inc("Issue")
+	cred, err := srv.issue(dbc.CloudRole, dbc.Hostname)
+	if err != nil {
+		return credential{}, err
+	}
+
+	for key, cached := range srv.cache {
+		if time.Now().After(cached.Expiration) {
+			delete(srv.cache, key)
+		}
+	}
+	srv.cache[key] = cred
+	return cred, nil
+}
+
+// assumeRole returns an issuer that assumes the role with the minion's own
+// credentials, which are those of its instance profile, and that containers are
+// cut off from.  The session is named after the container, so that the cloud's
+// audit logs identify it.
+func assumeRole(region string) issuer {
+	sess := session.New()
+	sess.Config.Region = aws.String(region)
+	client := sts.New(sess)
+
+	return func(role, hostname string) (credential, error) {
+		resp, err := client.AssumeRole(&sts.AssumeRoleInput{
+			RoleArn:         aws.String(role),
+			RoleSessionName: aws.String(sessionName(hostname)),
+			DurationSeconds: aws.Int64(int64(lifetime / time.Second)),
+		})
+		if err != nil {
+			return credential{}, err
+		}
+
+		creds := resp.Credentials
+		return credential{
+			AccessKeyID:     aws.StringValue(creds.AccessKeyId),
+			SecretAccessKey: aws.StringValue(creds.SecretAccessKey),
+			Token:           aws.StringValue(creds.SessionToken),
+			Expiration:      aws.TimeValue(creds.Expiration),
+		}, nil
+	}
+}
+
+// sessionName returns the name of the role session of the container `hostname`.
+// Session names are at most 64 characters long.
+func sessionName(hostname string) string {
+	name := "quilt-" + hostname
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,125 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+)
+
+const role = "arn:aws:iam::123456789012:role/uploader"
+
+func TestServeHTTP(t *testing.T) {
+	conn := db.New()
+	conn.Txn(db.ContainerTable).Run(func(view db.Database) error {
+		dbc := view.InsertContainer()
+		dbc.IP = "10.0.0.2"
+		dbc.Hostname = "web"
+		dbc.CloudRole = role
+		view.Commit(dbc)
+
+		dbc = view.InsertContainer()
+		dbc.IP = "10.0.0.3"
+		dbc.Hostname = "db"
+		view.Commit(dbc)
+		return nil
+	})
+
+	expiration := time.Now().Add(time.Hour).UTC().Round(time.Second)
+	var issued []string
+	srv := &server{conn: conn, cache: map[string]credential{},
+		issue: func(role, hostname string) (credential, error) {
+			issued = append(issued, role+" "+hostname)
+			return credential{AccessKeyID: "id", SecretAccessKey: "secret",
+				Token: "token", Expiration: expiration}, nil
+		}}
+
+	request := func(method, path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("PUT", tokenPath, "10.0.0.2:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request("GET", credentialsPath, "10.0.0.2:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "uploader", w.Body.String())
+
+	w = request("GET", credentialsPath+"uploader", "10.0.0.2:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Success", resp["Code"])
+	assert.Equal(t, "id", resp["AccessKeyId"])
+	assert.Equal(t, "secret", resp["SecretAccessKey"])
+	assert.Equal(t, "token", resp["Token"])
+	assert.Equal(t, expiration.Format(time.RFC3339), resp["Expiration"])
+
+	// Credentials are cached until they're about to expire.
+	request("GET", credentialsPath+"uploader", "10.0.0.2:1234")
+	assert.Equal(t, []string{role + " web"}, issued)
+
+	// Only the container's own role is served.
+	w = request("GET", credentialsPath+"admin", "10.0.0.2:1234")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Containers without a role, and unknown addresses, aren't issued
+	// credentials.
+	w = request("GET", credentialsPath, "10.0.0.3:1234")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request("GET", credentialsPath, "10.0.0.4:1234")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// The rest of the metadata API isn't served.
+	w = request("GET", "/latest/meta-data/instance-id", "10.0.0.2:1234")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request("POST", credentialsPath, "10.0.0.2:1234")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	srv.cache = map[string]credential{}
+	srv.issue = func(role, hostname string) (credential, error) {
+		return credential{}, errors.New("access denied")
+	}
+	w = request("GET", credentialsPath+"uploader", "10.0.0.2:1234")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestCredentialRefresh(t *testing.T) {
+	conn := db.New()
+	var issued int
+	srv := &server{conn: conn, provider: "Google",
+		cache: map[string]credential{},
+		issue: func(role, hostname string) (credential, error) {
+			issued++
+			return credential{Expiration: time.Now().Add(time.Minute)}, nil
+		}}
+
+	// Credentials within the refresh window of expiring are reissued.
+	dbc := db.Container{Hostname: "web", CloudRole: role}
+	srv.credential(dbc)
+	srv.credential(dbc)
+	assert.Equal(t, 2, issued)
+
+	srv.issue = nil
+	_, err := srv.credential(dbc)
+	assert.EqualError(t, err, "credentials aren't supported on Google")
+}
+
+func TestSessionName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "quilt-web", sessionName("web"))
+	assert.Len(t, sessionName(strings.Repeat("a", 63)), 64)
+}
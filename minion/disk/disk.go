@@ -0,0 +1,106 @@
+// Package disk tracks how full the partitions of the minion's machine are, and
+// frees space on them before Docker runs out.
+package disk
+
+import (
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const interval = 30 * time.Second
+
+// Once a partition is this full, unused images and stopped containers are
+// removed if the minion's DiskGC flag is set.
+const gcThreshold = 85
+
+// Maps the tracked partitions to a path on them.  The host's filesystem is
+// mounted at /hostfs in the minion's container.  If Docker's directory isn't a
+// separate partition, both report the usage of the root partition.
+var partitions = map[string]string{
+	"root":   "/hostfs",
+	"docker": "/hostfs/var/lib/docker",
+}
+
+var c = counter.New("Disk")
+
+// Run periodically records the disk usage of the minion's machine in the
+// Minion table.
+func Run(conn db.Conn, dk docker.Client) {
+	for range time.Tick(interval) {
+		runOnce(conn, dk)
+	}
+}
+
+func runOnce(conn db.Conn, dk docker.Client) {
+	usage := map[string]int{}
+	for partition, path := range partitions {
+		percent, err := usedPercent(path)
+		if err != nil {
+			log.WithError(err).WithField("partition", partition).Warn(
+				"Failed to get disk usage.")
+			continue
+		}
+		usage[partition] = percent
+	}
+
+	var gc bool
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		gc = self.DiskGC
+		if !reflect.DeepEqual(self.DiskUsage, usage) {
+			self.DiskUsage = usage
+			view.Commit(self)
+		}
+		return nil
+	})
+
+	if !gc || !nearlyFull(usage) {
+		return
+	}
+
+	c.Inc("Garbage Collect")
+	reclaimed, err := dk.Prune()
+	if err != nil {
+		c.Inc("Garbage Collect Error")
+		log.WithError(err).Warn("Failed to remove unused images and containers.")
+		return
+	}
+	log.WithField("usage", usage).Infof("Disk nearly full. Removed unused "+
+		"images and stopped containers, reclaiming %d bytes.", reclaimed)
+}
+
+func nearlyFull(usage map[string]int) bool {
+	for _, percent := range usage {
+		if percent >= gcThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// usedPercent returns the percentage of the space available to unprivileged
+// users on the partition containing `path` that's used, in the manner of df.
+func usedPercent(path string) (int, error) {
+	var stat syscall.Statfs_t
+	if err := statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	used := stat.Blocks - stat.Bfree
+	total := used + stat.Bavail
+	if total == 0 {
+		return 0, nil
+	}
+
+	// Round up, as df does.
+	return int((used*100 + total - 1) / total), nil
+}
+
+var statfs = syscall.Statfs
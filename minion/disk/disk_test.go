@@ -0,0 +1,80 @@
+package disk
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+)
+
+func TestUsedPercent(t *testing.T) {
+	statfs = func(path string, stat *syscall.Statfs_t) error {
+		*stat = syscall.Statfs_t{Blocks: 1000, Bfree: 300, Bavail: 250}
+		return nil
+	}
+	defer func() { statfs = syscall.Statfs }()
+
+	// 700 of the 950 blocks available to users are used.
+	percent, err := usedPercent("/")
+	assert.NoError(t, err)
+	assert.Equal(t, 74, percent)
+
+	statfs = func(path string, stat *syscall.Statfs_t) error {
+		return errors.New("no such file")
+	}
+	_, err = usedPercent("/")
+	assert.EqualError(t, err, "no such file")
+}
+
+func TestRunOnce(t *testing.T) {
+	usage := map[string]uint64{"/hostfs": 50, "/hostfs/var/lib/docker": 90}
+	statfs = func(path string, stat *syscall.Statfs_t) error {
+		*stat = syscall.Statfs_t{Blocks: 100, Bfree: 100 - usage[path],
+			Bavail: 100 - usage[path]}
+		return nil
+	}
+	defer func() { statfs = syscall.Statfs }()
+
+	conn := db.New()
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.InsertMinion()
+		self.Self = true
+		view.Commit(self)
+		return nil
+	})
+
+	md, dk := docker.NewMock()
+	id, err := dk.Run(docker.RunOptions{Name: "stopped", Image: "image"})
+	assert.NoError(t, err)
+	md.StopContainer(id)
+
+	// Without the DiskGC flag, the usage is only recorded.
+	runOnce(conn, dk)
+	assert.Equal(t, map[string]int{"root": 50, "docker": 90},
+		conn.MinionSelf().DiskUsage)
+	assert.Len(t, md.Containers, 1)
+
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		self.DiskGC = true
+		view.Commit(self)
+		return nil
+	})
+	runOnce(conn, dk)
+	assert.Empty(t, md.Containers)
+	assert.Empty(t, md.Pulled)
+
+	// Nothing is removed while the disk has space.
+	usage["/hostfs/var/lib/docker"] = 60
+	id, err = dk.Run(docker.RunOptions{Name: "stopped", Image: "image"})
+	assert.NoError(t, err)
+	md.StopContainer(id)
+	runOnce(conn, dk)
+	assert.Equal(t, map[string]int{"root": 50, "docker": 60},
+		conn.MinionSelf().DiskUsage)
+	assert.Len(t, md.Containers, 1)
+}
@@ -1,10 +1,13 @@
 package docker
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,15 +18,53 @@ import (
 
 	dkc "github.com/fsouza/go-dockerclient"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
 )
 
+// The network created by the Quilt network plugin.  Containers that are also
+// connected to other networks report their IP on this network.
+const quiltNetwork = "quilt"
+
 var pullCacheTimeout = time.Minute
 var networkTimeout = time.Minute
+var execTimeout = 30 * time.Second
+
+// Mirror is the registry that images are pulled from on clusters without internet
+// access.  Images are pulled from their mirrored name (see util.MirrorImage), and
+// tagged with their original name.  Set by the minion's command line flags.
+var Mirror string
+
+// registryCache is the address of the registry that caches Docker Hub images for
+// the machines in this minion's region, or empty if there isn't one.
+var registryCache struct {
+	sync.Mutex
+	addr string
+}
+
+// SetRegistryCache sets the address of the registry that caches Docker Hub images
+// for this minion's region.  Images are pulled through the cache when possible, and
+// directly otherwise.  The cache isn't used by clusters with a Mirror, whose
+// images are already pulled from within the cluster's network.
+func SetRegistryCache(addr string) {
+	registryCache.Lock()
+	registryCache.addr = addr
+	registryCache.Unlock()
+}
 
 // ErrNoSuchContainer is the error returned when an operation is requested on a
 // non-existent container.
 var ErrNoSuchContainer = errors.New("container does not exist")
 
+// A MissingNetworkError is returned by Run when the container should join a
+// network that doesn't exist on this machine.
+type MissingNetworkError struct {
+	Network string
+}
+
+func (err MissingNetworkError) Error() string {
+	return fmt.Sprintf("network %s does not exist", err.Network)
+}
+
 // A Container as returned by the docker client API.
 type Container struct {
 	ID      string
@@ -40,6 +81,18 @@ type Container struct {
 	Env     map[string]string
 	Labels  map[string]string
 	Created time.Time
+
+	// The names of the networks the container is connected to.
+	Networks []string
+
+	// Whether the container has a TTY.
+	TTY bool
+
+	// The container's DNS servers and search domains, and the entries added to
+	// its /etc/hosts, from hostname to IP.
+	DNS        []string
+	DNSSearch  []string
+	ExtraHosts map[string]string
 }
 
 // ContainerSlice is an alias for []Container to allow for joins
@@ -71,24 +124,47 @@ type RunOptions struct {
 	DNS         []string
 	DNSSearch   []string
 
+	// Entries added to the container's /etc/hosts, from hostname to IP.
+	ExtraHosts map[string]string
+
+	// Networks, in addition to `NetworkMode`, that the container is connected
+	// to before it starts.
+	Networks []string
+
 	PidMode     string
 	Privileged  bool
 	VolumesFrom []string
+
+	// Allocate a TTY, and keep stdin open, so that the container can be
+	// attached to.
+	TTY bool
 }
 
 type client interface {
 	StartContainer(id string, hostConfig *dkc.HostConfig) error
 	UploadToContainer(id string, opts dkc.UploadToContainerOptions) error
+	KillContainer(opts dkc.KillContainerOptions) error
 	RemoveContainer(opts dkc.RemoveContainerOptions) error
 	BuildImage(opts dkc.BuildImageOptions) error
 	PullImage(opts dkc.PullImageOptions, auth dkc.AuthConfiguration) error
 	PushImage(opts dkc.PushImageOptions, auth dkc.AuthConfiguration) error
+	TagImage(name string, opts dkc.TagImageOptions) error
 	ListContainers(opts dkc.ListContainersOptions) ([]dkc.APIContainers, error)
 	InspectContainer(id string) (*dkc.Container, error)
 	InspectImage(id string) (*dkc.Image, error)
 	CreateContainer(dkc.CreateContainerOptions) (*dkc.Container, error)
 	CreateNetwork(dkc.CreateNetworkOptions) (*dkc.Network, error)
 	ListNetworks() ([]dkc.Network, error)
+	ConnectNetwork(id string, opts dkc.NetworkConnectionOptions) error
+	CreateExec(dkc.CreateExecOptions) (*dkc.Exec, error)
+	StartExec(id string, opts dkc.StartExecOptions) error
+	InspectExec(id string) (*dkc.ExecInspect, error)
+	AttachToContainerNonBlocking(dkc.AttachToContainerOptions) (dkc.CloseWaiter,
+		error)
+	ResizeContainerTTY(id string, height, width int) error
+	PruneContainers(dkc.PruneContainersOptions) (*dkc.PruneContainersResults,
+		error)
+	PruneImages(dkc.PruneImagesOptions) (*dkc.PruneImagesResults, error)
 }
 
 var c = counter.New("Docker")
@@ -126,13 +202,14 @@ func (dk Client) Run(opts RunOptions) (string, error) {
 		VolumesFrom: opts.VolumesFrom,
 		DNS:         opts.DNS,
 		DNSSearch:   opts.DNSSearch,
+		ExtraHosts:  extraHosts(opts.ExtraHosts),
 	}
 
 	var nc *dkc.NetworkingConfig
 	if opts.IP != "" {
 		nc = &dkc.NetworkingConfig{
 			EndpointsConfig: map[string]*dkc.EndpointConfig{
-				"quilt": {
+				quiltNetwork: {
 					IPAMConfig: &dkc.EndpointIPAMConfig{
 						IPv4Address: opts.IP,
 					},
@@ -141,12 +218,27 @@ func (dk Client) Run(opts RunOptions) (string, error) {
 		}
 	}
 
+	// The networks are checked before the container is created, so that
+	// machines that lack them don't repeatedly create and remove it.
+	if err := dk.checkNetworks(opts.Networks); err != nil {
+		return "", err
+	}
+
 	id, err := dk.create(opts.Name, opts.Image, opts.Args, opts.Labels, env,
-		opts.FilepathToContent, hc, nc)
+		opts.TTY, opts.FilepathToContent, hc, nc)
 	if err != nil {
 		return "", err
 	}
 
+	for _, network := range opts.Networks {
+		err = dk.ConnectNetwork(network, dkc.NetworkConnectionOptions{
+			Container: id})
+		if err != nil {
+			dk.RemoveID(id)
+			return "", fmt.Errorf("connect to network %s: %s", network, err)
+		}
+	}
+
 	if err = dk.StartContainer(id, hc); err != nil {
 		dk.RemoveID(id) // Remove the container to avoid a zombie.
 		return "", err
@@ -155,6 +247,31 @@ func (dk Client) Run(opts RunOptions) (string, error) {
 	return id, nil
 }
 
+// checkNetworks returns a MissingNetworkError if any of `names` isn't the name
+// of a Docker network on this machine.
+func (dk Client) checkNetworks(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	networks, err := dk.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("list networks: %s", err)
+	}
+
+	exists := map[string]bool{}
+	for _, nw := range networks {
+		exists[nw.Name] = true
+	}
+
+	for _, name := range names {
+		if !exists[name] {
+			return MissingNetworkError{name}
+		}
+	}
+	return nil
+}
+
 // ConfigureNetwork makes a request to docker to create a network running on driver.
 func (dk Client) ConfigureNetwork(driver string) error {
 	c.Inc("Configure Network")
@@ -203,9 +320,110 @@ func (dk Client) RemoveID(id string) error {
 	return nil
 }
 
+// Exec runs `cmd` in the running container with `id`.  It returns an error if the
+// command exits with a non-zero status, or doesn't finish within a timeout.
+func (dk Client) Exec(id string, cmd []string) error {
+	c.Inc("Exec")
+
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	exec, err := dk.CreateExec(dkc.CreateExecOptions{
+		Container:    id,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+		Context:      ctx,
+	})
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	err = dk.StartExec(exec.ID, dkc.StartExecOptions{
+		OutputStream: &out,
+		ErrorStream:  &out,
+		Context:      ctx,
+	})
+	if err != nil {
+		return err
+	}
+
+	inspect, err := dk.InspectExec(exec.ID)
+	if err != nil {
+		return err
+	}
+
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("exit status %d: %s", inspect.ExitCode,
+			strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// UploadFiles writes the files in `filepathToContent` into the container with
+// `id`, replacing any existing files at the same paths.
+func (dk Client) UploadFiles(id string, filepathToContent map[string]string) error {
+	c.Inc("Upload Files")
+	return dk.uploadFiles(id, filepathToContent)
+}
+
+// The signals that may be sent to containers, keyed by name.
+var signals = map[string]dkc.Signal{
+	"SIGHUP":  dkc.SIGHUP,
+	"SIGUSR1": dkc.SIGUSR1,
+	"SIGUSR2": dkc.SIGUSR2,
+}
+
+// Signal sends the signal named `signal`, e.g. SIGHUP, to the main process of
+// the container with `id`.
+func (dk Client) Signal(id, signal string) error {
+	sig, ok := signals[signal]
+	if !ok {
+		return fmt.Errorf("unsupported signal: %s", signal)
+	}
+
+	c.Inc("Signal")
+	return dk.KillContainer(dkc.KillContainerOptions{ID: id, Signal: sig})
+}
+
+// Attach connects `stdin` and `stdout` to the container with `id`.  The
+// container's stderr is also written to `stdout`.  Stdin is only read if the
+// container keeps it open.  The returned CloseWaiter waits for the container to
+// exit, and detaches from it when closed.
+func (dk Client) Attach(id string, stdin io.Reader, stdout io.Writer) (
+	dkc.CloseWaiter, error) {
+
+	c.Inc("Attach")
+	container, err := dk.InspectContainer(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return dk.AttachToContainerNonBlocking(dkc.AttachToContainerOptions{
+		Container:    id,
+		InputStream:  stdin,
+		OutputStream: stdout,
+		ErrorStream:  stdout,
+		RawTerminal:  container.Config.Tty,
+		Stream:       true,
+		Stdin:        container.Config.OpenStdin,
+		Stdout:       true,
+		Stderr:       true,
+	})
+}
+
+// ResizeTTY sets the size of the TTY of the container with `id`.
+func (dk Client) ResizeTTY(id string, height, width int) error {
+	c.Inc("Resize TTY")
+	return dk.ResizeContainerTTY(id, height, width)
+}
+
 // Build builds an image with the given name and Dockerfile, and returns the
-// ID of the resulting image.
-func (dk Client) Build(name, dockerfile string, useCache bool) (id string, err error) {
+// ID of the resulting image.  The layers of the `cacheFrom` images are reused
+// where possible, and the build cache isn't used at all if none are given.
+func (dk Client) Build(name, dockerfile string, cacheFrom []string) (
+	id string, err error) {
 	c.Inc("Build")
 	tarBuf, err := util.ToTar("Dockerfile", 0644, dockerfile)
 	if err != nil {
@@ -217,7 +435,8 @@ func (dk Client) Build(name, dockerfile string, useCache bool) (id string, err e
 		Name:         name,
 		InputStream:  tarBuf,
 		OutputStream: ioutil.Discard,
-		NoCache:      !useCache,
+		NoCache:      len(cacheFrom) == 0,
+		CacheFrom:    cacheFrom,
 	})
 	if err != nil {
 		return "", err
@@ -251,7 +470,32 @@ func (dk Client) Pull(image string) error {
 	}
 
 	log.WithField("image", image).Info("Begin image pull")
-	opts := dkc.PullImageOptions{Repository: repo,
+	pulled := false
+	if cached, ok := cachedImage(image); ok {
+		if err := dk.pullAs(cached, repo, tag); err != nil {
+			log.WithError(err).WithField("image", image).Warn(
+				"Failed to pull image through the registry cache")
+		} else {
+			pulled = true
+		}
+	}
+
+	if !pulled {
+		err := dk.pullAs(util.MirrorImage(Mirror, image), repo, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	entry.expiration = time.Now().Add(pullCacheTimeout)
+	log.WithField("image", image).Info("Finish image pull")
+	return nil
+}
+
+// pullAs pulls `image`, and tags it as `repo`:`tag` if its name differs.
+func (dk Client) pullAs(image, repo, tag string) error {
+	pullRepo, _ := dkc.ParseRepositoryTag(image)
+	opts := dkc.PullImageOptions{Repository: pullRepo,
 		Tag:               tag,
 		InactivityTimeout: networkTimeout,
 	}
@@ -259,11 +503,31 @@ func (dk Client) Pull(image string) error {
 		return fmt.Errorf("pull image error: %s", err)
 	}
 
-	entry.expiration = time.Now().Add(pullCacheTimeout)
-	log.WithField("image", image).Info("Finish image pull")
+	if pullRepo != repo {
+		err := dk.TagImage(pullRepo+":"+tag, dkc.TagImageOptions{
+			Repo: repo, Tag: tag, Force: true})
+		if err != nil {
+			return fmt.Errorf("tag image error: %s", err)
+		}
+	}
 	return nil
 }
 
+// cachedImage returns the name of `image` within the registry cache, and whether
+// it should be pulled through the cache.
+func cachedImage(image string) (string, bool) {
+	registryCache.Lock()
+	cache := registryCache.addr
+	registryCache.Unlock()
+
+	if Mirror != "" || cache == "" {
+		return "", false
+	}
+
+	cached := util.CachedImage(cache, image)
+	return cached, cached != image
+}
+
 func (dk Client) getCacheEntry(repo, tag string) *cacheEntry {
 	dk.Lock()
 	defer dk.Unlock()
@@ -288,6 +552,33 @@ func (dk Client) Push(registry, image string) error {
 	}, dkc.AuthConfiguration{})
 }
 
+// Prune removes stopped containers, and then the images that aren't used by any
+// container.  It returns the number of bytes reclaimed.
+func (dk Client) Prune() (int64, error) {
+	c.Inc("Prune")
+
+	// Images that were pulled recently may be pruned, so they must be pulled
+	// again before they're used.
+	dk.Lock()
+	for key := range dk.imageCache {
+		delete(dk.imageCache, key)
+	}
+	dk.Unlock()
+
+	containers, err := dk.PruneContainers(dkc.PruneContainersOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	images, err := dk.PruneImages(dkc.PruneImagesOptions{
+		Filters: map[string][]string{"dangling": {"false"}},
+	})
+	if err != nil {
+		return containers.SpaceReclaimed, err
+	}
+	return containers.SpaceReclaimed + images.SpaceReclaimed, nil
+}
+
 // List returns a slice of all running containers.  The List can be be filtered with the
 // supplied `filters` map.
 func (dk Client) List(filters map[string][]string) ([]Container, error) {
@@ -305,10 +596,14 @@ func (dk Client) list(filters map[string][]string, all bool) ([]Container, error
 	var containers []Container
 	for _, apic := range apics {
 		c, err := dk.Get(apic.ID)
-		if err != nil {
-			log.WithError(err).Warnf("Failed to inspect container: %s",
-				apic.ID)
+		if _, ok := err.(*dkc.NoSuchContainer); ok || err == ErrNoSuchContainer {
+			// The container was removed after it was listed.
 			continue
+		} else if err != nil {
+			// Callers rely on the list being complete, e.g. to decide which
+			// network endpoints are no longer in use, so a container that
+			// can't be inspected fails the whole list.
+			return nil, fmt.Errorf("inspect container %s: %s", apic.ID, err)
 		}
 
 		containers = append(containers, c)
@@ -348,26 +643,64 @@ func (dk Client) Get(id string) (Container, error) {
 		Labels:  dkc.Config.Labels,
 		Status:  dkc.State.Status,
 		Created: dkc.Created,
+		TTY:     dkc.Config.Tty,
 	}
 
-	networks := keys(dkc.NetworkSettings.Networks)
-	if len(networks) == 1 {
-		config := dkc.NetworkSettings.Networks[networks[0]]
+	if hc := dkc.HostConfig; hc != nil {
+		c.DNS = hc.DNS
+		c.DNSSearch = hc.DNSSearch
+		c.ExtraHosts = parseExtraHosts(hc.ExtraHosts)
+	}
+
+	c.Networks = keys(dkc.NetworkSettings.Networks)
+	config, ok := dkc.NetworkSettings.Networks[quiltNetwork]
+	if !ok && len(c.Networks) == 1 {
+		config, ok = dkc.NetworkSettings.Networks[c.Networks[0]], true
+	} else if !ok && len(c.Networks) > 1 {
+		log.Warnf("Multiple networks for container: %s", dkc.ID)
+	}
+
+	if ok {
 		c.IP = config.IPAddress
 		c.Mac = config.MacAddress
 		c.EID = config.EndpointID
-	} else if len(networks) > 1 {
-		log.Warnf("Multiple networks for container: %s", dkc.ID)
 	}
 
 	return c, nil
 }
 
+// extraHosts converts `hosts`, from hostname to IP, into Docker's "hostname:IP"
+// format.
+func extraHosts(hosts map[string]string) []string {
+	var entries []string
+	for host, ip := range hosts {
+		entries = append(entries, host+":"+ip)
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+func parseExtraHosts(entries []string) map[string]string {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	hosts := map[string]string{}
+	for _, entry := range entries {
+		// IPv6 addresses contain colons, so the hostname ends at the first.
+		if parts := strings.SplitN(entry, ":", 2); len(parts) == 2 {
+			hosts[parts[0]] = parts[1]
+		}
+	}
+	return hosts
+}
+
 func keys(networks map[string]dkc.ContainerNetwork) []string {
-	keySet := []string{}
+	var keySet []string
 	for key := range networks {
 		keySet = append(keySet, key)
 	}
+	sort.Strings(keySet)
 	return keySet
 }
 
@@ -384,8 +717,9 @@ func (dk Client) IsRunning(name string) (bool, error) {
 }
 
 func (dk Client) create(name, image string, args []string,
-	labels map[string]string, env []string, filepathToContent map[string]string,
-	hc *dkc.HostConfig, nc *dkc.NetworkingConfig) (string, error) {
+	labels map[string]string, env []string, tty bool,
+	filepathToContent map[string]string, hc *dkc.HostConfig,
+	nc *dkc.NetworkingConfig) (string, error) {
 
 	if err := dk.Pull(image); err != nil {
 		return "", err
@@ -394,10 +728,12 @@ func (dk Client) create(name, image string, args []string,
 	container, err := dk.CreateContainer(dkc.CreateContainerOptions{
 		Name: name,
 		Config: &dkc.Config{
-			Image:  string(image),
-			Cmd:    args,
-			Labels: labels,
-			Env:    env},
+			Image:     string(image),
+			Cmd:       args,
+			Labels:    labels,
+			Env:       env,
+			Tty:       tty,
+			OpenStdin: tty},
 		HostConfig:       hc,
 		NetworkingConfig: nc,
 	})
@@ -405,6 +741,13 @@ func (dk Client) create(name, image string, args []string,
 		return "", err
 	}
 
+	if err := dk.uploadFiles(container.ID, filepathToContent); err != nil {
+		return "", err
+	}
+	return container.ID, nil
+}
+
+func (dk Client) uploadFiles(id string, filepathToContent map[string]string) error {
 	for path, content := range filepathToContent {
 		dir := "."
 		if filepath.IsAbs(path) {
@@ -416,19 +759,18 @@ func (dk Client) create(name, image string, args []string,
 		relPath, _ := filepath.Rel(dir, path)
 		tarBuf, err := util.ToTar(relPath, 0644, content)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		err = dk.UploadToContainer(container.ID, dkc.UploadToContainerOptions{
+		err = dk.UploadToContainer(id, dkc.UploadToContainerOptions{
 			InputStream: tarBuf,
 			Path:        dir,
 		})
 		if err != nil {
-			return "", err
+			return err
 		}
 	}
-
-	return container.ID, nil
+	return nil
 }
 
 func (dk Client) getID(name string) (string, error) {
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 
@@ -69,6 +71,52 @@ func TestPull(t *testing.T) {
 	assert.Equal(t, exp, cacheKeys(dk.imageCache))
 }
 
+func TestPullMirror(t *testing.T) {
+	Mirror = "mirror:5000"
+	defer func() { Mirror = "" }()
+
+	md, dk := NewMock()
+	assert.NoError(t, dk.Pull("nginx:1.13"))
+	assert.NoError(t, dk.Pull("10.0.0.1:5000/app"))
+
+	// Images are pulled from the mirror, and tagged with their original name.
+	exp := map[string]struct{}{
+		"mirror:5000/library/nginx:1.13": {},
+		"nginx:1.13":                     {},
+		"10.0.0.1:5000/app:latest":       {},
+	}
+	assert.Equal(t, exp, md.Pulled)
+}
+
+func TestPullRegistryCache(t *testing.T) {
+	SetRegistryCache("10.0.0.2:5001")
+	defer SetRegistryCache("")
+
+	md, dk := NewMock()
+	assert.NoError(t, dk.Pull("nginx:1.13"))
+	assert.NoError(t, dk.Pull("quay.io/coreos/etcd:v3.2"))
+
+	// Docker Hub images are pulled through the cache, and images hosted
+	// elsewhere are pulled directly.
+	exp := map[string]struct{}{
+		"10.0.0.2:5001/library/nginx:1.13": {},
+		"nginx:1.13":                       {},
+		"quay.io/coreos/etcd:v3.2":         {},
+	}
+	assert.Equal(t, exp, md.Pulled)
+
+	// Clusters with a mirror pull from it instead.
+	Mirror = "mirror:5000"
+	defer func() { Mirror = "" }()
+
+	md, dk = NewMock()
+	assert.NoError(t, dk.Pull("nginx:1.13"))
+	assert.Equal(t, map[string]struct{}{
+		"mirror:5000/library/nginx:1.13": {},
+		"nginx:1.13":                     {},
+	}, md.Pulled)
+}
+
 func checkCache(prePull func()) (bool, error) {
 	testImage := "foo"
 	md, dk := NewMock()
@@ -109,12 +157,12 @@ func TestCreateGet(t *testing.T) {
 	md, dk := NewMock()
 
 	md.PullError = true
-	_, err := dk.create("name", "image", nil, nil, nil, nil, nil, nil)
+	_, err := dk.create("name", "image", nil, nil, nil, false, nil, nil, nil)
 	assert.NotNil(t, err)
 	md.PullError = false
 
 	md.CreateError = true
-	_, err = dk.create("name", "image", nil, nil, nil, nil, nil, nil)
+	_, err = dk.create("name", "image", nil, nil, nil, false, nil, nil, nil)
 	assert.NotNil(t, err)
 	md.CreateError = false
 
@@ -124,7 +172,8 @@ func TestCreateGet(t *testing.T) {
 	args := []string{"arg1"}
 	env := []string{"envA=B"}
 	labels := map[string]string{"label": "foo"}
-	id, err := dk.create("name", "image", args, labels, env, nil, nil, nil)
+	id, err := dk.create("name", "image", args, labels, env, true, nil, nil,
+		nil)
 	assert.Nil(t, err)
 
 	container, err := dk.Get(id)
@@ -137,6 +186,7 @@ func TestCreateGet(t *testing.T) {
 		Args:   args,
 		Env:    map[string]string{"envA": "B"},
 		Labels: labels,
+		TTY:    true,
 	}
 	assert.Equal(t, expContainer, container)
 }
@@ -189,7 +239,7 @@ func TestRun(t *testing.T) {
 
 	md.InspectContainerError = true
 	containers, err = dk.List(nil)
-	assert.Nil(t, err)
+	assert.EqualError(t, err, "inspect container "+id1+": inspect error")
 	assert.Zero(t, len(containers))
 	md.InspectContainerError = false
 
@@ -206,6 +256,56 @@ func TestRun(t *testing.T) {
 	assert.False(t, running)
 }
 
+func TestRunNetworks(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	_, err := dk.Run(RunOptions{Name: "name", Networks: []string{"vpc"}})
+	assert.Equal(t, MissingNetworkError{"vpc"}, err)
+	assert.Empty(t, md.Containers)
+
+	md.ListNetworksError = true
+	_, err = dk.Run(RunOptions{Name: "name", Networks: []string{"vpc"}})
+	assert.EqualError(t, err, "list networks: list networks error")
+	md.ListNetworksError = false
+
+	md.Networks["macvlan"] = &dkc.Network{Name: "vpc", Driver: "macvlan"}
+	md.Networks["quilt"] = &dkc.Network{Name: "quilt", Driver: "quilt"}
+	id, err := dk.Run(RunOptions{Name: "name", NetworkMode: "quilt",
+		Networks: []string{"vpc"}})
+	assert.NoError(t, err)
+
+	// The container reports its IP on the Quilt network.
+	md.Containers[id].NetworkSettings.Networks["quilt"] = dkc.ContainerNetwork{
+		IPAddress: "10.1.2.3"}
+
+	container, err := dk.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"quilt", "vpc"}, container.Networks)
+	assert.Equal(t, "10.1.2.3", container.IP)
+}
+
+func TestRunDNS(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	id, err := dk.Run(RunOptions{Name: "name",
+		DNS:       []string{"10.0.0.1", "192.168.1.53"},
+		DNSSearch: []string{"q", "corp.internal"},
+		ExtraHosts: map[string]string{"legacy": "192.168.1.10",
+			"ldap": "fd00::1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ldap:fd00::1", "legacy:192.168.1.10"},
+		md.Containers[id].HostConfig.ExtraHosts)
+
+	container, err := dk.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "192.168.1.53"}, container.DNS)
+	assert.Equal(t, []string{"q", "corp.internal"}, container.DNSSearch)
+	assert.Equal(t, map[string]string{"legacy": "192.168.1.10",
+		"ldap": "fd00::1"}, container.ExtraHosts)
+}
+
 func TestRunEnv(t *testing.T) {
 	t.Parallel()
 	_, dk := NewMock()
@@ -320,11 +420,115 @@ func TestRemove(t *testing.T) {
 	assert.Zero(t, len(containers))
 }
 
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	id1, err := dk.Run(RunOptions{Name: "name1", Image: "a"})
+	assert.NoError(t, err)
+
+	id2, err := dk.Run(RunOptions{Name: "name2", Image: "b"})
+	assert.NoError(t, err)
+	md.StopContainer(id2)
+
+	assert.NoError(t, dk.Pull("c"))
+	assert.NotEmpty(t, dk.imageCache)
+
+	_, err = dk.Prune()
+	assert.NoError(t, err)
+
+	containers, err := dk.list(nil, true)
+	assert.NoError(t, err)
+	assert.Len(t, containers, 1)
+	assert.Equal(t, id1, containers[0].ID)
+	assert.Equal(t, map[string]struct{}{"a:latest": {}}, md.Pulled)
+	assert.Empty(t, dk.imageCache)
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	id, err := dk.Run(RunOptions{Name: "name"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, dk.Exec(id, []string{"warm", "cache"}))
+	assert.Equal(t, []string{"warm cache"}, md.Executions[id])
+
+	md.ExecExitCode = 2
+	assert.EqualError(t, dk.Exec(id, []string{"false"}), "exit status 2: ")
+	md.ExecExitCode = 0
+
+	md.StartExecError = true
+	assert.Error(t, dk.Exec(id, []string{"true"}))
+	md.StartExecError = false
+
+	assert.Error(t, dk.Exec("unknown", []string{"true"}))
+}
+
+func TestUploadFiles(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	id, err := dk.Run(RunOptions{Name: "name"})
+	assert.NoError(t, err)
+
+	err = dk.UploadFiles(id, map[string]string{"/etc/app.conf": "conf"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[UploadToContainerOptions]struct{}{
+		{
+			ContainerID: id,
+			UploadPath:  "/",
+			TarPath:     "etc/app.conf",
+			Contents:    "conf",
+		}: {},
+	}, md.Uploads)
+
+	md.UploadError = true
+	assert.Error(t, dk.UploadFiles(id, map[string]string{"a": "b"}))
+}
+
+func TestSignal(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	id, err := dk.Run(RunOptions{Name: "name"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, dk.Signal(id, "SIGHUP"))
+	assert.NoError(t, dk.Signal(id, "SIGUSR1"))
+	assert.Equal(t, []dkc.Signal{dkc.SIGHUP, dkc.SIGUSR1}, md.Signals[id])
+
+	assert.EqualError(t, dk.Signal(id, "SIGKILL"), "unsupported signal: SIGKILL")
+	assert.Error(t, dk.Signal("unknown", "SIGHUP"))
+}
+
+func TestAttach(t *testing.T) {
+	t.Parallel()
+	md, dk := NewMock()
+
+	id, err := dk.Run(RunOptions{Name: "name", TTY: true})
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	attachment, err := dk.Attach(id, strings.NewReader("input"), &out)
+	assert.NoError(t, err)
+	assert.NoError(t, attachment.Wait())
+	assert.Equal(t, "input", out.String())
+
+	assert.NoError(t, dk.ResizeTTY(id, 24, 80))
+	assert.Equal(t, [2]int{24, 80}, md.TTYSizes[id])
+
+	_, err = dk.Attach("unknown", nil, nil)
+	assert.Error(t, err)
+	assert.Error(t, dk.ResizeTTY("unknown", 24, 80))
+}
+
 func TestBuild(t *testing.T) {
 	t.Parallel()
 	md, dk := NewMock()
 
-	_, err := dk.Build("foo", "bar", false)
+	_, err := dk.Build("foo", "bar", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, map[BuildImageOptions]struct{}{
 		{
@@ -334,12 +538,23 @@ func TestBuild(t *testing.T) {
 		}: {},
 	}, md.Built)
 
+	md.ResetBuilt()
+	_, err = dk.Build("foo", "bar", []string{"cache1", "cache2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[BuildImageOptions]struct{}{
+		{
+			Name:       "foo",
+			Dockerfile: "bar",
+			CacheFrom:  "cache1 cache2",
+		}: {},
+	}, md.Built)
+
 	md.InspectImageError = true
-	_, err = dk.Build("foo", "bar", false)
+	_, err = dk.Build("foo", "bar", nil)
 	assert.NotNil(t, err)
 
 	md.BuildError = true
-	_, err = dk.Build("foo", "bar", false)
+	_, err = dk.Build("foo", "bar", nil)
 	assert.NotNil(t, err)
 }
 
@@ -22,6 +22,7 @@ type mockContainer struct {
 type BuildImageOptions struct {
 	Name, Dockerfile string
 	NoCache          bool
+	CacheFrom        string // The CacheFrom images, joined by spaces.
 }
 
 // UploadToContainerOptions represents the parameters in a call to UploadToContainer.
@@ -44,6 +45,15 @@ type MockClient struct {
 	createdExecs map[string]dkc.CreateExecOptions
 	Executions   map[string][]string
 
+	// The exit code of every execution.
+	ExecExitCode int
+
+	// The most recent size, as {height, width}, of the TTY of each container.
+	TTYSizes map[string][2]int
+
+	// The signals sent to each container, in order.
+	Signals map[string][]dkc.Signal
+
 	CreateError           bool
 	CreateNetworkError    bool
 	ListNetworksError     bool
@@ -74,6 +84,8 @@ func NewMock() (*MockClient, Client) {
 		Images:       map[string]*dkc.Image{},
 		createdExecs: map[string]dkc.CreateExecOptions{},
 		Executions:   map[string][]string{},
+		TTYSizes:     map[string][2]int{},
+		Signals:      map[string][]dkc.Signal{},
 	}
 	return md, Client{md, &sync.Mutex{}, map[string]*cacheEntry{}}
 }
@@ -116,6 +128,48 @@ func (dk MockClient) RemoveContainer(opts dkc.RemoveContainerOptions) error {
 	return nil
 }
 
+// PruneContainers removes the containers that aren't running.
+func (dk MockClient) PruneContainers(opts dkc.PruneContainersOptions) (
+	*dkc.PruneContainersResults, error) {
+	dk.Lock()
+	defer dk.Unlock()
+
+	var results dkc.PruneContainersResults
+	for id, container := range dk.Containers {
+		if !container.Running {
+			delete(dk.Containers, id)
+			results.ContainersDeleted = append(results.ContainersDeleted, id)
+		}
+	}
+	return &results, nil
+}
+
+// PruneImages removes the pulled images that aren't used by any container.
+func (dk MockClient) PruneImages(opts dkc.PruneImagesOptions) (
+	*dkc.PruneImagesResults, error) {
+	dk.Lock()
+	defer dk.Unlock()
+
+	used := map[string]bool{}
+	for _, container := range dk.Containers {
+		image := container.Config.Image
+		if strings.Count(image, ":") == 0 {
+			image = image + ":latest"
+		}
+		used[image] = true
+	}
+
+	var results dkc.PruneImagesResults
+	for image := range dk.Pulled {
+		if !used[image] {
+			delete(dk.Pulled, image)
+			results.ImagesDeleted = append(results.ImagesDeleted,
+				struct{ Untagged, Deleted string }{Untagged: image})
+		}
+	}
+	return &results, nil
+}
+
 func readDockerfile(inp io.Reader) ([]byte, error) {
 	tarball := tar.NewReader(inp)
 	for {
@@ -143,6 +197,7 @@ func (dk MockClient) BuildImage(opts dkc.BuildImageOptions) error {
 		Name:       opts.Name,
 		Dockerfile: string(dockerfile),
 		NoCache:    opts.NoCache,
+		CacheFrom:  strings.Join(opts.CacheFrom, " "),
 	}] = struct{}{}
 	dk.Images[opts.Name] = &dkc.Image{ID: uuid.NewV4().String()}
 	return err
@@ -186,6 +241,19 @@ func (dk MockClient) PullImage(opts dkc.PullImageOptions,
 	return nil
 }
 
+// TagImage tags the image `name`.  The tag is treated like a pulled image.
+func (dk MockClient) TagImage(name string, opts dkc.TagImageOptions) error {
+	dk.Lock()
+	defer dk.Unlock()
+
+	if _, ok := dk.Pulled[name]; !ok {
+		return fmt.Errorf("no image with name %s", name)
+	}
+
+	dk.Pulled[opts.Repo+":"+opts.Tag] = struct{}{}
+	return nil
+}
+
 // PushImage pushes the requested image.
 func (dk MockClient) PushImage(opts dkc.PushImageOptions, _ dkc.AuthConfiguration) error {
 	dk.Lock()
@@ -227,11 +295,27 @@ func (dk MockClient) ListContainers(opts dkc.ListContainersOptions) ([]dkc.APICo
 			continue
 		}
 
+		if !hasLabels(container.Config, opts.Filters["label"]) {
+			continue
+		}
+
 		apics = append(apics, dkc.APIContainers{ID: id})
 	}
 	return apics, nil
 }
 
+// hasLabels returns whether the container has each of the labels, which are of
+// the form KEY=VALUE.
+func hasLabels(config *dkc.Config, labels []string) bool {
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || config == nil || config.Labels[kv[0]] != kv[1] {
+			return false
+		}
+	}
+	return true
+}
+
 // CreateNetwork creates a network according to opts.
 func (dk MockClient) CreateNetwork(opts dkc.CreateNetworkOptions) (*dkc.Network, error) {
 	dk.Lock()
@@ -266,6 +350,30 @@ func (dk MockClient) ListNetworks() ([]dkc.Network, error) {
 	return networks, nil
 }
 
+// ConnectNetwork connects a container to the network with the given name.
+func (dk MockClient) ConnectNetwork(id string,
+	opts dkc.NetworkConnectionOptions) error {
+	dk.Lock()
+	defer dk.Unlock()
+
+	var found bool
+	for _, nw := range dk.Networks {
+		found = found || nw.Name == id
+	}
+
+	container, ok := dk.Containers[opts.Container]
+	if !found || !ok {
+		return &dkc.NoSuchNetworkOrContainer{NetworkID: id,
+			ContainerID: opts.Container}
+	}
+
+	if container.NetworkSettings.Networks == nil {
+		container.NetworkSettings.Networks = map[string]dkc.ContainerNetwork{}
+	}
+	container.NetworkSettings.Networks[id] = dkc.ContainerNetwork{}
+	return nil
+}
+
 // InspectContainer returns details of the specified container.
 func (dk MockClient) InspectContainer(id string) (*dkc.Container, error) {
 	dk.Lock()
@@ -352,6 +460,70 @@ func (dk MockClient) StartExec(id string, opts dkc.StartExecOptions) error {
 	return nil
 }
 
+// InspectExec returns the status of the execution with `id`.
+func (dk MockClient) InspectExec(id string) (*dkc.ExecInspect, error) {
+	dk.Lock()
+	defer dk.Unlock()
+
+	exec, ok := dk.createdExecs[id]
+	if !ok {
+		return nil, errors.New("unknown exec")
+	}
+	return &dkc.ExecInspect{ID: id, ContainerID: exec.Container,
+		ExitCode: dk.ExecExitCode}, nil
+}
+
+// AttachToContainerNonBlocking attaches to a container that behaves like `cat`:
+// it echoes its input to its output until its input is closed.
+func (dk MockClient) AttachToContainerNonBlocking(
+	opts dkc.AttachToContainerOptions) (dkc.CloseWaiter, error) {
+	dk.Lock()
+	defer dk.Unlock()
+
+	if _, ok := dk.Containers[opts.Container]; !ok {
+		return nil, ErrNoSuchContainer
+	}
+
+	attachment := &mockAttachment{done: make(chan struct{}),
+		closed: make(chan struct{})}
+	go func() {
+		io.Copy(opts.OutputStream, opts.InputStream)
+		close(attachment.done)
+	}()
+	return attachment, nil
+}
+
+type mockAttachment struct {
+	done, closed chan struct{}
+	closeOnce    sync.Once
+}
+
+func (ma *mockAttachment) Wait() error {
+	select {
+	case <-ma.done:
+		return nil
+	case <-ma.closed:
+		return errors.New("detached")
+	}
+}
+
+func (ma *mockAttachment) Close() error {
+	ma.closeOnce.Do(func() { close(ma.closed) })
+	return nil
+}
+
+// ResizeContainerTTY records the size of the container's TTY.
+func (dk MockClient) ResizeContainerTTY(id string, height, width int) error {
+	dk.Lock()
+	defer dk.Unlock()
+
+	if _, ok := dk.Containers[id]; !ok {
+		return ErrNoSuchContainer
+	}
+	dk.TTYSizes[id] = [2]int{height, width}
+	return nil
+}
+
 // ResetExec clears the list of created and started executions, for use by the unit
 // tests.
 func (dk *MockClient) ResetExec() {
@@ -362,6 +534,19 @@ func (dk *MockClient) ResetExec() {
 	dk.Executions = map[string][]string{}
 }
 
+// KillContainer records the signal sent to the given container.
+func (dk MockClient) KillContainer(opts dkc.KillContainerOptions) error {
+	dk.Lock()
+	defer dk.Unlock()
+
+	if _, ok := dk.Containers[opts.ID]; !ok {
+		return ErrNoSuchContainer
+	}
+
+	dk.Signals[opts.ID] = append(dk.Signals[opts.ID], opts.Signal)
+	return nil
+}
+
 // UploadToContainer extracts a tarball into the given container.
 func (dk MockClient) UploadToContainer(id string,
 	opts dkc.UploadToContainerOptions) error {
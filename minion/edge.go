@@ -0,0 +1,117 @@
+package minion
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/scheduler"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The edge proxies are in the Quilt image, which each worker runs the build of for
+// its own architecture.
+var edgeProxyImage = scheduler.QuiltImage
+
+// addEdgeProxies returns `bp` with the public connections to load balancers that
+// have edge machines replaced by connections to proxies, one of which is placed on
+// each of the workers with the load balancer's edge label.  The proxies forward
+// the connections to the load balancer, so the public ports are only mapped on
+// the edge machines.
+func addEdgeProxies(bp blueprint.Blueprint, minions []db.Minion) blueprint.Blueprint {
+	edges := map[string]bool{}
+	for _, lb := range bp.LoadBalancers {
+		if lb.Edge != "" {
+			edges[lb.Name] = true
+		}
+	}
+
+	publicPorts := map[string][]int{}
+	connections := []blueprint.Connection{}
+	for _, conn := range bp.Connections {
+		if conn.From == blueprint.PublicInternetLabel && edges[conn.To] {
+			publicPorts[conn.To] = append(publicPorts[conn.To], conn.MinPort)
+			continue
+		}
+		connections = append(connections, conn)
+	}
+
+	hostnames := map[string]bool{}
+	for _, c := range bp.Containers {
+		hostnames[c.Hostname] = true
+	}
+
+	containers := append([]blueprint.Container{}, bp.Containers...)
+	placements := append([]blueprint.Placement{}, bp.Placements...)
+	for _, lb := range bp.LoadBalancers {
+		ports := publicPorts[lb.Name]
+		if len(ports) == 0 {
+			continue
+		}
+		sort.Ints(ports)
+
+		command := []string{"quilt", "edge-proxy"}
+		for _, port := range ports {
+			command = append(command, "-port", strconv.Itoa(port))
+		}
+		command = append(command, lb.Name+".q")
+
+		for i := 1; i <= countEdges(minions, lb.Edge); i++ {
+			hostname := fmt.Sprintf("%s-edge-%d", lb.Name, i)
+			if hostnames[hostname] {
+				log.WithField("hostname", hostname).Warn("Edge proxy " +
+					"hostname is used by the blueprint. Skipping.")
+				continue
+			}
+
+			id := fmt.Sprintf("%x", sha1.Sum([]byte("edge-proxy "+hostname)))
+			containers = append(containers, blueprint.Container{
+				ID:       id,
+				Image:    blueprint.Image{Name: edgeProxyImage},
+				Command:  command,
+				Hostname: hostname,
+				Label:    lb.Name + "-edge",
+			})
+			placements = append(placements, blueprint.Placement{
+				TargetContainerID: id,
+				Label:             lb.Edge,
+			})
+
+			// The proxies share public ports, so the port placements keep
+			// them on separate machines.
+			for _, port := range ports {
+				connections = append(connections, blueprint.Connection{
+					From:    blueprint.PublicInternetLabel,
+					To:      hostname,
+					MinPort: port,
+					MaxPort: port,
+				}, blueprint.Connection{
+					From:    hostname,
+					To:      lb.Name,
+					MinPort: port,
+					MaxPort: port,
+				})
+			}
+		}
+	}
+
+	bp.Containers = containers
+	bp.Connections = connections
+	bp.Placements = placements
+	return bp
+}
+
+// countEdges returns the number of workers with the edge label `label`.
+func countEdges(minions []db.Minion, label string) int {
+	count := 0
+	for _, m := range minions {
+		if m.Role == db.Worker && scheduler.HasLabel(m.Labels, label) {
+			count++
+		}
+	}
+	return count
+}
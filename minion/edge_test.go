@@ -0,0 +1,113 @@
+package minion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+func TestAddEdgeProxies(t *testing.T) {
+	t.Parallel()
+
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{{ID: "1", Hostname: "web"}},
+		LoadBalancers: []blueprint.LoadBalancer{
+			{Name: "lb", Hostnames: []string{"web"}, Edge: "edge=true"},
+			{Name: "internal", Hostnames: []string{"web"}},
+		},
+		Connections: []blueprint.Connection{
+			{From: "public", To: "lb", MinPort: 443, MaxPort: 443},
+			{From: "public", To: "lb", MinPort: 80, MaxPort: 80},
+			{From: "web", To: "internal", MinPort: 80, MaxPort: 80},
+		},
+	}
+
+	minions := []db.Minion{
+		{Role: db.Master, Labels: map[string]string{"edge": "true"}},
+		{Role: db.Worker, Labels: map[string]string{"edge": "true"}},
+		{Role: db.Worker, Labels: map[string]string{"edge": "true"}},
+		{Role: db.Worker, Labels: map[string]string{"edge": "false"}},
+		{Role: db.Worker},
+	}
+
+	res := addEdgeProxies(bp, minions)
+	assert.Len(t, res.Containers, 3)
+	assert.Equal(t, bp.Containers[0], res.Containers[0])
+
+	proxy := res.Containers[1]
+	assert.Equal(t, "lb-edge-1", proxy.Hostname)
+	assert.Equal(t, "lb-edge-2", res.Containers[2].Hostname)
+	assert.Equal(t, "lb-edge", proxy.Label)
+	assert.Equal(t, edgeProxyImage, proxy.Image.Name)
+	assert.Equal(t, []string{"quilt", "edge-proxy", "-port", "80",
+		"-port", "443", "lb.q"}, proxy.Command)
+	assert.NotEqual(t, proxy.ID, res.Containers[2].ID)
+
+	assert.Equal(t, []blueprint.Placement{
+		{TargetContainerID: proxy.ID, Label: "edge=true"},
+		{TargetContainerID: res.Containers[2].ID, Label: "edge=true"},
+	}, res.Placements)
+
+	// The public connections go to the proxies rather than the load balancer.
+	assert.Contains(t, res.Connections, bp.Connections[2])
+	assert.NotContains(t, res.Connections, bp.Connections[0])
+	assert.Contains(t, res.Connections, blueprint.Connection{
+		From: "public", To: "lb-edge-1", MinPort: 80, MaxPort: 80})
+	assert.Contains(t, res.Connections, blueprint.Connection{
+		From: "lb-edge-2", To: "lb", MinPort: 443, MaxPort: 443})
+	assert.Len(t, res.Connections, 9)
+
+	// The proxies keep their IDs, so they aren't restarted by updates.
+	assert.Equal(t, res, addEdgeProxies(bp, minions))
+
+	// Without edge machines, nothing accepts the public connections.
+	res = addEdgeProxies(bp, nil)
+	assert.Equal(t, bp.Containers, res.Containers)
+	assert.Equal(t, []blueprint.Connection{bp.Connections[2]}, res.Connections)
+}
+
+func TestEdgeProxyTxn(t *testing.T) {
+	t.Parallel()
+	conn := db.New()
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Role = db.Worker
+		m.Labels = map[string]string{"edge": "true"}
+		view.Commit(m)
+
+		updatePolicy(view, blueprint.Blueprint{
+			Containers: []blueprint.Container{{ID: "1", Hostname: "web"}},
+			LoadBalancers: []blueprint.LoadBalancer{
+				{Name: "lb", Hostnames: []string{"web"}, Edge: "edge=true"},
+			},
+			Connections: []blueprint.Connection{
+				{From: "public", To: "lb", MinPort: 80, MaxPort: 80},
+			},
+		}.String())
+		return nil
+	})
+
+	proxies := conn.SelectFromContainer(func(dbc db.Container) bool {
+		return dbc.Hostname == "lb-edge-1"
+	})
+	assert.Len(t, proxies, 1)
+
+	placements := conn.SelectFromPlacement(func(p db.Placement) bool {
+		return p.TargetContainer == proxies[0].BlueprintID
+	})
+	assert.Len(t, placements, 1)
+	assert.Equal(t, "edge=true", placements[0].Label)
+
+	// The proxy may reach the containers behind the load balancer.
+	conns := conn.SelectFromConnection(func(c db.Connection) bool {
+		return c.From == "lb-edge-1"
+	})
+	assert.Len(t, conns, 2)
+	assert.Empty(t, conn.SelectFromConnection(func(c db.Connection) bool {
+		return c.From == "public" && c.To != "lb-edge-1"
+	}))
+}
@@ -0,0 +1,95 @@
+// Package edgeproxy implements the proxies that carry public traffic to load
+// balancers.  Load balancers only exist within Quilt's overlay network, so the
+// minion runs a proxy on each of a load balancer's edge machines, and maps the
+// load balancer's public ports to it.  The proxy accepts the connections, and
+// opens a new connection to the same port of the load balancer for each.
+package edgeproxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kelda/kelda/counter"
+)
+
+const dialTimeout = 10 * time.Second
+
+var c = counter.New("Edge Proxy")
+
+// Run accepts connections on each of `ports`, and forwards them to the same port
+// of `host`, until it fails.
+func Run(host string, ports []int) error {
+	if len(ports) == 0 {
+		return fmt.Errorf("no ports to proxy to %s", host)
+	}
+
+	errChan := make(chan error, len(ports))
+	for _, port := range ports {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+
+		target := net.JoinHostPort(host, strconv.Itoa(port))
+		go func() {
+			errChan <- serve(listener, target)
+		}()
+	}
+	return <-errChan
+}
+
+func serve(listener net.Listener, target string) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go forward(conn, target)
+	}
+}
+
+// forward opens a connection to `target`, and copies data between it and `conn`.
+func forward(conn net.Conn, target string) {
+	c.Inc("Connection")
+	defer conn.Close()
+
+	peer, err := dial(target)
+	if err != nil {
+		c.Inc("Dial Failed")
+		log.WithError(err).WithField("target", target).Warn(
+			"Failed to connect to load balancer")
+		return
+	}
+	defer peer.Close()
+
+	var wg sync.WaitGroup
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+
+		// Propagate the half-close, so that the other direction may finish.
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	wg.Add(2)
+	go pipe(conn, peer)
+	go pipe(peer, conn)
+	wg.Wait()
+}
+
+func dialImpl(target string) (net.Conn, error) {
+	return net.DialTimeout("tcp", target, dialTimeout)
+}
+
+var dial = dialImpl
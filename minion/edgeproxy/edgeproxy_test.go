@@ -0,0 +1,68 @@
+package edgeproxy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy(t *testing.T) {
+	// The load balancer echoes whatever it's sent.
+	lb, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer lb.Close()
+	go func() {
+		conn, err := lb.Accept()
+		if err != nil {
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		conn.Write(data)
+		conn.Close()
+	}()
+
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer proxy.Close()
+	go serve(proxy, lb.Addr().String())
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	assert.NoError(t, err)
+	conn.(*net.TCPConn).CloseWrite()
+
+	resp, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(resp))
+}
+
+func TestDialFailure(t *testing.T) {
+	dial = func(target string) (net.Conn, error) {
+		return nil, errors.New("unreachable")
+	}
+	defer func() { dial = dialImpl }()
+
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer proxy.Close()
+	go serve(proxy, "10.0.0.1:80")
+
+	// Connections that can't be forwarded are closed.
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	resp, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Empty(t, resp)
+}
+
+func TestNoPorts(t *testing.T) {
+	assert.EqualError(t, Run("web.q", nil), "no ports to proxy to web.q")
+}
@@ -1,13 +1,18 @@
 package minion
 
 import (
+	"time"
+
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/minion/scheduler"
 
 	log "github.com/sirupsen/logrus"
 )
 
+var now = time.Now
+
 func updatePolicy(view db.Database, bp string) {
 	compiled, err := blueprint.FromJSON(bp)
 	if err != nil {
@@ -16,72 +21,19 @@ func updatePolicy(view db.Database, bp string) {
 	}
 
 	c.Inc("Update Policy")
+	compiled = addEdgeProxies(compiled, view.SelectFromMinion(nil))
 	updateImages(view, compiled)
-	updateContainers(view, compiled)
 	updateLoadBalancers(view, compiled)
+	updateContainers(view, compiled)
 	updateConnections(view, compiled)
 	updatePlacements(view, compiled)
 }
 
-// `portPlacements` creates exclusive placement rules such that no two containers
-// listening on the same public port get placed on the same machine.
-func portPlacements(connections []db.Connection, containers []db.Container) (
-	placements []db.Placement) {
-
-	hostnameToContainer := map[string]db.Container{}
-	for _, c := range containers {
-		hostnameToContainer[c.Hostname] = c
-	}
-
-	ports := make(map[int][]string)
-	for _, conn := range connections {
-		if conn.From != blueprint.PublicInternetLabel {
-			continue
-		}
-
-		toContainer, ok := hostnameToContainer[conn.To]
-		if !ok {
-			log.WithField("connection", conn).
-				WithField("hostname", conn.To).
-				Warn("Public connection in terms of unknown hostname." +
-					"Ignoring.")
-			continue
-		}
-
-		// XXX: Public connections do not currently support ranges, so we can
-		// safely consider just the MinPort.
-		ports[conn.MinPort] = append(ports[conn.MinPort], toContainer.BlueprintID)
-	}
-
-	// Create placement rules for all combinations of containers that listen on
-	// the same port. We do not need to create a rule for every permutation
-	// because order does not matter for the `TargetContainer` and
-	// `OtherContainer` fields -- the placement is equivalent if the two fields
-	// are swapped.  We do so by creating a placement rule between each
-	// container, and the containers after it. There is no need to create rules
-	// for the preceding containers because the previous rules will have
-	// covered it.
-	for _, cids := range ports {
-		for i, tgt := range cids {
-			for _, other := range cids[i+1:] {
-				placements = append(placements,
-					db.Placement{
-						Exclusive:       true,
-						TargetContainer: tgt,
-						OtherContainer:  other,
-					},
-				)
-			}
-		}
-	}
-
-	return placements
-}
-
 func updatePlacements(view db.Database, bp blueprint.Blueprint) {
 	connections := view.SelectFromConnection(nil)
 	containers := view.SelectFromContainer(nil)
-	placements := db.PlacementSlice(portPlacements(connections, containers))
+	placements := db.PlacementSlice(scheduler.PortPlacements(connections,
+		containers))
 	for _, sp := range bp.Placements {
 		placements = append(placements, db.Placement{
 			TargetContainer: sp.TargetContainerID,
@@ -90,6 +42,7 @@ func updatePlacements(view db.Database, bp blueprint.Blueprint) {
 			Size:            sp.Size,
 			Region:          sp.Region,
 			FloatingIP:      sp.FloatingIP,
+			Label:           sp.Label,
 		})
 	}
 
@@ -119,8 +72,11 @@ func updateLoadBalancers(view db.Database, bp blueprint.Blueprint) {
 	var bpLoadBalancers db.LoadBalancerSlice
 	for _, lb := range bp.LoadBalancers {
 		bpLoadBalancers = append(bpLoadBalancers, db.LoadBalancer{
-			Name:      lb.Name,
-			Hostnames: lb.Hostnames,
+			Name:            lb.Name,
+			Hostnames:       lb.Hostnames,
+			CanaryHostnames: lb.CanaryHostnames,
+			CanaryFraction:  lb.CanaryFraction,
+			DrainPeriod:     lb.DrainPeriod,
 		})
 	}
 
@@ -146,12 +102,52 @@ func updateLoadBalancers(view db.Database, bp blueprint.Blueprint) {
 
 		// Modify the original database load balancer so that we preserve
 		// whatever IP the load balancer might have already been allocated.
+		dbLoadBalancer.Draining = drainingHostnames(dbLoadBalancer,
+			bpLoadBalancer)
 		dbLoadBalancer.Name = bpLoadBalancer.Name
 		dbLoadBalancer.Hostnames = bpLoadBalancer.Hostnames
+		dbLoadBalancer.CanaryHostnames = bpLoadBalancer.CanaryHostnames
+		dbLoadBalancer.CanaryFraction = bpLoadBalancer.CanaryFraction
+		dbLoadBalancer.DrainPeriod = bpLoadBalancer.DrainPeriod
 		view.Commit(dbLoadBalancer)
 	}
 }
 
+// drainingHostnames computes the containers that `dbLB` drains once it's updated
+// to `bpLB`.  Containers that leave the load balancer start draining for its drain
+// period, and stop once their drain ends or they rejoin.
+func drainingHostnames(dbLB, bpLB db.LoadBalancer) map[string]time.Time {
+	members := map[string]struct{}{}
+	for _, hostname := range lbMembers(bpLB) {
+		members[hostname] = struct{}{}
+	}
+
+	draining := map[string]time.Time{}
+	for hostname, end := range dbLB.Draining {
+		if _, ok := members[hostname]; !ok && now().Before(end) {
+			draining[hostname] = end
+		}
+	}
+
+	if bpLB.DrainPeriod > 0 {
+		end := now().Add(time.Duration(bpLB.DrainPeriod) * time.Second)
+		for _, hostname := range lbMembers(dbLB) {
+			if _, ok := members[hostname]; !ok {
+				draining[hostname] = end
+			}
+		}
+	}
+
+	if len(draining) == 0 {
+		return nil
+	}
+	return draining
+}
+
+func lbMembers(lb db.LoadBalancer) []string {
+	return append(append([]string{}, lb.Hostnames...), lb.CanaryHostnames...)
+}
+
 func updateConnections(view db.Database, bp blueprint.Blueprint) {
 	scs := blueprint.ConnectionSlice(bp.Connections)
 
@@ -165,18 +161,32 @@ func updateConnections(view db.Database, bp blueprint.Blueprint) {
 		loadBalancers[lb.Name] = lb
 	}
 
+	// Draining containers keep their connections so that the ACLs don't cut
+	// off their established flows.  OpenFlow refuses new ones.
+	draining := map[string][]string{}
+	for _, lb := range view.SelectFromLoadBalancer(nil) {
+		for hostname := range lb.Draining {
+			draining[lb.Name] = append(draining[lb.Name], hostname)
+		}
+	}
+
 	for _, c := range scs {
 		lb, ok := loadBalancers[c.To]
 		if !ok {
 			continue
 		}
 
-		for _, hostname := range lb.Hostnames {
+		var members []string
+		members = append(members, lb.Hostnames...)
+		members = append(members, lb.CanaryHostnames...)
+		members = append(members, draining[lb.Name]...)
+		for _, hostname := range members {
 			scs = append(scs, blueprint.Connection{
 				From:    c.From,
 				To:      hostname,
 				MinPort: c.MinPort,
 				MaxPort: c.MaxPort,
+				MTLS:    c.MTLS,
 			})
 		}
 	}
@@ -188,6 +198,7 @@ func updateConnections(view db.Database, bp blueprint.Blueprint) {
 			To:      c.To,
 			MinPort: c.MinPort,
 			MaxPort: c.MaxPort,
+			MTLS:    c.MTLS,
 		}
 	}
 
@@ -212,6 +223,7 @@ func updateConnections(view db.Database, bp blueprint.Blueprint) {
 		dbc.To = blueprintc.To
 		dbc.MinPort = blueprintc.MinPort
 		dbc.MaxPort = blueprintc.MaxPort
+		dbc.MTLS = blueprintc.MTLS
 		view.Commit(dbc)
 	}
 }
@@ -219,6 +231,12 @@ func updateConnections(view db.Database, bp blueprint.Blueprint) {
 func queryContainers(bp blueprint.Blueprint) []db.Container {
 	containers := map[string]*db.Container{}
 	for _, c := range bp.Containers {
+		priority, err := db.ParsePriorityClass(c.PriorityClass)
+		if err != nil {
+			log.WithError(err).WithField("container", c.Hostname).Warn(
+				"Invalid priority class. Using the default priority.")
+		}
+
 		containers[c.Hostname] = &db.Container{
 			BlueprintID:       c.ID,
 			Command:           c.Command,
@@ -227,6 +245,19 @@ func queryContainers(bp blueprint.Blueprint) []db.Container {
 			Image:             c.Image.Name,
 			Dockerfile:        c.Image.Dockerfile,
 			Hostname:          c.Hostname,
+			Label:             c.Label,
+			Priority:          priority,
+			PostStart:         c.PostStart,
+			PreStop:           c.PreStop,
+			ReloadSignal:      c.ReloadSignal,
+			Networks:          c.Networks,
+			DNS:               c.DNS,
+			DNSSearch:         c.DNSSearch,
+			ExtraHosts:        c.ExtraHosts,
+			ExposeMetadata:    c.ExposeMetadata,
+			CloudRole:         c.CloudRole,
+			TTY:               c.TTY,
+			Stopped:           c.Stopped,
 		}
 	}
 
@@ -243,11 +274,30 @@ func updateContainers(view db.Database, bp blueprint.Blueprint) {
 		return val.(db.Container).BlueprintID
 	}
 
-	pairs, news, dbcs := join.HashJoin(db.ContainerSlice(queryContainers(bp)),
+	bpcs := queryContainers(bp)
+	pairs, news, dbcs := join.HashJoin(db.ContainerSlice(bpcs),
 		db.ContainerSlice(view.SelectFromContainer(nil)), key, key)
 
-	for _, dbc := range dbcs {
-		view.Remove(dbc.(db.Container))
+	// Containers that are draining from a load balancer are kept until their
+	// drain ends, unless the blueprint reuses their hostname.
+	draining := map[string]struct{}{}
+	for _, lb := range view.SelectFromLoadBalancer(nil) {
+		for hostname := range lb.Draining {
+			draining[hostname] = struct{}{}
+		}
+	}
+	for _, c := range bpcs {
+		delete(draining, c.Hostname)
+	}
+
+	for _, dbcIntf := range dbcs {
+		dbc := dbcIntf.(db.Container)
+		if _, ok := draining[dbc.Hostname]; !ok {
+			view.Remove(dbc)
+		} else if !dbc.Draining {
+			dbc.Draining = true
+			view.Commit(dbc)
+		}
 	}
 
 	for _, new := range news {
@@ -265,6 +315,20 @@ func updateContainers(view db.Database, bp blueprint.Blueprint) {
 		dbc.FilepathToContent = newc.FilepathToContent
 		dbc.BlueprintID = newc.BlueprintID
 		dbc.Hostname = newc.Hostname
+		dbc.Label = newc.Label
+		dbc.Priority = newc.Priority
+		dbc.PostStart = newc.PostStart
+		dbc.PreStop = newc.PreStop
+		dbc.ReloadSignal = newc.ReloadSignal
+		dbc.Networks = newc.Networks
+		dbc.DNS = newc.DNS
+		dbc.DNSSearch = newc.DNSSearch
+		dbc.ExtraHosts = newc.ExtraHosts
+		dbc.ExposeMetadata = newc.ExposeMetadata
+		dbc.CloudRole = newc.CloudRole
+		dbc.TTY = newc.TTY
+		dbc.Stopped = newc.Stopped
+		dbc.Draining = false
 		view.Commit(dbc)
 	}
 }
@@ -283,6 +283,15 @@ func TestConnectionTxn(t *testing.T) {
 	testConnectionTxn(t, conn, bp)
 	assert.False(t, fired(trigg))
 
+	bp.Connections = []blueprint.Connection{
+		{From: "a", To: "a", MinPort: 90, MaxPort: 90, MTLS: true},
+	}
+	testConnectionTxn(t, conn, bp)
+	assert.True(t, fired(trigg))
+
+	testConnectionTxn(t, conn, bp)
+	assert.False(t, fired(trigg))
+
 	bp.Connections = []blueprint.Connection{
 		{From: "b", To: "a", MinPort: 90, MaxPort: 90},
 		{From: "b", To: "c", MinPort: 90, MaxPort: 90},
@@ -316,7 +325,7 @@ func testConnectionTxn(t *testing.T, conn db.Conn, bp blueprint.Blueprint) {
 		found := false
 		for i, c := range connections {
 			if e.From == c.From && e.To == c.To && e.MinPort == c.MinPort &&
-				e.MaxPort == c.MaxPort {
+				e.MaxPort == c.MaxPort && e.MTLS == c.MTLS {
 				connections = append(
 					connections[:i], connections[i+1:]...)
 				found = true
@@ -551,9 +560,11 @@ func checkLoadBalancer(t *testing.T, conn db.Conn, bp blueprint.Blueprint,
 	key := func(intf interface{}) interface{} {
 		lb := intf.(db.LoadBalancer)
 		return struct {
-			Name, IP, Hostnames string
+			Name, IP, Hostnames, CanaryHostnames string
+			CanaryFraction                       float64
 		}{
 			lb.Name, lb.IP, fmt.Sprintf("%+v", lb.Hostnames),
+			fmt.Sprintf("%+v", lb.CanaryHostnames), lb.CanaryFraction,
 		}
 	}
 	_, lonelyLeft, lonelyRight := join.HashJoin(
@@ -623,4 +634,113 @@ func TestLoadBalancerTxn(t *testing.T) {
 		Name:      loadBalancerB,
 		Hostnames: hostnamesB,
 	})
+
+	// Add a canary to the load balancer.
+	checkLoadBalancer(t, conn, blueprint.Blueprint{
+		LoadBalancers: []blueprint.LoadBalancer{
+			{
+				Name:            loadBalancerB,
+				Hostnames:       hostnamesB,
+				CanaryHostnames: []string{"canary"},
+				CanaryFraction:  0.05,
+			},
+		},
+	}, db.LoadBalancer{
+		Name:            loadBalancerB,
+		Hostnames:       hostnamesB,
+		CanaryHostnames: []string{"canary"},
+		CanaryFraction:  0.05,
+	})
+}
+
+func TestLoadBalancerDrain(t *testing.T) {
+	start := time.Now()
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{ID: "1", Hostname: "a"},
+			{ID: "2", Hostname: "b"},
+		},
+		LoadBalancers: []blueprint.LoadBalancer{
+			{Name: "web", Hostnames: []string{"a", "b"}, DrainPeriod: 30},
+		},
+		Connections: []blueprint.Connection{
+			{From: "client", To: "web", MinPort: 80, MaxPort: 80},
+		},
+	}
+
+	conn := db.New()
+	update := func() (lb db.LoadBalancer, dbcs []db.Container,
+		members []string) {
+		conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			updatePolicy(view, bp.String())
+			lb = view.SelectFromLoadBalancer(nil)[0]
+			dbcs = view.SelectFromContainer(nil)
+			for _, c := range view.SelectFromConnection(nil) {
+				if c.To != "web" {
+					members = append(members, c.To)
+				}
+			}
+			return nil
+		})
+		sort.Sort(db.ContainerSlice(dbcs))
+		sort.Strings(members)
+		return lb, dbcs, members
+	}
+
+	lb, dbcs, members := update()
+	assert.Nil(t, lb.Draining)
+	assert.Len(t, dbcs, 2)
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	// Removing "b" from the load balancer and the blueprint drains it.
+	bp.Containers = bp.Containers[:1]
+	bp.LoadBalancers[0].Hostnames = []string{"a"}
+	lb, dbcs, members = update()
+	assert.Equal(t, map[string]time.Time{"b": start.Add(30 * time.Second)},
+		lb.Draining)
+	assert.Len(t, dbcs, 2)
+	assert.False(t, dbcs[0].Draining)
+	assert.Equal(t, "b", dbcs[1].Hostname)
+	assert.True(t, dbcs[1].Draining)
+	assert.Equal(t, []string{"a", "b"}, members)
+
+	// The drain isn't extended by later updates.
+	now = func() time.Time { return start.Add(20 * time.Second) }
+	lb, dbcs, _ = update()
+	assert.Equal(t, map[string]time.Time{"b": start.Add(30 * time.Second)},
+		lb.Draining)
+	assert.Len(t, dbcs, 2)
+
+	// Once the drain ends, the container is removed.
+	now = func() time.Time { return start.Add(31 * time.Second) }
+	lb, dbcs, members = update()
+	assert.Nil(t, lb.Draining)
+	assert.Len(t, dbcs, 1)
+	assert.Equal(t, []string{"a"}, members)
+
+	// Without a drain period, removed containers are removed immediately.
+	bp.LoadBalancers[0].DrainPeriod = 0
+	bp.Containers = nil
+	bp.LoadBalancers[0].Hostnames = nil
+	lb, dbcs, _ = update()
+	assert.Nil(t, lb.Draining)
+	assert.Empty(t, dbcs)
+
+	// A container that rejoins the load balancer stops draining.
+	bp.LoadBalancers[0].DrainPeriod = 30
+	bp.Containers = []blueprint.Container{{ID: "3", Hostname: "c"}}
+	bp.LoadBalancers[0].Hostnames = []string{"c"}
+	update()
+	bp.LoadBalancers[0].Hostnames = nil
+	lb, dbcs, _ = update()
+	assert.Len(t, lb.Draining, 1)
+	assert.Len(t, dbcs, 1)
+	assert.False(t, dbcs[0].Draining)
+
+	bp.LoadBalancers[0].Hostnames = []string{"c"}
+	lb, _, _ = update()
+	assert.Nil(t, lb.Draining)
 }
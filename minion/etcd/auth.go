@@ -0,0 +1,254 @@
+package etcd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/coreos/etcd/client"
+	"github.com/kelda/kelda/db"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RootUser is the etcd user that masters connect as, which may read and write
+// every key.  Each worker connects as its own user (see WorkerUser).
+const RootUser = "root"
+
+// The prefix of the workers' users, which is followed by their private IPs.
+const workerUserPrefix = "worker-"
+
+// The role of users that don't authenticate.  Etcd grants it full access when
+// authentication is enabled, so its permissions are revoked.
+const guestRole = "guest"
+
+// WorkerUser returns the etcd user of the worker with the given private IP.
+// Each worker user has a role of the same name, which may only read the keys
+// that the worker needs, and only write its own minion key, so that a
+// compromised worker can neither read the containers of other workers nor, for
+// example, change the containers scheduled on them.
+func WorkerUser(privateIP string) string {
+	return workerUserPrefix + privateIP
+}
+
+// workerPermissions returns the prefixes that the worker with the given private
+// IP may read and write.
+func workerPermissions(privateIP string) (read, write []string) {
+	ownContainers := path.Join(containerDir, privateIP)
+	read = []string{leaderKey, hostnamePath, connectionPath, imagePath,
+		minionPath, minionPath + "/*", ownContainers, ownContainers + "/*"}
+	write = []string{minionPath, path.Join(minionPath, privateIP)}
+	return read, write
+}
+
+// waitForCredentials blocks until the daemon sends the minion its etcd
+// password, and returns the user and password with which it should connect.
+func waitForCredentials(conn db.Conn) (string, string, error) {
+	trigger := conn.TriggerTick(30, db.MinionTable)
+	defer trigger.Stop()
+
+	for range trigger.C {
+		minion := conn.MinionSelf()
+		user := WorkerUser(minion.PrivateIP)
+		if minion.Role == db.Master {
+			user = RootUser
+		}
+
+		if password := minion.EtcdPasswords[user]; password != "" {
+			return user, password, nil
+		}
+	}
+	return "", "", errors.New("stopped waiting for etcd credentials")
+}
+
+// runAuth enables authentication while this minion is the etcd leader, using
+// the passwords sent by the daemon.  Until then, etcd ignores the credentials of
+// the minions and allows them full access.  It's checked periodically, as etcd
+// doesn't persist its data, so the users are lost when the cluster is reset.
+func runAuth(conn db.Conn, store Store) {
+	for range conn.TriggerTick(60, db.EtcdTable, db.MinionTable).C {
+		if !conn.EtcdLeader() {
+			continue
+		}
+
+		passwords := conn.MinionSelf().EtcdPasswords
+		if err := store.EnableAuth(passwords); err != nil {
+			log.WithError(err).Warn("Failed to enable etcd authentication")
+		}
+	}
+}
+
+// EnableAuth creates the etcd users with the given passwords, and enables
+// authentication.  The users of workers that don't have a password anymore are
+// removed.  It's idempotent, and returns early if authentication is already set
+// up.
+func (s store) EnableAuth(passwords map[string]string) error {
+	users := client.NewAuthUserAPI(s.client)
+	roles := client.NewAuthRoleAPI(s.client)
+
+	// The guest role is created when authentication is enabled, and its
+	// permissions are revoked last, so once it has none, there's nothing to do
+	// unless the workers changed.
+	existing, err := users.ListUsers(ctx())
+	if err != nil {
+		return fmt.Errorf("list users: %s", err)
+	}
+	sort.Strings(existing)
+
+	var want []string
+	for user := range passwords {
+		want = append(want, user)
+	}
+	sort.Strings(want)
+
+	guest, err := roles.GetRole(ctx(), guestRole)
+	if err == nil && len(guest.Permissions.KV.Read) == 0 &&
+		len(guest.Permissions.KV.Write) == 0 &&
+		strings.Join(existing, ",") == strings.Join(want, ",") {
+		return nil
+	}
+
+	c.Inc("EnableAuth")
+
+	if err := setPassword(users, RootUser, passwords[RootUser]); err != nil {
+		return fmt.Errorf("set password of %s: %s", RootUser, err)
+	}
+
+	for _, user := range want {
+		if !strings.HasPrefix(user, workerUserPrefix) {
+			continue
+		}
+
+		if len(missing([]string{user}, existing)) == 0 {
+			continue
+		}
+
+		err := addWorker(users, roles, user, passwords[user])
+		if err != nil {
+			return fmt.Errorf("add worker %s: %s", user, err)
+		}
+	}
+
+	for _, user := range missing(existing, want) {
+		if user == RootUser {
+			continue
+		}
+
+		// The user's role is removed even if it never had one.
+		err := users.RemoveUser(ctx(), user)
+		if err != nil {
+			return fmt.Errorf("remove user %s: %s", user, err)
+		}
+		roles.RemoveRole(ctx(), user)
+	}
+
+	// Etcd doesn't report whether authentication is enabled, so the error
+	// of enabling it again is ignored.
+	err = client.NewAuthAPI(s.client).Enable(ctx())
+	if err != nil && !strings.Contains(err.Error(), "already enabled") {
+		return fmt.Errorf("enable: %s", err)
+	}
+
+	guest, err = roles.GetRole(ctx(), guestRole)
+	if err != nil {
+		return fmt.Errorf("get guest role: %s", err)
+	}
+
+	for _, perm := range []struct {
+		prefixes []string
+		typ      client.PermissionType
+	}{
+		{guest.Permissions.KV.Read, client.ReadPermission},
+		{guest.Permissions.KV.Write, client.WritePermission},
+	} {
+		if len(perm.prefixes) == 0 {
+			continue
+		}
+
+		_, err := roles.RevokeRoleKV(ctx(), guestRole, perm.prefixes, perm.typ)
+		if err != nil {
+			return fmt.Errorf("revoke guest permission: %s", err)
+		}
+	}
+	return nil
+}
+
+// addWorker creates the worker `user` with `password`, along with its role.
+func addWorker(users client.AuthUserAPI, roles client.AuthRoleAPI, user,
+	password string) error {
+
+	if err := setPassword(users, user, password); err != nil {
+		return fmt.Errorf("set password: %s", err)
+	}
+
+	role, err := roles.GetRole(ctx(), user)
+	if err != nil {
+		if err := roles.AddRole(ctx(), user); err != nil {
+			return fmt.Errorf("add role: %s", err)
+		}
+		role = &client.Role{}
+	}
+
+	read, write := workerPermissions(strings.TrimPrefix(user, workerUserPrefix))
+	for _, perm := range []struct {
+		prefixes []string
+		typ      client.PermissionType
+	}{
+		{missing(read, role.Permissions.KV.Read), client.ReadPermission},
+		{missing(write, role.Permissions.KV.Write), client.WritePermission},
+	} {
+		if len(perm.prefixes) == 0 {
+			continue
+		}
+
+		_, err := roles.GrantRoleKV(ctx(), user, perm.prefixes, perm.typ)
+		if err != nil {
+			return fmt.Errorf("grant permission: %s", err)
+		}
+	}
+
+	u, err := users.GetUser(ctx(), user)
+	if err != nil {
+		return fmt.Errorf("get user: %s", err)
+	}
+
+	if len(missing([]string{user}, u.Roles)) > 0 {
+		if _, err := users.GrantUser(ctx(), user, []string{user}); err != nil {
+			return fmt.Errorf("grant role: %s", err)
+		}
+	}
+	return nil
+}
+
+// setPassword creates `user` with `password`, or changes its password if it
+// already exists.
+func setPassword(users client.AuthUserAPI, user, password string) error {
+	if password == "" {
+		return fmt.Errorf("no password for %s", user)
+	}
+
+	if err := users.AddUser(ctx(), user, password); err == nil {
+		return nil
+	}
+
+	_, err := users.ChangePassword(ctx(), user, password)
+	return err
+}
+
+// missing returns the elements of `want` that aren't in `have`.
+func missing(want, have []string) []string {
+	haveSet := map[string]struct{}{}
+	for _, s := range have {
+		haveSet[s] = struct{}{}
+	}
+
+	var result []string
+	for _, s := range want {
+		if _, ok := haveSet[s]; !ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package etcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForCredentials(t *testing.T) {
+	t.Parallel()
+
+	passwords := map[string]string{
+		RootUser:          "root",
+		"worker-10.0.0.2": "worker",
+		"worker-10.0.0.3": "other",
+	}
+	for role, expUser := range map[db.Role]string{
+		db.Master: RootUser,
+		db.Worker: "worker-10.0.0.2",
+	} {
+		conn := db.New()
+		conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			m := view.InsertMinion()
+			m.Self = true
+			m.Role = role
+			m.PrivateIP = "10.0.0.2"
+			m.EtcdPasswords = passwords
+			view.Commit(m)
+			return nil
+		})
+
+		user, password, err := waitForCredentials(conn)
+		assert.NoError(t, err)
+		assert.Equal(t, expUser, user)
+		assert.Equal(t, passwords[expUser], password)
+	}
+}
+
+func TestRunAuth(t *testing.T) {
+	t.Parallel()
+
+	passwords := map[string]string{RootUser: "root", WorkerUser("1.2.3.4"): "worker"}
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Self = true
+		m.Role = db.Master
+		m.EtcdPasswords = passwords
+		view.Commit(m)
+
+		etcd := view.InsertEtcd()
+		etcd.Leader = true
+		view.Commit(etcd)
+		return nil
+	})
+
+	store := newTestMock()
+	go runAuth(conn, store)
+
+	timeout := time.After(time.Second)
+	for {
+		store.Lock()
+		auth := map[string]string{}
+		for user, password := range store.auth {
+			auth[user] = password
+		}
+		store.Unlock()
+
+		if len(auth) > 0 {
+			assert.Equal(t, passwords, auth)
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatal("Authentication wasn't enabled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestWorkerPermissions(t *testing.T) {
+	t.Parallel()
+
+	read, write := workerPermissions("10.0.0.2")
+	assert.Equal(t, []string{"/leader", "/hostnames", "/connections", "/images",
+		"/minions", "/minions/*", "/minion-containers/10.0.0.2",
+		"/minion-containers/10.0.0.2/*"}, read)
+	assert.Equal(t, []string{"/minions", "/minions/10.0.0.2"}, write)
+
+	// Workers can't read the root, or the containers of other workers.
+	for _, key := range read {
+		assert.NotEqual(t, "/*", key)
+		assert.NotContains(t, key, "10.0.0.3")
+	}
+}
+
+func TestMissing(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"b"}, missing([]string{"a", "b"}, []string{"a", "c"}))
+	assert.Empty(t, missing([]string{"a"}, []string{"a"}))
+}
@@ -33,6 +33,13 @@ func runConnectionOnce(conn db.Conn, store Store) error {
 	if conn.EtcdLeader() {
 		c.Inc("Run Connection Leader")
 		slice := db.ConnectionSlice(conn.SelectFromConnection(nil))
+
+		// The connections' status is only tracked by the leader, so it's
+		// left out to avoid rewriting the connections as it changes.
+		for i := range slice {
+			slice[i].Pending = nil
+		}
+
 		err = writeEtcdSlice(store, connectionPath, etcdStr, slice)
 		if err != nil {
 			return fmt.Errorf("etcd write error: %s", err)
@@ -52,15 +59,22 @@ func runConnectionOnce(conn db.Conn, store Store) error {
 
 func joinConnections(view db.Database, etcdConns []db.Connection) {
 	key := func(iface interface{}) interface{} {
-		conn := iface.(db.Connection)
-		conn.ID = 0
-		return conn
+		return iface.(db.Connection).Key()
 	}
 
-	_, connIfaces, etcdConnIfaces := join.HashJoin(
+	pairs, connIfaces, etcdConnIfaces := join.HashJoin(
 		db.ConnectionSlice(view.SelectFromConnection(nil)),
 		db.ConnectionSlice(etcdConns), key, key)
 
+	// Only the leader tracks the connections' status, so it's cleared in case
+	// this minion was the leader before.
+	for _, pair := range pairs {
+		if dbc := pair.L.(db.Connection); dbc.Pending != nil {
+			dbc.Pending = nil
+			view.Commit(dbc)
+		}
+	}
+
 	for _, conn := range connIfaces {
 		view.Remove(conn.(db.Connection))
 	}
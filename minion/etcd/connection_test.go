@@ -29,6 +29,7 @@ func TestRunConnectionOnce(t *testing.T) {
 		conn.To = "b"
 		conn.MinPort = 80
 		conn.MaxPort = 8080
+		conn.Pending = []string{"1.2.3.4"}
 		view.Commit(conn)
 		return nil
 	})
@@ -36,6 +37,7 @@ func TestRunConnectionOnce(t *testing.T) {
 	err = runConnectionOnce(conn, store)
 	assert.NoError(t, err)
 
+	// The leader's view of the connection's status isn't written to etcd.
 	str, err := store.Get(connectionPath)
 	assert.NoError(t, err)
 
@@ -71,4 +73,16 @@ func TestRunConnectionOnce(t *testing.T) {
 	conns[0].ID = 0
 	assert.Equal(t, db.Connection{From: "a", To: "b", MinPort: 80, MaxPort: 8080},
 		conns[0])
+
+	// Minions that are no longer the leader clear the connections' status.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		conn := view.SelectFromConnection(nil)[0]
+		conn.Pending = []string{"1.2.3.4"}
+		view.Commit(conn)
+		return nil
+	})
+
+	err = runConnectionOnce(conn, store)
+	assert.NoError(t, err)
+	assert.Empty(t, conn.SelectFromConnection(nil)[0].Pending)
 }
@@ -3,6 +3,7 @@ package etcd
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"time"
 
 	"github.com/kelda/kelda/db"
@@ -12,61 +13,255 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-const containerPath = "/containers"
+// The directory of the containers.  Each container has its own key, within the
+// directory of the minion that it's scheduled on, so that the leader only writes
+// the containers that changed, and workers only read and watch their own.
+const containerDir = "/minion-containers"
+
+// The key that held every container before they were split by minion.  Minions
+// that haven't been upgraded still read it, so the leader deletes it rather than
+// leave them running stale containers.
+const legacyContainerPath = "/containers"
 
 func runContainer(conn db.Conn, store Store) {
-	etcdWatch := store.Watch(containerPath, 1*time.Second)
+	var cs containerSync
+	etcdWatch := store.Watch(containerWatchDir(conn.MinionSelf()), 1*time.Second)
 	trigg := conn.TriggerTick(60, db.ContainerTable)
 	for range util.JoinNotifiers(trigg.C, etcdWatch) {
-		if err := runContainerOnce(conn, store); err != nil {
+		if err := cs.runOnce(conn, store); err != nil {
 			log.WithError(err).Warn("Failed to sync containers with Etcd.")
 		}
 	}
 }
 
-func runContainerOnce(conn db.Conn, store Store) error {
-	etcdStr, err := readEtcdNode(store, containerPath)
+// containerWatchDir returns the directory of the containers that `self` reads.
+// Masters may become the leader, so they read every container.
+func containerWatchDir(self db.Minion) string {
+	if self.Role == db.Master || self.PrivateIP == "" {
+		return containerDir
+	}
+	return path.Join(containerDir, self.PrivateIP)
+}
+
+// containerSync is the state of the container sync that's kept between runs.
+type containerSync struct {
+	leader bool
+
+	// The IPs that etcd recorded for containers, keyed by blueprint ID, when
+	// this minion became the leader.  The leader's database may have been
+	// lost when its minion restarted, so the IPs are restored to its
+	// containers before they're written to etcd, rather than replaced by the
+	// new IPs it allocates.
+	etcdIPs map[string]string
+
+	// Whether this minion has deleted the legacy container key.
+	legacyDeleted bool
+}
+
+func (cs *containerSync) runOnce(conn db.Conn, store Store) error {
+	etcdKeys, err := readContainerKeys(store,
+		containerWatchDir(conn.MinionSelf()))
 	if err != nil {
 		return fmt.Errorf("etcd read error: %s", err)
 	}
+	etcdDBCs := parseContainers(etcdKeys)
 
-	if conn.EtcdLeader() {
+	leader := conn.EtcdLeader()
+	if leader && !cs.leader {
+		cs.etcdIPs = containerIPs(etcdDBCs)
+	}
+	cs.leader = leader
+
+	if leader {
 		c.Inc("Run Container Leader")
-		return updateLeader(conn, store, etcdStr)
+		if err := updateLeader(conn, store, etcdKeys, cs.etcdIPs); err != nil {
+			return err
+		}
+
+		if !cs.legacyDeleted {
+			err := store.Delete(legacyContainerPath)
+			if err != nil && !isKeyNotFound(err) {
+				return fmt.Errorf("etcd delete error: %s", err)
+			}
+			cs.legacyDeleted = true
+		}
+		return nil
 	}
 
 	c.Inc("Run Container Worker")
-	updateNonLeader(conn, etcdStr)
+	updateNonLeader(conn, etcdDBCs)
 	return nil
 }
 
-func updateLeader(conn db.Conn, store Store, etcdStr string) error {
+func updateLeader(conn db.Conn, store Store, etcdKeys map[string]string,
+	etcdIPs map[string]string) error {
 	self := conn.MinionSelf()
 	myIP := self.PrivateIP
 
+	if len(etcdIPs) > 0 {
+		conn.Txn(db.ContainerTable, db.LoadBalancerTable).Run(
+			func(view db.Database) error {
+				restoreIPs(view, etcdIPs)
+				return nil
+			})
+	}
+
+	// Containers are keyed by their blueprint ID, so those without one
+	// can't be written.
 	dbcs := conn.SelectFromContainer(func(dbc db.Container) bool {
-		return dbc.Minion != "" && dbc.IP != ""
+		return dbc.Minion != "" && dbc.IP != "" && dbc.BlueprintID != ""
 	})
-	for i := range dbcs {
-		if dbcs[i].Dockerfile == "" {
-			continue
+
+	keys := map[string]string{}
+	for _, dbc := range dbcs {
+		if dbc.Dockerfile != "" {
+			dbc.Image = myIP + ":5000/" + dbc.Image
+		}
+
+		js, err := jsonMarshal(dbc)
+		if err != nil {
+			return fmt.Errorf("marshal container: %s", err)
 		}
-		dbcs[i].Image = myIP + ":5000/" + dbcs[i].Image
+		keys[containerKey(dbc)] = string(js)
 	}
 
-	err := writeEtcdSlice(store, containerPath, etcdStr, db.ContainerSlice(dbcs))
-	if err != nil {
+	if err := writeContainerKeys(store, etcdKeys, keys); err != nil {
 		return fmt.Errorf("etcd write error: %s", err)
 	}
+	return nil
+}
+
+func containerKey(dbc db.Container) string {
+	return path.Join(containerDir, dbc.Minion, dbc.BlueprintID)
+}
 
+// readContainerKeys returns the value of each container key within `dir`, by
+// path.  A missing directory has no containers.
+func readContainerKeys(store Store, dir string) (map[string]string, error) {
+	tree, err := store.GetTree(dir)
+	if isKeyNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]string{}
+	var walk func(string, Tree)
+	walk = func(p string, t Tree) {
+		if len(t.Children) == 0 && t.Value != "" {
+			keys[p] = t.Value
+		}
+		for name, child := range t.Children {
+			walk(path.Join(p, name), child)
+		}
+	}
+	walk(dir, tree)
+	return keys, nil
+}
+
+// writeContainerKeys updates etcd from the keys in `old` to those in `new`.
+// Only the keys that changed are written, and the directories of minions that
+// no longer have containers are deleted along with their keys.
+func writeContainerKeys(store Store, old, new map[string]string) error {
+	newDirs := map[string]struct{}{}
+	for key, value := range new {
+		newDirs[path.Dir(key)] = struct{}{}
+		if old[key] == value {
+			continue
+		}
+
+		c.Inc("Write Container")
+		if err := store.Set(key, value, 0); err != nil {
+			return err
+		}
+	}
+
+	deletedDirs := map[string]struct{}{}
+	for key := range old {
+		if _, ok := new[key]; ok {
+			continue
+		}
+
+		dir := path.Dir(key)
+		if _, ok := newDirs[dir]; ok {
+			c.Inc("Delete Container")
+			if err := store.Delete(key); err != nil {
+				return err
+			}
+		} else if _, ok := deletedDirs[dir]; !ok {
+			c.Inc("Delete Container Dir")
+			if err := store.Delete(dir); err != nil {
+				return err
+			}
+			deletedDirs[dir] = struct{}{}
+		}
+	}
 	return nil
 }
 
-func updateNonLeader(conn db.Conn, etcdStr string) {
+// parseContainers parses the containers in the container keys `etcdKeys`.
+// Containers that fail to parse are skipped.
+func parseContainers(etcdKeys map[string]string) []db.Container {
+	var dbcs []db.Container
+	for key, value := range etcdKeys {
+		var dbc db.Container
+		if err := json.Unmarshal([]byte(value), &dbc); err != nil {
+			log.WithError(err).WithField("key", key).Warning(
+				"Failed to parse container.")
+			continue
+		}
+		dbcs = append(dbcs, dbc)
+	}
+	return dbcs
+}
+
+// containerIPs returns the IP of each container in `etcdDBCs`, keyed by
+// blueprint ID.
+func containerIPs(etcdDBCs []db.Container) map[string]string {
+	ips := map[string]string{}
+	for _, dbc := range etcdDBCs {
+		if dbc.IP != "" && dbc.BlueprintID != "" {
+			ips[dbc.BlueprintID] = dbc.IP
+		}
+	}
+	return ips
+}
+
+// restoreIPs gives the containers in `view` the IPs in `etcdIPs`, unless they're
+// taken by something else.  Each IP is only restored once, so that it doesn't
+// override later changes, and is then removed from `etcdIPs`.
+func restoreIPs(view db.Database, etcdIPs map[string]string) {
+	taken := map[string]struct{}{}
+	for _, dbc := range view.SelectFromContainer(nil) {
+		taken[dbc.IP] = struct{}{}
+	}
+	for _, lb := range view.SelectFromLoadBalancer(nil) {
+		taken[lb.IP] = struct{}{}
+	}
+
+	for _, dbc := range view.SelectFromContainer(nil) {
+		ip, ok := etcdIPs[dbc.BlueprintID]
+		if !ok {
+			continue
+		}
+		delete(etcdIPs, dbc.BlueprintID)
+
+		if _, ok := taken[ip]; ok {
+			continue
+		}
+
+		c.Inc("Restore Container IP")
+		delete(taken, dbc.IP)
+		taken[ip] = struct{}{}
+		dbc.IP = ip
+		view.Commit(dbc)
+	}
+}
+
+func updateNonLeader(conn db.Conn, rawEtcdDBCs []db.Container) {
 	self := conn.MinionSelf()
 
-	var rawEtcdDBCs, etcdDBCs []db.Container
-	json.Unmarshal([]byte(etcdStr), &rawEtcdDBCs)
+	var etcdDBCs []db.Container
 	for _, dbc := range rawEtcdDBCs {
 		if self.Role == db.Master || dbc.Minion == self.PrivateIP {
 			etcdDBCs = append(etcdDBCs, dbc)
@@ -81,9 +276,15 @@ func updateNonLeader(conn db.Conn, etcdStr string) {
 
 func joinContainers(view db.Database, etcdDBCs []db.Container) {
 	// The join contains only those fields that require restart of the container.
+	// Containers with a reload signal have their files updated in place.
 	key := func(iface interface{}) interface{} {
 		dbc := iface.(db.Container)
 
+		files := util.MapAsString(dbc.FilepathToContent)
+		if dbc.ReloadSignal != "" {
+			files = ""
+		}
+
 		return struct {
 			Hostname          string
 			IP                string
@@ -101,7 +302,7 @@ func joinContainers(view db.Database, etcdDBCs []db.Container) {
 			ImageID:           dbc.ImageID,
 			Command:           fmt.Sprintf("%v", dbc.Command),
 			Env:               util.MapAsString(dbc.Env),
-			FilepathToContent: util.MapAsString(dbc.FilepathToContent),
+			FilepathToContent: files,
 		}
 	}
 
@@ -131,6 +332,18 @@ func joinContainers(view db.Database, etcdDBCs []db.Container) {
 		dbc.Env = edbc.Env
 		dbc.FilepathToContent = edbc.FilepathToContent
 		dbc.Hostname = edbc.Hostname
+		dbc.PostStart = edbc.PostStart
+		dbc.PreStop = edbc.PreStop
+		dbc.ReloadSignal = edbc.ReloadSignal
+		dbc.Networks = edbc.Networks
+		dbc.DNS = edbc.DNS
+		dbc.DNSSearch = edbc.DNSSearch
+		dbc.ExtraHosts = edbc.ExtraHosts
+		dbc.ExposeMetadata = edbc.ExposeMetadata
+		dbc.CloudRole = edbc.CloudRole
+		dbc.TTY = edbc.TTY
+		dbc.Stopped = edbc.Stopped
+		dbc.Draining = edbc.Draining
 		view.Commit(dbc)
 	}
 }
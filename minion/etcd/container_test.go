@@ -13,12 +13,7 @@ func TestRunContainerOnce(t *testing.T) {
 	store := newTestMock()
 	conn := db.New()
 
-	err := runContainerOnce(conn, store)
-	assert.Error(t, err)
-
-	err = store.Set(containerPath, "", 0)
-	assert.NoError(t, err)
-
+	cs := &containerSync{}
 	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		self := view.InsertMinion()
 		self.Self = true
@@ -42,34 +37,39 @@ func TestRunContainerOnce(t *testing.T) {
 		return nil
 	})
 
-	err = runContainerOnce(conn, store)
+	// The key that the containers were written to by older minions is
+	// deleted.
+	assert.NoError(t, store.Set(legacyContainerPath, "[]", 0))
+
+	err := cs.runOnce(conn, store)
 	assert.NoError(t, err)
 
-	str, err := store.Get(containerPath)
+	_, err = store.Get(legacyContainerPath)
+	assert.Error(t, err)
+
+	str, err := store.Get(containerDir + "/1.2.3.4/12")
 	assert.NoError(t, err)
 
-	expStr := `[
-    {
-        "IP": "10.0.0.2",
-        "Minion": "1.2.3.4",
-        "BlueprintID": "12",
-        "Command": [
-            "1",
-            "2",
-            "3"
-        ],
-        "Env": {
-            "blue": "pill",
-            "red": "pill"
-        },
-        "FilepathToContent": {
-            "foo": "bar"
-        },
-        "Hostname": "host",
-        "Created": "0001-01-01T00:00:00Z",
-        "Image": "ubuntu"
-    }
-]`
+	expStr := `{
+    "IP": "10.0.0.2",
+    "Minion": "1.2.3.4",
+    "BlueprintID": "12",
+    "Command": [
+        "1",
+        "2",
+        "3"
+    ],
+    "Env": {
+        "blue": "pill",
+        "red": "pill"
+    },
+    "FilepathToContent": {
+        "foo": "bar"
+    },
+    "Hostname": "host",
+    "Created": "0001-01-01T00:00:00Z",
+    "Image": "ubuntu"
+}`
 	assert.Equal(t, expStr, str)
 
 	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
@@ -84,7 +84,7 @@ func TestRunContainerOnce(t *testing.T) {
 		return nil
 	})
 
-	err = runContainerOnce(conn, store)
+	err = cs.runOnce(conn, store)
 	assert.NoError(t, err)
 
 	expDBC := db.Container{
@@ -102,7 +102,7 @@ func TestRunContainerOnce(t *testing.T) {
 	dbcs[0].ID = 0
 	assert.Equal(t, expDBC, dbcs[0])
 
-	err = runContainerOnce(conn, store)
+	err = cs.runOnce(conn, store)
 	assert.NoError(t, err)
 
 	dbcs = conn.SelectFromContainer(nil)
@@ -118,7 +118,7 @@ func TestRunContainerOnce(t *testing.T) {
 		return nil
 	})
 
-	err = runContainerOnce(conn, store)
+	err = cs.runOnce(conn, store)
 	assert.NoError(t, err)
 
 	dbcs = conn.SelectFromContainer(nil)
@@ -133,21 +133,61 @@ func TestRunContainerOnce(t *testing.T) {
 		return nil
 	})
 
-	err = runContainerOnce(conn, store)
+	err = cs.runOnce(conn, store)
 	assert.NoError(t, err)
 
 	dbcs = conn.SelectFromContainer(nil)
 	assert.Len(t, dbcs, 0)
 }
 
-func TestRunContainerOnceWithDockerfile(t *testing.T) {
+func TestWriteContainerKeys(t *testing.T) {
 	t.Parallel()
 
 	store := newTestMock()
-	conn := db.New()
+	keys := map[string]string{
+		"/minion-containers/1.2.3.4/1": "a",
+		"/minion-containers/1.2.3.4/2": "b",
+		"/minion-containers/1.2.3.5/3": "c",
+	}
+	assert.NoError(t, writeContainerKeys(store, nil, keys))
+	assert.Equal(t, 3, *store.writes)
+
+	read := func() map[string]string {
+		keys, err := readContainerKeys(store, containerDir)
+		assert.NoError(t, err)
+		return keys
+	}
+	assert.Equal(t, keys, read())
+
+	// Only the containers that changed are written.  Rescheduling the last
+	// container of a minion deletes the minion's directory.
+	newKeys := map[string]string{
+		"/minion-containers/1.2.3.4/1": "a",
+		"/minion-containers/1.2.3.4/3": "c",
+	}
+	*store.writes = 0
+	assert.NoError(t, writeContainerKeys(store, keys, newKeys))
+	assert.Equal(t, 1, *store.writes)
+	assert.Equal(t, newKeys, read())
 
-	err := store.Set(containerPath, "", 0)
+	_, err := store.GetTree(containerDir + "/1.2.3.5")
+	assert.True(t, isKeyNotFound(err))
+
+	assert.NoError(t, writeContainerKeys(store, newKeys, nil))
+	assert.Empty(t, read())
+
+	// A missing directory has no containers.
+	store = newTestMock()
+	keys, err = readContainerKeys(store, containerDir)
 	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestRunContainerOnceWithDockerfile(t *testing.T) {
+	t.Parallel()
+
+	store := newTestMock()
+	conn := db.New()
 
 	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		self := view.InsertMinion()
@@ -171,20 +211,138 @@ func TestRunContainerOnceWithDockerfile(t *testing.T) {
 		return nil
 	})
 
-	err = runContainerOnce(conn, store)
+	err := (&containerSync{}).runOnce(conn, store)
 	assert.NoError(t, err)
 
-	str, err := store.Get(containerPath)
+	str, err := store.Get(containerDir + "/1.2.3.4/12")
 	assert.NoError(t, err)
 
-	expStr := `[
-    {
-        "IP": "10.0.0.2",
-        "Minion": "1.2.3.4",
-        "BlueprintID": "12",
-        "Created": "0001-01-01T00:00:00Z",
-        "Image": "leader:5000/custom"
-    }
-]`
+	expStr := `{
+    "IP": "10.0.0.2",
+    "Minion": "1.2.3.4",
+    "BlueprintID": "12",
+    "Created": "0001-01-01T00:00:00Z",
+    "Image": "leader:5000/custom"
+}`
 	assert.Equal(t, expStr, str)
 }
+
+func TestRestoreIPsOnLeadership(t *testing.T) {
+	t.Parallel()
+
+	store := newTestMock()
+	conn := db.New()
+
+	assert.NoError(t, store.Set(containerDir+"/1.2.3.4/1",
+		`{"IP": "10.0.0.2", "BlueprintID": "1", "Minion": "1.2.3.4"}`, 0))
+	assert.NoError(t, store.Set(containerDir+"/1.2.3.4/2",
+		`{"IP": "10.0.0.3", "BlueprintID": "2", "Minion": "1.2.3.4"}`, 0))
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		self := view.InsertMinion()
+		self.Self = true
+		self.Role = db.Master
+		view.Commit(self)
+
+		etcd := view.InsertEtcd()
+		etcd.Leader = true
+		view.Commit(etcd)
+		return nil
+	})
+
+	// The restarted leader hasn't loaded its blueprint yet, so its IPs are
+	// restored once the containers appear.
+	cs := &containerSync{}
+	assert.NoError(t, cs.runOnce(conn, store))
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		// Allocated a new IP before learning of the old one.
+		dbc := view.InsertContainer()
+		dbc.BlueprintID = "1"
+		dbc.Minion = "1.2.3.4"
+		dbc.IP = "10.0.0.9"
+		view.Commit(dbc)
+
+		// Its old IP was taken by a load balancer.
+		dbc = view.InsertContainer()
+		dbc.BlueprintID = "2"
+		dbc.Minion = "1.2.3.4"
+		dbc.IP = "10.0.0.8"
+		view.Commit(dbc)
+
+		lb := view.InsertLoadBalancer()
+		lb.IP = "10.0.0.3"
+		view.Commit(lb)
+		return nil
+	})
+	assert.NoError(t, cs.runOnce(conn, store))
+
+	ips := map[string]string{}
+	for _, dbc := range conn.SelectFromContainer(nil) {
+		ips[dbc.BlueprintID] = dbc.IP
+	}
+	assert.Equal(t, map[string]string{"1": "10.0.0.2", "2": "10.0.0.8"}, ips)
+	assert.Empty(t, cs.etcdIPs)
+
+	assert.Equal(t, map[string]string{"1": "10.0.0.2", "2": "10.0.0.8"},
+		etcdContainerIPs(t, store))
+
+	// IPs are only restored once.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		dbc := view.SelectFromContainer(func(dbc db.Container) bool {
+			return dbc.BlueprintID == "1"
+		})[0]
+		dbc.IP = "10.0.0.10"
+		view.Commit(dbc)
+		return nil
+	})
+	assert.NoError(t, cs.runOnce(conn, store))
+
+	assert.Equal(t, map[string]string{"1": "10.0.0.10", "2": "10.0.0.8"},
+		etcdContainerIPs(t, store))
+}
+
+func etcdContainerIPs(t *testing.T, store Store) map[string]string {
+	keys, err := readContainerKeys(store, containerDir)
+	assert.NoError(t, err)
+	return containerIPs(parseContainers(keys))
+}
+
+func TestJoinContainersReloadSignal(t *testing.T) {
+	t.Parallel()
+
+	conn := db.New()
+	dbc := db.Container{
+		BlueprintID:       "id",
+		IP:                "10.0.0.2",
+		FilepathToContent: map[string]string{"/app.conf": "old"},
+		ReloadSignal:      "SIGHUP",
+	}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		joinContainers(view, []db.Container{dbc})
+		return nil
+	})
+	id := conn.SelectFromContainer(nil)[0].ID
+
+	// Containers that reload their files keep their row when the files
+	// change, while other containers are replaced.
+	dbc.FilepathToContent = map[string]string{"/app.conf": "new"}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		joinContainers(view, []db.Container{dbc})
+		return nil
+	})
+	dbcs := conn.SelectFromContainer(nil)
+	assert.Len(t, dbcs, 1)
+	assert.Equal(t, id, dbcs[0].ID)
+	assert.Equal(t, dbc.FilepathToContent, dbcs[0].FilepathToContent)
+
+	dbc.ReloadSignal = ""
+	dbc.FilepathToContent = map[string]string{"/app.conf": "newer"}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		joinContainers(view, []db.Container{dbc})
+		return nil
+	})
+	dbcs = conn.SelectFromContainer(nil)
+	assert.Len(t, dbcs, 1)
+	assert.NotEqual(t, id, dbcs[0].ID)
+}
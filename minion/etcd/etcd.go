@@ -22,16 +22,19 @@ type Store interface {
 	Set(path, value string, ttl time.Duration) error
 	Refresh(path, value string, ttl time.Duration) error
 	RefreshDir(dir string, ttl time.Duration) error
+	EnableAuth(passwords map[string]string) error
 }
 
 type store struct {
-	kapi client.KeysAPI
+	client client.Client
+	kapi   client.KeysAPI
 }
 
 var c = counter.New("Etcd")
 
-// NewStore creates a new consensus store and returns it.
-func NewStore() Store {
+// NewStore creates a new consensus store, authenticated as `user`, and returns
+// it.
+func NewStore(user, password string) Store {
 	c.Inc("NewStore")
 	var etcd client.Client
 	for {
@@ -39,6 +42,8 @@ func NewStore() Store {
 		etcd, err = client.New(client.Config{
 			Endpoints: []string{"http://127.0.0.1:2379"},
 			Transport: client.DefaultTransport,
+			Username:  user,
+			Password:  password,
 		})
 		if err != nil {
 			log.WithError(err).Warning("Failed to connect to ETCD.")
@@ -49,7 +54,7 @@ func NewStore() Store {
 		break
 	}
 
-	return store{client.NewKeysAPI(etcd)}
+	return store{etcd, client.NewKeysAPI(etcd)}
 }
 
 func (s store) Watch(path string, rateLimit time.Duration) chan struct{} {
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/coreos/etcd/client"
 )
 
 // Mock implements a fake etcd.Store interface suitable for unit testing.  It
@@ -23,6 +25,9 @@ type mock struct {
 	writes      *int
 	reads       *int
 	currentTime *time.Time
+
+	// Maps users to their passwords, once authentication is enabled.
+	auth map[string]string
 }
 
 // NewMock creates a new mock etcd store for use of the unit tests.
@@ -31,6 +36,7 @@ func NewMock() Store {
 	m.Mutex = &sync.Mutex{}
 	m.root.Children = make(map[string]Tree)
 	m.expires = map[string]time.Time{}
+	m.auth = map[string]string{}
 	now := time.Now()
 	m.currentTime = &now
 	return m
@@ -155,7 +161,8 @@ func (m mock) getTree(path string) (Tree, error) {
 		var ok bool
 		t, ok = t.Children[dir]
 		if !ok {
-			return Tree{}, errors.New("no such directory")
+			return Tree{}, client.Error{Code: client.ErrorCodeKeyNotFound,
+				Message: "no such directory"}
 		}
 	}
 
@@ -170,7 +177,8 @@ func (m mock) Delete(path string) error {
 	}
 
 	if _, ok := tree.Children[node]; !ok {
-		return errors.New("undefined key")
+		return client.Error{Code: client.ErrorCodeKeyNotFound,
+			Message: "undefined key"}
 	}
 
 	delete(tree.Children, node)
@@ -213,6 +221,15 @@ func (m mock) RefreshDir(dir string, ttl time.Duration) error {
 	return m.Refresh(dir, "", ttl)
 }
 
+func (m mock) EnableAuth(passwords map[string]string) error {
+	m.Lock()
+	defer m.Unlock()
+	for user, password := range passwords {
+		m.auth[user] = password
+	}
+	return nil
+}
+
 func (m mock) expired(path string) bool {
 	expireTime, ok := m.expires[path]
 	return ok && !expireTime.IsZero() && m.now().After(expireTime)
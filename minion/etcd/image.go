@@ -0,0 +1,87 @@
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const imagePath = "/images"
+
+// runImage shares the leader's image table with the workers, so that they know
+// which images they've been assigned to build.
+func runImage(conn db.Conn, store Store) {
+	etcdWatch := store.Watch(imagePath, 1*time.Second)
+	trigg := conn.TriggerTick(60, db.ImageTable)
+	for range util.JoinNotifiers(trigg.C, etcdWatch) {
+		if err := runImageOnce(conn, store); err != nil {
+			log.WithError(err).Warn("Failed to sync images with Etcd.")
+		}
+	}
+}
+
+func runImageOnce(conn db.Conn, store Store) error {
+	etcdStr, err := readEtcdNode(store, imagePath)
+	if err != nil {
+		return fmt.Errorf("etcd read error: %s", err)
+	}
+
+	if conn.EtcdLeader() {
+		c.Inc("Run Image Leader")
+		slice := db.ImageSlice(conn.SelectFromImage(nil))
+
+		// The IDs are local to each minion's database.
+		for i := range slice {
+			slice[i].ID = 0
+		}
+
+		err = writeEtcdSlice(store, imagePath, etcdStr, slice)
+		if err != nil {
+			return fmt.Errorf("etcd write error: %s", err)
+		}
+	} else {
+		c.Inc("Run Image Worker")
+		var etcdImages []db.Image
+		json.Unmarshal([]byte(etcdStr), &etcdImages)
+		conn.Txn(db.ImageTable).Run(func(view db.Database) error {
+			joinImages(view, etcdImages)
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func joinImages(view db.Database, etcdImages []db.Image) {
+	key := func(iface interface{}) interface{} {
+		img := iface.(db.Image)
+		return struct{ Name, Dockerfile string }{img.Name, img.Dockerfile}
+	}
+
+	pairs, dbImages, newImages := join.HashJoin(
+		db.ImageSlice(view.SelectFromImage(nil)),
+		db.ImageSlice(etcdImages), key, key)
+
+	for _, dbImage := range dbImages {
+		view.Remove(dbImage.(db.Image))
+	}
+
+	for _, newImage := range newImages {
+		pairs = append(pairs, join.Pair{L: view.InsertImage(), R: newImage})
+	}
+
+	for _, pair := range pairs {
+		dbImage := pair.L.(db.Image)
+		etcdImage := pair.R.(db.Image)
+		etcdImage.ID = dbImage.ID
+		if dbImage != etcdImage {
+			view.Commit(etcdImage)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunImageOnce(t *testing.T) {
+	t.Parallel()
+
+	store := newTestMock()
+	conn := db.New()
+
+	err := runImageOnce(conn, store)
+	assert.Error(t, err)
+
+	err = store.Set(imagePath, "", 0)
+	assert.NoError(t, err)
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		etcd := view.InsertEtcd()
+		etcd.Leader = true
+		view.Commit(etcd)
+
+		img := view.InsertImage()
+		img.Name = "name"
+		img.Dockerfile = "dockerfile"
+		img.Status = db.Building
+		img.Minion = "10.0.0.2"
+		img.Registry = "10.0.0.1"
+		view.Commit(img)
+		return nil
+	})
+
+	err = runImageOnce(conn, store)
+	assert.NoError(t, err)
+
+	str, err := store.Get(imagePath)
+	assert.NoError(t, err)
+
+	expStr := `[
+    {
+        "ID": 0,
+        "Name": "name",
+        "Dockerfile": "dockerfile",
+        "DockerID": "",
+        "Status": "building",
+        "Minion": "10.0.0.2",
+        "Registry": "10.0.0.1"
+    }
+]`
+	assert.Equal(t, expStr, str)
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		etcd := view.SelectFromEtcd(nil)[0]
+		etcd.Leader = false
+		view.Commit(etcd)
+
+		img := view.SelectFromImage(nil)[0]
+		img.Status = db.Built
+		img.DockerID = "id"
+		view.Commit(img)
+
+		img = view.InsertImage()
+		img.Name = "stale"
+		view.Commit(img)
+		return nil
+	})
+
+	err = runImageOnce(conn, store)
+	assert.NoError(t, err)
+
+	images := conn.SelectFromImage(nil)
+	assert.Len(t, images, 1)
+	images[0].ID = 0
+	assert.Equal(t, db.Image{Name: "name", Dockerfile: "dockerfile",
+		Status: db.Building, Minion: "10.0.0.2", Registry: "10.0.0.1"},
+		images[0])
+}
@@ -20,7 +20,12 @@ const (
 
 func runMinionSync(conn db.Conn, store Store) {
 	loopLog := util.NewEventTimer("Etcd")
-	for range conn.TriggerTick(minionTimeout/2, db.MinionTable).C {
+
+	// Watch for changes to remote minions so that their container health
+	// propagates within seconds.
+	etcdWatch := store.Watch(minionPath, 1*time.Second)
+	trigg := conn.TriggerTick(minionTimeout/2, db.MinionTable)
+	for range util.JoinNotifiers(trigg.C, etcdWatch) {
 		loopLog.LogStart()
 		writeMinion(conn, store)
 		readMinion(conn, store)
@@ -89,11 +94,23 @@ func diffMinion(dbMinions, storeMinions []db.Minion) (del, add []db.Minion) {
 	key := func(iface interface{}) interface{} {
 		m := iface.(db.Minion)
 		return struct {
-			Role, PrivateIP, HostSubnets       string
-			Provider, Size, Region, FloatingIP string
+			Role, PrivateIP, HostSubnets        string
+			Provider, Size, Region, FloatingIP  string
+			Labels                              string
+			FailedContainers                    string
+			UnhealthyContainers                 string
+			ProgrammedConnections               string
+			BuiltImages                         string
+			NetworkDegraded, Drain, Interrupted bool
 		}{
 			string(m.Role), m.PrivateIP, strings.Join(m.HostSubnets, " "),
 			m.Provider, m.Size, m.Region, m.FloatingIP,
+			util.MapAsString(m.Labels),
+			strings.Join(m.FailedContainers, " "),
+			strings.Join(m.UnhealthyContainers, " "),
+			m.ProgrammedConnections,
+			util.MapAsString(m.BuiltImages), m.NetworkDegraded,
+			m.Drain, m.Interrupted,
 		}
 	}
 
@@ -103,6 +103,13 @@ func TestReadDiff(t *testing.T) {
 	del, add = diffMinion(append(dbms, sharedDbm), append(etcd, sharedEtcd))
 	assert.Equal(t, dbms, del)
 	assert.Equal(t, etcd, add)
+
+	// Minions whose labels change are updated.
+	relabeled := sharedEtcd
+	relabeled.Labels = map[string]string{"ssd": "true"}
+	del, add = diffMinion([]db.Minion{sharedDbm}, []db.Minion{relabeled})
+	assert.Equal(t, []db.Minion{sharedDbm}, del)
+	assert.Equal(t, []db.Minion{relabeled}, add)
 }
 
 func TestFilter(t *testing.T) {
@@ -11,13 +11,21 @@ import (
 
 // Run synchronizes state in `conn` with the Etcd cluster.
 func Run(conn db.Conn) {
-	store := NewStore()
+	user, password, err := waitForCredentials(conn)
+	if err != nil {
+		log.WithError(err).Error("Failed to get etcd credentials")
+		return
+	}
+
+	store := NewStore(user, password)
 	makeEtcdDir(minionPath, store, 0)
 
+	go runAuth(conn, store)
 	go runElection(conn, store)
 	go runConnection(conn, store)
 	go runContainer(conn, store)
 	go runHostname(conn, store)
+	go runImage(conn, store)
 	runMinionSync(conn, store)
 }
 
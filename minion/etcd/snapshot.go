@@ -0,0 +1,60 @@
+package etcd
+
+import (
+	"errors"
+	"path"
+
+	"github.com/kelda/kelda/db"
+)
+
+// The keys through which the minions share the cluster's state.  Each minion
+// writes its own key within MinionDir, and each container has a key within the
+// directory of its minion in ContainerDir.
+const (
+	ContainerDir  = containerDir
+	ConnectionKey = connectionPath
+	MinionDir     = minionPath
+)
+
+// Snapshot returns the values of the connection key, and of each key in the
+// minion and container directories, by path.  It connects to etcd with the
+// credentials that the daemon sent the minion, so it fails until they arrive.
+func Snapshot(conn db.Conn) (map[string]string, error) {
+	minion := conn.MinionSelf()
+	user := WorkerUser(minion.PrivateIP)
+	if minion.Role == db.Master {
+		user = RootUser
+	}
+
+	password := minion.EtcdPasswords[user]
+	if password == "" {
+		return nil, errors.New("the minion has no etcd credentials yet")
+	}
+	return snapshot(NewStore(user, password))
+}
+
+func snapshot(store Store) (map[string]string, error) {
+	connections, err := readEtcdNode(store, ConnectionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := readContainerKeys(store, ContainerDir)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = map[string]string{}
+	}
+	keys[ConnectionKey] = connections
+
+	tree, err := store.GetTree(MinionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, child := range tree.Children {
+		keys[path.Join(MinionDir, name)] = child.Value
+	}
+	return keys, nil
+}
@@ -0,0 +1,49 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	store := newTestMock()
+	_, err := snapshot(store)
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Set(connectionPath, "connections", 0))
+	assert.NoError(t, store.Set(minionPath+"/1.2.3.4", "minion", 0))
+	assert.NoError(t, store.Set(hostnamePath, "hostnames", 0))
+
+	// Without any containers, the container directory doesn't exist.
+	keys, err := snapshot(store)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/connections":     "connections",
+		"/minions/1.2.3.4": "minion",
+	}, keys)
+
+	assert.NoError(t, store.Set(containerDir+"/1.2.3.4/1", "container", 0))
+	keys, err = snapshot(store)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/minion-containers/1.2.3.4/1": "container",
+		"/connections":                 "connections",
+		"/minions/1.2.3.4":             "minion",
+	}, keys)
+
+	conn := db.New()
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Self = true
+		m.Role = db.Master
+		m.EtcdPasswords = map[string]string{WorkerUser(""): "password"}
+		view.Commit(m)
+		return nil
+	})
+	_, err = Snapshot(conn)
+	assert.EqualError(t, err, "the minion has no etcd credentials yet")
+}
@@ -18,18 +18,18 @@ func writeEtcdSlice(store Store, path, old string, new sort.Interface) error {
 
 func readEtcdNode(store Store, path string) (string, error) {
 	value, err := store.Get(path)
-	if err != nil {
-		etcdErr, ok := err.(client.Error)
-		if ok && etcdErr.Code == client.ErrorCodeKeyNotFound {
-			// The key was missing, which should be interpreted as empty.
-			return "", nil
-		}
-		return "", err
+	if isKeyNotFound(err) {
+		// The key was missing, which should be interpreted as empty.
+		return "", nil
 	}
-
 	return value, err
 }
 
+func isKeyNotFound(err error) bool {
+	etcdErr, ok := err.(client.Error)
+	return ok && etcdErr.Code == client.ErrorCodeKeyNotFound
+}
+
 func jsonMarshal(v interface{}) ([]byte, error) {
 	return json.MarshalIndent(v, "", "    ")
 }
@@ -0,0 +1,127 @@
+// Package interruption watches for notices that the minion's machine is about to
+// be reclaimed by its cloud provider, as happens to preemptible machines, so that
+// its containers are scheduled elsewhere before the machine is terminated.
+package interruption
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kelda/kelda/counter"
+	"github.com/kelda/kelda/db"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Amazon gives two minutes of notice, and Google only thirty seconds, so the
+// notices are polled often.
+const interval = 5 * time.Second
+
+// The instance metadata that announces an interruption.  Amazon's returns 404
+// until the instance is scheduled to be interrupted, while Google's reports
+// whether the instance has been preempted.
+var awsNoticeURL = "http://169.254.169.254/latest/meta-data/spot/instance-action"
+var gceNoticeURL = "http://metadata.google.internal/computeMetadata/v1/" +
+	"instance/preempted"
+
+var httpClient = &http.Client{Timeout: 2 * time.Second}
+
+var c = counter.New("Interruption")
+
+// Run periodically polls the instance metadata of the minion's machine, and sets
+// the Interrupted flag of the Minion once the machine is about to be reclaimed.
+func Run(conn db.Conn) {
+	for range time.Tick(interval) {
+		runOnce(conn)
+	}
+}
+
+func runOnce(conn db.Conn) {
+	self := conn.MinionSelf()
+	if self.Interrupted {
+		return
+	}
+
+	var interrupted bool
+	var err error
+	switch db.ProviderName(self.Provider) {
+	case db.Amazon:
+		interrupted, err = awsInterrupted()
+	case db.Google:
+		interrupted, err = gceInterrupted()
+	default:
+		return
+	}
+
+	if err != nil {
+		c.Inc("Poll Error")
+		log.WithError(err).Debug("Failed to poll for interruption notices.")
+		return
+	}
+
+	if !interrupted {
+		return
+	}
+
+	c.Inc("Interrupted")
+	log.Warn("Machine is about to be reclaimed by its provider. " +
+		"Draining containers.")
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		self.Interrupted = true
+		view.Commit(self)
+		return nil
+	})
+}
+
+func awsInterrupted() (bool, error) {
+	status, _, err := getMetadata(awsNoticeURL, nil)
+	switch {
+	case err != nil:
+		return false, err
+	case status == http.StatusNotFound:
+		return false, nil
+	case status != http.StatusOK:
+		return false, fmt.Errorf("unexpected status %d", status)
+	}
+	return true, nil
+}
+
+func gceInterrupted() (bool, error) {
+	status, body, err := getMetadata(gceNoticeURL,
+		map[string]string{"Metadata-Flavor": "Google"})
+	switch {
+	case err != nil:
+		return false, err
+	case status != http.StatusOK:
+		return false, fmt.Errorf("unexpected status %d", status)
+	}
+	return body == "TRUE", nil
+}
+
+// getMetadata fetches `url`, returning the response's status code and its
+// trimmed body.
+func getMetadata(url string, headers map[string]string) (int, string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode, strings.TrimSpace(string(body)), nil
+}
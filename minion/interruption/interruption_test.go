@@ -0,0 +1,82 @@
+package interruption
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+)
+
+func TestRunOnce(t *testing.T) {
+	var awsStatus int
+	var preempted string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/aws":
+				w.WriteHeader(awsStatus)
+				fmt.Fprint(w, `{"action": "terminate"}`)
+			case "/gce":
+				assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+				fmt.Fprintln(w, preempted)
+			}
+		}))
+	defer server.Close()
+
+	awsNoticeURL = server.URL + "/aws"
+	gceNoticeURL = server.URL + "/gce"
+
+	conn := db.New()
+	setProvider := func(provider db.ProviderName) {
+		conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+			self := view.MinionSelf()
+			self.Self = true
+			self.Provider = string(provider)
+			self.Interrupted = false
+			view.Commit(self)
+			return nil
+		})
+	}
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.InsertMinion()
+		self.Self = true
+		view.Commit(self)
+		return nil
+	})
+
+	setProvider(db.Amazon)
+	awsStatus = http.StatusNotFound
+	runOnce(conn)
+	assert.False(t, conn.MinionSelf().Interrupted)
+
+	awsStatus = http.StatusInternalServerError
+	runOnce(conn)
+	assert.False(t, conn.MinionSelf().Interrupted)
+
+	awsStatus = http.StatusOK
+	runOnce(conn)
+	assert.True(t, conn.MinionSelf().Interrupted)
+
+	// Once interrupted, the minion stays interrupted.
+	awsStatus = http.StatusNotFound
+	runOnce(conn)
+	assert.True(t, conn.MinionSelf().Interrupted)
+
+	setProvider(db.Google)
+	preempted = "FALSE"
+	runOnce(conn)
+	assert.False(t, conn.MinionSelf().Interrupted)
+
+	preempted = "TRUE"
+	runOnce(conn)
+	assert.True(t, conn.MinionSelf().Interrupted)
+
+	// Other providers don't announce interruptions.
+	setProvider(db.DigitalOcean)
+	runOnce(conn)
+	assert.False(t, conn.MinionSelf().Interrupted)
+}
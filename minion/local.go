@@ -0,0 +1,143 @@
+// +build !windows
+
+package minion
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/ipdef"
+	"github.com/kelda/kelda/minion/network/plugin"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// localSocket is where the minion serves its read-only debugging API.  The
+// directory is shared with the host, so operators who SSH into the machine can
+// inspect what the minion believes, e.g. with
+// `curl --unix-socket /var/lib/quilt/minion.sock http://minion/config`.
+const localSocket = "/var/lib/quilt/minion.sock"
+
+// Allow mocking out the network plugin for the unit tests.
+var listEndpoints = plugin.Endpoints
+
+// An endpoint is the network plugin's view of a container's veth.  Endpoints
+// without a container are stale, and will be cleaned up by the minion.
+type endpoint struct {
+	Link      string
+	Container string `json:",omitempty"`
+	IP        string `json:",omitempty"`
+}
+
+func localServerRun(conn db.Conn) {
+	// A socket left behind by a previous minion would prevent listening.
+	if err := os.Remove(localSocket); err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Error("Failed to remove stale local API socket")
+		return
+	}
+
+	sock, err := net.Listen("unix", localSocket)
+	if err != nil {
+		log.WithError(err).Error("Failed to listen on local API socket")
+		return
+	}
+
+	// The config includes the blueprint, so only root may read it.
+	if err := os.Chmod(localSocket, 0600); err != nil {
+		log.WithError(err).Error("Failed to restrict local API socket")
+		sock.Close()
+		return
+	}
+
+	err = http.Serve(sock, localHandler(conn))
+	log.WithError(err).Error("Local API server exited")
+}
+
+// localHandler serves the minion's config at /config, the containers scheduled
+// on it at /containers, and the network plugin's endpoints at /network, all as
+// JSON.
+func localHandler(conn db.Conn) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", readOnly(func() (interface{}, error) {
+		return server{Conn: conn}.config(), nil
+	}))
+	mux.HandleFunc("/containers", readOnly(func() (interface{}, error) {
+		return localContainers(conn), nil
+	}))
+	mux.HandleFunc("/network", readOnly(func() (interface{}, error) {
+		return localEndpoints(conn)
+	}))
+	return mux
+}
+
+func readOnly(get func() (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.Inc("Local API")
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "the local API is read-only",
+				http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp, err := get()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		enc.Encode(resp)
+	}
+}
+
+// localContainers returns the containers scheduled on this minion.
+func localContainers(conn db.Conn) []db.Container {
+	dbcs := []db.Container{}
+	conn.Txn(db.ContainerTable, db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		dbcs = append(dbcs, view.SelectFromContainer(
+			func(dbc db.Container) bool {
+				return self.PrivateIP != "" && dbc.Minion == self.PrivateIP
+			})...)
+		return nil
+	})
+	sort.Sort(db.ContainerSlice(dbcs))
+	return dbcs
+}
+
+// localEndpoints returns the network plugin's endpoints, along with the
+// containers that they belong to.
+func localEndpoints(conn db.Conn) ([]endpoint, error) {
+	links, err := listEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	containers := map[string]db.Container{}
+	for _, dbc := range localContainers(conn) {
+		if dbc.EndpointID != "" {
+			containers[ipdef.IFName(dbc.EndpointID)] = dbc
+		}
+	}
+
+	endpoints := []endpoint{}
+	for _, link := range links {
+		ep := endpoint{Link: link}
+		if dbc, ok := containers[link]; ok {
+			ep.Container = dbc.BlueprintID
+			ep.IP = dbc.IP
+		}
+		endpoints = append(endpoints, ep)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Link < endpoints[j].Link
+	})
+	return endpoints, nil
+}
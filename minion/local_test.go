@@ -0,0 +1,80 @@
+// +build !windows
+
+package minion
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/network/plugin"
+)
+
+func TestLocalHandler(t *testing.T) {
+	defer func() { listEndpoints = plugin.Endpoints }()
+
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Self = true
+		m.Role = db.Worker
+		m.PrivateIP = "10.0.0.1"
+		m.ConfigGeneration = 2
+		view.Commit(m)
+
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			dbc := view.InsertContainer()
+			dbc.BlueprintID = "on-" + ip
+			dbc.Minion = ip
+			dbc.IP = "10.1.0.1"
+			dbc.EndpointID = "000000000000000abc"
+			view.Commit(dbc)
+		}
+		return nil
+	})
+	handler := localHandler(conn)
+
+	get := func(path string, resp interface{}) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		}
+		return w.Code
+	}
+
+	var cfg map[string]interface{}
+	assert.Equal(t, http.StatusOK, get("/config", &cfg))
+	assert.Equal(t, "10.0.0.1", cfg["PrivateIP"])
+	assert.Equal(t, float64(2), cfg["Generation"])
+
+	var dbcs []db.Container
+	assert.Equal(t, http.StatusOK, get("/containers", &dbcs))
+	assert.Len(t, dbcs, 1)
+	assert.Equal(t, "on-10.0.0.1", dbcs[0].BlueprintID)
+
+	listEndpoints = func() ([]string, error) {
+		return []string{"111111111111111", "000000000000000"}, nil
+	}
+	var endpoints []endpoint
+	assert.Equal(t, http.StatusOK, get("/network", &endpoints))
+	assert.Equal(t, []endpoint{
+		{Link: "000000000000000", Container: "on-10.0.0.1", IP: "10.1.0.1"},
+		{Link: "111111111111111"},
+	}, endpoints)
+
+	listEndpoints = func() ([]string, error) {
+		return nil, errors.New("err")
+	}
+	assert.Equal(t, http.StatusInternalServerError, get("/network", nil))
+
+	// The API is read-only.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/config", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
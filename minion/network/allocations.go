@@ -0,0 +1,101 @@
+package network
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The file in which the leader records the IPs it allocates, so that containers
+// and load balancers keep their addresses when the minion restarts, rather than
+// being re-addressed, which would break their long-lived connections.  The
+// directory is mounted from the host, so it outlives the minion container.
+var allocationsPath = "/var/lib/quilt/ip-allocations.json"
+
+// ipAllocations maps container blueprint IDs, and load balancer names, to the IPs
+// that were allocated to them.
+type ipAllocations struct {
+	Containers    map[string]string `json:",omitempty"`
+	LoadBalancers map[string]string `json:",omitempty"`
+}
+
+func readAllocations() ipAllocations {
+	var allocs ipAllocations
+	allocsStr, err := util.ReadFile(allocationsPath)
+	if err == nil {
+		err = json.Unmarshal([]byte(allocsStr), &allocs)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Warn("Failed to read IP allocations")
+	}
+
+	if allocs.Containers == nil {
+		allocs.Containers = map[string]string{}
+	}
+	if allocs.LoadBalancers == nil {
+		allocs.LoadBalancers = map[string]string{}
+	}
+	return allocs
+}
+
+func (allocs ipAllocations) write() error {
+	allocsJSON, err := json.Marshal(allocs)
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(allocationsPath, allocsJSON, 0644)
+}
+
+// update records the IPs of the containers and load balancers in `view`, and
+// returns whether any changed.  Allocations of entities that aren't in the
+// database are kept, as it's empty until the blueprint is loaded after a
+// restart, unless their IP now belongs to something else.
+func (allocs ipAllocations) update(view db.Database) bool {
+	taken := map[string]struct{}{}
+
+	containerIPs := map[string]string{}
+	for _, dbc := range view.SelectFromContainer(nil) {
+		if dbc.IP != "" && dbc.BlueprintID != "" {
+			containerIPs[dbc.BlueprintID] = dbc.IP
+			taken[dbc.IP] = struct{}{}
+		}
+	}
+
+	loadBalancerIPs := map[string]string{}
+	for _, lb := range view.SelectFromLoadBalancer(nil) {
+		if lb.IP != "" {
+			loadBalancerIPs[lb.Name] = lb.IP
+			taken[lb.IP] = struct{}{}
+		}
+	}
+
+	containersChanged := syncAllocations(allocs.Containers, containerIPs, taken)
+	lbsChanged := syncAllocations(allocs.LoadBalancers, loadBalancerIPs, taken)
+	return containersChanged || lbsChanged
+}
+
+// syncAllocations records the `current` allocations in `recorded`, and forgets
+// the recorded allocations whose IP is now taken by something else.
+func syncAllocations(recorded, current map[string]string,
+	taken map[string]struct{}) (changed bool) {
+
+	for key, ip := range recorded {
+		if _, ok := taken[ip]; ok && current[key] != ip {
+			delete(recorded, key)
+			changed = true
+		}
+	}
+
+	for key, ip := range current {
+		if recorded[key] != ip {
+			recorded[key] = ip
+			changed = true
+		}
+	}
+	return changed
+}
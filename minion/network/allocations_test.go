@@ -0,0 +1,80 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/util"
+)
+
+func TestReadWriteAllocations(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	// A missing or malformed file has no allocations.
+	assert.Equal(t, ipAllocations{
+		Containers:    map[string]string{},
+		LoadBalancers: map[string]string{},
+	}, readAllocations())
+
+	util.WriteFile(allocationsPath, []byte("malformed"), 0644)
+	assert.Empty(t, readAllocations().Containers)
+
+	allocs := ipAllocations{
+		Containers:    map[string]string{"container": "10.0.0.2"},
+		LoadBalancers: map[string]string{"lb": "10.0.0.3"},
+	}
+	assert.NoError(t, allocs.write())
+	assert.Equal(t, allocs, readAllocations())
+}
+
+func TestUpdateAllocations(t *testing.T) {
+	t.Parallel()
+
+	allocs := ipAllocations{
+		Containers: map[string]string{
+			"unchanged": "10.0.0.2",
+			"missing":   "10.0.0.3",
+			"stale":     "10.0.0.4",
+		},
+		LoadBalancers: map[string]string{},
+	}
+
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		dbc := view.InsertContainer()
+		dbc.BlueprintID = "unchanged"
+		dbc.IP = "10.0.0.2"
+		view.Commit(dbc)
+
+		// Took the IP of the stale allocation.
+		dbc = view.InsertContainer()
+		dbc.BlueprintID = "new"
+		dbc.IP = "10.0.0.4"
+		view.Commit(dbc)
+
+		dbc = view.InsertContainer()
+		dbc.BlueprintID = "unallocated"
+		view.Commit(dbc)
+
+		lb := view.InsertLoadBalancer()
+		lb.Name = "lb"
+		lb.IP = "10.0.0.5"
+		view.Commit(lb)
+
+		assert.True(t, allocs.update(view))
+		assert.False(t, allocs.update(view))
+		return nil
+	})
+
+	assert.Equal(t, ipAllocations{
+		Containers: map[string]string{
+			"unchanged": "10.0.0.2",
+			"missing":   "10.0.0.3",
+			"new":       "10.0.0.4",
+		},
+		LoadBalancers: map[string]string{"lb": "10.0.0.5"},
+	}, allocs)
+}
@@ -1,8 +1,10 @@
 package network
 
 import (
+	"bytes"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 	"sync"
 
@@ -21,7 +23,7 @@ type dnsTable struct {
 	server dns.Server
 
 	recordLock sync.Mutex
-	records    map[string]net.IP
+	records    map[string][]net.IP
 }
 
 var table *dnsTable
@@ -51,13 +53,15 @@ func syncHostnamesOnce(conn db.Conn) {
 		return
 	}
 
-	conn.Txn(db.LoadBalancerTable, db.ContainerTable, db.HostnameTable).
-		Run(joinHostnames)
+	conn.Txn(db.LoadBalancerTable, db.ContainerTable, db.HostnameTable,
+		db.MinionTable).Run(joinHostnames)
 }
 
 func joinHostnames(view db.Database) error {
 	var target []db.Hostname
+	taken := map[string]struct{}{}
 	for _, lb := range view.SelectFromLoadBalancer(nil) {
+		taken[lb.Name] = struct{}{}
 		if lb.IP != "" {
 			target = append(target, db.Hostname{
 				Hostname: lb.Name,
@@ -65,7 +69,10 @@ func joinHostnames(view db.Database) error {
 			})
 		}
 	}
-	for _, c := range view.SelectFromContainer(nil) {
+
+	containers := view.SelectFromContainer(nil)
+	for _, c := range containers {
+		taken[c.Hostname] = struct{}{}
 		if c.Hostname != "" && c.IP != "" {
 			target = append(target, db.Hostname{
 				Hostname: c.Hostname,
@@ -73,6 +80,8 @@ func joinHostnames(view db.Database) error {
 			})
 		}
 	}
+	target = append(target, labelHostnames(containers)...)
+	target = append(target, roleHostnames(view.SelectFromMinion(nil), taken)...)
 
 	key := func(iface interface{}) interface{} {
 		h := iface.(db.Hostname)
@@ -96,6 +105,72 @@ func joinHostnames(view db.Database) error {
 	return nil
 }
 
+// labelHostnames groups the replicas of each container label.  Every replica is
+// reachable at "all.<label>", and each individual replica at "<i>.<label>", where
+// `i` starts at 1 and matches the suffix of the replica's unique hostname.
+func labelHostnames(containers []db.Container) []db.Hostname {
+	labelToContainers := map[string][]db.Container{}
+	for _, c := range containers {
+		if c.Label != "" {
+			labelToContainers[c.Label] = append(labelToContainers[c.Label], c)
+		}
+	}
+
+	var hostnames []db.Hostname
+	for label, replicas := range labelToContainers {
+		// Unique hostnames are generated by appending an increasing count to
+		// the label, so ordering by length first puts "web10" after "web9".
+		sort.Slice(replicas, func(i, j int) bool {
+			hi, hj := replicas[i].Hostname, replicas[j].Hostname
+			if len(hi) != len(hj) {
+				return len(hi) < len(hj)
+			}
+			return hi < hj
+		})
+
+		for i, c := range replicas {
+			if c.IP == "" {
+				continue
+			}
+
+			hostnames = append(hostnames, db.Hostname{
+				Hostname: "all." + label,
+				IP:       c.IP,
+			}, db.Hostname{
+				Hostname: fmt.Sprintf("%d.%s", i+1, label),
+				IP:       c.IP,
+			})
+		}
+	}
+	return hostnames
+}
+
+// roleHostnames makes the minions reachable at "masters" and "workers" according
+// to their role, unless a container or load balancer already uses the name.
+func roleHostnames(minions []db.Minion, taken map[string]struct{}) []db.Hostname {
+	var hostnames []db.Hostname
+	for _, m := range minions {
+		var name string
+		switch m.Role {
+		case db.Master:
+			name = "masters"
+		case db.Worker:
+			name = "workers"
+		default:
+			continue
+		}
+
+		if _, ok := taken[name]; ok || m.PrivateIP == "" {
+			continue
+		}
+		hostnames = append(hostnames, db.Hostname{
+			Hostname: name,
+			IP:       m.PrivateIP,
+		})
+	}
+	return hostnames
+}
+
 func serveDNSOnce(conn db.Conn) {
 	self := conn.MinionSelf()
 
@@ -202,12 +277,9 @@ func (table *dnsTable) lookupA(name string) []net.IP {
 	dnsC.Inc("Lookup External")
 	if strings.HasSuffix(name, ".q.") {
 		table.recordLock.Lock()
-		ip := table.records[name]
+		ips := table.records[name]
 		table.recordLock.Unlock()
-		if ip == nil {
-			return nil
-		}
-		return []net.IP{ip}
+		return ips
 	}
 
 	ipStrs, err := lookupHost(strings.TrimRight(name, "."))
@@ -225,7 +297,7 @@ func (table *dnsTable) lookupA(name string) []net.IP {
 	return ips
 }
 
-func makeTable(records map[string]net.IP) *dnsTable {
+func makeTable(records map[string][]net.IP) *dnsTable {
 	tbl := &dnsTable{
 		records: records,
 		server: dns.Server{
@@ -237,13 +309,20 @@ func makeTable(records map[string]net.IP) *dnsTable {
 	return tbl
 }
 
-func hostnamesToDNS(hostnames []db.Hostname) map[string]net.IP {
-	records := map[string]net.IP{}
+func hostnamesToDNS(hostnames []db.Hostname) map[string][]net.IP {
+	records := map[string][]net.IP{}
 	for _, hn := range hostnames {
 		if ip := net.ParseIP(hn.IP); ip != nil {
-			records[hn.Hostname+".q."] = ip
+			name := hn.Hostname + ".q."
+			records[name] = append(records[name], ip)
 		}
 	}
+
+	for _, ips := range records {
+		sort.Slice(ips, func(i, j int) bool {
+			return bytes.Compare(ips[i], ips[j]) < 0
+		})
+	}
 	return records
 }
 
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"fmt"
 	"net"
 	"testing"
 
@@ -22,20 +23,21 @@ func TestUpdateTable(t *testing.T) {
 
 	table := updateTable(nil, []db.Hostname{{Hostname: "foo", IP: "1.2.3.4"}})
 	assert.NotNil(t, table)
-	assert.Equal(t, map[string]net.IP{"foo.q.": net.IPv4(1, 2, 3, 4)}, table.records)
+	assert.Equal(t, map[string][]net.IP{"foo.q.": {net.IPv4(1, 2, 3, 4)}},
+		table.records)
 
 	newTable := updateTable(table, []db.Hostname{{Hostname: "foo", IP: "5.6.7.8"}})
 	assert.NotNil(t, newTable)
 	assert.True(t, table == newTable) // Pointer Equality.
-	assert.Equal(t, map[string]net.IP{"foo.q.": net.IPv4(5, 6, 7, 8)},
+	assert.Equal(t, map[string][]net.IP{"foo.q.": {net.IPv4(5, 6, 7, 8)}},
 		newTable.records)
 }
 
 func TestGenResponse(t *testing.T) {
 	t.Parallel()
 
-	table := makeTable(map[string]net.IP{
-		"a.q.": net.IPv4(1, 2, 3, 4),
+	table := makeTable(map[string][]net.IP{
+		"a.q.": {net.IPv4(1, 2, 3, 4)},
 	})
 
 	req := &dns.Msg{}
@@ -74,13 +76,17 @@ func TestGenResponse(t *testing.T) {
 func TestLookupA(t *testing.T) {
 	t.Parallel()
 
-	table := makeTable(map[string]net.IP{
-		"a.q.": net.IPv4(1, 2, 3, 4),
+	table := makeTable(map[string][]net.IP{
+		"a.q.": {net.IPv4(1, 2, 3, 4)},
 	})
 
 	assert.Empty(t, table.lookupA("bad.q."))
 	assert.Equal(t, []net.IP{net.IPv4(1, 2, 3, 4)}, table.lookupA("a.q."))
 
+	table.records["all.a.q."] = []net.IP{net.IPv4(1, 2, 3, 4), net.IPv4(5, 6, 7, 8)}
+	assert.Equal(t, []net.IP{net.IPv4(1, 2, 3, 4), net.IPv4(5, 6, 7, 8)},
+		table.lookupA("all.a.q."))
+
 	lookupHost = func(string) ([]string, error) { return nil, assert.AnError }
 	assert.Empty(t, table.lookupA("quilt.io."))
 
@@ -102,7 +108,7 @@ func TestLookupA(t *testing.T) {
 func TestMakeTable(t *testing.T) {
 	t.Parallel()
 
-	records := map[string]net.IP{"a": net.IPv4(1, 2, 3, 4)}
+	records := map[string][]net.IP{"a": {net.IPv4(1, 2, 3, 4)}}
 	tbl := makeTable(records)
 	assert.Equal(t, tbl.records, records)
 	assert.Equal(t, tbl.server.Addr, "10.0.0.1:53")
@@ -129,12 +135,19 @@ func TestHostnamesToDNS(t *testing.T) {
 	}, {
 		Hostname: "2.h4",
 		IP:       "2.2.2.2",
+	}, {
+		Hostname: "all.h4",
+		IP:       "2.2.2.2",
+	}, {
+		Hostname: "all.h4",
+		IP:       "1.1.1.1",
 	}})
-	exp := map[string]net.IP{
-		"h3.q.":   net.IPv4(1, 2, 3, 4),
-		"h4.q.":   net.IPv4(5, 6, 7, 8),
-		"1.h4.q.": net.IPv4(1, 1, 1, 1),
-		"2.h4.q.": net.IPv4(2, 2, 2, 2),
+	exp := map[string][]net.IP{
+		"h3.q.":     {net.IPv4(1, 2, 3, 4)},
+		"h4.q.":     {net.IPv4(5, 6, 7, 8)},
+		"1.h4.q.":   {net.IPv4(1, 1, 1, 1)},
+		"2.h4.q.":   {net.IPv4(2, 2, 2, 2)},
+		"all.h4.q.": {net.IPv4(1, 1, 1, 1), net.IPv4(2, 2, 2, 2)},
 	}
 	assert.Equal(t, exp, res)
 }
@@ -165,6 +178,7 @@ func TestSyncHostnamesWorker(t *testing.T) {
 type syncHostnameTest struct {
 	loadBalancers              []db.LoadBalancer
 	containers                 []db.Container
+	minions                    []db.Minion
 	oldHostnames, expHostnames []db.Hostname
 }
 
@@ -236,6 +250,37 @@ func TestSyncHostnames(t *testing.T) {
 				{Hostname: "container", IP: "containerIP"},
 			},
 		},
+		{
+			containers: []db.Container{
+				{Hostname: "web", Label: "web", IP: "1"},
+				{Hostname: "web2", Label: "web", IP: "2"},
+				{Hostname: "web10", Label: "web", IP: "10"},
+				{Hostname: "web3", Label: "web"},
+				{Hostname: "web4", Label: "web", IP: "4"},
+				{Hostname: "web5", Label: "web", IP: "5"},
+				{Hostname: "web6", Label: "web", IP: "6"},
+				{Hostname: "web7", Label: "web", IP: "7"},
+				{Hostname: "web8", Label: "web", IP: "8"},
+				{Hostname: "web9", Label: "web", IP: "9"},
+			},
+			expHostnames: webHostnames(),
+		},
+		{
+			containers: []db.Container{
+				{Hostname: "workers", IP: "containerIP"},
+			},
+			minions: []db.Minion{
+				{Role: db.Master, PrivateIP: "master1"},
+				{Role: db.Master, PrivateIP: "master2"},
+				{Role: db.Worker, PrivateIP: "worker"},
+				{Role: db.Worker},
+			},
+			expHostnames: []db.Hostname{
+				{Hostname: "workers", IP: "containerIP"},
+				{Hostname: "masters", IP: "master1"},
+				{Hostname: "masters", IP: "master2"},
+			},
+		},
 	}
 	for _, test := range tests {
 		conn := db.New()
@@ -259,6 +304,11 @@ func TestSyncHostnames(t *testing.T) {
 				c.ID = dbc.ID
 				view.Commit(c)
 			}
+			for _, m := range test.minions {
+				dbm := view.InsertMinion()
+				m.ID = dbm.ID
+				view.Commit(m)
+			}
 			return nil
 		})
 		syncHostnamesOnce(conn)
@@ -266,6 +316,30 @@ func TestSyncHostnames(t *testing.T) {
 	}
 }
 
+// webHostnames returns the hostnames expected for ten replicas labeled "web",
+// where "web3" doesn't have an IP yet.
+func webHostnames() []db.Hostname {
+	hostnames := []db.Hostname{{Hostname: "web", IP: "1"}}
+	for i := 1; i <= 10; i++ {
+		ip := fmt.Sprint(i)
+		if i > 1 {
+			hostnames = append(hostnames, db.Hostname{
+				Hostname: "web" + ip, IP: ip})
+		}
+		hostnames = append(hostnames,
+			db.Hostname{Hostname: "all.web", IP: ip},
+			db.Hostname{Hostname: ip + ".web", IP: ip})
+	}
+
+	var result []db.Hostname
+	for _, h := range hostnames {
+		if h.IP != "3" {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
 func assertHostnamesEqual(t *testing.T, exp, actual []db.Hostname) {
 	assert.Len(t, actual, len(exp))
 	assert.Equal(t, toHostnameMap(exp), toHostnameMap(actual))
@@ -0,0 +1,220 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/db"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+)
+
+// The chain of the filter table that accepts the traffic allowed by the ACLs.
+// Traffic that arrives on the public interface and isn't accepted by it is
+// dropped, mirroring the cloud's firewall for providers that lack one.  IPv4 and
+// IPv6 traffic are filtered by iptables and ip6tables respectively.
+const aclChain = "QUILT-ACL"
+
+func runFirewall(conn db.Conn, inboundPubIntf string) {
+	for range conn.TriggerTick(30, db.MinionTable).C {
+		acls := conn.MinionSelf().ACLs
+
+		pubIntf, _, err := pickIntfs(inboundPubIntf, inboundPubIntf)
+		if err != nil {
+			log.WithError(err).Error("Failed to get public interface")
+			continue
+		}
+
+		for _, proto := range []iptables.Protocol{iptables.ProtocolIPv4,
+			iptables.ProtocolIPv6} {
+			ipt, err := iptables.NewWithProtocol(proto)
+			if err != nil {
+				log.WithError(err).Error("Failed to get iptables handle")
+				continue
+			}
+
+			ipv6 := proto == iptables.ProtocolIPv6
+			if err := updateFirewall(ipt, pubIntf, acls, ipv6); err != nil {
+				log.WithError(err).WithField("ipv6", ipv6).Error(
+					"Failed to update firewall rules")
+			}
+		}
+	}
+}
+
+// updateFirewall filters the traffic that arrives on `pubIntf`, to the host or
+// to its containers, so that only the traffic allowed by `acls` is accepted.  If
+// there are no ACLs, the traffic isn't filtered.  `ipt` filters IPv6 traffic if
+// `ipv6` is set, in which case only IPv6 ACLs apply, and otherwise IPv4 traffic.
+// Traffic of a family without ACLs is still dropped if the other family has
+// some.
+func updateFirewall(ipt IPTables, pubIntf string, acls []acl.ACL,
+	ipv6 bool) error {
+
+	jumps := []string{
+		fmt.Sprintf("-i %s -j %s", pubIntf, aclChain),
+		fmt.Sprintf("-i %s -j DROP", pubIntf),
+	}
+
+	if len(acls) == 0 {
+		for _, chain := range []string{"INPUT", "FORWARD"} {
+			if err := removeRules(ipt, chain, jumps); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := ensureChain(ipt, "filter", aclChain); err != nil {
+		return err
+	}
+
+	rules := aclRules(acls, ipv6)
+	if err := syncChain(ipt, "filter", aclChain, rules); err != nil {
+		return err
+	}
+
+	for _, chain := range []string{"INPUT", "FORWARD"} {
+		if err := prependRules(ipt, chain, jumps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aclRules returns the rules that accept the traffic allowed by the IPv4 ACLs in
+// `acls`, or the IPv6 ones if `ipv6` is set, along with replies to connections
+// that the host or its containers initiated.
+func aclRules(acls []acl.ACL, ipv6 bool) []string {
+	rules := []string{"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT"}
+
+	// IPv6 relies on ICMPv6 for neighbor discovery, so it's always accepted.
+	if ipv6 {
+		rules = append(rules, "-p ipv6-icmp -j ACCEPT")
+	}
+
+	sources := map[string]struct{}{}
+	for _, acl := range acls {
+		if acl.IPv6() != ipv6 {
+			continue
+		}
+
+		source := aclSource(acl.CidrIP)
+		sources[source] = struct{}{}
+
+		ports := fmt.Sprintf("%d", acl.MinPort)
+		if acl.MaxPort != acl.MinPort {
+			ports = fmt.Sprintf("%d:%d", acl.MinPort, acl.MaxPort)
+		}
+
+		for _, protocol := range []string{"tcp", "udp"} {
+			rules = append(rules, fmt.Sprintf(
+				"%[1]s-p %[2]s -m %[2]s --dport %[3]s -j ACCEPT",
+				source, protocol, ports))
+		}
+	}
+
+	if ipv6 {
+		return rules
+	}
+
+	// Like the cloud firewalls, ICMP is allowed from every source in the ACLs.
+	var sortedSources []string
+	for source := range sources {
+		sortedSources = append(sortedSources, source)
+	}
+	sort.Strings(sortedSources)
+	for _, source := range sortedSources {
+		rules = append(rules, source+"-p icmp -j ACCEPT")
+	}
+	return rules
+}
+
+// aclSource returns the option that matches packets from `cidr`, in the form that
+// iptables lists it, so that the rules can be compared with the listed ones.
+// Iptables omits the option when it matches every address.
+func aclSource(cidr string) string {
+	cidr = acl.Canonical(cidr)
+	if cidr == "0.0.0.0/0" || cidr == "::/0" {
+		return ""
+	}
+	return "-s " + cidr + " "
+}
+
+// ensureChain creates `chain` in `table` if it doesn't exist.
+func ensureChain(ipt IPTables, table, chain string) error {
+	iptC.Inc("List Chains")
+	chains, err := ipt.ListChains(table)
+	if err != nil {
+		return fmt.Errorf("iptables list chains: %s", err)
+	}
+
+	for _, existing := range chains {
+		if existing == chain {
+			return nil
+		}
+	}
+
+	iptC.Inc("New Chain")
+	if err := ipt.NewChain(table, chain); err != nil {
+		return fmt.Errorf("iptables new chain: %s", err)
+	}
+	return nil
+}
+
+// prependRules ensures that `rules` are the first rules of `chain` in the filter
+// table, in order, so that they take precedence over the rules of others, such
+// as Docker.
+func prependRules(ipt IPTables, chain string, rules []string) error {
+	curr, err := getRules(ipt, "filter", chain)
+	if err != nil {
+		return fmt.Errorf("iptables get: %s", err)
+	}
+
+	if len(curr) >= len(rules) && reflect.DeepEqual(curr[:len(rules)], rules) {
+		return nil
+	}
+
+	if err := removeRules(ipt, chain, rules); err != nil {
+		return err
+	}
+
+	for i := len(rules) - 1; i >= 0; i-- {
+		iptC.Inc("Insert")
+		err := ipt.Insert("filter", chain, 1, strings.Split(rules[i], " ")...)
+		if err != nil {
+			return fmt.Errorf("iptables insert: %s", err)
+		}
+	}
+	return nil
+}
+
+// removeRules removes every instance of `rules` from `chain` in the filter
+// table.
+func removeRules(ipt IPTables, chain string, rules []string) error {
+	curr, err := getRules(ipt, "filter", chain)
+	if err != nil {
+		return fmt.Errorf("iptables get: %s", err)
+	}
+
+	toRemove := map[string]bool{}
+	for _, rule := range rules {
+		toRemove[rule] = true
+	}
+
+	for _, rule := range curr {
+		if !toRemove[rule] {
+			continue
+		}
+
+		iptC.Inc("Delete")
+		if err := ipt.Delete("filter", chain, strings.Split(rule, " ")...); err != nil {
+			return fmt.Errorf("iptables delete: %s", err)
+		}
+	}
+	return nil
+}
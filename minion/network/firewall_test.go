@@ -0,0 +1,181 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/cloud/acl"
+)
+
+func TestAclRules(t *testing.T) {
+	t.Parallel()
+
+	rules := aclRules([]acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 22, MaxPort: 22},
+		{CidrIP: "0.0.0.0/0", MinPort: 80, MaxPort: 81},
+		{CidrIP: "2001:db8::/32", MinPort: 22, MaxPort: 22},
+		{CidrIP: "10.0.0.1/8", MinPort: 443, MaxPort: 443},
+	}, false)
+	assert.Equal(t, []string{
+		"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"-s 1.2.3.4/32 -p tcp -m tcp --dport 22 -j ACCEPT",
+		"-s 1.2.3.4/32 -p udp -m udp --dport 22 -j ACCEPT",
+		"-p tcp -m tcp --dport 80:81 -j ACCEPT",
+		"-p udp -m udp --dport 80:81 -j ACCEPT",
+		"-s 10.0.0.0/8 -p tcp -m tcp --dport 443 -j ACCEPT",
+		"-s 10.0.0.0/8 -p udp -m udp --dport 443 -j ACCEPT",
+		"-p icmp -j ACCEPT",
+		"-s 1.2.3.4/32 -p icmp -j ACCEPT",
+		"-s 10.0.0.0/8 -p icmp -j ACCEPT",
+	}, rules)
+
+	// ICMPv6 is accepted from every source.
+	rules = aclRules([]acl.ACL{
+		{CidrIP: "1.2.3.4/32", MinPort: 22, MaxPort: 22},
+		{CidrIP: "2001:db8::/32", MinPort: 22, MaxPort: 22},
+		{CidrIP: "::/0", MinPort: 80, MaxPort: 80},
+	}, true)
+	assert.Equal(t, []string{
+		"-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"-p ipv6-icmp -j ACCEPT",
+		"-s 2001:db8::/32 -p tcp -m tcp --dport 22 -j ACCEPT",
+		"-s 2001:db8::/32 -p udp -m udp --dport 22 -j ACCEPT",
+		"-p tcp -m tcp --dport 80 -j ACCEPT",
+		"-p udp -m udp --dport 80 -j ACCEPT",
+	}, rules)
+}
+
+func TestUpdateFirewall(t *testing.T) {
+	t.Parallel()
+
+	ipt := newFakeIPTables()
+	ipt.chains["filter INPUT"] = []string{"-j ACCEPT"}
+	ipt.chains["filter FORWARD"] = []string{"-j DOCKER-USER", "-j ACCEPT"}
+
+	// Without ACLs, nothing is filtered.
+	assert.NoError(t, updateFirewall(ipt, "eth0", nil, false))
+	assert.Equal(t, []string{"-j ACCEPT"}, ipt.chains["filter INPUT"])
+	_, ok := ipt.chains["filter "+aclChain]
+	assert.False(t, ok)
+
+	acls := []acl.ACL{{CidrIP: "1.2.3.4/32", MinPort: 22, MaxPort: 22}}
+	assert.NoError(t, updateFirewall(ipt, "eth0", acls, false))
+	assert.Equal(t, []string{"-i eth0 -j QUILT-ACL", "-i eth0 -j DROP",
+		"-j ACCEPT"}, ipt.chains["filter INPUT"])
+	assert.Equal(t, []string{"-i eth0 -j QUILT-ACL", "-i eth0 -j DROP",
+		"-j DOCKER-USER", "-j ACCEPT"}, ipt.chains["filter FORWARD"])
+	assert.Equal(t, aclRules(acls, false), ipt.chains["filter "+aclChain])
+
+	// Updates are idempotent, and the jumps stay in front of rules that are
+	// inserted by others.
+	ipt.chains["filter FORWARD"] = append([]string{"-j DOCKER-USER"},
+		ipt.chains["filter FORWARD"]...)
+	assert.NoError(t, updateFirewall(ipt, "eth0", acls, false))
+	assert.NoError(t, updateFirewall(ipt, "eth0", acls, false))
+	assert.Equal(t, []string{"-i eth0 -j QUILT-ACL", "-i eth0 -j DROP",
+		"-j DOCKER-USER", "-j DOCKER-USER", "-j ACCEPT"},
+		ipt.chains["filter FORWARD"])
+
+	acls = append(acls, acl.ACL{CidrIP: "0.0.0.0/0", MinPort: 80, MaxPort: 80})
+	assert.NoError(t, updateFirewall(ipt, "eth0", acls, false))
+	exp, actual := aclRules(acls, false), ipt.chains["filter "+aclChain]
+	sort.Strings(exp)
+	sort.Strings(actual)
+	assert.Equal(t, exp, actual)
+
+	// Removing the ACLs stops the filtering.
+	assert.NoError(t, updateFirewall(ipt, "eth0", nil, false))
+	assert.Equal(t, []string{"-j ACCEPT"}, ipt.chains["filter INPUT"])
+	assert.Equal(t, []string{"-j DOCKER-USER", "-j DOCKER-USER", "-j ACCEPT"},
+		ipt.chains["filter FORWARD"])
+
+	// IPv6 traffic is dropped if only IPv4 traffic is allowed.
+	ip6t := newFakeIPTables()
+	assert.NoError(t, updateFirewall(ip6t, "eth0", acls, true))
+	assert.Equal(t, []string{"-i eth0 -j QUILT-ACL", "-i eth0 -j DROP"},
+		ip6t.chains["filter INPUT"])
+	assert.Equal(t, aclRules(nil, true), ip6t.chains["filter "+aclChain])
+
+	ipt.listError = true
+	assert.Error(t, updateFirewall(ipt, "eth0", acls, false))
+}
+
+type fakeIPTables struct {
+	chains    map[string][]string
+	listError bool
+}
+
+func newFakeIPTables() *fakeIPTables {
+	return &fakeIPTables{chains: map[string][]string{}}
+}
+
+func (ipt *fakeIPTables) Append(table, chain string, rule ...string) error {
+	key := table + " " + chain
+	ipt.chains[key] = append(ipt.chains[key], strings.Join(rule, " "))
+	return nil
+}
+
+func (ipt *fakeIPTables) AppendUnique(table, chain string, rule ...string) error {
+	for _, r := range ipt.chains[table+" "+chain] {
+		if r == strings.Join(rule, " ") {
+			return nil
+		}
+	}
+	return ipt.Append(table, chain, rule...)
+}
+
+func (ipt *fakeIPTables) Insert(table, chain string, pos int, rule ...string) error {
+	key := table + " " + chain
+	rules := append([]string{}, ipt.chains[key][:pos-1]...)
+	rules = append(rules, strings.Join(rule, " "))
+	ipt.chains[key] = append(rules, ipt.chains[key][pos-1:]...)
+	return nil
+}
+
+func (ipt *fakeIPTables) Delete(table, chain string, rule ...string) error {
+	key := table + " " + chain
+	for i, r := range ipt.chains[key] {
+		if r == strings.Join(rule, " ") {
+			ipt.chains[key] = append(ipt.chains[key][:i],
+				ipt.chains[key][i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("no such rule")
+}
+
+func (ipt *fakeIPTables) List(table, chain string) ([]string, error) {
+	if ipt.listError {
+		return nil, errors.New("list error")
+	}
+
+	rules := []string{"-N " + chain}
+	for _, r := range ipt.chains[table+" "+chain] {
+		rules = append(rules, fmt.Sprintf("-A %s %s", chain, r))
+	}
+	return rules, nil
+}
+
+func (ipt *fakeIPTables) ListChains(table string) ([]string, error) {
+	if ipt.listError {
+		return nil, errors.New("list error")
+	}
+
+	var chains []string
+	for key := range ipt.chains {
+		if strings.HasPrefix(key, table+" ") {
+			chains = append(chains, strings.TrimPrefix(key, table+" "))
+		}
+	}
+	return chains, nil
+}
+
+func (ipt *fakeIPTables) NewChain(table, chain string) error {
+	ipt.chains[table+" "+chain] = nil
+	return nil
+}
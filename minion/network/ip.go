@@ -24,24 +24,41 @@ the host might get routed to the wrong interface.
 Note that the proper fix to this problem is to separate the Quilt networking
 stack from the host network. */
 func runUpdateIPs(conn db.Conn) {
+	allocs := readAllocations()
 	for range conn.Trigger(db.ContainerTable, db.LoadBalancerTable, db.EtcdTable,
 		db.MinionTable).C {
 		if !conn.EtcdLeader() {
 			continue
 		}
 
+		var changed bool
 		err := conn.Txn(db.ContainerTable, db.LoadBalancerTable,
-			db.MinionTable).Run(updateIPsOnce)
+			db.MinionTable).Run(func(view db.Database) error {
+			err := updateIPsOnce(view, allocs)
+			changed = allocs.update(view)
+			return err
+		})
 		if err != nil {
 			log.WithError(err).Warn("Failed to allocate IP addresses")
 		}
+
+		if changed {
+			if err := allocs.write(); err != nil {
+				log.WithError(err).Warn("Failed to record IP allocations")
+			}
+		}
 	}
 }
 
 // ipContext describes what addresses have been allocated, and what entities
 // require new IP addresses.
 type ipContext struct {
-	reserved map[string]struct{}
+	reserved        map[string]struct{}
+	subnetBlacklist []net.IPNet
+
+	// The IPs previously allocated to the entities, which are reused if
+	// they're still available.
+	previous ipAllocations
 
 	unassignedContainers    []db.Container
 	unassignedLoadBalancers []db.LoadBalancer
@@ -49,6 +66,7 @@ type ipContext struct {
 
 func makeIPContext(view db.Database, subnetBlacklist []net.IPNet) ipContext {
 	ctx := ipContext{
+		subnetBlacklist: subnetBlacklist,
 		reserved: map[string]struct{}{
 			ipdef.GatewayIP.String():      {},
 			ipdef.LoadBalancerIP.String(): {},
@@ -87,7 +105,7 @@ func makeIPContext(view db.Database, subnetBlacklist []net.IPNet) ipContext {
 	return ctx
 }
 
-func updateIPsOnce(view db.Database) error {
+func updateIPsOnce(view db.Database, previous ipAllocations) error {
 	subnetBlacklist, err := makeSubnetBlacklist(view)
 	if err != nil {
 		return fmt.Errorf("make subnet blacklist: %s", err)
@@ -97,6 +115,7 @@ func updateIPsOnce(view db.Database) error {
 	// an IP that falls within a blacklisted subnet.
 	for i := 0; i < 3; i++ {
 		ctx := makeIPContext(view, subnetBlacklist)
+		ctx.previous = previous
 		if len(ctx.unassignedContainers) == 0 &&
 			len(ctx.unassignedLoadBalancers) == 0 {
 			return nil
@@ -154,10 +173,14 @@ func ipBlacklisted(ip string, subnetBlacklist []net.IPNet) bool {
 
 func allocateContainerIPs(view db.Database, ctx ipContext) error {
 	for _, dbc := range ctx.unassignedContainers {
-		c.Inc("Allocate Container IP")
-		ip, err := allocateIP(ctx.reserved, ipdef.QuiltSubnet)
-		if err != nil {
-			return err
+		ip := ctx.reuse(ctx.previous.Containers[dbc.BlueprintID])
+		if ip == "" {
+			c.Inc("Allocate Container IP")
+			var err error
+			ip, err = allocateIP(ctx.reserved, ipdef.QuiltSubnet)
+			if err != nil {
+				return err
+			}
 		}
 
 		dbc.IP = ip
@@ -169,10 +192,14 @@ func allocateContainerIPs(view db.Database, ctx ipContext) error {
 
 func allocateLoadBalancerIPs(view db.Database, ctx ipContext) error {
 	for _, lb := range ctx.unassignedLoadBalancers {
-		c.Inc("Allocate LoadBalancer IP")
-		ip, err := allocateIP(ctx.reserved, ipdef.QuiltSubnet)
-		if err != nil {
-			return err
+		ip := ctx.reuse(ctx.previous.LoadBalancers[lb.Name])
+		if ip == "" {
+			c.Inc("Allocate LoadBalancer IP")
+			var err error
+			ip, err = allocateIP(ctx.reserved, ipdef.QuiltSubnet)
+			if err != nil {
+				return err
+			}
 		}
 
 		lb.IP = ip
@@ -182,6 +209,22 @@ func allocateLoadBalancerIPs(view db.Database, ctx ipContext) error {
 	return nil
 }
 
+// reuse reserves and returns `ip`, a previous allocation, if it's still
+// available.  Otherwise, it returns the empty string.
+func (ctx ipContext) reuse(ip string) string {
+	if ip == "" || ipBlacklisted(ip, ctx.subnetBlacklist) {
+		return ""
+	}
+
+	if _, ok := ctx.reserved[ip]; ok {
+		return ""
+	}
+
+	c.Inc("Reuse IP")
+	ctx.reserved[ip] = struct{}{}
+	return ip
+}
+
 func allocateIP(ipSet map[string]struct{}, subnet net.IPNet) (string, error) {
 	prefix := binary.BigEndian.Uint32(subnet.IP.To4())
 	mask := binary.BigEndian.Uint32(subnet.Mask)
@@ -238,3 +238,55 @@ func TestAllocate(t *testing.T) {
 		t.Errorf("Too few conflicts: %d", len(conflicts))
 	}
 }
+
+func TestUpdateIPsReusesAllocations(t *testing.T) {
+	t.Parallel()
+
+	previous := ipAllocations{
+		Containers: map[string]string{
+			"reused":      "10.0.0.5",
+			"taken":       "10.0.0.6",
+			"blacklisted": "10.0.1.1",
+		},
+		LoadBalancers: map[string]string{"lb": "10.0.0.7"},
+	}
+
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Role = db.Worker
+		m.HostSubnets = []string{"10.0.1.0/24"}
+		view.Commit(m)
+
+		for _, id := range []string{"reused", "taken", "blacklisted"} {
+			dbc := view.InsertContainer()
+			dbc.BlueprintID = id
+			view.Commit(dbc)
+		}
+
+		dbc := view.InsertContainer()
+		dbc.BlueprintID = "other"
+		dbc.IP = "10.0.0.6"
+		view.Commit(dbc)
+
+		lb := view.InsertLoadBalancer()
+		lb.Name = "lb"
+		view.Commit(lb)
+
+		assert.NoError(t, updateIPsOnce(view, previous))
+		return nil
+	})
+
+	ips := map[string]string{}
+	for _, dbc := range conn.SelectFromContainer(nil) {
+		ips[dbc.BlueprintID] = dbc.IP
+	}
+	assert.Equal(t, "10.0.0.5", ips["reused"])
+	assert.Equal(t, "10.0.0.6", ips["other"])
+	assert.NotEqual(t, "10.0.0.6", ips["taken"])
+	assert.NotEqual(t, "10.0.1.1", ips["blacklisted"])
+	assert.NotEmpty(t, ips["taken"])
+	assert.NotEmpty(t, ips["blacklisted"])
+
+	assert.Equal(t, "10.0.0.7", conn.SelectFromLoadBalancer(nil)[0].IP)
+}
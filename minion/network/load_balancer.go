@@ -1,6 +1,7 @@
 package network
 
 import (
+	"math"
 	"sort"
 	"strings"
 
@@ -13,6 +14,10 @@ import (
 	"github.com/kelda/kelda/util"
 )
 
+// The number of buckets that canary load balancers divide their traffic between,
+// so CanaryFraction is rounded to the nearest percent.
+const canaryResolution = 100
+
 /*
 Load balancing works by rewriting load balanced IPs using the Load_Balancer
 table in the Logical_Switch and routing load balanced requests to the load balancer
@@ -51,6 +56,41 @@ func updateLoadBalancers(client ovsdb.Client, loadBalancers []db.LoadBalancer,
 	updateLoadBalancerARP(client, loadBalancers)
 }
 
+// liveHostnames returns `hostnameToIP` without the hostnames of the containers
+// that workers report as unhealthy, or that are stopped, so that load balancers
+// stop sending them traffic.  Stopped containers keep their hostname, and thus
+// their DNS record, so that they resume with the same identity.
+func liveHostnames(hostnameToIP map[string]string, minions []db.Minion,
+	containers []db.Container) map[string]string {
+
+	unhealthy := map[string]struct{}{}
+	for _, m := range minions {
+		for _, ip := range m.UnhealthyContainers {
+			unhealthy[ip] = struct{}{}
+		}
+	}
+
+	stopped := map[string]struct{}{}
+	for _, dbc := range containers {
+		if dbc.Stopped {
+			stopped[dbc.IP] = struct{}{}
+		}
+	}
+
+	live := map[string]string{}
+	for hostname, ip := range hostnameToIP {
+		if _, ok := unhealthy[ip]; ok {
+			c.Inc("Unhealthy Load Balancer Member")
+			continue
+		}
+		if _, ok := stopped[ip]; ok {
+			continue
+		}
+		live[hostname] = ip
+	}
+	return live
+}
+
 func updateLoadBalancerIPs(client ovsdb.Client, loadBalancers []db.LoadBalancer,
 	hostnameToIP map[string]string) {
 	curr, err := client.ListLoadBalancers()
@@ -61,20 +101,11 @@ func updateLoadBalancerIPs(client ovsdb.Client, loadBalancers []db.LoadBalancer,
 
 	var target []ovsdb.LoadBalancer
 	for _, lb := range loadBalancers {
-		var ips []string
-		for _, hostname := range lb.Hostnames {
-			ip := hostnameToIP[hostname]
-			if ip != "" {
-				ips = append(ips, ip)
-			}
-		}
-		// Ignore the ip order.
-		sort.Strings(ips)
-
 		target = append(target, ovsdb.LoadBalancer{
 			Name: lb.Name,
 			VIPs: map[string]string{
-				lb.IP: strings.Join(ips, ","),
+				lb.IP: strings.Join(loadBalancerBackends(lb, hostnameToIP),
+					","),
 			},
 		})
 	}
@@ -109,6 +140,57 @@ func updateLoadBalancerIPs(client ovsdb.Client, loadBalancers []db.LoadBalancer,
 	}
 }
 
+// loadBalancerBackends returns the IPs that traffic to `lb` is sent to.  OVN
+// spreads connections evenly across the backends of a VIP, each of which becomes
+// a bucket of an OpenFlow select group, so canaries are weighted by repeating
+// backends.  The backends are divided into multiples of `canaryResolution`
+// buckets, of which the canaries get CanaryFraction.
+func loadBalancerBackends(lb db.LoadBalancer,
+	hostnameToIP map[string]string) []string {
+
+	stable := hostnameIPs(lb.Hostnames, hostnameToIP)
+	canary := hostnameIPs(lb.CanaryHostnames, hostnameToIP)
+
+	canaryBuckets := int(math.Floor(lb.CanaryFraction*canaryResolution + 0.5))
+	stableBuckets := canaryResolution - canaryBuckets
+	switch {
+	case len(canary) == 0 || canaryBuckets <= 0:
+		return stable
+	case len(stable) == 0 || stableBuckets <= 0:
+		return canary
+	}
+
+	// Scale up the buckets until every backend gets at least one.
+	scale := 1
+	for canaryBuckets*scale < len(canary) || stableBuckets*scale < len(stable) {
+		scale++
+	}
+
+	var backends []string
+	for i := 0; i < canaryBuckets*scale; i++ {
+		backends = append(backends, canary[i%len(canary)])
+	}
+	for i := 0; i < stableBuckets*scale; i++ {
+		backends = append(backends, stable[i%len(stable)])
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+// hostnameIPs returns the sorted IPs of the hostnames that have one.
+func hostnameIPs(hostnames []string, hostnameToIP map[string]string) []string {
+	var ips []string
+	for _, hostname := range hostnames {
+		ip := hostnameToIP[hostname]
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	// Ignore the ip order.
+	sort.Strings(ips)
+	return ips
+}
+
 // updateLoadBalancerARP updates the `addresses` field of the logical switch
 // port attached to the load balancer router. This is necessary so that the
 // switch port synthesizes ARP responses to load balanced VIPs.
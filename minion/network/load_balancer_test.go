@@ -55,6 +55,83 @@ func TestUpdateLoadBalancerIPs(t *testing.T) {
 	client.AssertExpectations(t)
 }
 
+func TestLiveHostnames(t *testing.T) {
+	t.Parallel()
+
+	hostnameToIP := map[string]string{
+		"red":    "10.0.0.2",
+		"blue":   "10.0.0.3",
+		"yellow": "10.0.0.4",
+	}
+	minions := []db.Minion{
+		{PrivateIP: "1", UnhealthyContainers: []string{"10.0.0.2"}},
+		{PrivateIP: "2", UnhealthyContainers: []string{"10.0.0.4"}},
+		{PrivateIP: "3"},
+	}
+
+	assert.Equal(t, map[string]string{"blue": "10.0.0.3"},
+		liveHostnames(hostnameToIP, minions, nil))
+	assert.Equal(t, hostnameToIP, liveHostnames(hostnameToIP, nil, nil))
+
+	// Stopped containers lose their place in load balancers, but not their
+	// hostname.
+	containers := []db.Container{
+		{IP: "10.0.0.2"},
+		{IP: "10.0.0.3", Stopped: true},
+	}
+	assert.Equal(t, map[string]string{"red": "10.0.0.2", "yellow": "10.0.0.4"},
+		liveHostnames(hostnameToIP, nil, containers))
+	assert.Equal(t, map[string]string{"yellow": "10.0.0.4"},
+		liveHostnames(hostnameToIP, minions[:1], containers))
+}
+
+func TestLoadBalancerBackends(t *testing.T) {
+	t.Parallel()
+
+	hostnameToIP := map[string]string{
+		"stable1": "10.0.0.2",
+		"stable2": "10.0.0.3",
+		"canary":  "10.0.0.4",
+	}
+	count := func(backends []string) map[string]int {
+		counts := map[string]int{}
+		for _, ip := range backends {
+			counts[ip]++
+		}
+		return counts
+	}
+
+	lb := db.LoadBalancer{
+		Hostnames:       []string{"stable1", "stable2", "missing"},
+		CanaryHostnames: []string{"canary"},
+	}
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"},
+		loadBalancerBackends(lb, hostnameToIP))
+
+	lb.CanaryFraction = 0.05
+	assert.Equal(t, map[string]int{"10.0.0.2": 48, "10.0.0.3": 47, "10.0.0.4": 5},
+		count(loadBalancerBackends(lb, hostnameToIP)))
+
+	lb.CanaryFraction = 1
+	assert.Equal(t, []string{"10.0.0.4"}, loadBalancerBackends(lb, hostnameToIP))
+
+	// The buckets are scaled up so that every backend gets traffic.
+	lb.CanaryFraction = 0.99
+	assert.Equal(t, map[string]int{"10.0.0.2": 1, "10.0.0.3": 1, "10.0.0.4": 198},
+		count(loadBalancerBackends(lb, hostnameToIP)))
+
+	// If none of the canaries have an IP, the stable containers get all traffic.
+	lb.CanaryHostnames = []string{"missing"}
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"},
+		loadBalancerBackends(lb, hostnameToIP))
+
+	// And vice versa.
+	lb.Hostnames = nil
+	lb.CanaryHostnames = []string{"canary"}
+	lb.CanaryFraction = 0.05
+	assert.Equal(t, []string{"10.0.0.4"}, loadBalancerBackends(lb, hostnameToIP))
+}
+
 func TestUpdateLoadBalancerARP(t *testing.T) {
 	client := new(mocks.Client)
 
@@ -72,6 +72,27 @@ func (_m *IPTables) Delete(_a0 string, _a1 string, _a2 ...string) error {
 	return r0
 }
 
+// Insert provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *IPTables) Insert(_a0 string, _a1 string, _a2 int, _a3 ...string) error {
+	_va := make([]interface{}, len(_a3))
+	for _i := range _a3 {
+		_va[_i] = _a3[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _a0, _a1, _a2)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, int, ...string) error); ok {
+		r0 = rf(_a0, _a1, _a2, _a3...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // List provides a mock function with given fields: _a0, _a1
 func (_m *IPTables) List(_a0 string, _a1 string) ([]string, error) {
 	ret := _m.Called(_a0, _a1)
@@ -94,3 +115,40 @@ func (_m *IPTables) List(_a0 string, _a1 string) ([]string, error) {
 
 	return r0, r1
 }
+
+// ListChains provides a mock function with given fields: _a0
+func (_m *IPTables) ListChains(_a0 string) ([]string, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string) []string); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewChain provides a mock function with given fields: _a0, _a1
+func (_m *IPTables) NewChain(_a0 string, _a1 string) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
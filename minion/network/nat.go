@@ -10,6 +10,7 @@ import (
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/minion/ipdef"
 	"github.com/kelda/kelda/minion/nl"
 	"github.com/kelda/kelda/util"
 
@@ -21,12 +22,24 @@ import (
 type IPTables interface {
 	Append(string, string, ...string) error
 	AppendUnique(string, string, ...string) error
+	Insert(string, string, int, ...string) error
 	Delete(string, string, ...string) error
 	List(string, string) ([]string, error)
+	ListChains(string) ([]string, error)
+	NewChain(string, string) error
 }
 
 var iptC = counter.New("Network IP Tables")
 
+// The rule that drops the containers' traffic to the cloud's instance metadata
+// service, in the form that iptables lists it.  The service hands out the
+// credentials of the machine's instance profile, which may assume the cloud role
+// of any container, so containers are cut off from it while cloud roles are in
+// use.  It's in the raw table, which Quilt and Docker otherwise leave alone, so
+// that it's dropped before any other rule accepts it.
+var metadataRule = fmt.Sprintf("-s %s -d 169.254.169.254/32 -j DROP",
+	ipdef.QuiltSubnet.String())
+
 func runNat(conn db.Conn, inboundPubIntf, outboundPubIntf string) {
 	tables := []db.TableType{db.ContainerTable, db.ConnectionTable, db.MinionTable}
 	for range conn.TriggerTick(30, tables...).C {
@@ -86,6 +99,8 @@ func pickIntfs(inboundPubIntf, outboundPubIntf string) (string, string, error) {
 // containers. They overwrite any pre-existing or outdated rules.
 // "postrouting rules" are responsible for routing traffic from containers
 // to the public internet. They overwrite any pre-existing or outdated rules.
+// Traffic from containers to the instance metadata service is also dropped if any
+// container has a cloud role.
 func updateNAT(ipt IPTables, containers []db.Container,
 	connections []db.Connection, inboundPubIntf, outboundPubIntf string) (err error) {
 
@@ -104,7 +119,54 @@ func updateNAT(ipt IPTables, containers []db.Container,
 	}
 
 	postrouting := postroutingRules(outboundPubIntf, containers, connections)
-	return syncChain(ipt, "nat", "POSTROUTING", postrouting)
+	if err := syncChain(ipt, "nat", "POSTROUTING", postrouting); err != nil {
+		return err
+	}
+
+	return syncMetadataRule(ipt, containers)
+}
+
+// syncMetadataRule installs the metadataRule if any of `containers` has a cloud
+// role, and removes it otherwise, so that containers may use the machine's
+// instance profile when no container has a role of its own.
+func syncMetadataRule(ipt IPTables, containers []db.Container) error {
+	block := false
+	for _, dbc := range containers {
+		if dbc.CloudRole != "" {
+			block = true
+			break
+		}
+	}
+
+	curr, err := getRules(ipt, "raw", "PREROUTING")
+	if err != nil {
+		return fmt.Errorf("iptables get: %s", err)
+	}
+
+	installed := false
+	for _, rule := range curr {
+		if rule == metadataRule {
+			installed = true
+			break
+		}
+	}
+
+	switch {
+	case block && !installed:
+		iptC.Inc("Insert")
+		err = ipt.Insert("raw", "PREROUTING", 1,
+			strings.Split(metadataRule, " ")...)
+		if err != nil {
+			return fmt.Errorf("iptables insert: %s", err)
+		}
+	case !block && installed:
+		iptC.Inc("Delete")
+		err = ipt.Delete("raw", "PREROUTING", strings.Split(metadataRule, " ")...)
+		if err != nil {
+			return fmt.Errorf("iptables delete: %s", err)
+		}
+	}
+	return nil
 }
 
 var flagRegex = regexp.MustCompile(`-{1,2}(\S+) (\S+)(.*)`)
@@ -50,6 +50,41 @@ func TestUpdateNATErrors(t *testing.T) {
 	assert.NotNil(t, updateNAT(ipt, nil, nil, "", ""))
 }
 
+func TestSyncMetadataRule(t *testing.T) {
+	roles := []db.Container{{IP: "10.0.0.2"}, {IP: "10.0.0.3",
+		CloudRole: "arn:aws:iam::123456789012:role/uploader"}}
+	rule := []string{"-s", "10.0.0.0/8", "-d", "169.254.169.254/32", "-j", "DROP"}
+	listed := []string{"-P PREROUTING ACCEPT",
+		"-A PREROUTING -s 10.0.0.0/8 -d 169.254.169.254/32 -j DROP"}
+
+	// Containers are cut off from the metadata service while any container
+	// has a cloud role.
+	ipt := &mocks.IPTables{}
+	ipt.On("List", "raw", "PREROUTING").Return(nil, nil)
+	ipt.On("Insert", "raw", "PREROUTING", 1, rule[0], rule[1], rule[2],
+		rule[3], rule[4], rule[5]).Return(nil).Once()
+	assert.NoError(t, syncMetadataRule(ipt, roles))
+	ipt.AssertExpectations(t)
+
+	ipt = &mocks.IPTables{}
+	ipt.On("List", "raw", "PREROUTING").Return(listed, nil)
+	assert.NoError(t, syncMetadataRule(ipt, roles))
+	ipt.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+
+	// Otherwise they may use the machine's instance profile.
+	ipt = &mocks.IPTables{}
+	ipt.On("List", "raw", "PREROUTING").Return(listed, nil)
+	ipt.On("Delete", "raw", "PREROUTING", rule[0], rule[1], rule[2], rule[3],
+		rule[4], rule[5]).Return(nil).Once()
+	assert.NoError(t, syncMetadataRule(ipt, roles[:1]))
+	ipt.AssertExpectations(t)
+
+	ipt = &mocks.IPTables{}
+	ipt.On("List", "raw", "PREROUTING").Return(nil, errors.New("err"))
+	assert.EqualError(t, syncMetadataRule(ipt, roles), "iptables get: err")
+}
+
 func TestPreroutingRules(t *testing.T) {
 	t.Parallel()
 
@@ -27,11 +27,13 @@ var c = counter.New("Network")
 // Run blocks implementing the network services.
 func Run(conn db.Conn, inboundPubIntf, outboundPubIntf string) {
 	go runNat(conn, inboundPubIntf, outboundPubIntf)
+	go runFirewall(conn, inboundPubIntf)
 	go runDNS(conn)
 	go runUpdateIPs(conn)
 
 	for range conn.TriggerTick(30, db.ContainerTable, db.HostnameTable,
-		db.ConnectionTable, db.LoadBalancerTable, db.EtcdTable).C {
+		db.ConnectionTable, db.LoadBalancerTable, db.EtcdTable,
+		db.MinionTable).C {
 		if conn.EtcdLeader() {
 			runMaster(conn)
 		}
@@ -49,9 +51,11 @@ func runMaster(conn db.Conn) {
 	var loadBalancers []db.LoadBalancer
 	var containers []db.Container
 	var connections []db.Connection
+	var minions []db.Minion
 	var hostnameToIP map[string]string
 	conn.Txn(db.ConnectionTable, db.ContainerTable, db.EtcdTable,
-		db.LoadBalancerTable, db.HostnameTable).Run(func(view db.Database) error {
+		db.LoadBalancerTable, db.HostnameTable,
+		db.MinionTable).Run(func(view db.Database) error {
 
 		loadBalancers = view.SelectFromLoadBalancer(
 			func(lb db.LoadBalancer) bool {
@@ -64,6 +68,7 @@ func runMaster(conn db.Conn) {
 
 		connections = view.SelectFromConnection(nil)
 		hostnameToIP = view.GetHostnameMappings()
+		minions = view.SelectFromMinion(nil)
 		return nil
 	})
 
@@ -76,7 +81,8 @@ func runMaster(conn db.Conn) {
 
 	updateLogicalSwitch(ovsdbClient, containers)
 	updateLoadBalancerRouter(ovsdbClient)
-	updateLoadBalancers(ovsdbClient, loadBalancers, hostnameToIP)
+	updateLoadBalancers(ovsdbClient, loadBalancers,
+		liveHostnames(hostnameToIP, minions, containers))
 	updateACLs(ovsdbClient, connections, hostnameToIP)
 }
 
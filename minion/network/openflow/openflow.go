@@ -51,6 +51,13 @@ Table_0 { // Initial Table
 			goto Table_1
 		}
 
+		// IP packets from OVN are tracked so that Table_4 can tell new
+		// connections from established ones.
+		if ip && in_port=dbc.PatchPort {
+			ct(zone=conntrackZone)
+			goto Table_4
+		}
+
 		if in_port=dbc.PatchPort {
 			output:dbc.VethPort
 		}
@@ -73,7 +80,12 @@ Table_1 {
 		goto Table_3
 	}
 
-	// Everything else can be handled by OVN.
+	// Everything else can be handled by OVN.  IP connections are committed to
+	// conntrack so that their replies are recognized as established.
+	if ip {
+		ct(commit,zone=conntrackZone)
+		output:reg0
+	}
 	output:reg0
 }
 
@@ -138,6 +150,24 @@ Table_3 {
 	}
 }
 
+// Table_4 forwards tracked IP packets coming from OVN.
+Table_4 {
+	for each db.Container {
+		// Packets in established connections are always delivered, so
+		// that draining containers can finish the connections they have.
+		if in_port=dbc.PatchPort && (ct_state=+est || ct_state=+rel) {
+			output:dbc.VethPort
+		}
+
+		// Containers draining from a load balancer refuse new connections,
+		// so packets that reach this point are dropped.
+		if in_port=dbc.PatchPort && !dbc.Draining {
+			ct(commit,zone=conntrackZone)
+			output:dbc.VethPort
+		}
+	}
+}
+
 */
 
 // A Container that needs OpenFlow rules installed for it.
@@ -150,6 +180,10 @@ type Container struct {
 	// Set of ports going to and from the public internet.
 	ToPub   map[int]struct{}
 	FromPub map[int]struct{}
+
+	// Whether the container is draining from a load balancer, and thus only
+	// accepts packets in its established connections.
+	Draining bool
 }
 
 type container struct {
@@ -159,6 +193,11 @@ type container struct {
 	patchPort int
 }
 
+// conntrackZone is the conntrack zone in which connections to and from containers
+// are tracked.  OVN allocates its zones counting up from 1, so the top of the
+// range is left to us.
+const conntrackZone = 65535
+
 var c = counter.New("OpenFlow")
 
 var staticFlows = []string{
@@ -170,6 +209,8 @@ var staticFlows = []string{
 		"actions=output:LOCAL,output:NXM_NX_REG0[]",
 	fmt.Sprintf("table=1,priority=900,dl_dst=%s,actions=resubmit(,3)",
 		ipdef.GatewayMac),
+	fmt.Sprintf("table=1,priority=850,ip,actions=ct(commit,zone=%d),"+
+		"output:NXM_NX_REG0[]", conntrackZone),
 	"table=1,priority=800,actions=output:NXM_NX_REG0[]",
 
 	// Table 3
@@ -234,7 +275,9 @@ func containerFlows(c container) []string {
 		fmt.Sprintf("table=0,in_port=%d,dl_src=%s,"+
 			"actions=load:0x%x->NXM_NX_REG0[],resubmit(,1)",
 			c.vethPort, c.Mac, c.patchPort),
-		fmt.Sprintf("table=0,in_port=%d,actions=output:%d",
+		fmt.Sprintf("table=0,priority=900,ip,in_port=%d,"+
+			"actions=ct(zone=%d,table=4)", c.patchPort, conntrackZone),
+		fmt.Sprintf("table=0,priority=800,in_port=%d,actions=output:%d",
 			c.patchPort, c.vethPort),
 
 		// Table 2
@@ -242,6 +285,18 @@ func containerFlows(c container) []string {
 			c.Mac, c.vethPort),
 		fmt.Sprintf("table=2,priority=800,ip,dl_dst=%s,nw_src=%s,"+
 			"action=output:%d", c.Mac, ipdef.GatewayIP, c.vethPort),
+
+		// Table 4
+		fmt.Sprintf("table=4,priority=1000,ip,in_port=%d,ct_state=+trk+est,"+
+			"actions=output:%d", c.patchPort, c.vethPort),
+		fmt.Sprintf("table=4,priority=1000,ip,in_port=%d,ct_state=+trk+rel,"+
+			"actions=output:%d", c.patchPort, c.vethPort),
+	}
+
+	if !c.Draining {
+		flows = append(flows, fmt.Sprintf("table=4,priority=900,ip,in_port=%d,"+
+			"actions=ct(commit,zone=%d),output:%d", c.patchPort,
+			conntrackZone, c.vethPort))
 	}
 
 	table2 := "table=2,priority=500,%s,dl_dst=%s,ip_dst=%s,tp_src=%d," +
@@ -80,16 +80,24 @@ func TestAllFlows(t *testing.T) {
 		patchPort: 9,
 		vethPort:  8,
 		Container: Container{
-			IP:      "9.8.7.6",
-			Mac:     "99:99:99:99:99:99",
-			FromPub: map[int]struct{}{8: {}}}}})
+			IP:       "9.8.7.6",
+			Mac:      "99:99:99:99:99:99",
+			FromPub:  map[int]struct{}{8: {}},
+			Draining: true}}})
 	exp := append(staticFlows,
 		"table=0,in_port=5,dl_src=66:66:66:66:66:66,"+
 			"actions=load:0x4->NXM_NX_REG0[],resubmit(,1)",
-		"table=0,in_port=4,actions=output:5",
+		"table=0,priority=900,ip,in_port=4,actions=ct(zone=65535,table=4)",
+		"table=0,priority=800,in_port=4,actions=output:5",
 		"table=2,priority=900,arp,dl_dst=66:66:66:66:66:66,action=output:5",
 		"table=2,priority=800,ip,dl_dst=66:66:66:66:66:66,nw_src=10.0.0.1,"+
 			"action=output:5",
+		"table=4,priority=1000,ip,in_port=4,ct_state=+trk+est,"+
+			"actions=output:5",
+		"table=4,priority=1000,ip,in_port=4,ct_state=+trk+rel,"+
+			"actions=output:5",
+		"table=4,priority=900,ip,in_port=4,"+
+			"actions=ct(commit,zone=65535),output:5",
 		"table=2,priority=500,tcp,dl_dst=66:66:66:66:66:66,ip_dst=6.7.8.9,"+
 			"tp_src=5,actions=output:5",
 		"table=2,priority=500,udp,dl_dst=66:66:66:66:66:66,ip_dst=6.7.8.9,"+
@@ -100,10 +108,15 @@ func TestAllFlows(t *testing.T) {
 			"tp_dst=5,actions=output:LOCAL",
 		"table=0,in_port=8,dl_src=99:99:99:99:99:99,"+
 			"actions=load:0x9->NXM_NX_REG0[],resubmit(,1)",
-		"table=0,in_port=9,actions=output:8",
+		"table=0,priority=900,ip,in_port=9,actions=ct(zone=65535,table=4)",
+		"table=0,priority=800,in_port=9,actions=output:8",
 		"table=2,priority=900,arp,dl_dst=99:99:99:99:99:99,action=output:8",
 		"table=2,priority=800,ip,dl_dst=99:99:99:99:99:99,nw_src=10.0.0.1,"+
 			"action=output:8",
+		"table=4,priority=1000,ip,in_port=9,ct_state=+trk+est,"+
+			"actions=output:8",
+		"table=4,priority=1000,ip,in_port=9,ct_state=+trk+rel,"+
+			"actions=output:8",
 		"table=2,priority=500,tcp,dl_dst=99:99:99:99:99:99,ip_dst=9.8.7.6,"+
 			"tp_dst=8,actions=output:8",
 		"table=2,priority=500,udp,dl_dst=99:99:99:99:99:99,ip_dst=9.8.7.6,"+
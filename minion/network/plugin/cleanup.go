@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/kelda/kelda/minion/ipdef"
+	"github.com/kelda/kelda/minion/nl"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Docker endpoint IDs are hex strings, so the host side of each endpoint's veth is
+// named with the ID truncated to IFNAMSIZ - 1 characters.
+var endpointLinkRegex = regexp.MustCompile("^[0-9a-f]{15}$")
+
+// CleanupEndpoints removes the veths and OpenVSwitch ports of every endpoint not
+// in `live`.  If the minion or Docker crashes in the middle of creating or deleting
+// an endpoint, this state would otherwise be leaked forever.  The caller must
+// guarantee that no endpoints are created concurrently.
+func CleanupEndpoints(live []string) {
+	links, err := nl.N.LinkList()
+	if err != nil {
+		c.Inc("Cleanup Error")
+		log.WithError(err).Warn("Failed to list links")
+		return
+	}
+
+	liveLinks := map[string]struct{}{}
+	for _, eid := range live {
+		liveLinks[ipdef.IFName(eid)] = struct{}{}
+	}
+
+	for _, link := range links {
+		name := link.Attrs().Name
+		if link.Type() != "veth" || !endpointLinkRegex.MatchString(name) {
+			continue
+		}
+
+		if _, ok := liveLinks[name]; ok {
+			continue
+		}
+
+		log.WithField("link", name).Info("Cleaning up stale endpoint")
+		if err := cleanupEndpoint(link); err != nil {
+			c.Inc("Cleanup Error")
+			log.WithError(err).WithField("link", name).Warn(
+				"Failed to clean up stale endpoint")
+			continue
+		}
+		c.Inc("Cleanup Stale Endpoint")
+	}
+}
+
+// Endpoints returns the names of the host side veths of the endpoints on this
+// machine, whether or not they're live.
+func Endpoints() ([]string, error) {
+	links, err := nl.N.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %s", err)
+	}
+
+	var names []string
+	for _, link := range links {
+		name := link.Attrs().Name
+		if link.Type() == "veth" && endpointLinkRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func cleanupEndpoint(link nl.Link) error {
+	// The link name is a prefix of the endpoint ID that's long enough to derive
+	// the patch port names from.
+	name := link.Attrs().Name
+	peerBr, peerQuilt := ipdef.PatchPorts(name)
+	err := vsctl([][]string{
+		{"--if-exists", "del-port", ipdef.QuiltBridge, name},
+		{"--if-exists", "del-port", ipdef.QuiltBridge, peerQuilt},
+		{"--if-exists", "del-port", ipdef.OvnBridge, peerBr}})
+	if err != nil {
+		return fmt.Errorf("ovs-vsctl: %v", err)
+	}
+
+	if err := nl.N.LinkDel(link); err != nil {
+		return fmt.Errorf("failed to delete link %s: %s", name, err)
+	}
+	return nil
+}
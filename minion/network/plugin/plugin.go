@@ -72,11 +72,21 @@ func (d driver) GetCapabilities() (*dnet.CapabilitiesResponse, error) {
 	return &dnet.CapabilitiesResponse{Scope: dnet.LocalScope}, nil
 }
 
-// CreateEndpoint acknowledges the request, but does not actually do anything.
+// CreateEndpoint creates a veth for the endpoint, and attaches it to OpenVSwitch.
 func (d driver) CreateEndpoint(req *dnet.CreateEndpointRequest) (
 	*dnet.CreateEndpointResponse, error) {
 	c.Inc("Create Endpoint")
 
+	resp, err := createEndpoint(req)
+	if err != nil {
+		c.Inc("Create Endpoint Error")
+	}
+	return resp, err
+}
+
+func createEndpoint(req *dnet.CreateEndpointRequest) (
+	*dnet.CreateEndpointResponse, error) {
+
 	addr, _, err := net.ParseCIDR(req.Interface.Address)
 	if err != nil {
 		return nil, fmt.Errorf("invalid IP: %s", req.Interface.Address)
@@ -142,6 +152,15 @@ func (d driver) EndpointInfo(req *dnet.InfoRequest) (*dnet.InfoResponse, error)
 // DeleteEndpoint cleans up state associated with a docker endpoint.
 func (d driver) DeleteEndpoint(req *dnet.DeleteEndpointRequest) error {
 	c.Inc("Delete Endpoint")
+
+	err := deleteEndpoint(req)
+	if err != nil {
+		c.Inc("Delete Endpoint Error")
+	}
+	return err
+}
+
+func deleteEndpoint(req *dnet.DeleteEndpointRequest) error {
 	peerBr, peerQuilt := ipdef.PatchPorts(req.EndpointID)
 	err := vsctl([][]string{
 		{"del-port", ipdef.QuiltBridge, ipdef.IFName(req.EndpointID)},
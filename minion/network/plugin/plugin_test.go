@@ -188,3 +188,59 @@ func TestLeave(t *testing.T) {
 	err = d.Leave(&dnet.LeaveRequest{EndpointID: zero})
 	assert.NoError(t, err)
 }
+
+func TestCleanupEndpoints(t *testing.T) {
+	mk := setup()
+
+	var args [][]string
+	vsctl = func(a [][]string) error {
+		args = append(args, a...)
+		return nil
+	}
+
+	live := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "000000000000000"}}
+	stale := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "111111111111111"}}
+	notVeth := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "222222222222222"}}
+	notEndpoint := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}}
+	mk.On("LinkList").Once().Return(
+		[]nl.Link{live, stale, notVeth, notEndpoint}, nil)
+	mk.On("LinkDel", stale).Once().Return(nil)
+
+	CleanupEndpoints([]string{zero})
+	mk.AssertExpectations(t)
+	assert.Equal(t, [][]string{
+		{"--if-exists", "del-port", "quilt-int", "111111111111111"},
+		{"--if-exists", "del-port", "quilt-int", "q_1111111111111"},
+		{"--if-exists", "del-port", "br-int", "br_111111111111"}}, args)
+
+	// Failures to clean up one endpoint shouldn't prevent cleaning up others.
+	args = nil
+	other := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "333333333333333"}}
+	mk.On("LinkList").Once().Return([]nl.Link{stale, other}, nil)
+	mk.On("LinkDel", stale).Once().Return(errors.New("err"))
+	mk.On("LinkDel", other).Once().Return(nil)
+	CleanupEndpoints(nil)
+	mk.AssertExpectations(t)
+	assert.Len(t, args, 6)
+
+	mk.On("LinkList").Once().Return(nil, errors.New("err"))
+	CleanupEndpoints(nil)
+	mk.AssertNumberOfCalls(t, "LinkList", 3)
+}
+
+func TestEndpoints(t *testing.T) {
+	mk := setup()
+
+	endpoint := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "000000000000000"}}
+	notVeth := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "222222222222222"}}
+	notEndpoint := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}}
+	mk.On("LinkList").Once().Return([]nl.Link{endpoint, notVeth, notEndpoint}, nil)
+
+	names, err := Endpoints()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"000000000000000"}, names)
+
+	mk.On("LinkList").Once().Return(nil, errors.New("err"))
+	_, err = Endpoints()
+	assert.EqualError(t, err, "failed to list links: err")
+}
@@ -16,6 +16,7 @@ type I interface {
 	LinkDel(link Link) error
 	LinkByName(name string) (Link, error)
 	LinkByIndex(index int) (Link, error)
+	LinkList() ([]Link, error)
 	AddrAdd(link Link, ip net.IPNet) error
 	RouteList(family int) ([]Route, error)
 }
@@ -60,6 +61,17 @@ func (n n) LinkByIndex(index int) (Link, error) {
 	return netlink.LinkByIndex(index)
 }
 
+func (n n) LinkList() ([]Link, error) {
+	c.Inc("List Links")
+	res, err := netlink.LinkList()
+
+	var links []Link
+	for _, l := range res {
+		links = append(links, Link(l))
+	}
+	return links, err
+}
+
 func (n n) AddrAdd(link Link, ip net.IPNet) error {
 	c.Inc("Add Address")
 	return netlink.AddrAdd(link, &netlink.Addr{IPNet: &ip})
@@ -99,6 +99,29 @@ func (_m *I) LinkDel(link nl.Link) error {
 	return r0
 }
 
+// LinkList provides a mock function with given fields:
+func (_m *I) LinkList() ([]nl.Link, error) {
+	ret := _m.Called()
+
+	var r0 []nl.Link
+	if rf, ok := ret.Get(0).(func() []nl.Link); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]nl.Link)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // LinkSetUp provides a mock function with given fields: link
 func (_m *I) LinkSetUp(link nl.Link) error {
 	ret := _m.Called(link)
@@ -66,6 +66,20 @@ func (_m *Client) CreateLogicalSwitch(lswitch string) error {
 	return r0
 }
 
+// CreateMirror provides a mock function with given fields: bridge, mirror
+func (_m *Client) CreateMirror(bridge string, mirror ovsdb.Mirror) error {
+	ret := _m.Called(bridge, mirror)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, ovsdb.Mirror) error); ok {
+		r0 = rf(bridge, mirror)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateRouterPort provides a mock function with given fields: lrouter, lport
 func (_m *Client) CreateRouterPort(lrouter string, lport ovsdb.RouterPort) error {
 	ret := _m.Called(lrouter, lport)
@@ -122,6 +136,20 @@ func (_m *Client) DeleteLoadBalancer(lswitch string, lb ovsdb.LoadBalancer) erro
 	return r0
 }
 
+// DeleteMirror provides a mock function with given fields: mirror
+func (_m *Client) DeleteMirror(mirror ovsdb.Mirror) error {
+	ret := _m.Called(mirror)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ovsdb.Mirror) error); ok {
+		r0 = rf(mirror)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteRouterPort provides a mock function with given fields: lrouter, lport
 func (_m *Client) DeleteRouterPort(lrouter string, lport ovsdb.RouterPort) error {
 	ret := _m.Called(lrouter, lport)
@@ -201,6 +229,29 @@ func (_m *Client) ListLoadBalancers() ([]ovsdb.LoadBalancer, error) {
 	return r0, r1
 }
 
+// ListMirrors provides a mock function with given fields:
+func (_m *Client) ListMirrors() ([]ovsdb.Mirror, error) {
+	ret := _m.Called()
+
+	var r0 []ovsdb.Mirror
+	if rf, ok := ret.Get(0).(func() []ovsdb.Mirror); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]ovsdb.Mirror)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListRouterPorts provides a mock function with given fields:
 func (_m *Client) ListRouterPorts() ([]ovsdb.RouterPort, error) {
 	ret := _m.Called()
@@ -43,6 +43,10 @@ type Client interface {
 
 	OpenFlowPorts() (map[string]int, error)
 
+	ListMirrors() ([]Mirror, error)
+	CreateMirror(bridge string, mirror Mirror) error
+	DeleteMirror(mirror Mirror) error
+
 	Disconnect()
 }
 
@@ -108,6 +112,16 @@ type LoadBalancer struct {
 	VIPs map[string]string
 }
 
+// Mirror copies the packets sent and received on Ports, which are ports of an Open
+// vSwitch bridge, to the Output port.
+type Mirror struct {
+	uuid        ovs.UUID
+	Name        string
+	Ports       []string
+	Output      string
+	ExternalIDs map[string]string
+}
+
 type row map[string]interface{}
 type mutation interface{}
 
@@ -554,6 +568,149 @@ func (ovsdb client) OpenFlowPorts() (map[string]int, error) {
 	return ifaceMap, nil
 }
 
+// ListMirrors lists the port mirrors in Open vSwitch.
+func (ovsdb client) ListMirrors() ([]Mirror, error) {
+	c.Inc("List Mirrors")
+	reply, err := ovsdb.Transact("Open_vSwitch",
+		ovs.Operation{Op: "select", Table: "Mirror", Where: noCondition},
+		ovs.Operation{Op: "select", Table: "Port", Where: noCondition})
+	if err != nil {
+		return nil, fmt.Errorf("transaction error: listing mirrors: %s", err)
+	}
+	if err := errorCheck(reply, 2); err != nil {
+		return nil, err
+	}
+
+	portNames := map[string]string{}
+	for _, row := range reply[1].Rows {
+		portNames[ovsUUIDFromRow(row).GoUUID], _ = row["name"].(string)
+	}
+
+	var result []Mirror
+	for _, row := range reply[0].Rows {
+		ports, err := ovsUUIDSetToSlice(row["select_src_port"])
+		if err != nil {
+			return nil, fmt.Errorf("malformed select_src_port: %s", err)
+		}
+
+		output, err := ovsUUIDSetToSlice(row["output_port"])
+		if err != nil {
+			return nil, fmt.Errorf("malformed output_port: %s", err)
+		}
+
+		externalIDs, err := ovsStringMapToMap(row["external_ids"])
+		if err != nil {
+			return nil, fmt.Errorf("malformed external_ids: %s", err)
+		}
+
+		mirror := Mirror{
+			uuid:        ovsUUIDFromRow(row),
+			Name:        row["name"].(string),
+			ExternalIDs: externalIDs,
+		}
+		for _, port := range ports {
+			mirror.Ports = append(mirror.Ports, portNames[port])
+		}
+		if len(output) > 0 {
+			mirror.Output = portNames[output[0]]
+		}
+		result = append(result, mirror)
+	}
+	return result, nil
+}
+
+// CreateMirror mirrors the traffic of `mirror.Ports` on `bridge` to `mirror.Output`.
+func (ovsdb client) CreateMirror(bridge string, mirror Mirror) error {
+	c.Inc("Create Mirror")
+
+	// Mirrors refer to their ports by UUID, so the ports are looked up first.
+	names := append([]string{mirror.Output}, mirror.Ports...)
+	var selectOps []ovs.Operation
+	for _, name := range names {
+		selectOps = append(selectOps, ovs.Operation{
+			Op:      "select",
+			Table:   "Port",
+			Where:   newCondition("name", "==", name),
+			Columns: []string{"_uuid"},
+		})
+	}
+
+	reply, err := ovsdb.Transact("Open_vSwitch", selectOps...)
+	if err != nil {
+		return fmt.Errorf("transaction error: selecting ports of mirror %s: %s",
+			mirror.Name, err)
+	}
+	if err := errorCheck(reply, len(selectOps)); err != nil {
+		return err
+	}
+
+	var uuids []ovs.UUID
+	for i, result := range reply {
+		if len(result.Rows) != 1 {
+			return fmt.Errorf("no port named %s", names[i])
+		}
+		uuids = append(uuids, ovsUUIDFromRow(result.Rows[0]))
+	}
+
+	externalIDs := mirror.ExternalIDs
+	if externalIDs == nil {
+		externalIDs = map[string]string{}
+	}
+
+	insertOp := ovs.Operation{
+		Op:    "insert",
+		Table: "Mirror",
+		Row: map[string]interface{}{
+			"name":            mirror.Name,
+			"select_src_port": newOvsSet(uuids[1:]),
+			"select_dst_port": newOvsSet(uuids[1:]),
+			"output_port":     uuids[0],
+			"external_ids":    newOvsMap(externalIDs),
+		},
+		UUIDName: "qmirroradd",
+	}
+
+	mutateOp := ovs.Operation{
+		Op:    "mutate",
+		Table: "Bridge",
+		Mutations: []interface{}{
+			newMutation("mirrors", "insert", ovs.UUID{GoUUID: "qmirroradd"}),
+		},
+		Where: newCondition("name", "==", bridge),
+	}
+
+	results, err := ovsdb.Transact("Open_vSwitch", insertOp, mutateOp)
+	if err != nil {
+		return fmt.Errorf("transaction error: creating mirror %s on %s: %s",
+			mirror.Name, bridge, err)
+	}
+	return errorCheck(results, 2)
+}
+
+// DeleteMirror removes a port mirror from Open vSwitch.
+func (ovsdb client) DeleteMirror(mirror Mirror) error {
+	c.Inc("Delete Mirror")
+	deleteOp := ovs.Operation{
+		Op:    "delete",
+		Table: "Mirror",
+		Where: newCondition("_uuid", "==", mirror.uuid),
+	}
+
+	mutateOp := ovs.Operation{
+		Op:        "mutate",
+		Table:     "Bridge",
+		Mutations: []interface{}{newMutation("mirrors", "delete", mirror.uuid)},
+		Where:     newCondition("mirrors", "includes", mirror.uuid),
+	}
+
+	results, err := ovsdb.Transact("Open_vSwitch", deleteOp, mutateOp)
+	if err != nil {
+		return fmt.Errorf("transaction error: deleting mirror %s: %s",
+			mirror.Name, err)
+	}
+	return errorCheck(results, 2)
+}
+
 func (ovsdb client) CreateLoadBalancer(lswitch, name string,
 	vips map[string]string) error {
 	c.Inc("Create Load Balancer")
@@ -692,6 +849,38 @@ func ovsStringMapToMap(oMap interface{}) (map[string]string, error) {
 	return ret, nil
 }
 
+// ovsUUIDSetToSlice returns the UUIDs in `oSet`, which is either a set of UUIDs, or
+// a single UUID.
+func ovsUUIDSetToSlice(oSet interface{}) ([]string, error) {
+	wrap, ok := oSet.([]interface{})
+	if !ok || len(wrap) != 2 {
+		return nil, errors.New("ovs set invalid")
+	}
+
+	if wrap[0] == "uuid" {
+		uuid, ok := wrap[1].(string)
+		if !ok {
+			return nil, errors.New("ovs uuid must be a string")
+		}
+		return []string{uuid}, nil
+	}
+
+	elems, ok := wrap[1].([]interface{})
+	if wrap[0] != "set" || !ok {
+		return nil, errors.New("ovs set content invalid")
+	}
+
+	var uuids []string
+	for _, elem := range elems {
+		elemUUIDs, err := ovsUUIDSetToSlice(elem)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, elemUUIDs...)
+	}
+	return uuids, nil
+}
+
 func ovsUUIDFromRow(row row) ovs.UUID {
 	uuid := ovs.UUID{}
 	block, ok := row["_uuid"].([]interface{})
@@ -670,6 +670,168 @@ func TestDeleteLoadBalancer(t *testing.T) {
 	api.AssertExpectations(t)
 }
 
+func TestListMirrors(t *testing.T) {
+	t.Parallel()
+
+	api := new(mockTransact)
+	odb := Client(client{api})
+
+	ops := []ovs.Operation{
+		{Op: "select", Table: "Mirror", Where: noCondition},
+		{Op: "select", Table: "Port", Where: noCondition},
+	}
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		nil, errors.New("err")).Once()
+	_, err := odb.ListMirrors()
+	assert.EqualError(t, err, "transaction error: listing mirrors: err")
+
+	mirror := map[string]interface{}{
+		"_uuid":           []interface{}{"uuid", "m"},
+		"name":            "name",
+		"select_src_port": []interface{}{"uuid", "p1"},
+		"output_port":     []interface{}{"uuid", "p2"},
+		"external_ids": []interface{}{"map", []interface{}{
+			[]interface{}{"key", "value"},
+		}},
+	}
+	ports := []map[string]interface{}{
+		{"_uuid": []interface{}{"uuid", "p1"}, "name": "port1"},
+		{"_uuid": []interface{}{"uuid", "p2"}, "name": "port2"},
+	}
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		[]ovs.OperationResult{
+			{Rows: []map[string]interface{}{mirror}},
+			{Rows: ports},
+		}, nil).Once()
+	res, err := odb.ListMirrors()
+	assert.NoError(t, err)
+	assert.Equal(t, []Mirror{{
+		uuid:        ovs.UUID{GoUUID: "m"},
+		Name:        "name",
+		Ports:       []string{"port1"},
+		Output:      "port2",
+		ExternalIDs: map[string]string{"key": "value"},
+	}}, res)
+
+	mirror["select_src_port"] = "bad"
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		[]ovs.OperationResult{
+			{Rows: []map[string]interface{}{mirror}},
+			{Rows: ports},
+		}, nil).Once()
+	_, err = odb.ListMirrors()
+	assert.EqualError(t, err, "malformed select_src_port: ovs set invalid")
+
+	api.AssertExpectations(t)
+}
+
+func TestCreateMirror(t *testing.T) {
+	t.Parallel()
+
+	api := new(mockTransact)
+	odb := Client(client{api})
+
+	selectPort := func(name string) ovs.Operation {
+		return ovs.Operation{
+			Op:      "select",
+			Table:   "Port",
+			Where:   newCondition("name", "==", name),
+			Columns: []string{"_uuid"},
+		}
+	}
+	portRow := func(uuid string) ovs.OperationResult {
+		return ovs.OperationResult{Rows: []map[string]interface{}{
+			{"_uuid": []interface{}{"uuid", uuid}},
+		}}
+	}
+	mirror := Mirror{
+		Name:        "name",
+		Ports:       []string{"port1"},
+		Output:      "port2",
+		ExternalIDs: map[string]string{"key": "value"},
+	}
+
+	api.On("Transact", "Open_vSwitch", selectPort("port2"),
+		selectPort("port1")).Return([]ovs.OperationResult{
+		portRow("p2"), {}}, nil).Once()
+	err := odb.CreateMirror("bridge", mirror)
+	assert.EqualError(t, err, "no port named port1")
+
+	api.On("Transact", "Open_vSwitch", selectPort("port2"),
+		selectPort("port1")).Return([]ovs.OperationResult{
+		portRow("p2"), portRow("p1")}, nil)
+
+	mut := newMutation("mirrors", "insert", ovs.UUID{GoUUID: "qmirroradd"})
+	ops := []ovs.Operation{
+		{
+			Op:    "insert",
+			Table: "Mirror",
+			Row: map[string]interface{}{
+				"name": "name",
+				"select_src_port": newOvsSet(
+					[]ovs.UUID{{GoUUID: "p1"}}),
+				"select_dst_port": newOvsSet(
+					[]ovs.UUID{{GoUUID: "p1"}}),
+				"output_port": ovs.UUID{GoUUID: "p2"},
+				"external_ids": newOvsMap(
+					map[string]string{"key": "value"}),
+			},
+			UUIDName: "qmirroradd",
+		},
+		{
+			Op:        "mutate",
+			Table:     "Bridge",
+			Mutations: []interface{}{mut},
+			Where:     newCondition("name", "==", "bridge"),
+		},
+	}
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		nil, errors.New("err")).Once()
+	err = odb.CreateMirror("bridge", mirror)
+	assert.EqualError(t, err,
+		"transaction error: creating mirror name on bridge: err")
+
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		[]ovs.OperationResult{{}, {}}, nil)
+	err = odb.CreateMirror("bridge", mirror)
+	assert.NoError(t, err)
+
+	api.AssertExpectations(t)
+}
+
+func TestDeleteMirror(t *testing.T) {
+	t.Parallel()
+
+	api := new(mockTransact)
+	odb := Client(client{api})
+
+	uuid := ovs.UUID{GoUUID: "foo"}
+	ops := []ovs.Operation{
+		{
+			Op:    "delete",
+			Table: "Mirror",
+			Where: newCondition("_uuid", "==", uuid),
+		},
+		{
+			Op:        "mutate",
+			Table:     "Bridge",
+			Mutations: []interface{}{newMutation("mirrors", "delete", uuid)},
+			Where:     newCondition("mirrors", "includes", uuid),
+		},
+	}
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		nil, errors.New("err")).Once()
+	err := odb.DeleteMirror(Mirror{uuid: uuid, Name: "name"})
+	assert.EqualError(t, err, "transaction error: deleting mirror name: err")
+
+	api.On("Transact", "Open_vSwitch", ops[0], ops[1]).Return(
+		[]ovs.OperationResult{{}, {}}, nil)
+	err = odb.DeleteMirror(Mirror{uuid: uuid, Name: "name"})
+	assert.NoError(t, err)
+
+	api.AssertExpectations(t)
+}
+
 func TestOvsStringSetToSlice(t *testing.T) {
 	t.Parallel()
 	assert.Equal(t, []string{"b"}, ovsStringSetToSlice("b"))
@@ -677,6 +839,26 @@ func TestOvsStringSetToSlice(t *testing.T) {
 		ovsStringSetToSlice([]interface{}{"set", []interface{}{"b"}}))
 }
 
+func TestOvsUUIDSetToSlice(t *testing.T) {
+	t.Parallel()
+
+	uuids, err := ovsUUIDSetToSlice([]interface{}{"uuid", "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, uuids)
+
+	uuids, err = ovsUUIDSetToSlice([]interface{}{"set", []interface{}{
+		[]interface{}{"uuid", "a"}, []interface{}{"uuid", "b"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, uuids)
+
+	uuids, err = ovsUUIDSetToSlice([]interface{}{"set", []interface{}{}})
+	assert.NoError(t, err)
+	assert.Empty(t, uuids)
+
+	_, err = ovsUUIDSetToSlice([]interface{}{"map", []interface{}{}})
+	assert.EqualError(t, err, "ovs set content invalid")
+}
+
 func TestErrorCheck(t *testing.T) {
 	t.Parallel()
 	assert.EqualError(t, errorCheck(nil, 3), "mismatched responses and operations")
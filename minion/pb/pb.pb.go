@@ -1,14 +1,15 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
-// source: minion/pb/pb.proto
+// source: pb/pb.proto
 
 /*
 Package pb is a generated protocol buffer package.
 
 It is generated from these files:
-	minion/pb/pb.proto
+	pb/pb.proto
 
 It has these top-level messages:
 	MinionConfig
+	ACL
 	Reply
 	Request
 */
@@ -59,16 +60,82 @@ func (x MinionConfig_Role) String() string {
 func (MinionConfig_Role) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
 
 type MinionConfig struct {
-	ID             string            `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
-	Role           MinionConfig_Role `protobuf:"varint,2,opt,name=role,enum=MinionConfig_Role" json:"role,omitempty"`
-	PrivateIP      string            `protobuf:"bytes,3,opt,name=PrivateIP" json:"PrivateIP,omitempty"`
-	Blueprint      string            `protobuf:"bytes,4,opt,name=Blueprint" json:"Blueprint,omitempty"`
-	Provider       string            `protobuf:"bytes,5,opt,name=Provider" json:"Provider,omitempty"`
-	Size           string            `protobuf:"bytes,6,opt,name=Size" json:"Size,omitempty"`
-	Region         string            `protobuf:"bytes,7,opt,name=Region" json:"Region,omitempty"`
-	FloatingIP     string            `protobuf:"bytes,8,opt,name=FloatingIP" json:"FloatingIP,omitempty"`
-	EtcdMembers    []string          `protobuf:"bytes,9,rep,name=EtcdMembers" json:"EtcdMembers,omitempty"`
-	AuthorizedKeys []string          `protobuf:"bytes,10,rep,name=AuthorizedKeys" json:"AuthorizedKeys,omitempty"`
+	ID              string            `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	Role            MinionConfig_Role `protobuf:"varint,2,opt,name=role,enum=MinionConfig_Role" json:"role,omitempty"`
+	PrivateIP       string            `protobuf:"bytes,3,opt,name=PrivateIP" json:"PrivateIP,omitempty"`
+	Blueprint       string            `protobuf:"bytes,4,opt,name=Blueprint" json:"Blueprint,omitempty"`
+	Provider        string            `protobuf:"bytes,5,opt,name=Provider" json:"Provider,omitempty"`
+	Size            string            `protobuf:"bytes,6,opt,name=Size" json:"Size,omitempty"`
+	Region          string            `protobuf:"bytes,7,opt,name=Region" json:"Region,omitempty"`
+	FloatingIP      string            `protobuf:"bytes,8,opt,name=FloatingIP" json:"FloatingIP,omitempty"`
+	EtcdMembers     []string          `protobuf:"bytes,9,rep,name=EtcdMembers" json:"EtcdMembers,omitempty"`
+	AuthorizedKeys  []string          `protobuf:"bytes,10,rep,name=AuthorizedKeys" json:"AuthorizedKeys,omitempty"`
+	NetworkDegraded bool              `protobuf:"varint,11,opt,name=NetworkDegraded" json:"NetworkDegraded,omitempty"`
+	// Maps partitions to the percentage of their space that's used.  Reported
+	// by the minion.
+	DiskUsage map[string]int32 `protobuf:"bytes,12,rep,name=DiskUsage" json:"DiskUsage,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Whether the minion should remove unused images and stopped containers
+	// when a partition is nearly full.  Set by the daemon.
+	DiskGC bool `protobuf:"varint,13,opt,name=DiskGC" json:"DiskGC,omitempty"`
+	// The traffic that the minion should accept on its public interface, if
+	// the host-firewall setting is enabled.  Set by the daemon.
+	ACLs []*ACL `protobuf:"bytes,14,rep,name=ACLs" json:"ACLs,omitempty"`
+	// Maps etcd users to their passwords.  Masters are sent the passwords of
+	// every user, so that they can create them, while workers are only sent
+	// the password of the worker user.  Set by the daemon, and never reported
+	// by the minion.
+	EtcdPasswords map[string]string `protobuf:"bytes,16,rep,name=EtcdPasswords" json:"EtcdPasswords,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The public IP of the machine, which is exposed to the containers that
+	// request their machine's metadata.  Set by the daemon.
+	PublicIP string `protobuf:"bytes,17,opt,name=PublicIP" json:"PublicIP,omitempty"`
+	// Whether the minion's containers should be scheduled elsewhere, because
+	// its machine is about to be rebooted or reimaged.  Set by the daemon.
+	Drain bool `protobuf:"varint,18,opt,name=Drain" json:"Drain,omitempty"`
+	// Whether the minion is draining, and no containers remain scheduled on
+	// it.  Reported by the minion.
+	Drained bool `protobuf:"varint,19,opt,name=Drained" json:"Drained,omitempty"`
+	// The API version that the sender speaks, and the oldest version that it
+	// can communicate with.  Set by both the daemon and the minion, which each
+	// refuse configs from incompatible peers.
+	APIVersion    int32 `protobuf:"varint,20,opt,name=APIVersion" json:"APIVersion,omitempty"`
+	MinAPIVersion int32 `protobuf:"varint,21,opt,name=MinAPIVersion" json:"MinAPIVersion,omitempty"`
+	// Whether the minion's machine is about to be reclaimed by its provider.
+	// Reported by the minion.
+	Interrupted bool `protobuf:"varint,22,opt,name=Interrupted" json:"Interrupted,omitempty"`
+	// Incremented by the daemon whenever the rest of the config changes.  The
+	// minion reports the generation of the last config that it applied, so
+	// that the daemon knows which minions run a stale config.
+	Generation int64 `protobuf:"varint,23,opt,name=Generation" json:"Generation,omitempty"`
+	// Key-value pairs from the blueprint that describe the machine, e.g.
+	// "ssd": "true", and that container placement rules may require.  Set by
+	// the daemon.
+	Labels map[string]string `protobuf:"bytes,24,rep,name=Labels" json:"Labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The state that a self-hosted daemon runs with, as a tar archive of its
+	// .quilt directory.  Only sent to masters, and only if the namespace is
+	// self-hosted.  Set by the daemon, and never reported by the minion.
+	SelfHost []byte `protobuf:"bytes,25,opt,name=SelfHost,proto3" json:"SelfHost,omitempty"`
+	// The signature, by the daemon's certificate authority, of the rest of the
+	// config (see SignedBytes).  Minions reject configs that aren't signed, or
+	// whose Generation isn't higher than that of the config they last
+	// applied, so that other machines can neither forge configs nor replay old
+	// ones.  Set by the daemon, and never reported by the minion.
+	Signature []byte `protobuf:"bytes,27,opt,name=Signature,proto3" json:"Signature,omitempty"`
+	// Maps the hostnames of the minion's containers that run a TLS proxy to
+	// the PEM-encoded public keys of their proxies.  Reported by the minion,
+	// which keeps the private keys to itself.
+	ContainerKeys map[string]string `protobuf:"bytes,28,rep,name=ContainerKeys" json:"ContainerKeys,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Maps the hostnames in ContainerKeys to PEM-encoded certificates for
+	// their keys, issued by the daemon's certificate authority.  Set by the
+	// daemon.
+	ContainerCerts map[string]string `protobuf:"bytes,29,rep,name=ContainerCerts" json:"ContainerCerts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Instructs a master to stop running the self-hosted daemon, and to
+	// delete its state.  Only sent if self-hosting was explicitly turned off,
+	// so that a daemon that doesn't know the namespace is self-hosted can't
+	// remove it.  Set by the daemon.
+	DisableSelfHost bool `protobuf:"varint,30,opt,name=DisableSelfHost" json:"DisableSelfHost,omitempty"`
+	// Whether the master is running the self-hosted daemon.  Other daemons
+	// stop managing the namespace while it is.  Reported by the minion.
+	SelfHosted bool `protobuf:"varint,31,opt,name=SelfHosted" json:"SelfHosted,omitempty"`
 }
 
 func (m *MinionConfig) Reset()                    { *m = MinionConfig{} }
@@ -146,13 +213,178 @@ func (m *MinionConfig) GetAuthorizedKeys() []string {
 	return nil
 }
 
+func (m *MinionConfig) GetNetworkDegraded() bool {
+	if m != nil {
+		return m.NetworkDegraded
+	}
+	return false
+}
+
+func (m *MinionConfig) GetDiskUsage() map[string]int32 {
+	if m != nil {
+		return m.DiskUsage
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetDiskGC() bool {
+	if m != nil {
+		return m.DiskGC
+	}
+	return false
+}
+
+func (m *MinionConfig) GetACLs() []*ACL {
+	if m != nil {
+		return m.ACLs
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetEtcdPasswords() map[string]string {
+	if m != nil {
+		return m.EtcdPasswords
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetPublicIP() string {
+	if m != nil {
+		return m.PublicIP
+	}
+	return ""
+}
+
+func (m *MinionConfig) GetDrain() bool {
+	if m != nil {
+		return m.Drain
+	}
+	return false
+}
+
+func (m *MinionConfig) GetDrained() bool {
+	if m != nil {
+		return m.Drained
+	}
+	return false
+}
+
+func (m *MinionConfig) GetAPIVersion() int32 {
+	if m != nil {
+		return m.APIVersion
+	}
+	return 0
+}
+
+func (m *MinionConfig) GetMinAPIVersion() int32 {
+	if m != nil {
+		return m.MinAPIVersion
+	}
+	return 0
+}
+
+func (m *MinionConfig) GetInterrupted() bool {
+	if m != nil {
+		return m.Interrupted
+	}
+	return false
+}
+
+func (m *MinionConfig) GetGeneration() int64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+func (m *MinionConfig) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetSelfHost() []byte {
+	if m != nil {
+		return m.SelfHost
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetContainerKeys() map[string]string {
+	if m != nil {
+		return m.ContainerKeys
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetContainerCerts() map[string]string {
+	if m != nil {
+		return m.ContainerCerts
+	}
+	return nil
+}
+
+func (m *MinionConfig) GetDisableSelfHost() bool {
+	if m != nil {
+		return m.DisableSelfHost
+	}
+	return false
+}
+
+func (m *MinionConfig) GetSelfHosted() bool {
+	if m != nil {
+		return m.SelfHosted
+	}
+	return false
+}
+
+type ACL struct {
+	CidrIP  string `protobuf:"bytes,1,opt,name=CidrIP" json:"CidrIP,omitempty"`
+	MinPort int32  `protobuf:"varint,2,opt,name=MinPort" json:"MinPort,omitempty"`
+	MaxPort int32  `protobuf:"varint,3,opt,name=MaxPort" json:"MaxPort,omitempty"`
+}
+
+func (m *ACL) Reset()                    { *m = ACL{} }
+func (m *ACL) String() string            { return proto.CompactTextString(m) }
+func (*ACL) ProtoMessage()               {}
+func (*ACL) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *ACL) GetCidrIP() string {
+	if m != nil {
+		return m.CidrIP
+	}
+	return ""
+}
+
+func (m *ACL) GetMinPort() int32 {
+	if m != nil {
+		return m.MinPort
+	}
+	return 0
+}
+
+func (m *ACL) GetMaxPort() int32 {
+	if m != nil {
+		return m.MaxPort
+	}
+	return 0
+}
+
 type Reply struct {
 }
 
 func (m *Reply) Reset()                    { *m = Reply{} }
 func (m *Reply) String() string            { return proto.CompactTextString(m) }
 func (*Reply) ProtoMessage()               {}
-func (*Reply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*Reply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 type Request struct {
 }
@@ -160,10 +392,11 @@ type Request struct {
 func (m *Request) Reset()                    { *m = Request{} }
 func (m *Request) String() string            { return proto.CompactTextString(m) }
 func (*Request) ProtoMessage()               {}
-func (*Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
 func init() {
 	proto.RegisterType((*MinionConfig)(nil), "MinionConfig")
+	proto.RegisterType((*ACL)(nil), "ACL")
 	proto.RegisterType((*Reply)(nil), "Reply")
 	proto.RegisterType((*Request)(nil), "Request")
 	proto.RegisterEnum("MinionConfig_Role", MinionConfig_Role_name, MinionConfig_Role_value)
@@ -271,32 +504,58 @@ var _Minion_serviceDesc = grpc.ServiceDesc{
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
-	Metadata: "minion/pb/pb.proto",
+	Metadata: "pb/pb.proto",
 }
 
-func init() { proto.RegisterFile("minion/pb/pb.proto", fileDescriptor0) }
+func init() { proto.RegisterFile("pb/pb.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 332 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x91, 0x51, 0x8b, 0xda, 0x40,
-	0x10, 0xc7, 0x4d, 0x8c, 0x31, 0x99, 0xb6, 0x2a, 0xf3, 0x50, 0x16, 0x29, 0x25, 0xe4, 0x41, 0x42,
-	0x29, 0x11, 0xec, 0x27, 0xb0, 0x35, 0x3d, 0x82, 0xa8, 0x61, 0x3d, 0xb8, 0x67, 0x73, 0xce, 0x79,
-	0x0b, 0x31, 0x9b, 0x5b, 0x57, 0x41, 0x3f, 0xec, 0x7d, 0x96, 0xc3, 0x55, 0x3c, 0xf5, 0x6d, 0xe6,
-	0xf7, 0x9b, 0xff, 0xc2, 0xce, 0x00, 0xae, 0x45, 0x29, 0x64, 0xd9, 0xaf, 0xf2, 0x7e, 0x95, 0xc7,
-	0x95, 0x92, 0x5a, 0x86, 0xef, 0x36, 0x7c, 0x9d, 0x18, 0xfc, 0x4f, 0x96, 0x2f, 0x62, 0x85, 0x2d,
-	0xb0, 0xd3, 0x11, 0xb3, 0x02, 0x2b, 0xf2, 0xb9, 0x9d, 0x8e, 0xb0, 0x07, 0x8e, 0x92, 0x05, 0x31,
-	0x3b, 0xb0, 0xa2, 0xd6, 0x00, 0xe3, 0xeb, 0xe1, 0x98, 0xcb, 0x82, 0xb8, 0xf1, 0xf8, 0x03, 0xfc,
-	0x4c, 0x89, 0xdd, 0x42, 0x53, 0x9a, 0xb1, 0xba, 0x89, 0x7f, 0x82, 0xa3, 0xfd, 0x5b, 0x6c, 0xa9,
-	0x52, 0xa2, 0xd4, 0xcc, 0x39, 0xd9, 0x0b, 0xc0, 0x2e, 0x78, 0x99, 0x92, 0x3b, 0xb1, 0x24, 0xc5,
-	0x1a, 0x46, 0x5e, 0x7a, 0x44, 0x70, 0xe6, 0xe2, 0x40, 0xcc, 0x35, 0xdc, 0xd4, 0xf8, 0x1d, 0x5c,
-	0x4e, 0x2b, 0x21, 0x4b, 0xd6, 0x34, 0xf4, 0xdc, 0xe1, 0x4f, 0x80, 0xff, 0x85, 0x5c, 0x68, 0x51,
-	0xae, 0xd2, 0x8c, 0x79, 0xc6, 0x5d, 0x11, 0x0c, 0xe0, 0x4b, 0xa2, 0x9f, 0x97, 0x13, 0x5a, 0xe7,
-	0xa4, 0x36, 0xcc, 0x0f, 0xea, 0x91, 0xcf, 0xaf, 0x11, 0xf6, 0xa0, 0x35, 0xdc, 0xea, 0x57, 0xa9,
-	0xc4, 0x81, 0x96, 0x63, 0xda, 0x6f, 0x18, 0x98, 0xa1, 0x3b, 0x1a, 0x46, 0xe0, 0x1c, 0xff, 0x8e,
-	0x1e, 0x38, 0xd3, 0xd9, 0x34, 0xe9, 0xd4, 0x10, 0xc0, 0x7d, 0x9a, 0xf1, 0x71, 0xc2, 0x3b, 0xd6,
-	0xb1, 0x9e, 0x0c, 0xe7, 0x8f, 0x09, 0xef, 0xd8, 0x61, 0x13, 0x1a, 0x9c, 0xaa, 0x62, 0x1f, 0xfa,
-	0xd0, 0xe4, 0xf4, 0xb6, 0xa5, 0x8d, 0x1e, 0xe4, 0xe0, 0x9e, 0xd6, 0x88, 0xbf, 0xa0, 0x3d, 0x27,
-	0x7d, 0x73, 0x80, 0x6f, 0x37, 0x2b, 0xee, 0xba, 0xf1, 0x29, 0x5e, 0xc3, 0xdf, 0xd0, 0x7e, 0xb8,
-	0x9b, 0xf5, 0xe2, 0xf3, 0x93, 0xdd, 0xdb, 0x54, 0x58, 0xcb, 0x5d, 0x73, 0xdf, 0x3f, 0x1f, 0x01,
-	0x00, 0x00, 0xff, 0xff, 0x2e, 0xbe, 0xc6, 0x53, 0xf5, 0x01, 0x00, 0x00,
+	// 744 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x95, 0x55, 0xdb, 0x72, 0xd3, 0x30,
+	0x10, 0x6d, 0x12, 0xe7, 0xb6, 0x69, 0x12, 0xa3, 0x96, 0xa2, 0xa6, 0xa5, 0x0d, 0x19, 0x86, 0xe9,
+	0x30, 0x4c, 0x18, 0xca, 0x0b, 0x74, 0x78, 0x20, 0x24, 0x69, 0x09, 0xbd, 0x05, 0x85, 0xcb, 0xb3,
+	0x5d, 0xab, 0xc1, 0x53, 0x63, 0x07, 0x59, 0x29, 0x84, 0x2f, 0xe2, 0x33, 0x59, 0xc9, 0x6e, 0x6a,
+	0xa7, 0x65, 0x98, 0xbe, 0x78, 0x76, 0xcf, 0x1e, 0x9d, 0x95, 0x76, 0x57, 0x32, 0x54, 0x26, 0xf6,
+	0xf3, 0x89, 0xdd, 0x9e, 0x88, 0x40, 0x06, 0xad, 0x3f, 0x15, 0x58, 0x3e, 0x76, 0x7d, 0x37, 0xf0,
+	0xbb, 0x81, 0x7f, 0xee, 0x8e, 0x49, 0x0d, 0xb2, 0x83, 0x1e, 0xcd, 0x34, 0x33, 0x3b, 0x65, 0x86,
+	0x16, 0x79, 0x02, 0x86, 0x08, 0x3c, 0x4e, 0xb3, 0x88, 0xd4, 0x76, 0x49, 0x3b, 0x49, 0x6e, 0x33,
+	0x8c, 0x30, 0x1d, 0x27, 0x9b, 0x50, 0x1e, 0x0a, 0xf7, 0xd2, 0x92, 0x7c, 0x30, 0xa4, 0x39, 0xbd,
+	0xfc, 0x1a, 0x50, 0xd1, 0x77, 0xde, 0x94, 0x4f, 0x84, 0xeb, 0x4b, 0x6a, 0x44, 0xd1, 0x39, 0x40,
+	0x1a, 0x50, 0x1a, 0x8a, 0xe0, 0xd2, 0x75, 0xb8, 0xa0, 0x79, 0x1d, 0x9c, 0xfb, 0x84, 0x80, 0x31,
+	0x72, 0x7f, 0x73, 0x5a, 0xd0, 0xb8, 0xb6, 0xc9, 0x1a, 0x14, 0x18, 0x1f, 0xe3, 0x36, 0x68, 0x51,
+	0xa3, 0xb1, 0x47, 0xb6, 0x00, 0xf6, 0xbd, 0xc0, 0x92, 0xae, 0x3f, 0xc6, 0x4d, 0x94, 0x74, 0x2c,
+	0x81, 0x90, 0x26, 0x54, 0xfa, 0xf2, 0xcc, 0x39, 0xe6, 0xdf, 0x6d, 0x2e, 0x42, 0x5a, 0x6e, 0xe6,
+	0x90, 0x90, 0x84, 0xf0, 0xb4, 0xb5, 0xce, 0x54, 0x7e, 0x0b, 0x04, 0xa6, 0x71, 0x0e, 0xf9, 0x2c,
+	0xa4, 0xa0, 0x49, 0x0b, 0x28, 0xd9, 0x81, 0xfa, 0x09, 0x97, 0x3f, 0x03, 0x71, 0xd1, 0xe3, 0x63,
+	0x61, 0x39, 0xdc, 0xa1, 0x15, 0x4c, 0x57, 0x62, 0x8b, 0x30, 0xd9, 0x83, 0x72, 0xcf, 0x0d, 0x2f,
+	0x3e, 0x87, 0xd6, 0x98, 0xd3, 0x65, 0x14, 0xab, 0xec, 0x6e, 0xa6, 0x8b, 0x38, 0x0f, 0xf7, 0x7d,
+	0x29, 0x66, 0xec, 0x9a, 0xae, 0xce, 0xa9, 0x9c, 0x83, 0x2e, 0xad, 0x6a, 0xf1, 0xd8, 0x23, 0x14,
+	0x8c, 0x4e, 0xf7, 0x28, 0xa4, 0x35, 0x2d, 0x67, 0xb4, 0xd1, 0x61, 0x1a, 0x21, 0xfb, 0x50, 0x55,
+	0xc7, 0x19, 0x5a, 0x61, 0x88, 0xbb, 0x70, 0x42, 0x6a, 0x6a, 0x4a, 0x33, 0x9d, 0x31, 0x45, 0x89,
+	0xb2, 0xa6, 0x97, 0xe9, 0x8e, 0x4c, 0x6d, 0xcf, 0x3d, 0xc3, 0x3a, 0xde, 0x8b, 0x3b, 0x12, 0xfb,
+	0x64, 0x15, 0xf2, 0x3d, 0x61, 0xb9, 0x3e, 0x25, 0x7a, 0x53, 0x91, 0x83, 0x7b, 0x2a, 0x6a, 0x03,
+	0x2b, 0xb1, 0xa2, 0xf1, 0x2b, 0x57, 0x75, 0xa5, 0x33, 0x1c, 0x7c, 0xc1, 0xf2, 0xaa, 0x8e, 0xad,
+	0x62, 0x30, 0xcf, 0x12, 0x08, 0x79, 0x0c, 0x55, 0xdc, 0x5d, 0x82, 0x72, 0x5f, 0x53, 0xd2, 0xa0,
+	0xea, 0xdd, 0xc0, 0x97, 0x5c, 0x88, 0xe9, 0x44, 0x62, 0x8e, 0x35, 0x9d, 0x23, 0x09, 0xa9, 0x3c,
+	0x07, 0xdc, 0xe7, 0x02, 0xbb, 0x8d, 0x22, 0x0f, 0x90, 0x90, 0x63, 0x09, 0x84, 0xbc, 0x80, 0xc2,
+	0x91, 0x65, 0x73, 0x2f, 0xa4, 0x54, 0x17, 0x65, 0x3d, 0x5d, 0x94, 0x28, 0x16, 0x55, 0x23, 0x26,
+	0xaa, 0x32, 0x8c, 0xb8, 0x77, 0xfe, 0x3e, 0x08, 0x25, 0x5d, 0x47, 0xc1, 0x65, 0x36, 0xf7, 0xd5,
+	0x48, 0x8f, 0xdc, 0xb1, 0x6f, 0xc9, 0xa9, 0xe0, 0x74, 0x43, 0x07, 0xaf, 0x01, 0xd5, 0x08, 0xd4,
+	0x95, 0xaa, 0x02, 0x42, 0xcf, 0xd1, 0xe6, 0x6d, 0x8d, 0x48, 0x51, 0xe2, 0x46, 0xa4, 0x30, 0x32,
+	0x80, 0xda, 0x1c, 0xe8, 0x72, 0x21, 0x43, 0xfa, 0x50, 0x0b, 0x3d, 0xfa, 0x87, 0x90, 0xe6, 0x44,
+	0x4a, 0x0b, 0x0b, 0xd5, 0xcc, 0xe2, 0xfc, 0x58, 0xb6, 0xc7, 0xe7, 0x67, 0xda, 0x8a, 0x66, 0x76,
+	0x01, 0x56, 0x95, 0xbc, 0xb2, 0xb1, 0xd4, 0xdb, 0x9a, 0x94, 0x40, 0x1a, 0x6f, 0xa0, 0x96, 0x1e,
+	0x5a, 0x62, 0x42, 0xee, 0x82, 0xcf, 0xe2, 0x67, 0x43, 0x99, 0x6a, 0x4a, 0x2e, 0x2d, 0xbc, 0xe1,
+	0xfa, 0xe1, 0xc8, 0xb3, 0xc8, 0xd9, 0xcb, 0xbe, 0xca, 0x34, 0xde, 0x02, 0xb9, 0x39, 0x80, 0xff,
+	0x53, 0x28, 0x27, 0x15, 0x5e, 0x43, 0x25, 0xd1, 0xad, 0x3b, 0x2d, 0xc5, 0xe4, 0x37, 0x8b, 0x7e,
+	0x27, 0x85, 0x0e, 0xac, 0xdc, 0x52, 0xed, 0xbb, 0x48, 0xb4, 0x76, 0xc0, 0x50, 0x2f, 0x27, 0x29,
+	0x81, 0x71, 0x72, 0x7a, 0xd2, 0x37, 0x97, 0x08, 0x40, 0xe1, 0xeb, 0x29, 0x3b, 0xec, 0x33, 0x33,
+	0xa3, 0xec, 0xe3, 0xce, 0xe8, 0x13, 0xda, 0xd9, 0x0f, 0x46, 0xa9, 0x6e, 0x9a, 0xf8, 0x6d, 0x98,
+	0x1b, 0xad, 0x8f, 0x90, 0xc3, 0x3b, 0xae, 0x1e, 0x85, 0xae, 0xeb, 0x08, 0xbc, 0x98, 0x51, 0xae,
+	0xd8, 0x53, 0x17, 0x10, 0x47, 0x62, 0x18, 0x08, 0x19, 0x97, 0xfc, 0xca, 0xd5, 0x11, 0xeb, 0x97,
+	0x8e, 0xe4, 0xe2, 0x48, 0xe4, 0xb6, 0x8a, 0x90, 0x67, 0x7c, 0xe2, 0xcd, 0x5a, 0x65, 0x28, 0x32,
+	0xfe, 0x63, 0xca, 0x43, 0xb9, 0x6b, 0x63, 0x7a, 0x3d, 0x5a, 0xe4, 0x29, 0xd4, 0x47, 0x5c, 0xa6,
+	0xfe, 0x0e, 0xd5, 0xd4, 0xd8, 0x35, 0x0a, 0xed, 0x68, 0xf9, 0x12, 0x79, 0x06, 0xf5, 0x83, 0x05,
+	0x6e, 0xa9, 0x1d, 0x4b, 0x36, 0xd2, 0xab, 0x5a, 0x4b, 0x76, 0x41, 0xff, 0x7c, 0x5e, 0xfe, 0x05,
+	0xd1, 0x58, 0xf8, 0x4c, 0x8b, 0x06, 0x00, 0x00,
 }
@@ -0,0 +1,13 @@
+package pb
+
+import "encoding/json"
+
+// SignedBytes returns the serialization of the config that the daemon signs,
+// which covers every field but the signature itself.  The protobuf encoding isn't
+// used because it doesn't order map entries, so a config that the minion decodes
+// and encodes again may differ from the one that the daemon signed.
+func (m *MinionConfig) SignedBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
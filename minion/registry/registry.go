@@ -1,115 +1,229 @@
 package registry
 
 import (
-	"errors"
+	"crypto/sha256"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/util"
 )
 
+var c = counter.New("Registry")
+
 /*
 The registry submodule builds custom Dockerfiles. When a custom Dockerfile is
 deployed in a blueprint (e.g.`new Container(new Image("name", "dk"))`), a couple
 things happen:
 1) On the leader, the engine reads the custom images from the Containers in the
 blueprint, and writes them to the Image table.
-2) The registry submodule builds the images in the Image table, and updates
-their image ID with the ID of the built image.
-3) The scheduler schedules containers for which the image has been built.
+2) The leader assigns each unbuilt image to the least-loaded worker, and shares
+the Image table with the workers through etcd. Each master runs its own
+registry, so when leadership changes, the new leader reassigns the images that
+were pushed to its predecessor's registry.
+3) Each worker builds the images assigned to it, using the copy of the image
+already in the leader's registry as its layer cache, and pushes the result to
+the registry. The worker publishes the IDs of the images it built on its Minion
+row, which the leader copies into the Image table.
+4) The scheduler schedules containers for which the image has been built.
 When scheduling Containers with custom images, it modifies the image to
 be pointed at the registry running on the leader. A side effect of this is that
 if the leader dies, the scheduler updates the image names in etcd, and the workers
 restart containers running the custom image.
-4) The workers pull and run the image just like any other image.
+5) The workers pull and run the image just like any other image.
 */
 
-// Run builds Docker images according to the Image table if the minion's Role is
-// Master, and does nothing otherwise.
+// Run places the images in the Image table on workers if the minion is the
+// leader, and builds the images placed on it if the minion is a worker.
 func Run(conn db.Conn, dk docker.Client) {
-	if conn.MinionSelf().Role != db.Master {
-		return
+	if conn.MinionSelf().Role == db.Master {
+		bootWait()
+		for range conn.TriggerTick(30, db.ImageTable, db.MinionTable,
+			db.ContainerTable, db.EtcdTable).C {
+			if conn.EtcdLeader() {
+				placeImages(conn)
+			}
+		}
 	}
 
-	bootWait()
-	for range conn.TriggerTick(30, db.ImageTable).C {
-		syncImages(conn, dk)
+	for range conn.TriggerTick(30, db.ImageTable, db.MinionTable).C {
+		buildImages(conn, dk)
+	}
+}
+
+// placeImages assigns each image that hasn't been built to a worker, and marks
+// the image built once the worker reports that it has finished.
+func placeImages(conn db.Conn) {
+	conn.Txn(db.ImageTable, db.MinionTable,
+		db.ContainerTable).Run(func(view db.Database) error {
+		myIP := view.MinionSelf().PrivateIP
+		if myIP == "" {
+			return nil
+		}
+
+		workers := map[string]db.Minion{}
+		load := map[string]int{}
+		for _, m := range view.SelectFromMinion(nil) {
+			if m.Role == db.Worker && m.PrivateIP != "" && !m.Drain &&
+				!m.Interrupted {
+				workers[m.PrivateIP] = m
+				load[m.PrivateIP] = 0
+			}
+		}
+
+		for _, dbc := range view.SelectFromContainer(nil) {
+			if _, ok := load[dbc.Minion]; ok {
+				load[dbc.Minion]++
+			}
+		}
+
+		images := view.SelectFromImage(func(img db.Image) bool {
+			return img.Status != db.Built || img.Registry != myIP
+		})
+		sort.Sort(db.ImageSlice(images))
+		for _, img := range images {
+			if _, ok := workers[img.Minion]; ok {
+				load[img.Minion]++
+			}
+		}
+
+		for _, img := range images {
+			// Images pushed to a previous leader's registry can't be pulled
+			// from ours, so they're built again.
+			if img.Registry != myIP {
+				img.Registry = myIP
+				img.DockerID = ""
+				img.Status = db.Building
+				view.Commit(img)
+			}
+
+			worker, ok := workers[img.Minion]
+			if ok {
+				id, built := worker.BuiltImages[buildKey(img)]
+				if !built {
+					continue
+				}
+
+				img.DockerID = id
+				img.Status = db.Built
+				view.Commit(img)
+				continue
+			}
+
+			ip := leastLoaded(load)
+			if ip == "" {
+				log.WithField("image", img.Name).Debug(
+					"No workers available to build image")
+				continue
+			}
+
+			c.Inc("Place Image")
+			load[ip]++
+			img.Minion = ip
+			img.DockerID = ""
+			img.Status = db.Building
+			view.Commit(img)
+		}
+		return nil
+	})
+}
+
+// leastLoaded returns the IP of the worker with the smallest load, breaking ties
+// by IP so that placement is deterministic.
+func leastLoaded(load map[string]int) string {
+	var best string
+	for ip, l := range load {
+		if best == "" || l < load[best] || (l == load[best] && ip < best) {
+			best = ip
+		}
 	}
+	return best
 }
 
-// syncImages checks the Image table for any images that have not yet been
-// built, and builds them.
-func syncImages(conn db.Conn, dk docker.Client) {
+// buildImages builds the images that the leader placed on this worker, and
+// publishes their IDs on the worker's Minion row.
+func buildImages(conn db.Conn, dk docker.Client) {
+	var self db.Minion
 	var toBuild []db.Image
-	conn.Txn(db.ImageTable).Run(func(view db.Database) error {
+	conn.Txn(db.ImageTable, db.MinionTable).Run(func(view db.Database) error {
+		self = view.MinionSelf()
 		toBuild = view.SelectFromImage(func(img db.Image) bool {
-			return img.Status != db.Built
+			return self.PrivateIP != "" && img.Minion == self.PrivateIP &&
+				img.Registry != ""
 		})
 		return nil
 	})
 
+	if self.PrivateIP == "" {
+		return
+	}
+
+	built := map[string]string{}
 	for _, img := range toBuild {
-		img.Status = db.Building
-		writeImage(conn, img)
+		key := buildKey(img)
+		if id, ok := self.BuiltImages[key]; ok {
+			built[key] = id
+			continue
+		}
 
-		id, err := updateRegistry(dk, img)
-		if err != nil {
-			img.Status = "" // Unset the building status.
-			writeImage(conn, img)
+		// The leader only marks images built after this worker reports them,
+		// so an image it considers built was built by a previous worker.
+		if img.Status == db.Built {
+			continue
+		}
 
+		c.Inc("Build Image")
+		id, err := updateRegistry(dk, img.Registry+":5000", img)
+		if err != nil {
 			log.WithError(err).WithField("image", img.Name).
 				Error("Failed to update registry")
 			continue
 		}
-
-		img.DockerID = id
-		img.Status = db.Built
-		writeImage(conn, img)
+		built[key] = id
 	}
+
+	// Images that are no longer placed on this worker are forgotten, so that
+	// they're rebuilt if the leader places them here again.
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		if util.MapAsString(self.BuiltImages) != util.MapAsString(built) {
+			self.BuiltImages = built
+			view.Commit(self)
+		}
+		return nil
+	})
 }
 
-func updateRegistry(dk docker.Client, img db.Image) (string, error) {
-	registryImg := "localhost:5000/" + img.Name
-	id, err := dk.Build(registryImg, img.Dockerfile, false)
-	if err == nil {
-		err = dk.Push("localhost:5000", registryImg)
+// updateRegistry builds `img` and pushes it to `registry`.  The copy of the image
+// already in the registry, if any, is pulled first so that its layers can be
+// reused by the build, even though they were built by another worker.
+func updateRegistry(dk docker.Client, registry string, img db.Image) (
+	string, error) {
+
+	registryImg := registry + "/" + img.Name
+	if err := dk.Pull(registryImg); err != nil {
+		log.WithError(err).WithField("image", registryImg).Debug(
+			"Failed to pull image cache")
 	}
-	return id, err
-}
 
-// writeImage updates the attributes of the image committed to the database that
-// has the same Name and Dockerfile.
-func writeImage(conn db.Conn, img db.Image) {
-	err := conn.Txn(db.ImageTable).Run(
-		func(view db.Database) error {
-			dbImg, err := getImageHandle(view, img)
-			if err == nil {
-				img.ID = dbImg.ID
-				view.Commit(img)
-			}
-			return err
-		},
-	)
-	if err != nil {
-		log.WithError(err).WithField("image", img).Warn("Failed to write image")
+	id, err := dk.Build(registryImg, img.Dockerfile, []string{registryImg})
+	if err == nil {
+		err = dk.Push(registry, registryImg)
 	}
+	return id, err
 }
 
-func getImageHandle(view db.Database, ref db.Image) (db.Image, error) {
-	matchingImages := view.SelectFromImage(func(img db.Image) bool {
-		return img.Dockerfile == ref.Dockerfile && img.Name == ref.Name
-	})
-	switch len(matchingImages) {
-	case 0:
-		return db.Image{}, errors.New("no matching images")
-	case 1:
-		return matchingImages[0], nil
-	default:
-		return db.Image{}, errors.New("multiple matching images")
-	}
+// buildKey identifies the build of `img`, so that a Dockerfile or registry change
+// is noticed even if the image's name stays the same.
+func buildKey(img db.Image) string {
+	return fmt.Sprintf("%s/%s@%x", img.Registry, img.Name,
+		sha256.Sum256([]byte(img.Dockerfile)))
 }
 
 // bootWait blocks until the registry is ready to be pushed to.
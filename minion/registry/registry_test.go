@@ -10,49 +10,194 @@ import (
 	"github.com/kelda/kelda/minion/docker"
 )
 
-func TestSyncImages(t *testing.T) {
+func TestPlaceImages(t *testing.T) {
+	t.Parallel()
+	conn := db.New()
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, m := range []db.Minion{
+			{Role: db.Master, PrivateIP: "10.0.0.1", Self: true},
+			{Role: db.Worker, PrivateIP: "10.0.0.2"},
+			{Role: db.Worker, PrivateIP: "10.0.0.3"},
+			{Role: db.Worker, PrivateIP: "10.0.0.4", Drain: true},
+		} {
+			dbm := view.InsertMinion()
+			m.ID = dbm.ID
+			view.Commit(m)
+		}
+
+		dbc := view.InsertContainer()
+		dbc.Minion = "10.0.0.2"
+		view.Commit(dbc)
+
+		for _, name := range []string{"a", "b", "c"} {
+			img := view.InsertImage()
+			img.Name = name
+			img.Dockerfile = "dockerfile"
+			view.Commit(img)
+		}
+		return nil
+	})
+
+	// The container on 10.0.0.2 makes 10.0.0.3 the least loaded worker, after
+	// which the images are spread evenly.
+	placeImages(conn)
+	placed := map[string]string{}
+	for _, img := range getImages(conn) {
+		assert.Equal(t, db.Building, img.Status)
+		assert.Equal(t, "10.0.0.1", img.Registry)
+		placed[img.Name] = img.Minion
+	}
+	assert.Equal(t, map[string]string{
+		"a": "10.0.0.3", "b": "10.0.0.2", "c": "10.0.0.3"}, placed)
+
+	// Images are marked built once their worker reports them.
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		m := view.SelectFromMinion(func(m db.Minion) bool {
+			return m.PrivateIP == "10.0.0.3"
+		})[0]
+		m.BuiltImages = map[string]string{
+			buildKey(db.Image{Name: "a", Dockerfile: "dockerfile",
+				Registry: "10.0.0.1"}): "id"}
+		view.Commit(m)
+		return nil
+	})
+
+	placeImages(conn)
+	for _, img := range getImages(conn) {
+		if img.Name == "a" {
+			assert.Equal(t, db.Built, img.Status)
+			assert.Equal(t, "id", img.DockerID)
+		} else {
+			assert.Equal(t, db.Building, img.Status)
+		}
+	}
+
+	// Images on workers that are drained are placed elsewhere.
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		m := view.SelectFromMinion(func(m db.Minion) bool {
+			return m.PrivateIP == "10.0.0.2"
+		})[0]
+		m.Drain = true
+		view.Commit(m)
+		return nil
+	})
+
+	placeImages(conn)
+	for _, img := range getImages(conn) {
+		assert.Equal(t, "10.0.0.3", img.Minion)
+	}
+
+	// When another master becomes the leader, its registry doesn't have the
+	// images built for the old leader, so they're built again.
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		for _, m := range view.SelectFromMinion(nil) {
+			m.Self = m.PrivateIP == "10.0.0.5"
+			view.Commit(m)
+		}
+		m := view.InsertMinion()
+		m.Role = db.Master
+		m.PrivateIP = "10.0.0.5"
+		m.Self = true
+		view.Commit(m)
+		return nil
+	})
+
+	placeImages(conn)
+	for _, img := range getImages(conn) {
+		assert.Equal(t, db.Building, img.Status)
+		assert.Empty(t, img.DockerID)
+		assert.Equal(t, "10.0.0.5", img.Registry)
+	}
+}
+
+func TestBuildImages(t *testing.T) {
 	md, dk := docker.NewMock()
 	conn := db.New()
 
+	img := db.Image{Name: "image", Dockerfile: "dockerfile",
+		Minion: "10.0.0.2", Status: db.Building, Registry: "10.0.0.1"}
 	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
-		im := view.InsertImage()
-		im.Name = "image"
-		view.Commit(im)
+		self := view.InsertMinion()
+		self.Self = true
+		self.Role = db.Worker
+		self.PrivateIP = "10.0.0.2"
+		view.Commit(self)
+
+		for _, name := range []string{img.Name, "other"} {
+			dbImg := view.InsertImage()
+			dbImg.Name = name
+			dbImg.Dockerfile = img.Dockerfile
+			dbImg.Minion = img.Minion
+			if name == "other" {
+				dbImg.Minion = "10.0.0.3"
+			}
+			dbImg.Status = db.Building
+			view.Commit(dbImg)
+		}
 		return nil
 	})
 
-	// Test building an image that fails. The status should not be "built".
+	// Nothing is built until the leader assigns the image a registry.
+	buildImages(conn, dk)
+	assert.Empty(t, md.Built)
+
+	conn.Txn(db.ImageTable).Run(func(view db.Database) error {
+		for _, dbImg := range view.SelectFromImage(nil) {
+			dbImg.Registry = img.Registry
+			view.Commit(dbImg)
+		}
+		return nil
+	})
+
+	// Failed builds aren't reported.
 	md.BuildError = true
-	syncImages(conn, dk)
-	images := getImages(conn)
-	assert.Len(t, images, 1)
-	assert.Empty(t, images[0].DockerID)
-	assert.Empty(t, images[0].Status)
+	buildImages(conn, dk)
+	assert.Empty(t, conn.MinionSelf().BuiltImages)
 
-	// Test successfully building an image.
 	md.BuildError = false
-	syncImages(conn, dk)
-	images = getImages(conn)
-	assert.Len(t, images, 1)
-	builtID := images[0].DockerID
-	assert.NotEmpty(t, builtID, "should save ID of built image")
-	assert.Equal(t, db.Built, images[0].Status)
-
-	// Test ignoring already-built image.
-	md.ResetBuilt()
-	syncImages(conn, dk)
+	buildImages(conn, dk)
+	builtImages := conn.MinionSelf().BuiltImages
+	assert.Len(t, builtImages, 1)
+	assert.NotEmpty(t, builtImages[buildKey(img)])
+	assert.Len(t, md.Built, 1)
 
-	images = getImages(conn)
-	assert.Len(t, images, 1)
-	assert.Equal(t, builtID, images[0].DockerID, "should not change image ID")
-	assert.Equal(t, md.Built, map[docker.BuildImageOptions]struct{}{},
-		"should not attempt to rebuild")
+	// Images that were already built aren't rebuilt.
+	md.ResetBuilt()
+	buildImages(conn, dk)
+	assert.Equal(t, builtImages, conn.MinionSelf().BuiltImages)
+	assert.Empty(t, md.Built)
+
+	// A new leader's registry needs its own build.
+	conn.Txn(db.ImageTable).Run(func(view db.Database) error {
+		dbImg := view.SelectFromImage(func(dbImg db.Image) bool {
+			return dbImg.Name == img.Name
+		})[0]
+		dbImg.Registry = "10.0.0.5"
+		view.Commit(dbImg)
+		return nil
+	})
+	buildImages(conn, dk)
+	assert.Len(t, md.Built, 1)
+	for opts := range md.Built {
+		assert.Equal(t, "10.0.0.5:5000/image", opts.Name)
+	}
+
+	// Images that are no longer placed on the worker are forgotten.
+	conn.Txn(db.ImageTable).Run(func(view db.Database) error {
+		view.Remove(view.SelectFromImage(func(dbImg db.Image) bool {
+			return dbImg.Name == img.Name
+		})[0])
+		return nil
+	})
+	buildImages(conn, dk)
+	assert.Empty(t, conn.MinionSelf().BuiltImages)
 }
 
 func TestUpdateRegistry(t *testing.T) {
 	md, dk := docker.NewMock()
 
-	_, err := updateRegistry(dk, db.Image{
+	_, err := updateRegistry(dk, "10.0.0.1:5000", db.Image{
 		Name:       "mean:tag",
 		Dockerfile: "dockerfile",
 	})
@@ -60,60 +205,45 @@ func TestUpdateRegistry(t *testing.T) {
 
 	assert.Equal(t, map[docker.BuildImageOptions]struct{}{
 		{
-			Name:       "localhost:5000/mean:tag",
+			Name:       "10.0.0.1:5000/mean:tag",
 			Dockerfile: "dockerfile",
-			NoCache:    true,
+			CacheFrom:  "10.0.0.1:5000/mean:tag",
 		}: {},
 	}, md.Built)
 
 	assert.Equal(t, map[dkc.PushImageOptions]struct{}{
 		{
-			Registry: "localhost:5000",
-			Name:     "localhost:5000/mean",
+			Registry: "10.0.0.1:5000",
+			Name:     "10.0.0.1:5000/mean",
 			Tag:      "tag",
 		}: {},
 	}, md.Pushed)
+
+	// The registry not yet holding the image doesn't prevent the build.
+	md.ResetBuilt()
+	md.PullError = true
+	_, err = updateRegistry(dk, "10.0.0.1:5000", db.Image{Name: "new"})
+	assert.NoError(t, err)
+	assert.Len(t, md.Built, 1)
 }
 
-func TestGetImageHandle(t *testing.T) {
+func TestBuildKey(t *testing.T) {
 	t.Parallel()
 
-	// The image that we'll be trying to retrieve.
-	expImg := db.Image{Name: "foo", Dockerfile: "bar"}
-
-	db.New().Txn(db.AllTables...).Run(func(view db.Database) error {
-		// Test no matching images.
-		im := view.InsertImage()
-		im.Name = "other"
-		im.Dockerfile = "ignoreme"
-		view.Commit(im)
-
-		_, err := getImageHandle(view, expImg)
-		assert.NotNil(t, err)
+	img := db.Image{Name: "name", Dockerfile: "dockerfile"}
+	assert.Equal(t, buildKey(img), buildKey(img))
 
-		// Test one matching image.
-		im = view.InsertImage()
-		im.Name = expImg.Name
-		im.Dockerfile = expImg.Dockerfile
-		view.Commit(im)
+	other := img
+	other.Dockerfile = "other"
+	assert.NotEqual(t, buildKey(img), buildKey(other))
 
-		dbImg, err := getImageHandle(view, expImg)
-		assert.NoError(t, err)
-		assert.Equal(t, expImg.Name, dbImg.Name)
-		assert.Equal(t, expImg.Dockerfile, dbImg.Dockerfile)
-		assert.Equal(t, 2, dbImg.ID)
+	other = img
+	other.Name = "other"
+	assert.NotEqual(t, buildKey(img), buildKey(other))
 
-		// Test multiple matching images.
-		im = view.InsertImage()
-		im.Name = expImg.Name
-		im.Dockerfile = expImg.Dockerfile
-		view.Commit(im)
-
-		_, err = getImageHandle(view, expImg)
-		assert.NotNil(t, err)
-
-		return nil
-	})
+	other = img
+	other.Registry = "10.0.0.1"
+	assert.NotEqual(t, buildKey(img), buildKey(other))
 }
 
 func getImages(conn db.Conn) (images []db.Image) {
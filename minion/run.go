@@ -12,8 +12,11 @@ import (
 	tlsIO "github.com/kelda/kelda/connection/tls/io"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/credentials"
+	"github.com/kelda/kelda/minion/disk"
 	"github.com/kelda/kelda/minion/docker"
 	"github.com/kelda/kelda/minion/etcd"
+	"github.com/kelda/kelda/minion/interruption"
 	"github.com/kelda/kelda/minion/network"
 	"github.com/kelda/kelda/minion/network/plugin"
 	"github.com/kelda/kelda/minion/pprofile"
@@ -62,13 +65,18 @@ func Run(role db.Role, inboundPubIntf, outboundPubIntf string) {
 	go network.Run(conn, inboundPubIntf, outboundPubIntf)
 	go registry.Run(conn, dk)
 	go etcd.Run(conn)
+	go disk.Run(conn, dk)
+	go interruption.Run(conn)
+	go credentials.Run(conn)
 	go syncAuthorizedKeys(conn)
+	go localServerRun(conn)
 
 	// Block until the credentials are in place on the local filesystem. We
 	// can't simply fail if the first read fails because the daemon might still
 	// be generating and copying keys onto the local filesystem. The key
 	// installation is handled by SyncCredentials in cloud/credentials.go.
 	var creds connection.Credentials
+	var caCert string
 	err := util.BackoffWaitFor(func() bool {
 		var err error
 		creds, err = tlsIO.ReadCredentials(tlsIO.MinionTLSDir)
@@ -76,6 +84,12 @@ func Run(role db.Role, inboundPubIntf, outboundPubIntf string) {
 			log.WithError(err).Debug("TLS keys not ready yet")
 			return false
 		}
+
+		caCert, err = tlsIO.ReadCACert(tlsIO.MinionTLSDir)
+		if err != nil {
+			log.WithError(err).Debug("TLS keys not ready yet")
+			return false
+		}
 		return true
 	}, 30*time.Second, 1*time.Hour)
 	if err != nil {
@@ -83,13 +97,15 @@ func Run(role db.Role, inboundPubIntf, outboundPubIntf string) {
 		return
 	}
 
-	go minionServerRun(conn, creds)
+	go minionServerRun(conn, creds, caCert)
 	go apiServer.Run(conn, fmt.Sprintf("tcp://0.0.0.0:%d", api.DefaultRemotePort),
 		false, creds)
 
 	loopLog := util.NewEventTimer("Minion-Update")
 
-	for range conn.Trigger(db.MinionTable, db.EtcdTable).C {
+	// The policy is also updated periodically so that load balancers finish
+	// draining their containers on time.
+	for range conn.TriggerTick(10, db.MinionTable, db.EtcdTable).C {
 		loopLog.LogStart()
 		txn := conn.Txn(db.ConnectionTable, db.ContainerTable, db.MinionTable,
 			db.EtcdTable, db.PlacementTable, db.ImageTable,
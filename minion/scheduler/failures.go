@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ContainerFailed is the status prefix given to containers that are
+// quarantined after repeatedly failing to boot.
+const ContainerFailed = "failed"
+
+// A failureTracker keeps track of consecutive boot failures for each container,
+// keyed by BlueprintID.
+type failureTracker struct {
+	sync.Mutex
+	failures map[string]*bootFailure
+}
+
+type bootFailure struct {
+	attempts int
+	err      error
+	retryAt  time.Time
+}
+
+var now = time.Now
+
+func newFailureTracker() *failureTracker {
+	return &failureTracker{failures: map[string]*bootFailure{}}
+}
+
+// record updates the failure count for the container with `id` based on the
+// result of booting it.
+func (ft *failureTracker) record(id string, err error) {
+	ft.Lock()
+	defer ft.Unlock()
+
+	if err == nil {
+		delete(ft.failures, id)
+		return
+	}
+
+	f := ft.failures[id]
+	if f == nil {
+		f = &bootFailure{}
+		ft.failures[id] = f
+	}
+
+	f.attempts++
+	f.err = err
+
+	// Retrying can't help if this machine lacks one of the container's
+	// networks, so the container is quarantined right away.
+	if _, ok := err.(docker.MissingNetworkError); ok {
+		f.attempts = maxBootAttempts
+	}
+
+	if f.attempts >= maxBootAttempts {
+		c.Inc("Quarantine Container")
+		f.retryAt = now().Add(quarantineTime)
+		log.WithError(err).WithField("id", id).Warn(
+			"Container failed to boot too many times. Quarantining it.")
+		return
+	}
+
+	backoff := bootBackoff * time.Duration(1<<uint(f.attempts-1))
+	f.retryAt = now().Add(backoff)
+}
+
+// filter removes the containers that are backing off or quarantined from
+// `toBoot`.  Quarantined containers have their status set to failed, and are
+// appended to `changed`.
+func (ft *failureTracker) filter(changed []db.Container, toBoot []interface{}) (
+	[]db.Container, []interface{}) {
+
+	ft.Lock()
+	defer ft.Unlock()
+
+	ft.expire()
+
+	var bootable []interface{}
+	for _, iface := range toBoot {
+		dbc := iface.(db.Container)
+		f := ft.failures[dbc.BlueprintID]
+		if f == nil || !now().Before(f.retryAt) {
+			bootable = append(bootable, iface)
+			continue
+		}
+
+		if f.attempts >= maxBootAttempts {
+			status := fmt.Sprintf("%s: %s", ContainerFailed, f.err)
+			if dbc.Status != status {
+				dbc.Status = status
+				changed = append(changed, dbc)
+			}
+		}
+	}
+	return changed, bootable
+}
+
+// quarantined returns the sorted BlueprintIDs of the quarantined containers.
+func (ft *failureTracker) quarantined() []string {
+	ft.Lock()
+	defer ft.Unlock()
+
+	ft.expire()
+
+	var ids []string
+	for id, f := range ft.failures {
+		if f.attempts >= maxBootAttempts {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// expire forgets about quarantined containers whose quarantine has ended, so
+// that they may be retried.  The caller must hold the lock.
+func (ft *failureTracker) expire() {
+	for id, f := range ft.failures {
+		if f.attempts >= maxBootAttempts && !now().Before(f.retryAt) {
+			delete(ft.failures, id)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureTracker(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	ft := newFailureTracker()
+	dbc := db.Container{BlueprintID: "a"}
+	other := db.Container{BlueprintID: "b"}
+	toBoot := []interface{}{dbc, other}
+
+	changed, bootable := ft.filter(nil, toBoot)
+	assert.Empty(t, changed)
+	assert.Equal(t, toBoot, bootable)
+
+	// A failure causes the container to back off.
+	ft.record("a", errors.New("no such image"))
+	_, bootable = ft.filter(nil, toBoot)
+	assert.Equal(t, []interface{}{other}, bootable)
+
+	current = current.Add(bootBackoff)
+	_, bootable = ft.filter(nil, toBoot)
+	assert.Equal(t, toBoot, bootable)
+
+	// A successful boot resets the count.
+	ft.record("a", nil)
+	assert.Empty(t, ft.failures)
+
+	for i := 0; i < maxBootAttempts; i++ {
+		assert.Empty(t, ft.quarantined())
+		ft.record("a", errors.New("no such image"))
+	}
+	assert.Equal(t, []string{"a"}, ft.quarantined())
+
+	changed, bootable = ft.filter(nil, toBoot)
+	assert.Equal(t, []interface{}{other}, bootable)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, "failed: no such image", changed[0].Status)
+
+	// Quarantined containers are retried eventually.
+	current = current.Add(quarantineTime)
+	_, bootable = ft.filter(nil, toBoot)
+	assert.Equal(t, toBoot, bootable)
+	assert.Empty(t, ft.quarantined())
+
+	// Containers whose networks are missing are quarantined immediately.
+	ft.record("a", docker.MissingNetworkError{Network: "vpc"})
+	assert.Equal(t, []string{"a"}, ft.quarantined())
+
+	changed, _ = ft.filter(nil, toBoot)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, "failed: network vpc does not exist", changed[0].Status)
+}
+
+func TestBootFailuresFilter(t *testing.T) {
+	t.Parallel()
+
+	minions := []db.Minion{
+		{PrivateIP: "1", Role: db.Worker, FailedContainers: []string{"1"}},
+		{PrivateIP: "2", Role: db.Worker},
+	}
+	containers := []db.Container{
+		{ID: 1, BlueprintID: "1", Minion: "1"},
+	}
+
+	ctx := makeContext(minions, nil, containers, nil)
+	cleanupPlacements(ctx)
+	placeUnassigned(ctx)
+
+	assert.Equal(t, "2", containers[0].Minion)
+}
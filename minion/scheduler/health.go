@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The health checks run much more often than the scheduler so that load balancers
+// react to container failures quickly.
+const healthCheckInterval = 2 * time.Second
+
+func runHealthChecks(conn db.Conn, dk docker.Client) {
+	for range time.Tick(healthCheckInterval) {
+		self := conn.MinionSelf()
+		if self.Role == db.Worker && self.PrivateIP != "" {
+			healthCheckOnce(conn, dk, self.PrivateIP)
+		}
+	}
+}
+
+// healthCheckOnce publishes the IPs of the containers assigned to this worker that
+// aren't running in its minion row.  The row is shared with every other minion
+// through Etcd.
+func healthCheckOnce(conn db.Conn, dk docker.Client, myIP string) {
+	dkcs, err := dk.List(map[string][]string{"label": {labelPair}})
+	if err != nil {
+		log.WithError(err).Warning("Failed to list docker containers.")
+		return
+	}
+
+	// List only returns running containers.
+	running := map[string]struct{}{}
+	for _, dkc := range dkcs {
+		running[dkc.IP] = struct{}{}
+	}
+
+	conn.Txn(db.ContainerTable, db.MinionTable).Run(func(view db.Database) error {
+		var unhealthy []string
+		for _, dbc := range view.SelectFromContainer(func(dbc db.Container) bool {
+			return dbc.IP != "" && dbc.Minion == myIP
+		}) {
+			if _, ok := running[dbc.IP]; !ok {
+				unhealthy = append(unhealthy, dbc.IP)
+			}
+		}
+		sort.Strings(unhealthy)
+
+		self := view.MinionSelf()
+		if !util.StrSliceEqual(self.UnhealthyContainers, unhealthy) {
+			c.Inc("Container Health Changed")
+			self.UnhealthyContainers = unhealthy
+			view.Commit(self)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	md, dk := docker.NewMock()
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, ip := range []string{"10.0.0.2", "10.0.0.3"} {
+			dbc := view.InsertContainer()
+			dbc.IP = ip
+			dbc.Minion = "1.2.3.4"
+			view.Commit(dbc)
+		}
+
+		dbc := view.InsertContainer()
+		dbc.IP = "10.0.0.4"
+		dbc.Minion = "5.6.7.8"
+		view.Commit(dbc)
+
+		m := view.InsertMinion()
+		m.Self = true
+		m.PrivateIP = "1.2.3.4"
+		view.Commit(m)
+		return nil
+	})
+	unhealthy := func() []string {
+		return conn.MinionSelf().UnhealthyContainers
+	}
+
+	healthCheckOnce(conn, dk, "1.2.3.4")
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, unhealthy())
+
+	id, err := dk.Run(docker.RunOptions{
+		Image:  "image",
+		Labels: map[string]string{labelKey: labelValue},
+	})
+	assert.NoError(t, err)
+
+	// The mock doesn't assign IPs to containers.
+	md.Containers[id].NetworkSettings.IPAddress = "10.0.0.2"
+	healthCheckOnce(conn, dk, "1.2.3.4")
+	assert.Equal(t, []string{"10.0.0.3"}, unhealthy())
+
+	md.StopContainer(id)
+	healthCheckOnce(conn, dk, "1.2.3.4")
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, unhealthy())
+
+	// Failing to list containers leaves the previous result.
+	md.ListError = true
+	healthCheckOnce(conn, dk, "1.2.3.4")
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, unhealthy())
+}
@@ -3,92 +3,383 @@ package scheduler
 import (
 	"container/heap"
 	"fmt"
+	"io/ioutil"
 	"sort"
+	"strings"
+	"sync"
 
+	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/recorder"
 	"github.com/kelda/kelda/util"
 	log "github.com/sirupsen/logrus"
 )
 
-type minion struct {
-	db.Minion
-	containers []*db.Container
-}
-
 type context struct {
-	minions     []*minion
-	constraints []db.Placement
-	unassigned  []*db.Container
-	changed     []*db.Container
+	Cluster
+
+	unassigned []*db.Container
+	changed    []*db.Container
+
+	// Records decisions that can't be told apart from the changed containers
+	// alone, such as evictions.  Nil if decisions aren't recorded.
+	rj *recorder.Join
+
+	// Simulated placements aren't counted or logged, as they don't affect the
+	// cluster.
+	simulated bool
 }
 
+// A connected container on the same minion is worth `1`, while a connected
+// container elsewhere in the same region is worth `sameRegionScore`.
+const sameRegionScore = 0.25
+
 func runMaster(conn db.Conn) {
 	if !conn.EtcdLeader() {
 		return
 	}
 
-	conn.Txn(db.ContainerTable, db.MinionTable, db.ImageTable,
+	conn.Txn(db.ConnectionTable, db.ContainerTable, db.MinionTable, db.ImageTable,
 		db.PlacementTable).Run(func(view db.Database) error {
 		placeContainers(view)
+		updateConnectionStatus(view)
 		return nil
 	})
 }
 
+// The number of connection sets that the leader remembers.  Workers learn about
+// connections from the leader, so the hash that a worker reports is usually that
+// of one of the leader's recent sets.
+const maxConnectionHistory = 16
+
+// The sets of connection keys that the leader recently deployed, keyed by their
+// hash, so that the connections that a worker programmed can be recovered from
+// the hash that it reports.
+var connectionHistory = struct {
+	sync.Mutex
+	keys   map[string][]string
+	hashes []string
+}{keys: map[string][]string{}}
+
+// rememberConnections records `keys` in connectionHistory, and returns its hash.
+func rememberConnections(keys []string) string {
+	hash := db.HashConnectionKeys(keys)
+
+	connectionHistory.Lock()
+	defer connectionHistory.Unlock()
+	if _, ok := connectionHistory.keys[hash]; ok {
+		return hash
+	}
+
+	connectionHistory.keys[hash] = keys
+	connectionHistory.hashes = append(connectionHistory.hashes, hash)
+	if len(connectionHistory.hashes) > maxConnectionHistory {
+		delete(connectionHistory.keys, connectionHistory.hashes[0])
+		connectionHistory.hashes = connectionHistory.hashes[1:]
+	}
+	return hash
+}
+
+// recallConnections returns the connection keys whose hash is `hash`, if they're
+// among the sets in connectionHistory.
+func recallConnections(hash string) ([]string, bool) {
+	connectionHistory.Lock()
+	defer connectionHistory.Unlock()
+	keys, ok := connectionHistory.keys[hash]
+	return keys, ok
+}
+
+// updateConnectionStatus records the workers that have yet to install the rules
+// for each connection, according to the hash of the connections that the workers
+// report as programmed.  If a worker's hash isn't that of a set that the leader
+// remembers, such as after the leader changes, every connection is pending on it
+// until it catches up.
+func updateConnectionStatus(view db.Database) {
+	conns := view.SelectFromConnection(nil)
+	rememberConnections(db.ConnectionKeys(conns))
+
+	programmed := map[string]map[string]bool{}
+	var workers []string
+	for _, m := range view.SelectFromMinion(nil) {
+		if m.Role != db.Worker || m.PrivateIP == "" {
+			continue
+		}
+
+		workers = append(workers, m.PrivateIP)
+		programmed[m.PrivateIP] = map[string]bool{}
+		keys, _ := recallConnections(m.ProgrammedConnections)
+		for _, key := range keys {
+			programmed[m.PrivateIP][key] = true
+		}
+	}
+	sort.Strings(workers)
+
+	for _, dbc := range conns {
+		var pending []string
+		for _, ip := range workers {
+			if !programmed[ip][dbc.Key()] {
+				pending = append(pending, ip)
+			}
+		}
+
+		if !util.StrSliceEqual(dbc.Pending, pending) {
+			dbc.Pending = pending
+			view.Commit(dbc)
+		}
+	}
+}
+
 func placeContainers(view db.Database) {
 	constraints := view.SelectFromPlacement(nil)
 	containers := view.SelectFromContainer(nil)
 	minions := view.SelectFromMinion(nil)
 	images := view.SelectFromImage(nil)
 
+	// The context modifies `containers` in place, so the recorder is given a
+	// copy of the inputs.
+	rj := rec.Join(minions, constraints, append([]db.Container(nil),
+		containers...))
+
 	ctx := makeContext(minions, constraints, containers, images)
+	ctx.rj = rj
+	ctx.Connections = view.SelectFromConnection(nil)
+	ctx.neighbors = makeNeighbors(ctx.Connections)
 	cleanupPlacements(ctx)
 	placeUnassigned(ctx)
 
 	for _, change := range ctx.changed {
+		recordPlacement(rj, *change)
 		view.Commit(*change)
 	}
 }
 
+func recordPlacement(rj *recorder.Join, dbc db.Container) {
+	if dbc.Minion == "" {
+		rj.Decide("unassign", dbc.BlueprintID)
+	} else {
+		rj.Decide("place", fmt.Sprintf("%s on %s", dbc.BlueprintID, dbc.Minion))
+	}
+}
+
 // Unassign all containers that are placed incorrectly.
 func cleanupPlacements(ctx *context) {
-	for _, m := range ctx.minions {
+	for _, m := range ctx.Nodes {
 		var valid []*db.Container
-		for _, dbc := range m.containers {
-			if validPlacement(ctx.constraints, *m, valid, dbc) {
+		for _, dbc := range m.Containers {
+			if ctx.feasible(m, valid, dbc) {
 				valid = append(valid, dbc)
 				continue
 			}
-			c.Inc("Reschedule Container")
+			ctx.inc("Reschedule Container")
 			dbc.Minion = ""
 			ctx.unassigned = append(ctx.unassigned, dbc)
 			ctx.changed = append(ctx.changed, dbc)
 		}
-		m.containers = valid
+		m.Containers = valid
 	}
 }
 
 func placeUnassigned(ctx *context) {
-	minions := minionHeap(ctx.minions)
+	minions := minionHeap(ctx.Nodes)
 	heap.Init(&minions)
 
-Outer:
-	for _, dbc := range ctx.unassigned {
+	// Containers evicted by higher priority containers are appended to the
+	// queue so that they get a chance to be placed elsewhere.
+	queue := ctx.unassigned
+	for len(queue) > 0 {
+		dbc := queue[0]
+		queue = queue[1:]
+
+		// Choose the feasible minion with the best score. Ties are broken by
+		// the heap order, which prefers less loaded minions.
+		best := -1
+		var bestScore float64
 		for i, m := range minions {
-			if validPlacement(ctx.constraints, *m, m.containers, dbc) {
-				c.Inc("Place Container")
-				dbc.Minion = m.PrivateIP
-				ctx.changed = append(ctx.changed, dbc)
-				m.containers = append(m.containers, dbc)
-				heap.Fix(&minions, i)
-				log.WithField("container", dbc).Info("Placed container.")
-				continue Outer
+			if !ctx.feasible(m, m.Containers, dbc) {
+				continue
+			}
+
+			score := ctx.score(m, dbc)
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
 			}
 		}
 
-		log.WithField("container", dbc).Warning("Failed to place container.")
+		if best >= 0 {
+			m := minions[best]
+			ctx.inc("Place Container")
+			dbc.Minion = m.PrivateIP
+			ctx.changed = append(ctx.changed, dbc)
+			m.Containers = append(m.Containers, dbc)
+			heap.Fix(&minions, best)
+			ctx.log().WithField("container", dbc).Info("Placed container.")
+			continue
+		}
+
+		if evicted := preempt(ctx, dbc); evicted != nil {
+			queue = append(queue, evicted...)
+			heap.Init(&minions)
+			continue
+		}
+
+		ctx.log().WithField("container", dbc).Warning("Failed to place container.")
 	}
 }
 
+// preempt attempts to place `dbc` by evicting lower priority containers.  It
+// chooses the minion that requires the fewest evictions, and returns the
+// evicted containers, or nil if no such minion exists.
+func preempt(ctx *context, dbc *db.Container) []*db.Container {
+	var best *Node
+	var bestKeep, bestEvict []*db.Container
+	for _, m := range ctx.Nodes {
+		var keep, evict []*db.Container
+		for _, peer := range m.Containers {
+			if peer.Priority < dbc.Priority &&
+				!ctx.feasible(m, []*db.Container{peer}, dbc) {
+				evict = append(evict, peer)
+			} else {
+				keep = append(keep, peer)
+			}
+		}
+
+		if len(evict) == 0 || !ctx.feasible(m, keep, dbc) {
+			continue
+		}
+
+		if best == nil || len(evict) < len(bestEvict) {
+			best, bestKeep, bestEvict = m, keep, evict
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	for _, victim := range bestEvict {
+		ctx.inc("Evict Container")
+		ctx.decide("evict", fmt.Sprintf("%s from %s for %s",
+			victim.BlueprintID, best.PrivateIP, dbc.BlueprintID))
+		victim.Minion = ""
+		ctx.changed = append(ctx.changed, victim)
+		ctx.log().WithFields(log.Fields{
+			"container":   victim,
+			"preemptedBy": dbc.BlueprintID,
+			"minion":      best.PrivateIP,
+		}).Info("Evicted container to make room for higher priority container.")
+	}
+
+	ctx.inc("Place Container")
+	dbc.Minion = best.PrivateIP
+	ctx.changed = append(ctx.changed, dbc)
+	best.Containers = append(bestKeep, dbc)
+	ctx.log().WithField("container", dbc).Info("Placed container.")
+	return bestEvict
+}
+
+// localityScore rates how close `m` is to the containers that `dbc` has
+// connections with.  Placing communicating containers on the same machine, or
+// at least in the same region, avoids paying for cross-zone traffic.
+func localityScore(cluster *Cluster, m *Node, dbc *db.Container) float64 {
+	neighbors := cluster.neighbors[dbc.Hostname]
+	if len(neighbors) == 0 {
+		return 0
+	}
+
+	var score float64
+	for _, other := range cluster.Nodes {
+		weight := sameRegionScore
+		if other == m {
+			weight = 1
+		} else if other.Region != m.Region {
+			continue
+		}
+
+		for _, peer := range other.Containers {
+			if _, ok := neighbors[peer.Hostname]; ok {
+				score += weight
+			}
+		}
+	}
+	return score
+}
+
+// makeNeighbors builds an undirected graph of the hostnames that have
+// connections between them.
+func makeNeighbors(conns []db.Connection) map[string]map[string]struct{} {
+	neighbors := map[string]map[string]struct{}{}
+	addEdge := func(from, to string) {
+		if neighbors[from] == nil {
+			neighbors[from] = map[string]struct{}{}
+		}
+		neighbors[from][to] = struct{}{}
+	}
+
+	for _, conn := range conns {
+		if conn.From == conn.To {
+			continue
+		}
+		addEdge(conn.From, conn.To)
+		addEdge(conn.To, conn.From)
+	}
+	return neighbors
+}
+
+// PortPlacements creates exclusive placement rules such that no two containers
+// listening on the same public port get placed on the same machine.
+func PortPlacements(connections []db.Connection, containers []db.Container) (
+	placements []db.Placement) {
+
+	hostnameToContainer := map[string]db.Container{}
+	for _, c := range containers {
+		hostnameToContainer[c.Hostname] = c
+	}
+
+	ports := make(map[int][]string)
+	for _, conn := range connections {
+		if conn.From != blueprint.PublicInternetLabel {
+			continue
+		}
+
+		toContainer, ok := hostnameToContainer[conn.To]
+		if !ok {
+			log.WithField("connection", conn).
+				WithField("hostname", conn.To).
+				Warn("Public connection in terms of unknown hostname." +
+					"Ignoring.")
+			continue
+		}
+
+		// XXX: Public connections do not currently support ranges, so we can
+		// safely consider just the MinPort.
+		ports[conn.MinPort] = append(ports[conn.MinPort], toContainer.BlueprintID)
+	}
+
+	// Create placement rules for all combinations of containers that listen on
+	// the same port. We do not need to create a rule for every permutation
+	// because order does not matter for the `TargetContainer` and
+	// `OtherContainer` fields -- the placement is equivalent if the two fields
+	// are swapped.  We do so by creating a placement rule between each
+	// container, and the containers after it. There is no need to create rules
+	// for the preceding containers because the previous rules will have
+	// covered it.
+	for _, cids := range ports {
+		for i, tgt := range cids {
+			for _, other := range cids[i+1:] {
+				placements = append(placements,
+					db.Placement{
+						Exclusive:       true,
+						TargetContainer: tgt,
+						OtherContainer:  other,
+					},
+				)
+			}
+		}
+	}
+
+	return placements
+}
+
 func canBeColocated(constraint db.Placement, toPlace db.Container,
 	peers []*db.Container) bool {
 	if !constraint.Exclusive {
@@ -113,7 +404,9 @@ func canBeColocated(constraint db.Placement, toPlace db.Container,
 	return true
 }
 
-func validPlacement(constraints []db.Placement, m minion, peers []*db.Container,
+// validPlacement implements the built in filter that enforces the placement
+// constraints from the blueprint.
+func validPlacement(constraints []db.Placement, m Node, peers []*db.Container,
 	dbc *db.Container) bool {
 
 	for _, constraint := range constraints {
@@ -154,25 +447,43 @@ func validPlacement(constraints []db.Placement, m minion, peers []*db.Container,
 				return false
 			}
 		}
+
+		if constraint.Label != "" {
+			on := HasLabel(m.Labels, constraint.Label)
+			if constraint.Exclusive == on {
+				return false
+			}
+		}
 	}
 
 	return true
 }
 
+// HasLabel returns whether `labels` contain `label`, which is in the form
+// "key=value".
+func HasLabel(labels map[string]string, label string) bool {
+	parts := strings.SplitN(label, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	val, ok := labels[parts[0]]
+	return ok && val == parts[1]
+}
+
 func makeContext(minions []db.Minion, constraints []db.Placement,
 	containers []db.Container, images []db.Image) *context {
 
 	ctx := context{}
-	ctx.constraints = constraints
+	ctx.Placements = constraints
 
-	ipMinion := map[string]*minion{}
+	ipMinion := map[string]*Node{}
 	for _, dbm := range minions {
 		if dbm.Role != db.Worker || dbm.PrivateIP == "" {
 			continue
 		}
 
-		m := minion{dbm, nil}
-		ctx.minions = append(ctx.minions, &m)
+		m := Node{dbm, nil}
+		ctx.Nodes = append(ctx.Nodes, &m)
 		ipMinion[m.PrivateIP] = &m
 	}
 
@@ -194,6 +505,16 @@ func makeContext(minions []db.Minion, constraints []db.Placement,
 			ctx.changed = append(ctx.changed, dbc)
 		}
 
+		// Stopped containers are torn down, but keep their row, and thus their
+		// IP and hostname, so that they resume with the same identity.
+		if dbc.Stopped {
+			if dbc.Minion != "" {
+				dbc.Minion = ""
+				ctx.changed = append(ctx.changed, dbc)
+			}
+			continue
+		}
+
 		// If the container is built by Quilt, only schedule it if the image
 		// has been built.
 		if dbc.Dockerfile != "" {
@@ -215,7 +536,7 @@ func makeContext(minions []db.Minion, constraints []db.Placement,
 			continue
 		}
 
-		minion.containers = append(minion.containers, dbc)
+		minion.Containers = append(minion.Containers, dbc)
 	}
 
 	// XXX: We sort containers based on their image and command in an effort to
@@ -226,9 +547,31 @@ func makeContext(minions []db.Minion, constraints []db.Placement,
 	return &ctx
 }
 
+func (ctx *context) inc(name string) {
+	if !ctx.simulated {
+		c.Inc(name)
+	}
+}
+
+func (ctx *context) decide(action string, target interface{}) {
+	if !ctx.simulated && ctx.rj != nil {
+		ctx.rj.Decide(action, target)
+	}
+}
+
+var discardLog = &log.Logger{Out: ioutil.Discard,
+	Formatter: new(log.TextFormatter)}
+
+func (ctx *context) log() log.FieldLogger {
+	if ctx.simulated {
+		return discardLog
+	}
+	return log.StandardLogger()
+}
+
 // Minion Heap.  Minions are sorted based on the number of containers scheduled on them
 // with fewer containers being higher priority.
-type minionHeap []*minion
+type minionHeap []*Node
 
 func (mh minionHeap) Len() int      { return len(mh) }
 func (mh minionHeap) Swap(i, j int) { mh[i], mh[j] = mh[j], mh[i] }
@@ -238,13 +581,17 @@ func (mh *minionHeap) Push(x interface{}) { panic("Not Reached") }
 func (mh *minionHeap) Pop() interface{}   { panic("Not Reached") }
 
 func (mh minionHeap) Less(i, j int) bool {
-	return len(mh[i].containers) < len(mh[j].containers)
+	return len(mh[i].Containers) < len(mh[j].Containers)
 }
 
 type dbcSlice []*db.Container
 
 func (s dbcSlice) Less(i, j int) bool {
 	switch {
+	case s[i].Priority != s[j].Priority:
+		// Place higher priority containers first so that they are less
+		// likely to need to evict anything.
+		return s[i].Priority > s[j].Priority
 	case s[i].Image != s[j].Image:
 		return s[i].Image < s[j].Image
 	case !util.StrSliceEqual(s[i].Command, s[j].Command):
@@ -1,11 +1,13 @@
 package scheduler
 
 import (
+	"fmt"
 	"sort"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/recorder"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,6 +43,110 @@ func TestPlaceContainers(t *testing.T) {
 	})
 }
 
+func TestUpdateConnectionStatus(t *testing.T) {
+	t.Parallel()
+	conn := db.New()
+
+	webToDB := db.Connection{From: "web", To: "db", MinPort: 80, MaxPort: 80}
+	programmed := db.HashConnectionKeys([]string{webToDB.Key()})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, m := range []db.Minion{
+			{Role: db.Worker, PrivateIP: "2",
+				ProgrammedConnections: programmed},
+			{Role: db.Worker, PrivateIP: "1"},
+			{Role: db.Master, PrivateIP: "3"},
+		} {
+			m.ID = view.InsertMinion().ID
+			view.Commit(m)
+		}
+
+		dbc := webToDB
+		dbc.ID = view.InsertConnection().ID
+		view.Commit(dbc)
+		return nil
+	})
+
+	pending := func() []string {
+		var res []string
+		conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+			updateConnectionStatus(view)
+			res = view.SelectFromConnection(nil)[0].Pending
+			return nil
+		})
+		return res
+	}
+
+	// Masters don't install connections, so only the workers are pending.
+	assert.Equal(t, []string{"1"}, pending())
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.SelectFromMinion(func(m db.Minion) bool {
+			return m.PrivateIP == "1"
+		})[0]
+		m.ProgrammedConnections = programmed
+		view.Commit(m)
+		return nil
+	})
+	assert.Empty(t, pending())
+
+	// After another connection is added, the workers that programmed the
+	// previous set are still known to implement the connections in it.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		dbc := view.InsertConnection()
+		dbc.From = "app"
+		dbc.To = "web"
+		dbc.MinPort = 80
+		dbc.MaxPort = 80
+		view.Commit(dbc)
+		return nil
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		updateConnectionStatus(view)
+		for _, dbc := range view.SelectFromConnection(nil) {
+			if dbc.From == "web" {
+				assert.Empty(t, dbc.Pending)
+			} else {
+				assert.Equal(t, []string{"1", "2"}, dbc.Pending)
+			}
+		}
+		return nil
+	})
+
+	// Workers whose hash is unknown, such as after the leader changes, are
+	// pending until they catch up.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.SelectFromMinion(func(m db.Minion) bool {
+			return m.PrivateIP == "1"
+		})[0]
+		m.ProgrammedConnections = "unknown"
+		view.Commit(m)
+		return nil
+	})
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		updateConnectionStatus(view)
+		for _, dbc := range view.SelectFromConnection(nil) {
+			assert.Contains(t, dbc.Pending, "1")
+		}
+		return nil
+	})
+}
+
+func TestConnectionHistory(t *testing.T) {
+	t.Parallel()
+
+	first := rememberConnections([]string{"history-first"})
+	keys, ok := recallConnections(first)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"history-first"}, keys)
+
+	// Only the most recent sets are remembered.
+	for i := 0; i < maxConnectionHistory; i++ {
+		rememberConnections([]string{fmt.Sprintf("history-%d", i)})
+	}
+	_, ok = recallConnections(first)
+	assert.False(t, ok)
+}
+
 func TestCleanup(t *testing.T) {
 	t.Parallel()
 
@@ -76,14 +182,14 @@ func TestCleanup(t *testing.T) {
 	ctx := makeContext(minions, placements, containers, nil)
 	cleanupPlacements(ctx)
 
-	expMinions := []*minion{
+	expMinions := []*Node{
 		{
 			Minion:     minions[0],
-			containers: []*db.Container{&containers[1]},
+			Containers: []*db.Container{&containers[1]},
 		},
 	}
-	assert.Equal(t, expMinions, ctx.minions)
-	assert.Equal(t, placements, ctx.constraints)
+	assert.Equal(t, expMinions, ctx.Nodes)
+	assert.Equal(t, placements, ctx.Placements)
 
 	expUnassigned := []*db.Container{
 		{
@@ -146,23 +252,23 @@ func TestCleanupContainerRule(t *testing.T) {
 	ctx := makeContext(minions, placements, containers, nil)
 	cleanupPlacements(ctx)
 
-	expMinions := []*minion{
+	expMinions := []*Node{
 		{
 			Minion: minions[0],
-			containers: []*db.Container{
+			Containers: []*db.Container{
 				&containers[0],
 			},
 		},
 		{
 			Minion: minions[1],
-			containers: []*db.Container{
+			Containers: []*db.Container{
 				&containers[2],
 			},
 		},
 	}
 
-	assert.Equal(t, expMinions, ctx.minions)
-	assert.Equal(t, placements, ctx.constraints)
+	assert.Equal(t, expMinions, ctx.Nodes)
+	assert.Equal(t, placements, ctx.Placements)
 
 	expUnassigned := []*db.Container{
 		&containers[1],
@@ -248,6 +354,115 @@ func TestPlaceUnassigned(t *testing.T) {
 	assert.Nil(t, ctx.changed)
 }
 
+func TestPreempt(t *testing.T) {
+	recorder.Enabled = true
+	defer func() { recorder.Enabled = false }()
+
+	minions := []db.Minion{
+		{PrivateIP: "1", Role: db.Worker},
+		{PrivateIP: "2", Role: db.Worker},
+	}
+	containers := []db.Container{
+		{ID: 1, BlueprintID: "low1", Minion: "1", Priority: -10},
+		{ID: 2, BlueprintID: "low2", Minion: "2", Priority: -10},
+		{ID: 3, BlueprintID: "other", Minion: "2", Priority: -10},
+		{ID: 4, BlueprintID: "high", Priority: 10},
+	}
+
+	// The high priority container can't share a machine with either of the
+	// low priority containers, so one of them has to go.
+	placements := []db.Placement{
+		{Exclusive: true, TargetContainer: "high", OtherContainer: "low1"},
+		{Exclusive: true, TargetContainer: "high", OtherContainer: "low2"},
+		{Exclusive: true, TargetContainer: "low1", OtherContainer: "low2"},
+		{Exclusive: true, TargetContainer: "low1", OtherContainer: "other"},
+	}
+
+	ctx := makeContext(minions, placements, containers, nil)
+	ctx.rj = recorder.New("TestPreempt").Join(containers)
+	placeUnassigned(ctx)
+
+	minionOf := map[string]string{}
+	for _, dbc := range ctx.changed {
+		minionOf[dbc.BlueprintID] = dbc.Minion
+	}
+
+	// "low1" has to be evicted, and can't be rescheduled anywhere else.
+	assert.Equal(t, map[string]string{"high": "1", "low1": ""}, minionOf)
+
+	// The eviction is recorded, so that operators can find out why the
+	// container was unassigned.
+	var evictions []string
+	for _, decision := range recorder.Dump() {
+		if decision.Module == "TestPreempt" && decision.Action == "evict" {
+			evictions = append(evictions, decision.Target)
+		}
+	}
+	assert.Equal(t, []string{"low1 from 1 for high"}, evictions)
+
+	// Containers can't evict containers of equal priority.
+	containers[3].Priority = -10
+	ctx = makeContext(minions, placements, containers, nil)
+	placeUnassigned(ctx)
+	assert.Nil(t, ctx.changed)
+}
+
+func TestPlaceLocality(t *testing.T) {
+	minions := []db.Minion{
+		{PrivateIP: "1", Region: "a", Role: db.Worker},
+		{PrivateIP: "2", Region: "a", Role: db.Worker},
+		{PrivateIP: "3", Region: "b", Role: db.Worker},
+	}
+	containers := func() []db.Container {
+		return []db.Container{
+			{ID: 1, BlueprintID: "1", Hostname: "web", Minion: "3"},
+			{ID: 2, BlueprintID: "2", Hostname: "db"},
+		}
+	}
+	conns := []db.Connection{{From: "web", To: "db", MinPort: 5432}}
+
+	ctx := makeContext(minions, nil, containers(), nil)
+	ctx.neighbors = makeNeighbors(conns)
+	placeUnassigned(ctx)
+	assert.Len(t, ctx.changed, 1)
+	assert.Equal(t, "3", ctx.changed[0].Minion)
+
+	// Without locality, the container goes to the least loaded minion.
+	LocalityWeight = 0
+	defer func() { LocalityWeight = 1 }()
+	ctx = makeContext(minions, nil, containers(), nil)
+	ctx.neighbors = makeNeighbors(conns)
+	placeUnassigned(ctx)
+	assert.Len(t, ctx.changed, 1)
+	assert.NotEqual(t, "3", ctx.changed[0].Minion)
+}
+
+func TestLocalityScore(t *testing.T) {
+	t.Parallel()
+
+	web := &db.Container{Hostname: "web"}
+	cache := &db.Container{Hostname: "cache"}
+	other := &db.Container{Hostname: "other"}
+
+	m1 := &Node{db.Minion{Region: "a"}, []*db.Container{web}}
+	m2 := &Node{db.Minion{Region: "a"}, []*db.Container{cache, other}}
+	m3 := &Node{db.Minion{Region: "b"}, nil}
+	cluster := &Cluster{
+		Nodes: []*Node{m1, m2, m3},
+		neighbors: makeNeighbors([]db.Connection{
+			{From: "web", To: "db"},
+			{From: "db", To: "cache"},
+			{From: "db", To: "db"},
+		}),
+	}
+
+	dbc := &db.Container{Hostname: "db"}
+	assert.Equal(t, 1+sameRegionScore, localityScore(cluster, m1, dbc))
+	assert.Equal(t, 1+sameRegionScore, localityScore(cluster, m2, dbc))
+	assert.Equal(t, 0.0, localityScore(cluster, m3, dbc))
+	assert.Equal(t, 0.0, localityScore(cluster, m1, other))
+}
+
 func TestMakeContext(t *testing.T) {
 	t.Parallel()
 
@@ -313,6 +528,16 @@ func TestMakeContext(t *testing.T) {
 			Image:      "qux",
 			Dockerfile: "quuz",
 		},
+		// Stopped containers are unassigned, but not placed.
+		{
+			ID:      7,
+			Minion:  "2",
+			Stopped: true,
+		},
+		{
+			ID:      8,
+			Stopped: true,
+		},
 	}
 	placements := []db.Placement{
 		{
@@ -323,24 +548,24 @@ func TestMakeContext(t *testing.T) {
 	}
 
 	ctx := makeContext(minions, placements, containers, images)
-	assert.Equal(t, placements, ctx.constraints)
+	assert.Equal(t, placements, ctx.Placements)
 
-	expMinions := []*minion{
+	expMinions := []*Node{
 		{
 			Minion:     minions[0],
-			containers: []*db.Container{&containers[1]},
+			Containers: []*db.Container{&containers[1]},
 		},
 		{
 			Minion:     minions[1],
-			containers: nil,
+			Containers: nil,
 		},
 	}
-	assert.Equal(t, expMinions, ctx.minions)
+	assert.Equal(t, expMinions, ctx.Nodes)
 
 	expUnassigned := []*db.Container{&containers[0], &containers[2], &containers[3]}
 	assert.Equal(t, expUnassigned, ctx.unassigned)
 
-	expChanged := []*db.Container{&containers[2], &containers[3]}
+	expChanged := []*db.Container{&containers[2], &containers[3], &containers[6]}
 	assert.Equal(t, expChanged, ctx.changed)
 }
 
@@ -348,7 +573,7 @@ func TestValidPlacementTwoWay(t *testing.T) {
 	t.Parallel()
 
 	dbc := &db.Container{ID: 1, BlueprintID: "red"}
-	m := minion{
+	m := Node{
 		db.Minion{
 			PrivateIP: "1.2.3.4",
 			Provider:  "Provider",
@@ -359,7 +584,7 @@ func TestValidPlacementTwoWay(t *testing.T) {
 	}
 
 	dbc1 := &db.Container{ID: 4, BlueprintID: "blue"}
-	m1 := minion{
+	m1 := Node{
 		db.Minion{
 			PrivateIP: "1.2.3.4",
 			Provider:  "Provider",
@@ -379,19 +604,19 @@ func TestValidPlacementTwoWay(t *testing.T) {
 
 	testCases := []struct {
 		dbc *db.Container
-		m   minion
+		m   Node
 	}{
 		{dbc, m},
 		{dbc1, m1},
 	}
 
 	for _, testCase := range testCases {
-		res := validPlacement(constraints, testCase.m, testCase.m.containers,
+		res := validPlacement(constraints, testCase.m, testCase.m.Containers,
 			testCase.dbc)
 		if res {
 			t.Fatalf("Succeeded with bad placement: %s on %s",
 				testCase.dbc.BlueprintID,
-				testCase.m.containers[0].BlueprintID)
+				testCase.m.Containers[0].BlueprintID)
 		}
 	}
 }
@@ -404,12 +629,12 @@ func TestValidPlacementContainer(t *testing.T) {
 		BlueprintID: "red",
 	}
 
-	m := minion{}
+	m := Node{}
 	m.PrivateIP = "1.2.3.4"
 	m.Provider = "Provider"
 	m.Size = "Size"
 	m.Region = "Region"
-	m.containers = []*db.Container{
+	m.Containers = []*db.Container{
 		dbc,
 		{
 			ID:          2,
@@ -428,7 +653,7 @@ func TestValidPlacementContainer(t *testing.T) {
 			OtherContainer:  "orange",
 		},
 	}
-	res := validPlacement(constraints, m, m.containers, dbc)
+	res := validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -438,7 +663,7 @@ func TestValidPlacementContainer(t *testing.T) {
 			OtherContainer:  "blue",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	var empty []*db.Container
@@ -452,7 +677,7 @@ func TestValidPlacementContainer(t *testing.T) {
 			OtherContainer:  "yellow",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	constraints = []db.Placement{
@@ -462,7 +687,7 @@ func TestValidPlacementContainer(t *testing.T) {
 			OtherContainer:  "magenta",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -472,7 +697,7 @@ func TestValidPlacementContainer(t *testing.T) {
 			OtherContainer:  "yellow",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 }
 
@@ -485,13 +710,13 @@ func TestValidPlacementMachine(t *testing.T) {
 		BlueprintID: "red",
 	}
 
-	m := minion{}
+	m := Node{}
 	m.PrivateIP = "1.2.3.4"
 	m.Provider = "Provider"
 	m.Size = "Size"
 	m.Region = "Region"
 
-	res := validPlacement(constraints, m, m.containers, dbc)
+	res := validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -501,7 +726,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Provider:        "Provider",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -511,7 +736,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Provider:        "Provider",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	constraints = []db.Placement{
@@ -521,7 +746,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Provider:        "NotProvider",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	// Region
@@ -532,7 +757,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Region:          "Region",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -542,7 +767,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Region:          "Region",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	constraints = []db.Placement{
@@ -552,7 +777,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Region:          "NoRegion",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	// Size
@@ -563,7 +788,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Size:            "Size",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -573,7 +798,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Size:            "Size",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	constraints = []db.Placement{
@@ -583,7 +808,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Size:            "NoSize",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 
 	// Combination
@@ -604,7 +829,7 @@ func TestValidPlacementMachine(t *testing.T) {
 			Provider:        "Provider",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.True(t, res)
 
 	constraints = []db.Placement{
@@ -624,10 +849,34 @@ func TestValidPlacementMachine(t *testing.T) {
 			Provider:        "Provider",
 		},
 	}
-	res = validPlacement(constraints, m, m.containers, dbc)
+	res = validPlacement(constraints, m, m.Containers, dbc)
 	assert.False(t, res)
 }
 
+func TestValidPlacementLabel(t *testing.T) {
+	t.Parallel()
+
+	dbc := &db.Container{BlueprintID: "red"}
+	m := Node{}
+	m.Labels = map[string]string{"ssd": "true", "compliance": "pci"}
+
+	check := func(exp, exclusive bool, label string) {
+		constraints := []db.Placement{
+			{TargetContainer: "red", Exclusive: exclusive, Label: label},
+		}
+		assert.Equal(t, exp, validPlacement(constraints, m, m.Containers, dbc),
+			label)
+	}
+
+	check(true, false, "ssd=true")
+	check(true, false, "compliance=pci")
+	check(false, false, "ssd=false")
+	check(false, false, "gpu=true")
+	check(false, false, "ssd")
+	check(false, true, "ssd=true")
+	check(true, true, "ssd=false")
+}
+
 func TestSort(t *testing.T) {
 	a := &db.Container{Image: "1", BlueprintID: "1"}
 	b := &db.Container{Image: "1", BlueprintID: "2"}
@@ -637,8 +886,13 @@ func TestSort(t *testing.T) {
 	slice := []*db.Container{d, c, b, a}
 	sort.Sort(dbcSlice(slice))
 	assert.Equal(t, slice, []*db.Container{a, b, c, d})
+
+	e := &db.Container{Image: "3", Priority: 10}
+	slice = []*db.Container{a, b, c, d, e}
+	sort.Sort(dbcSlice(slice))
+	assert.Equal(t, slice, []*db.Container{e, a, b, c, d})
 }
 
-func (m minion) String() string {
-	return spew.Sprintf("(%s Containers: %s)", m.Minion, m.containers)
+func (m Node) String() string {
+	return spew.Sprintf("(%s Containers: %s)", m.Minion, m.Containers)
 }
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/credentials"
+)
+
+// withMetadata returns `dbc` with environment variables describing `minion`'s
+// machine, and the container's own identity, if the container requested them.
+// Like the hooks run by the daemon, the variables are named QUILT_*.  They're
+// set when the container boots, so they describe the machine at that time.
+// Containers with a cloud role are also pointed at the credentials that their
+// minion issues them.
+func withMetadata(dbc db.Container, minion db.Minion) db.Container {
+	if !dbc.ExposeMetadata && dbc.CloudRole == "" {
+		return dbc
+	}
+
+	env := map[string]string{}
+	if dbc.CloudRole != "" {
+		env[credentials.EndpointEnv] = credentials.Endpoint
+	}
+
+	if dbc.ExposeMetadata {
+		for key, value := range machineMetadata(dbc, minion) {
+			env[key] = value
+		}
+	}
+
+	// Variables set by the blueprint take precedence.
+	for key, value := range dbc.Env {
+		env[key] = value
+	}
+	dbc.Env = env
+	return dbc
+}
+
+// machineMetadata returns the QUILT_* variables that describe `minion`'s machine,
+// and the identity of `dbc`.
+func machineMetadata(dbc db.Container, minion db.Minion) map[string]string {
+
+	publicIP := minion.PublicIP
+	if minion.FloatingIP != "" {
+		publicIP = minion.FloatingIP
+	}
+
+	return map[string]string{
+		"QUILT_PROVIDER":     minion.Provider,
+		"QUILT_REGION":       minion.Region,
+		"QUILT_SIZE":         minion.Size,
+		"QUILT_PUBLIC_IP":    publicIP,
+		"QUILT_PRIVATE_IP":   minion.PrivateIP,
+		"QUILT_HOSTNAME":     dbc.Hostname + ".q",
+		"QUILT_IP":           dbc.IP,
+		"QUILT_BLUEPRINT_ID": dbc.BlueprintID,
+	}
+}
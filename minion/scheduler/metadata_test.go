@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/db"
+)
+
+func TestWithMetadata(t *testing.T) {
+	t.Parallel()
+
+	minion := db.Minion{
+		Provider:  "Amazon",
+		Region:    "us-west-1",
+		Size:      "m3.medium",
+		PublicIP:  "8.8.8.8",
+		PrivateIP: "172.31.0.1",
+	}
+	dbc := db.Container{
+		IP:          "10.0.0.2",
+		Hostname:    "web",
+		BlueprintID: "id",
+		Env:         map[string]string{"QUILT_REGION": "override", "a": "b"},
+	}
+
+	// Containers don't see the metadata unless they ask for it.
+	assert.Equal(t, dbc, withMetadata(dbc, minion))
+
+	dbc.ExposeMetadata = true
+	assert.Equal(t, map[string]string{
+		"QUILT_PROVIDER":     "Amazon",
+		"QUILT_REGION":       "override",
+		"QUILT_SIZE":         "m3.medium",
+		"QUILT_PUBLIC_IP":    "8.8.8.8",
+		"QUILT_PRIVATE_IP":   "172.31.0.1",
+		"QUILT_HOSTNAME":     "web.q",
+		"QUILT_IP":           "10.0.0.2",
+		"QUILT_BLUEPRINT_ID": "id",
+		"a":                  "b",
+	}, withMetadata(dbc, minion).Env)
+
+	// The blueprint's environment isn't modified.
+	assert.Len(t, dbc.Env, 2)
+
+	minion.FloatingIP = "9.9.9.9"
+	assert.Equal(t, "9.9.9.9", withMetadata(dbc, minion).Env["QUILT_PUBLIC_IP"])
+
+	// Containers with a cloud role are pointed at their credentials, even if
+	// they don't ask for the metadata.
+	dbc.ExposeMetadata = false
+	dbc.CloudRole = "arn:aws:iam::123456789012:role/uploader"
+	assert.Equal(t, map[string]string{
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT": "http://10.0.0.1:8182",
+		"QUILT_REGION":                      "override",
+		"a":                                 "b",
+	}, withMetadata(dbc, minion).Env)
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kelda/kelda/db"
+)
+
+// A Node is a worker minion that containers may be placed on, along with the
+// containers currently placed on it.
+type Node struct {
+	db.Minion
+	Containers []*db.Container
+}
+
+// Cluster is the view of the cluster that is available to scheduler plugins.
+type Cluster struct {
+	Nodes       []*Node
+	Placements  []db.Placement
+	Connections []db.Connection
+
+	// Maps each hostname to the hostnames it has a connection with.
+	neighbors map[string]map[string]struct{}
+}
+
+// A FilterFunc returns false if `dbc` must not be placed on `node`.  A
+// container is only placed on nodes that pass every registered filter.
+type FilterFunc func(cluster *Cluster, node Node, dbc *db.Container) bool
+
+// A ScoreFunc rates how good of a fit `node` is for `dbc`.  The container is
+// placed on the node with the highest weighted sum of scores.
+type ScoreFunc func(cluster *Cluster, node *Node, dbc *db.Container) float64
+
+type filterPlugin struct {
+	name   string
+	filter FilterFunc
+}
+
+type scorePlugin struct {
+	name   string
+	weight float64
+	score  ScoreFunc
+}
+
+var plugins struct {
+	sync.Mutex
+	filters []filterPlugin
+	scorers []scorePlugin
+}
+
+// LocalityWeight controls how strongly the scheduler prefers placing
+// containers near the containers they have connections with.  A weight of zero
+// disables locality-aware placement.
+var LocalityWeight = 1.0
+
+func init() {
+	RegisterFilter("placement", func(cluster *Cluster, node Node,
+		dbc *db.Container) bool {
+		return validPlacement(cluster.Placements, node, node.Containers, dbc)
+	})
+
+	RegisterFilter("boot-failures", func(_ *Cluster, node Node,
+		dbc *db.Container) bool {
+		for _, id := range node.FailedContainers {
+			if id == dbc.BlueprintID {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Containers can't communicate on minions whose network is degraded, so
+	// drain them.
+	RegisterFilter("network-degraded", func(_ *Cluster, node Node,
+		_ *db.Container) bool {
+		return !node.NetworkDegraded
+	})
+
+	// The daemon drains minions whose machines are about to be rebooted or
+	// reimaged, and minions drain themselves when their machines are about to
+	// be reclaimed by their provider.
+	RegisterFilter("draining", func(_ *Cluster, node Node, _ *db.Container) bool {
+		return !node.Drain && !node.Interrupted
+	})
+
+	RegisterScorer("locality", 1, func(cluster *Cluster, node *Node,
+		dbc *db.Container) float64 {
+		return LocalityWeight * localityScore(cluster, node, dbc)
+	})
+}
+
+// RegisterFilter adds a filter to the placement pipeline.  It's meant to be
+// called from an init function so that custom placement logic can be compiled
+// into the minion.  It panics if a filter with the same name already exists.
+func RegisterFilter(name string, filter FilterFunc) {
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	for _, p := range plugins.filters {
+		if p.name == name {
+			panic(fmt.Sprintf("duplicate scheduler filter: %s", name))
+		}
+	}
+	plugins.filters = append(plugins.filters, filterPlugin{name, filter})
+}
+
+// RegisterScorer adds a scorer to the placement pipeline, whose scores are
+// multiplied by `weight`.  Like RegisterFilter, it panics if a scorer with the
+// same name already exists.
+func RegisterScorer(name string, weight float64, score ScoreFunc) {
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	for _, p := range plugins.scorers {
+		if p.name == name {
+			panic(fmt.Sprintf("duplicate scheduler scorer: %s", name))
+		}
+	}
+	plugins.scorers = append(plugins.scorers, scorePlugin{name, weight, score})
+}
+
+// feasible returns true if `dbc` passes every filter when placed on `node`
+// alongside `peers`.
+func (ctx *context) feasible(node *Node, peers []*db.Container,
+	dbc *db.Container) bool {
+
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	candidate := Node{node.Minion, peers}
+	for _, p := range plugins.filters {
+		if !p.filter(&ctx.Cluster, candidate, dbc) {
+			ctx.inc("Filtered by " + p.name)
+			return false
+		}
+	}
+	return true
+}
+
+// score returns the weighted sum of the scores for placing `dbc` on `node`.
+func (ctx *context) score(node *Node, dbc *db.Container) float64 {
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	var total float64
+	for _, p := range plugins.scorers {
+		if p.weight != 0 {
+			total += p.weight * p.score(&ctx.Cluster, node, dbc)
+		}
+	}
+	return total
+}
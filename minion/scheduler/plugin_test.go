@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/kelda/kelda/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlugins(t *testing.T) {
+	oldFilters, oldScorers := plugins.filters, plugins.scorers
+	defer func() {
+		plugins.filters, plugins.scorers = oldFilters, oldScorers
+	}()
+
+	minions := []db.Minion{
+		{PrivateIP: "1", Size: "small", Role: db.Worker},
+		{PrivateIP: "2", Size: "licensed", Role: db.Worker},
+		{PrivateIP: "3", Size: "large", Role: db.Worker},
+	}
+	containers := func() []db.Container {
+		return []db.Container{
+			{ID: 1, BlueprintID: "1", Image: "licensed-software"},
+			{ID: 2, BlueprintID: "2", Image: "nginx"},
+		}
+	}
+
+	// Licensed software may only run on licensed machines.
+	RegisterFilter("license", func(_ *Cluster, node Node,
+		dbc *db.Container) bool {
+		return dbc.Image != "licensed-software" || node.Size == "licensed"
+	})
+
+	ctx := makeContext(minions, nil, containers(), nil)
+	placeUnassigned(ctx)
+	placed := map[string]string{}
+	for _, dbc := range ctx.changed {
+		placed[dbc.Image] = dbc.Minion
+	}
+	assert.Equal(t, "2", placed["licensed-software"])
+	assert.NotEqual(t, "2", placed["nginx"])
+
+	// Prefer large machines.
+	RegisterScorer("large", 1, func(_ *Cluster, node *Node,
+		_ *db.Container) float64 {
+		if node.Size == "large" {
+			return 1
+		}
+		return 0
+	})
+
+	ctx = makeContext(minions, nil, containers(), nil)
+	placeUnassigned(ctx)
+	placed = map[string]string{}
+	for _, dbc := range ctx.changed {
+		placed[dbc.Image] = dbc.Minion
+	}
+	assert.Equal(t, "2", placed["licensed-software"])
+	assert.Equal(t, "3", placed["nginx"])
+
+	assert.Panics(t, func() {
+		RegisterFilter("license", nil)
+	})
+	assert.Panics(t, func() {
+		RegisterScorer("large", 1, nil)
+	})
+}
+
+func TestNetworkDegradedFilter(t *testing.T) {
+	t.Parallel()
+
+	minions := []db.Minion{
+		{PrivateIP: "1", Role: db.Worker, NetworkDegraded: true},
+		{PrivateIP: "2", Role: db.Worker},
+	}
+	containers := []db.Container{
+		{ID: 1, BlueprintID: "1", Minion: "1"},
+	}
+
+	ctx := makeContext(minions, nil, containers, nil)
+	cleanupPlacements(ctx)
+	placeUnassigned(ctx)
+
+	assert.Equal(t, "2", containers[0].Minion)
+}
+
+func TestDrainingFilter(t *testing.T) {
+	t.Parallel()
+
+	minions := []db.Minion{
+		{PrivateIP: "1", Role: db.Worker, Drain: true},
+		{PrivateIP: "2", Role: db.Worker},
+	}
+	containers := []db.Container{
+		{ID: 1, BlueprintID: "1", Minion: "1"},
+		{ID: 2, BlueprintID: "2"},
+	}
+
+	ctx := makeContext(minions, nil, containers, nil)
+	cleanupPlacements(ctx)
+	placeUnassigned(ctx)
+
+	assert.Equal(t, "2", containers[0].Minion)
+	assert.Equal(t, "2", containers[1].Minion)
+
+	// Interrupted minions are drained as well.
+	minions = []db.Minion{
+		{PrivateIP: "1", Role: db.Worker},
+		{PrivateIP: "2", Role: db.Worker, Interrupted: true},
+	}
+	ctx = makeContext(minions, nil, containers, nil)
+	cleanupPlacements(ctx)
+	placeUnassigned(ctx)
+
+	assert.Equal(t, "1", containers[0].Minion)
+	assert.Equal(t, "1", containers[1].Minion)
+}
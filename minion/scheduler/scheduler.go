@@ -12,12 +12,15 @@ import (
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/docker"
 	"github.com/kelda/kelda/minion/network/plugin"
+	"github.com/kelda/kelda/recorder"
 	"github.com/kelda/kelda/util"
 	log "github.com/sirupsen/logrus"
 )
 
 var c = counter.New("Scheduler")
 
+var rec = recorder.New("Scheduler")
+
 // Run blocks implementing the scheduler module.
 func Run(conn db.Conn, dk docker.Client) {
 	bootWait(conn)
@@ -27,9 +30,12 @@ func Run(conn db.Conn, dk docker.Client) {
 		log.WithError(err).Fatal("Failed to configure network plugin")
 	}
 
+	go runHealthChecks(conn, dk)
+
 	loopLog := util.NewEventTimer("Scheduler")
 	trig := conn.TriggerTick(60, db.MinionTable, db.ContainerTable,
-		db.PlacementTable, db.EtcdTable, db.ImageTable).C
+		db.PlacementTable, db.EtcdTable, db.ImageTable, db.ConnectionTable,
+		db.HostnameTable).C
 	for range trig {
 		loopLog.LogStart()
 		minion := conn.MinionSelf()
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/kelda/kelda/blueprint"
+	"github.com/kelda/kelda/db"
+)
+
+// A Simulation describes how the containers of a blueprint would be placed on
+// its machines.
+type Simulation struct {
+	// Maps the ID of each worker machine to the hostnames of the containers
+	// placed on it.
+	Placed map[string][]string
+
+	// The hostnames of the containers that couldn't be placed.
+	Unplaced []string
+
+	// The number of workers that could be removed while every container could
+	// still be placed.  Like AllPlaced, it only accounts for the placement rules
+	// of the containers, and not their CPU or RAM.
+	SpareMachines int
+}
+
+// AllPlaced returns whether every container was placed.  The scheduler doesn't
+// know how much CPU or RAM containers need, so it doesn't mean that the machines
+// are large enough to run them.
+func (sim Simulation) AllPlaced() bool {
+	return len(sim.Unplaced) == 0
+}
+
+// Simulate places the containers of `bp` on its worker machines as the
+// scheduler would in a new cluster, without affecting any running cluster.
+// Images built from Dockerfiles are assumed to have been built.
+func Simulate(bp blueprint.Blueprint) Simulation {
+	var workers []db.Minion
+	for _, m := range bp.Machines {
+		if m.Role != db.Worker {
+			continue
+		}
+
+		// Containers refer to the minion they're placed on by its IP, so the
+		// machine's ID stands in for it.
+		workers = append(workers, db.Minion{
+			Role:       db.Worker,
+			PrivateIP:  m.ID,
+			Provider:   m.Provider,
+			Region:     m.Region,
+			Size:       m.Size,
+			FloatingIP: m.FloatingIP,
+			Labels:     m.Labels,
+		})
+	}
+
+	var containers []db.Container
+	for _, c := range bp.Containers {
+		priority, _ := db.ParsePriorityClass(c.PriorityClass)
+		containers = append(containers, db.Container{
+			BlueprintID: c.ID,
+			Hostname:    c.Hostname,
+			Image:       c.Image.Name,
+			Command:     c.Command,
+			Priority:    priority,
+		})
+	}
+
+	var connections []db.Connection
+	for _, conn := range bp.Connections {
+		connections = append(connections, db.Connection{
+			From:    conn.From,
+			To:      conn.To,
+			MinPort: conn.MinPort,
+			MaxPort: conn.MaxPort,
+		})
+	}
+
+	placements := PortPlacements(connections, containers)
+	for _, p := range bp.Placements {
+		placements = append(placements, db.Placement{
+			TargetContainer: p.TargetContainerID,
+			Exclusive:       p.Exclusive,
+			Provider:        p.Provider,
+			Size:            p.Size,
+			Region:          p.Region,
+			FloatingIP:      p.FloatingIP,
+			Label:           p.Label,
+		})
+	}
+
+	sim := simulate(workers, containers, placements, connections)
+
+	// Greedily remove the least loaded workers for as long as every container
+	// can still be placed on the rest.
+	if sim.AllPlaced() {
+		sort.SliceStable(workers, func(i, j int) bool {
+			return len(sim.Placed[workers[i].PrivateIP]) <
+				len(sim.Placed[workers[j].PrivateIP])
+		})
+
+		var kept []db.Minion
+		for i, worker := range workers {
+			candidate := append(append([]db.Minion(nil), kept...),
+				workers[i+1:]...)
+			if simulate(candidate, containers, placements,
+				connections).AllPlaced() {
+				sim.SpareMachines++
+				continue
+			}
+			kept = append(kept, worker)
+		}
+	}
+	return sim
+}
+
+func simulate(workers []db.Minion, containers []db.Container,
+	placements []db.Placement, connections []db.Connection) Simulation {
+
+	// The context modifies the containers in place.
+	containers = append([]db.Container(nil), containers...)
+	ctx := makeContext(workers, placements, containers, nil)
+	ctx.simulated = true
+	ctx.Connections = connections
+	ctx.neighbors = makeNeighbors(connections)
+	placeUnassigned(ctx)
+
+	sim := Simulation{Placed: map[string][]string{}}
+	for _, worker := range workers {
+		sim.Placed[worker.PrivateIP] = nil
+	}
+
+	for _, dbc := range containers {
+		if dbc.Minion == "" {
+			sim.Unplaced = append(sim.Unplaced, dbc.Hostname)
+		} else {
+			sim.Placed[dbc.Minion] = append(sim.Placed[dbc.Minion],
+				dbc.Hostname)
+		}
+	}
+
+	for _, hostnames := range sim.Placed {
+		sort.Strings(hostnames)
+	}
+	sort.Strings(sim.Unplaced)
+	return sim
+}
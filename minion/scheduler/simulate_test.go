@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/blueprint"
+)
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+
+	bp := blueprint.Blueprint{
+		Machines: []blueprint.Machine{
+			{ID: "master", Role: "Master"},
+			{ID: "w1", Role: "Worker", Region: "us-west-1"},
+			{ID: "w2", Role: "Worker", Region: "us-west-2"},
+			{ID: "w3", Role: "Worker", Region: "us-west-2"},
+		},
+		Containers: []blueprint.Container{
+			{ID: "a", Hostname: "a", Image: blueprint.Image{Name: "nginx"}},
+			{ID: "b", Hostname: "b", Image: blueprint.Image{Name: "nginx"}},
+			{ID: "c", Hostname: "c", Image: blueprint.Image{Name: "redis"}},
+		},
+		Connections: []blueprint.Connection{
+			{From: blueprint.PublicInternetLabel, To: "a", MinPort: 80,
+				MaxPort: 80},
+			{From: blueprint.PublicInternetLabel, To: "b", MinPort: 80,
+				MaxPort: 80},
+		},
+		Placements: []blueprint.Placement{
+			{TargetContainerID: "c", Exclusive: false, Region: "us-west-1"},
+		},
+	}
+
+	// The web servers listen on the same public port, so they need separate
+	// machines, and the third machine isn't needed.
+	sim := Simulate(bp)
+	assert.True(t, sim.AllPlaced())
+	assert.Empty(t, sim.Unplaced)
+	assert.Len(t, sim.Placed, 3)
+	assert.Contains(t, sim.Placed["w1"], "c")
+	assert.Equal(t, 1, sim.SpareMachines)
+
+	var placed int
+	for _, hostnames := range sim.Placed {
+		placed += len(hostnames)
+		assert.False(t, len(hostnames) == 2 && hostnames[0] == "a" &&
+			hostnames[1] == "b")
+	}
+	assert.Equal(t, 3, placed)
+
+	// Without the machine in us-west-1, the database can't be placed.
+	bp.Machines = bp.Machines[:1]
+	bp.Machines = append(bp.Machines,
+		blueprint.Machine{ID: "w2", Role: "Worker", Region: "us-west-2"})
+	sim = Simulate(bp)
+	assert.False(t, sim.AllPlaced())
+	assert.Equal(t, []string{"b", "c"}, sim.Unplaced)
+	assert.Equal(t, map[string][]string{"w2": {"a"}}, sim.Placed)
+	assert.Zero(t, sim.SpareMachines)
+}
@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/kelda/kelda/connection/tls"
+	tlsIO "github.com/kelda/kelda/connection/tls/io"
+	"github.com/kelda/kelda/connection/tls/rsa"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/minion/tlsproxy"
+	"github.com/kelda/kelda/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// The proxies that wrap mTLS connections are labeled apart from the containers in
+// the blueprint, so that runWorker doesn't remove them.
+const tlsProxyLabelValue = "tls-proxy"
+const tlsProxyLabelPair = labelKey + "=" + tlsProxyLabelValue
+
+// The docker ID of the container whose connections a proxy wraps, and the peers of
+// the proxy, are stored in labels so that the proxy is replaced if either changes.
+const tlsProxyContainerKey = "tls-proxy-container"
+const tlsProxyPeersKey = "tls-proxy-peers"
+
+// The proxy is in the build of the Quilt image for the worker's architecture, and
+// trusts the minion's certificate authority.
+var tlsProxyImage = runImage(QuiltImage)
+var readTLSProxyCA = func() (string, error) {
+	return tlsIO.ReadCACert(tlsIO.MinionTLSDir)
+}
+
+// A tlsProxy wraps the mTLS connections of the container with `dockerID` and
+// `hostname`.
+type tlsProxy struct {
+	dockerID string
+	hostname string
+	inbound  []tlsproxy.Rule
+	outbound []tlsproxy.Rule
+}
+
+type tlsProxySlice []tlsProxy
+
+func (ps tlsProxySlice) Get(ii int) interface{} {
+	return ps[ii]
+}
+
+func (ps tlsProxySlice) Len() int {
+	return len(ps)
+}
+
+func (p tlsProxy) args() []string {
+	args := []string{"quilt", "tls-proxy"}
+	for _, r := range p.inbound {
+		args = append(args, "-inbound", r.String())
+	}
+	for _, r := range p.outbound {
+		args = append(args, "-outbound", r.String())
+	}
+	return args
+}
+
+func (p tlsProxy) peers() string {
+	return strings.Join(p.args()[2:], " ")
+}
+
+// runTLSProxies runs a proxy alongside each container on this worker that's at
+// either end of an mTLS connection.  Each proxy is given its own key, and a
+// certificate that the daemon issued to its container, so that the minion's
+// credentials never leave the host.
+func runTLSProxies(conn db.Conn, dk docker.Client, myIP string) {
+	var dbcs []db.Container
+	var conns []db.Connection
+	var hostnameToIP map[string]string
+	var self db.Minion
+	conn.Txn(db.ConnectionTable, db.ContainerTable, db.HostnameTable,
+		db.MinionTable).Run(func(view db.Database) error {
+		dbcs = view.SelectFromContainer(nil)
+		conns = view.SelectFromConnection(func(c db.Connection) bool {
+			return c.MTLS
+		})
+		hostnameToIP = view.GetHostnameMappings()
+		self = view.MinionSelf()
+		return nil
+	})
+
+	proxies := tlsProxies(myIP, dbcs, conns, hostnameToIP)
+	keys := updateContainerKeys(conn, self.ContainerKeys, proxies)
+
+	dkcs, err := dk.List(map[string][]string{"label": {tlsProxyLabelPair}})
+	if err != nil {
+		log.WithError(err).Warning("Failed to list TLS proxies.")
+		return
+	}
+
+	proxyKey := func(iface interface{}) interface{} {
+		proxy := iface.(tlsProxy)
+		return [2]string{proxy.dockerID, proxy.peers()}
+	}
+	dkcKey := func(iface interface{}) interface{} {
+		dkc := iface.(docker.Container)
+		return [2]string{dkc.Labels[tlsProxyContainerKey],
+			dkc.Labels[tlsProxyPeersKey]}
+	}
+	_, toBoot, toKill := join.HashJoin(tlsProxySlice(proxies),
+		docker.ContainerSlice(dkcs), proxyKey, dkcKey)
+
+	doContainers(dk, toKill, dockerKill)
+	if len(toBoot) == 0 {
+		return
+	}
+
+	caCert, err := readTLSProxyCA()
+	if err != nil {
+		log.WithError(err).Warning("Failed to read the certificate authority.")
+		return
+	}
+
+	doContainers(dk, toBoot, func(dk docker.Client, iface interface{}) error {
+		proxy := iface.(tlsProxy)
+		key, cert := keys[proxy.hostname], self.ContainerCerts[proxy.hostname]
+
+		// The daemon issues the certificate after the minion reports the
+		// key, so the proxy waits until a certificate for its key arrives.
+		if _, err := tls.New(caCert, cert, key); err != nil {
+			log.WithField("container", proxy.dockerID).Debug(
+				"Waiting for TLS proxy certificate")
+			return err
+		}
+
+		files := tlsIO.CredentialFiles(tlsproxy.TLSDir, caCert, cert, key)
+		return runTLSProxy(dk, proxy, files)
+	})
+}
+
+// updateContainerKeys generates a key for each of `proxies` that doesn't already
+// have one in `keys`, and commits the keys of `proxies` to the minion, which
+// reports them to the daemon.  It returns the committed keys.
+func updateContainerKeys(conn db.Conn, keys map[string]string,
+	proxies []tlsProxy) map[string]string {
+
+	newKeys := map[string]string{}
+	for _, proxy := range proxies {
+		if key, ok := keys[proxy.hostname]; ok {
+			newKeys[proxy.hostname] = key
+			continue
+		}
+
+		key, err := rsa.NewPrivateKey()
+		if err != nil {
+			log.WithError(err).Warning("Failed to generate TLS proxy key.")
+			continue
+		}
+		newKeys[proxy.hostname] = key
+	}
+
+	if util.StrStrMapEqual(newKeys, keys) {
+		return keys
+	}
+
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		self.ContainerKeys = newKeys
+		view.Commit(self)
+		return nil
+	})
+	return newKeys
+}
+
+// tlsProxies returns the proxies that should run on the worker with `myIP`.  The
+// peers of its containers, which usually run on other machines, are resolved by
+// `hostnameToIP`.  It includes load balancers, which containers connect to by
+// their IP, so the proxies wrap those connections too.  The connections to the
+// containers behind the load balancer are unwrapped by the containers' own
+// proxies.
+func tlsProxies(myIP string, dbcs []db.Container, conns []db.Connection,
+	hostnameToIP map[string]string) []tlsProxy {
+
+	var proxies []tlsProxy
+	for _, dbc := range dbcs {
+		if dbc.Minion != myIP || dbc.DockerID == "" || dbc.IP == "" {
+			continue
+		}
+
+		proxy := tlsProxy{dockerID: dbc.DockerID, hostname: dbc.Hostname}
+		for _, c := range conns {
+			if ip, ok := hostnameToIP[c.From]; ok && c.To == dbc.Hostname {
+				proxy.inbound = append(proxy.inbound,
+					tlsproxy.Rule{IP: ip, MinPort: c.MinPort,
+						MaxPort: c.MaxPort})
+			}
+
+			if ip, ok := hostnameToIP[c.To]; ok && c.From == dbc.Hostname {
+				proxy.outbound = append(proxy.outbound,
+					tlsproxy.Rule{IP: ip, MinPort: c.MinPort,
+						MaxPort: c.MaxPort})
+			}
+		}
+
+		if len(proxy.inbound) == 0 && len(proxy.outbound) == 0 {
+			continue
+		}
+
+		sortRules(proxy.inbound)
+		sortRules(proxy.outbound)
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+func sortRules(rules []tlsproxy.Rule) {
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].String() < rules[j].String()
+	})
+}
+
+func runTLSProxy(dk docker.Client, proxy tlsProxy, files []tlsIO.File) error {
+	log.WithField("container", proxy.dockerID).Info("Start TLS proxy")
+
+	filepathToContent := map[string]string{}
+	for _, f := range files {
+		filepathToContent[f.Path] = f.Content
+	}
+
+	// The proxy's redirects outlive it, as they're in the container's network
+	// namespace, so they're removed before the proxy is.
+	preStop, _ := json.Marshal([]string{"quilt", "tls-proxy", "-cleanup"})
+
+	_, err := dk.Run(docker.RunOptions{
+		Image: tlsProxyImage,
+		Args:  proxy.args(),
+		Labels: map[string]string{
+			labelKey:             tlsProxyLabelValue,
+			tlsProxyContainerKey: proxy.dockerID,
+			tlsProxyPeersKey:     proxy.peers(),
+			preStopKey:           string(preStop),
+		},
+		FilepathToContent: filepathToContent,
+		NetworkMode:       "container:" + proxy.dockerID,
+
+		// The proxy needs NET_ADMIN to install its redirects, and to mark
+		// the connections that it opens.
+		Privileged: true,
+	})
+	if err != nil {
+		log.WithError(err).WithField("container", proxy.dockerID).Warning(
+			"Failed to run TLS proxy")
+	}
+	return err
+}
@@ -0,0 +1,255 @@
+package scheduler
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/connection/tls"
+	tlsIO "github.com/kelda/kelda/connection/tls/io"
+	"github.com/kelda/kelda/connection/tls/rsa"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/minion/tlsproxy"
+)
+
+func TestTLSProxies(t *testing.T) {
+	t.Parallel()
+
+	// Like on a real worker, only the local containers are known, and their
+	// peers are resolved by their hostnames.
+	dbcs := []db.Container{
+		{Hostname: "web", IP: "10.0.0.2", DockerID: "web-id", Minion: "1.2.3.4"},
+		{Hostname: "db", IP: "10.0.0.3", DockerID: "db-id", Minion: "1.2.3.4"},
+		{Hostname: "cache", IP: "10.0.0.4", DockerID: "cache-id",
+			Minion: "1.2.3.4"},
+		{Hostname: "booting", IP: "10.0.0.6", Minion: "1.2.3.4"},
+	}
+	hostnameToIP := map[string]string{
+		"web":     "10.0.0.2",
+		"db":      "10.0.0.3",
+		"cache":   "10.0.0.4",
+		"remote":  "10.0.0.5",
+		"booting": "10.0.0.6",
+		"lb":      "10.1.0.1",
+	}
+	conns := []db.Connection{
+		{From: "web", To: "db", MinPort: 5432, MaxPort: 5432, MTLS: true},
+		{From: "web", To: "remote", MinPort: 80, MaxPort: 90, MTLS: true},
+		{From: "web", To: "lb", MinPort: 80, MaxPort: 80, MTLS: true},
+		{From: "booting", To: "db", MinPort: 5432, MaxPort: 5432, MTLS: true},
+		{From: "remote", To: "cache", MinPort: 6379, MaxPort: 6379,
+			MTLS: true},
+		{From: "unknown", To: "db", MinPort: 5432, MaxPort: 5432, MTLS: true},
+	}
+
+	assert.Equal(t, []tlsProxy{
+		{
+			dockerID: "web-id",
+			hostname: "web",
+			outbound: []tlsproxy.Rule{
+				{IP: "10.0.0.3", MinPort: 5432, MaxPort: 5432},
+				{IP: "10.0.0.5", MinPort: 80, MaxPort: 90},
+				{IP: "10.1.0.1", MinPort: 80, MaxPort: 80},
+			},
+		},
+		{
+			dockerID: "db-id",
+			hostname: "db",
+			inbound: []tlsproxy.Rule{
+				{IP: "10.0.0.2", MinPort: 5432, MaxPort: 5432},
+				{IP: "10.0.0.6", MinPort: 5432, MaxPort: 5432},
+			},
+		},
+		{
+			dockerID: "cache-id",
+			hostname: "cache",
+			inbound: []tlsproxy.Rule{
+				{IP: "10.0.0.5", MinPort: 6379, MaxPort: 6379},
+			},
+		},
+	}, tlsProxies("1.2.3.4", dbcs, conns, hostnameToIP))
+
+	assert.Empty(t, tlsProxies("1.2.3.6", dbcs, conns, hostnameToIP))
+}
+
+func TestRunTLSProxies(t *testing.T) {
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+	readTLSProxyCA = func() (string, error) {
+		return ca.CertString(), nil
+	}
+
+	md, dk := docker.NewMock()
+	conn := db.New()
+
+	// The worker's database only holds its own containers.  The peer on
+	// another machine is only known by its hostname.  Containers without mTLS
+	// connections don't get a proxy.
+	var web, db1 db.Container
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		self := view.InsertMinion()
+		self.Self = true
+		self.PrivateIP = "1.2.3.4"
+		view.Commit(self)
+
+		web = view.InsertContainer()
+		web.Hostname = "web"
+		web.IP = "10.0.0.2"
+		web.Minion = "1.2.3.4"
+		web.DockerID = "web-id"
+		view.Commit(web)
+
+		db1 = view.InsertContainer()
+		db1.Hostname = "db"
+		db1.IP = "10.0.0.3"
+		db1.Minion = "1.2.3.4"
+		db1.DockerID = "db-id"
+		view.Commit(db1)
+
+		for _, h := range []db.Hostname{{Hostname: "web", IP: "10.0.0.2"},
+			{Hostname: "db", IP: "10.0.0.3"},
+			{Hostname: "remote", IP: "10.0.0.5"}} {
+			row := view.InsertHostname()
+			h.ID = row.ID
+			view.Commit(h)
+		}
+
+		for _, to := range []string{"db", "remote"} {
+			dbConn := view.InsertConnection()
+			dbConn.From = "web"
+			dbConn.To = to
+			dbConn.MinPort = 5432
+			dbConn.MaxPort = 5432
+			view.Commit(dbConn)
+		}
+		return nil
+	})
+
+	runTLSProxies(conn, dk, "1.2.3.4")
+	assert.Empty(t, listTLSProxies(t, dk))
+	assert.Empty(t, conn.MinionSelf().ContainerKeys)
+
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, dbConn := range view.SelectFromConnection(nil) {
+			dbConn.MTLS = true
+			view.Commit(dbConn)
+		}
+		return nil
+	})
+
+	// The proxies wait for the daemon to issue certificates for their keys.
+	runTLSProxies(conn, dk, "1.2.3.4")
+	assert.Empty(t, listTLSProxies(t, dk))
+
+	keys := conn.MinionSelf().ContainerKeys
+	assert.Len(t, keys, 2)
+	assert.NotEqual(t, keys["web"], keys["db"])
+
+	// The keys are kept while the containers need proxies.
+	runTLSProxies(conn, dk, "1.2.3.4")
+	assert.Equal(t, keys, conn.MinionSelf().ContainerKeys)
+
+	issueContainerCerts(t, conn, ca)
+	runTLSProxies(conn, dk, "1.2.3.4")
+	proxies := listTLSProxies(t, dk)
+	assert.Len(t, proxies, 2)
+	assert.Equal(t, []string{"quilt", "tls-proxy",
+		"-inbound", "10.0.0.2:5432"}, proxies["db-id"].Args)
+	assert.Equal(t, []string{"quilt", "tls-proxy",
+		"-outbound", "10.0.0.3:5432", "-outbound", "10.0.0.5:5432"},
+		proxies["web-id"].Args)
+	assert.Equal(t, tlsProxyImage, proxies["web-id"].Image)
+
+	// Each proxy holds its own container's credentials.
+	self := conn.MinionSelf()
+	for _, f := range tlsIO.CredentialFiles(tlsproxy.TLSDir, ca.CertString(),
+		self.ContainerCerts["web"], keys["web"]) {
+		assert.Contains(t, md.Uploads, docker.UploadToContainerOptions{
+			ContainerID: proxies["web-id"].ID,
+			UploadPath:  "/",
+			TarPath:     strings.TrimPrefix(f.Path, "/"),
+			Contents:    f.Content,
+		})
+	}
+
+	// Running again doesn't change anything.
+	runTLSProxies(conn, dk, "1.2.3.4")
+	assert.Equal(t, proxies, listTLSProxies(t, dk))
+
+	// When the container is replaced, so is its proxy, after its redirects are
+	// cleaned up.
+	oldProxy := proxies["db-id"].ID
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		db1.DockerID = "db-id-2"
+		view.Commit(db1)
+		return nil
+	})
+
+	runTLSProxies(conn, dk, "1.2.3.4")
+	proxies = listTLSProxies(t, dk)
+	assert.Len(t, proxies, 2)
+	assert.Contains(t, proxies, "db-id-2")
+	assert.Equal(t, []string{"quilt tls-proxy -cleanup"},
+		md.Executions[oldProxy])
+
+	// Proxies aren't started without the certificate authority.
+	readTLSProxyCA = func() (string, error) {
+		return "", errors.New("not ready")
+	}
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		web.DockerID = "web-id-2"
+		view.Commit(web)
+		return nil
+	})
+
+	runTLSProxies(conn, dk, "1.2.3.4")
+	proxies = listTLSProxies(t, dk)
+	assert.Len(t, proxies, 1)
+	assert.Contains(t, proxies, "db-id-2")
+
+	// The keys of containers that no longer need proxies are dropped.
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		view.Remove(web)
+		return nil
+	})
+	runTLSProxies(conn, dk, "1.2.3.4")
+	assert.Equal(t, map[string]string{"db": keys["db"]},
+		conn.MinionSelf().ContainerKeys)
+}
+
+// issueContainerCerts plays the part of the daemon, which issues certificates for
+// the keys that the minion reports.
+func issueContainerCerts(t *testing.T, conn db.Conn, ca rsa.KeyPair) {
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		self.ContainerCerts = map[string]string{}
+		for hostname, key := range self.ContainerKeys {
+			pubKey, err := rsa.PublicKeyString(key)
+			assert.NoError(t, err)
+
+			cert, err := rsa.SignPublicKey(ca, pubKey,
+				[]*url.URL{tls.ContainerURI(hostname)})
+			assert.NoError(t, err)
+			self.ContainerCerts[hostname] = cert
+		}
+		view.Commit(self)
+		return nil
+	})
+}
+
+// listTLSProxies returns the running TLS proxies, keyed by the docker ID of their
+// container.
+func listTLSProxies(t *testing.T, dk docker.Client) map[string]docker.Container {
+	dkcs, err := dk.List(map[string][]string{"label": {tlsProxyLabelPair}})
+	assert.NoError(t, err)
+
+	proxies := map[string]docker.Container{}
+	for _, dkc := range dkcs {
+		proxies[dkc.Labels[tlsProxyContainerKey]] = dkc
+	}
+	return proxies
+}
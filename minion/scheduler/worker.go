@@ -2,18 +2,22 @@ package scheduler
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
+	"runtime"
 	"sync"
 	"time"
 
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/join"
+	"github.com/kelda/kelda/minion/credentials"
 	"github.com/kelda/kelda/minion/docker"
 	"github.com/kelda/kelda/minion/ipdef"
 	"github.com/kelda/kelda/minion/network/openflow"
 	"github.com/kelda/kelda/minion/network/plugin"
 	"github.com/kelda/kelda/util"
+	"github.com/kelda/kelda/version"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,10 +25,56 @@ const labelKey = "quilt"
 const labelValue = "scheduler"
 const labelPair = labelKey + "=" + labelValue
 const filesKey = "files"
+
+// The pre-stop command is stored in a label because the container's database row
+// may already be gone by the time the container is stopped.
+const preStopKey = "prestop"
 const concurrencyLimit = 32
 
+// After `maxBootAttempts` consecutive failures to boot a container, the
+// container is quarantined on this worker for `quarantineTime`.  In the
+// meantime, the master schedules it elsewhere.
+const maxBootAttempts = 5
+const quarantineTime = time.Hour
+
+// The delay before retrying a failed boot starts at `bootBackoff`, and doubles
+// with each consecutive failure.
+const bootBackoff = 5 * time.Second
+
 var once sync.Once
 
+var bootFailures = newFailureTracker()
+
+// The hash of the files most recently copied into each running container, keyed
+// by Docker ID.  Labels can't be changed once a container is created, so the
+// files label only describes the files that the container started with.  If the
+// minion restarts, the updated files are copied in again.
+var updatedFiles = struct {
+	sync.Mutex
+	hashes map[string]string
+}{hashes: map[string]string{}}
+
+// Allow mocking out for the unit tests.
+var cleanupEndpoints = plugin.CleanupEndpoints
+
+// QuiltImage is the Quilt image, as named by the containers that the minions add
+// to the blueprint, such as the edge proxies.  Workers may differ in architecture
+// from the leader that adds the containers, so each worker runs the build of the
+// image for its own architecture instead (see runImage).
+const QuiltImage = "quilt/quilt:" + version.Version
+
+// The CPU architecture of the worker.
+var arch = runtime.GOARCH
+
+// runImage returns the image that the worker runs for a container whose image is
+// `image`.
+func runImage(image string) string {
+	if image != QuiltImage {
+		return image
+	}
+	return util.ArchImage(image, arch)
+}
+
 func runWorker(conn db.Conn, dk docker.Client, myIP string) {
 	if myIP == "" {
 		return
@@ -39,7 +89,15 @@ func runWorker(conn db.Conn, dk docker.Client, myIP string) {
 
 	filter := map[string][]string{"label": {labelPair}}
 
-	var toBoot, toKill []interface{}
+	// The machine's metadata is exposed to the containers that request it.
+	var self db.Minion
+	if minions := conn.SelectFromMinion(func(m db.Minion) bool {
+		return m.Self
+	}); len(minions) > 0 {
+		self = minions[0]
+	}
+
+	var toBoot, toKill, toUpdate []interface{}
 	for i := 0; i < 2; i++ {
 		dkcs, err := dk.List(filter)
 		if err != nil {
@@ -53,7 +111,16 @@ func runWorker(conn db.Conn, dk docker.Client, myIP string) {
 			})
 
 			var changed []db.Container
-			changed, toBoot, toKill = syncWorker(dbcs, dkcs)
+			changed, toBoot, toKill, toUpdate = syncWorker(dbcs, dkcs)
+			changed, toBoot = bootFailures.filter(changed, toBoot)
+
+			rj := rec.Join(dbcs, dkcs)
+			for _, dbc := range toBoot {
+				rj.Decide("boot", dbc.(db.Container).BlueprintID)
+			}
+			for _, dkc := range toKill {
+				rj.Decide("kill", dkc.(docker.Container).ID)
+			}
 			for _, dbc := range changed {
 				view.Commit(dbc)
 			}
@@ -61,29 +128,68 @@ func runWorker(conn db.Conn, dk docker.Client, myIP string) {
 			return nil
 		})
 
+		doContainers(dk, toUpdate, dockerUpdateFiles)
+
 		if len(toBoot) == 0 && len(toKill) == 0 {
+			// The scheduler is the only one creating endpoints, and List
+			// fails rather than omit a container it couldn't inspect, so
+			// `dkcs` is guaranteed to contain every endpoint that should
+			// exist.
+			var eids []string
+			for _, dkc := range dkcs {
+				eids = append(eids, dkc.EID)
+			}
+			cleanupEndpoints(eids)
 			break
 		}
 
 		start := time.Now()
 		doContainers(dk, toKill, dockerKill)
-		doContainers(dk, toBoot, dockerRun)
+		doContainers(dk, toBoot, func(dk docker.Client, iface interface{}) error {
+			dbc := iface.(db.Container)
+			err := dockerRun(dk, withMetadata(dbc, self))
+			bootFailures.record(dbc.BlueprintID, err)
+			return err
+		})
 		log.Infof("Scheduler spent %v starting/stopping containers",
 			time.Since(start))
 	}
 
+	runTLSProxies(conn, dk, myIP)
+	updateFailedContainers(conn)
 	updateOpenflow(conn, myIP)
 }
 
+// updateFailedContainers publishes the containers quarantined on this worker
+// in its minion row, so that the master can schedule them elsewhere.
+func updateFailedContainers(conn db.Conn) {
+	failed := bootFailures.quarantined()
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		if !util.StrSliceEqual(self.FailedContainers, failed) {
+			self.FailedContainers = failed
+			view.Commit(self)
+		}
+		return nil
+	})
+}
+
+// syncWorker pairs the database containers with the running containers that
+// implement them.  Paired containers whose files are out of date are returned
+// in `toUpdate`, as join.Pairs.
 func syncWorker(dbcs []db.Container, dkcs []docker.Container) (
-	changed []db.Container, toBoot, toKill []interface{}) {
+	changed []db.Container, toBoot, toKill, toUpdate []interface{}) {
 
 	var pairs []join.Pair
-	pairs, toBoot, toKill = join.Join(dbcs, dkcs, syncJoinScore)
+	pairs, toBoot, toKill = rec.ScoredJoin("containers", dbcs, dkcs,
+		syncJoinScore)
 
 	for _, pair := range pairs {
 		dbc := pair.L.(db.Container)
 		dkc := pair.R.(docker.Container)
+		if filesHash(dbc.FilepathToContent) != currentFilesHash(dkc) {
+			toUpdate = append(toUpdate, pair)
+		}
 
 		dbc.DockerID = dkc.ID
 		dbc.EndpointID = dkc.EID
@@ -92,11 +198,11 @@ func syncWorker(dbcs []db.Container, dkcs []docker.Container) (
 		changed = append(changed, dbc)
 	}
 
-	return changed, toBoot, toKill
+	return changed, toBoot, toKill, toUpdate
 }
 
 func doContainers(dk docker.Client, ifaces []interface{},
-	do func(docker.Client, interface{})) {
+	do func(docker.Client, interface{}) error) {
 
 	var wg sync.WaitGroup
 	wg.Add(len(ifaces))
@@ -113,52 +219,132 @@ func doContainers(dk docker.Client, ifaces []interface{},
 	}
 }
 
-func dockerRun(dk docker.Client, iface interface{}) {
+func dockerRun(dk docker.Client, iface interface{}) error {
 	dbc := iface.(db.Container)
 	log.WithField("container", dbc).Info("Start container")
-	_, err := dk.Run(docker.RunOptions{
-		Image:             dbc.Image,
+
+	labels := map[string]string{
+		labelKey: labelValue,
+		filesKey: filesHash(dbc.FilepathToContent),
+	}
+	if len(dbc.PreStop) > 0 {
+		preStop, _ := json.Marshal(dbc.PreStop)
+		labels[preStopKey] = string(preStop)
+	}
+
+	id, err := dk.Run(docker.RunOptions{
+		Image:             runImage(dbc.Image),
 		Args:              dbc.Command,
 		Env:               dbc.Env,
 		FilepathToContent: dbc.FilepathToContent,
-		Labels: map[string]string{
-			labelKey: labelValue,
-			filesKey: filesHash(dbc.FilepathToContent),
-		},
-		IP:          dbc.IP,
-		NetworkMode: plugin.NetworkName,
-		DNS:         []string{ipdef.GatewayIP.String()},
-		DNSSearch:   []string{"q"},
+		Labels:            labels,
+		IP:                dbc.IP,
+		NetworkMode:       plugin.NetworkName,
+		Networks:          dbc.Networks,
+		TTY:               dbc.TTY,
+		DNS:               dnsServers(dbc),
+		DNSSearch:         dnsSearch(dbc),
+		ExtraHosts:        dbc.ExtraHosts,
 	})
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error":     err,
 			"container": dbc,
 		}).WithError(err).Warning("Failed to run container")
+		return err
+	}
+
+	if len(dbc.PostStart) > 0 {
+		if err := dk.Exec(id, dbc.PostStart); err != nil {
+			// The container is removed so that the boot is retried, subject
+			// to the usual backoff.
+			log.WithError(err).WithField("container", dbc).Warning(
+				"PostStart hook failed. Removing container.")
+			dk.RemoveID(id)
+			return fmt.Errorf("postStart: %s", err)
+		}
 	}
+	return nil
 }
 
-func dockerKill(dk docker.Client, iface interface{}) {
+func dockerKill(dk docker.Client, iface interface{}) error {
 	dkc := iface.(docker.Container)
+
+	var preStop []string
+	if label := dkc.Labels[preStopKey]; label != "" {
+		if err := json.Unmarshal([]byte(label), &preStop); err != nil {
+			log.WithError(err).WithField("id", dkc.ID).Warning(
+				"Malformed preStop hook.")
+		}
+	}
+
+	// The container is removed even if the hook fails, as otherwise it could
+	// never be stopped.
+	if len(preStop) > 0 {
+		if err := dk.Exec(dkc.ID, preStop); err != nil {
+			log.WithError(err).WithField("id", dkc.ID).Warning(
+				"PreStop hook failed.")
+		}
+	}
+
 	log.WithField("container", dkc.ID).Info("Remove container")
-	if err := dk.RemoveID(dkc.ID); err != nil {
+	setFilesHash(dkc.ID, "")
+	err := dk.RemoveID(dkc.ID)
+	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 			"id":    dkc.ID,
 		}).Warning("Failed to remove container.")
 	}
+	return err
+}
+
+// dockerUpdateFiles copies the files of the database container in `iface`, a
+// join.Pair, into the running container that implements it, and then sends it
+// its reload signal.  If either fails, the container is removed so that it's
+// restarted with the new files instead.
+func dockerUpdateFiles(dk docker.Client, iface interface{}) error {
+	pair := iface.(join.Pair)
+	dbc := pair.L.(db.Container)
+	dkc := pair.R.(docker.Container)
+	log.WithField("container", dbc).Info("Update container files")
+
+	err := dk.UploadFiles(dkc.ID, dbc.FilepathToContent)
+	if err == nil {
+		err = dk.Signal(dkc.ID, dbc.ReloadSignal)
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("container", dbc).Warning(
+			"Failed to update container files. Removing container.")
+		dockerKill(dk, dkc)
+		return err
+	}
+
+	setFilesHash(dkc.ID, filesHash(dbc.FilepathToContent))
+	return nil
 }
 
 func syncJoinScore(left, right interface{}) int {
 	dbc := left.(db.Container)
 	dkc := right.(docker.Container)
 
-	if dbc.IP != dkc.IP || filesHash(dbc.FilepathToContent) != dkc.Labels[filesKey] {
+	// Containers with a reload signal can have their files updated in place,
+	// but are paired with up to date containers first.
+	score := 0
+	if filesHash(dbc.FilepathToContent) != currentFilesHash(dkc) {
+		if dbc.ReloadSignal == "" {
+			return -1
+		}
+		score = 1
+	}
+
+	if dbc.IP != dkc.IP {
 		return -1
 	}
 
 	compareIDs := dbc.ImageID != ""
-	namesMatch := dkc.Image == dbc.Image
+	namesMatch := dkc.Image == runImage(dbc.Image)
 	idsMatch := dkc.ImageID == dbc.ImageID
 	if (compareIDs && !idsMatch) || (!compareIDs && !namesMatch) {
 		return -1
@@ -170,6 +356,42 @@ func syncJoinScore(left, right interface{}) int {
 		}
 	}
 
+	if dbc.TTY != dkc.TTY {
+		return -1
+	}
+
+	// Besides the Quilt network, the container must be connected to exactly
+	// the networks in the blueprint.
+	networks := map[string]bool{plugin.NetworkName: true}
+	for _, network := range dbc.Networks {
+		networks[network] = true
+	}
+	for _, network := range dkc.Networks {
+		if !networks[network] {
+			return -1
+		}
+		delete(networks, network)
+	}
+	delete(networks, plugin.NetworkName)
+	if len(networks) != 0 {
+		return -1
+	}
+
+	// The containers that are issued credentials must be pointed at them.
+	if (dbc.CloudRole != "") != (dkc.Env[credentials.EndpointEnv] != "") {
+		return -1
+	}
+
+	// Quilt's DNS server and search domain are always added, so only the
+	// blueprint's additions are compared.
+	dns := trimFirst(dkc.DNS, ipdef.GatewayIP.String())
+	dnsSearch := trimFirst(dkc.DNSSearch, "q")
+	if !util.StrSliceEqual(dbc.DNS, dns) ||
+		!util.StrSliceEqual(dbc.DNSSearch, dnsSearch) ||
+		!util.StrStrMapEqual(dbc.ExtraHosts, dkc.ExtraHosts) {
+		return -1
+	}
+
 	// Depending on the container, the command in the database could be
 	// either the command plus it's arguments, or just it's arguments.  To
 	// handle that case, we check both.
@@ -181,7 +403,26 @@ func syncJoinScore(left, right interface{}) int {
 		return -1
 	}
 
-	return 0
+	return score
+}
+
+// dnsServers returns the DNS servers of `dbc`.  Quilt's DNS server, which
+// resolves the hostnames of containers, is queried first.  It doesn't respond
+// for names it can't resolve, so the container's own servers are queried next.
+func dnsServers(dbc db.Container) []string {
+	return append([]string{ipdef.GatewayIP.String()}, dbc.DNS...)
+}
+
+func dnsSearch(dbc db.Container) []string {
+	return append([]string{"q"}, dbc.DNSSearch...)
+}
+
+// trimFirst returns `slice` without its first element if that element is `s`.
+func trimFirst(slice []string, s string) []string {
+	if len(slice) > 0 && slice[0] == s {
+		return slice[1:]
+	}
+	return slice
 }
 
 func filesHash(filepathToContent map[string]string) string {
@@ -189,6 +430,31 @@ func filesHash(filepathToContent map[string]string) string {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(toHash)))
 }
 
+// currentFilesHash returns the hash of the files in `dkc`, taking into account
+// files that were updated in place.
+func currentFilesHash(dkc docker.Container) string {
+	updatedFiles.Lock()
+	defer updatedFiles.Unlock()
+
+	if hash, ok := updatedFiles.hashes[dkc.ID]; ok {
+		return hash
+	}
+	return dkc.Labels[filesKey]
+}
+
+// setFilesHash records that the files with `hash` were copied into the
+// container with `id`.  An empty hash forgets the container.
+func setFilesHash(id, hash string) {
+	updatedFiles.Lock()
+	defer updatedFiles.Unlock()
+
+	if hash == "" {
+		delete(updatedFiles.hashes, id)
+	} else {
+		updatedFiles.hashes[id] = hash
+	}
+}
+
 func updateOpenflow(conn db.Conn, myIP string) {
 	var dbcs []db.Container
 	var conns []db.Connection
@@ -205,7 +471,24 @@ func updateOpenflow(conn db.Conn, myIP string) {
 	ofcs := openflowContainers(dbcs, conns)
 	if err := replaceFlows(ofcs); err != nil {
 		log.WithError(err).Warning("Failed to update OpenFlow")
+		return
 	}
+	updateProgrammedConnections(conn, conns)
+}
+
+// updateProgrammedConnections publishes the hash of the connections whose rules
+// were just installed in OpenFlow in the worker's minion row, so that the leader
+// can report which connections are implemented on every worker.
+func updateProgrammedConnections(conn db.Conn, conns []db.Connection) {
+	programmed := db.HashConnectionKeys(db.ConnectionKeys(conns))
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		if self.ProgrammedConnections != programmed {
+			self.ProgrammedConnections = programmed
+			view.Commit(self)
+		}
+		return nil
+	})
 }
 
 func openflowContainers(dbcs []db.Container,
@@ -247,8 +530,9 @@ func openflowContainers(dbcs []db.Container,
 			Mac:   ipdef.IPStrToMac(dbc.IP),
 			IP:    dbc.IP,
 
-			ToPub:   map[int]struct{}{},
-			FromPub: map[int]struct{}{},
+			ToPub:    map[int]struct{}{},
+			FromPub:  map[int]struct{}{},
+			Draining: dbc.Draining,
 		}
 
 		for _, p := range toPubPorts[dbc.Hostname] {
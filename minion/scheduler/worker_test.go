@@ -2,13 +2,18 @@ package scheduler
 
 import (
 	"errors"
+	"runtime"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	dkc "github.com/fsouza/go-dockerclient"
 	"github.com/kelda/kelda/blueprint"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/credentials"
 	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/minion/ipdef"
 	"github.com/kelda/kelda/minion/network/openflow"
+	"github.com/kelda/kelda/minion/network/plugin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,6 +21,7 @@ func TestRunWorker(t *testing.T) {
 	t.Parallel()
 
 	replaceFlows = func(ofcs []openflow.Container) error { return errors.New("err") }
+	cleanupEndpoints = func(eids []string) {}
 
 	md, dk := docker.NewMock()
 	conn := db.New()
@@ -54,15 +60,85 @@ func TestRunWorker(t *testing.T) {
 	assert.Equal(t, "Image", dkcs[0].Image)
 }
 
+func TestRunWorkerCleanup(t *testing.T) {
+	replaceFlows = func(ofcs []openflow.Container) error { return nil }
+
+	var cleaned bool
+	var liveEndpoints []string
+	cleanupEndpoints = func(eids []string) {
+		cleaned = true
+		liveEndpoints = eids
+	}
+	defer func() { cleanupEndpoints = plugin.CleanupEndpoints }()
+
+	md, dk := docker.NewMock()
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Self = true
+		m.PrivateIP = "1.2.3.4"
+		view.Commit(m)
+		return nil
+	})
+
+	_, err := dk.Run(docker.RunOptions{
+		Name:   "orphan",
+		Image:  "Image",
+		Labels: map[string]string{labelKey: labelValue},
+	})
+	assert.NoError(t, err)
+
+	// Endpoints aren't cleaned up if a container couldn't be inspected, as its
+	// endpoint may still be in use.
+	md.InspectContainerError = true
+	runWorker(conn, dk, "1.2.3.4")
+	assert.False(t, cleaned)
+	md.InspectContainerError = false
+
+	// The orphaned container must be removed before cleaning up endpoints.
+	runWorker(conn, dk, "1.2.3.4")
+	assert.True(t, cleaned)
+	assert.Empty(t, liveEndpoints)
+}
+
 func runSync(dk docker.Client, dbcs []db.Container,
 	dkcs []docker.Container) []db.Container {
 
-	changes, tdbcs, tdkcs := syncWorker(dbcs, dkcs)
+	changes, tdbcs, tdkcs, updates := syncWorker(dbcs, dkcs)
+	doContainers(dk, updates, dockerUpdateFiles)
 	doContainers(dk, tdkcs, dockerKill)
 	doContainers(dk, tdbcs, dockerRun)
 	return changes
 }
 
+func TestUpdateProgrammedConnections(t *testing.T) {
+	conn := db.New()
+	conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Self = true
+		view.Commit(m)
+
+		for _, c := range []db.Connection{
+			{From: "web", To: "db", MinPort: 5432, MaxPort: 5432},
+			{From: "app", To: "web", MinPort: 80, MaxPort: 80},
+		} {
+			c.ID = view.InsertConnection().ID
+			view.Commit(c)
+		}
+		return nil
+	})
+
+	// Connections aren't reported as programmed if OpenFlow fails to update.
+	replaceFlows = func(ofcs []openflow.Container) error { return assert.AnError }
+	updateOpenflow(conn, "1.2.3.4")
+	assert.Empty(t, conn.MinionSelf().ProgrammedConnections)
+
+	replaceFlows = func(ofcs []openflow.Container) error { return nil }
+	updateOpenflow(conn, "1.2.3.4")
+	assert.Equal(t, db.HashConnectionKeys([]string{"app->web:80-80",
+		"web->db:5432-5432"}), conn.MinionSelf().ProgrammedConnections)
+}
+
 func TestSyncWorker(t *testing.T) {
 	t.Parallel()
 
@@ -83,7 +159,7 @@ func TestSyncWorker(t *testing.T) {
 
 	runSync(dk, dbcs, nil)
 	dkcs, err := dk.List(nil)
-	changed, _, _ = syncWorker(dbcs, dkcs)
+	changed, _, _, _ = syncWorker(dbcs, dkcs)
 	assert.NoError(t, err)
 
 	if changed[0].DockerID != dkcs[0].ID {
@@ -160,6 +236,126 @@ func TestInitsFiles(t *testing.T) {
 	}, md.Uploads)
 }
 
+func TestUpdateFiles(t *testing.T) {
+	t.Parallel()
+
+	md, dk := docker.NewMock()
+	dbcs := []db.Container{{
+		ID:                1,
+		Image:             "Image1",
+		FilepathToContent: map[string]string{"/app.conf": "old"},
+		ReloadSignal:      "SIGHUP",
+	}}
+
+	runSync(dk, dbcs, nil)
+	dkcs, err := dk.List(nil)
+	assert.NoError(t, err)
+	assert.Len(t, dkcs, 1)
+	id := dkcs[0].ID
+
+	// The new files are copied into the running container, which is then told
+	// to reload them.
+	dbcs[0].FilepathToContent = map[string]string{"/app.conf": "new"}
+	changed := runSync(dk, dbcs, dkcs)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, id, changed[0].DockerID)
+	assert.Contains(t, md.Uploads, docker.UploadToContainerOptions{
+		ContainerID: id,
+		UploadPath:  "/",
+		TarPath:     "app.conf",
+		Contents:    "new",
+	})
+	assert.Equal(t, []dkc.Signal{dkc.SIGHUP}, md.Signals[id])
+
+	// The update isn't repeated, even though the container's label still
+	// describes the old files.
+	_, toBoot, toKill, toUpdate := syncWorker(dbcs, dkcs)
+	assert.Empty(t, toBoot)
+	assert.Empty(t, toKill)
+	assert.Empty(t, toUpdate)
+
+	// Containers that fail to update are removed, so that they're restarted.
+	dbcs[0].FilepathToContent = map[string]string{"/app.conf": "newer"}
+	md.UploadError = true
+	runSync(dk, dbcs, dkcs)
+	md.UploadError = false
+
+	dkcs, err = dk.List(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, dkcs)
+
+	// Without a reload signal, changed files restart the container.
+	dbcs[0].ReloadSignal = ""
+	runSync(dk, dbcs, nil)
+	dkcs, err = dk.List(nil)
+	assert.NoError(t, err)
+	assert.Len(t, dkcs, 1)
+
+	dbcs[0].FilepathToContent = map[string]string{"/app.conf": "newest"}
+	_, toBoot, toKill, toUpdate = syncWorker(dbcs, dkcs)
+	assert.Len(t, toBoot, 1)
+	assert.Len(t, toKill, 1)
+	assert.Empty(t, toUpdate)
+}
+
+func TestContainerHooks(t *testing.T) {
+	t.Parallel()
+
+	md, dk := docker.NewMock()
+	md.Pulled["image"] = struct{}{}
+	dbc := db.Container{
+		Image:     "image",
+		PostStart: []string{"warm", "cache"},
+		PreStop:   []string{"deregister"},
+	}
+
+	assert.NoError(t, dockerRun(dk, dbc))
+	dkcs, err := dk.List(nil)
+	assert.NoError(t, err)
+	assert.Len(t, dkcs, 1)
+	assert.Equal(t, []string{"warm cache"}, md.Executions[dkcs[0].ID])
+	assert.Equal(t, `["deregister"]`, dkcs[0].Labels[preStopKey])
+
+	// The preStop hook runs from the label, even though the database container
+	// is gone.
+	assert.NoError(t, dockerKill(dk, dkcs[0]))
+	assert.Equal(t, []string{"warm cache", "deregister"},
+		md.Executions[dkcs[0].ID])
+
+	// Containers whose postStart hook fails are removed.
+	md.ExecExitCode = 1
+	assert.EqualError(t, dockerRun(dk, dbc), "postStart: exit status 1: ")
+	dkcs, err = dk.List(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, dkcs)
+}
+
+func TestRunImageArch(t *testing.T) {
+	arch = "arm64"
+	defer func() { arch = runtime.GOARCH }()
+
+	// The Quilt image is run as the worker's build of it.
+	armImage := QuiltImage + "-arm64"
+	md, dk := docker.NewMock()
+	md.Pulled[armImage] = struct{}{}
+	dbc := db.Container{IP: "1.2.3.4", Image: QuiltImage}
+	assert.NoError(t, dockerRun(dk, dbc))
+
+	dkcs, err := dk.List(nil)
+	assert.NoError(t, err)
+	assert.Len(t, dkcs, 1)
+	assert.Equal(t, armImage, dkcs[0].Image)
+
+	dkc := docker.Container{IP: dbc.IP, Image: armImage,
+		Labels: map[string]string{filesKey: filesHash(nil)}}
+	assert.Zero(t, syncJoinScore(dbc, dkc))
+	dkc.Image = QuiltImage
+	assert.Equal(t, -1, syncJoinScore(dbc, dkc))
+
+	// Other images are run as named.
+	assert.Equal(t, "nginx", runImage("nginx"))
+}
+
 func TestSyncJoinScore(t *testing.T) {
 	t.Parallel()
 
@@ -217,10 +413,83 @@ func TestSyncJoinScore(t *testing.T) {
 	score = syncJoinScore(dbc, dkc)
 	assert.Equal(t, -1, score)
 
+	// Containers with a reload signal can be updated in place.
+	dbc.ReloadSignal = "SIGHUP"
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, 1, score)
+	dbc.ReloadSignal = ""
+
 	dbc.FilepathToContent = map[string]string{"c": "d"}
 	score = syncJoinScore(dbc, dkc)
 	assert.Zero(t, score)
 
+	// The Quilt network doesn't need to be listed in the blueprint.
+	dkc.Networks = []string{"quilt", "vpc"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+
+	dbc.Networks = []string{"vpc"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
+	dbc.Networks = []string{"vpc", "overlay"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+	dbc.Networks = []string{"vpc"}
+
+	// Containers with a cloud role must be pointed at their credentials.
+	dbc.CloudRole = "arn:aws:iam::123456789012:role/uploader"
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+
+	dkc.Env = map[string]string{"a": "b",
+		credentials.EndpointEnv: credentials.Endpoint}
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
+	dbc.CloudRole = ""
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+	dkc.Env = dbc.Env
+
+	// Quilt's DNS server and search domain don't need to be listed either.
+	dkc.DNS = []string{ipdef.GatewayIP.String()}
+	dkc.DNSSearch = []string{"q"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
+	dbc.DNS = []string{"192.168.1.53"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+
+	dkc.DNS = []string{ipdef.GatewayIP.String(), "192.168.1.53"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
+	dbc.DNSSearch = []string{"corp.internal"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+
+	dkc.DNSSearch = []string{"q", "corp.internal"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
+	dbc.ExtraHosts = map[string]string{"legacy": "192.168.1.10"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+
+	dkc.ExtraHosts = map[string]string{"legacy": "192.168.1.10"}
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
+	dbc.TTY = true
+	score = syncJoinScore(dbc, dkc)
+	assert.Equal(t, -1, score)
+
+	dkc.TTY = true
+	score = syncJoinScore(dbc, dkc)
+	assert.Zero(t, score)
+
 	dkc.ImageID = "id"
 	dbc.Command = dkc.Args
 	dbc.Env = dkc.Env
@@ -252,4 +521,18 @@ func TestOpenFlowContainers(t *testing.T) {
 		FromPub: map[int]struct{}{2: {}},
 	}}
 	assert.Equal(t, exp, res)
+
+	res = openflowContainers([]db.Container{
+		{EndpointID: "f", IP: "1.2.3.4", Hostname: "green", Draining: true}},
+		conns)
+	exp = []openflow.Container{{
+		Veth:     "f",
+		Patch:    "q_f",
+		IP:       "1.2.3.4",
+		Mac:      "02:00:01:02:03:04",
+		ToPub:    map[int]struct{}{},
+		FromPub:  map[int]struct{}{},
+		Draining: true,
+	}}
+	assert.Equal(t, exp, res)
 }
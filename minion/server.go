@@ -1,67 +1,204 @@
 package minion
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/connection"
+	"github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/pb"
+	"github.com/kelda/kelda/version"
 
 	"golang.org/x/net/context"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// The number of configs per second that the minion applies, and the number that it
+// may apply in a burst above that rate.  The daemon only sends configs when they
+// change, so a higher rate suggests that it's misbehaving, and limiting it keeps
+// the minion from churning its containers and etcd.
+var configRate, configBurst = 1.0, 10.0
+
 type server struct {
 	db.Conn
+
+	// The PEM-encoded certificate of the daemon's certificate authority, with
+	// which configs must be signed.
+	caCert string
+
+	limiter *configLimiter
 }
 
-func minionServerRun(conn db.Conn, creds connection.Credentials) {
+func newServer(conn db.Conn, caCert string) server {
+	return server{conn, caCert, &configLimiter{tokens: configBurst, last: now()}}
+}
+
+func minionServerRun(conn db.Conn, creds connection.Credentials, caCert string) {
 	sock, s := connection.Server("tcp", ":9999", creds.ServerOpts())
-	server := server{conn}
-	pb.RegisterMinionServer(s, server)
+	pb.RegisterMinionServer(s, newServer(conn, caCert))
 	s.Serve(sock)
 }
 
-func (s server) GetMinionConfig(cts context.Context,
+func (s server) GetMinionConfig(ctx context.Context,
 	_ *pb.Request) (*pb.MinionConfig, error) {
 
-	var cfg pb.MinionConfig
-
 	c.Inc("GetMinionConfig")
 
+	// The config includes the blueprint, so only the daemon may read it.
+	if err := tls.VerifyDaemon(ctx); err != nil {
+		c.Inc("Unauthorized Client")
+		return nil, err
+	}
+	return s.config(), nil
+}
+
+// config returns the minion's config, as reported to the daemon.
+func (s server) config() *pb.MinionConfig {
+	var cfg pb.MinionConfig
+
 	m := s.MinionSelf()
 	cfg.Role = db.RoleToPB(m.Role)
 	cfg.PrivateIP = m.PrivateIP
+	cfg.PublicIP = m.PublicIP
 	cfg.Blueprint = m.Blueprint
 	cfg.Provider = m.Provider
 	cfg.Size = m.Size
 	cfg.Region = m.Region
+	cfg.Labels = m.Labels
 	cfg.AuthorizedKeys = strings.Split(m.AuthorizedKeys, "\n")
+	cfg.NetworkDegraded = m.NetworkDegraded
+	cfg.DiskGC = m.DiskGC
+	cfg.Interrupted = m.Interrupted
+	cfg.SelfHosted = m.SelfHosted
+	cfg.Generation = m.ConfigGeneration
+	cfg.APIVersion = version.APIVersion
+	cfg.MinAPIVersion = version.MinAPIVersion
+
+	if len(m.DiskUsage) > 0 {
+		cfg.DiskUsage = map[string]int32{}
+		for partition, usage := range m.DiskUsage {
+			cfg.DiskUsage[partition] = int32(usage)
+		}
+	}
+
+	for hostname, key := range m.ContainerKeys {
+		pubKey, err := rsa.PublicKeyString(key)
+		if err != nil {
+			log.WithError(err).WithField("hostname", hostname).Warn(
+				"Failed to read container key.")
+			continue
+		}
+
+		if cfg.ContainerKeys == nil {
+			cfg.ContainerKeys = map[string]string{}
+		}
+		cfg.ContainerKeys[hostname] = pubKey
+	}
 
-	s.Txn(db.EtcdTable).Run(func(view db.Database) error {
+	s.Txn(db.ContainerTable, db.EtcdTable).Run(func(view db.Database) error {
 		if etcdRow, err := view.GetEtcd(); err == nil {
 			cfg.EtcdMembers = etcdRow.EtcdIPs
 		}
+
+		// The daemon waits until a draining minion has no containers left
+		// before rebooting or reimaging its machine.
+		if m.Drain {
+			cfg.Drain = true
+			cfg.Drained = len(view.SelectFromContainer(
+				func(dbc db.Container) bool {
+					return dbc.Minion == m.PrivateIP
+				})) == 0
+		}
 		return nil
 	})
 
-	return &cfg, nil
+	return &cfg
 }
 
 func (s server) SetMinionConfig(ctx context.Context,
 	msg *pb.MinionConfig) (*pb.Reply, error) {
 
 	c.Inc("SetMinionConfig")
-	go s.Txn(db.EtcdTable, db.MinionTable).Run(func(view db.Database) error {
+
+	// Machines' certificates are signed by the same CA as the daemon's, so
+	// they'd otherwise be able to reconfigure other minions.
+	if err := tls.VerifyDaemon(ctx); err != nil {
+		c.Inc("Unauthorized Client")
+		log.WithError(err).Warn("Rejected minion config.")
+		return nil, err
+	}
+
+	err := version.CheckCompatible("daemon", msg.APIVersion, msg.MinAPIVersion)
+	if err != nil {
+		c.Inc("Incompatible Daemon")
+		log.WithError(err).Warn("Rejected minion config.")
+		return nil, err
+	}
+
+	if !s.limiter.allow() {
+		c.Inc("Rate Limited")
+		return nil, errors.New("rate limit exceeded")
+	}
+
+	// Any machine with credentials signed by the certificate authority may
+	// connect, so the signature proves that the config came from the daemon
+	// rather than, for example, a compromised worker.
+	signed, err := msg.SignedBytes()
+	if err == nil {
+		err = rsa.Verify(s.caCert, signed, msg.Signature)
+	}
+	if err != nil {
+		c.Inc("Invalid Signature")
+		log.WithError(err).Warn("Rejected minion config with an invalid " +
+			"signature.")
+		return nil, fmt.Errorf("invalid signature: %s", err)
+	}
+
+	err = s.Txn(db.EtcdTable, db.MinionTable).Run(func(view db.Database) error {
 		minion := view.MinionSelf()
+
+		// Configs are signed along with their generation, so this keeps
+		// old configs from being replayed.
+		if msg.Generation <= minion.ConfigGeneration {
+			c.Inc("Stale Config")
+			return fmt.Errorf("config generation %d isn't newer than "+
+				"the applied generation %d", msg.Generation,
+				minion.ConfigGeneration)
+		}
+
 		minion.PrivateIP = msg.PrivateIP
+		minion.PublicIP = msg.PublicIP
 		minion.Blueprint = msg.Blueprint
 		minion.Provider = msg.Provider
 		minion.Size = msg.Size
 		minion.Region = msg.Region
 		minion.FloatingIP = msg.FloatingIP
+		minion.Labels = msg.Labels
+		minion.DiskGC = msg.DiskGC
+		minion.Drain = msg.Drain
+		minion.EtcdPasswords = msg.EtcdPasswords
+		minion.SelfHost = msg.SelfHost
+		minion.DisableSelfHost = msg.DisableSelfHost
+		minion.ContainerCerts = msg.ContainerCerts
+		minion.ConfigGeneration = msg.Generation
+
+		minion.ACLs = nil
+		for _, pbACL := range msg.ACLs {
+			minion.ACLs = append(minion.ACLs, acl.ACL{
+				CidrIP:  pbACL.CidrIP,
+				MinPort: int(pbACL.MinPort),
+				MaxPort: int(pbACL.MaxPort),
+			})
+		}
 		minion.AuthorizedKeys = strings.Join(msg.AuthorizedKeys, "\n")
 		minion.Self = true
 		view.Commit(minion)
@@ -78,6 +215,33 @@ func (s server) SetMinionConfig(ctx context.Context,
 
 		return nil
 	})
+	if err != nil {
+		log.WithError(err).Warn("Rejected minion config.")
+		return nil, err
+	}
 
 	return &pb.Reply{}, nil
 }
+
+// A configLimiter is a token bucket that limits the rate at which configs are
+// applied.
+type configLimiter struct {
+	sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow returns true if a config may be applied, and consumes a token if so.
+func (l *configLimiter) allow() bool {
+	l.Lock()
+	defer l.Unlock()
+
+	t := now()
+	l.tokens = math.Min(configBurst, l.tokens+t.Sub(l.last).Seconds()*configRate)
+	l.last = t
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
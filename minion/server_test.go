@@ -1,19 +1,33 @@
 package minion
 
 import (
+	cryptoTLS "crypto/tls"
+	"crypto/x509"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 
+	"github.com/kelda/kelda/cloud/acl"
+	"github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/connection/tls/rsa"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/pb"
+	"github.com/kelda/kelda/version"
 )
 
 func TestSetMinionConfig(t *testing.T) {
 	t.Parallel()
-	s := server{db.New()}
+
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+	s := newServer(db.New(), ca.CertString())
+	ctx := daemonContext(t, ca)
 
 	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		m := view.InsertMinion()
@@ -25,24 +39,42 @@ func TestSetMinionConfig(t *testing.T) {
 
 	cfg := pb.MinionConfig{
 		PrivateIP:      "priv",
+		PublicIP:       "pub",
 		Blueprint:      "blueprint",
 		Provider:       "provider",
 		Size:           "size",
 		Region:         "region",
+		Labels:         map[string]string{"ssd": "true"},
 		EtcdMembers:    []string{"etcd1", "etcd2"},
 		AuthorizedKeys: []string{"key1", "key2"},
+		DiskGC:         true,
+		Drain:          true,
+		ACLs:           []*pb.ACL{{CidrIP: "1.2.3.4/32", MinPort: 22, MaxPort: 22}},
+		EtcdPasswords:  map[string]string{"root": "password"},
+		ContainerCerts: map[string]string{"web": "cert"},
+		Generation:     7,
+		APIVersion:     version.APIVersion,
+		MinAPIVersion:  version.MinAPIVersion,
 	}
 	expMinion := db.Minion{
-		Self:           true,
-		Blueprint:      "blueprint",
-		PrivateIP:      "priv",
-		Provider:       "provider",
-		Role:           db.Master,
-		Size:           "size",
-		Region:         "region",
-		AuthorizedKeys: "key1\nkey2",
+		Self:             true,
+		Blueprint:        "blueprint",
+		PrivateIP:        "priv",
+		PublicIP:         "pub",
+		Provider:         "provider",
+		Role:             db.Master,
+		Size:             "size",
+		Region:           "region",
+		Labels:           map[string]string{"ssd": "true"},
+		AuthorizedKeys:   "key1\nkey2",
+		DiskGC:           true,
+		Drain:            true,
+		ACLs:             []acl.ACL{{CidrIP: "1.2.3.4/32", MinPort: 22, MaxPort: 22}},
+		EtcdPasswords:    map[string]string{"root": "password"},
+		ContainerCerts:   map[string]string{"web": "cert"},
+		ConfigGeneration: 7,
 	}
-	_, err := s.SetMinionConfig(nil, &cfg)
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, cfg))
 	assert.NoError(t, err)
 	checkMinionEquals(t, s.Conn, expMinion)
 	checkEtcdEquals(t, s.Conn, db.Etcd{
@@ -51,14 +83,155 @@ func TestSetMinionConfig(t *testing.T) {
 
 	// Update a field.
 	cfg.Blueprint = "new"
-	expMinion.Blueprint = "new"
 	cfg.EtcdMembers = []string{"etcd3"}
-	_, err = s.SetMinionConfig(nil, &cfg)
+	cfg.SelfHost = []byte("bundle")
+	cfg.Generation = 8
+	expMinion.Blueprint = "new"
+	expMinion.SelfHost = []byte("bundle")
+	expMinion.ConfigGeneration = 8
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, cfg))
 	assert.NoError(t, err)
 	checkMinionEquals(t, s.Conn, expMinion)
 	checkEtcdEquals(t, s.Conn, db.Etcd{
 		EtcdIPs: []string{"etcd3"},
 	})
+
+	// Configs that are forged, unsigned, or signed by another CA are rejected
+	// entirely.
+	forged := *sign(t, ca, cfg)
+	forged.Generation = 9
+	forged.DiskGC = false
+	_, err = s.SetMinionConfig(ctx, &forged)
+	assert.Error(t, err)
+
+	forged.Signature = nil
+	_, err = s.SetMinionConfig(ctx, &forged)
+	assert.Error(t, err)
+
+	otherCA, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+	_, err = s.SetMinionConfig(ctx, sign(t, otherCA, forged))
+	assert.Error(t, err)
+
+	// So are configs that aren't newer than the applied one, so that old
+	// configs can't be replayed.
+	old := cfg
+	old.DiskGC = false
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, old))
+	assert.EqualError(t, err, "config generation 8 isn't newer than the "+
+		"applied generation 8")
+
+	old.Generation = 7
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, old))
+	assert.Error(t, err)
+
+	// Only the daemon may set the config, even though machines' certificates
+	// are signed by the same CA.
+	machine, err := rsa.NewSignedWithURIs(ca,
+		[]*url.URL{tls.MachineURI("id")})
+	assert.NoError(t, err)
+	cfg.DiskGC = false
+	cfg.Generation = 9
+	_, err = s.SetMinionConfig(peerContext(machine), sign(t, ca, cfg))
+	assert.EqualError(t, err, "peer certificate wasn't issued to the daemon")
+
+	// Configs from daemons that speak an incompatible API version are
+	// rejected.
+	incompatible := cfg
+	incompatible.MinAPIVersion = version.APIVersion + 1
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, incompatible))
+	assert.Error(t, err)
+
+	checkMinionEquals(t, s.Conn, expMinion)
+	checkEtcdEquals(t, s.Conn, db.Etcd{
+		EtcdIPs: []string{"etcd3"},
+	})
+
+	// Self-hosting is only disabled when the daemon says so.
+	cfg.SelfHost = nil
+	cfg.DisableSelfHost = true
+	expMinion.SelfHost = nil
+	expMinion.DisableSelfHost = true
+	expMinion.DiskGC = false
+	expMinion.ConfigGeneration = 9
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, cfg))
+	assert.NoError(t, err)
+	checkMinionEquals(t, s.Conn, expMinion)
+
+	// Workers aren't sent the blueprint.
+	cfg.Blueprint = ""
+	cfg.DisableSelfHost = false
+	cfg.Generation = 10
+	expMinion.Blueprint = ""
+	expMinion.DisableSelfHost = false
+	expMinion.ConfigGeneration = 10
+	_, err = s.SetMinionConfig(ctx, sign(t, ca, cfg))
+	assert.NoError(t, err)
+	checkMinionEquals(t, s.Conn, expMinion)
+}
+
+func TestSetMinionConfigRateLimit(t *testing.T) {
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	s := newServer(db.New(), ca.CertString())
+	ctx := daemonContext(t, ca)
+	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.InsertMinion()
+		m.Self = true
+		view.Commit(m)
+		return nil
+	})
+
+	set := func(generation int64) error {
+		_, err := s.SetMinionConfig(ctx, sign(t, ca, pb.MinionConfig{
+			Generation:    generation,
+			APIVersion:    version.APIVersion,
+			MinAPIVersion: version.MinAPIVersion,
+		}))
+		return err
+	}
+
+	// Configs may be applied in bursts, after which they're limited to the
+	// configured rate.
+	for i := 1; i <= int(configBurst); i++ {
+		assert.NoError(t, set(int64(i)))
+	}
+	assert.EqualError(t, set(100), "rate limit exceeded")
+
+	current = current.Add(time.Second)
+	assert.NoError(t, set(100))
+	assert.EqualError(t, set(101), "rate limit exceeded")
+}
+
+// sign returns `cfg` signed by `ca`.
+func sign(t *testing.T, ca rsa.KeyPair, cfg pb.MinionConfig) *pb.MinionConfig {
+	signed, err := cfg.SignedBytes()
+	assert.NoError(t, err)
+
+	cfg.Signature, err = ca.Sign(signed)
+	assert.NoError(t, err)
+	return &cfg
+}
+
+// daemonContext returns the context of a request from a client that presented a
+// daemon certificate signed by `ca`.
+func daemonContext(t *testing.T, ca rsa.KeyPair) context.Context {
+	daemon, err := rsa.NewSignedWithURIs(ca, []*url.URL{tls.DaemonURI()})
+	assert.NoError(t, err)
+	return peerContext(daemon)
+}
+
+func peerContext(client rsa.KeyPair) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: cryptoTLS.ConnectionState{
+			PeerCertificates: []*x509.Certificate{client.Cert()},
+		}},
+	})
 }
 
 func checkMinionEquals(t *testing.T, conn db.Conn, exp db.Minion) {
@@ -104,7 +277,11 @@ func checkEtcdEquals(t *testing.T, conn db.Conn, exp db.Etcd) {
 
 func TestGetMinionConfig(t *testing.T) {
 	t.Parallel()
-	s := server{db.New()}
+
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+	s := newServer(db.New(), ca.CertString())
+	ctx := daemonContext(t, ca)
 
 	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		m := view.InsertMinion()
@@ -112,14 +289,23 @@ func TestGetMinionConfig(t *testing.T) {
 		m.Blueprint = "selfblueprint"
 		m.Role = db.Master
 		m.PrivateIP = "selfpriv"
+		m.PublicIP = "selfpub"
 		m.Provider = "selfprovider"
 		m.Size = "selfsize"
 		m.Region = "selfregion"
 		m.AuthorizedKeys = "key1\nkey2"
+		m.ConfigGeneration = 3
 		view.Commit(m)
 		return nil
 	})
 
+	// Only the daemon may read the config, as it includes the blueprint.
+	machine, err := rsa.NewSignedWithURIs(ca,
+		[]*url.URL{tls.MachineURI("id")})
+	assert.NoError(t, err)
+	_, err = s.GetMinionConfig(peerContext(machine), &pb.Request{})
+	assert.EqualError(t, err, "peer certificate wasn't issued to the daemon")
+
 	// Should only return config for "self".
 	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		m := view.InsertMinion()
@@ -134,16 +320,20 @@ func TestGetMinionConfig(t *testing.T) {
 		view.Commit(m)
 		return nil
 	})
-	cfg, err := s.GetMinionConfig(nil, &pb.Request{})
+	cfg, err := s.GetMinionConfig(ctx, &pb.Request{})
 	assert.NoError(t, err)
 	assert.Equal(t, pb.MinionConfig{
 		Role:           pb.MinionConfig_MASTER,
+		APIVersion:     version.APIVersion,
+		MinAPIVersion:  version.MinAPIVersion,
 		PrivateIP:      "selfpriv",
+		PublicIP:       "selfpub",
 		Blueprint:      "selfblueprint",
 		Provider:       "selfprovider",
 		Size:           "selfsize",
 		Region:         "selfregion",
 		AuthorizedKeys: []string{"key1", "key2"},
+		Generation:     3,
 	}, *cfg)
 
 	// Test returning a full config.
@@ -151,18 +341,72 @@ func TestGetMinionConfig(t *testing.T) {
 		etcd := view.InsertEtcd()
 		etcd.EtcdIPs = []string{"etcd1", "etcd2"}
 		view.Commit(etcd)
+
+		self := view.MinionSelf()
+		self.DiskUsage = map[string]int{"root": 42, "docker": 91}
+		self.SelfHosted = true
+		view.Commit(self)
 		return nil
 	})
-	cfg, err = s.GetMinionConfig(nil, &pb.Request{})
+	cfg, err = s.GetMinionConfig(ctx, &pb.Request{})
 	assert.NoError(t, err)
 	assert.Equal(t, pb.MinionConfig{
 		Role:           pb.MinionConfig_MASTER,
+		APIVersion:     version.APIVersion,
+		MinAPIVersion:  version.MinAPIVersion,
 		PrivateIP:      "selfpriv",
+		PublicIP:       "selfpub",
 		Blueprint:      "selfblueprint",
 		Provider:       "selfprovider",
 		Size:           "selfsize",
 		Region:         "selfregion",
 		EtcdMembers:    []string{"etcd1", "etcd2"},
 		AuthorizedKeys: []string{"key1", "key2"},
+		DiskUsage:      map[string]int32{"root": 42, "docker": 91},
+		SelfHosted:     true,
+		Generation:     3,
 	}, *cfg)
+
+	// A draining minion reports whether containers remain scheduled on it.
+	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		self.Drain = true
+		view.Commit(self)
+
+		dbc := view.InsertContainer()
+		dbc.Minion = "selfpriv"
+		view.Commit(dbc)
+		return nil
+	})
+	cfg, err = s.GetMinionConfig(ctx, &pb.Request{})
+	assert.NoError(t, err)
+	assert.True(t, cfg.Drain)
+	assert.False(t, cfg.Drained)
+
+	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		for _, dbc := range view.SelectFromContainer(nil) {
+			view.Remove(dbc)
+		}
+		return nil
+	})
+	cfg, err = s.GetMinionConfig(ctx, &pb.Request{})
+	assert.NoError(t, err)
+	assert.True(t, cfg.Drain)
+	assert.True(t, cfg.Drained)
+
+	// Only the public keys of the containers' TLS proxies are reported.
+	key, err := rsa.NewPrivateKey()
+	assert.NoError(t, err)
+	pubKey, err := rsa.PublicKeyString(key)
+	assert.NoError(t, err)
+
+	s.Conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		self.ContainerKeys = map[string]string{"web": key, "bad": "garbage"}
+		view.Commit(self)
+		return nil
+	})
+	cfg, err = s.GetMinionConfig(ctx, &pb.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": pubKey}, cfg.ContainerKeys)
 }
@@ -1,6 +1,10 @@
 package images
 
 const (
+	// Daemon is the name of the container that runs the daemon on the etcd
+	// leader of a self-hosted namespace.
+	Daemon = "quilt-daemon"
+
 	// Etcd is the name etcd cluster store container.
 	Etcd = "etcd"
 
@@ -18,4 +22,8 @@ const (
 
 	// Registry is the name of the registry container.
 	Registry = "registry"
+
+	// RegistryCache is the name of the container that caches Docker Hub images
+	// for the workers in a region.
+	RegistryCache = "registry-cache"
 )
@@ -3,6 +3,7 @@ package supervisor
 import (
 	"fmt"
 
+	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/supervisor/images"
 	"github.com/kelda/kelda/util"
@@ -49,10 +50,12 @@ func runMasterOnce() {
 
 	run(images.Etcd, fmt.Sprintf("--name=master-%s", IP),
 		fmt.Sprintf("--initial-cluster=%s", initialClusterString(etcdIPs)),
-		fmt.Sprintf("--advertise-client-urls=http://%s:2379", IP),
-		fmt.Sprintf("--listen-peer-urls=http://%s:2380", IP),
-		fmt.Sprintf("--initial-advertise-peer-urls=http://%s:2380", IP),
-		"--listen-client-urls=http://0.0.0.0:2379",
+		fmt.Sprintf("--advertise-client-urls=http://%s:%d", IP,
+			acl.EtcdClientPort),
+		fmt.Sprintf("--listen-peer-urls=http://%s:%d", IP, acl.EtcdPeerPort),
+		fmt.Sprintf("--initial-advertise-peer-urls=http://%s:%d", IP,
+			acl.EtcdPeerPort),
+		fmt.Sprintf("--listen-client-urls=http://0.0.0.0:%d", acl.EtcdClientPort),
 		"--heartbeat-interval="+etcdHeartbeatInterval,
 		"--initial-cluster-state=new",
 		"--election-timeout="+etcdElectionTimeout)
@@ -68,4 +71,14 @@ func runMasterOnce() {
 	} else {
 		Remove(images.Ovnnorthd)
 	}
+
+	// Every master keeps the self-hosted daemon's state, so that whichever
+	// becomes the leader can take over running it.
+	selfHosted := syncSelfHost(minion) && leader
+	if selfHosted {
+		run(images.Daemon, daemonArgs()...)
+	} else {
+		Remove(images.Daemon)
+	}
+	setSelfHosted(selfHosted)
 }
@@ -2,11 +2,15 @@ package supervisor
 
 import (
 	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/supervisor/images"
+	"github.com/kelda/kelda/selfhost"
+	"github.com/kelda/kelda/util"
+	"github.com/kelda/kelda/version"
 )
 
 func TestNone(t *testing.T) {
@@ -223,3 +227,127 @@ func TestEtcdRemove(t *testing.T) {
 			spew.Sdump(exp))
 	}
 }
+
+func TestSelfHost(t *testing.T) {
+	ctx := initTest(db.Master)
+	ip := "1.2.3.4"
+	etcdIPs := []string{ip}
+
+	bundle, err := selfhost.Bundle(nil)
+	if err != nil {
+		t.Fatalf("failed to bundle: %s", err)
+	}
+
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.MinionSelf()
+		e := view.SelectFromEtcd(nil)[0]
+		m.Role = db.Master
+		m.PrivateIP = ip
+		m.Blueprint = `{"Namespace": "ns"}`
+		m.SelfHost = bundle
+		e.EtcdIPs = etcdIPs
+		view.Commit(m)
+		view.Commit(e)
+		return nil
+	})
+	ctx.run()
+
+	// Followers store the blueprint, but don't run the daemon.
+	if _, ok := ctx.fd.running()[images.Daemon]; ok {
+		t.Errorf("fd.running = %s; want no daemon", spew.Sdump(ctx.fd.running()))
+	}
+	if bp, _ := util.ReadFile(selfhost.BlueprintPath); bp != `{"Namespace": "ns"}` {
+		t.Errorf("blueprint = %q; want the minion's blueprint", bp)
+	}
+
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		e := view.SelectFromEtcd(nil)[0]
+		e.Leader = true
+		view.Commit(e)
+		return nil
+	})
+	ctx.run()
+
+	expArgs := []string{"quilt", "-l", util.LogLevel().String(), "daemon",
+		"-H", "tcp://0.0.0.0:9001", "-self-hosted"}
+	if args := ctx.fd.running()[images.Daemon]; !reflect.DeepEqual(args, expArgs) {
+		t.Errorf("daemon args = %s; want %s", spew.Sdump(args),
+			spew.Sdump(expArgs))
+	}
+	if !ctx.conn.MinionSelf().SelfHosted {
+		t.Error("minion doesn't report running the self-hosted daemon")
+	}
+
+	// Configs without the state, such as those from a daemon that doesn't
+	// know the namespace is self-hosted, leave the daemon running.
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.MinionSelf()
+		m.SelfHost = nil
+		view.Commit(m)
+		return nil
+	})
+	ctx.run()
+
+	if _, ok := ctx.fd.running()[images.Daemon]; !ok {
+		t.Errorf("fd.running = %s; want the daemon",
+			spew.Sdump(ctx.fd.running()))
+	}
+
+	// Disabling self-hosting stops the daemon, and removes its state.
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.MinionSelf()
+		m.DisableSelfHost = true
+		view.Commit(m)
+		return nil
+	})
+	ctx.run()
+
+	if _, ok := ctx.fd.running()[images.Daemon]; ok {
+		t.Errorf("fd.running = %s; want no daemon", spew.Sdump(ctx.fd.running()))
+	}
+	if exists, _ := util.FileExists(selfhost.BlueprintPath); exists {
+		t.Error("self-hosted blueprint wasn't removed")
+	}
+	if ctx.conn.MinionSelf().SelfHosted {
+		t.Error("minion reports running the stopped self-hosted daemon")
+	}
+}
+
+func TestMasterARM64(t *testing.T) {
+	ctx := initTest(db.Master)
+	imageMap = archImages("arm64")
+	defer func() { imageMap = archImages(runtime.GOARCH) }()
+
+	bundle, err := selfhost.Bundle(nil)
+	if err != nil {
+		t.Fatalf("failed to bundle: %s", err)
+	}
+
+	ip := "1.2.3.4"
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.MinionSelf()
+		e := view.SelectFromEtcd(nil)[0]
+		m.Role = db.Master
+		m.PrivateIP = ip
+		m.Blueprint = `{"Namespace": "ns"}`
+		m.SelfHost = bundle
+		e.EtcdIPs = []string{ip}
+		e.Leader = true
+		view.Commit(m)
+		view.Commit(e)
+		return nil
+	})
+	ctx.run()
+
+	exp := map[string]string{
+		images.Daemon:    "quilt/quilt:" + version.Version + "-arm64",
+		images.Etcd:      "quay.io/coreos/etcd:v3.0.2-arm64",
+		images.Ovsdb:     "quilt/ovs:latest-arm64",
+		images.Ovnnorthd: "quilt/ovs:latest-arm64",
+		images.Registry:  "registry:2",
+	}
+	if !reflect.DeepEqual(ctx.fd.images(), exp) {
+		t.Errorf("fd.images = %s\n\nwant %s", spew.Sdump(ctx.fd.images()),
+			spew.Sdump(exp))
+	}
+}
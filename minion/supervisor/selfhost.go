@@ -0,0 +1,98 @@
+package supervisor
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/kelda/kelda/api"
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/selfhost"
+	"github.com/kelda/kelda/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The bundle and blueprint most recently written to disk, so that they're only
+// rewritten when they change.
+var selfHostBundle []byte
+var selfHostBlueprint string
+
+// syncSelfHost writes the self-hosted daemon's state, and the most recent
+// blueprint, to disk, where they outlive restarts of the minion.  Configs without
+// the state leave what's already on disk, as the daemon that sent them may not
+// know that the namespace is self-hosted, or may be the one that the masters
+// run.  The state is only removed when the daemon explicitly disables
+// self-hosting.  It returns whether the daemon should run.
+func syncSelfHost(minion db.Minion) bool {
+	if minion.DisableSelfHost {
+		if selfHostBundle != nil {
+			c.Inc("Remove Self-Host State")
+		}
+		if err := util.RemoveAll(selfhost.HomeDir); err != nil {
+			log.WithError(err).Warn("Failed to remove self-hosted daemon state")
+		}
+		selfHostBundle = nil
+		selfHostBlueprint = ""
+		return false
+	}
+
+	if len(minion.SelfHost) == 0 {
+		return selfHostBlueprintExists()
+	}
+
+	if !bytes.Equal(minion.SelfHost, selfHostBundle) {
+		c.Inc("Write Self-Host State")
+		if err := selfhost.Extract(minion.SelfHost, selfhost.QuiltDir); err != nil {
+			log.WithError(err).Error("Failed to write self-hosted daemon state")
+			return false
+		}
+		selfHostBundle = minion.SelfHost
+	}
+
+	if minion.Blueprint != "" && minion.Blueprint != selfHostBlueprint {
+		err := util.WriteFile(selfhost.BlueprintPath, []byte(minion.Blueprint),
+			0600)
+		if err != nil {
+			log.WithError(err).Error("Failed to write self-hosted blueprint")
+			return false
+		}
+		selfHostBlueprint = minion.Blueprint
+	}
+
+	return selfHostBlueprintExists()
+}
+
+func selfHostBlueprintExists() bool {
+	exists, err := util.FileExists(selfhost.BlueprintPath)
+	if err != nil {
+		log.WithError(err).Warn("Failed to check for self-hosted blueprint")
+	}
+	return exists
+}
+
+// setSelfHosted records whether this master is running the self-hosted daemon, so
+// that the minion can report it to the daemons that connect to it.
+func setSelfHosted(running bool) {
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		if self.SelfHosted != running {
+			self.SelfHosted = running
+			view.Commit(self)
+		}
+		return nil
+	})
+}
+
+// daemonArgs returns the command that the self-hosted daemon runs with.  It
+// listens on the self-hosted port rather than the minion's, and pulls images from
+// the same mirror as the minion.
+func daemonArgs() []string {
+	args := []string{"quilt", "-l", util.LogLevel().String(), "daemon",
+		"-H", fmt.Sprintf("tcp://0.0.0.0:%d", api.DefaultSelfHostedPort),
+		"-self-hosted"}
+	if docker.Mirror != "" {
+		args = append(args, "-mirror", docker.Mirror)
+	}
+	return args
+}
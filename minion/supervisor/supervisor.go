@@ -3,29 +3,61 @@ package supervisor
 import (
 	"fmt"
 	"os/exec"
+	"runtime"
 	"strings"
 
+	"github.com/kelda/kelda/cloud/acl"
 	"github.com/kelda/kelda/counter"
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/docker"
 	"github.com/kelda/kelda/minion/supervisor/images"
+	"github.com/kelda/kelda/selfhost"
+	"github.com/kelda/kelda/util"
+	"github.com/kelda/kelda/version"
 
 	log "github.com/sirupsen/logrus"
 )
 
 const ovsImage = "quilt/ovs"
 
+// The image that the self-hosted daemon runs in, which is the same as the
+// minion's.
+const quiltImage = "quilt/quilt"
+
 // The tunneling protocol to use between machines.
 // "stt" and "geneve" are supported.
 const tunnelingProtocol = "stt"
 
-var imageMap = map[string]string{
-	images.Etcd:          "quay.io/coreos/etcd:v3.0.2",
-	images.Ovncontroller: ovsImage,
-	images.Ovnnorthd:     ovsImage,
-	images.Ovsdb:         ovsImage,
-	images.Ovsvswitchd:   ovsImage,
-	images.Registry:      "registry:2",
+var imageMap = archImages(runtime.GOARCH)
+
+// archImages returns the image of each of the supervisor's containers, built for
+// the CPU architecture `arch`.  The registry image is a multi-arch manifest, so
+// Docker pulls the build for the machine's architecture on its own.
+func archImages(arch string) map[string]string {
+	ovs := util.ArchImage(ovsImage, arch)
+	registry := "registry:2"
+	return map[string]string{
+		images.Daemon: util.ArchImage(
+			quiltImage+":"+version.Version, arch),
+		images.Etcd:          util.ArchImage("quay.io/coreos/etcd:v3.0.2", arch),
+		images.Ovncontroller: ovs,
+		images.Ovnnorthd:     ovs,
+		images.Ovsdb:         ovs,
+		images.Ovsvswitchd:   ovs,
+		images.Registry:      registry,
+		images.RegistryCache: registry,
+	}
+}
+
+// The port that the registry cache listens on.  It differs from the port of the
+// master's registry, so that the two are never confused.
+const registryCachePort = 5001
+
+// The environment of the registry cache, which configures it as a pull-through
+// cache of Docker Hub.
+var registryCacheEnv = map[string]string{
+	"REGISTRY_HTTP_ADDR":       fmt.Sprintf(":%d", registryCachePort),
+	"REGISTRY_PROXY_REMOTEURL": "https://registry-1.docker.io",
 }
 
 const etcdHeartbeatInterval = "500"
@@ -86,6 +118,14 @@ func run(name string, args ...string) {
 		ro.Privileged = true
 	}
 
+	if name == images.RegistryCache {
+		ro.Env = registryCacheEnv
+	}
+
+	if name == images.Daemon {
+		ro.Env = map[string]string{"HOME": selfhost.HomeDir}
+	}
+
 	log.Infof("Start Container: %s", name)
 	_, err = dk.Run(ro)
 	if err != nil {
@@ -106,7 +146,8 @@ func initialClusterString(etcdIPs []string) string {
 	var initialCluster []string
 	for _, ip := range etcdIPs {
 		initialCluster = append(initialCluster,
-			fmt.Sprintf("%s=http://%s:2380", nodeName(ip), ip))
+			fmt.Sprintf("%s=http://%s:%d", nodeName(ip), ip,
+				acl.EtcdPeerPort))
 	}
 	return strings.Join(initialCluster, ",")
 }
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/spf13/afero"
+
 	"github.com/kelda/kelda/db"
 	"github.com/kelda/kelda/minion/docker"
+	"github.com/kelda/kelda/util"
 )
 
 type testCtx struct {
@@ -23,6 +26,9 @@ func initTest(r db.Role) *testCtx {
 		conn.Trigger(db.MinionTable, db.EtcdTable)}
 	role = r
 	dk = ctx.fd.Client
+	util.AppFs = afero.NewMemMapFs()
+	selfHostBundle = nil
+	selfHostBlueprint = ""
 
 	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
 		m := view.InsertMinion()
@@ -75,6 +81,16 @@ func (f fakeDocker) running() map[string][]string {
 	return res
 }
 
+func (f fakeDocker) images() map[string]string {
+	containers, _ := f.List(nil)
+
+	res := map[string]string{}
+	for _, c := range containers {
+		res[c.Name] = c.Image
+	}
+	return res
+}
+
 func etcdArgsMaster(ip string, etcdIPs []string) []string {
 	return []string{
 		fmt.Sprintf("--name=master-%s", ip),
@@ -0,0 +1,105 @@
+package supervisor
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/ipdef"
+	"github.com/kelda/kelda/minion/supervisor/images"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const watchdogInterval = 30 * time.Second
+
+// After `maxRepairAttempts` consecutive failures to repair OpenVSwitch, the minion
+// declares its network degraded so that its containers are scheduled elsewhere.
+const maxRepairAttempts = 3
+
+var errVswitchd = errors.New("ovs-vswitchd is unresponsive")
+var errNoBridge = errors.New("missing bridge " + ipdef.QuiltBridge)
+
+// The number of consecutive failed repairs.
+var failedRepairs int
+
+func runWatchdog() {
+	for range time.Tick(watchdogInterval) {
+		watchdogOnce()
+	}
+}
+
+func watchdogOnce() {
+	err := checkOVS()
+	if err == nil {
+		failedRepairs = 0
+		setNetworkDegraded(false)
+		return
+	}
+
+	c.Inc("OVS Unhealthy")
+	log.WithError(err).Warn("OpenVSwitch is unhealthy. Attempting repair.")
+	repairOVS(err)
+
+	if err := checkOVS(); err == nil {
+		c.Inc("OVS Repaired")
+		log.Info("Repaired OpenVSwitch")
+		failedRepairs = 0
+		setNetworkDegraded(false)
+		return
+	}
+
+	failedRepairs++
+	if failedRepairs >= maxRepairAttempts {
+		log.WithError(err).Error("Failed to repair OpenVSwitch. " +
+			"Marking the network as degraded.")
+		setNetworkDegraded(true)
+	}
+}
+
+// checkOVS returns an error if ovs-vswitchd is wedged, or the Quilt bridge is
+// missing.
+func checkOVS() error {
+	if execRun("ovs-appctl", "--timeout=5", "-t", "ovs-vswitchd",
+		"version") != nil {
+		return errVswitchd
+	}
+
+	if execRun("ovs-vsctl", "--timeout=5", "br-exists", ipdef.QuiltBridge) != nil {
+		return errNoBridge
+	}
+	return nil
+}
+
+func repairOVS(err error) {
+	if err == errVswitchd {
+		c.Inc("Restart ovs-vswitchd")
+		Remove(images.Ovsvswitchd)
+		run(images.Ovsvswitchd, "ovs-vswitchd")
+	}
+
+	if err := execRun("ovs-vsctl", "--may-exist", "add-br", ipdef.QuiltBridge,
+		"--", "set", "bridge", ipdef.QuiltBridge, "fail_mode=secure",
+		"other_config:hwaddr=\""+ipdef.GatewayMac+"\""); err != nil {
+		log.WithError(err).Warnf("Failed to exec in %s.", images.Ovsvswitchd)
+		return
+	}
+
+	// Recreating the bridge's internal interface loses its address.
+	ip := net.IPNet{IP: ipdef.GatewayIP, Mask: ipdef.QuiltSubnet.Mask}
+	if err := cfgGateway(ipdef.QuiltBridge, ip); err != nil {
+		log.WithError(err).Warnf("Failed to configure %s.", ipdef.QuiltBridge)
+	}
+}
+
+func setNetworkDegraded(degraded bool) {
+	conn.Txn(db.MinionTable).Run(func(view db.Database) error {
+		self := view.MinionSelf()
+		if self.NetworkDegraded != degraded {
+			self.NetworkDegraded = degraded
+			view.Commit(self)
+		}
+		return nil
+	})
+}
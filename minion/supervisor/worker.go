@@ -1,11 +1,13 @@
 package supervisor
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 	"time"
 
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
 	"github.com/kelda/kelda/minion/ipdef"
 	"github.com/kelda/kelda/minion/nl"
 	"github.com/kelda/kelda/minion/supervisor/images"
@@ -17,6 +19,7 @@ import (
 func runWorker() {
 	setupWorker()
 	go runWorkerSystem()
+	go runWatchdog()
 }
 
 func setupWorker() {
@@ -79,6 +82,7 @@ func runWorkerOnce() {
 
 	run(images.Ovsdb, "ovsdb-server")
 	run(images.Ovsvswitchd, "ovs-vswitchd")
+	updateRegistryCache(minion)
 
 	if leaderIP == "" || IP == "" {
 		return
@@ -99,6 +103,53 @@ func runWorkerOnce() {
 	run(images.Ovncontroller, "ovn-controller")
 }
 
+// updateRegistryCache runs the registry cache if this worker hosts the cache for
+// its region, and points the Docker client at the region's cache.
+func updateRegistryCache(self db.Minion) {
+	// Clusters with a mirror already pull their images from within the
+	// cluster's network.
+	if docker.Mirror != "" {
+		return
+	}
+
+	host := registryCacheHost(self, conn.SelectFromMinion(nil))
+	if host != "" && host == self.PrivateIP {
+		run(images.RegistryCache)
+	} else {
+		Remove(images.RegistryCache)
+	}
+
+	var addr string
+	if host != "" {
+		addr = fmt.Sprintf("%s:%d", host, registryCachePort)
+	}
+	docker.SetRegistryCache(addr)
+}
+
+// registryCacheHost returns the private IP of the worker that hosts the registry
+// cache for the region of `self`.  It's the worker in the region with the lowest
+// private IP, so that every worker agrees on it without coordinating.  Draining
+// workers are skipped, as they're about to go away.
+func registryCacheHost(self db.Minion, minions []db.Minion) string {
+	var host net.IP
+	for _, m := range minions {
+		ip := net.ParseIP(m.PrivateIP)
+		if m.Role != db.Worker || m.Drain || m.Interrupted || ip == nil ||
+			m.Provider != self.Provider || m.Region != self.Region {
+			continue
+		}
+
+		if host == nil || bytes.Compare(ip.To16(), host.To16()) < 0 {
+			host = ip
+		}
+	}
+
+	if host == nil {
+		return ""
+	}
+	return host.String()
+}
+
 func setupBridge() error {
 	gwMac := ipdef.IPToMac(ipdef.GatewayIP)
 	return execRun("ovs-vsctl", "add-br", "quilt-int",
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kelda/kelda/db"
+	"github.com/kelda/kelda/minion/docker"
 	"github.com/kelda/kelda/minion/ipdef"
 	"github.com/kelda/kelda/minion/nl"
 	"github.com/kelda/kelda/minion/nl/nlmock"
@@ -34,10 +36,13 @@ func TestWorker(t *testing.T) {
 	})
 	ctx.run()
 
+	// The worker is the only one in its region, so it hosts the registry
+	// cache.
 	exp := map[string][]string{
-		images.Etcd:        etcdArgsWorker(etcdIPs),
-		images.Ovsdb:       {"ovsdb-server"},
-		images.Ovsvswitchd: {"ovs-vswitchd"},
+		images.Etcd:          etcdArgsWorker(etcdIPs),
+		images.Ovsdb:         {"ovsdb-server"},
+		images.Ovsvswitchd:   {"ovs-vswitchd"},
+		images.RegistryCache: nil,
 	}
 	if !reflect.DeepEqual(ctx.fd.running(), exp) {
 		t.Errorf("fd.running = %s\n\nwant %s", spew.Sdump(ctx.fd.running()),
@@ -66,6 +71,7 @@ func TestWorker(t *testing.T) {
 		images.Ovsdb:         {"ovsdb-server"},
 		images.Ovncontroller: {"ovn-controller"},
 		images.Ovsvswitchd:   {"ovs-vswitchd"},
+		images.RegistryCache: nil,
 	}
 	if !reflect.DeepEqual(ctx.fd.running(), exp) {
 		t.Errorf("fd.running = %s\n\nwant %s", spew.Sdump(ctx.fd.running()),
@@ -79,6 +85,108 @@ func TestWorker(t *testing.T) {
 	}
 }
 
+func TestWorkerARM64(t *testing.T) {
+	ctx := initTest(db.Worker)
+	imageMap = archImages("arm64")
+	defer func() { imageMap = archImages(runtime.GOARCH) }()
+
+	ip := "1.2.3.4"
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.MinionSelf()
+		e := view.SelectFromEtcd(nil)[0]
+		m.Role = db.Worker
+		m.PrivateIP = ip
+		e.EtcdIPs = []string{ip}
+		e.LeaderIP = "5.6.7.8"
+		view.Commit(m)
+		view.Commit(e)
+		return nil
+	})
+	ctx.run()
+
+	exp := map[string]string{
+		images.Etcd:          "quay.io/coreos/etcd:v3.0.2-arm64",
+		images.Ovsdb:         "quilt/ovs:latest-arm64",
+		images.Ovncontroller: "quilt/ovs:latest-arm64",
+		images.Ovsvswitchd:   "quilt/ovs:latest-arm64",
+		images.RegistryCache: "registry:2",
+	}
+	if !reflect.DeepEqual(ctx.fd.images(), exp) {
+		t.Errorf("fd.images = %s\n\nwant %s", spew.Sdump(ctx.fd.images()),
+			spew.Sdump(exp))
+	}
+}
+
+func TestRegistryCache(t *testing.T) {
+	ctx := initTest(db.Worker)
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		m := view.MinionSelf()
+		m.Role = db.Worker
+		m.PrivateIP = "10.0.0.9"
+		m.Region = "us-west-1"
+		view.Commit(m)
+
+		other := view.InsertMinion()
+		other.Role = db.Worker
+		other.PrivateIP = "10.0.0.10"
+		other.Region = "us-west-1"
+		view.Commit(other)
+		return nil
+	})
+	ctx.run()
+
+	// The worker with the lowest IP in the region hosts the cache.
+	containers, err := ctx.fd.List(nil)
+	assert.NoError(t, err)
+
+	var env map[string]string
+	for _, dkc := range containers {
+		if dkc.Name == images.RegistryCache {
+			env = dkc.Env
+		}
+	}
+	assert.Equal(t, "https://registry-1.docker.io",
+		env["REGISTRY_PROXY_REMOTEURL"])
+
+	ctx.conn.Txn(db.AllTables...).Run(func(view db.Database) error {
+		other := view.InsertMinion()
+		other.Role = db.Worker
+		other.PrivateIP = "10.0.0.1"
+		other.Region = "us-west-1"
+		view.Commit(other)
+		return nil
+	})
+	ctx.run()
+	assert.NotContains(t, ctx.fd.running(), images.RegistryCache)
+}
+
+func TestRegistryCacheHost(t *testing.T) {
+	self := db.Minion{Role: db.Worker, PrivateIP: "10.0.0.9",
+		Provider: "Amazon", Region: "us-west-1"}
+	assert.Equal(t, "10.0.0.9", registryCacheHost(self, []db.Minion{self}))
+
+	minions := []db.Minion{
+		self,
+		{Role: db.Worker, PrivateIP: "10.0.0.10", Provider: "Amazon",
+			Region: "us-west-1"},
+		{Role: db.Master, PrivateIP: "10.0.0.2", Provider: "Amazon",
+			Region: "us-west-1"},
+		{Role: db.Worker, PrivateIP: "10.0.0.3", Provider: "Amazon",
+			Region: "us-east-1"},
+		{Role: db.Worker, PrivateIP: "10.0.0.4", Provider: "Google",
+			Region: "us-west-1"},
+		{Role: db.Worker, PrivateIP: "10.0.0.5", Provider: "Amazon",
+			Region: "us-west-1", Drain: true},
+	}
+	assert.Equal(t, "10.0.0.9", registryCacheHost(self, minions))
+
+	minions = append(minions, db.Minion{Role: db.Worker, PrivateIP: "10.0.0.8",
+		Provider: "Amazon", Region: "us-west-1"})
+	assert.Equal(t, "10.0.0.8", registryCacheHost(self, minions))
+
+	assert.Empty(t, registryCacheHost(db.Minion{}, nil))
+}
+
 func TestSetupWorker(t *testing.T) {
 	ctx := initTest(db.Worker)
 
@@ -169,3 +277,67 @@ func etcdArgsWorker(etcdIPs []string) []string {
 		"--proxy=on",
 	}
 }
+
+func TestWatchdog(t *testing.T) {
+	ctx := initTest(db.Worker)
+	degraded := func() bool { return ctx.conn.MinionSelf().NetworkDegraded }
+
+	// Healthy.
+	watchdogOnce()
+	assert.Equal(t, [][]string{appctlArgs(), brExistsArgs()}, ctx.execs)
+	assert.False(t, degraded())
+
+	// The bridge is missing, and gets repaired.
+	ctx.execs = nil
+	var brExistsCalls int
+	execRun = func(name string, args ...string) error {
+		ctx.execs = append(ctx.execs, append([]string{name}, args...))
+		if name == "ovs-vsctl" && args[1] == "br-exists" {
+			brExistsCalls++
+			if brExistsCalls == 1 {
+				return errors.New("missing")
+			}
+		}
+		return nil
+	}
+	watchdogOnce()
+	assert.Equal(t, [][]string{appctlArgs(), brExistsArgs(), repairArgs(),
+		{"cfgGateway", "10.0.0.1/8"}, appctlArgs(), brExistsArgs()}, ctx.execs)
+	assert.False(t, degraded())
+
+	// ovs-vswitchd is wedged, and can't be repaired.
+	execRun = func(name string, args ...string) error {
+		if name == "ovs-appctl" {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+	ctx.fd.Run(docker.RunOptions{Name: images.Ovsvswitchd})
+	for i := 0; i < maxRepairAttempts-1; i++ {
+		watchdogOnce()
+		assert.False(t, degraded())
+	}
+	watchdogOnce()
+	assert.True(t, degraded())
+	assert.Contains(t, ctx.fd.running(), images.Ovsvswitchd)
+
+	// Once OVS recovers, the network is no longer degraded.
+	execRun = func(name string, args ...string) error { return nil }
+	watchdogOnce()
+	assert.False(t, degraded())
+	assert.Zero(t, failedRepairs)
+}
+
+func appctlArgs() []string {
+	return []string{"ovs-appctl", "--timeout=5", "-t", "ovs-vswitchd", "version"}
+}
+
+func brExistsArgs() []string {
+	return []string{"ovs-vsctl", "--timeout=5", "br-exists", "quilt-int"}
+}
+
+func repairArgs() []string {
+	return []string{"ovs-vsctl", "--may-exist", "add-br", "quilt-int",
+		"--", "set", "bridge", "quilt-int", "fail_mode=secure",
+		"other_config:hwaddr=\"02:00:0a:00:00:01\""}
+}
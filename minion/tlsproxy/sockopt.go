@@ -0,0 +1,68 @@
+package tlsproxy
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// Socket options from linux/netfilter_ipv4.h and asm-generic/socket.h.
+const (
+	soOriginalDst = 80
+	soMark        = 36
+)
+
+// origDstImpl returns the address that `conn` was sent to before iptables
+// redirected it to the proxy.
+func origDstImpl(conn net.Conn) (string, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return "", errors.New("not a TCP connection")
+	}
+
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+
+	// The original destination is a sockaddr_in, which happens to be the same
+	// size as an IPv6Mreq.
+	var addr *syscall.IPv6Mreq
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		addr, sockErr = syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP,
+			soOriginalDst)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sa := addr.Multiaddr
+	port := int(sa[2])<<8 | int(sa[3])
+	ip := net.IPv4(sa[4], sa[5], sa[6], sa[7])
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
+
+// dialImpl opens a TCP connection to `addr` that's marked so that the proxy's
+// redirects ignore it.
+func dialImpl(addr string) (net.Conn, error) {
+	dialer := net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(_, _ string, raw syscall.RawConn) error {
+			var sockErr error
+			err := raw.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(int(fd),
+					syscall.SOL_SOCKET, soMark, proxyMark)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	return dialer.Dial("tcp", addr)
+}
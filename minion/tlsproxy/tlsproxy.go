@@ -0,0 +1,332 @@
+// Package tlsproxy implements the proxies that wrap mTLS connections in mutual
+// TLS.  For each container at either end of an mTLS connection, the minion runs a
+// proxy that shares the container's network namespace.  The proxy redirects the
+// container's connections to it with iptables, so the containers themselves
+// needn't be configured.
+//
+// Outbound connections to the peers of mTLS connections are redirected to the
+// outbound port, where the proxy opens a TLS connection to the connection's
+// original destination.  There, the peer's proxy accepts it on the inbound port,
+// and opens a plain connection to the container.  Each proxy presents a
+// certificate that the daemon issued to its container, and only accepts peers
+// with a certificate signed by the namespace's certificate authority.  The
+// proxies never hold the minion's own key.
+package tlsproxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+
+	quiltTLS "github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/counter"
+)
+
+// TLSDir is the directory in which the minion installs the credentials in the
+// proxy's container.
+const TLSDir = "/quilt/tls"
+
+// The ports on which the proxy accepts redirected connections.
+const (
+	inboundPort  = 15006
+	outboundPort = 15001
+)
+
+// The connections that the proxy opens are marked, so that they aren't
+// redirected back to it.
+const proxyMark = 0x7154
+
+// The chains in the nat table that hold the proxy's redirects.
+const (
+	inboundChain  = "QUILT-TLS-IN"
+	outboundChain = "QUILT-TLS-OUT"
+)
+
+const dialTimeout = 10 * time.Second
+
+var c = counter.New("TLS Proxy")
+
+// A Rule matches the connections between the container and `IP` on the ports
+// in the range [MinPort, MaxPort].
+type Rule struct {
+	IP      string
+	MinPort int
+	MaxPort int
+}
+
+// ParseRule parses rules of the form IP:PORT or IP:MINPORT-MAXPORT.
+func ParseRule(str string) (Rule, error) {
+	host, ports, err := net.SplitHostPort(str)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	if net.ParseIP(host) == nil {
+		return Rule{}, fmt.Errorf("invalid IP: %q", host)
+	}
+
+	portStrs := strings.SplitN(ports, "-", 2)
+	if len(portStrs) == 1 {
+		portStrs = append(portStrs, portStrs[0])
+	}
+
+	var portRange []int
+	for _, portStr := range portStrs {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return Rule{}, fmt.Errorf("invalid port: %q", portStr)
+		}
+		portRange = append(portRange, port)
+	}
+
+	if portRange[0] > portRange[1] {
+		return Rule{}, fmt.Errorf("invalid port range: %q", ports)
+	}
+	return Rule{IP: host, MinPort: portRange[0], MaxPort: portRange[1]}, nil
+}
+
+func (r Rule) String() string {
+	ports := strconv.Itoa(r.MinPort)
+	if r.MaxPort != r.MinPort {
+		ports += "-" + strconv.Itoa(r.MaxPort)
+	}
+	return net.JoinHostPort(r.IP, ports)
+}
+
+// Run redirects the connections matched by the rules to the proxy, and proxies
+// them until it fails.  Connections from the `inbound` peers are unwrapped, and
+// connections to the `outbound` peers are wrapped.
+func Run(creds quiltTLS.TLS, inbound, outbound []Rule) error {
+	ipt, err := newIPTables()
+	if err != nil {
+		return fmt.Errorf("iptables: %s", err)
+	}
+
+	inListener, err := net.Listen("tcp", fmt.Sprintf(":%d", inboundPort))
+	if err != nil {
+		return err
+	}
+	defer inListener.Close()
+
+	outListener, err := net.Listen("tcp", fmt.Sprintf(":%d", outboundPort))
+	if err != nil {
+		return err
+	}
+	defer outListener.Close()
+
+	// The redirects are only installed once the proxy is listening, so that
+	// connections aren't refused in the meantime.
+	if err := setupRedirects(ipt, inbound, outbound); err != nil {
+		return err
+	}
+
+	errChan := make(chan error, 2)
+	go func() {
+		errChan <- serve(inListener, func(conn net.Conn) {
+			unwrap(conn, creds.ServerConfig())
+		})
+	}()
+	go func() {
+		errChan <- serve(outListener, func(conn net.Conn) {
+			wrap(conn, creds.ClientConfig())
+		})
+	}()
+	return <-errChan
+}
+
+// Cleanup removes the proxy's redirects, so that the container's connections
+// are no longer sent to it.
+func Cleanup() error {
+	ipt, err := newIPTables()
+	if err != nil {
+		return fmt.Errorf("iptables: %s", err)
+	}
+
+	var errs []string
+	for _, chain := range []struct{ parent, name string }{
+		{"PREROUTING", inboundChain},
+		{"OUTPUT", outboundChain},
+	} {
+		// The jump must be removed before the chain may be deleted.
+		ipt.Delete("nat", chain.parent, "-j", chain.name)
+		if err := ipt.ClearChain("nat", chain.name); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := ipt.DeleteChain("nat", chain.name); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// setupRedirects replaces the rules in the proxy's chains, as a previous proxy
+// in the same network namespace may have left its rules behind.
+func setupRedirects(ipt ipTables, inbound, outbound []Rule) error {
+	inRules := []string{}
+	for _, r := range inbound {
+		inRules = append(inRules, fmt.Sprintf("-s %s/32 -p tcp -m tcp "+
+			"--dport %d:%d -j REDIRECT --to-ports %d",
+			r.IP, r.MinPort, r.MaxPort, inboundPort))
+	}
+
+	outRules := []string{fmt.Sprintf("-m mark --mark %#x -j RETURN", proxyMark)}
+	for _, r := range outbound {
+		outRules = append(outRules, fmt.Sprintf("-d %s/32 -p tcp -m tcp "+
+			"--dport %d:%d -j REDIRECT --to-ports %d",
+			r.IP, r.MinPort, r.MaxPort, outboundPort))
+	}
+
+	for _, chain := range []struct {
+		parent, name string
+		rules        []string
+	}{
+		{"PREROUTING", inboundChain, inRules},
+		{"OUTPUT", outboundChain, outRules},
+	} {
+		if err := ipt.ClearChain("nat", chain.name); err != nil {
+			return fmt.Errorf("clear %s: %s", chain.name, err)
+		}
+
+		for _, rule := range chain.rules {
+			c.Inc("Append Rule")
+			err := ipt.Append("nat", chain.name, strings.Split(rule, " ")...)
+			if err != nil {
+				return fmt.Errorf("append to %s: %s", chain.name, err)
+			}
+		}
+
+		err := ipt.AppendUnique("nat", chain.parent, "-j", chain.name)
+		if err != nil {
+			return fmt.Errorf("jump to %s: %s", chain.name, err)
+		}
+	}
+	return nil
+}
+
+func serve(listener net.Listener, handle func(net.Conn)) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handle(conn)
+	}
+}
+
+// unwrap terminates the TLS connection from a peer's proxy, and forwards it to
+// the container.
+func unwrap(conn net.Conn, config *tls.Config) {
+	c.Inc("Inbound Connection")
+	defer conn.Close()
+
+	dst, err := origDst(conn)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get original destination")
+		return
+	}
+
+	tlsConn := tls.Server(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		c.Inc("Inbound Handshake Failed")
+		log.WithError(err).WithField("peer", conn.RemoteAddr()).Warn(
+			"Rejected inbound connection")
+		return
+	}
+
+	container, err := dial(dst)
+	if err != nil {
+		log.WithError(err).WithField("dst", dst).Warn(
+			"Failed to connect to container")
+		return
+	}
+	splice(tlsConn, container)
+}
+
+// wrap forwards the container's connection to the proxy of its destination,
+// over TLS.
+func wrap(conn net.Conn, config *tls.Config) {
+	c.Inc("Outbound Connection")
+	defer conn.Close()
+
+	dst, err := origDst(conn)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get original destination")
+		return
+	}
+
+	peer, err := dial(dst)
+	if err != nil {
+		log.WithError(err).WithField("dst", dst).Warn(
+			"Failed to connect to peer")
+		return
+	}
+
+	tlsConn := tls.Client(peer, config)
+	if err := tlsConn.Handshake(); err != nil {
+		c.Inc("Outbound Handshake Failed")
+		log.WithError(err).WithField("dst", dst).Warn(
+			"Rejected outbound connection")
+		peer.Close()
+		return
+	}
+	splice(conn, tlsConn)
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// splice copies data between `a` and `b` until both directions are closed.
+func splice(a, b net.Conn) {
+	var wg sync.WaitGroup
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+
+		// Propagate the half-close, so that the other direction may finish.
+		if cw, ok := dst.(closeWriter); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	wg.Add(2)
+	go pipe(a, b)
+	go pipe(b, a)
+	wg.Wait()
+
+	a.Close()
+	b.Close()
+}
+
+type ipTables interface {
+	Append(string, string, ...string) error
+	AppendUnique(string, string, ...string) error
+	Delete(string, string, ...string) error
+	ClearChain(string, string) error
+	DeleteChain(string, string) error
+}
+
+func newIPTablesImpl() (ipTables, error) {
+	return iptables.New()
+}
+
+// Allow mocking out for the unit tests.
+var newIPTables = newIPTablesImpl
+var origDst = origDstImpl
+var dial = dialImpl
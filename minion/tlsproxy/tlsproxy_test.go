@@ -0,0 +1,228 @@
+package tlsproxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	quiltTLS "github.com/kelda/kelda/connection/tls"
+	"github.com/kelda/kelda/connection/tls/rsa"
+)
+
+func TestParseRule(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		str string
+		exp Rule
+	}{
+		{"10.0.0.1:80", Rule{"10.0.0.1", 80, 80}},
+		{"10.0.0.1:80-90", Rule{"10.0.0.1", 80, 90}},
+	} {
+		rule, err := ParseRule(test.str)
+		assert.NoError(t, err)
+		assert.Equal(t, test.exp, rule)
+		assert.Equal(t, test.str, rule.String())
+	}
+
+	for _, str := range []string{"10.0.0.1", "foo:80", "10.0.0.1:0",
+		"10.0.0.1:80-", "10.0.0.1:90-80", "10.0.0.1:65536"} {
+		_, err := ParseRule(str)
+		assert.Error(t, err, str)
+	}
+}
+
+type mockIPTables struct {
+	rules  map[string][]string
+	errors bool
+}
+
+func (ipt *mockIPTables) Append(table, chain string, rule ...string) error {
+	if ipt.errors {
+		return errors.New("append")
+	}
+	ipt.rules[chain] = append(ipt.rules[chain], joinRule(rule))
+	return nil
+}
+
+func (ipt *mockIPTables) AppendUnique(table, chain string, rule ...string) error {
+	for _, r := range ipt.rules[chain] {
+		if r == joinRule(rule) {
+			return nil
+		}
+	}
+	return ipt.Append(table, chain, rule...)
+}
+
+func (ipt *mockIPTables) Delete(table, chain string, rule ...string) error {
+	var rules []string
+	for _, r := range ipt.rules[chain] {
+		if r != joinRule(rule) {
+			rules = append(rules, r)
+		}
+	}
+	ipt.rules[chain] = rules
+	return nil
+}
+
+func (ipt *mockIPTables) ClearChain(table, chain string) error {
+	ipt.rules[chain] = []string{}
+	return nil
+}
+
+func (ipt *mockIPTables) DeleteChain(table, chain string) error {
+	if ipt.rules[chain] == nil {
+		return errors.New("no such chain")
+	}
+	delete(ipt.rules, chain)
+	return nil
+}
+
+func joinRule(rule []string) string {
+	return strings.Join(rule, " ")
+}
+
+func TestRedirects(t *testing.T) {
+	ipt := &mockIPTables{rules: map[string][]string{
+		"PREROUTING": {"-j DOCKER"},
+		// Rules left behind by a previous proxy.
+		inboundChain: {"-s 10.0.0.9/32 -p tcp -m tcp --dport 80:80 " +
+			"-j REDIRECT --to-ports 15006"},
+	}}
+	newIPTables = func() (ipTables, error) { return ipt, nil }
+
+	inbound := []Rule{{"10.0.0.2", 80, 80}}
+	outbound := []Rule{{"10.0.0.3", 443, 443}, {"10.0.0.4", 8000, 8080}}
+
+	// Setting up the redirects twice is the same as setting them up once.
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, setupRedirects(ipt, inbound, outbound))
+		assert.Equal(t, map[string][]string{
+			"PREROUTING": {"-j DOCKER", "-j QUILT-TLS-IN"},
+			"OUTPUT":     {"-j QUILT-TLS-OUT"},
+			inboundChain: {"-s 10.0.0.2/32 -p tcp -m tcp --dport 80:80 " +
+				"-j REDIRECT --to-ports 15006"},
+			outboundChain: {
+				"-m mark --mark 0x7154 -j RETURN",
+				"-d 10.0.0.3/32 -p tcp -m tcp --dport 443:443 " +
+					"-j REDIRECT --to-ports 15001",
+				"-d 10.0.0.4/32 -p tcp -m tcp --dport 8000:8080 " +
+					"-j REDIRECT --to-ports 15001",
+			},
+		}, ipt.rules)
+	}
+
+	assert.NoError(t, Cleanup())
+	assert.Equal(t, map[string][]string{
+		"PREROUTING": {"-j DOCKER"},
+		"OUTPUT":     nil,
+	}, ipt.rules)
+
+	ipt.errors = true
+	assert.EqualError(t, setupRedirects(ipt, inbound, outbound),
+		"append to QUILT-TLS-IN: append")
+
+	newIPTables = func() (ipTables, error) { return nil, errors.New("missing") }
+	assert.EqualError(t, Cleanup(), "iptables: missing")
+}
+
+func TestProxy(t *testing.T) {
+	ca, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+	creds := newCreds(t, ca)
+
+	containerListener := listen(t)
+	inboundListener := listen(t)
+	outboundListener := listen(t)
+	defer containerListener.Close()
+	defer inboundListener.Close()
+	defer outboundListener.Close()
+
+	container := containerListener.Addr().String()
+	inbound := inboundListener.Addr().String()
+	outbound := outboundListener.Addr().String()
+
+	// Connections to the outbound proxy were destined for the peer, whose
+	// inbound proxy forwards them to the container.
+	origDst = func(conn net.Conn) (string, error) {
+		if conn.LocalAddr().String() == outbound {
+			return inbound, nil
+		}
+		return container, nil
+	}
+	dial = func(addr string) (net.Conn, error) {
+		return net.Dial("tcp", addr)
+	}
+
+	// The container echoes what it reads.
+	go serve(containerListener, func(conn net.Conn) {
+		data, _ := ioutil.ReadAll(conn)
+		conn.Write(data)
+		conn.Close()
+	})
+	go serve(inboundListener, func(conn net.Conn) {
+		unwrap(conn, creds.ServerConfig())
+	})
+	go serve(outboundListener, func(conn net.Conn) {
+		wrap(conn, creds.ClientConfig())
+	})
+
+	conn, err := net.Dial("tcp", outbound)
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("ping"))
+	assert.NoError(t, err)
+	conn.(*net.TCPConn).CloseWrite()
+
+	reply, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "ping", string(reply))
+
+	// Peers with certificates from other certificate authorities are rejected.
+	otherCA, err := rsa.NewCertificateAuthority()
+	assert.NoError(t, err)
+	otherCreds := newCreds(t, otherCA)
+
+	tlsConn, err := quiltDial(inbound, otherCreds)
+	if err == nil {
+		// The server's rejection of the client certificate may only be
+		// reported once the connection is read.
+		_, err = tlsConn.Read(make([]byte, 1))
+	}
+	assert.Error(t, err)
+}
+
+func newCreds(t *testing.T, ca rsa.KeyPair) quiltTLS.TLS {
+	signed, err := rsa.NewSigned(ca, net.IPv4(127, 0, 0, 1))
+	assert.NoError(t, err)
+
+	creds, err := quiltTLS.New(ca.CertString(), signed.CertString(),
+		signed.PrivateKeyString())
+	assert.NoError(t, err)
+	return creds
+}
+
+func quiltDial(addr string, creds quiltTLS.TLS) (net.Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client doesn't check the server, so that only the server rejects
+	// the handshake.
+	config := creds.ClientConfig()
+	config.VerifyPeerCertificate = nil
+
+	tlsConn := tls.Client(conn, config)
+	return tlsConn, tlsConn.Handshake()
+}
+
+func listen(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	return listener
+}
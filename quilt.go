@@ -8,6 +8,7 @@ import (
 	l_mod "log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/kelda/kelda/cli"
 	"github.com/kelda/kelda/util"
@@ -25,8 +26,8 @@ To see the help text for a given command:
 quilt COMMAND --help
 
 Commands:
-  counters, daemon, debug-logs, init, inspect, logs, minion, show, run, ssh,
-  stop, version`
+  counters, daemon, debug-logs, decisions, init, inspect, log-level, logs,
+  minion, ready, settings, show, run, ssh, stop, version`
 
 func main() {
 	flag.Usage = func() {
@@ -47,8 +48,9 @@ func main() {
 		fmt.Println(err)
 		usage()
 	}
-	log.SetLevel(level)
-	log.SetFormatter(util.Formatter{})
+	util.SetLogLevel(level)
+	log.SetFormatter(util.LevelFormatter{
+		Formatter: util.NewDedupFormatter(util.Formatter{}, 5*time.Minute)})
 
 	if *logOut != "" {
 		file, err := os.Create(*logOut)
@@ -0,0 +1,112 @@
+// Package recorder implements a flight recorder of the decisions made by Quilt's
+// reconciliation loops, such as which machines to boot and where to place
+// containers.  The most recent decisions are kept in memory so that surprising
+// behavior can be explained after the fact.
+package recorder
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kelda/kelda/api/pb"
+)
+
+// Capacity is the number of decisions retained.  Once full, the oldest decisions
+// are discarded.
+const Capacity = 1024
+
+// Enabled controls whether decisions are recorded.  Recording is optional, so it's
+// off unless the daemon or minion is started with --flight-recorder.
+var Enabled = false
+
+// A Recorder records the decisions of a single module.
+type Recorder struct {
+	module string
+}
+
+// A Join records the decisions made from a single set of inputs.
+type Join struct {
+	module string
+	inputs []interface{}
+	hash   string
+}
+
+var ring struct {
+	sync.Mutex
+	decisions []pb.Decision
+	next      int
+}
+
+var now = time.Now
+
+// New creates a Recorder for the module with the given name.
+func New(module string) Recorder {
+	return Recorder{module}
+}
+
+// Join starts recording the decisions made based on `inputs`.  The inputs are
+// hashed lazily, so they must not be modified until the decisions are recorded.
+func (r Recorder) Join(inputs ...interface{}) *Join {
+	return &Join{module: r.module, inputs: inputs}
+}
+
+// Decide records that `action` was taken on `target`.
+func (j *Join) Decide(action string, target interface{}) {
+	if !Enabled {
+		return
+	}
+
+	record(pb.Decision{
+		Time:      now().UnixNano(),
+		Module:    j.module,
+		InputHash: j.hashOnce(),
+		Action:    action,
+		Target:    fmt.Sprint(target),
+	})
+}
+
+// hashOnce hashes the inputs of `j`.  Hashing may be expensive, so it's only done
+// once a decision is actually made.
+func (j *Join) hashOnce() string {
+	if j.hash != "" {
+		return j.hash
+	}
+
+	// JSON follows pointers, unlike fmt, so it hashes the contents of nested
+	// structs rather than their addresses.
+	bytes, err := json.Marshal(j.inputs)
+	if err != nil {
+		bytes = []byte(fmt.Sprintf("%+v", j.inputs))
+	}
+	j.hash = fmt.Sprintf("%x", sha1.Sum(bytes))[:12]
+	return j.hash
+}
+
+func record(decision pb.Decision) {
+	ring.Lock()
+	defer ring.Unlock()
+
+	if len(ring.decisions) < Capacity {
+		ring.decisions = append(ring.decisions, decision)
+		return
+	}
+
+	ring.decisions[ring.next] = decision
+	ring.next = (ring.next + 1) % Capacity
+}
+
+// Dump returns the recorded decisions, oldest first.
+func Dump() []*pb.Decision {
+	ring.Lock()
+	defer ring.Unlock()
+
+	var result []*pb.Decision
+	for i := range ring.decisions {
+		decision := ring.decisions[(ring.next+i)%len(ring.decisions)]
+		result = append(result, &decision)
+	}
+	return result
+}
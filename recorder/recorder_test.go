@@ -0,0 +1,66 @@
+package recorder
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	Enabled = true
+	defer func() { Enabled = false }()
+	now = func() time.Time { return time.Unix(0, 5) }
+	defer func() { now = time.Now }()
+	reset()
+
+	rec := New("a")
+	rj := rec.Join([]string{"in"})
+	rj.Decide("boot", "x")
+	rj.Decide("stop", 1)
+	rec.Join([]string{"other"}).Decide("boot", "y")
+
+	res := Dump()
+	assert.Len(t, res, 3)
+	assert.Equal(t, "a", res[0].Module)
+	assert.Equal(t, int64(5), res[0].Time)
+	assert.Equal(t, "boot", res[0].Action)
+	assert.Equal(t, "x", res[0].Target)
+	assert.Equal(t, "stop", res[1].Action)
+	assert.Equal(t, "1", res[1].Target)
+
+	// Decisions from the same inputs share a hash.
+	assert.Equal(t, res[0].InputHash, res[1].InputHash)
+	assert.NotEqual(t, res[0].InputHash, res[2].InputHash)
+	assert.Equal(t, res[0].InputHash,
+		rec.Join([]string{"in"}).hashOnce())
+
+	Enabled = false
+	rec.Join().Decide("boot", "z")
+	Enabled = true
+	assert.Len(t, Dump(), 3)
+}
+
+func TestRecorderRing(t *testing.T) {
+	Enabled = true
+	defer func() { Enabled = false }()
+	reset()
+
+	rj := New("a").Join()
+	for i := 0; i < Capacity+10; i++ {
+		rj.Decide("boot", i)
+	}
+
+	res := Dump()
+	assert.Len(t, res, Capacity)
+	assert.Equal(t, "10", res[0].Target)
+	assert.Equal(t, fmt.Sprint(Capacity+9), res[Capacity-1].Target)
+}
+
+func reset() {
+	ring.Lock()
+	ring.decisions = nil
+	ring.next = 0
+	ring.Unlock()
+}
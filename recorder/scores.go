@@ -0,0 +1,155 @@
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/kelda/kelda/api/pb"
+	"github.com/kelda/kelda/join"
+)
+
+// A scoredJoin is the input of a call to join.Join.  Score functions are pure,
+// so the score matrix and pairings are recomputed from it when they're dumped,
+// rather than on every join.
+type scoredJoin struct {
+	time          int64
+	lefts, rights []interface{}
+	score         func(left, right interface{}) int
+}
+
+// The most recent scored join of each module, keyed by module and name.
+var joins struct {
+	sync.Mutex
+	latest map[[2]string]scoredJoin
+}
+
+// ScoredJoin performs join.Join, and records its inputs as the most recent join
+// called `name`, so that DumpScores can explain how it paired elements.
+func (r Recorder) ScoredJoin(name string, lSlice, rSlice interface{},
+	score func(left, right interface{}) int) (
+	pairs []join.Pair, lonelyLefts, lonelyRights []interface{}) {
+
+	pairs, lonelyLefts, lonelyRights = join.Join(lSlice, rSlice, score)
+	if !Enabled {
+		return pairs, lonelyLefts, lonelyRights
+	}
+
+	sj := scoredJoin{
+		time:   now().UnixNano(),
+		lefts:  toInterfaces(lSlice),
+		rights: toInterfaces(rSlice),
+		score:  score,
+	}
+
+	joins.Lock()
+	defer joins.Unlock()
+	if joins.latest == nil {
+		joins.latest = map[[2]string]scoredJoin{}
+	}
+	joins.latest[[2]string{r.module, name}] = sj
+	return pairs, lonelyLefts, lonelyRights
+}
+
+func toInterfaces(slice interface{}) []interface{} {
+	val := reflect.ValueOf(slice)
+	result := make([]interface{}, val.Len())
+	for i := range result {
+		result[i] = val.Index(i).Interface()
+	}
+	return result
+}
+
+// DumpScores returns an explanation of the most recent scored join of each
+// name, ordered by module and name.
+func DumpScores() []*pb.JoinScores {
+	joins.Lock()
+	defer joins.Unlock()
+
+	var result []*pb.JoinScores
+	for key, sj := range joins.latest {
+		result = append(result, &pb.JoinScores{
+			Time:        sj.time,
+			Module:      key[0],
+			Name:        key[1],
+			Explanation: sj.explain(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Module != result[j].Module {
+			return result[i].Module < result[j].Module
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// explain describes the elements of the join, their score matrix, and how they
+// were paired.  Elements are referred to by index, e.g. L0 and R2, as they're
+// usually too long to fit in the matrix.
+func (sj scoredJoin) explain() string {
+	var buf bytes.Buffer
+	for i, l := range sj.lefts {
+		fmt.Fprintf(&buf, "L%d: %v\n", i, l)
+	}
+	for i, r := range sj.rights {
+		fmt.Fprintf(&buf, "R%d: %v\n", i, r)
+	}
+
+	if len(sj.lefts) > 0 && len(sj.rights) > 0 {
+		fmt.Fprintln(&buf, "\nScores (lower is preferred, - never pairs):")
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for j := range sj.rights {
+			fmt.Fprintf(w, "\tR%d", j)
+		}
+		fmt.Fprintln(w, "\t")
+		for i, l := range sj.lefts {
+			fmt.Fprintf(w, "L%d", i)
+			for _, r := range sj.rights {
+				if score := sj.score(l, r); score < 0 {
+					fmt.Fprint(w, "\t-")
+				} else {
+					fmt.Fprintf(w, "\t%d", score)
+				}
+			}
+			fmt.Fprintln(w, "\t")
+		}
+		w.Flush()
+	}
+
+	// Joining the indices of the elements reproduces the original pairing,
+	// as join.Join only depends on the order of its inputs and their scores.
+	lIdx, rIdx := indices(len(sj.lefts)), indices(len(sj.rights))
+	pairs, lonelyLefts, lonelyRights := join.Join(lIdx, rIdx,
+		func(l, r interface{}) int {
+			return sj.score(sj.lefts[l.(int)], sj.rights[r.(int)])
+		})
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].L.(int) < pairs[j].L.(int)
+	})
+
+	fmt.Fprintln(&buf, "\nPairs:")
+	for _, pair := range pairs {
+		fmt.Fprintf(&buf, "L%d-R%d\n", pair.L, pair.R)
+	}
+	for _, l := range lonelyLefts {
+		fmt.Fprintf(&buf, "L%d unpaired\n", l)
+	}
+	for _, r := range lonelyRights {
+		fmt.Fprintf(&buf, "R%d unpaired\n", r)
+	}
+	return buf.String()
+}
+
+func indices(n int) []int {
+	result := make([]int, n)
+	for i := range result {
+		result[i] = i
+	}
+	return result
+}
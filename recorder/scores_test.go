@@ -0,0 +1,89 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/join"
+)
+
+func TestScoredJoin(t *testing.T) {
+	Enabled = true
+	defer func() { Enabled = false }()
+	now = func() time.Time { return time.Unix(0, 5) }
+	defer func() { now = time.Now }()
+	joins.latest = nil
+
+	// Pair numbers that are close, unless they differ by more than 2.
+	score := func(l, r interface{}) int {
+		diff := l.(int) - r.(int)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > 2 {
+			return -1
+		}
+		return diff
+	}
+
+	rec := New("a")
+	pairs, lonelyLefts, lonelyRights := rec.ScoredJoin("numbers",
+		[]int{1, 5, 20}, []int{6, 2, 12}, score)
+	assert.Equal(t, []join.Pair{{L: 1, R: 2}, {L: 5, R: 6}}, pairs)
+	assert.Equal(t, []interface{}{20}, lonelyLefts)
+	assert.Equal(t, []interface{}{12}, lonelyRights)
+
+	// Only the most recent join of each name is kept.
+	rec.ScoredJoin("numbers", []int{1}, []int{1}, score)
+	New("b").ScoredJoin("empty", []int{}, []int{3}, score)
+
+	res := DumpScores()
+	assert.Len(t, res, 2)
+	assert.Equal(t, "a", res[0].Module)
+	assert.Equal(t, "numbers", res[0].Name)
+	assert.Equal(t, int64(5), res[0].Time)
+	assert.Equal(t, "b", res[1].Module)
+	assert.Equal(t, "R0: 3\n\nPairs:\nR0 unpaired\n", res[1].Explanation)
+
+	Enabled = false
+	rec.ScoredJoin("disabled", []int{1}, []int{1}, score)
+	Enabled = true
+	assert.Len(t, DumpScores(), 2)
+}
+
+func TestExplain(t *testing.T) {
+	Enabled = true
+	defer func() { Enabled = false }()
+	sj := scoredJoin{
+		lefts:  []interface{}{1, 5, 20},
+		rights: []interface{}{6, 2, 12},
+		score: func(l, r interface{}) int {
+			if l.(int) > r.(int) {
+				return -1
+			}
+			return r.(int) - l.(int)
+		},
+	}
+
+	assert.Equal(t, `L0: 1
+L1: 5
+L2: 20
+R0: 6
+R1: 2
+R2: 12
+
+Scores (lower is preferred, - never pairs):
+      R0  R1  R2
+  L0   5   1  11
+  L1   1   -   7
+  L2   -   -   -
+
+Pairs:
+L0-R1
+L1-R0
+L2 unpaired
+R2 unpaired
+`, sj.explain())
+}
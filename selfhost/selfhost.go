@@ -0,0 +1,136 @@
+// Package selfhost moves the daemon into the cluster that it manages.  When a
+// namespace is self-hosted, the daemon bundles its state (its TLS credentials,
+// SSH key, and configuration file) and sends it to the masters, which store it
+// on disk.  The etcd leader then runs the daemon in a container with that state,
+// so the cluster keeps being managed without the machine it was deployed from.
+//
+// The bundled credentials include the private key of the certificate authority,
+// which the self-hosted daemon signs minion configs and machine certificates
+// with, so every master holds a key trusted by the whole deployment.
+package selfhost
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kelda/kelda/util"
+)
+
+// HomeDir is the home directory of the daemon container on the masters.  The
+// daemon finds its state in the .quilt directory within it, as it would on a
+// user's machine.
+const HomeDir = "/var/lib/quilt/daemon"
+
+// QuiltDir is where the masters extract the bundled state of the daemon.
+var QuiltDir = filepath.Join(HomeDir, ".quilt")
+
+// BlueprintPath is where the masters write the most recent blueprint that they
+// received, which the self-hosted daemon deploys when it starts.
+var BlueprintPath = filepath.Join(QuiltDir, "blueprint.json")
+
+// Bundle returns a tar archive of `paths`, which maps names within the archive to
+// the files or directories on disk that they're read from.  Paths that don't
+// exist are left out.  The archive's entries are sorted, so that bundling the
+// same files always produces the same archive.
+func Bundle(paths map[string]string) ([]byte, error) {
+	files := map[string]os.FileInfo{}
+	sources := map[string]string{}
+	for name, root := range paths {
+		err := util.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			entry := filepath.ToSlash(filepath.Join(name, rel))
+			files[entry] = info
+			sources[entry] = path
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %s", root, err)
+		}
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, name := range names {
+		contents, err := util.ReadFile(sources[name])
+		if err != nil {
+			return nil, err
+		}
+
+		hdr := &tar.Header{
+			Name: name,
+			Mode: int64(files[name].Mode().Perm()),
+			Size: int64(len(contents)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Extract writes the files in the archive `bundle` to `dir`, replacing any files
+// that are already there.
+func Extract(bundle []byte, dir string) error {
+	tr := tar.NewReader(bytes.NewReader(bundle))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			return fmt.Errorf("malformed path in bundle: %s", hdr.Name)
+		}
+
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, name)
+		if err := util.AppFs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+		if err := util.WriteFile(path, contents, mode); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package selfhost
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kelda/kelda/util"
+)
+
+func TestBundle(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	util.WriteFile("/home/.quilt/tls/certificate_authority.crt",
+		[]byte("ca"), 0644)
+	util.WriteFile("/home/.quilt/tls/quilt.key", []byte("key"), 0600)
+	util.WriteFile("/home/.quilt/ssh_key", []byte("ssh"), 0600)
+
+	paths := map[string]string{
+		"tls":         "/home/.quilt/tls",
+		"ssh_key":     "/home/.quilt/ssh_key",
+		"daemon.json": "/home/.quilt/daemon.json",
+	}
+	bundle, err := Bundle(paths)
+	assert.NoError(t, err)
+
+	// Bundles are deterministic.
+	again, err := Bundle(paths)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, again)
+
+	assert.NoError(t, Extract(bundle, QuiltDir))
+	for path, expected := range map[string]string{
+		QuiltDir + "/tls/certificate_authority.crt": "ca",
+		QuiltDir + "/tls/quilt.key":                 "key",
+		QuiltDir + "/ssh_key":                       "ssh",
+	} {
+		contents, err := util.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, contents)
+	}
+
+	info, err := util.Stat(QuiltDir + "/ssh_key")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	exists, err := util.FileExists(QuiltDir + "/daemon.json")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestExtractMalformedPath(t *testing.T) {
+	util.AppFs = afero.NewMemMapFs()
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	tw.WriteHeader(&tar.Header{Name: "../../etc/passwd", Mode: 0644, Size: 1})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	assert.EqualError(t, Extract(buf.Bytes(), QuiltDir),
+		"malformed path in bundle: ../../etc/passwd")
+}
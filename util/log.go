@@ -3,7 +3,9 @@ package util
 import (
 	"bytes"
 	"fmt"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -28,6 +30,122 @@ func (f Formatter) Format(entry *log.Entry) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// A DedupFormatter wraps a formatter so that warnings and errors that repeat the
+// message of a previous one logged from the same location are suppressed for
+// `window`.  The first repeat logged after the window notes how many times the
+// message was repeated in the meantime.  This keeps logs usable while loops
+// repeat the same failure every iteration, such as during provider outages.
+type DedupFormatter struct {
+	log.Formatter
+	window time.Duration
+
+	mutex sync.Mutex
+	seen  map[dedupKey]*dedupState
+}
+
+type dedupKey struct {
+	message, location string
+}
+
+type dedupState struct {
+	logged   time.Time
+	repeated int
+}
+
+// Once this many messages are tracked, those outside the window are forgotten.
+const maxDedupMessages = 1024
+
+// NewDedupFormatter creates a DedupFormatter that suppresses repeats of messages
+// formatted by `formatter` for `window`.
+func NewDedupFormatter(formatter log.Formatter,
+	window time.Duration) *DedupFormatter {
+	return &DedupFormatter{
+		Formatter: formatter,
+		window:    window,
+		seen:      map[dedupKey]*dedupState{},
+	}
+}
+
+// Format formats `entry` with the wrapped formatter, unless it's a repeat that
+// should be suppressed, in which case it returns nothing.
+func (f *DedupFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if entry.Level != log.WarnLevel && entry.Level != log.ErrorLevel {
+		return f.Formatter.Format(entry)
+	}
+
+	key := dedupKey{entry.Message, callerLocation()}
+
+	f.mutex.Lock()
+	state, ok := f.seen[key]
+	if ok && entry.Time.Sub(state.logged) < f.window {
+		state.repeated++
+		f.mutex.Unlock()
+		return nil, nil
+	}
+
+	if !ok {
+		f.forgetExpired(entry.Time)
+		state = &dedupState{}
+		f.seen[key] = state
+	}
+
+	repeated := state.repeated
+	state.logged = entry.Time
+	state.repeated = 0
+	f.mutex.Unlock()
+
+	if repeated > 0 {
+		summarized := *entry
+		summarized.Message = fmt.Sprintf("%s (repeated %d times)",
+			entry.Message, repeated)
+		entry = &summarized
+	}
+	return f.Formatter.Format(entry)
+}
+
+// forgetExpired forgets the messages that were last logged outside the window,
+// if too many messages are tracked.  The caller must hold the mutex.
+func (f *DedupFormatter) forgetExpired(now time.Time) {
+	if len(f.seen) < maxDedupMessages {
+		return
+	}
+
+	for key, state := range f.seen {
+		if now.Sub(state.logged) >= f.window {
+			delete(f.seen, key)
+		}
+	}
+}
+
+// callerLocation returns the file and line of the code that logged the entry
+// being formatted.
+func callerLocation() string {
+	frame := caller()
+	if frame.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+// caller returns the frame of the code that logged the entry being formatted,
+// i.e. the first caller outside of the formatters in this package and logrus.
+func caller() runtime.Frame {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		formatter := strings.HasSuffix(frame.Function, "Formatter.Format") ||
+			strings.HasSuffix(frame.Function, "Formatter).Format")
+		if !formatter && !strings.Contains(frame.Function, "sirupsen/logrus.") {
+			return frame
+		}
+
+		if !more {
+			return runtime.Frame{}
+		}
+	}
+}
+
 // EventTimer is a utility struct that allows us to time how long loops take, as
 // well as how often they are triggered.
 type EventTimer struct {
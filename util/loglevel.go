@@ -0,0 +1,156 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logModules maps the modules whose log level can be set independently to the
+// package that implements them.  Modules include their subpackages, unless the
+// subpackage is a module itself, e.g. the foreman isn't part of the cloud module.
+var logModules = map[string]string{
+	"cloud":     "github.com/kelda/kelda/cloud",
+	"foreman":   "github.com/kelda/kelda/cloud/foreman",
+	"scheduler": "github.com/kelda/kelda/minion/scheduler",
+	"network":   "github.com/kelda/kelda/minion/network",
+}
+
+var logLevels = struct {
+	sync.Mutex
+	base    log.Level
+	modules map[string]log.Level
+}{base: log.InfoLevel, modules: map[string]log.Level{}}
+
+// LogModules returns the names of the modules whose log level can be set with
+// SetModuleLogLevel, in alphabetical order.
+func LogModules() []string {
+	var modules []string
+	for module := range logModules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// LogLevel returns the log level of modules whose level hasn't been set
+// explicitly.
+func LogLevel() log.Level {
+	logLevels.Lock()
+	defer logLevels.Unlock()
+	return logLevels.base
+}
+
+// SetLogLevel sets the log level of modules whose level hasn't been set
+// explicitly, and of code outside of any module.
+func SetLogLevel(level log.Level) {
+	logLevels.Lock()
+	defer logLevels.Unlock()
+	logLevels.base = level
+	updateLoggerLevel()
+}
+
+// SetModuleLogLevel sets the log level of `module`, regardless of the level set
+// by SetLogLevel.
+func SetModuleLogLevel(module string, level log.Level) error {
+	if _, ok := logModules[module]; !ok {
+		return fmt.Errorf("unknown module: %s", module)
+	}
+
+	logLevels.Lock()
+	defer logLevels.Unlock()
+	logLevels.modules[module] = level
+	updateLoggerLevel()
+	return nil
+}
+
+// ResetModuleLogLevel reverts `module` to the level set by SetLogLevel.
+func ResetModuleLogLevel(module string) error {
+	if _, ok := logModules[module]; !ok {
+		return fmt.Errorf("unknown module: %s", module)
+	}
+
+	logLevels.Lock()
+	defer logLevels.Unlock()
+	delete(logLevels.modules, module)
+	updateLoggerLevel()
+	return nil
+}
+
+// ModuleLogLevels returns the log level of each module.
+func ModuleLogLevels() map[string]log.Level {
+	logLevels.Lock()
+	defer logLevels.Unlock()
+
+	levels := map[string]log.Level{}
+	for module := range logModules {
+		levels[module] = moduleLevel(module)
+	}
+	return levels
+}
+
+// updateLoggerLevel sets the level of the logrus logger to the most verbose
+// level of any module, so that logrus doesn't discard entries before the
+// LevelFormatter can decide whether to keep them.  The caller must hold the lock.
+func updateLoggerLevel() {
+	level := logLevels.base
+	for _, moduleLevel := range logLevels.modules {
+		if moduleLevel > level {
+			level = moduleLevel
+		}
+	}
+	log.SetLevel(level)
+}
+
+// moduleLevel returns the log level of `module`.  The caller must hold the lock.
+func moduleLevel(module string) log.Level {
+	if level, ok := logLevels.modules[module]; ok {
+		return level
+	}
+	return logLevels.base
+}
+
+// A LevelFormatter wraps a formatter so that entries are discarded if they're
+// more verbose than the log level of the module that logged them.
+type LevelFormatter struct {
+	log.Formatter
+}
+
+// Format formats `entry` with the wrapped formatter, unless it's more verbose
+// than the level of its module, in which case it returns nothing.
+func (f LevelFormatter) Format(entry *log.Entry) ([]byte, error) {
+	logLevels.Lock()
+	// Finding the module of the caller is relatively slow, so it's skipped
+	// when no module has its own level.
+	var level log.Level
+	if len(logLevels.modules) == 0 {
+		level = logLevels.base
+	} else {
+		level = moduleLevel(callerModule())
+	}
+	logLevels.Unlock()
+
+	if entry.Level > level {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+// callerModule returns the module of the code that logged the entry being
+// formatted, or the empty string if it isn't in a module.
+func callerModule() string {
+	function := caller().Function
+
+	var module, pkg string
+	for name, modulePkg := range logModules {
+		if len(modulePkg) > len(pkg) &&
+			(strings.HasPrefix(function, modulePkg+".") ||
+				strings.HasPrefix(function, modulePkg+"/")) {
+			module, pkg = name, modulePkg
+		}
+	}
+	return module
+}
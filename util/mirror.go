@@ -0,0 +1,82 @@
+package util
+
+import (
+	"net"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+)
+
+// MirrorImage returns the name of `image` within the registry `mirror`, which
+// holds copies of the images used by clusters without internet access.  Images
+// hosted on Docker Hub are mirrored at their path (e.g. library/nginx), and images
+// hosted elsewhere are mirrored at their registry's domain followed by their path
+// (e.g. quay.io/coreos/etcd).  Images hosted on registries that are addressed by
+// IP, such as the cluster's own registry, are already reachable, so they're
+// returned unchanged, as are malformed images and all images if `mirror` is empty.
+func MirrorImage(mirror, image string) string {
+	if mirror == "" {
+		return image
+	}
+
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+
+	domain := reference.Domain(named)
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return image
+	}
+
+	path := reference.Path(named)
+	if domain != "docker.io" {
+		path = domain + "/" + path
+	}
+
+	// The tag or digest, if any, follows the name.
+	suffix := strings.TrimPrefix(named.String(), named.Name())
+	return mirror + "/" + path + suffix
+}
+
+// CachedImage returns the name of `image` within the registry `cache`, which
+// proxies Docker Hub and keeps copies of the images pulled through it.  Images
+// that aren't hosted on Docker Hub can't be pulled through the cache, so they're
+// returned unchanged, as are malformed images and all images if `cache` is empty.
+func CachedImage(cache, image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil || reference.Domain(named) != "docker.io" {
+		return image
+	}
+	return MirrorImage(cache, image)
+}
+
+// ArchImage returns the build of `image` for the CPU architecture `arch`, named as
+// runtime.GOARCH names it.  The images that Quilt runs are built for AMD64, and
+// the builds for other architectures are tagged with the architecture after their
+// tag (e.g. quilt/quilt:1.2.3-arm64), so AMD64 images are returned unchanged, as
+// are malformed images and images pinned to a digest.
+func ArchImage(image, arch string) string {
+	if arch == "" || arch == "amd64" {
+		return image
+	}
+
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return image
+	}
+
+	tag := "latest"
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+		image = strings.TrimSuffix(image, ":"+tag)
+	}
+	return image + ":" + tag + "-" + arch
+}
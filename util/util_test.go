@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -117,3 +118,154 @@ func TestJoinNotifiers(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestMirrorImage(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                    "mirror:5000/library/nginx",
+		"nginx:1.13":               "mirror:5000/library/nginx:1.13",
+		"quilt/quilt:1.2.3":        "mirror:5000/quilt/quilt:1.2.3",
+		"quay.io/coreos/etcd:v3.2": "mirror:5000/quay.io/coreos/etcd:v3.2",
+		"10.0.0.1:5000/app":        "10.0.0.1:5000/app",
+		"10.0.0.1/app:v1":          "10.0.0.1/app:v1",
+		"Malformed":                "Malformed",
+	}
+	for image, exp := range tests {
+		assert.Equal(t, exp, MirrorImage("mirror:5000", image), image)
+	}
+	assert.Equal(t, "nginx", MirrorImage("", "nginx"))
+}
+
+func TestCachedImage(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                    "10.0.0.2:5001/library/nginx",
+		"quilt/quilt:1.2.3":        "10.0.0.2:5001/quilt/quilt:1.2.3",
+		"quay.io/coreos/etcd:v3.2": "quay.io/coreos/etcd:v3.2",
+		"10.0.0.1:5000/app":        "10.0.0.1:5000/app",
+		"Malformed":                "Malformed",
+	}
+	for image, exp := range tests {
+		assert.Equal(t, exp, CachedImage("10.0.0.2:5001", image), image)
+	}
+	assert.Equal(t, "nginx", CachedImage("", "nginx"))
+}
+
+func TestArchImage(t *testing.T) {
+	tests := map[string]string{
+		"quilt/ovs":                  "quilt/ovs:latest-arm64",
+		"quilt/quilt:1.2.3":          "quilt/quilt:1.2.3-arm64",
+		"quay.io/coreos/etcd:v3.0.2": "quay.io/coreos/etcd:v3.0.2-arm64",
+		"10.0.0.1:5000/app":          "10.0.0.1:5000/app:latest-arm64",
+		"Malformed":                  "Malformed",
+	}
+	for image, exp := range tests {
+		assert.Equal(t, exp, ArchImage(image, "arm64"), image)
+	}
+
+	digest := "nginx@sha256:" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	assert.Equal(t, digest, ArchImage(digest, "arm64"))
+	assert.Equal(t, "quilt/ovs", ArchImage("quilt/ovs", "amd64"))
+	assert.Equal(t, "quilt/ovs", ArchImage("quilt/ovs", ""))
+}
+
+func TestDedupFormatter(t *testing.T) {
+	formatter := NewDedupFormatter(Formatter{}, time.Minute)
+	start := time.Unix(0, 0)
+
+	// All entries are formatted from the same location, so they're only
+	// distinguished by their message.
+	format := func(level log.Level, msg string, elapsed time.Duration) string {
+		out, err := formatter.Format(&log.Entry{
+			Level:   level,
+			Message: msg,
+			Time:    start.Add(elapsed),
+			Data:    log.Fields{},
+		})
+		assert.NoError(t, err)
+		return string(out)
+	}
+
+	assert.Contains(t, format(log.WarnLevel, "failed", 0), "failed")
+	assert.Empty(t, format(log.WarnLevel, "failed", time.Second))
+	assert.Empty(t, format(log.WarnLevel, "failed", 30*time.Second))
+
+	// Other messages, and levels below warnings, aren't suppressed.
+	assert.Contains(t, format(log.ErrorLevel, "other", time.Second), "other")
+	assert.Contains(t, format(log.InfoLevel, "failed", time.Second), "failed")
+	assert.Contains(t, format(log.InfoLevel, "failed", time.Second), "failed")
+
+	assert.Contains(t, format(log.WarnLevel, "failed", time.Minute),
+		"failed (repeated 2 times)")
+	assert.Empty(t, format(log.WarnLevel, "failed", time.Minute+time.Second))
+	assert.Contains(t, format(log.WarnLevel, "failed", 3*time.Minute), "failed")
+	assert.NotContains(t, format(log.WarnLevel, "failed", 5*time.Minute),
+		"repeated")
+}
+
+func TestModuleLogLevels(t *testing.T) {
+	defer SetLogLevel(log.InfoLevel)
+	defer ResetModuleLogLevel("network")
+	defer ResetModuleLogLevel("cloud")
+
+	SetLogLevel(log.WarnLevel)
+	assert.NoError(t, SetModuleLogLevel("cloud", log.DebugLevel))
+	assert.NoError(t, SetModuleLogLevel("network", log.ErrorLevel))
+	assert.EqualError(t, SetModuleLogLevel("ovs", log.DebugLevel),
+		"unknown module: ovs")
+
+	// Logrus must pass debug entries on, so that the cloud module can log them.
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	assert.Equal(t, log.WarnLevel, LogLevel())
+	assert.Equal(t, map[string]log.Level{
+		"cloud":     log.DebugLevel,
+		"foreman":   log.WarnLevel,
+		"network":   log.ErrorLevel,
+		"scheduler": log.WarnLevel,
+	}, ModuleLogLevels())
+
+	assert.NoError(t, ResetModuleLogLevel("cloud"))
+	assert.Equal(t, log.ErrorLevel, ModuleLogLevels()["network"])
+	assert.Equal(t, log.WarnLevel, ModuleLogLevels()["cloud"])
+	assert.Equal(t, log.WarnLevel, log.GetLevel())
+}
+
+func TestLevelFormatter(t *testing.T) {
+	defer SetLogLevel(log.InfoLevel)
+
+	// Treat this package as a module, so that the entries formatted by this
+	// test belong to it.
+	oldModules := logModules
+	logModules = map[string]string{
+		"util":  "github.com/kelda/kelda/util",
+		"cloud": "github.com/kelda/kelda/cloud",
+	}
+	defer func() { logModules = oldModules }()
+
+	formatter := LevelFormatter{Formatter{}}
+	format := func(level log.Level) string {
+		out, err := formatter.Format(&log.Entry{
+			Level:   level,
+			Message: "msg",
+			Data:    log.Fields{},
+		})
+		assert.NoError(t, err)
+		return string(out)
+	}
+
+	SetLogLevel(log.InfoLevel)
+	assert.Contains(t, format(log.InfoLevel), "msg")
+	assert.Empty(t, format(log.DebugLevel))
+
+	assert.NoError(t, SetModuleLogLevel("util", log.DebugLevel))
+	assert.Contains(t, format(log.DebugLevel), "msg")
+
+	assert.NoError(t, SetModuleLogLevel("util", log.ErrorLevel))
+	assert.Empty(t, format(log.InfoLevel))
+	assert.Contains(t, format(log.ErrorLevel), "msg")
+
+	// Other modules don't affect this one.
+	assert.NoError(t, ResetModuleLogLevel("util"))
+	assert.NoError(t, SetModuleLogLevel("cloud", log.DebugLevel))
+	assert.Empty(t, format(log.DebugLevel))
+	assert.NoError(t, ResetModuleLogLevel("cloud"))
+}
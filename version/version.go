@@ -1,4 +1,34 @@
 package version
 
+import "fmt"
+
 // Version is the Quilt version number.
 const Version = "dev"
+
+// APIVersion is the version of the protocol spoken between the CLI, the daemon,
+// and the minions.  It must be incremented whenever the protobuf schemas change,
+// and MinAPIVersion raised to it whenever the change isn't backwards compatible.
+const APIVersion int32 = 12
+
+// MinAPIVersion is the oldest API version that this build can communicate with.
+// Builds that predate version negotiation report version 0.  Version 10 signs
+// MinionConfigs as a whole, and minions reject configs that aren't.  Version 11
+// restricts workers to their own keys in etcd, deletes the legacy containers key,
+// and adds the self-hosted daemon settings to MinionConfigs.
+const MinAPIVersion int32 = 11
+
+// CheckCompatible returns an error if this build can't communicate with `peer`,
+// which speaks API version `peerVersion` and can communicate with versions as old
+// as `peerMin`.
+func CheckCompatible(peer string, peerVersion, peerMin int32) error {
+	switch {
+	case peerVersion < MinAPIVersion:
+		return fmt.Errorf("%s speaks API version %d, but this build requires "+
+			"version %d or later; upgrade the %s", peer, peerVersion,
+			MinAPIVersion, peer)
+	case APIVersion < peerMin:
+		return fmt.Errorf("%s requires API version %d or later, but this build "+
+			"speaks version %d; upgrade this build", peer, peerMin, APIVersion)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package version
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompatible(t *testing.T) {
+	assert.NoError(t, CheckCompatible("daemon", APIVersion, MinAPIVersion))
+	assert.NoError(t, CheckCompatible("daemon", MinAPIVersion, MinAPIVersion))
+
+	assert.EqualError(t, CheckCompatible("daemon", MinAPIVersion-1, 0),
+		fmt.Sprintf("daemon speaks API version %d, but this build requires "+
+			"version %d or later; upgrade the daemon", MinAPIVersion-1,
+			MinAPIVersion))
+	assert.EqualError(t, CheckCompatible("client", APIVersion+1,
+		APIVersion+1), fmt.Sprintf("client requires API version %d or "+
+		"later, but this build speaks version %d; upgrade this build",
+		APIVersion+1, APIVersion))
+}
+
+// Raising MinAPIVersion refuses every older peer, so it should only change along
+// with a note in version.go explaining which incompatible change required it.
+func TestMinAPIVersion(t *testing.T) {
+	assert.EqualValues(t, 12, APIVersion)
+	assert.EqualValues(t, 11, MinAPIVersion)
+}